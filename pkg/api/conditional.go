@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NotModified sets the validators of a resource last changed at modified, an ETag and
+// Last-Modified derived from that time, and reports whether the request's conditional headers
+// show the client already has it, in which case a bodyless 304 has been sent and the handler is
+// done. If-None-Match is checked in preference to If-Modified-Since, as RFC 9110 requires.
+func NotModified(w http.ResponseWriter, r *http.Request, modified time.Time) bool {
+	etag := `W/"` + strconv.FormatInt(modified.UnixNano(), 36) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "no-cache") // clients may cache but must revalidate
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		if !etagMatches(header, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		// Last-Modified drops fractions of a second, so the times are compared to the second
+		if err != nil || modified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	w.Header().Del("Content-Type")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether an If-None-Match header value names etag, comparing weakly
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
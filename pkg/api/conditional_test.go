@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2026, 3, 1, 12, 0, 0, 500_000_000, time.UTC)
+	check := func(headers map[string]string) (*httptest.ResponseRecorder, bool) {
+		req := httptest.NewRequest(http.MethodGet, "/orders/o1", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		return rec, NotModified(rec, req, modified)
+	}
+
+	rec, done := check(nil)
+	etag, lastModified := rec.Header().Get("ETag"), rec.Header().Get("Last-Modified")
+	if done || etag == "" || lastModified != "Sun, 01 Mar 2026 12:00:00 GMT" {
+		t.Fatalf("expected validators and no 304 for an unconditional request, got %v %q %q", done, etag, lastModified)
+	}
+
+	if rec, done := check(map[string]string{"If-None-Match": `"other", ` + etag}); !done || rec.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching ETag, got %v %d", done, rec.Code)
+	}
+	if _, done := check(map[string]string{"If-Modified-Since": lastModified}); !done {
+		t.Error("expected 304 when unmodified since Last-Modified")
+	}
+	if _, done := check(map[string]string{"If-Modified-Since": modified.Add(-time.Second).Format(http.TimeFormat)}); done {
+		t.Error("expected the resource sent when modified since the date given")
+	}
+	// If-None-Match wins over If-Modified-Since
+	if _, done := check(map[string]string{"If-None-Match": `W/"stale"`, "If-Modified-Since": lastModified}); done {
+		t.Error("expected a stale ETag to send the resource whatever If-Modified-Since says")
+	}
+	if _, done := check(map[string]string{"If-Modified-Since": "yesterday"}); done {
+		t.Error("expected an unreadable date to be ignored")
+	}
+}
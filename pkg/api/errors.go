@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details, sent instead of the usual
+// envelope to clients that accept it
+const ProblemContentType = "application/problem+json"
+
+// Error codes shared by every service. Handlers that don't give a code get one named after the
+// status, such as NOT_FOUND or TOO_MANY_REQUESTS.
+const (
+	CodeInvalidJSON      = "INVALID_JSON"      // the body couldn't be decoded; details is a BodyError
+	CodeValidationFailed = "VALIDATION_FAILED" // fields broke their validate tags; details lists them
+)
+
+// StatusCode returns the error code for a status that has no more specific one, such as
+// INTERNAL_SERVER_ERROR for 500
+func StatusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "ERROR"
+	}
+	words := strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	return strings.ToUpper(strings.Join(words, "_"))
+}
+
+// WriteErrorCode sends a standardized error response with a stable code clients can branch on,
+// such as ORDER_INSUFFICIENT_STOCK, rather than matching the message
+func WriteErrorCode(w http.ResponseWriter, statusCode int, code, message string) {
+	WriteErrorResponse(w, statusCode, Response[Unpaged]{Code: code, Error: message})
+}
+
+// WriteErrorResponse sends an error response carrying data or details as well as its code and
+// message. A missing code is filled in from the status. Writers from ProblemWriter get problem
+// details instead, with the code, data, and details as extension members.
+func WriteErrorResponse(w http.ResponseWriter, statusCode int, response Response[Unpaged]) {
+	response.Success = false
+	if response.Code == "" {
+		response.Code = StatusCode(statusCode)
+	}
+	if pw := findProblemWriter(w); pw != nil {
+		pw.writeProblem(w, statusCode, response)
+		return
+	}
+	WriteJSON(w, statusCode, response)
+}
+
+// Problem is an RFC 7807 problem details body
+type Problem struct {
+	Type     string      `json:"type"`
+	Title    string      `json:"title"`
+	Status   int         `json:"status"`
+	Detail   string      `json:"detail,omitempty"`
+	Instance string      `json:"instance,omitempty"`
+	Code     string      `json:"code"`
+	Data     interface{} `json:"data,omitempty"`
+	Errors   interface{} `json:"errors,omitempty"` // the envelope's details, such as every field that failed validation
+}
+
+// AcceptsProblems reports whether the request's Accept header lists the problem details media type
+func AcceptsProblems(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaType := range strings.Split(accept, ",") {
+			mediaType, _, _ = strings.Cut(mediaType, ";")
+			if strings.EqualFold(strings.TrimSpace(mediaType), ProblemContentType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ProblemWriter wraps w so errors written through it, or through writers wrapped around it, are
+// sent as problem details. instance is the path of the request the response is for.
+func ProblemWriter(w http.ResponseWriter, instance string) http.ResponseWriter {
+	return &problemWriter{ResponseWriter: w, instance: instance}
+}
+
+// problemWriter marks a response as going to a client that wants problem details
+type problemWriter struct {
+	http.ResponseWriter
+	instance string // the path the problem happened at
+}
+
+// Unwrap returns the writer underneath, for http.ResponseController and WriteErrorResponse
+func (pw *problemWriter) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
+
+// Flush passes flushes on, for handlers that stream their response
+func (pw *problemWriter) Flush() {
+	if flusher, ok := pw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// writeProblem sends an error response as problem details through w, which wraps pw
+func (pw *problemWriter) writeProblem(w http.ResponseWriter, statusCode int, response Response[Unpaged]) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(statusCode),
+		Status:   statusCode,
+		Detail:   response.Error,
+		Instance: pw.instance,
+		Code:     response.Code,
+		Data:     response.Data,
+		Errors:   response.Details,
+	})
+}
+
+// findProblemWriter looks through the writers middleware wrapped around w for a problemWriter
+func findProblemWriter(w http.ResponseWriter) *problemWriter {
+	for {
+		switch writer := w.(type) {
+		case *problemWriter:
+			return writer
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = writer.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusCode_NamesTheStatus(t *testing.T) {
+	for status, want := range map[int]string{
+		http.StatusNotFound:            "NOT_FOUND",
+		http.StatusInternalServerError: "INTERNAL_SERVER_ERROR",
+		http.StatusTooManyRequests:     "TOO_MANY_REQUESTS",
+		http.StatusTeapot:              "I_M_A_TEAPOT",
+		599:                            "ERROR",
+	} {
+		if got := StatusCode(status); got != want {
+			t.Errorf("%d: expected %s, got %s", status, want, got)
+		}
+	}
+}
+
+func TestWriteErrorCode_KeepsTheGivenCode(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteErrorCode(rec, http.StatusConflict, "ORDER_INSUFFICIENT_STOCK", "Insufficient stock")
+
+	if body := rec.Body.String(); body != `{"success":false,"error":"Insufficient stock","code":"ORDER_INSUFFICIENT_STOCK"}`+"\n" {
+		t.Fatalf("unexpected body %s", body)
+	}
+}
+
+// wrapper stands in for middleware that wraps the response writer, such as a status recorder
+type wrapper struct {
+	http.ResponseWriter
+}
+
+func (w *wrapper) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func TestProblemWriter_SendsProblemDetailsThroughWrappingWriters(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &wrapper{ProblemWriter(rec, "/orders")}
+	WriteErrorResponse(w, http.StatusBadRequest, Response[Unpaged]{
+		Code:    CodeValidationFailed,
+		Error:   "Validation failed",
+		Details: []string{"items is required"},
+	})
+
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Type") != ProblemContentType {
+		t.Fatalf("expected a 400 problem response, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var problem struct {
+		Problem
+		Errors []string `json:"errors"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Type != "about:blank" || problem.Title != "Bad Request" || problem.Status != http.StatusBadRequest ||
+		problem.Detail != "Validation failed" || problem.Instance != "/orders" || problem.Code != CodeValidationFailed {
+		t.Errorf("unexpected problem %+v", problem.Problem)
+	}
+	if len(problem.Errors) != 1 {
+		t.Errorf("expected the details as errors, got %v", problem.Errors)
+	}
+}
+
+func TestAcceptsProblems_MatchesTheMediaTypeAnywhereInAccept(t *testing.T) {
+	for accept, want := range map[string]bool{
+		"application/problem+json":                         true,
+		"application/json, application/problem+json;q=0.9": true,
+		"application/json":                                 false,
+		"":                                                 false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		r.Header.Set("Accept", accept)
+		if got := AcceptsProblems(r); got != want {
+			t.Errorf("Accept %q: expected %v, got %v", accept, want, got)
+		}
+	}
+}
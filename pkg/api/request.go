@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"ecommerce/pkg/validation"
+)
+
+// IdempotencyKeyHeader carries a key the client picks for a request it may send again, so that a
+// retry gets the outcome of the first attempt instead of repeating it
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// DefaultMaxBodyBytes is how large a JSON request body may be until SetMaxBodyBytes changes it
+const DefaultMaxBodyBytes = 1 << 20
+
+var maxBodyBytes atomic.Int64
+
+// unknownFieldPrefix starts the error encoding/json returns for a field the target doesn't have;
+// the package has no error type for it
+const unknownFieldPrefix = "json: unknown field "
+
+// SetMaxBodyBytes changes how large a JSON request body DecodeJSON accepts; 0 or less restores the default
+func SetMaxBodyBytes(limit int64) {
+	maxBodyBytes.Store(limit)
+}
+
+// MaxBodyBytes returns how large a JSON request body DecodeJSON accepts
+func MaxBodyBytes() int64 {
+	if limit := maxBodyBytes.Load(); limit > 0 {
+		return limit
+	}
+	return DefaultMaxBodyBytes
+}
+
+// Reasons a request body is rejected, sent as the code of a BodyError
+const (
+	BodyTooLarge     = "too_large"
+	BodyEmpty        = "empty"
+	BodyMalformed    = "malformed"
+	BodyUnknownField = "unknown_field"
+	BodyWrongType    = "wrong_type"
+	BodyTrailingData = "trailing_data"
+)
+
+// BodyError is sent in the details of a rejected request body to say what was wrong with it
+type BodyError struct {
+	Code   string `json:"code"`
+	Field  string `json:"field,omitempty"`  // the field that was unknown or of the wrong type
+	Offset int64  `json:"offset,omitempty"` // the byte the body stopped making sense at
+	Limit  int64  `json:"limit,omitempty"`  // the most bytes a body may have
+}
+
+// DecodeJSON reads a JSON request body into v and checks it against v's validate tags. It rejects
+// bodies over MaxBodyBytes with 413, and empty or malformed bodies, fields v doesn't have, values
+// of the wrong type, and anything after the JSON value with 400, writing a BodyError in the details.
+// Fields that break their validate tags get 400 "Validation failed" with a validation.FieldError
+// for each in the details. It reports whether v was filled in and is valid.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if problem := decodeJSON(w, r, v); problem != nil {
+		writeBodyError(w, problem)
+		return false
+	}
+	return Validate(w, v)
+}
+
+// DecodeOptionalJSON is DecodeJSON for bodies that may be left out, leaving v untouched and
+// unchecked when the body is empty
+func DecodeOptionalJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	problem := decodeJSON(w, r, v)
+	if problem == nil {
+		return Validate(w, v)
+	}
+	if problem.Code != BodyEmpty {
+		writeBodyError(w, problem)
+		return false
+	}
+	return true
+}
+
+// Validate checks v against its validate tags, sending 400 with the fields that broke them if any
+// did. It reports whether v is valid.
+func Validate(w http.ResponseWriter, v interface{}) bool {
+	fields := validation.Struct(v)
+	if len(fields) == 0 {
+		return true
+	}
+	WriteErrorResponse(w, http.StatusBadRequest, Response[Unpaged]{Code: CodeValidationFailed, Error: "Validation failed", Details: fields})
+	return false
+}
+
+// writeBodyError sends 413 for a body over the limit and 400 for anything else wrong with it
+func writeBodyError(w http.ResponseWriter, problem *BodyError) {
+	if problem.Code == BodyTooLarge {
+		WriteErrorResponse(w, http.StatusRequestEntityTooLarge, Response[Unpaged]{Error: "Request body too large", Details: problem})
+		return
+	}
+	WriteErrorResponse(w, http.StatusBadRequest, Response[Unpaged]{Code: CodeInvalidJSON, Error: "Invalid JSON payload", Details: problem})
+}
+
+// decodeJSON decodes the body into v, describing why it couldn't
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) *BodyError {
+	limit := MaxBodyBytes()
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(v)
+	if err == nil {
+		// Anything after the value is rejected too, so {"a":1}{"a":2} isn't half read
+		if _, err = decoder.Token(); errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err == nil {
+			return &BodyError{Code: BodyTrailingData, Offset: decoder.InputOffset()}
+		}
+	}
+
+	var tooLarge *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &tooLarge):
+		return &BodyError{Code: BodyTooLarge, Limit: limit}
+	case errors.Is(err, io.EOF):
+		return &BodyError{Code: BodyEmpty}
+	case errors.As(err, &syntaxErr):
+		return &BodyError{Code: BodyMalformed, Offset: syntaxErr.Offset}
+	case errors.As(err, &typeErr):
+		return &BodyError{Code: BodyWrongType, Field: typeErr.Field, Offset: typeErr.Offset}
+	case strings.HasPrefix(err.Error(), unknownFieldPrefix):
+		return &BodyError{Code: BodyUnknownField, Field: strings.Trim(strings.TrimPrefix(err.Error(), unknownFieldPrefix), `"`)}
+	default:
+		return &BodyError{Code: BodyMalformed, Offset: decoder.InputOffset()}
+	}
+}
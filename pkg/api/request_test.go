@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"ecommerce/pkg/validation"
+)
+
+type itemRequest struct {
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+}
+
+func decodeBody(t *testing.T, body string) (*httptest.ResponseRecorder, bool) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	var req itemRequest
+	ok := DecodeJSON(rec, httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(body)), &req)
+	return rec, ok
+}
+
+func bodyError(t *testing.T, rec *httptest.ResponseRecorder) BodyError {
+	t.Helper()
+	var response struct {
+		Error   string    `json:"error"`
+		Details BodyError `json:"details"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	return response.Details
+}
+
+func TestDecodeJSON_AcceptsAKnownBody(t *testing.T) {
+	rec := httptest.NewRecorder()
+	var req itemRequest
+	body := strings.NewReader(`{"name":"mug","quantity":2}` + "\n")
+	if !DecodeJSON(rec, httptest.NewRequest(http.MethodPost, "/items", body), &req) {
+		t.Fatalf("expected the body to decode, got %d %s", rec.Code, rec.Body)
+	}
+	if req.Name != "mug" || req.Quantity != 2 {
+		t.Errorf("unexpected request %+v", req)
+	}
+}
+
+func TestDecodeJSON_RejectsBadBodies(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want BodyError
+	}{
+		{"empty", "", BodyError{Code: BodyEmpty}},
+		{"malformed", `{"name":`, BodyError{Code: BodyMalformed}},
+		{"unknown field", `{"name":"mug","colour":"red"}`, BodyError{Code: BodyUnknownField, Field: "colour"}},
+		{"wrong type", `{"quantity":"two"}`, BodyError{Code: BodyWrongType, Field: "quantity"}},
+		{"trailing data", `{"name":"mug"}{"name":"cup"}`, BodyError{Code: BodyTrailingData}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, ok := decodeBody(t, tt.body)
+			if ok || rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d", rec.Code)
+			}
+			got := bodyError(t, rec)
+			if got.Code != tt.want.Code || got.Field != tt.want.Field {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDecodeJSON_RejectsBodiesOverTheLimit(t *testing.T) {
+	SetMaxBodyBytes(16)
+	defer SetMaxBodyBytes(0)
+
+	rec, ok := decodeBody(t, `{"name":"a much longer name than sixteen bytes"}`)
+	if ok || rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", rec.Code)
+	}
+	if got := bodyError(t, rec); got.Code != BodyTooLarge || got.Limit != 16 {
+		t.Errorf("expected the limit in the details, got %+v", got)
+	}
+	if rec, ok := decodeBody(t, `{"quantity":1}`); !ok {
+		t.Errorf("expected a body within the limit to decode, got %d", rec.Code)
+	}
+}
+
+func TestDecodeOptionalJSON_AllowsAnEmptyBody(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := itemRequest{Name: "unchanged"}
+	if !DecodeOptionalJSON(rec, httptest.NewRequest(http.MethodPost, "/items", http.NoBody), &req) {
+		t.Fatalf("expected an empty body to be allowed, got %d", rec.Code)
+	}
+	if req.Name != "unchanged" {
+		t.Errorf("expected the request to be left alone, got %+v", req)
+	}
+
+	rec = httptest.NewRecorder()
+	body := strings.NewReader(`{"note":"ok"}`)
+	if DecodeOptionalJSON(rec, httptest.NewRequest(http.MethodPost, "/items", body), &req) || rec.Code != http.StatusBadRequest {
+		t.Errorf("expected an unknown field to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestDecodeJSON_RejectsFieldsThatBreakTheirValidateTags(t *testing.T) {
+	var req struct {
+		Name     string `json:"name" validate:"required,min=2"`
+		Quantity int    `json:"quantity" validate:"min=1"`
+	}
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"name":"x","quantity":0}`)
+	if DecodeJSON(rec, httptest.NewRequest(http.MethodPost, "/items", body), &req) || rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+
+	var response struct {
+		Error   string                  `json:"error"`
+		Details []validation.FieldError `json:"details"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error != "Validation failed" || len(response.Details) != 2 {
+		t.Fatalf("expected both fields in the details, got %+v", response)
+	}
+	if got := response.Details[0]; got.Field != "name" || got.Message != "name must be at least 2 characters long" {
+		t.Errorf("unexpected field error %+v", got)
+	}
+}
@@ -0,0 +1,33 @@
+// Package api holds the response envelope and helpers shared by every service's HTTP handlers
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Response represents a standard API response. P is the type a service describes pages of results with.
+type Response[P any] struct {
+	Success    bool        `json:"success"`
+	Message    string      `json:"message,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Code       string      `json:"code,omitempty"`    // stable name for the error, such as ORDER_NOT_FOUND
+	Details    interface{} `json:"details,omitempty"` // more about an error, such as every rule a request broke
+	Pagination *P          `json:"pagination,omitempty"`
+}
+
+// Unpaged is the pagination type of services that don't page their results
+type Unpaged struct{}
+
+// WriteJSON sends v as JSON with the given status code
+func WriteJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
+
+// WriteError sends a standardized error response, coded after its status
+func WriteError(w http.ResponseWriter, statusCode int, message string) {
+	WriteErrorResponse(w, statusCode, Response[Unpaged]{Error: message})
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteError_SendsTheStandardEnvelope(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteError(rec, http.StatusNotFound, "Order not found")
+
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected a 404 JSON response, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	// Empty fields, pagination included, are left out
+	if body := rec.Body.String(); body != `{"success":false,"error":"Order not found","code":"NOT_FOUND"}`+"\n" {
+		t.Fatalf("unexpected body %s", body)
+	}
+}
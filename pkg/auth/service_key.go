@@ -0,0 +1,208 @@
+// Package auth verifies the service keys other services call with, checking them with user service,
+// which issues them
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"ecommerce/pkg/api"
+	"ecommerce/pkg/requestid"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceKeyHeader is the header other services use to present their API key
+const ServiceKeyHeader = "X-Service-Key"
+
+type contextKey string
+
+const serviceContextKey contextKey = "auth.service"
+
+var errInvalidServiceKey = errors.New("Invalid service key")
+
+// cachedVerification remembers the outcome of a key check until expiresAt
+type cachedVerification struct {
+	service   string
+	valid     bool
+	expiresAt time.Time
+}
+
+// ServiceKeyVerifier validates X-Service-Key headers against user service, which
+// issues the keys. Results are cached briefly so every internal call does not
+// cost an extra round trip.
+type ServiceKeyVerifier struct {
+	httpClient *http.Client
+	verifyURL  string
+	ttl        time.Duration
+	cache      map[string]cachedVerification
+	mutex      sync.Mutex // guards verifyURL and cache
+}
+
+// NewServiceKeyVerifier creates a verifier that checks keys with the user service
+func NewServiceKeyVerifier(userServiceURL string, ttl time.Duration) *ServiceKeyVerifier {
+	return &ServiceKeyVerifier{
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+		verifyURL: userServiceURL + "/v1/internal/service-keys/verify",
+		ttl:       ttl,
+		cache:     make(map[string]cachedVerification),
+	}
+}
+
+// UseTLS makes the verifier call user service over TLS with config, presenting this service's
+// certificate; user service's URL must then be https. Call it before the verifier is used.
+func (v *ServiceKeyVerifier) UseTLS(config *tls.Config) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	v.httpClient.Transport = transport
+}
+
+// SetUserServiceURL points the verifier at a user service that has moved. Cached results are
+// dropped, since the keys they vouch for were checked with the old one.
+func (v *ServiceKeyVerifier) SetUserServiceURL(userServiceURL string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.verifyURL = userServiceURL + "/v1/internal/service-keys/verify"
+	v.cache = make(map[string]cachedVerification)
+}
+
+// Verify returns the name of the service that owns the key. ctx is the request the key came with.
+func (v *ServiceKeyVerifier) Verify(ctx context.Context, key string) (string, error) {
+	if key == "" {
+		return "", errInvalidServiceKey
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	cacheKey := hex.EncodeToString(sum[:])
+
+	v.mutex.Lock()
+	cached, found := v.cache[cacheKey]
+	v.mutex.Unlock()
+	if found && time.Now().Before(cached.expiresAt) {
+		if !cached.valid {
+			return "", errInvalidServiceKey
+		}
+		return cached.service, nil
+	}
+
+	service, valid, err := v.verifyRemote(ctx, key)
+	if err != nil {
+		// Do not cache transport failures; the next call will retry
+		return "", err
+	}
+
+	v.mutex.Lock()
+	v.cache[cacheKey] = cachedVerification{service: service, valid: valid, expiresAt: time.Now().Add(v.ttl)}
+	v.mutex.Unlock()
+
+	if !valid {
+		return "", errInvalidServiceKey
+	}
+	return service, nil
+}
+
+// verifyRemote asks user service whether the key is valid
+func (v *ServiceKeyVerifier) verifyRemote(ctx context.Context, key string) (string, bool, error) {
+	payload, _ := json.Marshal(map[string]string{"key": key})
+	v.mutex.Lock()
+	verifyURL := v.verifyURL
+	v.mutex.Unlock()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, verifyURL, bytes.NewReader(payload))
+	if err != nil {
+		return "", false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	requestid.Propagate(req)
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to verify service key: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return "", false, nil
+	default:
+		return "", false, fmt.Errorf("key verification returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data struct {
+			Service string `json:"service"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", false, fmt.Errorf("failed to decode key verification: %w", err)
+	}
+	return result.Data.Service, true, nil
+}
+
+// Authenticate validates the X-Service-Key header when present and records the calling service
+func (v *ServiceKeyVerifier) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(ServiceKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		service, err := v.Verify(r.Context(), key)
+		if err != nil {
+			if errors.Is(err, errInvalidServiceKey) {
+				api.WriteError(w, http.StatusUnauthorized, err.Error())
+			} else {
+				api.WriteError(w, http.StatusServiceUnavailable, "Unable to verify service key")
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithService(r.Context(), service)))
+	})
+}
+
+// AuthenticateCall validates the service key a gRPC call came with and records the calling service
+func (v *ServiceKeyVerifier) AuthenticateCall(ctx context.Context, key string) (context.Context, error) {
+	service, err := v.Verify(ctx, key)
+	if err != nil {
+		if errors.Is(err, errInvalidServiceKey) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil, status.Error(codes.Unavailable, "Unable to verify service key")
+	}
+	return WithService(ctx, service), nil
+}
+
+// RequireService rejects requests that did not present a valid service key
+func (v *ServiceKeyVerifier) RequireService(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ServiceFromContext(r.Context()) == "" {
+			api.WriteError(w, http.StatusUnauthorized, "Service key required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WithService returns a copy of ctx carrying the name of the calling service
+func WithService(ctx context.Context, service string) context.Context {
+	return context.WithValue(ctx, serviceContextKey, service)
+}
+
+// ServiceFromContext returns the calling service name, or "" for end-user requests
+func ServiceFromContext(ctx context.Context) string {
+	service, _ := ctx.Value(serviceContextKey).(string)
+	return service
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newFakeUserService(t *testing.T, calls *int32) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		var req struct {
+			Key string `json:"key"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Key != "sk_valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"success":false,"error":"Invalid service key"}`))
+			return
+		}
+		w.Write([]byte(`{"success":true,"data":{"service":"user-service"}}`))
+	}))
+}
+
+func TestServiceKeyVerifier_RequireService(t *testing.T) {
+	var calls int32
+	server := newFakeUserService(t, &calls)
+	defer server.Close()
+
+	verifier := NewServiceKeyVerifier(server.URL, time.Minute)
+	var caller string
+	handler := verifier.Authenticate(verifier.RequireService(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller = ServiceFromContext(r.Context())
+	})))
+
+	cases := []struct {
+		name   string
+		key    string
+		expect int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong", "sk_wrong", http.StatusUnauthorized},
+		{"valid", "sk_valid", http.StatusOK},
+		{"valid cached", "sk_valid", http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/internal", nil)
+		if tc.key != "" {
+			req.Header.Set(ServiceKeyHeader, tc.key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.expect {
+			t.Errorf("%s: expected %d got %d", tc.name, tc.expect, rec.Code)
+		}
+	}
+	if caller != "user-service" {
+		t.Errorf("expected caller user-service, got %q", caller)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 remote verifications thanks to caching, got %d", calls)
+	}
+}
+
+func TestServiceKeyVerifier_UserServiceDown(t *testing.T) {
+	verifier := NewServiceKeyVerifier("http://127.0.0.1:1", time.Minute)
+	handler := verifier.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(ServiceKeyHeader, "sk_valid")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 got %d", rec.Code)
+	}
+}
+
+func TestServiceKeyVerifier_AuthenticateCall(t *testing.T) {
+	var calls int32
+	server := newFakeUserService(t, &calls)
+	defer server.Close()
+
+	verifier := NewServiceKeyVerifier(server.URL, time.Minute)
+	ctx, err := verifier.AuthenticateCall(context.Background(), "sk_valid")
+	if err != nil || ServiceFromContext(ctx) != "user-service" {
+		t.Fatalf("expected the calling service recorded, got %v", err)
+	}
+	if _, err := verifier.AuthenticateCall(context.Background(), "sk_wrong"); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for a wrong key, got %v", err)
+	}
+
+	down := NewServiceKeyVerifier("http://127.0.0.1:1", time.Minute)
+	if _, err := down.AuthenticateCall(context.Background(), "sk_valid"); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable when keys can't be checked, got %v", err)
+	}
+}
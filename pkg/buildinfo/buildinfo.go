@@ -0,0 +1,55 @@
+// Package buildinfo tells which build of a service is running. The version, commit, and build time
+// are stamped at build time, as the Dockerfiles do:
+//
+//	go build -ldflags "-X ecommerce/pkg/buildinfo.version=1.4.0 -X ecommerce/pkg/buildinfo.commit=$(git rev-parse HEAD) -X ecommerce/pkg/buildinfo.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package buildinfo
+
+import (
+	"runtime/debug"
+)
+
+// Stamped at build time; see the package comment
+var version, commit, buildTime string
+
+// Info is what a binary was built from
+type Info struct {
+	Version   string `json:"version"`              // semantic version, or dev when none was stamped
+	Commit    string `json:"commit,omitempty"`     // git SHA
+	BuildTime string `json:"build_time,omitempty"` // RFC 3339
+}
+
+// current is read once; the stamped values are in place before package variables are initialized
+var current = read()
+
+// Get returns what the running binary was built from
+func Get() Info {
+	return current
+}
+
+// String gives the version with the short commit as build metadata, such as 1.4.0+3f2c1a9b7d4e
+func (i Info) String() string {
+	if len(i.Commit) < 12 {
+		return i.Version
+	}
+	return i.Version + "+" + i.Commit[:12]
+}
+
+// read takes the stamped values. Building from a git checkout without them, Go records the commit and
+// its time, which stand in for the build time.
+func read() Info {
+	info := Info{Version: version, Commit: commit, BuildTime: buildTime}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+	return info
+}
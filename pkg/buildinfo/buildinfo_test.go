@@ -0,0 +1,21 @@
+package buildinfo
+
+import "testing"
+
+func TestInfo_String(t *testing.T) {
+	for info, want := range map[Info]string{
+		{Version: "1.4.0", Commit: "3f2c1a9b7d4e5f60718293a4b5c6d7e8f9012345"}: "1.4.0+3f2c1a9b7d4e",
+		{Version: "1.4.0"}:              "1.4.0",
+		{Version: "dev", Commit: "abc"}: "dev",
+	} {
+		if got := info.String(); got != want {
+			t.Errorf("%+v: expected %q, got %q", info, want, got)
+		}
+	}
+}
+
+func TestGet_DefaultsToDev(t *testing.T) {
+	if version == "" && Get().Version != "dev" {
+		t.Errorf("expected dev without a stamped version, got %q", Get().Version)
+	}
+}
@@ -0,0 +1,117 @@
+// Package cache keeps copies of slow-to-fetch values in Redis for a while, so every instance of a
+// service shares them and an update made through one instance can invalidate them for all
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"ecommerce/pkg/metrics"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrMiss is returned by Get when the key isn't cached
+var ErrMiss = errors.New("cache miss")
+
+// DefaultTTL is how long values stay cached unless configured otherwise
+const DefaultTTL = time.Minute
+
+var lookups = metrics.NewCounterVec("cache_lookups_total",
+	"Cache lookups by keyspace and result (hit, miss, or error)", "keyspace", "result")
+
+// Cache reads and writes JSON values in Redis. Every key is prefixed, so services can share one Redis.
+type Cache struct {
+	client *redis.Client
+	prefix string
+}
+
+// New connects to the Redis at url, such as redis://:password@localhost:6379/0, checks it can be
+// reached, and returns a cache whose keys all start with prefix
+func New(ctx context.Context, url, prefix string) (*Cache, error) {
+	if url == "" {
+		return nil, errors.New("Redis URL is required")
+	}
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	client := redis.NewClient(options)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connecting to Redis: %w", err)
+	}
+	return &Cache{client: client, prefix: prefix}, nil
+}
+
+// Get decodes the value cached at key into out, returning ErrMiss if there is none.
+// keyspace names the kind of value for the lookup metrics.
+func (c *Cache) Get(ctx context.Context, keyspace, key string, out interface{}) error {
+	data, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	switch {
+	case errors.Is(err, redis.Nil):
+		lookups.WithLabelValues(keyspace, "miss").Inc()
+		return ErrMiss
+	case err != nil:
+		lookups.WithLabelValues(keyspace, "error").Inc()
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		lookups.WithLabelValues(keyspace, "error").Inc()
+		return err
+	}
+	lookups.WithLabelValues(keyspace, "hit").Inc()
+	return nil
+}
+
+// Set caches value at key for ttl
+func (c *Cache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, c.prefix+key, data, ttl).Err()
+}
+
+// Delete drops the values cached at keys
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.prefix + key
+	}
+	return c.client.Del(ctx, prefixed...).Err()
+}
+
+// Generation returns the counter at key, zero if it was never bumped. Keys that embed a
+// generation are all invalidated at once by bumping it, without knowing what they were.
+func (c *Cache) Generation(ctx context.Context, key string) (int64, error) {
+	value, err := c.client.Get(ctx, c.prefix+key).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// Bump moves the counter at key to its next generation
+func (c *Cache) Bump(ctx context.Context, key string) error {
+	return c.client.Incr(ctx, c.prefix+key).Err()
+}
+
+// Ping checks Redis can be reached, for the readiness probe
+func (c *Cache) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+// Close releases the connections to Redis
+func (c *Cache) Close() error {
+	return c.client.Close()
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestCache(t *testing.T) (*Cache, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	cache, err := New(context.Background(), "redis://"+server.Addr(), "test:")
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	return cache, server
+}
+
+func TestCache_SetGetAndExpire(t *testing.T) {
+	cache, server := newTestCache(t)
+	ctx := context.Background()
+
+	var missing map[string]int
+	if err := cache.Get(ctx, "things", "a", &missing); !errors.Is(err, ErrMiss) {
+		t.Fatalf("expected a miss, got %v", err)
+	}
+
+	if err := cache.Set(ctx, "a", map[string]int{"stock": 3}, time.Minute); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if !server.Exists("test:a") {
+		t.Error("expected the key to carry the prefix")
+	}
+	var got map[string]int
+	if err := cache.Get(ctx, "things", "a", &got); err != nil || got["stock"] != 3 {
+		t.Errorf("expected the cached value, got %v, %v", got, err)
+	}
+
+	server.FastForward(2 * time.Minute)
+	if err := cache.Get(ctx, "things", "a", &got); !errors.Is(err, ErrMiss) {
+		t.Errorf("expected the value to expire, got %v", err)
+	}
+}
+
+func TestCache_DeleteAndGenerations(t *testing.T) {
+	cache, _ := newTestCache(t)
+	ctx := context.Background()
+
+	cache.Set(ctx, "a", 1, time.Minute)
+	cache.Set(ctx, "b", 2, time.Minute)
+	if err := cache.Delete(ctx, "a", "b"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	var value int
+	if err := cache.Get(ctx, "things", "b", &value); !errors.Is(err, ErrMiss) {
+		t.Errorf("expected deleted values gone, got %v", err)
+	}
+
+	if generation, err := cache.Generation(ctx, "gen"); err != nil || generation != 0 {
+		t.Errorf("expected generation 0 before any bump, got %d, %v", generation, err)
+	}
+	cache.Bump(ctx, "gen")
+	cache.Bump(ctx, "gen")
+	if generation, err := cache.Generation(ctx, "gen"); err != nil || generation != 2 {
+		t.Errorf("expected generation 2, got %d, %v", generation, err)
+	}
+}
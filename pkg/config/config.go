@@ -0,0 +1,388 @@
+// Package config loads a service's settings from defaults, an optional YAML file, environment variables,
+// and command-line flags. Later sources win: a flag overrides the environment, which overrides the file.
+//
+// Settings are named like environment variables, such as USER_SERVICE_TIMEOUT. In the YAML file the same
+// setting is user_service_timeout, or timeout nested under user_service, and on the command line it is
+// -user-service-timeout=5s. The file is named by -config or CONFIG_FILE.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileEnv names the environment variable, and "config" the flag, that point at the YAML file
+const FileEnv = "CONFIG_FILE"
+
+// Config holds the settings a service was started with. Reading a setting that is set but can't be
+// parsed, or that breaks one of its rules, returns the default and records the problem for Err.
+type Config struct {
+	path     string            // the YAML file, if any
+	file     map[string]string // from the YAML file
+	flags    map[string]string // from the command line
+	lookup   func(key string) (string, bool)
+	used     map[string]bool
+	settings map[string]Setting // what each setting read resolved to
+	reading  map[string]bool    // settings read while registering a Tunable
+	errs     []error
+}
+
+// Setting is the value a service uses for a setting and where it came from. Secrets, such as keys and
+// passwords, are shown as [redacted].
+type Setting struct {
+	Key        string `json:"key"`
+	Value      string `json:"value"`
+	Source     string `json:"source"`     // flag, env, file, or default
+	Reloadable bool   `json:"reloadable"` // whether a reload puts a new value into effect
+}
+
+// Sources a setting can come from
+const (
+	SourceFlag    = "flag"
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceDefault = "default"
+)
+
+// Load reads the command-line arguments, without the program name, and the YAML file they or the
+// environment name. It fails if a flag is malformed or the file can't be read or parsed.
+func Load(args []string) (*Config, error) {
+	flags, err := parseFlags(args)
+	if err != nil {
+		return nil, err
+	}
+	return load(flags, os.LookupEnv)
+}
+
+// load builds a config from parsed flags and an environment
+func load(flags map[string]string, lookup func(string) (string, bool)) (*Config, error) {
+	path := flags["CONFIG"]
+	delete(flags, "CONFIG")
+	if path == "" {
+		path, _ = lookup(FileEnv)
+	}
+	return open(path, flags, lookup)
+}
+
+// open builds a config from parsed flags, an environment, and the YAML file at path, if there is one
+func open(path string, flags map[string]string, lookup func(string) (string, bool)) (*Config, error) {
+	c := &Config{
+		path:     path,
+		file:     make(map[string]string),
+		flags:    flags,
+		lookup:   lookup,
+		used:     make(map[string]bool),
+		settings: make(map[string]Setting),
+	}
+	if path == "" {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	if c.file, err = parseYAML(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// reread loads the configuration again from the same flags, environment, and file
+func (c *Config) reread() (*Config, error) {
+	return open(c.path, c.flags, c.lookup)
+}
+
+// value returns a setting from the highest-precedence source that sets it, and which source that was.
+// Empty values count as unset.
+func (c *Config) value(key string) (string, string, bool) {
+	c.used[key] = true
+	if c.reading != nil {
+		c.reading[key] = true
+	}
+	if value := c.flags[key]; value != "" {
+		return value, SourceFlag, true
+	}
+	if value, ok := c.lookup(key); ok && value != "" {
+		return value, SourceEnv, true
+	}
+	if value := c.file[key]; value != "" {
+		return value, SourceFile, true
+	}
+	return "", SourceDefault, false
+}
+
+// record remembers what a setting resolved to, for Settings
+func (c *Config) record(key, value, source string) {
+	if value != "" && secret(key) {
+		value = "[redacted]"
+	}
+	c.settings[key] = Setting{Key: key, Value: value, Source: source}
+}
+
+// secret reports whether a setting holds a credential, judging by words such as KEY or PASSWORD in its
+// name. Database and Redis URLs count too, since they usually carry the password.
+func secret(key string) bool {
+	for _, word := range strings.Split(key, "_") {
+		switch word {
+		case "KEY", "KEYS", "SECRET", "PASSWORD", "TOKEN", "DATABASE", "URI", "REDIS":
+			return true
+		}
+	}
+	return false
+}
+
+// Rule checks a setting's value, returning why it isn't allowed
+type Rule[T any] func(value T) error
+
+// NonNegative rejects values below zero
+func NonNegative[T int | float64 | time.Duration](value T) error {
+	if value < 0 {
+		return errors.New("must not be negative")
+	}
+	return nil
+}
+
+// Positive rejects values of zero or below
+func Positive[T int | float64 | time.Duration](value T) error {
+	if value <= 0 {
+		return errors.New("must be greater than zero")
+	}
+	return nil
+}
+
+// OneOf rejects values not in allowed
+func OneOf(allowed ...string) Rule[string] {
+	return func(value string) error {
+		for _, candidate := range allowed {
+			if value == candidate {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(allowed, ", "))
+	}
+}
+
+// Value returns a setting parsed by parse, for types the other getters don't cover
+func Value[T any](c *Config, key string, fallback T, parse func(string) (T, error), rules ...Rule[T]) T {
+	return read(c, key, fallback, parse, rules)
+}
+
+// read parses a setting and checks it against rules, falling back when it is unset or invalid
+func read[T any](c *Config, key string, fallback T, parse func(string) (T, error), rules []Rule[T]) T {
+	raw, source, ok := c.value(key)
+	if !ok {
+		c.record(key, fmt.Sprint(fallback), SourceDefault)
+		return fallback
+	}
+	value, err := parse(raw)
+	if err != nil {
+		c.errs = append(c.errs, fmt.Errorf("%s: invalid value %q", key, raw))
+		c.record(key, fmt.Sprint(fallback), SourceDefault)
+		return fallback
+	}
+	for _, rule := range rules {
+		if err := rule(value); err != nil {
+			c.errs = append(c.errs, fmt.Errorf("%s: %w, got %q", key, err, raw))
+			c.record(key, fmt.Sprint(fallback), SourceDefault)
+			return fallback
+		}
+	}
+	c.record(key, raw, source)
+	return value
+}
+
+// String returns a setting, or fallback when it is unset
+func (c *Config) String(key, fallback string, rules ...Rule[string]) string {
+	return read(c, key, fallback, func(raw string) (string, error) { return raw, nil }, rules)
+}
+
+// Int returns a whole-number setting
+func (c *Config) Int(key string, fallback int, rules ...Rule[int]) int {
+	return read(c, key, fallback, strconv.Atoi, rules)
+}
+
+// Float returns a decimal setting
+func (c *Config) Float(key string, fallback float64, rules ...Rule[float64]) float64 {
+	return read(c, key, fallback, func(raw string) (float64, error) { return strconv.ParseFloat(raw, 64) }, rules)
+}
+
+// Bool returns a true/false setting
+func (c *Config) Bool(key string, fallback bool, rules ...Rule[bool]) bool {
+	return read(c, key, fallback, strconv.ParseBool, rules)
+}
+
+// Duration returns a duration setting such as 30s or 1h30m
+func (c *Config) Duration(key string, fallback time.Duration, rules ...Rule[time.Duration]) time.Duration {
+	return read(c, key, fallback, time.ParseDuration, rules)
+}
+
+// List returns a comma-separated setting, or a YAML list, as its non-empty items
+func (c *Config) List(key string, fallback []string) []string {
+	raw, source, ok := c.value(key)
+	if !ok {
+		c.record(key, strings.Join(fallback, ","), SourceDefault)
+		return fallback
+	}
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	c.record(key, strings.Join(items, ","), source)
+	return items
+}
+
+// Err returns every problem found reading settings so far, or nil if there were none
+func (c *Config) Err() error {
+	return errors.Join(c.errs...)
+}
+
+// Unused returns the settings given in the file or on the command line that were never read, sorted.
+// They are usually typos, but a setting only read in some configurations is also unused in the others.
+func (c *Config) Unused() []string {
+	var unused []string
+	for _, source := range []map[string]string{c.file, c.flags} {
+		for key := range source {
+			if !c.used[key] {
+				unused = append(unused, key)
+			}
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
+
+// Settings returns every setting read so far, sorted by name
+func (c *Config) Settings() []Setting {
+	settings := make([]Setting, 0, len(c.settings))
+	for _, setting := range c.settings {
+		settings = append(settings, setting)
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	return settings
+}
+
+// Server holds the HTTP server settings every service shares
+type Server struct {
+	Port            int
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration  // how long in-flight requests get to finish on shutdown
+	DrainTimeout    time.Duration  // how long background work, such as jobs and event publishing, then gets to finish
+	CORSOrigins     []string       // origins browsers may call the service from; * allows any
+	GRPCPort        int            // port the gRPC API listens on, next to the HTTP one
+	TrustedProxies  []netip.Prefix // networks of the proxies, such as the gateway, whose X-Forwarded-For is believed
+	DebugAddr       string         // host:port of the internal port serving profiles and runtime variables; off when empty
+}
+
+// Server reads PORT, SERVER_READ_TIMEOUT, SERVER_WRITE_TIMEOUT, SERVER_IDLE_TIMEOUT,
+// SHUTDOWN_TIMEOUT, DRAIN_TIMEOUT, CORS_ALLOWED_ORIGINS, GRPC_PORT, which is 1000 above the default port by default,
+// TRUSTED_PROXIES, a list of networks or addresses that is empty by default, and DEBUG_ADDR, such as
+// localhost:6060, which is unset by default
+func (c *Config) Server(defaultPort int) Server {
+	return Server{
+		Port:            c.Int("PORT", defaultPort, inRange(1, 65535)),
+		ReadTimeout:     c.Duration("SERVER_READ_TIMEOUT", 15*time.Second, Positive[time.Duration]),
+		WriteTimeout:    c.Duration("SERVER_WRITE_TIMEOUT", 15*time.Second, Positive[time.Duration]),
+		IdleTimeout:     c.Duration("SERVER_IDLE_TIMEOUT", 60*time.Second, Positive[time.Duration]),
+		ShutdownTimeout: c.Duration("SHUTDOWN_TIMEOUT", 30*time.Second, Positive[time.Duration]),
+		DrainTimeout:    c.Duration("DRAIN_TIMEOUT", 30*time.Second, Positive[time.Duration]),
+		CORSOrigins:     c.List("CORS_ALLOWED_ORIGINS", []string{"*"}),
+		GRPCPort:        c.Int("GRPC_PORT", defaultPort+1000, inRange(1, 65535)),
+		TrustedProxies:  Value(c, "TRUSTED_PROXIES", nil, parseNetworks),
+		DebugAddr:       c.String("DEBUG_ADDR", "", hostPort),
+	}
+}
+
+// hostPort rejects addresses without a port, allowing the empty address
+func hostPort(value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(value); err != nil {
+		return errors.New("must be host:port, such as localhost:6060")
+	}
+	return nil
+}
+
+// parseNetworks parses a comma-separated list of networks, such as 10.0.0.0/8, or single addresses
+func parseNetworks(raw string) ([]netip.Prefix, error) {
+	var networks []netip.Prefix
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, err
+			}
+			networks = append(networks, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		network, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network.Masked())
+	}
+	return networks, nil
+}
+
+// Addr is the address the server listens on
+func (s Server) Addr() string {
+	return ":" + strconv.Itoa(s.Port)
+}
+
+// inRange rejects values outside [min, max]
+func inRange(min, max int) Rule[int] {
+	return func(value int) error {
+		if value < min || value > max {
+			return fmt.Errorf("must be between %d and %d", min, max)
+		}
+		return nil
+	}
+}
+
+// parseFlags reads -name=value, --name=value, and -name value arguments. A flag without a value
+// that is followed by another flag, or comes last, is set to true.
+func parseFlags(args []string) (map[string]string, error) {
+	flags := make(map[string]string)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" || arg == "--" {
+			return nil, fmt.Errorf("unexpected argument %q", arg)
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name == "" {
+			return nil, fmt.Errorf("malformed flag %q", arg)
+		}
+		if !hasValue {
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				i++
+				value = args[i]
+			} else {
+				value = "true"
+			}
+		}
+		flags[settingName(name)] = value
+	}
+	return flags, nil
+}
+
+// settingName turns a flag or YAML key into the environment-style name settings are read by
+func settingName(name string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+func TestLoad_FlagsOverrideEnvironmentOverrideFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "order-service.yaml")
+	file := "port: 9000\nuser_service:\n  url: http://users.internal\n  timeout: 3s\ntax_rate: 0.2\n"
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	flags, err := parseFlags([]string{"-config", path, "--user-service-timeout=5s", "-debug"})
+	if err != nil {
+		t.Fatalf("parseFlags failed: %v", err)
+	}
+	cfg, err := load(flags, env(map[string]string{"USER_SERVICE_URL": "http://users.env", "TAX_RATE": ""}))
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	if got := cfg.Int("PORT", 8083); got != 9000 {
+		t.Errorf("expected the file's port, got %d", got)
+	}
+	if got := cfg.String("USER_SERVICE_URL", ""); got != "http://users.env" {
+		t.Errorf("expected the environment to override the file, got %q", got)
+	}
+	if got := cfg.Duration("USER_SERVICE_TIMEOUT", time.Second); got != 5*time.Second {
+		t.Errorf("expected the flag to override the file, got %s", got)
+	}
+	if got := cfg.Float("TAX_RATE", 0); got != 0.2 {
+		t.Errorf("expected an empty variable to leave the file's value, got %v", got)
+	}
+	if got := cfg.Duration("PRODUCT_SERVICE_TIMEOUT", 10*time.Second); got != 10*time.Second {
+		t.Errorf("expected the default for an unset setting, got %s", got)
+	}
+	if unused := cfg.Unused(); !reflect.DeepEqual(unused, []string{"DEBUG"}) {
+		t.Errorf("expected only the debug flag unused, got %v", unused)
+	}
+	if err := cfg.Err(); err != nil {
+		t.Errorf("expected no errors, got %v", err)
+	}
+}
+
+func TestConfig_CollectsInvalidSettings(t *testing.T) {
+	cfg, _ := load(map[string]string{}, env(map[string]string{
+		"PORT":                 "eighty",
+		"PENDING_ORDER_TTL":    "-1m",
+		"PAYMENT_PROVIDER":     "cash",
+		"CORS_ALLOWED_ORIGINS": "https://shop.example, ,https://admin.example",
+		"TRUSTED_PROXIES":      "10.0.0.0/8, 192.168.1.7",
+		"DEBUG_ADDR":           "6060",
+	}))
+
+	server := cfg.Server(8083)
+	if server.Port != 8083 || server.Addr() != ":8083" {
+		t.Errorf("expected the default port when PORT is invalid, got %d", server.Port)
+	}
+	if server.GRPCPort != 9083 {
+		t.Errorf("expected the gRPC port 1000 above the default port, got %d", server.GRPCPort)
+	}
+	if !reflect.DeepEqual(server.CORSOrigins, []string{"https://shop.example", "https://admin.example"}) {
+		t.Errorf("expected the listed origins, got %v", server.CORSOrigins)
+	}
+	if proxies := server.TrustedProxies; len(proxies) != 2 || proxies[0].String() != "10.0.0.0/8" || proxies[1].String() != "192.168.1.7/32" {
+		t.Errorf("expected the network and the single address, got %v", proxies)
+	}
+	if server.DebugAddr != "" {
+		t.Errorf("expected the debug port left off when DEBUG_ADDR has no port, got %q", server.DebugAddr)
+	}
+	if ttl := cfg.Duration("PENDING_ORDER_TTL", time.Hour, NonNegative); ttl != time.Hour {
+		t.Errorf("expected the default when a rule is broken, got %s", ttl)
+	}
+	if provider := cfg.String("PAYMENT_PROVIDER", "none", OneOf("none", "mock", "stripe")); provider != "none" {
+		t.Errorf("expected the default provider, got %q", provider)
+	}
+
+	err := cfg.Err()
+	if err == nil {
+		t.Fatal("expected the invalid settings reported")
+	}
+	for _, want := range []string{`PORT: invalid value "eighty"`, "PENDING_ORDER_TTL: must not be negative", "PAYMENT_PROVIDER: must be one of none, mock, stripe", "DEBUG_ADDR: must be host:port"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestParseFlags_RejectsStrayArguments(t *testing.T) {
+	if _, err := parseFlags([]string{"-port", "8083", "extra"}); err == nil {
+		t.Error("expected an argument that isn't a flag rejected")
+	}
+	flags, err := parseFlags([]string{"--cors-allowed-origins=https://a.example,https://b.example", "-port", "8083"})
+	if err != nil || flags["CORS_ALLOWED_ORIGINS"] != "https://a.example,https://b.example" || flags["PORT"] != "8083" {
+		t.Errorf("expected both flags parsed, got %v %v", flags, err)
+	}
+}
+
+func TestLoad_ReportsFileErrors(t *testing.T) {
+	if _, err := load(map[string]string{"CONFIG": filepath.Join(t.TempDir(), "missing.yaml")}, env(nil)); err == nil {
+		t.Error("expected a missing config file reported")
+	}
+
+	path := filepath.Join(t.TempDir(), "bad.yaml")
+	os.WriteFile(path, []byte("port 8083\n"), 0o600)
+	if _, err := load(map[string]string{}, env(map[string]string{FileEnv: path})); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected the malformed line reported, got %v", err)
+	}
+}
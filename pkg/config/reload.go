@@ -0,0 +1,141 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"ecommerce/pkg/api"
+	"ecommerce/pkg/logging"
+)
+
+// Tunable reads the settings a service can change while it runs and returns the function that puts
+// them into effect. Reading and applying are split so a reload with any invalid setting changes nothing.
+type Tunable func(cfg *Config) (apply func())
+
+// Reloader reloads a service's configuration on SIGHUP and hands it to the registered Tunables.
+// Flags and environment variables can't change while a service runs, so a reload picks up edits
+// to the YAML file.
+type Reloader struct {
+	initial    *Config
+	tunables   []Tunable
+	reloadable map[string]bool
+	reloaded   map[string]Setting // what reloadable settings resolved to on the last reload
+	mutex      sync.Mutex
+}
+
+// NewReloader creates a reloader for the configuration a service started with
+func NewReloader(cfg *Config) *Reloader {
+	return &Reloader{
+		initial:    cfg,
+		reloadable: make(map[string]bool),
+		reloaded:   make(map[string]Setting),
+	}
+}
+
+// Register reads tune's settings from the starting configuration, puts them into effect, and
+// reads them again on every reload. Problems with the starting values are reported by the
+// starting configuration's Err.
+func (r *Reloader) Register(tune Tunable) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.initial.reading = make(map[string]bool)
+	apply := tune(r.initial)
+	for key := range r.initial.reading {
+		r.reloadable[key] = true
+	}
+	r.initial.reading = nil
+
+	apply()
+	r.tunables = append(r.tunables, tune)
+}
+
+// Reload reads the configuration again and, if every reloadable setting is valid, puts the new
+// values into effect. Otherwise it returns the problems and keeps the current values.
+func (r *Reloader) Reload() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	next, err := r.initial.reread()
+	if err != nil {
+		return err
+	}
+	applies := make([]func(), 0, len(r.tunables))
+	for _, tune := range r.tunables {
+		applies = append(applies, tune(next))
+	}
+	if err := next.Err(); err != nil {
+		return err
+	}
+
+	for _, apply := range applies {
+		apply()
+	}
+	for key, setting := range next.settings {
+		r.reloaded[key] = setting
+	}
+	return nil
+}
+
+// Watch reloads the configuration on every SIGHUP until ctx is done. A failed reload is logged
+// and the service carries on with its current settings.
+func (r *Reloader) Watch(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			if err := r.Reload(); err != nil {
+				slog.Error("Configuration reload failed, keeping the current settings", "error", err)
+				continue
+			}
+			slog.Info("Configuration reloaded")
+		}
+	}
+}
+
+// Settings returns the settings in effect, sorted by name
+func (r *Reloader) Settings() []Setting {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	settings := make([]Setting, 0, len(r.initial.settings))
+	for key, setting := range r.initial.settings {
+		if reloaded, ok := r.reloaded[key]; ok {
+			setting = reloaded
+		}
+		setting.Reloadable = r.reloadable[key]
+		settings = append(settings, setting)
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	return settings
+}
+
+// ServeSettings handles GET requests for the settings in effect
+func (r *Reloader) ServeSettings(w http.ResponseWriter, req *http.Request) {
+	api.WriteJSON(w, http.StatusOK, api.Response[api.Unpaged]{
+		Success: true,
+		Data:    r.Settings(),
+	})
+}
+
+// TuneLogLevel reads LOG_LEVEL: debug, info (the default), warn, or error
+func TuneLogLevel(cfg *Config) func() {
+	level := Value(cfg, "LOG_LEVEL", slog.LevelInfo, logging.ParseLevel)
+	return func() { logging.SetLevel(level) }
+}
+
+// TuneMaxBodyBytes reads MAX_BODY_BYTES, how large a JSON request body may be (1 MiB by default)
+func TuneMaxBodyBytes(cfg *Config) func() {
+	limit := cfg.Int("MAX_BODY_BYTES", api.DefaultMaxBodyBytes, Positive)
+	return func() { api.SetMaxBodyBytes(int64(limit)) }
+}
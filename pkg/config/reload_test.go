@@ -0,0 +1,152 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"ecommerce/pkg/api"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloader_AppliesEditedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "order-service.yaml")
+	writeFile(t, path, "user_service_url: http://users-a\nport: 9000\n")
+	cfg, err := load(map[string]string{"CONFIG": path}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var url string
+	reloader := NewReloader(cfg)
+	reloader.Register(func(cfg *Config) func() {
+		next := cfg.String("USER_SERVICE_URL", "http://localhost:8081")
+		return func() { url = next }
+	})
+	cfg.Int("PORT", 8083)
+	if url != "http://users-a" {
+		t.Fatalf("expected the starting value to be applied, got %q", url)
+	}
+
+	writeFile(t, path, "user_service_url: http://users-b\nport: 9001\n")
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if url != "http://users-b" {
+		t.Errorf("expected the edited value after a reload, got %q", url)
+	}
+
+	settings := map[string]Setting{}
+	for _, setting := range reloader.Settings() {
+		settings[setting.Key] = setting
+	}
+	if got := settings["USER_SERVICE_URL"]; got.Value != "http://users-b" || got.Source != SourceFile || !got.Reloadable {
+		t.Errorf("expected the reloaded URL from the file, got %+v", got)
+	}
+	if got := settings["PORT"]; got.Value != "9000" || got.Reloadable {
+		t.Errorf("expected the port a restart would change to keep its starting value, got %+v", got)
+	}
+}
+
+func TestReloader_KeepsCurrentValuesWhenAnySettingIsInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user-service.yaml")
+	writeFile(t, path, "login_rate_ip_burst: 20\nlog_level: info\n")
+	cfg, err := load(map[string]string{"CONFIG": path}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var burst int
+	var level string
+	reloader := NewReloader(cfg)
+	reloader.Register(func(cfg *Config) func() {
+		next := cfg.Int("LOGIN_RATE_IP_BURST", 10, Positive)
+		return func() { burst = next }
+	})
+	reloader.Register(func(cfg *Config) func() {
+		next := cfg.String("LOG_LEVEL", "info", OneOf("debug", "info"))
+		return func() { level = next }
+	})
+
+	writeFile(t, path, "login_rate_ip_burst: 50\nlog_level: loud\n")
+	if err := reloader.Reload(); err == nil {
+		t.Fatal("expected the invalid log level to fail the reload")
+	}
+	if burst != 20 || level != "info" {
+		t.Errorf("expected no setting to change, got burst %d and level %q", burst, level)
+	}
+
+	os.Remove(path)
+	if err := reloader.Reload(); err == nil {
+		t.Error("expected a missing file to fail the reload")
+	}
+}
+
+func TestReloader_ServeSettingsRedactsSecrets(t *testing.T) {
+	cfg, _ := load(map[string]string{}, env(map[string]string{
+		"SERVICE_KEY":               "sk-live",
+		"SERVICE_KEEP_ALIVE":        "15s",
+		"DIGITAL_DOWNLOAD_SECRET":   "",
+		"CIRCUIT_BREAKER_THRESHOLD": "5",
+		"DATABASE_URL":              "postgres://orders:hunter2@db/orders",
+		"MONGODB_URI":               "mongodb://orders:hunter2@db/?replicaSet=rs0",
+		"REDIS_URL":                 "redis://:hunter2@cache:6379/0",
+	}))
+	cfg.String("SERVICE_KEY", "")
+	cfg.String("DIGITAL_DOWNLOAD_SECRET", "")
+	cfg.Duration("SERVICE_KEEP_ALIVE", 30*time.Second)
+	cfg.Int("CIRCUIT_BREAKER_THRESHOLD", 5)
+	cfg.String("DATABASE_URL", "")
+	cfg.String("MONGODB_URI", "")
+	cfg.String("REDIS_URL", "")
+	reloader := NewReloader(cfg)
+
+	rec := httptest.NewRecorder()
+	reloader.ServeSettings(rec, httptest.NewRequest(http.MethodGet, "/admin/config", nil))
+
+	var body struct {
+		Data []Setting `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	want := []Setting{
+		{Key: "CIRCUIT_BREAKER_THRESHOLD", Value: "5", Source: SourceEnv},
+		{Key: "DATABASE_URL", Value: "[redacted]", Source: SourceEnv},
+		{Key: "DIGITAL_DOWNLOAD_SECRET", Value: "", Source: SourceDefault},
+		{Key: "MONGODB_URI", Value: "[redacted]", Source: SourceEnv},
+		{Key: "REDIS_URL", Value: "[redacted]", Source: SourceEnv},
+		{Key: "SERVICE_KEEP_ALIVE", Value: "15s", Source: SourceEnv},
+		{Key: "SERVICE_KEY", Value: "[redacted]", Source: SourceEnv},
+	}
+	if len(body.Data) != len(want) {
+		t.Fatalf("expected %d settings, got %+v", len(want), body.Data)
+	}
+	for i := range want {
+		if body.Data[i] != want[i] {
+			t.Errorf("setting %d: expected %+v, got %+v", i, want[i], body.Data[i])
+		}
+	}
+}
+
+func TestTuneMaxBodyBytes_SetsTheRequestBodyLimit(t *testing.T) {
+	defer api.SetMaxBodyBytes(api.MaxBodyBytes())
+	cfg, err := load(map[string]string{"MAX_BODY_BYTES": "2048"}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	NewReloader(cfg).Register(TuneMaxBodyBytes)
+	if limit := api.MaxBodyBytes(); limit != 2048 {
+		t.Errorf("expected a limit of 2048 bytes, got %d", limit)
+	}
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// TLS is a service's certificate, which it serves with and presents to the services it calls, and
+// the CA the certificates of the services on the other end must be signed by. The files are read
+// again on every reload, so rotated certificates take effect on SIGHUP without a restart; new
+// connections use them, while open ones carry on with the old.
+//
+// A nil *TLS stands for plain HTTP and gRPC: its configs are nil and ListenAndServe serves plain HTTP.
+type TLS struct {
+	clientAuth tls.ClientAuthType
+	mutex      sync.RWMutex
+	cert       *tls.Certificate
+	roots      *x509.CertPool
+}
+
+// TLS reads TLS_CERT_FILE and TLS_KEY_FILE, this service's certificate and key in PEM, TLS_CA_FILE, the
+// CA the services' certificates are signed by, and TLS_CLIENT_AUTH: "require" (the default) has
+// callers present a certificate signed by the CA, while "optional" also lets in callers without one,
+// such as browsers in dev, checking the certificates that are presented. It returns nil when
+// TLS_CERT_FILE is unset, leaving the service on plain HTTP and gRPC. The file paths are reloadable;
+// whether TLS is on and TLS_CLIENT_AUTH are fixed at startup.
+func (r *Reloader) TLS() *TLS {
+	if r.initial.String("TLS_CERT_FILE", "") == "" {
+		return nil
+	}
+	t := &TLS{clientAuth: tls.RequireAnyClientCert}
+	if r.initial.String("TLS_CLIENT_AUTH", "require", OneOf("require", "optional")) == "optional" {
+		t.clientAuth = tls.RequestClientCert
+	}
+	r.Register(t.tune)
+	return t
+}
+
+// tune reads the certificate files, recording a problem with any of them for cfg.Err, so a reload
+// with a broken file keeps the certificates in effect
+func (t *TLS) tune(cfg *Config) func() {
+	certFile := cfg.String("TLS_CERT_FILE", "")
+	keyFile := cfg.String("TLS_KEY_FILE", "")
+	caFile := cfg.String("TLS_CA_FILE", "")
+	if certFile == "" || keyFile == "" || caFile == "" {
+		cfg.errs = append(cfg.errs, errors.New("TLS_CERT_FILE, TLS_KEY_FILE, and TLS_CA_FILE must all be set to use TLS"))
+		return func() {}
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		cfg.errs = append(cfg.errs, fmt.Errorf("TLS_CERT_FILE: %w", err))
+		return func() {}
+	}
+	roots, err := loadCertPool(caFile)
+	if err != nil {
+		cfg.errs = append(cfg.errs, fmt.Errorf("TLS_CA_FILE: %w", err))
+		return func() {}
+	}
+	return func() {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		t.cert, t.roots = &cert, roots
+	}
+}
+
+// loadCertPool reads the PEM certificates in path
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates in %s", path)
+	}
+	return pool, nil
+}
+
+// current returns the certificate and CA in effect
+func (t *TLS) current() (*tls.Certificate, *x509.CertPool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.cert, t.roots
+}
+
+// ServerConfig returns the config to serve with: the current certificate, asking callers for theirs
+// and checking it against the current CA
+func (t *TLS) ServerConfig() *tls.Config {
+	if t == nil {
+		return nil
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, _ := t.current()
+			return cert, nil
+		},
+		// The caller's certificate is checked by VerifyConnection rather than against ClientCAs,
+		// which can't change once the server is listening
+		ClientAuth: t.clientAuth,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 && t.clientAuth == tls.RequestClientCert {
+				return nil
+			}
+			return t.verify(state.PeerCertificates, "", x509.ExtKeyUsageClientAuth)
+		},
+	}
+}
+
+// ClientConfig returns the config to call other services with: presenting the current certificate,
+// and checking theirs against the current CA and the name called
+func (t *TLS) ClientConfig() *tls.Config {
+	if t == nil {
+		return nil
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := t.current()
+			return cert, nil
+		},
+		// The server's certificate is checked by VerifyConnection, against the CA in effect when
+		// connecting rather than the one RootCAs would fix for good
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			return t.verify(state.PeerCertificates, state.ServerName, x509.ExtKeyUsageServerAuth)
+		},
+	}
+}
+
+// verify checks that chain leads to the current CA and, when name is set, is for name
+func (t *TLS) verify(chain []*x509.Certificate, name string, usage x509.ExtKeyUsage) error {
+	if len(chain) == 0 {
+		return errors.New("no certificate presented")
+	}
+	_, roots := t.current()
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		DNSName:       name,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	})
+	return err
+}
+
+// ListenAndServe serves server over TLS with the current certificate, or over plain HTTP when t is nil
+func (t *TLS) ListenAndServe(server *http.Server) error {
+	if t == nil {
+		return server.ListenAndServe()
+	}
+	server.TLSConfig = t.ServerConfig()
+	return server.ListenAndServeTLS("", "")
+}
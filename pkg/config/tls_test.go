@@ -0,0 +1,197 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testPKI is a CA and a certificate it signed for 127.0.0.1, good for serving and calling
+type testPKI struct {
+	caPEM, certPEM, keyPEM []byte
+	serial                 *big.Int
+}
+
+func newTestPKI(t *testing.T, serial int64) testPKI {
+	t.Helper()
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial * 1000),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "order-service"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return testPKI{
+		caPEM:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		serial:  template.SerialNumber,
+	}
+}
+
+// install writes the PKI's files where the settings point
+func (p testPKI) install(t *testing.T, settings map[string]string) {
+	writeFile(t, settings["TLS_CA_FILE"], string(p.caPEM))
+	writeFile(t, settings["TLS_CERT_FILE"], string(p.certPEM))
+	writeFile(t, settings["TLS_KEY_FILE"], string(p.keyPEM))
+}
+
+func tlsSettings(t *testing.T) map[string]string {
+	dir := t.TempDir()
+	return map[string]string{
+		"TLS_CA_FILE":   filepath.Join(dir, "ca.pem"),
+		"TLS_CERT_FILE": filepath.Join(dir, "service.pem"),
+		"TLS_KEY_FILE":  filepath.Join(dir, "service-key.pem"),
+	}
+}
+
+// serveTLS serves a 204 over TLS with config until the test ends, returning the server's URL
+func serveTLS(t *testing.T, config *tls.Config) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})}
+	go server.Serve(tls.NewListener(listener, config))
+	t.Cleanup(func() { server.Close() })
+	return "https://" + listener.Addr().String()
+}
+
+// call makes a GET with a fresh connection, returning the serial of the certificate the server presented
+func call(url string, config *tls.Config) (*big.Int, error) {
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp.TLS.PeerCertificates[0].SerialNumber, nil
+}
+
+func TestTLS_MutualAuthentication(t *testing.T) {
+	settings := tlsSettings(t)
+	pki := newTestPKI(t, 1)
+	pki.install(t, settings)
+	cfg, _ := load(nil, env(settings))
+	certs := NewReloader(cfg).TLS()
+	if err := cfg.Err(); err != nil {
+		t.Fatalf("expected valid TLS settings, got %v", err)
+	}
+	url := serveTLS(t, certs.ServerConfig())
+
+	if _, err := call(url, certs.ClientConfig()); err != nil {
+		t.Fatalf("expected a caller with a certificate to get through, got %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(pki.caPEM)
+	if _, err := call(url, &tls.Config{RootCAs: roots}); err == nil {
+		t.Error("expected a caller without a certificate to be turned away")
+	}
+
+	stranger := newTestPKI(t, 9)
+	cert, _ := tls.X509KeyPair(stranger.certPEM, stranger.keyPEM)
+	if _, err := call(url, &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{cert}}); err == nil {
+		t.Error("expected a certificate from another CA to be turned away")
+	}
+}
+
+func TestTLS_OptionalClientAuth(t *testing.T) {
+	settings := tlsSettings(t)
+	pki := newTestPKI(t, 1)
+	pki.install(t, settings)
+	settings["TLS_CLIENT_AUTH"] = "optional"
+	cfg, _ := load(nil, env(settings))
+	certs := NewReloader(cfg).TLS()
+	url := serveTLS(t, certs.ServerConfig())
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(pki.caPEM)
+	if _, err := call(url, &tls.Config{RootCAs: roots}); err != nil {
+		t.Errorf("expected a caller without a certificate to get through, got %v", err)
+	}
+}
+
+func TestTLS_ReloadRotatesCertificates(t *testing.T) {
+	settings := tlsSettings(t)
+	newTestPKI(t, 1).install(t, settings)
+	cfg, _ := load(nil, env(settings))
+	reloader := NewReloader(cfg)
+	certs := reloader.TLS()
+	url := serveTLS(t, certs.ServerConfig())
+
+	// Both ends move to a new CA at once, as when every service picks up rotated files
+	rotated := newTestPKI(t, 2)
+	rotated.install(t, settings)
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	serial, err := call(url, certs.ClientConfig())
+	if err != nil {
+		t.Fatalf("expected the rotated certificates to work, got %v", err)
+	}
+	if serial.Cmp(rotated.serial) != 0 {
+		t.Errorf("expected the rotated certificate served, got serial %s", serial)
+	}
+
+	// A broken file is refused, keeping the rotated certificates
+	writeFile(t, settings["TLS_KEY_FILE"], "not a key")
+	if err := reloader.Reload(); err == nil {
+		t.Error("expected a reload with a broken key to fail")
+	}
+	if _, err := call(url, certs.ClientConfig()); err != nil {
+		t.Errorf("expected the certificates in effect to be kept, got %v", err)
+	}
+}
+
+func TestTLS_Settings(t *testing.T) {
+	cfg, _ := load(nil, env(nil))
+	if certs := NewReloader(cfg).TLS(); certs != nil || certs.ServerConfig() != nil || certs.ClientConfig() != nil {
+		t.Error("expected no TLS without a certificate")
+	}
+
+	settings := tlsSettings(t)
+	newTestPKI(t, 1).install(t, settings)
+	delete(settings, "TLS_CA_FILE")
+	cfg, _ = load(nil, env(settings))
+	NewReloader(cfg).TLS()
+	if cfg.Err() == nil {
+		t.Error("expected a certificate without a CA to be reported")
+	}
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAML reads the subset of YAML config files need: nested mappings of scalars, and lists of
+// scalars written inline ([a, b]) or one "- item" per line. Nested keys are joined with underscores,
+// so timeout under user_service is USER_SERVICE_TIMEOUT, and lists become comma-separated values.
+func parseYAML(data []byte) (map[string]string, error) {
+	type parent struct {
+		indent int
+		name   string
+	}
+
+	values := make(map[string]string)
+	lists := make(map[string][]string)
+	var (
+		parents []parent // keys whose values are the lines indented below them
+		pending *parent  // the last key without a value, which list items belong to
+	)
+
+	for number, line := range strings.Split(string(data), "\n") {
+		number++
+		line = strings.TrimRight(stripComment(line), " \t\r")
+		content := strings.TrimLeft(line, " ")
+		if content == "" || content == "---" {
+			continue
+		}
+		if strings.HasPrefix(content, "\t") {
+			return nil, fmt.Errorf("line %d: indent with spaces, not tabs", number)
+		}
+		indent := len(line) - len(content)
+
+		if content == "-" || strings.HasPrefix(content, "- ") {
+			if pending == nil || indent < pending.indent {
+				return nil, fmt.Errorf("line %d: list item without a key", number)
+			}
+			item, err := parseScalar(strings.TrimSpace(strings.TrimPrefix(content, "-")))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", number, err)
+			}
+			lists[pending.name] = append(lists[pending.name], item)
+			continue
+		}
+
+		for len(parents) > 0 && parents[len(parents)-1].indent >= indent {
+			parents = parents[:len(parents)-1]
+		}
+		if pending != nil && len(lists[pending.name]) > 0 && indent > pending.indent {
+			return nil, fmt.Errorf("line %d: a key can't hold both a list and nested keys", number)
+		}
+
+		key, rest, found := cutKey(content)
+		if !found {
+			return nil, fmt.Errorf("line %d: expected key: value", number)
+		}
+		name := settingName(key)
+		if len(parents) > 0 {
+			name = parents[len(parents)-1].name + "_" + name
+		}
+		if _, exists := values[name]; exists {
+			return nil, fmt.Errorf("line %d: %s is set twice", number, key)
+		}
+		if _, exists := lists[name]; exists {
+			return nil, fmt.Errorf("line %d: %s is set twice", number, key)
+		}
+
+		if rest == "" {
+			key := parent{indent: indent, name: name}
+			parents = append(parents, key)
+			pending = &key
+			continue
+		}
+		pending = nil
+		value, err := parseValue(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+		values[name] = value
+	}
+
+	for name, items := range lists {
+		values[name] = strings.Join(items, ",")
+	}
+	return values, nil
+}
+
+// cutKey splits "key: value" at the colon ending the key
+func cutKey(content string) (key, rest string, found bool) {
+	for i := 0; i < len(content); i++ {
+		if content[i] == ':' && (i+1 == len(content) || content[i+1] == ' ') {
+			key = strings.TrimSpace(content[:i])
+			return key, strings.TrimSpace(content[i+1:]), key != ""
+		}
+	}
+	return "", "", false
+}
+
+// stripComment removes a # comment that isn't inside quotes
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseValue reads a scalar or an inline [a, b] list
+func parseValue(raw string) (string, error) {
+	if !strings.HasPrefix(raw, "[") {
+		return parseScalar(raw)
+	}
+	if !strings.HasSuffix(raw, "]") {
+		return "", fmt.Errorf("unterminated list %s", raw)
+	}
+	var items []string
+	for _, part := range strings.Split(raw[1:len(raw)-1], ",") {
+		item, err := parseScalar(strings.TrimSpace(part))
+		if err != nil {
+			return "", err
+		}
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return strings.Join(items, ","), nil
+}
+
+// parseScalar reads a plain, single-quoted, or double-quoted scalar
+func parseScalar(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		value, err := strconv.Unquote(raw)
+		if err != nil {
+			return "", fmt.Errorf("malformed quoted string %s", raw)
+		}
+		return value, nil
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return "", fmt.Errorf("malformed quoted string %s", raw)
+		}
+		return strings.ReplaceAll(raw[1:len(raw)-1], "''", "'"), nil
+	case raw == "~" || raw == "null":
+		return "", nil
+	}
+	return raw, nil
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	values, err := parseYAML([]byte(`
+# order service settings
+---
+port: 8083
+service_name: "order-service" # quoted
+user_service:
+  url: http://user-service:8081
+  timeout: 5s
+circuit-breaker:
+  threshold: 5
+  open.timeout: '30s'
+cors_allowed_origins: [https://shop.example, "https://admin.example"]
+broker_topics:
+- order-events
+- "audit # events"
+stripe_secret_key: ~
+`))
+	if err != nil {
+		t.Fatalf("parseYAML failed: %v", err)
+	}
+
+	want := map[string]string{
+		"PORT":                         "8083",
+		"SERVICE_NAME":                 "order-service",
+		"USER_SERVICE_URL":             "http://user-service:8081",
+		"USER_SERVICE_TIMEOUT":         "5s",
+		"CIRCUIT_BREAKER_THRESHOLD":    "5",
+		"CIRCUIT_BREAKER_OPEN_TIMEOUT": "30s",
+		"CORS_ALLOWED_ORIGINS":         "https://shop.example,https://admin.example",
+		"BROKER_TOPICS":                "order-events,audit # events",
+		"STRIPE_SECRET_KEY":            "",
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("expected %v\ngot      %v", want, values)
+	}
+}
+
+func TestParseYAML_RejectsMalformedFiles(t *testing.T) {
+	for name, file := range map[string]string{
+		"missing colon":     "port 8083",
+		"duplicate key":     "port: 8083\nport: 8084",
+		"tab indent":        "user_service:\n\turl: http://users",
+		"orphan item":       "- order-events",
+		"list and keys":     "topics:\n  - a\n  name: b",
+		"bad quote":         `name: "unterminated`,
+		"unterminated list": "origins: [a, b",
+	} {
+		if _, err := parseYAML([]byte(file)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Package diagnostics serves Go's runtime profiles and variables on an internal port of their own,
+// kept apart from the API so only operators can reach them
+package diagnostics
+
+import (
+	"expvar"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} { return runtime.NumGoroutine() }))
+}
+
+// Handler serves the pprof profiles, such as /debug/pprof/profile for CPU and /debug/pprof/heap,
+// and the expvar variables, memory statistics and the goroutine count among them, at /debug/vars
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// Server serves Handler on the diagnostics port. A nil *Server stands for the port being off.
+type Server struct {
+	server *http.Server
+}
+
+// Serve listens on addr and serves Handler there in the background. It returns nil without
+// listening when addr is empty.
+func Serve(addr string) (*Server, error) {
+	if addr == "" {
+		return nil, nil
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// No write timeout: a CPU profile or trace takes as many seconds as it is asked for
+	server := &http.Server{Handler: Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			slog.Error("Diagnostics port stopped", "error", err)
+		}
+	}()
+	slog.Info("🩺 Serving profiles and runtime variables", "addr", listener.Addr().String())
+	return &Server{server: server}, nil
+}
+
+// Close stops the diagnostics port, cutting off any profile still being taken rather than holding
+// up shutdown for it
+func (s *Server) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.server.Close()
+}
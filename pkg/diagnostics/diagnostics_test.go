@@ -0,0 +1,50 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_ServesProfilesAndVariables(t *testing.T) {
+	handler := Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &vars); err != nil || vars["memstats"] == nil || vars["goroutines"] == nil {
+		t.Fatalf("expected memory statistics and the goroutine count, got %d %v", rec.Code, err)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/heap?debug=1", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "heap profile") {
+		t.Fatalf("expected a heap profile, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected nothing but diagnostics served, got %d", rec.Code)
+	}
+}
+
+func TestServe(t *testing.T) {
+	server, err := Serve("")
+	if server != nil || err != nil || server.Close() != nil {
+		t.Fatalf("expected the port left off without an address, got %v %v", server, err)
+	}
+
+	server, err = Serve("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Close(); err != nil {
+		t.Errorf("expected the port closed, got %v", err)
+	}
+	if _, err := Serve("127.0.0.1:-1"); err == nil {
+		t.Error("expected an address that can't be listened on reported")
+	}
+}
@@ -0,0 +1,135 @@
+// Package events is the messaging layer services integrate through: the order events order service
+// and the payment events payment service publish, and the interfaces publishing and consuming go through
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Order event types, which are also the webhook event types
+const (
+	OrderCreated       = "order.created"
+	OrderStatusChanged = "order.status.changed"
+	OrderCancelled     = "order.cancelled"
+)
+
+// DefaultOrderTopic is the topic order events are published to unless configured otherwise
+const DefaultOrderTopic = "order-events"
+
+// Message is a record read from a topic
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       string
+	Value     json.RawMessage
+}
+
+// Handler processes one message. An error means the message wasn't handled and should be retried.
+type Handler func(ctx context.Context, msg Message) error
+
+// Publisher publishes messages to a topic
+type Publisher interface {
+	Publish(ctx context.Context, topic, key string, value interface{}) error
+}
+
+// Subscriber consumes a topic as a member of a consumer group, so each message is handled by one
+// instance of the consuming service
+type Subscriber interface {
+	// Consume hands every message on topic to handler until ctx is done. Delivery is at least once:
+	// a message may be handled again after a failure or restart, so handlers should be idempotent.
+	Consume(ctx context.Context, group, topic string, handler Handler) error
+}
+
+// OrderEvent is an event about an order, as order service publishes it. Only the fields other services
+// use are decoded.
+type OrderEvent struct {
+	ID         string         `json:"id"`
+	Type       string         `json:"type"`
+	OrderID    string         `json:"order_id"`
+	Data       OrderEventData `json:"data"`
+	OccurredAt time.Time      `json:"occurred_at"`
+}
+
+// OrderEventData is the data of an order event
+type OrderEventData struct {
+	Order          Order       `json:"order"`
+	PreviousStatus string      `json:"previous_status,omitempty"`
+	Release        []HeldStock `json:"release,omitempty"` // on order.cancelled, the stock left for product service to release
+}
+
+// Order is an order as it was when the event happened
+type Order struct {
+	ID     string      `json:"id"`
+	UserID string      `json:"user_id"`
+	Status string      `json:"status"`
+	Total  float64     `json:"total"`
+	Items  []OrderItem `json:"items"`
+}
+
+// OrderItem is a line of an order
+type OrderItem struct {
+	ProductID   string  `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	Quantity    int     `json:"quantity"`
+	Price       float64 `json:"price"`
+}
+
+// HeldStock is a stock reservation an order held
+type HeldStock struct {
+	ProductID     string `json:"product_id"`
+	ReservationID string `json:"reservation_id"`
+}
+
+// DecodeOrderEvent decodes an order event from a message
+func DecodeOrderEvent(msg Message) (*OrderEvent, error) {
+	var event OrderEvent
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// Payment event types payment service publishes
+const (
+	PaymentAuthorized = "payment.authorized"
+	PaymentPending    = "payment.pending"
+	PaymentSucceeded  = "payment.succeeded"
+	PaymentFailed     = "payment.failed"
+	PaymentRefunded   = "payment.refunded"
+)
+
+// DefaultPaymentTopic is the topic payment events are published to unless configured otherwise
+const DefaultPaymentTopic = "payment-events"
+
+// PaymentEvent is an event about a payment, as payment service publishes it
+type PaymentEvent struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	PaymentID  string    `json:"payment_id"`
+	OrderID    string    `json:"order_id"`
+	Data       Payment   `json:"data"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Payment is a payment as it was when the event happened
+type Payment struct {
+	ID             string  `json:"id"`
+	OrderID        string  `json:"order_id"`
+	UserID         string  `json:"user_id"`
+	Status         string  `json:"status"`
+	Amount         float64 `json:"amount"`
+	AmountRefunded float64 `json:"amount_refunded"`
+	Currency       string  `json:"currency"`
+}
+
+// DecodePaymentEvent decodes a payment event from a message
+func DecodePaymentEvent(msg Message) (*PaymentEvent, error) {
+	var event PaymentEvent
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
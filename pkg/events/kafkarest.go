@@ -0,0 +1,211 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Kafka REST Proxy v2 content types
+const (
+	kafkaContentType     = "application/vnd.kafka.v2+json"
+	kafkaJSONContentType = "application/vnd.kafka.json.v2+json"
+)
+
+// Retry timing for consumers, replaced in tests
+var (
+	consumeRetryDelay = time.Second     // how long a consumer waits before retrying a failed handler or poll
+	maxHandlerRetries = 5               // how many times a failed message is retried before it is skipped
+	pollTimeout       = 5 * time.Second // how long one poll waits for records
+)
+
+// KafkaREST publishes and consumes JSON records through a Kafka REST Proxy
+type KafkaREST struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewKafkaREST creates a client for the REST Proxy at baseURL
+func NewKafkaREST(baseURL string) *KafkaREST {
+	return &KafkaREST{
+		httpClient: &http.Client{Timeout: pollTimeout + 10*time.Second},
+		baseURL:    strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// kafkaRecord is one record of a produce request
+type kafkaRecord struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// kafkaProduceResponse reports per-record results; a record the proxy couldn't write carries an error
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		Partition int    `json:"partition"`
+		Offset    int64  `json:"offset"`
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+// Publish produces value to topic as JSON. Records with the same key go to the same partition, so
+// they are consumed in the order they were published.
+func (k *KafkaREST) Publish(ctx context.Context, topic, key string, value interface{}) error {
+	var produced kafkaProduceResponse
+	body := map[string]interface{}{"records": []kafkaRecord{{Key: key, Value: value}}}
+	if err := k.call(ctx, http.MethodPost, k.baseURL+"/topics/"+url.PathEscape(topic), kafkaJSONContentType, body, &produced); err != nil {
+		return err
+	}
+	for _, offset := range produced.Offsets {
+		if offset.ErrorCode != nil {
+			return fmt.Errorf("kafka REST proxy rejected the record: %s", offset.Error)
+		}
+	}
+	return nil
+}
+
+// kafkaConsumedRecord is a record returned by a poll
+type kafkaConsumedRecord struct {
+	Topic     string          `json:"topic"`
+	Key       string          `json:"key"`
+	Value     json.RawMessage `json:"value"`
+	Partition int             `json:"partition"`
+	Offset    int64           `json:"offset"`
+}
+
+// Consume joins group and hands every record on topic to handler until ctx is done. A record is
+// committed once handled; a failing handler is retried, and after maxHandlerRetries failures the record
+// is logged and skipped so one bad record can't stall the topic. While the proxy can't be reached the
+// consumer keeps retrying.
+func (k *KafkaREST) Consume(ctx context.Context, group, topic string, handler Handler) error {
+	for ctx.Err() == nil {
+		if err := k.consume(ctx, group, topic, handler); err != nil && ctx.Err() == nil {
+			slog.Error("Event consumer failed; retrying", "group", group, "topic", topic, "error", err)
+			sleep(ctx, consumeRetryDelay)
+		}
+	}
+	return ctx.Err()
+}
+
+// consume runs one consumer instance until ctx is done or a call to the proxy fails
+func (k *KafkaREST) consume(ctx context.Context, group, topic string, handler Handler) error {
+	var instance struct {
+		BaseURI string `json:"base_uri"`
+	}
+	settings := map[string]string{"format": "json", "auto.offset.reset": "earliest", "auto.commit.enable": "false"}
+	if err := k.call(ctx, http.MethodPost, k.baseURL+"/consumers/"+url.PathEscape(group), kafkaContentType, settings, &instance); err != nil {
+		return fmt.Errorf("creating consumer: %w", err)
+	}
+	defer func() {
+		// Leave the group so its partitions are handed to the other instances straight away
+		leaveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		k.call(leaveCtx, http.MethodDelete, instance.BaseURI, kafkaContentType, nil, nil)
+	}()
+
+	if err := k.call(ctx, http.MethodPost, instance.BaseURI+"/subscription", kafkaContentType, map[string][]string{"topics": {topic}}, nil); err != nil {
+		return fmt.Errorf("subscribing: %w", err)
+	}
+
+	for ctx.Err() == nil {
+		var records []kafkaConsumedRecord
+		pollURL := fmt.Sprintf("%s/records?timeout=%d", instance.BaseURI, pollTimeout.Milliseconds())
+		if err := k.call(ctx, http.MethodGet, pollURL, "", nil, &records); err != nil {
+			return fmt.Errorf("polling: %w", err)
+		}
+		for _, record := range records {
+			msg := Message{Topic: record.Topic, Partition: record.Partition, Offset: record.Offset, Key: record.Key, Value: record.Value}
+			if !handle(ctx, group, handler, msg) {
+				return ctx.Err()
+			}
+			// The proxy commits the offset after the one given, so the record isn't read again
+			offsets := map[string]interface{}{"offsets": []map[string]interface{}{{"topic": record.Topic, "partition": record.Partition, "offset": record.Offset}}}
+			if err := k.call(ctx, http.MethodPost, instance.BaseURI+"/offsets", kafkaContentType, offsets, nil); err != nil {
+				return fmt.Errorf("committing: %w", err)
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// handle passes msg to handler, retrying failures. It reports false when ctx ended first.
+func handle(ctx context.Context, group string, handler Handler, msg Message) bool {
+	for attempt := 1; ; attempt++ {
+		err := handler(ctx, msg)
+		if err == nil {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		if attempt > maxHandlerRetries {
+			slog.Error("Skipping event that kept failing", "group", group, "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset, "error", err)
+			return true
+		}
+		slog.Warn("Handling event failed; retrying", "group", group, "topic", msg.Topic, "offset", msg.Offset, "attempt", attempt, "error", err)
+		if !sleep(ctx, consumeRetryDelay) {
+			return false
+		}
+	}
+}
+
+// call sends body, if any, as contentType to the proxy and decodes the response into out, if given.
+// Records are read as JSON; everything else the proxy answers in its own format.
+func (k *KafkaREST) call(ctx context.Context, method, url, contentType string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	accept := kafkaContentType
+	if method == http.MethodGet {
+		accept = kafkaJSONContentType
+	}
+	req.Header.Set("Accept", accept)
+
+	resp, err := k.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("kafka REST proxy responded with status %d", resp.StatusCode)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding kafka REST proxy response: %w", err)
+	}
+	return nil
+}
+
+// sleep waits for d, reporting false if ctx ended first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,113 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProxy is a Kafka REST Proxy holding one topic's records in memory, for one consumer at a time
+type fakeProxy struct {
+	mu        sync.Mutex
+	records   []kafkaConsumedRecord
+	committed int64 // the offset the group reads from next
+	polled    bool  // records have been handed out since the last commit
+}
+
+func (f *fakeProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	server := "http://" + r.Host
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/topics/orders":
+		var body struct {
+			Records []struct {
+				Key   string          `json:"key"`
+				Value json.RawMessage `json:"value"`
+			} `json:"records"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		for _, record := range body.Records {
+			f.records = append(f.records, kafkaConsumedRecord{Topic: "orders", Key: record.Key, Value: record.Value, Offset: int64(len(f.records))})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"offsets": []map[string]int{{"partition": 0, "offset": len(f.records) - 1}}})
+	case r.Method == http.MethodPost && r.URL.Path == "/consumers/group":
+		json.NewEncoder(w).Encode(map[string]string{"instance_id": "c1", "base_uri": server + "/consumers/group/instances/c1"})
+	case r.Method == http.MethodPost && r.URL.Path == "/consumers/group/instances/c1/subscription":
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && r.URL.Path == "/consumers/group/instances/c1/records":
+		pending := []kafkaConsumedRecord{}
+		if !f.polled {
+			pending = append(pending, f.records[f.committed:]...)
+			f.polled = true
+		}
+		json.NewEncoder(w).Encode(pending)
+	case r.Method == http.MethodPost && r.URL.Path == "/consumers/group/instances/c1/offsets":
+		var body struct {
+			Offsets []struct {
+				Offset int64 `json:"offset"`
+			} `json:"offsets"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.committed = body.Offsets[0].Offset + 1
+		f.polled = false
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestKafkaREST_PublishesAndConsumes(t *testing.T) {
+	consumeRetryDelay, pollTimeout = time.Millisecond, time.Millisecond
+	proxy := &fakeProxy{}
+	server := httptest.NewServer(proxy)
+	t.Cleanup(server.Close)
+	kafka := NewKafkaREST(server.URL + "/")
+
+	for _, id := range []string{"e1", "e2"} {
+		if err := kafka.Publish(context.Background(), "orders", "o1", map[string]string{"id": id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var handled []string
+	failures := 1
+	done := make(chan error)
+	go func() {
+		done <- kafka.Consume(ctx, "group", "orders", func(ctx context.Context, msg Message) error {
+			var value map[string]string
+			json.Unmarshal(msg.Value, &value)
+			if value["id"] == "e2" && failures > 0 {
+				failures--
+				return errors.New("not yet")
+			}
+			handled = append(handled, value["id"])
+			if len(handled) == 2 {
+				cancel()
+			}
+			return nil
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumer didn't finish")
+	}
+	if len(handled) != 2 || handled[0] != "e1" || handled[1] != "e2" {
+		t.Fatalf("expected both records handled in order, e2 after a retry, got %v", handled)
+	}
+	if proxy.committed != 1 {
+		t.Fatalf("expected e1 committed, got offset %d", proxy.committed)
+	}
+}
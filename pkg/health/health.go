@@ -0,0 +1,134 @@
+// Package health answers the liveness and readiness probes every service serves at /healthz and /readyz
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+	"ecommerce/pkg/api"
+	"ecommerce/pkg/buildinfo"
+)
+
+// Statuses reported for a service and each of its dependencies
+const (
+	StatusUp   = "UP"
+	StatusDown = "DOWN"
+)
+
+// DefaultTimeout is how long the readiness probe waits for a dependency to answer
+const DefaultTimeout = 2 * time.Second
+
+// Check reports whether a dependency can be used, returning why not when it can't. It should give up once ctx is done.
+type Check func(ctx context.Context) error
+
+// DependencyStatus is the result of checking one dependency
+type DependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is what a probe answers with, naming the build of the service that answered
+type Report struct {
+	Service string `json:"service"`
+	buildinfo.Info
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies,omitempty"`
+}
+
+// Checker answers a service's probes. Liveness only says the process is serving; readiness also
+// checks every dependency the service needs to handle requests.
+type Checker struct {
+	service string
+	timeout time.Duration
+	checks  map[string]Check
+}
+
+// NewChecker creates a checker for service that waits up to timeout for each dependency
+func NewChecker(service string, timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Checker{
+		service: service,
+		timeout: timeout,
+		checks:  make(map[string]Check),
+	}
+}
+
+// Register adds a dependency checked by the readiness probe. Dependencies are registered at startup,
+// before the server takes requests.
+func (c *Checker) Register(name string, check Check) {
+	c.checks[name] = check
+}
+
+// Liveness handles GET /healthz. It never looks at dependencies, so an outage elsewhere doesn't get
+// healthy instances restarted.
+func (c *Checker) Liveness(w http.ResponseWriter, r *http.Request) {
+	api.WriteJSON(w, http.StatusOK, api.Response[api.Unpaged]{
+		Success: true,
+		Message: c.service + " is alive",
+		Data:    Report{Service: c.service, Info: buildinfo.Get(), Status: StatusUp},
+	})
+}
+
+// Readiness handles GET /readyz, answering 503 with the failing dependencies when any of them is down
+func (c *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := c.Check(r.Context())
+	if report.Status != StatusUp {
+		api.WriteJSON(w, http.StatusServiceUnavailable, api.Response[api.Unpaged]{
+			Success: false,
+			Error:   c.service + " is not ready",
+			Data:    report,
+		})
+		return
+	}
+
+	api.WriteJSON(w, http.StatusOK, api.Response[api.Unpaged]{
+		Success: true,
+		Message: c.service + " is ready",
+		Data:    report,
+	})
+}
+
+// Check runs every dependency check at once, each bounded by the checker's timeout, and reports
+// the service up only if all of them pass
+func (c *Checker) Check(ctx context.Context) Report {
+	report := Report{Service: c.service, Info: buildinfo.Get(), Status: StatusUp}
+	if len(c.checks) == 0 {
+		return report
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	report.Dependencies = make(map[string]DependencyStatus, len(c.checks))
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+	)
+	for name, check := range c.checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check(ctx)
+			status := DependencyStatus{Status: StatusUp, LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				status.Status = StatusDown
+				status.Error = err.Error()
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			report.Dependencies[name] = status
+			if err != nil {
+				report.Status = StatusDown
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return report
+}
@@ -0,0 +1,61 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func probe(t *testing.T, handler http.HandlerFunc) (int, Report) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var response struct {
+		Data Report `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode %s: %v", rec.Body.String(), err)
+	}
+	return rec.Code, response.Data
+}
+
+func TestChecker_ReadyOnlyWhenEveryDependencyIsUp(t *testing.T) {
+	checker := NewChecker("order-service", 50*time.Millisecond)
+	checker.Register("user_service", func(ctx context.Context) error { return nil })
+	checker.Register("product_service", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	code, report := probe(t, checker.Readiness)
+	if code != http.StatusServiceUnavailable || report.Status != StatusDown {
+		t.Fatalf("expected 503 with the service down, got %d %+v", code, report)
+	}
+	if report.Dependencies["user_service"].Status != StatusUp {
+		t.Errorf("expected user_service up, got %+v", report.Dependencies["user_service"])
+	}
+	product := report.Dependencies["product_service"]
+	if product.Status != StatusDown || product.Error == "" || product.LatencyMS < 50 {
+		t.Errorf("expected product_service down after the timeout, got %+v", product)
+	}
+
+	// Liveness doesn't look at dependencies
+	if code, report := probe(t, checker.Liveness); code != http.StatusOK || report.Status != StatusUp || report.Version == "" || report.Dependencies != nil {
+		t.Fatalf("expected the service alive, got %d %+v", code, report)
+	}
+
+	checker.Register("product_service", func(ctx context.Context) error { return nil })
+	if code, report := probe(t, checker.Readiness); code != http.StatusOK || len(report.Dependencies) != 2 {
+		t.Fatalf("expected 200 once every dependency is up, got %d %+v", code, report)
+	}
+}
+
+func TestChecker_ReadyWithoutDependencies(t *testing.T) {
+	checker := NewChecker("user-service", 0)
+	if code, report := probe(t, checker.Readiness); code != http.StatusOK || report.Status != StatusUp {
+		t.Fatalf("expected a service with nothing to check to be ready, got %d %+v", code, report)
+	}
+}
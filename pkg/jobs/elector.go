@@ -0,0 +1,171 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultLeaseTTL is how long a leader's lease lasts unless renewed. An instance that dies is
+// replaced as leader within this long.
+const DefaultLeaseTTL = 15 * time.Second
+
+// campaignScript takes the lease if nobody holds it and renews it if this instance does, in one step
+var campaignScript = redis.NewScript(`
+local holder = redis.call("GET", KEYS[1])
+if holder == false then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+if holder == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// resignScript gives the lease up if this instance still holds it
+var resignScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisElector elects a leader among the instances sharing a Redis key. The leader holds a lease on
+// the key, renewing it every third of its TTL; when the leader stops renewing, another instance
+// takes the key once the lease runs out. An instance only counts itself as leading until its lease
+// would run out, so two instances never both believe they lead while Redis is reachable; a job run
+// that outlasts the lease may still overlap one started by the next leader.
+type RedisElector struct {
+	client *redis.Client
+	key    string
+	id     string
+	ttl    time.Duration
+
+	mutex      sync.Mutex
+	leaseUntil time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRedisElector connects to the Redis at url, such as redis://:password@localhost:6379/0, and
+// starts campaigning for the lease on key. It tries once before returning, so an instance that
+// finds no leader leads from the start.
+func NewRedisElector(ctx context.Context, url, key string, ttl time.Duration) (*RedisElector, error) {
+	if url == "" {
+		return nil, errors.New("Redis URL is required")
+	}
+	if ttl < 3*time.Millisecond {
+		return nil, fmt.Errorf("lease TTL %s is too short", ttl)
+	}
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	client := redis.NewClient(options)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connecting to Redis: %w", err)
+	}
+
+	elector := &RedisElector{
+		client: client,
+		key:    key,
+		id:     instanceID(),
+		ttl:    ttl,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	elector.campaign()
+	go elector.renew()
+	return elector, nil
+}
+
+// instanceID names this instance in the lease, unique even among instances on one host
+func instanceID() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 8)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// Leading reports whether this instance holds an unexpired lease
+func (e *RedisElector) Leading() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return time.Now().Before(e.leaseUntil)
+}
+
+// renew campaigns every third of the TTL until Close
+func (e *RedisElector) renew() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			e.campaign()
+		}
+	}
+}
+
+// campaign takes or renews the lease. The lease is counted from before the call, so this
+// instance stops counting itself as leader no later than Redis expires its lease.
+func (e *RedisElector) campaign() {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+	defer cancel()
+	held, err := campaignScript.Run(ctx, e.client, []string{e.key}, e.id, e.ttl.Milliseconds()).Int()
+	if err != nil {
+		slog.Warn("Error renewing the job leader lease", "key", e.key, "error", err)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	wasLeading := start.Before(e.leaseUntil)
+	if held == 1 {
+		e.leaseUntil = start.Add(e.ttl)
+	} else {
+		e.leaseUntil = time.Time{}
+	}
+	switch isLeading := held == 1; {
+	case isLeading && !wasLeading:
+		slog.Info("This instance now runs the leader-only jobs", "key", e.key)
+	case !isLeading && wasLeading:
+		slog.Warn("This instance no longer runs the leader-only jobs", "key", e.key)
+	}
+}
+
+// Close stops campaigning and gives the lease up, so another instance can lead without waiting
+// for it to run out
+func (e *RedisElector) Close() error {
+	close(e.stop)
+	<-e.done
+
+	e.mutex.Lock()
+	e.leaseUntil = time.Time{}
+	e.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+	defer cancel()
+	err := resignScript.Run(ctx, e.client, []string{e.key}, e.id).Err()
+	if closeErr := e.client.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
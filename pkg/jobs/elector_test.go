@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRedisElector_OneLeaderWithFailover(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+	url := "redis://" + server.Addr()
+	ttl := 300 * time.Millisecond
+
+	first, err := NewRedisElector(ctx, url, "jobs:leader", ttl)
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	second, err := NewRedisElector(ctx, url, "jobs:leader", ttl)
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	defer second.Close()
+
+	if !first.Leading() || second.Leading() {
+		t.Fatalf("expected only the first instance to lead, got %v and %v", first.Leading(), second.Leading())
+	}
+	// The leader keeps its lease by renewing it
+	time.Sleep(2 * ttl)
+	if !first.Leading() || second.Leading() {
+		t.Fatalf("expected the first instance to keep leading, got %v and %v", first.Leading(), second.Leading())
+	}
+
+	// Closing hands the lease over
+	first.Close()
+	waitFor(t, second.Leading)
+	if first.Leading() {
+		t.Error("expected a closed elector not to lead")
+	}
+}
+
+func TestRedisElector_StepsDownWithoutRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	elector, err := NewRedisElector(context.Background(), "redis://"+server.Addr(), "jobs:leader", 30*time.Millisecond)
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	defer elector.Close()
+	if !elector.Leading() {
+		t.Fatal("expected the only instance to lead")
+	}
+
+	server.Close()
+	waitFor(t, func() bool { return !elector.Leading() })
+}
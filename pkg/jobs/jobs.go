@@ -0,0 +1,163 @@
+// Package jobs runs a service's background tasks, such as expiring pending orders or sending
+// digests, on a schedule. When several instances of a service run, an Elector picks one of them to
+// run the jobs that must only run once per schedule, while jobs over an instance's own state run
+// everywhere.
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+	"ecommerce/pkg/metrics"
+)
+
+var (
+	jobRuns = metrics.NewCounterVec("job_runs_total",
+		"Background job runs by job and result (success, failure, or skipped when another instance leads)", "job", "result")
+	jobDuration = metrics.NewHistogramVec("job_duration_seconds",
+		"How long background job runs took, by job", []float64{0.01, 0.1, 0.5, 1, 5, 10, 30, 60, 300}, "job")
+)
+
+// Job is a task run on a schedule
+type Job struct {
+	Name     string
+	Schedule Schedule
+	// Run does the work. now is when the run was due; ctx is cancelled when the scheduler stops.
+	Run func(ctx context.Context, now time.Time) error
+	// EveryInstance runs the job on every instance rather than only the leader, for work on state
+	// each instance keeps for itself
+	EveryInstance bool
+}
+
+// Elector decides whether this instance is the one running leader-only jobs
+type Elector interface {
+	// Leading reports whether this instance leads right now
+	Leading() bool
+}
+
+// Solo is the Elector of a service run as a single instance, which always leads
+type Solo struct{}
+
+// Leading always reports true
+func (Solo) Leading() bool { return true }
+
+// Scheduler runs jobs on their schedules, each on its own goroutine, so a slow job delays only
+// its own next run. A job runs again only after its last run ends.
+type Scheduler struct {
+	elector  Elector
+	jobs     []Job
+	ctx      context.Context // runs' context, cancelled to cut them short
+	cancel   context.CancelFunc
+	stopping chan struct{} // closed once no more runs should start
+	wg       sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler whose leader-only jobs run while elector says this instance leads
+func NewScheduler(elector Elector) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{elector: elector, ctx: ctx, cancel: cancel, stopping: make(chan struct{})}
+}
+
+// Add registers a job; jobs added after Start don't run
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start begins running the jobs
+func (s *Scheduler) Start() {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(job)
+	}
+}
+
+// Stop cancels the jobs, waits for runs under way to return, and then closes the elector if it
+// can be closed, so another instance can take over at once
+func (s *Scheduler) Stop() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Shutdown(ctx)
+}
+
+// Shutdown stops starting runs and gives the runs under way until ctx is done to finish. Then it
+// cancels those still going, which should stop where their work so far is saved, such as between
+// orders, and waits for them to return. The elector is closed as Stop closes it. It returns ctx's
+// error when runs were cut short.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	close(s.stopping)
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(finished)
+	}()
+
+	var err error
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		err = ctx.Err()
+		s.cancel()
+		<-finished
+	}
+	s.cancel()
+	if closer, ok := s.elector.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			slog.Error("Error closing the job elector", "error", err)
+		}
+	}
+	return err
+}
+
+// loop runs a job each time it falls due until the scheduler stops
+func (s *Scheduler) loop(job Job) {
+	defer s.wg.Done()
+
+	next := job.Schedule.Next(time.Now())
+	for !next.IsZero() {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.stopping:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		select {
+		case <-s.stopping:
+			return // stopping began as the run fell due
+		default:
+		}
+
+		if job.EveryInstance || s.elector.Leading() {
+			s.run(job, next)
+		} else {
+			jobRuns.WithLabelValues(job.Name, "skipped").Inc()
+		}
+		next = job.Schedule.Next(time.Now())
+	}
+	slog.Info("Job has no more runs scheduled", "job", job.Name)
+}
+
+// run runs a job once, recording its result. A panicking job counts as a failed run rather
+// than taking the service down.
+func (s *Scheduler) run(job Job, now time.Time) {
+	start := time.Now()
+	err := func() (err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				err = fmt.Errorf("panic: %v", recovered)
+			}
+		}()
+		return job.Run(s.ctx, now)
+	}()
+	jobDuration.WithLabelValues(job.Name).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		slog.Error("Job failed", "job", job.Name, "error", err)
+		jobRuns.WithLabelValues(job.Name, "failure").Inc()
+		return
+	}
+	jobRuns.WithLabelValues(job.Name, "success").Inc()
+}
@@ -0,0 +1,131 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// leader is an Elector switched on and off by the test
+type leader struct{ leading atomic.Bool }
+
+func (l *leader) Leading() bool { return l.leading.Load() }
+
+// waitFor polls until condition holds or a second passes
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScheduler_RunsLeaderOnlyJobsWhileLeading(t *testing.T) {
+	elector := &leader{}
+	scheduler := NewScheduler(elector)
+	var leaderRuns, everyRuns, failingRuns atomic.Int32
+	scheduler.Add(Job{Name: "leader-only", Schedule: Every(2 * time.Millisecond), Run: func(ctx context.Context, now time.Time) error {
+		leaderRuns.Add(1)
+		return nil
+	}})
+	scheduler.Add(Job{Name: "everywhere", Schedule: Every(2 * time.Millisecond), EveryInstance: true, Run: func(ctx context.Context, now time.Time) error {
+		everyRuns.Add(1)
+		return nil
+	}})
+	scheduler.Add(Job{Name: "failing", Schedule: Every(2 * time.Millisecond), EveryInstance: true, Run: func(ctx context.Context, now time.Time) error {
+		if failingRuns.Add(1) == 1 {
+			panic("boom")
+		}
+		return errors.New("still failing")
+	}})
+	scheduler.Start()
+
+	waitFor(t, func() bool { return everyRuns.Load() >= 3 })
+	if got := leaderRuns.Load(); got != 0 {
+		t.Errorf("expected no leader-only runs before leading, got %d", got)
+	}
+	elector.leading.Store(true)
+	waitFor(t, func() bool { return leaderRuns.Load() >= 2 })
+	waitFor(t, func() bool { return failingRuns.Load() >= 2 })
+
+	scheduler.Stop()
+	stopped := everyRuns.Load()
+	time.Sleep(10 * time.Millisecond)
+	if got := everyRuns.Load(); got != stopped {
+		t.Errorf("expected no runs after Stop, got %d more", got-stopped)
+	}
+}
+
+func TestScheduler_StopCancelsRunningJobs(t *testing.T) {
+	scheduler := NewScheduler(Solo{})
+	var started atomic.Bool
+	scheduler.Add(Job{Name: "slow", Schedule: Every(time.Millisecond), Run: func(ctx context.Context, now time.Time) error {
+		started.Store(true)
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	scheduler.Start()
+	waitFor(t, started.Load)
+
+	done := make(chan struct{})
+	go func() {
+		scheduler.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Stop to cancel the running job")
+	}
+}
+
+func TestScheduler_ShutdownLetsRunsFinish(t *testing.T) {
+	scheduler := NewScheduler(Solo{})
+	var started, cancelled atomic.Bool
+	var runs atomic.Int32
+	release := make(chan struct{})
+	scheduler.Add(Job{Name: "batch", Schedule: Every(time.Millisecond), Run: func(ctx context.Context, now time.Time) error {
+		runs.Add(1)
+		started.Store(true)
+		select {
+		case <-release:
+		case <-ctx.Done():
+			cancelled.Store(true)
+		}
+		return nil
+	}})
+	scheduler.Start()
+	waitFor(t, started.Load)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	if err := scheduler.Shutdown(context.Background()); err != nil || cancelled.Load() {
+		t.Fatalf("expected the run under way to finish uncancelled, got %v", err)
+	}
+	if got := runs.Load(); got != 1 {
+		t.Errorf("expected no runs started during shutdown, got %d", got)
+	}
+
+	// Past the deadline, runs still going are cancelled
+	scheduler = NewScheduler(Solo{})
+	started.Store(false)
+	scheduler.Add(Job{Name: "stuck", Schedule: Every(time.Millisecond), Run: func(ctx context.Context, now time.Time) error {
+		started.Store(true)
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	scheduler.Start()
+	waitFor(t, started.Load)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := scheduler.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline reported, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron_Next(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2024, 5, 15, 8, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 5, 15, 8, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 5, 15, 8, 45, 0, 0, time.UTC)},
+		{"0 8 * * *", time.Date(2024, 5, 16, 8, 0, 0, 0, time.UTC)},
+		{"0 8 * * 1-5", time.Date(2024, 5, 16, 8, 0, 0, 0, time.UTC)},
+		{"0 9 * * 0", time.Date(2024, 5, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2024, 5, 19, 9, 0, 0, 0, time.UTC)},
+		{"30 6 1 * *", time.Date(2024, 6, 1, 6, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Day of month or day of week when both are restricted: the 20th, or the Friday before it
+		{"0 12 20 * 5", time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC)},
+		{"5,10 9-10/1 * 5 *", time.Date(2024, 5, 15, 9, 5, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.expr, err)
+		}
+		if got := schedule.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: expected %s, got %s", tt.expr, tt.want, got)
+		}
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("%q: expected an error", expr)
+		}
+	}
+}
+
+func TestParseCron_NeverMatching(t *testing.T) {
+	schedule, _ := ParseCron("0 0 31 2 *")
+	if next := schedule.Next(time.Now()); !next.IsZero() {
+		t.Errorf("expected no run on February 31st, got %s", next)
+	}
+}
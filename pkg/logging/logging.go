@@ -0,0 +1,76 @@
+// Package logging sets up the structured JSON logs every service writes
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"ecommerce/pkg/requestid"
+)
+
+// level is the lowest level every handler from NewHandler logs at; SetLevel changes it while the service runs
+var level slog.LevelVar
+
+// Setup makes the default slog logger write JSON to stdout, tagging every entry with the service's
+// name and, when logged with a context, the ID of the request it was logged for. Output from the
+// standard log package goes through the same logger.
+func Setup(service string) {
+	slog.SetDefault(slog.New(NewHandler(os.Stdout)).With("service", service))
+}
+
+// NewHandler creates a handler writing JSON entries to w, with the request ID of each entry's context
+func NewHandler(w io.Writer) slog.Handler {
+	return &contextHandler{Handler: slog.NewJSONHandler(w, &slog.HandlerOptions{Level: &level})}
+}
+
+// ParseLevel parses a level name: debug, info, warn, or error, in any case
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+// SetLevel changes the lowest level logged, starting with the next entry
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// Fatal logs msg at error level and exits, for configuration a service can't start without
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// contextHandler adds the request ID carried by a log call's context to the entry
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds the request ID, if there is one, and passes the entry on
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := requestid.FromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs keeps request IDs being added to loggers derived with With
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup keeps request IDs being added to loggers derived with WithGroup
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
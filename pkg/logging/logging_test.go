@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"ecommerce/pkg/requestid"
+)
+
+func TestHandler_AddsTheRequestIDOfTheContext(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(NewHandler(&out)).With("service", "order-service")
+
+	logger.InfoContext(requestid.NewContext(context.Background(), "req-1"), "order placed", "order_id", "o1")
+	logger.Info("no request")
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("expected two entries, got %s", out.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(lines[0], &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["request_id"] != "req-1" || entry["service"] != "order-service" || entry["order_id"] != "o1" {
+		t.Fatalf("expected the request ID and attributes, got %v", entry)
+	}
+	if bytes.Contains(lines[1], []byte("request_id")) {
+		t.Fatalf("expected no request ID without one in the context, got %s", lines[1])
+	}
+}
+
+func TestSetLevel_DropsEntriesBelowTheLevel(t *testing.T) {
+	defer SetLevel(slog.LevelInfo)
+	var out bytes.Buffer
+	logger := slog.New(NewHandler(&out))
+
+	logger.Debug("hidden")
+	level, err := ParseLevel("DEBUG")
+	if err != nil {
+		t.Fatalf("ParseLevel failed: %v", err)
+	}
+	SetLevel(level)
+	logger.Debug("shown")
+
+	if strings.Contains(out.String(), "hidden") || !strings.Contains(out.String(), "shown") {
+		t.Fatalf("expected only the entry logged after lowering the level, got %s", out.String())
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("expected an unknown level to be rejected")
+	}
+}
@@ -0,0 +1,350 @@
+// Package metrics keeps counters, histograms, and gauges and serves them in the Prometheus text
+// exposition format, so each service can be scraped at /metrics
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are latency buckets, in seconds, suited to HTTP requests
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// collector is a metric family the registry can write out
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds a service's metrics
+type Registry struct {
+	collectors map[string]collector
+	mutex      sync.RWMutex
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// Default is the registry the package-level constructors register with and Handler serves
+var Default = NewRegistry()
+
+// register adds a metric; registering two metrics with the same name is a programming error
+func (r *Registry) register(c collector) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.collectors[c.name()]; exists {
+		panic("metrics: " + c.name() + " registered twice")
+	}
+	r.collectors[c.name()] = c
+}
+
+// Handler serves every metric in the Prometheus text format, sorted by name
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mutex.RLock()
+		names := make([]string, 0, len(r.collectors))
+		for name := range r.collectors {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		collectors := make([]collector, len(names))
+		for i, name := range names {
+			collectors[i] = r.collectors[name]
+		}
+		r.mutex.RUnlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		out := bufio.NewWriter(w)
+		for _, c := range collectors {
+			c.write(out)
+		}
+		out.Flush()
+	})
+}
+
+// Handler serves the default registry
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// family holds what every metric family shares: its name, help text, and label names
+type family struct {
+	metricName string
+	help       string
+	labels     []string
+}
+
+func (f *family) name() string { return f.metricName }
+
+// writeHeader writes the family's HELP and TYPE lines
+func (f *family) writeHeader(w *bufio.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.metricName, escapeHelp(f.help), f.metricName, kind)
+}
+
+// seriesKey joins label values into a map key, checking there is one for each label
+func (f *family) seriesKey(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.metricName, len(f.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs renders label values as {name="value",...}, with extra pairs appended
+func (f *family) labelPairs(values []string, extra ...string) string {
+	if len(values) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(values)+len(extra)/2)
+	for i, value := range values {
+		pairs = append(pairs, f.labels[i]+`="`+escapeLabel(value)+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// CounterVec is a counter split by label values
+type CounterVec struct {
+	family
+	series map[string]*Counter
+	mutex  sync.Mutex
+}
+
+// Counter is a value that only goes up
+type Counter struct {
+	labelValues []string
+	value       float64
+	mutex       sync.Mutex
+}
+
+// NewCounterVec registers a counter with the given labels in the default registry
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return Default.NewCounterVec(name, help, labels...)
+}
+
+// NewCounter registers an unlabelled counter in the default registry
+func NewCounter(name, help string) *Counter {
+	return Default.NewCounterVec(name, help).WithLabelValues()
+}
+
+// NewCounterVec registers a counter with the given labels
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{family: family{metricName: name, help: help, labels: labels}, series: make(map[string]*Counter)}
+	r.register(c)
+	return c
+}
+
+// WithLabelValues returns the counter for the label values, given in the order the labels were declared
+func (c *CounterVec) WithLabelValues(values ...string) *Counter {
+	key := c.seriesKey(values)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	counter, ok := c.series[key]
+	if !ok {
+		counter = &Counter{labelValues: append([]string(nil), values...)}
+		c.series[key] = counter
+	}
+	return counter
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds delta, which must not be negative, to the counter
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("metrics: counters can't go down")
+	}
+	c.mutex.Lock()
+	c.value += delta
+	c.mutex.Unlock()
+}
+
+// Value returns the counter's current value
+func (c *Counter) Value() float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.value
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.writeHeader(w, "counter")
+	for _, counter := range c.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.labelPairs(counter.labelValues), formatFloat(counter.Value()))
+	}
+}
+
+// sorted returns the counters ordered by label values, so output is stable between scrapes
+func (c *CounterVec) sorted() []*Counter {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	keys := make([]string, 0, len(c.series))
+	for key := range c.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	counters := make([]*Counter, len(keys))
+	for i, key := range keys {
+		counters[i] = c.series[key]
+	}
+	return counters
+}
+
+// HistogramVec is a histogram split by label values
+type HistogramVec struct {
+	family
+	buckets []float64
+	series  map[string]*Histogram
+	mutex   sync.Mutex
+}
+
+// Histogram counts observations into buckets, keeping their count and sum
+type Histogram struct {
+	labelValues []string
+	buckets     []float64
+	counts      []uint64 // per bucket, not cumulative
+	count       uint64
+	sum         float64
+	mutex       sync.Mutex
+}
+
+// NewHistogramVec registers a histogram with the given upper bucket bounds and labels in the default registry
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.NewHistogramVec(name, help, buckets, labels...)
+}
+
+// NewHistogramVec registers a histogram with the given upper bucket bounds, in increasing order, and labels
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: " + name + " buckets must be in increasing order")
+	}
+	h := &HistogramVec{
+		family:  family{metricName: name, help: help, labels: labels},
+		buckets: append([]float64(nil), buckets...),
+		series:  make(map[string]*Histogram),
+	}
+	r.register(h)
+	return h
+}
+
+// WithLabelValues returns the histogram for the label values, given in the order the labels were declared
+func (h *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	key := h.seriesKey(values)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	histogram, ok := h.series[key]
+	if !ok {
+		histogram = &Histogram{
+			labelValues: append([]string(nil), values...),
+			buckets:     h.buckets,
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = histogram
+	}
+	return histogram
+}
+
+// Observe records a value
+func (h *Histogram) Observe(value float64) {
+	i := sort.SearchFloat64s(h.buckets, value)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += value
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.writeHeader(w, "histogram")
+
+	h.mutex.Lock()
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	histograms := make([]*Histogram, len(keys))
+	for i, key := range keys {
+		histograms[i] = h.series[key]
+	}
+	h.mutex.Unlock()
+
+	for _, histogram := range histograms {
+		histogram.mutex.Lock()
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += histogram.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(histogram.labelValues, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(histogram.labelValues, "le", "+Inf"), histogram.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labelPairs(histogram.labelValues), formatFloat(histogram.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labelPairs(histogram.labelValues), histogram.count)
+		histogram.mutex.Unlock()
+	}
+}
+
+// GaugeFunc is a gauge whose value is read when the metrics are scraped
+type GaugeFunc struct {
+	family
+	value func() float64
+}
+
+// NewGaugeFunc registers a gauge reading its value from value in the default registry
+func NewGaugeFunc(name, help string, value func() float64) *GaugeFunc {
+	return Default.NewGaugeFunc(name, help, value)
+}
+
+// NewGaugeFunc registers a gauge reading its value from value, which must be safe to call concurrently
+func (r *Registry) NewGaugeFunc(name, help string, value func() float64) *GaugeFunc {
+	g := &GaugeFunc{family: family{metricName: name, help: help}, value: value}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	g.writeHeader(w, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatFloat(g.value()))
+}
+
+// formatFloat renders a sample value the way Prometheus expects
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string { return labelEscaper.Replace(value) }
+
+func escapeHelp(help string) string { return helpEscaper.Replace(help) }
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, registry *Registry) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("expected the Prometheus text format, got %q", rec.Header().Get("Content-Type"))
+	}
+	return rec.Body.String()
+}
+
+func TestRegistry_WritesCountersAndGauges(t *testing.T) {
+	registry := NewRegistry()
+	orders := registry.NewCounterVec("orders_total", "Orders placed", "status")
+	orders.WithLabelValues("paid").Inc()
+	orders.WithLabelValues("paid").Add(2)
+	orders.WithLabelValues(`odd"value`).Inc()
+	registry.NewGaugeFunc("orders_stored", "Orders in the repository", func() float64 { return 7 })
+
+	body := scrape(t, registry)
+	for _, line := range []string{
+		"# HELP orders_total Orders placed",
+		"# TYPE orders_total counter",
+		`orders_total{status="paid"} 3`,
+		`orders_total{status="odd\"value"} 1`,
+		"# TYPE orders_stored gauge",
+		"orders_stored 7",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("expected %q in\n%s", line, body)
+		}
+	}
+	if strings.Index(body, "orders_stored") > strings.Index(body, "orders_total") {
+		t.Fatal("expected metrics sorted by name")
+	}
+}
+
+func TestRegistry_WritesCumulativeHistogramBuckets(t *testing.T) {
+	registry := NewRegistry()
+	latency := registry.NewHistogramVec("call_seconds", "Call latency", []float64{0.1, 1}, "service")
+	for _, value := range []float64{0.05, 0.1, 0.5, 3} {
+		latency.WithLabelValues("user").Observe(value)
+	}
+
+	body := scrape(t, registry)
+	for _, line := range []string{
+		"# TYPE call_seconds histogram",
+		`call_seconds_bucket{service="user",le="0.1"} 2`,
+		`call_seconds_bucket{service="user",le="1"} 3`,
+		`call_seconds_bucket{service="user",le="+Inf"} 4`,
+		`call_seconds_sum{service="user"} 3.65`,
+		`call_seconds_count{service="user"} 4`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("expected %q in\n%s", line, body)
+		}
+	}
+}
+
+func TestRegistry_RejectsDuplicateNames(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounterVec("requests_total", "Requests")
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected registering the same name twice to panic")
+		}
+	}()
+	registry.NewGaugeFunc("requests_total", "Requests", func() float64 { return 0 })
+}
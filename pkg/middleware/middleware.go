@@ -0,0 +1,115 @@
+// Package middleware holds the HTTP middleware every service wraps its router in
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"ecommerce/pkg/api"
+	"ecommerce/pkg/requestid"
+)
+
+// CORS adds CORS headers for requests from allowedOrigins and answers preflight requests. An origin
+// of * allows any; other origins get no Access-Control-Allow-Origin header, so browsers block them.
+func CORS(allowedOrigins []string) func(http.Handler) http.Handler {
+	allowAny := false
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			allowAny = true
+		}
+		allowed[strings.TrimRight(origin, "/")] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if allowAny {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				// The response depends on the caller's origin, so caches must keep them apart
+				w.Header().Add("Vary", "Origin")
+				if origin := r.Header.Get("Origin"); allowed[origin] {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+				}
+			}
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Service-Key, If-None-Match, If-Modified-Since, "+APIVersionHeader+", "+requestid.Header)
+			w.Header().Set("Access-Control-Expose-Headers", "ETag, Last-Modified, Retry-After, "+RateLimitLimitHeader+", "+RateLimitRemainingHeader+", "+RateLimitResetHeader+", "+APIVersionHeader+", "+ServiceVersionHeader+", Deprecation, Link, "+requestid.Header)
+
+			// Handle preflight requests
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequestID gives every request an ID, taken from X-Request-ID when the caller sent one, puts it in the
+// request's context for logging and for calls to other services, and echoes it on the response
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestid.FromRequest(r)
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	})
+}
+
+// ProblemDetails sends error responses as RFC 7807 application/problem+json to clients whose Accept
+// header asks for it; everyone else keeps getting the standard envelope. Errors written by middleware
+// it wraps are converted too, so it belongs near the outside of the chain.
+func ProblemDetails(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if api.AcceptsProblems(r) {
+			w = api.ProblemWriter(w, r.URL.Path)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Logging logs HTTP requests with their status and how long they took
+func Logging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// Call the next handler
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		// Log the request
+		slog.InfoContext(r.Context(), "request",
+			"method", r.Method,
+			"uri", r.RequestURI,
+			"remote_addr", r.RemoteAddr,
+			"status", recorder.status,
+			"duration", time.Since(start),
+		)
+	})
+}
+
+// statusRecorder remembers the status code a handler wrote
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code and passes it on
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap returns the writer underneath, for http.ResponseController and api.WriteErrorResponse
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Flush passes flushes on, for handlers that stream their response
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,382 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"ecommerce/pkg/api"
+	"ecommerce/pkg/buildinfo"
+	"ecommerce/pkg/config"
+	"ecommerce/pkg/metrics"
+	"ecommerce/pkg/ratelimit"
+	"ecommerce/pkg/requestid"
+
+	"github.com/gorilla/mux"
+)
+
+func TestCORS_AnswersPreflightRequests(t *testing.T) {
+	called := false
+	handler := CORS([]string{"*"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/api/v1/orders", nil))
+	if rec.Code != http.StatusOK || called || rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("expected the preflight answered without calling the handler, got %d %v", rec.Code, called)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/orders", nil))
+	if !called || !strings.Contains(rec.Header().Get("Access-Control-Expose-Headers"), "ETag") {
+		t.Fatal("expected other requests passed on with CORS headers")
+	}
+}
+
+func TestCORS_AllowsOnlyListedOrigins(t *testing.T) {
+	handler := CORS([]string{"https://shop.example", "https://admin.example/"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for origin, want := range map[string]string{
+		"https://shop.example":  "https://shop.example",
+		"https://admin.example": "https://admin.example",
+		"https://evil.example":  "",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/orders", nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != want || rec.Header().Get("Vary") != "Origin" {
+			t.Errorf("%s: expected allowed origin %q, got %q", origin, want, got)
+		}
+	}
+}
+
+func TestRequestID_PutsTheIDInTheContextAndResponse(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestid.FromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orders", nil)
+	req.Header.Set(requestid.Header, "from-caller")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if seen != "from-caller" || rec.Header().Get(requestid.Header) != "from-caller" {
+		t.Fatalf("expected the caller's ID kept, got %q and %q", seen, rec.Header().Get(requestid.Header))
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/orders", nil))
+	if seen == "" || seen == "from-caller" || rec.Header().Get(requestid.Header) != seen {
+		t.Fatalf("expected a new ID generated and echoed, got %q and %q", seen, rec.Header().Get(requestid.Header))
+	}
+}
+
+func TestProblemDetails_ConvertsErrorsForClientsThatAskForThem(t *testing.T) {
+	// Logging wraps the writer too, as it does in the services
+	handler := ProblemDetails(Logging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.WriteErrorCode(w, http.StatusNotFound, "ORDER_NOT_FOUND", "Order not found")
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/o1", nil)
+	req.Header.Set("Accept", "application/problem+json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Type") != api.ProblemContentType {
+		t.Fatalf("expected problem details, got %q", rec.Header().Get("Content-Type"))
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"code":"ORDER_NOT_FOUND"`) || !strings.Contains(body, `"instance":"/orders/o1"`) {
+		t.Errorf("unexpected body %s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders/o1", nil))
+	if body := rec.Body.String(); rec.Header().Get("Content-Type") != "application/json" || !strings.Contains(body, `"success":false`) {
+		t.Errorf("expected the standard envelope, got %q %s", rec.Header().Get("Content-Type"), body)
+	}
+}
+
+func TestMetrics_LabelsRequestsByRouteTemplate(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(Metrics)
+	router.HandleFunc("/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	before := httpRequests.WithLabelValues(http.MethodGet, "/orders/{id}", "404").Value()
+	for _, id := range []string{"a", "b"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders/"+id, nil))
+	}
+	if got := httpRequests.WithLabelValues(http.MethodGet, "/orders/{id}", "404").Value() - before; got != 2 {
+		t.Fatalf("expected both requests counted under the route template, got %v", got)
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), `http_request_duration_seconds_count{method="GET",route="/orders/{id}"}`) {
+		t.Fatalf("expected the request timed under its route, got\n%s", rec.Body.String())
+	}
+}
+
+// testService names the calling service the way the services' auth middleware would, from X-Service-Key
+func testService(r *http.Request) *http.Request {
+	if key := r.Header.Get("X-Service-Key"); key != "" {
+		return r.WithContext(context.WithValue(r.Context(), testServiceKey{}, key))
+	}
+	return r
+}
+
+type testServiceKey struct{}
+
+func serviceFromTestContext(ctx context.Context) string {
+	service, _ := ctx.Value(testServiceKey{}).(string)
+	return service
+}
+
+func TestRateLimit_LimitsEachClientSeparately(t *testing.T) {
+	limit := RateLimit("test", ratelimit.NewTokenBucket(2, 1), ratelimit.NewTokenBucket(1, 1), serviceFromTestContext)
+	handler := limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	request := func(remoteAddr, service string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.RemoteAddr = remoteAddr
+		if service != "" {
+			req.Header.Set("X-Service-Key", service)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, testService(req))
+		return rec
+	}
+
+	first := request("10.0.0.1:5000", "")
+	if first.Code != http.StatusOK || first.Header().Get(RateLimitLimitHeader) != "2" || first.Header().Get(RateLimitRemainingHeader) != "1" {
+		t.Fatalf("expected the first request allowed with one left, got %d %v", first.Code, first.Header())
+	}
+	request("10.0.0.1:5001", "")
+	limited := request("10.0.0.1:5002", "")
+	if limited.Code != http.StatusTooManyRequests || limited.Header().Get("Retry-After") != "60" || limited.Header().Get(RateLimitRemainingHeader) != "0" {
+		t.Fatalf("expected the third request from the IP limited, got %d %v", limited.Code, limited.Header())
+	}
+	if reset := limited.Header().Get(RateLimitResetHeader); reset != "120" {
+		t.Errorf("expected two minutes until the limit is full again, got %s", reset)
+	}
+
+	if rec := request("10.0.0.2:5000", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected another IP to have its own limit, got %d", rec.Code)
+	}
+	if rec := request("10.0.0.1:5003", "order-service"); rec.Code != http.StatusOK || rec.Header().Get(RateLimitLimitHeader) != "1" {
+		t.Errorf("expected a service to be limited apart from its IP, got %d %v", rec.Code, rec.Header())
+	}
+	if rec := request("10.0.0.1:5004", "order-service"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the service's own limit to apply, got %d", rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), `http_rate_limited_total{limit="test"} 2`) {
+		t.Errorf("expected the rejections counted, got\n%s", rec.Body.String())
+	}
+}
+
+func TestRateLimit_LeavesCallersWithoutALimiterAlone(t *testing.T) {
+	limit := RateLimit("checkout", ratelimit.NewTokenBucket(1, 1), nil, serviceFromTestContext)
+	handler := limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+		req.Header.Set("X-Service-Key", "subscription-runner")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, testService(req))
+		if rec.Code != http.StatusOK || rec.Header().Get(RateLimitLimitHeader) != "" {
+			t.Fatalf("expected services unlimited, got %d %v", rec.Code, rec.Header())
+		}
+	}
+}
+
+// failingLimiter is a limiter whose backend is down
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(ctx context.Context, key string) (ratelimit.Decision, error) {
+	return ratelimit.Decision{}, errors.New("backend down")
+}
+
+func TestRateLimit_FailsOpen(t *testing.T) {
+	called := false
+	handler := RateLimit("test", failingLimiter{}, nil, serviceFromTestContext)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products", nil))
+	if !called || rec.Header().Get(RateLimitLimitHeader) != "" {
+		t.Fatalf("expected the request through without rate limit headers, got %v", rec.Header())
+	}
+}
+
+func TestRateLimiter_ReadsItsLimitsFromConfig(t *testing.T) {
+	cfg, err := config.Load([]string{"--rate-limit-checkout-burst=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := RateLimiter(config.NewReloader(cfg), "RATE_LIMIT_CHECKOUT", 10, 30)
+
+	if decision, _ := limiter.Allow(context.Background(), "10.0.0.1"); !decision.Allowed {
+		t.Fatalf("expected the first request allowed, got %+v", decision)
+	}
+	if decision, _ := limiter.Allow(context.Background(), "10.0.0.1"); decision.Allowed {
+		t.Errorf("expected the configured burst of 1 to apply, got %+v", decision)
+	}
+}
+
+func TestShedder_TurnsAwayRequestsOverTheLimit(t *testing.T) {
+	shedder := NewShedder(2)
+	entered, release := make(chan struct{}), make(chan struct{})
+	handler := shedder.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+		return rec
+	}
+
+	done := make(chan *httptest.ResponseRecorder, 2)
+	for i := 0; i < 2; i++ {
+		go func() { done <- serve() }()
+		<-entered
+	}
+	rec := serve()
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected 503 with Retry-After over the limit, got %d %v", rec.Code, rec.Header())
+	}
+
+	// Raising the limit lets more in at once, and requests finishing make room
+	shedder.SetLimit(3)
+	go func() { done <- serve() }()
+	<-entered
+	close(release)
+	for i := 0; i < 3; i++ {
+		if rec := <-done; rec.Code != http.StatusOK {
+			t.Errorf("expected the requests let in to be served, got %d", rec.Code)
+		}
+	}
+	go func() { <-entered }()
+	if rec := serve(); rec.Code != http.StatusOK {
+		t.Errorf("expected room once requests finished, got %d", rec.Code)
+	}
+}
+
+func TestShedder_WithoutALimitLetsEveryoneIn(t *testing.T) {
+	handler := NewShedder(0).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the request through, got %d", rec.Code)
+	}
+}
+
+// versionedRouter routes /v1/orders and an unversioned /healthz, echoing the path it served
+func versionedRouter() *mux.Router {
+	router := mux.NewRouter()
+	echo := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(r.URL.Path)) }
+	router.HandleFunc("/healthz", echo).Methods(http.MethodGet)
+	router.PathPrefix("/v1").Subrouter().HandleFunc("/orders", echo).Methods(http.MethodGet)
+	return router
+}
+
+func TestTrustProxies_TakesTheClientFromTrustedProxies(t *testing.T) {
+	var seen string
+	handler := TrustProxies([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.RemoteAddr
+	}))
+	request := func(remoteAddr, forwardedFor string) string {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return seen
+	}
+
+	if addr := request("10.0.0.5:4000", "203.0.113.9"); addr != "203.0.113.9:0" {
+		t.Errorf("expected the client behind the gateway, got %s", addr)
+	}
+	if addr := request("10.0.0.5:4000", "198.51.100.1, 203.0.113.9, 10.0.0.6"); addr != "203.0.113.9:0" {
+		t.Errorf("expected the rightmost address that isn't a proxy, not the one the client wrote, got %s", addr)
+	}
+	if addr := request("203.0.113.9:4000", "198.51.100.1"); addr != "203.0.113.9:4000" {
+		t.Errorf("expected X-Forwarded-For ignored from a client, got %s", addr)
+	}
+	if addr := request("10.0.0.5:4000", "not-an-ip"); addr != "10.0.0.5:4000" {
+		t.Errorf("expected the proxy kept when X-Forwarded-For can't be read, got %s", addr)
+	}
+}
+
+func TestVersioned_ServesVersionedAndOperationalPaths(t *testing.T) {
+	handler := Versioned(versionedRouter(), "v1", "v1")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/orders", nil))
+	if rec.Body.String() != "/v1/orders" || rec.Header().Get(APIVersionHeader) != "v1" || rec.Header().Get("Deprecation") != "" {
+		t.Fatalf("expected /v1/orders served as v1, got %q %v", rec.Body.String(), rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Body.String() != "/healthz" || rec.Header().Get("Deprecation") != "" {
+		t.Fatalf("expected /healthz served unversioned, got %q %v", rec.Body.String(), rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/healthz", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected the wrong method on /healthz to get 405, got %d", rec.Code)
+	}
+}
+
+func TestVersioned_ShimsUnversionedPaths(t *testing.T) {
+	handler := Versioned(versionedRouter(), "v1", "v1")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	if rec.Body.String() != "/v1/orders" || rec.Header().Get(APIVersionHeader) != "v1" {
+		t.Fatalf("expected /orders served by /v1/orders, got %q %v", rec.Body.String(), rec.Header())
+	}
+	if rec.Header().Get("Deprecation") != "true" || rec.Header().Get("Link") != `</v1/orders>; rel="successor-version"` {
+		t.Fatalf("expected the shim marked deprecated with a link to its successor, got %v", rec.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set(APIVersionHeader, "v1")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Body.String() != "/v1/orders" {
+		t.Fatalf("expected the header's version served, got %q", rec.Body.String())
+	}
+}
+
+func TestVersioned_RejectsUnknownVersions(t *testing.T) {
+	handler := Versioned(versionedRouter(), "v1", "v1")
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set(APIVersionHeader, "v9")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), CodeUnsupportedVersion) {
+		t.Fatalf("expected 400 %s, got %d %s", CodeUnsupportedVersion, rec.Code, rec.Body.String())
+	}
+}
+
+func TestServiceVersion_NamesTheBuild(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ServiceVersion(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/orders", nil))
+	if got := rec.Header().Get(ServiceVersionHeader); got == "" || got != buildinfo.Get().String() {
+		t.Errorf("expected the build named, got %q", got)
+	}
+}
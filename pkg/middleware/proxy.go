@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustProxies takes each request's client address from X-Forwarded-For when it came from one of the
+// proxies in networks, such as the gateway, so rate limits, logs, and sessions see the client rather
+// than the proxy. The rightmost address that isn't itself a trusted proxy is taken, since everything
+// left of it was written by the client. Requests from anywhere else keep their own address, so a
+// client can't pass itself off as another. With no networks it changes nothing.
+func TrustProxies(networks []netip.Prefix) func(http.Handler) http.Handler {
+	trusted := func(addr netip.Addr) bool {
+		for _, network := range networks {
+			if network.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(networks) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer, err := netip.ParseAddrPort(r.RemoteAddr)
+			forwarded := r.Header.Values("X-Forwarded-For")
+			if err != nil || !trusted(peer.Addr()) || len(forwarded) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			hops := strings.Split(strings.Join(forwarded, ","), ",")
+			for i := len(hops) - 1; i >= 0; i-- {
+				client, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+				if err != nil {
+					break
+				}
+				if !trusted(client) || i == 0 {
+					r = r.Clone(r.Context())
+					r.RemoteAddr = net.JoinHostPort(client.Unmap().String(), "0")
+					break
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"ecommerce/pkg/config"
+	"ecommerce/pkg/ratelimit"
+)
+
+// RateLimiter creates the token bucket for the limit named prefix, allowing <prefix>_BURST requests at
+// once and <prefix>_PER_MINUTE sustained per client; both are re-read on reload
+func RateLimiter(reloader *config.Reloader, prefix string, burst, perMinute int) *ratelimit.TokenBucket {
+	limiter := ratelimit.NewTokenBucket(burst, perMinute)
+	reloader.Register(func(cfg *config.Config) func() {
+		nextBurst, nextPerMinute := cfg.Int(prefix+"_BURST", burst, config.Positive), cfg.Int(prefix+"_PER_MINUTE", perMinute, config.Positive)
+		return func() { limiter.SetLimits(nextBurst, nextPerMinute) }
+	})
+	return limiter
+}
+
+// LoadShedder creates the shedder letting MAX_IN_FLIGHT_REQUESTS API requests be handled at once (1000 by
+// default, 0 for no limit); the limit is re-read on reload
+func LoadShedder(reloader *config.Reloader) *Shedder {
+	shedder := NewShedder(0)
+	reloader.Register(func(cfg *config.Config) func() {
+		limit := cfg.Int("MAX_IN_FLIGHT_REQUESTS", 1000, config.NonNegative)
+		return func() { shedder.SetLimit(limit) }
+	})
+	return shedder
+}
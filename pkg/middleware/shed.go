@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"sync/atomic"
+	"ecommerce/pkg/api"
+	"ecommerce/pkg/metrics"
+)
+
+// inFlight counts the requests shedders have let in that are still being handled
+var inFlight atomic.Int64
+
+var (
+	requestsShed = metrics.NewCounter("http_requests_shed_total",
+		"Requests turned away with 503 because too many were already in flight")
+	_ = metrics.NewGaugeFunc("http_requests_in_flight",
+		"Requests being handled behind the load shedder", func() float64 { return float64(inFlight.Load()) })
+)
+
+// Shedder turns requests away with 503 and Retry-After once too many are being handled at once, so
+// a spike backs off at the client rather than piling onto the stores and the services behind
+type Shedder struct {
+	limit    atomic.Int64
+	inFlight atomic.Int64
+}
+
+// NewShedder creates a shedder letting limit requests in at once; a limit of 0 lets every request in
+func NewShedder(limit int) *Shedder {
+	s := &Shedder{}
+	s.SetLimit(limit)
+	return s
+}
+
+// SetLimit changes how many requests are let in at once. Requests already in carry on when it is lowered.
+func (s *Shedder) SetLimit(limit int) {
+	s.limit.Store(int64(limit))
+}
+
+// Middleware sheds the requests over the limit
+func (s *Shedder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count := s.inFlight.Add(1); s.limit.Load() > 0 && count > s.limit.Load() {
+			s.inFlight.Add(-1)
+			requestsShed.Inc()
+			w.Header().Set("Retry-After", "1")
+			api.WriteError(w, http.StatusServiceUnavailable, "Service is busy, try again shortly")
+			return
+		}
+		inFlight.Add(1)
+		defer func() {
+			s.inFlight.Add(-1)
+			inFlight.Add(-1)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
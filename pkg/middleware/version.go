@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"ecommerce/pkg/api"
+	"ecommerce/pkg/buildinfo"
+
+	"github.com/gorilla/mux"
+)
+
+// APIVersionHeader names the API version a response came from. On a path without a version,
+// such as /orders, a client may send it to pick the version the request is served by.
+const APIVersionHeader = "API-Version"
+
+// ServiceVersionHeader names the build of the service that answered, such as 1.4.0+3f2c1a9b7d4e
+const ServiceVersionHeader = "X-Service-Version"
+
+// CodeUnsupportedVersion is the error code for a request naming an API version the service doesn't have
+const CodeUnsupportedVersion = "UNSUPPORTED_API_VERSION"
+
+// Versioned serves an API whose routes live under a version prefix, such as /v1/orders, and keeps
+// the paths from before versioning working. A request for an unversioned path that router has no
+// route for, such as /orders, is served by the version named in its API-Version header, or by
+// fallback, as if it had asked for /v1/orders; the response is marked deprecated and links to the
+// versioned path. Unversioned routes the router does have, such as /healthz, are served as they are.
+// versions lists the versions router serves, such as v1 and v2.
+func Versioned(router *mux.Router, fallback string, versions ...string) http.Handler {
+	known := make(map[string]bool, len(versions))
+	for _, version := range versions {
+		known[version] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if known[first] {
+			w.Header().Set(APIVersionHeader, first)
+			router.ServeHTTP(w, r)
+			return
+		}
+		// A route the wrong method was used on still belongs to the unversioned path, so it gets its 405
+		var match mux.RouteMatch
+		if router.Match(r, &match) || match.MatchErr == mux.ErrMethodMismatch {
+			router.ServeHTTP(w, r)
+			return
+		}
+
+		version := r.Header.Get(APIVersionHeader)
+		if version == "" {
+			version = fallback
+		}
+		if !known[version] {
+			api.WriteErrorCode(w, http.StatusBadRequest, CodeUnsupportedVersion,
+				"Unsupported API version "+version+"; use one of "+strings.Join(versions, ", "))
+			return
+		}
+
+		versioned := r.Clone(r.Context())
+		versioned.URL.Path = "/" + version + r.URL.Path
+		versioned.URL.RawPath = ""
+		w.Header().Set(APIVersionHeader, version)
+		w.Header().Set("Deprecation", "true")
+		w.Header().Add("Link", "<"+versioned.URL.Path+`>; rel="successor-version"`)
+		router.ServeHTTP(w, versioned)
+	})
+}
+
+// ServiceVersion names the running build on every response, so operators can tell which build
+// answered a request
+func ServiceVersion(next http.Handler) http.Handler {
+	version := buildinfo.Get().String()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(ServiceVersionHeader, version)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,26 @@
+package mongodb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestIsDuplicateKey(t *testing.T) {
+	duplicate := mongo.WriteException{WriteErrors: []mongo.WriteError{{
+		Code:    11000,
+		Message: `E11000 duplicate key error collection: shop.users index: users_email_key dup key: { email: "a@example.com" }`,
+	}}}
+
+	if !IsDuplicateKey(fmt.Errorf("creating user: %w", duplicate), "users_email_key") {
+		t.Error("expected a wrapped violation of the index to match")
+	}
+	if IsDuplicateKey(duplicate, "users_email") {
+		t.Error("expected a violation of another index not to match")
+	}
+	if IsDuplicateKey(errors.New("server selection timeout"), "users_email_key") {
+		t.Error("expected other errors not to match")
+	}
+}
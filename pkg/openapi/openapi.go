@@ -0,0 +1,257 @@
+// Package openapi builds OpenAPI 3 documents for a service from its router and the models its
+// handlers take and return, and serves them along with Swagger UI.
+//
+// Every route the router has is documented, with its path parameters. Describe adds what a route
+// can't say about itself, such as its summary, request body, and response. Schemas are read from
+// the models' json tags, and their validate tags become constraints, so `validate:"required,min=2"`
+// on a string makes it a required string of at least 2 characters.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"ecommerce/pkg/api"
+
+	"github.com/gorilla/mux"
+)
+
+// Version is the OpenAPI version documents are written in
+const Version = "3.0.3"
+
+// Security schemes a Route can name in Auth
+const (
+	Session    = "session"    // a session token from the user service, as Authorization: Bearer <token>
+	ServiceKey = "serviceKey" // a service key, as X-Service-Key
+)
+
+// Route describes what a route takes and returns, beyond what the router knows about it
+type Route struct {
+	Summary  string
+	Body     interface{}       // a value of the request body's type, such as models.CreateOrderRequest{}
+	Response interface{}       // a value of the type of data in a success response, or of the whole body with Produces; nil if it has none
+	Page     interface{}       // a value of the type of pagination in a success response, for paged lists
+	Status   int               // the success status; 200 if unset
+	Query    map[string]string // query parameters, with what each does
+	Auth     []string          // the security schemes that may call the route; none means anyone may
+	Consumes string            // the body's media type when it isn't JSON, such as multipart/form-data
+	Produces string            // the response's media type when it isn't in the JSON envelope, such as application/pdf
+}
+
+// Spec collects the descriptions of a service's routes, to document them with once the router is built
+type Spec struct {
+	info   Info
+	routes map[string]Route // keyed by method and path, such as "POST /v1/orders"
+
+	once     sync.Once
+	document []byte
+	err      error
+}
+
+// New returns a Spec for the service the document is titled after
+func New(title, version, description string) *Spec {
+	return &Spec{
+		info:   Info{Title: title, Version: version, Description: description},
+		routes: make(map[string]Route),
+	}
+}
+
+// Describe adds the description of the route for method and path, a template as registered
+// with the router, such as /v1/orders/{id}
+func (s *Spec) Describe(method, path string, route Route) {
+	s.routes[method+" "+path] = route
+}
+
+// Handler serves the document for router as JSON. It's built on the first request, so the
+// handler can be registered before the rest of the routes.
+func (s *Spec) Handler(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.once.Do(func() {
+			var document *Document
+			if document, s.err = s.Document(router); s.err == nil {
+				s.document, s.err = json.Marshal(document)
+			}
+			if s.err != nil {
+				slog.ErrorContext(r.Context(), "Building the API document failed", "error", s.err)
+			}
+		})
+		if s.err != nil {
+			api.WriteError(w, http.StatusInternalServerError, "Failed to build the API document")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(s.document)
+	})
+}
+
+// Document builds the document for every route router has. Routes registered with PathPrefix
+// are left out, as their paths can't be listed. It fails if a described route isn't routed,
+// so descriptions can't drift from the routes they describe.
+func (s *Spec) Document(router *mux.Router) (*Document, error) {
+	document := &Document{
+		OpenAPI: Version,
+		Info:    s.info,
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]SecurityScheme{
+				Session:    {Type: "http", Scheme: "bearer", Description: "A session token from POST /v1/auth/login"},
+				ServiceKey: {Type: "apiKey", In: "header", Name: "X-Service-Key", Description: "A key issued by POST /v1/admin/service-keys"},
+			},
+		},
+	}
+	schemas := newSchemas(document.Components.Schemas)
+
+	routed := make(map[string]bool)
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		if pattern, err := route.GetPathRegexp(); err != nil || !strings.HasSuffix(pattern, "$") {
+			return nil
+		}
+
+		path, params := pathParams(template)
+		for _, method := range methods {
+			if method == http.MethodHead || method == http.MethodOptions {
+				continue
+			}
+			key := method + " " + template
+			routed[key] = true
+			if document.Paths[path] == nil {
+				document.Paths[path] = make(PathItem)
+			}
+			document.Paths[path][strings.ToLower(method)] = operation(schemas, path, params, s.routes[key])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var unrouted []string
+	for key := range s.routes {
+		if !routed[key] {
+			unrouted = append(unrouted, key)
+		}
+	}
+	if len(unrouted) > 0 {
+		sort.Strings(unrouted)
+		return nil, fmt.Errorf("openapi: described routes aren't routed: %s", strings.Join(unrouted, ", "))
+	}
+	return document, nil
+}
+
+// variable matches a variable in a route template, such as {id} or {id:[0-9]+}
+var variable = regexp.MustCompile(`\{([^{}:]+)(:[^{}]*)?\}`)
+
+// pathParams returns template as an OpenAPI path, without the variables' patterns, and the
+// names of its variables
+func pathParams(template string) (string, []string) {
+	var params []string
+	path := variable.ReplaceAllStringFunc(template, func(match string) string {
+		name := variable.FindStringSubmatch(match)[1]
+		params = append(params, name)
+		return "{" + name + "}"
+	})
+	return path, params
+}
+
+// operation documents one method of the route at path
+func operation(schemas *schemas, path string, params []string, route Route) *Operation {
+	op := &Operation{
+		Summary:   route.Summary,
+		Tags:      []string{tag(path)},
+		Responses: make(map[string]Response),
+	}
+
+	for _, name := range params {
+		op.Parameters = append(op.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	names := make([]string, 0, len(route.Query))
+	for name := range route.Query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		op.Parameters = append(op.Parameters, Parameter{Name: name, In: "query", Description: route.Query[name], Schema: &Schema{Type: "string"}})
+	}
+
+	if route.Body != nil || route.Consumes != "" {
+		mediaType := route.Consumes
+		if mediaType == "" {
+			mediaType = "application/json"
+		}
+		body := &Schema{Type: "string", Format: "binary"}
+		if route.Body != nil {
+			body = schemas.of(reflect.TypeOf(route.Body))
+		}
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{mediaType: {Schema: body}}}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := Response{Description: http.StatusText(status)}
+	switch {
+	case status == http.StatusNoContent || status/100 == 3:
+	case route.Produces != "":
+		body := &Schema{Type: "string", Format: "binary"}
+		if route.Response != nil {
+			body = schemas.of(reflect.TypeOf(route.Response))
+		}
+		success.Content = map[string]MediaType{route.Produces: {Schema: body}}
+	default:
+		envelope := schemas.of(reflect.TypeOf(api.Response[api.Unpaged]{}))
+		payload := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		if route.Response != nil {
+			payload.Properties["data"] = schemas.of(reflect.TypeOf(route.Response))
+		}
+		if route.Page != nil {
+			payload.Properties["pagination"] = schemas.of(reflect.TypeOf(route.Page))
+		}
+		if len(payload.Properties) > 0 {
+			envelope = &Schema{AllOf: []*Schema{envelope, payload}}
+		}
+		success.Content = map[string]MediaType{"application/json": {Schema: envelope}}
+	}
+	op.Responses[fmt.Sprint(status)] = success
+	op.Responses["default"] = Response{
+		Description: "An error, with a code such as NOT_FOUND",
+		Content: map[string]MediaType{
+			"application/json":     {Schema: schemas.of(reflect.TypeOf(api.Response[api.Unpaged]{}))},
+			api.ProblemContentType: {Schema: schemas.of(reflect.TypeOf(api.Problem{}))},
+		},
+	}
+
+	for _, scheme := range route.Auth {
+		op.Security = append(op.Security, map[string][]string{scheme: {}})
+	}
+	return op
+}
+
+// version matches a version prefix, such as v1
+var version = regexp.MustCompile(`^v[0-9]+$`)
+
+// tag groups a path with the others under its first segment after the version, such as orders
+// for /v1/orders/{id}
+func tag(path string) string {
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" && !version.MatchString(segment) {
+			return segment
+		}
+	}
+	return "root"
+}
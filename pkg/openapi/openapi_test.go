@@ -0,0 +1,191 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type widget struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name" validate:"required,min=2,max=50"`
+	Color     string            `json:"color,omitempty" validate:"omitempty,oneof=red blue"`
+	Quantity  int               `json:"quantity" validate:"gte=1"`
+	Tags      []string          `json:"tags,omitempty" validate:"max=3,dive,min=1"`
+	Parts     []widget          `json:"parts,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	secret    string
+	Ignored   string `json:"-"`
+}
+
+type createWidgetRequest struct {
+	audit
+	Widget widget `json:"widget" validate:"required"`
+}
+
+type widgetPage struct {
+	Total int `json:"total"`
+}
+
+type audit struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+func noop(w http.ResponseWriter, r *http.Request) {}
+
+// widgetRouter routes a small widget API the way the services route theirs
+func widgetRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/healthz", noop).Methods("GET")
+	router.PathPrefix("/uploads/").HandlerFunc(noop).Methods("GET")
+	v1 := router.PathPrefix("/v1").Subrouter()
+	v1.HandleFunc("/widgets", noop).Methods("POST")
+	v1.HandleFunc("/widgets/{id:[0-9]+}", noop).Methods("GET", "HEAD")
+	v1.HandleFunc("/widgets/{id}/parts/{part_id}", noop).Methods("DELETE")
+	return router
+}
+
+func TestDocument_DocumentsEveryRoute(t *testing.T) {
+	spec := New("Widget Service", "1.0.0", "")
+	spec.Describe("POST", "/v1/widgets", Route{Summary: "Create a widget", Body: createWidgetRequest{}, Response: widget{}, Status: http.StatusCreated, Auth: []string{Session}})
+	spec.Describe("GET", "/v1/widgets/{id:[0-9]+}", Route{Summary: "Get a widget", Response: &widget{}, Page: widgetPage{}, Query: map[string]string{"fields": "fields to include"}})
+
+	document, err := spec.Document(widgetRouter())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(document.Paths) != 4 || document.Paths["/uploads/"] != nil {
+		t.Fatalf("expected the four routed paths without the prefix route, got %v", document.Paths)
+	}
+	get := document.Paths["/v1/widgets/{id}"]
+	if len(get) != 1 || get["get"] == nil || get["get"].Summary != "Get a widget" || get["get"].Tags[0] != "widgets" {
+		t.Fatalf("expected GET documented without HEAD or the id's pattern, got %+v", get)
+	}
+	if params := get["get"].Parameters; len(params) != 2 || params[0].Name != "id" || params[0].In != "path" || !params[0].Required || params[1].In != "query" {
+		t.Fatalf("expected the id path parameter and the fields query parameter, got %+v", params)
+	}
+	if page := get["get"].Responses["200"].Content["application/json"].Schema.AllOf[1].Properties["pagination"]; page == nil || page.Ref != "#/components/schemas/widgetPage" {
+		t.Fatalf("expected the pagination documented, got %+v", page)
+	}
+	if params := document.Paths["/v1/widgets/{id}/parts/{part_id}"]["delete"].Parameters; len(params) != 2 || params[1].Name != "part_id" {
+		t.Fatalf("expected undescribed routes documented with their path parameters, got %+v", params)
+	}
+
+	create := document.Paths["/v1/widgets"]["post"]
+	if create.RequestBody.Content["application/json"].Schema.Ref != "#/components/schemas/createWidgetRequest" {
+		t.Fatalf("expected the body to refer to its model, got %+v", create.RequestBody)
+	}
+	created, ok := create.Responses["201"]
+	if !ok || created.Content["application/json"].Schema.AllOf[1].Properties["data"].Ref != "#/components/schemas/widget" {
+		t.Fatalf("expected a 201 envelope with the widget as data, got %+v", create.Responses)
+	}
+	if _, ok := create.Responses["default"].Content["application/problem+json"]; !ok {
+		t.Fatal("expected errors documented as problem details too")
+	}
+	if len(create.Security) != 1 || create.Security[0][Session] == nil {
+		t.Fatalf("expected the session scheme required, got %v", create.Security)
+	}
+}
+
+func TestDocument_ReadsSchemasFromTags(t *testing.T) {
+	spec := New("Widget Service", "1.0.0", "")
+	spec.Describe("POST", "/v1/widgets", Route{Body: createWidgetRequest{}})
+	document, err := spec.Document(widgetRouter())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := document.Components.Schemas["createWidgetRequest"]
+	if strings.Join(request.Required, ",") != "reason,widget" || request.Properties["reason"] == nil {
+		t.Fatalf("expected embedded fields flattened and required fields listed, got %+v", request)
+	}
+
+	w := document.Components.Schemas["widget"]
+	if strings.Join(w.Required, ",") != "name" {
+		t.Fatalf("expected only name required, got %v", w.Required)
+	}
+	if _, ok := w.Properties["secret"]; ok || w.Properties["Ignored"] != nil || w.Properties["-"] != nil {
+		t.Fatalf("expected unexported and ignored fields left out, got %v", w.Properties)
+	}
+	name := w.Properties["name"]
+	if name.Type != "string" || *name.MinLength != 2 || *name.MaxLength != 50 {
+		t.Fatalf("expected name limited to 2-50 characters, got %+v", name)
+	}
+	if color := w.Properties["color"]; len(color.Enum) != 2 || color.Enum[0] != "red" {
+		t.Fatalf("expected color limited to its choices, got %+v", color)
+	}
+	if quantity := w.Properties["quantity"]; quantity.Type != "integer" || *quantity.Minimum != 1 {
+		t.Fatalf("expected quantity of at least 1, got %+v", quantity)
+	}
+	if tags := w.Properties["tags"]; *tags.MaxItems != 3 || *tags.Items.MinLength != 1 {
+		t.Fatalf("expected rules after dive applied to each tag, got %+v", tags)
+	}
+	if parts := w.Properties["parts"]; parts.Items.Ref != "#/components/schemas/widget" {
+		t.Fatalf("expected a widget's parts to refer back to widget, got %+v", parts)
+	}
+	if labels := w.Properties["labels"]; labels.Type != "object" || labels.AdditionalProperties.Type != "string" {
+		t.Fatalf("expected labels as a map of strings, got %+v", labels)
+	}
+	if created := w.Properties["created_at"]; created.Format != "date-time" {
+		t.Fatalf("expected times as date-time strings, got %+v", created)
+	}
+}
+
+func TestDocument_FailsOnDescriptionsOfMissingRoutes(t *testing.T) {
+	spec := New("Widget Service", "1.0.0", "")
+	spec.Describe("PUT", "/v1/widgets/{id}", Route{Summary: "Replace a widget"})
+	if _, err := spec.Document(widgetRouter()); err == nil || !strings.Contains(err.Error(), "PUT /v1/widgets/{id}") {
+		t.Fatalf("expected the unrouted description named, got %v", err)
+	}
+}
+
+func TestHandler_ServesTheDocument(t *testing.T) {
+	router := widgetRouter()
+	spec := New("Widget Service", "1.0.0", "")
+	router.Handle("/openapi.json", spec.Handler(router)).Methods("GET")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var document Document
+	if err := json.NewDecoder(rec.Body).Decode(&document); err != nil || document.OpenAPI != Version || document.Info.Title != "Widget Service" {
+		t.Fatalf("expected the document, got %d %v %+v", rec.Code, err, document)
+	}
+	if document.Paths["/openapi.json"] == nil {
+		t.Fatal("expected routes registered after the handler documented too")
+	}
+}
+
+func TestSwaggerUI_ServesTheUIForTheDocument(t *testing.T) {
+	handler := SwaggerUI("/docs/", "/openapi.json")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/docs/" {
+		t.Fatalf("expected /docs redirected to /docs/, got %d %v", rec.Code, rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "swagger-ui") {
+		t.Fatalf("expected the UI page, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/swagger-initializer.js", nil))
+	if !strings.Contains(rec.Body.String(), `url: "/openapi.json"`) {
+		t.Fatalf("expected the UI pointed at the document, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/swagger-ui-bundle.js", nil))
+	if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+		t.Fatalf("expected the UI's scripts served, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,221 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	rawMessageType  = reflect.TypeOf(json.RawMessage{})
+	textMarshalType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemas turns Go types into schemas, keeping each named struct once under the document's components
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string // the component each struct was kept under
+}
+
+func newSchemas(components map[string]*Schema) *schemas {
+	return &schemas{components: components, names: make(map[reflect.Type]string)}
+}
+
+// of returns the schema for t. Named structs are referred to rather than repeated, and every
+// other schema is new, so callers may add constraints to it.
+func (s *schemas) of(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	case t.Kind() != reflect.Struct && t.Implements(textMarshalType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + s.component(t)}
+	}
+	return &Schema{}
+}
+
+// component keeps the schema for the named struct t under components, and returns its name
+func (s *schemas) component(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+
+	// Generic types are named without their type arguments, and types sharing a name with one
+	// already kept are told apart by their package
+	name, _, _ := strings.Cut(t.Name(), "[")
+	if _, taken := s.components[name]; taken {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	s.names[t] = name
+	s.components[name] = &Schema{} // claimed before it's built, for types that refer to themselves
+	*s.components[name] = *s.object(t)
+	return name
+}
+
+// object returns the schema for the fields of the struct t
+func (s *schemas) object(t reflect.Type) *Schema {
+	object := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	s.addFields(object, t)
+	return object
+}
+
+// addFields adds the fields of the struct t to object, including those of structs it embeds
+func (s *schemas) addFields(object *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			s.addFields(object, fieldType)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := s.of(field.Type)
+		if strings.Contains(","+options+",", ",string,") && schema.Type != "string" {
+			schema = &Schema{Type: "string"}
+		}
+		if constrain(schema, field.Tag.Get("validate")) {
+			object.Required = append(object.Required, name)
+		}
+		object.Properties[name] = schema
+	}
+}
+
+// constrain adds the rules in a validate tag to schema, and reports whether they make the field
+// required. Rules after dive apply to the elements of a slice or map.
+func constrain(schema *Schema, tag string) bool {
+	required := false
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		if rule == "dive" {
+			if element := schema.Items; element != nil {
+				constrain(element, strings.Join(rules[i+1:], ","))
+			} else if element := schema.AdditionalProperties; element != nil {
+				constrain(element, strings.Join(rules[i+1:], ","))
+			}
+			break
+		}
+		name, param, _ := strings.Cut(rule, "=")
+		if name == "required" {
+			required = true
+			continue
+		}
+		if schema.Ref != "" {
+			continue
+		}
+		constrainRule(schema, name, param)
+	}
+	return required
+}
+
+// constrainRule adds one validate rule, such as min with the param 2, to schema
+func constrainRule(schema *Schema, name, param string) {
+	number, err := strconv.ParseFloat(param, 64)
+	bounded := err == nil
+	count := int(number)
+
+	switch name {
+	case "min", "gte", "max", "lte", "len", "gt", "lt":
+		if !bounded {
+			return
+		}
+		low := name == "min" || name == "gte" || name == "len" || name == "gt"
+		high := name == "max" || name == "lte" || name == "len" || name == "lt"
+		switch schema.Type {
+		case "integer", "number":
+			if low {
+				schema.Minimum, schema.ExclusiveMinimum = &number, name == "gt"
+			}
+			if high {
+				schema.Maximum, schema.ExclusiveMaximum = &number, name == "lt"
+			}
+		case "string":
+			if low {
+				schema.MinLength = &count
+			}
+			if high {
+				schema.MaxLength = &count
+			}
+		case "array":
+			if low {
+				schema.MinItems = &count
+			}
+			if high {
+				schema.MaxItems = &count
+			}
+		}
+	case "oneof":
+		for _, value := range strings.Fields(param) {
+			if n, err := strconv.ParseFloat(value, 64); err == nil && schema.Type != "string" {
+				schema.Enum = append(schema.Enum, n)
+			} else {
+				schema.Enum = append(schema.Enum, value)
+			}
+		}
+	case "email":
+		schema.Format = "email"
+	case "url", "uri", "http_url":
+		schema.Format = "uri"
+	case "uuid", "uuid4":
+		schema.Format = "uuid"
+	case "e164", "phone":
+		schema.Format = "phone"
+	case "datetime":
+		schema.Format = "date-time"
+	}
+}
@@ -0,0 +1,46 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// initializer starts Swagger UI on the document at the URL it's formatted with, in place of
+// the one bundled with the UI, which shows an example API
+const initializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: %s,
+    dom_id: '#swagger-ui',
+    deepLinking: true,
+    presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+    plugins: [SwaggerUIBundle.plugins.DownloadUrl],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+// SwaggerUI serves Swagger UI, from files built into the binary, under prefix, such as /docs/,
+// showing the document at url. The prefix without its trailing slash redirects to it.
+func SwaggerUI(prefix, url string) http.Handler {
+	quoted, _ := json.Marshal(url)
+	script := []byte(fmt.Sprintf(initializer, quoted))
+	files := http.StripPrefix(prefix, http.FileServer(http.FS(swaggerFiles.FS)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == strings.TrimSuffix(prefix, "/"):
+			http.Redirect(w, r, prefix, http.StatusMovedPermanently)
+		case !strings.HasPrefix(r.URL.Path, prefix):
+			http.NotFound(w, r)
+		case r.URL.Path == prefix+"swagger-initializer.js":
+			w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+			w.Write(script)
+		default:
+			files.ServeHTTP(w, r)
+		}
+	})
+}
@@ -0,0 +1,26 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestIsUniqueViolation(t *testing.T) {
+	violation := &pq.Error{Code: "23505", Constraint: "users_email_key"}
+
+	if !IsUniqueViolation(fmt.Errorf("creating user: %w", violation), "users_email_key") {
+		t.Error("expected a wrapped violation of the constraint to match")
+	}
+	if IsUniqueViolation(violation, "users_phone_key") {
+		t.Error("expected a violation of another constraint not to match")
+	}
+	if IsUniqueViolation(&pq.Error{Code: "23503", Constraint: "users_email_key"}, "users_email_key") {
+		t.Error("expected a foreign key violation not to match")
+	}
+	if IsUniqueViolation(errors.New("connection refused"), "users_email_key") {
+		t.Error("expected other errors not to match")
+	}
+}
@@ -0,0 +1,5 @@
+// Package proto holds the protobuf contracts of the services' gRPC APIs, in one package per service
+// and version, with the Go code generated from them. Regenerate it after changing a .proto file.
+package proto
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative user/v1/user.proto product/v1/product.proto order/v1/order.proto
@@ -0,0 +1,125 @@
+// Package ratelimit limits how often an action may be taken per key, such as a client IP or API key
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Decision is a limiter's answer for one attempt, with what the caller needs to tell the client
+type Decision struct {
+	Allowed    bool
+	Limit      int           // attempts a key may make at once
+	Remaining  int           // attempts left right now, after this one
+	RetryAfter time.Duration // until the next attempt would be allowed, when this one wasn't
+	Reset      time.Duration // until the key is back to its full limit
+}
+
+// Limiter decides whether an action identified by key may proceed.
+// Implementations must be safe for concurrent use; a Redis-backed limiter can satisfy
+// this interface to share limits across replicas.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Decision, error)
+}
+
+// bucket holds the state of a single token bucket
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// TokenBucket is an in-memory Limiter. Each key gets a bucket holding up to burst tokens
+// that refills at rate tokens per second; every allowed call consumes one token.
+type TokenBucket struct {
+	burst     float64
+	rate      float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+	mutex     sync.Mutex
+}
+
+// NewTokenBucket creates a limiter allowing burst requests at once and perMinute sustained requests per key
+func NewTokenBucket(burst, perMinute int) *TokenBucket {
+	l := &TokenBucket{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+	l.SetLimits(burst, perMinute)
+	return l
+}
+
+// SetLimits changes the burst and sustained rate for every key. Buckets keep their tokens,
+// capped at the new burst when they next refill.
+func (l *TokenBucket) SetLimits(burst, perMinute int) {
+	if burst < 1 {
+		burst = 1
+	}
+	if perMinute < 1 {
+		perMinute = 1
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.burst = float64(burst)
+	l.rate = float64(perMinute) / 60
+}
+
+// Allow consumes a token for key if one is available
+func (l *TokenBucket) Allow(ctx context.Context, key string) (Decision, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[key] = b
+	} else {
+		b.tokens = l.refill(b, now)
+		b.updated = now
+	}
+
+	decision := Decision{Limit: int(l.burst)}
+	if b.tokens < 1 {
+		decision.RetryAfter = l.wait(1 - b.tokens)
+	} else {
+		b.tokens--
+		decision.Allowed = true
+	}
+	decision.Remaining = int(math.Floor(b.tokens))
+	decision.Reset = l.wait(l.burst - b.tokens)
+	return decision, nil
+}
+
+// wait returns how long the bucket takes to refill the given number of tokens
+func (l *TokenBucket) wait(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// refill returns the bucket's token count at the given time
+func (l *TokenBucket) refill(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + now.Sub(b.updated).Seconds()*l.rate
+	if tokens > l.burst {
+		tokens = l.burst
+	}
+	return tokens
+}
+
+// sweep drops buckets that have refilled completely so idle keys don't accumulate.
+// It runs at most once a minute.
+func (l *TokenBucket) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
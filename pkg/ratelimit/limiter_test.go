@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket_AllowsBurstThenLimits(t *testing.T) {
+	l := NewTokenBucket(2, 60)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if decision, _ := l.Allow(ctx, "k"); !decision.Allowed {
+			t.Fatalf("expected attempt %d to be allowed", i+1)
+		}
+	}
+
+	decision, _ := l.Allow(ctx, "k")
+	if decision.Allowed {
+		t.Fatal("expected attempt beyond burst to be limited")
+	}
+	if decision.RetryAfter <= 0 || decision.RetryAfter > time.Second {
+		t.Errorf("expected retry after within a second, got %v", decision.RetryAfter)
+	}
+	if decision.Limit != 2 || decision.Remaining != 0 || decision.Reset <= time.Second || decision.Reset > 2*time.Second {
+		t.Errorf("expected no attempts left and the bucket full within two seconds, got %+v", decision)
+	}
+
+	if decision, _ := l.Allow(ctx, "other"); !decision.Allowed {
+		t.Error("expected keys to be limited independently")
+	}
+
+	now = now.Add(time.Second)
+	if decision, _ := l.Allow(ctx, "k"); !decision.Allowed {
+		t.Error("expected a token to refill after one second")
+	}
+}
+
+func TestTokenBucket_SweepsIdleBuckets(t *testing.T) {
+	l := NewTokenBucket(1, 60)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	_, _ = l.Allow(context.Background(), "k")
+	now = now.Add(2 * time.Minute)
+	_, _ = l.Allow(context.Background(), "other")
+
+	if _, exists := l.buckets["k"]; exists {
+		t.Error("expected refilled bucket to be swept")
+	}
+}
+
+func TestTokenBucket_SetLimitsAppliesToExistingKeys(t *testing.T) {
+	l := NewTokenBucket(1, 60)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if decision, _ := l.Allow(ctx, "k"); !decision.Allowed {
+		t.Fatal("expected the first attempt to be allowed")
+	}
+	l.SetLimits(1, 6)
+
+	now = now.Add(time.Second)
+	decision, _ := l.Allow(ctx, "k")
+	if decision.Allowed {
+		t.Fatal("expected the slower rate to leave no token after one second")
+	}
+	if decision.RetryAfter <= time.Second {
+		t.Errorf("expected the wait to reflect the slower rate, got %v", decision.RetryAfter)
+	}
+}
@@ -0,0 +1,53 @@
+// Package requestid identifies the request a piece of work is done for, so it can be followed through
+// every service's logs
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// Header carries the request ID on incoming requests, responses, and calls to other services
+const Header = "X-Request-ID"
+
+// maxLength bounds the IDs accepted from callers, so a client can't bloat every log line
+const maxLength = 128
+
+type contextKey struct{}
+
+// New generates a random request ID
+func New() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// NewContext returns a copy of ctx carrying the request ID
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID ctx carries, or "" if it has none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// FromRequest returns the request ID the caller sent, or a new one if it sent none or one that's too long
+func FromRequest(r *http.Request) string {
+	id := r.Header.Get(Header)
+	if id == "" || len(id) > maxLength {
+		return New()
+	}
+	return id
+}
+
+// Propagate sets the request ID carried by the outgoing request's context on its headers, so the
+// service it goes to logs under the same ID
+func Propagate(req *http.Request) {
+	if id := FromContext(req.Context()); id != "" {
+		req.Header.Set(Header, id)
+	}
+}
@@ -0,0 +1,41 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFromRequest_KeepsTheCallersIDOrMakesOne(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(Header, "abc")
+	if id := FromRequest(r); id != "abc" {
+		t.Fatalf("expected the caller's ID, got %q", id)
+	}
+
+	r.Header.Set(Header, strings.Repeat("x", maxLength+1))
+	if id := FromRequest(r); id == "" || len(id) > maxLength {
+		t.Fatalf("expected an overlong ID replaced, got %q", id)
+	}
+	r.Header.Del(Header)
+	if a, b := FromRequest(r), FromRequest(r); a == "" || a == b {
+		t.Fatalf("expected a fresh ID each time, got %q and %q", a, b)
+	}
+}
+
+func TestPropagate_CopiesTheIDOntoOutgoingRequests(t *testing.T) {
+	ctx := NewContext(context.Background(), "abc")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://product-service/products/p1", nil)
+	Propagate(req)
+	if got := req.Header.Get(Header); got != "abc" {
+		t.Fatalf("expected X-Request-ID abc, got %q", got)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "http://product-service/products/p1", nil)
+	Propagate(req)
+	if _, ok := req.Header[Header]; ok {
+		t.Fatal("expected no header without a request ID")
+	}
+}
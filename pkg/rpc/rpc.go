@@ -0,0 +1,206 @@
+// Package rpc holds the plumbing every service's gRPC server and clients share: request IDs, service
+// keys, logging, and metrics, the way the HTTP middleware provides them for the REST APIs
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"runtime/debug"
+	"strconv"
+	"time"
+	"ecommerce/pkg/metrics"
+	"ecommerce/pkg/requestid"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceKeyMetadata carries the calling service's API key, as X-Service-Key does over HTTP
+const ServiceKeyMetadata = "x-service-key"
+
+// requestIDMetadata carries the request ID, as X-Request-ID does over HTTP
+const requestIDMetadata = "x-request-id"
+
+// maxRequestIDLength bounds the IDs accepted from callers, as requestid.FromRequest does
+const maxRequestIDLength = 128
+
+// Authenticator checks the service key a call came with and returns ctx carrying the calling service.
+// It is only called for calls that came with a key, and should return a status error, such as
+// Unauthenticated for a key that isn't valid.
+type Authenticator func(ctx context.Context, key string) (context.Context, error)
+
+var (
+	rpcRequests = metrics.NewCounterVec("grpc_server_requests_total",
+		"gRPC calls handled, by method and status code", "method", "code")
+	rpcRequestDuration = metrics.NewHistogramVec("grpc_server_request_duration_seconds",
+		"How long gRPC calls took to handle, by method", metrics.DefBuckets, "method")
+)
+
+// NewServer creates a gRPC server whose calls are tagged with a request ID, authenticated with
+// authenticate when they come with a service key, logged, and counted for /metrics. A panicking call
+// fails with Internal rather than taking the service down. The standard health service is registered,
+// so grpc_health_probe and load balancers can check it. With tlsConfig, calls are served over TLS;
+// a nil tlsConfig serves them in plaintext.
+func NewServer(authenticate Authenticator, tlsConfig *tls.Config) *grpc.Server {
+	options := []grpc.ServerOption{grpc.ChainUnaryInterceptor(
+		tagRequestID,
+		logCall,
+		countCall,
+		recoverCall,
+		authenticateCall(authenticate),
+	)}
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	server := grpc.NewServer(options...)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	return server
+}
+
+// Serve serves server on port until it is stopped, returning the error that stopped it, if any
+func Serve(server *grpc.Server, port int) error {
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return err
+	}
+	return server.Serve(listener)
+}
+
+// Shutdown stops server from taking new calls and waits for the ones in flight to finish, cutting them
+// off once ctx is done
+func Shutdown(ctx context.Context, server *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		server.Stop()
+	}
+}
+
+// Dial creates a client connection to the gRPC server at target, such as localhost:9081. It connects
+// lazily, on the first call, and passes the request ID carried by each call's context on to the server.
+// When target's name resolves to several instances, calls are spread round-robin across those that are
+// connected, so an instance that goes away is left out until it can be reached again. With tlsConfig,
+// calls go over TLS; a nil tlsConfig sends them in plaintext.
+func Dial(target string, tlsConfig *tls.Config) (*grpc.ClientConn, error) {
+	transport := insecure.NewCredentials()
+	if tlsConfig != nil {
+		transport = credentials.NewTLS(tlsConfig)
+	}
+	return grpc.NewClient(target,
+		grpc.WithTransportCredentials(transport),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin": {}}]}`),
+		grpc.WithUnaryInterceptor(propagateRequestID),
+	)
+}
+
+// HealthCheck returns a readiness check that asks the server conn connects to whether it is serving,
+// through the standard health service NewServer registers
+func HealthCheck(conn grpc.ClientConnInterface) func(ctx context.Context) error {
+	client := healthpb.NewHealthClient(conn)
+	return func(ctx context.Context) error {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			return err
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("server is %s", resp.Status)
+		}
+		return nil
+	}
+}
+
+// WithServiceKey returns a copy of ctx whose outgoing calls present key as the calling service's key
+func WithServiceKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, ServiceKeyMetadata, key)
+}
+
+// propagateRequestID sends the request ID carried by ctx with the call, so the server logs under the same ID
+func propagateRequestID(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if id := requestid.FromContext(ctx); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, requestIDMetadata, id)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// tagRequestID gives every call an ID, taken from x-request-id when the caller sent one, and puts it in
+// the call's context for logging and for calls to other services
+func tagRequestID(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	id := firstMetadata(ctx, requestIDMetadata)
+	if id == "" || len(id) > maxRequestIDLength {
+		id = requestid.New()
+	}
+	return handler(requestid.NewContext(ctx, id), req)
+}
+
+// logCall logs calls with their status code and how long they took
+func logCall(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	slog.InfoContext(ctx, "rpc",
+		"method", info.FullMethod,
+		"code", status.Code(err).String(),
+		"duration", time.Since(start),
+	)
+	return resp, err
+}
+
+// countCall counts calls and times them for /metrics
+func countCall(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	rpcRequests.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+	rpcRequestDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// recoverCall turns a panic in a handler into an Internal error
+func recoverCall(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			slog.ErrorContext(ctx, "Panic handling call", "method", info.FullMethod, "panic", recovered, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "Internal error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// authenticateCall checks the service key of calls that came with one; calls without one go through
+// as end-user calls, as they do over HTTP
+func authenticateCall(authenticate Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		key := firstMetadata(ctx, ServiceKeyMetadata)
+		if key == "" {
+			return handler(ctx, req)
+		}
+		ctx, err := authenticate(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// firstMetadata returns the first value the caller sent for key, or ""
+func firstMetadata(ctx context.Context, key string) string {
+	if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"ecommerce/pkg/requestid"
+	userv1 "ecommerce/pkg/proto/user/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type serviceKey struct{}
+
+// echoUsers answers GetUser with the request ID and calling service it saw in the user's name and email
+type echoUsers struct {
+	userv1.UnimplementedUserServiceServer
+}
+
+func (echoUsers) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.User, error) {
+	if req.Id == "panic" {
+		panic("boom")
+	}
+	service, _ := ctx.Value(serviceKey{}).(string)
+	return &userv1.User{Id: req.Id, Name: requestid.FromContext(ctx), Email: service}, nil
+}
+
+// dialTestServer starts a server for echoUsers in memory and returns a client for it
+func dialTestServer(t *testing.T) userv1.UserServiceClient {
+	return userv1.NewUserServiceClient(dialTestConn(t))
+}
+
+// dialTestConn starts a server for echoUsers in memory and returns a connection to it
+func dialTestConn(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	server := NewServer(func(ctx context.Context, key string) (context.Context, error) {
+		if key != "good-key" {
+			return nil, status.Error(codes.Unauthenticated, "Invalid service key")
+		}
+		return context.WithValue(ctx, serviceKey{}, "order-service"), nil
+	}, nil)
+	userv1.RegisterUserServiceServer(server, echoUsers{})
+
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(propagateRequestID),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServer_PassesRequestIDsAndServicesToHandlers(t *testing.T) {
+	users := dialTestServer(t)
+
+	ctx := WithServiceKey(requestid.NewContext(context.Background(), "req-123"), "good-key")
+	user, err := users.GetUser(ctx, &userv1.GetUserRequest{Id: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "req-123" || user.Email != "order-service" {
+		t.Fatalf("expected the caller's request ID and service, got %q and %q", user.Name, user.Email)
+	}
+
+	user, err = users.GetUser(context.Background(), &userv1.GetUserRequest{Id: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name == "" || user.Email != "" {
+		t.Fatalf("expected a new request ID and no service for an end-user call, got %q and %q", user.Name, user.Email)
+	}
+}
+
+func TestServer_RejectsInvalidServiceKeys(t *testing.T) {
+	users := dialTestServer(t)
+
+	_, err := users.GetUser(WithServiceKey(context.Background(), "bad-key"), &userv1.GetUserRequest{Id: "u1"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestServer_RecoversFromPanics(t *testing.T) {
+	users := dialTestServer(t)
+
+	_, err := users.GetUser(context.Background(), &userv1.GetUserRequest{Id: "panic"})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("expected Internal, got %v", err)
+	}
+	if _, err := users.GetUser(context.Background(), &userv1.GetUserRequest{Id: "u1"}); err != nil {
+		t.Fatalf("expected the server to keep serving, got %v", err)
+	}
+	if count := rpcRequests.WithLabelValues(userv1.UserService_GetUser_FullMethodName, "Internal").Value(); count < 1 {
+		t.Fatalf("expected the failed call counted, got %v", count)
+	}
+}
+
+func TestHealthCheck_ReportsServingServers(t *testing.T) {
+	check := HealthCheck(dialTestConn(t))
+	if err := check(context.Background()); err != nil {
+		t.Fatalf("expected the server ready, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+// Package seed reads fixture files: sample users, products, and orders that services load at
+// startup for demos and integration tests. One file can hold fixtures for every service, each in
+// its own top-level section, such as:
+//
+//	products:
+//	  - id: prod-macbook-pro
+//	    name: MacBook Pro 16"
+//	    price: 2499.99
+//
+// Fields are named as in the services' JSON, so a fixture reads like the API's responses.
+package seed
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load decodes the section of the fixture file at path named section into out, a pointer to a
+// slice of the service's fixture type. JSON files are told apart by a .json extension; anything
+// else is read as YAML. A file without the section leaves out as it is, and a field out doesn't
+// know is an error, so a misspelt field doesn't silently drop data.
+func Load(path, section string, out interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	raw, err := sectionJSON(data, strings.EqualFold(filepath.Ext(path), ".json"), section)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if raw == nil {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(out); err != nil {
+		return fmt.Errorf("%s: %s: %w", path, section, err)
+	}
+	return nil
+}
+
+// sectionJSON returns a section of a fixture file as JSON, or nil if the file doesn't have it.
+// YAML is turned into JSON so both formats decode through the models' JSON field names.
+func sectionJSON(data []byte, isJSON bool, section string) (json.RawMessage, error) {
+	if isJSON {
+		var sections map[string]json.RawMessage
+		if err := json.Unmarshal(data, &sections); err != nil {
+			return nil, err
+		}
+		return sections[section], nil
+	}
+
+	var sections map[string]interface{}
+	if err := yaml.Unmarshal(data, &sections); err != nil {
+		return nil, err
+	}
+	value, ok := sections[section]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	return json.Marshal(value)
+}
@@ -0,0 +1,92 @@
+package seed
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testProduct struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Price     float64   `json:"price"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func writeFixture(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	return path
+}
+
+func TestLoad_YAMLAndJSONDecodeAlike(t *testing.T) {
+	yamlPath := writeFixture(t, "fixtures.yaml", `
+users:
+  - id: user-1
+products:
+  - id: prod-1
+    name: Coffee Maker
+    price: 89.99
+    tags: [kitchen, coffee]
+    created_at: 2024-03-01T09:00:00Z
+`)
+	jsonPath := writeFixture(t, "fixtures.json", `{
+	"users": [{"id": "user-1"}],
+	"products": [{"id": "prod-1", "name": "Coffee Maker", "price": 89.99, "tags": ["kitchen", "coffee"], "created_at": "2024-03-01T09:00:00Z"}]
+}`)
+
+	for _, path := range []string{yamlPath, jsonPath} {
+		var products []testProduct
+		if err := Load(path, "products", &products); err != nil {
+			t.Fatalf("%s: %v", filepath.Base(path), err)
+		}
+		if len(products) != 1 {
+			t.Fatalf("%s: expected one product, got %v", filepath.Base(path), products)
+		}
+		product := products[0]
+		if product.ID != "prod-1" || product.Name != "Coffee Maker" || product.Price != 89.99 || len(product.Tags) != 2 {
+			t.Errorf("%s: unexpected product %+v", filepath.Base(path), product)
+		}
+		if !product.CreatedAt.Equal(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)) {
+			t.Errorf("%s: expected the creation time kept, got %s", filepath.Base(path), product.CreatedAt)
+		}
+	}
+}
+
+func TestLoad_MissingSectionLeavesOutAlone(t *testing.T) {
+	path := writeFixture(t, "fixtures.yml", "users:\n  - id: user-1\n")
+
+	var products []testProduct
+	if err := Load(path, "orders", &products); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if products != nil {
+		t.Errorf("expected no products, got %v", products)
+	}
+}
+
+func TestLoad_RejectsUnknownFields(t *testing.T) {
+	path := writeFixture(t, "fixtures.yaml", "products:\n  - id: prod-1\n    prise: 10\n")
+
+	var products []testProduct
+	err := Load(path, "products", &products)
+	if err == nil || !strings.Contains(err.Error(), "prise") {
+		t.Errorf("expected the misspelt field reported, got %v", err)
+	}
+}
+
+func TestLoad_InvalidFile(t *testing.T) {
+	var products []testProduct
+	if err := Load(writeFixture(t, "fixtures.json", "{not json"), "products", &products); err == nil {
+		t.Error("expected invalid JSON to fail")
+	}
+	if err := Load(filepath.Join(t.TempDir(), "missing.yaml"), "products", &products); err == nil {
+		t.Error("expected a missing file to fail")
+	}
+}
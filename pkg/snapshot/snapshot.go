@@ -0,0 +1,213 @@
+// Package snapshot keeps the state of in-memory repositories on disk, so restarting a service in a
+// dev or demo environment doesn't wipe its data. A repository's state is saved whole now and then,
+// and every change made since is appended to a log, so a crash between snapshots loses nothing.
+//
+// Both are written with encoding/gob rather than JSON: gob keeps every exported field, including
+// the ones models leave out of API responses, such as password hashes and claim tokens.
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is how often repositories are snapshotted unless configured otherwise
+const DefaultInterval = 5 * time.Minute
+
+// maxEntrySize bounds one log entry, so a corrupt length can't make Load allocate without limit
+const maxEntrySize = 64 << 20
+
+// Store keeps a state S in dir as name.snapshot, and the entries E changing it since as name.log
+type Store[S any, E any] struct {
+	snapshotPath string
+	logPath      string
+	log          *os.File
+	mutex        sync.Mutex
+}
+
+// Open prepares a store in dir, creating the directory if needed
+func Open[S any, E any](dir, name string) (*Store[S, E], error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	store := &Store[S, E]{
+		snapshotPath: filepath.Join(dir, name+".snapshot"),
+		logPath:      filepath.Join(dir, name+".log"),
+	}
+	log, err := os.OpenFile(store.logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	store.log = log
+	return store, nil
+}
+
+// Load reads the last snapshot and the entries logged since, in order. The state is nil when no
+// snapshot was saved yet. An entry cut short by a crash while it was written is dropped.
+func (s *Store[S, E]) Load() (*S, []E, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var state *S
+	file, err := os.Open(s.snapshotPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, nil, err
+	default:
+		defer file.Close()
+		state = new(S)
+		if err := gob.NewDecoder(bufio.NewReader(file)).Decode(state); err != nil {
+			return nil, nil, fmt.Errorf("reading %s: %w", s.snapshotPath, err)
+		}
+	}
+
+	entries, valid, err := s.readLog()
+	if err != nil {
+		return nil, nil, err
+	}
+	// Drop a torn entry so new ones aren't appended after it
+	if err := s.log.Truncate(valid); err != nil {
+		return nil, nil, err
+	}
+	return state, entries, nil
+}
+
+// readLog decodes the log's entries, returning them with the length of the log they take up
+func (s *Store[S, E]) readLog() ([]E, int64, error) {
+	file, err := os.Open(s.logPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var entries []E
+	var valid int64
+	for {
+		var size uint32
+		if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+			return entries, valid, nil
+		}
+		if size > maxEntrySize {
+			return entries, valid, nil
+		}
+		record := make([]byte, size)
+		if _, err := io.ReadFull(reader, record); err != nil {
+			return entries, valid, nil
+		}
+		var entry E
+		if err := gob.NewDecoder(bytes.NewReader(record)).Decode(&entry); err != nil {
+			return nil, 0, fmt.Errorf("reading %s: %w", s.logPath, err)
+		}
+		entries = append(entries, entry)
+		valid += 4 + int64(size)
+	}
+}
+
+// Append logs an entry. Each entry is written in one call, prefixed with its length.
+func (s *Store[S, E]) Append(entry E) error {
+	var record bytes.Buffer
+	record.Write(make([]byte, 4))
+	if err := gob.NewEncoder(&record).Encode(entry); err != nil {
+		return err
+	}
+	data := record.Bytes()
+	binary.BigEndian.PutUint32(data, uint32(len(data)-4))
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, err := s.log.Write(data)
+	return err
+}
+
+// Save replaces the snapshot with state and empties the log. The caller must make sure no entry is
+// appended while the state is saved, or it would be lost. The new snapshot is written beside the old
+// one and renamed over it, so a crash mid-save leaves the old snapshot and the log intact.
+func (s *Store[S, E]) Save(state *S) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	temp, err := os.CreateTemp(filepath.Dir(s.snapshotPath), filepath.Base(s.snapshotPath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	writer := bufio.NewWriter(temp)
+	err = gob.NewEncoder(writer).Encode(state)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", s.snapshotPath, err)
+	}
+	if err := os.Rename(temp.Name(), s.snapshotPath); err != nil {
+		return err
+	}
+	return s.log.Truncate(0)
+}
+
+// Close closes the log
+func (s *Store[S, E]) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.log.Close()
+}
+
+// Snapshotter is a repository that keeps its state in a Store
+type Snapshotter interface {
+	// Snapshot saves the whole state, emptying the log
+	Snapshot() error
+	// Close takes a last snapshot and closes the store
+	Close() error
+}
+
+// Schedule snapshots s every interval, so its log doesn't grow without bound, until the returned
+// stop is called. Stop closes s, taking a last snapshot.
+func Schedule(name string, s Snapshotter, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Snapshot(); err != nil {
+					slog.Error("Snapshot failed; changes are still logged", "repository", name, "error", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+		if err := s.Close(); err != nil {
+			slog.Error("Final snapshot failed", "repository", name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testState struct {
+	Values map[string]int
+	Secret string `json:"-"`
+}
+
+type testEntry struct {
+	Key   string
+	Value int
+}
+
+func TestStore_SaveAppendAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open[testState, testEntry](dir, "things")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	if state, entries, err := store.Load(); err != nil || state != nil || len(entries) != 0 {
+		t.Fatalf("expected nothing saved yet, got %v, %v, %v", state, entries, err)
+	}
+
+	if err := store.Save(&testState{Values: map[string]int{"a": 1}, Secret: "kept"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	store.Append(testEntry{Key: "b", Value: 2})
+	store.Append(testEntry{Key: "c", Value: 3})
+	store.Close()
+
+	reopened, err := Open[testState, testEntry](dir, "things")
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	state, entries, err := reopened.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if state == nil || state.Values["a"] != 1 || state.Secret != "kept" {
+		t.Errorf("expected the snapshot with fields JSON would drop, got %+v", state)
+	}
+	if len(entries) != 2 || entries[1] != (testEntry{Key: "c", Value: 3}) {
+		t.Errorf("expected both entries in order, got %v", entries)
+	}
+
+	// Saving again folds the entries into the snapshot
+	if err := reopened.Save(&testState{Values: map[string]int{"a": 1, "b": 2, "c": 3}}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, entries, _ := reopened.Load(); len(entries) != 0 {
+		t.Errorf("expected the log emptied by a snapshot, got %v", entries)
+	}
+}
+
+func TestStore_DropsTornEntry(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := Open[testState, testEntry](dir, "things")
+	store.Append(testEntry{Key: "a", Value: 1})
+	store.Append(testEntry{Key: "b", Value: 2})
+	store.Close()
+
+	// Cut the last entry short, as a crash while writing it would
+	logPath := filepath.Join(dir, "things.log")
+	info, _ := os.Stat(logPath)
+	os.Truncate(logPath, info.Size()-3)
+
+	reopened, _ := Open[testState, testEntry](dir, "things")
+	defer reopened.Close()
+	_, entries, err := reopened.Load()
+	if err != nil || len(entries) != 1 || entries[0].Key != "a" {
+		t.Fatalf("expected only the whole entry, got %v, %v", entries, err)
+	}
+
+	reopened.Append(testEntry{Key: "c", Value: 3})
+	if _, entries, _ := reopened.Load(); len(entries) != 2 || entries[1].Key != "c" {
+		t.Errorf("expected new entries after the dropped one, got %v", entries)
+	}
+}
+
+type countingSnapshotter struct {
+	snapshots atomic.Int32
+	closed    atomic.Bool
+}
+
+func (c *countingSnapshotter) Snapshot() error { c.snapshots.Add(1); return nil }
+func (c *countingSnapshotter) Close() error    { c.closed.Store(true); return nil }
+
+func TestSchedule_SnapshotsUntilStopped(t *testing.T) {
+	repo := &countingSnapshotter{}
+	stop := Schedule("things", repo, 5*time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for repo.snapshots.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+
+	if repo.snapshots.Load() < 2 {
+		t.Errorf("expected periodic snapshots, got %d", repo.snapshots.Load())
+	}
+	if !repo.closed.Load() {
+		t.Error("expected stop to close the repository")
+	}
+}
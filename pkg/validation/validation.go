@@ -0,0 +1,150 @@
+// Package validation checks request structs against their validate struct tags, such as
+// `validate:"required,min=2"`, and describes each failing field in terms a client can act on.
+//
+// Fields are named as they appear in JSON, so a bad quantity in the second order item is
+// items[1].quantity. Besides the validator's built-in rules there is phone, an international
+// number that may contain spaces, dashes, dots, and brackets.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes one field that broke one of its rules
+type FieldError struct {
+	Field   string `json:"field"`           // the field's JSON path, such as items[0].quantity
+	Rule    string `json:"rule"`            // the rule it broke, such as required or min
+	Param   string `json:"param,omitempty"` // the rule's parameter, such as the 2 in min=2
+	Message string `json:"message"`
+}
+
+var (
+	validate *validator.Validate
+	once     sync.Once
+)
+
+// instance returns the shared validator, setting it up on first use
+func instance() *validator.Validate {
+	once.Do(func() {
+		validate = validator.New(validator.WithRequiredStructEnabled())
+		validate.RegisterTagNameFunc(jsonName)
+		validate.RegisterValidation("phone", phone)
+	})
+	return validate
+}
+
+// Struct checks v, a struct or a pointer to one, against its validate tags and returns every
+// field that broke a rule, or nil if none did. Anything that isn't a struct passes.
+func Struct(v interface{}) []FieldError {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var failures validator.ValidationErrors
+	if err := instance().Struct(v); !errors.As(err, &failures) {
+		return nil
+	}
+	fields := make([]FieldError, 0, len(failures))
+	for _, failure := range failures {
+		field := fieldPath(failure.Namespace())
+		fields = append(fields, FieldError{
+			Field:   field,
+			Rule:    failure.Tag(),
+			Param:   failure.Param(),
+			Message: field + " " + describe(failure),
+		})
+	}
+	return fields
+}
+
+// jsonName names a field by its JSON key, leaving fields JSON skips unnamed
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+// fieldPath drops the struct's own name from the front of a namespace such as CreateOrderRequest.items[0].quantity
+func fieldPath(namespace string) string {
+	if _, path, ok := strings.Cut(namespace, "."); ok {
+		return path
+	}
+	return namespace
+}
+
+// describe says what a field has to be to pass the rule it broke
+func describe(failure validator.FieldError) string {
+	param := failure.Param()
+	switch failure.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	case "phone", "e164":
+		return "must be a phone number in international format, e.g. +254712345678"
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(param, " ", ", ")
+	case "min":
+		return bound("at least", param, failure.Kind())
+	case "max":
+		return bound("at most", param, failure.Kind())
+	case "len":
+		return bound("exactly", param, failure.Kind())
+	case "gt":
+		return "must be greater than " + param
+	case "gte":
+		return "must be at least " + param
+	case "lt":
+		return "must be less than " + param
+	case "lte":
+		return "must be at most " + param
+	}
+	return fmt.Sprintf("failed the %s rule", failure.Tag())
+}
+
+// bound describes a min, max, or len rule, which limits the length of strings and lists and the value of numbers
+func bound(limit, param string, kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return fmt.Sprintf("must be %s %s characters long", limit, param)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return fmt.Sprintf("must have %s %s items", limit, param)
+	}
+	return fmt.Sprintf("must be %s %s", limit, param)
+}
+
+// phone accepts an international number: a +, then 8 to 15 digits, which may be separated by
+// spaces, dashes, dots, and brackets
+func phone(field validator.FieldLevel) bool {
+	value := strings.TrimSpace(field.Field().String())
+	if !strings.HasPrefix(value, "+") {
+		return false
+	}
+	digits := 0
+	for _, c := range value[1:] {
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case !strings.ContainsRune(" -.()", c):
+			return false
+		}
+	}
+	return digits >= 8 && digits <= 15
+}
@@ -0,0 +1,60 @@
+package validation
+
+import "testing"
+
+type item struct {
+	ProductID string `json:"product_id" validate:"required"`
+	Quantity  int    `json:"quantity" validate:"required,min=1"`
+}
+
+type orderRequest struct {
+	Email string `json:"email,omitempty" validate:"omitempty,email"`
+	Phone string `json:"phone" validate:"required,phone"`
+	Items []item `json:"items" validate:"required,min=1,dive"`
+	Note  string `json:"note" validate:"max=5"`
+}
+
+func TestStruct_ReportsEachFieldByItsJSONPath(t *testing.T) {
+	req := orderRequest{
+		Email: "not-an-email",
+		Phone: "0712345678",
+		Items: []item{{ProductID: "p1", Quantity: 1}, {Quantity: 0}},
+		Note:  "far too long",
+	}
+
+	got := map[string]FieldError{}
+	for _, field := range Struct(&req) {
+		got[field.Field] = field
+	}
+	want := map[string]string{
+		"email":               "email must be a valid email address",
+		"phone":               "phone must be a phone number in international format, e.g. +254712345678",
+		"items[1].product_id": "items[1].product_id is required",
+		"items[1].quantity":   "items[1].quantity is required",
+		"note":                "note must be at most 5 characters long",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d field errors, got %+v", len(want), got)
+	}
+	for field, message := range want {
+		if got[field].Message != message {
+			t.Errorf("%s: expected %q, got %+v", field, message, got[field])
+		}
+	}
+	if got["note"].Rule != "max" || got["note"].Param != "5" {
+		t.Errorf("expected the rule and its parameter, got %+v", got["note"])
+	}
+}
+
+func TestStruct_PassesValidValuesAndNonStructs(t *testing.T) {
+	req := orderRequest{Phone: "+254 712-345-678", Items: []item{{ProductID: "p1", Quantity: 2}}}
+	if fields := Struct(req); fields != nil {
+		t.Errorf("expected a valid request to pass, got %+v", fields)
+	}
+	if fields := Struct(map[string]string{"a": "b"}); fields != nil {
+		t.Errorf("expected a map to pass, got %+v", fields)
+	}
+	if fields := Struct(orderRequest{Phone: "+254712345678"}); len(fields) != 1 || fields[0].Message != "items is required" {
+		t.Errorf("expected the missing items to be reported, got %+v", fields)
+	}
+}
@@ -9,6 +9,12 @@ GREEN='\033[0;32m'
 YELLOW='\033[1;33m'
 NC='\033[0m' # No Color
 
+# Stamp every binary with the build it is, as its health probes and X-Service-Version report
+VERSION=${VERSION:-$(git describe --tags --always 2>/dev/null || echo dev)}
+COMMIT=$(git rev-parse HEAD 2>/dev/null)
+BUILD_TIME=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+LDFLAGS="-X ecommerce/pkg/buildinfo.version=${VERSION} -X ecommerce/pkg/buildinfo.commit=${COMMIT} -X ecommerce/pkg/buildinfo.buildTime=${BUILD_TIME}"
+
 # Function to build a service
 build_service() {
     local service_name=$1
@@ -28,7 +34,7 @@ build_service() {
     
     # Build the service
     echo "🔧 Compiling ${service_name}..."
-    go build -o bin/main ./cmd/
+    go build -ldflags "$LDFLAGS" -o bin/main ./cmd/
     
     if [ $? -eq 0 ]; then
         echo -e "${GREEN}✅ ${service_name} built successfully${NC}"
@@ -48,6 +54,10 @@ echo "🚀 Starting build process..."
 mkdir -p services/user-service/bin
 mkdir -p services/product-service/bin
 mkdir -p services/order-service/bin
+mkdir -p services/cart-service/bin
+mkdir -p services/shipping-service/bin
+mkdir -p services/payment-service/bin
+mkdir -p services/gateway-service/bin
 
 # Build User Service
 build_service "User Service" "services/user-service"
@@ -70,11 +80,43 @@ if [ $? -ne 0 ]; then
     exit 1
 fi
 
+# Build Payment Service
+build_service "Payment Service" "services/payment-service"
+if [ $? -ne 0 ]; then
+    echo -e "${RED}❌ Build failed for Payment Service${NC}"
+    exit 1
+fi
+
+# Build Cart Service
+build_service "Cart Service" "services/cart-service"
+if [ $? -ne 0 ]; then
+    echo -e "${RED}❌ Build failed for Cart Service${NC}"
+    exit 1
+fi
+
+# Build Shipping Service
+build_service "Shipping Service" "services/shipping-service"
+if [ $? -ne 0 ]; then
+    echo -e "${RED}❌ Build failed for Shipping Service${NC}"
+    exit 1
+fi
+
+# Build Gateway Service
+build_service "Gateway Service" "services/gateway-service"
+if [ $? -ne 0 ]; then
+    echo -e "${RED}❌ Build failed for Gateway Service${NC}"
+    exit 1
+fi
+
 echo -e "${GREEN}🎉 All services built successfully!${NC}"
 echo ""
 echo "📁 Binaries are located in:"
 echo "  • services/user-service/bin/main"
 echo "  • services/product-service/bin/main"
 echo "  • services/order-service/bin/main"
+echo "  • services/payment-service/bin/main"
+echo "  • services/cart-service/bin/main"
+echo "  • services/shipping-service/bin/main"
+echo "  • services/gateway-service/bin/main"
 echo ""
 echo "🚀 Run './scripts/run.sh' to start all services"
@@ -57,6 +57,10 @@ echo "🔍 Checking if services are built..."
 check_binary "User Service" "services/user-service/bin/main" || exit 1
 check_binary "Product Service" "services/product-service/bin/main" || exit 1
 check_binary "Order Service" "services/order-service/bin/main" || exit 1
+check_binary "Payment Service" "services/payment-service/bin/main" || exit 1
+check_binary "Cart Service" "services/cart-service/bin/main" || exit 1
+check_binary "Shipping Service" "services/shipping-service/bin/main" || exit 1
+check_binary "Gateway Service" "services/gateway-service/bin/main" || exit 1
 
 echo -e "${GREEN}✅ All binaries found${NC}"
 echo ""
@@ -66,6 +70,10 @@ echo "🧹 Cleaning up any existing processes..."
 pkill -f "user-service/bin/main" 2>/dev/null || true
 pkill -f "product-service/bin/main" 2>/dev/null || true
 pkill -f "order-service/bin/main" 2>/dev/null || true
+pkill -f "payment-service/bin/main" 2>/dev/null || true
+pkill -f "cart-service/bin/main" 2>/dev/null || true
+pkill -f "shipping-service/bin/main" 2>/dev/null || true
+pkill -f "gateway-service/bin/main" 2>/dev/null || true
 
 # Wait a moment for processes to terminate
 sleep 2
@@ -80,7 +88,27 @@ if [ "$1" = "--no-monitor" ]; then
   NOMONITOR=1
 fi
 
+# Development service-to-service API keys (override by exporting them before running)
+ORDER_SERVICE_KEY=${ORDER_SERVICE_KEY:-dev-order-service-key}
+USER_SERVICE_KEY=${USER_SERVICE_KEY:-dev-user-service-key}
+PRODUCT_SERVICE_KEY=${PRODUCT_SERVICE_KEY:-dev-product-service-key}
+GATEWAY_SERVICE_KEY=${GATEWAY_SERVICE_KEY:-dev-gateway-service-key}
+CART_SERVICE_KEY=${CART_SERVICE_KEY:-dev-cart-service-key}
+PAYMENT_SERVICE_KEY=${PAYMENT_SERVICE_KEY:-dev-payment-service-key}
+SHIPPING_SERVICE_KEY=${SHIPPING_SERVICE_KEY:-dev-shipping-service-key}
+
+# The gateway runs on this machine, so the services take the client address it forwards from here
+TRUSTED_PROXIES=${TRUSTED_PROXIES:-127.0.0.1}
+
+# Sample users, products, and orders the services start with (export SEED_FILE= to start empty)
+SEED_FILE=${SEED_FILE-fixtures/demo.yaml}
+
 # Start User Service (port 8081)
+SERVICE_KEYS="order-service:${ORDER_SERVICE_KEY},user-service:${USER_SERVICE_KEY},product-service:${PRODUCT_SERVICE_KEY},gateway-service:${GATEWAY_SERVICE_KEY},cart-service:${CART_SERVICE_KEY},payment-service:${PAYMENT_SERVICE_KEY},shipping-service:${SHIPPING_SERVICE_KEY}" \
+SERVICE_KEY="${USER_SERVICE_KEY}" \
+TRUSTED_PROXIES="${TRUSTED_PROXIES}" \
+SEED_FILE="${SEED_FILE}" \
+PASSWORD_BANNED_FILE="${PASSWORD_BANNED_FILE:-services/user-service/config/banned_passwords.txt}" \
 start_service "User Service" "./services/user-service/bin/main" "8081"
 if [ $? -ne 0 ]; then
     echo -e "${RED}❌ Failed to start User Service${NC}"
@@ -88,22 +116,65 @@ if [ $? -ne 0 ]; then
 fi
 
 # Start Product Service (port 8082)
+SERVICE_KEY="${PRODUCT_SERVICE_KEY}" \
+TRUSTED_PROXIES="${TRUSTED_PROXIES}" \
+SEED_FILE="${SEED_FILE}" \
 start_service "Product Service" "./services/product-service/bin/main" "8082"
 if [ $? -ne 0 ]; then
     echo -e "${RED}❌ Failed to start Product Service${NC}"
     exit 1
 fi
 
+# Start Payment Service (port 8085)
+SERVICE_KEY="${PAYMENT_SERVICE_KEY}" \
+TRUSTED_PROXIES="${TRUSTED_PROXIES}" \
+start_service "Payment Service" "./services/payment-service/bin/main" "8085"
+if [ $? -ne 0 ]; then
+    echo -e "${RED}❌ Failed to start Payment Service${NC}"
+    exit 1
+fi
+
 # Shorter wait before starting Order Service to reduce race window
 sleep 1
 
-# Start Order Service (port 8083)
+# Start Order Service (port 8083), taking payments through the payment service
+SERVICE_KEY="${ORDER_SERVICE_KEY}" \
+PAYMENT_PROVIDER="${PAYMENT_PROVIDER:-service}" \
+TRUSTED_PROXIES="${TRUSTED_PROXIES}" \
+SEED_FILE="${SEED_FILE}" \
 start_service "Order Service" "./services/order-service/bin/main" "8083"
 if [ $? -ne 0 ]; then
     echo -e "${RED}❌ Failed to start Order Service${NC}"
     exit 1
 fi
 
+# Start Cart Service (port 8084)
+SERVICE_KEY="${CART_SERVICE_KEY}" \
+TRUSTED_PROXIES="${TRUSTED_PROXIES}" \
+start_service "Cart Service" "./services/cart-service/bin/main" "8084"
+if [ $? -ne 0 ]; then
+    echo -e "${RED}❌ Failed to start Cart Service${NC}"
+    exit 1
+fi
+
+# Start Shipping Service (port 8086)
+SERVICE_KEY="${SHIPPING_SERVICE_KEY}" \
+TRUSTED_PROXIES="${TRUSTED_PROXIES}" \
+start_service "Shipping Service" "./services/shipping-service/bin/main" "8086"
+if [ $? -ne 0 ]; then
+    echo -e "${RED}❌ Failed to start Shipping Service${NC}"
+    exit 1
+fi
+
+# Start Gateway Service (port 8080)
+SERVICE_KEY="${GATEWAY_SERVICE_KEY}" \
+PAYMENT_SERVICE_URL="http://localhost:8085" \
+start_service "Gateway Service" "./services/gateway-service/bin/main" "8080"
+if [ $? -ne 0 ]; then
+    echo -e "${RED}❌ Failed to start Gateway Service${NC}"
+    exit 1
+fi
+
 echo ""
 echo -e "${GREEN}🎉 All services are running!${NC}"
 echo ""
@@ -111,11 +182,19 @@ echo "📊 Service Status:"
 echo -e "${BLUE}  • User Service:    http://localhost:8081${NC}"
 echo -e "${BLUE}  • Product Service: http://localhost:8082${NC}"
 echo -e "${BLUE}  • Order Service:   http://localhost:8083${NC}"
+echo -e "${BLUE}  • Cart Service:    http://localhost:8084${NC}"
+echo -e "${BLUE}  • Payment Service: http://localhost:8085${NC}"
+echo -e "${BLUE}  • Shipping Service: http://localhost:8086${NC}"
+echo -e "${BLUE}  • Gateway Service: http://localhost:8080 (REST and /v1/graphql)${NC}"
 echo ""
 echo "📋 Quick Health Checks:"
-echo "  curl http://localhost:8081/health"
-echo "  curl http://localhost:8082/health"
-echo "  curl http://localhost:8083/health"
+echo "  curl http://localhost:8081/healthz"
+echo "  curl http://localhost:8082/healthz"
+echo "  curl http://localhost:8083/healthz"
+echo "  curl http://localhost:8084/healthz"
+echo "  curl http://localhost:8085/healthz"
+echo "  curl http://localhost:8086/healthz"
+echo "  curl http://localhost:8080/healthz"
 echo ""
 echo "📄 Logs are available in the 'logs/' directory"
 echo "🛑 Run './scripts/stop.sh' to stop all services"
@@ -130,7 +209,7 @@ fi
 while true; do
     sleep 10
     # derive filenames
-    for name in "User Service" "Product Service" "Order Service"; do
+    for name in "User Service" "Product Service" "Order Service" "Payment Service" "Cart Service" "Shipping Service"; do
         log_base=$(echo "$name" | tr 'A-Z' 'a-z' | tr ' ' '-')
         pid_file="logs/${log_base}.pid"
         if [ ! -f "$pid_file" ] || ! kill -0 $(cat "$pid_file" 2>/dev/null) 2>/dev/null; then
@@ -47,12 +47,20 @@ stop_service() {
 stop_service "User Service" "logs/user-service.pid"
 stop_service "Product Service" "logs/product-service.pid"
 stop_service "Order Service" "logs/order-service.pid"
+stop_service "Payment Service" "logs/payment-service.pid"
+stop_service "Cart Service" "logs/cart-service.pid"
+stop_service "Shipping Service" "logs/shipping-service.pid"
+stop_service "Gateway Service" "logs/gateway-service.pid"
 
 # Also kill any processes that might be running without PID files
 echo "🧹 Cleaning up any remaining processes..."
 pkill -f "user-service/bin/main" 2>/dev/null || true
 pkill -f "product-service/bin/main" 2>/dev/null || true
 pkill -f "order-service/bin/main" 2>/dev/null || true
+pkill -f "payment-service/bin/main" 2>/dev/null || true
+pkill -f "cart-service/bin/main" 2>/dev/null || true
+pkill -f "shipping-service/bin/main" 2>/dev/null || true
+pkill -f "gateway-service/bin/main" 2>/dev/null || true
 
 echo ""
 echo -e "${GREEN}🎉 All services stopped successfully!${NC}"
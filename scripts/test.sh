@@ -77,6 +77,9 @@ wait_for() {
 # Run unit tests for all services
 echo "🔬 Running unit tests..."
 
+echo "  Shared pkg tests"
+( cd pkg && go test ./... -count=1 ) || unit_failed=true
+
 echo "  User Service repository tests"
 ( cd services/user-service && go test ./internal/repository -count=1 ) || unit_failed=true
 
@@ -86,6 +89,15 @@ echo "  Product Service repository tests"
 echo "  Order Service repository tests"
 ( cd services/order-service && go test ./internal/repository -count=1 ) || unit_failed=true
 
+echo "  Payment Service repository tests"
+( cd services/payment-service && go test ./internal/repository -count=1 ) || unit_failed=true
+
+echo "  Cart Service repository tests"
+( cd services/cart-service && go test ./internal/repository -count=1 ) || unit_failed=true
+
+echo "  Shipping Service repository tests"
+( cd services/shipping-service && go test ./internal/repository -count=1 ) || unit_failed=true
+
 if [ "$unit_failed" = true ]; then
   echo -e "${RED}❌ Some unit tests failed${NC}"
 else
@@ -99,26 +111,26 @@ echo "🌐 Testing API endpoints..."
 echo ""
 
 # Wait for health before tests
-wait_for "User Service" "http://localhost:8081/health" || api_test_passed=false
-wait_for "Product Service" "http://localhost:8082/health" || api_test_passed=false
-wait_for "Order Service" "http://localhost:8083/health" || api_test_passed=false
+wait_for "User Service" "http://localhost:8081/readyz" || api_test_passed=false
+wait_for "Product Service" "http://localhost:8082/readyz" || api_test_passed=false
+wait_for "Order Service" "http://localhost:8083/readyz" || api_test_passed=false
 
 # Check if services are running by testing health endpoints
 api_test_passed=true
 
 # Test User Service
-if test_api "User Service" "http://localhost:8081/health" 200; then
+if test_api "User Service" "http://localhost:8081/healthz" 200; then
     # Test additional User Service endpoints
     echo "  Testing User Service endpoints..."
     
     # Test creating a user
     echo "  Creating test user..."
-    curl -s -X POST http://localhost:8081/users \
+    curl -s -X POST http://localhost:8081/v1/users \
          -H "Content-Type: application/json" \
          -d '{"name":"Test User","email":"test@example.com","password":"password123"}' > /dev/null
     
     # Test getting users
-    test_api "User Service" "http://localhost:8081/users" 200 || api_test_passed=false
+    test_api "User Service" "http://localhost:8081/v1/users" 200 || api_test_passed=false
 else
     echo -e "${RED}❌ User Service is not running${NC}"
     api_test_passed=false
@@ -127,10 +139,10 @@ fi
 echo ""
 
 # Test Product Service
-if test_api "Product Service" "http://localhost:8082/health" 200; then
+if test_api "Product Service" "http://localhost:8082/healthz" 200; then
     # Test additional Product Service endpoints
     echo "  Testing Product Service endpoints..."
-    test_api "Product Service" "http://localhost:8082/products" 200 || api_test_passed=false
+    test_api "Product Service" "http://localhost:8082/v1/products" 200 || api_test_passed=false
 else
     echo -e "${RED}❌ Product Service is not running${NC}"
     api_test_passed=false
@@ -139,10 +151,10 @@ fi
 echo ""
 
 # Test Order Service
-if test_api "Order Service" "http://localhost:8083/health" 200; then
+if test_api "Order Service" "http://localhost:8083/healthz" 200; then
     # Test additional Order Service endpoints
     echo "  Testing Order Service endpoints..."
-    test_api "Order Service" "http://localhost:8083/orders" 200 || api_test_passed=false
+    test_api "Order Service" "http://localhost:8083/v1/orders" 200 || api_test_passed=false
 else
     echo -e "${RED}❌ Order Service is not running${NC}"
     api_test_passed=false
@@ -157,7 +169,7 @@ echo "  Creating an order that requires all services..."
 # First, create a user and get their ID
 echo "  1. Creating user..."
 unique_email="integration+$(date +%s%N)@example.com"
-user_response=$(curl -s -X POST http://localhost:8081/users \
+user_response=$(curl -s -X POST http://localhost:8081/v1/users \
                      -H "Content-Type: application/json" \
                      -d '{"name":"Integration Test User","email":"'"${unique_email}"'","password":"password123"}')
 
@@ -173,7 +185,7 @@ if [ -n "$user_id" ]; then
     
     # Get a product ID
     echo "  2. Getting product..."
-    products_response=$(curl -s http://localhost:8082/products)
+    products_response=$(curl -s http://localhost:8082/v1/products)
     product_id=$(echo "$products_response" | grep -o '"id":"[^"]*"' | head -1 | cut -d'"' -f4)
     
     if [ -n "$product_id" ]; then
@@ -181,7 +193,7 @@ if [ -n "$user_id" ]; then
         
         # Create an order
         echo "  3. Creating order..."
-        order_response=$(curl -s -X POST http://localhost:8083/orders \
+        order_response=$(curl -s -X POST http://localhost:8083/v1/orders \
                              -H "Content-Type: application/json" \
                              -d "{\"user_id\":\"${user_id}\",\"items\":[{\"product_id\":\"${product_id}\",\"quantity\":1}]}")
         
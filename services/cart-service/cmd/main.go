@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"ecommerce/pkg/config"
+	"ecommerce/pkg/diagnostics"
+	"ecommerce/pkg/health"
+	"ecommerce/pkg/jobs"
+	"ecommerce/pkg/logging"
+	"ecommerce/pkg/metrics"
+	"ecommerce/pkg/middleware"
+	"ecommerce/pkg/rpc"
+	productv1 "ecommerce/pkg/proto/product/v1"
+	"cart-service/internal/client"
+	"cart-service/internal/handlers"
+	"cart-service/internal/repository"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	// Settings come from flags, the environment, and the YAML file named by -config or CONFIG_FILE
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		logging.Fatal("Failed to load configuration", "error", err)
+	}
+	logging.Setup(cfg.String("SERVICE_NAME", "cart-service"))
+	serverConfig := cfg.Server(8084)
+
+	// The log level, body size limit, and rate limits are read again on SIGHUP
+	reloader := config.NewReloader(cfg)
+	reloader.Register(config.TuneLogLevel)
+	reloader.Register(config.TuneMaxBodyBytes)
+
+	// With TLS_CERT_FILE, the API is served over mutual TLS, and calls to other services present the
+	// same certificate; the files are read again on SIGHUP, so rotated ones take effect
+	certs := reloader.TLS()
+
+	// Carts are kept in memory: a cart is a shopper's scratch list, lost with the instance
+	cartRepo := repository.NewInMemoryCartRepository()
+	metrics.NewGaugeFunc("carts_stored", "Carts in the repository, checked out ones among them", func() float64 {
+		return float64(cartRepo.Count())
+	})
+
+	// Carts are priced over the product service's gRPC API, and become orders through the order
+	// service's REST API; both are called with SERVICE_KEY, which user service must list in SERVICE_KEYS
+	// In production, these addresses would come from service discovery
+	serviceKey := cfg.String("SERVICE_KEY", "")
+	products := dialService(cfg, "PRODUCT_SERVICE_GRPC_ADDR", "localhost:9082", certs)
+	catalog := client.NewProductCatalog(productv1.NewProductServiceClient(products), serviceKey)
+	orders := client.NewOrderServiceClient(serviceURL(cfg, "ORDER_SERVICE_URL", "http://localhost:8083"), serviceKey)
+	orders.UseTLS(certs.ClientConfig())
+
+	// Carts left alone for CART_TTL are thrown away, as are checked out ones once it has passed
+	cartTTL := cfg.Duration("CART_TTL", 7*24*time.Hour, config.Positive)
+	scheduler := jobs.NewScheduler(jobs.Solo{})
+	scheduler.Add(jobs.Job{Name: "expire-carts", Schedule: jobs.Every(time.Hour), Run: expireCarts(cartRepo, cartTTL), EveryInstance: true})
+	scheduler.Start()
+
+	cartHandler := handlers.NewCartHandler(cartRepo, catalog, orders)
+
+	// Carts can't be priced or checked out without product and order service, so readiness checks both
+	probes := health.NewChecker("cart-service", cfg.Duration("READINESS_TIMEOUT", health.DefaultTimeout, config.NonNegative))
+	probes.Register("product_service", rpc.HealthCheck(products))
+	probes.Register("order_service", orders.Ping)
+
+	// Setup routes
+	router := setupRoutes(serverConfig.CORSOrigins, reloader, probes, cartHandler)
+
+	// Stop before serving if any setting was invalid, listing every problem at once
+	if err := cfg.Err(); err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+	for _, key := range cfg.Unused() {
+		slog.Warn("Setting is not used", "key", key)
+	}
+	go reloader.Watch(context.Background())
+
+	// CPU and heap profiles and runtime variables are served on DEBUG_ADDR, an internal port apart
+	// from the API, when it is set
+	debugServer, err := diagnostics.Serve(serverConfig.DebugAddr)
+	if err != nil {
+		logging.Fatal("Diagnostics port failed to start", "error", err)
+	}
+
+	// Configure server; requests through the proxies in TRUSTED_PROXIES, such as the gateway, are
+	// attributed to the client they came from
+	server := &http.Server{
+		Addr:         serverConfig.Addr(),
+		Handler:      middleware.TrustProxies(serverConfig.TrustedProxies)(middleware.Versioned(router, "v1", "v1")),
+		ReadTimeout:  serverConfig.ReadTimeout,
+		WriteTimeout: serverConfig.WriteTimeout,
+		IdleTimeout:  serverConfig.IdleTimeout,
+	}
+
+	// Start server in a goroutine
+	go func() {
+		slog.Info("🚀 Cart Service starting", "port", serverConfig.Port)
+		slog.Info("📚 API Documentation:")
+		slog.Info("  POST /carts                 - Create a cart for a user or guest")
+		slog.Info("  GET  /carts/{id}            - Get a cart, priced by product service")
+		slog.Info("  DELETE /carts/{id}          - Delete a cart")
+		slog.Info("  POST /carts/{id}/items      - Add a product to a cart")
+		slog.Info("  DELETE /carts/{id}/items/{product_id} - Remove a product from a cart")
+		slog.Info("  POST /carts/merge           - Merge a guest's cart into their own at login")
+		slog.Info("  POST /carts/{id}/checkout   - Place an order for a cart (rate limited per IP)")
+		slog.Info("  GET  /healthz               - Liveness probe")
+		slog.Info("  GET  /readyz                - Readiness probe, checking product and order service")
+		slog.Info("  GET  /metrics               - Prometheus metrics")
+		slog.Info("---")
+
+		if err := certs.ListenAndServe(server); err != nil && err != http.ErrServerClosed {
+			logging.Fatal("Server failed to start", "error", err)
+		}
+	}()
+
+	// Wait for interrupt signal to gracefully shutdown the server
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	slog.Info("🛑 Shutting down Cart Service...")
+
+	// Graceful shutdown with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), serverConfig.ShutdownTimeout)
+	defer cancel()
+
+	debugServer.Close()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Server forced to shutdown", "error", err)
+	} else {
+		slog.Info("✅ Cart Service shutdown complete")
+	}
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), serverConfig.DrainTimeout)
+	defer cancelDrain()
+	if err := scheduler.Shutdown(drainCtx); err != nil {
+		slog.Error("Job runs cut short at shutdown", "error", err)
+	}
+}
+
+// setupRoutes configures all the HTTP routes
+func setupRoutes(corsOrigins []string, reloader *config.Reloader, probes *health.Checker, cartHandler *handlers.CartHandler) *mux.Router {
+	router := mux.NewRouter()
+
+	// Add CORS middleware
+	router.Use(middleware.CORS(corsOrigins))
+
+	// Tag each request with an ID for the logs and calls to other services
+	router.Use(middleware.RequestID)
+
+	// Name the running build on every response
+	router.Use(middleware.ServiceVersion)
+
+	// Send errors as problem details to clients that ask for application/problem+json
+	router.Use(middleware.ProblemDetails)
+
+	// Add logging middleware
+	router.Use(middleware.Logging)
+
+	// Count and time requests by route for /metrics
+	router.Use(middleware.Metrics)
+
+	// Limit how fast each client may call, per IP
+	router.Use(middleware.RateLimit("global", middleware.RateLimiter(reloader, "RATE_LIMIT", 100, 600), nil, noService))
+
+	// API routes live under a version prefix; operational endpoints stay at the root
+	v1 := router.PathPrefix("/v1").Subrouter()
+
+	// Turn API requests away with 503 once too many are in flight, rather than letting a spike pile up
+	v1.Use(middleware.LoadShedder(reloader).Middleware)
+
+	// Cart routes; the order service doesn't limit checkouts it gets from this service, so they are
+	// limited here per shopper IP
+	checkoutLimit := middleware.RateLimit("checkout", middleware.RateLimiter(reloader, "RATE_LIMIT_CHECKOUT", 10, 30), nil, noService)
+	v1.HandleFunc("/carts", cartHandler.CreateCart).Methods("POST")
+	v1.HandleFunc("/carts/merge", cartHandler.MergeCart).Methods("POST")
+	v1.HandleFunc("/carts/{id}", cartHandler.GetCart).Methods("GET")
+	v1.HandleFunc("/carts/{id}", cartHandler.DeleteCart).Methods("DELETE")
+	v1.HandleFunc("/carts/{id}/items", cartHandler.AddItem).Methods("POST")
+	v1.HandleFunc("/carts/{id}/items/{product_id}", cartHandler.RemoveItem).Methods("DELETE")
+	v1.Handle("/carts/{id}/checkout", checkoutLimit(http.HandlerFunc(cartHandler.Checkout))).Methods("POST")
+
+	// Service metrics
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// Liveness and readiness probes
+	router.HandleFunc("/healthz", probes.Liveness).Methods("GET")
+	router.HandleFunc("/readyz", probes.Readiness).Methods("GET")
+
+	return router
+}
+
+// noService is the caller name for rate limits: every caller of the cart service is a shopper
+func noService(ctx context.Context) string {
+	return ""
+}
+
+// dialService connects to the gRPC API at the address setting key names, over TLS when certs is set
+func dialService(cfg *config.Config, key, defaultAddr string, certs *config.TLS) *grpc.ClientConn {
+	conn, err := rpc.Dial(cfg.String(key, defaultAddr), certs.ClientConfig())
+	if err != nil {
+		logging.Fatal("Invalid gRPC address", "key", key, "error", err)
+	}
+	return conn
+}
+
+// serviceURL reads the base URL of a service's REST API from the setting key names
+func serviceURL(cfg *config.Config, key, defaultURL string) string {
+	raw := cfg.String(key, defaultURL)
+	upstream, err := url.Parse(raw)
+	if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+		logging.Fatal("Invalid service URL", "key", key, "url", raw)
+	}
+	return raw
+}
+
+var cartsExpired = metrics.NewCounter("carts_expired_total",
+	"Carts thrown away after being left alone for CART_TTL")
+
+// expireCarts throws away the carts not changed within ttl
+func expireCarts(repo repository.CartRepository, ttl time.Duration) func(ctx context.Context, now time.Time) error {
+	return func(ctx context.Context, now time.Time) error {
+		expired, err := repo.DeleteIdleSince(now.Add(-ttl))
+		if err != nil {
+			return err
+		}
+		cartsExpired.Add(float64(expired))
+		if expired > 0 {
+			slog.Info("Threw away idle carts", "count", expired)
+		}
+		return nil
+	}
+}
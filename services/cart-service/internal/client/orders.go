@@ -0,0 +1,114 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"ecommerce/pkg/requestid"
+)
+
+// Orders places the orders carts turn into. Implemented by OrderServiceClient; enables mocking in tests.
+type Orders interface {
+	// PlaceOrder sends an order request to the order service and returns what it answered, whether
+	// or not the order was placed. It fails only when the order service can't be asked.
+	PlaceOrder(ctx context.Context, body []byte) (*PlacedOrder, error)
+	Ping(ctx context.Context) error
+}
+
+// PlacedOrder is the order service's answer to an order request
+type PlacedOrder struct {
+	StatusCode int
+	Body       []byte // the response as the order service sent it
+	OrderID    string // the placed order's ID, when the order service answered 201 with one
+}
+
+// serviceKeyHeader carries this service's API key on internal calls
+const serviceKeyHeader = "X-Service-Key"
+
+// maxResponseBytes bounds how much of an order service response is read
+const maxResponseBytes = 1 << 20
+
+// OrderServiceClient calls the order service's REST API
+type OrderServiceClient struct {
+	httpClient *http.Client
+	baseURL    string
+	serviceKey string
+}
+
+// NewOrderServiceClient creates a client for the order service at baseURL. serviceKey is sent as
+// X-Service-Key to authenticate this service, which also exempts its checkouts from the order
+// service's per-IP checkout limit.
+func NewOrderServiceClient(baseURL, serviceKey string) *OrderServiceClient {
+	return &OrderServiceClient{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		baseURL:    baseURL,
+		serviceKey: serviceKey,
+	}
+}
+
+// UseTLS makes the client call order service over TLS with config, presenting this service's
+// certificate; order service's URL must then be https. Call it before the client is used.
+func (c *OrderServiceClient) UseTLS(config *tls.Config) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	c.httpClient.Transport = transport
+}
+
+// PlaceOrder posts the order request to POST /v1/orders
+func (c *OrderServiceClient) PlaceOrder(ctx context.Context, body []byte) (*PlacedOrder, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/v1/orders", body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call order service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	answer, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read order service response: %w", err)
+	}
+	placed := &PlacedOrder{StatusCode: resp.StatusCode, Body: answer}
+	if resp.StatusCode == http.StatusCreated {
+		var envelope struct {
+			Data struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		}
+		json.Unmarshal(answer, &envelope)
+		placed.OrderID = envelope.Data.ID
+	}
+	return placed, nil
+}
+
+// Ping checks the order service is serving, for the readiness probe
+func (c *OrderServiceClient) Ping(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodGet, "/healthz", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("order service returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// do sends a request authenticated with the service key, tagged with ctx's request ID
+func (c *OrderServiceClient) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	requestid.Propagate(req)
+	if c.serviceKey != "" {
+		req.Header.Set(serviceKeyHeader, c.serviceKey)
+	}
+	return c.httpClient.Do(req)
+}
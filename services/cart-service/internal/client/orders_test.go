@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOrderServiceClient_PlaceOrder(t *testing.T) {
+	var gotKey, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/orders" {
+			http.NotFound(w, r)
+			return
+		}
+		gotKey = r.Header.Get(serviceKeyHeader)
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		if gotBody == `{"bad":true}` {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"success":false,"error":"Invalid"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"success":true,"data":{"id":"o1","claim_token":"t"}}`))
+	}))
+	defer server.Close()
+	orders := NewOrderServiceClient(server.URL, "cart-key")
+
+	placed, err := orders.PlaceOrder(context.Background(), []byte(`{"user_id":"u1"}`))
+	if err != nil || placed.StatusCode != http.StatusCreated || placed.OrderID != "o1" {
+		t.Fatalf("expected order o1 placed, got %v %+v", err, placed)
+	}
+	if gotKey != "cart-key" || gotBody != `{"user_id":"u1"}` {
+		t.Errorf("expected the request sent as given with the service key, got %q %q", gotKey, gotBody)
+	}
+
+	placed, err = orders.PlaceOrder(context.Background(), []byte(`{"bad":true}`))
+	if err != nil || placed.StatusCode != http.StatusBadRequest || placed.OrderID != "" || string(placed.Body) != `{"success":false,"error":"Invalid"}` {
+		t.Fatalf("expected the rejection passed back as it was, got %v %+v", err, placed)
+	}
+
+	server.Close()
+	if _, err := orders.PlaceOrder(context.Background(), []byte(`{}`)); err == nil {
+		t.Error("expected an error when the order service can't be reached")
+	}
+}
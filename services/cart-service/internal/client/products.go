@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"ecommerce/pkg/rpc"
+	productv1 "ecommerce/pkg/proto/product/v1"
+	"cart-service/internal/models"
+)
+
+// Catalog looks up the products carts are priced from. Implemented by ProductCatalog; enables
+// mocking in tests.
+type Catalog interface {
+	// GetProducts returns the published products among ids, keyed by ID, priced in currency
+	GetProducts(ctx context.Context, ids []string, currency string) (map[string]*models.Product, error)
+}
+
+// ProductCatalog looks products up over the product service's gRPC API
+type ProductCatalog struct {
+	products   productv1.ProductServiceClient
+	serviceKey string
+}
+
+// NewProductCatalog creates a catalog calling products, presenting serviceKey
+func NewProductCatalog(products productv1.ProductServiceClient, serviceKey string) *ProductCatalog {
+	return &ProductCatalog{products: products, serviceKey: serviceKey}
+}
+
+// GetProducts looks every product up in one BatchGetProducts call
+func (c *ProductCatalog) GetProducts(ctx context.Context, ids []string, currency string) (map[string]*models.Product, error) {
+	products := make(map[string]*models.Product, len(ids))
+	if len(ids) == 0 {
+		return products, nil
+	}
+
+	resp, err := c.products.BatchGetProducts(rpc.WithServiceKey(ctx, c.serviceKey), &productv1.BatchGetProductsRequest{Ids: ids, Currency: currency})
+	if err != nil {
+		return nil, err
+	}
+	for _, product := range resp.GetProducts() {
+		unitPrice := product.GetEffectivePrice()
+		if unitPrice <= 0 {
+			unitPrice = product.GetPrice()
+		}
+		products[product.GetId()] = &models.Product{
+			ID:             product.GetId(),
+			Name:           product.GetName(),
+			UnitPrice:      unitPrice,
+			Currency:       product.GetCurrency(),
+			Stock:          int(product.GetStock()),
+			MinOrderQty:    int(product.GetMinOrderQty()),
+			MaxOrderQty:    int(product.GetMaxOrderQty()),
+			AllowBackorder: product.GetAllowBackorder(),
+		}
+	}
+	return products, nil
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"ecommerce/pkg/api"
+	"cart-service/internal/client"
+	"cart-service/internal/models"
+	"cart-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// CartHandler serves carts: filling them, pricing them with the product service, and turning them
+// into orders with the order service
+type CartHandler struct {
+	repo    repository.CartRepository
+	catalog client.Catalog
+	orders  client.Orders
+}
+
+// NewCartHandler creates a new cart handler
+func NewCartHandler(repo repository.CartRepository, catalog client.Catalog, orders client.Orders) *CartHandler {
+	return &CartHandler{repo: repo, catalog: catalog, orders: orders}
+}
+
+// CreateCart handles POST /carts - creates a cart for a user or a guest. A user has one open cart at
+// a time, so when they already have one it is returned instead, with 200.
+func (h *CartHandler) CreateCart(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateCartRequest
+	if !api.DecodeOptionalJSON(w, r, &req) {
+		return
+	}
+
+	req.UserID = strings.TrimSpace(req.UserID)
+	if req.UserID != "" {
+		if cart, err := h.repo.OpenCartOf(req.UserID); err == nil {
+			h.writeCart(w, r, http.StatusOK, cart, "")
+			return
+		}
+	}
+
+	cart := models.NewCart(req.UserID)
+	if err := h.repo.Create(cart); err != nil {
+		slog.ErrorContext(r.Context(), "Error creating cart", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to create cart")
+		return
+	}
+	h.writeCart(w, r, http.StatusCreated, cart, "Cart created")
+}
+
+// GetCart handles GET /carts/{id} - the cart priced at its products' current prices, in the
+// currency named by ?currency (USD by default)
+func (h *CartHandler) GetCart(w http.ResponseWriter, r *http.Request) {
+	cart, err := h.repo.Get(mux.Vars(r)["id"])
+	if err != nil {
+		h.writeRepoError(w, r, err)
+		return
+	}
+	h.writeCart(w, r, http.StatusOK, cart, "")
+}
+
+// DeleteCart handles DELETE /carts/{id} - throws a cart away
+func (h *CartHandler) DeleteCart(w http.ResponseWriter, r *http.Request) {
+	if err := h.repo.Delete(mux.Vars(r)["id"]); err != nil {
+		h.writeRepoError(w, r, err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, models.Response{Success: true, Message: "Cart deleted"})
+}
+
+// AddItem handles POST /carts/{id}/items - puts units of a product in the cart, adding to its line
+// if the cart has one. Whether they can be ordered shows in the priced cart returned.
+func (h *CartHandler) AddItem(w http.ResponseWriter, r *http.Request) {
+	var req models.AddItemRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	cart, err := h.repo.Update(mux.Vars(r)["id"], func(cart *models.Cart) error {
+		if !cart.IsOpen() {
+			return repository.ErrCartNotOpen
+		}
+		cart.AddItem(strings.TrimSpace(req.ProductID), req.Quantity)
+		return nil
+	})
+	if err != nil {
+		h.writeRepoError(w, r, err)
+		return
+	}
+	h.writeCart(w, r, http.StatusOK, cart, "Item added")
+}
+
+// errItemMissing is returned from an update when the cart has no line for the product
+var errItemMissing = errors.New("cart has no such item")
+
+// RemoveItem handles DELETE /carts/{id}/items/{product_id} - takes a product's line out of the cart
+func (h *CartHandler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cart, err := h.repo.Update(vars["id"], func(cart *models.Cart) error {
+		if !cart.IsOpen() {
+			return repository.ErrCartNotOpen
+		}
+		if !cart.RemoveItem(vars["product_id"]) {
+			return errItemMissing
+		}
+		return nil
+	})
+	if err != nil {
+		h.writeRepoError(w, r, err)
+		return
+	}
+	h.writeCart(w, r, http.StatusOK, cart, "Item removed")
+}
+
+// MergeCart handles POST /carts/merge - called once a guest logs in, it moves their guest cart's
+// items into their own open cart, creating one if they have none, and deletes the guest cart.
+// Products in both carts have their quantities added up.
+func (h *CartHandler) MergeCart(w http.ResponseWriter, r *http.Request) {
+	var req models.MergeCartRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	cart, err := h.repo.Merge(req.GuestCartID, strings.TrimSpace(req.UserID))
+	if err != nil {
+		h.writeRepoError(w, r, err)
+		return
+	}
+	h.writeCart(w, r, http.StatusOK, cart, "Guest cart merged")
+}
+
+// Checkout handles POST /carts/{id}/checkout - places an order for the cart's items with the order
+// service. The body is an order request without items or user_id, which come from the cart: a
+// shipping_address_id, payment_method, coupon_code, and so on, or for a guest's cart their email and
+// shipping_address. The order service's answer is returned as it is. Once the order is placed the
+// cart is marked ordered with the order's ID; if it isn't, the cart stays open to try again.
+func (h *CartHandler) Checkout(w http.ResponseWriter, r *http.Request) {
+	order := map[string]json.RawMessage{}
+	if !api.DecodeOptionalJSON(w, r, &order) {
+		return
+	}
+	for _, field := range []string{"items", "user_id"} {
+		if _, ok := order[field]; ok {
+			api.WriteError(w, http.StatusBadRequest, field+" comes from the cart and can't be given at checkout")
+			return
+		}
+	}
+
+	id := mux.Vars(r)["id"]
+	cart, err := h.repo.Update(id, func(cart *models.Cart) error {
+		if !cart.IsOpen() {
+			return repository.ErrCartNotOpen
+		}
+		if len(cart.Items) == 0 {
+			return errCartEmpty
+		}
+		cart.Status = models.CartStatusCheckingOut
+		return nil
+	})
+	if err != nil {
+		h.writeRepoError(w, r, err)
+		return
+	}
+
+	items := make([]map[string]interface{}, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		items = append(items, map[string]interface{}{"product_id": item.ProductID, "quantity": item.Quantity})
+	}
+	order["items"], _ = json.Marshal(items)
+	if cart.UserID != "" {
+		order["user_id"], _ = json.Marshal(cart.UserID)
+	}
+	body, _ := json.Marshal(order)
+
+	// The order is placed even if the shopper gives up waiting, so the cart is never left open
+	// behind an order that went through
+	placed, err := h.orders.PlaceOrder(context.WithoutCancel(r.Context()), body)
+	if err != nil || placed.StatusCode != http.StatusCreated {
+		h.reopen(r, id)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error placing order", "dependency", "order-service", "cart_id", id, "error", err)
+			api.WriteError(w, http.StatusServiceUnavailable, "Checkout is unavailable, try again shortly")
+			return
+		}
+		writeRaw(w, placed.StatusCode, placed.Body)
+		return
+	}
+
+	_, err = h.repo.Update(id, func(cart *models.Cart) error {
+		cart.Status = models.CartStatusOrdered
+		cart.OrderID = placed.OrderID
+		return nil
+	})
+	if err != nil {
+		// The order stands; only the cart's record of it is lost
+		slog.ErrorContext(r.Context(), "Error marking cart ordered", "cart_id", id, "order_id", placed.OrderID, "error", err)
+	}
+	writeRaw(w, placed.StatusCode, placed.Body)
+}
+
+// errCartEmpty is returned from an update when an empty cart is checked out
+var errCartEmpty = errors.New("cart is empty")
+
+// reopen puts a cart whose checkout failed back in the open state
+func (h *CartHandler) reopen(r *http.Request, id string) {
+	_, err := h.repo.Update(id, func(cart *models.Cart) error {
+		cart.Status = models.CartStatusOpen
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reopening cart after a failed checkout", "cart_id", id, "error", err)
+	}
+}
+
+// writeCart sends the cart priced with the product service. If the product service can't price it,
+// the cart is sent unpriced with 503, so the shopper still sees what is in it.
+func (h *CartHandler) writeCart(w http.ResponseWriter, r *http.Request, status int, cart *models.Cart, message string) {
+	currency := strings.ToUpper(r.URL.Query().Get("currency"))
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+
+	ids := make([]string, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		ids = append(ids, item.ProductID)
+	}
+	products, err := h.catalog.GetProducts(r.Context(), ids, currency)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error pricing cart", "dependency", "product-service", "cart_id", cart.ID, "error", err)
+		api.WriteJSON(w, http.StatusServiceUnavailable, models.Response{
+			Success: false,
+			Error:   "Prices are unavailable, try again shortly",
+			Data:    cart,
+		})
+		return
+	}
+
+	api.WriteJSON(w, status, models.Response{
+		Success: true,
+		Message: message,
+		Data:    models.Price(cart, products, currency),
+	})
+}
+
+// writeRepoError sends the response for an error from the repository or an update
+func (h *CartHandler) writeRepoError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, repository.ErrCartNotFound):
+		api.WriteErrorCode(w, http.StatusNotFound, CodeCartNotFound, "Cart not found")
+	case errors.Is(err, repository.ErrCartNotOpen):
+		api.WriteErrorCode(w, http.StatusConflict, CodeCartNotOpen, "Cart has been checked out")
+	case errors.Is(err, errCartEmpty):
+		api.WriteErrorCode(w, http.StatusBadRequest, CodeCartEmpty, "Cart is empty")
+	case errors.Is(err, errItemMissing):
+		api.WriteErrorCode(w, http.StatusNotFound, CodeCartItemMissing, "Cart has no such item")
+	default:
+		slog.ErrorContext(r.Context(), "Error updating cart", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to update cart")
+	}
+}
+
+// writeRaw relays a response from another service as it was sent
+func writeRaw(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"cart-service/internal/client"
+	"cart-service/internal/models"
+	"cart-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeCatalog prices from a fixed set of products, or fails when err is set
+type fakeCatalog struct {
+	products map[string]*models.Product
+	err      error
+}
+
+func (c *fakeCatalog) GetProducts(ctx context.Context, ids []string, currency string) (map[string]*models.Product, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	found := make(map[string]*models.Product)
+	for _, id := range ids {
+		if product, ok := c.products[id]; ok {
+			found[id] = product
+		}
+	}
+	return found, nil
+}
+
+// fakeOrders answers order requests with answer, or fails with err, keeping the last request
+type fakeOrders struct {
+	answer *client.PlacedOrder
+	err    error
+	body   map[string]interface{}
+}
+
+func (o *fakeOrders) PlaceOrder(ctx context.Context, body []byte) (*client.PlacedOrder, error) {
+	o.body = nil
+	json.Unmarshal(body, &o.body)
+	return o.answer, o.err
+}
+
+func (o *fakeOrders) Ping(ctx context.Context) error { return nil }
+
+func newTestHandler() (*CartHandler, *repository.InMemoryCartRepository, *fakeCatalog, *fakeOrders) {
+	repo := repository.NewInMemoryCartRepository()
+	catalog := &fakeCatalog{products: map[string]*models.Product{
+		"p1": {ID: "p1", Name: "Mug", UnitPrice: 12.5, Currency: "USD", Stock: 10},
+		"p2": {ID: "p2", Name: "Poster", UnitPrice: 4.99, Currency: "USD", Stock: 1},
+	}}
+	orders := &fakeOrders{answer: &client.PlacedOrder{StatusCode: http.StatusCreated, Body: []byte(`{"success":true,"data":{"id":"o1"}}`), OrderID: "o1"}}
+	return NewCartHandler(repo, catalog, orders), repo, catalog, orders
+}
+
+// call runs handler with body and the route's vars, decoding the priced cart it answers with
+func call(t *testing.T, handler http.HandlerFunc, method, body string, vars map[string]string) (*httptest.ResponseRecorder, models.PricedCart) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, mux.SetURLVars(httptest.NewRequest(method, "/carts", bytes.NewBufferString(body)), vars))
+	var response struct {
+		Data models.PricedCart `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	return rec, response.Data
+}
+
+func TestCartHandler_FillsAndPricesCarts(t *testing.T) {
+	h, _, _, _ := newTestHandler()
+
+	rec, cart := call(t, h.CreateCart, http.MethodPost, `{"user_id":"u1"}`, nil)
+	if rec.Code != http.StatusCreated || cart.Cart == nil || cart.ID == "" || cart.UserID != "u1" {
+		t.Fatalf("expected a cart for u1, got %d %s", rec.Code, rec.Body.String())
+	}
+	vars := map[string]string{"id": cart.ID}
+
+	// A user has one open cart, which creating another returns
+	if rec, again := call(t, h.CreateCart, http.MethodPost, `{"user_id":"u1"}`, nil); rec.Code != http.StatusOK || again.ID != cart.ID {
+		t.Fatalf("expected u1's cart again, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	call(t, h.AddItem, http.MethodPost, `{"product_id":"p1","quantity":2}`, vars)
+	call(t, h.AddItem, http.MethodPost, `{"product_id":"p2","quantity":3}`, vars)
+	rec, cart = call(t, h.AddItem, http.MethodPost, `{"product_id":"p1","quantity":1}`, vars)
+	if rec.Code != http.StatusOK || len(cart.Items) != 2 || cart.Items[0].Quantity != 3 || cart.Items[0].Subtotal != 37.5 {
+		t.Fatalf("expected the mug's line to grow to 3, got %d %s", rec.Code, rec.Body.String())
+	}
+	if cart.Items[1].Problem != models.ProblemInsufficientStock || cart.Ready || cart.Subtotal != 37.5 {
+		t.Errorf("expected the poster flagged and left out of the subtotal, got %+v", cart)
+	}
+
+	rec, cart = call(t, h.RemoveItem, http.MethodDelete, "", map[string]string{"id": cart.ID, "product_id": "p2"})
+	if rec.Code != http.StatusOK || len(cart.Items) != 1 || !cart.Ready || cart.Subtotal != 37.5 || cart.Currency != "USD" {
+		t.Fatalf("expected a ready cart of mugs, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec, _ := call(t, h.RemoveItem, http.MethodDelete, "", map[string]string{"id": cart.ID, "product_id": "p2"}); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 removing a product not in the cart, got %d", rec.Code)
+	}
+	if rec, _ := call(t, h.AddItem, http.MethodPost, `{"product_id":"p1","quantity":0}`, vars); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for no units, got %d", rec.Code)
+	}
+}
+
+func TestCartHandler_ShowsTheCartWhenPricesAreUnavailable(t *testing.T) {
+	h, repo, catalog, _ := newTestHandler()
+	cart := models.NewCart("")
+	cart.AddItem("p1", 1)
+	repo.Create(cart)
+	catalog.err = errors.New("product service is down")
+
+	rec, _ := call(t, h.GetCart, http.MethodGet, "", map[string]string{"id": cart.ID})
+	if rec.Code != http.StatusServiceUnavailable || !bytes.Contains(rec.Body.Bytes(), []byte(`"p1"`)) {
+		t.Fatalf("expected 503 with the unpriced cart, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCartHandler_MergesAGuestCartAtLogin(t *testing.T) {
+	h, repo, _, _ := newTestHandler()
+	guest := models.NewCart("")
+	guest.AddItem("p1", 1)
+	guest.AddItem("p2", 1)
+	repo.Create(guest)
+	own := models.NewCart("u1")
+	own.AddItem("p1", 2)
+	repo.Create(own)
+
+	rec, cart := call(t, h.MergeCart, http.MethodPost, `{"user_id":"u1","guest_cart_id":"`+guest.ID+`"}`, nil)
+	if rec.Code != http.StatusOK || cart.ID != own.ID || len(cart.Items) != 2 || cart.Items[0].Quantity != 3 {
+		t.Fatalf("expected the guest's items added to u1's cart, got %d %s", rec.Code, rec.Body.String())
+	}
+	if _, err := repo.Get(guest.ID); !errors.Is(err, repository.ErrCartNotFound) {
+		t.Errorf("expected the guest cart gone, got %v", err)
+	}
+
+	// A user without a cart gets the guest's items in a new one
+	other := models.NewCart("")
+	other.AddItem("p2", 1)
+	repo.Create(other)
+	if rec, cart := call(t, h.MergeCart, http.MethodPost, `{"user_id":"u2","guest_cart_id":"`+other.ID+`"}`, nil); rec.Code != http.StatusOK || cart.UserID != "u2" || len(cart.Items) != 1 {
+		t.Fatalf("expected a new cart for u2, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Only guest carts can be merged
+	if rec, _ := call(t, h.MergeCart, http.MethodPost, `{"user_id":"u2","guest_cart_id":"`+own.ID+`"}`, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 merging a user's cart, got %d", rec.Code)
+	}
+}
+
+func TestCartHandler_ChecksOutThroughTheOrderService(t *testing.T) {
+	h, repo, _, orders := newTestHandler()
+	cart := models.NewCart("u1")
+	cart.AddItem("p1", 2)
+	repo.Create(cart)
+	vars := map[string]string{"id": cart.ID}
+
+	if rec, _ := call(t, h.Checkout, http.MethodPost, `{"items":[]}`, vars); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for items given at checkout, got %d", rec.Code)
+	}
+
+	// The order service's rejection is passed on, and the cart stays open to try again
+	orders.answer = &client.PlacedOrder{StatusCode: http.StatusConflict, Body: []byte(`{"success":false,"code":"ORDER_INSUFFICIENT_STOCK"}`)}
+	rec, _ := call(t, h.Checkout, http.MethodPost, `{"shipping_method":"express"}`, vars)
+	if rec.Code != http.StatusConflict || !bytes.Contains(rec.Body.Bytes(), []byte("ORDER_INSUFFICIENT_STOCK")) {
+		t.Fatalf("expected the order service's 409, got %d %s", rec.Code, rec.Body.String())
+	}
+	if stored, _ := repo.Get(cart.ID); !stored.IsOpen() {
+		t.Fatalf("expected the cart open after a rejected checkout, got %s", stored.Status)
+	}
+	if orders.body["user_id"] != "u1" || orders.body["shipping_method"] != "express" || len(orders.body["items"].([]interface{})) != 1 {
+		t.Errorf("expected the order request built from the cart and the checkout body, got %v", orders.body)
+	}
+
+	// An unreachable order service leaves the cart open too
+	orders.err = errors.New("connection refused")
+	if rec, _ := call(t, h.Checkout, http.MethodPost, "", vars); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while the order service is down, got %d", rec.Code)
+	}
+
+	orders.err = nil
+	orders.answer = &client.PlacedOrder{StatusCode: http.StatusCreated, Body: []byte(`{"success":true,"data":{"id":"o1"}}`), OrderID: "o1"}
+	if rec, _ := call(t, h.Checkout, http.MethodPost, "", vars); rec.Code != http.StatusCreated || !bytes.Contains(rec.Body.Bytes(), []byte(`"o1"`)) {
+		t.Fatalf("expected the placed order, got %d %s", rec.Code, rec.Body.String())
+	}
+	if stored, _ := repo.Get(cart.ID); stored.Status != models.CartStatusOrdered || stored.OrderID != "o1" {
+		t.Fatalf("expected the cart marked ordered, got %+v", stored)
+	}
+
+	// An ordered cart can't be changed or checked out again
+	if rec, _ := call(t, h.Checkout, http.MethodPost, "", vars); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 checking out twice, got %d", rec.Code)
+	}
+	if rec, _ := call(t, h.AddItem, http.MethodPost, `{"product_id":"p1","quantity":1}`, vars); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 adding to an ordered cart, got %d", rec.Code)
+	}
+
+	empty := models.NewCart("u2")
+	repo.Create(empty)
+	if rec, _ := call(t, h.Checkout, http.MethodPost, "", map[string]string{"id": empty.ID}); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 checking out an empty cart, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,11 @@
+package handlers
+
+// Error codes the cart service sends in the code of an error response, so clients can tell
+// failures apart without matching messages. Errors without one of these get a code named after
+// their status, such as NOT_FOUND.
+const (
+	CodeCartNotFound    = "CART_NOT_FOUND"
+	CodeCartNotOpen     = "CART_NOT_OPEN" // the cart has been checked out, or is being
+	CodeCartEmpty       = "CART_EMPTY"
+	CodeCartItemMissing = "CART_ITEM_NOT_FOUND" // the cart has no line for the product
+)
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"time"
+	"ecommerce/pkg/api"
+
+	"github.com/google/uuid"
+)
+
+// Response is the envelope every endpoint answers with
+type Response = api.Response[api.Unpaged]
+
+// CartStatus is where a cart is on its way to becoming an order
+type CartStatus string
+
+const (
+	CartStatusOpen        CartStatus = "open"
+	CartStatusCheckingOut CartStatus = "checking_out" // its order is being placed; it can't change meanwhile
+	CartStatusOrdered     CartStatus = "ordered"      // it became the order in OrderID
+)
+
+// Cart holds the products a shopper means to buy until they check out. A guest's cart has no user;
+// it is merged into the user's cart when they log in.
+type Cart struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id,omitempty"`
+	Items     []CartItem `json:"items"`
+	Status    CartStatus `json:"status"`
+	OrderID   string     `json:"order_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CartItem is one product in a cart; each product has one line
+type CartItem struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// NewCart creates an empty open cart for userID, or for a guest when userID is empty
+func NewCart(userID string) *Cart {
+	now := time.Now()
+	return &Cart{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Items:     []CartItem{},
+		Status:    CartStatusOpen,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// IsOpen reports whether the cart can still be changed
+func (c *Cart) IsOpen() bool {
+	return c.Status == CartStatusOpen
+}
+
+// AddItem puts quantity units of a product in the cart, adding to its line if it has one
+func (c *Cart) AddItem(productID string, quantity int) {
+	defer c.touch()
+	for i := range c.Items {
+		if c.Items[i].ProductID == productID {
+			c.Items[i].Quantity += quantity
+			return
+		}
+	}
+	c.Items = append(c.Items, CartItem{ProductID: productID, Quantity: quantity})
+}
+
+// RemoveItem takes a product's line out of the cart, reporting whether it had one
+func (c *Cart) RemoveItem(productID string) bool {
+	for i := range c.Items {
+		if c.Items[i].ProductID == productID {
+			c.Items = append(c.Items[:i], c.Items[i+1:]...)
+			c.touch()
+			return true
+		}
+	}
+	return false
+}
+
+// Merge adds other's items to the cart, as when a guest's cart joins their own at login
+func (c *Cart) Merge(other *Cart) {
+	for _, item := range other.Items {
+		c.AddItem(item.ProductID, item.Quantity)
+	}
+	c.touch()
+}
+
+// touch records that the cart changed
+func (c *Cart) touch() {
+	c.UpdatedAt = time.Now()
+}
+
+// Copy returns a copy of the cart that shares nothing with it
+func (c *Cart) Copy() *Cart {
+	cartCopy := *c
+	cartCopy.Items = append([]CartItem{}, c.Items...)
+	return &cartCopy
+}
+
+// CreateCartRequest represents the request payload for creating a cart
+type CreateCartRequest struct {
+	// UserID is the shopper the cart is for; a guest's cart leaves it out
+	UserID string `json:"user_id,omitempty"`
+}
+
+// AddItemRequest represents the request payload for putting a product in a cart
+type AddItemRequest struct {
+	ProductID string `json:"product_id" validate:"required"`
+	Quantity  int    `json:"quantity" validate:"required,min=1"`
+}
+
+// MergeCartRequest represents the request payload for merging a guest's cart into a user's at login
+type MergeCartRequest struct {
+	UserID      string `json:"user_id" validate:"required"`
+	GuestCartID string `json:"guest_cart_id" validate:"required"`
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"math"
+)
+
+// DefaultCurrency is what carts are priced in unless another currency is asked for; orders are
+// placed in it
+const DefaultCurrency = "USD"
+
+// Problems that keep an item from being ordered as it is
+const (
+	ProblemUnavailable        = "unavailable"        // the product is gone or no longer published
+	ProblemInsufficientStock  = "insufficient_stock" // fewer units are in stock than the item asks for
+	ProblemQuantityOutOfRange = "quantity_out_of_range"
+)
+
+// Product is the part of a product service product a cart is priced from
+type Product struct {
+	ID             string
+	Name           string
+	UnitPrice      float64 // the sale price while one applies, else the regular price
+	Currency       string
+	Stock          int
+	MinOrderQty    int // 0 means no minimum
+	MaxOrderQty    int // 0 means no maximum
+	AllowBackorder bool
+}
+
+// PricedItem is a cart item at its product's current price
+type PricedItem struct {
+	CartItem
+	ProductName string  `json:"product_name,omitempty"`
+	UnitPrice   float64 `json:"unit_price"`
+	Subtotal    float64 `json:"subtotal"`
+	Problem     string  `json:"problem,omitempty"` // why the item can't be ordered as it is
+}
+
+// PricedCart is a cart priced at its products' current prices. Checkout prices the order again, so
+// the order may differ if prices change in between.
+type PricedCart struct {
+	*Cart
+	Items    []PricedItem `json:"items"`
+	Subtotal float64      `json:"subtotal"` // of the items that can be ordered
+	Currency string       `json:"currency"`
+	Ready    bool         `json:"ready"` // every item can be ordered as it is
+}
+
+// Price prices cart from products, keyed by ID, in currency; products missing from it are unavailable
+func Price(cart *Cart, products map[string]*Product, currency string) *PricedCart {
+	priced := &PricedCart{Cart: cart, Items: make([]PricedItem, 0, len(cart.Items)), Currency: currency, Ready: len(cart.Items) > 0}
+	for _, item := range cart.Items {
+		line := PricedItem{CartItem: item}
+		product, ok := products[item.ProductID]
+		switch {
+		case !ok:
+			line.Problem = ProblemUnavailable
+		case (product.MinOrderQty > 0 && item.Quantity < product.MinOrderQty) || (product.MaxOrderQty > 0 && item.Quantity > product.MaxOrderQty):
+			line.Problem = ProblemQuantityOutOfRange
+		case item.Quantity > product.Stock && !product.AllowBackorder:
+			line.Problem = ProblemInsufficientStock
+		}
+		if ok {
+			line.ProductName = product.Name
+			line.UnitPrice = product.UnitPrice
+			line.Subtotal = roundCents(product.UnitPrice * float64(item.Quantity))
+		}
+		if line.Problem == "" {
+			priced.Subtotal += line.Subtotal
+		} else {
+			priced.Ready = false
+		}
+		priced.Items = append(priced.Items, line)
+	}
+	priced.Subtotal = roundCents(priced.Subtotal)
+	return priced
+}
+
+// roundCents rounds an amount to whole cents
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+	"time"
+	"cart-service/internal/models"
+)
+
+var (
+	ErrCartNotFound = errors.New("cart not found")
+	ErrCartNotOpen  = errors.New("cart is checked out")
+)
+
+// CartRepository defines the interface for cart data operations
+type CartRepository interface {
+	Create(cart *models.Cart) error
+	Get(id string) (*models.Cart, error)
+	// OpenCartOf returns the user's open cart, or ErrCartNotFound when they have none
+	OpenCartOf(userID string) (*models.Cart, error)
+	// Update changes a cart with change, saving it only if change returns nil, and returns the cart
+	// as saved. No other change to the cart is made meanwhile.
+	Update(id string, change func(cart *models.Cart) error) (*models.Cart, error)
+	// Merge moves the items of the open guest cart guestID into userID's open cart, creating one if
+	// they have none, and deletes the guest cart. It returns the user's cart.
+	Merge(guestID, userID string) (*models.Cart, error)
+	Delete(id string) error
+	// DeleteIdleSince deletes the carts not changed since cutoff and returns how many it deleted
+	DeleteIdleSince(cutoff time.Time) (int, error)
+}
+
+// InMemoryCartRepository implements CartRepository using in-memory storage
+type InMemoryCartRepository struct {
+	carts map[string]*models.Cart
+	mutex sync.RWMutex
+}
+
+// NewInMemoryCartRepository creates a new in-memory cart repository
+func NewInMemoryCartRepository() *InMemoryCartRepository {
+	return &InMemoryCartRepository{carts: make(map[string]*models.Cart)}
+}
+
+// Create adds a cart
+func (r *InMemoryCartRepository) Create(cart *models.Cart) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.carts[cart.ID] = cart.Copy()
+	return nil
+}
+
+// Get retrieves a cart by its ID
+func (r *InMemoryCartRepository) Get(id string) (*models.Cart, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	cart, exists := r.carts[id]
+	if !exists {
+		return nil, ErrCartNotFound
+	}
+	return cart.Copy(), nil
+}
+
+// OpenCartOf returns the user's open cart
+func (r *InMemoryCartRepository) OpenCartOf(userID string) (*models.Cart, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if cart := r.openCartOf(userID); cart != nil {
+		return cart.Copy(), nil
+	}
+	return nil, ErrCartNotFound
+}
+
+// openCartOf finds the user's open cart; the caller holds the lock
+func (r *InMemoryCartRepository) openCartOf(userID string) *models.Cart {
+	for _, cart := range r.carts {
+		if cart.UserID == userID && cart.IsOpen() {
+			return cart
+		}
+	}
+	return nil
+}
+
+// Update changes a cart under the repository's lock
+func (r *InMemoryCartRepository) Update(id string, change func(cart *models.Cart) error) (*models.Cart, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, exists := r.carts[id]
+	if !exists {
+		return nil, ErrCartNotFound
+	}
+	cart := stored.Copy()
+	if err := change(cart); err != nil {
+		return nil, err
+	}
+	r.carts[id] = cart
+	return cart.Copy(), nil
+}
+
+// Merge moves a guest cart's items into the user's open cart
+func (r *InMemoryCartRepository) Merge(guestID, userID string) (*models.Cart, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	guest, exists := r.carts[guestID]
+	if !exists || guest.UserID != "" {
+		return nil, ErrCartNotFound
+	}
+	if !guest.IsOpen() {
+		return nil, ErrCartNotOpen
+	}
+
+	cart := r.openCartOf(userID)
+	if cart == nil {
+		cart = models.NewCart(userID)
+		r.carts[cart.ID] = cart
+	}
+	cart.Merge(guest)
+	delete(r.carts, guestID)
+	return cart.Copy(), nil
+}
+
+// Delete removes a cart
+func (r *InMemoryCartRepository) Delete(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.carts[id]; !exists {
+		return ErrCartNotFound
+	}
+	delete(r.carts, id)
+	return nil
+}
+
+// DeleteIdleSince removes the carts left alone since cutoff. Carts being checked out are kept, so an
+// order being placed still has its cart to record itself on.
+func (r *InMemoryCartRepository) DeleteIdleSince(cutoff time.Time) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	deleted := 0
+	for id, cart := range r.carts {
+		if cart.UpdatedAt.Before(cutoff) && cart.Status != models.CartStatusCheckingOut {
+			delete(r.carts, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Count returns how many carts are stored
+func (r *InMemoryCartRepository) Count() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return len(r.carts)
+}
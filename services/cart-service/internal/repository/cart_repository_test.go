@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"cart-service/internal/models"
+)
+
+func TestInMemoryCartRepository_UpdateKeepsFailedChangesOut(t *testing.T) {
+	repo := NewInMemoryCartRepository()
+	cart := models.NewCart("u1")
+	repo.Create(cart)
+
+	_, err := repo.Update(cart.ID, func(cart *models.Cart) error {
+		cart.AddItem("p1", 1)
+		return ErrCartNotOpen
+	})
+	if !errors.Is(err, ErrCartNotOpen) {
+		t.Fatalf("expected the change's error, got %v", err)
+	}
+	if stored, _ := repo.Get(cart.ID); len(stored.Items) != 0 {
+		t.Fatalf("expected a failed change not saved, got %+v", stored.Items)
+	}
+
+	updated, err := repo.Update(cart.ID, func(cart *models.Cart) error {
+		cart.AddItem("p1", 1)
+		return nil
+	})
+	if err != nil || len(updated.Items) != 1 {
+		t.Fatalf("expected the change saved, got %v %+v", err, updated)
+	}
+	if _, err := repo.Update("missing", func(*models.Cart) error { return nil }); !errors.Is(err, ErrCartNotFound) {
+		t.Errorf("expected ErrCartNotFound, got %v", err)
+	}
+}
+
+func TestInMemoryCartRepository_OpenCartOf(t *testing.T) {
+	repo := NewInMemoryCartRepository()
+	ordered := models.NewCart("u1")
+	ordered.Status = models.CartStatusOrdered
+	repo.Create(ordered)
+	if _, err := repo.OpenCartOf("u1"); !errors.Is(err, ErrCartNotFound) {
+		t.Fatalf("expected an ordered cart not to count as open, got %v", err)
+	}
+
+	open := models.NewCart("u1")
+	repo.Create(open)
+	if cart, err := repo.OpenCartOf("u1"); err != nil || cart.ID != open.ID {
+		t.Fatalf("expected u1's open cart, got %v %+v", err, cart)
+	}
+}
+
+func TestInMemoryCartRepository_DeleteIdleSince(t *testing.T) {
+	repo := NewInMemoryCartRepository()
+	idle, fresh, checkingOut := models.NewCart(""), models.NewCart(""), models.NewCart("u1")
+	idle.UpdatedAt = time.Now().Add(-48 * time.Hour)
+	checkingOut.UpdatedAt = idle.UpdatedAt
+	checkingOut.Status = models.CartStatusCheckingOut
+	for _, cart := range []*models.Cart{idle, fresh, checkingOut} {
+		repo.Create(cart)
+	}
+
+	deleted, err := repo.DeleteIdleSince(time.Now().Add(-24 * time.Hour))
+	if err != nil || deleted != 1 || repo.Count() != 2 {
+		t.Fatalf("expected only the idle cart deleted, got %d deleted, %d left", deleted, repo.Count())
+	}
+	if _, err := repo.Get(idle.ID); !errors.Is(err, ErrCartNotFound) {
+		t.Errorf("expected the idle cart gone, got %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"ecommerce/pkg/config"
+	"ecommerce/pkg/diagnostics"
+	"ecommerce/pkg/health"
+	"ecommerce/pkg/logging"
+	"ecommerce/pkg/metrics"
+	"ecommerce/pkg/middleware"
+	"ecommerce/pkg/rpc"
+	orderv1 "ecommerce/pkg/proto/order/v1"
+	productv1 "ecommerce/pkg/proto/product/v1"
+	userv1 "ecommerce/pkg/proto/user/v1"
+	"gateway-service/internal/auth"
+	"gateway-service/internal/graph"
+	"gateway-service/internal/proxy"
+	"gateway-service/internal/status"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	// Settings come from flags, the environment, and the YAML file named by -config or CONFIG_FILE
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		logging.Fatal("Failed to load configuration", "error", err)
+	}
+	logging.Setup(cfg.String("SERVICE_NAME", "gateway-service"))
+	serverConfig := cfg.Server(8080)
+
+	// The log level, body size limit, and rate limits are read again on SIGHUP
+	reloader := config.NewReloader(cfg)
+	reloader.Register(config.TuneLogLevel)
+	reloader.Register(config.TuneMaxBodyBytes)
+
+	// With TLS_CERT_FILE, the services are called over mutual TLS, presenting the gateway's certificate;
+	// the gateway itself still serves plain HTTP, leaving TLS for clients to the ingress in front of it
+	certs := reloader.TLS()
+
+	// Queries are resolved over the services' gRPC APIs
+	// In production, these addresses would come from service discovery
+	users := dialService(cfg, "USER_SERVICE_GRPC_ADDR", "localhost:9081", certs)
+	products := dialService(cfg, "PRODUCT_SERVICE_GRPC_ADDR", "localhost:9082", certs)
+	orders := dialService(cfg, "ORDER_SERVICE_GRPC_ADDR", "localhost:9083", certs)
+	graphHandler := graph.NewHandler(graph.Clients{
+		Users:    userv1.NewUserServiceClient(users),
+		Products: productv1.NewProductServiceClient(products),
+		Orders:   orderv1.NewOrderServiceClient(orders),
+	}, graphSettings(cfg))
+
+	// Bearer tokens are verified once here, with the user service, before requests go any further
+	authenticator := auth.NewAuthenticator(userv1.NewUserServiceClient(users), cfg.String("SERVICE_KEY", ""))
+
+	// The REST API is forwarded to the service owning each path
+	// In production, these URLs would come from service discovery
+	upstreams := proxy.Upstreams{
+		Users:    serviceURL(cfg, "USER_SERVICE_URL", "http://localhost:8081"),
+		Products: serviceURL(cfg, "PRODUCT_SERVICE_URL", "http://localhost:8082"),
+		Orders:   serviceURL(cfg, "ORDER_SERVICE_URL", "http://localhost:8083"),
+		Carts:    serviceURL(cfg, "CART_SERVICE_URL", "http://localhost:8084"),
+		Shipping: serviceURL(cfg, "SHIPPING_SERVICE_URL", "http://localhost:8086"),
+		TLS:      certs.ClientConfig(),
+	}
+	// Payment provider webhooks go to payment service when PAYMENT_SERVICE_URL is set
+	if cfg.String("PAYMENT_SERVICE_URL", "") != "" {
+		upstreams.Payments = serviceURL(cfg, "PAYMENT_SERVICE_URL", "")
+	}
+	restProxy := proxy.New(upstreams)
+
+	// Queries can't be answered without all three services, so readiness checks each, waiting up to
+	// READINESS_TIMEOUT for each
+	readinessTimeout := cfg.Duration("READINESS_TIMEOUT", health.DefaultTimeout, config.NonNegative)
+	probes := health.NewChecker("gateway-service", readinessTimeout)
+	probes.Register("user_service", rpc.HealthCheck(users))
+	probes.Register("product_service", rpc.HealthCheck(products))
+	probes.Register("order_service", rpc.HealthCheck(orders))
+
+	// /status gathers every service's readiness probe, with its version and latency, for dashboards
+	services := []status.Service{
+		{Name: "user_service", URL: upstreams.Users},
+		{Name: "product_service", URL: upstreams.Products},
+		{Name: "order_service", URL: upstreams.Orders},
+		{Name: "cart_service", URL: upstreams.Carts},
+		{Name: "shipping_service", URL: upstreams.Shipping},
+	}
+	if upstreams.Payments != nil {
+		services = append(services, status.Service{Name: "payment_service", URL: upstreams.Payments})
+	}
+	serviceStatus := status.New("gateway-service", services, upstreams.TLS, readinessTimeout)
+
+	// Setup routes
+	router := setupRoutes(serverConfig.CORSOrigins, reloader, probes, serviceStatus, authenticator, graphHandler, restProxy)
+
+	// Stop before serving if any setting was invalid, listing every problem at once
+	if err := cfg.Err(); err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+	for _, key := range cfg.Unused() {
+		slog.Warn("Setting is not used", "key", key)
+	}
+	go reloader.Watch(context.Background())
+
+	// CPU and heap profiles and runtime variables are served on DEBUG_ADDR, an internal port apart
+	// from the API, when it is set
+	debugServer, err := diagnostics.Serve(serverConfig.DebugAddr)
+	if err != nil {
+		logging.Fatal("Diagnostics port failed to start", "error", err)
+	}
+
+	// Configure server; requests through the proxies in TRUSTED_PROXIES, such as the gateway, are
+	// attributed to the client they came from
+	server := &http.Server{
+		Addr:         serverConfig.Addr(),
+		Handler:      middleware.TrustProxies(serverConfig.TrustedProxies)(middleware.Versioned(router, "v1", "v1")),
+		ReadTimeout:  serverConfig.ReadTimeout,
+		WriteTimeout: serverConfig.WriteTimeout,
+		IdleTimeout:  serverConfig.IdleTimeout,
+	}
+
+	// Start server in a goroutine
+	go func() {
+		slog.Info("🚀 Gateway Service starting", "port", serverConfig.Port)
+		slog.Info("📚 API Documentation:")
+		slog.Info("  POST /graphql         - Run a GraphQL query across users, products, and orders")
+		slog.Info("  *    /users, /auth      - Forwarded to the user service")
+		slog.Info("  *    /products, /uploads - Forwarded to the product service")
+		slog.Info("  *    /orders, /payments - Forwarded to the order service")
+		slog.Info("  GET  /schema.graphql  - The GraphQL schema")
+		slog.Info("  GET  /healthz         - Liveness probe")
+		slog.Info("  GET  /readyz          - Readiness probe, checking user, product, and order service")
+		slog.Info("  GET  /status          - Every service's health, version, and latency")
+		slog.Info("  GET  /metrics         - Prometheus metrics")
+		slog.Info("---")
+
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logging.Fatal("Server failed to start", "error", err)
+		}
+	}()
+
+	// Wait for interrupt signal to gracefully shutdown the server
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	slog.Info("🛑 Shutting down Gateway Service...")
+
+	// Graceful shutdown with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), serverConfig.ShutdownTimeout)
+	defer cancel()
+
+	debugServer.Close()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Server forced to shutdown", "error", err)
+	} else {
+		slog.Info("✅ Gateway Service shutdown complete")
+	}
+}
+
+// setupRoutes configures all the HTTP routes
+func setupRoutes(corsOrigins []string, reloader *config.Reloader, probes *health.Checker, serviceStatus *status.Aggregator, authenticator *auth.Authenticator, graphHandler *graph.Handler, restProxy *proxy.Proxy) *mux.Router {
+	router := mux.NewRouter()
+
+	// Add CORS middleware; the services' own CORS headers are dropped from what they return, so this is
+	// the only policy clients see
+	router.Use(middleware.CORS(corsOrigins))
+
+	// Tag each request with an ID for the logs and calls to other services
+	router.Use(middleware.RequestID)
+
+	// Name the running build on every response
+	router.Use(middleware.ServiceVersion)
+
+	// Send errors as problem details to clients that ask for application/problem+json
+	router.Use(middleware.ProblemDetails)
+
+	// Add logging middleware
+	router.Use(middleware.Logging)
+
+	// Count and time requests by route for /metrics
+	router.Use(middleware.Metrics)
+
+	// Verify bearer tokens, rejecting invalid ones before they reach a service
+	router.Use(authenticator.Authenticate)
+
+	// Limit how fast each client may call: signed-in users per user, everyone else per IP
+	router.Use(middleware.RateLimit("global", middleware.RateLimiter(reloader, "RATE_LIMIT", 100, 600), middleware.RateLimiter(reloader, "RATE_LIMIT_USER", 100, 600), auth.UserID))
+
+	// API routes live under a version prefix; operational endpoints stay at the root
+	v1 := router.PathPrefix("/v1").Subrouter()
+
+	// Turn API requests away with 503 once too many are in flight, rather than letting a spike pile up
+	v1.Use(middleware.LoadShedder(reloader).Middleware)
+
+	// GraphQL queries
+	v1.Handle("/graphql", graphHandler).Methods("POST")
+
+	// Everything else under /v1, and product images, is forwarded to the services. Any method is
+	// matched, so preflight requests reach the CORS middleware.
+	v1.PathPrefix("/").Handler(restProxy)
+	router.PathPrefix("/uploads/").Handler(restProxy)
+
+	// The schema, for clients and code generators that don't use introspection
+	router.HandleFunc("/schema.graphql", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(graph.Schema()))
+	}).Methods("GET")
+
+	// Service metrics
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// Liveness and readiness probes
+	router.HandleFunc("/healthz", probes.Liveness).Methods("GET")
+	router.HandleFunc("/readyz", probes.Readiness).Methods("GET")
+
+	// Every service's health in one view
+	router.Handle("/status", serviceStatus).Methods("GET")
+
+	return router
+}
+
+// dialService connects to the gRPC API at the address setting key names, over TLS when certs is set
+func dialService(cfg *config.Config, key, defaultAddr string, certs *config.TLS) grpc.ClientConnInterface {
+	conn, err := rpc.Dial(cfg.String(key, defaultAddr), certs.ClientConfig())
+	if err != nil {
+		logging.Fatal("Invalid gRPC address", "key", key, "error", err)
+	}
+	return conn
+}
+
+// serviceURL reads the base URL of a service's REST API from the setting key names
+func serviceURL(cfg *config.Config, key, defaultURL string) *url.URL {
+	raw := cfg.String(key, defaultURL)
+	upstream, err := url.Parse(raw)
+	if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+		logging.Fatal("Invalid service URL", "key", key, "url", raw)
+	}
+	return upstream
+}
+
+// graphSettings reads how queries are run: SERVICE_KEY is presented to the services, QUERY_TIMEOUT
+// bounds how long a query may take (0 for no limit), and QUERY_MAX_DEPTH bounds how deeply its fields
+// may nest
+func graphSettings(cfg *config.Config) graph.Settings {
+	settings := graph.DefaultSettings
+	settings.ServiceKey = cfg.String("SERVICE_KEY", "")
+	settings.Timeout = cfg.Duration("QUERY_TIMEOUT", settings.Timeout, config.NonNegative)
+	settings.MaxDepth = cfg.Int("QUERY_MAX_DEPTH", settings.MaxDepth, config.Positive)
+	return settings
+}
@@ -0,0 +1,77 @@
+// Package auth authenticates the gateway's clients, verifying each bearer token once with the user
+// service before the request goes any further
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"ecommerce/pkg/api"
+	"ecommerce/pkg/rpc"
+	userv1 "ecommerce/pkg/proto/user/v1"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type contextKey struct{}
+
+// Authenticator verifies bearer tokens over the user service's gRPC API
+type Authenticator struct {
+	users      userv1.UserServiceClient
+	serviceKey string
+}
+
+// NewAuthenticator creates an authenticator verifying tokens with users, presenting serviceKey
+func NewAuthenticator(users userv1.UserServiceClient, serviceKey string) *Authenticator {
+	return &Authenticator{users: users, serviceKey: serviceKey}
+}
+
+// Authenticate verifies the bearer token (if any) and stores the caller in the request context.
+// Requests without a token pass through anonymously for the services to decide on; invalid tokens
+// get 401, and 503 when the user service can't say.
+func (a *Authenticator) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := strings.TrimPrefix(header, "Bearer ")
+		if token == header || token == "" {
+			api.WriteError(w, http.StatusUnauthorized, "Invalid or expired token")
+			return
+		}
+
+		session, err := a.users.VerifySession(rpc.WithServiceKey(r.Context(), a.serviceKey), &userv1.VerifySessionRequest{Token: token})
+		if status.Code(err) == codes.Unauthenticated {
+			api.WriteError(w, http.StatusUnauthorized, status.Convert(err).Message())
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error verifying session", "dependency", "user-service", "error", err)
+			api.WriteError(w, http.StatusServiceUnavailable, "Authentication is unavailable")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), session.User)))
+	})
+}
+
+// WithUser returns a copy of ctx carrying the authenticated user
+func WithUser(ctx context.Context, user *userv1.User) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// UserFromContext returns the authenticated user, or nil for anonymous requests
+func UserFromContext(ctx context.Context) *userv1.User {
+	user, _ := ctx.Value(contextKey{}).(*userv1.User)
+	return user
+}
+
+// UserID returns the authenticated user's ID, or "" for anonymous requests, for keying rate limits
+func UserID(ctx context.Context) string {
+	return UserFromContext(ctx).GetId()
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	userv1 "ecommerce/pkg/proto/user/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeUsers knows one token, or fails every call with err
+type fakeUsers struct {
+	userv1.UserServiceClient
+	err error
+}
+
+func (f *fakeUsers) VerifySession(ctx context.Context, req *userv1.VerifySessionRequest, opts ...grpc.CallOption) (*userv1.Session, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if req.Token != "good" {
+		return nil, status.Error(codes.Unauthenticated, "Invalid or expired token")
+	}
+	return &userv1.Session{Id: "s1", User: &userv1.User{Id: "u1"}}, nil
+}
+
+// authenticate sends a request with the Authorization header through Authenticate, returning the
+// status and the user the next handler saw
+func authenticate(users userv1.UserServiceClient, header string) (int, string) {
+	seen := "-"
+	handler := NewAuthenticator(users, "key").Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = UserID(r.Context())
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/v1/orders", nil)
+	if header != "" {
+		req.Header.Set("Authorization", header)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code, seen
+}
+
+func TestAuthenticate(t *testing.T) {
+	users := &fakeUsers{}
+	for _, tc := range []struct {
+		header string
+		code   int
+		user   string
+	}{
+		{"", http.StatusOK, ""},
+		{"Bearer good", http.StatusOK, "u1"},
+		{"Bearer bad", http.StatusUnauthorized, "-"},
+		{"Basic good", http.StatusUnauthorized, "-"},
+	} {
+		code, user := authenticate(users, tc.header)
+		if code != tc.code || user != tc.user {
+			t.Fatalf("%q: expected %d as %q, got %d as %q", tc.header, tc.code, tc.user, code, user)
+		}
+	}
+}
+
+func TestAuthenticate_UnavailableWhenTheUserServiceIsDown(t *testing.T) {
+	code, user := authenticate(&fakeUsers{err: errors.New("connection refused")}, "Bearer good")
+	if code != http.StatusServiceUnavailable || user != "-" {
+		t.Fatalf("expected 503 without reaching the handler, got %d as %q", code, user)
+	}
+}
@@ -0,0 +1,150 @@
+// Package graph serves the gateway's GraphQL API, resolving users, products, and orders over the
+// services' gRPC APIs
+package graph
+
+import (
+	"context"
+	_ "embed"
+	"net/http"
+	"time"
+	"ecommerce/pkg/api"
+	"ecommerce/pkg/rpc"
+	orderv1 "ecommerce/pkg/proto/order/v1"
+	productv1 "ecommerce/pkg/proto/product/v1"
+	userv1 "ecommerce/pkg/proto/user/v1"
+	"gateway-service/internal/loader"
+
+	"github.com/graph-gophers/graphql-go"
+)
+
+//go:embed schema.graphql
+var schema string
+
+// Clients are the services' gRPC APIs queries are resolved with
+type Clients struct {
+	Users    userv1.UserServiceClient
+	Products productv1.ProductServiceClient
+	Orders   orderv1.OrderServiceClient
+}
+
+// Settings tune how queries are run
+type Settings struct {
+	// ServiceKey is presented to the services as the gateway's key; none is sent when it is empty
+	ServiceKey string
+	// Timeout bounds how long a query may take to resolve; 0 means no limit
+	Timeout time.Duration
+	// MaxDepth is how deeply fields may be nested in a query, so a query can't fan out without bound
+	MaxDepth int
+}
+
+// DefaultSettings are the settings used unless configured otherwise
+var DefaultSettings = Settings{
+	Timeout:  10 * time.Second,
+	MaxDepth: 10,
+}
+
+// Handler serves GraphQL queries POSTed as JSON
+type Handler struct {
+	schema   *graphql.Schema
+	clients  Clients
+	settings Settings
+}
+
+// NewHandler creates a handler resolving queries with clients
+func NewHandler(clients Clients, settings Settings) *Handler {
+	return &Handler{
+		schema:   graphql.MustParseSchema(schema, &Resolver{clients: clients}, graphql.MaxDepth(settings.MaxDepth)),
+		clients:  clients,
+		settings: settings,
+	}
+}
+
+// Schema returns the schema queries are run against, in the GraphQL schema language
+func Schema() string {
+	return schema
+}
+
+// request is a GraphQL request body
+type request struct {
+	Query         string                 `json:"query" validate:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    map[string]interface{} `json:"extensions"`
+}
+
+// ServeHTTP runs the query in the request body. The response is a standard GraphQL response, with
+// data and errors, rather than the API envelope, since GraphQL clients expect one.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := rpc.WithServiceKey(r.Context(), h.settings.ServiceKey)
+	if h.settings.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.settings.Timeout)
+		defer cancel()
+	}
+	ctx = newQueryContext(ctx, h.clients)
+
+	api.WriteJSON(w, http.StatusOK, h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables))
+}
+
+// productKey identifies a product and the currency its prices are wanted in
+type productKey struct {
+	id       string
+	currency string
+}
+
+// queryContext holds what resolvers share while resolving one query: the clients, and loaders that
+// batch the users and products the query asks for
+type queryContext struct {
+	clients  Clients
+	users    *loader.Loader[string, *userv1.User]
+	products *loader.Loader[productKey, *productv1.Product]
+}
+
+type queryContextKey struct{}
+
+// newQueryContext returns a copy of ctx carrying fresh loaders, so nothing is cached across queries
+func newQueryContext(ctx context.Context, clients Clients) context.Context {
+	qc := &queryContext{
+		clients: clients,
+		users: loader.New(func(ctx context.Context, ids []string) (map[string]*userv1.User, error) {
+			resp, err := clients.Users.BatchGetUsers(ctx, &userv1.BatchGetUsersRequest{Ids: ids})
+			if err != nil {
+				return nil, err
+			}
+			users := make(map[string]*userv1.User, len(resp.Users))
+			for _, user := range resp.Users {
+				users[user.Id] = user
+			}
+			return users, nil
+		}),
+		products: loader.New(func(ctx context.Context, keys []productKey) (map[productKey]*productv1.Product, error) {
+			// One call per currency asked for, which is one call for almost every query
+			idsByCurrency := make(map[string][]string)
+			for _, key := range keys {
+				idsByCurrency[key.currency] = append(idsByCurrency[key.currency], key.id)
+			}
+			products := make(map[productKey]*productv1.Product, len(keys))
+			for currency, ids := range idsByCurrency {
+				resp, err := clients.Products.BatchGetProducts(ctx, &productv1.BatchGetProductsRequest{Ids: ids, Currency: currency})
+				if err != nil {
+					return nil, err
+				}
+				for _, product := range resp.Products {
+					products[productKey{id: product.Id, currency: currency}] = product
+				}
+			}
+			return products, nil
+		}),
+	}
+	return context.WithValue(ctx, queryContextKey{}, qc)
+}
+
+// fromContext returns the query context newQueryContext put in ctx
+func fromContext(ctx context.Context) *queryContext {
+	return ctx.Value(queryContextKey{}).(*queryContext)
+}
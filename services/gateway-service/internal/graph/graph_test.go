@@ -0,0 +1,208 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	orderv1 "ecommerce/pkg/proto/order/v1"
+	productv1 "ecommerce/pkg/proto/product/v1"
+	userv1 "ecommerce/pkg/proto/user/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeServices serves fixed users, products, and orders, recording the IDs of each batch call
+type fakeServices struct {
+	userv1.UnimplementedUserServiceServer
+	productv1.UnimplementedProductServiceServer
+	orderv1.UnimplementedOrderServiceServer
+
+	mu             sync.Mutex
+	userBatches    []string
+	productBatches []string
+}
+
+var (
+	testUsers = map[string]*userv1.User{
+		"u1": {Id: "u1", Name: "Ada"},
+		"u2": {Id: "u2", Name: "Bob"},
+	}
+	testProducts = map[string]*productv1.Product{
+		"lamp":  {Id: "lamp", Name: "Lamp", Price: 20, Currency: "USD"},
+		"chair": {Id: "chair", Name: "Chair", Price: 50, Currency: "USD"},
+	}
+	testOrders = []*orderv1.Order{
+		{Id: "o1", UserId: "u1", Total: 70, Items: []*orderv1.OrderItem{{ProductId: "lamp", Quantity: 1}, {ProductId: "chair", Quantity: 1}}},
+		{Id: "o2", UserId: "u1", Total: 20, Items: []*orderv1.OrderItem{{ProductId: "lamp", Quantity: 1}, {ProductId: "gone", ProductName: "Stool", Quantity: 1}}},
+		{Id: "o3", Total: 50, Items: []*orderv1.OrderItem{{ProductId: "chair", Quantity: 1}}},
+	}
+)
+
+// recordBatch records the IDs of a batch call, sorted
+func (f *fakeServices) recordBatch(batches *[]string, ids []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+	*batches = append(*batches, strings.Join(sorted, ","))
+}
+
+func (f *fakeServices) BatchGetUsers(ctx context.Context, req *userv1.BatchGetUsersRequest) (*userv1.BatchGetUsersResponse, error) {
+	f.recordBatch(&f.userBatches, req.Ids)
+	resp := &userv1.BatchGetUsersResponse{}
+	for _, id := range req.Ids {
+		if user, exists := testUsers[id]; exists {
+			resp.Users = append(resp.Users, user)
+		}
+	}
+	return resp, nil
+}
+
+func (f *fakeServices) BatchGetProducts(ctx context.Context, req *productv1.BatchGetProductsRequest) (*productv1.BatchGetProductsResponse, error) {
+	if req.Currency == "XYZ" {
+		return nil, status.Error(codes.InvalidArgument, "Unsupported currency: XYZ")
+	}
+	f.recordBatch(&f.productBatches, req.Ids)
+	resp := &productv1.BatchGetProductsResponse{}
+	for _, id := range req.Ids {
+		if product, exists := testProducts[id]; exists {
+			resp.Products = append(resp.Products, product)
+		}
+	}
+	return resp, nil
+}
+
+func (f *fakeServices) GetOrder(ctx context.Context, req *orderv1.GetOrderRequest) (*orderv1.Order, error) {
+	for _, order := range testOrders {
+		if order.Id == req.Id {
+			return order, nil
+		}
+	}
+	return nil, status.Error(codes.NotFound, "Order not found")
+}
+
+func (f *fakeServices) ListUserOrders(ctx context.Context, req *orderv1.ListUserOrdersRequest) (*orderv1.ListUserOrdersResponse, error) {
+	resp := &orderv1.ListUserOrdersResponse{}
+	for _, order := range testOrders {
+		if order.UserId == req.UserId {
+			resp.Orders = append(resp.Orders, order)
+		}
+	}
+	return resp, nil
+}
+
+// newTestHandler serves the fake services in memory and returns a handler resolving queries with them
+func newTestHandler(t *testing.T) (*Handler, *fakeServices) {
+	t.Helper()
+	fake := &fakeServices{}
+	server := grpc.NewServer()
+	userv1.RegisterUserServiceServer(server, fake)
+	productv1.RegisterProductServiceServer(server, fake)
+	orderv1.RegisterOrderServiceServer(server, fake)
+
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	clients := Clients{
+		Users:    userv1.NewUserServiceClient(conn),
+		Products: productv1.NewProductServiceClient(conn),
+		Orders:   orderv1.NewOrderServiceClient(conn),
+	}
+	return NewHandler(clients, DefaultSettings), fake
+}
+
+// graphQLResponse is a GraphQL response with its data left raw
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// query POSTs query to handler and returns the response
+func query(t *testing.T, handler http.Handler, query string) graphQLResponse {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"query": query})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader(string(body))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp graphQLResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestHandler_StitchesOrdersWithTheirUsersAndProducts(t *testing.T) {
+	handler, fake := newTestHandler(t)
+
+	resp := query(t, handler, `{ orders(userId: "u1") { id user { name } items { productName product { name } } } }`)
+	if len(resp.Errors) != 0 {
+		t.Fatalf("expected no errors, got %+v", resp.Errors)
+	}
+	want := `{"orders":[` +
+		`{"id":"o1","user":{"name":"Ada"},"items":[{"productName":"","product":{"name":"Lamp"}},{"productName":"","product":{"name":"Chair"}}]},` +
+		`{"id":"o2","user":{"name":"Ada"},"items":[{"productName":"","product":{"name":"Lamp"}},{"productName":"Stool","product":null}]}]}`
+	if string(resp.Data) != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, resp.Data)
+	}
+
+	if strings.Join(fake.userBatches, ";") != "u1" {
+		t.Fatalf("expected one user lookup for both orders, got %v", fake.userBatches)
+	}
+	if strings.Join(fake.productBatches, ";") != "chair,gone,lamp" {
+		t.Fatalf("expected one product lookup with each product once, got %v", fake.productBatches)
+	}
+}
+
+func TestHandler_ResolvesMissingObjectsAsNull(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	resp := query(t, handler, `{ order(id: "missing") { id } guest: order(id: "o3") { user { name } } user(id: "nobody") { name } }`)
+	if len(resp.Errors) != 0 {
+		t.Fatalf("expected no errors, got %+v", resp.Errors)
+	}
+	if string(resp.Data) != `{"order":null,"guest":{"user":null},"user":null}` {
+		t.Fatalf("expected nulls for the missing order and users, got %s", resp.Data)
+	}
+}
+
+func TestHandler_PassesInvalidArgumentsOn(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	resp := query(t, handler, `{ product(id: "lamp", currency: "XYZ") { name } }`)
+	if len(resp.Errors) != 1 || resp.Errors[0].Message != "Unsupported currency: XYZ" {
+		t.Fatalf("expected the service's message, got %+v", resp.Errors)
+	}
+}
+
+func TestHandler_RejectsQueriesNestedTooDeeply(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	resp := query(t, handler, `{ user(id: "u1") { orders { user { orders { user { orders { user { orders { user { orders { id } } } } } } } } } } }`)
+	if len(resp.Errors) == 0 || len(resp.Data) != 0 && string(resp.Data) != "null" {
+		t.Fatalf("expected the query rejected, got %s %+v", resp.Data, resp.Errors)
+	}
+}
@@ -0,0 +1,206 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	orderv1 "ecommerce/pkg/proto/order/v1"
+	productv1 "ecommerce/pkg/proto/product/v1"
+	userv1 "ecommerce/pkg/proto/user/v1"
+	"gateway-service/internal/loader"
+
+	"github.com/graph-gophers/graphql-go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Resolver resolves the Query type
+type Resolver struct {
+	clients Clients
+}
+
+// User returns a user, or nil when there is no such user
+func (r *Resolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	return loadUser(ctx, string(args.ID))
+}
+
+// Product returns a published product, or nil when there is none
+func (r *Resolver) Product(ctx context.Context, args struct {
+	ID       graphql.ID
+	Currency *string
+}) (*productResolver, error) {
+	return loadProduct(ctx, string(args.ID), args.Currency)
+}
+
+// Order returns an order, or nil when there is no such order
+func (r *Resolver) Order(ctx context.Context, args struct{ ID graphql.ID }) (*orderResolver, error) {
+	order, err := r.clients.Orders.GetOrder(ctx, &orderv1.GetOrderRequest{Id: string(args.ID)})
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, serviceError(ctx, "order service", err)
+	}
+	return &orderResolver{order}, nil
+}
+
+// Orders returns a user's orders
+func (r *Resolver) Orders(ctx context.Context, args struct {
+	UserID          graphql.ID
+	IncludeArchived bool
+}) ([]*orderResolver, error) {
+	return listOrders(ctx, r.clients.Orders, string(args.UserID), args.IncludeArchived)
+}
+
+// userResolver resolves the User type
+type userResolver struct {
+	user *userv1.User
+}
+
+func (u *userResolver) ID() graphql.ID          { return graphql.ID(u.user.Id) }
+func (u *userResolver) Name() string            { return u.user.Name }
+func (u *userResolver) Email() string           { return u.user.Email }
+func (u *userResolver) Phone() string           { return u.user.Phone }
+func (u *userResolver) Role() string            { return u.user.Role }
+func (u *userResolver) Active() bool            { return u.user.Active }
+func (u *userResolver) CreatedAt() graphql.Time { return timeOf(u.user.CreatedAt) }
+func (u *userResolver) UpdatedAt() graphql.Time { return timeOf(u.user.UpdatedAt) }
+
+// Orders returns the user's orders
+func (u *userResolver) Orders(ctx context.Context, args struct{ IncludeArchived bool }) ([]*orderResolver, error) {
+	return listOrders(ctx, fromContext(ctx).clients.Orders, u.user.Id, args.IncludeArchived)
+}
+
+// productResolver resolves the Product type
+type productResolver struct {
+	product *productv1.Product
+}
+
+func (p *productResolver) ID() graphql.ID          { return graphql.ID(p.product.Id) }
+func (p *productResolver) Name() string            { return p.product.Name }
+func (p *productResolver) SKU() string             { return p.product.Sku }
+func (p *productResolver) Kind() string            { return p.product.Kind }
+func (p *productResolver) Description() string     { return p.product.Description }
+func (p *productResolver) Price() float64          { return p.product.Price }
+func (p *productResolver) EffectivePrice() float64 { return p.product.EffectivePrice }
+func (p *productResolver) OnSale() bool            { return p.product.OnSale }
+func (p *productResolver) Currency() string        { return p.product.Currency }
+func (p *productResolver) CategoryID() string      { return p.product.CategoryId }
+func (p *productResolver) Status() string          { return p.product.Status }
+func (p *productResolver) Stock() int32            { return p.product.Stock }
+func (p *productResolver) MinOrderQty() int32      { return p.product.MinOrderQty }
+func (p *productResolver) MaxOrderQty() int32      { return p.product.MaxOrderQty }
+func (p *productResolver) WeightKg() float64       { return p.product.WeightKg }
+func (p *productResolver) AllowBackorder() bool    { return p.product.AllowBackorder }
+func (p *productResolver) CreatedAt() graphql.Time { return timeOf(p.product.CreatedAt) }
+func (p *productResolver) UpdatedAt() graphql.Time { return timeOf(p.product.UpdatedAt) }
+
+// orderResolver resolves the Order type
+type orderResolver struct {
+	order *orderv1.Order
+}
+
+func (o *orderResolver) ID() graphql.ID          { return graphql.ID(o.order.Id) }
+func (o *orderResolver) Subtotal() float64       { return o.order.Subtotal }
+func (o *orderResolver) Discount() float64       { return o.order.Discount }
+func (o *orderResolver) ShippingCost() float64   { return o.order.ShippingCost }
+func (o *orderResolver) Tax() float64            { return o.order.Tax }
+func (o *orderResolver) Total() float64          { return o.order.Total }
+func (o *orderResolver) Status() string          { return o.order.Status }
+func (o *orderResolver) PaymentStatus() string   { return o.order.PaymentStatus }
+func (o *orderResolver) Archived() bool          { return o.order.Archived }
+func (o *orderResolver) CreatedAt() graphql.Time { return timeOf(o.order.CreatedAt) }
+func (o *orderResolver) UpdatedAt() graphql.Time { return timeOf(o.order.UpdatedAt) }
+
+// User returns the user who placed the order, or nil for an unclaimed guest order
+func (o *orderResolver) User(ctx context.Context) (*userResolver, error) {
+	if o.order.UserId == "" {
+		return nil, nil
+	}
+	return loadUser(ctx, o.order.UserId)
+}
+
+// Items returns the order's lines
+func (o *orderResolver) Items() []*orderItemResolver {
+	items := make([]*orderItemResolver, len(o.order.Items))
+	for i, item := range o.order.Items {
+		items[i] = &orderItemResolver{item}
+	}
+	return items
+}
+
+// orderItemResolver resolves the OrderItem type
+type orderItemResolver struct {
+	item *orderv1.OrderItem
+}
+
+func (i *orderItemResolver) ProductID() graphql.ID { return graphql.ID(i.item.ProductId) }
+func (i *orderItemResolver) ProductName() string   { return i.item.ProductName }
+func (i *orderItemResolver) Price() float64        { return i.item.Price }
+func (i *orderItemResolver) Quantity() int32       { return i.item.Quantity }
+func (i *orderItemResolver) Subtotal() float64     { return i.item.Subtotal }
+func (i *orderItemResolver) Status() string        { return i.item.Status }
+
+// Product returns the product as it is now, or nil when it is no longer published
+func (i *orderItemResolver) Product(ctx context.Context, args struct{ Currency *string }) (*productResolver, error) {
+	return loadProduct(ctx, i.item.ProductId, args.Currency)
+}
+
+// loadUser loads a user with the query's other users, returning nil when there is no such user
+func loadUser(ctx context.Context, id string) (*userResolver, error) {
+	user, err := fromContext(ctx).users.Load(ctx, id)
+	if errors.Is(err, loader.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, serviceError(ctx, "user service", err)
+	}
+	return &userResolver{user}, nil
+}
+
+// loadProduct loads a product with the query's other products, returning nil when there is no
+// published product with that ID
+func loadProduct(ctx context.Context, id string, currency *string) (*productResolver, error) {
+	key := productKey{id: id}
+	if currency != nil {
+		key.currency = *currency
+	}
+	product, err := fromContext(ctx).products.Load(ctx, key)
+	if errors.Is(err, loader.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, serviceError(ctx, "product service", err)
+	}
+	return &productResolver{product}, nil
+}
+
+// listOrders returns a user's orders
+func listOrders(ctx context.Context, orders orderv1.OrderServiceClient, userID string, includeArchived bool) ([]*orderResolver, error) {
+	resp, err := orders.ListUserOrders(ctx, &orderv1.ListUserOrdersRequest{UserId: userID, IncludeArchived: includeArchived})
+	if err != nil {
+		return nil, serviceError(ctx, "order service", err)
+	}
+	resolvers := make([]*orderResolver, len(resp.Orders))
+	for i, order := range resp.Orders {
+		resolvers[i] = &orderResolver{order}
+	}
+	return resolvers, nil
+}
+
+// serviceError logs a failed call to a service and returns the error shown to the client. Invalid
+// arguments are passed on as the service worded them; other failures only name the service.
+func serviceError(ctx context.Context, service string, err error) error {
+	if status.Code(err) == codes.InvalidArgument {
+		return errors.New(status.Convert(err).Message())
+	}
+	slog.ErrorContext(ctx, "Error calling service", "dependency", service, "error", err)
+	return fmt.Errorf("%s is unavailable", service)
+}
+
+// timeOf converts a protobuf timestamp to a GraphQL Time
+func timeOf(ts *timestamppb.Timestamp) graphql.Time {
+	return graphql.Time{Time: ts.AsTime()}
+}
@@ -0,0 +1,113 @@
+// Package loader batches the lookups made while resolving a GraphQL query, so a query touching many
+// users or products asks each service once rather than once per object
+package loader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned for keys the batch function found nothing for
+var ErrNotFound = errors.New("not found")
+
+// DefaultWait is how long a loader collects keys before fetching them
+const DefaultWait = 2 * time.Millisecond
+
+// DefaultMaxBatch is how many keys a loader fetches in one call at most
+const DefaultMaxBatch = 100
+
+// BatchFunc fetches the values for keys in one call. Keys it has no value for are left out of the map.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// Loader collects the keys asked for within Wait of each other and fetches them with one call to its
+// batch function. Each key is fetched once, so a loader should live for one request: values are
+// cached for as long as the loader is.
+type Loader[K comparable, V any] struct {
+	fetch    BatchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	results map[K]*result[V]
+	pending []K
+}
+
+// result is the value fetched for a key, available once done is closed
+type result[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// New creates a loader that fetches with fetch, waiting DefaultWait for keys to batch and fetching at
+// most DefaultMaxBatch keys per call
+func New[K comparable, V any](fetch BatchFunc[K, V]) *Loader[K, V] {
+	return &Loader[K, V]{
+		fetch:    fetch,
+		wait:     DefaultWait,
+		maxBatch: DefaultMaxBatch,
+		results:  make(map[K]*result[V]),
+	}
+}
+
+// Load returns the value for key, fetching it with the other keys asked for around the same time.
+// It returns ErrNotFound when the batch function found nothing for key.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	r, exists := l.results[key]
+	if !exists {
+		r = &result[V]{done: make(chan struct{})}
+		l.results[key] = r
+		l.pending = append(l.pending, key)
+		switch len(l.pending) {
+		case l.maxBatch:
+			l.dispatch(ctx)
+		case 1:
+			time.AfterFunc(l.wait, func() {
+				l.mu.Lock()
+				defer l.mu.Unlock()
+				l.dispatch(ctx)
+			})
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// dispatch fetches the pending keys in the background. It must be called with mu held.
+func (l *Loader[K, V]) dispatch(ctx context.Context) {
+	if len(l.pending) == 0 {
+		return
+	}
+	keys := l.pending
+	l.pending = nil
+	results := make([]*result[V], len(keys))
+	for i, key := range keys {
+		results[i] = l.results[key]
+	}
+
+	go func() {
+		values, err := l.fetch(ctx, keys)
+		for i, key := range keys {
+			r := results[i]
+			switch value, found := values[key]; {
+			case err != nil:
+				r.err = err
+			case !found:
+				r.err = ErrNotFound
+			default:
+				r.value = value
+			}
+			close(r.done)
+		}
+	}()
+}
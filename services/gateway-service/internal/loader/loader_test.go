@@ -0,0 +1,92 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recorder is a batch function that upper-cases its keys, leaving out "missing", and records each batch
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (rec *recorder) fetch(ctx context.Context, keys []string) (map[string]string, error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	batch := append([]string(nil), keys...)
+	sort.Strings(batch)
+	rec.batches = append(rec.batches, batch)
+
+	values := make(map[string]string)
+	for _, key := range keys {
+		if key != "missing" {
+			values[key] = strings.ToUpper(key)
+		}
+	}
+	return values, nil
+}
+
+// loadAll loads every key at once, as sibling GraphQL fields resolve, and returns the values in order
+func loadAll(l *Loader[string, string], keys ...string) ([]string, []error) {
+	values, errs := make([]string, len(keys)), make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			values[i], errs[i] = l.Load(context.Background(), key)
+		}(i, key)
+	}
+	wg.Wait()
+	return values, errs
+}
+
+func TestLoader_BatchesAndDeduplicatesKeys(t *testing.T) {
+	rec := &recorder{}
+	l := New(rec.fetch)
+
+	values, errs := loadAll(l, "a", "b", "a", "missing")
+	if values[0] != "A" || values[1] != "B" || values[2] != "A" || errs[0] != nil {
+		t.Fatalf("expected the values fetched, got %v %v", values, errs)
+	}
+	if !errors.Is(errs[3], ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a missing key, got %v", errs[3])
+	}
+	if len(rec.batches) != 1 || strings.Join(rec.batches[0], ",") != "a,b,missing" {
+		t.Fatalf("expected one batch with each key once, got %v", rec.batches)
+	}
+
+	if value, err := l.Load(context.Background(), "b"); err != nil || value != "B" {
+		t.Fatalf("expected the cached value, got %q %v", value, err)
+	}
+	if len(rec.batches) != 1 {
+		t.Fatalf("expected a loaded key not fetched again, got %v", rec.batches)
+	}
+}
+
+func TestLoader_SplitsLargeBatches(t *testing.T) {
+	rec := &recorder{}
+	l := New(rec.fetch)
+	l.maxBatch = 2
+
+	if _, errs := loadAll(l, "a", "b", "c"); errs[0] != nil || errs[1] != nil || errs[2] != nil {
+		t.Fatalf("expected every key loaded, got %v", errs)
+	}
+	if len(rec.batches) != 2 {
+		t.Fatalf("expected the keys fetched in two batches, got %v", rec.batches)
+	}
+}
+
+func TestLoader_FailsEveryKeyOfAFailedBatch(t *testing.T) {
+	failure := errors.New("unavailable")
+	l := New(func(ctx context.Context, keys []string) (map[string]string, error) { return nil, failure })
+
+	if _, errs := loadAll(l, "a", "b"); !errors.Is(errs[0], failure) || !errors.Is(errs[1], failure) {
+		t.Fatalf("expected the batch's error for every key, got %v", errs)
+	}
+}
@@ -0,0 +1,128 @@
+// Package proxy forwards the gateway's REST traffic to the service that owns each path
+package proxy
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"ecommerce/pkg/api"
+	"ecommerce/pkg/middleware"
+	"ecommerce/pkg/requestid"
+)
+
+// Upstreams are the base URLs of the services requests are forwarded to
+type Upstreams struct {
+	Users    *url.URL
+	Products *url.URL
+	Orders   *url.URL
+	Carts    *url.URL
+	Shipping *url.URL
+	// Payments, when set, receives the payment provider's webhooks, for when payment service rather
+	// than order service takes payments; nil leaves them with order service
+	Payments *url.URL
+	// TLS is used to reach upstreams with https URLs, presenting the gateway's certificate; nil
+	// uses Go's defaults
+	TLS *tls.Config
+}
+
+// route sends requests whose path starts with prefix to one service
+type route struct {
+	prefix string
+	proxy  *httputil.ReverseProxy
+}
+
+// Proxy forwards each request to the service owning its path. Paths no service owns publicly, such as
+// /v1/internal/, get 404, so internal APIs can't be reached through the gateway.
+type Proxy struct {
+	routes []route
+}
+
+// New creates a proxy forwarding to upstreams
+func New(upstreams Upstreams) *Proxy {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = upstreams.TLS
+	users := newReverseProxy("user-service", upstreams.Users, transport)
+	products := newReverseProxy("product-service", upstreams.Products, transport)
+	orders := newReverseProxy("order-service", upstreams.Orders, transport)
+	carts := newReverseProxy("cart-service", upstreams.Carts, transport)
+	shipping := newReverseProxy("shipping-service", upstreams.Shipping, transport)
+
+	// Each service serves its own /v1/admin/config, so that one is left to be called on the service
+	routes := []route{
+		{"/v1/users", users},
+		{"/v1/auth/", users},
+		{"/v1/admin/users", users},
+		{"/v1/admin/service-keys", users},
+		{"/v1/admin/audit", users},
+		{"/v1/products", products},
+		{"/v1/categories", products},
+		{"/v1/tags", products},
+		{"/v1/warehouses", products},
+		{"/uploads/", products},
+		{"/v1/orders", orders},
+		{"/v1/coupons", orders},
+		{"/v1/webhooks", orders},
+		{"/v1/loyalty/", orders},
+		{"/v1/payments/", orders},
+		{"/v1/subscriptions", orders},
+		{"/v1/carts", carts},
+		{"/v1/rates", shipping},
+	}
+	if upstreams.Payments != nil {
+		// Checked before order service's /v1/payments/; payment service's other APIs are internal
+		webhook := route{"/v1/payments/webhook", newReverseProxy("payment-service", upstreams.Payments, transport)}
+		routes = append([]route{webhook}, routes...)
+	}
+	return &Proxy{routes: routes}
+}
+
+// ServeHTTP forwards the request to the service owning its path
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, route := range p.routes {
+		if owns(route.prefix, r.URL.Path) {
+			route.proxy.ServeHTTP(w, r)
+			return
+		}
+	}
+	api.WriteError(w, http.StatusNotFound, "Not found")
+}
+
+// owns reports whether path is prefix or below it; a prefix ending in / matches only below it
+func owns(prefix, path string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	rest := path[len(prefix):]
+	return strings.HasSuffix(prefix, "/") || rest == "" || rest[0] == '/'
+}
+
+// newReverseProxy creates a reverse proxy to one service. The request keeps its path and query, gains
+// X-Forwarded-For, -Host, and -Proto, and carries the gateway's request ID. The service's CORS headers
+// are dropped, since the gateway answers for CORS, as is its X-Service-Version, since the gateway names
+// its own build; GET /status names the services'. When the service can't be reached the client gets
+// 502.
+func newReverseProxy(service string, upstream *url.URL, transport http.RoundTripper) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Transport: transport,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(upstream)
+			pr.SetXForwarded()
+			pr.Out.Header.Set(requestid.Header, requestid.FromContext(pr.In.Context()))
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			for name := range resp.Header {
+				if strings.HasPrefix(name, "Access-Control-") || name == middleware.ServiceVersionHeader {
+					resp.Header.Del(name)
+				}
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.ErrorContext(r.Context(), "Error forwarding request", "dependency", service, "error", err)
+			api.WriteError(w, http.StatusBadGateway, service+" is unavailable")
+		},
+	}
+}
@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"ecommerce/pkg/requestid"
+)
+
+// newUpstream serves as a service named name, echoing what it received in headers
+func newUpstream(t *testing.T, name string) *url.URL {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Service", name)
+		w.Header().Set("X-Path", r.URL.RequestURI())
+		w.Header().Set("X-Got-Forwarded-For", r.Header.Get("X-Forwarded-For"))
+		w.Header().Set("X-Got-Request-ID", r.Header.Get(requestid.Header))
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	t.Cleanup(server.Close)
+	upstream, _ := url.Parse(server.URL)
+	return upstream
+}
+
+func TestProxy_ForwardsToTheServiceOwningThePath(t *testing.T) {
+	proxy := New(Upstreams{Users: newUpstream(t, "users"), Products: newUpstream(t, "products"), Orders: newUpstream(t, "orders"), Carts: newUpstream(t, "carts"), Shipping: newUpstream(t, "shipping")})
+
+	for path, want := range map[string]string{
+		"/v1/users/u1/addresses":    "users",
+		"/v1/auth/login":            "users",
+		"/v1/admin/users":           "users",
+		"/v1/products?category=art": "products",
+		"/uploads/p1/image.png":     "products",
+		"/v1/orders/o1/items":       "orders",
+		"/v1/payments/webhook":      "orders",
+		"/v1/carts/c1/checkout":     "carts",
+		"/v1/rates":                 "shipping",
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "203.0.113.9:4000"
+		req = req.WithContext(requestid.NewContext(req.Context(), "req-1"))
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusTeapot || rec.Header().Get("X-Service") != want {
+			t.Fatalf("%s: expected %s to answer, got %d from %q", path, want, rec.Code, rec.Header().Get("X-Service"))
+		}
+		if rec.Header().Get("X-Path") != path {
+			t.Fatalf("%s: expected the path and query kept, got %s", path, rec.Header().Get("X-Path"))
+		}
+		if rec.Header().Get("X-Got-Forwarded-For") != "203.0.113.9" || rec.Header().Get("X-Got-Request-ID") != "req-1" {
+			t.Fatalf("%s: expected the client address and request ID forwarded, got %v", path, rec.Header())
+		}
+		if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Fatalf("%s: expected the service's CORS headers dropped", path)
+		}
+	}
+}
+
+func TestProxy_SendsPaymentWebhooksToPaymentService(t *testing.T) {
+	proxy := New(Upstreams{Users: newUpstream(t, "users"), Products: newUpstream(t, "products"), Orders: newUpstream(t, "orders"), Carts: newUpstream(t, "carts"), Shipping: newUpstream(t, "shipping"), Payments: newUpstream(t, "payments")})
+
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/payments/webhook", nil))
+	if rec.Header().Get("X-Service") != "payments" {
+		t.Fatalf("expected payment service to get the webhook, got %q", rec.Header().Get("X-Service"))
+	}
+}
+
+func TestProxy_HidesPathsNoServiceOwnsPublicly(t *testing.T) {
+	proxy := New(Upstreams{Users: newUpstream(t, "users"), Products: newUpstream(t, "products"), Orders: newUpstream(t, "orders"), Carts: newUpstream(t, "carts"), Shipping: newUpstream(t, "shipping")})
+
+	for _, path := range []string{"/v1/internal/service-keys/verify", "/v1/admin/config", "/v1/shipments", "/v1/usersettings", "/metrics"} {
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestProxy_ReportsUnreachableServices(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	down, _ := url.Parse(server.URL)
+	server.Close()
+	proxy := New(Upstreams{Users: down, Products: down, Orders: down, Carts: down, Shipping: down})
+
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/orders", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d", rec.Code)
+	}
+}
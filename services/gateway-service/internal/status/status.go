@@ -0,0 +1,152 @@
+// Package status answers the gateway's GET /status, one view of every service's health for
+// dashboards and uptime checks
+package status
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+	"ecommerce/pkg/api"
+	"ecommerce/pkg/buildinfo"
+	"ecommerce/pkg/health"
+	"ecommerce/pkg/requestid"
+)
+
+// Service is a service whose readiness probe is checked, at /readyz below URL
+type Service struct {
+	Name string
+	URL  *url.URL
+}
+
+// ServiceStatus is what one service's readiness probe answered and how long it took
+type ServiceStatus struct {
+	Status string `json:"status"`
+	buildinfo.Info
+	LatencyMS    float64                            `json:"latency_ms"`
+	Error        string                             `json:"error,omitempty"`
+	Dependencies map[string]health.DependencyStatus `json:"dependencies,omitempty"`
+}
+
+// Report is the consolidated view: the gateway's own build, and the status of every service, which
+// is up only while all of them are
+type Report struct {
+	Service string `json:"service"`
+	buildinfo.Info
+	Status   string                   `json:"status"`
+	Services map[string]ServiceStatus `json:"services"`
+}
+
+// Aggregator checks every service's readiness probe at once
+type Aggregator struct {
+	service  string
+	services []Service
+	timeout  time.Duration
+	client   *http.Client
+}
+
+// New creates an aggregator for the gateway named service that waits up to timeout for each of
+// services. Services with https URLs are called with tlsConfig, presenting the gateway's certificate.
+func New(service string, services []Service, tlsConfig *tls.Config, timeout time.Duration) *Aggregator {
+	if timeout <= 0 {
+		timeout = health.DefaultTimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &Aggregator{service: service, services: services, timeout: timeout, client: &http.Client{Transport: transport}}
+}
+
+// ServeHTTP handles GET /status, answering 503 with the whole report when any service is down
+func (a *Aggregator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := a.Check(r.Context())
+	if report.Status != health.StatusUp {
+		api.WriteJSON(w, http.StatusServiceUnavailable, api.Response[api.Unpaged]{
+			Success: false,
+			Error:   "Some services are down",
+			Data:    report,
+		})
+		return
+	}
+
+	api.WriteJSON(w, http.StatusOK, api.Response[api.Unpaged]{
+		Success: true,
+		Message: "All services are up",
+		Data:    report,
+	})
+}
+
+// Check probes every service at once, each bounded by the aggregator's timeout
+func (a *Aggregator) Check(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	report := Report{Service: a.service, Info: buildinfo.Get(), Status: health.StatusUp, Services: make(map[string]ServiceStatus, len(a.services))}
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+	)
+	for _, service := range a.services {
+		wg.Add(1)
+		go func(service Service) {
+			defer wg.Done()
+			status := a.probe(ctx, service.URL)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			report.Services[service.Name] = status
+			if status.Status != health.StatusUp {
+				report.Status = health.StatusDown
+			}
+		}(service)
+	}
+	wg.Wait()
+	return report
+}
+
+// probe calls one service's readiness probe. A service that can't be reached, or answers with
+// something other than a probe report, is down.
+func (a *Aggregator) probe(ctx context.Context, base *url.URL) ServiceStatus {
+	start := time.Now()
+	down := func(err error) ServiceStatus {
+		return ServiceStatus{Status: health.StatusDown, LatencyMS: latencyMS(start), Error: err.Error()}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.JoinPath("readyz").String(), nil)
+	if err != nil {
+		return down(err)
+	}
+	req.Header.Set(requestid.Header, requestid.FromContext(ctx))
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return down(err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Error string        `json:"error"`
+		Data  health.Report `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Data.Status == "" {
+		return down(fmt.Errorf("unexpected answer with status %d", resp.StatusCode))
+	}
+	status := ServiceStatus{
+		Status:       body.Data.Status,
+		Info:         body.Data.Info,
+		LatencyMS:    latencyMS(start),
+		Error:        body.Error,
+		Dependencies: body.Data.Dependencies,
+	}
+	if resp.StatusCode != http.StatusOK {
+		status.Status = health.StatusDown
+	}
+	return status
+}
+
+// latencyMS is the time since start in milliseconds
+func latencyMS(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
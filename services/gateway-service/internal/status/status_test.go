@@ -0,0 +1,85 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+	"ecommerce/pkg/health"
+)
+
+// newService serves a readiness probe answering as checker does, returning the service to probe
+func newService(t *testing.T, name string, checker *health.Checker) Service {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/readyz" {
+			http.NotFound(w, r)
+			return
+		}
+		checker.Readiness(w, r)
+	}))
+	t.Cleanup(server.Close)
+	base, _ := url.Parse(server.URL)
+	return Service{Name: name, URL: base}
+}
+
+func status(t *testing.T, aggregator *Aggregator) (int, Report) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	aggregator.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var response struct {
+		Data Report `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode %s: %v", rec.Body.String(), err)
+	}
+	return rec.Code, response.Data
+}
+
+func TestAggregator_UpWhenEveryServiceIsReady(t *testing.T) {
+	users := newService(t, "user_service", health.NewChecker("user-service", 0))
+	products := newService(t, "product_service", health.NewChecker("product-service", 0))
+
+	code, report := status(t, New("gateway-service", []Service{users, products}, nil, time.Second))
+	if code != http.StatusOK || report.Status != health.StatusUp || report.Version == "" {
+		t.Fatalf("expected every service up, got %d %+v", code, report)
+	}
+	if len(report.Services) != 2 || report.Services["user_service"].Status != health.StatusUp || report.Services["product_service"].Version == "" {
+		t.Errorf("expected each service's status and version, got %+v", report.Services)
+	}
+}
+
+func TestAggregator_DownWhenAnyServiceIsNot(t *testing.T) {
+	users := newService(t, "user_service", health.NewChecker("user-service", 0))
+	orderChecker := health.NewChecker("order-service", time.Second)
+	orderChecker.Register("database", func(ctx context.Context) error { return errors.New("connection refused") })
+	orders := newService(t, "order_service", orderChecker)
+	unreachable, _ := url.Parse("http://127.0.0.1:1")
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer slow.Close()
+	slowURL, _ := url.Parse(slow.URL)
+
+	aggregator := New("gateway-service", []Service{users, orders, {Name: "product_service", URL: unreachable}, {Name: "search", URL: slowURL}}, nil, 50*time.Millisecond)
+	code, report := status(t, aggregator)
+	if code != http.StatusServiceUnavailable || report.Status != health.StatusDown {
+		t.Fatalf("expected 503 with the services down, got %d %+v", code, report)
+	}
+	if report.Services["user_service"].Status != health.StatusUp {
+		t.Errorf("expected user_service up, got %+v", report.Services["user_service"])
+	}
+	if order := report.Services["order_service"]; order.Status != health.StatusDown || order.Dependencies["database"].Status != health.StatusDown {
+		t.Errorf("expected order_service down with its failing dependency, got %+v", order)
+	}
+	if product := report.Services["product_service"]; product.Status != health.StatusDown || product.Error == "" {
+		t.Errorf("expected an unreachable product_service down, got %+v", product)
+	}
+	if search := report.Services["search"]; search.Status != health.StatusDown || search.LatencyMS < 50 {
+		t.Errorf("expected a service that doesn't answer down after the timeout, got %+v", search)
+	}
+}
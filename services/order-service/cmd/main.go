@@ -2,60 +2,327 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	"ecommerce/pkg/auth"
+	"ecommerce/pkg/cache"
+	"ecommerce/pkg/config"
+	"ecommerce/pkg/diagnostics"
+	"ecommerce/pkg/events"
+	"ecommerce/pkg/health"
+	"ecommerce/pkg/jobs"
+	"ecommerce/pkg/logging"
+	"ecommerce/pkg/metrics"
+	"ecommerce/pkg/middleware"
+	"ecommerce/pkg/mongodb"
+	"ecommerce/pkg/openapi"
+	"ecommerce/pkg/postgres"
+	"ecommerce/pkg/rpc"
+	"ecommerce/pkg/snapshot"
+	orderv1 "ecommerce/pkg/proto/order/v1"
+	"order-service/internal/carrier"
 	"order-service/internal/client"
+	"order-service/internal/consumer"
+	"order-service/internal/fraud"
+	"order-service/internal/fulfillment"
 	"order-service/internal/handlers"
+	"order-service/internal/loyalty"
+	"order-service/internal/models"
+	"order-service/internal/outbox"
+	"order-service/internal/payment"
+	"order-service/internal/reports"
 	"order-service/internal/repository"
+	"order-service/internal/shipping"
+	"order-service/internal/tax"
+	"order-service/internal/webhook"
 
 	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
 )
 
 func main() {
+	// Settings come from flags, the environment, and the YAML file named by -config or CONFIG_FILE
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		logging.Fatal("Failed to load configuration", "error", err)
+	}
+	logging.Setup(cfg.String("SERVICE_NAME", "order-service"))
+	serverConfig := cfg.Server(8083)
+
+	// Settings shown as reloadable at /admin/config are read again on SIGHUP
+	reloader := config.NewReloader(cfg)
+	reloader.Register(config.TuneLogLevel)
+	reloader.Register(config.TuneMaxBodyBytes)
+
+	// With TLS_CERT_FILE, the REST and gRPC APIs are served over mutual TLS, and calls to other services
+	// present the same certificate; the files are read again on SIGHUP, so rotated ones take effect
+	certs := reloader.TLS()
+
 	// Initialize repository
-	orderRepo := repository.NewInMemoryOrderRepository()
+	orderRepo := setupOrderRepository(cfg)
+	stopSnapshots := setupSnapshots(cfg, orderRepo)
+	seedOrders(cfg, orderRepo)
+	metrics.NewGaugeFunc("orders_stored", "Orders in the repository", func() float64 {
+		count, err := orderRepo.Count(context.Background())
+		if err != nil {
+			slog.Error("Error counting orders", "error", err)
+		}
+		return float64(count)
+	})
 
 	// Initialize service client for inter-service communication
-	// In production, these URLs would come from service discovery or environment variables
-	userServiceURL := "http://localhost:8081"
-	productServiceURL := "http://localhost:8082"
-	serviceClient := client.NewServiceClient(userServiceURL, productServiceURL)
+	// In production, these URLs would come from service discovery
+	serviceClient := client.NewServiceClient("http://localhost:8081", "http://localhost:8082", cfg.String("SERVICE_KEY", ""), breakerSettings(cfg), balancerSettings(cfg), httpSettings(cfg, certs))
+	// Users and products are looked up over gRPC at USER_SERVICE_GRPC_ADDR and PRODUCT_SERVICE_GRPC_ADDR;
+	// setting either to "" sends those lookups over REST instead
+	serviceClient.UseGRPC(dialService(cfg, "USER_SERVICE_GRPC_ADDR", "localhost:9081", certs), dialService(cfg, "PRODUCT_SERVICE_GRPC_ADDR", "localhost:9082", certs))
+	serviceClient.HedgeProductLookups(hedgeSettings(cfg))
+	expvar.Publish("circuit_breakers", expvar.Func(func() interface{} { return serviceClient.BreakerStates() }))
+	expvar.Publish("service_instances", expvar.Func(func() interface{} { return serviceClient.InstanceStates() }))
+	validationClient := setupValidationClient(cfg, serviceClient)
+
+	// Service keys presented by other services are verified with the user service
+	serviceKeys := auth.NewServiceKeyVerifier("http://localhost:8081", time.Minute)
+	if certs != nil {
+		serviceKeys.UseTLS(certs.ClientConfig())
+	}
+	// USER_SERVICE_URL and PRODUCT_SERVICE_URL may each list several instances, which calls are spread across
+	reloader.Register(func(cfg *config.Config) func() {
+		userServiceURLs := serviceURLs(cfg, "USER_SERVICE_URL", "http://localhost:8081")
+		productServiceURLs := serviceURLs(cfg, "PRODUCT_SERVICE_URL", "http://localhost:8082")
+		return func() {
+			serviceClient.SetURLs(userServiceURLs, productServiceURLs)
+			serviceKeys.SetUserServiceURL(userServiceURLs[0])
+		}
+	})
+
+	// Shipping is priced with the default rates, as domestic when the order ships within SHIPPING_ORIGIN_COUNTRY
+	shippingCosts := shipping.NewCalculator(cfg.String("SHIPPING_ORIGIN_COUNTRY", "US"), shipping.DefaultRates)
+
+	// Orders are taxed at a flat TAX_RATE (0.2 for 20%) until a regional or external calculator is plugged in
+	taxes, err := tax.NewFlatRateCalculator(cfg.Float("TAX_RATE", 0))
+	if err != nil {
+		logging.Fatal("Invalid TAX_RATE", "error", err)
+	}
+
+	// Digital items get a signed download link when their order is confirmed
+	downloads := setupDownloadIssuer(cfg)
+
+	// Payments are taken through the provider named by PAYMENT_PROVIDER
+	payments := setupPaymentProvider(cfg, certs)
+
+	// Order events are delivered to webhook subscribers in the background
+	webhookRepo := repository.NewInMemoryWebhookRepository()
+	webhooks := webhook.NewDispatcher(webhookRepo, webhook.DefaultMaxAttempts, webhook.DefaultRetryDelay)
+
+	// Admin reports are served from read models built from the stored orders, then kept up to date
+	// from each published order event
+	orderReports := reports.New()
+	storedOrders, _, err := orderRepo.List(context.Background(), &models.OrderFilter{IncludeArchived: true})
+	if err != nil {
+		logging.Fatal("Failed to build order reports", "error", err)
+	}
+	orderReports.Rebuild(storedOrders)
+
+	// Order events written to the outbox are relayed to the broker named by BROKER, then to webhooks
+	// and the reports
+	relay := outbox.NewRelay(orderRepo, setupBroker(cfg), webhooks, orderReports)
+
+	// Background jobs run on a scheduler; with JOB_ELECTION, jobs over the shared orders run on one
+	// instance at a time, while each instance relays its outbox and refreshes its own reports
+	scheduler := jobs.NewScheduler(setupElector(cfg, orderRepo))
+	scheduler.Add(jobs.Job{Name: "relay-outbox", Schedule: jobs.Every(time.Second), Run: relayOutbox(relay), EveryInstance: true})
+
+	// Reports are rebuilt from the stored orders every REPORT_REFRESH_INTERVAL, picking up events
+	// relayed by other instances; 0 turns the refresh off
+	if interval := cfg.Duration("REPORT_REFRESH_INTERVAL", DefaultReportRefreshInterval, config.NonNegative); interval > 0 {
+		scheduler.Add(jobs.Job{Name: "refresh-reports", Schedule: jobs.Every(interval), Run: refreshReports(orderRepo, orderReports), EveryInstance: true})
+	}
+
+	// Parcels are tracked with the API named by TRACKING_PROVIDER
+	tracker := setupTracker(cfg)
+
+	// Coupons are managed by other services and applied at checkout
+	couponRepo := repository.NewInMemoryCouponRepository()
+
+	// Orders earn LOYALTY_POINTS_PER_UNIT points per unit of currency, and each point redeemed takes
+	// LOYALTY_POINT_VALUE off a later order
+	loyaltyProgram := setupLoyaltyProgram(cfg)
+
+	// New orders that break the fraud rules are held for review
+	fraudChecker := setupFraudChecker(cfg, orderRepo)
 
 	// Initialize handlers
-	orderHandler := handlers.NewOrderHandler(orderRepo, serviceClient)
+	orderHandler := handlers.NewOrderHandler(orderRepo, validationClient, payments, shippingCosts, taxes, downloads, couponRepo, loyaltyProgram, fraudChecker)
+	// With RELEASE_STOCK_BY_EVENT, product service releases a cancelled order's stock when it consumes
+	// order.cancelled, so cancelling doesn't wait on it; the events must then go through Kafka
+	if cfg.Bool("RELEASE_STOCK_BY_EVENT", false) {
+		if cfg.String("BROKER", "log") != "kafka-rest" {
+			logging.Fatal("Invalid configuration: RELEASE_STOCK_BY_EVENT requires BROKER=kafka-rest")
+		}
+		orderHandler.ReleaseStockByEvent()
+	}
+	// With PAYMENT_PROVIDER=service, payments that payment service settles later, and refunds made there,
+	// arrive as payment events through the broker named by BROKER
+	consumeCtx, stopConsuming := context.WithCancel(context.Background())
+	if _, ok := payments.(*payment.ServiceProvider); ok {
+		if subscriber := setupSubscriber(cfg); subscriber != nil {
+			topic := cfg.String("PAYMENT_EVENTS_TOPIC", events.DefaultPaymentTopic)
+			go subscriber.Consume(consumeCtx, "order-service", topic, consumer.NewPaymentEvents(orderHandler).Handle)
+		}
+	}
+	webhookHandler := handlers.NewWebhookHandler(webhookRepo)
+	couponHandler := handlers.NewCouponHandler(couponRepo)
+	trackingHandler := handlers.NewTrackingHandler(orderRepo, tracker)
+	subscriptionHandler := handlers.NewSubscriptionHandler(repository.NewInMemorySubscriptionRepository(), orderHandler)
+	loyaltyHandler := handlers.NewLoyaltyHandler(loyaltyProgram)
+	reportHandler := handlers.NewReportHandler(orderReports)
+
+	// Unpaid orders left pending longer than PENDING_ORDER_TTL are cancelled; 0 turns expiry off
+	pendingOrderTTL := cfg.Duration("PENDING_ORDER_TTL", DefaultPendingOrderTTL, config.NonNegative)
+	if pendingOrderTTL > 0 {
+		scheduler.Add(jobs.Job{Name: "expire-pending-orders", Schedule: jobs.Every(time.Minute), Run: expirePendingOrders(orderHandler, pendingOrderTTL)})
+	}
+
+	// Delivered orders are archived after ORDER_RETENTION and deleted ARCHIVED_ORDER_RETENTION later; 0 turns either off
+	orderRetention := cfg.Duration("ORDER_RETENTION", DefaultOrderRetention, config.NonNegative)
+	archivedOrderRetention := cfg.Duration("ARCHIVED_ORDER_RETENTION", DefaultArchivedOrderRetention, config.NonNegative)
+	if orderRetention > 0 || archivedOrderRetention > 0 {
+		scheduler.Add(jobs.Job{Name: "archive-orders", Schedule: jobs.Every(time.Hour), Run: archiveOrders(orderHandler, orderRetention, archivedOrderRetention)})
+	}
+
+	// Subscriptions place their orders as each cycle falls due. Subscriptions are kept in memory,
+	// so every instance runs its own.
+	scheduler.Add(jobs.Job{Name: "run-subscriptions", Schedule: jobs.Every(time.Minute), Run: runSubscriptions(subscriptionHandler), EveryInstance: true})
+
+	// Backordered items are given stock as it arrives, checked every BACKORDER_ALLOCATION_INTERVAL
+	backorderInterval := cfg.Duration("BACKORDER_ALLOCATION_INTERVAL", DefaultBackorderAllocationInterval, config.Positive)
+	scheduler.Add(jobs.Job{Name: "allocate-backorders", Schedule: jobs.Every(backorderInterval), Run: allocateBackorders(orderHandler)})
+
+	// Parcels still on their way are checked with the carrier every TRACKING_REFRESH_INTERVAL
+	if tracker != nil {
+		trackingInterval := cfg.Duration("TRACKING_REFRESH_INTERVAL", DefaultTrackingRefreshInterval, config.Positive)
+		scheduler.Add(jobs.Job{Name: "refresh-tracking", Schedule: jobs.Every(trackingInterval), Run: refreshTracking(trackingHandler)})
+	}
+	scheduler.Start()
+
+	// Orders can't be placed without user and product service, so readiness checks both,
+	// waiting up to READINESS_TIMEOUT for each
+	probes := health.NewChecker("order-service", cfg.Duration("READINESS_TIMEOUT", health.DefaultTimeout, config.NonNegative))
+	probes.Register("user_service", serviceClient.PingUserService)
+	probes.Register("product_service", serviceClient.PingProductService)
+	switch database := orderRepo.(type) {
+	case *repository.PostgresOrderRepository:
+		probes.Register("postgres", database.Ping)
+	case *repository.MongoOrderRepository:
+		probes.Register("mongodb", database.Ping)
+	}
 
 	// Setup routes
-	router := setupRoutes(orderHandler)
+	router := setupRoutes(serverConfig.CORSOrigins, reloader, serviceKeys, probes, orderHandler, webhookHandler, couponHandler, trackingHandler, subscriptionHandler, loyaltyHandler, reportHandler)
+
+	// Other services look orders up over gRPC, next to the REST API
+	grpcServer := rpc.NewServer(serviceKeys.AuthenticateCall, certs.ServerConfig())
+	orderv1.RegisterOrderServiceServer(grpcServer, handlers.NewOrderServer(orderRepo))
 
-	// Configure server
+	// Stop before serving if any setting was invalid, listing every problem at once
+	if err := cfg.Err(); err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+	for _, key := range cfg.Unused() {
+		slog.Warn("Setting is not used", "key", key)
+	}
+	go reloader.Watch(context.Background())
+
+	// CPU and heap profiles and runtime variables are served on DEBUG_ADDR, an internal port apart
+	// from the API, when it is set
+	debugServer, err := diagnostics.Serve(serverConfig.DebugAddr)
+	if err != nil {
+		logging.Fatal("Diagnostics port failed to start", "error", err)
+	}
+
+	// Configure server; requests through the proxies in TRUSTED_PROXIES, such as the gateway, are
+	// attributed to the client they came from
 	server := &http.Server{
-		Addr:         ":8083",
-		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:         serverConfig.Addr(),
+		Handler:      middleware.TrustProxies(serverConfig.TrustedProxies)(middleware.Versioned(router, "v1", "v1")),
+		ReadTimeout:  serverConfig.ReadTimeout,
+		WriteTimeout: serverConfig.WriteTimeout,
+		IdleTimeout:  serverConfig.IdleTimeout,
 	}
 
 	// Start server in a goroutine
 	go func() {
-		log.Println("🚀 Order Service starting on port 8083...")
-		log.Println("📚 API Documentation:")
-		log.Println("  POST  /orders              - Create order")
-		log.Println("  GET   /orders/{id}         - Get order by ID")
-		log.Println("  GET   /orders/user/{id}    - Get orders by user")
-		log.Println("  PATCH /orders/{id}/status  - Update order status")
-		log.Println("  GET   /orders              - List all orders")
-		log.Println("  GET   /health              - Health check")
-		log.Println("---")
-		log.Printf("🔗 Connected to User Service: %s", userServiceURL)
-		log.Printf("🔗 Connected to Product Service: %s", productServiceURL)
-
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed to start: %v", err)
+		slog.Info("🚀 Order Service starting", "port", serverConfig.Port)
+		slog.Info("📚 API Documentation:")
+		slog.Info("  POST  /orders              - Create order")
+		slog.Info("  GET   /orders/{id}         - Get order by ID")
+		slog.Info("  GET   /orders/user/{id}    - Get orders by user")
+		slog.Info("  GET   /orders/user/{id}/stats - Get a user's lifetime spend, order count, and top products")
+		slog.Info("  POST  /orders/user/{id}/anonymize - Anonymize a user's orders (internal)")
+		slog.Info("  PATCH /orders/{id}/status  - Update order status")
+		slog.Info("  PATCH /orders/{id}/items   - Change a pending order's items")
+		slog.Info("  POST  /orders/{id}/restore - Bring an archived order back into listings (internal)")
+		slog.Info("  POST  /orders/{id}/review/approve|reject - Approve or reject an order held for fraud review (internal)")
+		slog.Info("  GET   /orders/{id}/history - Get order status history")
+		slog.Info("  POST  /orders/{id}/notes   - Add an internal note to an order (internal)")
+		slog.Info("  GET   /orders/{id}/notes   - List an order's internal notes (internal)")
+		slog.Info("  GET   /orders/{id}/invoice - Download the order's invoice (PDF, or ?format=html)")
+		slog.Info("  POST  /orders/{id}/shipments - Ship some or all of an order's items")
+		slog.Info("  PATCH /orders/{id}/shipments/{shipment_id} - Mark a shipment delivered")
+		slog.Info("  PATCH /orders/{id}/tracking - Update a shipment's carrier, tracking number, and ETA")
+		slog.Info("  POST  /orders/{id}/pay     - Pay for an order")
+		slog.Info("  POST  /orders/{id}/claim   - Link a guest order to an account")
+		slog.Info("  POST  /payments/webhook    - Payment provider notifications")
+		slog.Info("  GET   /orders              - List orders (filter by status, user_id, from/to; paginated)")
+		slog.Info("  GET   /orders/export       - Export orders as CSV or JSON, one line per item (internal)")
+		slog.Info("  GET   /internal/purchases  - Check if a user bought a product (internal)")
+		slog.Info("  POST  /internal/orders/{id}/shipments/{shipment_id}/tracking - Record a carrier's report on a shipment (internal)")
+		slog.Info("  GET   /metrics             - Prometheus metrics")
+		slog.Info("  GET   /admin/config        - Settings in effect; reloadable ones are re-read on SIGHUP (internal)")
+		slog.Info("  GET   /admin/reports/order-counts - Orders in each status (internal)")
+		slog.Info("  GET   /admin/reports/orders?status= - Orders in a status, newest first (internal)")
+		slog.Info("  GET   /admin/reports/revenue  - Revenue by day, from and to as YYYY-MM-DD (internal)")
+		slog.Info("  GET   /admin/reports/top-products - Products sold in the most units (internal)")
+		slog.Info("  POST  /webhooks            - Subscribe to order events (internal)")
+		slog.Info("  GET   /webhooks            - List webhook subscriptions (internal)")
+		slog.Info("  GET   /webhooks/{id}       - Get a webhook subscription (internal)")
+		slog.Info("  DELETE /webhooks/{id}      - Delete a webhook subscription (internal)")
+		slog.Info("  GET   /webhooks/{id}/deliveries - Webhook delivery log (internal)")
+		slog.Info("  POST  /subscriptions       - Subscribe to a recurring order")
+		slog.Info("  GET   /subscriptions/{id}  - Get a subscription")
+		slog.Info("  GET   /subscriptions/user/{id} - Get a user's subscriptions")
+		slog.Info("  POST  /subscriptions/{id}/pause|resume|cancel - Pause, resume, or cancel a subscription")
+		slog.Info("  GET   /loyalty/{user_id}   - Get a user's loyalty points balance and history")
+		slog.Info("  POST  /coupons             - Create a coupon (internal)")
+		slog.Info("  GET   /coupons             - List coupons (internal)")
+		slog.Info("  GET   /coupons/{code}      - Get a coupon (internal)")
+		slog.Info("  DELETE /coupons/{code}     - Delete a coupon (internal)")
+		slog.Info("  GET   /healthz             - Liveness probe")
+		slog.Info("  GET   /readyz              - Readiness probe, checking user and product service")
+		slog.Info("---")
+		slog.Info("🔗 Connected to User Service", "user_service_urls", serviceClient.UserServiceURLs())
+		slog.Info("🔗 Connected to Product Service", "product_service_urls", serviceClient.ProductServiceURLs())
+
+		if err := certs.ListenAndServe(server); err != nil && err != http.ErrServerClosed {
+			logging.Fatal("Server failed to start", "error", err)
+		}
+	}()
+
+	go func() {
+		slog.Info("🚀 Order Service gRPC API starting", "port", serverConfig.GRPCPort)
+		slog.Info("  ecommerce.order.v1.OrderService/GetOrder       - Get order by ID")
+		slog.Info("  ecommerce.order.v1.OrderService/ListUserOrders - Get a user's orders")
+		slog.Info("  ecommerce.order.v1.OrderService/CheckPurchase  - Check if a user bought a product (internal)")
+		if err := rpc.Serve(grpcServer, serverConfig.GRPCPort); err != nil {
+			logging.Fatal("gRPC server failed to start", "error", err)
 		}
 	}()
 
@@ -64,77 +331,640 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("🛑 Shutting down Order Service...")
+	slog.Info("🛑 Shutting down Order Service...")
 
 	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), serverConfig.ShutdownTimeout)
 	defer cancel()
 
+	stopConsuming()
+	rpc.Shutdown(ctx, grpcServer)
+	debugServer.Close()
 	if err := server.Shutdown(ctx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
+		slog.Error("Server forced to shutdown", "error", err)
 	} else {
-		log.Println("✅ Order Service shutdown complete")
+		slog.Info("✅ Order Service shutdown complete")
+	}
+
+	// With no more requests coming in, background work gets DRAIN_TIMEOUT to wind down: job runs
+	// under way finish, what the last requests wrote to the outbox is published, and webhook
+	// deliveries make their last attempt. Whatever is cut off is picked up again on restart, as events
+	// stay in the outbox until published.
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), serverConfig.DrainTimeout)
+	defer cancelDrain()
+	if err := scheduler.Shutdown(drainCtx); err != nil {
+		slog.Error("Job runs cut short at shutdown", "error", err)
+	}
+	if err := relay.Drain(drainCtx); err != nil {
+		slog.Error("Error publishing order events", "error", err)
+	}
+	stopSnapshots()
+	if err := webhooks.Drain(drainCtx); err != nil {
+		slog.Error("Webhook deliveries dropped at shutdown", "error", err)
 	}
 }
 
 // setupRoutes configures all the HTTP routes
-func setupRoutes(orderHandler *handlers.OrderHandler) *mux.Router {
+func setupRoutes(corsOrigins []string, reloader *config.Reloader, serviceKeys *auth.ServiceKeyVerifier, probes *health.Checker, orderHandler *handlers.OrderHandler, webhookHandler *handlers.WebhookHandler, couponHandler *handlers.CouponHandler, trackingHandler *handlers.TrackingHandler, subscriptionHandler *handlers.SubscriptionHandler, loyaltyHandler *handlers.LoyaltyHandler, reportHandler *handlers.ReportHandler) *mux.Router {
 	router := mux.NewRouter()
 
 	// Add CORS middleware
-	router.Use(corsMiddleware)
+	router.Use(middleware.CORS(corsOrigins))
 	
+	// Tag each request with an ID for the logs and calls to other services
+	router.Use(middleware.RequestID)
+
+	// Name the running build on every response
+	router.Use(middleware.ServiceVersion)
+
+	// Send errors as problem details to clients that ask for application/problem+json
+	router.Use(middleware.ProblemDetails)
+
 	// Add logging middleware
-	router.Use(loggingMiddleware)
+	router.Use(middleware.Logging)
+
+	// Count and time requests by route for /metrics
+	router.Use(middleware.Metrics)
 
-	// API routes
-	api := router.PathPrefix("/").Subrouter()
+	// Resolve service API keys for internal calls
+	router.Use(serviceKeys.Authenticate)
+
+	// Limit how fast each client may call: end users per IP, other services per service
+	router.Use(middleware.RateLimit("global", middleware.RateLimiter(reloader, "RATE_LIMIT", 100, 600), middleware.RateLimiter(reloader, "RATE_LIMIT_SERVICE", 1000, 6000), auth.ServiceFromContext))
+
+	// API routes live under a version prefix; operational endpoints stay at the root
+	v1 := router.PathPrefix("/v1").Subrouter()
+
+	// Turn API requests away with 503 once too many are in flight, rather than letting a spike pile up
+	v1.Use(middleware.LoadShedder(reloader).Middleware)
 
 	// Order routes
-	api.HandleFunc("/orders", orderHandler.CreateOrder).Methods("POST")
-	api.HandleFunc("/orders", orderHandler.ListOrders).Methods("GET")
-	api.HandleFunc("/orders/{id}", orderHandler.GetOrder).Methods("GET")
-	api.HandleFunc("/orders/user/{user_id}", orderHandler.GetUserOrders).Methods("GET")
-	api.HandleFunc("/orders/{id}/status", orderHandler.UpdateOrderStatus).Methods("PATCH")
+	checkoutLimit := middleware.RateLimit("checkout", middleware.RateLimiter(reloader, "RATE_LIMIT_CHECKOUT", 10, 30), nil, auth.ServiceFromContext)
+	v1.Handle("/orders", checkoutLimit(http.HandlerFunc(orderHandler.CreateOrder))).Methods("POST")
+	v1.HandleFunc("/orders", orderHandler.ListOrders).Methods("GET")
+	v1.Handle("/orders/export", serviceKeys.RequireService(http.HandlerFunc(orderHandler.ExportOrders))).Methods("GET")
+	v1.HandleFunc("/orders/{id}", orderHandler.GetOrder).Methods("GET")
+	v1.HandleFunc("/orders/user/{user_id}", orderHandler.GetUserOrders).Methods("GET")
+	v1.HandleFunc("/orders/user/{user_id}/stats", orderHandler.GetUserOrderStats).Methods("GET")
+	v1.Handle("/orders/user/{user_id}/anonymize", serviceKeys.RequireService(http.HandlerFunc(orderHandler.AnonymizeUserOrders))).Methods("POST")
+	v1.HandleFunc("/orders/{id}/status", orderHandler.UpdateOrderStatus).Methods("PATCH")
+	v1.HandleFunc("/orders/{id}/items", orderHandler.AmendOrderItems).Methods("PATCH")
+	v1.Handle("/orders/{id}/restore", serviceKeys.RequireService(http.HandlerFunc(orderHandler.RestoreOrder))).Methods("POST")
+	v1.Handle("/orders/{id}/review/approve", serviceKeys.RequireService(http.HandlerFunc(orderHandler.ApproveOrder))).Methods("POST")
+	v1.Handle("/orders/{id}/review/reject", serviceKeys.RequireService(http.HandlerFunc(orderHandler.RejectOrder))).Methods("POST")
+	v1.HandleFunc("/orders/{id}/history", orderHandler.GetOrderHistory).Methods("GET")
+	v1.Handle("/orders/{id}/notes", serviceKeys.RequireService(http.HandlerFunc(orderHandler.AddOrderNote))).Methods("POST")
+	v1.Handle("/orders/{id}/notes", serviceKeys.RequireService(http.HandlerFunc(orderHandler.GetOrderNotes))).Methods("GET")
+	v1.HandleFunc("/orders/{id}/invoice", orderHandler.GetOrderInvoice).Methods("GET")
+	v1.HandleFunc("/orders/{id}/shipments", orderHandler.CreateShipment).Methods("POST")
+	v1.HandleFunc("/orders/{id}/shipments/{shipment_id}", orderHandler.UpdateShipment).Methods("PATCH")
+	v1.HandleFunc("/orders/{id}/tracking", trackingHandler.UpdateTracking).Methods("PATCH")
+	v1.HandleFunc("/orders/{id}/pay", orderHandler.PayOrder).Methods("POST")
+	v1.HandleFunc("/orders/{id}/claim", orderHandler.ClaimOrder).Methods("POST")
+
+	// Payment provider callbacks, authenticated by the provider's signature
+	v1.HandleFunc("/payments/webhook", orderHandler.PaymentWebhook).Methods("POST")
+
+	// Internal routes for other services
+	v1.Handle("/internal/purchases", serviceKeys.RequireService(http.HandlerFunc(orderHandler.CheckPurchase))).Methods("GET")
+	v1.Handle("/internal/orders/{id}/shipments/{shipment_id}/tracking", serviceKeys.RequireService(http.HandlerFunc(trackingHandler.ReportTracking))).Methods("POST")
+
+	// Service metrics; the expvar counters are on the diagnostics port
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// Settings in effect, for other services and operators
+	v1.Handle("/admin/config", serviceKeys.RequireService(http.HandlerFunc(reloader.ServeSettings))).Methods("GET")
+
+	// Admin reports, served from read models
+	v1.Handle("/admin/reports/order-counts", serviceKeys.RequireService(http.HandlerFunc(reportHandler.GetOrderCounts))).Methods("GET")
+	v1.Handle("/admin/reports/orders", serviceKeys.RequireService(http.HandlerFunc(reportHandler.ListOrdersByStatus))).Methods("GET")
+	v1.Handle("/admin/reports/revenue", serviceKeys.RequireService(http.HandlerFunc(reportHandler.GetRevenue))).Methods("GET")
+	v1.Handle("/admin/reports/top-products", serviceKeys.RequireService(http.HandlerFunc(reportHandler.GetTopProducts))).Methods("GET")
+
+	// Webhook subscriptions, managed by other services
+	v1.Handle("/webhooks", serviceKeys.RequireService(http.HandlerFunc(webhookHandler.CreateSubscription))).Methods("POST")
+	v1.Handle("/webhooks", serviceKeys.RequireService(http.HandlerFunc(webhookHandler.ListSubscriptions))).Methods("GET")
+	v1.Handle("/webhooks/{id}", serviceKeys.RequireService(http.HandlerFunc(webhookHandler.GetSubscription))).Methods("GET")
+	v1.Handle("/webhooks/{id}", serviceKeys.RequireService(http.HandlerFunc(webhookHandler.DeleteSubscription))).Methods("DELETE")
+	v1.Handle("/webhooks/{id}/deliveries", serviceKeys.RequireService(http.HandlerFunc(webhookHandler.ListDeliveries))).Methods("GET")
+
+	// Recurring orders
+	v1.HandleFunc("/subscriptions", subscriptionHandler.CreateSubscription).Methods("POST")
+	v1.HandleFunc("/subscriptions/{id}", subscriptionHandler.GetSubscription).Methods("GET")
+	v1.HandleFunc("/subscriptions/user/{user_id}", subscriptionHandler.GetUserSubscriptions).Methods("GET")
+	v1.HandleFunc("/subscriptions/{id}/pause", subscriptionHandler.PauseSubscription).Methods("POST")
+	v1.HandleFunc("/subscriptions/{id}/resume", subscriptionHandler.ResumeSubscription).Methods("POST")
+	v1.HandleFunc("/subscriptions/{id}/cancel", subscriptionHandler.CancelSubscription).Methods("POST")
+
+	// Loyalty points
+	v1.HandleFunc("/loyalty/{user_id}", loyaltyHandler.GetAccount).Methods("GET")
+
+	// Coupons, managed by other services
+	v1.Handle("/coupons", serviceKeys.RequireService(http.HandlerFunc(couponHandler.CreateCoupon))).Methods("POST")
+	v1.Handle("/coupons", serviceKeys.RequireService(http.HandlerFunc(couponHandler.ListCoupons))).Methods("GET")
+	v1.Handle("/coupons/{code}", serviceKeys.RequireService(http.HandlerFunc(couponHandler.GetCoupon))).Methods("GET")
+	v1.Handle("/coupons/{code}", serviceKeys.RequireService(http.HandlerFunc(couponHandler.DeleteCoupon))).Methods("DELETE")
 
-	// Health check
-	api.HandleFunc("/health", orderHandler.HealthCheck).Methods("GET")
+	// The API's OpenAPI document, and Swagger UI to browse it
+	router.Handle("/openapi.json", handlers.OpenAPI().Handler(router)).Methods("GET")
+	router.PathPrefix("/docs").Handler(openapi.SwaggerUI("/docs/", "/openapi.json")).Methods("GET")
+
+	// Liveness and readiness probes
+	router.HandleFunc("/healthz", probes.Liveness).Methods("GET")
+	router.HandleFunc("/readyz", probes.Readiness).Methods("GET")
 
 	return router
 }
 
-// corsMiddleware adds CORS headers to responses
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// breakerSettings reads the circuit breaker settings for calls to other services: the circuit opens
+// after CIRCUIT_BREAKER_THRESHOLD failures in a row (0 turns the breakers off) and a probe call is let
+// through after CIRCUIT_BREAKER_OPEN_TIMEOUT
+func breakerSettings(cfg *config.Config) client.BreakerSettings {
+	settings := client.DefaultBreakerSettings
+	settings.FailureThreshold = cfg.Int("CIRCUIT_BREAKER_THRESHOLD", settings.FailureThreshold, config.NonNegative)
+	settings.OpenTimeout = cfg.Duration("CIRCUIT_BREAKER_OPEN_TIMEOUT", settings.OpenTimeout, config.Positive)
+	return settings
+}
 
-		// Handle preflight requests
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+// balancerSettings reads how calls are spread across a service's instances: an instance is ejected
+// after INSTANCE_EJECT_AFTER failures in a row (0 never ejects) and tried again after
+// INSTANCE_EJECT_DURATION
+func balancerSettings(cfg *config.Config) client.BalancerSettings {
+	settings := client.DefaultBalancerSettings
+	settings.EjectAfter = cfg.Int("INSTANCE_EJECT_AFTER", settings.EjectAfter, config.NonNegative)
+	settings.EjectFor = cfg.Duration("INSTANCE_EJECT_DURATION", settings.EjectFor, config.Positive)
+	return settings
+}
+
+// serviceURLs reads the comma-separated URLs of a service's instances from the setting key names
+func serviceURLs(cfg *config.Config, key, defaultURL string) []string {
+	urls := cfg.List(key, []string{defaultURL})
+	if len(urls) == 0 {
+		return []string{defaultURL}
+	}
+	return urls
+}
+
+// dialService connects to the gRPC API at the address setting key names, over TLS when certs is set,
+// or returns nil when the address is set to "", so the service's lookups go over REST instead
+func dialService(cfg *config.Config, key, defaultAddr string, certs *config.TLS) grpc.ClientConnInterface {
+	addr := cfg.String(key, defaultAddr)
+	if addr == "" {
+		return nil
+	}
+	conn, err := rpc.Dial(addr, certs.ClientConfig())
+	if err != nil {
+		logging.Fatal("Invalid gRPC address", "key", key, "error", err)
+	}
+	return conn
+}
 
-		next.ServeHTTP(w, r)
+// httpSettings reads how calls to other services are made: USER_SERVICE_TIMEOUT and
+// PRODUCT_SERVICE_TIMEOUT bound each call (0 for no limit), and SERVICE_MAX_IDLE_CONNS,
+// SERVICE_IDLE_CONN_TIMEOUT, and SERVICE_KEEP_ALIVE tune the pooled connections. Services with https
+// URLs are called with certs.
+func httpSettings(cfg *config.Config, certs *config.TLS) client.HTTPSettings {
+	settings := client.DefaultHTTPSettings
+	settings.TLS = certs.ClientConfig()
+	settings.UserServiceTimeout = cfg.Duration("USER_SERVICE_TIMEOUT", settings.UserServiceTimeout, config.NonNegative)
+	settings.ProductServiceTimeout = cfg.Duration("PRODUCT_SERVICE_TIMEOUT", settings.ProductServiceTimeout, config.NonNegative)
+	settings.IdleConnTimeout = cfg.Duration("SERVICE_IDLE_CONN_TIMEOUT", settings.IdleConnTimeout, config.NonNegative)
+	settings.KeepAlive = cfg.Duration("SERVICE_KEEP_ALIVE", settings.KeepAlive, config.NonNegative)
+	settings.MaxIdleConnsPerHost = cfg.Int("SERVICE_MAX_IDLE_CONNS", settings.MaxIdleConnsPerHost, config.NonNegative)
+	return settings
+}
+
+// hedgeSettings reads when product lookups are hedged: once a lookup has taken longer than the
+// PRODUCT_LOOKUP_HEDGE_PERCENTILE latency of recent ones (0, the default, turns hedging off), but at
+// least PRODUCT_LOOKUP_HEDGE_MIN_DELAY, it is sent again to the next instance
+func hedgeSettings(cfg *config.Config) client.HedgeSettings {
+	settings := client.DefaultHedgeSettings
+	settings.Percentile = cfg.Float("PRODUCT_LOOKUP_HEDGE_PERCENTILE", settings.Percentile, config.NonNegative, func(percentile float64) error {
+		if percentile >= 100 {
+			return errors.New("must be below 100")
+		}
+		return nil
 	})
+	settings.MinDelay = cfg.Duration("PRODUCT_LOOKUP_HEDGE_MIN_DELAY", settings.MinDelay, config.NonNegative)
+	return settings
 }
 
-// loggingMiddleware logs HTTP requests
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+// setupLoyaltyProgram creates the loyalty program from LOYALTY_POINTS_PER_UNIT and LOYALTY_POINT_VALUE
+func setupLoyaltyProgram(cfg *config.Config) *loyalty.Program {
+	pointsPerUnit := cfg.Float("LOYALTY_POINTS_PER_UNIT", 1)
+	pointValue := cfg.Float("LOYALTY_POINT_VALUE", 0.01)
+	program, err := loyalty.NewProgram(repository.NewInMemoryLoyaltyRepository(), pointsPerUnit, pointValue)
+	if err != nil {
+		logging.Fatal("Invalid loyalty program", "error", err)
+	}
+	return program
+}
 
-		// Call the next handler
-		next.ServeHTTP(w, r)
+// Fraud rule defaults: a buyer's eleventh order within an hour is held for review
+const (
+	DefaultFraudVelocityLimit  = 10
+	DefaultFraudVelocityWindow = time.Hour
+)
 
-		// Log the request
-		log.Printf(
-			"[%s] %s %s %v",
-			r.Method,
-			r.RequestURI,
-			r.RemoteAddr,
-			time.Since(start),
-		)
+// setupFraudChecker creates the rule checker from FRAUD_VELOCITY_LIMIT orders per FRAUD_VELOCITY_WINDOW
+// and FRAUD_MAX_ORDER_TOTAL; a limit of 0 turns its rule off, and the total limit is off by default
+func setupFraudChecker(cfg *config.Config, orderRepo repository.OrderRepository) *fraud.RuleChecker {
+	return fraud.NewRuleChecker(orderRepo, fraud.Rules{
+		VelocityLimit:  cfg.Int("FRAUD_VELOCITY_LIMIT", DefaultFraudVelocityLimit, config.NonNegative),
+		VelocityWindow: cfg.Duration("FRAUD_VELOCITY_WINDOW", DefaultFraudVelocityWindow, config.NonNegative),
+		MaxOrderTotal:  cfg.Float("FRAUD_MAX_ORDER_TOTAL", 0, config.NonNegative),
 	})
 }
+
+// DefaultPendingOrderTTL is how long an unpaid order may stay pending before it is cancelled
+const DefaultPendingOrderTTL = 30 * time.Minute
+
+// Pending order expiry metrics, published at /debug/vars
+var (
+	ordersExpired       = expvar.NewInt("orders_expired_total")
+	orderExpiryFailures = expvar.NewInt("order_expiry_failures_total")
+)
+
+// expirePendingOrders cancels orders left pending longer than ttl
+func expirePendingOrders(orderHandler *handlers.OrderHandler, ttl time.Duration) func(ctx context.Context, now time.Time) error {
+	return func(ctx context.Context, now time.Time) error {
+		expired, failed, err := orderHandler.ExpireStaleOrders(ctx, now, ttl)
+		if err != nil {
+			orderExpiryFailures.Add(1)
+			return err
+		}
+		ordersExpired.Add(int64(expired))
+		orderExpiryFailures.Add(int64(failed))
+		if expired > 0 {
+			slog.Info("Cancelled orders left pending too long", "count", expired, "ttl", ttl)
+		}
+		return nil
+	}
+}
+
+// Order retention defaults: delivered orders are archived after 90 days and deleted a year after that
+const (
+	DefaultOrderRetention         = 90 * 24 * time.Hour
+	DefaultArchivedOrderRetention = 365 * 24 * time.Hour
+)
+
+// Order archival metrics, published at /debug/vars
+var (
+	ordersArchived        = expvar.NewInt("orders_archived_total")
+	ordersPurged          = expvar.NewInt("orders_purged_total")
+	orderArchivalFailures = expvar.NewInt("order_archival_failures_total")
+)
+
+// archiveOrders archives delivered orders older than retention and deletes archived orders older
+// than archivedRetention; a zero retention skips that step
+func archiveOrders(orderHandler *handlers.OrderHandler, retention, archivedRetention time.Duration) func(ctx context.Context, now time.Time) error {
+	return func(ctx context.Context, now time.Time) error {
+		var errs []error
+		if retention > 0 {
+			archived, failed, err := orderHandler.ArchiveDeliveredOrders(ctx, now, retention)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("archiving orders: %w", err))
+				orderArchivalFailures.Add(1)
+			}
+			ordersArchived.Add(int64(archived))
+			orderArchivalFailures.Add(int64(failed))
+		}
+		if archivedRetention > 0 {
+			purged, failed, err := orderHandler.PurgeArchivedOrders(ctx, now, archivedRetention)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("deleting archived orders: %w", err))
+				orderArchivalFailures.Add(1)
+			}
+			ordersPurged.Add(int64(purged))
+			orderArchivalFailures.Add(int64(failed))
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// Subscription metrics, published at /debug/vars
+var (
+	subscriptionOrdersPlaced  = expvar.NewInt("subscription_orders_placed_total")
+	subscriptionOrderFailures = expvar.NewInt("subscription_order_failures_total")
+)
+
+// runSubscriptions places the orders of subscriptions that have fallen due
+func runSubscriptions(subscriptionHandler *handlers.SubscriptionHandler) func(ctx context.Context, now time.Time) error {
+	return func(ctx context.Context, now time.Time) error {
+		placed, failed, err := subscriptionHandler.RunDueSubscriptions(ctx, now)
+		if err != nil {
+			subscriptionOrderFailures.Add(1)
+			return err
+		}
+		subscriptionOrdersPlaced.Add(int64(placed))
+		subscriptionOrderFailures.Add(int64(failed))
+		return nil
+	}
+}
+
+// DefaultBackorderAllocationInterval is how often backordered items are offered newly arrived stock
+const DefaultBackorderAllocationInterval = time.Minute
+
+// Backorder allocation metrics, published at /debug/vars
+var (
+	backordersAllocated         = expvar.NewInt("backorder_items_allocated_total")
+	backorderAllocationFailures = expvar.NewInt("backorder_allocation_failures_total")
+)
+
+// allocateBackorders reserves stock for backordered items
+func allocateBackorders(orderHandler *handlers.OrderHandler) func(ctx context.Context, now time.Time) error {
+	return func(ctx context.Context, now time.Time) error {
+		allocated, failed, err := orderHandler.AllocateBackorders(ctx, now)
+		if err != nil {
+			backorderAllocationFailures.Add(1)
+			return err
+		}
+		backordersAllocated.Add(int64(allocated))
+		backorderAllocationFailures.Add(int64(failed))
+		if allocated > 0 {
+			slog.Info("Allocated stock to backordered items", "count", allocated)
+		}
+		return nil
+	}
+}
+
+// DefaultTrackingRefreshInterval is how often parcels on their way are checked with the carrier
+const DefaultTrackingRefreshInterval = 30 * time.Minute
+
+// Tracking refresh metrics, published at /debug/vars
+var (
+	trackingOrdersUpdated = expvar.NewInt("tracking_orders_updated_total")
+	trackingFailures      = expvar.NewInt("tracking_failures_total")
+)
+
+// refreshTracking checks parcels still on their way with the carrier
+func refreshTracking(trackingHandler *handlers.TrackingHandler) func(ctx context.Context, now time.Time) error {
+	return func(ctx context.Context, now time.Time) error {
+		updated, failed, err := trackingHandler.RefreshTracking(ctx, now)
+		if err != nil {
+			trackingFailures.Add(1)
+			return err
+		}
+		trackingOrdersUpdated.Add(int64(updated))
+		trackingFailures.Add(int64(failed))
+		return nil
+	}
+}
+
+// Outbox relay metrics, published at /debug/vars
+var (
+	orderEventsPublished      = expvar.NewInt("order_events_published_total")
+	orderEventPublishFailures = expvar.NewInt("order_event_publish_failures_total")
+)
+
+// relayOutbox publishes the order events waiting in the outbox
+func relayOutbox(relay *outbox.Relay) func(ctx context.Context, now time.Time) error {
+	return func(ctx context.Context, now time.Time) error {
+		published, err := relay.PublishPending()
+		orderEventsPublished.Add(int64(published))
+		if err != nil {
+			orderEventPublishFailures.Add(1)
+		}
+		return err
+	}
+}
+
+// DefaultReportRefreshInterval is how often the admin reports are rebuilt from the stored orders
+const DefaultReportRefreshInterval = 15 * time.Minute
+
+// refreshReports rebuilds the report read models from the stored orders, archived ones included
+func refreshReports(orderRepo repository.OrderRepository, orderReports *reports.Reports) func(ctx context.Context, now time.Time) error {
+	return func(ctx context.Context, now time.Time) error {
+		orders, _, err := orderRepo.List(ctx, &models.OrderFilter{IncludeArchived: true})
+		if err != nil {
+			return err
+		}
+		orderReports.Rebuild(orders)
+		return nil
+	}
+}
+
+// setupDownloadIssuer configures download links from DIGITAL_DOWNLOAD_SECRET, DIGITAL_DOWNLOAD_BASE_URL,
+// and DIGITAL_DOWNLOAD_TTL. Without a secret no links are issued.
+func setupDownloadIssuer(cfg *config.Config) *fulfillment.TokenIssuer {
+	secret := cfg.String("DIGITAL_DOWNLOAD_SECRET", "")
+	if secret == "" {
+		return nil
+	}
+	ttl := cfg.Duration("DIGITAL_DOWNLOAD_TTL", fulfillment.DefaultLinkTTL)
+	issuer, err := fulfillment.NewTokenIssuer(secret, cfg.String("DIGITAL_DOWNLOAD_BASE_URL", "http://localhost:8080/downloads"), ttl)
+	if err != nil {
+		logging.Fatal("Invalid digital download configuration", "error", err)
+	}
+	return issuer
+}
+
+// setupPaymentProvider configures payments from PAYMENT_PROVIDER: "none" (the default) places orders
+// without charging, "service" takes them through payment service at PAYMENT_SERVICE_URL, "mock"
+// settles payments locally, and "stripe" uses STRIPE_SECRET_KEY and STRIPE_WEBHOOK_SECRET
+func setupPaymentProvider(cfg *config.Config, certs *config.TLS) payment.PaymentProvider {
+	switch provider := cfg.String("PAYMENT_PROVIDER", "none"); provider {
+	case "none":
+		return nil
+	case "service":
+		payments := payment.NewServiceProvider(cfg.String("PAYMENT_SERVICE_URL", "http://localhost:8085"), cfg.String("SERVICE_KEY", ""))
+		payments.UseTLS(certs.ClientConfig())
+		return payments
+	case "mock":
+		return payment.NewMockProvider()
+	case "stripe":
+		secretKey, webhookSecret := cfg.String("STRIPE_SECRET_KEY", ""), cfg.String("STRIPE_WEBHOOK_SECRET", "")
+		if secretKey == "" || webhookSecret == "" {
+			logging.Fatal("Invalid payment configuration: STRIPE_SECRET_KEY and STRIPE_WEBHOOK_SECRET are required")
+		}
+		return payment.NewStripeProvider(secretKey, webhookSecret)
+	default:
+		logging.Fatal("Invalid PAYMENT_PROVIDER", "provider", provider)
+		return nil
+	}
+}
+
+// setupValidationClient puts the Redis at REDIS_URL in front of the product lookups of order
+// validation, caching them for PRODUCT_CACHE_TTL. Without REDIS_URL every lookup goes to product service.
+func setupValidationClient(cfg *config.Config, serviceClient *client.ServiceClient) client.OrderValidationClient {
+	redisURL := cfg.String("REDIS_URL", "")
+	ttl := cfg.Duration("PRODUCT_CACHE_TTL", cache.DefaultTTL, config.Positive)
+	if redisURL == "" {
+		return serviceClient
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	productCache, err := cache.New(ctx, redisURL, "order-service:")
+	if err != nil {
+		logging.Fatal("Failed to connect to Redis", "error", err)
+	}
+	return client.NewCachedServiceClient(serviceClient, productCache, ttl)
+}
+
+// orderStore keeps orders and the outbox of events they record
+type orderStore interface {
+	repository.OrderRepository
+	repository.OutboxRepository
+}
+
+// setupOrderRepository picks the order store from ORDER_STORE: "memory" (the default), "postgres",
+// which connects to DATABASE_URL and creates its tables on first start, or "mongodb", which uses the
+// MONGODB_DATABASE database at MONGODB_URI and creates its indexes on first start
+func setupOrderRepository(cfg *config.Config) orderStore {
+	switch store := cfg.String("ORDER_STORE", "memory"); store {
+	case "memory":
+		return repository.NewInMemoryOrderRepository()
+	case "postgres":
+		databaseURL := cfg.String("DATABASE_URL", "")
+		if databaseURL == "" {
+			logging.Fatal("Invalid store configuration: DATABASE_URL is required")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		db, err := postgres.Open(ctx, databaseURL)
+		if err != nil {
+			logging.Fatal("Failed to connect to PostgreSQL", "error", err)
+		}
+		repo, err := repository.NewPostgresOrderRepository(ctx, db)
+		if err != nil {
+			logging.Fatal("Failed to prepare the order tables", "error", err)
+		}
+		return repo
+	case "mongodb":
+		mongoURI := cfg.String("MONGODB_URI", "")
+		if mongoURI == "" {
+			logging.Fatal("Invalid store configuration: MONGODB_URI is required")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		db, err := mongodb.Connect(ctx, mongoURI, cfg.String("MONGODB_DATABASE", "ecommerce"))
+		if err != nil {
+			logging.Fatal("Failed to connect to MongoDB", "error", err)
+		}
+		repo, err := repository.NewMongoOrderRepository(ctx, db)
+		if err != nil {
+			logging.Fatal("Failed to prepare the order collections", "error", err)
+		}
+		return repo
+	default:
+		logging.Fatal("Invalid ORDER_STORE", "store", store)
+		return nil
+	}
+}
+
+// setupSnapshots keeps in-memory orders and their unpublished events in SNAPSHOT_DIR, when it is
+// set, so a dev or demo instance keeps them across restarts. They are snapshotted every
+// SNAPSHOT_INTERVAL, with each change logged in between; the returned stop takes a last snapshot.
+func setupSnapshots(cfg *config.Config, store orderStore) (stop func()) {
+	dir := cfg.String("SNAPSHOT_DIR", "")
+	memoryStore, inMemory := store.(*repository.InMemoryOrderRepository)
+	if dir == "" || !inMemory {
+		return func() {}
+	}
+	if err := memoryStore.PersistTo(dir); err != nil {
+		logging.Fatal("Failed to load the orders snapshot", "dir", dir, "error", err)
+	}
+	slog.Info("Persisting orders", "dir", dir)
+	interval := cfg.Duration("SNAPSHOT_INTERVAL", snapshot.DefaultInterval, config.Positive)
+	return snapshot.Schedule("orders", memoryStore, interval)
+}
+
+// seedOrders creates the orders in the SEED_FILE fixtures, such as fixtures/demo.yaml, that the
+// store doesn't have yet. It runs after snapshots are loaded, so orders kept from an earlier run
+// aren't created again.
+func seedOrders(cfg *config.Config, orderRepo repository.OrderRepository) {
+	path := cfg.String("SEED_FILE", "")
+	if path == "" {
+		return
+	}
+	created, err := repository.SeedOrders(context.Background(), orderRepo, path)
+	if err != nil {
+		logging.Fatal("Failed to seed orders", "file", path, "error", err)
+	}
+	slog.Info("🌱 Orders seeded", "file", path, "created", created)
+}
+
+// setupElector picks how instances share the jobs over stored orders from JOB_ELECTION: "none" (the
+// default) runs them on every instance, which suits a single one, and "redis" has the instances elect
+// one through the Redis at REDIS_URL, whose lease lasts JOB_LEADER_TTL. Election needs an order store
+// the instances share; with the in-memory one each instance must run the jobs over its own orders.
+func setupElector(cfg *config.Config, store orderStore) jobs.Elector {
+	switch election := cfg.String("JOB_ELECTION", "none"); election {
+	case "none":
+		return jobs.Solo{}
+	case "redis":
+		if _, inMemory := store.(*repository.InMemoryOrderRepository); inMemory {
+			logging.Fatal("Invalid configuration: JOB_ELECTION=redis requires a shared ORDER_STORE")
+		}
+		redisURL := cfg.String("REDIS_URL", "")
+		if redisURL == "" {
+			logging.Fatal("Invalid job configuration: REDIS_URL is required")
+		}
+		ttl := cfg.Duration("JOB_LEADER_TTL", jobs.DefaultLeaseTTL, config.Positive)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		elector, err := jobs.NewRedisElector(ctx, redisURL, "order-service:jobs:leader", ttl)
+		if err != nil {
+			logging.Fatal("Failed to connect to Redis", "error", err)
+		}
+		return elector
+	default:
+		logging.Fatal("Invalid JOB_ELECTION", "election", election)
+		return nil
+	}
+}
+
+// setupSubscriber configures consuming events from BROKER: "log" (the default) means payment events
+// only go to payment service's log, so there is nothing to consume, and "kafka-rest" consumes them
+// through the Kafka REST Proxy at KAFKA_REST_URL
+func setupSubscriber(cfg *config.Config) events.Subscriber {
+	switch broker := cfg.String("BROKER", "log"); broker {
+	case "log":
+		return nil
+	case "kafka-rest":
+		proxyURL := cfg.String("KAFKA_REST_URL", "")
+		if proxyURL == "" {
+			logging.Fatal("Invalid broker configuration: KAFKA_REST_URL is required")
+		}
+		return events.NewKafkaREST(proxyURL)
+	default:
+		logging.Fatal("Invalid BROKER", "broker", broker)
+		return nil
+	}
+}
+
+// setupBroker configures the message broker from BROKER: "log" (the default) writes events to the
+// service log, and "kafka-rest" produces them to ORDER_EVENTS_TOPIC through the Kafka REST Proxy at KAFKA_REST_URL
+func setupBroker(cfg *config.Config) outbox.Broker {
+	switch broker := cfg.String("BROKER", "log"); broker {
+	case "log":
+		return outbox.LogBroker{}
+	case "kafka-rest":
+		proxyURL := cfg.String("KAFKA_REST_URL", "")
+		if proxyURL == "" {
+			logging.Fatal("Invalid broker configuration: KAFKA_REST_URL is required")
+		}
+		return outbox.NewKafkaRESTBroker(proxyURL, cfg.String("ORDER_EVENTS_TOPIC", events.DefaultOrderTopic))
+	default:
+		logging.Fatal("Invalid BROKER", "broker", broker)
+		return nil
+	}
+}
+
+// setupTracker configures parcel tracking from TRACKING_PROVIDER: "none" (the default) leaves tracking to
+// manual updates, and "aftership" uses AFTERSHIP_API_KEY
+func setupTracker(cfg *config.Config) carrier.Tracker {
+	switch provider := cfg.String("TRACKING_PROVIDER", "none"); provider {
+	case "none":
+		return nil
+	case "aftership":
+		apiKey := cfg.String("AFTERSHIP_API_KEY", "")
+		if apiKey == "" {
+			logging.Fatal("Invalid tracking configuration: AFTERSHIP_API_KEY is required")
+		}
+		return carrier.NewAfterShipTracker(apiKey)
+	default:
+		logging.Fatal("Invalid TRACKING_PROVIDER", "provider", provider)
+		return nil
+	}
+}
@@ -0,0 +1,113 @@
+package carrier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"order-service/internal/models"
+)
+
+// AfterShipAPIURL is the base URL of AfterShip's tracking API
+const AfterShipAPIURL = "https://api.aftership.com"
+
+// AfterShipTracker tracks parcels of any carrier AfterShip supports. Carriers are named by their
+// AfterShip slug, such as "ups" or "dhl", and parcels must already be registered with AfterShip.
+type AfterShipTracker struct {
+	httpClient *http.Client
+	apiURL     string
+	apiKey     string
+}
+
+// NewAfterShipTracker creates a tracker using the AfterShip API key
+func NewAfterShipTracker(apiKey string) *AfterShipTracker {
+	return &AfterShipTracker{
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		apiURL: AfterShipAPIURL,
+		apiKey: apiKey,
+	}
+}
+
+// afterShipTracking is the part of an AfterShip tracking the tracker needs
+type afterShipTracking struct {
+	Tag                  string `json:"tag"`
+	ExpectedDelivery     string `json:"expected_delivery"`
+	ShipmentDeliveryDate string `json:"shipment_delivery_date"`
+}
+
+// afterShipResponse is AfterShip's response envelope
+type afterShipResponse struct {
+	Meta struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"meta"`
+	Data struct {
+		Tracking afterShipTracking `json:"tracking"`
+	} `json:"data"`
+}
+
+// Track fetches the parcel's latest status from AfterShip
+func (t *AfterShipTracker) Track(carrier, trackingNumber string) (*TrackingInfo, error) {
+	endpoint := fmt.Sprintf("%s/v4/trackings/%s/%s", t.apiURL, url.PathEscape(strings.ToLower(carrier)), url.PathEscape(trackingNumber))
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("aftership-api-key", t.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body afterShipResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding AfterShip response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrUnknownShipment
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("AfterShip responded with status %d: %s", resp.StatusCode, body.Meta.Message)
+	}
+
+	tracking := body.Data.Tracking
+	info := &TrackingInfo{
+		Status:            afterShipStatus(tracking.Tag),
+		EstimatedDelivery: parseAfterShipTime(tracking.ExpectedDelivery),
+	}
+	if info.Status == models.TrackingDelivered {
+		info.DeliveredAt = parseAfterShipTime(tracking.ShipmentDeliveryDate)
+	}
+	return info, nil
+}
+
+// afterShipStatus maps an AfterShip tag to a tracking status
+func afterShipStatus(tag string) models.TrackingStatus {
+	switch tag {
+	case "Delivered":
+		return models.TrackingDelivered
+	case "InTransit", "OutForDelivery", "AvailableForPickup":
+		return models.TrackingInTransit
+	case "Exception", "AttemptFail", "Expired":
+		return models.TrackingException
+	default: // Pending, InfoReceived
+		return models.TrackingPending
+	}
+}
+
+// parseAfterShipTime reads AfterShip's timestamps, which are dates or RFC 3339 times with or without an offset
+func parseAfterShipTime(value string) *time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return &parsed
+		}
+	}
+	return nil
+}
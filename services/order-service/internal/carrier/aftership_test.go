@@ -0,0 +1,48 @@
+package carrier
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"order-service/internal/models"
+)
+
+func TestAfterShipTracker_Track(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("aftership-api-key") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"meta":{"code":401,"message":"Invalid API key."}}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v4/trackings/ups/1Z999":
+			w.Write([]byte(`{"meta":{"code":200},"data":{"tracking":{"tag":"InTransit","expected_delivery":"2024-05-10"}}}`))
+		case "/v4/trackings/dhl/42":
+			w.Write([]byte(`{"meta":{"code":200},"data":{"tracking":{"tag":"Delivered","shipment_delivery_date":"2024-05-09T14:30:00+02:00"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"meta":{"code":4004,"message":"Tracking does not exist."}}`))
+		}
+	}))
+	defer server.Close()
+
+	tracker := NewAfterShipTracker("key")
+	tracker.apiURL = server.URL
+
+	info, err := tracker.Track("UPS", "1Z999")
+	if err != nil || info.Status != models.TrackingInTransit || info.EstimatedDelivery == nil || info.EstimatedDelivery.Day() != 10 {
+		t.Fatalf("expected an in-transit parcel due on the 10th, got %+v %v", info, err)
+	}
+	info, err = tracker.Track("dhl", "42")
+	if err != nil || info.Status != models.TrackingDelivered || info.DeliveredAt == nil || info.DeliveredAt.Hour() != 14 {
+		t.Fatalf("expected a delivered parcel, got %+v %v", info, err)
+	}
+	if _, err := tracker.Track("ups", "missing"); err != ErrUnknownShipment {
+		t.Fatalf("expected ErrUnknownShipment got %v", err)
+	}
+
+	tracker.apiKey = "wrong"
+	if _, err := tracker.Track("ups", "1Z999"); err == nil {
+		t.Error("expected an error for a rejected API key")
+	}
+}
@@ -0,0 +1,23 @@
+package carrier
+
+import (
+	"errors"
+	"time"
+	"order-service/internal/models"
+)
+
+// ErrUnknownShipment is returned when the carrier has no parcel with the tracking number
+var ErrUnknownShipment = errors.New("carrier does not know the tracking number")
+
+// TrackingInfo is what a carrier reports about a parcel
+type TrackingInfo struct {
+	Status            models.TrackingStatus
+	EstimatedDelivery *time.Time // nil when the carrier gives no estimate
+	DeliveredAt       *time.Time // set once the parcel is delivered
+}
+
+// Tracker looks up parcels with an external tracking API. Implementations can talk to a single
+// carrier or to an aggregator covering many.
+type Tracker interface {
+	Track(carrier, trackingNumber string) (*TrackingInfo, error)
+}
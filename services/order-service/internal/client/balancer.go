@@ -0,0 +1,143 @@
+package client
+
+import (
+	"sync"
+	"time"
+	"ecommerce/pkg/metrics"
+)
+
+// BalancerSettings configures how calls are spread across a service's instances
+type BalancerSettings struct {
+	EjectAfter int           // consecutive failures that take an instance out of rotation; 0 never ejects
+	EjectFor   time.Duration // how long an ejected instance is left out before it is tried again
+}
+
+// DefaultBalancerSettings ejects an instance after 3 failures in a row and tries it again after 30 seconds
+var DefaultBalancerSettings = BalancerSettings{EjectAfter: 3, EjectFor: 30 * time.Second}
+
+var instanceEjections = metrics.NewCounterVec("service_client_instance_ejections_total",
+	"Instances of other services taken out of rotation for failing, by service", "service")
+
+// instance is one running copy of a service and its recent health
+type instance struct {
+	url          string
+	failures     int       // consecutive failed calls
+	ejectedUntil time.Time // the instance is left out of rotation until then
+}
+
+// Balancer spreads calls to a service round-robin across its instances. An instance that fails
+// EjectAfter calls in a row, by not answering or answering with a server error, is ejected for
+// EjectFor. It then rejoins the rotation, but a single further failure ejects it again; a success
+// clears its record. When every instance is ejected they are all tried anyway, leaving it to the
+// service's circuit breaker to stop calls.
+type Balancer struct {
+	label     string // identifies the service in metrics
+	settings  BalancerSettings
+	mutex     sync.Mutex
+	instances []*instance
+	next      int
+	now       func() time.Time // replaced in tests
+}
+
+// NewBalancer creates a balancer over the instances at urls
+func NewBalancer(label string, urls []string, settings BalancerSettings) *Balancer {
+	b := &Balancer{label: label, settings: settings, now: time.Now}
+	b.SetURLs(urls)
+	return b
+}
+
+// SetURLs replaces the instances calls are spread across. Instances that stay keep their health.
+func (b *Balancer) SetURLs(urls []string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	known := make(map[string]*instance, len(b.instances))
+	for _, inst := range b.instances {
+		known[inst.url] = inst
+	}
+	instances := make([]*instance, 0, len(urls))
+	for _, url := range urls {
+		inst, exists := known[url]
+		if !exists {
+			inst = &instance{url: url}
+		}
+		instances = append(instances, inst)
+	}
+	b.instances = instances
+	b.next = 0
+}
+
+// URLs returns the URLs of the instances calls are spread across
+func (b *Balancer) URLs() []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	urls := make([]string, len(b.instances))
+	for i, inst := range b.instances {
+		urls[i] = inst.url
+	}
+	return urls
+}
+
+// States returns whether each instance is "healthy" or "ejected", by URL
+func (b *Balancer) States() map[string]string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.now()
+	states := make(map[string]string, len(b.instances))
+	for _, inst := range b.instances {
+		states[inst.url] = "healthy"
+		if now.Before(inst.ejectedUntil) {
+			states[inst.url] = "ejected"
+		}
+	}
+	return states
+}
+
+// pick returns the instance the next call goes to, or nil when the service has none
+func (b *Balancer) pick() *instance {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(b.instances) == 0 {
+		return nil
+	}
+	now := b.now()
+	for range b.instances {
+		inst := b.instances[b.next%len(b.instances)]
+		b.next++
+		if !now.Before(inst.ejectedUntil) {
+			return inst
+		}
+	}
+	inst := b.instances[b.next%len(b.instances)]
+	b.next++
+	return inst
+}
+
+// success records that a call to inst was answered
+func (b *Balancer) success(inst *instance) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	inst.failures = 0
+	inst.ejectedUntil = time.Time{}
+}
+
+// failure records that a call to inst went unanswered or failed with a server error, ejecting it
+// once it has failed EjectAfter times in a row
+func (b *Balancer) failure(inst *instance) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	inst.failures++
+	if b.settings.EjectAfter <= 0 || inst.failures < b.settings.EjectAfter {
+		return
+	}
+	now := b.now()
+	if now.Before(inst.ejectedUntil) {
+		return
+	}
+	inst.ejectedUntil = now.Add(b.settings.EjectFor)
+	instanceEjections.WithLabelValues(b.label).Inc()
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"order-service/internal/models"
+)
+
+func TestBalancer_RoundRobinsAndEjectsFailingInstances(t *testing.T) {
+	now := time.Now()
+	b := NewBalancer("test", []string{"a", "b", "c"}, BalancerSettings{EjectAfter: 2, EjectFor: time.Minute})
+	b.now = func() time.Time { return now }
+
+	var picked []string
+	for i := 0; i < 4; i++ {
+		picked = append(picked, b.pick().url)
+	}
+	if got := fmt.Sprint(picked); got != "[a b c a]" {
+		t.Fatalf("expected calls in turn, got %s", got)
+	}
+
+	// b fails twice in a row and is left out until EjectFor has passed
+	inst := b.instances[1]
+	b.failure(inst)
+	if b.States()["b"] != "healthy" {
+		t.Fatal("expected one failure to keep b in rotation")
+	}
+	b.failure(inst)
+	if b.States()["b"] != "ejected" {
+		t.Fatal("expected b ejected after two failures")
+	}
+	for i := 0; i < 4; i++ {
+		if url := b.pick().url; url == "b" {
+			t.Fatal("expected b skipped while ejected")
+		}
+	}
+
+	// Back in rotation after EjectFor, one more failure ejects it again, and a success clears it
+	now = now.Add(time.Minute)
+	if b.States()["b"] != "healthy" {
+		t.Fatal("expected b back in rotation")
+	}
+	b.failure(inst)
+	if b.States()["b"] != "ejected" {
+		t.Fatal("expected b ejected again at its next failure")
+	}
+	now = now.Add(time.Minute)
+	b.success(inst)
+	b.failure(inst)
+	if b.States()["b"] != "healthy" {
+		t.Fatal("expected a success to clear b's record")
+	}
+}
+
+func TestBalancer_TriesEveryInstanceWhenAllAreEjected(t *testing.T) {
+	b := NewBalancer("test", []string{"a"}, BalancerSettings{EjectAfter: 1, EjectFor: time.Minute})
+	b.failure(b.pick())
+	if inst := b.pick(); inst == nil || inst.url != "a" {
+		t.Fatalf("expected the ejected instance still tried, got %+v", inst)
+	}
+}
+
+func TestBalancer_SetURLsKeepsTheHealthOfInstancesThatStay(t *testing.T) {
+	b := NewBalancer("test", []string{"a", "b"}, BalancerSettings{EjectAfter: 1, EjectFor: time.Minute})
+	b.failure(b.instances[0])
+
+	b.SetURLs([]string{"a", "c"})
+	if states := b.States(); len(states) != 2 || states["a"] != "ejected" || states["c"] != "healthy" {
+		t.Fatalf("expected a still ejected next to the new c, got %v", states)
+	}
+}
+
+func TestServiceClient_SpreadsCallsAndRoutesAroundFailingInstances(t *testing.T) {
+	var goodCalls, badCalls int32
+	good := productServer(t, map[string]models.Product{"p1": {ID: "p1", Name: "Mouse"}})
+	counted := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&goodCalls, 1)
+		good.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(counted.Close)
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&badCalls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(bad.Close)
+
+	c := NewServiceClient("", "", "", BreakerSettings{}, BalancerSettings{EjectAfter: 2, EjectFor: time.Minute}, DefaultHTTPSettings)
+	c.SetURLs(nil, []string{bad.URL, counted.URL})
+
+	// Each lookup that lands on the failing instance is retried on the other one
+	for i := 0; i < 6; i++ {
+		if _, err := c.GetProduct(context.Background(), "p1"); err != nil {
+			t.Fatalf("lookup %d: expected the healthy instance to answer, got %v", i, err)
+		}
+	}
+	if got := atomic.LoadInt32(&badCalls); got != 2 {
+		t.Fatalf("expected the failing instance ejected after two calls, got %d calls", got)
+	}
+	if got := atomic.LoadInt32(&goodCalls); got != 6 {
+		t.Fatalf("expected every lookup answered by the healthy instance, got %d calls", got)
+	}
+	if states := c.InstanceStates()["product_service"]; states[bad.URL] != "ejected" || states[counted.URL] != "healthy" {
+		t.Fatalf("expected the failing instance ejected, got %v", states)
+	}
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling a downstream service while its circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerSettings configures a circuit breaker
+type BreakerSettings struct {
+	FailureThreshold int           // consecutive failures that open the circuit; 0 turns the breaker off
+	OpenTimeout      time.Duration // how long the circuit stays open before a probe call is let through
+}
+
+// DefaultBreakerSettings opens the circuit after 5 failures in a row and probes again after 30 seconds
+var DefaultBreakerSettings = BreakerSettings{FailureThreshold: 5, OpenTimeout: 30 * time.Second}
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"    // calls go through
+	BreakerOpen     = "open"      // calls fail fast with ErrCircuitOpen
+	BreakerHalfOpen = "half-open" // one probe call is let through to see if the service has recovered
+)
+
+// Breaker stops calling a downstream service that keeps failing, so callers fail fast instead of
+// waiting out timeouts. Once OpenTimeout has passed a single probe call is let through: the
+// circuit closes if it succeeds and opens again if it fails.
+type Breaker struct {
+	settings BreakerSettings
+	mutex    sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool             // a half-open probe is in flight
+	now      func() time.Time // replaced in tests
+}
+
+// NewBreaker creates a closed circuit breaker
+func NewBreaker(settings BreakerSettings) *Breaker {
+	return &Breaker{
+		settings: settings,
+		state:    BreakerClosed,
+		now:      time.Now,
+	}
+}
+
+// Allow reports whether a call may go ahead, returning ErrCircuitOpen if not. Every allowed call
+// must be followed by Success or Failure.
+func (b *Breaker) Allow() error {
+	if b.settings.FailureThreshold <= 0 {
+		return nil
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.settings.OpenTimeout {
+		b.state = BreakerHalfOpen
+	}
+	switch b.state {
+	case BreakerOpen:
+		return ErrCircuitOpen
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Success records a call the service answered, closing the circuit
+func (b *Breaker) Success() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure records a call the service didn't answer, opening the circuit once the threshold is
+// reached or straight away if the call was a probe
+func (b *Breaker) Failure() {
+	if b.settings.FailureThreshold <= 0 {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.settings.FailureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+	b.probing = false
+}
+
+// Abandon records a call the caller gave up on before the service answered. It counts as neither
+// success nor failure, but lets another probe through if it was one.
+func (b *Breaker) Abandon() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.probing = false
+}
+
+// State returns closed, open, or half-open. An open circuit whose timeout has passed reports
+// half-open, as the next call will probe.
+func (b *Breaker) State() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.settings.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreaker_OpensAndProbes(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	b := NewBreaker(BreakerSettings{FailureThreshold: 2, OpenTimeout: time.Minute})
+	b.now = func() time.Time { return now }
+
+	// A success resets the count of failures in a row
+	b.Failure()
+	b.Success()
+	b.Failure()
+	if err := b.Allow(); err != nil || b.State() != BreakerClosed {
+		t.Fatalf("expected the circuit closed, got %s %v", b.State(), err)
+	}
+	b.Failure()
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) || b.State() != BreakerOpen {
+		t.Fatalf("expected the circuit open, got %s %v", b.State(), err)
+	}
+
+	// After the timeout one probe goes through; a failed probe opens the circuit again
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expected a probe to be allowed, got %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected only one probe at a time, got %v", err)
+	}
+	b.Failure()
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected a failed probe to reopen the circuit, got %s", b.State())
+	}
+
+	now = now.Add(time.Minute)
+	_ = b.Allow()
+	b.Success()
+	if err := b.Allow(); err != nil || b.State() != BreakerClosed {
+		t.Fatalf("expected a successful probe to close the circuit, got %s %v", b.State(), err)
+	}
+
+	off := NewBreaker(BreakerSettings{})
+	for i := 0; i < 10; i++ {
+		off.Failure()
+	}
+	if err := off.Allow(); err != nil {
+		t.Fatalf("expected a zero threshold to turn the breaker off, got %v", err)
+	}
+}
+
+func TestServiceClient_FailsFastWhileCircuitIsOpen(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	c := NewServiceClient("", server.URL, "", BreakerSettings{FailureThreshold: 2, OpenTimeout: time.Minute}, DefaultBalancerSettings, DefaultHTTPSettings)
+
+	// The second failed attempt opens the circuit, so the third retry isn't made
+	if _, err := c.GetProduct(context.Background(), "p1"); err == nil || calls != 2 {
+		t.Fatalf("expected two failed calls, got %d %v", calls, err)
+	}
+	if _, err := c.GetProduct(context.Background(), "p1"); !errors.Is(err, ErrCircuitOpen) || calls != 2 {
+		t.Fatalf("expected ErrCircuitOpen without calling the service, got %d %v", calls, err)
+	}
+	if _, err := c.ReserveStock(context.Background(), "p1", 1, "o1"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected reservations to fail fast too, got %v", err)
+	}
+	if states := c.BreakerStates(); states["product_service"] != BreakerOpen || states["user_service"] != BreakerClosed {
+		t.Fatalf("expected only the product service circuit open, got %v", states)
+	}
+}
+
+func TestServiceClient_CancelledCallsAreAbandoned(t *testing.T) {
+	release := make(chan struct{})
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	c := NewServiceClient("", server.URL, "", BreakerSettings{FailureThreshold: 1, OpenTimeout: time.Minute}, DefaultBalancerSettings, DefaultHTTPSettings)
+
+	// The caller going away stops the call without retrying, and isn't held against the service
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetProduct(ctx, "p1"); !errors.Is(err, context.DeadlineExceeded) || atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("expected one call cut short by the deadline, got %d %v", atomic.LoadInt32(&calls), err)
+	}
+	if state := c.BreakerStates()["product_service"]; state != BreakerClosed {
+		t.Fatalf("expected the circuit to stay closed, got %s", state)
+	}
+}
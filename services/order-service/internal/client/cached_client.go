@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+	"ecommerce/pkg/cache"
+	"order-service/internal/models"
+)
+
+// cacheTimeout bounds each cache call, so a slow Redis costs an order little more than a miss
+const cacheTimeout = 250 * time.Millisecond
+
+// productCacheKey prefixes the ID of a cached product
+const productCacheKey = "product:"
+
+// CachedServiceClient answers the product lookups of order validation from a cache, going to
+// product service only on a miss. A product's entry is dropped whenever this service reserves,
+// releases, or commits its stock, so stock seen by the next order is fresh; price and other
+// catalog changes reach new orders within the TTL. Reservations still always go to product
+// service, which has the final say on stock. User lookups aren't cached, so a deactivated
+// account can't keep ordering.
+type CachedServiceClient struct {
+	client *ServiceClient
+	cache  *cache.Cache
+	ttl    time.Duration
+}
+
+// NewCachedServiceClient caches client's product lookups for ttl
+func NewCachedServiceClient(client *ServiceClient, cache *cache.Cache, ttl time.Duration) *CachedServiceClient {
+	return &CachedServiceClient{client: client, cache: cache, ttl: ttl}
+}
+
+// GetProduct retrieves product information, from the cache when it is there
+func (c *CachedServiceClient) GetProduct(ctx context.Context, productID string) (*models.Product, error) {
+	var product models.Product
+	err := c.withCache(ctx, func(cacheCtx context.Context) error {
+		return c.cache.Get(cacheCtx, "product", productCacheKey+productID, &product)
+	})
+	if err == nil {
+		return &product, nil
+	}
+	if !errors.Is(err, cache.ErrMiss) {
+		slog.WarnContext(ctx, "Error reading the product cache", "product_id", productID, "error", err)
+	}
+
+	fetched, err := c.client.GetProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	err = c.withCache(ctx, func(cacheCtx context.Context) error {
+		return c.cache.Set(cacheCtx, productCacheKey+productID, fetched, c.ttl)
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Error writing the product cache", "product_id", productID, "error", err)
+	}
+	return fetched, nil
+}
+
+// withCache runs one cache call, bounded by cacheTimeout. Calls made as a request finishes, such
+// as invalidations, still run after its context is cancelled.
+func (c *CachedServiceClient) withCache(ctx context.Context, call func(cacheCtx context.Context) error) error {
+	cacheCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cacheTimeout)
+	defer cancel()
+	return call(cacheCtx)
+}
+
+// Invalidate drops the cached copy of a product
+func (c *CachedServiceClient) Invalidate(ctx context.Context, productID string) {
+	err := c.withCache(ctx, func(cacheCtx context.Context) error {
+		return c.cache.Delete(cacheCtx, productCacheKey+productID)
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Error invalidating a cached product", "product_id", productID, "error", err)
+	}
+}
+
+// ValidateOrderItems validates all items in an order against cached products
+func (c *CachedServiceClient) ValidateOrderItems(ctx context.Context, items []models.CreateOrderItem) ([]models.OrderItem, error) {
+	return validateOrderItems(ctx, items, c.GetProduct)
+}
+
+// ReserveStock sets quantity units of a product aside for an order and returns the reservation ID
+func (c *CachedServiceClient) ReserveStock(ctx context.Context, productID string, quantity int, orderID string) (string, error) {
+	defer c.Invalidate(ctx, productID)
+	return c.client.ReserveStock(ctx, productID, quantity, orderID)
+}
+
+// ReleaseStock returns a reservation's units to the product's stock
+func (c *CachedServiceClient) ReleaseStock(ctx context.Context, productID, reservationID string) error {
+	defer c.Invalidate(ctx, productID)
+	return c.client.ReleaseStock(ctx, productID, reservationID)
+}
+
+// CommitStock turns a reservation into a sale so it no longer expires
+func (c *CachedServiceClient) CommitStock(ctx context.Context, productID, reservationID string) error {
+	defer c.Invalidate(ctx, productID)
+	return c.client.CommitStock(ctx, productID, reservationID)
+}
+
+// CheckUserExists verifies that a user exists and has not been deactivated
+func (c *CachedServiceClient) CheckUserExists(ctx context.Context, userID string) error {
+	return c.client.CheckUserExists(ctx, userID)
+}
+
+// GetUser retrieves user information from the user service
+func (c *CachedServiceClient) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	return c.client.GetUser(ctx, userID)
+}
+
+// GetShippingAddress retrieves a shipping address from the user service
+func (c *CachedServiceClient) GetShippingAddress(ctx context.Context, userID, addressID string) (*models.Address, error) {
+	return c.client.GetShippingAddress(ctx, userID, addressID)
+}
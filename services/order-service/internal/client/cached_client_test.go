@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"ecommerce/pkg/cache"
+	"order-service/internal/models"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestCachedServiceClient_CachesProductsUntilStockChanges(t *testing.T) {
+	var lookups atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/reserve") {
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": map[string]string{"id": "r1"}})
+			return
+		}
+		lookups.Add(1)
+		product := models.Product{ID: "pen", Name: "Pen", Price: 1, Stock: 100}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": product})
+	}))
+	t.Cleanup(server.Close)
+
+	redis := miniredis.RunT(t)
+	productCache, err := cache.New(context.Background(), "redis://"+redis.Addr(), "order-service:")
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	t.Cleanup(func() { productCache.Close() })
+	c := NewCachedServiceClient(NewServiceClient("", server.URL, "", DefaultBreakerSettings, DefaultBalancerSettings, DefaultHTTPSettings), productCache, time.Minute)
+
+	ctx := context.Background()
+	items := []models.CreateOrderItem{{ProductID: "pen", Quantity: 2}}
+	for i := 0; i < 3; i++ {
+		if _, err := c.ValidateOrderItems(ctx, items); err != nil {
+			t.Fatalf("validate: %v", err)
+		}
+	}
+	if got := lookups.Load(); got != 1 {
+		t.Errorf("expected one product lookup for three validations, got %d", got)
+	}
+
+	if _, err := c.ReserveStock(ctx, "pen", 2, "o1"); err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	c.ValidateOrderItems(ctx, items)
+	if got := lookups.Load(); got != 2 {
+		t.Errorf("expected the product looked up again after its stock changed, got %d lookups", got)
+	}
+
+	redis.Close()
+	if _, err := c.ValidateOrderItems(ctx, items); err != nil {
+		t.Errorf("expected validation to fall back to product service without the cache, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"ecommerce/pkg/rpc"
+	productv1 "ecommerce/pkg/proto/product/v1"
+	userv1 "ecommerce/pkg/proto/user/v1"
+	"order-service/internal/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// getUserRPC looks a user up with the user service's GetUser call
+func (c *ServiceClient) getUserRPC(ctx context.Context, userID string) (*models.User, error) {
+	user, err := callRPC(ctx, c, c.userService, "GetUser", func(ctx context.Context) (*userv1.User, error) {
+		return c.users.GetUser(ctx, &userv1.GetUserRequest{Id: userID})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &models.User{
+		ID:     user.Id,
+		Name:   user.Name,
+		Email:  user.Email,
+		Active: user.Active,
+	}, nil
+}
+
+// getProductRPC looks a product up with the product service's GetProduct call, priced in the order currency
+func (c *ServiceClient) getProductRPC(ctx context.Context, productID string) (*models.Product, error) {
+	product, err := callRPC(ctx, c, c.productService, "GetProduct", func(ctx context.Context) (*productv1.Product, error) {
+		return c.products.GetProduct(ctx, &productv1.GetProductRequest{Id: productID, Currency: models.OrderCurrency})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &models.Product{
+		ID:             product.Id,
+		Name:           product.Name,
+		Price:          product.Price,
+		EffectivePrice: product.EffectivePrice,
+		Currency:       product.Currency,
+		Stock:          int(product.Stock),
+		Kind:           product.Kind,
+		WeightKg:       product.WeightKg,
+		MinOrderQty:    int(product.MinOrderQty),
+		MaxOrderQty:    int(product.MaxOrderQty),
+		AllowBackorder: product.AllowBackorder,
+	}, nil
+}
+
+// callRPC makes a gRPC call to service with c's key, retrying it as getJSON retries REST calls:
+// unavailable services and timeouts are tried again, NotFound is returned as ErrNotFound, and slow
+// attempts are hedged when the service has a hedger
+func callRPC[T any](ctx context.Context, c *ServiceClient, service *dependency, method string, call func(ctx context.Context) (T, error)) (T, error) {
+	ctx = rpc.WithServiceKey(ctx, c.serviceKey)
+	var result T
+	err := retry(ctx, service, func() (bool, error) {
+		value, done, err := hedged(ctx, service.hedger, func(ctx context.Context) (T, bool, error) {
+			var value T
+			err := service.invoke(ctx, method, func(ctx context.Context) (err error) {
+				value, err = call(ctx)
+				return err
+			})
+			done, err := callOutcome(service, err)
+			return value, done, err
+		})
+		result = value
+		return done, err
+	})
+	return result, err
+}
+
+// callOutcome turns a gRPC call's error into the outcome of an attempt, reporting done=false when the
+// call may pass if tried again
+func callOutcome(service *dependency, err error) (bool, error) {
+	switch status.Code(err) {
+	case codes.OK:
+		return true, nil
+	case codes.NotFound:
+		return true, fmt.Errorf("%s: %w", service.name, ErrNotFound)
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled, codes.Internal, codes.Unknown, codes.DataLoss:
+		return false, fmt.Errorf("failed to call %s: %w", service.name, err)
+	default:
+		return true, fmt.Errorf("%s error: %s", service.name, status.Convert(err).Message())
+	}
+}
+
+// invoke makes one gRPC call, bounded by the service's timeout, and records how long the service
+// took to answer. The outcome is the call's status code, such as OK or Unavailable.
+func (d *dependency) invoke(ctx context.Context, method string, call func(ctx context.Context) error) error {
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := call(ctx)
+	serviceCallDuration.WithLabelValues(d.label, method, status.Code(err).String()).Observe(time.Since(start).Seconds())
+	return err
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"ecommerce/pkg/metrics"
+	"ecommerce/pkg/rpc"
+	productv1 "ecommerce/pkg/proto/product/v1"
+	userv1 "ecommerce/pkg/proto/user/v1"
+	"order-service/internal/models"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeUsers serves a fixed set of users, failing the first failures calls with Unavailable
+type fakeUsers struct {
+	userv1.UnimplementedUserServiceServer
+	users    map[string]*userv1.User
+	failures int32
+	calls    int32
+	key      atomic.Value // the service key the last call came with
+}
+
+func (f *fakeUsers) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.User, error) {
+	if keys := metadata.ValueFromIncomingContext(ctx, rpc.ServiceKeyMetadata); len(keys) > 0 {
+		f.key.Store(keys[0])
+	}
+	if atomic.AddInt32(&f.calls, 1) <= f.failures {
+		return nil, status.Error(codes.Unavailable, "starting up")
+	}
+	user, exists := f.users[req.Id]
+	if !exists {
+		return nil, status.Error(codes.NotFound, "User not found")
+	}
+	return user, nil
+}
+
+// fakeProducts serves a fixed set of products, remembering the currency it was last asked for
+type fakeProducts struct {
+	productv1.UnimplementedProductServiceServer
+	products map[string]*productv1.Product
+	currency atomic.Value
+}
+
+func (f *fakeProducts) GetProduct(ctx context.Context, req *productv1.GetProductRequest) (*productv1.Product, error) {
+	f.currency.Store(req.Currency)
+	product, exists := f.products[req.Id]
+	if !exists {
+		return nil, status.Error(codes.NotFound, "Product not found")
+	}
+	return product, nil
+}
+
+// grpcConn serves users and products in memory and returns a connection to them
+func grpcConn(t *testing.T, users *fakeUsers, products *fakeProducts) *grpc.ClientConn {
+	t.Helper()
+	server := grpc.NewServer()
+	userv1.RegisterUserServiceServer(server, users)
+	productv1.RegisterProductServiceServer(server, products)
+
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServiceClient_GetUserOverGRPC(t *testing.T) {
+	users := &fakeUsers{users: map[string]*userv1.User{
+		"u1": {Id: "u1", Name: "Ada", Email: "ada@example.com", Active: true},
+	}, failures: 1}
+	conn := grpcConn(t, users, &fakeProducts{})
+	c := NewServiceClient("http://127.0.0.1:1", "http://127.0.0.1:1", "sk_order", DefaultBreakerSettings, DefaultBalancerSettings, DefaultHTTPSettings)
+	c.UseGRPC(conn, nil)
+
+	user, err := c.GetUser(context.Background(), "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != "u1" || user.Email != "ada@example.com" || !user.Active {
+		t.Fatalf("expected the user, got %+v", user)
+	}
+	if atomic.LoadInt32(&users.calls) != 2 {
+		t.Fatalf("expected the unavailable call retried once, got %d calls", users.calls)
+	}
+	if key, _ := users.key.Load().(string); key != "sk_order" {
+		t.Fatalf("expected the service key sent, got %q", key)
+	}
+
+	calls := atomic.LoadInt32(&users.calls)
+	if _, err := c.GetUser(context.Background(), "u2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if atomic.LoadInt32(&users.calls) != calls+1 {
+		t.Fatal("expected a missing user not retried")
+	}
+	if err := c.CheckUserExists(context.Background(), "u1"); err != nil {
+		t.Fatalf("expected an active user accepted, got %v", err)
+	}
+}
+
+func TestServiceClient_GetProductOverGRPC(t *testing.T) {
+	products := &fakeProducts{products: map[string]*productv1.Product{
+		"paper": {Id: "paper", Name: "Paper", Price: 5, EffectivePrice: 4, Currency: "USD", Stock: 100, MinOrderQty: 10},
+	}}
+	conn := grpcConn(t, &fakeUsers{}, products)
+	c := NewServiceClient("http://127.0.0.1:1", "http://127.0.0.1:1", "", DefaultBreakerSettings, DefaultBalancerSettings, DefaultHTTPSettings)
+	c.UseGRPC(nil, conn)
+
+	items, err := c.ValidateOrderItems(context.Background(), []models.CreateOrderItem{{ProductID: "paper", Quantity: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items[0].Price != 4 || items[0].ProductName != "Paper" {
+		t.Fatalf("expected the line priced at the effective price, got %+v", items[0])
+	}
+	if currency, _ := products.currency.Load().(string); currency != models.OrderCurrency {
+		t.Fatalf("expected prices asked for in %s, got %q", models.OrderCurrency, currency)
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), `service_client_request_duration_seconds_count{service="product_service",method="GetProduct",outcome="OK"}`) {
+		t.Fatalf("expected the call recorded by method and status code, got\n%s", rec.Body.String())
+	}
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+	"ecommerce/pkg/metrics"
+)
+
+// HedgeSettings configures hedged lookups: when a lookup hasn't been answered within the Percentile
+// latency of recent lookups, a second request goes to the next instance and whichever answers first
+// is used, so one slow instance or connection doesn't hold up order validation
+type HedgeSettings struct {
+	Percentile float64       // latency percentile after which a hedge is sent, such as 95; 0 turns hedging off
+	MinDelay   time.Duration // a hedge is never sent sooner than this, however fast recent lookups were
+}
+
+// DefaultHedgeSettings leaves hedging off
+var DefaultHedgeSettings = HedgeSettings{MinDelay: 10 * time.Millisecond}
+
+const (
+	hedgeWindow     = 200 // recent lookup latencies the percentile is taken over
+	minHedgeSamples = 20  // lookups answered before any are hedged, so the percentile means something
+)
+
+var (
+	hedgesSent = metrics.NewCounterVec("service_client_hedged_requests_total",
+		"Second requests sent because a lookup from another service was slow to answer, by service", "service")
+	hedgesWon = metrics.NewCounterVec("service_client_hedge_wins_total",
+		"Hedged requests answered before the request they backed up, by service", "service")
+)
+
+// hedger decides when a lookup from a service is slow enough to hedge, from the latencies of the
+// lookups before it. A nil *hedger never hedges.
+type hedger struct {
+	label    string // identifies the service in metrics
+	settings HedgeSettings
+	mutex    sync.Mutex
+	samples  []time.Duration // the last hedgeWindow latencies, oldest overwritten first
+	next     int
+}
+
+// newHedger creates the hedger for a service, or nil when settings turn hedging off
+func newHedger(label string, settings HedgeSettings) *hedger {
+	if settings.Percentile <= 0 {
+		return nil
+	}
+	return &hedger{label: label, settings: settings, samples: make([]time.Duration, 0, hedgeWindow)}
+}
+
+// observe records how long a lookup took to be answered
+func (h *hedger) observe(latency time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.samples) < hedgeWindow {
+		h.samples = append(h.samples, latency)
+		return
+	}
+	h.samples[h.next] = latency
+	h.next = (h.next + 1) % hedgeWindow
+}
+
+// delay returns how long to wait for an answer before hedging, and false while too few lookups have
+// been seen to tell what slow is
+func (h *hedger) delay() (time.Duration, bool) {
+	if h == nil {
+		return 0, false
+	}
+	h.mutex.Lock()
+	sorted := append([]time.Duration(nil), h.samples...)
+	h.mutex.Unlock()
+	if len(sorted) < minHedgeSamples {
+		return 0, false
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(h.settings.Percentile/100*float64(len(sorted)))) - 1
+	rank = min(max(rank, 0), len(sorted)-1)
+	return max(sorted[rank], h.settings.MinDelay), true
+}
+
+// attemptResult is what one request of a possibly hedged attempt came back with
+type attemptResult[T any] struct {
+	value T
+	done  bool
+	err   error
+	hedge bool // the result is the hedge's rather than the first request's
+}
+
+// hedged makes one attempt at a lookup with call, which reports done=false as retry's attempts do.
+// If no answer has come back after h's delay, call is made a second time alongside the first and the
+// first done answer is used; the other request is cancelled. When both fail, the later failure is
+// returned for retry to act on. A call that fails before the delay isn't hedged.
+func hedged[T any](ctx context.Context, h *hedger, call func(ctx context.Context) (T, bool, error)) (T, bool, error) {
+	delay, ok := h.delay()
+	start := time.Now()
+	if !ok {
+		value, done, err := call(ctx)
+		if h != nil && done {
+			h.observe(time.Since(start))
+		}
+		return value, done, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan attemptResult[T], 2)
+	launch := func(hedge bool) {
+		go func() {
+			value, done, err := call(ctx)
+			results <- attemptResult[T]{value: value, done: done, err: err, hedge: hedge}
+		}()
+	}
+	launch(false)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	hedgeAfter := timer.C
+	running := 1
+	for {
+		select {
+		case <-hedgeAfter:
+			hedgeAfter = nil
+			hedgesSent.WithLabelValues(h.label).Inc()
+			launch(true)
+			running++
+		case result := <-results:
+			running--
+			if result.done {
+				if result.hedge {
+					hedgesWon.WithLabelValues(h.label).Inc()
+				}
+				h.observe(time.Since(start))
+				return result.value, true, result.err
+			}
+			if running == 0 {
+				return result.value, false, result.err
+			}
+		}
+	}
+}
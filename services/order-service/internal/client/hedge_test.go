@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"order-service/internal/models"
+)
+
+// primedHedger returns a hedger that has seen 20 lookups taking 1ms to 20ms
+func primedHedger(settings HedgeSettings) *hedger {
+	h := newHedger("test", settings)
+	for i := 1; i <= minHedgeSamples; i++ {
+		h.observe(time.Duration(i) * time.Millisecond)
+	}
+	return h
+}
+
+func TestHedger_DelayFollowsThePercentile(t *testing.T) {
+	if newHedger("test", DefaultHedgeSettings) != nil {
+		t.Fatal("expected hedging off by default")
+	}
+	h := newHedger("test", HedgeSettings{Percentile: 90})
+	if _, ok := h.delay(); ok {
+		t.Fatal("expected no hedging before enough lookups are seen")
+	}
+
+	if delay, ok := primedHedger(HedgeSettings{Percentile: 90}).delay(); !ok || delay != 18*time.Millisecond {
+		t.Errorf("expected the 90th percentile, 18ms, got %s %v", delay, ok)
+	}
+	if delay, _ := primedHedger(HedgeSettings{Percentile: 50, MinDelay: 15 * time.Millisecond}).delay(); delay != 15*time.Millisecond {
+		t.Errorf("expected the minimum delay over a faster percentile, got %s", delay)
+	}
+}
+
+func TestHedged_TakesTheFirstAnswer(t *testing.T) {
+	h := primedHedger(HedgeSettings{Percentile: 50})
+	var calls atomic.Int32
+	value, done, err := hedged(context.Background(), h, func(ctx context.Context) (string, bool, error) {
+		if calls.Add(1) == 1 {
+			<-ctx.Done() // the first request hangs until the hedge's answer cancels it
+			return "", false, ctx.Err()
+		}
+		return "hedge", true, nil
+	})
+	if value != "hedge" || !done || err != nil || calls.Load() != 2 {
+		t.Fatalf("expected the hedge's answer, got %q %v %v after %d calls", value, done, err, calls.Load())
+	}
+
+	// A request that fails before the delay is left to retry rather than hedged
+	calls.Store(0)
+	_, done, err = hedged(context.Background(), h, func(ctx context.Context) (string, bool, error) {
+		calls.Add(1)
+		return "", false, errors.New("refused")
+	})
+	if done || err == nil || calls.Load() != 1 {
+		t.Errorf("expected the failure returned without a hedge, got %v %v after %d calls", done, err, calls.Load())
+	}
+}
+
+func TestServiceClient_HedgesSlowProductLookups(t *testing.T) {
+	products := map[string]models.Product{"p1": {ID: "p1", Name: "Mouse"}}
+	fast := productServer(t, products)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	t.Cleanup(slow.Close)
+
+	c := NewServiceClient("", slow.URL, "", DefaultBreakerSettings, DefaultBalancerSettings, DefaultHTTPSettings)
+	c.HedgeProductLookups(HedgeSettings{Percentile: 95, MinDelay: time.Millisecond})
+	for i := 0; i < minHedgeSamples; i++ {
+		c.productService.hedger.observe(20 * time.Millisecond)
+	}
+	c.SetURLs(nil, []string{slow.URL, fast.URL})
+
+	start := time.Now()
+	product, err := c.GetProduct(context.Background(), "p1")
+	if err != nil || product.Name != "Mouse" {
+		t.Fatalf("expected the product from the fast instance, got %+v, %v", product, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the hedge to answer before the slow instance, took %s", elapsed)
+	}
+	if states := c.InstanceStates()["product_service"]; states[slow.URL] != states[fast.URL] {
+		t.Errorf("expected the cancelled request not held against the slow instance, got %v", states)
+	}
+}
@@ -1,10 +1,18 @@
 package client
 
-import "order-service/internal/models"
+import (
+	"context"
+	"order-service/internal/models"
+)
 
-// OrderValidationClient abstracts the validation operations needed by the order handler.
-// Implemented by ServiceClient; enables mocking in tests.
+// OrderValidationClient abstracts the validation and stock operations needed by the order handler.
+// Implemented by ServiceClient; enables mocking in tests. Calls are abandoned once ctx is done.
 type OrderValidationClient interface {
-	CheckUserExists(userID string) error
-	ValidateOrderItems(items []models.CreateOrderItem) ([]models.OrderItem, error)
+	CheckUserExists(ctx context.Context, userID string) error
+	GetUser(ctx context.Context, userID string) (*models.User, error)
+	ValidateOrderItems(ctx context.Context, items []models.CreateOrderItem) ([]models.OrderItem, error)
+	GetShippingAddress(ctx context.Context, userID, addressID string) (*models.Address, error)
+	ReserveStock(ctx context.Context, productID string, quantity int, orderID string) (string, error)
+	ReleaseStock(ctx context.Context, productID, reservationID string) error
+	CommitStock(ctx context.Context, productID, reservationID string) error
 }
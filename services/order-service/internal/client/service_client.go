@@ -1,127 +1,534 @@
 package client
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
+	"ecommerce/pkg/metrics"
+	"ecommerce/pkg/requestid"
+	productv1 "ecommerce/pkg/proto/product/v1"
+	userv1 "ecommerce/pkg/proto/user/v1"
 	"order-service/internal/models"
+
+	"google.golang.org/grpc"
 )
 
+// serviceKeyHeader carries this service's API key on internal calls
+const serviceKeyHeader = "X-Service-Key"
+
 // ServiceClient handles communication with other microservices
 type ServiceClient struct {
+	serviceKey     string
+	userService    *dependency
+	productService *dependency
+	users          userv1.UserServiceClient       // when set, users are looked up over gRPC
+	products       productv1.ProductServiceClient // when set, products are looked up over gRPC
+}
+
+// dependency is a service ServiceClient calls, with the HTTP client, circuit breaker, and balancer
+// across its instances that its calls go through
+type dependency struct {
+	name       string
+	label      string // identifies the service in breaker states and metrics
 	httpClient *http.Client
-	userServiceURL    string
-	productServiceURL string
+	timeout    time.Duration // how long one gRPC call may take, 0 for no limit
+	breaker    *Breaker
+	balancer   *Balancer
+	hedger     *hedger // when set, slow lookups are hedged with a second request
+}
+
+var serviceCallDuration = metrics.NewHistogramVec("service_client_request_duration_seconds",
+	"How long calls to other services took, by service, method, and outcome", metrics.DefBuckets, "service", "method", "outcome")
+
+// do sends req and records how long the service took to answer. The outcome is the status class,
+// such as 2xx or 5xx, or error when no response came back.
+func (d *dependency) do(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := d.httpClient.Do(req)
+
+	outcome := "error"
+	if err == nil {
+		outcome = strconv.Itoa(resp.StatusCode/100) + "xx"
+	}
+	serviceCallDuration.WithLabelValues(d.label, req.Method, outcome).Observe(time.Since(start).Seconds())
+	return resp, err
 }
 
-// NewServiceClient creates a new service client for inter-service communication
-func NewServiceClient(userServiceURL, productServiceURL string) *ServiceClient {
+// NewServiceClient creates a new service client for inter-service communication, calling one instance
+// of each service until SetURLs names more.
+// serviceKey is sent as X-Service-Key so downstream services can tell internal calls from end users.
+// Calls to each service go through their own circuit breaker, configured by breakerSettings, are
+// spread across its instances as balancerSettings says, and time out as httpSettings says.
+func NewServiceClient(userServiceURL, productServiceURL, serviceKey string, breakerSettings BreakerSettings, balancerSettings BalancerSettings, httpSettings HTTPSettings) *ServiceClient {
+	transport := newTransport(httpSettings)
 	return &ServiceClient{
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+		serviceKey: serviceKey,
+		userService: &dependency{
+			name:       "user service",
+			label:      "user_service",
+			httpClient: &http.Client{Transport: transport, Timeout: httpSettings.UserServiceTimeout},
+			timeout:    httpSettings.UserServiceTimeout,
+			breaker:    NewBreaker(breakerSettings),
+			balancer:   NewBalancer("user_service", []string{userServiceURL}, balancerSettings),
+		},
+		productService: &dependency{
+			name:       "product service",
+			label:      "product_service",
+			httpClient: &http.Client{Transport: transport, Timeout: httpSettings.ProductServiceTimeout},
+			timeout:    httpSettings.ProductServiceTimeout,
+			breaker:    NewBreaker(breakerSettings),
+			balancer:   NewBalancer("product_service", []string{productServiceURL}, balancerSettings),
 		},
-		userServiceURL:    userServiceURL,
-		productServiceURL: productServiceURL,
 	}
 }
 
-// UserServiceResponse represents the response from user service
-type UserServiceResponse struct {
-	Success bool        `json:"success"`
-	Data    models.User `json:"data"`
-	Error   string      `json:"error"`
+// UseGRPC makes GetUser and GetProduct call the user and product services' gRPC APIs over the given
+// connections instead of their REST ones. A nil connection leaves that service's lookups on REST.
+// Call it before the client is used.
+func (c *ServiceClient) UseGRPC(users, products grpc.ClientConnInterface) {
+	if users != nil {
+		c.users = userv1.NewUserServiceClient(users)
+	}
+	if products != nil {
+		c.products = productv1.NewProductServiceClient(products)
+	}
+}
+
+// HedgeProductLookups hedges GetProduct as settings say: a lookup product service is slow to answer
+// is sent again to the next instance, and the first answer is used. Lookups are idempotent, so the
+// extra request is harmless. Call it before the client is used.
+func (c *ServiceClient) HedgeProductLookups(settings HedgeSettings) {
+	c.productService.hedger = newHedger(c.productService.label, settings)
+}
+
+// SetURLs points the client at the instances of the user and product services, such as when they
+// have moved or been scaled. Calls are spread across each service's instances.
+func (c *ServiceClient) SetURLs(userServiceURLs, productServiceURLs []string) {
+	c.userService.balancer.SetURLs(userServiceURLs)
+	c.productService.balancer.SetURLs(productServiceURLs)
+}
+
+// UserServiceURLs returns the URLs of the user service instances calls are spread across
+func (c *ServiceClient) UserServiceURLs() []string {
+	return c.userService.balancer.URLs()
+}
+
+// ProductServiceURLs returns the URLs of the product service instances calls are spread across
+func (c *ServiceClient) ProductServiceURLs() []string {
+	return c.productService.balancer.URLs()
+}
+
+// BreakerStates returns the state of the circuit breaker in front of each service
+func (c *ServiceClient) BreakerStates() map[string]string {
+	return map[string]string{
+		c.userService.label:    c.userService.breaker.State(),
+		c.productService.label: c.productService.breaker.State(),
+	}
+}
+
+// InstanceStates returns whether each instance of each service is in rotation, by service and URL
+func (c *ServiceClient) InstanceStates() map[string]map[string]string {
+	return map[string]map[string]string{
+		c.userService.label:    c.userService.balancer.States(),
+		c.productService.label: c.productService.balancer.States(),
+	}
+}
+
+// PingUserService checks that an instance of user service answers its liveness probe
+func (c *ServiceClient) PingUserService(ctx context.Context) error {
+	return c.ping(ctx, c.userService)
+}
+
+// PingProductService checks that an instance of product service answers its liveness probe
+func (c *ServiceClient) PingProductService(ctx context.Context) error {
+	return c.ping(ctx, c.productService)
+}
+
+// ping calls the probe of each of a service's instances in turn until one answers. It bypasses the
+// breaker and balancer, so a probe reports whether the service is reachable now rather than what
+// recent calls saw, and its result doesn't count towards opening the breaker or ejecting an instance.
+func (c *ServiceClient) ping(ctx context.Context, service *dependency) error {
+	err := fmt.Errorf("%s has no instances", service.name)
+	for _, url := range service.balancer.URLs() {
+		if err = pingInstance(ctx, service, url+"/healthz"); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// pingInstance calls one instance's probe once
+func pingInstance(ctx context.Context, service *dependency, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	requestid.Propagate(req)
+
+	resp, err := service.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %w", service.name, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", service.name, resp.StatusCode)
+	}
+	return nil
 }
 
-// ProductServiceResponse represents the response from product service
-type ProductServiceResponse struct {
-	Success bool           `json:"success"`
-	Data    models.Product `json:"data"`
-	Error   string         `json:"error"`
+// ErrNotFound is returned when a downstream service reports that a resource does not exist
+var ErrNotFound = errors.New("resource not found")
+
+// ErrConflict is returned when a downstream service rejects a request because of the resource's state,
+// such as a stock reservation for more units than are available
+var ErrConflict = errors.New("request conflicts with current state")
+
+// ErrUserInactive is returned when the user exists but their account has been deactivated
+var ErrUserInactive = errors.New("user account is deactivated")
+
+// serviceResponse represents the standard response envelope returned by the other services
+type serviceResponse struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error"`
 }
 
 // GetUser retrieves user information from the user service
-func (c *ServiceClient) GetUser(userID string) (*models.User, error) {
-	url := fmt.Sprintf("%s/users/%s", c.userServiceURL, userID)
+func (c *ServiceClient) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	if c.users != nil {
+		return c.getUserRPC(ctx, userID)
+	}
+
+	path := fmt.Sprintf("/v1/users/%s", userID)
+	var user models.User
+	if err := c.getJSON(ctx, c.userService, path, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetProduct retrieves product information from the product service, priced in the order currency
+func (c *ServiceClient) GetProduct(ctx context.Context, productID string) (*models.Product, error) {
+	if c.products != nil {
+		return c.getProductRPC(ctx, productID)
+	}
+
+	path := fmt.Sprintf("/v1/products/%s?currency=%s", productID, models.OrderCurrency)
+	var product models.Product
+	if err := c.getJSON(ctx, c.productService, path, &product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// GetShippingAddress retrieves a shipping address from the user service's internal routes, which
+// take the service key. When addressID is empty the user's default shipping address is returned.
+func (c *ServiceClient) GetShippingAddress(ctx context.Context, userID, addressID string) (*models.Address, error) {
+	path := fmt.Sprintf("/v1/internal/users/%s/addresses/%s", userID, addressID)
+	if addressID == "" {
+		path = fmt.Sprintf("/v1/internal/users/%s/addresses/default?type=shipping", userID)
+	}
+	var address models.Address
+	if err := c.getJSON(ctx, c.userService, path, &address); err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// getJSON performs a GET request for path with retries and decodes the data field of the
+// standard response envelope into out. Server errors and network failures are
+// retried with exponential backoff, each attempt on the next instance; a 404 is
+// returned immediately as ErrNotFound. Slow attempts are hedged when the service has a hedger.
+func (c *ServiceClient) getJSON(ctx context.Context, service *dependency, path string, out interface{}) error {
+	return retry(ctx, service, func() (bool, error) {
+		data, done, err := hedged(ctx, service.hedger, func(ctx context.Context) (json.RawMessage, bool, error) {
+			return c.getEnvelope(ctx, service, path)
+		})
+		if !done || err != nil {
+			return done, err
+		}
+		if err := json.Unmarshal(data, out); err != nil {
+			return true, fmt.Errorf("failed to decode %s data: %w", service.name, err)
+		}
+		return true, nil
+	})
+}
+
+// getEnvelope makes one GET request for path to the next instance and returns the data field of the
+// response envelope, reporting done=false as retry's attempts do
+func (c *ServiceClient) getEnvelope(ctx context.Context, service *dependency, path string) (json.RawMessage, bool, error) {
+	inst := service.balancer.pick()
+	if inst == nil {
+		return nil, true, fmt.Errorf("%s has no instances", service.name)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, inst.url+path, nil)
+	if err != nil {
+		return nil, true, err
+	}
+	requestid.Propagate(req)
+	if c.serviceKey != "" {
+		req.Header.Set(serviceKeyHeader, c.serviceKey)
+	}
+
+	resp, err := service.do(req)
+	if err != nil {
+		service.report(ctx, inst, false)
+		return nil, false, fmt.Errorf("failed to call %s: %w", service.name, err)
+	}
+	var data json.RawMessage
+	done, err := decodeEnvelope(resp, service.name, &data)
+	service.report(ctx, inst, done)
+	return data, done, err
+}
+
+// report records with the balancer whether a call to inst was answered. A call the caller gave up on,
+// or a hedged request that lost the race, says nothing about the instance's health, so it isn't recorded.
+func (d *dependency) report(ctx context.Context, inst *instance, answered bool) {
+	switch {
+	case ctx.Err() != nil:
+	case answered:
+		d.balancer.success(inst)
+	default:
+		d.balancer.failure(inst)
+	}
+}
+
+// retry makes up to three attempts at a call to service, backing off exponentially between them.
+// attempt reports done=false when it failed in a way that may pass if tried again. Each attempt goes
+// through the service's breaker, and no more are made once it opens or ctx is done.
+func retry(ctx context.Context, service *dependency, attempt func() (done bool, err error)) error {
 	var lastErr error
-	for attempt := 0; attempt < 3; attempt++ {
-		resp, err := c.httpClient.Get(url)
-		if err != nil {
-			lastErr = fmt.Errorf("failed to call user service: %w", err)
-		} else {
-			defer resp.Body.Close()
-			if resp.StatusCode == http.StatusOK {
-				var userResp UserServiceResponse
-				if err := json.NewDecoder(resp.Body).Decode(&userResp); err != nil {
-					return nil, fmt.Errorf("failed to decode user service response: %w", err)
-				}
-				if !userResp.Success {
-					return nil, fmt.Errorf("user service error: %s", userResp.Error)
-				}
-				return &userResp.Data, nil
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(time.Duration(math.Pow(2, float64(i-1))) * 100 * time.Millisecond):
+			case <-ctx.Done():
+				return lastErr
+			}
+		}
+
+		if err := service.breaker.Allow(); err != nil {
+			if lastErr != nil {
+				return lastErr
 			}
-			lastErr = fmt.Errorf("user service returned status %d", resp.StatusCode)
+			return fmt.Errorf("%s: %w", service.name, err)
 		}
-		time.Sleep(time.Duration(math.Pow(2, float64(attempt))) * 100 * time.Millisecond)
+		done, err := attempt()
+		if done {
+			service.breaker.Success()
+			return err
+		}
+		if ctx.Err() != nil {
+			// A call the caller gave up on says nothing about the service's health
+			service.breaker.Abandon()
+			return err
+		}
+		service.breaker.Failure()
+		lastErr = err
 	}
-	return nil, lastErr
+	return lastErr
 }
 
-// GetProduct retrieves product information from the product service
-func (c *ServiceClient) GetProduct(productID string) (*models.Product, error) {
-	url := fmt.Sprintf("%s/products/%s", c.productServiceURL, productID)
-	var lastErr error
-	for attempt := 0; attempt < 3; attempt++ {
-		resp, err := c.httpClient.Get(url)
-		if err != nil {
-			lastErr = fmt.Errorf("failed to call product service: %w", err)
+// postJSON sends body as JSON to path on the next instance and decodes the data field of the response
+// envelope into out (which may be nil). It is not retried, since the calls it makes are not idempotent.
+func (c *ServiceClient) postJSON(ctx context.Context, service *dependency, path string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	inst := service.balancer.pick()
+	if inst == nil {
+		return fmt.Errorf("%s has no instances", service.name)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, inst.url+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	requestid.Propagate(req)
+	if c.serviceKey != "" {
+		req.Header.Set(serviceKeyHeader, c.serviceKey)
+	}
+
+	if err := service.breaker.Allow(); err != nil {
+		return fmt.Errorf("%s: %w", service.name, err)
+	}
+	resp, err := service.do(req)
+	if err != nil {
+		// A call the caller gave up on says nothing about the service's health
+		if ctx.Err() != nil {
+			service.breaker.Abandon()
 		} else {
-			defer resp.Body.Close()
-			if resp.StatusCode == http.StatusOK {
-				var productResp ProductServiceResponse
-				if err := json.NewDecoder(resp.Body).Decode(&productResp); err != nil {
-					return nil, fmt.Errorf("failed to decode product service response: %w", err)
-				}
-				if !productResp.Success {
-					return nil, fmt.Errorf("product service error: %s", productResp.Error)
-				}
-				return &productResp.Data, nil
-			}
-			lastErr = fmt.Errorf("product service returned status %d", resp.StatusCode)
+			service.breaker.Failure()
 		}
-		time.Sleep(time.Duration(math.Pow(2, float64(attempt))) * 100 * time.Millisecond)
+		service.report(ctx, inst, false)
+		return fmt.Errorf("failed to call %s: %w", service.name, err)
+	}
+	if resp.StatusCode == http.StatusConflict {
+		service.breaker.Success()
+		service.report(ctx, inst, true)
+		resp.Body.Close()
+		return fmt.Errorf("%s: %w", service.name, ErrConflict)
 	}
-	return nil, lastErr
+
+	done, err := decodeEnvelope(resp, service.name, out)
+	service.report(ctx, inst, done)
+	if done {
+		service.breaker.Success()
+	} else {
+		service.breaker.Failure()
+	}
+	return err
 }
 
-// ValidateOrderItems validates all items in an order by checking with services
-func (c *ServiceClient) ValidateOrderItems(items []models.CreateOrderItem) ([]models.OrderItem, error) {
-	var orderItems []models.OrderItem
+// decodeEnvelope reads a standard response envelope. It reports done=false
+// when the failure is transient and the request may be retried.
+func decodeEnvelope(resp *http.Response, service string, out interface{}) (bool, error) {
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return true, fmt.Errorf("%s: %w", service, ErrNotFound)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return false, fmt.Errorf("%s returned status %d", service, resp.StatusCode)
+	}
 
-	for _, item := range items {
-		// Get product information
-		product, err := c.GetProduct(item.ProductID)
-		if err != nil {
-			return nil, fmt.Errorf("invalid product %s: %w", item.ProductID, err)
+	var envelope serviceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return true, fmt.Errorf("failed to decode %s response: %w", service, err)
+	}
+	if !envelope.Success {
+		return true, fmt.Errorf("%s error: %s", service, envelope.Error)
+	}
+	if out == nil {
+		return true, nil
+	}
+	if err := json.Unmarshal(envelope.Data, out); err != nil {
+		return true, fmt.Errorf("failed to decode %s data: %w", service, err)
+	}
+	return true, nil
+}
+
+// maxConcurrentItemLookups bounds how many product lookups one order validation runs at once
+const maxConcurrentItemLookups = 8
+
+// ValidateOrderItems validates all items in an order by checking with services. Products are looked
+// up concurrently, and every rejected line is reported together as a *models.ItemValidationErrors.
+func (c *ServiceClient) ValidateOrderItems(ctx context.Context, items []models.CreateOrderItem) ([]models.OrderItem, error) {
+	return validateOrderItems(ctx, items, c.GetProduct)
+}
+
+// productLookup fetches a product by ID
+type productLookup func(ctx context.Context, productID string) (*models.Product, error)
+
+// validateOrderItems checks every line of an order against the products getProduct returns
+func validateOrderItems(ctx context.Context, items []models.CreateOrderItem, getProduct productLookup) ([]models.OrderItem, error) {
+	orderItems := make([]models.OrderItem, len(items))
+	itemErrs := make([]*models.ItemValidationError, len(items))
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, maxConcurrentItemLookups)
+	for i, item := range items {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, item models.CreateOrderItem) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			orderItems[i], itemErrs[i] = validateOrderItem(ctx, i, item, getProduct)
+		}(i, item)
+	}
+	wg.Wait()
+
+	var rejected []*models.ItemValidationError
+	for _, itemErr := range itemErrs {
+		if itemErr != nil {
+			rejected = append(rejected, itemErr)
 		}
+	}
+	if len(rejected) > 0 {
+		return nil, &models.ItemValidationErrors{Errors: rejected}
+	}
+	return orderItems, nil
+}
 
-		// Check stock availability
-		if product.Stock < item.Quantity {
-			return nil, fmt.Errorf("insufficient stock for product %s: available %d, requested %d", 
-				product.Name, product.Stock, item.Quantity)
+// validateOrderItem looks up the product for line i of an order and checks the requested quantity
+func validateOrderItem(ctx context.Context, i int, item models.CreateOrderItem, getProduct productLookup) (models.OrderItem, *models.ItemValidationError) {
+	// Get product information
+	product, err := getProduct(ctx, item.ProductID)
+	if err != nil {
+		return models.OrderItem{}, &models.ItemValidationError{
+			ItemIndex: i,
+			ProductID: item.ProductID,
+			Code:      models.ItemErrorInvalidProduct,
+			Message:   fmt.Sprintf("invalid product %s: %v", item.ProductID, err),
+			Requested: item.Quantity,
+			Err:       err,
 		}
+	}
 
-		// Create order item
-		orderItem := models.NewOrderItem(product.ID, product.Name, product.Price, item.Quantity)
-		orderItems = append(orderItems, orderItem)
+	// Check order quantity limits and stock availability
+	if itemErr := product.CheckOrderQuantity(i, item.Quantity); itemErr != nil {
+		return models.OrderItem{}, itemErr
 	}
 
-	return orderItems, nil
+	// Create order item at the price in effect now, so active sales are honoured
+	orderItem := models.NewOrderItem(product.ID, product.Name, product.UnitPrice(), item.Quantity)
+	orderItem.Digital = product.IsDigital()
+	orderItem.WeightKg = product.WeightKg
+	if product.NeedsBackorder(item.Quantity) {
+		orderItem.Status = models.ItemStatusBackordered
+	}
+	return orderItem, nil
 }
 
-// CheckUserExists verifies that a user exists
-func (c *ServiceClient) CheckUserExists(userID string) error {
-	_, err := c.GetUser(userID)
-	return err
+// stockReservation is the part of product service's reservation response order service needs
+type stockReservation struct {
+	ID string `json:"id"`
+}
+
+// ReserveStock sets quantity units of a product aside for an order and returns the reservation ID
+func (c *ServiceClient) ReserveStock(ctx context.Context, productID string, quantity int, orderID string) (string, error) {
+	path := fmt.Sprintf("/v1/products/%s/reserve", productID)
+	body := map[string]interface{}{
+		"quantity": quantity,
+		"order_id": orderID,
+	}
+
+	var reservation stockReservation
+	if err := c.postJSON(ctx, c.productService, path, body, &reservation); err != nil {
+		return "", err
+	}
+	return reservation.ID, nil
+}
+
+// ReleaseStock returns a reservation's units to the product's stock
+func (c *ServiceClient) ReleaseStock(ctx context.Context, productID, reservationID string) error {
+	path := fmt.Sprintf("/v1/products/%s/release", productID)
+	return c.postJSON(ctx, c.productService, path, map[string]string{"reservation_id": reservationID}, nil)
+}
+
+// CommitStock turns a reservation into a sale so it no longer expires
+func (c *ServiceClient) CommitStock(ctx context.Context, productID, reservationID string) error {
+	path := fmt.Sprintf("/v1/products/%s/commit", productID)
+	return c.postJSON(ctx, c.productService, path, map[string]string{"reservation_id": reservationID}, nil)
+}
+
+// CheckUserExists verifies that a user exists and has not been deactivated
+func (c *ServiceClient) CheckUserExists(ctx context.Context, userID string) error {
+	user, err := c.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.Active {
+		return ErrUserInactive
+	}
+	return nil
 }
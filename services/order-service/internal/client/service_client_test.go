@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"ecommerce/pkg/metrics"
+	"order-service/internal/models"
+)
+
+// productServer serves products from a fixed set in the product service's response envelope
+func productServer(t *testing.T, products map[string]models.Product) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		product, exists := products[strings.TrimPrefix(r.URL.Path, "/v1/products/")]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": product})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestValidateOrderItems_OrderQuantityLimits(t *testing.T) {
+	server := productServer(t, map[string]models.Product{
+		"pen":   {ID: "pen", Name: "Pen", Price: 1, Stock: 100},
+		"paper": {ID: "paper", Name: "Paper", Price: 5, Stock: 100, MinOrderQty: 10, MaxOrderQty: 50},
+		"ebook": {ID: "ebook", Name: "E-book", Price: 8, Kind: models.ProductKindDigital, MaxOrderQty: 5},
+		"console": {ID: "console", Name: "Console", Price: 400, Stock: 2, AllowBackorder: true, MaxOrderQty: 10},
+	})
+	c := NewServiceClient("", server.URL, "", DefaultBreakerSettings, DefaultBalancerSettings, DefaultHTTPSettings)
+
+	cases := []struct {
+		name     string
+		items    []models.CreateOrderItem
+		wantCode string
+		wantLine int
+	}{
+		{"within limits", []models.CreateOrderItem{{ProductID: "pen", Quantity: 1}, {ProductID: "paper", Quantity: 10}}, "", 0},
+		{"below minimum", []models.CreateOrderItem{{ProductID: "pen", Quantity: 1}, {ProductID: "paper", Quantity: 9}}, models.ItemErrorBelowMinimum, 1},
+		{"above maximum", []models.CreateOrderItem{{ProductID: "paper", Quantity: 51}}, models.ItemErrorAboveMaximum, 0},
+		{"insufficient stock", []models.CreateOrderItem{{ProductID: "pen", Quantity: 101}}, models.ItemErrorInsufficientStock, 0},
+		{"digital without stock", []models.CreateOrderItem{{ProductID: "ebook", Quantity: 2}}, "", 0},
+		{"digital above maximum", []models.CreateOrderItem{{ProductID: "ebook", Quantity: 6}}, models.ItemErrorAboveMaximum, 0},
+		{"covered by stock", []models.CreateOrderItem{{ProductID: "console", Quantity: 2}}, "", 0},
+		{"backordered beyond stock", []models.CreateOrderItem{{ProductID: "pen", Quantity: 1}, {ProductID: "console", Quantity: 5}}, "", 0},
+		{"backordered above maximum", []models.CreateOrderItem{{ProductID: "console", Quantity: 11}}, models.ItemErrorAboveMaximum, 0},
+		{"unknown product", []models.CreateOrderItem{{ProductID: "pen", Quantity: 1}, {ProductID: "ink", Quantity: 1}}, models.ItemErrorInvalidProduct, 1},
+	}
+	for _, tc := range cases {
+		items, err := c.ValidateOrderItems(context.Background(), tc.items)
+		if tc.wantCode == "" {
+			if err != nil || len(items) != len(tc.items) {
+				t.Errorf("%s: expected items to validate, got %v", tc.name, err)
+				continue
+			}
+			for i, item := range items {
+				if item.Digital != (item.ProductID == "ebook") {
+					t.Errorf("%s: line %d marked digital=%v", tc.name, i, item.Digital)
+				}
+				if backordered := item.Status == models.ItemStatusBackordered; backordered != (item.ProductID == "console" && item.Quantity > 2) {
+					t.Errorf("%s: line %d marked backordered=%v", tc.name, i, backordered)
+				}
+			}
+			continue
+		}
+
+		var itemErr *models.ItemValidationError
+		if !errors.As(err, &itemErr) {
+			t.Errorf("%s: expected an item validation error, got %v", tc.name, err)
+			continue
+		}
+		if itemErr.Code != tc.wantCode || itemErr.ItemIndex != tc.wantLine || itemErr.ProductID != tc.items[tc.wantLine].ProductID {
+			t.Errorf("%s: expected %s on line %d, got %+v", tc.name, tc.wantCode, tc.wantLine, itemErr)
+		}
+	}
+}
+
+func TestValidateOrderItems_ReportsEveryRejectedLine(t *testing.T) {
+	products := map[string]models.Product{
+		"paper": {ID: "paper", Name: "Paper", Price: 5, Stock: 100, MinOrderQty: 10},
+	}
+	var items []models.CreateOrderItem
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("p%d", i)
+		products[id] = models.Product{ID: id, Name: id, Price: 1, Stock: 10}
+		items = append(items, models.CreateOrderItem{ProductID: id, Quantity: 1})
+	}
+	c := NewServiceClient("", productServer(t, products).URL, "", DefaultBreakerSettings, DefaultBalancerSettings, DefaultHTTPSettings)
+
+	validated, err := c.ValidateOrderItems(context.Background(), items)
+	if err != nil || len(validated) != len(items) {
+		t.Fatalf("expected every line to validate, got %v", err)
+	}
+	for i, item := range validated {
+		if item.ProductID != items[i].ProductID {
+			t.Fatalf("expected line %d to stay in request order, got %s", i, item.ProductID)
+		}
+	}
+
+	items = append(items,
+		models.CreateOrderItem{ProductID: "ink", Quantity: 1},
+		models.CreateOrderItem{ProductID: "paper", Quantity: 2},
+	)
+	items[3].Quantity = 11
+	_, err = c.ValidateOrderItems(context.Background(), items)
+	var itemErrs *models.ItemValidationErrors
+	if !errors.As(err, &itemErrs) || len(itemErrs.Errors) != 3 {
+		t.Fatalf("expected three rejected lines, got %v", err)
+	}
+	want := []struct {
+		line int
+		code string
+	}{{3, models.ItemErrorInsufficientStock}, {20, models.ItemErrorInvalidProduct}, {21, models.ItemErrorBelowMinimum}}
+	for i, w := range want {
+		if got := itemErrs.Errors[i]; got.ItemIndex != w.line || got.Code != w.code {
+			t.Errorf("expected %s on line %d, got %+v", w.code, w.line, got)
+		}
+	}
+}
+
+func TestServiceClient_RecordsCallDurations(t *testing.T) {
+	server := productServer(t, map[string]models.Product{})
+	c := NewServiceClient("", server.URL, "", DefaultBreakerSettings, DefaultBalancerSettings, DefaultHTTPSettings)
+
+	if _, err := c.GetProduct(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), `service_client_request_duration_seconds_count{service="product_service",method="GET",outcome="4xx"}`) {
+		t.Fatalf("expected the call recorded by service and outcome, got\n%s", rec.Body.String())
+	}
+}
+
+func TestServiceClient_PingBypassesTheBreaker(t *testing.T) {
+	var unhealthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" || unhealthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(server.Close)
+	c := NewServiceClient(server.URL, "http://127.0.0.1:1", "", BreakerSettings{FailureThreshold: 1, OpenTimeout: time.Minute}, DefaultBalancerSettings, DefaultHTTPSettings)
+
+	if err := c.PingUserService(context.Background()); err != nil {
+		t.Fatalf("expected user service up, got %v", err)
+	}
+	unhealthy.Store(true)
+	if err := c.PingUserService(context.Background()); err == nil {
+		t.Fatal("expected an unhealthy user service reported")
+	}
+	if err := c.PingProductService(context.Background()); err == nil {
+		t.Fatal("expected an unreachable product service reported")
+	}
+	if states := c.BreakerStates(); states["user_service"] != BreakerClosed || states["product_service"] != BreakerClosed {
+		t.Fatalf("expected failed probes to leave the breakers closed, got %v", states)
+	}
+}
+
+func TestServiceClient_SetURLsRedirectsLaterCalls(t *testing.T) {
+	server := productServer(t, map[string]models.Product{"p1": {ID: "p1", Name: "Mouse"}})
+	c := NewServiceClient("", "http://127.0.0.1:1", "", BreakerSettings{}, DefaultBalancerSettings, DefaultHTTPSettings)
+
+	c.SetURLs(nil, []string{server.URL})
+	product, err := c.GetProduct(context.Background(), "p1")
+	if err != nil {
+		t.Fatalf("expected the product from the new URL, got %v", err)
+	}
+	if product.Name != "Mouse" {
+		t.Errorf("expected Mouse, got %+v", product)
+	}
+}
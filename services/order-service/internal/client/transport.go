@@ -0,0 +1,48 @@
+package client
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// HTTPSettings configures the HTTP clients ServiceClient uses. Every service gets its own timeout,
+// but their connections come from one pooled transport.
+type HTTPSettings struct {
+	UserServiceTimeout    time.Duration // how long one call to user service may take, 0 for no limit
+	ProductServiceTimeout time.Duration // how long one call to product service may take, 0 for no limit
+	MaxIdleConnsPerHost   int           // idle connections kept open to each service for reuse
+	IdleConnTimeout       time.Duration // how long an unused connection is kept open
+	KeepAlive             time.Duration // how often TCP keep-alives are sent on open connections
+	// TLS is used for calls to https URLs, presenting this service's certificate; nil uses Go's defaults
+	TLS *tls.Config
+}
+
+// DefaultHTTPSettings is the configuration used when none is given
+var DefaultHTTPSettings = HTTPSettings{
+	UserServiceTimeout:    10 * time.Second,
+	ProductServiceTimeout: 10 * time.Second,
+	MaxIdleConnsPerHost:   32,
+	IdleConnTimeout:       90 * time.Second,
+	KeepAlive:             30 * time.Second,
+}
+
+// newTransport creates the transport shared by the clients for every service. Go's default keeps only
+// two idle connections per host, so concurrent item lookups would open and close connections
+// to product service on every order.
+func newTransport(settings HTTPSettings) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: settings.KeepAlive,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConnsPerHost:   settings.MaxIdleConnsPerHost,
+		IdleConnTimeout:       settings.IdleConnTimeout,
+		TLSClientConfig:       settings.TLS,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
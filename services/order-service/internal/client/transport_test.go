@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServiceClient_TimesOutEachServiceSeparately(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success":true,"data":{"id":"u1","active":true}}`))
+	}))
+	t.Cleanup(func() {
+		close(release)
+		slow.Close()
+		fast.Close()
+	})
+
+	settings := DefaultHTTPSettings
+	settings.ProductServiceTimeout = 50 * time.Millisecond
+	c := NewServiceClient(fast.URL, slow.URL, "", BreakerSettings{FailureThreshold: 1, OpenTimeout: time.Minute}, DefaultBalancerSettings, settings)
+
+	// A product service that doesn't answer in time counts as a failure, as any network error does
+	if _, err := c.GetProduct(context.Background(), "p1"); err == nil {
+		t.Fatal("expected the product lookup to time out")
+	}
+	if states := c.BreakerStates(); states["product_service"] != BreakerOpen {
+		t.Fatalf("expected the product service circuit open, got %v", states)
+	}
+	if err := c.CheckUserExists(context.Background(), "u1"); err != nil {
+		t.Fatalf("expected user service to keep its own timeout, got %v", err)
+	}
+
+	transport := c.userService.httpClient.Transport
+	if transport != c.productService.httpClient.Transport || transport.(*http.Transport).MaxIdleConnsPerHost != settings.MaxIdleConnsPerHost {
+		t.Fatal("expected both services to share the tuned transport")
+	}
+}
+
+func TestServiceClient_CallsHTTPSServicesWithTheTLSConfig(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"success":true,"data":{"id":"u1","active":true}}`))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	// The test server's own certificate doubles as the client's
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	settings := DefaultHTTPSettings
+	settings.TLS = &tls.Config{RootCAs: roots, Certificates: server.TLS.Certificates}
+	c := NewServiceClient(server.URL, server.URL, "", DefaultBreakerSettings, DefaultBalancerSettings, settings)
+
+	if err := c.CheckUserExists(context.Background(), "u1"); err != nil {
+		t.Fatalf("expected the call to present the certificate, got %v", err)
+	}
+}
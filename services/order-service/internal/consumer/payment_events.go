@@ -0,0 +1,51 @@
+// Package consumer handles the events other services publish that change order data
+package consumer
+
+import (
+	"context"
+	"log/slog"
+	"ecommerce/pkg/events"
+	"order-service/internal/models"
+)
+
+// PaymentRecorder records a payment's outcome on its order.
+// Implemented by handlers.OrderHandler; enables mocking in tests.
+type PaymentRecorder interface {
+	ApplyPaymentEvent(ctx context.Context, event *models.PaymentEvent) error
+}
+
+// orderPaymentStatuses maps the payment statuses payment service reports to the order's payment
+// status. Authorizations are left out: this service has payments captured at once.
+var orderPaymentStatuses = map[string]models.PaymentStatus{
+	"pending":  models.PaymentPending,
+	"paid":     models.PaymentPaid,
+	"failed":   models.PaymentFailed,
+	"refunded": models.PaymentRefunded,
+}
+
+// PaymentEvents records on each order what payment service reports about its payment, such as a
+// pending payment succeeding or a refund made from payment service
+type PaymentEvents struct {
+	payments PaymentRecorder
+}
+
+// NewPaymentEvents creates a handler recording payments through payments
+func NewPaymentEvents(payments PaymentRecorder) *PaymentEvents {
+	return &PaymentEvents{payments: payments}
+}
+
+// Handle processes one payment event. Events are delivered at least once, and recording the same
+// status again changes nothing; a failure to save the order is returned for the event to be retried.
+func (c *PaymentEvents) Handle(ctx context.Context, msg events.Message) error {
+	event, err := events.DecodePaymentEvent(msg)
+	if err != nil {
+		// Retrying can't fix a malformed event
+		slog.ErrorContext(ctx, "Skipping undecodable payment event", "offset", msg.Offset, "error", err)
+		return nil
+	}
+	status, ok := orderPaymentStatuses[event.Data.Status]
+	if !ok {
+		return nil
+	}
+	return c.payments.ApplyPaymentEvent(ctx, &models.PaymentEvent{PaymentID: event.PaymentID, OrderID: event.OrderID, Status: status})
+}
@@ -0,0 +1,58 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"ecommerce/pkg/events"
+	"order-service/internal/models"
+)
+
+type fakePayments struct {
+	err      error
+	recorded []models.PaymentEvent
+}
+
+func (f *fakePayments) ApplyPaymentEvent(ctx context.Context, event *models.PaymentEvent) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.recorded = append(f.recorded, *event)
+	return nil
+}
+
+func paymentMessage(t *testing.T, event events.PaymentEvent) events.Message {
+	value, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events.Message{Topic: events.DefaultPaymentTopic, Key: event.OrderID, Value: value}
+}
+
+func TestPaymentEvents_RecordsPaymentsOnTheirOrders(t *testing.T) {
+	payments := &fakePayments{}
+	consumer := NewPaymentEvents(payments)
+
+	authorized := paymentMessage(t, events.PaymentEvent{ID: "e1", Type: events.PaymentAuthorized, PaymentID: "pay_1", OrderID: "o1", Data: events.Payment{Status: "authorized"}})
+	if err := consumer.Handle(context.Background(), authorized); err != nil || len(payments.recorded) != 0 {
+		t.Fatalf("expected authorizations to be ignored, got %v %v", err, payments.recorded)
+	}
+
+	succeeded := paymentMessage(t, events.PaymentEvent{ID: "e2", Type: events.PaymentSucceeded, PaymentID: "pay_1", OrderID: "o1", Data: events.Payment{Status: "paid"}})
+	if err := consumer.Handle(context.Background(), succeeded); err != nil {
+		t.Fatalf("handle failed: %v", err)
+	}
+	if len(payments.recorded) != 1 || payments.recorded[0] != (models.PaymentEvent{PaymentID: "pay_1", OrderID: "o1", Status: models.PaymentPaid}) {
+		t.Fatalf("expected o1 recorded paid, got %+v", payments.recorded)
+	}
+
+	payments.err = errors.New("database unavailable")
+	if err := consumer.Handle(context.Background(), succeeded); err == nil {
+		t.Error("expected a failed save to be retried")
+	}
+
+	if err := consumer.Handle(context.Background(), events.Message{Value: []byte("{")}); err != nil {
+		t.Errorf("expected a malformed event to be skipped, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"order-service/internal/models"
+)
+
+// Formats orders can be exported in
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// Line is one order item in an export. The order's own amounts repeat on each of its lines so every
+// line can be reconciled on its own.
+type Line struct {
+	OrderID       string  `json:"order_id"`
+	CreatedAt     string  `json:"created_at"`
+	Status        string  `json:"status"`
+	UserID        string  `json:"user_id"`
+	GuestEmail    string  `json:"guest_email"`
+	PaymentStatus string  `json:"payment_status"`
+	PaymentID     string  `json:"payment_id"`
+	Currency      string  `json:"currency"`
+	CouponCode    string  `json:"coupon_code"`
+	ProductID     string  `json:"product_id"`
+	ProductName   string  `json:"product_name"`
+	Quantity      int     `json:"quantity"`
+	UnitPrice     float64 `json:"unit_price"`
+	LineSubtotal  float64 `json:"line_subtotal"`
+	OrderSubtotal float64 `json:"order_subtotal"`
+	OrderDiscount float64 `json:"order_discount"`
+	OrderShipping float64 `json:"order_shipping"`
+	OrderTax      float64 `json:"order_tax"`
+	OrderTotal    float64 `json:"order_total"`
+}
+
+// header names the CSV columns, in the order Line.record writes them
+var header = []string{
+	"order_id", "created_at", "status", "user_id", "guest_email", "payment_status", "payment_id", "currency",
+	"coupon_code", "product_id", "product_name", "quantity", "unit_price", "line_subtotal", "order_subtotal",
+	"order_discount", "order_shipping", "order_tax", "order_total",
+}
+
+// Lines flattens an order into one line per item
+func Lines(order *models.Order) []Line {
+	lines := make([]Line, 0, len(order.Items))
+	for _, item := range order.Items {
+		lines = append(lines, Line{
+			OrderID:       order.ID,
+			CreatedAt:     order.CreatedAt.UTC().Format(time.RFC3339),
+			Status:        string(order.Status),
+			UserID:        order.UserID,
+			GuestEmail:    order.GuestEmail,
+			PaymentStatus: string(order.PaymentStatus),
+			PaymentID:     order.PaymentID,
+			Currency:      models.OrderCurrency,
+			CouponCode:    order.CouponCode,
+			ProductID:     item.ProductID,
+			ProductName:   item.ProductName,
+			Quantity:      item.Quantity,
+			UnitPrice:     item.Price,
+			LineSubtotal:  models.RoundCents(item.Subtotal),
+			OrderSubtotal: order.Subtotal,
+			OrderDiscount: order.Discount,
+			OrderShipping: order.ShippingCost,
+			OrderTax:      order.Tax,
+			OrderTotal:    order.Total,
+		})
+	}
+	return lines
+}
+
+// record formats the line as CSV fields
+func (l Line) record() []string {
+	return []string{
+		l.OrderID, l.CreatedAt, l.Status, cell(l.UserID), cell(l.GuestEmail), l.PaymentStatus, cell(l.PaymentID), l.Currency,
+		cell(l.CouponCode), cell(l.ProductID), cell(l.ProductName), strconv.Itoa(l.Quantity), amount(l.UnitPrice),
+		amount(l.LineSubtotal), amount(l.OrderSubtotal), amount(l.OrderDiscount), amount(l.OrderShipping),
+		amount(l.OrderTax), amount(l.OrderTotal),
+	}
+}
+
+// cell keeps free text from being read as a formula when the export is opened in a spreadsheet
+func cell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// amount formats money with two decimals
+func amount(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
+// Writer streams orders in an export format. Close must be called to finish the document.
+type Writer interface {
+	WriteOrder(order *models.Order) error
+	Close() error
+}
+
+// NewWriter creates a writer producing format on w
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{csv: csv.NewWriter(w)}, nil
+	case FormatJSON:
+		return &jsonWriter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// ContentType returns the MIME type of an export in format
+func ContentType(format string) string {
+	if format == FormatJSON {
+		return "application/json"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// csvWriter writes a header row, then one row per order line
+type csvWriter struct {
+	csv         *csv.Writer
+	wroteHeader bool
+}
+
+func (c *csvWriter) WriteOrder(order *models.Order) error {
+	if !c.wroteHeader {
+		if err := c.csv.Write(header); err != nil {
+			return err
+		}
+		c.wroteHeader = true
+	}
+	for _, line := range Lines(order) {
+		if err := c.csv.Write(line.record()); err != nil {
+			return err
+		}
+	}
+	c.csv.Flush()
+	return c.csv.Error()
+}
+
+func (c *csvWriter) Close() error {
+	if !c.wroteHeader {
+		if err := c.csv.Write(header); err != nil {
+			return err
+		}
+	}
+	c.csv.Flush()
+	return c.csv.Error()
+}
+
+// jsonWriter writes a JSON array of order lines, one element at a time
+type jsonWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func (j *jsonWriter) WriteOrder(order *models.Order) error {
+	for _, line := range Lines(order) {
+		separator := ","
+		if !j.started {
+			separator = "["
+			j.started = true
+		}
+		encoded, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(j.w, separator+"\n"+string(encoded)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *jsonWriter) Close() error {
+	closing := "\n]\n"
+	if !j.started {
+		closing = "[]\n"
+	}
+	_, err := io.WriteString(j.w, closing)
+	return err
+}
@@ -0,0 +1,64 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"order-service/internal/models"
+)
+
+func exportOrders(t *testing.T, format string, orders ...*models.Order) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, format)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	for _, order := range orders {
+		if err := writer.WriteOrder(order); err != nil {
+			t.Fatalf("WriteOrder failed: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriter_FlattensLineItems(t *testing.T) {
+	order := models.NewOrder("u1", []models.OrderItem{
+		models.NewOrderItem("p1", "Widget", 10, 2),
+		models.NewOrderItem("p2", "=HYPERLINK(\"http://x\")", 2.5, 1),
+	})
+	order.ApplyTax(4.5)
+
+	rows, err := csv.NewReader(bytes.NewReader(exportOrders(t, FormatCSV, order))).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	if len(rows) != 3 || rows[0][0] != "order_id" || len(rows[1]) != len(header) {
+		t.Fatalf("expected a header and a row per item, got %v", rows)
+	}
+	if rows[1][9] != "p1" || rows[1][11] != "2" || rows[1][13] != "20.00" || rows[2][18] != "27.00" {
+		t.Errorf("unexpected line %v", rows[1])
+	}
+	if rows[2][10] != `'=HYPERLINK("http://x")` {
+		t.Errorf("expected a formula-like name to be escaped, got %q", rows[2][10])
+	}
+
+	var lines []Line
+	if err := json.Unmarshal(exportOrders(t, FormatJSON, order, order), &lines); err != nil {
+		t.Fatalf("export is not valid JSON: %v", err)
+	}
+	if len(lines) != 4 || lines[0].OrderTax != 4.5 || lines[1].ProductName != `=HYPERLINK("http://x")` {
+		t.Fatalf("unexpected JSON lines %+v", lines)
+	}
+
+	if got := string(exportOrders(t, FormatJSON)); got != "[]\n" {
+		t.Errorf("expected an empty array for no orders, got %q", got)
+	}
+	if rows, _ := csv.NewReader(bytes.NewReader(exportOrders(t, FormatCSV))).ReadAll(); len(rows) != 1 {
+		t.Errorf("expected just the header for no orders, got %v", rows)
+	}
+}
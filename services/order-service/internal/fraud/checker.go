@@ -0,0 +1,85 @@
+package fraud
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"order-service/internal/models"
+	"order-service/internal/repository"
+)
+
+// FraudChecker screens new orders before they are placed. Implementations can apply local rules,
+// like RuleChecker, or ask an external fraud service.
+type FraudChecker interface {
+	// Check returns why the order looks fraudulent, or nothing if it looks fine
+	Check(ctx context.Context, order *models.Order) ([]string, error)
+}
+
+// Rules configures a RuleChecker. A zero limit turns its rule off.
+type Rules struct {
+	VelocityLimit  int           // orders a buyer may place within VelocityWindow before the next is held
+	VelocityWindow time.Duration // how far back a buyer's orders are counted
+	MaxOrderTotal  float64       // orders paying more than this are held
+}
+
+// RuleChecker holds orders that break simple rules: too many orders from one buyer in a short
+// time, an unusually large total, or a billing address in a different country to the shipping address
+type RuleChecker struct {
+	orders repository.OrderRepository
+	rules  Rules
+}
+
+// NewRuleChecker creates a checker applying rules, counting a buyer's earlier orders in orders
+func NewRuleChecker(orders repository.OrderRepository, rules Rules) *RuleChecker {
+	return &RuleChecker{
+		orders: orders,
+		rules:  rules,
+	}
+}
+
+// Check applies every rule to the order and returns the ones it breaks
+func (c *RuleChecker) Check(ctx context.Context, order *models.Order) ([]string, error) {
+	var reasons []string
+
+	if c.rules.VelocityLimit > 0 && c.rules.VelocityWindow > 0 {
+		recent, err := c.recentOrders(ctx, order, order.CreatedAt.Add(-c.rules.VelocityWindow))
+		if err != nil {
+			return nil, err
+		}
+		if recent >= c.rules.VelocityLimit {
+			reasons = append(reasons, fmt.Sprintf("buyer placed %d orders in the last %s", recent, c.rules.VelocityWindow))
+		}
+	}
+
+	if c.rules.MaxOrderTotal > 0 && order.Total > c.rules.MaxOrderTotal {
+		reasons = append(reasons, fmt.Sprintf("total of %.2f is over %.2f", order.Total, c.rules.MaxOrderTotal))
+	}
+
+	billing, shipping := order.BillingAddress, order.ShippingAddress
+	if billing != nil && shipping != nil && !strings.EqualFold(strings.TrimSpace(billing.Country), strings.TrimSpace(shipping.Country)) {
+		reasons = append(reasons, fmt.Sprintf("billing country %s doesn't match shipping country %s", billing.Country, shipping.Country))
+	}
+
+	return reasons, nil
+}
+
+// recentOrders counts the orders the buyer placed since from; guests are matched by their email
+func (c *RuleChecker) recentOrders(ctx context.Context, order *models.Order, from time.Time) (int, error) {
+	filter := &models.OrderFilter{UserID: order.UserID, From: &from}
+	orders, _, err := c.orders.List(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	if order.UserID != "" {
+		return len(orders), nil
+	}
+
+	count := 0
+	for _, earlier := range orders {
+		if earlier.UserID == "" && strings.EqualFold(earlier.GuestEmail, order.GuestEmail) {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,50 @@
+package fraud
+
+import (
+	"context"
+	"testing"
+	"time"
+	"order-service/internal/models"
+	"order-service/internal/repository"
+)
+
+func TestRuleChecker_Check(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	checker := NewRuleChecker(repo, Rules{VelocityLimit: 2, VelocityWindow: time.Hour, MaxOrderTotal: 500})
+	items := []models.OrderItem{models.NewOrderItem("p1", "Prod", 100, 1)}
+
+	order := models.NewOrder("u1", items)
+	order.ApplyTax(0)
+	if reasons, err := checker.Check(context.Background(), order); err != nil || len(reasons) != 0 {
+		t.Fatalf("expected a clean order, got %v %v", reasons, err)
+	}
+
+	// Orders older than the window don't count towards the limit
+	old := models.NewOrder("u1", items)
+	old.CreatedAt = time.Now().Add(-2 * time.Hour)
+	_ = repo.Create(context.Background(), old)
+	_ = repo.Create(context.Background(), models.NewOrder("u1", items))
+	_ = repo.Create(context.Background(), models.NewOrder("u2", items))
+	if reasons, _ := checker.Check(context.Background(), order); len(reasons) != 0 {
+		t.Fatalf("expected one recent order to be under the limit, got %v", reasons)
+	}
+	_ = repo.Create(context.Background(), models.NewOrder("u1", items))
+	if reasons, _ := checker.Check(context.Background(), order); len(reasons) != 1 {
+		t.Fatalf("expected the velocity rule to hold the order, got %v", reasons)
+	}
+
+	// Over the total, and paid with a card from another country
+	guest := models.NewGuestOrder("guest@example.com", items, "token")
+	guest.ShippingAddress = &models.Address{Country: "US"}
+	guest.BillingAddress = &models.Address{Country: "ng"}
+	guest.ApplyTax(500)
+	reasons, err := checker.Check(context.Background(), guest)
+	if err != nil || len(reasons) != 2 {
+		t.Fatalf("expected the total and the address mismatch to be flagged, got %v %v", reasons, err)
+	}
+	guest.BillingAddress.Country = "us"
+	guest.ApplyTax(0)
+	if reasons, _ := checker.Check(context.Background(), guest); len(reasons) != 0 {
+		t.Fatalf("expected a clean guest order, got %v", reasons)
+	}
+}
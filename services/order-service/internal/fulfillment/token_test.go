@@ -0,0 +1,46 @@
+package fulfillment
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenIssuer_IssueAndVerify(t *testing.T) {
+	issuer, err := NewTokenIssuer("secret", "https://downloads.example.com/", time.Hour)
+	if err != nil {
+		t.Fatalf("NewTokenIssuer failed: %v", err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	link := issuer.Issue("order-1", "ebook", now)
+	if !strings.HasPrefix(link.DownloadURL, "https://downloads.example.com/ebook?token=") {
+		t.Errorf("unexpected download URL %s", link.DownloadURL)
+	}
+	if !link.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected expiry an hour out, got %v", link.ExpiresAt)
+	}
+
+	orderID, productID, err := issuer.Verify(link.Token, now.Add(30*time.Minute))
+	if err != nil || orderID != "order-1" || productID != "ebook" {
+		t.Fatalf("expected token for order-1/ebook, got %s/%s (%v)", orderID, productID, err)
+	}
+	if _, _, err := issuer.Verify(link.Token, now.Add(time.Hour)); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expected ErrTokenExpired, got %v", err)
+	}
+
+	other, _ := NewTokenIssuer("other-secret", "https://downloads.example.com", time.Hour)
+	if _, _, err := other.Verify(link.Token, now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected a token signed with another secret to be rejected, got %v", err)
+	}
+	if _, _, err := issuer.Verify("not-a-token", now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for garbage, got %v", err)
+	}
+}
+
+func TestNewTokenIssuer_RequiresSecret(t *testing.T) {
+	if _, err := NewTokenIssuer("", "https://downloads.example.com", time.Hour); err == nil {
+		t.Error("expected an empty secret to be rejected")
+	}
+}
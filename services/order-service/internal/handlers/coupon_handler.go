@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+	"ecommerce/pkg/api"
+	"order-service/internal/models"
+	"order-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// CouponHandler manages the coupons customers can apply at checkout
+type CouponHandler struct {
+	repo repository.CouponRepository
+}
+
+// NewCouponHandler creates a new coupon handler
+func NewCouponHandler(repo repository.CouponRepository) *CouponHandler {
+	return &CouponHandler{repo: repo}
+}
+
+// CreateCoupon handles POST /coupons - creates a percentage or fixed-amount coupon
+func (h *CouponHandler) CreateCoupon(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.CreateCouponRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	coupon := &models.Coupon{
+		Code:      models.NormalizeCouponCode(req.Code),
+		Type:      req.Type,
+		Value:     req.Value,
+		ExpiresAt: req.ExpiresAt,
+		MaxUses:   req.MaxUses,
+		CreatedAt: time.Now(),
+	}
+	if err := coupon.Validate(); err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.repo.Create(coupon); err != nil {
+		api.WriteErrorCode(w, http.StatusConflict, CodeCouponCodeTaken, "Coupon code already exists")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Coupon created successfully",
+		Data:    coupon,
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListCoupons handles GET /coupons - lists coupons with how often each has been used
+func (h *CouponHandler) ListCoupons(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	coupons, err := h.repo.List()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing coupons", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve coupons")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    coupons,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetCoupon handles GET /coupons/{code} - retrieves a coupon
+func (h *CouponHandler) GetCoupon(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	coupon, err := h.repo.GetByCode(models.NormalizeCouponCode(mux.Vars(r)["code"]))
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeCouponNotFound, "Coupon not found")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    coupon,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteCoupon handles DELETE /coupons/{code} - withdraws a coupon; orders that used it keep their discount
+func (h *CouponHandler) DeleteCoupon(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := h.repo.Delete(models.NormalizeCouponCode(mux.Vars(r)["code"])); err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeCouponNotFound, "Coupon not found")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Coupon deleted successfully",
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+// Error codes the order service sends in the code of an error response, so clients can tell
+// failures apart without matching messages. Errors without one of these get a code named after
+// their status, such as NOT_FOUND.
+const (
+	CodeOrderNotFound           = "ORDER_NOT_FOUND"
+	CodeOrderInsufficientStock  = "ORDER_INSUFFICIENT_STOCK" // a product doesn't have the stock an item asks for
+	CodeOrderInvalidProduct     = "ORDER_INVALID_PRODUCT"    // an item names a product that doesn't exist
+	CodeOrderQuantityOutOfRange = "ORDER_QUANTITY_OUT_OF_RANGE"
+	CodeOrderItemsRejected      = "ORDER_ITEMS_REJECTED" // items were rejected for different reasons; data lists each
+	CodeOrderInvalidTransition  = "ORDER_INVALID_TRANSITION"
+	CodeOrderReservationExpired = "ORDER_RESERVATION_EXPIRED"
+	CodeOrderNotAmendable       = "ORDER_NOT_AMENDABLE"
+	CodeOrderAlreadyPaid        = "ORDER_ALREADY_PAID"
+	CodeOrderNotPayable         = "ORDER_NOT_PAYABLE"
+	CodeOrderChanged            = "ORDER_CHANGED" // the order changed while the request was handled; fetch it and try again
+	CodeOrderNotShippable       = "ORDER_NOT_SHIPPABLE"
+	CodeOrderAlreadyShipped     = "ORDER_ALREADY_SHIPPED"
+	CodeOrderBackordered        = "ORDER_BACKORDERED"
+	CodeOrderHeldForReview      = "ORDER_HELD_FOR_REVIEW"
+	CodeOrderNotInReview        = "ORDER_NOT_IN_REVIEW"
+	CodeOrderNotArchived        = "ORDER_NOT_ARCHIVED"
+	CodeOrderAlreadyClaimed     = "ORDER_ALREADY_CLAIMED"
+	CodeOrderInvalidClaimToken  = "ORDER_INVALID_CLAIM_TOKEN"
+	CodeInvalidUser             = "INVALID_USER" // the user service doesn't know the user
+	CodeInvalidShippingAddress  = "INVALID_SHIPPING_ADDRESS"
+	CodeInsufficientPoints      = "INSUFFICIENT_LOYALTY_POINTS"
+	CodePaymentsUnavailable     = "PAYMENTS_UNAVAILABLE" // no payment provider is configured
+	CodePaymentDeclined         = "PAYMENT_DECLINED"
+	CodeCouponNotFound          = "COUPON_NOT_FOUND"
+	CodeCouponExpired           = "COUPON_EXPIRED"
+	CodeCouponUsedUp            = "COUPON_USED_UP"
+	CodeCouponCodeTaken         = "COUPON_CODE_TAKEN"
+	CodeShipmentNotFound        = "SHIPMENT_NOT_FOUND"
+	CodeSubscriptionNotFound    = "SUBSCRIPTION_NOT_FOUND"
+	CodeWebhookNotFound         = "WEBHOOK_NOT_FOUND"
+)
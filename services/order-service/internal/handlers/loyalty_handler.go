@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"ecommerce/pkg/api"
+	"order-service/internal/loyalty"
+	"order-service/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+// LoyaltyHandler shows users the points they have earned and spent on orders
+type LoyaltyHandler struct {
+	program *loyalty.Program
+}
+
+// NewLoyaltyHandler creates a new loyalty handler
+func NewLoyaltyHandler(program *loyalty.Program) *LoyaltyHandler {
+	return &LoyaltyHandler{program: program}
+}
+
+// GetAccount handles GET /loyalty/{user_id} - returns a user's points balance and its history
+func (h *LoyaltyHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, err := h.program.Account(mux.Vars(r)["user_id"])
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving loyalty account", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve loyalty points")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    account,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"ecommerce/pkg/openapi"
+	"order-service/internal/models"
+)
+
+// orderFilterQuery is the query parameters orders are listed and exported by
+var orderFilterQuery = map[string]string{
+	"status":           "only orders in this status",
+	"user_id":          "only this user's orders",
+	"from":             "only orders created at or after this RFC 3339 time or YYYY-MM-DD date",
+	"to":               "only orders created before this RFC 3339 time or YYYY-MM-DD date",
+	"include_archived": "true to include archived orders",
+}
+
+// OpenAPI describes the order service's routes for its OpenAPI document
+func OpenAPI() *openapi.Spec {
+	spec := openapi.New("Order Service", "1.0.0", "Places, pays for, ships, and tracks orders, and runs subscriptions, coupons, loyalty points, and webhooks.")
+	service := []string{openapi.ServiceKey}
+
+	// Orders
+	spec.Describe("POST", "/v1/orders", openapi.Route{Summary: "Place an order; guest orders also return their claim token", Body: models.CreateOrderRequest{}, Response: models.Order{}, Status: http.StatusCreated})
+	spec.Describe("GET", "/v1/orders", openapi.Route{Summary: "List orders, newest first", Response: []models.Order{}, Page: models.PageInfo{}, Query: merge(orderFilterQuery, map[string]string{
+		"page":  "the page to return, from 1",
+		"limit": "how many orders a page holds",
+	})})
+	spec.Describe("GET", "/v1/orders/export", openapi.Route{Summary: "Export orders, one line per item", Query: merge(orderFilterQuery, map[string]string{"format": "csv (default) or json"}), Produces: "text/csv", Auth: service})
+	spec.Describe("GET", "/v1/orders/{id}", openapi.Route{Summary: "Get an order", Response: models.Order{}})
+	spec.Describe("GET", "/v1/orders/user/{user_id}", openapi.Route{Summary: "List a user's orders", Response: []models.Order{}})
+	spec.Describe("GET", "/v1/orders/user/{user_id}/stats", openapi.Route{Summary: "Summarise a user's purchases", Response: models.UserOrderStats{}})
+	spec.Describe("POST", "/v1/orders/user/{user_id}/anonymize", openapi.Route{Summary: "Strip personal data from a user's orders", Response: map[string]int{}, Auth: service})
+	spec.Describe("PATCH", "/v1/orders/{id}/status", openapi.Route{Summary: "Move an order to another status", Body: models.UpdateOrderStatusRequest{}, Response: models.Order{}})
+	spec.Describe("PATCH", "/v1/orders/{id}/items", openapi.Route{Summary: "Add, remove, or change the quantity of a pending order's items", Body: models.AmendItemsRequest{}, Response: models.Order{}})
+	spec.Describe("POST", "/v1/orders/{id}/restore", openapi.Route{Summary: "Bring an archived order back into listings", Response: models.Order{}, Auth: service})
+	spec.Describe("POST", "/v1/orders/{id}/review/approve", openapi.Route{Summary: "Release an order held for review", Response: models.Order{}, Auth: service})
+	spec.Describe("POST", "/v1/orders/{id}/review/reject", openapi.Route{Summary: "Cancel an order held for review", Body: models.ReviewDecisionRequest{}, Response: models.Order{}, Auth: service})
+	spec.Describe("GET", "/v1/orders/{id}/history", openapi.Route{Summary: "List an order's status changes, oldest first", Response: []models.StatusChange{}})
+	spec.Describe("POST", "/v1/orders/{id}/notes", openapi.Route{Summary: "Add an internal note to an order", Body: models.AddNoteRequest{}, Response: models.OrderNote{}, Status: http.StatusCreated, Auth: service})
+	spec.Describe("GET", "/v1/orders/{id}/notes", openapi.Route{Summary: "List an order's internal notes, oldest first", Response: []models.OrderNote{}, Auth: service})
+	spec.Describe("GET", "/v1/orders/{id}/invoice", openapi.Route{Summary: "Render an order's invoice", Query: map[string]string{"format": "pdf (default) or html"}, Produces: "application/pdf"})
+	spec.Describe("POST", "/v1/orders/{id}/shipments", openapi.Route{Summary: "Ship some or all of a confirmed order's items", Body: models.CreateShipmentRequest{}, Response: models.Order{}, Status: http.StatusCreated})
+	spec.Describe("PATCH", "/v1/orders/{id}/shipments/{shipment_id}", openapi.Route{Summary: "Mark a shipment delivered", Body: models.UpdateShipmentRequest{}, Response: models.Order{}})
+	spec.Describe("PATCH", "/v1/orders/{id}/tracking", openapi.Route{Summary: "Set a shipment's carrier, tracking number, and estimated delivery", Body: models.UpdateTrackingRequest{}, Response: models.Order{}})
+	spec.Describe("POST", "/v1/orders/{id}/pay", openapi.Route{Summary: "Charge the customer for an unpaid order", Body: models.PayOrderRequest{}, Response: models.Order{}})
+	spec.Describe("POST", "/v1/orders/{id}/claim", openapi.Route{Summary: "Link a guest order to the buyer's account", Body: models.ClaimOrderRequest{}, Response: models.Order{}})
+	spec.Describe("POST", "/v1/payments/webhook", openapi.Route{Summary: "Record a payment the provider settled, signed with Stripe-Signature", Body: json.RawMessage{}})
+	spec.Describe("GET", "/v1/internal/purchases", openapi.Route{Summary: "Report whether a user has bought a product", Response: map[string]bool{}, Query: map[string]string{"user_id": "the buyer", "product_id": "the product"}, Auth: service})
+	spec.Describe("POST", "/v1/internal/orders/{id}/shipments/{shipment_id}/tracking", openapi.Route{Summary: "Record what the carrier reports about a shipment", Body: models.TrackingReportRequest{}, Response: models.Order{}, Auth: service})
+
+	// Webhooks
+	spec.Describe("POST", "/v1/webhooks", openapi.Route{Summary: "Subscribe a URL to order events; the signing secret is only returned here", Body: models.CreateWebhookRequest{}, Response: models.WebhookSubscriptionResponse{}, Status: http.StatusCreated, Auth: service})
+	spec.Describe("GET", "/v1/webhooks", openapi.Route{Summary: "List webhook subscriptions", Response: []models.WebhookSubscription{}, Auth: service})
+	spec.Describe("GET", "/v1/webhooks/{id}", openapi.Route{Summary: "Get a webhook subscription", Response: models.WebhookSubscription{}, Auth: service})
+	spec.Describe("DELETE", "/v1/webhooks/{id}", openapi.Route{Summary: "Unsubscribe a webhook", Auth: service})
+	spec.Describe("GET", "/v1/webhooks/{id}/deliveries", openapi.Route{Summary: "List a webhook's recent deliveries", Response: []models.WebhookDelivery{}, Auth: service})
+
+	// Subscriptions
+	spec.Describe("POST", "/v1/subscriptions", openapi.Route{Summary: "Subscribe a user to a recurring order", Body: models.CreateSubscriptionRequest{}, Response: models.Subscription{}, Status: http.StatusCreated})
+	spec.Describe("GET", "/v1/subscriptions/{id}", openapi.Route{Summary: "Get a subscription", Response: models.Subscription{}})
+	spec.Describe("GET", "/v1/subscriptions/user/{user_id}", openapi.Route{Summary: "List a user's subscriptions, oldest first", Response: []models.Subscription{}})
+	spec.Describe("POST", "/v1/subscriptions/{id}/pause", openapi.Route{Summary: "Stop placing orders until resumed", Response: models.Subscription{}})
+	spec.Describe("POST", "/v1/subscriptions/{id}/resume", openapi.Route{Summary: "Restart a paused subscription from its next cycle", Response: models.Subscription{}})
+	spec.Describe("POST", "/v1/subscriptions/{id}/cancel", openapi.Route{Summary: "End a subscription", Response: models.Subscription{}})
+
+	// Loyalty and coupons
+	spec.Describe("GET", "/v1/loyalty/{user_id}", openapi.Route{Summary: "Get a user's points balance and its history", Response: models.LoyaltyAccount{}})
+	spec.Describe("POST", "/v1/coupons", openapi.Route{Summary: "Create a coupon", Body: models.CreateCouponRequest{}, Response: models.Coupon{}, Status: http.StatusCreated, Auth: service})
+	spec.Describe("GET", "/v1/coupons", openapi.Route{Summary: "List coupons with how often each has been used", Response: []models.Coupon{}, Auth: service})
+	spec.Describe("GET", "/v1/coupons/{code}", openapi.Route{Summary: "Get a coupon", Response: models.Coupon{}, Auth: service})
+	spec.Describe("DELETE", "/v1/coupons/{code}", openapi.Route{Summary: "Withdraw a coupon", Auth: service})
+
+	// Operations
+	spec.Describe("GET", "/v1/admin/config", openapi.Route{Summary: "Show the settings in effect", Auth: service})
+
+	// Reports
+	spec.Describe("GET", "/v1/admin/reports/order-counts", openapi.Route{Summary: "Count the orders in each status", Response: models.StatusCounts{}, Auth: service})
+	spec.Describe("GET", "/v1/admin/reports/orders", openapi.Route{Summary: "List the orders in a status, newest first", Response: []models.OrderSummary{}, Page: models.PageInfo{}, Query: map[string]string{
+		"status": "the status to list",
+		"page":   "the page to return, from 1",
+		"limit":  "how many orders a page holds",
+	}, Auth: service})
+	spec.Describe("GET", "/v1/admin/reports/revenue", openapi.Route{Summary: "Report revenue by the day orders were placed", Response: []models.DailyRevenue{}, Query: map[string]string{
+		"from": "the first day, YYYY-MM-DD; 29 days before to by default",
+		"to":   "the last day, YYYY-MM-DD; today by default",
+	}, Auth: service})
+	spec.Describe("GET", "/v1/admin/reports/top-products", openapi.Route{Summary: "List the products sold in the most units", Response: []models.ProductSales{}, Query: map[string]string{"limit": "how many products to list, 10 by default"}, Auth: service})
+	spec.Describe("GET", "/openapi.json", openapi.Route{Summary: "This document"})
+	spec.Describe("GET", "/healthz", openapi.Route{Summary: "Report whether the service is running"})
+	spec.Describe("GET", "/readyz", openapi.Route{Summary: "Report whether the service can take traffic"})
+	spec.Describe("GET", "/metrics", openapi.Route{Summary: "Prometheus metrics", Produces: "text/plain"})
+	spec.Describe("GET", "/debug/vars", openapi.Route{Summary: "Runtime variables", Auth: service})
+	return spec
+}
+
+// merge returns the query parameters of every one of queries
+func merge(queries ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, query := range queries {
+		for name, description := range query {
+			merged[name] = description
+		}
+	}
+	return merged
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	orderv1 "ecommerce/pkg/proto/order/v1"
+	"ecommerce/pkg/auth"
+	"order-service/internal/models"
+	"order-service/internal/repository"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// OrderServer serves the OrderService gRPC API other services look orders up with
+type OrderServer struct {
+	orderv1.UnimplementedOrderServiceServer
+	repo repository.OrderRepository
+}
+
+// NewOrderServer creates a new OrderService server
+func NewOrderServer(repo repository.OrderRepository) *OrderServer {
+	return &OrderServer{repo: repo}
+}
+
+// GetOrder returns an order, the gRPC counterpart of GET /orders/{id}
+func (s *OrderServer) GetOrder(ctx context.Context, req *orderv1.GetOrderRequest) (*orderv1.Order, error) {
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "Order ID is required")
+	}
+
+	order, err := s.repo.GetByID(ctx, req.Id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting order", "error", err)
+		return nil, status.Error(codes.NotFound, "Order not found")
+	}
+	return orderMessage(order), nil
+}
+
+// ListUserOrders returns a user's orders, the gRPC counterpart of GET /orders/user/{user_id}.
+// Archived orders are left out unless the call asks for them.
+func (s *OrderServer) ListUserOrders(ctx context.Context, req *orderv1.ListUserOrdersRequest) (*orderv1.ListUserOrdersResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "User ID is required")
+	}
+
+	orders, err := s.repo.GetByUserID(ctx, req.UserId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting user orders", "error", err)
+		return nil, status.Error(codes.Internal, "Failed to retrieve orders")
+	}
+
+	resp := &orderv1.ListUserOrdersResponse{}
+	for _, order := range orders {
+		if order.Archived && !req.IncludeArchived {
+			continue
+		}
+		resp.Orders = append(resp.Orders, orderMessage(order))
+	}
+	return resp, nil
+}
+
+// CheckPurchase reports whether a user has bought a product, the gRPC counterpart of
+// GET /internal/purchases. Only other services may ask.
+func (s *OrderServer) CheckPurchase(ctx context.Context, req *orderv1.CheckPurchaseRequest) (*orderv1.CheckPurchaseResponse, error) {
+	if auth.ServiceFromContext(ctx) == "" {
+		return nil, status.Error(codes.Unauthenticated, "Service key required")
+	}
+	if req.UserId == "" || req.ProductId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id and product_id are required")
+	}
+
+	orders, err := s.repo.GetByUserID(ctx, req.UserId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting user orders", "error", err)
+		return nil, status.Error(codes.Internal, "Failed to check purchases")
+	}
+
+	for _, order := range orders {
+		if order.IsPurchased() && order.Contains(req.ProductId) {
+			return &orderv1.CheckPurchaseResponse{Purchased: true}, nil
+		}
+	}
+	return &orderv1.CheckPurchaseResponse{}, nil
+}
+
+// orderMessage converts an order to its protobuf message
+func orderMessage(order *models.Order) *orderv1.Order {
+	message := &orderv1.Order{
+		Id:            order.ID,
+		UserId:        order.UserID,
+		Subtotal:      order.Subtotal,
+		Discount:      order.Discount,
+		ShippingCost:  order.ShippingCost,
+		Tax:           order.Tax,
+		Total:         order.Total,
+		Status:        string(order.Status),
+		PaymentStatus: string(order.PaymentStatus),
+		Archived:      order.Archived,
+		CreatedAt:     timestamppb.New(order.CreatedAt),
+		UpdatedAt:     timestamppb.New(order.UpdatedAt),
+	}
+	for _, item := range order.Items {
+		message.Items = append(message.Items, &orderv1.OrderItem{
+			ProductId:   item.ProductID,
+			ProductName: item.ProductName,
+			Price:       item.Price,
+			Quantity:    int32(item.Quantity),
+			Subtotal:    item.Subtotal,
+			Status:      string(item.Status),
+		})
+	}
+	return message
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	orderv1 "ecommerce/pkg/proto/order/v1"
+	"ecommerce/pkg/auth"
+	"order-service/internal/models"
+	"order-service/internal/repository"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestOrderServer(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryOrderRepository()
+	confirmed := models.NewOrder("user-1", []models.OrderItem{models.NewOrderItem("prod-1", "Lamp", 20, 2)})
+	confirmed.Status = models.OrderStatusConfirmed
+	archived := models.NewOrder("user-1", []models.OrderItem{models.NewOrderItem("prod-2", "Chair", 50, 1)})
+	archived.Archived = true
+	for _, order := range []*models.Order{confirmed, archived} {
+		if err := repo.Create(ctx, order); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := NewOrderServer(repo)
+
+	order, err := server.GetOrder(ctx, &orderv1.GetOrderRequest{Id: confirmed.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.UserId != "user-1" || order.Status != "confirmed" || len(order.Items) != 1 || order.Items[0].Quantity != 2 {
+		t.Fatalf("expected the confirmed order, got %+v", order)
+	}
+	if _, err := server.GetOrder(ctx, &orderv1.GetOrderRequest{Id: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for an unknown order, got %v", err)
+	}
+
+	listed, err := server.ListUserOrders(ctx, &orderv1.ListUserOrdersRequest{UserId: "user-1"})
+	if err != nil || len(listed.Orders) != 1 {
+		t.Fatalf("expected the archived order left out, got %v %v", listed, err)
+	}
+	listed, err = server.ListUserOrders(ctx, &orderv1.ListUserOrdersRequest{UserId: "user-1", IncludeArchived: true})
+	if err != nil || len(listed.Orders) != 2 {
+		t.Fatalf("expected both orders, got %v %v", listed, err)
+	}
+
+	check := &orderv1.CheckPurchaseRequest{UserId: "user-1", ProductId: "prod-1"}
+	if _, err := server.CheckPurchase(ctx, check); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected end users turned away, got %v", err)
+	}
+	purchase, err := server.CheckPurchase(auth.WithService(ctx, "product-service"), check)
+	if err != nil || !purchase.Purchased {
+		t.Fatalf("expected the confirmed purchase reported, got %v %v", purchase, err)
+	}
+}
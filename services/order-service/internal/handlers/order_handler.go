@@ -1,77 +1,333 @@
 package handlers
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"ecommerce/pkg/api"
+	"ecommerce/pkg/auth"
 	"order-service/internal/client"
+	"order-service/internal/export"
+	"order-service/internal/fraud"
+	"order-service/internal/fulfillment"
+	"order-service/internal/invoice"
+	"order-service/internal/loyalty"
 	"order-service/internal/models"
+	"order-service/internal/payment"
 	"order-service/internal/repository"
+	"order-service/internal/saga"
+	"order-service/internal/shipping"
+	"order-service/internal/tax"
 
 	"github.com/gorilla/mux"
 )
 
 // OrderHandler handles HTTP requests related to orders
 type OrderHandler struct {
-	repo   repository.OrderRepository
-	client client.OrderValidationClient
+	repo      repository.OrderRepository
+	client    client.OrderValidationClient
+	payments  payment.PaymentProvider
+	shipping  *shipping.Calculator
+	taxes     tax.Calculator
+	downloads *fulfillment.TokenIssuer
+	coupons   repository.CouponRepository
+	loyalty   *loyalty.Program
+	fraud     fraud.FraudChecker
+	invoices  *invoice.Cache
+	// releaseByEvent leaves the stock of cancelled orders for product service to release when it
+	// consumes order.cancelled, rather than releasing it directly
+	releaseByEvent bool
 }
 
-// NewOrderHandler creates a new order handler
-func NewOrderHandler(repo repository.OrderRepository, serviceClient client.OrderValidationClient) *OrderHandler {
+// maxWebhookBytes caps the size of a payment webhook payload
+const maxWebhookBytes = 64 * 1024
+
+// expiryActor is recorded in the status history of orders cancelled for going stale
+const expiryActor = "order-expiry"
+
+// Steps of the create-order saga
+const (
+	stepRedeemCoupon  = "redeem_coupon"
+	stepRedeemPoints  = "redeem_points"
+	stepReserveStock  = "reserve_stock"
+	stepReleaseStock  = "release_stock"
+	stepChargePayment = "charge_payment"
+	stepPersistOrder  = "persist_order"
+)
+
+// placementError is why an order couldn't be placed, with the HTTP status that reports it
+type placementError struct {
+	status  int
+	code    string // sent as the response's code; left empty, it is named after the status
+	message string
+	data    interface{} // details for the client, such as the rejected lines
+}
+
+func (e *placementError) Error() string {
+	return e.message
+}
+
+// NewOrderHandler creates a new order handler. Orders are charged through payments, priced for shipping
+// by shippingCosts, taxed by taxes, download links for digital items are issued by downloads, coupon
+// codes are looked up in coupons, loyalty points are earned and redeemed through loyaltyProgram, and new
+// orders are screened by fraudChecker; any of them may be nil to skip that step. Order events are recorded
+// on the order and reach the outbox when it is saved.
+func NewOrderHandler(repo repository.OrderRepository, serviceClient client.OrderValidationClient, payments payment.PaymentProvider, shippingCosts *shipping.Calculator, taxes tax.Calculator, downloads *fulfillment.TokenIssuer, coupons repository.CouponRepository, loyaltyProgram *loyalty.Program, fraudChecker fraud.FraudChecker) *OrderHandler {
 	return &OrderHandler{
-		repo:   repo,
-		client: serviceClient,
+		repo:      repo,
+		client:    serviceClient,
+		payments:  payments,
+		shipping:  shippingCosts,
+		taxes:     taxes,
+		downloads: downloads,
+		coupons:   coupons,
+		loyalty:   loyaltyProgram,
+		fraud:     fraudChecker,
+		invoices:  invoice.NewCache(),
 	}
 }
 
+// ReleaseStockByEvent makes cancellations leave the order's stock reservations to product service,
+// which releases them when it consumes the order.cancelled event. Orders can then be cancelled while
+// product service is unreachable. Call it before the handler is used.
+func (h *OrderHandler) ReleaseStockByEvent() {
+	h.releaseByEvent = true
+}
+
 // CreateOrder handles POST /orders - creates a new order
 func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var req models.CreateOrderRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid JSON payload")
+	if !api.DecodeJSON(w, r, &req) {
 		return
 	}
 
-	// Basic validation
-	if req.UserID == "" || len(req.Items) == 0 {
-		h.sendErrorResponse(w, http.StatusBadRequest, "User ID and at least one item are required")
+	order, err := h.placeOrder(r.Context(), &req)
+	if err != nil {
+		h.sendPlacementErrorResponse(w, err)
 		return
 	}
 
-	// Validate user exists
-	if err := h.client.CheckUserExists(req.UserID); err != nil {
-		log.Printf("User validation failed: %v", err)
-		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
-		return
+	response := models.Response{
+		Success: true,
+		Message: "Order created successfully",
+		Data:    order,
+	}
+	if order.Status == models.OrderStatusReview {
+		response.Message = "Order created and held for review"
+	}
+	// The claim token is only ever shown here; the guest uses it to link the order to an account later
+	if order.IsGuest() {
+		response.Data = models.GuestOrderResponse{Order: *order, ClaimToken: order.ClaimToken}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// placeOrder validates, prices, and places an order: the work behind POST /orders, shared with
+// anything else that places orders for customers. Errors the caller can correct, or should retry,
+// are returned as a *placementError.
+func (h *OrderHandler) placeOrder(ctx context.Context, req *models.CreateOrderRequest) (*models.Order, error) {
+	// Basic validation; guests give an email in place of a user ID
+	req.Email = strings.TrimSpace(req.Email)
+	guest := req.UserID == "" && req.Email != ""
+	if (req.UserID == "" && !guest) || len(req.Items) == 0 {
+		return nil, &placementError{status: http.StatusBadRequest, message: "User ID or guest email, and at least one item, are required"}
+	}
+	if req.UserID != "" && (req.Email != "" || req.ShippingAddress != nil) {
+		return nil, &placementError{status: http.StatusBadRequest, message: "email and shipping_address are only for guest orders; registered users choose a saved address"}
+	}
+	if guest {
+		if err := models.ValidateGuestEmail(req.Email); err != nil {
+			return nil, &placementError{status: http.StatusBadRequest, message: err.Error()}
+		}
+		if err := req.ShippingAddress.CheckComplete(); err != nil {
+			return nil, &placementError{status: http.StatusBadRequest, message: err.Error()}
+		}
+		if req.ShippingAddressID != "" {
+			return nil, &placementError{status: http.StatusBadRequest, message: "Guests give their shipping_address rather than a shipping_address_id"}
+		}
+	}
+	if req.BillingAddress != nil && strings.TrimSpace(req.BillingAddress.Country) == "" {
+		return nil, &placementError{status: http.StatusBadRequest, message: "billing_address needs a country"}
+	}
+	if req.ShippingMethod == "" {
+		req.ShippingMethod = models.ShippingStandard
+	}
+	if !models.IsValidShippingMethod(req.ShippingMethod) {
+		return nil, &placementError{status: http.StatusBadRequest, message: "shipping_method must be standard or express"}
+	}
+	if err := models.ValidateMetadata(req.Metadata); err != nil {
+		return nil, &placementError{status: http.StatusBadRequest, message: err.Error()}
+	}
+	req.CouponCode = models.NormalizeCouponCode(req.CouponCode)
+	if req.CouponCode != "" && h.coupons == nil {
+		return nil, &placementError{status: http.StatusServiceUnavailable, message: "Coupons are not available"}
+	}
+	if req.RedeemPoints < 0 {
+		return nil, &placementError{status: http.StatusBadRequest, message: "redeem_points must not be negative"}
+	}
+	if req.RedeemPoints > 0 && guest {
+		return nil, &placementError{status: http.StatusBadRequest, message: "Guests can't redeem loyalty points"}
+	}
+	if req.RedeemPoints > 0 && h.loyalty == nil {
+		return nil, &placementError{status: http.StatusServiceUnavailable, message: "Loyalty points are not available"}
+	}
+
+	// Registered buyers must exist and ship to one of their saved addresses; an explicitly requested
+	// address must exist, while a missing default simply leaves the order without one. Only the user
+	// service saying so makes the request invalid; failing to reach it is a 503.
+	shippingAddress := req.ShippingAddress
+	if !guest {
+		if err := h.client.CheckUserExists(ctx, req.UserID); err != nil {
+			slog.ErrorContext(ctx, "User validation failed", "error", err)
+			if !errors.Is(err, client.ErrNotFound) && !errors.Is(err, client.ErrUserInactive) {
+				return nil, &placementError{status: http.StatusServiceUnavailable, message: "Unable to verify the user"}
+			}
+			return nil, &placementError{status: http.StatusBadRequest, code: CodeInvalidUser, message: "Invalid user ID"}
+		}
+
+		address, err := h.client.GetShippingAddress(ctx, req.UserID, req.ShippingAddressID)
+		switch {
+		case err == nil:
+		case !errors.Is(err, client.ErrNotFound):
+			slog.ErrorContext(ctx, "Shipping address lookup failed", "error", err)
+			return nil, &placementError{status: http.StatusServiceUnavailable, message: "Unable to look up the shipping address"}
+		case req.ShippingAddressID != "":
+			slog.ErrorContext(ctx, "Shipping address not found", "error", err)
+			return nil, &placementError{status: http.StatusBadRequest, code: CodeInvalidShippingAddress, message: "Invalid shipping address ID"}
+		default:
+			slog.InfoContext(ctx, "No default shipping address for user", "user_id", req.UserID, "error", err)
+			address = nil
+		}
+		shippingAddress = address
 	}
 
 	// Validate and get order items
-	orderItems, err := h.client.ValidateOrderItems(req.Items)
+	orderItems, err := h.client.ValidateOrderItems(ctx, req.Items)
 	if err != nil {
-		log.Printf("Order items validation failed: %v", err)
-		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
-		return
+		slog.ErrorContext(ctx, "Order items validation failed", "error", err)
+		var itemErrs *models.ItemValidationErrors
+		if errors.As(err, &itemErrs) {
+			return nil, &placementError{status: http.StatusBadRequest, code: itemErrorCode(itemErrs), message: itemErrs.Error(), data: itemErrs}
+		}
+		return nil, &placementError{status: http.StatusBadRequest, message: err.Error()}
 	}
 
 	// Create order
-	order := models.NewOrder(req.UserID, orderItems)
-	if err := h.repo.Create(order); err != nil {
-		log.Printf("Error creating order: %v", err)
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to create order")
-		return
+	var order *models.Order
+	if guest {
+		claimToken, err := models.NewClaimToken()
+		if err != nil {
+			slog.ErrorContext(ctx, "Error generating claim token", "error", err)
+			return nil, &placementError{status: http.StatusInternalServerError, message: "Failed to create order"}
+		}
+		order = models.NewGuestOrder(req.Email, orderItems, claimToken)
+	} else {
+		order = models.NewOrder(req.UserID, orderItems)
 	}
+	order.ShippingAddress = shippingAddress
+	order.BillingAddress = req.BillingAddress
+	order.Metadata = req.Metadata
 
-	response := models.Response{
-		Success: true,
-		Message: "Order created successfully",
-		Data:    order,
+	// Shipping is priced by weight and destination; orders of only digital items don't ship
+	if h.shipping != nil && order.NeedsShipping() {
+		cost, err := h.shipping.Cost(order, req.ShippingMethod)
+		if err != nil {
+			slog.ErrorContext(ctx, "Shipping calculation failed", "error", err)
+			return nil, &placementError{status: http.StatusInternalServerError, message: "Unable to calculate shipping"}
+		}
+		order.ApplyShipping(req.ShippingMethod, cost)
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+	// The discount comes off before tax; the coupon's use is only counted once the order is placed
+	if req.CouponCode != "" {
+		coupon, err := h.coupons.GetByCode(req.CouponCode)
+		if err == nil {
+			err = coupon.CheckUsable(time.Now())
+		}
+		if err != nil {
+			return nil, couponPlacementError(err)
+		}
+		order.ApplyDiscount(coupon.Code, coupon.Discount(order.Subtotal))
+	}
+
+	// Tax is worked out after the address is known, since rates may depend on where the order ships
+	if h.taxes != nil {
+		orderTax, err := h.taxes.Calculate(order)
+		if err != nil {
+			slog.ErrorContext(ctx, "Tax calculation failed", "error", err)
+			return nil, &placementError{status: http.StatusServiceUnavailable, message: "Unable to calculate tax"}
+		}
+		order.ApplyTax(orderTax)
+	}
+
+	// Points are spent on what is left to pay, after tax; the balance is checked when the order is placed
+	if req.RedeemPoints > 0 {
+		credit := h.loyalty.Value(req.RedeemPoints)
+		if credit > order.Total {
+			return nil, &placementError{status: http.StatusBadRequest, message: fmt.Sprintf("%d points are worth %.2f, more than the order's total of %.2f", req.RedeemPoints, credit, order.Total)}
+		}
+		order.ApplyPoints(req.RedeemPoints, credit)
+	}
+
+	if req.PaymentMethod != "" && h.payments == nil {
+		return nil, &placementError{status: http.StatusServiceUnavailable, code: CodePaymentsUnavailable, message: "Payments are not available"}
+	}
+
+	// Suspicious orders are still placed, but held in review until someone approves them. An order
+	// that can't be screened is held too rather than let through unchecked.
+	if h.fraud != nil {
+		reasons, err := h.fraud.Check(ctx, order)
+		if err != nil {
+			slog.ErrorContext(ctx, "Fraud screening for order failed", "order_id", order.ID, "error", err)
+			reasons = []string{"fraud screening was unavailable"}
+		}
+		if len(reasons) > 0 {
+			order.HoldForReview(reasons)
+		}
+	}
+
+	// Reserve stock, charge, and store the order; whatever was done is undone if a later step fails
+	if err := h.createOrderSaga(ctx, order, req.PaymentMethod).Execute(); err != nil {
+		slog.ErrorContext(ctx, "Creating order failed", "order_id", order.ID, "error", err)
+		failedStep := ""
+		var stepErr *saga.StepError
+		if errors.As(err, &stepErr) {
+			failedStep = stepErr.Step
+		}
+		switch {
+		case failedStep == stepRedeemCoupon:
+			return nil, couponPlacementError(err)
+		case failedStep == stepRedeemPoints && errors.Is(err, models.ErrInsufficientPoints):
+			return nil, &placementError{status: http.StatusConflict, code: CodeInsufficientPoints, message: "Not enough loyalty points"}
+		case failedStep == stepRedeemPoints:
+			return nil, &placementError{status: http.StatusServiceUnavailable, message: "Unable to redeem loyalty points"}
+		case failedStep == stepReserveStock && errors.Is(err, client.ErrConflict):
+			return nil, &placementError{status: http.StatusConflict, code: CodeOrderInsufficientStock, message: "Insufficient stock for one or more items"}
+		case failedStep == stepReserveStock:
+			return nil, &placementError{status: http.StatusServiceUnavailable, message: "Unable to reserve stock"}
+		case failedStep == stepChargePayment && errors.Is(err, payment.ErrDeclined):
+			return nil, &placementError{status: http.StatusPaymentRequired, code: CodePaymentDeclined, message: "Payment was declined"}
+		case failedStep == stepChargePayment:
+			return nil, &placementError{status: http.StatusServiceUnavailable, message: "Unable to take payment"}
+		default:
+			return nil, &placementError{status: http.StatusInternalServerError, message: "Failed to create order"}
+		}
+	}
+	return order, nil
 }
 
 // GetOrder handles GET /orders/{id} - retrieves an order by ID
@@ -82,14 +338,17 @@ func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	orderID := vars["id"]
 
 	if orderID == "" {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Order ID is required")
+		api.WriteError(w, http.StatusBadRequest, "Order ID is required")
 		return
 	}
 
-	order, err := h.repo.GetByID(orderID)
+	order, err := h.repo.GetByID(r.Context(), orderID)
 	if err != nil {
-		log.Printf("Error getting order: %v", err)
-		h.sendErrorResponse(w, http.StatusNotFound, "Order not found")
+		slog.ErrorContext(r.Context(), "Error getting order", "error", err)
+		api.WriteErrorCode(w, http.StatusNotFound, CodeOrderNotFound, "Order not found")
+		return
+	}
+	if api.NotModified(w, r, order.UpdatedAt) {
 		return
 	}
 
@@ -101,121 +360,369 @@ func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// GetUserOrders handles GET /orders/user/{user_id} - retrieves all orders for a user
-func (h *OrderHandler) GetUserOrders(w http.ResponseWriter, r *http.Request) {
+// GetOrderHistory handles GET /orders/{id}/history - returns the order's status changes, oldest first
+func (h *OrderHandler) GetOrderHistory(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	vars := mux.Vars(r)
-	userID := vars["user_id"]
-
-	if userID == "" {
-		h.sendErrorResponse(w, http.StatusBadRequest, "User ID is required")
+	order, err := h.repo.GetByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeOrderNotFound, "Order not found")
 		return
 	}
 
-	// Validate user exists
-	if err := h.client.CheckUserExists(userID); err != nil {
-		log.Printf("User validation failed: %v", err)
-		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
+	response := models.Response{
+		Success: true,
+		Data:    order.StatusHistory,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// AddOrderNote handles POST /orders/{id}/notes - adds an internal note to an order (admin function)
+func (h *OrderHandler) AddOrderNote(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.AddNoteRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+	body := strings.TrimSpace(req.Body)
+	if body == "" {
+		api.WriteError(w, http.StatusBadRequest, "Note body is required")
 		return
 	}
 
-	orders, err := h.repo.GetByUserID(userID)
+	order, err := h.repo.GetByID(r.Context(), mux.Vars(r)["id"])
 	if err != nil {
-		log.Printf("Error getting user orders: %v", err)
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve orders")
+		api.WriteErrorCode(w, http.StatusNotFound, CodeOrderNotFound, "Order not found")
+		return
+	}
+
+	author := strings.TrimSpace(req.Author)
+	if author == "" {
+		author = statusActor(r)
+	}
+	note := order.AddNote(author, body, time.Now())
+
+	if err := h.repo.Update(r.Context(), order); err != nil {
+		slog.ErrorContext(r.Context(), "Error adding order note", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to add note")
 		return
 	}
 
 	response := models.Response{
 		Success: true,
-		Data:    orders,
+		Message: "Note added successfully",
+		Data:    note,
 	}
 
+	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(response)
 }
 
-// UpdateOrderStatus handles PATCH /orders/{id}/status - updates order status
-func (h *OrderHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+// GetOrderNotes handles GET /orders/{id}/notes - lists an order's internal notes, oldest first (admin function)
+func (h *OrderHandler) GetOrderNotes(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	vars := mux.Vars(r)
-	orderID := vars["id"]
+	order, err := h.repo.GetByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeOrderNotFound, "Order not found")
+		return
+	}
 
-	if orderID == "" {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Order ID is required")
+	notes := order.Notes
+	if notes == nil {
+		notes = []models.OrderNote{}
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    notes,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetOrderInvoice handles GET /orders/{id}/invoice - renders the order's invoice as a PDF, or as HTML
+// with ?format=html. Rendered invoices are cached until the order next changes.
+func (h *OrderHandler) GetOrderInvoice(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = invoice.FormatPDF
+	}
+	if format != invoice.FormatPDF && format != invoice.FormatHTML {
+		w.Header().Set("Content-Type", "application/json")
+		api.WriteError(w, http.StatusBadRequest, "format must be pdf or html")
 		return
 	}
 
-	var req models.UpdateOrderStatusRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid JSON payload")
+	order, err := h.repo.GetByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		api.WriteErrorCode(w, http.StatusNotFound, CodeOrderNotFound, "Order not found")
 		return
 	}
 
-	// Validate status
-	validStatuses := []models.OrderStatus{
-		models.OrderStatusPending,
-		models.OrderStatusConfirmed,
-		models.OrderStatusShipped,
-		models.OrderStatusDelivered,
-		models.OrderStatusCancelled,
-	}
-
-	isValidStatus := false
-	for _, status := range validStatuses {
-		if req.Status == status {
-			isValidStatus = true
-			break
+	document, cached := h.invoices.Get(order.ID, format, order.UpdatedAt)
+	if !cached {
+		// Anonymized orders no longer belong to anyone, so the invoice is issued without buyer details
+		var buyer *models.User
+		if order.IsGuest() {
+			buyer = &models.User{Email: order.GuestEmail}
+			if order.ShippingAddress != nil {
+				buyer.Name = order.ShippingAddress.RecipientName
+			}
+		} else if order.AnonymizedAt == nil {
+			buyer, err = h.client.GetUser(r.Context(), order.UserID)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Buyer lookup for invoice of order failed", "order_id", order.ID, "error", err)
+				w.Header().Set("Content-Type", "application/json")
+				api.WriteError(w, http.StatusServiceUnavailable, "Unable to load buyer details")
+				return
+			}
+		}
+
+		document, err = invoice.New(order, buyer, time.Now()).Render(format)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Rendering invoice for order failed", "order_id", order.ID, "error", err)
+			w.Header().Set("Content-Type", "application/json")
+			api.WriteError(w, http.StatusInternalServerError, "Failed to generate invoice")
+			return
 		}
+		h.invoices.Put(order.ID, format, order.UpdatedAt, document)
+	}
+
+	w.Header().Set("Content-Type", invoice.ContentType(format))
+	if format == invoice.FormatPDF {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"invoice-%s.pdf\"", order.ID))
+	}
+	w.Write(document)
+}
+
+// ClaimOrder handles POST /orders/{id}/claim - links a guest order to the buyer's account. The caller
+// proves the order is theirs with the claim token returned when it was placed.
+func (h *OrderHandler) ClaimOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.ClaimOrderRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	order, err := h.repo.GetByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeOrderNotFound, "Order not found")
+		return
+	}
+	if !order.IsGuest() {
+		api.WriteErrorCode(w, http.StatusConflict, CodeOrderAlreadyClaimed, "Order already belongs to an account")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(req.ClaimToken), []byte(order.ClaimToken)) != 1 {
+		api.WriteErrorCode(w, http.StatusForbidden, CodeOrderInvalidClaimToken, "Invalid claim token")
+		return
+	}
+	if err := h.client.CheckUserExists(r.Context(), req.UserID); err != nil {
+		slog.ErrorContext(r.Context(), "User validation failed", "error", err)
+		api.WriteErrorCode(w, http.StatusBadRequest, CodeInvalidUser, "Invalid user ID")
+		return
 	}
 
-	if !isValidStatus {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid order status")
+	order.Claim(req.UserID)
+	if err := h.repo.Update(r.Context(), order); err != nil {
+		slog.ErrorContext(r.Context(), "Error claiming order", "order_id", order.ID, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to claim order")
 		return
 	}
 
-	// Get existing order
-	order, err := h.repo.GetByID(orderID)
+	response := models.Response{
+		Success: true,
+		Message: "Order linked to the account",
+		Data:    order,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// AmendOrderItems handles PATCH /orders/{id}/items - adds, removes or changes the quantity of items on a
+// pending order. Every item is checked against product service again, so prices are brought up to date,
+// and the order's stock is let go and reserved anew, so units the order already holds count as available.
+func (h *OrderHandler) AmendOrderItems(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.AmendItemsRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	order, err := h.repo.GetByID(r.Context(), mux.Vars(r)["id"])
 	if err != nil {
-		h.sendErrorResponse(w, http.StatusNotFound, "Order not found")
+		api.WriteErrorCode(w, http.StatusNotFound, CodeOrderNotFound, "Order not found")
+		return
+	}
+	read := *order
+	if order.Status != models.OrderStatusPending {
+		api.WriteErrorCode(w, http.StatusConflict, CodeOrderNotAmendable, fmt.Sprintf("Only pending orders can be amended; order is %s", order.Status))
+		return
+	}
+	// A payment covers the total it was taken for, so a paid order has to be cancelled and placed again
+	if order.PaymentStatus == models.PaymentPaid || order.PaymentStatus == models.PaymentPending {
+		api.WriteErrorCode(w, http.StatusConflict, CodeOrderAlreadyPaid, "Orders with a payment taken can't be amended")
 		return
 	}
 
-	// Check if order can be cancelled
-	if req.Status == models.OrderStatusCancelled && !order.CanBeCancelled() {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Order cannot be cancelled in current status")
+	wanted, err := order.AmendedItems(req.Items)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	orderItems, err := h.client.ValidateOrderItems(r.Context(), wanted)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Order items validation failed", "error", err)
+		var itemErrs *models.ItemValidationErrors
+		if errors.As(err, &itemErrs) {
+			h.sendItemErrorResponse(w, itemErrs)
+			return
+		}
+		api.WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Update status
-	order.UpdateStatus(req.Status)
+	previousItems := order.Items
+	order.ReplaceItems(orderItems, time.Now())
+	if err := h.repriceOrder(order); err != nil {
+		slog.ErrorContext(r.Context(), "Repricing order failed", "order_id", order.ID, "error", err)
+		api.WriteError(w, http.StatusServiceUnavailable, "Unable to recalculate the order's total")
+		return
+	}
 
-	if err := h.repo.Update(order); err != nil {
-		log.Printf("Error updating order status: %v", err)
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to update order status")
+	// Release the old reservations, reserve the new quantities and store the order, unless it was paid,
+	// cancelled, or changed otherwise meanwhile. If any of it fails the new reservations are released and
+	// the old quantities reserved again.
+	amendOrder := saga.New("amend-order")
+	h.addReleaseSteps(r.Context(), amendOrder, order.ID, previousItems)
+	h.addReservationSteps(r.Context(), amendOrder, order)
+	var saved *models.Order
+	amendOrder.AddStep(stepPersistOrder, func() error {
+		var err error
+		saved, err = h.saveIfUnchanged(r.Context(), &read, order)
+		return err
+	}, nil)
+	if err := amendOrder.Execute(); err != nil {
+		slog.ErrorContext(r.Context(), "Amending order failed", "order_id", order.ID, "error", err)
+		// The stored order still names the old reservations, which were made again under new IDs
+		h.restoreReservations(context.WithoutCancel(r.Context()), order.ID, previousItems)
+		var stepErr *saga.StepError
+		switch {
+		case errors.Is(err, errOrderMovedOn):
+			api.WriteErrorCode(w, http.StatusConflict, CodeOrderChanged, "Order changed while it was being amended; fetch it and try again")
+		case errors.As(err, &stepErr) && stepErr.Step == stepReserveStock && errors.Is(err, client.ErrConflict):
+			api.WriteErrorCode(w, http.StatusConflict, CodeOrderInsufficientStock, "Insufficient stock for one or more items")
+		case errors.As(err, &stepErr) && stepErr.Step == stepReserveStock:
+			api.WriteError(w, http.StatusServiceUnavailable, "Unable to reserve stock")
+		case errors.As(err, &stepErr) && stepErr.Step == stepReleaseStock:
+			api.WriteError(w, http.StatusServiceUnavailable, "Unable to release stock")
+		default:
+			api.WriteError(w, http.StatusInternalServerError, "Failed to amend order")
+		}
 		return
 	}
 
 	response := models.Response{
 		Success: true,
-		Message: "Order status updated successfully",
-		Data:    order,
+		Message: "Order items updated successfully",
+		Data:    saved,
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
-// ListOrders handles GET /orders - retrieves all orders (admin function)
-func (h *OrderHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
+// repriceOrder works out shipping, the coupon's discount and tax again after the order's items change.
+// The coupon's use was counted when the order was placed, so it isn't checked or redeemed a second time;
+// if it has since been deleted, the discount already given is kept, up to the new subtotal.
+func (h *OrderHandler) repriceOrder(order *models.Order) error {
+	if h.shipping != nil {
+		method, cost := models.ShippingMethod(""), 0.0
+		if order.NeedsShipping() {
+			method = order.ShippingMethod
+			if method == "" {
+				method = models.ShippingStandard
+			}
+			var err error
+			if cost, err = h.shipping.Cost(order, method); err != nil {
+				return fmt.Errorf("shipping: %w", err)
+			}
+		}
+		order.ApplyShipping(method, cost)
+	}
+
+	if order.CouponCode != "" && h.coupons != nil {
+		coupon, err := h.coupons.GetByCode(order.CouponCode)
+		switch {
+		case err == nil:
+			order.ApplyDiscount(order.CouponCode, coupon.Discount(order.Subtotal))
+		case errors.Is(err, models.ErrCouponNotFound):
+			order.ApplyDiscount(order.CouponCode, math.Min(order.Discount, order.Subtotal))
+		default:
+			return fmt.Errorf("coupon %s: %w", order.CouponCode, err)
+		}
+	}
+
+	if h.taxes != nil {
+		orderTax, err := h.taxes.Calculate(order)
+		if err != nil {
+			return fmt.Errorf("tax: %w", err)
+		}
+		order.ApplyTax(orderTax)
+	}
+
+	// Points already spent keep their value, as far as the new total allows
+	if order.PointsRedeemed > 0 && h.loyalty != nil {
+		order.ApplyPoints(order.PointsRedeemed, h.loyalty.Value(order.PointsRedeemed))
+	}
+	return nil
+}
+
+// GetUserOrders handles GET /orders/user/{user_id} - retrieves all orders for a user
+func (h *OrderHandler) GetUserOrders(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	orders, err := h.repo.List()
+	vars := mux.Vars(r)
+	userID := vars["user_id"]
+
+	if userID == "" {
+		api.WriteError(w, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	includeArchived, err := includeArchivedFromQuery(r)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Validate user exists
+	if err := h.client.CheckUserExists(r.Context(), userID); err != nil {
+		slog.ErrorContext(r.Context(), "User validation failed", "error", err)
+		api.WriteErrorCode(w, http.StatusBadRequest, CodeInvalidUser, "Invalid user ID")
+		return
+	}
+
+	orders, err := h.repo.GetByUserID(r.Context(), userID)
 	if err != nil {
-		log.Printf("Error listing orders: %v", err)
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve orders")
+		slog.ErrorContext(r.Context(), "Error getting user orders", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve orders")
 		return
 	}
+	if !includeArchived {
+		listed := orders[:0]
+		for _, order := range orders {
+			if !order.Archived {
+				listed = append(listed, order)
+			}
+		}
+		orders = listed
+	}
 
 	response := models.Response{
 		Success: true,
@@ -225,30 +732,1384 @@ func (h *OrderHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// HealthCheck handles GET /health - returns service health status
-func (h *OrderHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
+// GetUserOrderStats handles GET /orders/user/{user_id}/stats - summarises a user's purchases: lifetime
+// spend, order count, average order value, and most-purchased products
+func (h *OrderHandler) GetUserOrderStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := mux.Vars(r)["user_id"]
+	if err := h.client.CheckUserExists(r.Context(), userID); err != nil {
+		slog.ErrorContext(r.Context(), "User validation failed", "error", err)
+		api.WriteErrorCode(w, http.StatusBadRequest, CodeInvalidUser, "Invalid user ID")
+		return
+	}
+
+	stats, err := h.repo.UserStats(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error computing order stats for user", "user_id", userID, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve order statistics")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    stats,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// AnonymizeUserOrders handles POST /orders/user/{user_id}/anonymize - strips personal data
+// from a user's historical orders (called by user service when a user is purged)
+func (h *OrderHandler) AnonymizeUserOrders(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	vars := mux.Vars(r)
+	userID := vars["user_id"]
+
+	if userID == "" {
+		api.WriteError(w, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	count, err := h.repo.AnonymizeByUserID(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error anonymizing orders", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to anonymize orders")
+		return
+	}
+
 	response := models.Response{
 		Success: true,
-		Message: "Order service is healthy",
-		Data: map[string]string{
-			"service": "order-service",
-			"status":  "UP",
+		Message: "Orders anonymized successfully",
+		Data: map[string]int{
+			"anonymized": count,
 		},
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
-// sendErrorResponse sends a standardized error response
-func (h *OrderHandler) sendErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-	w.WriteHeader(statusCode)
+// CheckPurchase handles GET /internal/purchases?user_id=&product_id= - reports whether a user
+// has a confirmed, shipped, or delivered order containing the product (used by product service
+// to mark verified reviews)
+func (h *OrderHandler) CheckPurchase(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := r.URL.Query().Get("user_id")
+	productID := r.URL.Query().Get("product_id")
+	if userID == "" || productID == "" {
+		api.WriteError(w, http.StatusBadRequest, "user_id and product_id are required")
+		return
+	}
+
+	orders, err := h.repo.GetByUserID(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error getting user orders", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to check purchases")
+		return
+	}
+
+	purchased := false
+	for _, order := range orders {
+		if order.IsPurchased() && order.Contains(productID) {
+			purchased = true
+			break
+		}
+	}
 
 	response := models.Response{
-		Success: false,
-		Error:   message,
+		Success: true,
+		Data: map[string]bool{
+			"purchased": purchased,
+		},
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
+
+// UpdateOrderStatus handles PATCH /orders/{id}/status - updates order status
+func (h *OrderHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	orderID := vars["id"]
+
+	if orderID == "" {
+		api.WriteError(w, http.StatusBadRequest, "Order ID is required")
+		return
+	}
+
+	var req models.UpdateOrderStatusRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	// Validate status
+	if !models.IsValidOrderStatus(req.Status) {
+		api.WriteError(w, http.StatusBadRequest, "Invalid order status")
+		return
+	}
+
+	// Get existing order
+	order, err := h.repo.GetByID(r.Context(), orderID)
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeOrderNotFound, "Order not found")
+		return
+	}
+	read := *order
+
+	if order.Status == models.OrderStatusReview {
+		api.WriteErrorCode(w, http.StatusConflict, CodeOrderHeldForReview, "Order is held for fraud review; approve or reject it instead")
+		return
+	}
+
+	// Only moves allowed by the order state machine are accepted
+	var transitionErr *models.TransitionError
+	if err := order.CheckTransition(req.Status); errors.As(err, &transitionErr) {
+		h.sendTransitionErrorResponse(w, transitionErr)
+		return
+	}
+
+	if req.Status == models.OrderStatusCancelled && order.HasShippedItems() {
+		api.WriteErrorCode(w, http.StatusConflict, CodeOrderAlreadyShipped, "Order has items that already shipped and cannot be cancelled")
+		return
+	}
+	if req.Status == models.OrderStatusShipped && order.HasBackorderedItems() {
+		api.WriteErrorCode(w, http.StatusConflict, CodeOrderBackordered, "Order has backordered items that can't ship until their stock arrives")
+		return
+	}
+
+	// Confirming the order turns its stock reservations into sales; cancelling returns the stock
+	var release []models.HeldStock
+	switch {
+	case req.Status == models.OrderStatusCancelled:
+		if err := h.refundPayment(order); err != nil {
+			slog.ErrorContext(r.Context(), "Refunding order failed", "order_id", order.ID, "error", err)
+			api.WriteError(w, http.StatusServiceUnavailable, "Unable to refund the order's payment")
+			return
+		}
+		// Held stock comes back by itself when its reservation expires, but sold stock only comes back here
+		if release, err = h.returnStock(r.Context(), order); err != nil && order.IsPurchased() {
+			slog.ErrorContext(r.Context(), "Returning stock for order failed", "order_id", order.ID, "error", err)
+			api.WriteError(w, http.StatusServiceUnavailable, "Unable to return the order's stock")
+			return
+		}
+	case models.IsPurchasedStatus(req.Status) && !order.IsPurchased():
+		if err := h.commitStock(r.Context(), order); err != nil {
+			slog.ErrorContext(r.Context(), "Committing stock for order failed", "order_id", order.ID, "error", err)
+			if errors.Is(err, client.ErrConflict) {
+				api.WriteErrorCode(w, http.StatusConflict, CodeOrderReservationExpired, "Stock reservation expired; the order must be placed again")
+				return
+			}
+			api.WriteError(w, http.StatusServiceUnavailable, "Unable to commit reserved stock")
+			return
+		}
+		h.fulfillDigitalItems(order, time.Now())
+		if h.loyalty != nil {
+			h.loyalty.Earn(order)
+		}
+	}
+
+	// Shipping or delivering the whole order covers every item not yet in that state
+	switch req.Status {
+	case models.OrderStatusShipped:
+		order.MarkItems(models.ItemStatusShipped)
+	case models.OrderStatusDelivered:
+		order.MarkItems(models.ItemStatusDelivered)
+	}
+
+	// Update status
+	previousStatus := order.Status
+	order.ChangeStatus(req.Status, statusActor(r), strings.TrimSpace(req.Note))
+	order.RecordEvent(models.EventOrderStatusChanged, previousStatus)
+	if req.Status == models.OrderStatusCancelled {
+		order.RecordCancellation(previousStatus, release)
+	}
+
+	// Another change, such as an expiry or a second status update, may have landed while the payment
+	// and stock were dealt with; the order is only saved if it is still as it was read
+	saved, err := h.saveIfUnchanged(r.Context(), &read, order)
+	if errors.Is(err, errOrderMovedOn) {
+		slog.WarnContext(r.Context(), "Order changed while its status was being updated", "order_id", order.ID, "status", req.Status)
+		h.recordRefund(context.WithoutCancel(r.Context()), &read, order)
+		api.WriteErrorCode(w, http.StatusConflict, CodeOrderChanged, "Order changed while its status was being updated; fetch it and try again")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating order status", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to update order status")
+		return
+	}
+
+	// Points move only once the status change is saved, so a change that lost a race moves none
+	switch {
+	case req.Status == models.OrderStatusCancelled:
+		h.returnPoints(saved, time.Now())
+	case models.IsPurchasedStatus(req.Status) && !read.IsPurchased():
+		h.awardPoints(saved, time.Now())
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Order status updated successfully",
+		Data:    saved,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// ApproveOrder handles POST /orders/{id}/review/approve - releases an order held by fraud screening,
+// which then carries on as a pending order with its stock reserved again (admin function)
+func (h *OrderHandler) ApproveOrder(w http.ResponseWriter, r *http.Request) {
+	h.decideReview(w, r, models.OrderStatusPending, "Order approved")
+}
+
+// RejectOrder handles POST /orders/{id}/review/reject - cancels an order held by fraud screening,
+// refunding its payment and returning its stock and points (admin function)
+func (h *OrderHandler) RejectOrder(w http.ResponseWriter, r *http.Request) {
+	h.decideReview(w, r, models.OrderStatusCancelled, "Order rejected")
+}
+
+// decideReview moves an order out of review to status, pending when approved or cancelled when rejected
+func (h *OrderHandler) decideReview(w http.ResponseWriter, r *http.Request, status models.OrderStatus, message string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// The note is optional, so an empty body is fine
+	var req models.ReviewDecisionRequest
+	if !api.DecodeOptionalJSON(w, r, &req) {
+		return
+	}
+
+	order, err := h.repo.GetByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeOrderNotFound, "Order not found")
+		return
+	}
+	if order.Status != models.OrderStatusReview {
+		api.WriteErrorCode(w, http.StatusConflict, CodeOrderNotInReview, fmt.Sprintf("Order is not held for review (status %s)", order.Status))
+		return
+	}
+	read := *order
+
+	var release []models.HeldStock
+	if status == models.OrderStatusCancelled {
+		if err := h.refundPayment(order); err != nil {
+			slog.ErrorContext(r.Context(), "Refunding order failed", "order_id", order.ID, "error", err)
+			api.WriteError(w, http.StatusServiceUnavailable, "Unable to refund the order's payment")
+			return
+		}
+		// The order was never confirmed, so its stock is only held and comes back by itself if this fails
+		if release, err = h.returnStock(r.Context(), order); err != nil {
+			slog.ErrorContext(r.Context(), "Releasing stock for order failed", "order_id", order.ID, "error", err)
+		}
+	}
+
+	order.ChangeStatus(status, statusActor(r), strings.TrimSpace(req.Note))
+	order.RecordEvent(models.EventOrderStatusChanged, models.OrderStatusReview)
+	if status == models.OrderStatusCancelled {
+		order.RecordCancellation(models.OrderStatusReview, release)
+	}
+
+	// The stock reserved when an approved order was placed may have lapsed while it waited, so it is
+	// released and reserved again; if that fails the order stays in review, holding what it held
+	decide := saga.New("decide-review")
+	previousItems := order.Items
+	if status == models.OrderStatusPending {
+		order.Items = append([]models.OrderItem(nil), previousItems...)
+		h.addReleaseSteps(r.Context(), decide, order.ID, previousItems)
+		h.addReservationSteps(r.Context(), decide, order)
+	}
+	var saved *models.Order
+	decide.AddStep(stepPersistOrder, func() error {
+		var err error
+		saved, err = h.saveIfUnchanged(r.Context(), &read, order)
+		return err
+	}, nil)
+	if err := decide.Execute(); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating order", "order_id", order.ID, "error", err)
+		if status == models.OrderStatusPending {
+			h.restoreReservations(context.WithoutCancel(r.Context()), order.ID, previousItems)
+		}
+		var stepErr *saga.StepError
+		switch {
+		case errors.Is(err, errOrderMovedOn):
+			h.recordRefund(context.WithoutCancel(r.Context()), &read, order)
+			api.WriteErrorCode(w, http.StatusConflict, CodeOrderChanged, "Order changed while the review was being decided; fetch it and try again")
+		case errors.As(err, &stepErr) && stepErr.Step == stepReserveStock && errors.Is(err, client.ErrConflict):
+			api.WriteErrorCode(w, http.StatusConflict, CodeOrderInsufficientStock, "Insufficient stock for one or more items")
+		case errors.As(err, &stepErr) && (stepErr.Step == stepReserveStock || stepErr.Step == stepReleaseStock):
+			api.WriteError(w, http.StatusServiceUnavailable, "Unable to reserve stock")
+		default:
+			api.WriteError(w, http.StatusInternalServerError, "Failed to update order")
+		}
+		return
+	}
+
+	if status == models.OrderStatusCancelled {
+		h.returnPoints(saved, time.Now())
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: message,
+		Data:    saved,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// RestoreOrder handles POST /orders/{id}/restore - brings an archived order back into listings (admin function)
+func (h *OrderHandler) RestoreOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	order, err := h.repo.GetByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeOrderNotFound, "Order not found")
+		return
+	}
+	if !order.Archived {
+		api.WriteErrorCode(w, http.StatusConflict, CodeOrderNotArchived, "Order is not archived")
+		return
+	}
+
+	order.Restore(time.Now())
+	if err := h.repo.Update(r.Context(), order); err != nil {
+		slog.ErrorContext(r.Context(), "Error restoring order", "order_id", order.ID, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to restore order")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Order restored",
+		Data:    order,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateShipment handles POST /orders/{id}/shipments - ships some or all of a confirmed order's items.
+// The order moves to shipped once every physical item has shipped.
+func (h *OrderHandler) CreateShipment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.CreateShipmentRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	order, err := h.repo.GetByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeOrderNotFound, "Order not found")
+		return
+	}
+
+	if order.Status != models.OrderStatusConfirmed {
+		api.WriteErrorCode(w, http.StatusConflict, CodeOrderNotShippable, fmt.Sprintf("Only confirmed orders can ship; order is %s", order.Status))
+		return
+	}
+
+	now := time.Now()
+	shipment, err := order.AddShipment(req.ProductIDs, strings.TrimSpace(req.Carrier), strings.TrimSpace(req.TrackingNumber), now)
+	if err != nil {
+		if errors.Is(err, models.ErrNothingToShip) {
+			api.WriteError(w, http.StatusConflict, err.Error())
+			return
+		}
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.EstimatedDelivery != nil {
+		shipment, _ = order.UpdateTracking(shipment.ID, models.TrackingUpdate{EstimatedDelivery: req.EstimatedDelivery}, now)
+	}
+	previousStatus := order.Status
+	applyShipmentStatus(order, statusActor(r), "shipment "+shipment.ID)
+	if order.Status != previousStatus {
+		order.RecordEvent(models.EventOrderStatusChanged, previousStatus)
+	}
+
+	if err := h.repo.Update(r.Context(), order); err != nil {
+		slog.ErrorContext(r.Context(), "Error recording shipment", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to record shipment")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Shipment created successfully",
+		Data:    order,
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateShipment handles PATCH /orders/{id}/shipments/{shipment_id} - marks a shipment delivered.
+// The order moves to delivered once every physical item has been delivered.
+func (h *OrderHandler) UpdateShipment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.UpdateShipmentRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Status != models.ItemStatusDelivered {
+		api.WriteError(w, http.StatusBadRequest, "Shipments can only be updated to delivered")
+		return
+	}
+
+	vars := mux.Vars(r)
+	order, err := h.repo.GetByID(r.Context(), vars["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeOrderNotFound, "Order not found")
+		return
+	}
+
+	shipment, err := order.DeliverShipment(vars["shipment_id"], time.Now())
+	if err != nil {
+		if errors.Is(err, models.ErrShipmentNotFound) {
+			api.WriteErrorCode(w, http.StatusNotFound, CodeShipmentNotFound, "Shipment not found")
+			return
+		}
+		api.WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+	previousStatus := order.Status
+	applyShipmentStatus(order, statusActor(r), "shipment "+shipment.ID+" delivered")
+	if order.Status != previousStatus {
+		order.RecordEvent(models.EventOrderStatusChanged, previousStatus)
+	}
+
+	if err := h.repo.Update(r.Context(), order); err != nil {
+		slog.ErrorContext(r.Context(), "Error recording delivery", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to update shipment")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Shipment updated successfully",
+		Data:    order,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// applyShipmentStatus moves the order to the status its items add up to, if that is a step forward
+func applyShipmentStatus(order *models.Order, actor, note string) {
+	status := order.ShipmentStatus()
+	if status == "" || status == order.Status || order.CheckTransition(status) != nil {
+		return
+	}
+	order.ChangeStatus(status, actor, note)
+}
+
+// PayOrder handles POST /orders/{id}/pay - charges the customer for an unpaid order
+func (h *OrderHandler) PayOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.payments == nil {
+		api.WriteErrorCode(w, http.StatusServiceUnavailable, CodePaymentsUnavailable, "Payments are not available")
+		return
+	}
+
+	var req models.PayOrderRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	order, err := h.repo.GetByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeOrderNotFound, "Order not found")
+		return
+	}
+
+	if !order.CanBePaid() {
+		api.WriteErrorCode(w, http.StatusConflict, CodeOrderNotPayable, fmt.Sprintf("Order cannot be paid (status %s, payment %s)", order.Status, order.PaymentStatus))
+		return
+	}
+	read := *order
+
+	result, err := h.payments.Charge(chargeRequest(order, req.PaymentMethod))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Charging order failed", "order_id", order.ID, "error", err)
+		if errors.Is(err, payment.ErrDeclined) {
+			order.ApplyPayment("", models.PaymentFailed)
+			if _, err := h.saveIfUnchanged(r.Context(), &read, order); err != nil && !errors.Is(err, errOrderMovedOn) {
+				slog.ErrorContext(r.Context(), "Error recording declined payment", "error", err)
+			}
+			api.WriteErrorCode(w, http.StatusPaymentRequired, CodePaymentDeclined, "Payment was declined")
+			return
+		}
+		api.WriteError(w, http.StatusServiceUnavailable, "Unable to take payment")
+		return
+	}
+	order.ApplyPayment(result.PaymentID, result.Status)
+
+	// The order may have been cancelled, amended, or paid while the charge was taken, in which case the
+	// charge is for an order that no longer wants it and is refunded
+	saved, err := h.saveIfUnchanged(r.Context(), &read, order)
+	if errors.Is(err, errOrderMovedOn) {
+		slog.WarnContext(r.Context(), "Order changed while it was being paid; refunding", "order_id", order.ID, "payment_id", result.PaymentID)
+		if err := h.payments.Refund(result.PaymentID); err != nil {
+			slog.ErrorContext(r.Context(), "Refunding payment for an order that changed failed", "order_id", order.ID, "payment_id", result.PaymentID, "error", err)
+			api.WriteError(w, http.StatusServiceUnavailable, "Order changed while it was being paid, and the payment couldn't be refunded")
+			return
+		}
+		api.WriteErrorCode(w, http.StatusConflict, CodeOrderChanged, "Order changed while it was being paid; the payment was refunded")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error recording payment", "payment_id", result.PaymentID, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to record payment")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Payment submitted successfully",
+		Data:    saved,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// PaymentWebhook handles POST /payments/webhook - records payments the provider settles asynchronously.
+// Events for unknown orders or superseded payments are acknowledged and ignored so the provider stops retrying.
+func (h *OrderHandler) PaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.payments == nil {
+		api.WriteErrorCode(w, http.StatusServiceUnavailable, CodePaymentsUnavailable, "Payments are not available")
+		return
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBytes))
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Unable to read payload")
+		return
+	}
+
+	event, err := h.payments.ParseWebhook(payload, r.Header.Get("Stripe-Signature"))
+	if err != nil {
+		slog.InfoContext(r.Context(), "Rejected payment webhook", "error", err)
+		if errors.Is(err, payment.ErrInvalidSignature) {
+			api.WriteError(w, http.StatusBadRequest, "Invalid signature")
+			return
+		}
+		api.WriteError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+
+	if event != nil {
+		// A failed write is logged; the provider's next notification about the payment tries again
+		h.ApplyPaymentEvent(r.Context(), event)
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Webhook received",
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// ApplyPaymentEvent records a settled payment on its order, from a provider webhook or a payment
+// service event. Events for unknown orders or superseded payments are ignored; the error is from
+// saving the order.
+func (h *OrderHandler) ApplyPaymentEvent(ctx context.Context, event *models.PaymentEvent) error {
+	if _, err := h.repo.GetByID(ctx, event.OrderID); err != nil {
+		slog.InfoContext(ctx, "Payment settled for unknown order", "payment_id", event.PaymentID, "order_id", event.OrderID)
+		return nil
+	}
+
+	// The order is checked under the repository's lock, so a refund or a new payment recorded
+	// meanwhile isn't overwritten
+	_, err := h.repo.Modify(ctx, event.OrderID, func(order *models.Order) error {
+		if order.PaymentID != event.PaymentID || order.PaymentStatus == models.PaymentRefunded {
+			slog.InfoContext(ctx, "Ignoring event for a payment the order is no longer", "status", event.Status, "payment_id", event.PaymentID, "order_id", order.ID, "order_payment_id", order.PaymentID, "payment_status", order.PaymentStatus)
+			return errOrderMovedOn
+		}
+		// Events can arrive out of order; a processing notice doesn't undo a payment that already succeeded
+		if order.PaymentStatus == models.PaymentPaid && event.Status == models.PaymentPending {
+			return errOrderMovedOn
+		}
+		if order.PaymentStatus == event.Status {
+			return errOrderMovedOn
+		}
+		order.ApplyPayment(event.PaymentID, event.Status)
+		return nil
+	})
+	if errors.Is(err, errOrderMovedOn) {
+		return nil
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error recording payment", "payment_id", event.PaymentID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// ListOrders handles GET /orders - retrieves orders, newest first, filtered by status, user_id, and
+// a from/to creation date range and paginated with page and limit (admin function)
+func (h *OrderHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	filter, err := filterFromQuery(r)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	orders, pageInfo, err := h.repo.List(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing orders", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve orders")
+		return
+	}
+
+	response := models.Response{
+		Success:    true,
+		Data:       orders,
+		Pagination: pageInfo,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// exportFlushEvery is how many orders are exported between flushes to the client
+const exportFlushEvery = 100
+
+// ExportOrders handles GET /orders/export - streams the orders matching status, user_id, from, and to
+// as CSV, or as JSON with ?format=json, one line per order item (admin function)
+func (h *OrderHandler) ExportOrders(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = export.FormatCSV
+	}
+	filter, err := filterFromQuery(r)
+	if err == nil && format != export.FormatCSV && format != export.FormatJSON {
+		err = errors.New("format must be csv or json")
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Exports cover every match rather than a page
+	filter.Page, filter.Limit = 0, 0
+
+	orders, _, err := h.repo.List(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing orders for export", "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		api.WriteError(w, http.StatusInternalServerError, "Failed to export orders")
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"orders-%s.%s\"", time.Now().UTC().Format("20060102"), format))
+	writer, _ := export.NewWriter(w, format)
+	flusher, _ := w.(http.Flusher)
+	for i, order := range orders {
+		// The status line is already sent, so a failure can only cut the download short
+		if err := writer.WriteOrder(order); err != nil {
+			slog.InfoContext(r.Context(), "Export stopped early", "exported", i, "error", err)
+			return
+		}
+		if flusher != nil && (i+1)%exportFlushEvery == 0 {
+			flusher.Flush()
+		}
+	}
+	if err := writer.Close(); err != nil {
+		slog.ErrorContext(r.Context(), "Error finishing export", "error", err)
+	}
+}
+
+// filterFromQuery builds an order filter from the status, user_id, from, to, page, and limit query parameters
+func filterFromQuery(r *http.Request) (*models.OrderFilter, error) {
+	query := r.URL.Query()
+	filter := &models.OrderFilter{
+		UserID: query.Get("user_id"),
+		Limit:  models.DefaultPageLimit,
+	}
+
+	if status := query.Get("status"); status != "" {
+		filter.Status = models.OrderStatus(status)
+		if !models.IsValidOrderStatus(filter.Status) {
+			return nil, errors.New("status must be pending, review, confirmed, shipped, delivered, or cancelled")
+		}
+	}
+
+	includeArchived, err := includeArchivedFromQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	filter.IncludeArchived = includeArchived
+
+	if fromStr := query.Get("from"); fromStr != "" {
+		from, err := parseQueryTime(fromStr)
+		if err != nil {
+			return nil, errors.New("from must be an RFC 3339 time or a YYYY-MM-DD date")
+		}
+		filter.From = &from
+	}
+	if toStr := query.Get("to"); toStr != "" {
+		to, err := parseQueryTime(toStr)
+		if err != nil {
+			return nil, errors.New("to must be an RFC 3339 time or a YYYY-MM-DD date")
+		}
+		filter.To = &to
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, errors.New("from must be before to")
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			return nil, errors.New("limit must be a positive integer")
+		}
+		if limit > models.MaxPageLimit {
+			limit = models.MaxPageLimit
+		}
+		filter.Limit = limit
+	}
+
+	if pageStr := query.Get("page"); pageStr != "" {
+		page, err := strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			return nil, errors.New("page must be a positive integer")
+		}
+		filter.Page = page
+	}
+
+	return filter, nil
+}
+
+// includeArchivedFromQuery reads the include_archived query parameter; archived orders are left out by default
+func includeArchivedFromQuery(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("include_archived")
+	if value == "" {
+		return false, nil
+	}
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.New("include_archived must be true or false")
+	}
+	return include, nil
+}
+
+// parseQueryTime accepts an RFC 3339 timestamp or a plain date, which means midnight UTC
+func parseQueryTime(value string) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// createOrderSaga builds the steps that place an order: redeem its coupon and points, hold the stock
+// of every physical item, charge paymentMethod if one was given, then store the order
+func (h *OrderHandler) createOrderSaga(ctx context.Context, order *models.Order, paymentMethod string) *saga.Saga {
+	createOrder := saga.New("create-order")
+
+	if order.CouponCode != "" {
+		createOrder.AddStep(stepRedeemCoupon, func() error {
+			_, err := h.coupons.Redeem(order.CouponCode, time.Now())
+			return err
+		}, func() error {
+			return h.coupons.Release(order.CouponCode)
+		})
+	}
+
+	if order.PointsRedeemed > 0 {
+		createOrder.AddStep(stepRedeemPoints, func() error {
+			return h.loyalty.Redeem(order, time.Now())
+		}, func() error {
+			return h.loyalty.RefundRedeemed(order, time.Now())
+		})
+	}
+
+	h.addReservationSteps(ctx, createOrder, order)
+
+	if h.payments != nil && paymentMethod != "" {
+		createOrder.AddStep(stepChargePayment, func() error {
+			result, err := h.payments.Charge(chargeRequest(order, paymentMethod))
+			if err != nil {
+				return err
+			}
+			order.ApplyPayment(result.PaymentID, result.Status)
+			return nil
+		}, func() error {
+			if err := h.payments.Refund(order.PaymentID); err != nil {
+				return fmt.Errorf("payment %s: %w", order.PaymentID, err)
+			}
+			order.ApplyPayment("", models.PaymentUnpaid)
+			return nil
+		})
+	}
+
+	createOrder.AddStep(stepPersistOrder, func() error {
+		order.RecordEvent(models.EventOrderCreated, "")
+		return h.repo.Create(ctx, order)
+	}, nil)
+	return createOrder
+}
+
+// addReservationSteps adds a step holding the stock of each of the order's physical items, so
+// concurrent checkouts can't sell the same units; compensating releases the hold. Backordered
+// items have no stock to hold yet and are left to AllocateBackorders. Releases outlive ctx, so a
+// caller that disconnects mid-saga doesn't strand the stock it held.
+func (h *OrderHandler) addReservationSteps(ctx context.Context, s *saga.Saga, order *models.Order) {
+	releaseCtx := context.WithoutCancel(ctx)
+	for i := range order.Items {
+		item := &order.Items[i]
+		if item.Digital || item.Status == models.ItemStatusBackordered {
+			continue
+		}
+		s.AddStep(stepReserveStock, func() error {
+			reservationID, err := h.client.ReserveStock(ctx, item.ProductID, item.Quantity, order.ID)
+			if err != nil {
+				return fmt.Errorf("product %s: %w", item.ProductID, err)
+			}
+			item.ReservationID = reservationID
+			return nil
+		}, func() error {
+			if err := h.client.ReleaseStock(releaseCtx, item.ProductID, item.ReservationID); err != nil {
+				return fmt.Errorf("reservation %s: %w", item.ReservationID, err)
+			}
+			item.ReservationID = ""
+			return nil
+		})
+	}
+}
+
+// addReleaseSteps adds a step to the saga for each item holding stock that releases its reservation,
+// compensated by reserving the item's quantity again under a new one
+func (h *OrderHandler) addReleaseSteps(ctx context.Context, s *saga.Saga, orderID string, items []models.OrderItem) {
+	reserveCtx := context.WithoutCancel(ctx)
+	for i := range items {
+		item := &items[i]
+		if item.ReservationID == "" {
+			continue
+		}
+		released := false
+		s.AddStep(stepReleaseStock, func() error {
+			err := h.client.ReleaseStock(ctx, item.ProductID, item.ReservationID)
+			// A reservation product service no longer has open was holding nothing to give back
+			if err != nil && !errors.Is(err, client.ErrConflict) && !errors.Is(err, client.ErrNotFound) {
+				return fmt.Errorf("reservation %s: %w", item.ReservationID, err)
+			}
+			released = err == nil
+			item.ReservationID = ""
+			return nil
+		}, func() error {
+			if !released {
+				return nil
+			}
+			reservationID, err := h.client.ReserveStock(reserveCtx, item.ProductID, item.Quantity, orderID)
+			if err != nil {
+				return fmt.Errorf("product %s: %w", item.ProductID, err)
+			}
+			item.ReservationID = reservationID
+			return nil
+		})
+	}
+}
+
+// ExpireStaleOrders cancels unpaid orders that became pending before now minus ttl and returns their
+// stock; an order approved after review counts from its approval. Orders with a payment taken or settling are left for someone to confirm.
+// It reports how many orders were cancelled and how many could not be.
+func (h *OrderHandler) ExpireStaleOrders(ctx context.Context, now time.Time, ttl time.Duration) (int, int, error) {
+	cutoff := now.Add(-ttl)
+	stale, _, err := h.repo.List(ctx, &models.OrderFilter{Status: models.OrderStatusPending, To: &cutoff})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	expired, failed := 0, 0
+	for _, candidate := range stale {
+		if candidate.PaymentStatus == models.PaymentPaid || candidate.PaymentStatus == models.PaymentPending {
+			continue
+		}
+
+		// The order may have been paid, cancelled, or amended since it was listed, so it is checked
+		// again under the repository's lock and only cancelled if it is still stale
+		order, err := h.repo.Modify(ctx, candidate.ID, func(order *models.Order) error {
+			if order.Status != models.OrderStatusPending || order.PaymentStatus == models.PaymentPaid ||
+				order.PaymentStatus == models.PaymentPending || !order.PendingBefore(cutoff) {
+				return errOrderMovedOn
+			}
+			var release []models.HeldStock
+			if h.releaseByEvent {
+				release = order.TakeReservations()
+			}
+			order.ChangeStatus(models.OrderStatusCancelled, expiryActor, fmt.Sprintf("pending for longer than %s", ttl))
+			order.RecordEvent(models.EventOrderStatusChanged, models.OrderStatusPending)
+			order.RecordCancellation(models.OrderStatusPending, release)
+			return nil
+		})
+		if errors.Is(err, errOrderMovedOn) {
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Error expiring order", "order_id", candidate.ID, "error", err)
+			failed++
+			continue
+		}
+		expired++
+
+		// A pending order's reservations lapse by themselves, so a failed release only delays the stock's return
+		if !h.releaseByEvent {
+			h.releaseStock(ctx, order)
+			h.saveReservations(ctx, order.ID, order.Items)
+		}
+		h.returnPoints(order, now)
+	}
+	return expired, failed, nil
+}
+
+// errOrderMovedOn rejects a change to an order whose state is no longer the one the change was for
+var errOrderMovedOn = errors.New("order has moved on")
+
+// saveReservations records the items' reservation IDs on the stored order, which has the same items in
+// the same order, for reservations released or made since it was stored
+func (h *OrderHandler) saveReservations(ctx context.Context, orderID string, items []models.OrderItem) {
+	_, err := h.repo.Modify(ctx, orderID, func(stored *models.Order) error {
+		for i := range stored.Items {
+			if i < len(items) && stored.Items[i].ProductID == items[i].ProductID {
+				stored.Items[i].ReservationID = items[i].ReservationID
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error saving stock reservations", "order_id", orderID, "error", err)
+	}
+}
+
+// restoreReservations records on the stored order the reservations made again for its items after a
+// change to it failed, as saveReservations does. An order cancelled in the meantime no longer holds
+// stock, so they are released instead.
+func (h *OrderHandler) restoreReservations(ctx context.Context, orderID string, items []models.OrderItem) {
+	_, err := h.repo.Modify(ctx, orderID, func(stored *models.Order) error {
+		if stored.Status == models.OrderStatusCancelled {
+			return errOrderMovedOn
+		}
+		for i := range stored.Items {
+			if i < len(items) && stored.Items[i].ProductID == items[i].ProductID {
+				stored.Items[i].ReservationID = items[i].ReservationID
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errOrderMovedOn) {
+		h.releaseReservations(ctx, items)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error saving stock reservations", "order_id", orderID, "error", err)
+	}
+}
+
+// saveIfUnchanged saves order, a changed copy of read, as long as the stored order is still as read
+// was: same status, same payment status, and not updated since. Otherwise nothing is saved and
+// errOrderMovedOn is returned, so a change worked out while the provider or product service was called
+// can't overwrite one that landed meanwhile, such as an expiry or a payment.
+func (h *OrderHandler) saveIfUnchanged(ctx context.Context, read, order *models.Order) (*models.Order, error) {
+	return h.repo.Modify(ctx, order.ID, func(stored *models.Order) error {
+		if stored.Status != read.Status || stored.PaymentStatus != read.PaymentStatus || !stored.UpdatedAt.Equal(read.UpdatedAt) {
+			return errOrderMovedOn
+		}
+		*stored = *order
+		return nil
+	})
+}
+
+// recordRefund marks the stored order's payment refunded when order, a changed copy of read that
+// couldn't be saved, refunded it. The money is back either way, so the order shouldn't say otherwise.
+func (h *OrderHandler) recordRefund(ctx context.Context, read, order *models.Order) {
+	if order.PaymentStatus != models.PaymentRefunded || read.PaymentStatus == models.PaymentRefunded {
+		return
+	}
+	slog.WarnContext(ctx, "Recording refund made for an order that changed", "order_id", order.ID, "payment_id", order.PaymentID)
+	_, err := h.repo.Modify(ctx, order.ID, func(stored *models.Order) error {
+		if stored.PaymentID != order.PaymentID || stored.PaymentStatus == models.PaymentRefunded {
+			return errOrderMovedOn
+		}
+		stored.ApplyPayment(order.PaymentID, models.PaymentRefunded)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errOrderMovedOn) {
+		slog.ErrorContext(ctx, "Error recording refund", "order_id", order.ID, "payment_id", order.PaymentID, "error", err)
+	}
+}
+
+// ArchiveDeliveredOrders archives orders delivered, or restored from the archive, before now minus
+// retention, dropping their cached invoices. It reports how many orders were archived and how many
+// could not be.
+func (h *OrderHandler) ArchiveDeliveredOrders(ctx context.Context, now time.Time, retention time.Duration) (int, int, error) {
+	delivered, _, err := h.repo.List(ctx, &models.OrderFilter{Status: models.OrderStatusDelivered})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	cutoff := now.Add(-retention)
+	archived, failed := 0, 0
+	for _, candidate := range delivered {
+		if !candidate.DueForArchive(cutoff) {
+			continue
+		}
+		// A return or refund may have changed the order since it was listed, so it is checked again
+		// under the repository's lock and only archived if it is still delivered and due
+		_, err := h.repo.Modify(ctx, candidate.ID, func(order *models.Order) error {
+			if !order.DueForArchive(cutoff) {
+				return errOrderMovedOn
+			}
+			order.Archive(now)
+			return nil
+		})
+		if errors.Is(err, errOrderMovedOn) {
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Error archiving order", "order_id", candidate.ID, "error", err)
+			failed++
+			continue
+		}
+		h.invoices.Remove(candidate.ID)
+		archived++
+	}
+	return archived, failed, nil
+}
+
+// PurgeArchivedOrders deletes orders archived before now minus keepFor. It reports how many orders
+// were deleted and how many could not be.
+func (h *OrderHandler) PurgeArchivedOrders(ctx context.Context, now time.Time, keepFor time.Duration) (int, int, error) {
+	orders, _, err := h.repo.List(ctx, &models.OrderFilter{Status: models.OrderStatusDelivered, IncludeArchived: true})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	cutoff := now.Add(-keepFor)
+	purged, failed := 0, 0
+	for _, order := range orders {
+		if !order.Archived || order.ArchivedAt == nil || !order.ArchivedAt.Before(cutoff) {
+			continue
+		}
+		if err := h.repo.Delete(ctx, order.ID); err != nil {
+			slog.ErrorContext(ctx, "Error deleting archived order", "order_id", order.ID, "error", err)
+			failed++
+			continue
+		}
+		h.invoices.Remove(order.ID)
+		purged++
+	}
+	return purged, failed, nil
+}
+
+// AllocateBackorders reserves stock for the backordered items of open orders, oldest order first, so
+// stock that has arrived goes to the customers who have waited longest. Items of orders already
+// confirmed are committed straight away, as their other items were when the order was confirmed.
+// It reports how many items were filled and how many could not be; items still short of stock
+// count as neither and are tried again on the next pass, and items of orders that were cancelled or
+// filled meanwhile count as neither and have their new reservations released.
+func (h *OrderHandler) AllocateBackorders(ctx context.Context, now time.Time) (int, int, error) {
+	var waiting []*models.Order
+	for _, status := range []models.OrderStatus{models.OrderStatusPending, models.OrderStatusConfirmed} {
+		orders, _, err := h.repo.List(ctx, &models.OrderFilter{Status: status})
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, order := range orders {
+			if order.HasBackorderedItems() {
+				waiting = append(waiting, order)
+			}
+		}
+	}
+	sort.Slice(waiting, func(i, j int) bool {
+		return waiting[i].CreatedAt.Before(waiting[j].CreatedAt)
+	})
+
+	allocated, failed := 0, 0
+	for _, order := range waiting {
+		var filled []models.OrderItem
+		for _, item := range order.Items {
+			if item.Status != models.ItemStatusBackordered {
+				continue
+			}
+			reservationID, err := h.client.ReserveStock(ctx, item.ProductID, item.Quantity, order.ID)
+			if errors.Is(err, client.ErrConflict) {
+				continue
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "Error allocating stock for product of order", "product_id", item.ProductID, "order_id", order.ID, "error", err)
+				failed++
+				continue
+			}
+			item.ReservationID = reservationID
+			filled = append(filled, item)
+		}
+		if len(filled) == 0 {
+			continue
+		}
+
+		// The order may have been cancelled, expired, or had its items filled since it was listed, so
+		// the reservations are saved under the repository's lock, and only for items of an open order
+		// still waiting for them. They are saved before any is committed, as only a reservation still
+		// held can be released if the order can't be saved.
+		var kept, unwanted []models.OrderItem
+		saved, err := h.repo.Modify(ctx, order.ID, func(stored *models.Order) error {
+			kept, unwanted = nil, nil
+			if stored.Status != models.OrderStatusPending && stored.Status != models.OrderStatusConfirmed {
+				unwanted = filled
+				return errOrderMovedOn
+			}
+			for _, item := range filled {
+				if stored.AllocateItem(item.ProductID, item.ReservationID, now) {
+					kept = append(kept, item)
+				} else {
+					unwanted = append(unwanted, item)
+				}
+			}
+			if len(kept) == 0 {
+				return errOrderMovedOn
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errOrderMovedOn) {
+			slog.ErrorContext(ctx, "Error saving stock allocated to order", "order_id", order.ID, "error", err)
+			h.releaseReservations(ctx, filled)
+			failed += len(filled)
+			continue
+		}
+		h.releaseReservations(ctx, unwanted)
+		if errors.Is(err, errOrderMovedOn) {
+			continue
+		}
+		for _, item := range kept {
+			if saved.IsPurchased() {
+				if err := h.client.CommitStock(ctx, item.ProductID, item.ReservationID); err != nil {
+					slog.ErrorContext(ctx, "Error committing stock allocated to order", "product_id", item.ProductID, "order_id", order.ID, "error", err)
+					h.backorderAgain(ctx, order.ID, item, now)
+					failed++
+					continue
+				}
+			}
+			allocated++
+		}
+	}
+	return allocated, failed, nil
+}
+
+// backorderAgain releases the stock allocated to an item and puts the item back on backorder, for the
+// next pass to try again
+func (h *OrderHandler) backorderAgain(ctx context.Context, orderID string, item models.OrderItem, now time.Time) {
+	// An uncommitted reservation lapses by itself, so a failed release only delays the stock's return
+	h.releaseReservations(ctx, []models.OrderItem{item})
+	_, err := h.repo.Modify(ctx, orderID, func(order *models.Order) error {
+		if !order.BackorderItem(item.ProductID, item.ReservationID, now) {
+			return errOrderMovedOn
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errOrderMovedOn) {
+		slog.ErrorContext(ctx, "Error putting item back on backorder", "product_id", item.ProductID, "order_id", orderID, "error", err)
+	}
+}
+
+// chargeRequest describes the payment for the order's total. It is keyed on the order as last saved,
+// so paying again for an order left unchanged by a failed attempt can't charge twice, while a new
+// attempt after a decline, which is saved on the order, gets a key of its own.
+func chargeRequest(order *models.Order, paymentMethod string) payment.ChargeRequest {
+	return payment.ChargeRequest{
+		OrderID:        order.ID,
+		UserID:         order.UserID,
+		Amount:         order.Total,
+		Currency:       models.OrderCurrency,
+		PaymentMethod:  paymentMethod,
+		IdempotencyKey: fmt.Sprintf("%s-%d", order.ID, order.UpdatedAt.UnixNano()),
+	}
+}
+
+// refundPayment refunds the order's payment if one was taken or is still settling
+func (h *OrderHandler) refundPayment(order *models.Order) error {
+	if order.PaymentStatus != models.PaymentPaid && order.PaymentStatus != models.PaymentPending {
+		return nil
+	}
+	if h.payments == nil {
+		return errors.New("no payment provider configured")
+	}
+	if err := h.payments.Refund(order.PaymentID); err != nil {
+		return fmt.Errorf("payment %s: %w", order.PaymentID, err)
+	}
+	order.ApplyPayment(order.PaymentID, models.PaymentRefunded)
+	return nil
+}
+
+// awardPoints credits the buyer with the points a newly purchased order earned, once it is saved.
+// Failures are logged; the order is confirmed regardless.
+func (h *OrderHandler) awardPoints(order *models.Order, now time.Time) {
+	if h.loyalty == nil {
+		return
+	}
+	if err := h.loyalty.Award(order, now); err != nil {
+		slog.Error("Awarding loyalty points for order failed", "order_id", order.ID, "error", err)
+	}
+}
+
+// returnPoints gives back the points a cancelled order redeemed and takes back those it earned, once
+// its cancellation is saved. Failures are logged; the order is cancelled regardless.
+func (h *OrderHandler) returnPoints(order *models.Order, now time.Time) {
+	if h.loyalty == nil {
+		return
+	}
+	if err := h.loyalty.RefundRedeemed(order, now); err != nil {
+		slog.Error("Refunding loyalty points redeemed on order failed", "order_id", order.ID, "error", err)
+	}
+	if err := h.loyalty.Reverse(order, now); err != nil {
+		slog.Error("Reversing loyalty points earned on order failed", "order_id", order.ID, "error", err)
+	}
+}
+
+// releaseStock returns the order's reserved stock. Reservations product service no longer has open
+// count as returned. Failures are logged, and the last one returned, so callers that can't leave the
+// reservation to expire can stop.
+func (h *OrderHandler) releaseStock(ctx context.Context, order *models.Order) error {
+	return h.releaseReservations(ctx, order.Items)
+}
+
+// returnStock returns a cancelled order's stock. It is released directly, as releaseStock does, unless
+// stock is released by event, in which case the reservations are handed back for the order.cancelled
+// event to name.
+func (h *OrderHandler) returnStock(ctx context.Context, order *models.Order) ([]models.HeldStock, error) {
+	if h.releaseByEvent {
+		return order.TakeReservations(), nil
+	}
+	return nil, h.releaseStock(ctx, order)
+}
+
+// releaseReservations returns the stock held for each of the items, as releaseStock does
+func (h *OrderHandler) releaseReservations(ctx context.Context, items []models.OrderItem) error {
+	var releaseErr error
+	for i := range items {
+		item := &items[i]
+		if item.ReservationID == "" {
+			continue
+		}
+		err := h.client.ReleaseStock(ctx, item.ProductID, item.ReservationID)
+		if err != nil && !errors.Is(err, client.ErrConflict) && !errors.Is(err, client.ErrNotFound) {
+			slog.ErrorContext(ctx, "Error releasing reservation", "reservation_id", item.ReservationID, "error", err)
+			releaseErr = fmt.Errorf("product %s: %w", item.ProductID, err)
+			continue
+		}
+		item.ReservationID = ""
+	}
+	return releaseErr
+}
+
+// commitStock commits every stock reservation held by the order
+func (h *OrderHandler) commitStock(ctx context.Context, order *models.Order) error {
+	for _, item := range order.Items {
+		if item.ReservationID == "" {
+			continue
+		}
+		if err := h.client.CommitStock(ctx, item.ProductID, item.ReservationID); err != nil {
+			return fmt.Errorf("product %s: %w", item.ProductID, err)
+		}
+	}
+	return nil
+}
+
+// fulfillDigitalItems issues a download link for each digital item of a newly purchased order
+func (h *OrderHandler) fulfillDigitalItems(order *models.Order, now time.Time) {
+	for i := range order.Items {
+		item := &order.Items[i]
+		if !item.Digital || item.Fulfillment != nil {
+			continue
+		}
+		if h.downloads == nil {
+			slog.Warn("No download issuer configured; digital item of order left unfulfilled", "product_id", item.ProductID, "order_id", order.ID)
+			continue
+		}
+		item.Fulfillment = h.downloads.Issue(order.ID, item.ProductID, now)
+	}
+}
+
+// statusActor identifies who changed an order's status for its history
+func statusActor(r *http.Request) string {
+	if service := auth.ServiceFromContext(r.Context()); service != "" {
+		return service
+	}
+	return "anonymous"
+}
+
+// couponPlacementError explains why a coupon code can't be applied
+func couponPlacementError(err error) *placementError {
+	switch {
+	case errors.Is(err, models.ErrCouponNotFound):
+		return &placementError{status: http.StatusBadRequest, code: CodeCouponNotFound, message: "Invalid coupon code"}
+	case errors.Is(err, models.ErrCouponExpired):
+		return &placementError{status: http.StatusConflict, code: CodeCouponExpired, message: "Coupon has expired"}
+	case errors.Is(err, models.ErrCouponUsedUp):
+		return &placementError{status: http.StatusConflict, code: CodeCouponUsedUp, message: "Coupon has reached its usage limit"}
+	default:
+		slog.Error("Coupon lookup failed", "error", err)
+		return &placementError{status: http.StatusInternalServerError, message: "Unable to apply coupon"}
+	}
+}
+
+// sendPlacementErrorResponse reports why an order couldn't be placed
+func (h *OrderHandler) sendPlacementErrorResponse(w http.ResponseWriter, err error) {
+	var placementErr *placementError
+	if !errors.As(err, &placementErr) {
+		slog.Error("Placing order failed", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to create order")
+		return
+	}
+	api.WriteErrorResponse(w, placementErr.status, api.Response[api.Unpaged]{
+		Code:  placementErr.code,
+		Error: placementErr.message,
+		Data:  placementErr.data,
+	})
+}
+
+// sendTransitionErrorResponse rejects an illegal status change, listing the statuses the order can move to
+func (h *OrderHandler) sendTransitionErrorResponse(w http.ResponseWriter, transitionErr *models.TransitionError) {
+	api.WriteErrorResponse(w, http.StatusConflict, api.Response[api.Unpaged]{
+		Code:  CodeOrderInvalidTransition,
+		Error: transitionErr.Error(),
+		Data:  transitionErr,
+	})
+}
+
+// sendItemErrorResponse rejects an order because of some of its lines, describing each line in data
+func (h *OrderHandler) sendItemErrorResponse(w http.ResponseWriter, itemErrs *models.ItemValidationErrors) {
+	api.WriteErrorResponse(w, http.StatusBadRequest, api.Response[api.Unpaged]{
+		Code:  itemErrorCode(itemErrs),
+		Error: itemErrs.Error(),
+		Data:  itemErrs,
+	})
+}
+
+// itemErrorCode names what was wrong with an order's rejected lines: the reason they share, or
+// ORDER_ITEMS_REJECTED when they were rejected for different reasons
+func itemErrorCode(itemErrs *models.ItemValidationErrors) string {
+	reason := ""
+	for _, itemErr := range itemErrs.Errors {
+		if reason != "" && itemErr.Code != reason {
+			return CodeOrderItemsRejected
+		}
+		reason = itemErr.Code
+	}
+	switch reason {
+	case models.ItemErrorInsufficientStock:
+		return CodeOrderInsufficientStock
+	case models.ItemErrorInvalidProduct:
+		return CodeOrderInvalidProduct
+	case models.ItemErrorBelowMinimum, models.ItemErrorAboveMaximum:
+		return CodeOrderQuantityOutOfRange
+	}
+	return CodeOrderItemsRejected
+}
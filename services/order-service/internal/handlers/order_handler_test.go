@@ -2,30 +2,114 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+	"ecommerce/pkg/auth"
+	"order-service/internal/client"
+	"order-service/internal/fulfillment"
+	"order-service/internal/loyalty"
 	"order-service/internal/models"
+	"order-service/internal/payment"
 	"order-service/internal/repository"
+	"order-service/internal/shipping"
+	"order-service/internal/tax"
+
+	"github.com/gorilla/mux"
 )
 
 type mockClient struct {
 	userErr   error
 	itemsErr  error
 	items     []models.OrderItem
+	address   *models.Address
+	// addressErr fails address lookups, as an unreachable user service would
+	addressErr error
+	// outOfStock lists product IDs whose reservation fails with a conflict
+	outOfStock  map[string]bool
+	// stock, when set, counts each product's available units: reservations take from it and
+	// releases give back what they held. Releasing a reservation no longer held is a conflict.
+	stock       map[string]int
+	held        map[string]int
+	commitErr   error
+	releaseErr  error
+	reserved    []string
+	released    []string
+	committed   []string
+	userLookups int
+	// onRelease, when set, runs once as the next release starts, as a change landing meanwhile would
+	onRelease func()
 }
 
-func (m *mockClient) CheckUserExists(userID string) error { return m.userErr }
-func (m *mockClient) ValidateOrderItems(items []models.CreateOrderItem) ([]models.OrderItem, error) {
+func (m *mockClient) CheckUserExists(ctx context.Context, userID string) error { return m.userErr }
+func (m *mockClient) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	if m.userErr != nil { return nil, m.userErr }
+	m.userLookups++
+	return &models.User{ID: userID, Name: "Test User", Email: "test@example.com", Active: true}, nil
+}
+func (m *mockClient) ValidateOrderItems(ctx context.Context, items []models.CreateOrderItem) ([]models.OrderItem, error) {
 	if m.itemsErr != nil { return nil, m.itemsErr }
 	return m.items, nil
 }
+func (m *mockClient) GetShippingAddress(ctx context.Context, userID, addressID string) (*models.Address, error) {
+	if m.addressErr != nil {
+		return nil, m.addressErr
+	}
+	if m.address == nil || (addressID != "" && addressID != m.address.ID) {
+		return nil, client.ErrNotFound
+	}
+	return m.address, nil
+}
+
+func (m *mockClient) ReserveStock(ctx context.Context, productID string, quantity int, orderID string) (string, error) {
+	if m.outOfStock[productID] {
+		return "", client.ErrConflict
+	}
+	id := "r-" + productID
+	if m.stock != nil {
+		if m.stock[productID] < quantity {
+			return "", client.ErrConflict
+		}
+		if m.held == nil {
+			m.held = make(map[string]int)
+		}
+		m.stock[productID] -= quantity
+		m.held[id] = quantity
+	}
+	m.reserved = append(m.reserved, id)
+	return id, nil
+}
+func (m *mockClient) ReleaseStock(ctx context.Context, productID, reservationID string) error {
+	if onRelease := m.onRelease; onRelease != nil {
+		m.onRelease = nil
+		onRelease()
+	}
+	if m.releaseErr != nil { return m.releaseErr }
+	if m.stock != nil {
+		if _, held := m.held[reservationID]; !held {
+			return client.ErrConflict
+		}
+		m.stock[productID] += m.held[reservationID]
+		delete(m.held, reservationID)
+	}
+	m.released = append(m.released, reservationID)
+	return nil
+}
+func (m *mockClient) CommitStock(ctx context.Context, productID, reservationID string) error {
+	if m.commitErr != nil { return m.commitErr }
+	m.committed = append(m.committed, reservationID)
+	return nil
+}
 
 func TestCreateOrder_Success(t *testing.T) {
 	repo := repository.NewInMemoryOrderRepository()
 	mock := &mockClient{items: []models.OrderItem{models.NewOrderItem("p1","Prod",10,1)}}
-	h := NewOrderHandler(repo, mock)
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
 	body := bytes.NewBufferString(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1}]}`)
 	req := httptest.NewRequest(http.MethodPost, "/orders", body)
 	rec := httptest.NewRecorder()
@@ -38,8 +122,8 @@ func TestCreateOrder_Success(t *testing.T) {
 
 func TestCreateOrder_InvalidUser(t *testing.T) {
 	repo := repository.NewInMemoryOrderRepository()
-	mock := &mockClient{userErr: errors.New("user not found")}
-	h := NewOrderHandler(repo, mock)
+	mock := &mockClient{userErr: client.ErrNotFound}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
 	body := bytes.NewBufferString(`{"user_id":"bad","items":[{"product_id":"p1","quantity":1}]}`)
 	req := httptest.NewRequest(http.MethodPost, "/orders", body)
 	rec := httptest.NewRecorder()
@@ -50,13 +134,72 @@ func TestCreateOrder_InvalidUser(t *testing.T) {
 	}
 }
 
+func TestCreateOrder_UserServiceUnavailable(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{userErr: errors.New("failed to call user-service: connection refused"), items: []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1)}}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+	create := func(body string) int {
+		rec := httptest.NewRecorder()
+		h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body)))
+		return rec.Code
+	}
+
+	if code := create(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1}]}`); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 when the user can't be checked got %d", code)
+	}
+	mock.userErr = nil
+	mock.addressErr = errors.New("user-service returned status 500")
+	if code := create(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1}],"shipping_address_id":"a1"}`); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 when the address can't be looked up got %d", code)
+	}
+	if code := create(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1}]}`); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 when the default address can't be looked up got %d", code)
+	}
+	mock.addressErr = nil
+	if code := create(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1}],"shipping_address_id":"a1"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an address the user doesn't have got %d", code)
+	}
+	if len(mock.reserved) != 0 {
+		t.Fatalf("expected no stock reserved, got %v", mock.reserved)
+	}
+}
+
+func TestCreateOrder_ReportsInvalidItemsByField(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+	body := bytes.NewBufferString(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1},{"product_id":"p2","quantity":0}]}`)
+	req := httptest.NewRequest(http.MethodPost, "/orders", body)
+	rec := httptest.NewRecorder()
+
+	h.CreateOrder(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 got %d", rec.Code)
+	}
+	var resp struct {
+		Details []struct {
+			Field string `json:"field"`
+			Rule  string `json:"rule"`
+		} `json:"details"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Details) != 1 || resp.Details[0].Field != "items[1].quantity" || resp.Details[0].Rule != "required" {
+		t.Fatalf("expected the second item's quantity to be reported, got %+v", resp.Details)
+	}
+	if len(mock.reserved) != 0 {
+		t.Errorf("expected no stock to be reserved, got %v", mock.reserved)
+	}
+}
+
 func TestUpdateOrderStatus_InvalidStatus(t *testing.T) {
 	repo := repository.NewInMemoryOrderRepository()
 	mock := &mockClient{}
-	h := NewOrderHandler(repo, mock)
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
 	// create base order directly
 	o := models.NewOrder("u1", []models.OrderItem{models.NewOrderItem("p1","Prod",10,1)})
-	_ = repo.Create(o)
+	_ = repo.Create(context.Background(), o)
 
 	body := bytes.NewBufferString(`{"status":"wrong"}`)
 	req := httptest.NewRequest(http.MethodPatch, "/orders/"+o.ID+"/status", body)
@@ -67,3 +210,1648 @@ func TestUpdateOrderStatus_InvalidStatus(t *testing.T) {
 		t.Fatalf("expected 400 got %d", rec.Code)
 	}
 }
+
+func TestCreateOrder_AttachesDefaultShippingAddress(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{
+		items:   []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1)},
+		address: &models.Address{ID: "a1", Line1: "1 Main St", City: "Nairobi", Country: "KE"},
+	}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+	body := bytes.NewBufferString(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1}]}`)
+	req := httptest.NewRequest(http.MethodPost, "/orders", body)
+	rec := httptest.NewRecorder()
+
+	h.CreateOrder(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", rec.Code)
+	}
+	orders, _, _ := repo.List(context.Background(), nil)
+	if len(orders) != 1 || orders[0].ShippingAddress == nil || orders[0].ShippingAddress.ID != "a1" {
+		t.Fatalf("expected order to carry default shipping address")
+	}
+}
+
+func TestCreateOrder_UnknownShippingAddress(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{items: []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1)}}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+	body := bytes.NewBufferString(`{"user_id":"u1","shipping_address_id":"nope","items":[{"product_id":"p1","quantity":1}]}`)
+	req := httptest.NewRequest(http.MethodPost, "/orders", body)
+	rec := httptest.NewRecorder()
+
+	h.CreateOrder(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 got %d", rec.Code)
+	}
+}
+
+func TestCheckPurchase(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	h := NewOrderHandler(repo, &mockClient{}, nil, nil, nil, nil, nil, nil, nil)
+	pending := models.NewOrder("u1", []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1)})
+	_ = repo.Create(context.Background(), pending)
+
+	check := func(productID string) bool {
+		rec := httptest.NewRecorder()
+		h.CheckPurchase(rec, httptest.NewRequest(http.MethodGet, "/internal/purchases?user_id=u1&product_id="+productID, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200 got %d", rec.Code)
+		}
+		return bytes.Contains(rec.Body.Bytes(), []byte(`"purchased":true`))
+	}
+
+	if check("p1") {
+		t.Error("pending orders should not count as purchases")
+	}
+	pending.UpdateStatus(models.OrderStatusDelivered)
+	_ = repo.Update(context.Background(), pending)
+	if !check("p1") {
+		t.Error("expected delivered order to count as a purchase")
+	}
+	if check("p2") {
+		t.Error("expected no purchase for a product not in the order")
+	}
+}
+
+func TestCreateOrder_ReservesStockAndReleasesOnFailure(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{
+		items:      []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1), models.NewOrderItem("p2", "Other", 5, 2)},
+		outOfStock: map[string]bool{"p2": true},
+	}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+	body := `{"user_id":"u1","items":[{"product_id":"p1","quantity":1},{"product_id":"p2","quantity":2}]}`
+
+	rec := httptest.NewRecorder()
+	h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 got %d", rec.Code)
+	}
+	var resp models.Response
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.Code != CodeOrderInsufficientStock {
+		t.Fatalf("expected code %s, got %q (%v)", CodeOrderInsufficientStock, resp.Code, err)
+	}
+	if len(mock.released) != 1 || mock.released[0] != "r-p1" {
+		t.Fatalf("expected the p1 reservation to be released, got %v", mock.released)
+	}
+	if orders, _, _ := repo.List(context.Background(), nil); len(orders) != 0 {
+		t.Fatalf("expected no order to be stored")
+	}
+
+	mock.outOfStock = nil
+	rec = httptest.NewRecorder()
+	h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", rec.Code)
+	}
+	orders, _, _ := repo.List(context.Background(), nil)
+	if len(orders) != 1 || orders[0].Items[1].ReservationID != "r-p2" {
+		t.Fatalf("expected order items to carry reservation IDs")
+	}
+}
+
+func TestUpdateOrderStatus_CommitsAndReleasesReservations(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+	item := models.NewOrderItem("p1", "Prod", 10, 1)
+	item.ReservationID = "r-p1"
+	o := models.NewOrder("u1", []models.OrderItem{item})
+	_ = repo.Create(context.Background(), o)
+
+	update := func(status string) int {
+		req := httptest.NewRequest(http.MethodPatch, "/orders/"+o.ID+"/status", bytes.NewBufferString(`{"status":"`+status+`"}`))
+		req = mux.SetURLVars(req, map[string]string{"id": o.ID})
+		rec := httptest.NewRecorder()
+		h.UpdateOrderStatus(rec, req)
+		return rec.Code
+	}
+
+	mock.commitErr = client.ErrConflict
+	if code := update("confirmed"); code != http.StatusConflict {
+		t.Fatalf("expected 409 when the reservation expired got %d", code)
+	}
+
+	mock.commitErr = nil
+	if code := update("confirmed"); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	if len(mock.committed) != 1 {
+		t.Fatalf("expected reservation to be committed, got %v", mock.committed)
+	}
+
+	if code := update("cancelled"); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	if len(mock.released) != 1 || mock.released[0] != "r-p1" {
+		t.Fatalf("expected reservation to be released on cancel, got %v", mock.released)
+	}
+}
+
+type mockPayments struct {
+	chargeErr error
+	charged   []string
+	refunded  []string
+	// onCharge, when set, runs while a charge is being taken, as a change landing meanwhile would
+	onCharge func()
+}
+
+func (m *mockPayments) Charge(request payment.ChargeRequest) (*models.PaymentResult, error) {
+	if m.chargeErr != nil { return nil, m.chargeErr }
+	if m.onCharge != nil {
+		m.onCharge()
+	}
+	m.charged = append(m.charged, request.OrderID)
+	return &models.PaymentResult{PaymentID: "pay-" + request.OrderID, Status: models.PaymentPaid}, nil
+}
+func (m *mockPayments) Refund(paymentID string) error {
+	m.refunded = append(m.refunded, paymentID)
+	return nil
+}
+func (m *mockPayments) ParseWebhook(payload []byte, signature string) (*models.PaymentEvent, error) {
+	return payment.NewMockProvider().ParseWebhook(payload, signature)
+}
+
+// failingOrderRepo fails every Create, as if the order store were down
+type failingOrderRepo struct {
+	repository.OrderRepository
+}
+
+func (r *failingOrderRepo) Create(ctx context.Context, order *models.Order) error { return errors.New("store unavailable") }
+
+func TestCreateOrder_SagaCompensatesFailedSteps(t *testing.T) {
+	body := `{"user_id":"u1","items":[{"product_id":"p1","quantity":1},{"product_id":"p2","quantity":2}],"payment_method":"pm_card_visa"}`
+	items := []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1), models.NewOrderItem("p2", "Other", 5, 2)}
+
+	// A declined payment returns the reserved stock
+	mock := &mockClient{items: items}
+	payments := &mockPayments{chargeErr: payment.ErrDeclined}
+	h := NewOrderHandler(repository.NewInMemoryOrderRepository(), mock, payments, nil, nil, nil, nil, nil, nil)
+	rec := httptest.NewRecorder()
+	h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusPaymentRequired {
+		t.Fatalf("expected 402 got %d", rec.Code)
+	}
+	if len(mock.released) != 2 || mock.released[0] != "r-p2" || mock.released[1] != "r-p1" {
+		t.Fatalf("expected both reservations released in reverse order, got %v", mock.released)
+	}
+
+	// An order that can't be stored is refunded and its stock returned
+	mock = &mockClient{items: items}
+	payments = &mockPayments{}
+	h = NewOrderHandler(&failingOrderRepo{repository.NewInMemoryOrderRepository()}, mock, payments, nil, nil, nil, nil, nil, nil)
+	rec = httptest.NewRecorder()
+	h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 got %d", rec.Code)
+	}
+	if len(payments.charged) != 1 || len(payments.refunded) != 1 || payments.refunded[0] != "pay-"+payments.charged[0] {
+		t.Fatalf("expected the charge to be refunded, got charged %v refunded %v", payments.charged, payments.refunded)
+	}
+	if len(mock.released) != 2 {
+		t.Fatalf("expected both reservations released, got %v", mock.released)
+	}
+
+	// When every step succeeds the order records its payment
+	repo := repository.NewInMemoryOrderRepository()
+	h = NewOrderHandler(repo, &mockClient{items: items}, &mockPayments{}, nil, nil, nil, nil, nil, nil)
+	rec = httptest.NewRecorder()
+	h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body)))
+	orders, _, _ := repo.List(context.Background(), nil)
+	if rec.Code != http.StatusCreated || len(orders) != 1 || orders[0].PaymentID != "pay-"+orders[0].ID || orders[0].PaymentStatus != models.PaymentPaid {
+		t.Fatalf("expected a paid order to be stored, got %d", rec.Code)
+	}
+}
+
+func TestPayOrder_SettledByWebhookAndRefundedOnCancel(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	h := NewOrderHandler(repo, &mockClient{}, payment.NewMockProvider(), nil, nil, nil, nil, nil, nil)
+	order := models.NewOrder("u1", []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1)})
+	_ = repo.Create(context.Background(), order)
+
+	pay := func(method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/orders/"+order.ID+"/pay", bytes.NewBufferString(`{"payment_method":"`+method+`"}`))
+		req = mux.SetURLVars(req, map[string]string{"id": order.ID})
+		rec := httptest.NewRecorder()
+		h.PayOrder(rec, req)
+		return rec
+	}
+
+	// A declined card leaves the order payable
+	if rec := pay(payment.MockDeclinedMethod); rec.Code != http.StatusPaymentRequired {
+		t.Fatalf("expected 402 got %d", rec.Code)
+	}
+	if got, _ := repo.GetByID(context.Background(), order.ID); got.PaymentStatus != models.PaymentFailed {
+		t.Fatalf("expected failed payment, got %s", got.PaymentStatus)
+	}
+
+	// A payment confirmed asynchronously stays pending until the webhook arrives
+	if rec := pay(payment.MockPendingMethod); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+	if rec := pay("pm_card_visa"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected a second payment to be rejected, got %d", rec.Code)
+	}
+	pending, _ := repo.GetByID(context.Background(), order.ID)
+	if pending.PaymentStatus != models.PaymentPending || pending.PaymentID == "" {
+		t.Fatalf("expected a pending payment, got %s", pending.PaymentStatus)
+	}
+
+	webhook := func(event models.PaymentEvent) {
+		payload, _ := json.Marshal(event)
+		rec := httptest.NewRecorder()
+		h.PaymentWebhook(rec, httptest.NewRequest(http.MethodPost, "/payments/webhook", bytes.NewBuffer(payload)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected webhook to be acknowledged, got %d", rec.Code)
+		}
+	}
+	webhook(models.PaymentEvent{PaymentID: "someone-else", OrderID: order.ID, Status: models.PaymentFailed})
+	webhook(models.PaymentEvent{PaymentID: pending.PaymentID, OrderID: order.ID, Status: models.PaymentPaid})
+	if got, _ := repo.GetByID(context.Background(), order.ID); got.PaymentStatus != models.PaymentPaid {
+		t.Fatalf("expected the webhook to mark the order paid, got %s", got.PaymentStatus)
+	}
+
+	// Cancelling a paid order refunds it
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPatch, "/orders/"+order.ID+"/status", bytes.NewBufferString(`{"status":"cancelled"}`)), map[string]string{"id": order.ID})
+	rec := httptest.NewRecorder()
+	h.UpdateOrderStatus(rec, req)
+	if got, _ := repo.GetByID(context.Background(), order.ID); rec.Code != http.StatusOK || got.PaymentStatus != models.PaymentRefunded {
+		t.Fatalf("expected the cancelled order to be refunded, got %d %s", rec.Code, got.PaymentStatus)
+	}
+}
+
+func TestUpdateOrderStatus_EnforcesStateMachine(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	h := NewOrderHandler(repo, &mockClient{}, nil, nil, nil, nil, nil, nil, nil)
+	o := models.NewOrder("u1", []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1)})
+	_ = repo.Create(context.Background(), o)
+
+	update := func(status string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPatch, "/orders/"+o.ID+"/status", bytes.NewBufferString(`{"status":"`+status+`"}`)), map[string]string{"id": o.ID})
+		rec := httptest.NewRecorder()
+		h.UpdateOrderStatus(rec, req)
+		return rec
+	}
+
+	if rec := update("shipped"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 shipping a pending order got %d", rec.Code)
+	}
+	for _, status := range []string{"confirmed", "shipped", "delivered"} {
+		if rec := update(status); rec.Code != http.StatusOK {
+			t.Fatalf("expected %s to be allowed, got %d %s", status, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := update("pending")
+	var response struct {
+		Error string                 `json:"error"`
+		Data  models.TransitionError `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	if rec.Code != http.StatusConflict || response.Data.From != models.OrderStatusDelivered || response.Data.To != models.OrderStatusPending {
+		t.Fatalf("expected delivered → pending to be rejected, got %d %s", rec.Code, rec.Body.String())
+	}
+	if response.Error != "order cannot move from delivered to pending; delivered is a final status" {
+		t.Errorf("unexpected error %q", response.Error)
+	}
+}
+
+func TestGetOrderHistory_RecordsStatusChanges(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	h := NewOrderHandler(repo, &mockClient{}, nil, nil, nil, nil, nil, nil, nil)
+	o := models.NewOrder("u1", []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1)})
+	_ = repo.Create(context.Background(), o)
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPatch, "/orders/"+o.ID+"/status", bytes.NewBufferString(`{"status":"confirmed"}`)), map[string]string{"id": o.ID})
+	h.UpdateOrderStatus(httptest.NewRecorder(), req)
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodPatch, "/orders/"+o.ID+"/status", bytes.NewBufferString(`{"status":"cancelled","note":" customer called "}`)), map[string]string{"id": o.ID})
+	req = req.WithContext(auth.WithService(req.Context(), "support-desk"))
+	h.UpdateOrderStatus(httptest.NewRecorder(), req)
+
+	rec := httptest.NewRecorder()
+	h.GetOrderHistory(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/orders/"+o.ID+"/history", nil), map[string]string{"id": o.ID}))
+	var response struct {
+		Data []models.StatusChange `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	if rec.Code != http.StatusOK || len(response.Data) != 3 {
+		t.Fatalf("expected creation and two changes, got %d %s", rec.Code, rec.Body.String())
+	}
+	created, confirmed, cancelled := response.Data[0], response.Data[1], response.Data[2]
+	if created.From != "" || created.To != models.OrderStatusPending || created.Actor != "u1" {
+		t.Errorf("unexpected creation entry %+v", created)
+	}
+	if confirmed.From != models.OrderStatusPending || confirmed.To != models.OrderStatusConfirmed || confirmed.Actor != "anonymous" {
+		t.Errorf("unexpected confirmation entry %+v", confirmed)
+	}
+	if cancelled.From != models.OrderStatusConfirmed || cancelled.Actor != "support-desk" || cancelled.Note != "customer called" {
+		t.Errorf("unexpected cancellation entry %+v", cancelled)
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetOrderHistory(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/orders/missing/history", nil), map[string]string{"id": "missing"}))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 got %d", rec.Code)
+	}
+}
+
+type failingTaxCalculator struct{}
+
+func (failingTaxCalculator) Calculate(order *models.Order) (float64, error) {
+	return 0, errors.New("tax service unavailable")
+}
+
+func TestCreateOrder_AppliesTax(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{items: []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 2), models.NewOrderItem("p2", "Other", 2.5, 1)}}
+	taxes, _ := tax.NewFlatRateCalculator(0.2)
+	h := NewOrderHandler(repo, mock, nil, nil, taxes, nil, nil, nil, nil)
+	body := `{"user_id":"u1","items":[{"product_id":"p1","quantity":2},{"product_id":"p2","quantity":1}]}`
+
+	rec := httptest.NewRecorder()
+	h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body)))
+	var response struct {
+		Data models.Order `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	if rec.Code != http.StatusCreated || response.Data.Subtotal != 22.5 || response.Data.Tax != 4.5 || response.Data.Total != 27 {
+		t.Fatalf("expected subtotal 22.5, tax 4.5 and total 27, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	h = NewOrderHandler(repo, mock, nil, nil, failingTaxCalculator{}, nil, nil, nil, nil)
+	rec = httptest.NewRecorder()
+	h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 when tax can't be calculated got %d", rec.Code)
+	}
+}
+
+func TestCreateOrder_AppliesCoupon(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	coupons := repository.NewInMemoryCouponRepository()
+	expired := time.Now().Add(-time.Hour)
+	_ = coupons.Create(&models.Coupon{Code: "SAVE10", Type: models.CouponPercentage, Value: 10, MaxUses: 1})
+	_ = coupons.Create(&models.Coupon{Code: "OLD", Type: models.CouponFixed, Value: 5, ExpiresAt: &expired})
+	mock := &mockClient{items: []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 2)}}
+	taxes, _ := tax.NewFlatRateCalculator(0.2)
+	h := NewOrderHandler(repo, mock, nil, nil, taxes, nil, coupons, nil, nil)
+
+	create := func(code string) (*httptest.ResponseRecorder, models.Order) {
+		rec := httptest.NewRecorder()
+		body := `{"user_id":"u1","items":[{"product_id":"p1","quantity":2}],"coupon_code":"` + code + `"}`
+		h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body)))
+		var response struct {
+			Data models.Order `json:"data"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+		return rec, response.Data
+	}
+
+	if rec, _ := create("NOPE"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown coupon got %d", rec.Code)
+	}
+	if rec, _ := create("old"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for an expired coupon got %d", rec.Code)
+	}
+
+	// A failed checkout gives the coupon's use back
+	mock.outOfStock = map[string]bool{"p1": true}
+	if rec, _ := create("save10"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for missing stock got %d", rec.Code)
+	}
+	mock.outOfStock = nil
+
+	rec, order := create("save10")
+	if rec.Code != http.StatusCreated || order.CouponCode != "SAVE10" || order.Discount != 2 || order.Tax != 3.6 || order.Total != 21.6 {
+		t.Fatalf("expected discount 2, tax 3.6 and total 21.6, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec, _ := create("SAVE10"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 once the coupon is used up got %d", rec.Code)
+	}
+}
+
+func TestCreateOrder_AddsShippingToTotal(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	item := models.NewOrderItem("p1", "Prod", 10, 2)
+	item.WeightKg = 1.5
+	mock := &mockClient{items: []models.OrderItem{item}, address: &models.Address{ID: "a1", Country: "DE"}}
+	taxes, _ := tax.NewFlatRateCalculator(0.1)
+	h := NewOrderHandler(repo, mock, nil, shipping.NewCalculator("US", shipping.DefaultRates), taxes, nil, nil, nil, nil)
+
+	create := func(body string) (*httptest.ResponseRecorder, models.Order) {
+		rec := httptest.NewRecorder()
+		h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body)))
+		var response struct {
+			Data models.Order `json:"data"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+		return rec, response.Data
+	}
+
+	rec, order := create(`{"user_id":"u1","items":[{"product_id":"p1","quantity":2}],"shipping_method":"express"}`)
+	// 2 × 1.5kg abroad by express: 35 + 8 × 3 = 59; tax is on the 20 subtotal only
+	if rec.Code != http.StatusCreated || order.ShippingMethod != models.ShippingExpress || order.ShippingCost != 59 || order.Tax != 2 || order.Total != 81 {
+		t.Fatalf("expected express shipping of 59 and total 81, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec, order := create(`{"user_id":"u1","items":[{"product_id":"p1","quantity":2}]}`); rec.Code != http.StatusCreated || order.ShippingMethod != models.ShippingStandard || order.ShippingCost != 27 {
+		t.Fatalf("expected standard shipping by default, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec, _ := create(`{"user_id":"u1","items":[{"product_id":"p1","quantity":2}],"shipping_method":"pigeon"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown shipping method got %d", rec.Code)
+	}
+}
+
+func TestUpdateOrderStatus_CancelNeedsSoldStockReturned(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{releaseErr: errors.New("product service unavailable")}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+
+	cancel := func(status models.OrderStatus) (*models.Order, int) {
+		item := models.NewOrderItem("p1", "Prod", 10, 1)
+		item.ReservationID = "r-p1"
+		o := models.NewOrder("u1", []models.OrderItem{item})
+		o.Status = status
+		_ = repo.Create(context.Background(), o)
+
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPatch, "/orders/"+o.ID+"/status", bytes.NewBufferString(`{"status":"cancelled"}`)), map[string]string{"id": o.ID})
+		rec := httptest.NewRecorder()
+		h.UpdateOrderStatus(rec, req)
+		stored, _ := repo.GetByID(context.Background(), o.ID)
+		return stored, rec.Code
+	}
+
+	// A held reservation expires on its own, so the cancellation goes ahead
+	if order, code := cancel(models.OrderStatusPending); code != http.StatusOK || order.Status != models.OrderStatusCancelled {
+		t.Fatalf("expected pending order to be cancelled, got %d %s", code, order.Status)
+	}
+	// Sold stock would never come back, so the order stays confirmed until it can be returned
+	order, code := cancel(models.OrderStatusConfirmed)
+	if code != http.StatusServiceUnavailable || order.Status != models.OrderStatusConfirmed {
+		t.Fatalf("expected 503 with the order still confirmed, got %d %s", code, order.Status)
+	}
+
+	// A reservation product service already closed counts as returned
+	mock.releaseErr = client.ErrConflict
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPatch, "/orders/"+order.ID+"/status", bytes.NewBufferString(`{"status":"cancelled"}`)), map[string]string{"id": order.ID})
+	rec := httptest.NewRecorder()
+	h.UpdateOrderStatus(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+}
+
+func TestDigitalItems_SkipReservationAndGetDownloadLinkOnConfirm(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	ebook := models.NewOrderItem("ebook", "E-book", 8, 1)
+	ebook.Digital = true
+	mock := &mockClient{items: []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1), ebook}}
+	downloads, _ := fulfillment.NewTokenIssuer("secret", "https://downloads.example.com", time.Hour)
+	h := NewOrderHandler(repo, mock, nil, nil, nil, downloads, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1},{"product_id":"ebook","quantity":1}]}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", rec.Code)
+	}
+	if len(mock.reserved) != 1 || mock.reserved[0] != "r-p1" {
+		t.Fatalf("expected only the physical item to be reserved, got %v", mock.reserved)
+	}
+	orders, _, _ := repo.List(context.Background(), nil)
+	o := orders[0]
+	if o.Items[1].Fulfillment != nil {
+		t.Fatalf("expected no download link before the order is confirmed")
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPatch, "/orders/"+o.ID+"/status", bytes.NewBufferString(`{"status":"confirmed"}`)), map[string]string{"id": o.ID})
+	rec = httptest.NewRecorder()
+	h.UpdateOrderStatus(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+	confirmed, _ := repo.GetByID(context.Background(), o.ID)
+	if confirmed.Items[0].Fulfillment != nil {
+		t.Errorf("expected no download link for the physical item")
+	}
+	link := confirmed.Items[1].Fulfillment
+	if link == nil {
+		t.Fatalf("expected a download link for the digital item")
+	}
+	if orderID, productID, err := downloads.Verify(link.Token, time.Now()); err != nil || orderID != o.ID || productID != "ebook" {
+		t.Errorf("expected the token to grant ebook on order %s, got %s %s (%v)", o.ID, orderID, productID, err)
+	}
+}
+
+func TestListOrders_FiltersAndPaginates(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	h := NewOrderHandler(repo, &mockClient{}, nil, nil, nil, nil, nil, nil, nil)
+	for i := 0; i < 3; i++ {
+		_ = repo.Create(context.Background(), models.NewOrder("u1", []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1)}))
+	}
+	_ = repo.Create(context.Background(), models.NewOrder("u2", []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1)}))
+
+	list := func(query string) (*httptest.ResponseRecorder, []models.Order, *models.PageInfo) {
+		rec := httptest.NewRecorder()
+		h.ListOrders(rec, httptest.NewRequest(http.MethodGet, "/orders"+query, nil))
+		var response struct {
+			Data       []models.Order   `json:"data"`
+			Pagination *models.PageInfo `json:"pagination"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+		return rec, response.Data, response.Pagination
+	}
+
+	rec, orders, info := list("?user_id=u1&status=pending&limit=2&page=2")
+	if rec.Code != http.StatusOK || len(orders) != 1 || info == nil || info.Total != 3 || info.Page != 2 {
+		t.Fatalf("expected the last of u1's 3 orders, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	for _, query := range []string{"?status=lost", "?from=yesterday", "?from=2024-05-02&to=2024-05-01", "?limit=0", "?page=-1"} {
+		if rec, _, _ := list(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestCreateOrder_ItemValidationErrorIdentifiesLine(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{itemsErr: &models.ItemValidationErrors{Errors: []*models.ItemValidationError{{
+		ItemIndex: 1,
+		ProductID: "p2",
+		Code:      models.ItemErrorBelowMinimum,
+		Message:   "quantity for product Paper must be at least 10, requested 2",
+		Requested: 2,
+		Limit:     10,
+	}}}}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+	body := bytes.NewBufferString(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1},{"product_id":"p2","quantity":2}]}`)
+	req := httptest.NewRequest(http.MethodPost, "/orders", body)
+	rec := httptest.NewRecorder()
+
+	h.CreateOrder(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 got %d", rec.Code)
+	}
+	var response struct {
+		Error string                      `json:"error"`
+		Data  models.ItemValidationErrors `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	if len(response.Data.Errors) != 1 {
+		t.Fatalf("expected one rejected line, got %s", rec.Body.String())
+	}
+	if line := response.Data.Errors[0]; line.ItemIndex != 1 || line.ProductID != "p2" || line.Code != models.ItemErrorBelowMinimum || line.Limit != 10 {
+		t.Fatalf("expected the second line to be identified, got %s", rec.Body.String())
+	}
+}
+
+func TestShipments_SplitShipmentDrivesOrderStatus(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	h := NewOrderHandler(repo, &mockClient{}, nil, nil, nil, nil, nil, nil, nil)
+	ebook := models.NewOrderItem("ebook", "Ebook", 5, 1)
+	ebook.Digital = true
+	order := models.NewOrder("u1", []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1), models.NewOrderItem("p2", "Other", 5, 2), ebook})
+	order.Status = models.OrderStatusConfirmed
+	_ = repo.Create(context.Background(), order)
+
+	ship := func(body string) (int, *models.Order) {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/orders/"+order.ID+"/shipments", bytes.NewBufferString(body)), map[string]string{"id": order.ID})
+		rec := httptest.NewRecorder()
+		h.CreateShipment(rec, req)
+		got, _ := repo.GetByID(context.Background(), order.ID)
+		return rec.Code, got
+	}
+	deliver := func(shipmentID string) int {
+		req := httptest.NewRequest(http.MethodPatch, "/orders/"+order.ID+"/shipments/"+shipmentID, bytes.NewBufferString(`{"status":"delivered"}`))
+		req = mux.SetURLVars(req, map[string]string{"id": order.ID, "shipment_id": shipmentID})
+		rec := httptest.NewRecorder()
+		h.UpdateShipment(rec, req)
+		return rec.Code
+	}
+
+	if code, _ := ship(`{"product_ids":["ebook"]}`); code != http.StatusBadRequest {
+		t.Fatalf("expected digital items to be rejected, got %d", code)
+	}
+
+	// Shipping part of the order leaves it confirmed
+	code, got := ship(`{"product_ids":["p1"],"carrier":"DHL","tracking_number":"123"}`)
+	if code != http.StatusCreated || got.Status != models.OrderStatusConfirmed || got.Items[0].Status != models.ItemStatusShipped || got.Items[1].Status != "" {
+		t.Fatalf("expected p1 alone to ship, got %d %s", code, got.Status)
+	}
+	first := got.Shipments[0].ID
+	if code := updateOrderStatus(h, order.ID, "cancelled"); code != http.StatusConflict {
+		t.Fatalf("expected a partly shipped order to stay uncancellable, got %d", code)
+	}
+
+	// Delivering the first parcel before the rest has shipped doesn't deliver the order
+	if code := deliver(first); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	if code := deliver(first); code != http.StatusConflict {
+		t.Fatalf("expected a second delivery to be rejected, got %d", code)
+	}
+
+	// The remaining items ship without listing them, completing the order's shipment
+	code, got = ship(`{}`)
+	if code != http.StatusCreated || got.Status != models.OrderStatusShipped || len(got.Shipments) != 2 {
+		t.Fatalf("expected the order to be shipped, got %d %s", code, got.Status)
+	}
+	if code := deliver(got.Shipments[1].ID); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	if got, _ := repo.GetByID(context.Background(), order.ID); got.Status != models.OrderStatusDelivered {
+		t.Fatalf("expected the order to be delivered, got %s", got.Status)
+	}
+	if code := deliver("missing"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 got %d", code)
+	}
+}
+
+// updateOrderStatus sends PATCH /orders/{id}/status and returns the response code
+func updateOrderStatus(h *OrderHandler, orderID, status string) int {
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPatch, "/orders/"+orderID+"/status", bytes.NewBufferString(`{"status":"`+status+`"}`)), map[string]string{"id": orderID})
+	rec := httptest.NewRecorder()
+	h.UpdateOrderStatus(rec, req)
+	return rec.Code
+}
+
+func TestGetOrder_ConditionalGet(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	h := NewOrderHandler(repo, &mockClient{}, nil, nil, nil, nil, nil, nil, nil)
+	order := models.NewOrder("u1", []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1)})
+	_ = repo.Create(context.Background(), order)
+
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/orders/"+order.ID, nil), map[string]string{"id": order.ID})
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		h.GetOrder(rec, req)
+		return rec
+	}
+
+	first := get("", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Header().Get("Last-Modified") == "" {
+		t.Fatalf("expected 200 with validators, got %d %q", first.Code, etag)
+	}
+	if rec := get("If-None-Match", etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("expected a bodyless 304 for a polling client, got %d", rec.Code)
+	}
+	if rec := get("If-Modified-Since", first.Header().Get("Last-Modified")); rec.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 when unmodified since Last-Modified, got %d", rec.Code)
+	}
+
+	order.ApplyPayment("pay-1", models.PaymentPaid)
+	_ = repo.Update(context.Background(), order)
+	if rec := get("If-None-Match", etag); rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("expected the paid order sent with a new ETag, got %d", rec.Code)
+	}
+}
+
+func TestGetOrderInvoice_RendersAndCaches(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+	order := models.NewOrder("u1", []models.OrderItem{models.NewOrderItem("p1", "Widget (large)", 10, 2)})
+	order.ApplyTax(2)
+	_ = repo.Create(context.Background(), order)
+
+	get := func(format string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/orders/"+order.ID+"/invoice?format="+format, nil), map[string]string{"id": order.ID})
+		rec := httptest.NewRecorder()
+		h.GetOrderInvoice(rec, req)
+		return rec
+	}
+
+	rec := get("")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/pdf" || !bytes.HasPrefix(rec.Body.Bytes(), []byte("%PDF-")) {
+		t.Fatalf("expected a PDF invoice, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !bytes.Contains(rec.Body.Bytes(), []byte(`Widget \(large\)`)) || !bytes.Contains(rec.Body.Bytes(), []byte("Test User")) {
+		t.Error("expected the PDF to list the item and the buyer")
+	}
+
+	rec = get("html")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !bytes.Contains([]byte(body), []byte("Tax (10%)")) || !bytes.Contains([]byte(body), []byte("22.00")) {
+		t.Fatalf("expected an HTML invoice with the tax breakdown, got %d %s", rec.Code, body)
+	}
+
+	// Repeat downloads are served from the cache until the order changes
+	get("html")
+	if mock.userLookups != 2 {
+		t.Fatalf("expected one buyer lookup per format, got %d", mock.userLookups)
+	}
+	order.UpdateStatus(models.OrderStatusConfirmed)
+	_ = repo.Update(context.Background(), order)
+	get("html")
+	if mock.userLookups != 3 {
+		t.Fatalf("expected the invoice to be regenerated after the order changed, got %d lookups", mock.userLookups)
+	}
+
+	if rec := get("docx"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown format, got %d", rec.Code)
+	}
+}
+
+func TestExpireStaleOrders_CancelsUnpaidPendingOrders(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+	now := time.Now()
+
+	newOrder := func(age time.Duration, status models.OrderStatus, payment models.PaymentStatus) *models.Order {
+		item := models.NewOrderItem("p1", "Prod", 10, 1)
+		item.ReservationID = "r-p1"
+		o := models.NewOrder("u1", []models.OrderItem{item})
+		o.CreatedAt, o.PendingSince = now.Add(-age), now.Add(-age)
+		o.Status = status
+		o.PaymentStatus = payment
+		_ = repo.Create(context.Background(), o)
+		return o
+	}
+	stale := newOrder(2*time.Hour, models.OrderStatusPending, models.PaymentUnpaid)
+	fresh := newOrder(time.Minute, models.OrderStatusPending, models.PaymentUnpaid)
+	paid := newOrder(2*time.Hour, models.OrderStatusPending, models.PaymentPaid)
+	confirmed := newOrder(2*time.Hour, models.OrderStatusConfirmed, models.PaymentUnpaid)
+
+	expired, failed, err := h.ExpireStaleOrders(context.Background(), now, time.Hour)
+	if err != nil || expired != 1 || failed != 0 {
+		t.Fatalf("expected one expired order, got %d expired %d failed (%v)", expired, failed, err)
+	}
+
+	got, _ := repo.GetByID(context.Background(), stale.ID)
+	last := got.StatusHistory[len(got.StatusHistory)-1]
+	if got.Status != models.OrderStatusCancelled || last.Actor != expiryActor || got.Items[0].ReservationID != "" {
+		t.Fatalf("expected the stale order to be cancelled with its stock released, got %s by %s", got.Status, last.Actor)
+	}
+	if len(mock.released) != 1 {
+		t.Errorf("expected one reservation released, got %v", mock.released)
+	}
+	for _, o := range []*models.Order{fresh, paid, confirmed} {
+		if got, _ := repo.GetByID(context.Background(), o.ID); got.Status != o.Status {
+			t.Errorf("expected order %s to keep status %s, got %s", o.ID, o.Status, got.Status)
+		}
+	}
+}
+
+// payingRepository marks every order it lists paid just after listing it, as a buyer paying while
+// the expiry job runs would
+type payingRepository struct {
+	*repository.InMemoryOrderRepository
+}
+
+func (r payingRepository) List(ctx context.Context, filter *models.OrderFilter) ([]*models.Order, *models.PageInfo, error) {
+	orders, info, err := r.InMemoryOrderRepository.List(ctx, filter)
+	for _, order := range orders {
+		paid := *order
+		paid.PaymentStatus = models.PaymentPaid
+		_ = r.InMemoryOrderRepository.Update(ctx, &paid)
+	}
+	return orders, info, err
+}
+
+func TestExpireStaleOrders_SkipsOrdersPaidAfterListing(t *testing.T) {
+	repo := payingRepository{repository.NewInMemoryOrderRepository()}
+	mock := &mockClient{}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+	now := time.Now()
+
+	item := models.NewOrderItem("p1", "Prod", 10, 1)
+	item.ReservationID = "r-p1"
+	order := models.NewOrder("u1", []models.OrderItem{item})
+	order.CreatedAt, order.PendingSince = now.Add(-2 * time.Hour), now.Add(-2 * time.Hour)
+	_ = repo.Create(context.Background(), order)
+
+	expired, failed, err := h.ExpireStaleOrders(context.Background(), now, time.Hour)
+	if err != nil || expired != 0 || failed != 0 {
+		t.Fatalf("expected the paid order skipped, got %d expired %d failed (%v)", expired, failed, err)
+	}
+	got, _ := repo.GetByID(context.Background(), order.ID)
+	if got.Status != models.OrderStatusPending || got.PaymentStatus != models.PaymentPaid || got.Items[0].ReservationID != "r-p1" {
+		t.Fatalf("expected the order left pending and paid with its stock held, got %s %s", got.Status, got.PaymentStatus)
+	}
+	if len(mock.released) != 0 {
+		t.Errorf("expected no stock released, got %v", mock.released)
+	}
+}
+
+func TestPayOrder_RefundsChargeForOrderThatExpiredMeanwhile(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{}
+	payments := &mockPayments{}
+	h := NewOrderHandler(repo, mock, payments, nil, nil, nil, nil, nil, nil)
+	now := time.Now()
+
+	item := models.NewOrderItem("p1", "Prod", 10, 1)
+	item.ReservationID = "r-p1"
+	order := models.NewOrder("u1", []models.OrderItem{item})
+	order.CreatedAt, order.PendingSince = now.Add(-2*time.Hour), now.Add(-2*time.Hour)
+	_ = repo.Create(context.Background(), order)
+
+	// The order expires while the charge is being taken
+	payments.onCharge = func() {
+		if expired, _, _ := h.ExpireStaleOrders(context.Background(), now, time.Hour); expired != 1 {
+			t.Fatalf("expected the order to expire, got %d", expired)
+		}
+	}
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/orders/"+order.ID+"/pay", bytes.NewBufferString(`{"payment_method":"pm_card_visa"}`)), map[string]string{"id": order.ID})
+	rec := httptest.NewRecorder()
+	h.PayOrder(rec, req)
+
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), CodeOrderChanged) {
+		t.Fatalf("expected 409 %s, got %d %s", CodeOrderChanged, rec.Code, rec.Body.String())
+	}
+	if len(payments.refunded) != 1 || payments.refunded[0] != "pay-"+order.ID {
+		t.Fatalf("expected the charge refunded, got %v", payments.refunded)
+	}
+	got, _ := repo.GetByID(context.Background(), order.ID)
+	if got.Status != models.OrderStatusCancelled || got.PaymentStatus == models.PaymentPaid {
+		t.Errorf("expected the order left cancelled and unpaid, got %s %s", got.Status, got.PaymentStatus)
+	}
+}
+
+func TestOrderHandler_RecordsLifecycleEventsInOutbox(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{items: []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1)}}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1}]}`)))
+	events, _ := repo.PendingEvents(0)
+	if rec.Code != http.StatusCreated || len(events) != 1 || events[0].Type != models.EventOrderCreated {
+		t.Fatalf("expected an order.created event in the outbox, got %d %+v", rec.Code, events)
+	}
+
+	orderID := events[0].OrderID
+	if code := updateOrderStatus(h, orderID, "confirmed"); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	if code := updateOrderStatus(h, orderID, "pending"); code != http.StatusConflict {
+		t.Fatalf("expected 409 got %d", code)
+	}
+	events, _ = repo.PendingEvents(0)
+	if len(events) != 2 {
+		t.Fatalf("expected only the accepted change to be recorded, got %d events", len(events))
+	}
+	changed := events[1]
+	if changed.Type != models.EventOrderStatusChanged || changed.Data.PreviousStatus != models.OrderStatusPending || changed.Data.Order.Status != models.OrderStatusConfirmed {
+		t.Fatalf("unexpected status change event %+v", changed)
+	}
+	if stored, _ := repo.GetByID(context.Background(), orderID); len(stored.Events) != 0 {
+		t.Error("expected saved orders to keep no recorded events")
+	}
+}
+
+func TestUpdateOrderStatus_CancelLeavesStockToEventWhenReleasingByEvent(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{releaseErr: errors.New("product service unavailable")}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+	h.ReleaseStockByEvent()
+
+	item := models.NewOrderItem("p1", "Prod", 10, 1)
+	item.ReservationID = "r-p1"
+	o := models.NewOrder("u1", []models.OrderItem{item})
+	o.Status = models.OrderStatusConfirmed
+	_ = repo.Create(context.Background(), o)
+
+	// Product service isn't called, so its being down doesn't hold the cancellation up
+	if code := updateOrderStatus(h, o.ID, "cancelled"); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	events, _ := repo.PendingEvents(0)
+	if len(events) != 2 || events[0].Type != models.EventOrderStatusChanged || events[1].Type != models.EventOrderCancelled {
+		t.Fatalf("expected status change and cancellation events, got %+v", events)
+	}
+	cancelled := events[1]
+	if cancelled.Data.PreviousStatus != models.OrderStatusConfirmed || len(cancelled.Data.Release) != 1 || cancelled.Data.Release[0] != (models.HeldStock{ProductID: "p1", ReservationID: "r-p1"}) {
+		t.Fatalf("expected the reservation to be named for release, got %+v", cancelled.Data)
+	}
+	if stored, _ := repo.GetByID(context.Background(), o.ID); stored.Items[0].ReservationID != "" {
+		t.Error("expected the order to give up its reservation")
+	}
+}
+
+func TestOrderHandler_NotesAndMetadata(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{items: []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1)}}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body)))
+		return rec
+	}
+	tooLong := strings.Repeat("x", models.MaxMetadataValueLength+1)
+	if rec := create(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1}],"metadata":{"ref":"` + tooLong + `"}}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for oversized metadata got %d", rec.Code)
+	}
+	rec := create(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1}],"metadata":{"storefront_ref":"cart-42"}}`)
+	var created struct {
+		Data models.Order `json:"data"`
+	}
+	json.NewDecoder(rec.Body).Decode(&created)
+	if rec.Code != http.StatusCreated || created.Data.Metadata["storefront_ref"] != "cart-42" {
+		t.Fatalf("expected the metadata on the created order, got %d %+v", rec.Code, created.Data.Metadata)
+	}
+	orderID := created.Data.ID
+
+	addNote := func(body string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/orders/"+orderID+"/notes", bytes.NewBufferString(body)), map[string]string{"id": orderID})
+		req = req.WithContext(auth.WithService(req.Context(), "admin-console"))
+		rec := httptest.NewRecorder()
+		h.AddOrderNote(rec, req)
+		return rec
+	}
+	if rec := addNote(`{"body":"  "}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an empty note got %d", rec.Code)
+	}
+	if rec := addNote(`{"body":"Customer asked for gift wrap"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", rec.Code)
+	}
+	if rec := addNote(`{"body":"Wrapped","author":"jo"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetOrderNotes(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/orders/"+orderID+"/notes", nil), map[string]string{"id": orderID}))
+	var listed struct {
+		Data []models.OrderNote `json:"data"`
+	}
+	json.NewDecoder(rec.Body).Decode(&listed)
+	if len(listed.Data) != 2 || listed.Data[0].Author != "admin-console" || listed.Data[1].Author != "jo" {
+		t.Fatalf("expected both notes oldest first, got %+v", listed.Data)
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetOrder(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/orders/"+orderID, nil), map[string]string{"id": orderID}))
+	if strings.Contains(rec.Body.String(), "gift wrap") {
+		t.Error("expected notes to stay out of the order response")
+	}
+}
+
+func TestCreateOrder_GuestCheckoutAndClaim(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	// Guests have no account, so a failing user lookup must not matter
+	mock := &mockClient{userErr: errors.New("no such user"), items: []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1)}}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body)))
+		return rec
+	}
+	address := `"shipping_address":{"recipient_name":"Jo","line1":"1 Main St","city":"Springfield","postal_code":"12345","country":"US"}`
+	if rec := create(`{"email":"not-an-email","items":[{"product_id":"p1","quantity":1}],` + address + `}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad email got %d", rec.Code)
+	}
+	if rec := create(`{"email":"jo@example.com","items":[{"product_id":"p1","quantity":1}]}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without an address got %d", rec.Code)
+	}
+
+	rec := create(`{"email":"jo@example.com","items":[{"product_id":"p1","quantity":1}],` + address + `}`)
+	var created struct {
+		Data struct {
+			models.Order
+			ClaimToken string `json:"claim_token"`
+		} `json:"data"`
+	}
+	json.NewDecoder(rec.Body).Decode(&created)
+	if rec.Code != http.StatusCreated || created.Data.UserID != "" || created.Data.GuestEmail != "jo@example.com" || created.Data.ClaimToken == "" {
+		t.Fatalf("expected a guest order with a claim token, got %d %+v", rec.Code, created.Data)
+	}
+	if created.Data.StatusHistory[0].Actor != models.GuestActor || created.Data.ShippingAddress.City != "Springfield" {
+		t.Fatalf("unexpected guest order %+v", created.Data.Order)
+	}
+	orderID := created.Data.ID
+
+	claim := func(token string) int {
+		body := `{"user_id":"u9","claim_token":"` + token + `"}`
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/orders/"+orderID+"/claim", bytes.NewBufferString(body)), map[string]string{"id": orderID})
+		rec := httptest.NewRecorder()
+		h.ClaimOrder(rec, req)
+		return rec.Code
+	}
+	if code := claim("wrong"); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a wrong token got %d", code)
+	}
+	if code := claim(created.Data.ClaimToken); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown account got %d", code)
+	}
+	mock.userErr = nil
+	if code := claim(created.Data.ClaimToken); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	claimed, _ := repo.GetByID(context.Background(), orderID)
+	if claimed.UserID != "u9" || claimed.GuestEmail != "" || claimed.ClaimToken != "" {
+		t.Fatalf("expected the order to move to the account, got %+v", claimed)
+	}
+	if code := claim(created.Data.ClaimToken); code != http.StatusConflict {
+		t.Fatalf("expected 409 for an order already claimed got %d", code)
+	}
+}
+
+func TestExportOrders_StreamsMatchingOrders(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	h := NewOrderHandler(repo, &mockClient{}, nil, nil, nil, nil, nil, nil, nil)
+	confirmed := models.NewOrder("u1", []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1), models.NewOrderItem("p2", "Other", 5, 2)})
+	confirmed.ChangeStatus(models.OrderStatusConfirmed, "test", "")
+	_ = repo.Create(context.Background(), confirmed)
+	_ = repo.Create(context.Background(), models.NewOrder("u2", []models.OrderItem{models.NewOrderItem("p3", "Third", 1, 1)}))
+
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ExportOrders(rec, httptest.NewRequest(http.MethodGet, "/orders/export"+query, nil))
+		return rec
+	}
+	if rec := get("?format=xml"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown format got %d", rec.Code)
+	}
+
+	rec := get("?status=confirmed")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("expected a CSV export, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[1], confirmed.ID) {
+		t.Fatalf("expected a header and the confirmed order's two items, got %q", rec.Body.String())
+	}
+
+	rec = get("?format=json")
+	var lines []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &lines); err != nil || len(lines) != 3 {
+		t.Fatalf("expected every order's items as JSON, got %v %s", err, rec.Body.String())
+	}
+}
+
+func TestAmendOrderItems_RepricesAndSwapsReservations(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	coupons := repository.NewInMemoryCouponRepository()
+	_ = coupons.Create(&models.Coupon{Code: "SAVE10", Type: models.CouponPercentage, Value: 10})
+	mock := &mockClient{items: []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 2)}}
+	taxes, _ := tax.NewFlatRateCalculator(0.2)
+	h := NewOrderHandler(repo, mock, nil, nil, taxes, nil, coupons, nil, nil)
+
+	rec := httptest.NewRecorder()
+	h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(`{"user_id":"u1","items":[{"product_id":"p1","quantity":2}],"coupon_code":"SAVE10"}`)))
+	var created struct {
+		Data models.Order `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	orderID := created.Data.ID
+
+	amend := func(body string) (*httptest.ResponseRecorder, models.Order) {
+		req := httptest.NewRequest(http.MethodPatch, "/orders/"+orderID+"/items", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"id": orderID})
+		rec := httptest.NewRecorder()
+		h.AmendOrderItems(rec, req)
+		var response struct {
+			Data models.Order `json:"data"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+		return rec, response.Data
+	}
+
+	// p1's price has gone up since the order was placed; p2 is added
+	mock.items = []models.OrderItem{models.NewOrderItem("p1", "Prod", 12, 1), models.NewOrderItem("p2", "Other", 5, 3)}
+	rec, order := amend(`{"items":[{"product_id":"p1","quantity":1},{"product_id":"p2","quantity":3}]}`)
+	if rec.Code != http.StatusOK || len(order.Items) != 2 || order.Subtotal != 27 || order.Discount != 2.7 || order.Tax != 4.86 || order.Total != 29.16 {
+		t.Fatalf("expected subtotal 27, discount 2.7, tax 4.86 and total 29.16, got %d %s", rec.Code, rec.Body.String())
+	}
+	if len(mock.reserved) != 3 || len(mock.released) != 1 || mock.released[0] != "r-p1" {
+		t.Fatalf("expected new reservations for both items and the old one released, got %v %v", mock.reserved, mock.released)
+	}
+
+	if rec, _ := amend(`{"items":[{"product_id":"p9","quantity":0}]}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for removing a product not on the order got %d", rec.Code)
+	}
+	if rec, _ := amend(`{"items":[{"product_id":"p1","quantity":0},{"product_id":"p2","quantity":0}]}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for removing every item got %d", rec.Code)
+	}
+
+	// Without stock for the new item the order is left as it was
+	mock.outOfStock = map[string]bool{"p3": true}
+	mock.items = []models.OrderItem{models.NewOrderItem("p1", "Prod", 12, 1), models.NewOrderItem("p2", "Other", 5, 3), models.NewOrderItem("p3", "Third", 1, 1)}
+	if rec, _ := amend(`{"items":[{"product_id":"p3","quantity":1}]}`); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for missing stock got %d", rec.Code)
+	}
+	if stored, _ := repo.GetByID(context.Background(), orderID); len(stored.Items) != 2 || stored.Total != 29.16 {
+		t.Fatalf("expected the order to be unchanged, got %+v", stored)
+	}
+	mock.outOfStock = nil
+
+	stored, _ := repo.GetByID(context.Background(), orderID)
+	stored.ChangeStatus(models.OrderStatusConfirmed, "test", "")
+	_ = repo.Update(context.Background(), stored)
+	if rec, _ := amend(`{"items":[{"product_id":"p1","quantity":2}]}`); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a confirmed order got %d", rec.Code)
+	}
+}
+
+func TestAmendOrderItems_ReusesStockTheOrderHolds(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{
+		items: []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 2)},
+		stock: map[string]int{"p1": 2, "p2": 1},
+	}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(`{"user_id":"u1","items":[{"product_id":"p1","quantity":2}]}`)))
+	var created struct {
+		Data models.Order `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	orderID := created.Data.ID
+	if rec.Code != http.StatusCreated || mock.stock["p1"] != 0 {
+		t.Fatalf("expected the order to hold all of p1's stock, got %d %v", rec.Code, mock.stock)
+	}
+
+	amend := func(body string) int {
+		req := httptest.NewRequest(http.MethodPatch, "/orders/"+orderID+"/items", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"id": orderID})
+		rec := httptest.NewRecorder()
+		h.AmendOrderItems(rec, req)
+		return rec.Code
+	}
+
+	// The units the order holds are enough for one fewer, though none are left in stock
+	mock.items = []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1), models.NewOrderItem("p2", "Other", 5, 1)}
+	if code := amend(`{"items":[{"product_id":"p1","quantity":1},{"product_id":"p2","quantity":1}]}`); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	if mock.stock["p1"] != 1 || mock.stock["p2"] != 0 {
+		t.Fatalf("expected one unit of p1 given back and p2 reserved, got %v", mock.stock)
+	}
+
+	// More than there is: the order keeps what it held, under reservations it still names
+	mock.items = []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 3), models.NewOrderItem("p2", "Other", 5, 1)}
+	if code := amend(`{"items":[{"product_id":"p1","quantity":3}]}`); code != http.StatusConflict {
+		t.Fatalf("expected 409 for missing stock got %d", code)
+	}
+	stored, _ := repo.GetByID(context.Background(), orderID)
+	if mock.stock["p1"] != 1 || mock.stock["p2"] != 0 || stored.Items[0].Quantity != 1 || mock.held[stored.Items[0].ReservationID] != 1 || mock.held[stored.Items[1].ReservationID] != 1 {
+		t.Fatalf("expected the old quantities held again, got stock %v held %v items %+v", mock.stock, mock.held, stored.Items)
+	}
+}
+
+func TestBackorders_AcceptedAndAllocatedWhenStockArrives(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	backordered := models.NewOrderItem("p2", "Console", 400, 2)
+	backordered.Status = models.ItemStatusBackordered
+	mock := &mockClient{items: []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1), backordered}}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1},{"product_id":"p2","quantity":2}]}`)))
+	var created struct {
+		Data models.Order `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	if rec.Code != http.StatusCreated || len(mock.reserved) != 1 || created.Data.Items[1].ReservationID != "" {
+		t.Fatalf("expected only the in-stock item to be reserved, got %d %v", rec.Code, mock.reserved)
+	}
+
+	update := func(status string) int {
+		req := httptest.NewRequest(http.MethodPatch, "/orders/"+created.Data.ID+"/status", bytes.NewBufferString(`{"status":"`+status+`"}`))
+		req = mux.SetURLVars(req, map[string]string{"id": created.Data.ID})
+		rec := httptest.NewRecorder()
+		h.UpdateOrderStatus(rec, req)
+		return rec.Code
+	}
+	if code := update("confirmed"); code != http.StatusOK || len(mock.committed) != 1 {
+		t.Fatalf("expected the order to confirm with one reservation committed, got %d %v", code, mock.committed)
+	}
+	if code := update("shipped"); code != http.StatusConflict {
+		t.Fatalf("expected 409 for shipping a backordered item got %d", code)
+	}
+
+	// Still no stock: nothing changes
+	mock.outOfStock = map[string]bool{"p2": true}
+	if allocated, failed, err := h.AllocateBackorders(context.Background(), time.Now()); err != nil || allocated != 0 || failed != 0 {
+		t.Fatalf("expected nothing allocated, got %d %d %v", allocated, failed, err)
+	}
+
+	mock.outOfStock = nil
+	if allocated, failed, err := h.AllocateBackorders(context.Background(), time.Now()); err != nil || allocated != 1 || failed != 0 {
+		t.Fatalf("expected one item allocated, got %d %d %v", allocated, failed, err)
+	}
+	order, _ := repo.GetByID(context.Background(), created.Data.ID)
+	if order.HasBackorderedItems() || order.Items[1].ReservationID != "r-p2" || len(mock.committed) != 2 {
+		t.Fatalf("expected the item reserved and committed for the confirmed order, got %+v %v", order.Items[1], mock.committed)
+	}
+	if code := update("shipped"); code != http.StatusOK {
+		t.Fatalf("expected the order to ship once allocated, got %d", code)
+	}
+}
+
+// unsavableOrderRepo fails every write, as if the order store went down after orders were listed
+type unsavableOrderRepo struct {
+	*repository.InMemoryOrderRepository
+}
+
+func (r unsavableOrderRepo) Update(ctx context.Context, order *models.Order) error { return errors.New("store unavailable") }
+func (r unsavableOrderRepo) Modify(ctx context.Context, id string, change func(order *models.Order) error) (*models.Order, error) {
+	return nil, errors.New("store unavailable")
+}
+
+// cancellingRepository cancels every order it lists just after listing it, as a customer cancelling
+// while the allocation job runs would
+type cancellingRepository struct {
+	*repository.InMemoryOrderRepository
+}
+
+func (r cancellingRepository) List(ctx context.Context, filter *models.OrderFilter) ([]*models.Order, *models.PageInfo, error) {
+	orders, info, err := r.InMemoryOrderRepository.List(ctx, filter)
+	for _, order := range orders {
+		cancelled := *order
+		cancelled.ChangeStatus(models.OrderStatusCancelled, "test", "")
+		_ = r.InMemoryOrderRepository.Update(ctx, &cancelled)
+	}
+	return orders, info, err
+}
+
+func TestAllocateBackorders_ReleasesStockItCannotKeep(t *testing.T) {
+	newOrder := func(repo repository.OrderRepository, status models.OrderStatus) *models.Order {
+		backordered := models.NewOrderItem("p2", "Console", 400, 2)
+		backordered.Status = models.ItemStatusBackordered
+		order := models.NewOrder("u1", []models.OrderItem{backordered})
+		if status != models.OrderStatusPending {
+			order.ChangeStatus(status, "test", "")
+		}
+		_ = repo.Create(context.Background(), order)
+		return order
+	}
+
+	// The order can't be saved: the reservation is released and the item stays on backorder
+	repo := unsavableOrderRepo{repository.NewInMemoryOrderRepository()}
+	mock := &mockClient{}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, nil)
+	order := newOrder(repo, models.OrderStatusConfirmed)
+	if allocated, failed, err := h.AllocateBackorders(context.Background(), time.Now()); err != nil || allocated != 0 || failed != 1 {
+		t.Fatalf("expected the item to fail, got %d %d %v", allocated, failed, err)
+	}
+	if len(mock.released) != 1 || len(mock.committed) != 0 {
+		t.Fatalf("expected the reservation released uncommitted, got %v %v", mock.released, mock.committed)
+	}
+	if stored, _ := repo.GetByID(context.Background(), order.ID); !stored.HasBackorderedItems() {
+		t.Fatalf("expected the item still backordered, got %+v", stored.Items)
+	}
+
+	// The stock can't be committed: it is released and the saved item goes back on backorder
+	inMemory := repository.NewInMemoryOrderRepository()
+	mock = &mockClient{commitErr: errors.New("product service unavailable")}
+	h = NewOrderHandler(inMemory, mock, nil, nil, nil, nil, nil, nil, nil)
+	order = newOrder(inMemory, models.OrderStatusConfirmed)
+	if allocated, failed, err := h.AllocateBackorders(context.Background(), time.Now()); err != nil || allocated != 0 || failed != 1 {
+		t.Fatalf("expected the item to fail, got %d %d %v", allocated, failed, err)
+	}
+	stored, _ := inMemory.GetByID(context.Background(), order.ID)
+	if len(mock.released) != 1 || !stored.HasBackorderedItems() || stored.Items[0].ReservationID != "" {
+		t.Fatalf("expected the reservation released and the item backordered again, got %v %+v", mock.released, stored.Items)
+	}
+
+	// The order is cancelled after it is listed: the reservation is released and the order stays cancelled
+	cancelling := cancellingRepository{repository.NewInMemoryOrderRepository()}
+	mock = &mockClient{}
+	h = NewOrderHandler(cancelling, mock, nil, nil, nil, nil, nil, nil, nil)
+	order = newOrder(cancelling, models.OrderStatusPending)
+	if allocated, failed, err := h.AllocateBackorders(context.Background(), time.Now()); err != nil || allocated != 0 || failed != 0 {
+		t.Fatalf("expected nothing allocated to the cancelled order, got %d %d %v", allocated, failed, err)
+	}
+	stored, _ = cancelling.GetByID(context.Background(), order.ID)
+	if len(mock.released) != 1 || stored.Status != models.OrderStatusCancelled || stored.Items[0].ReservationID != "" {
+		t.Errorf("expected the reservation released and the order left cancelled, got %v %s %+v", mock.released, stored.Status, stored.Items)
+	}
+}
+
+func TestLoyaltyPoints_EarnedOnConfirmAndRedeemedAtCheckout(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	ledger := repository.NewInMemoryLoyaltyRepository()
+	program, _ := loyalty.NewProgram(ledger, 1, 0.1)
+	mock := &mockClient{items: []models.OrderItem{models.NewOrderItem("p1", "Prod", 50, 1)}}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, program, nil)
+
+	create := func(points string) (*httptest.ResponseRecorder, models.Order) {
+		rec := httptest.NewRecorder()
+		body := `{"user_id":"u1","items":[{"product_id":"p1","quantity":1}],"redeem_points":` + points + `}`
+		h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body)))
+		var response struct {
+			Data models.Order `json:"data"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+		return rec, response.Data
+	}
+	update := func(id, status string) int {
+		req := httptest.NewRequest(http.MethodPatch, "/orders/"+id+"/status", bytes.NewBufferString(`{"status":"`+status+`"}`))
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		rec := httptest.NewRecorder()
+		h.UpdateOrderStatus(rec, req)
+		return rec.Code
+	}
+
+	// Confirming an order earns a point per unit spent
+	_, first := create("0")
+	if code := update(first.ID, "confirmed"); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	if balance, _ := ledger.Balance("u1"); balance != 50 {
+		t.Fatalf("expected 50 points earned, got %d", balance)
+	}
+
+	if rec, _ := create("-1"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for negative points got %d", rec.Code)
+	}
+	if rec, _ := create("600"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for points worth more than the total got %d", rec.Code)
+	}
+	if rec, _ := create("60"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for more points than the balance got %d", rec.Code)
+	}
+
+	rec, second := create("40")
+	if rec.Code != http.StatusCreated || second.PointsCredit != 4 || second.Total != 46 {
+		t.Fatalf("expected 4.00 off a total of 46, got %d %s", rec.Code, rec.Body.String())
+	}
+	if balance, _ := ledger.Balance("u1"); balance != 10 {
+		t.Fatalf("expected 10 points left, got %d", balance)
+	}
+
+	// Cancelling gives redeemed points back and takes earned ones away
+	if code := update(second.ID, "cancelled"); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	if code := update(first.ID, "cancelled"); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	entries, _ := ledger.Entries("u1")
+	if balance, _ := ledger.Balance("u1"); balance != 0 || len(entries) != 4 || entries[0].Reason != models.PointsReversed {
+		t.Fatalf("expected the balance back to 0 after a refund and a reversal, got %d %+v", balance, entries)
+	}
+}
+
+func TestLoyaltyPoints_ReturnedOnceWhenCancelRacesExpiry(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	ledger := repository.NewInMemoryLoyaltyRepository()
+	_ = ledger.Add(models.NewLoyaltyEntry("u1", "", 100, models.PointsEarned, time.Now()))
+	program, _ := loyalty.NewProgram(ledger, 1, 0.1)
+	item := models.NewOrderItem("p1", "Prod", 50, 1)
+	mock := &mockClient{items: []models.OrderItem{item}}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, program, nil)
+
+	rec := httptest.NewRecorder()
+	h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1}],"redeem_points":40}`)))
+	var created struct {
+		Data models.Order `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	if balance, _ := ledger.Balance("u1"); rec.Code != http.StatusCreated || balance != 60 {
+		t.Fatalf("expected 40 points redeemed, got %d with a balance of %d", rec.Code, balance)
+	}
+	now := time.Now()
+	_, _ = repo.Modify(context.Background(), created.Data.ID, func(order *models.Order) error {
+		order.CreatedAt, order.PendingSince = now.Add(-2*time.Hour), now.Add(-2*time.Hour)
+		return nil
+	})
+
+	// The order expires while the cancellation is releasing its stock
+	mock.onRelease = func() {
+		if expired, _, _ := h.ExpireStaleOrders(context.Background(), now, time.Hour); expired != 1 {
+			t.Fatalf("expected the order to expire, got %d", expired)
+		}
+	}
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPatch, "/orders/"+created.Data.ID+"/status", bytes.NewBufferString(`{"status":"cancelled"}`)), map[string]string{"id": created.Data.ID})
+	rec = httptest.NewRecorder()
+	h.UpdateOrderStatus(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for the cancellation that lost the race, got %d %s", rec.Code, rec.Body.String())
+	}
+	if balance, _ := ledger.Balance("u1"); balance != 100 {
+		t.Errorf("expected the redeemed points given back once, got a balance of %d", balance)
+	}
+}
+
+// flaggingFraudChecker holds every order over limit
+type flaggingFraudChecker struct {
+	limit float64
+}
+
+func (c flaggingFraudChecker) Check(ctx context.Context, order *models.Order) ([]string, error) {
+	if order.Total > c.limit {
+		return []string{"total is too high"}, nil
+	}
+	return nil, nil
+}
+
+func TestFraudReview_HoldsSuspiciousOrdersUntilDecided(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{items: []models.OrderItem{models.NewOrderItem("p1", "Prod", 100, 1)}}
+	payments := &mockPayments{}
+	h := NewOrderHandler(repo, mock, payments, nil, nil, nil, nil, nil, flaggingFraudChecker{limit: 50})
+
+	create := func() models.Order {
+		rec := httptest.NewRecorder()
+		h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1}],"payment_method":"pm_card"}`)))
+		var response struct {
+			Data models.Order `json:"data"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+		if rec.Code != http.StatusCreated || response.Data.Status != models.OrderStatusReview || len(response.Data.ReviewReasons) != 1 {
+			t.Fatalf("expected the order held for review, got %d %s", rec.Code, rec.Body.String())
+		}
+		return response.Data
+	}
+	decide := func(id, decision string) int {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/orders/"+id+"/review/"+decision, nil), map[string]string{"id": id})
+		rec := httptest.NewRecorder()
+		if decision == "approve" {
+			h.ApproveOrder(rec, req)
+		} else {
+			h.RejectOrder(rec, req)
+		}
+		return rec.Code
+	}
+
+	approved := create()
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPatch, "/orders/"+approved.ID+"/status", bytes.NewBufferString(`{"status":"confirmed"}`)), map[string]string{"id": approved.ID})
+	rec := httptest.NewRecorder()
+	h.UpdateOrderStatus(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for confirming an order in review got %d", rec.Code)
+	}
+	if code := decide(approved.ID, "approve"); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	if order, _ := repo.GetByID(context.Background(), approved.ID); order.Status != models.OrderStatusPending || order.PaymentStatus != models.PaymentPaid {
+		t.Fatalf("expected a paid pending order, got %s %s", order.Status, order.PaymentStatus)
+	}
+	if len(mock.released) != 1 || len(mock.reserved) != 2 {
+		t.Fatalf("expected the order's stock reserved again on approval, got %v %v", mock.released, mock.reserved)
+	}
+	if code := decide(approved.ID, "reject"); code != http.StatusConflict {
+		t.Fatalf("expected 409 once the order has left review got %d", code)
+	}
+
+	rejected := create()
+	if code := decide(rejected.ID, "reject"); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	order, _ := repo.GetByID(context.Background(), rejected.ID)
+	if order.Status != models.OrderStatusCancelled || len(payments.refunded) != 1 || len(mock.released) != 2 {
+		t.Fatalf("expected the order cancelled, refunded, and its stock released, got %s %v %v", order.Status, payments.refunded, mock.released)
+	}
+}
+
+func TestFraudReview_ApprovalReservesStockAgainAndRestartsExpiry(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	mock := &mockClient{items: []models.OrderItem{models.NewOrderItem("p1", "Prod", 100, 1)}, stock: map[string]int{"p1": 1}}
+	h := NewOrderHandler(repo, mock, nil, nil, nil, nil, nil, nil, flaggingFraudChecker{limit: 50})
+
+	rec := httptest.NewRecorder()
+	h.CreateOrder(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1}]}`)))
+	var created struct {
+		Data models.Order `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	if rec.Code != http.StatusCreated || created.Data.Status != models.OrderStatusReview {
+		t.Fatalf("expected the order held for review, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// The review takes longer than both the reservation and the pending order TTL; the reservation
+	// lapses and another buyer takes the unit
+	placed := time.Now().Add(-2 * time.Hour)
+	_, _ = repo.Modify(context.Background(), created.Data.ID, func(order *models.Order) error {
+		order.CreatedAt, order.PendingSince = placed, placed
+		return nil
+	})
+	mock.held = nil
+
+	approve := func() int {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/orders/"+created.Data.ID+"/review/approve", nil), map[string]string{"id": created.Data.ID})
+		rec := httptest.NewRecorder()
+		h.ApproveOrder(rec, req)
+		return rec.Code
+	}
+	if code := approve(); code != http.StatusConflict {
+		t.Fatalf("expected 409 without stock for the order got %d", code)
+	}
+	if order, _ := repo.GetByID(context.Background(), created.Data.ID); order.Status != models.OrderStatusReview {
+		t.Fatalf("expected the order left in review, got %s", order.Status)
+	}
+
+	// The unit comes back: approval reserves it, and the order has the full TTL to be paid
+	mock.stock["p1"] = 1
+	if code := approve(); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	order, _ := repo.GetByID(context.Background(), created.Data.ID)
+	if order.Status != models.OrderStatusPending || mock.stock["p1"] != 0 || mock.held[order.Items[0].ReservationID] != 1 {
+		t.Fatalf("expected a pending order holding the unit, got %s %v %+v", order.Status, mock.stock, order.Items)
+	}
+	if expired, _, _ := h.ExpireStaleOrders(context.Background(), time.Now(), 30*time.Minute); expired != 0 {
+		t.Fatalf("expected the order not to expire right after approval, got %d expired", expired)
+	}
+	if expired, _, _ := h.ExpireStaleOrders(context.Background(), time.Now().Add(time.Hour), 30*time.Minute); expired != 1 {
+		t.Fatalf("expected the order to expire a TTL after approval, got %d expired", expired)
+	}
+}
+
+func TestOrderArchival_HidesRestoresAndPurgesDeliveredOrders(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	h := NewOrderHandler(repo, &mockClient{}, nil, nil, nil, nil, nil, nil, nil)
+
+	delivered := models.NewOrder("u1", []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1)})
+	delivered.ChangeStatus(models.OrderStatusDelivered, "test", "")
+	open := models.NewOrder("u1", []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1)})
+	_ = repo.Create(context.Background(), delivered)
+	_ = repo.Create(context.Background(), open)
+
+	list := func(query string) int {
+		rec := httptest.NewRecorder()
+		h.ListOrders(rec, httptest.NewRequest(http.MethodGet, "/orders"+query, nil))
+		var response struct {
+			Data []models.Order `json:"data"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+		return len(response.Data)
+	}
+	restore := func() int {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/orders/"+delivered.ID+"/restore", nil), map[string]string{"id": delivered.ID})
+		rec := httptest.NewRecorder()
+		h.RestoreOrder(rec, req)
+		return rec.Code
+	}
+
+	if archived, _, _ := h.ArchiveDeliveredOrders(context.Background(), time.Now(), 24*time.Hour); archived != 0 {
+		t.Fatalf("expected nothing archived within the retention period, got %d", archived)
+	}
+	later := time.Now().Add(48 * time.Hour)
+	if archived, failed, err := h.ArchiveDeliveredOrders(context.Background(), later, 24*time.Hour); err != nil || archived != 1 || failed != 0 {
+		t.Fatalf("expected the delivered order archived, got %d %d %v", archived, failed, err)
+	}
+	if n := list(""); n != 1 {
+		t.Fatalf("expected archived orders left out of listings, got %d", n)
+	}
+	if n := list("?include_archived=true"); n != 2 {
+		t.Fatalf("expected archived orders included on request, got %d", n)
+	}
+
+	// A restored order gets another retention period before it is archived again
+	if code := restore(); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	if code := restore(); code != http.StatusConflict {
+		t.Fatalf("expected 409 for restoring an order that isn't archived got %d", code)
+	}
+	if archived, _, _ := h.ArchiveDeliveredOrders(context.Background(), time.Now().Add(12*time.Hour), 24*time.Hour); archived != 0 {
+		t.Fatalf("expected the restored order kept out of the archive, got %d", archived)
+	}
+
+	evenLater := time.Now().Add(72 * time.Hour)
+	_, _, _ = h.ArchiveDeliveredOrders(context.Background(), evenLater, 24*time.Hour)
+	if purged, _, _ := h.PurgeArchivedOrders(context.Background(), evenLater, 24*time.Hour); purged != 0 {
+		t.Fatalf("expected nothing purged within the archive retention, got %d", purged)
+	}
+	if purged, failed, err := h.PurgeArchivedOrders(context.Background(), evenLater.Add(48*time.Hour), 24*time.Hour); err != nil || purged != 1 || failed != 0 {
+		t.Fatalf("expected the archived order deleted, got %d %d %v", purged, failed, err)
+	}
+	if _, err := repo.GetByID(context.Background(), delivered.ID); err == nil {
+		t.Fatal("expected the purged order to be gone")
+	}
+}
+
+// refundingRepository marks every order it lists refunded just after listing it, as a refund landing
+// while the archive job runs would
+type refundingRepository struct {
+	*repository.InMemoryOrderRepository
+}
+
+func (r refundingRepository) List(ctx context.Context, filter *models.OrderFilter) ([]*models.Order, *models.PageInfo, error) {
+	orders, info, err := r.InMemoryOrderRepository.List(ctx, filter)
+	for _, order := range orders {
+		refunded := *order
+		refunded.PaymentStatus = models.PaymentRefunded
+		_ = r.InMemoryOrderRepository.Update(ctx, &refunded)
+	}
+	return orders, info, err
+}
+
+func TestArchiveDeliveredOrders_KeepsChangesMadeAfterListing(t *testing.T) {
+	repo := refundingRepository{repository.NewInMemoryOrderRepository()}
+	h := NewOrderHandler(repo, &mockClient{}, nil, nil, nil, nil, nil, nil, nil)
+
+	order := models.NewOrder("u1", []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1)})
+	order.PaymentStatus = models.PaymentPaid
+	order.ChangeStatus(models.OrderStatusDelivered, "test", "")
+	_ = repo.Create(context.Background(), order)
+
+	if archived, failed, err := h.ArchiveDeliveredOrders(context.Background(), time.Now().Add(48*time.Hour), 24*time.Hour); err != nil || archived != 1 || failed != 0 {
+		t.Fatalf("expected the delivered order archived, got %d %d %v", archived, failed, err)
+	}
+	if got, _ := repo.GetByID(context.Background(), order.ID); !got.Archived || got.PaymentStatus != models.PaymentRefunded {
+		t.Errorf("expected the order archived with its refund kept, got archived %v payment %s", got.Archived, got.PaymentStatus)
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"ecommerce/pkg/api"
+	"order-service/internal/models"
+	"order-service/internal/reports"
+)
+
+// Report defaults
+const (
+	defaultRevenueDays   = 30 // days a revenue report covers when no range is given
+	defaultTopProducts   = 10
+	maxRevenueReportDays = 366
+	reportDateLayout     = "2006-01-02"
+)
+
+// ReportHandler serves admin reports from the reports read models rather than the orders themselves
+type ReportHandler struct {
+	reports *reports.Reports
+}
+
+// NewReportHandler creates a new report handler
+func NewReportHandler(reports *reports.Reports) *ReportHandler {
+	return &ReportHandler{reports: reports}
+}
+
+// GetOrderCounts handles GET /admin/reports/order-counts - returns how many orders are in each status (admin function)
+func (h *ReportHandler) GetOrderCounts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := models.Response{
+		Success: true,
+		Data:    h.reports.StatusCounts(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListOrdersByStatus handles GET /admin/reports/orders?status= - lists the orders in a status, newest
+// first, a page at a time (admin function)
+func (h *ReportHandler) ListOrdersByStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	status := models.OrderStatus(query.Get("status"))
+	if !models.IsValidOrderStatus(status) {
+		api.WriteError(w, http.StatusBadRequest, "status must be pending, review, confirmed, shipped, delivered, or cancelled")
+		return
+	}
+	page, limit, err := pageFromQuery(r)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	orders, pageInfo := h.reports.OrdersByStatus(status, page, limit)
+	response := models.Response{
+		Success:    true,
+		Data:       orders,
+		Pagination: pageInfo,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetRevenue handles GET /admin/reports/revenue - returns the revenue of each day from from to to
+// (YYYY-MM-DD, inclusive), the last 30 days by default (admin function)
+func (h *ReportHandler) GetRevenue(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if toStr := query.Get("to"); toStr != "" {
+		parsed, err := time.Parse(reportDateLayout, toStr)
+		if err != nil {
+			api.WriteError(w, http.StatusBadRequest, "to must be a YYYY-MM-DD date")
+			return
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -(defaultRevenueDays - 1))
+	if fromStr := query.Get("from"); fromStr != "" {
+		parsed, err := time.Parse(reportDateLayout, fromStr)
+		if err != nil {
+			api.WriteError(w, http.StatusBadRequest, "from must be a YYYY-MM-DD date")
+			return
+		}
+		from = parsed
+	}
+	if to.Before(from) {
+		api.WriteError(w, http.StatusBadRequest, "from must not be after to")
+		return
+	}
+	if to.Sub(from) >= maxRevenueReportDays*24*time.Hour {
+		api.WriteError(w, http.StatusBadRequest, "revenue reports cover at most 366 days")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    h.reports.RevenueByDay(from.Format(reportDateLayout), to.Format(reportDateLayout)),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetTopProducts handles GET /admin/reports/top-products - returns the products sold in the most units,
+// 10 by default (admin function)
+func (h *ReportHandler) GetTopProducts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := defaultTopProducts
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 {
+			api.WriteError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = min(parsed, models.MaxPageLimit)
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    h.reports.TopProducts(limit),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// pageFromQuery reads the page and limit query parameters, as order listings take them
+func pageFromQuery(r *http.Request) (int, int, error) {
+	query := r.URL.Query()
+	page, limit := 1, models.DefaultPageLimit
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 {
+			return 0, 0, errors.New("limit must be a positive integer")
+		}
+		limit = min(parsed, models.MaxPageLimit)
+	}
+	if pageStr := query.Get("page"); pageStr != "" {
+		parsed, err := strconv.Atoi(pageStr)
+		if err != nil || parsed < 1 {
+			return 0, 0, errors.New("page must be a positive integer")
+		}
+		page = parsed
+	}
+	return page, limit, nil
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"order-service/internal/models"
+	"order-service/internal/reports"
+)
+
+func TestReportHandler_RevenueRange(t *testing.T) {
+	orderReports := reports.New()
+	order := models.NewOrder("u1", []models.OrderItem{models.NewOrderItem("p1", "Prod", 10, 1)})
+	order.Status, order.Total = models.OrderStatusConfirmed, 10
+	order.CreatedAt = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	orderReports.Rebuild([]*models.Order{order})
+	h := NewReportHandler(orderReports)
+
+	revenue := func(query string) (*httptest.ResponseRecorder, []models.DailyRevenue) {
+		rec := httptest.NewRecorder()
+		h.GetRevenue(rec, httptest.NewRequest(http.MethodGet, "/admin/reports/revenue"+query, nil))
+		var response struct {
+			Data []models.DailyRevenue `json:"data"`
+		}
+		json.NewDecoder(rec.Body).Decode(&response)
+		return rec, response.Data
+	}
+
+	if rec, days := revenue("?from=2024-02-01&to=2024-03-01"); rec.Code != http.StatusOK || len(days) != 1 || days[0].Revenue != 10 {
+		t.Fatalf("expected the day's revenue, got %d %+v", rec.Code, days)
+	}
+	// By default the report covers the 30 days to today
+	if rec, days := revenue(""); rec.Code != http.StatusOK || len(days) != 0 {
+		t.Fatalf("expected no recent revenue, got %d %+v", rec.Code, days)
+	}
+	for _, query := range []string{"?from=March", "?from=2024-03-02&to=2024-03-01", "?from=2022-01-01&to=2024-01-01"} {
+		if rec, _ := revenue(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", query, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ListOrdersByStatus(rec, httptest.NewRequest(http.MethodGet, "/admin/reports/orders?status=lost", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown status, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"ecommerce/pkg/api"
+	"order-service/internal/models"
+	"order-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// SubscriptionHandler manages recurring orders and places them as they fall due
+type SubscriptionHandler struct {
+	repo   repository.SubscriptionRepository
+	orders *OrderHandler
+}
+
+// NewSubscriptionHandler creates a new subscription handler. Each cycle's order is placed through
+// orders, exactly as if the customer had placed it with POST /orders.
+func NewSubscriptionHandler(repo repository.SubscriptionRepository, orders *OrderHandler) *SubscriptionHandler {
+	return &SubscriptionHandler{
+		repo:   repo,
+		orders: orders,
+	}
+}
+
+// CreateSubscription handles POST /subscriptions - subscribes a user to a recurring order
+func (h *SubscriptionHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.CreateSubscriptionRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	req.PaymentMethod = strings.TrimSpace(req.PaymentMethod)
+	if req.UserID == "" || len(req.Items) == 0 || req.PaymentMethod == "" {
+		api.WriteError(w, http.StatusBadRequest, "user_id, payment_method, and at least one item are required")
+		return
+	}
+	for _, item := range req.Items {
+		if item.ProductID == "" || item.Quantity < 1 {
+			api.WriteError(w, http.StatusBadRequest, "Every item needs a product_id and a quantity of at least 1")
+			return
+		}
+	}
+	if !models.IsValidSubscriptionInterval(req.Interval) {
+		api.WriteError(w, http.StatusBadRequest, "interval must be daily, weekly, or monthly")
+		return
+	}
+	if req.ShippingMethod == "" {
+		req.ShippingMethod = models.ShippingStandard
+	}
+	if !models.IsValidShippingMethod(req.ShippingMethod) {
+		api.WriteError(w, http.StatusBadRequest, "shipping_method must be standard or express")
+		return
+	}
+	// Every cycle is charged as it is placed, so there must be a way to charge it
+	if h.orders.payments == nil {
+		api.WriteErrorCode(w, http.StatusServiceUnavailable, CodePaymentsUnavailable, "Payments are not available")
+		return
+	}
+	if err := h.orders.client.CheckUserExists(r.Context(), req.UserID); err != nil {
+		slog.ErrorContext(r.Context(), "User validation failed", "error", err)
+		api.WriteErrorCode(w, http.StatusBadRequest, CodeInvalidUser, "Invalid user ID")
+		return
+	}
+
+	subscription := models.NewSubscription(&req, time.Now())
+	if err := h.repo.Create(subscription); err != nil {
+		slog.ErrorContext(r.Context(), "Error creating subscription", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to create subscription")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Subscription created successfully",
+		Data:    subscription,
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetSubscription handles GET /subscriptions/{id} - retrieves a subscription by ID
+func (h *SubscriptionHandler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	subscription, err := h.repo.GetByID(mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeSubscriptionNotFound, "Subscription not found")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    subscription,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetUserSubscriptions handles GET /subscriptions/user/{user_id} - lists a user's subscriptions, oldest first
+func (h *SubscriptionHandler) GetUserSubscriptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	subscriptions, err := h.repo.ListByUser(mux.Vars(r)["user_id"])
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing subscriptions", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve subscriptions")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    subscriptions,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// PauseSubscription handles POST /subscriptions/{id}/pause - stops placing orders until resumed
+func (h *SubscriptionHandler) PauseSubscription(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, (*models.Subscription).Pause, "Subscription paused")
+}
+
+// ResumeSubscription handles POST /subscriptions/{id}/resume - restarts a paused subscription from its next cycle
+func (h *SubscriptionHandler) ResumeSubscription(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, (*models.Subscription).Resume, "Subscription resumed")
+}
+
+// CancelSubscription handles POST /subscriptions/{id}/cancel - ends a subscription; orders already placed are kept
+func (h *SubscriptionHandler) CancelSubscription(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, (*models.Subscription).Cancel, "Subscription cancelled")
+}
+
+// changeStatus applies a pause, resume, or cancel to the subscription named in the path
+func (h *SubscriptionHandler) changeStatus(w http.ResponseWriter, r *http.Request, change func(*models.Subscription, time.Time) error, message string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	subscription, err := h.repo.GetByID(mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeSubscriptionNotFound, "Subscription not found")
+		return
+	}
+
+	if err := change(subscription, time.Now()); err != nil {
+		api.WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	if err := h.repo.Update(subscription); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating subscription", "subscription_id", subscription.ID, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to update subscription")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: message,
+		Data:    subscription,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// RunDueSubscriptions places an order for every active subscription due by now and schedules its
+// next cycle. A cycle whose order can't be placed, such as for missing stock or a declined payment,
+// is recorded on the subscription and skipped. It reports how many orders were placed and how many
+// cycles failed.
+func (h *SubscriptionHandler) RunDueSubscriptions(ctx context.Context, now time.Time) (int, int, error) {
+	due, err := h.repo.ListDue(now)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	placed, failed := 0, 0
+	for _, subscription := range due {
+		order, err := h.orders.placeOrder(ctx, subscription.OrderRequest())
+		orderID := ""
+		if err != nil {
+			slog.ErrorContext(ctx, "Placing the order for subscription failed", "subscription_id", subscription.ID, "error", err)
+			failed++
+		} else {
+			orderID = order.ID
+			placed++
+		}
+
+		subscription.RecordRun(orderID, err, now)
+		if err := h.repo.Update(subscription); err != nil {
+			slog.ErrorContext(ctx, "Error rescheduling subscription", "subscription_id", subscription.ID, "error", err)
+		}
+	}
+	return placed, failed, nil
+}
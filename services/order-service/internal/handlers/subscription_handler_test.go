@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"order-service/internal/models"
+	"order-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+func TestSubscriptions_PlaceOrdersEachCycleAndPause(t *testing.T) {
+	orderRepo := repository.NewInMemoryOrderRepository()
+	subscriptionRepo := repository.NewInMemorySubscriptionRepository()
+	mock := &mockClient{items: []models.OrderItem{models.NewOrderItem("p1", "Coffee", 12, 1)}}
+	payments := &mockPayments{}
+	h := NewSubscriptionHandler(subscriptionRepo, NewOrderHandler(orderRepo, mock, payments, nil, nil, nil, nil, nil, nil))
+
+	create := func(body string) (*httptest.ResponseRecorder, models.Subscription) {
+		rec := httptest.NewRecorder()
+		h.CreateSubscription(rec, httptest.NewRequest(http.MethodPost, "/subscriptions", bytes.NewBufferString(body)))
+		var response struct {
+			Data models.Subscription `json:"data"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+		return rec, response.Data
+	}
+	change := func(action string, id string) int {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/subscriptions/"+id+"/"+action, nil), map[string]string{"id": id})
+		rec := httptest.NewRecorder()
+		switch action {
+		case "pause":
+			h.PauseSubscription(rec, req)
+		case "resume":
+			h.ResumeSubscription(rec, req)
+		default:
+			h.CancelSubscription(rec, req)
+		}
+		return rec.Code
+	}
+
+	if rec, _ := create(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1}],"payment_method":"pm_card","interval":"hourly"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown interval got %d", rec.Code)
+	}
+	rec, subscription := create(`{"user_id":"u1","items":[{"product_id":"p1","quantity":1}],"payment_method":"pm_card","interval":"weekly"}`)
+	if rec.Code != http.StatusCreated || subscription.Status != models.SubscriptionActive {
+		t.Fatalf("expected an active subscription, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// The first order is due right away and is charged as it is placed
+	now := time.Now().Add(time.Second)
+	if placed, failed, err := h.RunDueSubscriptions(context.Background(), now); err != nil || placed != 1 || failed != 0 {
+		t.Fatalf("expected one order placed, got %d %d %v", placed, failed, err)
+	}
+	stored, _ := subscriptionRepo.GetByID(subscription.ID)
+	order, err := orderRepo.GetByID(context.Background(), stored.LastOrderID)
+	if err != nil || order.Metadata[models.SubscriptionMetadataKey] != subscription.ID || len(payments.charged) != 1 {
+		t.Fatalf("expected a paid order tagged with the subscription, got %+v %v", order, err)
+	}
+	if !stored.NextRunAt.Equal(subscription.NextRunAt.AddDate(0, 0, 7)) {
+		t.Fatalf("expected the next order a week later, got %s", stored.NextRunAt)
+	}
+	if placed, _, _ := h.RunDueSubscriptions(context.Background(), now); placed != 0 {
+		t.Fatalf("expected nothing due until next week, got %d", placed)
+	}
+
+	// Paused subscriptions are skipped
+	if code := change("pause", subscription.ID); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	if code := change("pause", subscription.ID); code != http.StatusConflict {
+		t.Fatalf("expected 409 for pausing twice got %d", code)
+	}
+	if placed, _, _ := h.RunDueSubscriptions(context.Background(), now.AddDate(0, 0, 8)); placed != 0 {
+		t.Fatalf("expected no orders while paused, got %d", placed)
+	}
+	if code := change("resume", subscription.ID); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+
+	// A cycle that can't be placed is recorded and skipped
+	mock.outOfStock = map[string]bool{"p1": true}
+	if placed, failed, _ := h.RunDueSubscriptions(context.Background(), now.AddDate(0, 0, 8)); placed != 0 || failed != 1 {
+		t.Fatalf("expected the cycle to fail, got %d placed %d failed", placed, failed)
+	}
+	stored, _ = subscriptionRepo.GetByID(subscription.ID)
+	if stored.LastError == "" || stored.LastOrderID != order.ID || !stored.NextRunAt.After(now.AddDate(0, 0, 8)) {
+		t.Fatalf("expected the failure recorded and the next cycle scheduled, got %+v", stored)
+	}
+
+	if code := change("cancel", subscription.ID); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	if code := change("resume", subscription.ID); code != http.StatusConflict {
+		t.Fatalf("expected 409 for resuming a cancelled subscription got %d", code)
+	}
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"ecommerce/pkg/api"
+	"order-service/internal/carrier"
+	"order-service/internal/models"
+	"order-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// trackingActor is recorded in the status history of orders delivered according to the carrier
+const trackingActor = "carrier-tracking"
+
+// TrackingHandler keeps shipment tracking details up to date, by hand or from a carrier's tracking API
+type TrackingHandler struct {
+	repo    repository.OrderRepository
+	tracker carrier.Tracker
+}
+
+// NewTrackingHandler creates a new tracking handler. Parcels are looked up with tracker, which may be
+// nil to leave tracking to manual updates.
+func NewTrackingHandler(repo repository.OrderRepository, tracker carrier.Tracker) *TrackingHandler {
+	return &TrackingHandler{
+		repo:    repo,
+		tracker: tracker,
+	}
+}
+
+// UpdateTracking handles PATCH /orders/{id}/tracking - sets a shipment's carrier, tracking number,
+// and estimated delivery
+func (h *TrackingHandler) UpdateTracking(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.UpdateTrackingRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+	update := models.TrackingUpdate{
+		Carrier:           strings.TrimSpace(req.Carrier),
+		TrackingNumber:    strings.TrimSpace(req.TrackingNumber),
+		EstimatedDelivery: req.EstimatedDelivery,
+	}
+	if update.Carrier == "" && update.TrackingNumber == "" && update.EstimatedDelivery == nil {
+		api.WriteError(w, http.StatusBadRequest, "Nothing to update; give a carrier, tracking_number, or estimated_delivery")
+		return
+	}
+
+	order, err := h.repo.GetByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeOrderNotFound, "Order not found")
+		return
+	}
+
+	shipment, err := order.FindShipment(req.ShipmentID)
+	if err != nil {
+		if errors.Is(err, models.ErrAmbiguousShipment) {
+			api.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		api.WriteErrorCode(w, http.StatusNotFound, CodeShipmentNotFound, "Shipment not found")
+		return
+	}
+	if shipment.Status == models.ItemStatusDelivered {
+		api.WriteError(w, http.StatusConflict, models.ErrAlreadyDelivered.Error())
+		return
+	}
+
+	shipment, err = order.UpdateTracking(shipment.ID, update, time.Now())
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeShipmentNotFound, "Shipment not found")
+		return
+	}
+	if err := h.repo.Update(r.Context(), order); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating tracking", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to update tracking")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Tracking updated successfully",
+		Data:    order,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// RefreshTracking asks the carrier about every parcel still on its way and records what it reports.
+// Parcels the carrier reports delivered are marked delivered, moving their orders on as manual
+// deliveries do. It reports how many orders changed and how many parcels could not be looked up.
+func (h *TrackingHandler) RefreshTracking(ctx context.Context, now time.Time) (int, int, error) {
+	if h.tracker == nil {
+		return 0, 0, nil
+	}
+
+	var orders []*models.Order
+	for _, status := range []models.OrderStatus{models.OrderStatusConfirmed, models.OrderStatusShipped} {
+		matching, _, err := h.repo.List(ctx, &models.OrderFilter{Status: status})
+		if err != nil {
+			return 0, 0, err
+		}
+		orders = append(orders, matching...)
+	}
+
+	updated, failed := 0, 0
+	for _, order := range orders {
+		changed := false
+		previousStatus := order.Status
+		for _, shipment := range order.Shipments {
+			if shipment.Status == models.ItemStatusDelivered || shipment.Carrier == "" || shipment.TrackingNumber == "" {
+				continue
+			}
+			info, err := h.tracker.Track(shipment.Carrier, shipment.TrackingNumber)
+			if err != nil {
+				slog.ErrorContext(ctx, "Tracking shipment of order failed", "shipment_id", shipment.ID, "order_id", order.ID, "error", err)
+				failed++
+				continue
+			}
+
+			applyCarrierReport(order, shipment.ID, info, now)
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+
+		if order.Status != previousStatus {
+			order.RecordEvent(models.EventOrderStatusChanged, previousStatus)
+		}
+		if err := h.repo.Update(ctx, order); err != nil {
+			slog.ErrorContext(ctx, "Error saving tracking for order", "order_id", order.ID, "error", err)
+			failed++
+			continue
+		}
+		updated++
+	}
+	return updated, failed, nil
+}
+
+// ReportTracking handles POST /internal/orders/{id}/shipments/{shipment_id}/tracking - records what
+// the carrier reports about a parcel, for shipping service, which tracks the parcels it made labels
+// for. A parcel reported delivered is marked delivered, moving the order on as manual deliveries
+// do; reports for a parcel already delivered change nothing.
+func (h *TrackingHandler) ReportTracking(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.TrackingReportRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	order, err := h.repo.GetByID(r.Context(), vars["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeOrderNotFound, "Order not found")
+		return
+	}
+	shipment, err := order.FindShipment(vars["shipment_id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeShipmentNotFound, "Shipment not found")
+		return
+	}
+
+	if shipment.Status != models.ItemStatusDelivered {
+		previousStatus := order.Status
+		applyCarrierReport(order, shipment.ID, &carrier.TrackingInfo{Status: req.Status, EstimatedDelivery: req.EstimatedDelivery, DeliveredAt: req.DeliveredAt}, time.Now())
+		if order.Status != previousStatus {
+			order.RecordEvent(models.EventOrderStatusChanged, previousStatus)
+		}
+		if err := h.repo.Update(r.Context(), order); err != nil {
+			slog.ErrorContext(r.Context(), "Error recording tracking report", "order_id", order.ID, "shipment_id", shipment.ID, "error", err)
+			api.WriteError(w, http.StatusInternalServerError, "Failed to record tracking")
+			return
+		}
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Tracking recorded",
+		Data:    order,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// applyCarrierReport records a carrier's report on one of the order's shipments, delivering it and
+// moving the order on when the carrier says it has arrived
+func applyCarrierReport(order *models.Order, shipmentID string, info *carrier.TrackingInfo, now time.Time) {
+	order.UpdateTracking(shipmentID, models.TrackingUpdate{Status: info.Status, EstimatedDelivery: info.EstimatedDelivery}, now)
+	if info.Status == models.TrackingDelivered {
+		deliveredAt := now
+		if info.DeliveredAt != nil {
+			deliveredAt = *info.DeliveredAt
+		}
+		order.DeliverShipment(shipmentID, deliveredAt)
+		applyShipmentStatus(order, trackingActor, "shipment "+shipmentID+" delivered")
+	}
+}
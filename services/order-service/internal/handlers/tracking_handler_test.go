@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"order-service/internal/carrier"
+	"order-service/internal/models"
+	"order-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+type mockTracker struct {
+	parcels map[string]*carrier.TrackingInfo
+}
+
+func (m *mockTracker) Track(carrierName, trackingNumber string) (*carrier.TrackingInfo, error) {
+	info, ok := m.parcels[trackingNumber]
+	if !ok {
+		return nil, carrier.ErrUnknownShipment
+	}
+	return info, nil
+}
+
+func newShippedOrder(t *testing.T, repo *repository.InMemoryOrderRepository, trackingNumbers ...string) *models.Order {
+	t.Helper()
+	var items []models.OrderItem
+	for _, number := range trackingNumbers {
+		items = append(items, models.NewOrderItem("p-"+number, "Prod", 10, 1))
+	}
+	order := models.NewOrder("u1", items)
+	order.ChangeStatus(models.OrderStatusConfirmed, "test", "")
+	for _, number := range trackingNumbers {
+		if _, err := order.AddShipment([]string{"p-" + number}, "ups", number, time.Now()); err != nil {
+			t.Fatalf("add shipment failed: %v", err)
+		}
+	}
+	applyShipmentStatus(order, "test", "")
+	_ = repo.Create(context.Background(), order)
+	return order
+}
+
+func TestTrackingHandler_UpdateTracking(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	h := NewTrackingHandler(repo, nil)
+	single := newShippedOrder(t, repo, "1Z1")
+	split := newShippedOrder(t, repo, "1Z2", "1Z3")
+
+	update := func(orderID, body string) int {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPatch, "/orders/"+orderID+"/tracking", bytes.NewBufferString(body)), map[string]string{"id": orderID})
+		rec := httptest.NewRecorder()
+		h.UpdateTracking(rec, req)
+		return rec.Code
+	}
+	if code := update(single.ID, `{}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an empty update got %d", code)
+	}
+	if code := update(single.ID, `{"carrier":"dhl","tracking_number":"JD01","estimated_delivery":"2030-01-02T00:00:00Z"}`); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	got, _ := repo.GetByID(context.Background(), single.ID)
+	if got.Shipments[0].Carrier != "dhl" || got.Shipments[0].TrackingNumber != "JD01" || got.EstimatedDelivery == nil || got.EstimatedDelivery.Year() != 2030 {
+		t.Fatalf("expected the shipment and order ETA to be updated, got %+v", got)
+	}
+
+	if code := update(split.ID, `{"carrier":"dhl"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a shipment_id for a split order got %d", code)
+	}
+	if code := update(split.ID, `{"shipment_id":"nope","carrier":"dhl"}`); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown shipment got %d", code)
+	}
+	if code := update(split.ID, `{"shipment_id":"`+split.Shipments[1].ID+`","carrier":"dhl"}`); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+}
+
+func TestTrackingHandler_RefreshTracking(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	eta := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	delivered := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := &mockTracker{parcels: map[string]*carrier.TrackingInfo{
+		"1Z1": {Status: models.TrackingInTransit, EstimatedDelivery: &eta},
+		"1Z2": {Status: models.TrackingDelivered, DeliveredAt: &delivered},
+	}}
+	h := NewTrackingHandler(repo, tracker)
+	split := newShippedOrder(t, repo, "1Z1", "1Z2")
+	single := newShippedOrder(t, repo, "1Z2")
+	unknown := newShippedOrder(t, repo, "1Z9")
+
+	updated, failed, err := h.RefreshTracking(context.Background(), time.Now())
+	if err != nil || updated != 2 || failed != 1 {
+		t.Fatalf("expected 2 orders updated and 1 lookup failed, got %d %d %v", updated, failed, err)
+	}
+
+	got, _ := repo.GetByID(context.Background(), split.ID)
+	if got.Status != models.OrderStatusShipped || got.Shipments[0].TrackingStatus != models.TrackingInTransit || got.Shipments[1].Status != models.ItemStatusDelivered {
+		t.Fatalf("expected one parcel in transit and one delivered, got %+v", got)
+	}
+	if got.EstimatedDelivery == nil || !got.EstimatedDelivery.Equal(eta) {
+		t.Errorf("expected the order ETA to come from the parcel still on its way, got %v", got.EstimatedDelivery)
+	}
+
+	got, _ = repo.GetByID(context.Background(), single.ID)
+	if got.Status != models.OrderStatusDelivered || !got.Shipments[0].DeliveredAt.Equal(delivered) {
+		t.Fatalf("expected the order delivered when the carrier said so, got %s", got.Status)
+	}
+	if last := got.StatusHistory[len(got.StatusHistory)-1]; last.Actor != trackingActor {
+		t.Errorf("expected the change to be attributed to %s, got %s", trackingActor, last.Actor)
+	}
+
+	if got, _ := repo.GetByID(context.Background(), unknown.ID); got.Shipments[0].TrackedAt != nil {
+		t.Error("expected a parcel the carrier doesn't know to be left alone")
+	}
+}
+
+func TestTrackingHandler_ReportTracking(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	h := NewTrackingHandler(repo, nil)
+	order := newShippedOrder(t, repo, "1Z1")
+	shipmentID := order.Shipments[0].ID
+
+	report := func(shipmentID, body string) int {
+		vars := map[string]string{"id": order.ID, "shipment_id": shipmentID}
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/internal/orders/"+order.ID+"/shipments/"+shipmentID+"/tracking", bytes.NewBufferString(body)), vars)
+		rec := httptest.NewRecorder()
+		h.ReportTracking(rec, req)
+		return rec.Code
+	}
+	if code := report(shipmentID, `{"status":"lost"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown status got %d", code)
+	}
+	if code := report("nope", `{"status":"in_transit"}`); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown shipment got %d", code)
+	}
+	if code := report(shipmentID, `{"status":"in_transit","estimated_delivery":"2030-01-02T00:00:00Z"}`); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	got, _ := repo.GetByID(context.Background(), order.ID)
+	if got.Shipments[0].TrackingStatus != models.TrackingInTransit || got.EstimatedDelivery == nil || got.EstimatedDelivery.Year() != 2030 {
+		t.Fatalf("expected the parcel in transit with its ETA, got %+v", got.Shipments[0])
+	}
+
+	if code := report(shipmentID, `{"status":"delivered","delivered_at":"2030-01-01T12:00:00Z"}`); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	got, _ = repo.GetByID(context.Background(), order.ID)
+	if got.Status != models.OrderStatusDelivered || got.Shipments[0].DeliveredAt.Year() != 2030 {
+		t.Fatalf("expected the order delivered, got %s", got.Status)
+	}
+	// A late report for a delivered parcel is acknowledged and changes nothing
+	if code := report(shipmentID, `{"status":"in_transit"}`); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	if got, _ := repo.GetByID(context.Background(), order.ID); got.Shipments[0].TrackingStatus != models.TrackingDelivered {
+		t.Errorf("expected the delivered parcel left alone, got %s", got.Shipments[0].TrackingStatus)
+	}
+}
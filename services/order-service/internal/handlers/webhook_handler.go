@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"ecommerce/pkg/api"
+	"order-service/internal/models"
+	"order-service/internal/repository"
+	"order-service/internal/webhook"
+
+	"github.com/gorilla/mux"
+)
+
+// WebhookHandler manages subscriptions to order events and their delivery logs
+type WebhookHandler struct {
+	repo repository.WebhookRepository
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(repo repository.WebhookRepository) *WebhookHandler {
+	return &WebhookHandler{repo: repo}
+}
+
+// CreateSubscription handles POST /webhooks - subscribes a URL to order events. The signing
+// secret is returned only in this response.
+func (h *WebhookHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.CreateWebhookRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	req.URL = strings.TrimSpace(req.URL)
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		api.WriteError(w, http.StatusBadRequest, "url must be an absolute http or https URL")
+		return
+	}
+	if len(req.Events) == 0 {
+		api.WriteError(w, http.StatusBadRequest, "At least one event is required")
+		return
+	}
+	for _, event := range req.Events {
+		if !models.IsValidWebhookEvent(event) {
+			api.WriteError(w, http.StatusBadRequest, "Unknown event "+event+"; expected "+models.EventOrderCreated+", "+models.EventOrderStatusChanged+", or "+models.EventOrderCancelled)
+			return
+		}
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating webhook secret", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to create webhook")
+		return
+	}
+
+	subscription := models.NewWebhookSubscription(req.URL, req.Events, secret)
+	if err := h.repo.CreateSubscription(subscription); err != nil {
+		slog.ErrorContext(r.Context(), "Error creating webhook subscription", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to create webhook")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Webhook created; store the secret now, it will not be shown again",
+		Data: models.WebhookSubscriptionResponse{
+			WebhookSubscription: *subscription,
+			Secret:              secret,
+		},
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListSubscriptions handles GET /webhooks - lists subscriptions without their secrets
+func (h *WebhookHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	subscriptions, err := h.repo.ListSubscriptions()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing webhook subscriptions", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve webhooks")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    subscriptions,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetSubscription handles GET /webhooks/{id} - retrieves a subscription
+func (h *WebhookHandler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	subscription, err := h.repo.GetSubscription(mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeWebhookNotFound, "Webhook not found")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    subscription,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteSubscription handles DELETE /webhooks/{id} - unsubscribes; pending retries are dropped
+func (h *WebhookHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := h.repo.DeleteSubscription(mux.Vars(r)["id"]); err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeWebhookNotFound, "Webhook not found")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Webhook deleted successfully",
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListDeliveries handles GET /webhooks/{id}/deliveries - returns the subscription's delivery
+// attempts, newest first
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	deliveries, err := h.repo.ListDeliveries(mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeWebhookNotFound, "Webhook not found")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    deliveries,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
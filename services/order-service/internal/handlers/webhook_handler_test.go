@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"order-service/internal/models"
+	"order-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+func TestWebhookHandler_SubscriptionLifecycle(t *testing.T) {
+	repo := repository.NewInMemoryWebhookRepository()
+	h := NewWebhookHandler(repo)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.CreateSubscription(rec, httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(body)))
+		return rec
+	}
+	if rec := create(`{"url":"ftp://example.com","events":["order.created"]}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a non-HTTP URL got %d", rec.Code)
+	}
+	if rec := create(`{"url":"https://example.com/hook","events":["order.deleted"]}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown event got %d", rec.Code)
+	}
+
+	rec := create(`{"url":"https://example.com/hook","events":["order.created","order.status.changed"]}`)
+	var created struct {
+		Data struct {
+			ID     string `json:"id"`
+			Secret string `json:"secret"`
+		} `json:"data"`
+	}
+	json.NewDecoder(rec.Body).Decode(&created)
+	if rec.Code != http.StatusCreated || created.Data.ID == "" || created.Data.Secret == "" {
+		t.Fatalf("expected the subscription and its secret, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetSubscription(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/webhooks/"+created.Data.ID, nil), map[string]string{"id": created.Data.ID}))
+	if rec.Code != http.StatusOK || bytes.Contains(rec.Body.Bytes(), []byte(created.Data.Secret)) {
+		t.Fatalf("expected the subscription without its secret, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	_ = repo.AddDelivery(&models.WebhookDelivery{ID: "d1", SubscriptionID: created.Data.ID, Attempt: 1, Success: true})
+	rec = httptest.NewRecorder()
+	h.ListDeliveries(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/webhooks/"+created.Data.ID+"/deliveries", nil), map[string]string{"id": created.Data.ID}))
+	if rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte(`"d1"`)) {
+		t.Fatalf("expected the delivery log, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.DeleteSubscription(rec, mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/webhooks/"+created.Data.ID, nil), map[string]string{"id": created.Data.ID}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+	if _, err := repo.ListDeliveries(created.Data.ID); err == nil {
+		t.Error("expected the delivery log to go with the subscription")
+	}
+}
@@ -0,0 +1,53 @@
+package invoice
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache keeps rendered invoices so repeat downloads skip the buyer lookup and rendering.
+// An entry is only served while the order is unchanged since it was rendered.
+type Cache struct {
+	mutex   sync.RWMutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	orderUpdatedAt time.Time
+	document       []byte
+}
+
+// NewCache creates an empty invoice cache
+func NewCache() *Cache {
+	return &Cache{entries: make(map[string]cacheEntry)}
+}
+
+// Get returns the cached invoice for the order in format, if it was rendered from the order as last updated at orderUpdatedAt
+func (c *Cache) Get(orderID, format string, orderUpdatedAt time.Time) ([]byte, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	entry, exists := c.entries[orderID+"/"+format]
+	if !exists || !entry.orderUpdatedAt.Equal(orderUpdatedAt) {
+		return nil, false
+	}
+	return entry.document, true
+}
+
+// Put stores a rendered invoice
+func (c *Cache) Put(orderID, format string, orderUpdatedAt time.Time, document []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries[orderID+"/"+format] = cacheEntry{orderUpdatedAt: orderUpdatedAt, document: document}
+}
+
+// Remove drops the order's cached invoices in every format
+func (c *Cache) Remove(orderID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, format := range []string{FormatPDF, FormatHTML} {
+		delete(c.entries, orderID+"/"+format)
+	}
+}
@@ -0,0 +1,175 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"math"
+	"strings"
+	"time"
+	"order-service/internal/models"
+)
+
+// Formats an invoice can be rendered in
+const (
+	FormatPDF  = "pdf"
+	FormatHTML = "html"
+)
+
+// Invoice is the billing document for an order
+type Invoice struct {
+	Number   string
+	IssuedAt time.Time
+	Order    *models.Order
+	Buyer    *models.User // nil when the buyer's details are no longer held
+}
+
+// New creates the invoice for an order. Invoice numbers are derived from the order ID so
+// regenerating an invoice keeps its number.
+func New(order *models.Order, buyer *models.User, now time.Time) *Invoice {
+	number := strings.ToUpper(strings.ReplaceAll(order.ID, "-", ""))
+	if len(number) > 12 {
+		number = number[:12]
+	}
+	return &Invoice{
+		Number:   "INV-" + number,
+		IssuedAt: now,
+		Order:    order,
+		Buyer:    buyer,
+	}
+}
+
+// ContentType returns the MIME type of an invoice rendered in format
+func ContentType(format string) string {
+	if format == FormatHTML {
+		return "text/html; charset=utf-8"
+	}
+	return "application/pdf"
+}
+
+// Render renders the invoice as a PDF or HTML document
+func (inv *Invoice) Render(format string) ([]byte, error) {
+	switch format {
+	case FormatPDF:
+		return inv.renderPDF(), nil
+	case FormatHTML:
+		var buf bytes.Buffer
+		if err := htmlTemplate.Execute(&buf, inv); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unsupported invoice format %q", format)
+}
+
+// Currency returns the currency the invoice amounts are in
+func (inv *Invoice) Currency() string {
+	return models.OrderCurrency
+}
+
+// TaxRate returns the effective tax rate on the discounted subtotal as a percentage
+func (inv *Invoice) TaxRate() float64 {
+	taxable := inv.Order.DiscountedSubtotal()
+	if taxable == 0 {
+		return 0
+	}
+	return math.Round(inv.Order.Tax/taxable*10000) / 100
+}
+
+// renderPDF lays the invoice out as lines of text on A4 pages
+func (inv *Invoice) renderPDF() []byte {
+	order := inv.Order
+	lines := []string{
+		"INVOICE " + inv.Number,
+		"",
+		"Order: " + order.ID,
+		"Order date: " + order.CreatedAt.Format("2006-01-02"),
+		"Invoice date: " + inv.IssuedAt.Format("2006-01-02"),
+		"Status: " + string(order.Status) + ", payment " + string(order.PaymentStatus),
+		"",
+		"Bill to:",
+	}
+	if inv.Buyer != nil {
+		lines = append(lines, "  "+inv.Buyer.Name, "  "+inv.Buyer.Email)
+	}
+	lines = append(lines, addressLines(order.ShippingAddress)...)
+
+	lines = append(lines, "", fmt.Sprintf("%-40s %8s %12s %12s", "Item", "Qty", "Unit price", "Amount"))
+	for _, item := range order.Items {
+		lines = append(lines, fmt.Sprintf("%-40.40s %8d %12s %12s", item.ProductName, item.Quantity, money(item.Price), money(item.Subtotal)))
+	}
+
+	lines = append(lines, "", fmt.Sprintf("%62s %12s", "Subtotal", money(order.Subtotal)))
+	if order.Discount > 0 {
+		lines = append(lines, fmt.Sprintf("%62s %12s", "Discount ("+order.CouponCode+")", "-"+money(order.Discount)))
+	}
+	lines = append(lines,
+		fmt.Sprintf("%62s %12s", "Shipping", money(order.ShippingCost)),
+		fmt.Sprintf("%62s %12s", fmt.Sprintf("Tax (%g%%)", inv.TaxRate()), money(order.Tax)),
+	)
+	if order.PointsCredit > 0 {
+		lines = append(lines, fmt.Sprintf("%62s %12s", fmt.Sprintf("Points redeemed (%d)", order.PointsRedeemed), "-"+money(order.PointsCredit)))
+	}
+	lines = append(lines, fmt.Sprintf("%62s %12s", "Total "+inv.Currency(), money(order.Total)))
+	return writePDF(lines)
+}
+
+// addressLines formats a shipping address, indented under "Bill to"
+func addressLines(address *models.Address) []string {
+	if address == nil {
+		return nil
+	}
+	var lines []string
+	for _, line := range []string{
+		address.RecipientName,
+		address.Line1,
+		address.Line2,
+		strings.TrimSpace(strings.Join([]string{address.City, address.State, address.PostalCode}, " ")),
+		address.Country,
+	} {
+		if line != "" {
+			lines = append(lines, "  "+line)
+		}
+	}
+	return lines
+}
+
+// money formats an amount with two decimals
+func money(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
+
+var htmlTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{"money": money}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Number}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { padding: 4px 8px; border-bottom: 1px solid #ddd; text-align: left; }
+td.amount, th.amount { text-align: right; }
+</style>
+</head>
+<body>
+<h1>Invoice {{.Number}}</h1>
+<p>Order {{.Order.ID}}<br>
+Order date {{.Order.CreatedAt.Format "2006-01-02"}}<br>
+Invoice date {{.IssuedAt.Format "2006-01-02"}}<br>
+Status {{.Order.Status}}, payment {{.Order.PaymentStatus}}</p>
+<h2>Bill to</h2>
+<p>{{with .Buyer}}{{.Name}}<br>{{.Email}}<br>{{end}}
+{{with .Order.ShippingAddress}}{{.RecipientName}}<br>{{.Line1}}<br>{{if .Line2}}{{.Line2}}<br>{{end}}{{.City}} {{.State}} {{.PostalCode}}<br>{{.Country}}{{end}}</p>
+<table>
+<tr><th>Item</th><th class="amount">Qty</th><th class="amount">Unit price</th><th class="amount">Amount</th></tr>
+{{range .Order.Items}}<tr><td>{{.ProductName}}</td><td class="amount">{{.Quantity}}</td><td class="amount">{{money .Price}}</td><td class="amount">{{money .Subtotal}}</td></tr>
+{{end}}<tr><td colspan="3" class="amount">Subtotal</td><td class="amount">{{money .Order.Subtotal}}</td></tr>
+{{if gt .Order.Discount 0.0}}<tr><td colspan="3" class="amount">Discount ({{.Order.CouponCode}})</td><td class="amount">-{{money .Order.Discount}}</td></tr>
+{{end}}<tr><td colspan="3" class="amount">Shipping</td><td class="amount">{{money .Order.ShippingCost}}</td></tr>
+<tr><td colspan="3" class="amount">Tax ({{.TaxRate}}%)</td><td class="amount">{{money .Order.Tax}}</td></tr>
+{{if gt .Order.PointsCredit 0.0}}<tr><td colspan="3" class="amount">Points redeemed ({{.Order.PointsRedeemed}})</td><td class="amount">-{{money .Order.PointsCredit}}</td></tr>
+{{end}}<tr><th colspan="3" class="amount">Total {{.Currency}}</th><th class="amount">{{money .Order.Total}}</th></tr>
+</table>
+</body>
+</html>
+`))
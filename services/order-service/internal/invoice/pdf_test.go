@@ -0,0 +1,41 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestWritePDF_CrossReferencesObjectsAndSplitsPages(t *testing.T) {
+	lines := make([]string, linesPerPage+5)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d (of many)", i)
+	}
+	doc := writePDF(lines)
+
+	if !bytes.Contains(doc, []byte("/Count 2")) {
+		t.Error("expected the lines to fill two pages")
+	}
+	if !bytes.Contains(doc, []byte(`(line 0 \(of many\)) '`)) {
+		t.Error("expected parentheses to be escaped")
+	}
+
+	// Every xref entry must point at the start of its object
+	startxref := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(doc)
+	if startxref == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(startxref[1]))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(doc[xref:], -1)
+	if len(entries) != 3+2*2 {
+		t.Fatalf("expected 7 objects, got %d", len(entries))
+	}
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if want := fmt.Sprintf("%d 0 obj", i+1); !bytes.HasPrefix(doc[offset:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q", i+1, doc[offset:offset+10])
+		}
+	}
+}
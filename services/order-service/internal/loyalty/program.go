@@ -0,0 +1,110 @@
+package loyalty
+
+import (
+	"errors"
+	"math"
+	"time"
+	"order-service/internal/models"
+	"order-service/internal/repository"
+)
+
+// ErrInvalidRates is returned for a negative earn rate or point value
+var ErrInvalidRates = errors.New("points per unit and point value must not be negative")
+
+// Program awards points on confirmed orders and lets users spend them at checkout. Every change
+// to a balance is written to the ledger.
+type Program struct {
+	ledger        repository.LoyaltyRepository
+	pointsPerUnit float64 // points earned for each whole unit of currency spent
+	pointValue    float64 // what one redeemed point takes off an order's total
+}
+
+// NewProgram creates a loyalty program earning pointsPerUnit points per unit of currency spent and
+// redeeming each point for pointValue
+func NewProgram(ledger repository.LoyaltyRepository, pointsPerUnit, pointValue float64) (*Program, error) {
+	if pointsPerUnit < 0 || pointValue < 0 {
+		return nil, ErrInvalidRates
+	}
+	return &Program{
+		ledger:        ledger,
+		pointsPerUnit: pointsPerUnit,
+		pointValue:    pointValue,
+	}, nil
+}
+
+// Value returns what the points take off an order's total
+func (p *Program) Value(points int) float64 {
+	return models.RoundCents(float64(points) * p.pointValue)
+}
+
+// PointsFor returns the points the order earns. Only the discounted subtotal counts, less whatever
+// was paid for with points; shipping and tax earn nothing.
+func (p *Program) PointsFor(order *models.Order) int {
+	spent := order.DiscountedSubtotal() - order.PointsCredit
+	if spent <= 0 {
+		return 0
+	}
+	return int(math.Floor(spent * p.pointsPerUnit))
+}
+
+// Redeem takes the points redeemed on the order from the buyer's balance
+func (p *Program) Redeem(order *models.Order, now time.Time) error {
+	if order.PointsRedeemed == 0 {
+		return nil
+	}
+	return p.ledger.Add(models.NewLoyaltyEntry(order.UserID, order.ID, -order.PointsRedeemed, models.PointsRedeemed, now))
+}
+
+// RefundRedeemed gives back the points redeemed on an order that was cancelled or never placed. They
+// are only given back once, however often the order is refunded.
+func (p *Program) RefundRedeemed(order *models.Order, now time.Time) error {
+	if order.PointsRedeemed == 0 {
+		return nil
+	}
+	return p.ledger.Add(models.NewLoyaltyEntry(order.UserID, order.ID, order.PointsRedeemed, models.PointsRefunded, now))
+}
+
+// Earn records on a newly purchased order the points it earns, for Award to credit once the order is
+// saved. Guest orders earn nothing.
+func (p *Program) Earn(order *models.Order) {
+	if order.UserID == "" {
+		return
+	}
+	order.PointsEarned = p.PointsFor(order)
+}
+
+// Award credits the buyer with the points Earn recorded on the order. An order is only credited once,
+// however often it is awarded.
+func (p *Program) Award(order *models.Order, now time.Time) error {
+	if order.PointsEarned == 0 {
+		return nil
+	}
+	return p.ledger.Add(models.NewLoyaltyEntry(order.UserID, order.ID, order.PointsEarned, models.PointsEarned, now))
+}
+
+// Reverse takes back the points a cancelled order earned; the order keeps them as a record of what
+// was earned. The balance can go below zero if they have already been spent.
+func (p *Program) Reverse(order *models.Order, now time.Time) error {
+	if order.PointsEarned == 0 {
+		return nil
+	}
+	return p.ledger.Add(models.NewLoyaltyEntry(order.UserID, order.ID, -order.PointsEarned, models.PointsReversed, now))
+}
+
+// Account returns the user's balance and ledger entries
+func (p *Program) Account(userID string) (*models.LoyaltyAccount, error) {
+	balance, err := p.ledger.Balance(userID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := p.ledger.Entries(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.LoyaltyAccount{
+		UserID:     userID,
+		Balance:    balance,
+		PointValue: p.pointValue,
+		Entries:    entries,
+	}, nil
+}
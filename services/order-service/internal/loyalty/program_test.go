@@ -0,0 +1,71 @@
+package loyalty
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"order-service/internal/models"
+	"order-service/internal/repository"
+)
+
+func TestProgram_AwardRedeemAndReverse(t *testing.T) {
+	if _, err := NewProgram(repository.NewInMemoryLoyaltyRepository(), -1, 0.01); !errors.Is(err, ErrInvalidRates) {
+		t.Fatalf("expected ErrInvalidRates, got %v", err)
+	}
+
+	ledger := repository.NewInMemoryLoyaltyRepository()
+	program, err := NewProgram(ledger, 2, 0.05)
+	if err != nil {
+		t.Fatalf("NewProgram failed: %v", err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Shipping and tax earn nothing; fractions of a point are dropped
+	order := &models.Order{ID: "o1", UserID: "u1", Subtotal: 10.75, ShippingCost: 5, Tax: 2}
+	if points := program.PointsFor(order); points != 21 {
+		t.Fatalf("expected 21 points, got %d", points)
+	}
+	program.Earn(order)
+	if err := program.Award(order, now); err != nil || order.PointsEarned != 21 {
+		t.Fatalf("expected 21 points awarded, got %d %v", order.PointsEarned, err)
+	}
+	// A retried or racing award credits nothing more
+	if err := program.Award(order, now); err != nil {
+		t.Fatalf("Award failed: %v", err)
+	}
+
+	next := &models.Order{ID: "o2", UserID: "u1", PointsRedeemed: 30}
+	if err := program.Redeem(next, now); !errors.Is(err, models.ErrInsufficientPoints) {
+		t.Fatalf("expected ErrInsufficientPoints, got %v", err)
+	}
+	next.PointsRedeemed = 20
+	if err := program.Redeem(next, now); err != nil {
+		t.Fatalf("Redeem failed: %v", err)
+	}
+
+	// Points already spent leave the balance negative when their order is cancelled; a cancellation
+	// handled twice takes them back once
+	for i := 0; i < 2; i++ {
+		if err := program.Reverse(order, now); err != nil {
+			t.Fatalf("Reverse failed: %v", err)
+		}
+	}
+	account, err := program.Account("u1")
+	if err != nil || account.Balance != -20 || len(account.Entries) != 3 || account.PointValue != 0.05 {
+		t.Fatalf("unexpected account %+v %v", account, err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := program.RefundRedeemed(next, now); err != nil {
+			t.Fatalf("RefundRedeemed failed: %v", err)
+		}
+	}
+	if balance, _ := ledger.Balance("u1"); balance != 0 {
+		t.Fatalf("expected the redeemed points given back once, got a balance of %d", balance)
+	}
+
+	guest := &models.Order{ID: "o3", GuestEmail: "guest@example.com", Subtotal: 100}
+	program.Earn(guest)
+	if err := program.Award(guest, now); err != nil || guest.PointsEarned != 0 {
+		t.Fatalf("expected guests to earn nothing, got %d %v", guest.PointsEarned, err)
+	}
+}
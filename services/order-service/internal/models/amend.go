@@ -0,0 +1,75 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AmendItemsRequest represents the request payload for changing a pending order's items. Each entry
+// sets a product's quantity: products not yet on the order are added, and a quantity of 0 removes one.
+type AmendItemsRequest struct {
+	Items []AmendOrderItem `json:"items" validate:"required,min=1,dive"`
+}
+
+// AmendOrderItem sets the quantity of one product on an order
+type AmendOrderItem struct {
+	ProductID string `json:"product_id" validate:"required"`
+	Quantity  int    `json:"quantity" validate:"min=0"`
+}
+
+// AmendedItems applies the changes to the order's items and returns the full list to order,
+// in the order's existing sequence with new products at the end
+func (o *Order) AmendedItems(changes []AmendOrderItem) ([]CreateOrderItem, error) {
+	quantities := make(map[string]int, len(changes))
+	var added []string
+	for _, change := range changes {
+		if change.ProductID == "" {
+			return nil, errors.New("product_id is required for every item")
+		}
+		if change.Quantity < 0 {
+			return nil, fmt.Errorf("quantity for product %s can't be negative", change.ProductID)
+		}
+		if _, repeated := quantities[change.ProductID]; repeated {
+			return nil, fmt.Errorf("product %s is listed more than once", change.ProductID)
+		}
+		quantities[change.ProductID] = change.Quantity
+		if !o.Contains(change.ProductID) {
+			if change.Quantity == 0 {
+				return nil, fmt.Errorf("product %s is not on the order", change.ProductID)
+			}
+			added = append(added, change.ProductID)
+		}
+	}
+
+	var items []CreateOrderItem
+	for _, item := range o.Items {
+		quantity, changed := quantities[item.ProductID]
+		if !changed {
+			quantity = item.Quantity
+		}
+		if quantity > 0 {
+			items = append(items, CreateOrderItem{ProductID: item.ProductID, Quantity: quantity})
+		}
+	}
+	for _, productID := range added {
+		items = append(items, CreateOrderItem{ProductID: productID, Quantity: quantities[productID]})
+	}
+	if len(items) == 0 {
+		return nil, errors.New("an order needs at least one item; cancel it instead")
+	}
+	return items, nil
+}
+
+// ReplaceItems swaps in a new set of items and recomputes the subtotal and total. Shipping, discount
+// and tax are left as they were for the caller to work out again.
+func (o *Order) ReplaceItems(items []OrderItem, now time.Time) {
+	var subtotal float64
+	for _, item := range items {
+		subtotal += item.Subtotal
+	}
+	o.Items = items
+	o.Subtotal = RoundCents(subtotal)
+	o.updateTotal()
+	o.UpdatedAt = now
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Archive hides a delivered order from default listings. It is kept until it is purged or restored.
+func (o *Order) Archive(now time.Time) {
+	o.Archived = true
+	o.ArchivedAt = &now
+	o.UpdatedAt = now
+}
+
+// Restore brings an archived order back into listings. It is kept out of the archive for another
+// retention period from now.
+func (o *Order) Restore(now time.Time) {
+	o.Archived = false
+	o.ArchivedAt = nil
+	o.RestoredAt = &now
+	o.UpdatedAt = now
+}
+
+// DeliveredAt returns when the order was last marked delivered, or nil if it never was
+func (o *Order) DeliveredAt() *time.Time {
+	for i := len(o.StatusHistory) - 1; i >= 0; i-- {
+		if o.StatusHistory[i].To == OrderStatusDelivered {
+			at := o.StatusHistory[i].At
+			return &at
+		}
+	}
+	return nil
+}
+
+// DueForArchive reports whether the order is delivered and has been left alone since before
+// cutoff: delivered, and not restored from the archive, by then
+func (o *Order) DueForArchive(cutoff time.Time) bool {
+	if o.Archived || o.Status != OrderStatusDelivered {
+		return false
+	}
+	since := o.UpdatedAt
+	if deliveredAt := o.DeliveredAt(); deliveredAt != nil {
+		since = *deliveredAt
+	}
+	if o.RestoredAt != nil && o.RestoredAt.After(since) {
+		since = *o.RestoredAt
+	}
+	return since.Before(cutoff)
+}
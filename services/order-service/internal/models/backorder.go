@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// NeedsBackorder reports whether an order line for quantity units can only be accepted as a
+// backorder: the product takes backorders and doesn't have the stock to cover it now
+func (p *Product) NeedsBackorder(quantity int) bool {
+	return p.AllowBackorder && !p.IsDigital() && p.Stock < quantity
+}
+
+// HasBackorderedItems reports whether any of the order's items are still waiting for stock
+func (o *Order) HasBackorderedItems() bool {
+	for _, item := range o.Items {
+		if item.Status == ItemStatusBackordered {
+			return true
+		}
+	}
+	return false
+}
+
+// AllocateItem fills a backordered item with the stock held by reservationID, making it ready to ship.
+// It reports whether the item was still backordered; if not, the order is left as it was.
+func (o *Order) AllocateItem(productID, reservationID string, now time.Time) bool {
+	item := findItem(o.Items, productID)
+	if item == nil || item.Status != ItemStatusBackordered {
+		return false
+	}
+	items := make([]OrderItem, len(o.Items))
+	copy(items, o.Items)
+	item = findItem(items, productID)
+	item.ReservationID = reservationID
+	item.Status = ""
+	o.Items = items
+	o.UpdatedAt = now
+	return true
+}
+
+// BackorderItem puts an item filled with the stock held by reservationID back on backorder, for when
+// that stock couldn't be kept. It reports whether the item was still filled with it.
+func (o *Order) BackorderItem(productID, reservationID string, now time.Time) bool {
+	item := findItem(o.Items, productID)
+	if item == nil || item.Status == ItemStatusBackordered || item.ReservationID != reservationID {
+		return false
+	}
+	items := make([]OrderItem, len(o.Items))
+	copy(items, o.Items)
+	item = findItem(items, productID)
+	item.ReservationID = ""
+	item.Status = ItemStatusBackordered
+	o.Items = items
+	o.UpdatedAt = now
+	return true
+}
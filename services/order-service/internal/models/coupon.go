@@ -0,0 +1,88 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// CouponType is how a coupon's value is applied
+type CouponType string
+
+// Coupon types
+const (
+	CouponPercentage CouponType = "percentage" // value is a percentage of the subtotal, 10 for 10%
+	CouponFixed      CouponType = "fixed"      // value is an amount off the subtotal
+)
+
+// Coupon errors returned when a code can't be applied
+var (
+	ErrCouponNotFound = errors.New("coupon not found")
+	ErrCouponExpired  = errors.New("coupon has expired")
+	ErrCouponUsedUp   = errors.New("coupon has reached its usage limit")
+)
+
+// Coupon is a discount code customers can apply at checkout
+type Coupon struct {
+	Code      string     `json:"code"`
+	Type      CouponType `json:"type"`
+	Value     float64    `json:"value"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // never expires when nil
+	MaxUses   int        `json:"max_uses,omitempty"`   // unlimited when 0
+	Uses      int        `json:"uses"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// CreateCouponRequest represents the request payload for creating a coupon
+type CreateCouponRequest struct {
+	Code      string     `json:"code" validate:"required"`
+	Type      CouponType `json:"type" validate:"required"`
+	Value     float64    `json:"value" validate:"required,gt=0"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	MaxUses   int        `json:"max_uses,omitempty"`
+}
+
+// NormalizeCouponCode returns the canonical form of a code; codes are matched case-insensitively
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Validate checks the coupon's type and value
+func (c *Coupon) Validate() error {
+	switch {
+	case c.Code == "":
+		return errors.New("code is required")
+	case c.Type != CouponPercentage && c.Type != CouponFixed:
+		return errors.New("type must be percentage or fixed")
+	case c.Value <= 0:
+		return errors.New("value must be greater than 0")
+	case c.Type == CouponPercentage && c.Value > 100:
+		return errors.New("a percentage coupon can't be worth more than 100")
+	case c.MaxUses < 0:
+		return errors.New("max_uses can't be negative")
+	}
+	return nil
+}
+
+// CheckUsable reports why the coupon can't be used at now, if it can't
+func (c *Coupon) CheckUsable(now time.Time) error {
+	if c.ExpiresAt != nil && !now.Before(*c.ExpiresAt) {
+		return ErrCouponExpired
+	}
+	if c.MaxUses > 0 && c.Uses >= c.MaxUses {
+		return ErrCouponUsedUp
+	}
+	return nil
+}
+
+// Discount returns what the coupon takes off a subtotal, never more than the subtotal itself
+func (c *Coupon) Discount(subtotal float64) float64 {
+	discount := c.Value
+	if c.Type == CouponPercentage {
+		discount = subtotal * c.Value / 100
+	}
+	if discount > subtotal {
+		discount = subtotal
+	}
+	return RoundCents(discount)
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+	"github.com/google/uuid"
+)
+
+// OrderEvent is a domain event about an order. Events recorded on an order are written to the
+// outbox in the same repository write as the order, then published from there.
+type OrderEvent struct {
+	ID         string         `json:"id"`
+	Type       string         `json:"type"` // EventOrderCreated, EventOrderStatusChanged, or EventOrderCancelled
+	OrderID    string         `json:"order_id"`
+	Data       OrderEventData `json:"data"`
+	OccurredAt time.Time      `json:"occurred_at"`
+	Attempts   int            `json:"attempts,omitempty"`   // failed publish attempts so far
+	LastError  string         `json:"last_error,omitempty"` // why the last publish attempt failed
+}
+
+// OrderEventData is the data of an order event
+type OrderEventData struct {
+	Order          *Order      `json:"order"`
+	PreviousStatus OrderStatus `json:"previous_status,omitempty"` // set on status changes
+	Release        []HeldStock `json:"release,omitempty"`         // on cancellation, the stock left for product service to release
+}
+
+// HeldStock is a stock reservation an order held in product service
+type HeldStock struct {
+	ProductID     string `json:"product_id"`
+	ReservationID string `json:"reservation_id"`
+}
+
+// RecordEvent notes an event about the order as it is now; it reaches the outbox when the order is next saved
+func (o *Order) RecordEvent(eventType string, previousStatus OrderStatus) {
+	o.recordEvent(eventType, OrderEventData{PreviousStatus: previousStatus})
+}
+
+// RecordCancellation notes that the order was cancelled from previousStatus, naming the reservations
+// product service should release on its own; release is empty when they were released directly
+func (o *Order) RecordCancellation(previousStatus OrderStatus, release []HeldStock) {
+	o.recordEvent(EventOrderCancelled, OrderEventData{PreviousStatus: previousStatus, Release: release})
+}
+
+// recordEvent notes an event with data about the order as it is now
+func (o *Order) recordEvent(eventType string, data OrderEventData) {
+	snapshot := *o
+	snapshot.Items = append([]OrderItem(nil), o.Items...)
+	snapshot.Events = nil
+	data.Order = &snapshot
+	o.Events = append(o.Events, OrderEvent{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		OrderID:    o.ID,
+		Data:       data,
+		OccurredAt: time.Now(),
+	})
+}
+
+// TakeReservations returns the stock reservations the order's items hold and forgets them, for when
+// someone else releases them
+func (o *Order) TakeReservations() []HeldStock {
+	var held []HeldStock
+	for i := range o.Items {
+		item := &o.Items[i]
+		if item.ReservationID == "" {
+			continue
+		}
+		held = append(held, HeldStock{ProductID: item.ProductID, ReservationID: item.ReservationID})
+		item.ReservationID = ""
+	}
+	return held
+}
+
+// TakeEvents returns the events recorded since the order was last saved and forgets them
+func (o *Order) TakeEvents() []OrderEvent {
+	events := o.Events
+	o.Events = nil
+	return events
+}
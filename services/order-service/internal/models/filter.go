@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Page size limits for order listings
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// OrderFilter represents filtering and pagination options for order queries.
+// Orders are listed newest first; a zero Limit returns every match.
+type OrderFilter struct {
+	Status          OrderStatus `json:"status,omitempty"`
+	UserID          string      `json:"user_id,omitempty"`
+	From            *time.Time  `json:"from,omitempty"`             // orders created at or after this time
+	To              *time.Time  `json:"to,omitempty"`               // orders created before this time
+	IncludeArchived bool        `json:"include_archived,omitempty"` // archived orders are left out otherwise
+	Page            int         `json:"page,omitempty"`
+	Limit           int         `json:"limit,omitempty"`
+}
+
+// PageInfo describes where a page of results sits in the full result set
+type PageInfo struct {
+	Page       int  `json:"page"`
+	Limit      int  `json:"limit"`
+	Total      int  `json:"total"`
+	TotalPages int  `json:"total_pages"`
+	HasMore    bool `json:"has_more"`
+}
+
+// Matches reports whether the order passes the filter's status, user, date range, and archive setting
+func (f *OrderFilter) Matches(order *Order) bool {
+	if order.Archived && !f.IncludeArchived {
+		return false
+	}
+	if f.Status != "" && order.Status != f.Status {
+		return false
+	}
+	if f.UserID != "" && order.UserID != f.UserID {
+		return false
+	}
+	if f.From != nil && order.CreatedAt.Before(*f.From) {
+		return false
+	}
+	if f.To != nil && !order.CreatedAt.Before(*f.To) {
+		return false
+	}
+	return true
+}
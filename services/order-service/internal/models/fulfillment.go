@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ProductKindDigital is the product service kind for products delivered as downloads
+const ProductKindDigital = "digital"
+
+// DigitalFulfillment is the download link issued for a digital order item
+type DigitalFulfillment struct {
+	Token       string    `json:"token"`
+	DownloadURL string    `json:"download_url"`
+	IssuedAt    time.Time `json:"issued_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// IsDigital reports whether the product is delivered as a download, so has no stock to check or reserve
+func (p *Product) IsDigital() bool {
+	return p.Kind == ProductKindDigital
+}
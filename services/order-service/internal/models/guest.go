@@ -0,0 +1,74 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// GuestActor is recorded in the status history as the creator of a guest order
+const GuestActor = "guest"
+
+// GuestOrderResponse is returned once, when a guest order is placed, with the token that claims it
+type GuestOrderResponse struct {
+	Order
+	ClaimToken string `json:"claim_token"`
+}
+
+// ClaimOrderRequest represents the request payload for linking a guest order to an account
+type ClaimOrderRequest struct {
+	UserID     string `json:"user_id" validate:"required"`
+	ClaimToken string `json:"claim_token" validate:"required"`
+}
+
+// NewClaimToken generates the secret that lets a guest claim their order later
+func NewClaimToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// NewGuestOrder creates an order for a buyer without an account, identified by their email
+func NewGuestOrder(email string, items []OrderItem, claimToken string) *Order {
+	order := NewOrder("", items)
+	order.GuestEmail = email
+	order.ClaimToken = claimToken
+	order.StatusHistory[0].Actor = GuestActor
+	return order
+}
+
+// IsGuest reports whether the order was placed without an account and hasn't been claimed
+func (o *Order) IsGuest() bool {
+	return o.UserID == "" && o.GuestEmail != ""
+}
+
+// Claim links a guest order to the buyer's account; the guest email and claim token are dropped
+func (o *Order) Claim(userID string) {
+	o.UserID = userID
+	o.GuestEmail = ""
+	o.ClaimToken = ""
+	o.UpdatedAt = time.Now()
+}
+
+// ValidateGuestEmail checks that email is a bare address such as jo@example.com
+func ValidateGuestEmail(email string) error {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return errors.New("email must be a valid email address")
+	}
+	return nil
+}
+
+// CheckComplete reports whether the address has everything needed to ship to it
+func (a *Address) CheckComplete() error {
+	if a == nil || strings.TrimSpace(a.RecipientName) == "" || strings.TrimSpace(a.Line1) == "" ||
+		strings.TrimSpace(a.City) == "" || strings.TrimSpace(a.PostalCode) == "" || strings.TrimSpace(a.Country) == "" {
+		return errors.New("shipping_address needs recipient_name, line1, city, postal_code and country")
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Codes identifying why an order line was rejected
+const (
+	ItemErrorInvalidProduct    = "invalid_product"
+	ItemErrorInsufficientStock = "insufficient_stock"
+	ItemErrorBelowMinimum      = "quantity_below_minimum"
+	ItemErrorAboveMaximum      = "quantity_above_maximum"
+)
+
+// ItemValidationError reports which line of an order request was rejected and why, so
+// clients can point the customer at the offending item
+type ItemValidationError struct {
+	ItemIndex int    `json:"item_index"` // zero-based position in the request's items
+	ProductID string `json:"product_id"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Requested int    `json:"requested"`
+	Limit     int    `json:"limit,omitempty"` // the minimum, maximum, or available stock the quantity broke
+	Err       error  `json:"-"`               // underlying cause, such as a failed product lookup
+}
+
+func (e *ItemValidationError) Error() string {
+	return e.Message
+}
+
+func (e *ItemValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ItemValidationErrors collects every rejected line of an order request, so the customer can
+// fix them all at once. errors.As finds the individual *ItemValidationError values too.
+type ItemValidationErrors struct {
+	Errors []*ItemValidationError `json:"errors"` // in request order
+}
+
+func (e *ItemValidationErrors) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Message
+	}
+	messages := make([]string, len(e.Errors))
+	for i, itemErr := range e.Errors {
+		messages[i] = itemErr.Message
+	}
+	return fmt.Sprintf("%d order items were rejected: %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+func (e *ItemValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, itemErr := range e.Errors {
+		errs[i] = itemErr
+	}
+	return errs
+}
+
+// CheckOrderQuantity validates a requested quantity against the product's order limits and stock.
+// Digital products have no stock to run out of, and products taking backorders accept more than they
+// hold, so only their limits apply.
+func (p *Product) CheckOrderQuantity(index, quantity int) *ItemValidationError {
+	itemErr := &ItemValidationError{ItemIndex: index, ProductID: p.ID, Requested: quantity}
+	switch {
+	case p.MinOrderQty > 0 && quantity < p.MinOrderQty:
+		itemErr.Code = ItemErrorBelowMinimum
+		itemErr.Limit = p.MinOrderQty
+		itemErr.Message = fmt.Sprintf("quantity for product %s must be at least %d, requested %d", p.Name, p.MinOrderQty, quantity)
+	case p.MaxOrderQty > 0 && quantity > p.MaxOrderQty:
+		itemErr.Code = ItemErrorAboveMaximum
+		itemErr.Limit = p.MaxOrderQty
+		itemErr.Message = fmt.Sprintf("quantity for product %s must be at most %d, requested %d", p.Name, p.MaxOrderQty, quantity)
+	case !p.IsDigital() && !p.AllowBackorder && p.Stock < quantity:
+		itemErr.Code = ItemErrorInsufficientStock
+		itemErr.Limit = p.Stock
+		itemErr.Message = fmt.Sprintf("insufficient stock for product %s: available %d, requested %d", p.Name, p.Stock, quantity)
+	default:
+		return nil
+	}
+	return itemErr
+}
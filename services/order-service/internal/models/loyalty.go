@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+	"github.com/google/uuid"
+)
+
+// LoyaltyReason says why a user's points balance changed
+type LoyaltyReason string
+
+const (
+	PointsEarned   LoyaltyReason = "earned"   // awarded when an order is confirmed
+	PointsRedeemed LoyaltyReason = "redeemed" // spent at checkout
+	PointsRefunded LoyaltyReason = "refunded" // redeemed points given back when their order was cancelled or not placed
+	PointsReversed LoyaltyReason = "reversed" // earned points taken back when their order was cancelled
+)
+
+// ErrInsufficientPoints is returned when a user redeems more points than they hold
+var ErrInsufficientPoints = errors.New("not enough loyalty points")
+
+// LoyaltyEntry is one change to a user's points balance
+type LoyaltyEntry struct {
+	ID        string        `json:"id"`
+	UserID    string        `json:"user_id"`
+	OrderID   string        `json:"order_id"`
+	Points    int           `json:"points"` // positive when points are added, negative when taken away
+	Reason    LoyaltyReason `json:"reason"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// NewLoyaltyEntry creates a ledger entry with a generated ID
+func NewLoyaltyEntry(userID, orderID string, points int, reason LoyaltyReason, now time.Time) *LoyaltyEntry {
+	return &LoyaltyEntry{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		OrderID:   orderID,
+		Points:    points,
+		Reason:    reason,
+		CreatedAt: now,
+	}
+}
+
+// LoyaltyAccount is a user's points balance with the entries that make it up
+type LoyaltyAccount struct {
+	UserID     string          `json:"user_id"`
+	Balance    int             `json:"balance"`
+	PointValue float64         `json:"point_value"` // what one point takes off an order's total
+	Entries    []*LoyaltyEntry `json:"entries"`     // newest first
+}
+
+// ApplyPoints records the points redeemed on the order and the credit they give, and recomputes its
+// total. The credit never takes the total below zero.
+func (o *Order) ApplyPoints(points int, credit float64) {
+	o.PointsRedeemed = points
+	o.PointsCredit = 0
+	o.updateTotal()
+	o.PointsCredit = RoundCents(math.Min(credit, o.Total))
+	o.updateTotal()
+}
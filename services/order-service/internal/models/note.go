@@ -0,0 +1,57 @@
+package models
+
+import (
+	"fmt"
+	"time"
+	"github.com/google/uuid"
+)
+
+// Limits on client-supplied order metadata
+const (
+	MaxMetadataKeys        = 20
+	MaxMetadataKeyLength   = 40
+	MaxMetadataValueLength = 500
+)
+
+// OrderNote is an internal note on an order. Notes are only shown to admin tooling.
+type OrderNote struct {
+	ID        string    `json:"id"`
+	Author    string    `json:"author"` // the person named by the caller, or the calling service
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddNoteRequest represents the request payload for adding a note to an order
+type AddNoteRequest struct {
+	Body   string `json:"body" validate:"required"`
+	Author string `json:"author,omitempty"` // who wrote the note; the calling service is recorded when empty
+}
+
+// AddNote appends an internal note to the order and returns it
+func (o *Order) AddNote(author, body string, now time.Time) *OrderNote {
+	notes := make([]OrderNote, len(o.Notes), len(o.Notes)+1)
+	copy(notes, o.Notes)
+	o.Notes = append(notes, OrderNote{
+		ID:        uuid.New().String(),
+		Author:    author,
+		Body:      body,
+		CreatedAt: now,
+	})
+	return &o.Notes[len(o.Notes)-1]
+}
+
+// ValidateMetadata checks client-supplied metadata against the size limits
+func ValidateMetadata(metadata map[string]string) error {
+	if len(metadata) > MaxMetadataKeys {
+		return fmt.Errorf("metadata can have at most %d keys", MaxMetadataKeys)
+	}
+	for key, value := range metadata {
+		if key == "" || len(key) > MaxMetadataKeyLength {
+			return fmt.Errorf("metadata keys must be 1 to %d characters", MaxMetadataKeyLength)
+		}
+		if len(value) > MaxMetadataValueLength {
+			return fmt.Errorf("metadata value for %q is longer than %d characters", key, MaxMetadataValueLength)
+		}
+	}
+	return nil
+}
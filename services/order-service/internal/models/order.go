@@ -2,6 +2,7 @@ package models
 
 import (
 	"time"
+	"ecommerce/pkg/api"
 	"github.com/google/uuid"
 )
 
@@ -14,32 +15,83 @@ const (
 	OrderStatusShipped   OrderStatus = "shipped"
 	OrderStatusDelivered OrderStatus = "delivered"
 	OrderStatusCancelled OrderStatus = "cancelled"
+	OrderStatusReview    OrderStatus = "review" // held by fraud screening until approved or rejected
 )
 
 // Order represents an order in the system
 type Order struct {
-	ID         string      `json:"id"`
-	UserID     string      `json:"user_id"`
-	Items      []OrderItem `json:"items"`
-	TotalPrice float64     `json:"total_price"`
-	Status     OrderStatus `json:"status"`
-	CreatedAt  time.Time   `json:"created_at"`
-	UpdatedAt  time.Time   `json:"updated_at"`
+	ID                string            `json:"id"`
+	UserID            string            `json:"user_id"`               // empty for guest orders until they are claimed
+	GuestEmail        string            `json:"guest_email,omitempty"` // how a guest buyer is reached
+	ClaimToken        string            `json:"-"`                     // proves the right to claim a guest order
+	Items             []OrderItem       `json:"items"`
+	Subtotal          float64           `json:"subtotal"` // sum of the item subtotals
+	CouponCode        string            `json:"coupon_code,omitempty"`
+	Discount          float64           `json:"discount"` // taken off the subtotal by the coupon
+	ShippingMethod    ShippingMethod    `json:"shipping_method,omitempty"`
+	ShippingCost      float64           `json:"shipping_cost"`
+	Tax               float64           `json:"tax"`
+	PointsRedeemed    int               `json:"points_redeemed,omitempty"` // loyalty points spent on the order
+	PointsCredit      float64           `json:"points_credit,omitempty"`   // what the redeemed points took off the total
+	Total             float64           `json:"total"`                     // subtotal less discount, plus shipping and tax, less points credit; what the customer pays
+	PointsEarned      int               `json:"points_earned,omitempty"`   // awarded once the order is confirmed
+	Status            OrderStatus       `json:"status"`
+	PendingSince      time.Time         `json:"pending_since"`            // when the order last became pending; unpaid orders expire a while after
+	ReviewReasons     []string          `json:"review_reasons,omitempty"` // why fraud screening held the order
+	ShippingAddress   *Address          `json:"shipping_address,omitempty"`
+	BillingAddress    *Address          `json:"billing_address,omitempty"`
+	PaymentStatus     PaymentStatus     `json:"payment_status"`
+	PaymentID         string            `json:"payment_id,omitempty"` // the provider's reference for the charge
+	StatusHistory     []StatusChange    `json:"status_history"`
+	Shipments         []Shipment        `json:"shipments,omitempty"`
+	EstimatedDelivery *time.Time        `json:"estimated_delivery,omitempty"` // the latest estimate among parcels still on their way
+	Metadata          map[string]string `json:"metadata,omitempty"`           // client-supplied, such as storefront correlation IDs
+	Notes             []OrderNote       `json:"-"`                            // internal; served only by the admin notes endpoint
+	Events            []OrderEvent      `json:"-"`                            // recorded since the order was last saved
+	AnonymizedAt      *time.Time        `json:"anonymized_at,omitempty"`
+	Archived          bool              `json:"archived,omitempty"` // left out of listings unless archived orders are asked for
+	ArchivedAt        *time.Time        `json:"archived_at,omitempty"`
+	RestoredAt        *time.Time        `json:"restored_at,omitempty"` // when the order was last brought back from the archive
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
 }
 
 // OrderItem represents a single item in an order
 type OrderItem struct {
-	ProductID string  `json:"product_id"`
-	ProductName string `json:"product_name"`
-	Price     float64 `json:"price"`
-	Quantity  int     `json:"quantity"`
-	Subtotal  float64 `json:"subtotal"`
+	ProductID     string     `json:"product_id"`
+	ProductName   string     `json:"product_name"`
+	Price         float64    `json:"price"`
+	Quantity      int        `json:"quantity"`
+	Subtotal      float64    `json:"subtotal"`
+	ReservationID string     `json:"reservation_id,omitempty"` // product service stock reservation held for this item
+	Digital       bool       `json:"digital,omitempty"`        // delivered as a download rather than shipped
+	WeightKg      float64    `json:"weight_kg,omitempty"`      // shipping weight of one unit
+	Status        ItemStatus `json:"status,omitempty"`         // backordered while waiting for stock; shipped or delivered once on its way
+	// Fulfillment is the download link issued once a digital item's order is confirmed
+	Fulfillment *DigitalFulfillment `json:"fulfillment,omitempty"`
 }
 
 // CreateOrderRequest represents the request payload for creating an order
 type CreateOrderRequest struct {
-	UserID string            `json:"user_id" validate:"required"`
-	Items  []CreateOrderItem `json:"items" validate:"required,min=1"`
+	UserID string            `json:"user_id,omitempty"`
+	Items  []CreateOrderItem `json:"items" validate:"required,min=1,dive"`
+	// Email places a guest order instead of one for UserID; guests give their ShippingAddress in full
+	Email           string   `json:"email,omitempty"`
+	ShippingAddress *Address `json:"shipping_address,omitempty"`
+	// ShippingAddressID selects one of the user's saved addresses; the default shipping address is used when empty
+	ShippingAddressID string `json:"shipping_address_id,omitempty"`
+	// BillingAddress is where the payment method is registered; it is only used to screen the order for fraud
+	BillingAddress *Address `json:"billing_address,omitempty"`
+	// ShippingMethod is standard or express; standard is used when empty
+	ShippingMethod ShippingMethod `json:"shipping_method,omitempty"`
+	// PaymentMethod pays for the order as it is placed; without one the order is paid later with POST /orders/{id}/pay
+	PaymentMethod string `json:"payment_method,omitempty"`
+	// CouponCode applies a discount to the order's subtotal
+	CouponCode string `json:"coupon_code,omitempty"`
+	// RedeemPoints spends the user's loyalty points on the order, taking their value off the total
+	RedeemPoints int `json:"redeem_points,omitempty"`
+	// Metadata is stored on the order as given, for the client's own references
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 // CreateOrderItem represents an item in the order creation request
@@ -51,41 +103,79 @@ type CreateOrderItem struct {
 // UpdateOrderStatusRequest represents the request payload for updating order status
 type UpdateOrderStatusRequest struct {
 	Status OrderStatus `json:"status" validate:"required"`
+	Note   string      `json:"note,omitempty"` // recorded in the order's status history
 }
 
 // User represents user data from user service
 type User struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Active bool   `json:"active"`
 }
 
+// OrderCurrency is the currency order totals are kept in; product prices are requested in it
+const OrderCurrency = "USD"
+
 // Product represents product data from product service
 type Product struct {
-	ID    string  `json:"id"`
-	Name  string  `json:"name"`
-	Price float64 `json:"price"`
-	Stock int     `json:"stock"`
+	ID             string  `json:"id"`
+	Name           string  `json:"name"`
+	Price          float64 `json:"price"`
+	EffectivePrice float64 `json:"effective_price"` // regular or sale price, whichever applies right now
+	Currency       string  `json:"currency"`
+	Stock          int     `json:"stock"`
+	Kind           string  `json:"kind,omitempty"` // physical or digital; empty means physical
+	WeightKg       float64 `json:"weight_kg,omitempty"`
+	MinOrderQty    int     `json:"min_order_qty,omitempty"` // 0 means no minimum
+	MaxOrderQty    int     `json:"max_order_qty,omitempty"` // 0 means no maximum
+	AllowBackorder bool    `json:"allow_backorder,omitempty"`
+}
+
+// UnitPrice returns the price to charge for the product, falling back to the regular
+// price when product service doesn't report an effective price
+func (p *Product) UnitPrice() float64 {
+	if p.EffectivePrice > 0 {
+		return p.EffectivePrice
+	}
+	return p.Price
+}
+
+// Address represents a shipping address snapshot from user service
+type Address struct {
+	ID            string `json:"id"`
+	RecipientName string `json:"recipient_name"`
+	Line1         string `json:"line1"`
+	Line2         string `json:"line2,omitempty"`
+	City          string `json:"city"`
+	State         string `json:"state,omitempty"`
+	PostalCode    string `json:"postal_code"`
+	Country       string `json:"country"`
+	Phone         string `json:"phone,omitempty"`
 }
 
 // NewOrder creates a new order with generated ID and timestamps
 func NewOrder(userID string, items []OrderItem) *Order {
 	now := time.Now()
 	
-	// Calculate total price
-	var totalPrice float64
+	// Calculate the subtotal; tax is added once it has been worked out
+	var subtotal float64
 	for _, item := range items {
-		totalPrice += item.Subtotal
+		subtotal += item.Subtotal
 	}
 
 	return &Order{
-		ID:         uuid.New().String(),
-		UserID:     userID,
-		Items:      items,
-		TotalPrice: totalPrice,
-		Status:     OrderStatusPending,
-		CreatedAt:  now,
-		UpdatedAt:  now,
+		ID:            uuid.New().String(),
+		UserID:        userID,
+		Items:         items,
+		Subtotal:      RoundCents(subtotal),
+		Total:         RoundCents(subtotal),
+		Status:        OrderStatusPending,
+		PaymentStatus: PaymentUnpaid,
+		StatusHistory: []StatusChange{{To: OrderStatusPending, Actor: userID, At: now}},
+		PendingSince:  now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
 	}
 }
 
@@ -106,15 +196,50 @@ func (o *Order) UpdateStatus(status OrderStatus) {
 	o.UpdatedAt = time.Now()
 }
 
+// Anonymize strips personal data from the order while keeping it for accounting
+func (o *Order) Anonymize() {
+	now := time.Now()
+	o.ShippingAddress = nil
+	o.BillingAddress = nil
+	o.GuestEmail = ""
+	o.Notes = nil // free-form notes may name the buyer
+	o.AnonymizedAt = &now
+	o.UpdatedAt = now
+}
+
+// Contains checks if the order includes the given product
+func (o *Order) Contains(productID string) bool {
+	for _, item := range o.Items {
+		if item.ProductID == productID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPurchased checks if the order has been confirmed and not cancelled
+func (o *Order) IsPurchased() bool {
+	return IsPurchasedStatus(o.Status)
+}
+
+// IsPurchasedStatus checks if a status means the order's items have been sold
+func IsPurchasedStatus(status OrderStatus) bool {
+	return status == OrderStatusConfirmed || status == OrderStatusShipped || status == OrderStatusDelivered
+}
+
+// IsValidOrderStatus checks if a status is one of the known order states
+func IsValidOrderStatus(status OrderStatus) bool {
+	switch status {
+	case OrderStatusPending, OrderStatusConfirmed, OrderStatusShipped, OrderStatusDelivered, OrderStatusCancelled, OrderStatusReview:
+		return true
+	}
+	return false
+}
+
 // CanBeCancelled checks if the order can be cancelled
 func (o *Order) CanBeCancelled() bool {
-	return o.Status == OrderStatusPending || o.Status == OrderStatusConfirmed
+	return o.CheckTransition(OrderStatusCancelled) == nil
 }
 
 // Response represents a standard API response
-type Response struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
-}
+type Response = api.Response[PageInfo]
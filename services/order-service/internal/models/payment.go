@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// PaymentStatus tracks whether an order has been paid for
+type PaymentStatus string
+
+// Payment states. Pending payments are settled later by a provider webhook.
+const (
+	PaymentUnpaid   PaymentStatus = "unpaid"
+	PaymentPending  PaymentStatus = "pending"
+	PaymentPaid     PaymentStatus = "paid"
+	PaymentFailed   PaymentStatus = "failed"
+	PaymentRefunded PaymentStatus = "refunded"
+)
+
+// PayOrderRequest represents the request payload for paying for an order
+type PayOrderRequest struct {
+	// PaymentMethod is the provider's reference for the customer's card or wallet, such as a Stripe PaymentMethod ID
+	PaymentMethod string `json:"payment_method" validate:"required"`
+}
+
+// PaymentResult is a payment provider's answer to a charge
+type PaymentResult struct {
+	PaymentID string
+	Status    PaymentStatus // paid, or pending when the provider confirms asynchronously
+}
+
+// PaymentEvent is a provider's notification that a payment settled
+type PaymentEvent struct {
+	PaymentID string        `json:"payment_id"`
+	OrderID   string        `json:"order_id"`
+	Status    PaymentStatus `json:"status"`
+}
+
+// CanBePaid checks if a payment can be taken for the order
+func (o *Order) CanBePaid() bool {
+	if o.Status == OrderStatusCancelled {
+		return false
+	}
+	return o.PaymentStatus == "" || o.PaymentStatus == PaymentUnpaid || o.PaymentStatus == PaymentFailed
+}
+
+// ApplyPayment records a charge's outcome on the order
+func (o *Order) ApplyPayment(paymentID string, status PaymentStatus) {
+	o.PaymentID = paymentID
+	o.PaymentStatus = status
+	o.UpdatedAt = time.Now()
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// OrderSummary is an order as admin listings show it, kept in the reports read model
+type OrderSummary struct {
+	ID        string      `json:"id"`
+	UserID    string      `json:"user_id,omitempty"`
+	Status    OrderStatus `json:"status"`
+	ItemCount int         `json:"item_count"` // units across the order's items
+	Total     float64     `json:"total"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// StatusCounts is how many orders are in each status
+type StatusCounts map[OrderStatus]int
+
+// DailyRevenue is what the orders placed on one day brought in. Only purchased orders count.
+type DailyRevenue struct {
+	Date       string  `json:"date"` // YYYY-MM-DD, in UTC
+	OrderCount int     `json:"order_count"`
+	Revenue    float64 `json:"revenue"` // the orders' totals, as paid
+}
+
+// ProductSales is how much of one product has been sold across purchased orders
+type ProductSales struct {
+	ProductID   string  `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	Quantity    int     `json:"quantity"`
+	Revenue     float64 `json:"revenue"` // the items' subtotals, before discounts, shipping, and tax
+	OrderCount  int     `json:"order_count"`
+}
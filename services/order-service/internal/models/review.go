@@ -0,0 +1,17 @@
+package models
+
+import "strings"
+
+// FraudScreeningActor is recorded in the status history of orders held by fraud screening
+const FraudScreeningActor = "fraud-screening"
+
+// ReviewDecisionRequest represents the request payload for approving or rejecting a held order
+type ReviewDecisionRequest struct {
+	Note string `json:"note,omitempty"` // recorded in the order's status history
+}
+
+// HoldForReview moves a new order into review, recording why it was held
+func (o *Order) HoldForReview(reasons []string) {
+	o.ReviewReasons = append([]string{}, reasons...)
+	o.ChangeStatus(OrderStatusReview, FraudScreeningActor, strings.Join(reasons, "; "))
+}
@@ -0,0 +1,195 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"github.com/google/uuid"
+)
+
+// ItemStatus tracks the delivery of one order item; empty means the item is ready but has not shipped yet
+type ItemStatus string
+
+// Item delivery states
+const (
+	ItemStatusBackordered ItemStatus = "backordered" // accepted without stock; no units are held for it yet
+	ItemStatusShipped     ItemStatus = "shipped"
+	ItemStatusDelivered   ItemStatus = "delivered"
+)
+
+// Shipment is a parcel carrying some of an order's items. An order can ship in several parcels.
+type Shipment struct {
+	ID                string         `json:"id"`
+	ProductIDs        []string       `json:"product_ids"`
+	Carrier           string         `json:"carrier,omitempty"`
+	TrackingNumber    string         `json:"tracking_number,omitempty"`
+	TrackingStatus    TrackingStatus `json:"tracking_status,omitempty"` // as last reported by the carrier
+	TrackedAt         *time.Time     `json:"tracked_at,omitempty"`      // when the carrier was last asked
+	EstimatedDelivery *time.Time     `json:"estimated_delivery,omitempty"`
+	Status            ItemStatus     `json:"status"`
+	ShippedAt         time.Time      `json:"shipped_at"`
+	DeliveredAt       *time.Time     `json:"delivered_at,omitempty"`
+}
+
+// CreateShipmentRequest represents the request payload for shipping some of an order's items
+type CreateShipmentRequest struct {
+	// ProductIDs lists the items in the parcel; every item still waiting to ship is included when empty
+	ProductIDs        []string   `json:"product_ids,omitempty"`
+	Carrier           string     `json:"carrier,omitempty"`
+	TrackingNumber    string     `json:"tracking_number,omitempty"`
+	EstimatedDelivery *time.Time `json:"estimated_delivery,omitempty"`
+}
+
+// UpdateShipmentRequest represents the request payload for updating a shipment
+type UpdateShipmentRequest struct {
+	Status ItemStatus `json:"status" validate:"required"` // only delivered is accepted
+}
+
+// Shipment errors
+var (
+	ErrShipmentNotFound = errors.New("shipment not found")
+	ErrNothingToShip    = errors.New("order has no items waiting to ship")
+	ErrAlreadyDelivered = errors.New("shipment was already delivered")
+)
+
+// AddShipment ships the listed items, or every unshipped physical item when productIDs is empty.
+// Items must belong to the order, be physical, have their stock, and not have shipped already.
+func (o *Order) AddShipment(productIDs []string, carrier, trackingNumber string, now time.Time) (*Shipment, error) {
+	if len(productIDs) == 0 {
+		for _, item := range o.Items {
+			if !item.Digital && item.Status == "" {
+				productIDs = append(productIDs, item.ProductID)
+			}
+		}
+		if len(productIDs) == 0 {
+			return nil, ErrNothingToShip
+		}
+	}
+
+	items := make([]OrderItem, len(o.Items))
+	copy(items, o.Items)
+	for _, productID := range productIDs {
+		item := findItem(items, productID)
+		switch {
+		case item == nil:
+			return nil, fmt.Errorf("product %s is not in the order", productID)
+		case item.Digital:
+			return nil, fmt.Errorf("product %s is digital and is not shipped", productID)
+		case item.Status == ItemStatusBackordered:
+			return nil, fmt.Errorf("product %s is backordered and can't ship until its stock arrives", productID)
+		case item.Status != "":
+			return nil, fmt.Errorf("product %s has already shipped", productID)
+		}
+		item.Status = ItemStatusShipped
+	}
+
+	shipment := Shipment{
+		ID:             uuid.New().String(),
+		ProductIDs:     productIDs,
+		Carrier:        carrier,
+		TrackingNumber: trackingNumber,
+		Status:         ItemStatusShipped,
+		ShippedAt:      now,
+	}
+	o.Items = items
+	o.Shipments = append(append(make([]Shipment, 0, len(o.Shipments)+1), o.Shipments...), shipment)
+	o.UpdatedAt = now
+	return &o.Shipments[len(o.Shipments)-1], nil
+}
+
+// DeliverShipment marks a shipment and the items it carried as delivered
+func (o *Order) DeliverShipment(shipmentID string, now time.Time) (*Shipment, error) {
+	shipments := make([]Shipment, len(o.Shipments))
+	copy(shipments, o.Shipments)
+
+	var shipment *Shipment
+	for i := range shipments {
+		if shipments[i].ID == shipmentID {
+			shipment = &shipments[i]
+			break
+		}
+	}
+	if shipment == nil {
+		return nil, ErrShipmentNotFound
+	}
+	if shipment.Status == ItemStatusDelivered {
+		return nil, ErrAlreadyDelivered
+	}
+
+	items := make([]OrderItem, len(o.Items))
+	copy(items, o.Items)
+	for _, productID := range shipment.ProductIDs {
+		if item := findItem(items, productID); item != nil {
+			item.Status = ItemStatusDelivered
+		}
+	}
+
+	shipment.Status = ItemStatusDelivered
+	shipment.DeliveredAt = &now
+	o.Items = items
+	o.Shipments = shipments
+	o.refreshEstimatedDelivery()
+	o.UpdatedAt = now
+	return shipment, nil
+}
+
+// MarkItems sets every physical item to status without recording a shipment, for orders
+// shipped or delivered in one go through a status change
+func (o *Order) MarkItems(status ItemStatus) {
+	items := make([]OrderItem, len(o.Items))
+	copy(items, o.Items)
+	for i := range items {
+		if !items[i].Digital && items[i].Status != ItemStatusDelivered {
+			items[i].Status = status
+		}
+	}
+	o.Items = items
+}
+
+// HasShippedItems reports whether any of the order's items have left the warehouse
+func (o *Order) HasShippedItems() bool {
+	for _, item := range o.Items {
+		if item.Status == ItemStatusShipped || item.Status == ItemStatusDelivered {
+			return true
+		}
+	}
+	return false
+}
+
+// ShipmentStatus derives the order status from its items: delivered once every physical item
+// has been delivered, shipped once every one has at least shipped, and empty while some are
+// still waiting (the order keeps its current status)
+func (o *Order) ShipmentStatus() OrderStatus {
+	shipped, delivered, physical := 0, 0, 0
+	for _, item := range o.Items {
+		if item.Digital {
+			continue
+		}
+		physical++
+		switch item.Status {
+		case ItemStatusDelivered:
+			delivered++
+			shipped++
+		case ItemStatusShipped:
+			shipped++
+		}
+	}
+	switch {
+	case physical == 0 || shipped < physical:
+		return ""
+	case delivered == physical:
+		return OrderStatusDelivered
+	default:
+		return OrderStatusShipped
+	}
+}
+
+// findItem returns the item for productID in items, or nil
+func findItem(items []OrderItem, productID string) *OrderItem {
+	for i := range items {
+		if items[i].ProductID == productID {
+			return &items[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package models
+
+// ShippingMethod is how fast an order is shipped
+type ShippingMethod string
+
+// Shipping methods
+const (
+	ShippingStandard ShippingMethod = "standard"
+	ShippingExpress  ShippingMethod = "express"
+)
+
+// IsValidShippingMethod checks if a method is one of the known shipping methods
+func IsValidShippingMethod(method ShippingMethod) bool {
+	return method == ShippingStandard || method == ShippingExpress
+}
+
+// NeedsShipping reports whether the order has physical items to ship
+func (o *Order) NeedsShipping() bool {
+	for _, item := range o.Items {
+		if !item.Digital {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package models
+
+import "sort"
+
+// TopProductsLimit is how many of a user's most-purchased products their stats list
+const TopProductsLimit = 5
+
+// UserOrderStats summarises what a user has bought. Only purchased orders count; pending, held,
+// and cancelled orders are left out.
+type UserOrderStats struct {
+	UserID            string             `json:"user_id"`
+	OrderCount        int                `json:"order_count"`
+	LifetimeSpend     float64            `json:"lifetime_spend"` // the orders' totals, as paid
+	AverageOrderValue float64            `json:"average_order_value"`
+	TopProducts       []ProductPurchases `json:"top_products"` // most units bought first
+}
+
+// ProductPurchases is how much of one product a user has bought
+type ProductPurchases struct {
+	ProductID   string `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Quantity    int    `json:"quantity"`    // units bought across all orders
+	OrderCount  int    `json:"order_count"` // orders it was bought in
+}
+
+// ComputeUserOrderStats works out a user's stats from their orders
+func ComputeUserOrderStats(userID string, orders []*Order) *UserOrderStats {
+	stats := &UserOrderStats{UserID: userID, TopProducts: []ProductPurchases{}}
+	products := make(map[string]*ProductPurchases)
+	for _, order := range orders {
+		if !order.IsPurchased() {
+			continue
+		}
+		stats.OrderCount++
+		stats.LifetimeSpend += order.Total
+
+		counted := make(map[string]bool)
+		for _, item := range order.Items {
+			product, exists := products[item.ProductID]
+			if !exists {
+				product = &ProductPurchases{ProductID: item.ProductID, ProductName: item.ProductName}
+				products[item.ProductID] = product
+			}
+			product.Quantity += item.Quantity
+			if !counted[item.ProductID] {
+				product.OrderCount++
+				counted[item.ProductID] = true
+			}
+		}
+	}
+
+	stats.LifetimeSpend = RoundCents(stats.LifetimeSpend)
+	if stats.OrderCount > 0 {
+		stats.AverageOrderValue = RoundCents(stats.LifetimeSpend / float64(stats.OrderCount))
+	}
+
+	for _, product := range products {
+		stats.TopProducts = append(stats.TopProducts, *product)
+	}
+	// Product IDs break ties so the list is stable
+	sort.Slice(stats.TopProducts, func(i, j int) bool {
+		if stats.TopProducts[i].Quantity != stats.TopProducts[j].Quantity {
+			return stats.TopProducts[i].Quantity > stats.TopProducts[j].Quantity
+		}
+		return stats.TopProducts[i].ProductID < stats.TopProducts[j].ProductID
+	})
+	if len(stats.TopProducts) > TopProductsLimit {
+		stats.TopProducts = stats.TopProducts[:TopProductsLimit]
+	}
+	return stats
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// StatusChange is one entry in an order's status timeline
+type StatusChange struct {
+	From  OrderStatus `json:"from,omitempty"` // empty for the entry recording the order's creation
+	To    OrderStatus `json:"to"`
+	Actor string      `json:"actor"` // user who placed the order, or the service or person that changed it
+	Note  string      `json:"note,omitempty"`
+	At    time.Time   `json:"at"`
+}
+
+// ChangeStatus moves the order to status and records the change in its status history
+func (o *Order) ChangeStatus(status OrderStatus, actor, note string) {
+	from := o.Status
+	o.UpdateStatus(status)
+	if status == OrderStatusPending {
+		o.PendingSince = o.UpdatedAt
+	}
+	history := make([]StatusChange, len(o.StatusHistory), len(o.StatusHistory)+1)
+	copy(history, o.StatusHistory)
+	o.StatusHistory = append(history, StatusChange{
+		From:  from,
+		To:    status,
+		Actor: actor,
+		Note:  note,
+		At:    o.UpdatedAt,
+	})
+}
+
+// PendingBefore reports whether the order became pending before cutoff. Orders saved before
+// PendingSince was recorded count from when they were placed.
+func (o *Order) PendingBefore(cutoff time.Time) bool {
+	since := o.PendingSince
+	if since.IsZero() {
+		since = o.CreatedAt
+	}
+	return since.Before(cutoff)
+}
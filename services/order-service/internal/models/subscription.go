@@ -0,0 +1,176 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"github.com/google/uuid"
+)
+
+// SubscriptionInterval is how often a subscription places an order
+type SubscriptionInterval string
+
+const (
+	IntervalDaily   SubscriptionInterval = "daily"
+	IntervalWeekly  SubscriptionInterval = "weekly"
+	IntervalMonthly SubscriptionInterval = "monthly"
+)
+
+// IsValidSubscriptionInterval checks if an interval is one subscriptions can run on
+func IsValidSubscriptionInterval(interval SubscriptionInterval) bool {
+	return interval == IntervalDaily || interval == IntervalWeekly || interval == IntervalMonthly
+}
+
+// After returns the time one interval after from
+func (i SubscriptionInterval) After(from time.Time) time.Time {
+	switch i {
+	case IntervalDaily:
+		return from.AddDate(0, 0, 1)
+	case IntervalWeekly:
+		return from.AddDate(0, 0, 7)
+	default:
+		return from.AddDate(0, 1, 0)
+	}
+}
+
+// SubscriptionStatus represents the state of a subscription
+type SubscriptionStatus string
+
+const (
+	SubscriptionActive    SubscriptionStatus = "active"
+	SubscriptionPaused    SubscriptionStatus = "paused"
+	SubscriptionCancelled SubscriptionStatus = "cancelled"
+)
+
+// Subscription errors
+var (
+	ErrSubscriptionCancelled = errors.New("subscription is cancelled")
+	ErrSubscriptionNotActive = errors.New("subscription is not active")
+	ErrSubscriptionNotPaused = errors.New("subscription is not paused")
+)
+
+// SubscriptionMetadataKey names the subscription in the metadata of the orders it places
+const SubscriptionMetadataKey = "subscription_id"
+
+// Subscription places the same order for a user every interval, charged to a saved payment method
+type Subscription struct {
+	ID                string               `json:"id"`
+	UserID            string               `json:"user_id"`
+	Items             []CreateOrderItem    `json:"items"`
+	ShippingAddressID string               `json:"shipping_address_id,omitempty"` // the user's default address when empty
+	ShippingMethod    ShippingMethod       `json:"shipping_method"`
+	PaymentMethod     string               `json:"payment_method"`
+	Interval          SubscriptionInterval `json:"interval"`
+	Status            SubscriptionStatus   `json:"status"`
+	NextRunAt         time.Time            `json:"next_run_at"` // when the next order is due; not meaningful once cancelled
+	LastRunAt         *time.Time           `json:"last_run_at,omitempty"`
+	LastOrderID       string               `json:"last_order_id,omitempty"` // the most recent order placed
+	LastError         string               `json:"last_error,omitempty"`    // why the last cycle's order couldn't be placed
+	CreatedAt         time.Time            `json:"created_at"`
+	UpdatedAt         time.Time            `json:"updated_at"`
+}
+
+// CreateSubscriptionRequest represents the request payload for subscribing to recurring orders
+type CreateSubscriptionRequest struct {
+	UserID            string               `json:"user_id" validate:"required"`
+	Items             []CreateOrderItem    `json:"items" validate:"required,min=1,dive"`
+	ShippingAddressID string               `json:"shipping_address_id,omitempty"`
+	ShippingMethod    ShippingMethod       `json:"shipping_method,omitempty"` // standard when empty
+	PaymentMethod     string               `json:"payment_method" validate:"required"`
+	Interval          SubscriptionInterval `json:"interval" validate:"required"`
+	// StartAt is when the first order is placed; the first one is placed right away when empty
+	StartAt *time.Time `json:"start_at,omitempty"`
+}
+
+// NewSubscription creates an active subscription from a validated request
+func NewSubscription(req *CreateSubscriptionRequest, now time.Time) *Subscription {
+	nextRun := now
+	if req.StartAt != nil && req.StartAt.After(now) {
+		nextRun = *req.StartAt
+	}
+	return &Subscription{
+		ID:                uuid.New().String(),
+		UserID:            req.UserID,
+		Items:             req.Items,
+		ShippingAddressID: req.ShippingAddressID,
+		ShippingMethod:    req.ShippingMethod,
+		PaymentMethod:     req.PaymentMethod,
+		Interval:          req.Interval,
+		Status:            SubscriptionActive,
+		NextRunAt:         nextRun,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+}
+
+// OrderRequest describes the order placed on each cycle, tagged with the subscription's ID
+func (s *Subscription) OrderRequest() *CreateOrderRequest {
+	return &CreateOrderRequest{
+		UserID:            s.UserID,
+		Items:             append([]CreateOrderItem{}, s.Items...),
+		ShippingAddressID: s.ShippingAddressID,
+		ShippingMethod:    s.ShippingMethod,
+		PaymentMethod:     s.PaymentMethod,
+		Metadata:          map[string]string{SubscriptionMetadataKey: s.ID},
+	}
+}
+
+// RecordRun notes the outcome of a cycle and schedules the next one. Cycles missed while the
+// scheduler was down are skipped rather than placed all at once.
+func (s *Subscription) RecordRun(orderID string, runErr error, now time.Time) {
+	s.LastRunAt = &now
+	s.LastError = ""
+	if runErr != nil {
+		s.LastError = runErr.Error()
+	} else {
+		s.LastOrderID = orderID
+	}
+	s.advance(now)
+	s.UpdatedAt = now
+}
+
+// Pause stops the subscription placing orders until it is resumed
+func (s *Subscription) Pause(now time.Time) error {
+	if s.Status != SubscriptionActive {
+		return s.statusError(ErrSubscriptionNotActive)
+	}
+	s.Status = SubscriptionPaused
+	s.UpdatedAt = now
+	return nil
+}
+
+// Resume restarts a paused subscription. Cycles that fell due while it was paused are skipped.
+func (s *Subscription) Resume(now time.Time) error {
+	if s.Status != SubscriptionPaused {
+		return s.statusError(ErrSubscriptionNotPaused)
+	}
+	s.Status = SubscriptionActive
+	s.advance(now)
+	s.UpdatedAt = now
+	return nil
+}
+
+// Cancel ends the subscription for good
+func (s *Subscription) Cancel(now time.Time) error {
+	if s.Status == SubscriptionCancelled {
+		return ErrSubscriptionCancelled
+	}
+	s.Status = SubscriptionCancelled
+	s.UpdatedAt = now
+	return nil
+}
+
+// advance moves NextRunAt to the first cycle after now
+func (s *Subscription) advance(now time.Time) {
+	for !s.NextRunAt.After(now) {
+		s.NextRunAt = s.Interval.After(s.NextRunAt)
+	}
+}
+
+// statusError reports a cancelled subscription as such, and any other wrong state as err
+func (s *Subscription) statusError(err error) error {
+	if s.Status == SubscriptionCancelled {
+		return ErrSubscriptionCancelled
+	}
+	return fmt.Errorf("%w; it is %s", err, s.Status)
+}
@@ -0,0 +1,37 @@
+package models
+
+import "math"
+
+// RoundCents rounds an amount to whole cents
+func RoundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// ApplyTax sets the order's tax and recomputes its total
+func (o *Order) ApplyTax(tax float64) {
+	o.Tax = RoundCents(tax)
+	o.updateTotal()
+}
+
+// ApplyDiscount records the coupon applied to the order and the amount it takes off, and recomputes its total
+func (o *Order) ApplyDiscount(couponCode string, discount float64) {
+	o.CouponCode = couponCode
+	o.Discount = RoundCents(discount)
+	o.updateTotal()
+}
+
+// DiscountedSubtotal is the subtotal less any discount; tax is charged on this amount
+func (o *Order) DiscountedSubtotal() float64 {
+	return RoundCents(o.Subtotal - o.Discount)
+}
+
+// ApplyShipping sets how the order ships and what that costs, and recomputes its total
+func (o *Order) ApplyShipping(method ShippingMethod, cost float64) {
+	o.ShippingMethod = method
+	o.ShippingCost = RoundCents(cost)
+	o.updateTotal()
+}
+
+func (o *Order) updateTotal() {
+	o.Total = RoundCents(o.Subtotal - o.Discount + o.ShippingCost + o.Tax - o.PointsCredit)
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// TrackingStatus is where a carrier reports a parcel to be
+type TrackingStatus string
+
+// Carrier tracking states
+const (
+	TrackingPending   TrackingStatus = "pending" // the carrier has the label but not the parcel
+	TrackingInTransit TrackingStatus = "in_transit"
+	TrackingDelivered TrackingStatus = "delivered"
+	TrackingException TrackingStatus = "exception" // delayed, failed delivery attempt, or returned
+)
+
+// ErrAmbiguousShipment is returned when a tracking update names no shipment and the order has several
+var ErrAmbiguousShipment = errors.New("order has several shipments; shipment_id is required")
+
+// UpdateTrackingRequest represents the request payload for updating a shipment's tracking details.
+// Fields left empty keep their current value.
+type UpdateTrackingRequest struct {
+	// ShipmentID picks the shipment; it may be left out when the order has only one
+	ShipmentID        string     `json:"shipment_id,omitempty"`
+	Carrier           string     `json:"carrier,omitempty"`
+	TrackingNumber    string     `json:"tracking_number,omitempty"`
+	EstimatedDelivery *time.Time `json:"estimated_delivery,omitempty"`
+}
+
+// TrackingReportRequest represents the request payload for what a carrier reports about a parcel,
+// sent by the service that tracks it
+type TrackingReportRequest struct {
+	Status            TrackingStatus `json:"status" validate:"required,oneof=pending in_transit delivered exception"`
+	EstimatedDelivery *time.Time     `json:"estimated_delivery,omitempty"`
+	DeliveredAt       *time.Time     `json:"delivered_at,omitempty"` // when it arrived; now when left out
+}
+
+// TrackingUpdate is a change to a shipment's tracking details; empty fields are left as they are
+type TrackingUpdate struct {
+	Carrier           string
+	TrackingNumber    string
+	Status            TrackingStatus
+	EstimatedDelivery *time.Time
+}
+
+// FindShipment returns the shipment with the given ID, or the order's only shipment when shipmentID is empty
+func (o *Order) FindShipment(shipmentID string) (*Shipment, error) {
+	if shipmentID == "" {
+		switch len(o.Shipments) {
+		case 0:
+			return nil, ErrShipmentNotFound
+		case 1:
+			return &o.Shipments[0], nil
+		default:
+			return nil, ErrAmbiguousShipment
+		}
+	}
+	for i := range o.Shipments {
+		if o.Shipments[i].ID == shipmentID {
+			return &o.Shipments[i], nil
+		}
+	}
+	return nil, ErrShipmentNotFound
+}
+
+// UpdateTracking applies a tracking update to one of the order's shipments and returns it
+func (o *Order) UpdateTracking(shipmentID string, update TrackingUpdate, now time.Time) (*Shipment, error) {
+	shipments := make([]Shipment, len(o.Shipments))
+	copy(shipments, o.Shipments)
+
+	var shipment *Shipment
+	for i := range shipments {
+		if shipments[i].ID == shipmentID {
+			shipment = &shipments[i]
+			break
+		}
+	}
+	if shipment == nil {
+		return nil, ErrShipmentNotFound
+	}
+
+	if update.Carrier != "" {
+		shipment.Carrier = update.Carrier
+	}
+	if update.TrackingNumber != "" {
+		shipment.TrackingNumber = update.TrackingNumber
+	}
+	if update.Status != "" {
+		shipment.TrackingStatus = update.Status
+		shipment.TrackedAt = &now
+	}
+	if update.EstimatedDelivery != nil {
+		shipment.EstimatedDelivery = update.EstimatedDelivery
+	}
+
+	o.Shipments = shipments
+	o.refreshEstimatedDelivery()
+	o.UpdatedAt = now
+	return shipment, nil
+}
+
+// refreshEstimatedDelivery sets the order's estimated delivery to the latest estimate of its parcels
+// still on their way; it is cleared once nothing with an estimate is outstanding
+func (o *Order) refreshEstimatedDelivery() {
+	var latest *time.Time
+	for _, shipment := range o.Shipments {
+		if shipment.Status == ItemStatusDelivered || shipment.EstimatedDelivery == nil {
+			continue
+		}
+		if latest == nil || shipment.EstimatedDelivery.After(*latest) {
+			latest = shipment.EstimatedDelivery
+		}
+	}
+	o.EstimatedDelivery = latest
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// orderTransitions lists the statuses an order may move to from each status. Orders go
+// pending → confirmed → shipped → delivered and can be cancelled until they ship. Orders held
+// by fraud screening start in review, and become pending if approved or cancelled if rejected.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusReview:    {OrderStatusPending, OrderStatusCancelled},
+	OrderStatusPending:   {OrderStatusConfirmed, OrderStatusCancelled},
+	OrderStatusConfirmed: {OrderStatusShipped, OrderStatusCancelled},
+	OrderStatusShipped:   {OrderStatusDelivered},
+	OrderStatusDelivered: {},
+	OrderStatusCancelled: {},
+}
+
+// TransitionError reports a status change the order state machine does not allow
+type TransitionError struct {
+	From    OrderStatus   `json:"from"`
+	To      OrderStatus   `json:"to"`
+	Allowed []OrderStatus `json:"allowed"` // statuses the order can move to instead
+}
+
+func (e *TransitionError) Error() string {
+	if len(e.Allowed) == 0 {
+		return fmt.Sprintf("order cannot move from %s to %s; %s is a final status", e.From, e.To, e.From)
+	}
+	allowed := make([]string, len(e.Allowed))
+	for i, status := range e.Allowed {
+		allowed[i] = string(status)
+	}
+	return fmt.Sprintf("order cannot move from %s to %s; it can only move to %s", e.From, e.To, strings.Join(allowed, " or "))
+}
+
+// CheckTransition returns a *TransitionError if the order may not move to status
+func (o *Order) CheckTransition(status OrderStatus) error {
+	allowed := orderTransitions[o.Status]
+	for _, next := range allowed {
+		if next == status {
+			return nil
+		}
+	}
+	return &TransitionError{From: o.Status, To: status, Allowed: append([]OrderStatus{}, allowed...)}
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+	"github.com/google/uuid"
+)
+
+// Order lifecycle events delivered to webhook subscribers
+const (
+	EventOrderCreated       = "order.created"
+	EventOrderStatusChanged = "order.status.changed"
+	EventOrderCancelled     = "order.cancelled" // follows the order.status.changed of a cancellation
+)
+
+// IsValidWebhookEvent checks if an event type is one subscribers can ask for
+func IsValidWebhookEvent(event string) bool {
+	return event == EventOrderCreated || event == EventOrderStatusChanged || event == EventOrderCancelled
+}
+
+// WebhookSubscription registers a URL to receive order events. The secret signs every delivery
+// and is only shown when the subscription is created.
+type WebhookSubscription struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewWebhookSubscription creates a subscription with a generated ID
+func NewWebhookSubscription(url string, events []string, secret string) *WebhookSubscription {
+	return &WebhookSubscription{
+		ID:        uuid.New().String(),
+		URL:       url,
+		Events:    events,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+}
+
+// Wants reports whether the subscription receives events of the given type
+func (s *WebhookSubscription) Wants(event string) bool {
+	for _, subscribed := range s.Events {
+		if subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWebhookRequest represents the request payload for subscribing to order events
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,url"`
+	Events []string `json:"events" validate:"required,min=1"`
+}
+
+// WebhookSubscriptionResponse is returned once, when a subscription is created, with its signing secret
+type WebhookSubscriptionResponse struct {
+	WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+// WebhookEvent is the body POSTed to subscribers
+type WebhookEvent struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// WebhookDelivery records one attempt to deliver an event to a subscription
+type WebhookDelivery struct {
+	ID             string    `json:"id"`
+	SubscriptionID string    `json:"subscription_id"`
+	EventID        string    `json:"event_id"`
+	EventType      string    `json:"event_type"`
+	Attempt        int       `json:"attempt"`
+	Success        bool      `json:"success"`
+	StatusCode     int       `json:"status_code,omitempty"` // the subscriber's response code; 0 if it couldn't be reached
+	Error          string    `json:"error,omitempty"`
+	AttemptedAt    time.Time `json:"attempted_at"`
+}
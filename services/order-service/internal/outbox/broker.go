@@ -0,0 +1,45 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"ecommerce/pkg/events"
+	"order-service/internal/models"
+)
+
+// Broker is the message broker order events are published to
+type Broker interface {
+	Publish(event *models.OrderEvent) error
+}
+
+// LogBroker writes events to the service log. It stands in for a broker in development.
+type LogBroker struct{}
+
+// Publish logs the event
+func (LogBroker) Publish(event *models.OrderEvent) error {
+	slog.Info("Order event", "event_id", event.ID, "type", event.Type, "order_id", event.OrderID)
+	return nil
+}
+
+// KafkaRESTBroker publishes events to a Kafka topic through a Kafka REST Proxy. Records are keyed
+// by order ID so each order's events stay in order on one partition.
+type KafkaRESTBroker struct {
+	kafka *events.KafkaREST
+	topic string
+}
+
+// NewKafkaRESTBroker creates a broker that produces to topic through the REST Proxy at baseURL
+func NewKafkaRESTBroker(baseURL, topic string) *KafkaRESTBroker {
+	return &KafkaRESTBroker{
+		kafka: events.NewKafkaREST(baseURL),
+		topic: topic,
+	}
+}
+
+// Publish produces the event to the topic
+func (b *KafkaRESTBroker) Publish(event *models.OrderEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return b.kafka.Publish(ctx, b.topic, event.OrderID, event)
+}
@@ -0,0 +1,79 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"order-service/internal/models"
+	"order-service/internal/repository"
+)
+
+// batchSize is how many outbox events one relay pass reads at most
+const batchSize = 100
+
+// Listener is told about each event once the broker has accepted it
+type Listener interface {
+	Publish(event *models.OrderEvent)
+}
+
+// Relay moves events from the outbox to the broker. An event leaves the outbox only after the broker
+// has accepted it, so events survive a crash and may be published more than once; consumers should
+// discard duplicates by event ID.
+type Relay struct {
+	store     repository.OutboxRepository
+	broker    Broker
+	listeners []Listener
+}
+
+// NewRelay creates a relay publishing to broker. Published events are also passed to each of
+// listeners, such as webhooks, in order.
+func NewRelay(store repository.OutboxRepository, broker Broker, listeners ...Listener) *Relay {
+	return &Relay{
+		store:     store,
+		broker:    broker,
+		listeners: listeners,
+	}
+}
+
+// PublishPending publishes waiting events, oldest first, and reports how many went out. It stops at
+// the first event the broker refuses so later events don't overtake it; that event is retried on the
+// next pass.
+func (r *Relay) PublishPending() (int, error) {
+	events, err := r.store.PendingEvents(batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, event := range events {
+		if err := r.broker.Publish(event); err != nil {
+			if markErr := r.store.MarkFailed(event.ID, err); markErr != nil {
+				slog.Error("Recording failed publish of event", "event_id", event.ID, "error", markErr)
+			}
+			return published, fmt.Errorf("event %s: %w", event.ID, err)
+		}
+		if err := r.store.MarkPublished(event.ID); err != nil {
+			return published, fmt.Errorf("event %s: %w", event.ID, err)
+		}
+		published++
+
+		for _, listener := range r.listeners {
+			listener.Publish(event)
+		}
+	}
+	return published, nil
+}
+
+// Drain publishes waiting events batch after batch until none are left, the broker refuses one, or
+// ctx is done. Events still waiting stay in the outbox, to go out once the service is back.
+func (r *Relay) Drain(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		published, err := r.PublishPending()
+		if err != nil || published == 0 {
+			return err
+		}
+	}
+}
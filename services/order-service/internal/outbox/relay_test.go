@@ -0,0 +1,132 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"order-service/internal/models"
+	"order-service/internal/repository"
+)
+
+type mockBroker struct {
+	published []string
+	failOn    string
+}
+
+func (m *mockBroker) Publish(event *models.OrderEvent) error {
+	if event.ID == m.failOn {
+		return errors.New("broker unavailable")
+	}
+	m.published = append(m.published, event.ID)
+	return nil
+}
+
+type mockPublisher struct {
+	events []*models.OrderEvent
+}
+
+func (m *mockPublisher) Publish(event *models.OrderEvent) {
+	m.events = append(m.events, event)
+}
+
+func TestRelay_PublishPending(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	order := models.NewOrder("u1", []models.OrderItem{{ProductID: "p1", Quantity: 1}})
+	order.RecordEvent(models.EventOrderCreated, "")
+	order.RecordEvent(models.EventOrderStatusChanged, models.OrderStatusPending)
+	order.RecordEvent(models.EventOrderStatusChanged, models.OrderStatusConfirmed)
+	ids := []string{order.Events[0].ID, order.Events[1].ID, order.Events[2].ID}
+	_ = repo.Create(context.Background(), order)
+
+	broker := &mockBroker{failOn: ids[1]}
+	webhooks := &mockPublisher{}
+	relay := NewRelay(repo, broker, webhooks)
+
+	published, err := relay.PublishPending()
+	if err == nil || published != 1 {
+		t.Fatalf("expected the relay to stop at the refused event, got %d %v", published, err)
+	}
+	pending, _ := repo.PendingEvents(0)
+	if len(pending) != 2 || pending[0].ID != ids[1] || pending[0].Attempts != 1 {
+		t.Fatalf("expected the refused event and the one after it to stay, got %+v", pending)
+	}
+
+	broker.failOn = ""
+	if published, err := relay.PublishPending(); err != nil || published != 2 {
+		t.Fatalf("expected the rest to go out, got %d %v", published, err)
+	}
+	if len(broker.published) != 3 || broker.published[1] != ids[1] || broker.published[2] != ids[2] {
+		t.Fatalf("expected events in order, got %v", broker.published)
+	}
+	if len(webhooks.events) != 3 {
+		t.Errorf("expected each published event to go to webhooks, got %d", len(webhooks.events))
+	}
+	if pending, _ := repo.PendingEvents(0); len(pending) != 0 {
+		t.Errorf("expected an empty outbox, got %d events", len(pending))
+	}
+}
+
+func TestRelay_DrainEmptiesTheOutbox(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	order := models.NewOrder("u1", []models.OrderItem{{ProductID: "p1", Quantity: 1}})
+	for i := 0; i < batchSize+50; i++ {
+		order.RecordEvent(models.EventOrderStatusChanged, models.OrderStatusPending)
+	}
+	_ = repo.Create(context.Background(), order)
+	broker := &mockBroker{}
+	relay := NewRelay(repo, broker)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := relay.Drain(cancelled); err == nil || len(broker.published) != 0 {
+		t.Fatalf("expected nothing published past the deadline, got %d %v", len(broker.published), err)
+	}
+
+	if err := relay.Drain(context.Background()); err != nil || len(broker.published) != batchSize+50 {
+		t.Fatalf("expected every event published across batches, got %d %v", len(broker.published), err)
+	}
+	if pending, _ := repo.PendingEvents(0); len(pending) != 0 {
+		t.Errorf("expected an empty outbox, got %d events", len(pending))
+	}
+}
+
+func TestKafkaRESTBroker_Publish(t *testing.T) {
+	var received struct {
+		Records []struct {
+			Key   string            `json:"key"`
+			Value models.OrderEvent `json:"value"`
+		} `json:"records"`
+	}
+	var contentType, path string
+	reject := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType, path = r.Header.Get("Content-Type"), r.URL.Path
+		json.NewDecoder(r.Body).Decode(&received)
+		if reject {
+			w.Write([]byte(`{"offsets":[{"partition":null,"offset":null,"error_code":50002,"error":"Kafka error"}]}`))
+			return
+		}
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":7}]}`))
+	}))
+	defer server.Close()
+
+	broker := NewKafkaRESTBroker(server.URL+"/", "order-events")
+	event := &models.OrderEvent{ID: "e1", Type: models.EventOrderCreated, OrderID: "o1"}
+	if err := broker.Publish(event); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	if path != "/topics/order-events" || contentType != "application/vnd.kafka.json.v2+json" {
+		t.Fatalf("unexpected request to %s with %s", path, contentType)
+	}
+	if len(received.Records) != 1 || received.Records[0].Key != "o1" || received.Records[0].Value.ID != "e1" {
+		t.Fatalf("unexpected records %+v", received.Records)
+	}
+
+	reject = true
+	if err := broker.Publish(event); err == nil {
+		t.Error("expected an error when the proxy rejects the record")
+	}
+}
@@ -0,0 +1,49 @@
+package payment
+
+import (
+	"encoding/json"
+	"order-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Payment methods the mock provider treats specially; any other method is charged successfully
+const (
+	MockDeclinedMethod = "pm_card_declined"
+	MockPendingMethod  = "pm_card_pending"
+)
+
+// MockProvider settles payments without a payment processor, for development and tests.
+// Its webhooks are unsigned models.PaymentEvent JSON, so it must not be used in production.
+type MockProvider struct{}
+
+// NewMockProvider creates a mock payment provider
+func NewMockProvider() *MockProvider {
+	return &MockProvider{}
+}
+
+// Charge succeeds unless the payment method is MockDeclinedMethod; MockPendingMethod leaves the
+// payment pending until a webhook settles it
+func (p *MockProvider) Charge(request ChargeRequest) (*models.PaymentResult, error) {
+	switch request.PaymentMethod {
+	case MockDeclinedMethod:
+		return nil, ErrDeclined
+	case MockPendingMethod:
+		return &models.PaymentResult{PaymentID: "mock_" + uuid.New().String(), Status: models.PaymentPending}, nil
+	}
+	return &models.PaymentResult{PaymentID: "mock_" + uuid.New().String(), Status: models.PaymentPaid}, nil
+}
+
+// Refund always succeeds
+func (p *MockProvider) Refund(paymentID string) error {
+	return nil
+}
+
+// ParseWebhook decodes a models.PaymentEvent; the signature is ignored
+func (p *MockProvider) ParseWebhook(payload []byte, signature string) (*models.PaymentEvent, error) {
+	var event models.PaymentEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
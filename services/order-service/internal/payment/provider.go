@@ -0,0 +1,34 @@
+package payment
+
+import (
+	"errors"
+	"order-service/internal/models"
+)
+
+// PaymentProvider takes and refunds payments for orders through a payment processor.
+// Implemented by MockProvider and StripeProvider.
+type PaymentProvider interface {
+	// Charge takes amount from the customer's payment method. A declined payment returns ErrDeclined.
+	Charge(request ChargeRequest) (*models.PaymentResult, error)
+	Refund(paymentID string) error
+	// ParseWebhook verifies a provider notification and returns the payment event it reports,
+	// or nil for notifications that don't settle a payment
+	ParseWebhook(payload []byte, signature string) (*models.PaymentEvent, error)
+}
+
+// ChargeRequest describes a payment to take for an order
+type ChargeRequest struct {
+	OrderID       string
+	UserID        string
+	Amount        float64
+	Currency      string
+	PaymentMethod string
+	// IdempotencyKey names this attempt at paying for the order; asking again with it takes nothing more
+	IdempotencyKey string
+}
+
+// Payment errors
+var (
+	ErrDeclined         = errors.New("payment was declined")
+	ErrInvalidSignature = errors.New("webhook signature is invalid")
+)
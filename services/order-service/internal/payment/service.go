@@ -0,0 +1,136 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"ecommerce/pkg/api"
+	"ecommerce/pkg/auth"
+	"order-service/internal/models"
+)
+
+// ErrSettledByEvents is returned for webhooks when payment service takes the provider's notifications
+var ErrSettledByEvents = errors.New("payment notifications go to payment service; settled payments arrive as payment events")
+
+// ServiceProvider takes payments through payment service, which owns the provider. Payments it
+// settles later are reported as payment events rather than webhooks to this service.
+type ServiceProvider struct {
+	httpClient *http.Client
+	baseURL    string
+	serviceKey string
+}
+
+// NewServiceProvider creates a provider calling payment service at baseURL with serviceKey
+func NewServiceProvider(baseURL, serviceKey string) *ServiceProvider {
+	return &ServiceProvider{
+		httpClient: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		serviceKey: serviceKey,
+	}
+}
+
+// UseTLS makes the provider call payment service over TLS with config, presenting this service's
+// certificate; payment service's URL must then be https. Call it before the provider is used.
+func (p *ServiceProvider) UseTLS(config *tls.Config) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	p.httpClient.Transport = transport
+}
+
+// servicePayment is the part of a payment service payment the provider needs
+type servicePayment struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// Charge takes the payment with payment service, captured at once
+func (p *ServiceProvider) Charge(request ChargeRequest) (*models.PaymentResult, error) {
+	body := map[string]interface{}{
+		"order_id":       request.OrderID,
+		"user_id":        request.UserID,
+		"amount":         request.Amount,
+		"currency":       request.Currency,
+		"payment_method": request.PaymentMethod,
+	}
+	var payment servicePayment
+	status, err := p.post("/v1/payments", request.IdempotencyKey, body, &payment)
+	switch {
+	case err != nil:
+		return nil, err
+	case status == http.StatusPaymentRequired:
+		return nil, ErrDeclined
+	case status != http.StatusCreated:
+		return nil, fmt.Errorf("payment service returned status %d", status)
+	}
+
+	result := &models.PaymentResult{PaymentID: payment.ID, Status: models.PaymentPending}
+	if models.PaymentStatus(payment.Status) == models.PaymentPaid {
+		result.Status = models.PaymentPaid
+	}
+	return result, nil
+}
+
+// Refund refunds what is left of the payment with payment service
+func (p *ServiceProvider) Refund(paymentID string) error {
+	status, err := p.post("/v1/payments/"+url.PathEscape(paymentID)+"/refund", "", map[string]interface{}{}, nil)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("payment service returned status %d", status)
+	}
+	return nil
+}
+
+// ParseWebhook refuses notifications, which go to payment service
+func (p *ServiceProvider) ParseWebhook(payload []byte, signature string) (*models.PaymentEvent, error) {
+	return nil, ErrSettledByEvents
+}
+
+// post sends body to payment service, with idempotencyKey unless it is empty, and returns the
+// response status, decoding a successful response's data into out (which may be nil)
+func (p *ServiceProvider) post(path, idempotencyKey string, body interface{}, out interface{}) (int, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.httpClient.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(auth.ServiceKeyHeader, p.serviceKey)
+	if idempotencyKey != "" {
+		req.Header.Set(api.IdempotencyKeyHeader, idempotencyKey)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call payment service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 300 && out != nil {
+		var envelope struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode payment service response: %w", err)
+		}
+		if err := json.Unmarshal(envelope.Data, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode payment service response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,77 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+	"order-service/internal/models"
+)
+
+func TestStripeProvider_Charge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/payment_intents" || r.Header.Get("Authorization") != "Bearer sk_test" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		r.ParseForm()
+		if r.PostForm.Get("payment_method") == "pm_card_chargeDeclined" {
+			w.WriteHeader(http.StatusPaymentRequired)
+			w.Write([]byte(`{"error":{"type":"card_error","code":"card_declined","message":"Your card was declined."}}`))
+			return
+		}
+		if r.PostForm.Get("amount") != "1999" || r.PostForm.Get("currency") != "usd" || r.PostForm.Get("metadata[order_id]") != "o1" {
+			t.Errorf("unexpected form %v", r.PostForm)
+		}
+		w.Write([]byte(`{"id":"pi_123","status":"succeeded"}`))
+	}))
+	defer server.Close()
+
+	provider := NewStripeProvider("sk_test", "whsec")
+	provider.apiURL = server.URL
+
+	request := ChargeRequest{OrderID: "o1", UserID: "u1", Amount: 19.99, Currency: "USD", PaymentMethod: "pm_card_visa"}
+	result, err := provider.Charge(request)
+	if err != nil || result.PaymentID != "pi_123" || result.Status != models.PaymentPaid {
+		t.Fatalf("expected a paid intent, got %+v (%v)", result, err)
+	}
+
+	request.PaymentMethod = "pm_card_chargeDeclined"
+	if _, err := provider.Charge(request); !errors.Is(err, ErrDeclined) {
+		t.Fatalf("expected ErrDeclined, got %v", err)
+	}
+}
+
+func TestStripeProvider_ParseWebhook(t *testing.T) {
+	provider := NewStripeProvider("sk_test", "whsec")
+	payload := []byte(`{"type":"payment_intent.succeeded","data":{"object":{"id":"pi_123","status":"succeeded","metadata":{"order_id":"o1"}}}}`)
+
+	sign := func(secret string, body []byte, at time.Time) string {
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	event, err := provider.ParseWebhook(payload, sign("whsec", payload, time.Now()))
+	if err != nil || event.PaymentID != "pi_123" || event.OrderID != "o1" || event.Status != models.PaymentPaid {
+		t.Fatalf("expected a paid event for o1, got %+v (%v)", event, err)
+	}
+
+	if _, err := provider.ParseWebhook(payload, sign("other", payload, time.Now())); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected a wrong secret to be rejected, got %v", err)
+	}
+	if _, err := provider.ParseWebhook(payload, sign("whsec", payload, time.Now().Add(-time.Hour))); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected a stale signature to be rejected, got %v", err)
+	}
+
+	other := []byte(`{"type":"charge.refunded","data":{"object":{"id":"ch_1"}}}`)
+	if event, err := provider.ParseWebhook(other, sign("whsec", other, time.Now())); err != nil || event != nil {
+		t.Errorf("expected unrelated events to be ignored, got %+v (%v)", event, err)
+	}
+}
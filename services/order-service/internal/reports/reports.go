@@ -0,0 +1,256 @@
+// Package reports keeps the read models admin reports are served from: orders by status, revenue by
+// day, and top products. They are updated from order events as they are published, so a report is a
+// lookup rather than a scan of every order, and may trail the orders themselves by a relay pass.
+package reports
+
+import (
+	"sort"
+	"sync"
+	"order-service/internal/models"
+)
+
+// dateLayout is how days are written in revenue reports
+const dateLayout = "2006-01-02"
+
+// entry is what the read models hold about one order, so its contribution can be taken back out
+// when a later event changes it
+type entry struct {
+	summary   models.OrderSummary
+	purchased bool
+	day       string
+	lines     map[string]*models.ProductSales // the order's units and subtotal by product
+}
+
+// Reports holds the read models. It is an outbox listener: each published order event replaces what
+// the models hold about that order with the order as the event describes it. Archiving an order
+// changes none of them, so reports cover archived orders too.
+type Reports struct {
+	mutex    sync.RWMutex
+	orders   map[string]*entry
+	byStatus map[models.OrderStatus]map[string]*models.OrderSummary
+	revenue  map[string]*models.DailyRevenue // by day
+	products map[string]*models.ProductSales // by product ID
+}
+
+// New creates empty read models
+func New() *Reports {
+	r := &Reports{}
+	r.reset()
+	return r
+}
+
+func (r *Reports) reset() {
+	r.orders = make(map[string]*entry)
+	r.byStatus = make(map[models.OrderStatus]map[string]*models.OrderSummary)
+	r.revenue = make(map[string]*models.DailyRevenue)
+	r.products = make(map[string]*models.ProductSales)
+}
+
+// Rebuild replaces the read models with ones built from orders, as when the service starts
+func (r *Reports) Rebuild(orders []*models.Order) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.reset()
+	for _, order := range orders {
+		r.apply(order)
+	}
+}
+
+// Publish updates the read models from a published order event
+func (r *Reports) Publish(event *models.OrderEvent) {
+	if event.Data.Order == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.apply(event.Data.Order)
+}
+
+// apply replaces what the models hold about order. An order older than the one they hold, as from an
+// event published again after a rebuild, is ignored.
+func (r *Reports) apply(order *models.Order) {
+	if previous, exists := r.orders[order.ID]; exists {
+		if order.UpdatedAt.Before(previous.summary.UpdatedAt) {
+			return
+		}
+		r.remove(previous)
+	}
+
+	e := &entry{
+		summary: models.OrderSummary{
+			ID:        order.ID,
+			UserID:    order.UserID,
+			Status:    order.Status,
+			Total:     order.Total,
+			CreatedAt: order.CreatedAt,
+			UpdatedAt: order.UpdatedAt,
+		},
+		purchased: order.IsPurchased(),
+		day:       order.CreatedAt.UTC().Format(dateLayout),
+		lines:     make(map[string]*models.ProductSales),
+	}
+	for _, item := range order.Items {
+		e.summary.ItemCount += item.Quantity
+		line, exists := e.lines[item.ProductID]
+		if !exists {
+			line = &models.ProductSales{ProductID: item.ProductID, ProductName: item.ProductName, OrderCount: 1}
+			e.lines[item.ProductID] = line
+		}
+		line.Quantity += item.Quantity
+		line.Revenue += item.Subtotal
+	}
+	r.add(e)
+}
+
+// add counts an order in the models
+func (r *Reports) add(e *entry) {
+	r.orders[e.summary.ID] = e
+	if r.byStatus[e.summary.Status] == nil {
+		r.byStatus[e.summary.Status] = make(map[string]*models.OrderSummary)
+	}
+	r.byStatus[e.summary.Status][e.summary.ID] = &e.summary
+
+	if !e.purchased {
+		return
+	}
+	day, exists := r.revenue[e.day]
+	if !exists {
+		day = &models.DailyRevenue{Date: e.day}
+		r.revenue[e.day] = day
+	}
+	day.OrderCount++
+	day.Revenue += e.summary.Total
+
+	for productID, line := range e.lines {
+		product, exists := r.products[productID]
+		if !exists {
+			product = &models.ProductSales{ProductID: productID}
+			r.products[productID] = product
+		}
+		// The latest order names the product as it is now
+		product.ProductName = line.ProductName
+		product.Quantity += line.Quantity
+		product.Revenue += line.Revenue
+		product.OrderCount++
+	}
+}
+
+// remove takes an order back out of the models
+func (r *Reports) remove(e *entry) {
+	delete(r.orders, e.summary.ID)
+	delete(r.byStatus[e.summary.Status], e.summary.ID)
+
+	if !e.purchased {
+		return
+	}
+	if day := r.revenue[e.day]; day != nil {
+		day.OrderCount--
+		day.Revenue -= e.summary.Total
+		if day.OrderCount <= 0 {
+			delete(r.revenue, e.day)
+		}
+	}
+	for productID, line := range e.lines {
+		product := r.products[productID]
+		if product == nil {
+			continue
+		}
+		product.Quantity -= line.Quantity
+		product.Revenue -= line.Revenue
+		product.OrderCount--
+		if product.OrderCount <= 0 {
+			delete(r.products, productID)
+		}
+	}
+}
+
+// StatusCounts returns how many orders are in each status
+func (r *Reports) StatusCounts() models.StatusCounts {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	counts := make(models.StatusCounts, len(r.byStatus))
+	for status, orders := range r.byStatus {
+		if len(orders) > 0 {
+			counts[status] = len(orders)
+		}
+	}
+	return counts
+}
+
+// OrdersByStatus returns a page of the orders in status, newest first; limit 0 returns them all
+func (r *Reports) OrdersByStatus(status models.OrderStatus, page, limit int) ([]models.OrderSummary, *models.PageInfo) {
+	r.mutex.RLock()
+	orders := make([]models.OrderSummary, 0, len(r.byStatus[status]))
+	for _, summary := range r.byStatus[status] {
+		orders = append(orders, *summary)
+	}
+	r.mutex.RUnlock()
+
+	// Order IDs break ties so pages are stable
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].CreatedAt.After(orders[j].CreatedAt)
+		}
+		return orders[i].ID < orders[j].ID
+	})
+
+	total := len(orders)
+	info := &models.PageInfo{Page: 1, Limit: limit, Total: total, TotalPages: 1}
+	if limit <= 0 {
+		info.Limit = total
+		return orders, info
+	}
+	if page > 1 {
+		info.Page = page
+	}
+	info.TotalPages = (total + limit - 1) / limit
+	start := min((info.Page-1)*limit, total)
+	end := min(start+limit, total)
+	info.HasMore = end < total
+	return orders[start:end], info
+}
+
+// RevenueByDay returns the revenue of each day from from to to, both YYYY-MM-DD and inclusive, oldest
+// first. Days without purchased orders are left out.
+func (r *Reports) RevenueByDay(from, to string) []models.DailyRevenue {
+	r.mutex.RLock()
+	days := make([]models.DailyRevenue, 0)
+	for date, day := range r.revenue {
+		if date < from || date > to {
+			continue
+		}
+		dayCopy := *day
+		dayCopy.Revenue = models.RoundCents(dayCopy.Revenue)
+		days = append(days, dayCopy)
+	}
+	r.mutex.RUnlock()
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days
+}
+
+// TopProducts returns the limit products sold in the most units, most first
+func (r *Reports) TopProducts(limit int) []models.ProductSales {
+	r.mutex.RLock()
+	products := make([]models.ProductSales, 0, len(r.products))
+	for _, product := range r.products {
+		productCopy := *product
+		productCopy.Revenue = models.RoundCents(productCopy.Revenue)
+		products = append(products, productCopy)
+	}
+	r.mutex.RUnlock()
+
+	// Product IDs break ties so the list is stable
+	sort.Slice(products, func(i, j int) bool {
+		if products[i].Quantity != products[j].Quantity {
+			return products[i].Quantity > products[j].Quantity
+		}
+		return products[i].ProductID < products[j].ProductID
+	})
+	if limit > 0 && len(products) > limit {
+		products = products[:limit]
+	}
+	return products
+}
@@ -0,0 +1,76 @@
+package reports
+
+import (
+	"testing"
+	"time"
+	"order-service/internal/models"
+)
+
+func publish(r *Reports, order *models.Order) {
+	order.UpdatedAt = order.UpdatedAt.Add(time.Second)
+	order.RecordEvent(models.EventOrderStatusChanged, models.OrderStatusPending)
+	for _, event := range order.TakeEvents() {
+		r.Publish(&event)
+	}
+}
+
+func TestReports_FollowOrdersThroughTheirEvents(t *testing.T) {
+	r := New()
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	first := models.NewOrder("u1", []models.OrderItem{models.NewOrderItem("p1", "Mug", 10, 2), models.NewOrderItem("p2", "Pen", 2, 5)})
+	first.CreatedAt, first.UpdatedAt, first.Total = day, day, 30
+	second := models.NewOrder("u2", []models.OrderItem{models.NewOrderItem("p2", "Pen", 2, 1)})
+	second.CreatedAt, second.UpdatedAt, second.Total = day.Add(time.Hour), day.Add(time.Hour), 2
+	r.Rebuild([]*models.Order{first, second})
+
+	if counts := r.StatusCounts(); counts[models.OrderStatusPending] != 2 {
+		t.Fatalf("expected 2 pending orders, got %v", counts)
+	}
+	if revenue := r.RevenueByDay("2024-03-01", "2024-03-01"); len(revenue) != 0 {
+		t.Fatalf("expected pending orders to bring in no revenue, got %+v", revenue)
+	}
+
+	first.Status = models.OrderStatusConfirmed
+	publish(r, first)
+	second.Status = models.OrderStatusConfirmed
+	publish(r, second)
+
+	pending, _ := r.OrdersByStatus(models.OrderStatusPending, 1, 10)
+	confirmed, page := r.OrdersByStatus(models.OrderStatusConfirmed, 1, 1)
+	if len(pending) != 0 || len(confirmed) != 1 || confirmed[0].ID != second.ID || page.Total != 2 || !page.HasMore {
+		t.Fatalf("expected the newest confirmed order on a page of 2, got %+v %+v", confirmed, page)
+	}
+	if confirmed[0].ItemCount != 1 || confirmed[0].Total != 2 {
+		t.Errorf("unexpected summary %+v", confirmed[0])
+	}
+
+	revenue := r.RevenueByDay("2024-02-01", "2024-03-31")
+	if len(revenue) != 1 || revenue[0].Date != "2024-03-01" || revenue[0].OrderCount != 2 || revenue[0].Revenue != 32 {
+		t.Fatalf("unexpected revenue %+v", revenue)
+	}
+	top := r.TopProducts(1)
+	if len(top) != 1 || top[0].ProductID != "p2" || top[0].Quantity != 6 || top[0].OrderCount != 2 || top[0].Revenue != 12 {
+		t.Fatalf("unexpected top products %+v", top)
+	}
+
+	// Cancelling takes the order back out of revenue and sales
+	first.Status = models.OrderStatusCancelled
+	publish(r, first)
+	if revenue := r.RevenueByDay("2024-03-01", "2024-03-01"); len(revenue) != 1 || revenue[0].OrderCount != 1 || revenue[0].Revenue != 2 {
+		t.Fatalf("expected only the second order's revenue, got %+v", revenue)
+	}
+	if top := r.TopProducts(10); len(top) != 1 || top[0].Quantity != 1 {
+		t.Fatalf("expected only the second order's sales, got %+v", top)
+	}
+
+	// An older event published again changes nothing
+	stale := *first
+	stale.Status = models.OrderStatusConfirmed
+	stale.UpdatedAt = day
+	stale.RecordEvent(models.EventOrderStatusChanged, models.OrderStatusPending)
+	r.Publish(&stale.TakeEvents()[0])
+	if counts := r.StatusCounts(); counts[models.OrderStatusCancelled] != 1 || counts[models.OrderStatusConfirmed] != 1 {
+		t.Errorf("expected a stale event to be ignored, got %v", counts)
+	}
+}
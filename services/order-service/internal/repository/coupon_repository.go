@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+	"order-service/internal/models"
+)
+
+// CouponRepository defines the interface for coupon data operations. Codes are stored and looked up
+// in their normalized form.
+type CouponRepository interface {
+	Create(coupon *models.Coupon) error
+	GetByCode(code string) (*models.Coupon, error)
+	List() ([]*models.Coupon, error)
+	Delete(code string) error
+	Redeem(code string, now time.Time) (*models.Coupon, error)
+	Release(code string) error
+}
+
+// InMemoryCouponRepository implements CouponRepository using in-memory storage
+type InMemoryCouponRepository struct {
+	coupons map[string]*models.Coupon
+	mutex   sync.RWMutex
+}
+
+// NewInMemoryCouponRepository creates a new in-memory coupon repository
+func NewInMemoryCouponRepository() *InMemoryCouponRepository {
+	return &InMemoryCouponRepository{
+		coupons: make(map[string]*models.Coupon),
+	}
+}
+
+// Create adds a coupon; its code must not be taken
+func (r *InMemoryCouponRepository) Create(coupon *models.Coupon) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.coupons[coupon.Code]; exists {
+		return errors.New("coupon code already exists")
+	}
+
+	couponCopy := *coupon
+	r.coupons[coupon.Code] = &couponCopy
+	return nil
+}
+
+// GetByCode retrieves a coupon by its code
+func (r *InMemoryCouponRepository) GetByCode(code string) (*models.Coupon, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	coupon, exists := r.coupons[code]
+	if !exists {
+		return nil, models.ErrCouponNotFound
+	}
+
+	couponCopy := *coupon
+	return &couponCopy, nil
+}
+
+// List returns every coupon, oldest first
+func (r *InMemoryCouponRepository) List() ([]*models.Coupon, error) {
+	r.mutex.RLock()
+	coupons := make([]*models.Coupon, 0, len(r.coupons))
+	for _, coupon := range r.coupons {
+		couponCopy := *coupon
+		coupons = append(coupons, &couponCopy)
+	}
+	r.mutex.RUnlock()
+
+	sort.Slice(coupons, func(i, j int) bool {
+		return coupons[i].CreatedAt.Before(coupons[j].CreatedAt)
+	})
+	return coupons, nil
+}
+
+// Delete removes a coupon; orders that used it keep their discount
+func (r *InMemoryCouponRepository) Delete(code string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.coupons[code]; !exists {
+		return models.ErrCouponNotFound
+	}
+
+	delete(r.coupons, code)
+	return nil
+}
+
+// Redeem counts one use of the coupon if it is still usable at now, checking and counting under one
+// lock so concurrent checkouts can't exceed the usage limit. It returns the coupon as redeemed.
+func (r *InMemoryCouponRepository) Redeem(code string, now time.Time) (*models.Coupon, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	coupon, exists := r.coupons[code]
+	if !exists {
+		return nil, models.ErrCouponNotFound
+	}
+	if err := coupon.CheckUsable(now); err != nil {
+		return nil, err
+	}
+
+	coupon.Uses++
+	couponCopy := *coupon
+	return &couponCopy, nil
+}
+
+// Release gives back a use counted by Redeem, for checkouts that failed afterwards
+func (r *InMemoryCouponRepository) Release(code string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	coupon, exists := r.coupons[code]
+	if !exists {
+		return models.ErrCouponNotFound
+	}
+	if coupon.Uses > 0 {
+		coupon.Uses--
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"order-service/internal/models"
+)
+
+func TestInMemoryCouponRepository_RedeemRespectsLimits(t *testing.T) {
+	repo := NewInMemoryCouponRepository()
+	now := time.Now()
+	expired := now.Add(-time.Hour)
+	_ = repo.Create(&models.Coupon{Code: "ONCE", Type: models.CouponFixed, Value: 5, MaxUses: 1})
+	_ = repo.Create(&models.Coupon{Code: "OLD", Type: models.CouponPercentage, Value: 10, ExpiresAt: &expired})
+	if err := repo.Create(&models.Coupon{Code: "ONCE", Type: models.CouponFixed, Value: 1}); err == nil {
+		t.Error("expected an error for a duplicate code")
+	}
+
+	if _, err := repo.Redeem("ONCE", now); err != nil {
+		t.Fatalf("redeem failed: %v", err)
+	}
+	if _, err := repo.Redeem("ONCE", now); !errors.Is(err, models.ErrCouponUsedUp) {
+		t.Fatalf("expected ErrCouponUsedUp got %v", err)
+	}
+	if _, err := repo.Redeem("OLD", now); !errors.Is(err, models.ErrCouponExpired) {
+		t.Fatalf("expected ErrCouponExpired got %v", err)
+	}
+	if _, err := repo.Redeem("MISSING", now); !errors.Is(err, models.ErrCouponNotFound) {
+		t.Fatalf("expected ErrCouponNotFound got %v", err)
+	}
+
+	_ = repo.Release("ONCE")
+	if coupon, err := repo.Redeem("ONCE", now); err != nil || coupon.Uses != 1 {
+		t.Fatalf("expected a released use to be redeemable again, got %+v %v", coupon, err)
+	}
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"sync"
+	"order-service/internal/models"
+)
+
+// LoyaltyRepository is the ledger of loyalty points. A user's balance is the sum of their entries.
+type LoyaltyRepository interface {
+	// Add records an entry. Entries taking points away for a redemption fail with
+	// models.ErrInsufficientPoints if they would leave the balance below zero. An order has at most
+	// one entry for each reason: adding another does nothing, so a retried or racing change to the
+	// order can't move its points twice.
+	Add(entry *models.LoyaltyEntry) error
+	Balance(userID string) (int, error)
+	Entries(userID string) ([]*models.LoyaltyEntry, error)
+}
+
+// InMemoryLoyaltyRepository implements LoyaltyRepository using in-memory storage
+type InMemoryLoyaltyRepository struct {
+	entries  map[string][]*models.LoyaltyEntry // by user ID, oldest first
+	balances map[string]int
+	recorded map[loyaltyEntryKey]bool // the order and reason of every entry for an order
+	mutex    sync.RWMutex
+}
+
+// loyaltyEntryKey identifies the one entry an order may have for a reason
+type loyaltyEntryKey struct {
+	orderID string
+	reason  models.LoyaltyReason
+}
+
+// NewInMemoryLoyaltyRepository creates a new in-memory loyalty ledger
+func NewInMemoryLoyaltyRepository() *InMemoryLoyaltyRepository {
+	return &InMemoryLoyaltyRepository{
+		entries:  make(map[string][]*models.LoyaltyEntry),
+		balances: make(map[string]int),
+		recorded: make(map[loyaltyEntryKey]bool),
+	}
+}
+
+// Add records an entry; the checks and the write happen under one lock, so concurrent checkouts can't
+// spend the same points twice and concurrent changes to an order can't record its entry twice
+func (r *InMemoryLoyaltyRepository) Add(entry *models.LoyaltyEntry) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := loyaltyEntryKey{orderID: entry.OrderID, reason: entry.Reason}
+	if entry.OrderID != "" && r.recorded[key] {
+		return nil
+	}
+	if entry.Reason == models.PointsRedeemed && r.balances[entry.UserID]+entry.Points < 0 {
+		return models.ErrInsufficientPoints
+	}
+
+	entryCopy := *entry
+	r.entries[entry.UserID] = append(r.entries[entry.UserID], &entryCopy)
+	r.balances[entry.UserID] += entry.Points
+	if entry.OrderID != "" {
+		r.recorded[key] = true
+	}
+	return nil
+}
+
+// Balance returns the user's points balance
+func (r *InMemoryLoyaltyRepository) Balance(userID string) (int, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.balances[userID], nil
+}
+
+// Entries returns the user's ledger entries, newest first
+func (r *InMemoryLoyaltyRepository) Entries(userID string) ([]*models.LoyaltyEntry, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	stored := r.entries[userID]
+	entries := make([]*models.LoyaltyEntry, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		entryCopy := *stored[i]
+		entries = append(entries, &entryCopy)
+	}
+	return entries, nil
+}
@@ -0,0 +1,397 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+	"ecommerce/pkg/mongodb"
+	"order-service/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoOutboxTimeout bounds each outbox call, since the outbox interface carries no context
+const mongoOutboxTimeout = 10 * time.Second
+
+var (
+	mongoOrderIndexes = []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: 1}}},
+	}
+	mongoOutboxIndexes = []mongo.IndexModel{
+		{Keys: bson.D{{Key: "seq", Value: 1}}},
+	}
+)
+
+// MongoOrderRepository implements OrderRepository and OutboxRepository on MongoDB collections.
+// An order and the events it recorded are written in one transaction, which needs a replica set.
+// Several service instances may relay the same pending event, so it can be published more than once.
+type MongoOrderRepository struct {
+	db     *mongo.Database
+	orders *mongo.Collection
+	outbox *mongo.Collection
+}
+
+// mongoOrderDocument is how an order is stored: the order keyed by its ID, plus the fields it
+// leaves out of API responses
+type mongoOrderDocument struct {
+	Key              string `bson:"_id"`
+	*models.Order    `bson:",inline"`
+	StoredClaimToken string             `json:"stored_claim_token,omitempty"`
+	StoredNotes      []models.OrderNote `json:"stored_notes,omitempty"`
+}
+
+// mongoOutboxDocument is how an outbox event is stored. Object IDs increase as events are
+// added, so they are relayed in the order they were recorded.
+type mongoOutboxDocument struct {
+	Key                string             `bson:"_id"`
+	Seq                primitive.ObjectID `json:"seq"`
+	*models.OrderEvent `bson:",inline"`
+}
+
+// NewMongoOrderRepository creates a repository on the database's orders and order_outbox
+// collections, creating their indexes if they don't exist yet
+func NewMongoOrderRepository(ctx context.Context, db *mongo.Database) (*MongoOrderRepository, error) {
+	repo := &MongoOrderRepository{
+		db:     db,
+		orders: db.Collection("orders"),
+		outbox: db.Collection("order_outbox"),
+	}
+	if err := mongodb.EnsureIndexes(ctx, repo.orders, mongoOrderIndexes); err != nil {
+		return nil, err
+	}
+	if err := mongodb.EnsureIndexes(ctx, repo.outbox, mongoOutboxIndexes); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// newMongoOrderDocument prepares an order for storing
+func newMongoOrderDocument(order *models.Order) *mongoOrderDocument {
+	return &mongoOrderDocument{
+		Key:              order.ID,
+		Order:            order,
+		StoredClaimToken: order.ClaimToken,
+		StoredNotes:      order.Notes,
+	}
+}
+
+// order rebuilds the stored order
+func (d *mongoOrderDocument) order() *models.Order {
+	order := d.Order
+	order.ClaimToken = d.StoredClaimToken
+	order.Notes = d.StoredNotes
+	return order
+}
+
+// Create adds a new order, moving its recorded events to the outbox in the same transaction
+func (r *MongoOrderRepository) Create(ctx context.Context, order *models.Order) error {
+	return r.save(ctx, order, func(ctx mongo.SessionContext, document *mongoOrderDocument) error {
+		_, err := r.orders.InsertOne(ctx, document)
+		if mongodb.IsDuplicateKey(err, "_id_") {
+			return errors.New("order already exists")
+		}
+		return err
+	})
+}
+
+// Update modifies an existing order, moving its recorded events to the outbox in the same transaction
+func (r *MongoOrderRepository) Update(ctx context.Context, order *models.Order) error {
+	return r.save(ctx, order, func(ctx mongo.SessionContext, document *mongoOrderDocument) error {
+		result, err := r.orders.ReplaceOne(ctx, bson.D{{Key: "_id", Value: order.ID}}, document)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errors.New("order not found")
+		}
+		return nil
+	})
+}
+
+// Modify changes an order within a transaction, which aborts and is retried if another write
+// reaches the order first
+func (r *MongoOrderRepository) Modify(ctx context.Context, id string, change func(order *models.Order) error) (*models.Order, error) {
+	var order *models.Order
+	var events []models.OrderEvent
+	err := mongodb.InTransaction(ctx, r.db, func(ctx mongo.SessionContext) error {
+		document := mongoOrderDocument{Order: &models.Order{}}
+		err := r.orders.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&document)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return errors.New("order not found")
+		}
+		if err != nil {
+			return err
+		}
+		order = document.order()
+		if err := change(order); err != nil {
+			return err
+		}
+
+		events = order.TakeEvents()
+		if _, err := r.orders.ReplaceOne(ctx, bson.D{{Key: "_id", Value: id}}, newMongoOrderDocument(order)); err != nil {
+			return err
+		}
+		return r.insertOutbox(ctx, events)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		orderEvents.WithLabelValues(event.Type, string(event.Data.Order.Status)).Inc()
+	}
+	return order, nil
+}
+
+// save writes an order with write and adds its recorded events to the outbox, all or nothing.
+// If the write fails the events stay on the order.
+func (r *MongoOrderRepository) save(ctx context.Context, order *models.Order, write func(ctx mongo.SessionContext, document *mongoOrderDocument) error) error {
+	events := order.TakeEvents()
+	err := mongodb.InTransaction(ctx, r.db, func(ctx mongo.SessionContext) error {
+		if err := write(ctx, newMongoOrderDocument(order)); err != nil {
+			return err
+		}
+		return r.insertOutbox(ctx, events)
+	})
+	if err != nil {
+		order.Events = events
+		return err
+	}
+
+	for _, event := range events {
+		orderEvents.WithLabelValues(event.Type, string(event.Data.Order.Status)).Inc()
+	}
+	return nil
+}
+
+// insertOutbox adds events to the outbox within the session's transaction
+func (r *MongoOrderRepository) insertOutbox(ctx mongo.SessionContext, events []models.OrderEvent) error {
+	for i := range events {
+		document := mongoOutboxDocument{Key: events[i].ID, Seq: primitive.NewObjectID(), OrderEvent: &events[i]}
+		if _, err := r.outbox.InsertOne(ctx, document); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetByID retrieves an order by its ID
+func (r *MongoOrderRepository) GetByID(ctx context.Context, id string) (*models.Order, error) {
+	document := mongoOrderDocument{Order: &models.Order{}}
+	err := r.orders.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errors.New("order not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return document.order(), nil
+}
+
+// GetByUserID retrieves all orders for a specific user
+func (r *MongoOrderRepository) GetByUserID(ctx context.Context, userID string) ([]*models.Order, error) {
+	return r.findOrders(ctx, bson.D{{Key: "user_id", Value: userID}})
+}
+
+// findOrders decodes every order matching filter
+func (r *MongoOrderRepository) findOrders(ctx context.Context, filter bson.D, opts ...*options.FindOptions) ([]*models.Order, error) {
+	cursor, err := r.orders.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var orders []*models.Order
+	for cursor.Next(ctx) {
+		document := mongoOrderDocument{Order: &models.Order{}}
+		if err := cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+		orders = append(orders, document.order())
+	}
+	return orders, cursor.Err()
+}
+
+// List returns the orders matching the filter, newest first, cut to the requested page.
+// A nil filter returns every order. Filtering, counting, and paging all happen in the database.
+func (r *MongoOrderRepository) List(ctx context.Context, filter *models.OrderFilter) ([]*models.Order, *models.PageInfo, error) {
+	if filter == nil {
+		filter = &models.OrderFilter{}
+	}
+
+	query := bson.D{}
+	if !filter.IncludeArchived {
+		query = append(query, bson.E{Key: "archived", Value: bson.D{{Key: "$ne", Value: true}}})
+	}
+	if filter.Status != "" {
+		query = append(query, bson.E{Key: "status", Value: filter.Status})
+	}
+	if filter.UserID != "" {
+		query = append(query, bson.E{Key: "user_id", Value: filter.UserID})
+	}
+	created := bson.D{}
+	if filter.From != nil {
+		created = append(created, bson.E{Key: "$gte", Value: *filter.From})
+	}
+	if filter.To != nil {
+		created = append(created, bson.E{Key: "$lt", Value: *filter.To})
+	}
+	if len(created) > 0 {
+		query = append(query, bson.E{Key: "created_at", Value: created})
+	}
+
+	count, err := r.orders.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	total := int(count)
+	info := &models.PageInfo{Page: 1, Limit: filter.Limit, Total: total, TotalPages: 1}
+
+	// Order IDs break ties so pages are stable
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: 1}})
+	if filter.Limit <= 0 {
+		// No limit means the whole result set
+		info.Limit = total
+	} else {
+		if filter.Page > 1 {
+			info.Page = filter.Page
+		}
+		info.TotalPages = (total + filter.Limit - 1) / filter.Limit
+		info.HasMore = info.Page*filter.Limit < total
+		opts.SetLimit(int64(filter.Limit)).SetSkip(int64((info.Page - 1) * filter.Limit))
+	}
+
+	orders, err := r.findOrders(ctx, query, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if orders == nil {
+		orders = []*models.Order{}
+	}
+	return orders, info, nil
+}
+
+// Delete removes an order
+func (r *MongoOrderRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.orders.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("order not found")
+	}
+	return nil
+}
+
+// AnonymizeByUserID strips personal data from all orders of a user and returns how many were
+// changed. The orders are rewritten in one transaction, so a concurrent change to any of them
+// makes it run again on their latest state.
+func (r *MongoOrderRepository) AnonymizeByUserID(ctx context.Context, userID string) (int, error) {
+	count := 0
+	err := mongodb.InTransaction(ctx, r.db, func(ctx mongo.SessionContext) error {
+		count = 0
+		orders, err := r.GetByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for _, order := range orders {
+			if order.AnonymizedAt != nil {
+				continue
+			}
+			order.Anonymize()
+			if _, err := r.orders.ReplaceOne(ctx, bson.D{{Key: "_id", Value: order.ID}}, newMongoOrderDocument(order)); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// UserStats summarises the user's purchased orders. Unlike the in-memory store nothing is cached,
+// since another instance may have changed the user's orders.
+func (r *MongoOrderRepository) UserStats(ctx context.Context, userID string) (*models.UserOrderStats, error) {
+	orders, err := r.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return models.ComputeUserOrderStats(userID, orders), nil
+}
+
+// Count returns how many orders are stored
+func (r *MongoOrderRepository) Count(ctx context.Context) (int, error) {
+	count, err := r.orders.CountDocuments(ctx, bson.D{})
+	return int(count), err
+}
+
+// Ping checks the deployment can be reached, for the readiness probe
+func (r *MongoOrderRepository) Ping(ctx context.Context) error {
+	return r.db.Client().Ping(ctx, nil)
+}
+
+// PendingEvents returns up to limit unpublished events, oldest first. A limit of zero or less returns them all.
+func (r *MongoOrderRepository) PendingEvents(limit int) ([]*models.OrderEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOutboxTimeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "seq", Value: 1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cursor, err := r.outbox.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	events := make([]*models.OrderEvent, 0)
+	for cursor.Next(ctx) {
+		document := mongoOutboxDocument{OrderEvent: &models.OrderEvent{}}
+		if err := cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+		events = append(events, document.OrderEvent)
+	}
+	return events, cursor.Err()
+}
+
+// MarkPublished removes a published event from the outbox
+func (r *MongoOrderRepository) MarkPublished(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOutboxTimeout)
+	defer cancel()
+
+	result, err := r.outbox.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("event not found")
+	}
+	return nil
+}
+
+// MarkFailed records a failed publish attempt; the event stays in the outbox to be retried
+func (r *MongoOrderRepository) MarkFailed(id string, publishErr error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOutboxTimeout)
+	defer cancel()
+
+	result, err := r.outbox.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+		{Key: "$set", Value: bson.D{{Key: "last_error", Value: publishErr.Error()}}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("event not found")
+	}
+	return nil
+}
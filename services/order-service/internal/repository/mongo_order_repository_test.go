@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+	"ecommerce/pkg/mongodb"
+	"order-service/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// newTestMongoOrderRepository connects to the replica set at TEST_MONGODB_URI, skipping the test
+// when it isn't set. The order collections are emptied first, so point it at a throwaway deployment.
+func newTestMongoOrderRepository(t *testing.T) *MongoOrderRepository {
+	t.Helper()
+	uri := os.Getenv("TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGODB_URI is not set")
+	}
+
+	ctx := context.Background()
+	db, err := mongodb.Connect(ctx, uri, "order_service_test")
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	t.Cleanup(func() { db.Client().Disconnect(context.Background()) })
+
+	repo, err := NewMongoOrderRepository(ctx, db)
+	if err != nil {
+		t.Fatalf("creating indexes: %v", err)
+	}
+	for _, name := range []string{"orders", "order_outbox"} {
+		if _, err := db.Collection(name).DeleteMany(ctx, bson.D{}); err != nil {
+			t.Fatalf("emptying %s: %v", name, err)
+		}
+	}
+	return repo
+}
+
+func TestMongoOrderRepository_KeepsFieldsLeftOutOfResponses(t *testing.T) {
+	repo := newTestMongoOrderRepository(t)
+	ctx := context.Background()
+	order := models.NewGuestOrder("guest@example.com", []models.OrderItem{{ProductID: "p1", Quantity: 2}}, "claim-token")
+	order.Notes = []models.OrderNote{{ID: "n1", Body: "Call before delivery"}}
+	if err := repo.Create(ctx, order); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	got, err := repo.GetByID(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.ClaimToken != "claim-token" || len(got.Notes) != 1 || got.GuestEmail != "guest@example.com" {
+		t.Errorf("expected the claim token, notes, and guest email kept, got %+v", got)
+	}
+	if _, err := repo.GetByID(ctx, "missing"); err == nil || err.Error() != "order not found" {
+		t.Errorf("expected order not found, got %v", err)
+	}
+}
+
+func TestMongoOrderRepository_ListFiltersAndPages(t *testing.T) {
+	repo := newTestMongoOrderRepository(t)
+	ctx := context.Background()
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		order := models.NewOrder("u1", []models.OrderItem{{ProductID: "p1", Quantity: 1}})
+		order.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		order.Archived = i == 0
+		if err := repo.Create(ctx, order); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	page, info, err := repo.List(ctx, &models.OrderFilter{UserID: "u1", Page: 2, Limit: 3})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if info.Total != 4 || info.TotalPages != 2 || info.HasMore || len(page) != 1 {
+		t.Errorf("expected the last of 4 unarchived orders on page 2, got %d orders and %+v", len(page), info)
+	}
+	if len(page) == 1 && !page[0].CreatedAt.Equal(base.Add(time.Hour)) {
+		t.Errorf("expected the oldest unarchived order last, got one created at %s", page[0].CreatedAt)
+	}
+
+	all, info, _ := repo.List(ctx, &models.OrderFilter{IncludeArchived: true})
+	if len(all) != 5 || info.Limit != 5 {
+		t.Errorf("expected every order without a limit, got %d and %+v", len(all), info)
+	}
+}
+
+func TestMongoOrderRepository_Modify(t *testing.T) {
+	repo := newTestMongoOrderRepository(t)
+	ctx := context.Background()
+	order := models.NewOrder("u1", []models.OrderItem{{ProductID: "p1", Quantity: 1, ReservationID: "r1"}})
+	_ = repo.Create(ctx, order)
+
+	// A rejected change saves nothing, the items included
+	rejected := errors.New("moved on")
+	_, err := repo.Modify(ctx, order.ID, func(order *models.Order) error {
+		order.Items[0].ReservationID = ""
+		order.Status = models.OrderStatusCancelled
+		return rejected
+	})
+	if !errors.Is(err, rejected) {
+		t.Fatalf("expected the change's error, got %v", err)
+	}
+	if got, _ := repo.GetByID(ctx, order.ID); got.Status != models.OrderStatusPending || got.Items[0].ReservationID != "r1" {
+		t.Fatalf("expected the order unchanged, got %+v", got)
+	}
+
+	modified, err := repo.Modify(ctx, order.ID, func(order *models.Order) error {
+		order.Status = models.OrderStatusConfirmed
+		order.RecordEvent(models.EventOrderStatusChanged, models.OrderStatusPending)
+		return nil
+	})
+	if err != nil || modified.Status != models.OrderStatusConfirmed {
+		t.Fatalf("expected the order confirmed, got %+v, %v", modified, err)
+	}
+	if got, _ := repo.GetByID(ctx, order.ID); got.Status != models.OrderStatusConfirmed {
+		t.Errorf("expected the change saved, got %s", got.Status)
+	}
+	if events, _ := repo.PendingEvents(0); len(events) != 1 {
+		t.Errorf("expected the change's event in the outbox, got %d", len(events))
+	}
+	if _, err := repo.Modify(ctx, "missing", func(*models.Order) error { return nil }); err == nil {
+		t.Error("expected modifying an unknown order to fail")
+	}
+}
+
+func TestMongoOrderRepository_OutboxWrittenWithOrder(t *testing.T) {
+	repo := newTestMongoOrderRepository(t)
+	ctx := context.Background()
+	order := models.NewOrder("u1", []models.OrderItem{{ProductID: "p1", Quantity: 1}})
+	order.RecordEvent(models.EventOrderCreated, "")
+	if err := repo.Create(ctx, order); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if len(order.Events) != 0 {
+		t.Error("expected the events moved to the outbox")
+	}
+
+	// A failed write leaves nothing in the outbox and the events on the order
+	missing := models.NewOrder("u1", nil)
+	missing.RecordEvent(models.EventOrderStatusChanged, models.OrderStatusPending)
+	if err := repo.Update(ctx, missing); err == nil {
+		t.Fatal("expected updating an unknown order to fail")
+	}
+	if len(missing.Events) != 1 {
+		t.Errorf("expected the event kept on the order, got %d", len(missing.Events))
+	}
+
+	events, err := repo.PendingEvents(0)
+	if err != nil || len(events) != 1 || events[0].OrderID != order.ID {
+		t.Fatalf("expected the order's one event pending, got %v, %v", events, err)
+	}
+	if err := repo.MarkFailed(events[0].ID, errors.New("broker down")); err != nil {
+		t.Fatalf("mark failed: %v", err)
+	}
+	events, _ = repo.PendingEvents(1)
+	if events[0].Attempts != 1 || events[0].LastError != "broker down" {
+		t.Errorf("expected the failed attempt recorded, got %+v", events[0])
+	}
+	if err := repo.MarkPublished(events[0].ID); err != nil {
+		t.Fatalf("mark published: %v", err)
+	}
+	if pending, _ := repo.PendingEvents(0); len(pending) != 0 {
+		t.Errorf("expected the outbox empty, got %d", len(pending))
+	}
+}
@@ -1,45 +1,80 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"sort"
 	"sync"
+	"ecommerce/pkg/metrics"
+	"ecommerce/pkg/snapshot"
 	"order-service/internal/models"
 )
 
-// OrderRepository defines the interface for order data operations
+// OrderRepository defines the interface for order data operations. Implementations backed by a
+// database should abandon a query once ctx is done.
 type OrderRepository interface {
-	Create(order *models.Order) error
-	GetByID(id string) (*models.Order, error)
-	GetByUserID(userID string) ([]*models.Order, error)
-	Update(order *models.Order) error
-	List() ([]*models.Order, error)
-	Delete(id string) error
+	Create(ctx context.Context, order *models.Order) error
+	GetByID(ctx context.Context, id string) (*models.Order, error)
+	GetByUserID(ctx context.Context, userID string) ([]*models.Order, error)
+	Update(ctx context.Context, order *models.Order) error
+	// Modify applies change to the stored order while holding it locked against other writes, and
+	// saves the result as Update does. Nothing is saved when change returns an error; Modify returns
+	// it. Use it to check an order's state and act on it in one step.
+	Modify(ctx context.Context, id string, change func(order *models.Order) error) (*models.Order, error)
+	List(ctx context.Context, filter *models.OrderFilter) ([]*models.Order, *models.PageInfo, error)
+	Delete(ctx context.Context, id string) error
+	AnonymizeByUserID(ctx context.Context, userID string) (int, error)
+	// UserStats summarises the user's purchased orders, archived ones included
+	UserStats(ctx context.Context, userID string) (*models.UserOrderStats, error)
+	// Count returns how many orders are stored
+	Count(ctx context.Context) (int, error)
 }
 
-// InMemoryOrderRepository implements OrderRepository using in-memory storage
+// OutboxRepository holds order events waiting to be published. Events enter the outbox in the
+// same write as the order that recorded them, so an event is never lost or published for a write
+// that didn't happen.
+type OutboxRepository interface {
+	PendingEvents(limit int) ([]*models.OrderEvent, error)
+	MarkPublished(id string) error
+	MarkFailed(id string, publishErr error) error
+}
+
+// InMemoryOrderRepository implements OrderRepository and OutboxRepository using in-memory storage
 type InMemoryOrderRepository struct {
 	orders map[string]*models.Order
+	byUser map[string]map[string]bool        // order IDs by user ID
+	stats  map[string]*models.UserOrderStats // by user ID; dropped whenever one of the user's orders changes
+	outbox []*models.OrderEvent              // oldest first
 	mutex  sync.RWMutex
+	// journal logs every change once PersistTo is called; nil otherwise
+	journal *snapshot.Store[orderSnapshot, orderChange]
 }
 
 // NewInMemoryOrderRepository creates a new in-memory order repository
 func NewInMemoryOrderRepository() *InMemoryOrderRepository {
 	return &InMemoryOrderRepository{
 		orders: make(map[string]*models.Order),
+		byUser: make(map[string]map[string]bool),
+		stats:  make(map[string]*models.UserOrderStats),
 	}
 }
 
-// Create adds a new order to the repository
-func (r *InMemoryOrderRepository) Create(order *models.Order) error {
+// Create adds a new order to the repository, moving its recorded events to the outbox
+func (r *InMemoryOrderRepository) Create(ctx context.Context, order *models.Order) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	r.orders[order.ID] = order
+	events := r.appendToOutbox(order)
+	// Store a copy so later changes only reach the repository through Update
+	orderCopy := *order
+	r.orders[order.ID] = &orderCopy
+	r.indexUser(order.UserID, order.ID)
+	r.log(orderChange{Order: &orderCopy, Events: events})
 	return nil
 }
 
 // GetByID retrieves an order by its ID
-func (r *InMemoryOrderRepository) GetByID(id string) (*models.Order, error) {
+func (r *InMemoryOrderRepository) GetByID(ctx context.Context, id string) (*models.Order, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
@@ -54,59 +89,265 @@ func (r *InMemoryOrderRepository) GetByID(id string) (*models.Order, error) {
 }
 
 // GetByUserID retrieves all orders for a specific user
-func (r *InMemoryOrderRepository) GetByUserID(userID string) ([]*models.Order, error) {
+func (r *InMemoryOrderRepository) GetByUserID(ctx context.Context, userID string) ([]*models.Order, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
 	var userOrders []*models.Order
-	for _, order := range r.orders {
-		if order.UserID == userID {
-			// Create a copy to prevent external modification
-			orderCopy := *order
-			userOrders = append(userOrders, &orderCopy)
-		}
+	for orderID := range r.byUser[userID] {
+		// Create a copy to prevent external modification
+		orderCopy := *r.orders[orderID]
+		userOrders = append(userOrders, &orderCopy)
 	}
 
 	return userOrders, nil
 }
 
-// Update modifies an existing order
-func (r *InMemoryOrderRepository) Update(order *models.Order) error {
+// Update modifies an existing order, moving its recorded events to the outbox
+func (r *InMemoryOrderRepository) Update(ctx context.Context, order *models.Order) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if _, exists := r.orders[order.ID]; !exists {
+	existing, exists := r.orders[order.ID]
+	if !exists {
 		return errors.New("order not found")
 	}
-
-	r.orders[order.ID] = order
+	r.replace(existing, order)
 	return nil
 }
 
-// List returns all orders
-func (r *InMemoryOrderRepository) List() ([]*models.Order, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+// Modify changes an order under the repository's lock
+func (r *InMemoryOrderRepository) Modify(ctx context.Context, id string, change func(order *models.Order) error) (*models.Order, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
+	existing, exists := r.orders[id]
+	if !exists {
+		return nil, errors.New("order not found")
+	}
+	// Work on a copy, items included, so a rejected change leaves the stored order as it was
+	order := *existing
+	order.Items = append([]models.OrderItem(nil), existing.Items...)
+	if err := change(&order); err != nil {
+		return nil, err
+	}
+	r.replace(existing, &order)
+	return &order, nil
+}
+
+// replace stores order in place of existing, moving its recorded events to the outbox; the caller
+// holds the write lock
+func (r *InMemoryOrderRepository) replace(existing, order *models.Order) {
+	// A claimed guest order moves to its new owner
+	if existing.UserID != order.UserID {
+		r.unindexUser(existing.UserID, order.ID)
+		r.indexUser(order.UserID, order.ID)
+	} else {
+		delete(r.stats, order.UserID)
+	}
+	events := r.appendToOutbox(order)
+	orderCopy := *order
+	r.orders[order.ID] = &orderCopy
+	r.log(orderChange{Order: &orderCopy, Events: events})
+}
+
+// indexUser records the order under its user and drops the user's cached stats; the caller holds the write lock
+func (r *InMemoryOrderRepository) indexUser(userID, orderID string) {
+	if r.byUser[userID] == nil {
+		r.byUser[userID] = make(map[string]bool)
+	}
+	r.byUser[userID][orderID] = true
+	delete(r.stats, userID)
+}
+
+// unindexUser removes the order from its user's index and drops the user's cached stats; the caller holds the write lock
+func (r *InMemoryOrderRepository) unindexUser(userID, orderID string) {
+	delete(r.byUser[userID], orderID)
+	if len(r.byUser[userID]) == 0 {
+		delete(r.byUser, userID)
+	}
+	delete(r.stats, userID)
+}
+
+var orderEvents = metrics.NewCounterVec("order_events_total",
+	"Order events recorded, by event type and the status the order was left in", "type", "status")
+
+// appendToOutbox moves the order's recorded events to the outbox, counting them for /metrics,
+// and returns them; the caller holds the write lock
+func (r *InMemoryOrderRepository) appendToOutbox(order *models.Order) []*models.OrderEvent {
+	var events []*models.OrderEvent
+	for _, event := range order.TakeEvents() {
+		eventCopy := event
+		r.outbox = append(r.outbox, &eventCopy)
+		events = append(events, &eventCopy)
+		orderEvents.WithLabelValues(event.Type, string(event.Data.Order.Status)).Inc()
+	}
+	return events
+}
+
+// List returns the orders matching the filter, newest first, cut to the requested page.
+// A nil filter returns every order.
+func (r *InMemoryOrderRepository) List(ctx context.Context, filter *models.OrderFilter) ([]*models.Order, *models.PageInfo, error) {
+	if filter == nil {
+		filter = &models.OrderFilter{}
+	}
+
+	r.mutex.RLock()
 	orders := make([]*models.Order, 0, len(r.orders))
 	for _, order := range r.orders {
+		if !filter.Matches(order) {
+			continue
+		}
 		// Create a copy to prevent external modification
 		orderCopy := *order
 		orders = append(orders, &orderCopy)
 	}
+	r.mutex.RUnlock()
+
+	// Order IDs break ties so pages are stable
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].CreatedAt.After(orders[j].CreatedAt)
+		}
+		return orders[i].ID < orders[j].ID
+	})
+
+	total := len(orders)
+	info := &models.PageInfo{Page: 1, Limit: filter.Limit, Total: total, TotalPages: 1}
+
+	// No limit means the whole result set
+	if filter.Limit <= 0 {
+		info.Limit = total
+		return orders, info, nil
+	}
+
+	if filter.Page > 1 {
+		info.Page = filter.Page
+	}
+	info.TotalPages = (total + filter.Limit - 1) / filter.Limit
+	start := (info.Page - 1) * filter.Limit
+	if start > total {
+		start = total
+	}
+	end := start + filter.Limit
+	if end > total {
+		end = total
+	}
+	info.HasMore = end < total
 
-	return orders, nil
+	return orders[start:end], info, nil
 }
 
 // Delete removes an order from the repository
-func (r *InMemoryOrderRepository) Delete(id string) error {
+func (r *InMemoryOrderRepository) Delete(ctx context.Context, id string) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if _, exists := r.orders[id]; !exists {
+	order, exists := r.orders[id]
+	if !exists {
 		return errors.New("order not found")
 	}
 
+	r.unindexUser(order.UserID, id)
 	delete(r.orders, id)
+	r.log(orderChange{DeletedOrderID: id})
 	return nil
 }
+
+// AnonymizeByUserID strips personal data from all orders of a user and returns how many were changed
+func (r *InMemoryOrderRepository) AnonymizeByUserID(ctx context.Context, userID string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	count := 0
+	for orderID := range r.byUser[userID] {
+		if order := r.orders[orderID]; order.AnonymizedAt == nil {
+			order.Anonymize()
+			r.log(orderChange{Order: order})
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// UserStats summarises the user's purchased orders. Stats are computed from the user's index and
+// cached until one of their orders changes.
+func (r *InMemoryOrderRepository) UserStats(ctx context.Context, userID string) (*models.UserOrderStats, error) {
+	r.mutex.RLock()
+	stats, cached := r.stats[userID]
+	r.mutex.RUnlock()
+
+	if !cached {
+		r.mutex.Lock()
+		orders := make([]*models.Order, 0, len(r.byUser[userID]))
+		for orderID := range r.byUser[userID] {
+			orders = append(orders, r.orders[orderID])
+		}
+		stats = models.ComputeUserOrderStats(userID, orders)
+		r.stats[userID] = stats
+		r.mutex.Unlock()
+	}
+
+	// Return a copy to prevent external modification
+	statsCopy := *stats
+	statsCopy.TopProducts = append([]models.ProductPurchases{}, stats.TopProducts...)
+	return &statsCopy, nil
+}
+
+// Count returns how many orders are stored
+func (r *InMemoryOrderRepository) Count(ctx context.Context) (int, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return len(r.orders), nil
+}
+
+// PendingEvents returns up to limit unpublished events, oldest first. A limit of zero or less returns them all.
+func (r *InMemoryOrderRepository) PendingEvents(limit int) ([]*models.OrderEvent, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	count := len(r.outbox)
+	if limit > 0 && limit < count {
+		count = limit
+	}
+
+	events := make([]*models.OrderEvent, 0, count)
+	for _, event := range r.outbox[:count] {
+		eventCopy := *event
+		events = append(events, &eventCopy)
+	}
+	return events, nil
+}
+
+// MarkPublished removes a published event from the outbox
+func (r *InMemoryOrderRepository) MarkPublished(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, event := range r.outbox {
+		if event.ID == id {
+			r.outbox = append(r.outbox[:i], r.outbox[i+1:]...)
+			r.log(orderChange{PublishedEventID: id})
+			return nil
+		}
+	}
+	return errors.New("event not found")
+}
+
+// MarkFailed records a failed publish attempt; the event stays in the outbox to be retried
+func (r *InMemoryOrderRepository) MarkFailed(id string, publishErr error) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, event := range r.outbox {
+		if event.ID == id {
+			event.Attempts++
+			event.LastError = publishErr.Error()
+			r.log(orderChange{FailedEvent: event})
+			return nil
+		}
+	}
+	return errors.New("event not found")
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"log/slog"
+	"ecommerce/pkg/snapshot"
+	"order-service/internal/models"
+)
+
+// orderSnapshot is the state of an InMemoryOrderRepository as saved on disk. The user index and
+// stats are rebuilt from the orders on load.
+type orderSnapshot struct {
+	Orders map[string]*models.Order
+	Outbox []*models.OrderEvent
+}
+
+// orderChange is one logged change: an order as it now stands with the events it moved to the
+// outbox, a deleted order, or an outbox event published or failed
+type orderChange struct {
+	Order            *models.Order
+	Events           []*models.OrderEvent
+	DeletedOrderID   string
+	PublishedEventID string
+	FailedEvent      *models.OrderEvent
+}
+
+// PersistTo keeps the repository's orders and unpublished events in dir, so they survive a
+// restart. Orders saved there before replace the ones in memory; a fresh directory starts from
+// the current orders. Call it before the repository is used.
+func (r *InMemoryOrderRepository) PersistTo(dir string) error {
+	journal, err := snapshot.Open[orderSnapshot, orderChange](dir, "orders")
+	if err != nil {
+		return err
+	}
+	saved, changes, err := journal.Load()
+	if err != nil {
+		journal.Close()
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if saved != nil {
+		r.orders = make(map[string]*models.Order)
+		for id, order := range saved.Orders {
+			r.orders[id] = order
+		}
+		r.outbox = saved.Outbox
+	}
+	for _, change := range changes {
+		r.replay(change)
+	}
+	r.byUser = make(map[string]map[string]bool)
+	r.stats = make(map[string]*models.UserOrderStats)
+	for id, order := range r.orders {
+		r.indexUser(order.UserID, id)
+	}
+
+	r.journal = journal
+	return r.journal.Save(&orderSnapshot{Orders: r.orders, Outbox: r.outbox})
+}
+
+// replay applies a logged change, leaving the user index to be rebuilt; the caller holds the write lock
+func (r *InMemoryOrderRepository) replay(change orderChange) {
+	if change.Order != nil {
+		r.orders[change.Order.ID] = change.Order
+	}
+	r.outbox = append(r.outbox, change.Events...)
+	if change.DeletedOrderID != "" {
+		delete(r.orders, change.DeletedOrderID)
+	}
+	for i, event := range r.outbox {
+		switch {
+		case change.PublishedEventID != "" && event.ID == change.PublishedEventID:
+			r.outbox = append(r.outbox[:i], r.outbox[i+1:]...)
+			return
+		case change.FailedEvent != nil && event.ID == change.FailedEvent.ID:
+			r.outbox[i] = change.FailedEvent
+			return
+		}
+	}
+}
+
+// Snapshot saves every order to disk, emptying the change log. It does nothing unless PersistTo was called.
+func (r *InMemoryOrderRepository) Snapshot() error {
+	if r.journal == nil {
+		return nil
+	}
+	// Changes are logged under the write lock, so none slips in while the snapshot is saved
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.journal.Save(&orderSnapshot{Orders: r.orders, Outbox: r.outbox})
+}
+
+// Close takes a last snapshot and closes the files PersistTo opened
+func (r *InMemoryOrderRepository) Close() error {
+	if r.journal == nil {
+		return nil
+	}
+	err := r.Snapshot()
+	if closeErr := r.journal.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// log appends a change to the journal. A failed write is logged rather than failing the change,
+// which is already made in memory; the next snapshot still saves it. Callers hold the write lock.
+func (r *InMemoryOrderRepository) log(change orderChange) {
+	if r.journal == nil {
+		return
+	}
+	if err := r.journal.Append(change); err != nil {
+		slog.Error("Error logging an order change; it is kept until the next snapshot", "error", err)
+	}
+}
@@ -1,17 +1,20 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 	"order-service/internal/models"
 )
 
 func TestInMemoryOrderRepository_CreateAndGet(t *testing.T) {
 	repo := NewInMemoryOrderRepository()
 	order := models.NewOrder("user1", []models.OrderItem{{ProductID: "p1", Quantity: 2}})
-	if err := repo.Create(order); err != nil {
+	if err := repo.Create(context.Background(), order); err != nil {
 		t.Fatalf("create failed: %v", err)
 	}
-	got, err := repo.GetByID(order.ID)
+	got, err := repo.GetByID(context.Background(), order.ID)
 	if err != nil {
 		t.Fatalf("get failed: %v", err)
 	}
@@ -25,11 +28,11 @@ func TestInMemoryOrderRepository_GetByUserID_List_Delete(t *testing.T) {
 	o1 := models.NewOrder("u1", []models.OrderItem{{ProductID: "p1", Quantity: 1}})
 	o2 := models.NewOrder("u1", []models.OrderItem{{ProductID: "p2", Quantity: 3}})
 	o3 := models.NewOrder("u2", []models.OrderItem{{ProductID: "p3", Quantity: 2}})
-	_ = repo.Create(o1)
-	_ = repo.Create(o2)
-	_ = repo.Create(o3)
+	_ = repo.Create(context.Background(), o1)
+	_ = repo.Create(context.Background(), o2)
+	_ = repo.Create(context.Background(), o3)
 
-	u1Orders, err := repo.GetByUserID("u1")
+	u1Orders, err := repo.GetByUserID(context.Background(), "u1")
 	if err != nil {
 		t.Fatalf("GetByUserID failed: %v", err)
 	}
@@ -37,29 +40,256 @@ func TestInMemoryOrderRepository_GetByUserID_List_Delete(t *testing.T) {
 		t.Errorf("expected 2 orders for u1 got %d", len(u1Orders))
 	}
 
-	all, _ := repo.List()
+	all, _, _ := repo.List(context.Background(), nil)
 	if len(all) != 3 {
 		t.Errorf("expected 3 total orders got %d", len(all))
 	}
 
-	if err := repo.Delete(o2.ID); err != nil {
+	if err := repo.Delete(context.Background(), o2.ID); err != nil {
 		t.Fatalf("delete failed: %v", err)
 	}
-	if _, err := repo.GetByID(o2.ID); err == nil {
+	if _, err := repo.GetByID(context.Background(), o2.ID); err == nil {
 		t.Error("expected error for deleted order")
 	}
+	if count, _ := repo.Count(context.Background()); count != 2 {
+		t.Errorf("expected 2 orders left got %d", count)
+	}
 }
 
 func TestInMemoryOrderRepository_Update(t *testing.T) {
 	repo := NewInMemoryOrderRepository()
 	o := models.NewOrder("u3", []models.OrderItem{{ProductID: "p9", Quantity: 4}})
-	_ = repo.Create(o)
+	_ = repo.Create(context.Background(), o)
 	o.Status = models.OrderStatusConfirmed
-	if err := repo.Update(o); err != nil {
+	if err := repo.Update(context.Background(), o); err != nil {
 		t.Fatalf("update failed: %v", err)
 	}
-	got, _ := repo.GetByID(o.ID)
+	got, _ := repo.GetByID(context.Background(), o.ID)
 	if got.Status != models.OrderStatusConfirmed {
 		t.Errorf("expected status confirmed got %s", got.Status)
 	}
 }
+
+func TestInMemoryOrderRepository_Modify(t *testing.T) {
+	repo := NewInMemoryOrderRepository()
+	ctx := context.Background()
+	order := models.NewOrder("u1", []models.OrderItem{{ProductID: "p1", Quantity: 1, ReservationID: "r1"}})
+	_ = repo.Create(ctx, order)
+
+	// A rejected change saves nothing, the items included
+	rejected := errors.New("moved on")
+	_, err := repo.Modify(ctx, order.ID, func(order *models.Order) error {
+		order.Items[0].ReservationID = ""
+		order.Status = models.OrderStatusCancelled
+		return rejected
+	})
+	if !errors.Is(err, rejected) {
+		t.Fatalf("expected the change's error, got %v", err)
+	}
+	if got, _ := repo.GetByID(ctx, order.ID); got.Status != models.OrderStatusPending || got.Items[0].ReservationID != "r1" {
+		t.Fatalf("expected the order unchanged, got %+v", got)
+	}
+
+	modified, err := repo.Modify(ctx, order.ID, func(order *models.Order) error {
+		order.Status = models.OrderStatusConfirmed
+		order.RecordEvent(models.EventOrderStatusChanged, models.OrderStatusPending)
+		return nil
+	})
+	if err != nil || modified.Status != models.OrderStatusConfirmed {
+		t.Fatalf("expected the order confirmed, got %+v, %v", modified, err)
+	}
+	if got, _ := repo.GetByID(ctx, order.ID); got.Status != models.OrderStatusConfirmed {
+		t.Errorf("expected the change saved, got %s", got.Status)
+	}
+	if events, _ := repo.PendingEvents(0); len(events) != 1 {
+		t.Errorf("expected the change's event in the outbox, got %d", len(events))
+	}
+	if _, err := repo.Modify(ctx, "missing", func(*models.Order) error { return nil }); err == nil {
+		t.Error("expected modifying an unknown order to fail")
+	}
+}
+
+func TestInMemoryOrderRepository_AnonymizeByUserID(t *testing.T) {
+	repo := NewInMemoryOrderRepository()
+	o1 := models.NewOrder("u1", []models.OrderItem{{ProductID: "p1", Quantity: 1}})
+	o1.ShippingAddress = &models.Address{ID: "a1", RecipientName: "Alice"}
+	o2 := models.NewOrder("u2", []models.OrderItem{{ProductID: "p1", Quantity: 1}})
+	o2.ShippingAddress = &models.Address{ID: "a2", RecipientName: "Bob"}
+	_ = repo.Create(context.Background(), o1)
+	_ = repo.Create(context.Background(), o2)
+
+	count, err := repo.AnonymizeByUserID(context.Background(), "u1")
+	if err != nil || count != 1 {
+		t.Fatalf("expected 1 anonymized order, got %d (%v)", count, err)
+	}
+	got, _ := repo.GetByID(context.Background(), o1.ID)
+	if got.ShippingAddress != nil || got.AnonymizedAt == nil {
+		t.Error("expected u1 order to be anonymized")
+	}
+	other, _ := repo.GetByID(context.Background(), o2.ID)
+	if other.ShippingAddress == nil {
+		t.Error("expected u2 order to be untouched")
+	}
+}
+
+func TestInMemoryOrderRepository_ListFiltersAndPaginates(t *testing.T) {
+	repo := NewInMemoryOrderRepository()
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	var ids []string
+	for i := 0; i < 5; i++ {
+		o := models.NewOrder("u1", []models.OrderItem{{ProductID: "p1", Quantity: 1}})
+		o.CreatedAt = start.Add(time.Duration(i) * 24 * time.Hour)
+		if i%2 == 1 {
+			o.UserID = "u2"
+			o.Status = models.OrderStatusConfirmed
+		}
+		_ = repo.Create(context.Background(), o)
+		ids = append(ids, o.ID)
+	}
+
+	page, info, err := repo.List(context.Background(), &models.OrderFilter{Limit: 2, Page: 2})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(page) != 2 || page[0].ID != ids[2] || page[1].ID != ids[1] {
+		t.Errorf("expected the middle two orders newest first, got %d orders", len(page))
+	}
+	if info.Total != 5 || info.TotalPages != 3 || !info.HasMore {
+		t.Errorf("unexpected page info %+v", info)
+	}
+
+	byUser, _, _ := repo.List(context.Background(), &models.OrderFilter{UserID: "u2"})
+	byStatus, _, _ := repo.List(context.Background(), &models.OrderFilter{Status: models.OrderStatusPending})
+	if len(byUser) != 2 || len(byStatus) != 3 {
+		t.Errorf("expected 2 orders for u2 and 3 pending, got %d and %d", len(byUser), len(byStatus))
+	}
+
+	from, to := start.Add(24*time.Hour), start.Add(3*24*time.Hour)
+	inRange, _, _ := repo.List(context.Background(), &models.OrderFilter{From: &from, To: &to})
+	if len(inRange) != 2 || inRange[0].ID != ids[2] || inRange[1].ID != ids[1] {
+		t.Errorf("expected the orders from the second and third days, got %d", len(inRange))
+	}
+}
+
+func TestInMemoryOrderRepository_Outbox(t *testing.T) {
+	repo := NewInMemoryOrderRepository()
+	o := models.NewOrder("u1", []models.OrderItem{{ProductID: "p1", Quantity: 1}})
+	o.RecordEvent(models.EventOrderCreated, "")
+	_ = repo.Create(context.Background(), o)
+	o.ChangeStatus(models.OrderStatusConfirmed, "test", "")
+	o.RecordEvent(models.EventOrderStatusChanged, models.OrderStatusPending)
+	_ = repo.Update(context.Background(), o)
+
+	events, _ := repo.PendingEvents(0)
+	if len(events) != 2 || events[0].Type != models.EventOrderCreated || events[1].Type != models.EventOrderStatusChanged {
+		t.Fatalf("expected both events oldest first, got %+v", events)
+	}
+	if len(o.Events) != 0 {
+		t.Error("expected the events to move off the order when it was saved")
+	}
+
+	if err := repo.MarkFailed(events[0].ID, errors.New("broker down")); err != nil {
+		t.Fatalf("mark failed: %v", err)
+	}
+	if err := repo.MarkPublished(events[1].ID); err != nil {
+		t.Fatalf("mark published: %v", err)
+	}
+	pending, _ := repo.PendingEvents(1)
+	if len(pending) != 1 || pending[0].ID != events[0].ID || pending[0].Attempts != 1 || pending[0].LastError != "broker down" {
+		t.Fatalf("expected the failed event to stay with its attempt recorded, got %+v", pending)
+	}
+	if err := repo.MarkPublished(events[1].ID); err == nil {
+		t.Error("expected an error for an event no longer in the outbox")
+	}
+
+	// A failed write leaves nothing in the outbox
+	missing := models.NewOrder("u2", nil)
+	missing.RecordEvent(models.EventOrderStatusChanged, models.OrderStatusPending)
+	if err := repo.Update(context.Background(), missing); err == nil {
+		t.Fatal("expected an error updating an unknown order")
+	}
+	if all, _ := repo.PendingEvents(0); len(all) != 1 {
+		t.Errorf("expected 1 pending event got %d", len(all))
+	}
+}
+
+func TestInMemoryOrderRepository_UserStats(t *testing.T) {
+	repo := NewInMemoryOrderRepository()
+	newOrder := func(userID string, status models.OrderStatus, items ...models.OrderItem) *models.Order {
+		order := models.NewOrder(userID, items)
+		order.Status = status
+		_ = repo.Create(context.Background(), order)
+		return order
+	}
+	newOrder("u1", models.OrderStatusDelivered, models.NewOrderItem("p1", "Tea", 5, 4), models.NewOrderItem("p2", "Mug", 12, 1))
+	newOrder("u1", models.OrderStatusConfirmed, models.NewOrderItem("p2", "Mug", 12, 2))
+	newOrder("u1", models.OrderStatusCancelled, models.NewOrderItem("p3", "Pot", 40, 5))
+	pending := newOrder("u1", models.OrderStatusPending, models.NewOrderItem("p3", "Pot", 40, 1))
+	newOrder("u2", models.OrderStatusDelivered, models.NewOrderItem("p3", "Pot", 40, 1))
+
+	stats, err := repo.UserStats(context.Background(), "u1")
+	if err != nil {
+		t.Fatalf("UserStats failed: %v", err)
+	}
+	if stats.OrderCount != 2 || stats.LifetimeSpend != 56 || stats.AverageOrderValue != 28 {
+		t.Fatalf("expected 2 orders worth 56 averaging 28, got %+v", stats)
+	}
+	if len(stats.TopProducts) != 2 || stats.TopProducts[0].ProductID != "p1" || stats.TopProducts[1].Quantity != 3 || stats.TopProducts[1].OrderCount != 2 {
+		t.Fatalf("expected tea then mugs, got %+v", stats.TopProducts)
+	}
+
+	// Cached stats are dropped when one of the user's orders changes
+	pending.Status = models.OrderStatusConfirmed
+	_ = repo.Update(context.Background(), pending)
+	if stats, _ := repo.UserStats(context.Background(), "u1"); stats.OrderCount != 3 || stats.TopProducts[2].ProductID != "p3" {
+		t.Fatalf("expected the confirmed order counted, got %+v", stats)
+	}
+
+	// A claimed guest order moves to its new owner's stats
+	guest := newOrder("", models.OrderStatusConfirmed, models.NewOrderItem("p1", "Tea", 5, 1))
+	guest.UserID = "u2"
+	_ = repo.Update(context.Background(), guest)
+	if stats, _ := repo.UserStats(context.Background(), "u2"); stats.OrderCount != 2 {
+		t.Fatalf("expected the claimed order counted for u2, got %+v", stats)
+	}
+	if stats, _ := repo.UserStats(context.Background(), "nobody"); stats.OrderCount != 0 || stats.AverageOrderValue != 0 {
+		t.Fatalf("expected empty stats, got %+v", stats)
+	}
+}
+
+func TestInMemoryOrderRepository_PersistsAcrossRestarts(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo := NewInMemoryOrderRepository()
+	if err := repo.PersistTo(dir); err != nil {
+		t.Fatalf("persist: %v", err)
+	}
+	kept := models.NewOrder("u1", []models.OrderItem{{ProductID: "p1", Quantity: 1}})
+	kept.RecordEvent(models.EventOrderCreated, "")
+	dropped := models.NewOrder("u1", []models.OrderItem{{ProductID: "p2", Quantity: 1}})
+	repo.Create(ctx, kept)
+	repo.Create(ctx, dropped)
+	repo.Snapshot()
+	// Changes after the snapshot come back from the log
+	kept.Status = models.OrderStatusConfirmed
+	kept.RecordEvent(models.EventOrderStatusChanged, models.OrderStatusPending)
+	repo.Update(ctx, kept)
+	repo.Delete(ctx, dropped.ID)
+	pending, _ := repo.PendingEvents(0)
+	repo.MarkPublished(pending[0].ID)
+	repo.MarkFailed(pending[1].ID, errors.New("broker down"))
+
+	restarted := NewInMemoryOrderRepository()
+	if err := restarted.PersistTo(dir); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	defer restarted.Close()
+	orders, _ := restarted.GetByUserID(ctx, "u1")
+	if len(orders) != 1 || orders[0].Status != models.OrderStatusConfirmed {
+		t.Fatalf("expected only the confirmed order, got %+v", orders)
+	}
+	events, _ := restarted.PendingEvents(0)
+	if len(events) != 1 || events[0].Type != models.EventOrderStatusChanged || events[0].Attempts != 1 {
+		t.Errorf("expected the failed status change left to publish, got %+v", events)
+	}
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"ecommerce/pkg/seed"
+	"order-service/internal/models"
+)
+
+// OrderFixture is an order as written in the "orders" section of a seed file. It is placed as
+// pending and unpaid unless Status and PaymentStatus say otherwise.
+type OrderFixture struct {
+	ID            string               `json:"id"`
+	UserID        string               `json:"user_id"`
+	Status        models.OrderStatus   `json:"status"`
+	PaymentStatus models.PaymentStatus `json:"payment_status"`
+	Items         []OrderItemFixture   `json:"items"`
+	CreatedAt     time.Time            `json:"created_at"` // now when unset
+}
+
+// OrderItemFixture is one line of an OrderFixture
+type OrderItemFixture struct {
+	ProductID   string  `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	Price       float64 `json:"price"`
+	Quantity    int     `json:"quantity"`
+}
+
+// SeedOrders creates the orders in the seed file at path that the repository doesn't have yet,
+// and returns how many it created. Fixtures need an ID, so seeding a store twice creates nothing
+// the second time. Seeded orders record no events, so nothing is published for them.
+func SeedOrders(ctx context.Context, repo OrderRepository, path string) (int, error) {
+	var fixtures []OrderFixture
+	if err := seed.Load(path, "orders", &fixtures); err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for i, fixture := range fixtures {
+		if fixture.ID == "" {
+			return created, fmt.Errorf("order fixture %d has no id", i+1)
+		}
+		if _, err := repo.GetByID(ctx, fixture.ID); err == nil {
+			continue
+		}
+		if len(fixture.Items) == 0 {
+			return created, fmt.Errorf("order fixture %s has no items", fixture.ID)
+		}
+
+		items := make([]models.OrderItem, 0, len(fixture.Items))
+		for _, item := range fixture.Items {
+			if item.ProductID == "" || item.Quantity <= 0 || item.Price < 0 {
+				return created, fmt.Errorf("order fixture %s needs a product, a positive quantity, and a price of at least 0 for each item", fixture.ID)
+			}
+			items = append(items, models.NewOrderItem(item.ProductID, item.ProductName, item.Price, item.Quantity))
+		}
+		order := models.NewOrder(fixture.UserID, items)
+		order.ID = fixture.ID
+		if !fixture.CreatedAt.IsZero() {
+			order.CreatedAt = fixture.CreatedAt
+			order.UpdatedAt = fixture.CreatedAt
+			order.StatusHistory[0].At = fixture.CreatedAt
+		}
+		if fixture.Status != "" && fixture.Status != order.Status {
+			if !models.IsValidOrderStatus(fixture.Status) {
+				return created, fmt.Errorf("order fixture %s has unknown status %q", fixture.ID, fixture.Status)
+			}
+			order.ChangeStatus(fixture.Status, "seed", "")
+		}
+		if fixture.PaymentStatus != "" {
+			order.PaymentStatus = fixture.PaymentStatus
+		}
+		if err := repo.Create(ctx, order); err != nil {
+			return created, fmt.Errorf("order fixture %s: %w", fixture.ID, err)
+		}
+		created++
+	}
+	return created, nil
+}
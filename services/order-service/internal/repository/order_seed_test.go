@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"order-service/internal/models"
+)
+
+// demoFixtures is the sample data shared by the services
+const demoFixtures = "../../../../fixtures/demo.yaml"
+
+func TestSeedOrders_CreatesMissingOrdersOnce(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryOrderRepository()
+
+	created, err := SeedOrders(ctx, repo, demoFixtures)
+	if err != nil || created != 1 {
+		t.Fatalf("expected the demo order created, got %d, %v", created, err)
+	}
+	order, err := repo.GetByID(ctx, "order-demo-delivered")
+	if err != nil {
+		t.Fatalf("expected the demo order, got %v", err)
+	}
+	if order.Status != models.OrderStatusDelivered || order.PaymentStatus != models.PaymentPaid || order.Total != 199.99 {
+		t.Errorf("expected a paid, delivered order of 199.99, got %+v", order)
+	}
+	if len(order.StatusHistory) != 2 {
+		t.Errorf("expected placement and delivery in the status history, got %+v", order.StatusHistory)
+	}
+	if pending, _ := repo.PendingEvents(10); len(pending) != 0 {
+		t.Errorf("expected no events for a seeded order, got %d", len(pending))
+	}
+
+	if created, err := SeedOrders(ctx, repo, demoFixtures); err != nil || created != 0 {
+		t.Errorf("expected nothing created the second time, got %d, %v", created, err)
+	}
+}
+
+func TestSeedOrders_RejectsUnknownStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.yaml")
+	os.WriteFile(path, []byte("orders:\n  - id: o1\n    status: lost\n    items:\n      - product_id: p1\n        price: 5\n        quantity: 1\n"), 0o644)
+
+	if _, err := SeedOrders(context.Background(), NewInMemoryOrderRepository(), path); err == nil {
+		t.Error("expected an unknown status to be rejected")
+	}
+}
@@ -0,0 +1,437 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"ecommerce/pkg/postgres"
+	"order-service/internal/models"
+)
+
+// postgresOutboxTimeout bounds each outbox call, since the outbox interface carries no context
+const postgresOutboxTimeout = 10 * time.Second
+
+// Orders are stored as JSON documents next to the columns they are filtered and sorted by.
+// Outbox events keep their publish attempts in columns so a failure doesn't rewrite the event.
+var postgresOrderSchema = []string{
+	`CREATE TABLE IF NOT EXISTS orders (
+		id         TEXT PRIMARY KEY,
+		user_id    TEXT NOT NULL,
+		status     TEXT NOT NULL,
+		archived   BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMPTZ NOT NULL,
+		document   JSONB NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS orders_user_idx ON orders (user_id)`,
+	`CREATE INDEX IF NOT EXISTS orders_created_idx ON orders (created_at DESC, id)`,
+	`CREATE TABLE IF NOT EXISTS order_outbox (
+		seq        BIGSERIAL PRIMARY KEY,
+		id         TEXT NOT NULL UNIQUE,
+		document   JSONB NOT NULL,
+		attempts   INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT ''
+	)`,
+}
+
+// PostgresOrderRepository implements OrderRepository and OutboxRepository on a PostgreSQL
+// database. An order and the events it recorded are written in one transaction. Several service
+// instances may relay the same pending event, so it can be published more than once.
+type PostgresOrderRepository struct {
+	db *sql.DB
+}
+
+// postgresOrderDocument is how an order is stored: the order's JSON form plus the fields it
+// leaves out of API responses
+type postgresOrderDocument struct {
+	*models.Order
+	StoredClaimToken string             `json:"stored_claim_token,omitempty"`
+	StoredNotes      []models.OrderNote `json:"stored_notes,omitempty"`
+}
+
+// NewPostgresOrderRepository creates a repository on db, creating its tables if they don't exist yet
+func NewPostgresOrderRepository(ctx context.Context, db *sql.DB) (*PostgresOrderRepository, error) {
+	if err := postgres.Migrate(ctx, db, "orders", postgresOrderSchema); err != nil {
+		return nil, err
+	}
+	return &PostgresOrderRepository{db: db}, nil
+}
+
+// encodeOrder prepares an order for storage. Documents go in as strings: lib/pq sends a []byte
+// as bytea, which a JSONB column won't accept.
+func encodeOrder(order *models.Order) (string, error) {
+	document, err := json.Marshal(postgresOrderDocument{
+		Order:            order,
+		StoredClaimToken: order.ClaimToken,
+		StoredNotes:      order.Notes,
+	})
+	return string(document), err
+}
+
+// decodeOrder rebuilds an order from its stored document
+func decodeOrder(source []byte) (*models.Order, error) {
+	document := postgresOrderDocument{Order: &models.Order{}}
+	if err := json.Unmarshal(source, &document); err != nil {
+		return nil, err
+	}
+	order := document.Order
+	order.ClaimToken = document.StoredClaimToken
+	order.Notes = document.StoredNotes
+	return order, nil
+}
+
+// Create adds a new order, moving its recorded events to the outbox in the same transaction
+func (r *PostgresOrderRepository) Create(ctx context.Context, order *models.Order) error {
+	return r.save(ctx, order, func(tx *sql.Tx, document string) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO orders (id, user_id, status, archived, created_at, document) VALUES ($1, $2, $3, $4, $5, $6)`,
+			order.ID, order.UserID, string(order.Status), order.Archived, order.CreatedAt, document)
+		if postgres.IsUniqueViolation(err, "orders_pkey") {
+			return errors.New("order already exists")
+		}
+		return err
+	})
+}
+
+// Update modifies an existing order, moving its recorded events to the outbox in the same transaction
+func (r *PostgresOrderRepository) Update(ctx context.Context, order *models.Order) error {
+	return r.save(ctx, order, func(tx *sql.Tx, document string) error {
+		result, err := tx.ExecContext(ctx, `UPDATE orders SET user_id = $2, status = $3, archived = $4, document = $5 WHERE id = $1`,
+			order.ID, order.UserID, string(order.Status), order.Archived, document)
+		if err != nil {
+			return err
+		}
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if updated == 0 {
+			return errors.New("order not found")
+		}
+		return nil
+	})
+}
+
+// Modify changes an order with its row locked by SELECT ... FOR UPDATE, saving it in the same
+// transaction
+func (r *PostgresOrderRepository) Modify(ctx context.Context, id string, change func(order *models.Order) error) (*models.Order, error) {
+	var order *models.Order
+	var events []models.OrderEvent
+	err := postgres.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		var source []byte
+		err := tx.QueryRowContext(ctx, `SELECT document FROM orders WHERE id = $1 FOR UPDATE`, id).Scan(&source)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("order not found")
+		}
+		if err != nil {
+			return err
+		}
+		if order, err = decodeOrder(source); err != nil {
+			return err
+		}
+		if err := change(order); err != nil {
+			return err
+		}
+
+		events = order.TakeEvents()
+		document, err := encodeOrder(order)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE orders SET user_id = $2, status = $3, archived = $4, document = $5 WHERE id = $1`,
+			order.ID, order.UserID, string(order.Status), order.Archived, document); err != nil {
+			return err
+		}
+		return insertOutbox(ctx, tx, events)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		orderEvents.WithLabelValues(event.Type, string(event.Data.Order.Status)).Inc()
+	}
+	return order, nil
+}
+
+// save writes an order with write and adds its recorded events to the outbox, all or nothing.
+// If the write fails the events stay on the order.
+func (r *PostgresOrderRepository) save(ctx context.Context, order *models.Order, write func(tx *sql.Tx, document string) error) error {
+	events := order.TakeEvents()
+	document, err := encodeOrder(order)
+	if err == nil {
+		err = postgres.InTx(ctx, r.db, func(tx *sql.Tx) error {
+			if err := write(tx, document); err != nil {
+				return err
+			}
+			return insertOutbox(ctx, tx, events)
+		})
+	}
+	if err != nil {
+		order.Events = events
+		return err
+	}
+
+	for _, event := range events {
+		orderEvents.WithLabelValues(event.Type, string(event.Data.Order.Status)).Inc()
+	}
+	return nil
+}
+
+// insertOutbox adds events to the outbox within tx
+func insertOutbox(ctx context.Context, tx *sql.Tx, events []models.OrderEvent) error {
+	for _, event := range events {
+		eventDocument, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO order_outbox (id, document) VALUES ($1, $2)`, event.ID, string(eventDocument)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetByID retrieves an order by its ID
+func (r *PostgresOrderRepository) GetByID(ctx context.Context, id string) (*models.Order, error) {
+	var document []byte
+	err := r.db.QueryRowContext(ctx, `SELECT document FROM orders WHERE id = $1`, id).Scan(&document)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("order not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeOrder(document)
+}
+
+// GetByUserID retrieves all orders for a specific user
+func (r *PostgresOrderRepository) GetByUserID(ctx context.Context, userID string) ([]*models.Order, error) {
+	return queryOrders(ctx, r.db, `SELECT document FROM orders WHERE user_id = $1`, userID)
+}
+
+// queryer is a *sql.DB or *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryOrders decodes every order document a query selects
+func queryOrders(ctx context.Context, db queryer, query string, args ...interface{}) ([]*models.Order, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []*models.Order
+	for rows.Next() {
+		var document []byte
+		if err := rows.Scan(&document); err != nil {
+			return nil, err
+		}
+		order, err := decodeOrder(document)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+// List returns the orders matching the filter, newest first, cut to the requested page.
+// A nil filter returns every order. Filtering, counting, and paging all happen in the database.
+func (r *PostgresOrderRepository) List(ctx context.Context, filter *models.OrderFilter) ([]*models.Order, *models.PageInfo, error) {
+	if filter == nil {
+		filter = &models.OrderFilter{}
+	}
+
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if !filter.IncludeArchived {
+		where("archived = ?", false)
+	}
+	if filter.Status != "" {
+		where("status = ?", string(filter.Status))
+	}
+	if filter.UserID != "" {
+		where("user_id = ?", filter.UserID)
+	}
+	if filter.From != nil {
+		where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		where("created_at < ?", *filter.To)
+	}
+	clause := ""
+	if len(conditions) > 0 {
+		clause = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM orders`+clause, args...).Scan(&total); err != nil {
+		return nil, nil, err
+	}
+	info := &models.PageInfo{Page: 1, Limit: filter.Limit, Total: total, TotalPages: 1}
+
+	// Order IDs break ties so pages are stable
+	query := `SELECT document FROM orders` + clause + ` ORDER BY created_at DESC, id`
+	if filter.Limit <= 0 {
+		// No limit means the whole result set
+		info.Limit = total
+	} else {
+		if filter.Page > 1 {
+			info.Page = filter.Page
+		}
+		info.TotalPages = (total + filter.Limit - 1) / filter.Limit
+		info.HasMore = info.Page*filter.Limit < total
+		args = append(args, filter.Limit, (info.Page-1)*filter.Limit)
+		query += ` LIMIT $` + strconv.Itoa(len(args)-1) + ` OFFSET $` + strconv.Itoa(len(args))
+	}
+
+	orders, err := queryOrders(ctx, r.db, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if orders == nil {
+		orders = []*models.Order{}
+	}
+	return orders, info, nil
+}
+
+// Delete removes an order
+func (r *PostgresOrderRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM orders WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return errors.New("order not found")
+	}
+	return nil
+}
+
+// AnonymizeByUserID strips personal data from all orders of a user and returns how many were
+// changed. The user's orders are locked until every one has been rewritten.
+func (r *PostgresOrderRepository) AnonymizeByUserID(ctx context.Context, userID string) (int, error) {
+	count := 0
+	err := postgres.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		orders, err := queryOrders(ctx, tx, `SELECT document FROM orders WHERE user_id = $1 FOR UPDATE`, userID)
+		if err != nil {
+			return err
+		}
+		for _, order := range orders {
+			if order.AnonymizedAt != nil {
+				continue
+			}
+			order.Anonymize()
+			document, err := encodeOrder(order)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE orders SET document = $2 WHERE id = $1`, order.ID, document); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// UserStats summarises the user's purchased orders. Unlike the in-memory store nothing is cached,
+// since another instance may have changed the user's orders.
+func (r *PostgresOrderRepository) UserStats(ctx context.Context, userID string) (*models.UserOrderStats, error) {
+	orders, err := r.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return models.ComputeUserOrderStats(userID, orders), nil
+}
+
+// Count returns how many orders are stored
+func (r *PostgresOrderRepository) Count(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM orders`).Scan(&count)
+	return count, err
+}
+
+// Ping checks the database can be reached, for the readiness probe
+func (r *PostgresOrderRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// PendingEvents returns up to limit unpublished events, oldest first. A limit of zero or less returns them all.
+func (r *PostgresOrderRepository) PendingEvents(limit int) ([]*models.OrderEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), postgresOutboxTimeout)
+	defer cancel()
+
+	query := `SELECT document, attempts, last_error FROM order_outbox ORDER BY seq`
+	var args []interface{}
+	if limit > 0 {
+		query += ` LIMIT $1`
+		args = append(args, limit)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]*models.OrderEvent, 0)
+	for rows.Next() {
+		var document []byte
+		var event models.OrderEvent
+		if err := rows.Scan(&document, &event.Attempts, &event.LastError); err != nil {
+			return nil, err
+		}
+		attempts, lastError := event.Attempts, event.LastError
+		if err := json.Unmarshal(document, &event); err != nil {
+			return nil, err
+		}
+		event.Attempts, event.LastError = attempts, lastError
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+// MarkPublished removes a published event from the outbox
+func (r *PostgresOrderRepository) MarkPublished(id string) error {
+	return r.execOutbox(`DELETE FROM order_outbox WHERE id = $1`, id)
+}
+
+// MarkFailed records a failed publish attempt; the event stays in the outbox to be retried
+func (r *PostgresOrderRepository) MarkFailed(id string, publishErr error) error {
+	return r.execOutbox(`UPDATE order_outbox SET attempts = attempts + 1, last_error = $2 WHERE id = $1`, id, publishErr.Error())
+}
+
+// execOutbox runs a statement that changes one outbox event, failing if it changed none
+func (r *PostgresOrderRepository) execOutbox(query string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), postgresOutboxTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if changed == 0 {
+		return errors.New("event not found")
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+	"order-service/internal/models"
+)
+
+// SubscriptionRepository defines the interface for recurring order subscriptions
+type SubscriptionRepository interface {
+	Create(subscription *models.Subscription) error
+	GetByID(id string) (*models.Subscription, error)
+	Update(subscription *models.Subscription) error
+	ListByUser(userID string) ([]*models.Subscription, error)
+	ListDue(now time.Time) ([]*models.Subscription, error)
+}
+
+// InMemorySubscriptionRepository implements SubscriptionRepository using in-memory storage
+type InMemorySubscriptionRepository struct {
+	subscriptions map[string]*models.Subscription
+	mutex         sync.RWMutex
+}
+
+// NewInMemorySubscriptionRepository creates a new in-memory subscription repository
+func NewInMemorySubscriptionRepository() *InMemorySubscriptionRepository {
+	return &InMemorySubscriptionRepository{
+		subscriptions: make(map[string]*models.Subscription),
+	}
+}
+
+// Create adds a new subscription
+func (r *InMemorySubscriptionRepository) Create(subscription *models.Subscription) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.subscriptions[subscription.ID]; exists {
+		return errors.New("subscription already exists")
+	}
+
+	subscriptionCopy := *subscription
+	r.subscriptions[subscription.ID] = &subscriptionCopy
+	return nil
+}
+
+// GetByID retrieves a subscription by ID
+func (r *InMemorySubscriptionRepository) GetByID(id string) (*models.Subscription, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	subscription, exists := r.subscriptions[id]
+	if !exists {
+		return nil, errors.New("subscription not found")
+	}
+
+	subscriptionCopy := *subscription
+	return &subscriptionCopy, nil
+}
+
+// Update replaces an existing subscription
+func (r *InMemorySubscriptionRepository) Update(subscription *models.Subscription) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.subscriptions[subscription.ID]; !exists {
+		return errors.New("subscription not found")
+	}
+
+	subscriptionCopy := *subscription
+	r.subscriptions[subscription.ID] = &subscriptionCopy
+	return nil
+}
+
+// ListByUser returns a user's subscriptions, oldest first
+func (r *InMemorySubscriptionRepository) ListByUser(userID string) ([]*models.Subscription, error) {
+	return r.list(func(subscription *models.Subscription) bool {
+		return subscription.UserID == userID
+	}, func(a, b *models.Subscription) bool {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}), nil
+}
+
+// ListDue returns the active subscriptions whose next order is due by now, the longest overdue first
+func (r *InMemorySubscriptionRepository) ListDue(now time.Time) ([]*models.Subscription, error) {
+	return r.list(func(subscription *models.Subscription) bool {
+		return subscription.Status == models.SubscriptionActive && !subscription.NextRunAt.After(now)
+	}, func(a, b *models.Subscription) bool {
+		return a.NextRunAt.Before(b.NextRunAt)
+	}), nil
+}
+
+// list copies out the subscriptions matching keep, sorted by less
+func (r *InMemorySubscriptionRepository) list(keep func(*models.Subscription) bool, less func(a, b *models.Subscription) bool) []*models.Subscription {
+	r.mutex.RLock()
+	subscriptions := []*models.Subscription{}
+	for _, subscription := range r.subscriptions {
+		if keep(subscription) {
+			subscriptionCopy := *subscription
+			subscriptions = append(subscriptions, &subscriptionCopy)
+		}
+	}
+	r.mutex.RUnlock()
+
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return less(subscriptions[i], subscriptions[j])
+	})
+	return subscriptions
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"order-service/internal/models"
+)
+
+// maxDeliveriesPerSubscription caps each subscription's delivery log; the oldest attempts are dropped first
+const maxDeliveriesPerSubscription = 500
+
+// WebhookRepository defines the interface for webhook subscription and delivery log data operations
+type WebhookRepository interface {
+	CreateSubscription(subscription *models.WebhookSubscription) error
+	GetSubscription(id string) (*models.WebhookSubscription, error)
+	ListSubscriptions() ([]*models.WebhookSubscription, error)
+	DeleteSubscription(id string) error
+	AddDelivery(delivery *models.WebhookDelivery) error
+	ListDeliveries(subscriptionID string) ([]*models.WebhookDelivery, error)
+}
+
+// InMemoryWebhookRepository implements WebhookRepository using in-memory storage
+type InMemoryWebhookRepository struct {
+	subscriptions map[string]*models.WebhookSubscription
+	deliveries    map[string][]*models.WebhookDelivery // keyed by subscription ID, oldest first
+	mutex         sync.RWMutex
+}
+
+// NewInMemoryWebhookRepository creates a new in-memory webhook repository
+func NewInMemoryWebhookRepository() *InMemoryWebhookRepository {
+	return &InMemoryWebhookRepository{
+		subscriptions: make(map[string]*models.WebhookSubscription),
+		deliveries:    make(map[string][]*models.WebhookDelivery),
+	}
+}
+
+// CreateSubscription adds a webhook subscription
+func (r *InMemoryWebhookRepository) CreateSubscription(subscription *models.WebhookSubscription) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	subscriptionCopy := *subscription
+	r.subscriptions[subscription.ID] = &subscriptionCopy
+	return nil
+}
+
+// GetSubscription retrieves a webhook subscription by its ID
+func (r *InMemoryWebhookRepository) GetSubscription(id string) (*models.WebhookSubscription, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	subscription, exists := r.subscriptions[id]
+	if !exists {
+		return nil, errors.New("webhook subscription not found")
+	}
+
+	subscriptionCopy := *subscription
+	return &subscriptionCopy, nil
+}
+
+// ListSubscriptions returns every webhook subscription, oldest first
+func (r *InMemoryWebhookRepository) ListSubscriptions() ([]*models.WebhookSubscription, error) {
+	r.mutex.RLock()
+	subscriptions := make([]*models.WebhookSubscription, 0, len(r.subscriptions))
+	for _, subscription := range r.subscriptions {
+		subscriptionCopy := *subscription
+		subscriptions = append(subscriptions, &subscriptionCopy)
+	}
+	r.mutex.RUnlock()
+
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+	})
+	return subscriptions, nil
+}
+
+// DeleteSubscription removes a webhook subscription together with its delivery log
+func (r *InMemoryWebhookRepository) DeleteSubscription(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.subscriptions[id]; !exists {
+		return errors.New("webhook subscription not found")
+	}
+
+	delete(r.subscriptions, id)
+	delete(r.deliveries, id)
+	return nil
+}
+
+// AddDelivery appends a delivery attempt to its subscription's log
+func (r *InMemoryWebhookRepository) AddDelivery(delivery *models.WebhookDelivery) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.subscriptions[delivery.SubscriptionID]; !exists {
+		return errors.New("webhook subscription not found")
+	}
+
+	deliveryCopy := *delivery
+	log := append(r.deliveries[delivery.SubscriptionID], &deliveryCopy)
+	if len(log) > maxDeliveriesPerSubscription {
+		log = log[len(log)-maxDeliveriesPerSubscription:]
+	}
+	r.deliveries[delivery.SubscriptionID] = log
+	return nil
+}
+
+// ListDeliveries returns a subscription's delivery attempts, newest first
+func (r *InMemoryWebhookRepository) ListDeliveries(subscriptionID string) ([]*models.WebhookDelivery, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if _, exists := r.subscriptions[subscriptionID]; !exists {
+		return nil, errors.New("webhook subscription not found")
+	}
+
+	log := r.deliveries[subscriptionID]
+	deliveries := make([]*models.WebhookDelivery, 0, len(log))
+	for i := len(log) - 1; i >= 0; i-- {
+		deliveryCopy := *log[i]
+		deliveries = append(deliveries, &deliveryCopy)
+	}
+	return deliveries, nil
+}
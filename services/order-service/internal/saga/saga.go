@@ -0,0 +1,76 @@
+package saga
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// Step is one action of a saga together with the compensation that undoes it
+type Step struct {
+	Name       string
+	Action     func() error
+	Compensate func() error // nil when the action has nothing to undo
+}
+
+// StepError reports which step of a saga failed. Compensations that also failed are listed
+// so callers know the rollback left something behind.
+type StepError struct {
+	Saga               string
+	Step               string
+	Err                error
+	CompensationErrors []error
+}
+
+func (e *StepError) Error() string {
+	message := fmt.Sprintf("saga %s: step %s failed: %v", e.Saga, e.Step, e.Err)
+	if len(e.CompensationErrors) > 0 {
+		message += fmt.Sprintf(" (%d compensations failed)", len(e.CompensationErrors))
+	}
+	return message
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// Saga runs steps in order. When a step fails, the steps that already succeeded are
+// compensated in reverse order, so a failure part way through leaves nothing half done.
+type Saga struct {
+	name  string
+	steps []Step
+}
+
+// New creates an empty saga; name identifies it in errors and logs
+func New(name string) *Saga {
+	return &Saga{name: name}
+}
+
+// AddStep appends a step to the saga
+func (s *Saga) AddStep(name string, action, compensate func() error) {
+	s.steps = append(s.steps, Step{Name: name, Action: action, Compensate: compensate})
+}
+
+// Execute runs every step, compensating the completed ones if any step fails. The error
+// is a *StepError naming the failed step and wrapping its error.
+func (s *Saga) Execute() error {
+	for i, step := range s.steps {
+		err := step.Action()
+		if err == nil {
+			continue
+		}
+
+		stepErr := &StepError{Saga: s.name, Step: step.Name, Err: err}
+		for j := i - 1; j >= 0; j-- {
+			completed := s.steps[j]
+			if completed.Compensate == nil {
+				continue
+			}
+			if err := completed.Compensate(); err != nil {
+				slog.Error("Saga compensating step failed", "saga", s.name, "step", completed.Name, "error", err)
+				stepErr.CompensationErrors = append(stepErr.CompensationErrors, fmt.Errorf("%s: %w", completed.Name, err))
+			}
+		}
+		return stepErr
+	}
+	return nil
+}
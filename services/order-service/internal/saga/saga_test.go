@@ -0,0 +1,61 @@
+package saga
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExecute_CompensatesCompletedStepsInReverse(t *testing.T) {
+	var calls []string
+	record := func(call string, err error) func() error {
+		return func() error {
+			calls = append(calls, call)
+			return err
+		}
+	}
+
+	s := New("test")
+	s.AddStep("a", record("do a", nil), record("undo a", nil))
+	s.AddStep("b", record("do b", nil), nil)
+	s.AddStep("c", record("do c", nil), record("undo c", nil))
+	s.AddStep("d", record("do d", errors.New("d failed")), record("undo d", nil))
+
+	err := s.Execute()
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != "d" {
+		t.Fatalf("expected step d to fail, got %v", err)
+	}
+	want := []string{"do a", "do b", "do c", "do d", "undo c", "undo a"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("expected %v, got %v", want, calls)
+	}
+}
+
+func TestExecute_ReportsFailedCompensations(t *testing.T) {
+	cause := errors.New("payment declined")
+	s := New("test")
+	s.AddStep("reserve", func() error { return nil }, func() error { return errors.New("release failed") })
+	s.AddStep("charge", func() error { return cause }, nil)
+
+	err := s.Execute()
+	if !errors.Is(err, cause) {
+		t.Fatalf("expected the step's error to be wrapped, got %v", err)
+	}
+	var stepErr *StepError
+	errors.As(err, &stepErr)
+	if len(stepErr.CompensationErrors) != 1 {
+		t.Errorf("expected one failed compensation, got %v", stepErr.CompensationErrors)
+	}
+}
+
+func TestExecute_Success(t *testing.T) {
+	s := New("test")
+	ran := 0
+	for i := 0; i < 3; i++ {
+		s.AddStep("step", func() error { ran++; return nil }, func() error { t.Error("unexpected compensation"); return nil })
+	}
+	if err := s.Execute(); err != nil || ran != 3 {
+		t.Fatalf("expected all steps to run, got %d (%v)", ran, err)
+	}
+}
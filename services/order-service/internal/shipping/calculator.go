@@ -0,0 +1,73 @@
+package shipping
+
+import (
+	"errors"
+	"strings"
+	"order-service/internal/models"
+)
+
+// ErrUnknownMethod is returned for a shipping method without configured rates
+var ErrUnknownMethod = errors.New("no rates for shipping method")
+
+// Rate prices a shipment as a base charge plus a charge per kilogram
+type Rate struct {
+	Base  float64
+	PerKg float64
+}
+
+// MethodRates holds a method's rates for shipments within the origin country and abroad
+type MethodRates struct {
+	Domestic      Rate
+	International Rate
+}
+
+// DefaultRates are the rates used unless others are configured
+var DefaultRates = map[models.ShippingMethod]MethodRates{
+	models.ShippingStandard: {
+		Domestic:      Rate{Base: 5, PerKg: 1},
+		International: Rate{Base: 15, PerKg: 4},
+	},
+	models.ShippingExpress: {
+		Domestic:      Rate{Base: 15, PerKg: 2},
+		International: Rate{Base: 35, PerKg: 8},
+	},
+}
+
+// Calculator prices shipping from the order's weight and destination
+type Calculator struct {
+	origin string
+	rates  map[models.ShippingMethod]MethodRates
+}
+
+// NewCalculator creates a calculator for orders shipped from the origin country (an ISO 3166 code)
+func NewCalculator(origin string, rates map[models.ShippingMethod]MethodRates) *Calculator {
+	return &Calculator{
+		origin: strings.ToUpper(origin),
+		rates:  rates,
+	}
+}
+
+// Cost returns the shipping cost of the order's items by method. Digital items weigh nothing,
+// and an order of only digital items ships for free. Orders without an address pay domestic rates.
+func (c *Calculator) Cost(order *models.Order, method models.ShippingMethod) (float64, error) {
+	rates, exists := c.rates[method]
+	if !exists {
+		return 0, ErrUnknownMethod
+	}
+
+	if !order.NeedsShipping() {
+		return 0, nil
+	}
+	var weightKg float64
+	for _, item := range order.Items {
+		if !item.Digital {
+			weightKg += item.WeightKg * float64(item.Quantity)
+		}
+	}
+
+	rate := rates.Domestic
+	if order.ShippingAddress != nil && !strings.EqualFold(order.ShippingAddress.Country, c.origin) {
+		rate = rates.International
+	}
+	return models.RoundCents(rate.Base + rate.PerKg*weightKg), nil
+}
@@ -0,0 +1,46 @@
+package shipping
+
+import (
+	"testing"
+	"order-service/internal/models"
+)
+
+func TestCalculatorCost(t *testing.T) {
+	calculator := NewCalculator("us", DefaultRates)
+	book := models.NewOrderItem("book", "Book", 20, 2)
+	book.WeightKg = 0.5
+	ebook := models.NewOrderItem("ebook", "E-book", 8, 1)
+	ebook.Digital = true
+	ebook.WeightKg = 3 // ignored, nothing ships
+
+	order := models.NewOrder("u1", []models.OrderItem{book, ebook})
+	order.ShippingAddress = &models.Address{Country: "US"}
+
+	cases := []struct {
+		name    string
+		country string
+		method  models.ShippingMethod
+		want    float64
+	}{
+		{"standard domestic", "US", models.ShippingStandard, 6},
+		{"express domestic", "us", models.ShippingExpress, 17},
+		{"standard international", "DE", models.ShippingStandard, 19},
+		{"express international", "DE", models.ShippingExpress, 43},
+	}
+	for _, tc := range cases {
+		order.ShippingAddress.Country = tc.country
+		cost, err := calculator.Cost(order, tc.method)
+		if err != nil || cost != tc.want {
+			t.Errorf("%s: expected %v, got %v (%v)", tc.name, tc.want, cost, err)
+		}
+	}
+
+	if _, err := calculator.Cost(order, "overnight"); err != ErrUnknownMethod {
+		t.Errorf("expected ErrUnknownMethod, got %v", err)
+	}
+
+	digitalOnly := models.NewOrder("u1", []models.OrderItem{ebook})
+	if cost, err := calculator.Cost(digitalOnly, models.ShippingExpress); err != nil || cost != 0 {
+		t.Errorf("expected digital-only orders to ship free, got %v (%v)", cost, err)
+	}
+}
@@ -0,0 +1,33 @@
+package tax
+
+import (
+	"errors"
+	"order-service/internal/models"
+)
+
+// Calculator works out the tax due on an order. Implementations can use the order's
+// shipping address to apply regional rates or ask an external tax service.
+type Calculator interface {
+	Calculate(order *models.Order) (float64, error)
+}
+
+// ErrInvalidRate is returned for a tax rate outside 0 to 1
+var ErrInvalidRate = errors.New("tax rate must be between 0 and 1")
+
+// FlatRateCalculator charges the same rate on every order, wherever it ships
+type FlatRateCalculator struct {
+	rate float64
+}
+
+// NewFlatRateCalculator creates a calculator charging rate (0.2 for 20%) on the order subtotal after discounts
+func NewFlatRateCalculator(rate float64) (*FlatRateCalculator, error) {
+	if rate < 0 || rate > 1 {
+		return nil, ErrInvalidRate
+	}
+	return &FlatRateCalculator{rate: rate}, nil
+}
+
+// Calculate returns the tax on the order's discounted subtotal, rounded to cents
+func (c *FlatRateCalculator) Calculate(order *models.Order) (float64, error) {
+	return models.RoundCents(order.DiscountedSubtotal() * c.rate), nil
+}
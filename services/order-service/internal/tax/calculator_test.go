@@ -0,0 +1,29 @@
+package tax
+
+import (
+	"testing"
+	"order-service/internal/models"
+)
+
+func TestFlatRateCalculator(t *testing.T) {
+	calculator, err := NewFlatRateCalculator(0.0825)
+	if err != nil {
+		t.Fatalf("NewFlatRateCalculator failed: %v", err)
+	}
+	order := models.NewOrder("u1", []models.OrderItem{models.NewOrderItem("p1", "Prod", 19.99, 3)})
+
+	tax, err := calculator.Calculate(order)
+	if err != nil || tax != 4.95 {
+		t.Fatalf("expected 4.95 tax on 59.97, got %v (%v)", tax, err)
+	}
+	order.ApplyTax(tax)
+	if order.Total != 64.92 {
+		t.Errorf("expected total 64.92, got %v", order.Total)
+	}
+
+	for _, rate := range []float64{-0.1, 1.5} {
+		if _, err := NewFlatRateCalculator(rate); err != ErrInvalidRate {
+			t.Errorf("expected ErrInvalidRate for %v, got %v", rate, err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"order-service/internal/models"
+	"order-service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// Headers sent with every delivery
+const (
+	SignatureHeader = "X-Webhook-Signature" // "t=<unix time>,v1=<hex HMAC-SHA256 of "<t>.<body>">"
+	EventHeader     = "X-Webhook-Event"
+	EventIDHeader   = "X-Webhook-Event-ID"
+)
+
+// Retry defaults: five attempts, waiting 1s, 2s, 4s, then 8s between them
+const (
+	DefaultMaxAttempts = 5
+	DefaultRetryDelay  = time.Second
+)
+
+// Publisher announces order events. Implemented by Dispatcher; enables mocking in tests.
+type Publisher interface {
+	Publish(event *models.OrderEvent)
+}
+
+// Dispatcher delivers events to the subscriptions that want them. Each delivery runs in the
+// background and is retried with exponential backoff; every attempt is written to the delivery log.
+type Dispatcher struct {
+	repo        repository.WebhookRepository
+	httpClient  *http.Client
+	maxAttempts int
+	retryDelay  time.Duration
+	pending     sync.WaitGroup
+	inFlight    atomic.Int64  // deliveries under way, for reporting what a drain cut off
+	draining    chan struct{} // closed by Drain so deliveries stop waiting between attempts
+	drainOnce   sync.Once
+}
+
+// NewDispatcher creates a dispatcher that tries each delivery up to maxAttempts times, waiting
+// retryDelay before the first retry and doubling the wait after each one
+func NewDispatcher(repo repository.WebhookRepository, maxAttempts int, retryDelay time.Duration) *Dispatcher {
+	return &Dispatcher{
+		repo: repo,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		maxAttempts: maxAttempts,
+		retryDelay:  retryDelay,
+		draining:    make(chan struct{}),
+	}
+}
+
+// NewSecret generates a signing secret for a new subscription
+func NewSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// Sign returns the signature header value for a delivery body sent at the given time
+func Sign(secret string, body []byte, at time.Time) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Publish sends an event to every subscription that wants its type. It returns without waiting
+// for the deliveries. Subscribers see the order event's ID, so they can discard duplicates.
+func (d *Dispatcher) Publish(orderEvent *models.OrderEvent) {
+	subscriptions, err := d.repo.ListSubscriptions()
+	if err != nil {
+		slog.Error("Listing webhook subscriptions failed; event not delivered", "order_event_id", orderEvent.ID, "error", err)
+		return
+	}
+
+	event := models.WebhookEvent{
+		ID:         orderEvent.ID,
+		Type:       orderEvent.Type,
+		OccurredAt: orderEvent.OccurredAt,
+		Data:       orderEvent.Data,
+	}
+	// The body is fixed now so later changes to the order don't leak into a retry
+	body, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Encoding webhook event failed", "event_id", event.ID, "error", err)
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		if !subscription.Wants(event.Type) {
+			continue
+		}
+		d.pending.Add(1)
+		d.inFlight.Add(1)
+		go func(subscription *models.WebhookSubscription) {
+			defer d.pending.Done()
+			defer d.inFlight.Add(-1)
+			d.deliver(subscription, &event, body)
+		}(subscription)
+	}
+}
+
+// Wait blocks until every delivery in progress has succeeded or run out of attempts
+func (d *Dispatcher) Wait() {
+	d.pending.Wait()
+}
+
+// Drain winds deliveries down for shutdown: a delivery waiting to retry makes its next attempt at
+// once and gives up if that fails too, rather than backing off. It waits for every delivery to end
+// until ctx is done, and then reports how many were cut off; their attempts so far are in the
+// delivery log.
+func (d *Dispatcher) Drain(ctx context.Context) error {
+	d.drainOnce.Do(func() { close(d.draining) })
+	finished := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d webhook deliveries still under way: %w", d.inFlight.Load(), ctx.Err())
+	}
+}
+
+// deliver posts the event to one subscription until it is accepted or the attempts run out.
+// Retries stop early if the subscription is deleted, and after one more attempt once draining.
+func (d *Dispatcher) deliver(subscription *models.WebhookSubscription, event *models.WebhookEvent, body []byte) {
+	delay := d.retryDelay
+	lastChance := false
+	for attempt := 1; ; attempt++ {
+		delivery := d.attempt(subscription, event, body)
+		delivery.Attempt = attempt
+		if err := d.repo.AddDelivery(delivery); err != nil {
+			slog.Info("Webhook subscription is gone; dropping event", "subscription_id", subscription.ID, "event_id", event.ID)
+			return
+		}
+		if delivery.Success {
+			return
+		}
+		if attempt == d.maxAttempts || lastChance {
+			slog.Info("Giving up delivering event to webhook", "event_id", event.ID, "subscription_id", subscription.ID, "attempts", attempt)
+			return
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-d.draining:
+		}
+		select {
+		case <-d.draining:
+			lastChance = true
+		default:
+		}
+		delay *= 2
+	}
+}
+
+// attempt makes one delivery; any 2xx response counts as delivered
+func (d *Dispatcher) attempt(subscription *models.WebhookSubscription, event *models.WebhookEvent, body []byte) *models.WebhookDelivery {
+	now := time.Now()
+	delivery := &models.WebhookDelivery{
+		ID:             uuid.New().String(),
+		SubscriptionID: subscription.ID,
+		EventID:        event.ID,
+		EventType:      event.Type,
+		AttemptedAt:    now,
+	}
+
+	req, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, body, now))
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(EventIDHeader, event.ID)
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	resp.Body.Close()
+
+	delivery.StatusCode = resp.StatusCode
+	delivery.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !delivery.Success {
+		delivery.Error = fmt.Sprintf("subscriber responded with status %d", resp.StatusCode)
+	}
+	return delivery
+}
@@ -0,0 +1,131 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"order-service/internal/models"
+	"order-service/internal/repository"
+)
+
+func TestDispatcher_SignsRetriesAndLogsDeliveries(t *testing.T) {
+	var calls int32
+	var received models.WebhookEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signature := r.Header.Get(SignatureHeader)
+		seconds, _ := strconv.ParseInt(strings.TrimPrefix(strings.Split(signature, ",")[0], "t="), 10, 64)
+		if want := Sign("secret", body, time.Unix(seconds, 0)); signature != want {
+			t.Errorf("signature %q does not match %q", signature, want)
+		}
+		// The first two attempts fail so the delivery has to be retried
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.Unmarshal(body, &received)
+	}))
+	defer server.Close()
+
+	repo := repository.NewInMemoryWebhookRepository()
+	wanted := models.NewWebhookSubscription(server.URL, []string{models.EventOrderCreated}, "secret")
+	ignored := models.NewWebhookSubscription(server.URL, []string{models.EventOrderStatusChanged}, "other")
+	_ = repo.CreateSubscription(wanted)
+	_ = repo.CreateSubscription(ignored)
+
+	dispatcher := NewDispatcher(repo, 3, time.Millisecond)
+	dispatcher.Publish(&models.OrderEvent{ID: "e1", Type: models.EventOrderCreated, OrderID: "o1"})
+	dispatcher.Wait()
+
+	if atomic.LoadInt32(&calls) != 3 || received.Type != models.EventOrderCreated || received.ID != "e1" {
+		t.Fatalf("expected the event to arrive on the third attempt, got %d calls and %+v", calls, received)
+	}
+
+	deliveries, _ := repo.ListDeliveries(wanted.ID)
+	if len(deliveries) != 3 || !deliveries[0].Success || deliveries[0].Attempt != 3 || deliveries[2].StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected three logged attempts, newest first, got %d", len(deliveries))
+	}
+	if others, _ := repo.ListDeliveries(ignored.ID); len(others) != 0 {
+		t.Errorf("expected no deliveries to a subscription without the event, got %d", len(others))
+	}
+}
+
+func TestDispatcher_GivesUpAfterMaxAttempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	repo := repository.NewInMemoryWebhookRepository()
+	subscription := models.NewWebhookSubscription(server.URL, []string{models.EventOrderStatusChanged}, "secret")
+	_ = repo.CreateSubscription(subscription)
+
+	dispatcher := NewDispatcher(repo, 2, time.Millisecond)
+	dispatcher.Publish(&models.OrderEvent{ID: "e1", Type: models.EventOrderStatusChanged, OrderID: "o1"})
+	dispatcher.Wait()
+
+	deliveries, _ := repo.ListDeliveries(subscription.ID)
+	if len(deliveries) != 2 || deliveries[0].Success || deliveries[1].Success {
+		t.Fatalf("expected two failed attempts, got %d", len(deliveries))
+	}
+}
+
+func TestDispatcher_DrainCutsRetriesShort(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	repo := repository.NewInMemoryWebhookRepository()
+	subscription := models.NewWebhookSubscription(server.URL, []string{models.EventOrderStatusChanged}, "secret")
+	_ = repo.CreateSubscription(subscription)
+
+	// Without a drain the second attempt would be an hour off
+	dispatcher := NewDispatcher(repo, 5, time.Hour)
+	dispatcher.Publish(&models.OrderEvent{ID: "e1", Type: models.EventOrderStatusChanged, OrderID: "o1"})
+	for deadline := time.Now().Add(5 * time.Second); calls.Load() == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := dispatcher.Drain(ctx); err != nil {
+		t.Fatalf("expected the drain to finish, got %v", err)
+	}
+	if deliveries, _ := repo.ListDeliveries(subscription.ID); len(deliveries) != 2 {
+		t.Errorf("expected one more attempt once draining, got %d attempts", len(deliveries))
+	}
+}
+
+func TestDispatcher_DrainReportsDeliveriesCutOff(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	repo := repository.NewInMemoryWebhookRepository()
+	subscription := models.NewWebhookSubscription(server.URL, []string{models.EventOrderStatusChanged}, "secret")
+	_ = repo.CreateSubscription(subscription)
+
+	dispatcher := NewDispatcher(repo, 1, time.Millisecond)
+	dispatcher.Publish(&models.OrderEvent{ID: "e1", Type: models.EventOrderStatusChanged, OrderID: "o1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := dispatcher.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the drain deadline to be reported, got %v", err)
+	}
+}
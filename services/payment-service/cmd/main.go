@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"ecommerce/pkg/auth"
+	"ecommerce/pkg/config"
+	"ecommerce/pkg/diagnostics"
+	"ecommerce/pkg/events"
+	"ecommerce/pkg/health"
+	"ecommerce/pkg/jobs"
+	"ecommerce/pkg/logging"
+	"ecommerce/pkg/metrics"
+	"ecommerce/pkg/middleware"
+	"payment-service/internal/handlers"
+	"payment-service/internal/outbox"
+	"payment-service/internal/provider"
+	"payment-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+func main() {
+	// Settings come from flags, the environment, and the YAML file named by -config or CONFIG_FILE
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		logging.Fatal("Failed to load configuration", "error", err)
+	}
+	logging.Setup(cfg.String("SERVICE_NAME", "payment-service"))
+	serverConfig := cfg.Server(8085)
+
+	// The log level, body size limit, rate limits, and user service URL are read again on SIGHUP
+	reloader := config.NewReloader(cfg)
+	reloader.Register(config.TuneLogLevel)
+	reloader.Register(config.TuneMaxBodyBytes)
+
+	// With TLS_CERT_FILE, the API is served over mutual TLS, and calls to other services present the
+	// same certificate; the files are read again on SIGHUP, so rotated ones take effect
+	certs := reloader.TLS()
+
+	// Payments are kept in memory until a database is plugged in
+	paymentRepo := repository.NewInMemoryPaymentRepository()
+	metrics.NewGaugeFunc("payments_stored", "Payments in the repository", func() float64 {
+		return float64(paymentRepo.Count())
+	})
+
+	// Payments are taken through the provider named by PAYMENT_PROVIDER
+	payments := setupProvider(cfg)
+
+	// Every change to a payment is published to the broker named by BROKER, retried every second
+	// until the broker takes it
+	paymentEvents := outbox.New(setupBroker(cfg))
+	metrics.NewGaugeFunc("payment_events_pending", "Payment events waiting to be published", func() float64 {
+		return float64(paymentEvents.Pending())
+	})
+	expvar.Publish("payment_events_pending", expvar.Func(func() interface{} { return paymentEvents.Pending() }))
+	scheduler := jobs.NewScheduler(jobs.Solo{})
+	scheduler.Add(jobs.Job{Name: "publish-payment-events", Schedule: jobs.Every(time.Second), Run: publishEvents(paymentEvents), EveryInstance: true})
+	scheduler.Start()
+
+	// Service keys presented by other services are verified with the user service
+	serviceKeys := auth.NewServiceKeyVerifier("http://localhost:8081", time.Minute)
+	if certs != nil {
+		serviceKeys.UseTLS(certs.ClientConfig())
+	}
+	reloader.Register(func(cfg *config.Config) func() {
+		userServiceURL := serviceURL(cfg, "USER_SERVICE_URL", "http://localhost:8081")
+		return func() { serviceKeys.SetUserServiceURL(userServiceURL) }
+	})
+
+	paymentHandler := handlers.NewPaymentHandler(paymentRepo, payments, paymentEvents)
+
+	// Payments are taken in-process once a request arrives, so readiness only says the service is up
+	probes := health.NewChecker("payment-service", health.DefaultTimeout)
+
+	// Setup routes
+	router := setupRoutes(serverConfig.CORSOrigins, reloader, serviceKeys, probes, paymentHandler)
+
+	// Stop before serving if any setting was invalid, listing every problem at once
+	if err := cfg.Err(); err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+	for _, key := range cfg.Unused() {
+		slog.Warn("Setting is not used", "key", key)
+	}
+	go reloader.Watch(context.Background())
+
+	// CPU and heap profiles and runtime variables are served on DEBUG_ADDR, an internal port apart
+	// from the API, when it is set
+	debugServer, err := diagnostics.Serve(serverConfig.DebugAddr)
+	if err != nil {
+		logging.Fatal("Diagnostics port failed to start", "error", err)
+	}
+
+	// Configure server; requests through the proxies in TRUSTED_PROXIES, such as the gateway, are
+	// attributed to the client they came from
+	server := &http.Server{
+		Addr:         serverConfig.Addr(),
+		Handler:      middleware.TrustProxies(serverConfig.TrustedProxies)(middleware.Versioned(router, "v1", "v1")),
+		ReadTimeout:  serverConfig.ReadTimeout,
+		WriteTimeout: serverConfig.WriteTimeout,
+		IdleTimeout:  serverConfig.IdleTimeout,
+	}
+
+	// Start server in a goroutine
+	go func() {
+		slog.Info("🚀 Payment Service starting", "port", serverConfig.Port, "provider", payments.Name())
+		slog.Info("📚 API Documentation:")
+		slog.Info("  POST /payments              - Take or authorize a payment for an order (internal)")
+		slog.Info("  GET  /payments?order_id=    - List an order's payments (internal)")
+		slog.Info("  GET  /payments/{id}         - Get a payment (internal)")
+		slog.Info("  POST /payments/{id}/capture - Capture an authorized payment (internal)")
+		slog.Info("  POST /payments/{id}/refund  - Refund a paid payment in full or in part (internal)")
+		slog.Info("  POST /payments/webhook      - Payment provider notifications")
+		slog.Info("  GET  /healthz               - Liveness probe")
+		slog.Info("  GET  /readyz                - Readiness probe")
+		slog.Info("  GET  /metrics               - Prometheus metrics")
+		slog.Info("---")
+
+		if err := certs.ListenAndServe(server); err != nil && err != http.ErrServerClosed {
+			logging.Fatal("Server failed to start", "error", err)
+		}
+	}()
+
+	// Wait for interrupt signal to gracefully shutdown the server
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	slog.Info("🛑 Shutting down Payment Service...")
+
+	// Graceful shutdown with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), serverConfig.ShutdownTimeout)
+	defer cancel()
+
+	debugServer.Close()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Server forced to shutdown", "error", err)
+	} else {
+		slog.Info("✅ Payment Service shutdown complete")
+	}
+
+	// Events still queued are published before exiting, since they are only kept in memory
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), serverConfig.DrainTimeout)
+	defer cancelDrain()
+	if err := scheduler.Shutdown(drainCtx); err != nil {
+		slog.Error("Job runs cut short at shutdown", "error", err)
+	}
+	if err := paymentEvents.Drain(drainCtx); err != nil {
+		slog.Error("Payment events lost at shutdown", "error", err)
+	}
+}
+
+// setupRoutes configures all the HTTP routes
+func setupRoutes(corsOrigins []string, reloader *config.Reloader, serviceKeys *auth.ServiceKeyVerifier, probes *health.Checker, paymentHandler *handlers.PaymentHandler) *mux.Router {
+	router := mux.NewRouter()
+
+	// Add CORS middleware
+	router.Use(middleware.CORS(corsOrigins))
+
+	// Tag each request with an ID for the logs and calls to other services
+	router.Use(middleware.RequestID)
+
+	// Name the running build on every response
+	router.Use(middleware.ServiceVersion)
+
+	// Send errors as problem details to clients that ask for application/problem+json
+	router.Use(middleware.ProblemDetails)
+
+	// Add logging middleware
+	router.Use(middleware.Logging)
+
+	// Count and time requests by route for /metrics
+	router.Use(middleware.Metrics)
+
+	// Record which service is calling when a service key is presented
+	router.Use(serviceKeys.Authenticate)
+
+	// Limit how fast each client may call: per calling service, else per IP
+	router.Use(middleware.RateLimit("global", middleware.RateLimiter(reloader, "RATE_LIMIT", 100, 600), middleware.RateLimiter(reloader, "RATE_LIMIT_SERVICE", 1000, 6000), auth.ServiceFromContext))
+
+	// API routes live under a version prefix; operational endpoints stay at the root
+	v1 := router.PathPrefix("/v1").Subrouter()
+
+	// Turn API requests away with 503 once too many are in flight, rather than letting a spike pile up
+	v1.Use(middleware.LoadShedder(reloader).Middleware)
+
+	// Payment routes are for other services, such as order service, and need a service key
+	v1.Handle("/payments", serviceKeys.RequireService(http.HandlerFunc(paymentHandler.CreatePayment))).Methods("POST")
+	v1.Handle("/payments", serviceKeys.RequireService(http.HandlerFunc(paymentHandler.ListPayments))).Methods("GET")
+	v1.Handle("/payments/{id}", serviceKeys.RequireService(http.HandlerFunc(paymentHandler.GetPayment))).Methods("GET")
+	v1.Handle("/payments/{id}/capture", serviceKeys.RequireService(http.HandlerFunc(paymentHandler.CapturePayment))).Methods("POST")
+	v1.Handle("/payments/{id}/refund", serviceKeys.RequireService(http.HandlerFunc(paymentHandler.RefundPayment))).Methods("POST")
+
+	// Payment provider callbacks, authenticated by the provider's signature
+	v1.HandleFunc("/payments/webhook", paymentHandler.Webhook).Methods("POST")
+
+	// Service metrics; the expvar counters are on the diagnostics port
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// Liveness and readiness probes
+	router.HandleFunc("/healthz", probes.Liveness).Methods("GET")
+	router.HandleFunc("/readyz", probes.Readiness).Methods("GET")
+
+	return router
+}
+
+// setupProvider configures payments from PAYMENT_PROVIDER: "mock" (the default) settles payments
+// locally, and "stripe" uses STRIPE_SECRET_KEY and STRIPE_WEBHOOK_SECRET
+func setupProvider(cfg *config.Config) provider.Provider {
+	switch name := cfg.String("PAYMENT_PROVIDER", "mock"); name {
+	case "mock":
+		slog.Warn("Payments are settled by the mock provider; set PAYMENT_PROVIDER=stripe in production")
+		return provider.NewMockProvider()
+	case "stripe":
+		secretKey, webhookSecret := cfg.String("STRIPE_SECRET_KEY", ""), cfg.String("STRIPE_WEBHOOK_SECRET", "")
+		if secretKey == "" || webhookSecret == "" {
+			logging.Fatal("Invalid payment configuration: STRIPE_SECRET_KEY and STRIPE_WEBHOOK_SECRET are required")
+		}
+		return provider.NewStripeProvider(secretKey, webhookSecret)
+	default:
+		logging.Fatal("Invalid PAYMENT_PROVIDER", "provider", name)
+		return nil
+	}
+}
+
+// setupBroker configures the message broker from BROKER: "log" (the default) writes events to the
+// service log, and "kafka-rest" produces them to PAYMENT_EVENTS_TOPIC through the Kafka REST Proxy at KAFKA_REST_URL
+func setupBroker(cfg *config.Config) outbox.Broker {
+	switch broker := cfg.String("BROKER", "log"); broker {
+	case "log":
+		return outbox.LogBroker{}
+	case "kafka-rest":
+		proxyURL := cfg.String("KAFKA_REST_URL", "")
+		if proxyURL == "" {
+			logging.Fatal("Invalid broker configuration: KAFKA_REST_URL is required")
+		}
+		return outbox.NewKafkaRESTBroker(proxyURL, cfg.String("PAYMENT_EVENTS_TOPIC", events.DefaultPaymentTopic))
+	default:
+		logging.Fatal("Invalid BROKER", "broker", broker)
+		return nil
+	}
+}
+
+// publishEvents publishes the payment events waiting in the outbox
+func publishEvents(paymentEvents *outbox.Outbox) func(ctx context.Context, now time.Time) error {
+	return func(ctx context.Context, now time.Time) error {
+		_, err := paymentEvents.PublishPending(ctx)
+		return err
+	}
+}
+
+// serviceURL reads the base URL of a service's REST API from the setting key names
+func serviceURL(cfg *config.Config, key, defaultURL string) string {
+	raw := cfg.String(key, defaultURL)
+	upstream, err := url.Parse(raw)
+	if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+		logging.Fatal("Invalid service URL", "key", key, "url", raw)
+	}
+	return raw
+}
@@ -0,0 +1,15 @@
+package handlers
+
+// Error codes the payment service sends in the code of an error response, so callers can tell
+// failures apart without matching messages. Errors without one of these get a code named after
+// their status, such as NOT_FOUND.
+const (
+	CodePaymentNotFound      = "PAYMENT_NOT_FOUND"
+	CodePaymentDeclined      = "PAYMENT_DECLINED"
+	CodePaymentNotCapturable = "PAYMENT_NOT_CAPTURABLE" // only authorized payments can be captured
+	CodePaymentNotRefundable = "PAYMENT_NOT_REFUNDABLE" // only paid payments can be refunded
+	CodeAmountTooLarge       = "AMOUNT_TOO_LARGE"       // more than the payment has left to capture or refund
+	CodeOperationInProgress  = "OPERATION_IN_PROGRESS"  // another capture or refund of the payment hasn't finished
+	CodeProviderUnavailable  = "PROVIDER_UNAVAILABLE"   // the payment provider couldn't be reached or failed
+	CodeIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED" // the order's payment with this key was for something else
+)
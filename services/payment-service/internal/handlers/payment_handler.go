@@ -0,0 +1,398 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"ecommerce/pkg/api"
+	"payment-service/internal/models"
+	"payment-service/internal/outbox"
+	"payment-service/internal/provider"
+	"payment-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// maxWebhookBytes caps the size of a provider notification
+const maxWebhookBytes = 64 * 1024
+
+// errStatusChanged is returned from an update when a notification doesn't move the payment on
+var errStatusChanged = errors.New("payment status changed")
+
+// PaymentHandler serves payments: taking them through the provider, capturing and refunding them,
+// and recording what the provider reports. Every change is published as a payment event.
+type PaymentHandler struct {
+	repo     repository.PaymentRepository
+	provider provider.Provider
+	events   *outbox.Outbox
+}
+
+// NewPaymentHandler creates a new payment handler
+func NewPaymentHandler(repo repository.PaymentRepository, payments provider.Provider, events *outbox.Outbox) *PaymentHandler {
+	return &PaymentHandler{repo: repo, provider: payments, events: events}
+}
+
+// CreatePayment handles POST /payments - takes a payment for an order, or with "capture": false only
+// authorizes it. A declined payment is recorded as failed and answered with 402. A request sent with
+// an Idempotency-Key header the order already has a payment for takes nothing; it is answered with
+// that payment instead, so a client can safely retry a request whose answer it never got.
+func (h *PaymentHandler) CreatePayment(w http.ResponseWriter, r *http.Request) {
+	var req models.CreatePaymentRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+	req.Currency = strings.ToUpper(req.Currency)
+	if req.Currency == "" {
+		req.Currency = models.DefaultCurrency
+	}
+
+	payment := models.NewPayment(req, h.provider.Name())
+	payment.IdempotencyKey = r.Header.Get(api.IdempotencyKeyHeader)
+	if err := h.repo.Create(payment); errors.Is(err, repository.ErrDuplicatePayment) {
+		h.replayPayment(w, r, payment)
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating payment", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to create payment")
+		return
+	}
+
+	// Once the provider is asked, its answer is recorded even if the caller has gone
+	ctx := context.WithoutCancel(r.Context())
+	intent, err := h.provider.CreateIntent(ctx, provider.IntentRequest{
+		PaymentID:     payment.ID,
+		OrderID:       payment.OrderID,
+		UserID:        payment.UserID,
+		Amount:        payment.Amount,
+		Currency:      payment.Currency,
+		PaymentMethod: payment.PaymentMethod,
+		Capture:       req.Capture == nil || *req.Capture,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Taking payment failed", "payment_id", payment.ID, "order_id", payment.OrderID, "error", err)
+		failed, updateErr := h.update(payment.ID, func(payment *models.Payment) error {
+			payment.FailureReason = err.Error()
+			payment.SetStatus(models.PaymentFailed)
+			return nil
+		})
+		if updateErr != nil {
+			slog.ErrorContext(r.Context(), "Error recording failed payment", "payment_id", payment.ID, "error", updateErr)
+		}
+		if errors.Is(err, provider.ErrDeclined) {
+			api.WriteErrorResponse(w, http.StatusPaymentRequired, models.Response{
+				Success: false,
+				Code:    CodePaymentDeclined,
+				Error:   "Payment was declined",
+				Data:    failed,
+			})
+			return
+		}
+		api.WriteErrorCode(w, http.StatusServiceUnavailable, CodeProviderUnavailable, "Unable to take payment")
+		return
+	}
+
+	taken, err := h.update(payment.ID, func(payment *models.Payment) error {
+		payment.ProviderPaymentID = intent.ProviderPaymentID
+		payment.SetStatus(intent.Status)
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error recording payment", "payment_id", payment.ID, "provider_payment_id", intent.ProviderPaymentID, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to record payment")
+		return
+	}
+
+	api.WriteJSON(w, http.StatusCreated, models.Response{
+		Success: true,
+		Message: "Payment submitted successfully",
+		Data:    taken,
+	})
+}
+
+// replayPayment answers a retried request with the payment the first attempt created, as it now
+// stands: 402 if it failed, 201 otherwise, even while the provider is still being asked. A retry
+// asking for a different payment than the first attempt did is refused.
+func (h *PaymentHandler) replayPayment(w http.ResponseWriter, r *http.Request, retry *models.Payment) {
+	payment, err := h.repo.GetByIdempotencyKey(retry.OrderID, retry.IdempotencyKey)
+	if err != nil {
+		h.writeRepoError(w, r, err)
+		return
+	}
+	if payment.Amount != retry.Amount || payment.Currency != retry.Currency || payment.PaymentMethod != retry.PaymentMethod || payment.UserID != retry.UserID {
+		api.WriteErrorCode(w, http.StatusConflict, CodeIdempotencyKeyReused, "Idempotency key was already used for a different payment of the order")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Answering retried payment with the existing one", "payment_id", payment.ID, "order_id", payment.OrderID, "status", payment.Status)
+	if payment.Status == models.PaymentFailed {
+		api.WriteErrorResponse(w, http.StatusPaymentRequired, models.Response{
+			Success: false,
+			Code:    CodePaymentDeclined,
+			Error:   "Payment failed",
+			Data:    payment,
+		})
+		return
+	}
+	api.WriteJSON(w, http.StatusCreated, models.Response{
+		Success: true,
+		Message: "Payment already submitted",
+		Data:    payment,
+	})
+}
+
+// GetPayment handles GET /payments/{id}
+func (h *PaymentHandler) GetPayment(w http.ResponseWriter, r *http.Request) {
+	payment, err := h.repo.Get(mux.Vars(r)["id"])
+	if err != nil {
+		h.writeRepoError(w, r, err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, models.Response{Success: true, Data: payment})
+}
+
+// ListPayments handles GET /payments?order_id= - an order's payments, oldest first
+func (h *PaymentHandler) ListPayments(w http.ResponseWriter, r *http.Request) {
+	orderID := r.URL.Query().Get("order_id")
+	if orderID == "" {
+		api.WriteError(w, http.StatusBadRequest, "order_id is required")
+		return
+	}
+	payments, err := h.repo.ListByOrder(orderID)
+	if err != nil {
+		h.writeRepoError(w, r, err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, models.Response{Success: true, Data: payments})
+}
+
+// CapturePayment handles POST /payments/{id}/capture - takes an authorized payment, in full or, with
+// amount, in part, releasing the rest
+func (h *PaymentHandler) CapturePayment(w http.ResponseWriter, r *http.Request) {
+	var req models.CapturePaymentRequest
+	if !api.DecodeOptionalJSON(w, r, &req) {
+		return
+	}
+
+	payment, operation, ok := h.claim(w, r, models.OperationCapture, func(payment *models.Payment) (float64, error) {
+		if payment.Status != models.PaymentAuthorized {
+			return 0, &claimError{http.StatusConflict, CodePaymentNotCapturable, fmt.Sprintf("Payment cannot be captured (status %s)", payment.Status)}
+		}
+		amount := models.RoundCents(req.Amount)
+		if amount == 0 {
+			amount = payment.Amount
+		}
+		if amount > payment.Amount {
+			return 0, &claimError{http.StatusBadRequest, CodeAmountTooLarge, fmt.Sprintf("At most %.2f can be captured", payment.Amount)}
+		}
+		return amount, nil
+	})
+	if !ok {
+		return
+	}
+
+	if err := h.provider.Capture(context.WithoutCancel(r.Context()), payment.ProviderPaymentID, operation.Amount, operation.IdempotencyKey); err != nil {
+		slog.ErrorContext(r.Context(), "Capturing payment failed", "payment_id", payment.ID, "error", err)
+		h.release(r, payment.ID, operation)
+		api.WriteErrorCode(w, http.StatusServiceUnavailable, CodeProviderUnavailable, "Unable to capture payment")
+		return
+	}
+
+	payment, err := h.update(payment.ID, func(payment *models.Payment) error {
+		payment.Finish(operation.IdempotencyKey)
+		payment.Capture(operation.Amount)
+		return nil
+	})
+	if err != nil {
+		h.writeRepoError(w, r, err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, models.Response{Success: true, Message: "Payment captured", Data: payment})
+}
+
+// RefundPayment handles POST /payments/{id}/refund - gives back what is left of a paid payment, or
+// amount of it. Once nothing is left the payment is refunded.
+func (h *PaymentHandler) RefundPayment(w http.ResponseWriter, r *http.Request) {
+	var req models.RefundPaymentRequest
+	if !api.DecodeOptionalJSON(w, r, &req) {
+		return
+	}
+
+	payment, operation, ok := h.claim(w, r, models.OperationRefund, func(payment *models.Payment) (float64, error) {
+		if payment.Status != models.PaymentPaid {
+			return 0, &claimError{http.StatusConflict, CodePaymentNotRefundable, fmt.Sprintf("Payment cannot be refunded (status %s)", payment.Status)}
+		}
+		amount := models.RoundCents(req.Amount)
+		if amount == 0 {
+			amount = payment.Refundable()
+		}
+		if amount > payment.Refundable() {
+			return 0, &claimError{http.StatusBadRequest, CodeAmountTooLarge, fmt.Sprintf("At most %.2f can be refunded", payment.Refundable())}
+		}
+		return amount, nil
+	})
+	if !ok {
+		return
+	}
+
+	if err := h.provider.Refund(context.WithoutCancel(r.Context()), payment.ProviderPaymentID, operation.Amount, operation.IdempotencyKey); err != nil {
+		slog.ErrorContext(r.Context(), "Refunding payment failed", "payment_id", payment.ID, "error", err)
+		h.release(r, payment.ID, operation)
+		api.WriteErrorCode(w, http.StatusServiceUnavailable, CodeProviderUnavailable, "Unable to refund payment")
+		return
+	}
+
+	payment, err := h.repo.Update(payment.ID, func(payment *models.Payment) error {
+		payment.Finish(operation.IdempotencyKey)
+		payment.AddRefund(operation.Amount, req.Reason)
+		return nil
+	})
+	if err != nil {
+		h.writeRepoError(w, r, err)
+		return
+	}
+	h.events.Add(payment.RefundEvent())
+	api.WriteJSON(w, http.StatusOK, models.Response{Success: true, Message: "Payment refunded", Data: payment})
+}
+
+// claimError is a capture or refund that can't be claimed, with the response to send for it
+type claimError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *claimError) Error() string { return e.message }
+
+// claim starts a capture or refund of the payment in the request. check decides the amount from the
+// payment as it is under the repository's lock, so a payment changed since the caller last saw it
+// can't be captured or refunded from stale figures. It writes the response and reports false when
+// the operation can't start.
+func (h *PaymentHandler) claim(w http.ResponseWriter, r *http.Request, kind string, check func(payment *models.Payment) (float64, error)) (*models.Payment, models.Operation, bool) {
+	var operation models.Operation
+	payment, err := h.repo.Update(mux.Vars(r)["id"], func(payment *models.Payment) error {
+		amount, err := check(payment)
+		if err != nil {
+			return err
+		}
+		operation, err = payment.Claim(kind, amount, time.Now())
+		return err
+	})
+	var rejected *claimError
+	switch {
+	case err == nil:
+		return payment, operation, true
+	case errors.As(err, &rejected):
+		api.WriteErrorCode(w, rejected.status, rejected.code, rejected.message)
+	case errors.Is(err, models.ErrOperationInProgress):
+		api.WriteErrorCode(w, http.StatusConflict, CodeOperationInProgress, "Another capture or refund of the payment is in progress")
+	default:
+		h.writeRepoError(w, r, err)
+	}
+	return nil, operation, false
+}
+
+// release ends an operation the provider didn't carry out, so the payment can be captured or refunded
+// again. If it can't be recorded the claim lapses after models.OperationTimeout.
+func (h *PaymentHandler) release(r *http.Request, id string, operation models.Operation) {
+	_, err := h.repo.Update(id, func(payment *models.Payment) error {
+		payment.Finish(operation.IdempotencyKey)
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error releasing payment operation", "payment_id", id, "operation", operation.Kind, "error", err)
+	}
+}
+
+// Webhook handles POST /payments/webhook - records what the provider reports about payments it
+// settles asynchronously. Notifications for unknown payments, or that would take a payment back a
+// step, are acknowledged and ignored so the provider stops retrying.
+func (h *PaymentHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBytes))
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Unable to read payload")
+		return
+	}
+
+	notification, err := h.provider.ParseWebhook(payload, r.Header.Get("Stripe-Signature"))
+	if err != nil {
+		slog.InfoContext(r.Context(), "Rejected payment webhook", "error", err)
+		if errors.Is(err, provider.ErrInvalidSignature) {
+			api.WriteError(w, http.StatusBadRequest, "Invalid signature")
+			return
+		}
+		api.WriteError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+
+	if notification != nil {
+		if err := h.applyNotification(r.Context(), notification); err != nil {
+			slog.ErrorContext(r.Context(), "Error recording payment notification", "provider_payment_id", notification.ProviderPaymentID, "error", err)
+			api.WriteError(w, http.StatusInternalServerError, "Failed to record notification")
+			return
+		}
+	}
+
+	api.WriteJSON(w, http.StatusOK, models.Response{Success: true, Message: "Webhook received"})
+}
+
+// applyNotification records a provider's report on its payment
+func (h *PaymentHandler) applyNotification(ctx context.Context, notification *provider.Notification) error {
+	payment, err := h.repo.GetByProviderID(notification.ProviderPaymentID)
+	if err != nil {
+		slog.InfoContext(ctx, "Notification for unknown payment", "provider_payment_id", notification.ProviderPaymentID, "status", notification.Status)
+		return nil
+	}
+
+	_, err = h.update(payment.ID, func(payment *models.Payment) error {
+		if !advances(payment.Status, notification.Status) {
+			return errStatusChanged
+		}
+		payment.FailureReason = notification.FailureReason
+		payment.SetStatus(notification.Status)
+		return nil
+	})
+	if errors.Is(err, errStatusChanged) {
+		slog.InfoContext(ctx, "Ignoring notification that doesn't move the payment on", "payment_id", payment.ID, "status", notification.Status, "payment_status", payment.Status)
+		return nil
+	}
+	return err
+}
+
+// advances reports whether a provider's report of status moves a payment at from forward. Reports can
+// arrive out of order, so a processing notice doesn't undo an authorization or a payment that already
+// succeeded, and nothing changes a payment once it is settled.
+func advances(from, status models.PaymentStatus) bool {
+	switch from {
+	case models.PaymentPending:
+		return status != models.PaymentPending
+	case models.PaymentAuthorized:
+		return status == models.PaymentPaid || status == models.PaymentFailed
+	default:
+		return false
+	}
+}
+
+// update changes a payment in the repository and publishes the status it is left in
+func (h *PaymentHandler) update(id string, change func(payment *models.Payment) error) (*models.Payment, error) {
+	payment, err := h.repo.Update(id, change)
+	if err != nil {
+		return nil, err
+	}
+	h.events.Add(payment.StatusEvent())
+	return payment, nil
+}
+
+// writeRepoError sends the response for an error from the repository
+func (h *PaymentHandler) writeRepoError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, repository.ErrPaymentNotFound) {
+		api.WriteErrorCode(w, http.StatusNotFound, CodePaymentNotFound, "Payment not found")
+		return
+	}
+	slog.ErrorContext(r.Context(), "Error accessing payments", "error", err)
+	api.WriteError(w, http.StatusInternalServerError, "Failed to access payments")
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"ecommerce/pkg/api"
+	"ecommerce/pkg/events"
+	"payment-service/internal/models"
+	"payment-service/internal/outbox"
+	"payment-service/internal/provider"
+	"payment-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// recordingBroker keeps the events published to it
+type recordingBroker struct {
+	events []*events.PaymentEvent
+}
+
+func (b *recordingBroker) Publish(ctx context.Context, event *events.PaymentEvent) error {
+	b.events = append(b.events, event)
+	return nil
+}
+
+// types publishes what is waiting in the outbox and returns the types published, then forgets them
+func (b *recordingBroker) types(t *testing.T, queue *outbox.Outbox) []string {
+	t.Helper()
+	if _, err := queue.PublishPending(context.Background()); err != nil {
+		t.Fatalf("publishing events: %v", err)
+	}
+	types := []string{}
+	for _, event := range b.events {
+		types = append(types, event.Type)
+	}
+	b.events = nil
+	return types
+}
+
+func newTestHandler() (*PaymentHandler, *repository.InMemoryPaymentRepository, *outbox.Outbox, *recordingBroker) {
+	repo := repository.NewInMemoryPaymentRepository()
+	broker := &recordingBroker{}
+	queue := outbox.New(broker)
+	return NewPaymentHandler(repo, provider.NewMockProvider(), queue), repo, queue, broker
+}
+
+// call runs handler with body and the route's vars, decoding the payment it answers with
+func call(t *testing.T, handler http.HandlerFunc, body string, vars map[string]string) (*httptest.ResponseRecorder, models.Payment) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/payments", bytes.NewBufferString(body)), vars))
+	var response struct {
+		Data models.Payment `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	return rec, response.Data
+}
+
+func equal(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPaymentHandler_TakesAndRefundsPayments(t *testing.T) {
+	h, _, queue, broker := newTestHandler()
+
+	rec, payment := call(t, h.CreatePayment, `{"order_id":"o1","user_id":"u1","amount":40,"currency":"eur","payment_method":"pm_card_visa"}`, nil)
+	if rec.Code != http.StatusCreated || payment.Status != models.PaymentPaid || payment.Currency != "EUR" || payment.AmountCaptured != 40 || payment.ProviderPaymentID == "" {
+		t.Fatalf("expected a paid payment, got %d %s", rec.Code, rec.Body.String())
+	}
+	if types := broker.types(t, queue); !equal(types, []string{events.PaymentSucceeded}) {
+		t.Fatalf("expected payment.succeeded published, got %v", types)
+	}
+	vars := map[string]string{"id": payment.ID}
+
+	rec, payment = call(t, h.RefundPayment, `{"amount":15,"reason":"damaged"}`, vars)
+	if rec.Code != http.StatusOK || payment.Status != models.PaymentPaid || payment.AmountRefunded != 15 || len(payment.Refunds) != 1 {
+		t.Fatalf("expected a partial refund, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec, _ := call(t, h.RefundPayment, `{"amount":30}`, vars); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 refunding more than is left, got %d", rec.Code)
+	}
+
+	rec, payment = call(t, h.RefundPayment, "", vars)
+	if rec.Code != http.StatusOK || payment.Status != models.PaymentRefunded || payment.AmountRefunded != 40 {
+		t.Fatalf("expected the rest refunded, got %d %s", rec.Code, rec.Body.String())
+	}
+	if types := broker.types(t, queue); !equal(types, []string{events.PaymentRefunded, events.PaymentRefunded}) {
+		t.Fatalf("expected a payment.refunded for each refund, got %v", types)
+	}
+	if rec, _ := call(t, h.RefundPayment, "", vars); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 refunding a refunded payment, got %d", rec.Code)
+	}
+}
+
+func TestPaymentHandler_RecordsDeclinedPayments(t *testing.T) {
+	h, repo, queue, broker := newTestHandler()
+
+	rec, payment := call(t, h.CreatePayment, `{"order_id":"o1","amount":10,"payment_method":"`+provider.MockDeclinedMethod+`"}`, nil)
+	if rec.Code != http.StatusPaymentRequired || payment.Status != models.PaymentFailed {
+		t.Fatalf("expected 402 with the failed payment, got %d %s", rec.Code, rec.Body.String())
+	}
+	if payments, _ := repo.ListByOrder("o1"); len(payments) != 1 || payments[0].FailureReason == "" {
+		t.Fatalf("expected the declined payment kept with its reason, got %+v", payments)
+	}
+	if types := broker.types(t, queue); !equal(types, []string{events.PaymentFailed}) {
+		t.Errorf("expected payment.failed published, got %v", types)
+	}
+
+	if rec, _ := call(t, h.CreatePayment, `{"order_id":"o1","amount":0,"payment_method":"pm_card_visa"}`, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for no amount, got %d", rec.Code)
+	}
+}
+
+func TestPaymentHandler_AnswersRetriesWithTheFirstPayment(t *testing.T) {
+	h, repo, queue, broker := newTestHandler()
+	create := func(body, key string) (*httptest.ResponseRecorder, models.Payment) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/payments", bytes.NewBufferString(body))
+		req.Header.Set(api.IdempotencyKeyHeader, key)
+		h.CreatePayment(rec, req)
+		var response struct {
+			Data models.Payment `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		return rec, response.Data
+	}
+	body := `{"order_id":"o1","amount":40,"payment_method":"pm_card_visa"}`
+
+	_, first := create(body, "attempt-1")
+	rec, retried := create(body, "attempt-1")
+	if rec.Code != http.StatusCreated || retried.ID != first.ID || retried.Status != models.PaymentPaid {
+		t.Fatalf("expected the retry answered with the first payment %s, got %d %s", first.ID, rec.Code, rec.Body.String())
+	}
+	if payments, _ := repo.ListByOrder("o1"); len(payments) != 1 {
+		t.Fatalf("expected the retry to take nothing, got %d payments", len(payments))
+	}
+	if types := broker.types(t, queue); !equal(types, []string{events.PaymentSucceeded}) {
+		t.Fatalf("expected one payment.succeeded, got %v", types)
+	}
+
+	if rec, _ := create(`{"order_id":"o1","amount":55,"payment_method":"pm_card_visa"}`, "attempt-1"); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 reusing the key for another amount, got %d", rec.Code)
+	}
+	if rec, other := create(`{"order_id":"o2","amount":40,"payment_method":"pm_card_visa"}`, "attempt-1"); rec.Code != http.StatusCreated || other.ID == first.ID {
+		t.Errorf("expected keys to be per order, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	declined := `{"order_id":"o1","amount":40,"payment_method":"` + provider.MockDeclinedMethod + `"}`
+	_, failed := create(declined, "attempt-2")
+	if rec, retried := create(declined, "attempt-2"); rec.Code != http.StatusPaymentRequired || retried.ID != failed.ID {
+		t.Errorf("expected the retry of a declined payment answered with it, got %d %s", rec.Code, rec.Body.String())
+	}
+	if payments, _ := repo.ListByOrder("o1"); len(payments) != 2 {
+		t.Errorf("expected two payments for the order, got %d", len(payments))
+	}
+}
+
+func TestPaymentHandler_CapturesAuthorizedPayments(t *testing.T) {
+	h, _, queue, broker := newTestHandler()
+
+	rec, payment := call(t, h.CreatePayment, `{"order_id":"o1","amount":25,"payment_method":"pm_card_visa","capture":false}`, nil)
+	if rec.Code != http.StatusCreated || payment.Status != models.PaymentAuthorized || payment.AmountCaptured != 0 {
+		t.Fatalf("expected an authorized payment, got %d %s", rec.Code, rec.Body.String())
+	}
+	vars := map[string]string{"id": payment.ID}
+
+	if rec, _ := call(t, h.RefundPayment, "", vars); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 refunding an uncaptured payment, got %d", rec.Code)
+	}
+	if rec, _ := call(t, h.CapturePayment, `{"amount":30}`, vars); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 capturing more than was authorized, got %d", rec.Code)
+	}
+
+	rec, payment = call(t, h.CapturePayment, `{"amount":20}`, vars)
+	if rec.Code != http.StatusOK || payment.Status != models.PaymentPaid || payment.AmountCaptured != 20 || payment.Refundable() != 20 {
+		t.Fatalf("expected 20 captured, got %d %s", rec.Code, rec.Body.String())
+	}
+	if types := broker.types(t, queue); !equal(types, []string{events.PaymentAuthorized, events.PaymentSucceeded}) {
+		t.Fatalf("expected payment.authorized then payment.succeeded, got %v", types)
+	}
+	if rec, _ := call(t, h.CapturePayment, "", vars); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 capturing twice, got %d", rec.Code)
+	}
+}
+
+// refundingProvider is the mock provider with refunds that record their idempotency keys, fail with err,
+// and, while release is set, wait for it after saying they started on entered
+type refundingProvider struct {
+	*provider.MockProvider
+	keys    []string
+	err     error
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (p *refundingProvider) Refund(ctx context.Context, providerPaymentID string, amount float64, idempotencyKey string) error {
+	p.keys = append(p.keys, idempotencyKey)
+	if p.release != nil {
+		p.entered <- struct{}{}
+		<-p.release
+	}
+	return p.err
+}
+
+func TestPaymentHandler_RefundsOneAtATime(t *testing.T) {
+	payments := &refundingProvider{MockProvider: provider.NewMockProvider(), err: errors.New("connection reset")}
+	repo := repository.NewInMemoryPaymentRepository()
+	h := NewPaymentHandler(repo, payments, outbox.New(&recordingBroker{}))
+
+	_, payment := call(t, h.CreatePayment, `{"order_id":"o1","amount":40,"payment_method":"pm_card_visa"}`, nil)
+	vars := map[string]string{"id": payment.ID}
+
+	if rec, _ := call(t, h.RefundPayment, `{"amount":10}`, vars); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 when the provider fails, got %d", rec.Code)
+	}
+	if stored, _ := repo.Get(payment.ID); stored.InProgress != nil || stored.AmountRefunded != 0 {
+		t.Fatalf("expected the failed refund released and unrecorded, got %+v", stored)
+	}
+
+	payments.err = nil
+	payments.entered = make(chan struct{})
+	payments.release = make(chan struct{})
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rec, _ := call(t, h.RefundPayment, `{"amount":10}`, vars)
+		done <- rec
+	}()
+	<-payments.entered
+
+	if rec, _ := call(t, h.RefundPayment, `{"amount":30}`, vars); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 refunding while another refund is in progress, got %d", rec.Code)
+	}
+	close(payments.release)
+	if rec := <-done; rec.Code != http.StatusOK {
+		t.Fatalf("expected the refund to finish, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	key := payment.ID + "-refund-1"
+	if !equal(payments.keys, []string{key, key}) {
+		t.Errorf("expected the retried refund to reuse its idempotency key, got %v", payments.keys)
+	}
+	if stored, _ := repo.Get(payment.ID); stored.InProgress != nil || stored.AmountRefunded != 10 || len(stored.Refunds) != 1 {
+		t.Errorf("expected one refund of 10 recorded, got %+v", stored)
+	}
+}
+
+func TestPaymentHandler_WebhookSettlesPendingPayments(t *testing.T) {
+	h, repo, queue, broker := newTestHandler()
+	_, payment := call(t, h.CreatePayment, `{"order_id":"o1","amount":10,"payment_method":"`+provider.MockPendingMethod+`"}`, nil)
+	if payment.Status != models.PaymentPending {
+		t.Fatalf("expected a pending payment, got %s", payment.Status)
+	}
+	broker.types(t, queue)
+
+	notify := func(status models.PaymentStatus) int {
+		body, _ := json.Marshal(provider.Notification{ProviderPaymentID: payment.ProviderPaymentID, Status: status})
+		rec, _ := call(t, h.Webhook, string(body), nil)
+		return rec.Code
+	}
+
+	if code := notify(models.PaymentPaid); code != http.StatusOK {
+		t.Fatalf("expected the notification accepted, got %d", code)
+	}
+	// A late processing notice doesn't undo the payment
+	if code := notify(models.PaymentPending); code != http.StatusOK {
+		t.Fatalf("expected the late notice acknowledged, got %d", code)
+	}
+	if stored, _ := repo.Get(payment.ID); stored.Status != models.PaymentPaid || stored.AmountCaptured != 10 {
+		t.Fatalf("expected the payment paid, got %+v", stored)
+	}
+	if types := broker.types(t, queue); !equal(types, []string{events.PaymentSucceeded}) {
+		t.Errorf("expected one payment.succeeded, got %v", types)
+	}
+
+	if rec, _ := call(t, h.Webhook, `{"provider_payment_id":"mock_unknown","status":"paid"}`, nil); rec.Code != http.StatusOK {
+		t.Errorf("expected notifications for unknown payments acknowledged, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+	"ecommerce/pkg/events"
+
+	"github.com/google/uuid"
+)
+
+// eventTypes names the event published when a payment reaches each status
+var eventTypes = map[PaymentStatus]string{
+	PaymentPending:    events.PaymentPending,
+	PaymentAuthorized: events.PaymentAuthorized,
+	PaymentPaid:       events.PaymentSucceeded,
+	PaymentFailed:     events.PaymentFailed,
+	PaymentRefunded:   events.PaymentRefunded,
+}
+
+// StatusEvent is the event reporting that the payment reached its current status
+func (p *Payment) StatusEvent() *events.PaymentEvent {
+	return p.event(eventTypes[p.Status])
+}
+
+// RefundEvent is the event reporting a refund; after a partial refund the payment is still paid
+func (p *Payment) RefundEvent() *events.PaymentEvent {
+	return p.event(events.PaymentRefunded)
+}
+
+// event is an event of eventType about the payment as it is now
+func (p *Payment) event(eventType string) *events.PaymentEvent {
+	return &events.PaymentEvent{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		PaymentID: p.ID,
+		OrderID:   p.OrderID,
+		Data: events.Payment{
+			ID:             p.ID,
+			OrderID:        p.OrderID,
+			UserID:         p.UserID,
+			Status:         string(p.Status),
+			Amount:         p.Amount,
+			AmountRefunded: p.AmountRefunded,
+			Currency:       p.Currency,
+		},
+		OccurredAt: time.Now(),
+	}
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"ecommerce/pkg/api"
+
+	"github.com/google/uuid"
+)
+
+// Response is the response envelope; payment lists aren't paginated
+type Response = api.Response[api.Unpaged]
+
+// DefaultCurrency is what payments are taken in when no currency is given
+const DefaultCurrency = "USD"
+
+// PaymentStatus is where a payment stands with the provider
+type PaymentStatus string
+
+// Payment states. A payment taken without capture is authorized until it is captured; pending ones are
+// settled later by a provider webhook. A paid payment stays paid while part of it is refunded.
+const (
+	PaymentPending    PaymentStatus = "pending"
+	PaymentAuthorized PaymentStatus = "authorized"
+	PaymentPaid       PaymentStatus = "paid"
+	PaymentFailed     PaymentStatus = "failed"
+	PaymentRefunded   PaymentStatus = "refunded"
+)
+
+// Payment is a payment intent taken for an order through a provider
+type Payment struct {
+	ID                string        `json:"id"`
+	OrderID           string        `json:"order_id"`
+	UserID            string        `json:"user_id,omitempty"`
+	Amount            float64       `json:"amount"`
+	AmountCaptured    float64       `json:"amount_captured"`
+	AmountRefunded    float64       `json:"amount_refunded"`
+	Currency          string        `json:"currency"`
+	PaymentMethod     string        `json:"payment_method"`
+	Provider          string        `json:"provider"`
+	ProviderPaymentID string        `json:"provider_payment_id,omitempty"`
+	Status            PaymentStatus `json:"status"`
+	FailureReason     string        `json:"failure_reason,omitempty"`
+	Refunds           []Refund      `json:"refunds"`
+	InProgress        *Operation    `json:"in_progress,omitempty"`     // the capture or refund the provider is being asked for
+	IdempotencyKey    string        `json:"idempotency_key,omitempty"` // the client's key for the request that created it; unique per order
+	CreatedAt         time.Time     `json:"created_at"`
+	UpdatedAt         time.Time     `json:"updated_at"`
+}
+
+// Refund is money given back from a payment
+type Refund struct {
+	Amount    float64   `json:"amount"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Operations a payment can have under way with its provider
+const (
+	OperationCapture = "capture"
+	OperationRefund  = "refund"
+)
+
+// OperationTimeout is how long a capture or refund may be under way before it is taken to have been
+// abandoned, by a service that stopped while waiting for the provider, and another may start
+const OperationTimeout = time.Minute
+
+// ErrOperationInProgress is returned when a capture or refund is claimed while another is under way
+var ErrOperationInProgress = errors.New("another capture or refund of the payment is in progress")
+
+// Operation is a capture or refund of a payment under way with its provider
+type Operation struct {
+	Kind           string    `json:"kind"` // capture or refund
+	Amount         float64   `json:"amount"`
+	IdempotencyKey string    `json:"idempotency_key"` // sent to the provider, so asking again can't capture or refund twice
+	StartedAt      time.Time `json:"started_at"`
+}
+
+// CreatePaymentRequest represents the request payload for taking a payment for an order
+type CreatePaymentRequest struct {
+	OrderID  string  `json:"order_id" validate:"required"`
+	UserID   string  `json:"user_id"`
+	Amount   float64 `json:"amount" validate:"gt=0"`
+	Currency string  `json:"currency" validate:"omitempty,len=3"`
+	// PaymentMethod is the provider's reference for the customer's card or wallet, such as a Stripe PaymentMethod ID
+	PaymentMethod string `json:"payment_method" validate:"required"`
+	// Capture takes the money at once; false only authorizes it, to be captured later. Defaults to true.
+	Capture *bool `json:"capture"`
+}
+
+// CapturePaymentRequest represents the request payload for capturing an authorized payment
+type CapturePaymentRequest struct {
+	Amount float64 `json:"amount" validate:"gte=0"` // 0 captures the whole authorization
+}
+
+// RefundPaymentRequest represents the request payload for refunding a payment
+type RefundPaymentRequest struct {
+	Amount float64 `json:"amount" validate:"gte=0"` // 0 refunds whatever is left
+	Reason string  `json:"reason" validate:"max=500"`
+}
+
+// NewPayment creates a pending payment for req through provider
+func NewPayment(req CreatePaymentRequest, provider string) *Payment {
+	now := time.Now()
+	return &Payment{
+		ID:            "pay_" + uuid.New().String(),
+		OrderID:       req.OrderID,
+		UserID:        req.UserID,
+		Amount:        RoundCents(req.Amount),
+		Currency:      req.Currency,
+		PaymentMethod: req.PaymentMethod,
+		Provider:      provider,
+		Status:        PaymentPending,
+		Refunds:       []Refund{},
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// Refundable is how much of the payment can still be refunded
+func (p *Payment) Refundable() float64 {
+	return RoundCents(p.AmountCaptured - p.AmountRefunded)
+}
+
+// SetStatus moves the payment to status; a paid payment counts its whole amount as captured
+func (p *Payment) SetStatus(status PaymentStatus) {
+	p.Status = status
+	if status == PaymentPaid && p.AmountCaptured == 0 {
+		p.AmountCaptured = p.Amount
+	}
+	p.UpdatedAt = time.Now()
+}
+
+// Capture records amount of an authorized payment taken
+func (p *Payment) Capture(amount float64) {
+	p.AmountCaptured = RoundCents(amount)
+	p.SetStatus(PaymentPaid)
+}
+
+// AddRefund records amount given back; once nothing is left the payment is refunded
+func (p *Payment) AddRefund(amount float64, reason string) {
+	p.Refunds = append(p.Refunds, Refund{Amount: RoundCents(amount), Reason: reason, CreatedAt: time.Now()})
+	p.AmountRefunded = RoundCents(p.AmountRefunded + amount)
+	if p.Refundable() <= 0 {
+		p.Status = PaymentRefunded
+	}
+	p.UpdatedAt = time.Now()
+}
+
+// Claim starts an operation of kind for amount, so no other capture or refund starts until it is
+// finished and two can't both be granted from the same amount. The idempotency key names the payment
+// and, for a refund, which refund it is, so the provider recognises one asked for again after a failure.
+func (p *Payment) Claim(kind string, amount float64, now time.Time) (Operation, error) {
+	if p.InProgress != nil && now.Sub(p.InProgress.StartedAt) < OperationTimeout {
+		return Operation{}, ErrOperationInProgress
+	}
+	key := p.ID + "-capture"
+	if kind == OperationRefund {
+		key = fmt.Sprintf("%s-refund-%d", p.ID, len(p.Refunds)+1)
+	}
+	p.InProgress = &Operation{Kind: kind, Amount: RoundCents(amount), IdempotencyKey: key, StartedAt: now}
+	return *p.InProgress, nil
+}
+
+// Finish ends the operation claimed with key; recording what it did is up to the caller
+func (p *Payment) Finish(key string) {
+	if p.InProgress != nil && p.InProgress.IdempotencyKey == key {
+		p.InProgress = nil
+	}
+}
+
+// Copy returns a copy of the payment that shares nothing with it
+func (p *Payment) Copy() *Payment {
+	copied := *p
+	copied.Refunds = append([]Refund{}, p.Refunds...)
+	if p.InProgress != nil {
+		operation := *p.InProgress
+		copied.InProgress = &operation
+	}
+	return &copied
+}
+
+// RoundCents rounds an amount to whole cents
+func RoundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
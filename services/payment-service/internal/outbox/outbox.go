@@ -0,0 +1,116 @@
+// Package outbox holds payment events until the message broker has accepted them
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+	"ecommerce/pkg/events"
+)
+
+// Broker is the message broker payment events are published to
+type Broker interface {
+	Publish(ctx context.Context, event *events.PaymentEvent) error
+}
+
+// LogBroker writes events to the service log. It stands in for a broker in development.
+type LogBroker struct{}
+
+// Publish logs the event
+func (LogBroker) Publish(ctx context.Context, event *events.PaymentEvent) error {
+	slog.InfoContext(ctx, "Payment event", "event_id", event.ID, "type", event.Type, "payment_id", event.PaymentID, "order_id", event.OrderID)
+	return nil
+}
+
+// KafkaRESTBroker publishes events to a Kafka topic through a Kafka REST Proxy. Records are keyed
+// by order ID so each order's payment events stay in order on one partition.
+type KafkaRESTBroker struct {
+	kafka *events.KafkaREST
+	topic string
+}
+
+// NewKafkaRESTBroker creates a broker that produces to topic through the REST Proxy at baseURL
+func NewKafkaRESTBroker(baseURL, topic string) *KafkaRESTBroker {
+	return &KafkaRESTBroker{
+		kafka: events.NewKafkaREST(baseURL),
+		topic: topic,
+	}
+}
+
+// Publish produces the event to the topic
+func (b *KafkaRESTBroker) Publish(ctx context.Context, event *events.PaymentEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return b.kafka.Publish(ctx, b.topic, event.OrderID, event)
+}
+
+// Outbox queues events and publishes them in the order they were added. An event leaves the queue
+// only after the broker has accepted it, so a broker outage delays events rather than losing them.
+// The queue is kept in memory, like the payments, so events still queued are lost with the instance.
+type Outbox struct {
+	broker  Broker
+	pending []*events.PaymentEvent
+	mutex   sync.Mutex // guards pending
+	publish sync.Mutex // lets one pass publish at a time, so events go out in order
+}
+
+// New creates an outbox publishing to broker
+func New(broker Broker) *Outbox {
+	return &Outbox{broker: broker}
+}
+
+// Add queues an event for the next pass
+func (o *Outbox) Add(event *events.PaymentEvent) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.pending = append(o.pending, event)
+}
+
+// Pending returns how many events are waiting to be published
+func (o *Outbox) Pending() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return len(o.pending)
+}
+
+// PublishPending publishes waiting events, oldest first, and reports how many went out. It stops at
+// the first event the broker refuses so later events don't overtake it; that event is retried on the
+// next pass.
+func (o *Outbox) PublishPending(ctx context.Context) (int, error) {
+	o.publish.Lock()
+	defer o.publish.Unlock()
+
+	published := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return published, err
+		}
+		o.mutex.Lock()
+		if len(o.pending) == 0 {
+			o.mutex.Unlock()
+			return published, nil
+		}
+		event := o.pending[0]
+		o.mutex.Unlock()
+
+		if err := o.broker.Publish(ctx, event); err != nil {
+			return published, fmt.Errorf("event %s: %w", event.ID, err)
+		}
+
+		o.mutex.Lock()
+		o.pending = o.pending[1:]
+		o.mutex.Unlock()
+		published++
+	}
+}
+
+// Drain publishes what is left in the outbox, for a clean shutdown; events the broker doesn't take
+// before ctx is done are lost
+func (o *Outbox) Drain(ctx context.Context) error {
+	if _, err := o.PublishPending(ctx); err != nil {
+		return fmt.Errorf("%d payment events still unpublished: %w", o.Pending(), err)
+	}
+	return nil
+}
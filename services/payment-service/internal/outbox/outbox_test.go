@@ -0,0 +1,52 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"ecommerce/pkg/events"
+)
+
+// flakyBroker refuses events while down, and keeps the IDs of those it accepts
+type flakyBroker struct {
+	down      bool
+	published []string
+}
+
+func (b *flakyBroker) Publish(ctx context.Context, event *events.PaymentEvent) error {
+	if b.down {
+		return errors.New("broker unavailable")
+	}
+	b.published = append(b.published, event.ID)
+	return nil
+}
+
+func TestOutbox_KeepsEventsUntilTheBrokerTakesThem(t *testing.T) {
+	broker := &flakyBroker{down: true}
+	queue := New(broker)
+	queue.Add(&events.PaymentEvent{ID: "e1"})
+	queue.Add(&events.PaymentEvent{ID: "e2"})
+
+	if published, err := queue.PublishPending(context.Background()); err == nil || published != 0 || queue.Pending() != 2 {
+		t.Fatalf("expected both events kept while the broker is down, got %d published, %d pending (%v)", published, queue.Pending(), err)
+	}
+
+	broker.down = false
+	if published, err := queue.PublishPending(context.Background()); err != nil || published != 2 || queue.Pending() != 0 {
+		t.Fatalf("expected both events published, got %d published, %d pending (%v)", published, queue.Pending(), err)
+	}
+	if len(broker.published) != 2 || broker.published[0] != "e1" || broker.published[1] != "e2" {
+		t.Errorf("expected the events published in order, got %v", broker.published)
+	}
+}
+
+func TestOutbox_DrainReportsEventsLeftBehind(t *testing.T) {
+	queue := New(&flakyBroker{down: true})
+	queue.Add(&events.PaymentEvent{ID: "e1"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := queue.Drain(ctx); err == nil || queue.Pending() != 1 {
+		t.Fatalf("expected an error naming the event left, got %v", err)
+	}
+}
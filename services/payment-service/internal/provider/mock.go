@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"payment-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Payment methods the mock provider treats specially; any other method is charged successfully
+const (
+	MockDeclinedMethod = "pm_card_declined"
+	MockPendingMethod  = "pm_card_pending"
+)
+
+// MockProvider settles payments without a payment processor, for development and tests.
+// Its webhooks are unsigned Notification JSON, so it must not be used in production.
+type MockProvider struct{}
+
+// NewMockProvider creates a mock payment provider
+func NewMockProvider() *MockProvider {
+	return &MockProvider{}
+}
+
+// Name is "mock"
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+// CreateIntent succeeds unless the payment method is MockDeclinedMethod; MockPendingMethod leaves the
+// payment pending until a webhook settles it
+func (p *MockProvider) CreateIntent(ctx context.Context, request IntentRequest) (*Intent, error) {
+	intent := &Intent{ProviderPaymentID: "mock_" + uuid.New().String(), Status: models.PaymentPaid}
+	switch {
+	case request.PaymentMethod == MockDeclinedMethod:
+		return nil, ErrDeclined
+	case request.PaymentMethod == MockPendingMethod:
+		intent.Status = models.PaymentPending
+	case !request.Capture:
+		intent.Status = models.PaymentAuthorized
+	}
+	return intent, nil
+}
+
+// Capture always succeeds
+func (p *MockProvider) Capture(ctx context.Context, providerPaymentID string, amount float64, idempotencyKey string) error {
+	return nil
+}
+
+// Refund always succeeds
+func (p *MockProvider) Refund(ctx context.Context, providerPaymentID string, amount float64, idempotencyKey string) error {
+	return nil
+}
+
+// ParseWebhook decodes a Notification; the signature is ignored
+func (p *MockProvider) ParseWebhook(payload []byte, signature string) (*Notification, error) {
+	var notification Notification
+	if err := json.Unmarshal(payload, &notification); err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
@@ -0,0 +1,56 @@
+// Package provider takes payments through payment processors, behind one interface the handlers use
+package provider
+
+import (
+	"context"
+	"errors"
+	"payment-service/internal/models"
+)
+
+// Provider takes, captures, and refunds payments through a payment processor.
+// Implemented by MockProvider and StripeProvider.
+type Provider interface {
+	// Name is what payments taken through the provider record as their provider
+	Name() string
+	// CreateIntent takes or, without Capture, authorizes a payment. A declined payment returns ErrDeclined.
+	CreateIntent(ctx context.Context, request IntentRequest) (*Intent, error)
+	// Capture takes amount of an authorized payment. Asking again with the same idempotencyKey
+	// doesn't capture again.
+	Capture(ctx context.Context, providerPaymentID string, amount float64, idempotencyKey string) error
+	// Refund gives amount of a captured payment back. Asking again with the same idempotencyKey
+	// doesn't refund again.
+	Refund(ctx context.Context, providerPaymentID string, amount float64, idempotencyKey string) error
+	// ParseWebhook verifies a provider notification and returns the payment change it reports,
+	// or nil for notifications that don't change a payment
+	ParseWebhook(payload []byte, signature string) (*Notification, error)
+}
+
+// IntentRequest describes a payment to take
+type IntentRequest struct {
+	PaymentID     string // the payment's ID here, which also keeps a retried request from paying twice
+	OrderID       string
+	UserID        string
+	Amount        float64
+	Currency      string
+	PaymentMethod string
+	Capture       bool
+}
+
+// Intent is a provider's answer to a payment request
+type Intent struct {
+	ProviderPaymentID string
+	Status            models.PaymentStatus // paid, authorized, or pending when the provider confirms asynchronously
+}
+
+// Notification is a provider's report that a payment changed
+type Notification struct {
+	ProviderPaymentID string               `json:"provider_payment_id"`
+	Status            models.PaymentStatus `json:"status"`
+	FailureReason     string               `json:"failure_reason,omitempty"`
+}
+
+// Payment errors
+var (
+	ErrDeclined         = errors.New("payment was declined")
+	ErrInvalidSignature = errors.New("webhook signature is invalid")
+)
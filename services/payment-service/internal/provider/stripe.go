@@ -0,0 +1,240 @@
+package provider
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"payment-service/internal/models"
+)
+
+// StripeAPIURL is the base URL of Stripe's API
+const StripeAPIURL = "https://api.stripe.com"
+
+// stripeWebhookTolerance is how old a webhook's signature timestamp may be, limiting replays
+const stripeWebhookTolerance = 5 * time.Minute
+
+// StripeProvider takes payments with Stripe PaymentIntents, confirmed as soon as they are created
+type StripeProvider struct {
+	httpClient    *http.Client
+	apiURL        string
+	secretKey     string
+	webhookSecret string
+}
+
+// NewStripeProvider creates a provider using the secret API key and the signing secret of the
+// webhook endpoint that receives payment_intent events
+func NewStripeProvider(secretKey, webhookSecret string) *StripeProvider {
+	return &StripeProvider{
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		apiURL:        StripeAPIURL,
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+	}
+}
+
+// stripePaymentIntent is the part of a Stripe PaymentIntent the provider needs
+type stripePaymentIntent struct {
+	ID               string `json:"id"`
+	Status           string `json:"status"`
+	LastPaymentError *struct {
+		Message string `json:"message"`
+	} `json:"last_payment_error"`
+}
+
+// stripeError is Stripe's error envelope
+type stripeError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Name is "stripe"
+func (p *StripeProvider) Name() string {
+	return "stripe"
+}
+
+// CreateIntent creates and confirms a PaymentIntent for the order, with manual capture when the
+// payment is only to be authorized. The payment's ID is the idempotency key, so Stripe answers a
+// retried request with the intent it already made.
+func (p *StripeProvider) CreateIntent(ctx context.Context, request IntentRequest) (*Intent, error) {
+	form := url.Values{}
+	form.Set("amount", stripeAmount(request.Amount))
+	form.Set("currency", strings.ToLower(request.Currency))
+	form.Set("payment_method", request.PaymentMethod)
+	form.Set("confirm", "true")
+	if !request.Capture {
+		form.Set("capture_method", "manual")
+	}
+	// Redirect-based methods can't complete without a return URL, so only offer the others
+	form.Set("automatic_payment_methods[enabled]", "true")
+	form.Set("automatic_payment_methods[allow_redirects]", "never")
+	form.Set("metadata[payment_id]", request.PaymentID)
+	form.Set("metadata[order_id]", request.OrderID)
+	form.Set("metadata[user_id]", request.UserID)
+
+	var intent stripePaymentIntent
+	if err := p.post(ctx, "/v1/payment_intents", request.PaymentID, form, &intent); err != nil {
+		return nil, err
+	}
+
+	status := stripeStatus(intent.Status)
+	if status == models.PaymentFailed {
+		return nil, ErrDeclined
+	}
+	return &Intent{ProviderPaymentID: intent.ID, Status: status}, nil
+}
+
+// Capture captures amount of a PaymentIntent made with manual capture; the rest of the
+// authorization is released
+func (p *StripeProvider) Capture(ctx context.Context, providerPaymentID string, amount float64, idempotencyKey string) error {
+	form := url.Values{}
+	form.Set("amount_to_capture", stripeAmount(amount))
+	return p.post(ctx, "/v1/payment_intents/"+url.PathEscape(providerPaymentID)+"/capture", idempotencyKey, form, nil)
+}
+
+// Refund refunds amount of a PaymentIntent
+func (p *StripeProvider) Refund(ctx context.Context, providerPaymentID string, amount float64, idempotencyKey string) error {
+	form := url.Values{}
+	form.Set("payment_intent", providerPaymentID)
+	form.Set("amount", stripeAmount(amount))
+	return p.post(ctx, "/v1/refunds", idempotencyKey, form, nil)
+}
+
+// ParseWebhook verifies the Stripe-Signature header and reports payment_intent.succeeded,
+// payment_intent.processing, payment_intent.amount_capturable_updated, and
+// payment_intent.payment_failed events
+func (p *StripeProvider) ParseWebhook(payload []byte, signature string) (*Notification, error) {
+	if err := p.verifySignature(payload, signature, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var event struct {
+		Type string `json:"type"`
+		Data struct {
+			Object stripePaymentIntent `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+
+	intent := event.Data.Object
+	notification := &Notification{ProviderPaymentID: intent.ID}
+	switch event.Type {
+	case "payment_intent.succeeded":
+		notification.Status = models.PaymentPaid
+	case "payment_intent.processing":
+		notification.Status = models.PaymentPending
+	case "payment_intent.amount_capturable_updated":
+		notification.Status = models.PaymentAuthorized
+	case "payment_intent.payment_failed":
+		notification.Status = models.PaymentFailed
+		if intent.LastPaymentError != nil {
+			notification.FailureReason = intent.LastPaymentError.Message
+		}
+	default:
+		return nil, nil
+	}
+	return notification, nil
+}
+
+// verifySignature checks a "t=<timestamp>,v1=<signature>" header against the payload
+func (p *StripeProvider) verifySignature(payload []byte, header string, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if now.Sub(time.Unix(seconds, 0)) > stripeWebhookTolerance {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(p.webhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+	for _, signature := range signatures {
+		if decoded, err := hex.DecodeString(signature); err == nil && hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// post sends a form-encoded request to the Stripe API, with idempotencyKey when it isn't empty, and
+// decodes the response into out (which may be nil). Card errors are reported as ErrDeclined.
+func (p *StripeProvider) post(ctx context.Context, path, idempotencyKey string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.apiURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.secretKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call Stripe: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr stripeError
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		if apiErr.Error.Type == "card_error" {
+			return fmt.Errorf("%w: %s", ErrDeclined, apiErr.Error.Message)
+		}
+		return fmt.Errorf("Stripe error (status %d): %s", resp.StatusCode, apiErr.Error.Message)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// stripeAmount formats an amount in Stripe's smallest currency unit
+func stripeAmount(amount float64) string {
+	return strconv.FormatInt(int64(math.Round(amount*100)), 10)
+}
+
+// stripeStatus maps a PaymentIntent status to a payment status
+func stripeStatus(status string) models.PaymentStatus {
+	switch status {
+	case "succeeded":
+		return models.PaymentPaid
+	case "requires_capture":
+		return models.PaymentAuthorized
+	case "processing":
+		return models.PaymentPending
+	default:
+		// requires_payment_method, requires_action, and canceled can't complete without the customer
+		return models.PaymentFailed
+	}
+}
@@ -0,0 +1,114 @@
+package provider
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+	"payment-service/internal/models"
+)
+
+func TestStripeProvider_CreateIntent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/payment_intents" || r.Header.Get("Authorization") != "Bearer sk_test" || r.Header.Get("Idempotency-Key") != "pay_1" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		r.ParseForm()
+		switch {
+		case r.PostForm.Get("payment_method") == "pm_card_chargeDeclined":
+			w.WriteHeader(http.StatusPaymentRequired)
+			w.Write([]byte(`{"error":{"type":"card_error","code":"card_declined","message":"Your card was declined."}}`))
+		case r.PostForm.Get("capture_method") == "manual":
+			w.Write([]byte(`{"id":"pi_456","status":"requires_capture"}`))
+		default:
+			if r.PostForm.Get("amount") != "1999" || r.PostForm.Get("currency") != "usd" || r.PostForm.Get("metadata[order_id]") != "o1" {
+				t.Errorf("unexpected form %v", r.PostForm)
+			}
+			w.Write([]byte(`{"id":"pi_123","status":"succeeded"}`))
+		}
+	}))
+	defer server.Close()
+
+	provider := NewStripeProvider("sk_test", "whsec")
+	provider.apiURL = server.URL
+
+	request := IntentRequest{PaymentID: "pay_1", OrderID: "o1", UserID: "u1", Amount: 19.99, Currency: "USD", PaymentMethod: "pm_card_visa", Capture: true}
+	intent, err := provider.CreateIntent(context.Background(), request)
+	if err != nil || intent.ProviderPaymentID != "pi_123" || intent.Status != models.PaymentPaid {
+		t.Fatalf("expected a paid intent, got %+v (%v)", intent, err)
+	}
+
+	request.Capture = false
+	if intent, err := provider.CreateIntent(context.Background(), request); err != nil || intent.Status != models.PaymentAuthorized {
+		t.Fatalf("expected an authorized intent, got %+v (%v)", intent, err)
+	}
+
+	request.PaymentMethod = "pm_card_chargeDeclined"
+	if _, err := provider.CreateIntent(context.Background(), request); !errors.Is(err, ErrDeclined) {
+		t.Fatalf("expected ErrDeclined, got %v", err)
+	}
+}
+
+func TestStripeProvider_CaptureAndRefund(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		calls = append(calls, r.URL.Path+" "+r.PostForm.Encode()+" "+r.Header.Get("Idempotency-Key"))
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	provider := NewStripeProvider("sk_test", "whsec")
+	provider.apiURL = server.URL
+
+	if err := provider.Capture(context.Background(), "pi_1", 10, "pay_1-capture"); err != nil {
+		t.Fatalf("capture failed: %v", err)
+	}
+	if err := provider.Refund(context.Background(), "pi_1", 2.5, "pay_1-refund-1"); err != nil {
+		t.Fatalf("refund failed: %v", err)
+	}
+	if len(calls) != 2 || calls[0] != "/v1/payment_intents/pi_1/capture amount_to_capture=1000 pay_1-capture" || calls[1] != "/v1/refunds amount=250&payment_intent=pi_1 pay_1-refund-1" {
+		t.Errorf("unexpected calls %q", calls)
+	}
+}
+
+func TestStripeProvider_ParseWebhook(t *testing.T) {
+	provider := NewStripeProvider("sk_test", "whsec")
+	payload := []byte(`{"type":"payment_intent.succeeded","data":{"object":{"id":"pi_123","status":"succeeded"}}}`)
+
+	sign := func(secret string, body []byte, at time.Time) string {
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	notification, err := provider.ParseWebhook(payload, sign("whsec", payload, time.Now()))
+	if err != nil || notification.ProviderPaymentID != "pi_123" || notification.Status != models.PaymentPaid {
+		t.Fatalf("expected a paid notification, got %+v (%v)", notification, err)
+	}
+
+	if _, err := provider.ParseWebhook(payload, sign("other", payload, time.Now())); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected a wrong secret to be rejected, got %v", err)
+	}
+	if _, err := provider.ParseWebhook(payload, sign("whsec", payload, time.Now().Add(-time.Hour))); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected a stale signature to be rejected, got %v", err)
+	}
+
+	failed := []byte(`{"type":"payment_intent.payment_failed","data":{"object":{"id":"pi_123","last_payment_error":{"message":"Insufficient funds"}}}}`)
+	if notification, err := provider.ParseWebhook(failed, sign("whsec", failed, time.Now())); err != nil || notification.Status != models.PaymentFailed || notification.FailureReason != "Insufficient funds" {
+		t.Errorf("expected a failure with its reason, got %+v (%v)", notification, err)
+	}
+
+	other := []byte(`{"type":"charge.refunded","data":{"object":{"id":"ch_1"}}}`)
+	if notification, err := provider.ParseWebhook(other, sign("whsec", other, time.Now())); err != nil || notification != nil {
+		t.Errorf("expected unrelated events to be ignored, got %+v (%v)", notification, err)
+	}
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"payment-service/internal/models"
+)
+
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// ErrDuplicatePayment is returned when a payment is created with an idempotency key its order
+// already has a payment for
+var ErrDuplicatePayment = errors.New("order already has a payment with this idempotency key")
+
+// PaymentRepository defines the interface for payment data operations
+type PaymentRepository interface {
+	// Create adds a payment. A payment with an idempotency key is only added if its order has no
+	// payment with that key yet; otherwise Create returns ErrDuplicatePayment.
+	Create(payment *models.Payment) error
+	Get(id string) (*models.Payment, error)
+	// GetByIdempotencyKey finds the order's payment created with the given idempotency key
+	GetByIdempotencyKey(orderID, key string) (*models.Payment, error)
+	// GetByProviderID finds a payment by the provider's ID for it
+	GetByProviderID(providerPaymentID string) (*models.Payment, error)
+	// ListByOrder returns the payments taken for an order, oldest first
+	ListByOrder(orderID string) ([]*models.Payment, error)
+	// Update changes a payment with change, saving it only if change returns nil, and returns the
+	// payment as saved. No other change to the payment is made meanwhile.
+	Update(id string, change func(payment *models.Payment) error) (*models.Payment, error)
+}
+
+// InMemoryPaymentRepository implements PaymentRepository using in-memory storage
+type InMemoryPaymentRepository struct {
+	payments map[string]*models.Payment
+	byKey    map[idempotencyKey]string // payment IDs by order and idempotency key
+	mutex    sync.RWMutex
+}
+
+// idempotencyKey identifies the payment a client's request created for an order
+type idempotencyKey struct {
+	orderID string
+	key     string
+}
+
+// NewInMemoryPaymentRepository creates a new in-memory payment repository
+func NewInMemoryPaymentRepository() *InMemoryPaymentRepository {
+	return &InMemoryPaymentRepository{
+		payments: make(map[string]*models.Payment),
+		byKey:    make(map[idempotencyKey]string),
+	}
+}
+
+// Create adds a payment unless its order already has one with the same idempotency key
+func (r *InMemoryPaymentRepository) Create(payment *models.Payment) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if payment.IdempotencyKey != "" {
+		key := idempotencyKey{payment.OrderID, payment.IdempotencyKey}
+		if _, exists := r.byKey[key]; exists {
+			return ErrDuplicatePayment
+		}
+		r.byKey[key] = payment.ID
+	}
+	r.payments[payment.ID] = payment.Copy()
+	return nil
+}
+
+// Get retrieves a payment by its ID
+func (r *InMemoryPaymentRepository) Get(id string) (*models.Payment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	payment, exists := r.payments[id]
+	if !exists {
+		return nil, ErrPaymentNotFound
+	}
+	return payment.Copy(), nil
+}
+
+// GetByIdempotencyKey retrieves the order's payment created with key
+func (r *InMemoryPaymentRepository) GetByIdempotencyKey(orderID, key string) (*models.Payment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	id, exists := r.byKey[idempotencyKey{orderID, key}]
+	if !exists {
+		return nil, ErrPaymentNotFound
+	}
+	return r.payments[id].Copy(), nil
+}
+
+// GetByProviderID retrieves a payment by the provider's ID for it
+func (r *InMemoryPaymentRepository) GetByProviderID(providerPaymentID string) (*models.Payment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, payment := range r.payments {
+		if providerPaymentID != "" && payment.ProviderPaymentID == providerPaymentID {
+			return payment.Copy(), nil
+		}
+	}
+	return nil, ErrPaymentNotFound
+}
+
+// ListByOrder retrieves an order's payments, oldest first
+func (r *InMemoryPaymentRepository) ListByOrder(orderID string) ([]*models.Payment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	payments := []*models.Payment{}
+	for _, payment := range r.payments {
+		if payment.OrderID == orderID {
+			payments = append(payments, payment.Copy())
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool { return payments[i].CreatedAt.Before(payments[j].CreatedAt) })
+	return payments, nil
+}
+
+// Update changes a payment under the repository's lock
+func (r *InMemoryPaymentRepository) Update(id string, change func(payment *models.Payment) error) (*models.Payment, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, exists := r.payments[id]
+	if !exists {
+		return nil, ErrPaymentNotFound
+	}
+	payment := stored.Copy()
+	if err := change(payment); err != nil {
+		return nil, err
+	}
+	r.payments[id] = payment
+	return payment.Copy(), nil
+}
+
+// Count returns how many payments are stored
+func (r *InMemoryPaymentRepository) Count() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return len(r.payments)
+}
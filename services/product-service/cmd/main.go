@@ -2,53 +2,261 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	"ecommerce/pkg/auth"
+	"ecommerce/pkg/cache"
+	"ecommerce/pkg/config"
+	"ecommerce/pkg/diagnostics"
+	"ecommerce/pkg/events"
+	"ecommerce/pkg/health"
+	"ecommerce/pkg/jobs"
+	"ecommerce/pkg/logging"
+	"ecommerce/pkg/metrics"
+	"ecommerce/pkg/middleware"
+	"ecommerce/pkg/mongodb"
+	"ecommerce/pkg/openapi"
+	"ecommerce/pkg/postgres"
+	"ecommerce/pkg/rpc"
+	"ecommerce/pkg/snapshot"
+	productv1 "ecommerce/pkg/proto/product/v1"
+	"product-service/internal/client"
+	"product-service/internal/consumer"
+	"product-service/internal/currency"
+	"product-service/internal/digest"
+	"product-service/internal/duplicate"
 	"product-service/internal/handlers"
+	"product-service/internal/models"
+	"product-service/internal/recommend"
 	"product-service/internal/repository"
+	"product-service/internal/storage"
 
 	"github.com/gorilla/mux"
 )
 
+// defaultCurrencyRates are used when CURRENCY_RATES is unset: units of each currency per US dollar
+const defaultCurrencyRates = "EUR=0.92,GBP=0.79,KES=129"
+
 func main() {
-	// Initialize repository with sample data
-	productRepo := repository.NewInMemoryProductRepository()
+	// Settings come from flags, the environment, and the YAML file named by -config or CONFIG_FILE
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		logging.Fatal("Failed to load configuration", "error", err)
+	}
+	logging.Setup(cfg.String("SERVICE_NAME", "product-service"))
+	serverConfig := cfg.Server(8082)
+
+	// Settings shown as reloadable at /admin/config are read again on SIGHUP
+	reloader := config.NewReloader(cfg)
+	reloader.Register(config.TuneLogLevel)
+	reloader.Register(config.TuneMaxBodyBytes)
+
+	// With TLS_CERT_FILE, the REST and gRPC APIs are served over mutual TLS, and calls to other services
+	// present the same certificate; the files are read again on SIGHUP, so rotated ones take effect
+	certs := reloader.TLS()
+
+	// Initialize repositories; SEED_FILE, such as fixtures/demo.yaml, adds sample products
+	productStore := setupProductRepository(cfg)
+	stopSnapshots := setupSnapshots(cfg, productStore)
+	productRepo := setupProductCache(cfg, productStore)
+	seedProducts(cfg, productRepo)
+	metrics.NewGaugeFunc("products_stored", "Products in the repository, unpublished ones included", func() float64 {
+		count, err := productRepo.Count(context.Background())
+		if err != nil {
+			slog.Error("Error counting products", "error", err)
+		}
+		return float64(count)
+	})
+	categoryRepo := repository.NewInMemoryCategoryRepository()
+	reviewRepo := repository.NewInMemoryReviewRepository()
+	warehouseRepo := repository.NewInMemoryWarehouseRepository()
+	subscriptionRepo := repository.NewInMemoryStockSubscriptionRepository()
+
+	// Service keys presented by other services are verified with the user service
+	serviceKeys := auth.NewServiceKeyVerifier("http://localhost:8081", time.Minute)
+
+	// Reviews are checked against order history to mark (or require) verified purchases
+	orderClient := client.NewOrderServiceClient("http://localhost:8083", cfg.String("SERVICE_KEY", ""))
+	if certs != nil {
+		serviceKeys.UseTLS(certs.ClientConfig())
+		orderClient.UseTLS(certs.ClientConfig())
+	}
+	reloader.Register(func(cfg *config.Config) func() {
+		userServiceURL := cfg.String("USER_SERVICE_URL", "http://localhost:8081")
+		orderServiceURL := cfg.String("ORDER_SERVICE_URL", "http://localhost:8083")
+		return func() {
+			serviceKeys.SetUserServiceURL(userServiceURL)
+			orderClient.SetURL(orderServiceURL)
+		}
+	})
+
+	// Stock reserved during checkout returns to the shelf if the order isn't confirmed in time
+	reservationTTL := cfg.Duration("RESERVATION_TTL", handlers.DefaultReservationTTL, config.Positive)
+
+	// Prices can be shown in any currency with a configured rate against the base currency
+	rates, err := currency.ParseRates(cfg.String("CURRENCY_RATES", defaultCurrencyRates))
+	if err != nil {
+		logging.Fatal("Invalid CURRENCY_RATES", "error", err)
+	}
+	currencies := currency.NewConverter(models.DefaultCurrency, rates)
+
+	// Uploaded product images go to local disk or S3; PUBLIC_URL is how clients reach this service
+	publicURL := cfg.String("PUBLIC_URL", "http://localhost:8082")
+	imageStorage, uploads := setupImageStorage(cfg, publicURL)
+
+	// Back-in-stock events are posted to the notification layer's webhook, or just logged without one
+	var restockPublisher client.BackInStockPublisher
+	if webhookURL := cfg.String("BACK_IN_STOCK_WEBHOOK_URL", ""); webhookURL != "" {
+		restockPublisher = client.NewWebhookClient(webhookURL, cfg.String("SERVICE_KEY", ""))
+	}
+
+	// New products whose name closely matches an existing one, or whose SKU is taken, are rejected
+	duplicates, err := duplicate.NewDetector(productRepo, cfg.Float("DUPLICATE_NAME_THRESHOLD", duplicate.DefaultThreshold))
+	if err != nil {
+		logging.Fatal("Invalid DUPLICATE_NAME_THRESHOLD", "error", err)
+	}
 
 	// Initialize handlers
-	productHandler := handlers.NewProductHandler(productRepo)
+	productHandler := handlers.NewProductHandler(productRepo, categoryRepo, currencies, duplicates)
+	categoryHandler := handlers.NewCategoryHandler(categoryRepo, productRepo)
+	imageHandler := handlers.NewImageHandler(productRepo, imageStorage, publicURL)
+	reviewHandler := handlers.NewReviewHandler(reviewRepo, productRepo, orderClient, false)
+	reloader.Register(func(cfg *config.Config) func() {
+		requirePurchase := cfg.Bool("REVIEWS_REQUIRE_PURCHASE", false)
+		return func() { reviewHandler.SetRequirePurchase(requirePurchase) }
+	})
+	stockAlertHandler := handlers.NewStockAlertHandler(subscriptionRepo, productRepo, restockPublisher)
+	productRepo.OnRestock(stockAlertHandler.ProductRestocked)
+	reservationHandler := handlers.NewReservationHandler(productRepo, reservationTTL)
+	warehouseHandler := handlers.NewWarehouseHandler(warehouseRepo, productRepo)
+	// Related products use the catalog heuristic until a recommendation service is available
+	recommendationHandler := handlers.NewRecommendationHandler(productRepo, recommend.NewCatalogRecommender(productRepo), currencies)
+
+	// Background jobs run on a scheduler; with JOB_ELECTION, one instance at a time runs them
+	scheduler := jobs.NewScheduler(setupElector(cfg, productStore))
+	// Periodically return stock held by expired reservations
+	scheduler.Add(jobs.Job{Name: "expire-stock-reservations", Schedule: jobs.Every(30 * time.Second), Run: expireReservations(productRepo)})
+	// Products with LOW_STOCK_THRESHOLD units or fewer are listed on LOW_STOCK_DIGEST_SCHEDULE and posted to
+	// LOW_STOCK_DIGEST_WEBHOOK_URL, or just logged without one; a threshold of 0 turns the digest off
+	if threshold := cfg.Int("LOW_STOCK_THRESHOLD", digest.DefaultLowStockThreshold, config.NonNegative); threshold > 0 {
+		scheduler.Add(setupLowStockDigest(cfg, productRepo, threshold))
+	}
+	scheduler.Start()
+
+	// Stock of cancelled orders that order service leaves to this service is released as their
+	// order.cancelled events arrive through the broker named by BROKER
+	consumeCtx, stopConsuming := context.WithCancel(context.Background())
+	if subscriber := setupSubscriber(cfg); subscriber != nil {
+		topic := cfg.String("ORDER_EVENTS_TOPIC", events.DefaultOrderTopic)
+		go subscriber.Consume(consumeCtx, "product-service", topic, consumer.NewOrderEvents(productRepo).Handle)
+	}
+
+	// With a search cluster or database as the product store, readiness checks it is reachable
+	probes := health.NewChecker("product-service", health.DefaultTimeout)
+	switch store := productStore.(type) {
+	case *repository.ElasticsearchProductRepository:
+		probes.Register("elasticsearch", store.Ping)
+	case *repository.PostgresProductRepository:
+		probes.Register("postgres", store.Ping)
+	case *repository.MongoProductRepository:
+		probes.Register("mongodb", store.Ping)
+	}
 
 	// Setup routes
-	router := setupRoutes(productHandler)
+	router := setupRoutes(serverConfig.CORSOrigins, reloader, serviceKeys, probes, productHandler, categoryHandler, imageHandler, reviewHandler, stockAlertHandler, reservationHandler, warehouseHandler, recommendationHandler, uploads)
+
+	// Other services look products up over gRPC, next to the REST API
+	grpcServer := rpc.NewServer(serviceKeys.AuthenticateCall, certs.ServerConfig())
+	productv1.RegisterProductServiceServer(grpcServer, handlers.NewProductServer(productRepo, currencies))
+
+	// Stop before serving if any setting was invalid, listing every problem at once
+	if err := cfg.Err(); err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+	for _, key := range cfg.Unused() {
+		slog.Warn("Setting is not used", "key", key)
+	}
+	go reloader.Watch(context.Background())
+
+	// CPU and heap profiles and runtime variables are served on DEBUG_ADDR, an internal port apart
+	// from the API, when it is set
+	debugServer, err := diagnostics.Serve(serverConfig.DebugAddr)
+	if err != nil {
+		logging.Fatal("Diagnostics port failed to start", "error", err)
+	}
 
-	// Configure server
+	// Configure server; requests through the proxies in TRUSTED_PROXIES, such as the gateway, are
+	// attributed to the client they came from
 	server := &http.Server{
-		Addr:         ":8082",
-		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:         serverConfig.Addr(),
+		Handler:      middleware.TrustProxies(serverConfig.TrustedProxies)(middleware.Versioned(router, "v1", "v1")),
+		ReadTimeout:  serverConfig.ReadTimeout,
+		WriteTimeout: serverConfig.WriteTimeout,
+		IdleTimeout:  serverConfig.IdleTimeout,
 	}
 
 	// Start server in a goroutine
 	go func() {
-		log.Println("🚀 Product Service starting on port 8082...")
-		log.Println("📚 API Documentation:")
-		log.Println("  GET  /products               - List all products")
-		log.Println("  GET  /products/{id}          - Get product by ID")
-		log.Println("  POST /products               - Create product")
-		log.Println("  PUT  /products/{id}          - Update product")
-		log.Println("  PATCH /products/{id}/stock   - Update stock")
-		log.Println("  GET  /products/category/{cat} - Get by category")
-		log.Println("  GET  /health                 - Health check")
-		log.Println("---")
-		log.Println("📦 Sample products loaded!")
-
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed to start: %v", err)
+		slog.Info("🚀 Product Service starting", "port", serverConfig.Port)
+		slog.Info("📚 API Documentation:")
+		slog.Info("  GET  /products               - List products (tag, sort, page/limit or cursor)")
+		slog.Info("  GET  /products/export        - Export catalog (?format=csv|json, list filters apply)")
+		slog.Info("  GET  /products/search?q=     - Search name, category, and description by relevance")
+		slog.Info("  GET  /products/{id}          - Get product by ID")
+		slog.Info("  GET  /products/{id}/related  - Related products (same category or tags)")
+		slog.Info("  POST /products               - Create product")
+		slog.Info("  POST /products/import        - Bulk import products from CSV")
+		slog.Info("  PUT  /products/{id}          - Update product")
+		slog.Info("  PATCH /products/{id}/stock   - Set or adjust (delta) stock")
+		slog.Info("  PATCH /products/{id}/visibility - Draft, publish, or schedule a product")
+		slog.Info("  GET  /products/{id}/stock-history - Stock movement audit trail")
+		slog.Info("  POST /products/{id}/reserve  - Reserve stock for checkout (internal)")
+		slog.Info("  POST /products/{id}/release  - Release reserved stock (internal)")
+		slog.Info("  POST /products/{id}/commit   - Commit reserved stock to a sale (internal)")
+		slog.Info("  GET  /products/category/{cat} - Get by category (includes subcategories)")
+		slog.Info("  GET  /products/{id}/images   - List product images")
+		slog.Info("  POST /products/{id}/images   - Add product image")
+		slog.Info("  POST /products/{id}/images/upload - Upload product image file")
+		slog.Info("  GET  /products/{id}/images/{image_id}/download - Download uploaded image")
+		slog.Info("  PUT  /products/{id}/images/order - Reorder product images")
+		slog.Info("  DELETE /products/{id}/images/{image_id} - Remove product image")
+		slog.Info("  GET  /products/{id}/reviews  - List product reviews (page/limit)")
+		slog.Info("  POST /products/{id}/reviews  - Review and rate a product")
+		slog.Info("  POST /products/{id}/notify-me - Get told when a product is back in stock")
+		slog.Info("  GET  /products/{id}/inventory - Stock per warehouse")
+		slog.Info("  PATCH /products/{id}/inventory/{warehouse_id} - Set or adjust warehouse stock")
+		slog.Info("  POST /products/{id}/inventory/transfer - Move stock between warehouses")
+		slog.Info("  GET  /warehouses             - List warehouses")
+		slog.Info("  POST /warehouses             - Create warehouse")
+		slog.Info("  GET  /warehouses/{id}        - Get warehouse")
+		slog.Info("  GET  /tags                   - List tags with product counts")
+		slog.Info("  GET  /categories             - List categories (?tree=true for hierarchy)")
+		slog.Info("  POST /categories             - Create category")
+		slog.Info("  GET  /categories/{id}        - Get category with subcategories")
+		slog.Info("  PUT  /categories/{id}        - Update or move category")
+		slog.Info("  DELETE /categories/{id}      - Delete empty category")
+		slog.Info("  GET  /healthz                - Liveness probe")
+		slog.Info("  GET  /readyz                 - Readiness probe")
+		slog.Info("  GET  /metrics                - Prometheus metrics")
+		slog.Info("  GET  /admin/config           - Settings in effect; reloadable ones are re-read on SIGHUP (internal)")
+		slog.Info("---")
+		slog.Info("📦 Sample products loaded!")
+
+		if err := certs.ListenAndServe(server); err != nil && err != http.ErrServerClosed {
+			logging.Fatal("Server failed to start", "error", err)
+		}
+	}()
+
+	go func() {
+		slog.Info("🚀 Product Service gRPC API starting", "port", serverConfig.GRPCPort)
+		slog.Info("  ecommerce.product.v1.ProductService/GetProduct       - Get published product by ID")
+		slog.Info("  ecommerce.product.v1.ProductService/BatchGetProducts - Get published products by ID in one call")
+		if err := rpc.Serve(grpcServer, serverConfig.GRPCPort); err != nil {
+			logging.Fatal("gRPC server failed to start", "error", err)
 		}
 	}()
 
@@ -57,78 +265,373 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("🛑 Shutting down Product Service...")
+	slog.Info("🛑 Shutting down Product Service...")
 
 	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), serverConfig.ShutdownTimeout)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
+	stopConsuming()
+	rpc.Shutdown(ctx, grpcServer)
+	debugServer.Close()
+	err = server.Shutdown(ctx)
+	if err != nil {
+		slog.Error("Server forced to shutdown", "error", err)
 	} else {
-		log.Println("✅ Product Service shutdown complete")
+		slog.Info("✅ Product Service shutdown complete")
+	}
+
+	// Job runs under way get DRAIN_TIMEOUT to finish, so a reservation sweep or digest isn't cut off
+	// halfway
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), serverConfig.DrainTimeout)
+	defer cancelDrain()
+	if err := scheduler.Shutdown(drainCtx); err != nil {
+		slog.Error("Job runs cut short at shutdown", "error", err)
+	}
+	// Snapshot once requests and jobs have drained, so the last snapshot has their changes
+	stopSnapshots()
+}
+
+// setupProductRepository picks the product store from PRODUCT_STORE ("memory", "elasticsearch",
+// "postgres", or "mongodb"). The Elasticsearch store also works against OpenSearch; the PostgreSQL store
+// connects to DATABASE_URL and creates its tables on first start; the MongoDB store uses the
+// MONGODB_DATABASE database at MONGODB_URI and creates its indexes on first start.
+func setupProductRepository(cfg *config.Config) repository.ProductRepository {
+	switch store := cfg.String("PRODUCT_STORE", "memory"); store {
+	case "memory":
+		return repository.NewInMemoryProductRepository()
+	case "elasticsearch":
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		repo, err := repository.NewElasticsearchProductRepository(ctx, repository.ElasticsearchConfig{
+			URL:         cfg.String("ELASTICSEARCH_URL", "http://localhost:9200"),
+			Username:    cfg.String("ELASTICSEARCH_USERNAME", ""),
+			Password:    cfg.String("ELASTICSEARCH_PASSWORD", ""),
+			IndexPrefix: cfg.String("ELASTICSEARCH_INDEX_PREFIX", "product-service"),
+		})
+		if err != nil {
+			logging.Fatal("Failed to connect to Elasticsearch", "error", err)
+		}
+		return repo
+	case "postgres":
+		databaseURL := cfg.String("DATABASE_URL", "")
+		if databaseURL == "" {
+			logging.Fatal("Invalid store configuration: DATABASE_URL is required")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		db, err := postgres.Open(ctx, databaseURL)
+		if err != nil {
+			logging.Fatal("Failed to connect to PostgreSQL", "error", err)
+		}
+		repo, err := repository.NewPostgresProductRepository(ctx, db)
+		if err != nil {
+			logging.Fatal("Failed to prepare the product tables", "error", err)
+		}
+		return repo
+	case "mongodb":
+		mongoURI := cfg.String("MONGODB_URI", "")
+		if mongoURI == "" {
+			logging.Fatal("Invalid store configuration: MONGODB_URI is required")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		db, err := mongodb.Connect(ctx, mongoURI, cfg.String("MONGODB_DATABASE", "ecommerce"))
+		if err != nil {
+			logging.Fatal("Failed to connect to MongoDB", "error", err)
+		}
+		repo, err := repository.NewMongoProductRepository(ctx, db)
+		if err != nil {
+			logging.Fatal("Failed to prepare the product collections", "error", err)
+		}
+		return repo
+	default:
+		logging.Fatal("Invalid PRODUCT_STORE", "store", store)
+		return nil
+	}
+}
+
+// setupSnapshots keeps in-memory products, reservations, and stock history in SNAPSHOT_DIR, when it
+// is set, so a dev or demo instance keeps them across restarts. They are snapshotted every
+// SNAPSHOT_INTERVAL, with each change logged in between; the returned stop takes a last snapshot.
+func setupSnapshots(cfg *config.Config, store repository.ProductRepository) (stop func()) {
+	dir := cfg.String("SNAPSHOT_DIR", "")
+	memoryStore, inMemory := store.(*repository.InMemoryProductRepository)
+	if dir == "" || !inMemory {
+		return func() {}
+	}
+	if err := memoryStore.PersistTo(dir); err != nil {
+		logging.Fatal("Failed to load the products snapshot", "dir", dir, "error", err)
+	}
+	slog.Info("Persisting products", "dir", dir)
+	interval := cfg.Duration("SNAPSHOT_INTERVAL", snapshot.DefaultInterval, config.Positive)
+	return snapshot.Schedule("products", memoryStore, interval)
+}
+
+// seedProducts creates the products in the SEED_FILE fixtures that the store doesn't have yet, so
+// a demo or test environment starts with a catalog. It runs after snapshots are loaded, so
+// products kept from an earlier run aren't created again.
+func seedProducts(cfg *config.Config, productRepo repository.ProductRepository) {
+	path := cfg.String("SEED_FILE", "")
+	if path == "" {
+		return
+	}
+	created, err := repository.SeedProducts(context.Background(), productRepo, path)
+	if err != nil {
+		logging.Fatal("Failed to seed products", "file", path, "error", err)
+	}
+	slog.Info("🌱 Products seeded", "file", path, "created", created)
+}
+
+// setupElector picks how instances share background jobs from JOB_ELECTION: "none" (the default)
+// runs them on every instance, which suits a single one, and "redis" has the instances elect one
+// through the Redis at REDIS_URL, whose lease lasts JOB_LEADER_TTL. Election needs a product store the
+// instances share; with the in-memory one each instance must expire its own reservations.
+func setupElector(cfg *config.Config, store repository.ProductRepository) jobs.Elector {
+	switch election := cfg.String("JOB_ELECTION", "none"); election {
+	case "none":
+		return jobs.Solo{}
+	case "redis":
+		if _, inMemory := store.(*repository.InMemoryProductRepository); inMemory {
+			logging.Fatal("Invalid configuration: JOB_ELECTION=redis requires a shared PRODUCT_STORE")
+		}
+		redisURL := cfg.String("REDIS_URL", "")
+		if redisURL == "" {
+			logging.Fatal("Invalid job configuration: REDIS_URL is required")
+		}
+		ttl := cfg.Duration("JOB_LEADER_TTL", jobs.DefaultLeaseTTL, config.Positive)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		elector, err := jobs.NewRedisElector(ctx, redisURL, "product-service:jobs:leader", ttl)
+		if err != nil {
+			logging.Fatal("Failed to connect to Redis", "error", err)
+		}
+		return elector
+	default:
+		logging.Fatal("Invalid JOB_ELECTION", "election", election)
+		return nil
+	}
+}
+
+// setupProductCache puts the Redis at REDIS_URL in front of the product store, caching product reads
+// for PRODUCT_CACHE_TTL. Without REDIS_URL every read goes to the store.
+func setupProductCache(cfg *config.Config, store repository.ProductRepository) repository.ProductRepository {
+	redisURL := cfg.String("REDIS_URL", "")
+	ttl := cfg.Duration("PRODUCT_CACHE_TTL", cache.DefaultTTL, config.Positive)
+	if redisURL == "" {
+		return store
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	productCache, err := cache.New(ctx, redisURL, "product-service:")
+	if err != nil {
+		logging.Fatal("Failed to connect to Redis", "error", err)
+	}
+	return repository.NewCachedProductRepository(store, productCache, ttl)
+}
+
+// setupSubscriber configures consuming events from BROKER: "log" (the default) means order events only
+// go to order service's log, so there is nothing to consume, and "kafka-rest" consumes them through
+// the Kafka REST Proxy at KAFKA_REST_URL
+func setupSubscriber(cfg *config.Config) events.Subscriber {
+	switch broker := cfg.String("BROKER", "log"); broker {
+	case "log":
+		return nil
+	case "kafka-rest":
+		proxyURL := cfg.String("KAFKA_REST_URL", "")
+		if proxyURL == "" {
+			logging.Fatal("Invalid broker configuration: KAFKA_REST_URL is required")
+		}
+		return events.NewKafkaREST(proxyURL)
+	default:
+		logging.Fatal("Invalid BROKER", "broker", broker)
+		return nil
+	}
+}
+
+// setupImageStorage picks the storage backend for uploaded images from IMAGE_STORAGE ("local" or "s3").
+// For local storage it also returns the handler that serves the files under /uploads/.
+func setupImageStorage(cfg *config.Config, publicURL string) (storage.Storage, http.Handler) {
+	switch backend := cfg.String("IMAGE_STORAGE", "local"); backend {
+	case "local":
+		local, err := storage.NewLocalStorage(cfg.String("IMAGE_UPLOAD_DIR", "./uploads"), publicURL+"/uploads")
+		if err != nil {
+			logging.Fatal("Invalid IMAGE_UPLOAD_DIR", "error", err)
+		}
+		return local, local.Handler()
+	case "s3":
+		config := storage.S3Config{
+			Bucket:          cfg.String("S3_BUCKET", ""),
+			Region:          cfg.String("S3_REGION", "us-east-1"),
+			Endpoint:        cfg.String("S3_ENDPOINT", ""),
+			AccessKeyID:     cfg.String("AWS_ACCESS_KEY_ID", ""),
+			SecretAccessKey: cfg.String("AWS_SECRET_ACCESS_KEY", ""),
+			PublicURL:       cfg.String("S3_PUBLIC_URL", ""),
+		}
+		if config.Bucket == "" || config.AccessKeyID == "" || config.SecretAccessKey == "" {
+			logging.Fatal("IMAGE_STORAGE=s3 requires S3_BUCKET, AWS_ACCESS_KEY_ID, and AWS_SECRET_ACCESS_KEY")
+		}
+		return storage.NewS3Storage(config), nil
+	default:
+		logging.Fatal("Invalid IMAGE_STORAGE", "backend", backend)
+		return nil, nil
 	}
 }
 
 // setupRoutes configures all the HTTP routes
-func setupRoutes(productHandler *handlers.ProductHandler) *mux.Router {
+func setupRoutes(
+	corsOrigins []string,
+	reloader *config.Reloader,
+	serviceKeys *auth.ServiceKeyVerifier,
+	probes *health.Checker,
+	productHandler *handlers.ProductHandler,
+	categoryHandler *handlers.CategoryHandler,
+	imageHandler *handlers.ImageHandler,
+	reviewHandler *handlers.ReviewHandler,
+	stockAlertHandler *handlers.StockAlertHandler,
+	reservationHandler *handlers.ReservationHandler,
+	warehouseHandler *handlers.WarehouseHandler,
+	recommendationHandler *handlers.RecommendationHandler,
+	uploads http.Handler,
+) *mux.Router {
 	router := mux.NewRouter()
 
 	// Add CORS middleware
-	router.Use(corsMiddleware)
+	router.Use(middleware.CORS(corsOrigins))
 	
+	// Tag each request with an ID for the logs and calls to other services
+	router.Use(middleware.RequestID)
+
+	// Name the running build on every response
+	router.Use(middleware.ServiceVersion)
+
+	// Send errors as problem details to clients that ask for application/problem+json
+	router.Use(middleware.ProblemDetails)
+
 	// Add logging middleware
-	router.Use(loggingMiddleware)
+	router.Use(middleware.Logging)
+
+	// Count and time requests by route for /metrics
+	router.Use(middleware.Metrics)
+
+	// Resolve service API keys for internal calls
+	router.Use(serviceKeys.Authenticate)
+
+	// Limit how fast each client may call: end users per IP, other services per service
+	router.Use(middleware.RateLimit("global", middleware.RateLimiter(reloader, "RATE_LIMIT", 100, 600), middleware.RateLimiter(reloader, "RATE_LIMIT_SERVICE", 1000, 6000), auth.ServiceFromContext))
 
-	// API routes
-	api := router.PathPrefix("/").Subrouter()
+	// API routes live under a version prefix; operational endpoints stay at the root
+	v1 := router.PathPrefix("/v1").Subrouter()
+
+	// Turn API requests away with 503 once too many are in flight, rather than letting a spike pile up
+	v1.Use(middleware.LoadShedder(reloader).Middleware)
 
 	// Product routes
-	api.HandleFunc("/products", productHandler.ListProducts).Methods("GET")
-	api.HandleFunc("/products", productHandler.CreateProduct).Methods("POST")
-	api.HandleFunc("/products/{id}", productHandler.GetProduct).Methods("GET")
-	api.HandleFunc("/products/{id}", productHandler.UpdateProduct).Methods("PUT")
-	api.HandleFunc("/products/{id}/stock", productHandler.UpdateStock).Methods("PATCH")
-	api.HandleFunc("/products/category/{category}", productHandler.GetProductsByCategory).Methods("GET")
+	v1.HandleFunc("/products", productHandler.ListProducts).Methods("GET")
+	v1.HandleFunc("/products", productHandler.CreateProduct).Methods("POST")
+	v1.HandleFunc("/products/import", productHandler.ImportProducts).Methods("POST")
+	v1.HandleFunc("/products/export", productHandler.ExportProducts).Methods("GET")
+	v1.HandleFunc("/products/search", productHandler.SearchProducts).Methods("GET")
+	v1.HandleFunc("/products/{id}", productHandler.GetProduct).Methods("GET")
+	v1.HandleFunc("/products/{id}", productHandler.UpdateProduct).Methods("PUT")
+	v1.HandleFunc("/products/{id}/stock", productHandler.UpdateStock).Methods("PATCH")
+	v1.HandleFunc("/products/{id}/stock-history", productHandler.StockHistory).Methods("GET")
+	v1.HandleFunc("/products/{id}/visibility", productHandler.UpdateVisibility).Methods("PATCH")
+	v1.HandleFunc("/products/{id}/related", recommendationHandler.ListRelated).Methods("GET")
+	v1.HandleFunc("/products/category/{category}", productHandler.GetProductsByCategory).Methods("GET")
+
+	// Stock reservation routes (internal, used by order service during checkout)
+	v1.Handle("/products/{id}/reserve", serviceKeys.RequireService(http.HandlerFunc(reservationHandler.ReserveStock))).Methods("POST")
+	v1.Handle("/products/{id}/release", serviceKeys.RequireService(http.HandlerFunc(reservationHandler.ReleaseStock))).Methods("POST")
+	v1.Handle("/products/{id}/commit", serviceKeys.RequireService(http.HandlerFunc(reservationHandler.CommitStock))).Methods("POST")
+
+	// Inventory and warehouse routes
+	v1.HandleFunc("/products/{id}/inventory", warehouseHandler.GetInventory).Methods("GET")
+	v1.HandleFunc("/products/{id}/inventory/transfer", warehouseHandler.TransferStock).Methods("POST")
+	v1.HandleFunc("/products/{id}/inventory/{warehouse_id}", warehouseHandler.UpdateWarehouseStock).Methods("PATCH")
+	v1.HandleFunc("/warehouses", warehouseHandler.ListWarehouses).Methods("GET")
+	v1.HandleFunc("/warehouses", warehouseHandler.CreateWarehouse).Methods("POST")
+	v1.HandleFunc("/warehouses/{id}", warehouseHandler.GetWarehouse).Methods("GET")
 
-	// Health check
-	api.HandleFunc("/health", productHandler.HealthCheck).Methods("GET")
+	// Tag routes
+	v1.HandleFunc("/tags", productHandler.ListTags).Methods("GET")
+
+	// Product image routes
+	v1.HandleFunc("/products/{id}/images", imageHandler.ListImages).Methods("GET")
+	v1.HandleFunc("/products/{id}/images", imageHandler.AddImage).Methods("POST")
+	uploadLimit := middleware.RateLimit("uploads", middleware.RateLimiter(reloader, "RATE_LIMIT_UPLOADS", 10, 20), nil, auth.ServiceFromContext)
+	v1.Handle("/products/{id}/images/upload", uploadLimit(http.HandlerFunc(imageHandler.UploadImage))).Methods("POST")
+	v1.HandleFunc("/products/{id}/images/order", imageHandler.ReorderImages).Methods("PUT")
+	v1.HandleFunc("/products/{id}/images/{image_id}", imageHandler.RemoveImage).Methods("DELETE")
+	v1.HandleFunc("/products/{id}/images/{image_id}/download", imageHandler.DownloadImage).Methods("GET")
+
+	// Uploaded files, when they are kept on local disk
+	if uploads != nil {
+		router.PathPrefix("/uploads/").Handler(http.StripPrefix("/uploads", uploads)).Methods("GET", "HEAD")
+	}
+
+	// Product review routes
+	v1.HandleFunc("/products/{id}/reviews", reviewHandler.ListReviews).Methods("GET")
+	v1.HandleFunc("/products/{id}/reviews", reviewHandler.CreateReview).Methods("POST")
+	v1.HandleFunc("/products/{id}/notify-me", stockAlertHandler.NotifyMe).Methods("POST")
+
+	// Category routes
+	v1.HandleFunc("/categories", categoryHandler.ListCategories).Methods("GET")
+	v1.HandleFunc("/categories", categoryHandler.CreateCategory).Methods("POST")
+	v1.HandleFunc("/categories/{id}", categoryHandler.GetCategory).Methods("GET")
+	v1.HandleFunc("/categories/{id}", categoryHandler.UpdateCategory).Methods("PUT")
+	v1.HandleFunc("/categories/{id}", categoryHandler.DeleteCategory).Methods("DELETE")
+
+	// The API's OpenAPI document, and Swagger UI to browse it
+	router.Handle("/openapi.json", handlers.OpenAPI().Handler(router)).Methods("GET")
+	router.PathPrefix("/docs").Handler(openapi.SwaggerUI("/docs/", "/openapi.json")).Methods("GET")
+
+	// Liveness and readiness probes
+	router.HandleFunc("/healthz", probes.Liveness).Methods("GET")
+	router.HandleFunc("/readyz", probes.Readiness).Methods("GET")
+
+	// Service metrics
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// Settings in effect, for other services and operators
+	v1.Handle("/admin/config", serviceKeys.RequireService(http.HandlerFunc(reloader.ServeSettings))).Methods("GET")
 
 	return router
 }
 
-// corsMiddleware adds CORS headers to responses
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+var reservationsExpired = metrics.NewCounter("stock_reservations_expired_total",
+	"Stock reservations whose hold timed out and whose stock went back on the shelf")
 
-		// Handle preflight requests
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+// expireReservations returns stock from expired reservations
+func expireReservations(repo repository.ProductRepository) func(ctx context.Context, now time.Time) error {
+	return func(ctx context.Context, now time.Time) error {
+		expired, err := repo.ExpireReservations(ctx, now)
+		if err != nil {
+			return err
 		}
-
-		next.ServeHTTP(w, r)
-	})
+		reservationsExpired.Add(float64(expired))
+		if expired > 0 {
+			slog.Info("Released stock from expired reservations", "count", expired)
+		}
+		return nil
+	}
 }
 
-// loggingMiddleware logs HTTP requests
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-
-		// Call the next handler
-		next.ServeHTTP(w, r)
-
-		// Log the request
-		log.Printf(
-			"[%s] %s %s %v",
-			r.Method,
-			r.RequestURI,
-			r.RemoteAddr,
-			time.Since(start),
-		)
+// setupLowStockDigest configures the low-stock digest job
+func setupLowStockDigest(cfg *config.Config, repo repository.ProductRepository, threshold int) jobs.Job {
+	expr := cfg.String("LOW_STOCK_DIGEST_SCHEDULE", digest.DefaultLowStockSchedule, func(expr string) error {
+		_, err := jobs.ParseCron(expr)
+		return err
 	})
+	schedule, _ := jobs.ParseCron(expr)
+
+	var publisher client.LowStockDigestPublisher
+	if webhookURL := cfg.String("LOW_STOCK_DIGEST_WEBHOOK_URL", ""); webhookURL != "" {
+		publisher = client.NewWebhookClient(webhookURL, cfg.String("SERVICE_KEY", ""))
+	}
+	lowStock := digest.NewLowStock(repo, publisher, threshold)
+	return jobs.Job{Name: "low-stock-digest", Schedule: schedule, Run: func(ctx context.Context, now time.Time) error {
+		_, err := lowStock.Send(ctx)
+		return err
+	}}
 }
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"product-service/internal/models"
+)
+
+// BackInStockPublisher hands back-in-stock events to the notification layer.
+// Implemented by WebhookClient; enables mocking in tests.
+type BackInStockPublisher interface {
+	PublishBackInStock(event *models.BackInStockEvent) error
+}
+
+// LowStockDigestPublisher hands low-stock digests to the notification layer.
+// Implemented by WebhookClient; enables mocking in tests.
+type LowStockDigestPublisher interface {
+	PublishLowStockDigest(digest *models.LowStockDigest) error
+}
+
+// WebhookClient delivers events by POSTing them as JSON to a webhook URL
+type WebhookClient struct {
+	httpClient *http.Client
+	webhookURL string
+	serviceKey string
+}
+
+// NewWebhookClient creates a client that posts events to webhookURL.
+// serviceKey is sent as X-Service-Key so the receiver can tell the call came from this service.
+func NewWebhookClient(webhookURL, serviceKey string) *WebhookClient {
+	return &WebhookClient{
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+		webhookURL: webhookURL,
+		serviceKey: serviceKey,
+	}
+}
+
+// PublishBackInStock posts a back-in-stock event; any 2xx response counts as delivered
+func (c *WebhookClient) PublishBackInStock(event *models.BackInStockEvent) error {
+	return c.post(event)
+}
+
+// PublishLowStockDigest posts a low-stock digest; any 2xx response counts as delivered
+func (c *WebhookClient) PublishLowStockDigest(digest *models.LowStockDigest) error {
+	return c.post(digest)
+}
+
+// post sends an event to the webhook as JSON
+func (c *WebhookClient) post(event interface{}) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.serviceKey != "" {
+		req.Header.Set(serviceKeyHeader, c.serviceKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call notification webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notification webhook error (status %d)", resp.StatusCode)
+	}
+	return nil
+}
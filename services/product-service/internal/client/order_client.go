@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+	"ecommerce/pkg/requestid"
+)
+
+// serviceKeyHeader carries this service's API key on internal calls
+const serviceKeyHeader = "X-Service-Key"
+
+// PurchaseVerifier checks whether a user has bought a product.
+// Implemented by OrderServiceClient; enables mocking in tests.
+type PurchaseVerifier interface {
+	HasPurchased(ctx context.Context, userID, productID string) (bool, error)
+}
+
+// OrderServiceClient talks to the order service's internal API
+type OrderServiceClient struct {
+	httpClient      *http.Client
+	orderServiceURL string
+	serviceKey      string
+	mutex           sync.RWMutex // guards orderServiceURL
+}
+
+// NewOrderServiceClient creates a client for the order service.
+// serviceKey is sent as X-Service-Key so the order service accepts the internal call.
+func NewOrderServiceClient(orderServiceURL, serviceKey string) *OrderServiceClient {
+	return &OrderServiceClient{
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+		orderServiceURL: orderServiceURL,
+		serviceKey:      serviceKey,
+	}
+}
+
+// UseTLS makes the client call order service over TLS with config, presenting this service's
+// certificate; order service's URL must then be https. Call it before the client is used.
+func (c *OrderServiceClient) UseTLS(config *tls.Config) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	c.httpClient.Transport = transport
+}
+
+// SetURL points the client at an order service that has moved
+func (c *OrderServiceClient) SetURL(orderServiceURL string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.orderServiceURL = orderServiceURL
+}
+
+// baseURL returns the order service's current URL
+func (c *OrderServiceClient) baseURL() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.orderServiceURL
+}
+
+// purchaseResponse is the order service's envelope for purchase checks
+type purchaseResponse struct {
+	Success bool `json:"success"`
+	Data    struct {
+		Purchased bool `json:"purchased"`
+	} `json:"data"`
+	Error string `json:"error"`
+}
+
+// HasPurchased reports whether the user has a confirmed, shipped, or delivered order containing the product
+func (c *OrderServiceClient) HasPurchased(ctx context.Context, userID, productID string) (bool, error) {
+	query := url.Values{}
+	query.Set("user_id", userID)
+	query.Set("product_id", productID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL()+"/v1/internal/purchases?"+query.Encode(), nil)
+	if err != nil {
+		return false, err
+	}
+	requestid.Propagate(req)
+	if c.serviceKey != "" {
+		req.Header.Set(serviceKeyHeader, c.serviceKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to call order service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var envelope purchaseResponse
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return false, fmt.Errorf("failed to decode order service response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || !envelope.Success {
+		return false, fmt.Errorf("order service error (status %d): %s", resp.StatusCode, envelope.Error)
+	}
+	return envelope.Data.Purchased, nil
+}
@@ -0,0 +1,57 @@
+// Package consumer handles the events other services publish that change product data
+package consumer
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"ecommerce/pkg/events"
+	"product-service/internal/models"
+)
+
+// orderServiceActor is recorded as the actor on stock movements made for order events, as when order
+// service makes them over the API
+const orderServiceActor = "order-service"
+
+// StockReleaser returns a reservation's units to stock.
+// Implemented by the product repositories; enables mocking in tests.
+type StockReleaser interface {
+	ReleaseReservation(ctx context.Context, productID, reservationID, actor string) (*models.StockReservation, error)
+}
+
+// OrderEvents adjusts stock for order events: when an order is cancelled, the reservations its event
+// names are released. Order service names them only when it leaves their release to this service.
+type OrderEvents struct {
+	stock StockReleaser
+}
+
+// NewOrderEvents creates a handler releasing stock through stock
+func NewOrderEvents(stock StockReleaser) *OrderEvents {
+	return &OrderEvents{stock: stock}
+}
+
+// Handle processes one order event. Events are delivered at least once, so a reservation already
+// released, or gone, counts as released; any other failure is returned for the event to be retried.
+func (c *OrderEvents) Handle(ctx context.Context, msg events.Message) error {
+	event, err := events.DecodeOrderEvent(msg)
+	if err != nil {
+		// Retrying can't fix a malformed event
+		slog.ErrorContext(ctx, "Skipping undecodable order event", "offset", msg.Offset, "error", err)
+		return nil
+	}
+	if event.Type != events.OrderCancelled {
+		return nil
+	}
+
+	for _, held := range event.Data.Release {
+		_, err := c.stock.ReleaseReservation(ctx, held.ProductID, held.ReservationID, orderServiceActor)
+		switch {
+		case err == nil:
+			slog.InfoContext(ctx, "Released stock of cancelled order", "order_id", event.OrderID, "product_id", held.ProductID, "reservation_id", held.ReservationID)
+		case errors.Is(err, models.ErrReservationClosed), errors.Is(err, models.ErrReservationNotFound):
+		default:
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"ecommerce/pkg/events"
+	"product-service/internal/models"
+)
+
+type fakeStock struct {
+	err      error
+	released []string
+}
+
+func (f *fakeStock) ReleaseReservation(ctx context.Context, productID, reservationID, actor string) (*models.StockReservation, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.released = append(f.released, productID+"/"+reservationID)
+	return &models.StockReservation{ID: reservationID, ProductID: productID}, nil
+}
+
+func orderMessage(t *testing.T, event events.OrderEvent) events.Message {
+	value, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events.Message{Topic: events.DefaultOrderTopic, Key: event.OrderID, Value: value}
+}
+
+func TestOrderEvents_ReleasesStockOfCancelledOrders(t *testing.T) {
+	stock := &fakeStock{}
+	consumer := NewOrderEvents(stock)
+	release := []events.HeldStock{{ProductID: "p1", ReservationID: "r1"}, {ProductID: "p2", ReservationID: "r2"}}
+
+	created := orderMessage(t, events.OrderEvent{ID: "e1", Type: events.OrderCreated, OrderID: "o1", Data: events.OrderEventData{Release: release}})
+	if err := consumer.Handle(context.Background(), created); err != nil || len(stock.released) != 0 {
+		t.Fatalf("expected other events to be ignored, got %v %v", err, stock.released)
+	}
+
+	cancelled := orderMessage(t, events.OrderEvent{ID: "e2", Type: events.OrderCancelled, OrderID: "o1", Data: events.OrderEventData{Release: release}})
+	if err := consumer.Handle(context.Background(), cancelled); err != nil {
+		t.Fatalf("handle failed: %v", err)
+	}
+	if len(stock.released) != 2 || stock.released[0] != "p1/r1" || stock.released[1] != "p2/r2" {
+		t.Fatalf("expected both reservations released, got %v", stock.released)
+	}
+
+	// A redelivered event finds its reservations already closed
+	stock.err = models.ErrReservationClosed
+	if err := consumer.Handle(context.Background(), cancelled); err != nil {
+		t.Fatalf("expected a redelivery to be accepted, got %v", err)
+	}
+
+	stock.err = errors.New("cluster unavailable")
+	if err := consumer.Handle(context.Background(), cancelled); err == nil {
+		t.Error("expected a failed release to be retried")
+	}
+
+	if err := consumer.Handle(context.Background(), events.Message{Value: json.RawMessage(`"not an event"`)}); err != nil {
+		t.Errorf("expected a malformed event to be skipped, got %v", err)
+	}
+}
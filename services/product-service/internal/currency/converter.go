@@ -0,0 +1,120 @@
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"product-service/internal/models"
+)
+
+// ErrUnsupportedCurrency is returned for currency codes without a configured rate
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// Converter converts amounts between currencies using fixed rates against the base currency.
+// Rates are expressed as units of the currency per one unit of the base currency.
+type Converter struct {
+	base  string
+	rates map[string]float64
+}
+
+// NewConverter creates a converter for the given rates; the base currency always has rate 1
+func NewConverter(base string, rates map[string]float64) *Converter {
+	base = Normalize(base)
+	converter := &Converter{
+		base:  base,
+		rates: map[string]float64{base: 1},
+	}
+	for code, rate := range rates {
+		if code = Normalize(code); code != base && rate > 0 {
+			converter.rates[code] = rate
+		}
+	}
+	return converter
+}
+
+// ParseRates parses a rate list such as "EUR=0.92,GBP=0.79"
+func ParseRates(spec string) (map[string]float64, error) {
+	rates := make(map[string]float64)
+	for _, entry := range strings.Split(spec, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		code, value, found := strings.Cut(entry, "=")
+		code = Normalize(code)
+		if !found || len(code) != 3 {
+			return nil, fmt.Errorf("invalid currency rate %q", entry)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid rate for %s: %q", code, value)
+		}
+		rates[code] = rate
+	}
+	return rates, nil
+}
+
+// Normalize upper-cases and trims a currency code
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Base returns the base currency code
+func (c *Converter) Base() string {
+	return c.base
+}
+
+// Supports reports whether the converter has a rate for the currency
+func (c *Converter) Supports(code string) bool {
+	_, exists := c.rates[Normalize(code)]
+	return exists
+}
+
+// Convert converts an amount between currencies, rounded to two decimal places
+func (c *Converter) Convert(amount float64, from, to string) (float64, error) {
+	fromRate, exists := c.rates[Normalize(from)]
+	if !exists {
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, from)
+	}
+	toRate, exists := c.rates[Normalize(to)]
+	if !exists {
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, to)
+	}
+	if fromRate == toRate {
+		return amount, nil
+	}
+	return math.Round(amount/fromRate*toRate*100) / 100, nil
+}
+
+// ConvertProduct re-prices a product into another currency in place
+func (c *Converter) ConvertProduct(product *models.Product, to string) error {
+	to = Normalize(to)
+	from := product.Currency
+	if from == "" {
+		from = c.base
+	}
+	if from == to {
+		return nil
+	}
+
+	convert := func(amount float64) (float64, error) {
+		return c.Convert(amount, from, to)
+	}
+	var err error
+	if product.Price, err = convert(product.Price); err != nil {
+		return err
+	}
+	if product.EffectivePrice, err = convert(product.EffectivePrice); err != nil {
+		return err
+	}
+	if product.SalePrice != nil {
+		salePrice, err := convert(*product.SalePrice)
+		if err != nil {
+			return err
+		}
+		product.SalePrice = &salePrice
+	}
+	product.Currency = to
+	return nil
+}
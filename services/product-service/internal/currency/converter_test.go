@@ -0,0 +1,39 @@
+package currency
+
+import (
+	"errors"
+	"testing"
+	"product-service/internal/models"
+)
+
+func TestParseRates(t *testing.T) {
+	rates, err := ParseRates(" eur=0.9, GBP=0.8 ,")
+	if err != nil || len(rates) != 2 || rates["EUR"] != 0.9 || rates["GBP"] != 0.8 {
+		t.Fatalf("unexpected rates %v (%v)", rates, err)
+	}
+	for _, spec := range []string{"EUR", "EURO=1", "EUR=abc", "EUR=-1"} {
+		if _, err := ParseRates(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestConverter_ConvertProduct(t *testing.T) {
+	converter := NewConverter("USD", map[string]float64{"EUR": 0.9, "KES": 129})
+
+	if amount, _ := converter.Convert(100, "EUR", "KES"); amount != 14333.33 {
+		t.Errorf("expected cross rate through the base currency, got %v", amount)
+	}
+	if _, err := converter.Convert(1, "USD", "JPY"); !errors.Is(err, ErrUnsupportedCurrency) {
+		t.Errorf("expected unsupported currency error, got %v", err)
+	}
+
+	salePrice := 8.0
+	product := &models.Product{Price: 10, SalePrice: &salePrice, EffectivePrice: 8, Currency: "USD"}
+	if err := converter.ConvertProduct(product, "eur"); err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	if product.Currency != "EUR" || product.Price != 9 || *product.SalePrice != 7.2 || product.EffectivePrice != 7.2 {
+		t.Errorf("unexpected converted product %+v", product)
+	}
+}
@@ -0,0 +1,74 @@
+// Package digest builds the summaries this service sends the notification layer on a schedule
+package digest
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"product-service/internal/client"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+)
+
+// Low-stock digest defaults: products with 5 units or fewer, listed at 08:00 every day
+const (
+	DefaultLowStockThreshold = 5
+	DefaultLowStockSchedule  = "0 8 * * *"
+)
+
+// LowStock sends the digest of published physical products with threshold units or fewer in
+// stock, lowest first. Digital products aren't limited by stock, so they are never listed.
+type LowStock struct {
+	products  repository.ProductRepository
+	publisher client.LowStockDigestPublisher
+	threshold int
+}
+
+// NewLowStock creates the low-stock digest. Digests go to publisher; when it is nil they are only logged.
+func NewLowStock(products repository.ProductRepository, publisher client.LowStockDigestPublisher, threshold int) *LowStock {
+	return &LowStock{products: products, publisher: publisher, threshold: threshold}
+}
+
+// Send publishes the digest and returns how many products it listed. Nothing is sent when no
+// product is running low.
+func (l *LowStock) Send(ctx context.Context) (int, error) {
+	var low []*models.Product
+	filter := &models.ProductFilter{Limit: models.MaxPageLimit, Status: models.ProductStatusPublished}
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		products, pageInfo, err := l.products.List(ctx, filter)
+		if err != nil {
+			return 0, err
+		}
+		for _, product := range products {
+			if !product.IsDigital() && product.Stock <= l.threshold {
+				low = append(low, product)
+			}
+		}
+		if pageInfo == nil || !pageInfo.HasMore || pageInfo.NextCursor == "" {
+			break
+		}
+		filter.Cursor = pageInfo.NextCursor
+	}
+	if len(low) == 0 {
+		return 0, nil
+	}
+
+	sort.Slice(low, func(i, j int) bool {
+		if low[i].Stock != low[j].Stock {
+			return low[i].Stock < low[j].Stock
+		}
+		return low[i].Name < low[j].Name
+	})
+	digest := models.NewLowStockDigest(l.threshold, low)
+	if l.publisher == nil {
+		slog.Info("Products are running low on stock", "count", len(low), "threshold", l.threshold)
+		return len(low), nil
+	}
+	if err := l.publisher.PublishLowStockDigest(digest); err != nil {
+		return 0, err
+	}
+	return len(low), nil
+}
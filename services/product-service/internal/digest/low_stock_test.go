@@ -0,0 +1,59 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+)
+
+// stubPublisher records the digests it is given
+type stubPublisher struct {
+	digests []*models.LowStockDigest
+	err     error
+}
+
+func (s *stubPublisher) PublishLowStockDigest(digest *models.LowStockDigest) error {
+	s.digests = append(s.digests, digest)
+	return s.err
+}
+
+func TestLowStock_Send(t *testing.T) {
+	products := repository.NewInMemoryProductRepository()
+	publisher := &stubPublisher{}
+	lowStock := NewLowStock(products, publisher, 5)
+
+	// The sample products are all well stocked
+	if count, err := lowStock.Send(context.Background()); err != nil || count != 0 || len(publisher.digests) != 0 {
+		t.Fatalf("expected no digest, got %d listed, %d sent, %v", count, len(publisher.digests), err)
+	}
+
+	lamp := models.NewProduct("Lamp", "", "Home", 30, 5, "")
+	lamp.SKU = "LAMP-1"
+	soldOut := models.NewProduct("Kettle", "", "Appliances", 40, 0, "")
+	ebook := models.NewProduct("Cookbook", "", "Books", 10, 0, "")
+	ebook.Kind = models.ProductKindDigital
+	draft := models.NewProduct("Prototype", "", "Home", 99, 1, "")
+	draft.Status = models.ProductStatusDraft
+	for _, product := range []*models.Product{lamp, soldOut, ebook, draft} {
+		products.Create(context.Background(), product)
+	}
+
+	count, err := lowStock.Send(context.Background())
+	if err != nil || count != 2 {
+		t.Fatalf("expected two products listed, got %d, %v", count, err)
+	}
+	digest := publisher.digests[0]
+	if digest.Type != models.EventLowStockDigest || digest.Threshold != 5 {
+		t.Errorf("unexpected digest %+v", digest)
+	}
+	if digest.Products[0].ProductID != soldOut.ID || digest.Products[1].SKU != "LAMP-1" {
+		t.Errorf("expected the sold-out kettle first, then the lamp, got %+v", digest.Products)
+	}
+
+	publisher.err = errors.New("webhook down")
+	if _, err := lowStock.Send(context.Background()); err == nil {
+		t.Error("expected a failed delivery to be reported")
+	}
+}
@@ -0,0 +1,154 @@
+package duplicate
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+)
+
+// Reasons a product is considered a duplicate of another
+const (
+	ReasonSameSKU     = "same_sku"
+	ReasonSimilarName = "similar_name"
+)
+
+// DefaultThreshold is the name similarity, from 0 to 1, at which products are flagged as duplicates
+const DefaultThreshold = 0.9
+
+// ErrInvalidThreshold is returned for similarity thresholds outside (0, 1]
+var ErrInvalidThreshold = errors.New("similarity threshold must be greater than 0 and at most 1")
+
+// Match describes an existing product that a new or changed product duplicates
+type Match struct {
+	Reason     string          `json:"reason"`
+	Similarity float64         `json:"similarity"` // 1 for a shared SKU or identical normalized names
+	Product    *models.Product `json:"product"`
+}
+
+// Detector finds existing products that a product duplicates: one with the same SKU, or one whose
+// normalized name is at least as similar as the threshold. Names are compared without case,
+// punctuation, or word order, and never match when they carry different numbers, so
+// "MacBook Pro 14" and "MacBook Pro 16" are distinct while "Headphones, Wireless" and
+// "wireless headphone" are not.
+type Detector struct {
+	products  repository.ProductRepository
+	threshold float64
+}
+
+// NewDetector creates a detector checking against the catalog in products. A threshold of 1
+// only flags names that normalize to the same text.
+func NewDetector(products repository.ProductRepository, threshold float64) (*Detector, error) {
+	if threshold <= 0 || threshold > 1 {
+		return nil, ErrInvalidThreshold
+	}
+	return &Detector{products: products, threshold: threshold}, nil
+}
+
+// Find returns the best match for candidate among the other products in the catalog, or nil.
+// A shared SKU takes precedence over a similar name.
+func (d *Detector) Find(ctx context.Context, candidate *models.Product) (*Match, error) {
+	// A zero filter covers every product, including drafts
+	products, _, err := d.products.List(ctx, &models.ProductFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	name := NormalizeName(candidate.Name)
+	var best *Match
+	for _, product := range products {
+		if product.ID == candidate.ID {
+			continue
+		}
+		if candidate.SKU != "" && product.SKU == candidate.SKU {
+			return &Match{Reason: ReasonSameSKU, Similarity: 1, Product: product}, nil
+		}
+		similarity := Similarity(name, NormalizeName(product.Name))
+		if similarity >= d.threshold && (best == nil || similarity > best.Similarity) {
+			best = &Match{Reason: ReasonSimilarName, Similarity: similarity, Product: product}
+		}
+	}
+	return best, nil
+}
+
+// FindSKU returns the other product using candidate's SKU, or nil
+func (d *Detector) FindSKU(ctx context.Context, candidate *models.Product) (*models.Product, error) {
+	if candidate.SKU == "" {
+		return nil, nil
+	}
+	products, _, err := d.products.List(ctx, &models.ProductFilter{})
+	if err != nil {
+		return nil, err
+	}
+	for _, product := range products {
+		if product.ID != candidate.ID && product.SKU == candidate.SKU {
+			return product, nil
+		}
+	}
+	return nil, nil
+}
+
+// NormalizeName lowercases a product name, turns punctuation into spaces, and sorts its words
+func NormalizeName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// Similarity scores two normalized names from 0 (nothing alike) to 1 (identical) by edit
+// distance. Names with different numbers in them score 0.
+func Similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	if !sameNumbers(a, b) {
+		return 0
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// sameNumbers reports whether two normalized names contain the same numeric words
+func sameNumbers(a, b string) bool {
+	numbers := func(name string) string {
+		var found []string
+		for _, word := range strings.Fields(name) {
+			if strings.IndexFunc(word, unicode.IsDigit) >= 0 {
+				found = append(found, word)
+			}
+		}
+		return strings.Join(found, " ")
+	}
+	return numbers(a) == numbers(b)
+}
+
+// levenshtein counts the single-character edits needed to turn a into b
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
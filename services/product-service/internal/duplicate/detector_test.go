@@ -0,0 +1,61 @@
+package duplicate
+
+import (
+	"context"
+	"testing"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+)
+
+func TestSimilarity(t *testing.T) {
+	cases := []struct {
+		a, b    string
+		similar bool
+	}{
+		{"Wireless Headphones", "headphones, wireless", true},
+		{"Wireless Headphones", "Wireless Headphone", true},
+		{"MacBook Pro 16\"", "macbook-pro 16", true},
+		{"MacBook Pro 16\"", "MacBook Pro 14\"", false},
+		{"Nike Air Max", "Nike Air Max 90", false},
+		{"Coffee Maker", "Coffee Grinder", false},
+	}
+	for _, tc := range cases {
+		score := Similarity(NormalizeName(tc.a), NormalizeName(tc.b))
+		if (score >= DefaultThreshold) != tc.similar {
+			t.Errorf("%q vs %q: similarity %.2f, expected similar=%v", tc.a, tc.b, score, tc.similar)
+		}
+	}
+}
+
+func TestDetector_Find(t *testing.T) {
+	repo := repository.NewInMemoryProductRepository()
+	lamp := models.NewProduct("Desk Lamp", "", "Lighting", 30, 1, "")
+	lamp.SKU = "LAMP-001"
+	_ = repo.Create(context.Background(), lamp)
+
+	detector, err := NewDetector(repo, DefaultThreshold)
+	if err != nil {
+		t.Fatalf("failed to create detector: %v", err)
+	}
+
+	candidate := models.NewProduct("Desk Lamps", "", "Lighting", 30, 1, "")
+	match, _ := detector.Find(context.Background(), candidate)
+	if match == nil || match.Reason != ReasonSimilarName || match.Product.ID != lamp.ID {
+		t.Fatalf("expected a similar name match, got %+v", match)
+	}
+
+	candidate = models.NewProduct("Standing Desk", "", "Furniture", 300, 1, "")
+	candidate.SKU = "LAMP-001"
+	if match, _ := detector.Find(context.Background(), candidate); match == nil || match.Reason != ReasonSameSKU {
+		t.Fatalf("expected a SKU match, got %+v", match)
+	}
+
+	// A product never duplicates itself
+	if existing, _ := detector.FindSKU(context.Background(), lamp); existing != nil {
+		t.Fatalf("expected no other product with the SKU, got %s", existing.Name)
+	}
+
+	if _, err := NewDetector(repo, 1.5); err != ErrInvalidThreshold {
+		t.Fatalf("expected ErrInvalidThreshold, got %v", err)
+	}
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"ecommerce/pkg/api"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// CategoryHandler handles HTTP requests related to product categories
+type CategoryHandler struct {
+	repo     repository.CategoryRepository
+	products repository.ProductRepository
+}
+
+// NewCategoryHandler creates a new category handler
+func NewCategoryHandler(repo repository.CategoryRepository, products repository.ProductRepository) *CategoryHandler {
+	return &CategoryHandler{
+		repo:     repo,
+		products: products,
+	}
+}
+
+// CreateCategory handles POST /categories - creates a new category
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.CreateCategoryRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	category := models.NewCategory(req.Name, req.Description, req.ParentID)
+	if req.Slug != "" {
+		category.ID = models.Slugify(req.Slug)
+	}
+	if category.ID == "" {
+		api.WriteError(w, http.StatusBadRequest, "Name or slug must contain letters or digits")
+		return
+	}
+
+	if req.ParentID != "" {
+		if _, err := h.repo.GetByID(req.ParentID); err != nil {
+			api.WriteErrorCode(w, http.StatusBadRequest, CodeCategoryNotFound, "Parent category does not exist")
+			return
+		}
+	}
+
+	if err := h.repo.Create(category); err != nil {
+		slog.ErrorContext(r.Context(), "Error creating category", "error", err)
+		api.WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Category created successfully",
+		Data:    category,
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetCategory handles GET /categories/{id} - retrieves a category with its direct subcategories
+func (h *CategoryHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	category, err := h.repo.GetByID(mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeCategoryNotFound, "Category not found")
+		return
+	}
+
+	children, err := h.repo.Children(category.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing subcategories", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve category")
+		return
+	}
+
+	node := &models.CategoryNode{Category: *category}
+	for _, child := range children {
+		node.Children = append(node.Children, &models.CategoryNode{Category: *child})
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    node,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListCategories handles GET /categories - lists all categories, or the full hierarchy with ?tree=true
+func (h *CategoryHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	categories, err := h.repo.List()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing categories", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve categories")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    categories,
+	}
+	if r.URL.Query().Get("tree") == "true" {
+		response.Data = buildCategoryTree(categories)
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateCategory handles PUT /categories/{id} - renames, describes, or moves a category
+func (h *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	category, err := h.repo.GetByID(mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeCategoryNotFound, "Category not found")
+		return
+	}
+
+	var req models.UpdateCategoryRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	renamed := req.Name != nil && *req.Name != category.Name
+	if req.Name != nil {
+		if *req.Name == "" {
+			api.WriteError(w, http.StatusBadRequest, "Name cannot be empty")
+			return
+		}
+		category.Name = *req.Name
+	}
+	if req.Description != nil {
+		category.Description = *req.Description
+	}
+	if req.ParentID != nil {
+		category.ParentID = *req.ParentID
+	}
+
+	if err := h.repo.Update(category); err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Keep the category name stored on products in sync
+	if renamed {
+		h.renameProducts(r.Context(), category)
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Category updated successfully",
+		Data:    category,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteCategory handles DELETE /categories/{id} - removes a category with no subcategories or products
+func (h *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	categoryID := mux.Vars(r)["id"]
+	if _, err := h.repo.GetByID(categoryID); err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeCategoryNotFound, "Category not found")
+		return
+	}
+
+	products, _, err := h.products.List(r.Context(), &models.ProductFilter{CategoryIDs: []string{categoryID}, Limit: 1})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking category products", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to delete category")
+		return
+	}
+	if len(products) > 0 {
+		api.WriteErrorCode(w, http.StatusConflict, CodeCategoryNotEmpty, "Category still has products")
+		return
+	}
+
+	if err := h.repo.Delete(categoryID); err != nil {
+		api.WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Category deleted successfully",
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// renameProducts updates the denormalized category name on every product in the category
+func (h *CategoryHandler) renameProducts(ctx context.Context, category *models.Category) {
+	products, _, err := h.products.List(ctx, &models.ProductFilter{CategoryIDs: []string{category.ID}})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing products for category", "category_id", category.ID, "error", err)
+		return
+	}
+	for _, product := range products {
+		product.Category = category.Name
+		if err := h.products.Update(ctx, product); err != nil {
+			slog.ErrorContext(ctx, "Error renaming category on product", "product_id", product.ID, "error", err)
+		}
+	}
+}
+
+// buildCategoryTree nests a flat, name-sorted category list under its roots
+func buildCategoryTree(categories []*models.Category) []*models.CategoryNode {
+	nodes := make(map[string]*models.CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &models.CategoryNode{Category: *category}
+	}
+
+	roots := make([]*models.CategoryNode, 0)
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if parent, ok := nodes[category.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+func TestCategoryHandler_CreateAndFilterBySubcategory(t *testing.T) {
+	products := repository.NewInMemoryProductRepository()
+	categories := repository.NewInMemoryCategoryRepository()
+	h := NewCategoryHandler(categories, products)
+	ph := NewProductHandler(products, categories, testCurrencies(), nil)
+
+	rec := httptest.NewRecorder()
+	h.CreateCategory(rec, httptest.NewRequest(http.MethodPost, "/categories", bytes.NewBufferString(`{"name":"Laptops","parent_id":"electronics"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	ph.CreateProduct(rec, httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(`{"name":"ThinkPad","category_id":"laptops","price":1200,"stock":3}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	ph.CreateProduct(rec, httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(`{"name":"Mystery","category":"Nowhere","price":1,"stock":1}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown category got %d", rec.Code)
+	}
+
+	// Filtering by the parent includes products in subcategories
+	rec = httptest.NewRecorder()
+	ph.ListProducts(rec, httptest.NewRequest(http.MethodGet, "/products?category=Electronics&limit=100", nil))
+	var listed []models.Product
+	resp := models.Response{Data: &listed}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	found := false
+	for _, p := range listed {
+		found = found || p.Name == "ThinkPad"
+	}
+	if !found {
+		t.Error("expected subcategory product in parent category listing")
+	}
+
+	// Categories with products cannot be deleted
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/categories/laptops", nil), map[string]string{"id": "laptops"})
+	rec = httptest.NewRecorder()
+	h.DeleteCategory(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 got %d", rec.Code)
+	}
+}
+
+func TestCategoryHandler_RenamePropagatesToProducts(t *testing.T) {
+	products := seededProducts()
+	categories := repository.NewInMemoryCategoryRepository()
+	h := NewCategoryHandler(categories, products)
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/categories/footwear", bytes.NewBufferString(`{"name":"Shoes"}`)), map[string]string{"id": "footwear"})
+	rec := httptest.NewRecorder()
+	h.UpdateCategory(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+
+	list, _, _ := products.List(context.Background(), &models.ProductFilter{CategoryIDs: []string{"footwear"}})
+	if len(list) == 0 || list[0].Category != "Shoes" {
+		t.Fatalf("expected products to carry the new category name, got %+v", list)
+	}
+}
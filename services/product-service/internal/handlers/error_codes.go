@@ -0,0 +1,23 @@
+package handlers
+
+// Error codes the product service sends in the code of an error response, so clients can tell
+// failures apart without matching messages. Errors without one of these get a code named after
+// their status, such as NOT_FOUND.
+const (
+	CodeProductNotFound            = "PRODUCT_NOT_FOUND"
+	CodeProductInsufficientStock   = "PRODUCT_INSUFFICIENT_STOCK"
+	CodeProductInStock             = "PRODUCT_IN_STOCK"  // stock alerts are only for products that are out
+	CodeProductDuplicate           = "PRODUCT_DUPLICATE" // data holds the similar product
+	CodeProductSKUTaken            = "PRODUCT_SKU_TAKEN"
+	CodeCategoryNotFound           = "CATEGORY_NOT_FOUND"
+	CodeCategoryNotEmpty           = "CATEGORY_NOT_EMPTY"
+	CodeWarehouseNotFound          = "WAREHOUSE_NOT_FOUND"
+	CodeWarehouseInsufficientStock = "WAREHOUSE_INSUFFICIENT_STOCK"
+	CodeImageNotFound              = "IMAGE_NOT_FOUND"
+	CodeReservationNotFound        = "RESERVATION_NOT_FOUND"
+	CodeReservationInactive        = "RESERVATION_INACTIVE" // the reservation was released, committed, or expired
+	CodeReviewExists               = "REVIEW_EXISTS"
+	CodeReviewPurchaseRequired     = "REVIEW_PURCHASE_REQUIRED"
+	CodeStockAlertExists           = "STOCK_ALERT_EXISTS"
+	CodeUnsupportedCurrency        = "UNSUPPORTED_CURRENCY"
+)
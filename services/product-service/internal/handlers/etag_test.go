@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"product-service/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+func TestProductReads_ConditionalGet(t *testing.T) {
+	h := setupProductHandler()
+	product := models.NewProduct("ETag Lamp", "", "Electronics", 20, 5, "")
+	_ = h.repo.Create(context.Background(), product)
+	vars := map[string]string{"id": product.ID}
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/products/"+product.ID, nil), vars)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		h.GetProduct(rec, req)
+		return rec
+	}
+
+	first := get("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with an ETag, got %d %q", first.Code, etag)
+	}
+	if rec := get(`"other", W/` + etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("expected bodyless 304 for a matching ETag, got %d", rec.Code)
+	}
+
+	_, _ = h.repo.AdjustStock(context.Background(), product.ID, -1, models.StockSource{})
+	if rec := get(etag); rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Fatalf("expected a new version after the product changed, got %d", rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ListProducts(rec, httptest.NewRequest(http.MethodGet, "/products", nil))
+	listReq := httptest.NewRequest(http.MethodGet, "/products", nil)
+	listReq.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	again := httptest.NewRecorder()
+	h.ListProducts(again, listReq)
+	if again.Code != http.StatusNotModified || again.Body.Len() != 0 {
+		t.Fatalf("expected 304 for an unchanged list, got %d", again.Code)
+	}
+}
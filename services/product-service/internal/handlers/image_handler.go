@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"ecommerce/pkg/api"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+	"product-service/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// MaxImageUploadSize is the largest image file accepted by UploadImage
+const MaxImageUploadSize = 5 << 20
+
+// uploadImageTypes maps the image formats accepted for upload to their file extensions
+var uploadImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// ImageHandler handles HTTP requests for a product's image gallery
+type ImageHandler struct {
+	repo      repository.ProductRepository
+	storage   storage.Storage
+	publicURL string
+}
+
+// NewImageHandler creates a new image handler. Uploaded files are kept in storage;
+// publicURL is this service's external address, used to build download links.
+func NewImageHandler(repo repository.ProductRepository, store storage.Storage, publicURL string) *ImageHandler {
+	return &ImageHandler{
+		repo:      repo,
+		storage:   store,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+	}
+}
+
+// ListImages handles GET /products/{id}/images - returns the product's images in order
+func (h *ImageHandler) ListImages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	product, err := h.repo.GetByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    product.Images,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// AddImage handles POST /products/{id}/images - adds an image, appending unless a position is given
+func (h *ImageHandler) AddImage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	product, err := h.repo.GetByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+
+	var req models.AddImageRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	if parsed, err := url.ParseRequestURI(req.URL); err != nil || parsed.Host == "" {
+		api.WriteError(w, http.StatusBadRequest, "A valid absolute image URL is required")
+		return
+	}
+
+	position := -1
+	if req.Position != nil {
+		position = *req.Position
+	}
+	image := product.AddImage(req.URL, req.AltText, position)
+
+	if err := h.repo.Update(r.Context(), product); err != nil {
+		slog.ErrorContext(r.Context(), "Error adding product image", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to add image")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Image added successfully",
+		Data:    image,
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// UploadImage handles POST /products/{id}/images/upload - stores an uploaded image file (multipart
+// "file" field, optional "alt_text" and zero-based "position") and adds it to the gallery
+func (h *ImageHandler) UploadImage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	product, err := h.repo.GetByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+
+	// Leave room for the multipart framing and the other form fields
+	r.Body = http.MaxBytesReader(w, r.Body, MaxImageUploadSize+64<<10)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			api.WriteError(w, http.StatusRequestEntityTooLarge, "Images are limited to 5 MB")
+			return
+		}
+		api.WriteError(w, http.StatusBadRequest, "An image file is required in the multipart \"file\" field")
+		return
+	}
+	defer file.Close()
+	if header.Size > MaxImageUploadSize {
+		api.WriteError(w, http.StatusRequestEntityTooLarge, "Images are limited to 5 MB")
+		return
+	}
+
+	// Trust the file's contents rather than the declared content type
+	sniff := make([]byte, 512)
+	n, _ := io.ReadFull(file, sniff)
+	contentType := http.DetectContentType(sniff[:n])
+	extension, allowed := uploadImageTypes[contentType]
+	if !allowed {
+		api.WriteError(w, http.StatusUnsupportedMediaType, "Images must be JPEG, PNG, GIF, or WebP")
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		api.WriteError(w, http.StatusInternalServerError, "Failed to read upload")
+		return
+	}
+
+	position := -1
+	if value := r.FormValue("position"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			api.WriteError(w, http.StatusBadRequest, "position must be a non-negative integer")
+			return
+		}
+		position = parsed
+	}
+
+	imageID := uuid.New().String()
+	key := fmt.Sprintf("products/%s/%s%s", product.ID, imageID, extension)
+	if err := h.storage.Put(key, contentType, file, header.Size); err != nil {
+		slog.ErrorContext(r.Context(), "Error storing product image", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to store image")
+		return
+	}
+
+	image := product.InsertImage(models.ProductImage{
+		ID:          imageID,
+		URL:         h.storage.URL(key),
+		AltText:     r.FormValue("alt_text"),
+		DownloadURL: fmt.Sprintf("%s/v1/products/%s/images/%s/download", h.publicURL, product.ID, imageID),
+		FileName:    filepath.Base(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+		StorageKey:  key,
+	}, position)
+
+	if err := h.repo.Update(r.Context(), product); err != nil {
+		slog.ErrorContext(r.Context(), "Error adding uploaded product image", "error", err)
+		h.deleteStored(key)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to add image")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Image uploaded successfully",
+		Data:    image,
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// DownloadImage handles GET /products/{id}/images/{image_id}/download - serves an uploaded image
+// as an attachment; images added by URL are redirected to
+func (h *ImageHandler) DownloadImage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	product, err := h.repo.GetByID(r.Context(), vars["id"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+	image, found := product.Image(vars["image_id"])
+	if !found {
+		w.Header().Set("Content-Type", "application/json")
+		api.WriteErrorCode(w, http.StatusNotFound, CodeImageNotFound, "Image not found")
+		return
+	}
+	if image.StorageKey == "" {
+		http.Redirect(w, r, image.URL, http.StatusFound)
+		return
+	}
+
+	object, err := h.storage.Get(image.StorageKey)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading product image", "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		api.WriteErrorCode(w, http.StatusNotFound, CodeImageNotFound, "Image file not found")
+		return
+	}
+	defer object.Body.Close()
+
+	fileName := image.FileName
+	if fileName == "" {
+		fileName = path.Base(image.StorageKey)
+	}
+	w.Header().Set("Content-Type", image.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	if object.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(object.Size, 10))
+	}
+	io.Copy(w, object.Body)
+}
+
+// deleteStored removes an uploaded file, logging rather than failing since the gallery is already consistent
+func (h *ImageHandler) deleteStored(key string) {
+	if err := h.storage.Delete(key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		slog.Error("Error deleting stored image", "key", key, "error", err)
+	}
+}
+
+// RemoveImage handles DELETE /products/{id}/images/{image_id} - removes an image
+func (h *ImageHandler) RemoveImage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	product, err := h.repo.GetByID(r.Context(), vars["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+
+	image, found := product.Image(vars["image_id"])
+	if !found {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeImageNotFound, "Image not found")
+		return
+	}
+	product.RemoveImage(image.ID)
+
+	if err := h.repo.Update(r.Context(), product); err != nil {
+		slog.ErrorContext(r.Context(), "Error removing product image", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to remove image")
+		return
+	}
+	if image.StorageKey != "" {
+		h.deleteStored(image.StorageKey)
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Image removed successfully",
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// ReorderImages handles PUT /products/{id}/images/order - sets the gallery order; the first image becomes primary
+func (h *ImageHandler) ReorderImages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	product, err := h.repo.GetByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+
+	var req models.ReorderImagesRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	if !product.ReorderImages(req.ImageIDs) {
+		api.WriteError(w, http.StatusBadRequest, "image_ids must list every image of the product exactly once")
+		return
+	}
+
+	if err := h.repo.Update(r.Context(), product); err != nil {
+		slog.ErrorContext(r.Context(), "Error reordering product images", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to reorder images")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Images reordered successfully",
+		Data:    product.Images,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
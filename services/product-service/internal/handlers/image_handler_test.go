@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+	"product-service/internal/storage"
+
+	"github.com/gorilla/mux"
+)
+
+func imageRequest(method, target, body string, vars map[string]string) *http.Request {
+	return mux.SetURLVars(httptest.NewRequest(method, target, bytes.NewBufferString(body)), vars)
+}
+
+// newTestImageHandler stores uploads in a temporary directory served from /uploads
+func newTestImageHandler(t *testing.T, repo repository.ProductRepository) (*ImageHandler, *storage.LocalStorage) {
+	store, err := storage.NewLocalStorage(t.TempDir(), "http://products.test/uploads")
+	if err != nil {
+		t.Fatalf("storage setup failed: %v", err)
+	}
+	return NewImageHandler(repo, store, "http://products.test"), store
+}
+
+// uploadRequest builds a multipart image upload
+func uploadRequest(t *testing.T, productID, fileName string, content []byte) *http.Request {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", fileName)
+	part.Write(content)
+	form.WriteField("alt_text", "Front view")
+	form.Close()
+
+	req := imageRequest(http.MethodPost, "/products/"+productID+"/images/upload", "", map[string]string{"id": productID})
+	req.Body = io.NopCloser(&body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+func TestImageHandler_AddReorderRemove(t *testing.T) {
+	repo := repository.NewInMemoryProductRepository()
+	h, _ := newTestImageHandler(t, repo)
+	product := models.NewProduct("Camera", "", "Electronics", 300, 2, "https://example.com/front.jpg")
+	_ = repo.Create(context.Background(), product)
+	vars := map[string]string{"id": product.ID}
+
+	rec := httptest.NewRecorder()
+	h.AddImage(rec, imageRequest(http.MethodPost, "/products/"+product.ID+"/images", `{"url":"https://example.com/back.jpg","alt_text":"Back"}`, vars))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.AddImage(rec, imageRequest(http.MethodPost, "/products/"+product.ID+"/images", `{"url":"not a url"}`, vars))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid url got %d", rec.Code)
+	}
+
+	stored, _ := repo.GetByID(context.Background(), product.ID)
+	if len(stored.Images) != 2 || stored.ImageURL != "https://example.com/front.jpg" {
+		t.Fatalf("expected two images with front as primary, got %+v", stored.Images)
+	}
+
+	// Swapping the order makes the back image primary
+	order := `{"image_ids":["` + stored.Images[1].ID + `","` + stored.Images[0].ID + `"]}`
+	rec = httptest.NewRecorder()
+	h.ReorderImages(rec, imageRequest(http.MethodPut, "/products/"+product.ID+"/images/order", order, vars))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+	reordered, _ := repo.GetByID(context.Background(), product.ID)
+	if reordered.ImageURL != "https://example.com/back.jpg" {
+		t.Errorf("expected image_url to follow the new primary image, got %s", reordered.ImageURL)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ReorderImages(rec, imageRequest(http.MethodPut, "/products/"+product.ID+"/images/order", `{"image_ids":["`+stored.Images[0].ID+`"]}`, vars))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for partial order got %d", rec.Code)
+	}
+
+	removeVars := map[string]string{"id": product.ID, "image_id": reordered.Images[0].ID}
+	rec = httptest.NewRecorder()
+	h.RemoveImage(rec, imageRequest(http.MethodDelete, "/products/"+product.ID+"/images/x", "", removeVars))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+	remaining, _ := repo.GetByID(context.Background(), product.ID)
+	if len(remaining.Images) != 1 || remaining.ImageURL != "https://example.com/front.jpg" {
+		t.Errorf("expected front image to be primary again, got %+v", remaining.Images)
+	}
+}
+
+func TestImageHandler_UploadDownloadRemove(t *testing.T) {
+	repo := repository.NewInMemoryProductRepository()
+	h, store := newTestImageHandler(t, repo)
+	product := models.NewProduct("Upload Camera", "", "Electronics", 300, 2, "")
+	_ = repo.Create(context.Background(), product)
+
+	// Smallest valid PNG header is enough for content sniffing
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+	rec := httptest.NewRecorder()
+	h.UploadImage(rec, uploadRequest(t, product.ID, "front.png", png))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d %s", rec.Code, rec.Body.String())
+	}
+
+	stored, _ := repo.GetByID(context.Background(), product.ID)
+	image := stored.Images[0]
+	if image.ContentType != "image/png" || image.FileName != "front.png" || image.AltText != "Front view" ||
+		image.URL != "http://products.test/uploads/"+image.StorageKey ||
+		image.DownloadURL != "http://products.test/v1/products/"+product.ID+"/images/"+image.ID+"/download" {
+		t.Fatalf("unexpected uploaded image %+v", image)
+	}
+
+	rec = httptest.NewRecorder()
+	store.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+image.StorageKey, nil))
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), png) {
+		t.Fatalf("expected stored file to be served, got %d", rec.Code)
+	}
+
+	vars := map[string]string{"id": product.ID, "image_id": image.ID}
+	rec = httptest.NewRecorder()
+	h.DownloadImage(rec, imageRequest(http.MethodGet, "/products/"+product.ID+"/images/"+image.ID+"/download", "", vars))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Disposition") != `attachment; filename=front.png` {
+		t.Fatalf("expected attachment download, got %d %q", rec.Code, rec.Header().Get("Content-Disposition"))
+	}
+
+	rec = httptest.NewRecorder()
+	h.UploadImage(rec, uploadRequest(t, product.ID, "notes.txt", []byte("not an image")))
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415 for a non-image upload got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.RemoveImage(rec, imageRequest(http.MethodDelete, "/products/"+product.ID+"/images/"+image.ID, "", vars))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+	if _, err := store.Get(image.StorageKey); err != storage.ErrNotFound {
+		t.Errorf("expected stored file to be deleted with the image, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"ecommerce/pkg/openapi"
+	"product-service/internal/models"
+)
+
+// productFilterQuery is the query parameters products are listed and exported by
+var productFilterQuery = map[string]string{
+	"category":  "only products in this category or its subcategories",
+	"min_price": "only products costing at least this much",
+	"max_price": "only products costing at most this much",
+	"in_stock":  "true for only products in stock",
+	"tag":       "only products with this tag; may repeat, and products must have every tag",
+	"sort":      "price or created_at",
+	"order":     "asc or desc",
+	"status":    "draft, scheduled, published (default), or all",
+}
+
+// OpenAPI describes the product service's routes for its OpenAPI document
+func OpenAPI() *openapi.Spec {
+	spec := openapi.New("Product Service", "1.0.0", "Keeps the catalog: products, categories, images, reviews, stock, and warehouses.")
+	service := []string{openapi.ServiceKey}
+	currency := map[string]string{"currency": "a currency to convert prices to, such as EUR"}
+
+	// Products
+	spec.Describe("GET", "/v1/products", openapi.Route{Summary: "List products", Response: []models.Product{}, Page: models.PageInfo{}, Query: merge(productFilterQuery, currency, map[string]string{
+		"page":   "the page to return, from 1",
+		"cursor": "the next_cursor of the previous page, in place of page",
+		"limit":  "how many products a page holds",
+	})})
+	spec.Describe("POST", "/v1/products", openapi.Route{Summary: "Create a product", Body: models.CreateProductRequest{}, Response: models.Product{}, Status: http.StatusCreated})
+	spec.Describe("POST", "/v1/products/import", openapi.Route{Summary: "Create products from a CSV file, reporting the outcome of every row", Consumes: "text/csv", Response: models.ImportReport{}})
+	spec.Describe("GET", "/v1/products/export", openapi.Route{Summary: "Export every matching product", Query: merge(productFilterQuery, map[string]string{"format": "csv (default) or json"}), Produces: "text/csv"})
+	spec.Describe("GET", "/v1/products/search", openapi.Route{Summary: "Search products by name, category, and description, most relevant first", Response: []models.Product{}, Query: merge(productFilterQuery, currency, map[string]string{
+		"q":     "the words to search for; products must contain every one",
+		"limit": "how many products to return",
+	})})
+	spec.Describe("GET", "/v1/products/{id}", openapi.Route{Summary: "Get a published product", Response: models.Product{}, Query: merge(currency, map[string]string{"include_unpublished": "true to get a draft or scheduled product too"})})
+	spec.Describe("PUT", "/v1/products/{id}", openapi.Route{Summary: "Update a product", Body: models.UpdateProductRequest{}, Response: models.Product{}})
+	spec.Describe("PATCH", "/v1/products/{id}/stock", openapi.Route{Summary: "Set or adjust a product's stock", Body: models.UpdateStockRequest{}, Response: map[string]interface{}{}})
+	spec.Describe("GET", "/v1/products/{id}/stock-history", openapi.Route{Summary: "List a product's stock changes, newest first", Response: []models.StockMovement{}, Query: map[string]string{"limit": "how many changes to list; 50 by default"}})
+	spec.Describe("PATCH", "/v1/products/{id}/visibility", openapi.Route{Summary: "Draft, publish, or schedule a product", Body: models.UpdateVisibilityRequest{}, Response: models.Product{}})
+	spec.Describe("GET", "/v1/products/{id}/related", openapi.Route{Summary: "List products related to a product", Response: []models.Product{}, Query: merge(currency, map[string]string{"limit": "how many products to list"})})
+	spec.Describe("GET", "/v1/products/category/{category}", openapi.Route{Summary: "List the products in a category and its subcategories", Response: []models.Product{}})
+	spec.Describe("GET", "/v1/tags", openapi.Route{Summary: "List every product tag with its product count", Response: []models.TagCount{}})
+
+	// Stock reservations
+	spec.Describe("POST", "/v1/products/{id}/reserve", openapi.Route{Summary: "Set stock aside for an order", Body: models.ReserveStockRequest{}, Response: models.StockReservation{}, Status: http.StatusCreated, Auth: service})
+	spec.Describe("POST", "/v1/products/{id}/release", openapi.Route{Summary: "Return a reservation's stock", Body: models.ReservationActionRequest{}, Response: models.StockReservation{}, Auth: service})
+	spec.Describe("POST", "/v1/products/{id}/commit", openapi.Route{Summary: "Turn a reservation into a sale", Body: models.ReservationActionRequest{}, Response: models.StockReservation{}, Auth: service})
+
+	// Inventory and warehouses
+	spec.Describe("GET", "/v1/products/{id}/inventory", openapi.Route{Summary: "Get a product's stock per warehouse", Response: inventoryResponse{}})
+	spec.Describe("POST", "/v1/products/{id}/inventory/transfer", openapi.Route{Summary: "Move stock between warehouses", Body: models.TransferStockRequest{}, Response: inventoryResponse{}})
+	spec.Describe("PATCH", "/v1/products/{id}/inventory/{warehouse_id}", openapi.Route{Summary: "Set or adjust the stock held at one warehouse", Body: models.UpdateStockRequest{}, Response: inventoryResponse{}})
+	spec.Describe("GET", "/v1/warehouses", openapi.Route{Summary: "List warehouses", Response: []models.Warehouse{}})
+	spec.Describe("POST", "/v1/warehouses", openapi.Route{Summary: "Create a warehouse", Body: models.CreateWarehouseRequest{}, Response: models.Warehouse{}, Status: http.StatusCreated})
+	spec.Describe("GET", "/v1/warehouses/{id}", openapi.Route{Summary: "Get a warehouse", Response: models.Warehouse{}})
+
+	// Images
+	spec.Describe("GET", "/v1/products/{id}/images", openapi.Route{Summary: "List a product's images in order", Response: []models.ProductImage{}})
+	spec.Describe("POST", "/v1/products/{id}/images", openapi.Route{Summary: "Add an image by URL", Body: models.AddImageRequest{}, Response: models.ProductImage{}, Status: http.StatusCreated})
+	spec.Describe("POST", "/v1/products/{id}/images/upload", openapi.Route{Summary: "Upload an image file in the multipart file field", Consumes: "multipart/form-data", Response: models.ProductImage{}, Status: http.StatusCreated})
+	spec.Describe("PUT", "/v1/products/{id}/images/order", openapi.Route{Summary: "Set the gallery order; the first image becomes primary", Body: models.ReorderImagesRequest{}, Response: []models.ProductImage{}})
+	spec.Describe("DELETE", "/v1/products/{id}/images/{image_id}", openapi.Route{Summary: "Remove an image"})
+	spec.Describe("GET", "/v1/products/{id}/images/{image_id}/download", openapi.Route{Summary: "Redirect to an uploaded image", Status: http.StatusFound})
+
+	// Reviews and stock alerts
+	spec.Describe("GET", "/v1/products/{id}/reviews", openapi.Route{Summary: "List a product's reviews, newest first", Response: []models.Review{}, Page: models.PageInfo{}, Query: map[string]string{
+		"page":  "the page to return, from 1",
+		"limit": "how many reviews a page holds",
+	}})
+	spec.Describe("POST", "/v1/products/{id}/reviews", openapi.Route{Summary: "Rate and review a product", Body: models.CreateReviewRequest{}, Response: models.Review{}, Status: http.StatusCreated})
+	spec.Describe("POST", "/v1/products/{id}/notify-me", openapi.Route{Summary: "Be told when a product is back in stock", Body: models.NotifyMeRequest{}, Response: models.StockSubscription{}, Status: http.StatusCreated})
+
+	// Categories
+	spec.Describe("GET", "/v1/categories", openapi.Route{Summary: "List categories", Response: []models.Category{}, Query: map[string]string{"tree": "true for the full hierarchy"}})
+	spec.Describe("POST", "/v1/categories", openapi.Route{Summary: "Create a category", Body: models.CreateCategoryRequest{}, Response: models.Category{}, Status: http.StatusCreated})
+	spec.Describe("GET", "/v1/categories/{id}", openapi.Route{Summary: "Get a category with its direct subcategories", Response: models.CategoryNode{}})
+	spec.Describe("PUT", "/v1/categories/{id}", openapi.Route{Summary: "Rename, describe, or move a category", Body: models.UpdateCategoryRequest{}, Response: models.Category{}})
+	spec.Describe("DELETE", "/v1/categories/{id}", openapi.Route{Summary: "Remove a category with no subcategories or products"})
+
+	// Operations
+	spec.Describe("GET", "/v1/admin/config", openapi.Route{Summary: "Show the settings in effect", Auth: service})
+	spec.Describe("GET", "/openapi.json", openapi.Route{Summary: "This document"})
+	spec.Describe("GET", "/healthz", openapi.Route{Summary: "Report whether the service is running"})
+	spec.Describe("GET", "/readyz", openapi.Route{Summary: "Report whether the service can take traffic"})
+	spec.Describe("GET", "/metrics", openapi.Route{Summary: "Prometheus metrics", Produces: "text/plain"})
+	return spec
+}
+
+// merge returns the query parameters of every one of queries
+func merge(queries ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, query := range queries {
+		for name, description := range query {
+			merged[name] = description
+		}
+	}
+	return merged
+}
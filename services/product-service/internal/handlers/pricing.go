@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"product-service/internal/currency"
+	"product-service/internal/models"
+)
+
+// requestedCurrency returns the ?currency= code to show prices in, or "" to keep each product's own currency
+func requestedCurrency(r *http.Request, converter *currency.Converter) (string, error) {
+	code := currency.Normalize(r.URL.Query().Get("currency"))
+	if code == "" {
+		return "", nil
+	}
+	if !converter.Supports(code) {
+		return "", fmt.Errorf("Unsupported currency: %s", code)
+	}
+	return code, nil
+}
+
+// convertPrices re-prices products into the requested currency; it does nothing when code is empty
+func convertPrices(converter *currency.Converter, code string, products ...*models.Product) error {
+	if code == "" {
+		return nil
+	}
+	for _, product := range products {
+		if err := converter.ConvertProduct(product, code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
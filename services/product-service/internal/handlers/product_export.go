@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"ecommerce/pkg/api"
+	"product-service/internal/models"
+)
+
+// Export formats
+const (
+	ExportCSV  = "csv"
+	ExportJSON = "json"
+)
+
+// exportColumns are the CSV columns written by ExportProducts
+var exportColumns = []string{"id", "name", "description", "category_id", "category", "price", "stock", "image_url", "tags", "created_at", "updated_at"}
+
+// ExportProducts handles GET /products/export?format=csv|json - streams every product matching the
+// usual list filters (category, min_price, max_price, in_stock, tag, sort, order) without pagination.
+// The catalog is read from the repository in cursor batches so it is never held in full.
+func (h *ProductHandler) ExportProducts(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = ExportCSV
+	}
+	if format != ExportCSV && format != ExportJSON {
+		w.Header().Set("Content-Type", "application/json")
+		api.WriteError(w, http.StatusBadRequest, "format must be csv or json")
+		return
+	}
+
+	filter, err := h.filterFromQuery(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.Limit = models.MaxPageLimit
+
+	filename := "products-" + time.Now().UTC().Format("20060102-150405") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	var (
+		writeBatch func([]*models.Product) error
+		finish     func() error
+	)
+	switch format {
+	case ExportCSV:
+		w.Header().Set("Content-Type", "text/csv")
+		writer := csv.NewWriter(w)
+		if err := writer.Write(exportColumns); err != nil {
+			return
+		}
+		writeBatch = func(products []*models.Product) error {
+			for _, product := range products {
+				if err := writer.Write(productCSVRecord(product)); err != nil {
+					return err
+				}
+			}
+			writer.Flush()
+			return writer.Error()
+		}
+		finish = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	case ExportJSON:
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		first := true
+		if _, err := w.Write([]byte("[")); err != nil {
+			return
+		}
+		writeBatch = func(products []*models.Product) error {
+			for _, product := range products {
+				if !first {
+					if _, err := w.Write([]byte(",")); err != nil {
+						return err
+					}
+				}
+				first = false
+				if err := encoder.Encode(product); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		finish = func() error {
+			_, err := w.Write([]byte("]\n"))
+			return err
+		}
+	}
+
+	flusher, _ := w.(http.Flusher)
+	for {
+		products, pageInfo, err := h.repo.List(r.Context(), filter)
+		if err != nil {
+			// Headers are already sent, so the best we can do is stop and log
+			slog.ErrorContext(r.Context(), "Error exporting products", "error", err)
+			return
+		}
+		if err := writeBatch(products); err != nil {
+			slog.ErrorContext(r.Context(), "Error writing product export", "error", err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if pageInfo == nil || !pageInfo.HasMore || pageInfo.NextCursor == "" {
+			break
+		}
+		filter.Cursor = pageInfo.NextCursor
+	}
+
+	if err := finish(); err != nil {
+		slog.ErrorContext(r.Context(), "Error writing product export", "error", err)
+	}
+}
+
+// productCSVRecord flattens a product into a row matching exportColumns
+func productCSVRecord(product *models.Product) []string {
+	return []string{
+		product.ID,
+		product.Name,
+		product.Description,
+		product.CategoryID,
+		product.Category,
+		strconv.FormatFloat(product.Price, 'f', 2, 64),
+		strconv.Itoa(product.Stock),
+		product.ImageURL,
+		strings.Join(product.Tags, tagSeparator),
+		product.CreatedAt.UTC().Format(time.RFC3339),
+		product.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
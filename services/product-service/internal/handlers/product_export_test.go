@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"product-service/internal/models"
+)
+
+func TestExportProducts_CSVRespectsFilters(t *testing.T) {
+	h := setupProductHandler()
+
+	rec := httptest.NewRecorder()
+	h.ExportProducts(rec, httptest.NewRequest(http.MethodGet, "/products/export?format=csv&category=Electronics&sort=price", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid csv: %v", err)
+	}
+	// header plus the three seeded electronics products
+	if len(records) != 4 || records[0][0] != "id" {
+		t.Fatalf("expected header and 3 rows, got %v", records)
+	}
+	if records[1][1] != "Wireless Headphones" {
+		t.Errorf("expected cheapest electronics product first, got %q", records[1][1])
+	}
+}
+
+func TestExportProducts_JSONStreamsPastPageLimit(t *testing.T) {
+	h := setupProductHandler()
+	for i := 0; i < models.MaxPageLimit+5; i++ {
+		product := models.NewProduct(fmt.Sprintf("Bulk %d", i), "", "Appliances", 1, 1, "")
+		product.CategoryID = "appliances"
+		_ = h.repo.Create(context.Background(), product)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ExportProducts(rec, httptest.NewRequest(http.MethodGet, "/products/export?format=json", nil))
+
+	var products []models.Product
+	if err := json.Unmarshal(rec.Body.Bytes(), &products); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if len(products) != models.MaxPageLimit+5+5 {
+		t.Fatalf("expected every product exported, got %d", len(products))
+	}
+	seen := make(map[string]bool)
+	for _, product := range products {
+		if seen[product.ID] {
+			t.Fatalf("product %s exported twice", product.ID)
+		}
+		seen[product.ID] = true
+	}
+}
+
+func TestExportProducts_InvalidFormat(t *testing.T) {
+	h := setupProductHandler()
+	rec := httptest.NewRecorder()
+	h.ExportProducts(rec, httptest.NewRequest(http.MethodGet, "/products/export?format=xml", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 got %d", rec.Code)
+	}
+}
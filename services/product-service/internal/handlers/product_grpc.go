@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	productv1 "ecommerce/pkg/proto/product/v1"
+	"product-service/internal/currency"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ProductServer serves the ProductService gRPC API other services look the catalog up with
+type ProductServer struct {
+	productv1.UnimplementedProductServiceServer
+	repo       repository.ProductRepository
+	currencies *currency.Converter
+}
+
+// NewProductServer creates a new ProductService server. The converter serves prices in the
+// currency a call asks for.
+func NewProductServer(repo repository.ProductRepository, currencies *currency.Converter) *ProductServer {
+	return &ProductServer{repo: repo, currencies: currencies}
+}
+
+// GetProduct returns a published product, the gRPC counterpart of GET /products/{id}
+func (s *ProductServer) GetProduct(ctx context.Context, req *productv1.GetProductRequest) (*productv1.Product, error) {
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "Product ID is required")
+	}
+	code := currency.Normalize(req.Currency)
+	if code != "" && !s.currencies.Supports(code) {
+		return nil, status.Errorf(codes.InvalidArgument, "Unsupported currency: %s", code)
+	}
+
+	product, err := s.repo.GetByID(ctx, req.Id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting product", "error", err)
+		return nil, status.Error(codes.NotFound, "Product not found")
+	}
+	// Drafts and products that aren't live yet can't be ordered, so other services never see them
+	if product.Status != models.ProductStatusPublished {
+		return nil, status.Error(codes.NotFound, "Product not found")
+	}
+	if err := convertPrices(s.currencies, code, product); err != nil {
+		slog.ErrorContext(ctx, "Error converting prices", "error", err)
+		return nil, status.Error(codes.Internal, "Failed to convert prices")
+	}
+	return productMessage(product), nil
+}
+
+// BatchGetProducts returns the published products with the given IDs, so callers resolving many
+// products make one call
+func (s *ProductServer) BatchGetProducts(ctx context.Context, req *productv1.BatchGetProductsRequest) (*productv1.BatchGetProductsResponse, error) {
+	code := currency.Normalize(req.Currency)
+	if code != "" && !s.currencies.Supports(code) {
+		return nil, status.Errorf(codes.InvalidArgument, "Unsupported currency: %s", code)
+	}
+
+	resp := &productv1.BatchGetProductsResponse{}
+	for _, id := range req.Ids {
+		product, err := s.repo.GetByID(ctx, id)
+		if err != nil || product.Status != models.ProductStatusPublished {
+			continue
+		}
+		if err := convertPrices(s.currencies, code, product); err != nil {
+			slog.ErrorContext(ctx, "Error converting prices", "error", err)
+			return nil, status.Error(codes.Internal, "Failed to convert prices")
+		}
+		resp.Products = append(resp.Products, productMessage(product))
+	}
+	return resp, nil
+}
+
+// productMessage converts a product to its protobuf message
+func productMessage(product *models.Product) *productv1.Product {
+	return &productv1.Product{
+		Id:             product.ID,
+		Name:           product.Name,
+		Sku:            product.SKU,
+		Kind:           string(product.Kind),
+		Description:    product.Description,
+		Price:          product.Price,
+		EffectivePrice: product.EffectivePrice,
+		OnSale:         product.OnSale,
+		Currency:       product.Currency,
+		CategoryId:     product.CategoryID,
+		Status:         string(product.Status),
+		Stock:          int32(product.Stock),
+		MinOrderQty:    int32(product.MinOrderQty),
+		MaxOrderQty:    int32(product.MaxOrderQty),
+		WeightKg:       product.WeightKg,
+		AllowBackorder: product.AllowBackorder,
+		CreatedAt:      timestamppb.New(product.CreatedAt),
+		UpdatedAt:      timestamppb.New(product.UpdatedAt),
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	productv1 "ecommerce/pkg/proto/product/v1"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestProductServer_GetProduct(t *testing.T) {
+	repo := repository.NewInMemoryProductRepository()
+	product := models.NewProduct("Lamp", "A desk lamp", "Home", 20, 4, "")
+	draft := models.NewProduct("Chair", "Not out yet", "Home", 50, 1, "")
+	draft.Status = models.ProductStatusDraft
+	for _, p := range []*models.Product{product, draft} {
+		if err := repo.Create(context.Background(), p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := NewProductServer(repo, testCurrencies())
+
+	got, err := server.GetProduct(context.Background(), &productv1.GetProductRequest{Id: product.ID, Currency: "eur"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Lamp" || got.Price != 40 || got.EffectivePrice != 40 || got.Currency != "EUR" || got.Stock != 4 {
+		t.Fatalf("expected the lamp priced in EUR, got %+v", got)
+	}
+
+	if _, err := server.GetProduct(context.Background(), &productv1.GetProductRequest{Id: draft.ID}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for a draft, got %v", err)
+	}
+	if _, err := server.GetProduct(context.Background(), &productv1.GetProductRequest{Id: product.ID, Currency: "XYZ"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for an unsupported currency, got %v", err)
+	}
+}
+
+func TestProductServer_BatchGetProducts(t *testing.T) {
+	repo := repository.NewInMemoryProductRepository()
+	product := models.NewProduct("Lamp", "A desk lamp", "Home", 20, 4, "")
+	draft := models.NewProduct("Chair", "Not out yet", "Home", 50, 1, "")
+	draft.Status = models.ProductStatusDraft
+	for _, p := range []*models.Product{product, draft} {
+		if err := repo.Create(context.Background(), p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := NewProductServer(repo, testCurrencies())
+
+	resp, err := server.BatchGetProducts(context.Background(), &productv1.BatchGetProductsRequest{Ids: []string{product.ID, draft.ID, "missing"}, Currency: "EUR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Products) != 1 || resp.Products[0].Id != product.ID || resp.Products[0].Price != 40 {
+		t.Fatalf("expected only the published lamp, priced in EUR, got %+v", resp.Products)
+	}
+	if _, err := server.BatchGetProducts(context.Background(), &productv1.BatchGetProductsRequest{Ids: []string{product.ID}, Currency: "XYZ"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for an unsupported currency, got %v", err)
+	}
+}
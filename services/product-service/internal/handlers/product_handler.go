@@ -2,9 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+	"ecommerce/pkg/api"
+	"ecommerce/pkg/auth"
+	"product-service/internal/currency"
+	"product-service/internal/duplicate"
 	"product-service/internal/models"
 	"product-service/internal/repository"
 
@@ -13,13 +21,21 @@ import (
 
 // ProductHandler handles HTTP requests related to products
 type ProductHandler struct {
-	repo repository.ProductRepository
+	repo       repository.ProductRepository
+	categories repository.CategoryRepository
+	currencies *currency.Converter
+	duplicates *duplicate.Detector
 }
 
-// NewProductHandler creates a new product handler
-func NewProductHandler(repo repository.ProductRepository) *ProductHandler {
+// NewProductHandler creates a new product handler. The converter validates product
+// currencies and serves prices in the currency requested with ?currency=. The detector,
+// when not nil, rejects new products that duplicate an existing one.
+func NewProductHandler(repo repository.ProductRepository, categories repository.CategoryRepository, currencies *currency.Converter, duplicates *duplicate.Detector) *ProductHandler {
 	return &ProductHandler{
-		repo: repo,
+		repo:       repo,
+		categories: categories,
+		currencies: currencies,
+		duplicates: duplicates,
 	}
 }
 
@@ -28,25 +44,120 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var req models.CreateProductRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid JSON payload")
+	if !api.DecodeJSON(w, r, &req) {
 		return
 	}
 
-	// Basic validation
-	if req.Name == "" || req.Category == "" || req.Price <= 0 {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Name, category, and positive price are required")
+	if req.Category == "" && req.CategoryID == "" {
+		api.WriteError(w, http.StatusBadRequest, "Category or category_id is required")
 		return
 	}
 
-	// Create product
-	product := models.NewProduct(req.Name, req.Description, req.Category, req.Price, req.Stock, req.ImageURL)
-	if err := h.repo.Create(product); err != nil {
-		log.Printf("Error creating product: %v", err)
-		h.sendErrorResponse(w, http.StatusConflict, err.Error())
+	// Products must belong to an existing category
+	categoryRef := req.CategoryID
+	if categoryRef == "" {
+		categoryRef = req.Category
+	}
+	category, err := h.resolveCategory(categoryRef)
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusBadRequest, CodeCategoryNotFound, "Category does not exist")
+		return
+	}
+
+	tags, err := models.NormalizeTags(req.Tags)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	attributes, err := models.NormalizeAttributes(req.Attributes)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.Stock < 0 {
+		api.WriteError(w, http.StatusBadRequest, "stock quantity cannot be negative")
+		return
+	}
+
+	sku, err := models.NormalizeSKU(req.SKU)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Create product; opening stock is added separately so it is attributed in the stock history
+	product := models.NewProduct(req.Name, req.Description, category.Name, req.Price, 0, req.ImageURL)
+	product.SKU = sku
+	if err := product.SetKind(req.Kind); err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	product.CategoryID = category.ID
+	product.Tags = tags
+	product.Attributes = attributes
+	product.SetSale(req.SalePrice, req.SaleStart, req.SaleEnd)
+	if err := product.ValidateSale(); err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	product.MinOrderQty = req.MinOrderQty
+	product.MaxOrderQty = req.MaxOrderQty
+	if err := product.ValidateOrderLimits(); err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := product.SetWeight(req.WeightKg); err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	product.AllowBackorder = req.AllowBackorder
+	if req.Status != "" {
+		if err := product.SetVisibility(req.Status, req.PublishAt, time.Now()); err != nil {
+			api.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	product.Currency = h.currencies.Base()
+	if req.Currency != "" {
+		if !h.currencies.Supports(req.Currency) {
+			api.WriteErrorCode(w, http.StatusBadRequest, CodeUnsupportedCurrency, "Unsupported currency")
+			return
+		}
+		product.Currency = currency.Normalize(req.Currency)
+	}
+	if h.duplicates != nil {
+		match, err := h.duplicates.Find(r.Context(), product)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error checking for duplicate products", "error", err)
+			api.WriteError(w, http.StatusInternalServerError, "Failed to check for duplicate products")
+			return
+		}
+		// A similar name can be overridden; a SKU in use never can
+		if match != nil && (match.Reason == duplicate.ReasonSameSKU || !req.AllowDuplicate) {
+			h.sendDuplicateResponse(w, match)
+			return
+		}
+	}
+	if err := h.repo.Create(r.Context(), product); err != nil {
+		slog.ErrorContext(r.Context(), "Error creating product", "error", err)
+		api.WriteError(w, http.StatusConflict, err.Error())
 		return
 	}
 
+	if req.Stock > 0 {
+		source := models.StockSource{Actor: stockActor(r), Reason: models.StockReasonInitial}
+		if _, err := h.repo.AdjustStock(r.Context(), product.ID, req.Stock, source); err != nil {
+			slog.ErrorContext(r.Context(), "Error setting initial stock", "error", err)
+			api.WriteError(w, http.StatusInternalServerError, "Failed to set initial stock")
+			return
+		}
+	}
+	if created, err := h.repo.GetByID(r.Context(), product.ID); err == nil {
+		product = created
+	}
+
 	response := models.Response{
 		Success: true,
 		Message: "Product created successfully",
@@ -57,7 +168,8 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// GetProduct handles GET /products/{id} - retrieves a product by ID
+// GetProduct handles GET /products/{id} - retrieves a published product by ID (?currency= converts its prices;
+// ?include_unpublished=true also returns drafts and scheduled products)
 func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -65,14 +177,30 @@ func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 	productID := vars["id"]
 
 	if productID == "" {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Product ID is required")
+		api.WriteError(w, http.StatusBadRequest, "Product ID is required")
+		return
+	}
+
+	code, err := requestedCurrency(r, h.currencies)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	product, err := h.repo.GetByID(productID)
+	product, err := h.repo.GetByID(r.Context(), productID)
 	if err != nil {
-		log.Printf("Error getting product: %v", err)
-		h.sendErrorResponse(w, http.StatusNotFound, "Product not found")
+		slog.ErrorContext(r.Context(), "Error getting product", "error", err)
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+	// Drafts and products that aren't live yet are hidden unless explicitly requested
+	if product.Status != models.ProductStatusPublished && r.URL.Query().Get("include_unpublished") != "true" {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+	if err := convertPrices(h.currencies, code, product); err != nil {
+		slog.ErrorContext(r.Context(), "Error converting prices", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to convert prices")
 		return
 	}
 
@@ -81,52 +209,219 @@ func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 		Data:    product,
 	}
 
-	json.NewEncoder(w).Encode(response)
+	sendCacheable(w, r, response)
 }
 
-// ListProducts handles GET /products - retrieves all products with optional filtering
+// ListProducts handles GET /products - retrieves products with optional filtering (?tag= may repeat;
+// products must have every tag; ?attr.color=red matches attributes), sorting
+// (?sort=price|created_at&order=asc|desc) and pagination (?page=&limit= or ?cursor=&limit=);
+// ?currency= converts prices in the response. Only published products are listed unless
+// ?status=draft|scheduled|published|all is given.
 func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Parse query parameters for filtering
+	filter, err := h.filterFromQuery(r)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filter.Limit = models.DefaultPageLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			api.WriteError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		if limit > models.MaxPageLimit {
+			limit = models.MaxPageLimit
+		}
+		filter.Limit = limit
+	}
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		page, err := strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			api.WriteError(w, http.StatusBadRequest, "page must be a positive integer")
+			return
+		}
+		filter.Page = page
+	}
+
+	filter.Cursor = r.URL.Query().Get("cursor")
+
+	code, err := requestedCurrency(r, h.currencies)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	products, pageInfo, err := h.repo.List(r.Context(), filter)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		api.WriteError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing products", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve products")
+		return
+	}
+	if err := convertPrices(h.currencies, code, products...); err != nil {
+		slog.ErrorContext(r.Context(), "Error converting prices", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to convert prices")
+		return
+	}
+
+	response := models.Response{
+		Success:    true,
+		Data:       products,
+		Pagination: pageInfo,
+	}
+
+	sendCacheable(w, r, response)
+}
+
+// maxSearchQueryLength bounds the text a product search may be given
+const maxSearchQueryLength = 200
+
+// SearchProducts handles GET /products/search?q= - returns the products whose name, category, or
+// description contain every word of q, most relevant first. Matches are narrowed by the same
+// filters as ListProducts and capped by ?limit=; ?currency= converts prices in the response.
+func (h *ProductHandler) SearchProducts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query().Get("q")
+	if len(repository.SearchTerms(query)) == 0 {
+		api.WriteError(w, http.StatusBadRequest, "q must contain a word to search for")
+		return
+	}
+	if len(query) > maxSearchQueryLength {
+		api.WriteError(w, http.StatusBadRequest, fmt.Sprintf("q must be at most %d characters", maxSearchQueryLength))
+		return
+	}
+
+	filter, err := h.filterFromQuery(r)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.Limit = models.DefaultPageLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			api.WriteError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		if limit > models.MaxPageLimit {
+			limit = models.MaxPageLimit
+		}
+		filter.Limit = limit
+	}
+
+	code, err := requestedCurrency(r, h.currencies)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	products, err := h.repo.Search(r.Context(), query, filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error searching products", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to search products")
+		return
+	}
+	if err := convertPrices(h.currencies, code, products...); err != nil {
+		slog.ErrorContext(r.Context(), "Error converting prices", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to convert prices")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    products,
+	}
+
+	sendCacheable(w, r, response)
+}
+
+// filterFromQuery builds a product filter from the category, price, stock, tag, attribute (attr.<name>),
+// sort and status query parameters
+func (h *ProductHandler) filterFromQuery(r *http.Request) (*models.ProductFilter, error) {
+	query := r.URL.Query()
 	filter := &models.ProductFilter{}
-	
-	if category := r.URL.Query().Get("category"); category != "" {
+
+	if category := query.Get("category"); category != "" {
 		filter.Category = category
+		filter.CategoryIDs = h.categoryTree(category)
 	}
-	
-	if minPriceStr := r.URL.Query().Get("min_price"); minPriceStr != "" {
+
+	if minPriceStr := query.Get("min_price"); minPriceStr != "" {
 		if minPrice, err := strconv.ParseFloat(minPriceStr, 64); err == nil {
 			filter.MinPrice = minPrice
 		}
 	}
-	
-	if maxPriceStr := r.URL.Query().Get("max_price"); maxPriceStr != "" {
+
+	if maxPriceStr := query.Get("max_price"); maxPriceStr != "" {
 		if maxPrice, err := strconv.ParseFloat(maxPriceStr, 64); err == nil {
 			filter.MaxPrice = maxPrice
 		}
 	}
-	
-	if inStockStr := r.URL.Query().Get("in_stock"); inStockStr == "true" {
+
+	if inStockStr := query.Get("in_stock"); inStockStr == "true" {
 		filter.InStock = true
 	}
 
-	products, err := h.repo.List(filter)
-	if err != nil {
-		log.Printf("Error listing products: %v", err)
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve products")
-		return
+	if tags := query["tag"]; len(tags) > 0 {
+		normalized, err := models.NormalizeTags(tags)
+		if err != nil {
+			return nil, err
+		}
+		filter.Tags = normalized
 	}
 
-	response := models.Response{
-		Success: true,
-		Data:    products,
+	for key, values := range query {
+		if name, found := strings.CutPrefix(key, models.AttributeQueryPrefix); found {
+			if filter.Attributes == nil {
+				filter.Attributes = make(map[string]string)
+			}
+			filter.Attributes[name] = values[0]
+		}
+	}
+	if filter.Attributes != nil {
+		attributes, err := models.NormalizeAttributes(filter.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		filter.Attributes = attributes
 	}
 
-	json.NewEncoder(w).Encode(response)
+	filter.Sort = query.Get("sort")
+	if filter.Sort != "" && filter.Sort != models.SortByPrice && filter.Sort != models.SortByCreatedAt {
+		return nil, errors.New("sort must be price or created_at")
+	}
+
+	filter.Order = query.Get("order")
+	if filter.Order != "" && filter.Order != models.SortAsc && filter.Order != models.SortDesc {
+		return nil, errors.New("order must be asc or desc")
+	}
+
+	// Shoppers only see published products; admins can ask for other states or all of them
+	filter.Status = models.ProductStatusPublished
+	switch status := query.Get("status"); status {
+	case "":
+	case "all":
+		filter.Status = ""
+	default:
+		filter.Status = models.ProductStatus(status)
+		if !models.IsValidProductStatus(filter.Status) {
+			return nil, errors.New("status must be draft, published, scheduled, or all")
+		}
+	}
+
+	return filter, nil
 }
 
-// GetProductsByCategory handles GET /products/category/{category} - retrieves products by category
+// GetProductsByCategory handles GET /products/category/{category} - retrieves products in a category and its subcategories
 func (h *ProductHandler) GetProductsByCategory(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -134,14 +429,27 @@ func (h *ProductHandler) GetProductsByCategory(w http.ResponseWriter, r *http.Re
 	category := vars["category"]
 
 	if category == "" {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Category is required")
+		api.WriteError(w, http.StatusBadRequest, "Category is required")
+		return
+	}
+
+	code, err := requestedCurrency(r, h.currencies)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	products, err := h.repo.GetByCategory(category)
+	// Include products from subcategories; unknown categories fall back to a name match
+	filter := &models.ProductFilter{Category: category, CategoryIDs: h.categoryTree(category), Status: models.ProductStatusPublished}
+	products, _, err := h.repo.List(r.Context(), filter)
 	if err != nil {
-		log.Printf("Error getting products by category: %v", err)
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve products")
+		slog.ErrorContext(r.Context(), "Error getting products by category", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve products")
+		return
+	}
+	if err := convertPrices(h.currencies, code, products...); err != nil {
+		slog.ErrorContext(r.Context(), "Error converting prices", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to convert prices")
 		return
 	}
 
@@ -150,7 +458,7 @@ func (h *ProductHandler) GetProductsByCategory(w http.ResponseWriter, r *http.Re
 		Data:    products,
 	}
 
-	json.NewEncoder(w).Encode(response)
+	sendCacheable(w, r, response)
 }
 
 // UpdateProduct handles PUT /products/{id} - updates an existing product
@@ -161,20 +469,19 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	productID := vars["id"]
 
 	if productID == "" {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Product ID is required")
+		api.WriteError(w, http.StatusBadRequest, "Product ID is required")
 		return
 	}
 
 	// Get existing product
-	existingProduct, err := h.repo.GetByID(productID)
+	existingProduct, err := h.repo.GetByID(r.Context(), productID)
 	if err != nil {
-		h.sendErrorResponse(w, http.StatusNotFound, "Product not found")
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
 		return
 	}
 
 	var req models.UpdateProductRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid JSON payload")
+	if !api.DecodeJSON(w, r, &req) {
 		return
 	}
 
@@ -182,28 +489,131 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	if req.Name != nil {
 		existingProduct.Name = *req.Name
 	}
+	if req.SKU != nil {
+		sku, err := models.NormalizeSKU(*req.SKU)
+		if err != nil {
+			api.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if h.duplicates != nil {
+			existing, err := h.duplicates.FindSKU(r.Context(), &models.Product{ID: productID, SKU: sku})
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error checking for duplicate SKUs", "error", err)
+				api.WriteError(w, http.StatusInternalServerError, "Failed to update product")
+				return
+			}
+			if existing != nil {
+				h.sendDuplicateResponse(w, &duplicate.Match{Reason: duplicate.ReasonSameSKU, Similarity: 1, Product: existing})
+				return
+			}
+		}
+		existingProduct.SKU = sku
+	}
+	if req.Kind != nil {
+		if err := existingProduct.SetKind(*req.Kind); err != nil {
+			api.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
 	if req.Description != nil {
 		existingProduct.Description = *req.Description
 	}
 	if req.Price != nil {
 		existingProduct.Price = *req.Price
 	}
-	if req.Category != nil {
-		existingProduct.Category = *req.Category
+	if req.Currency != nil {
+		if !h.currencies.Supports(*req.Currency) {
+			api.WriteErrorCode(w, http.StatusBadRequest, CodeUnsupportedCurrency, "Unsupported currency")
+			return
+		}
+		existingProduct.Currency = currency.Normalize(*req.Currency)
+	}
+	if req.CategoryID != nil || req.Category != nil {
+		categoryRef := ""
+		if req.CategoryID != nil {
+			categoryRef = *req.CategoryID
+		} else {
+			categoryRef = *req.Category
+		}
+		category, err := h.resolveCategory(categoryRef)
+		if err != nil {
+			api.WriteErrorCode(w, http.StatusBadRequest, CodeCategoryNotFound, "Category does not exist")
+			return
+		}
+		existingProduct.CategoryID = category.ID
+		existingProduct.Category = category.Name
 	}
-	if req.Stock != nil {
-		existingProduct.Stock = *req.Stock
+	if req.Stock != nil && *req.Stock < 0 {
+		api.WriteError(w, http.StatusBadRequest, "stock quantity cannot be negative")
+		return
 	}
 	if req.ImageURL != nil {
-		existingProduct.ImageURL = *req.ImageURL
+		existingProduct.SetPrimaryImageURL(*req.ImageURL)
+	}
+	if req.Tags != nil {
+		tags, err := models.NormalizeTags(*req.Tags)
+		if err != nil {
+			api.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		existingProduct.Tags = tags
+	}
+	if req.Attributes != nil {
+		attributes, err := models.NormalizeAttributes(*req.Attributes)
+		if err != nil {
+			api.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		existingProduct.Attributes = attributes
+	}
+	if req.SalePrice != nil {
+		existingProduct.SetSale(req.SalePrice, req.SaleStart, req.SaleEnd)
+	}
+	// Checked even when only the regular price changed, since the sale must stay below it
+	if err := existingProduct.ValidateSale(); err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.MinOrderQty != nil {
+		existingProduct.MinOrderQty = *req.MinOrderQty
+	}
+	if req.MaxOrderQty != nil {
+		existingProduct.MaxOrderQty = *req.MaxOrderQty
+	}
+	// Checked against both limits, since changing one can contradict the other
+	if err := existingProduct.ValidateOrderLimits(); err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.WeightKg != nil {
+		if err := existingProduct.SetWeight(*req.WeightKg); err != nil {
+			api.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.AllowBackorder != nil {
+		existingProduct.AllowBackorder = *req.AllowBackorder
 	}
 
-	if err := h.repo.Update(existingProduct); err != nil {
-		log.Printf("Error updating product: %v", err)
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to update product")
+	if err := h.repo.Update(r.Context(), existingProduct); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating product", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to update product")
 		return
 	}
 
+	// Stock goes through the stock methods, which keep per-warehouse inventory in step
+	if req.Stock != nil {
+		source := models.StockSource{Actor: stockActor(r), Reason: models.StockReasonManualSet}
+		if err := h.repo.UpdateStock(r.Context(), productID, *req.Stock, source); err != nil {
+			slog.ErrorContext(r.Context(), "Error updating stock", "error", err)
+			api.WriteError(w, http.StatusInternalServerError, "Failed to update product")
+			return
+		}
+	}
+	if updated, err := h.repo.GetByID(r.Context(), productID); err == nil {
+		existingProduct = updated
+	}
+
 	response := models.Response{
 		Success: true,
 		Message: "Product updated successfully",
@@ -213,7 +623,7 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// UpdateStock handles PATCH /products/{id}/stock - updates product stock
+// UpdateStock handles PATCH /products/{id}/stock - sets stock ({"stock": 25}) or adjusts it atomically ({"delta": -3})
 func (h *ProductHandler) UpdateStock(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -221,57 +631,176 @@ func (h *ProductHandler) UpdateStock(w http.ResponseWriter, r *http.Request) {
 	productID := vars["id"]
 
 	if productID == "" {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Product ID is required")
+		api.WriteError(w, http.StatusBadRequest, "Product ID is required")
 		return
 	}
 
-	var req struct {
-		Stock int `json:"stock"`
+	var req models.UpdateStockRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid JSON payload")
+	if (req.Stock == nil) == (req.Delta == nil) {
+		api.WriteError(w, http.StatusBadRequest, "Provide either stock or delta")
 		return
 	}
 
-	if err := h.repo.UpdateStock(productID, req.Stock); err != nil {
-		log.Printf("Error updating stock: %v", err)
-		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
-		return
+	var stock int
+	if req.Delta != nil {
+		source := models.StockSource{Actor: stockActor(r), Reason: models.StockReasonManualAdjustment, Note: req.Note}
+		adjusted, err := h.repo.AdjustStock(r.Context(), productID, *req.Delta, source)
+		if errors.Is(err, models.ErrInsufficientStock) {
+			api.WriteError(w, http.StatusConflict, fmt.Sprintf("Adjustment would make stock negative (current stock %d)", adjusted))
+			return
+		}
+		if err != nil {
+			api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+			return
+		}
+		stock = adjusted
+	} else {
+		source := models.StockSource{Actor: stockActor(r), Reason: models.StockReasonManualSet, Note: req.Note}
+		if err := h.repo.UpdateStock(r.Context(), productID, *req.Stock, source); err != nil {
+			slog.ErrorContext(r.Context(), "Error updating stock", "error", err)
+			api.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		stock = *req.Stock
 	}
 
 	response := models.Response{
 		Success: true,
 		Message: "Stock updated successfully",
+		Data: map[string]interface{}{
+			"product_id": productID,
+			"stock":      stock,
+		},
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
-// HealthCheck handles GET /health - returns service health status
-func (h *ProductHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
+// ListTags handles GET /tags - returns every distinct product tag with its product count
+func (h *ProductHandler) ListTags(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	tags, err := h.repo.TagCounts(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error counting tags", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve tags")
+		return
+	}
+
 	response := models.Response{
 		Success: true,
-		Message: "Product service is healthy",
-		Data: map[string]string{
-			"service": "product-service",
-			"status":  "UP",
-		},
+		Data:    tags,
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
-// sendErrorResponse sends a standardized error response
-func (h *ProductHandler) sendErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-	w.WriteHeader(statusCode)
+// resolveCategory finds a category by ID, or by name via its slug
+func (h *ProductHandler) resolveCategory(ref string) (*models.Category, error) {
+	if category, err := h.categories.GetByID(ref); err == nil {
+		return category, nil
+	}
+	return h.categories.GetByID(models.Slugify(ref))
+}
+
+// categoryTree returns the IDs of a category and its subcategories, or nil if it doesn't exist
+func (h *ProductHandler) categoryTree(ref string) []string {
+	category, err := h.resolveCategory(ref)
+	if err != nil {
+		return nil
+	}
+	ids, err := h.categories.Descendants(category.ID)
+	if err != nil {
+		return nil
+	}
+	return ids
+}
+
+// UpdateVisibility handles PATCH /products/{id}/visibility - drafts, publishes, or schedules a product (admin)
+func (h *ProductHandler) UpdateVisibility(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	product, err := h.repo.GetByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+
+	var req models.UpdateVisibilityRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := product.SetVisibility(req.Status, req.PublishAt, time.Now()); err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.repo.Update(r.Context(), product); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating product visibility", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to update product")
+		return
+	}
 
 	response := models.Response{
-		Success: false,
-		Error:   message,
+		Success: true,
+		Message: "Product visibility updated",
+		Data:    product,
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
+
+// StockHistory handles GET /products/{id}/stock-history - lists recorded stock changes, newest first (?limit=, default 50)
+func (h *ProductHandler) StockHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 {
+			api.WriteError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = parsed
+	}
+
+	history, err := h.repo.StockHistory(r.Context(), mux.Vars(r)["id"], limit)
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    history,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// stockActor identifies who is changing stock: the calling service, or "anonymous" for
+// end-user requests since product service does not authenticate users itself
+func stockActor(r *http.Request) string {
+	if service := auth.ServiceFromContext(r.Context()); service != "" {
+		return service
+	}
+	return "anonymous"
+}
+
+// sendDuplicateResponse rejects a product that duplicates another, returning the conflicting product as data
+func (h *ProductHandler) sendDuplicateResponse(w http.ResponseWriter, match *duplicate.Match) {
+	code, message := CodeProductDuplicate, "A product with a similar name already exists; set allow_duplicate to create it anyway"
+	if match.Reason == duplicate.ReasonSameSKU {
+		code, message = CodeProductSKUTaken, "A product with this SKU already exists"
+	}
+	api.WriteErrorResponse(w, http.StatusConflict, api.Response[api.Unpaged]{
+		Code:  code,
+		Error: message,
+		Data:  match,
+	})
+}
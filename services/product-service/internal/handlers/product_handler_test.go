@@ -1,21 +1,47 @@
 package handlers
 
 import (
+	"context"
 	"bytes"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"product-service/internal/currency"
+	"product-service/internal/duplicate"
+	"product-service/internal/models"
 	"product-service/internal/repository"
+
+	"github.com/gorilla/mux"
 )
 
+// demoFixtures is the sample data shared by the services, which seeded tests run against
+const demoFixtures = "../../../../fixtures/demo.yaml"
+
+// seededProducts returns an in-memory repository holding the demo products
+func seededProducts() *repository.InMemoryProductRepository {
+	products := repository.NewInMemoryProductRepository()
+	if _, err := repository.SeedProducts(context.Background(), products, demoFixtures); err != nil {
+		panic(err)
+	}
+	return products
+}
+
 func setupProductHandler() *ProductHandler {
-	return NewProductHandler(repository.NewInMemoryProductRepository())
+	products := seededProducts()
+	duplicates, _ := duplicate.NewDetector(products, duplicate.DefaultThreshold)
+	return NewProductHandler(products, repository.NewInMemoryCategoryRepository(), testCurrencies(), duplicates)
+}
+
+// testCurrencies converts at a round rate of 2 EUR per USD to keep expected prices simple
+func testCurrencies() *currency.Converter {
+	return currency.NewConverter(models.DefaultCurrency, map[string]float64{"EUR": 2})
 }
 
 func TestCreateProduct_Success(t *testing.T) {
 	h := setupProductHandler()
-	body := bytes.NewBufferString(`{"name":"Test","description":"d","category":"Cat","price":10.5,"stock":5}`)
+	body := bytes.NewBufferString(`{"name":"Test","description":"d","category":"Electronics","price":10.5,"stock":5}`)
 	req := httptest.NewRequest(http.MethodPost, "/products", body)
 	rec := httptest.NewRecorder()
 
@@ -52,3 +78,427 @@ func TestListProducts_Filter(t *testing.T) {
 		t.Fatalf("invalid json: %v", err)
 	}
 }
+
+func TestListProducts_Pagination(t *testing.T) {
+	h := setupProductHandler()
+	req := httptest.NewRequest(http.MethodGet, "/products?sort=price&order=asc&limit=2", nil)
+	rec := httptest.NewRecorder()
+
+	h.ListProducts(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+	var resp models.Response
+	var products []models.Product
+	resp.Data = &products
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if len(products) != 2 || resp.Pagination == nil || !resp.Pagination.HasMore || resp.Pagination.NextCursor == "" {
+		t.Fatalf("expected first page of 2 with a next cursor, got %d items and %+v", len(products), resp.Pagination)
+	}
+
+	for _, query := range []string{"sort=name", "order=up", "limit=0", "page=-1", "cursor=bogus"} {
+		rec = httptest.NewRecorder()
+		h.ListProducts(rec, httptest.NewRequest(http.MethodGet, "/products?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestProductTags_CreateFilterAndList(t *testing.T) {
+	h := setupProductHandler()
+	rec := httptest.NewRecorder()
+	h.CreateProduct(rec, httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(`{"name":"Lamp","category":"Electronics","price":20,"stock":1,"tags":[" Sale ","NEW","sale"]}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", rec.Code)
+	}
+	var created struct {
+		Data models.Product `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	if len(created.Data.Tags) != 2 || created.Data.Tags[0] != "sale" || created.Data.Tags[1] != "new" {
+		t.Fatalf("expected normalized tags [sale new], got %v", created.Data.Tags)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ListProducts(rec, httptest.NewRequest(http.MethodGet, "/products?tag=sale&tag=New", nil))
+	var listed struct {
+		Data []models.Product `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &listed)
+	if len(listed.Data) != 1 || listed.Data[0].ID != created.Data.ID {
+		t.Fatalf("expected only the tagged product, got %d products", len(listed.Data))
+	}
+
+	rec = httptest.NewRecorder()
+	h.ListTags(rec, httptest.NewRequest(http.MethodGet, "/tags", nil))
+	if !bytes.Contains(rec.Body.Bytes(), []byte(`{"tag":"new","count":1}`)) {
+		t.Fatalf("expected tag counts, got %s", rec.Body.String())
+	}
+}
+
+func TestUpdateStock_Delta(t *testing.T) {
+	h := setupProductHandler()
+	product := models.NewProduct("Delta Lamp", "", "Electronics", 20, 5, "")
+	_ = h.repo.Create(context.Background(), product)
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/products/"+product.ID+"/stock", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"id": product.ID})
+		rec := httptest.NewRecorder()
+		h.UpdateStock(rec, req)
+		return rec
+	}
+
+	if rec := patch(`{"delta":-3}`); rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte(`"stock":2`)) {
+		t.Fatalf("expected stock 2 after delta, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := patch(`{"delta":-3}`); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for negative result got %d", rec.Code)
+	}
+	if rec := patch(`{"stock":1,"delta":1}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 when both stock and delta are set got %d", rec.Code)
+	}
+}
+
+func TestStockHistory_RecordsActorAndReason(t *testing.T) {
+	h := setupProductHandler()
+	rec := httptest.NewRecorder()
+	h.CreateProduct(rec, httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(`{"name":"Audit Lamp","category":"Electronics","price":20,"stock":5}`)))
+	var created struct {
+		Data models.Product `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	if created.Data.Stock != 5 {
+		t.Fatalf("expected created product to have stock 5, got %d", created.Data.Stock)
+	}
+	vars := map[string]string{"id": created.Data.ID}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPatch, "/products/"+created.Data.ID+"/stock", bytes.NewBufferString(`{"delta":-2,"note":"damaged"}`)), vars)
+	h.UpdateStock(httptest.NewRecorder(), req)
+
+	rec = httptest.NewRecorder()
+	h.StockHistory(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/products/"+created.Data.ID+"/stock-history", nil), vars))
+	var history struct {
+		Data []models.StockMovement `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &history)
+	if rec.Code != http.StatusOK || len(history.Data) != 2 {
+		t.Fatalf("expected 2 movements got %d %s", rec.Code, rec.Body.String())
+	}
+	if m := history.Data[0]; m.Reason != models.StockReasonManualAdjustment || m.Delta != -2 || m.Note != "damaged" || m.Actor != "anonymous" {
+		t.Errorf("unexpected adjustment movement %+v", m)
+	}
+	if m := history.Data[1]; m.Reason != models.StockReasonInitial || m.Delta != 5 {
+		t.Errorf("unexpected initial movement %+v", m)
+	}
+
+	rec = httptest.NewRecorder()
+	h.StockHistory(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/products/x/stock-history?limit=0", nil), vars))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid limit got %d", rec.Code)
+	}
+}
+
+func TestSalePricing_CreateAndUpdate(t *testing.T) {
+	h := setupProductHandler()
+	rec := httptest.NewRecorder()
+	h.CreateProduct(rec, httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(`{"name":"Sale Lamp","category":"Electronics","price":20,"stock":1,"sale_price":25}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a sale price above the price got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.CreateProduct(rec, httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(`{"name":"Sale Lamp","category":"Electronics","price":20,"stock":1,"sale_price":15}`)))
+	var created struct {
+		Data models.Product `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	if rec.Code != http.StatusCreated || !created.Data.OnSale || created.Data.EffectivePrice != 15 {
+		t.Fatalf("expected product on sale at 15, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	update := func(body string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/products/"+created.Data.ID, bytes.NewBufferString(body)), map[string]string{"id": created.Data.ID})
+		rec := httptest.NewRecorder()
+		h.UpdateProduct(rec, req)
+		return rec
+	}
+	if rec := update(`{"price":10}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 when the price drops below the sale price got %d", rec.Code)
+	}
+	if rec := update(`{"sale_price":0}`); rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte(`"effective_price":20`)) {
+		t.Fatalf("expected sale to end, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestProductPrices_CurrencyConversion(t *testing.T) {
+	h := setupProductHandler()
+	rec := httptest.NewRecorder()
+	h.CreateProduct(rec, httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(`{"name":"Euro Lamp","category":"Electronics","price":10,"currency":"eur","stock":1}`)))
+	var created struct {
+		Data models.Product `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	if rec.Code != http.StatusCreated || created.Data.Currency != "EUR" {
+		t.Fatalf("expected product priced in EUR, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/products/"+created.Data.ID+query, nil), map[string]string{"id": created.Data.ID})
+		rec := httptest.NewRecorder()
+		h.GetProduct(rec, req)
+		return rec
+	}
+	if rec := get("?currency=usd"); !bytes.Contains(rec.Body.Bytes(), []byte(`"price":5,"currency":"USD"`)) {
+		t.Fatalf("expected price converted to 5 USD, got %s", rec.Body.String())
+	}
+	if rec := get(""); !bytes.Contains(rec.Body.Bytes(), []byte(`"price":10,"currency":"EUR"`)) {
+		t.Fatalf("expected stored price without ?currency=, got %s", rec.Body.String())
+	}
+	if rec := get("?currency=XYZ"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unsupported currency got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.CreateProduct(rec, httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(`{"name":"Yen Lamp","category":"Electronics","price":10,"currency":"JPY","stock":1}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unsupported product currency got %d", rec.Code)
+	}
+}
+
+func TestProductVisibility(t *testing.T) {
+	h := setupProductHandler()
+	rec := httptest.NewRecorder()
+	h.CreateProduct(rec, httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(`{"name":"Draft Lamp","category":"Electronics","price":20,"stock":1,"status":"draft"}`)))
+	var created struct {
+		Data models.Product `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	if rec.Code != http.StatusCreated || created.Data.Status != models.ProductStatusDraft {
+		t.Fatalf("expected draft product, got %d %s", rec.Code, rec.Body.String())
+	}
+	vars := map[string]string{"id": created.Data.ID}
+
+	get := func(query string) int {
+		rec := httptest.NewRecorder()
+		h.GetProduct(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/products/"+created.Data.ID+query, nil), vars))
+		return rec.Code
+	}
+	listed := func(query string) bool {
+		rec := httptest.NewRecorder()
+		h.ListProducts(rec, httptest.NewRequest(http.MethodGet, "/products?limit=100"+query, nil))
+		return bytes.Contains(rec.Body.Bytes(), []byte(created.Data.ID))
+	}
+	if get("") != http.StatusNotFound || listed("") {
+		t.Fatal("expected draft to be hidden from shoppers")
+	}
+	if get("?include_unpublished=true") != http.StatusOK || !listed("&status=draft") || !listed("&status=all") {
+		t.Fatal("expected draft to be visible to admins")
+	}
+
+	setVisibility := func(body string) int {
+		rec := httptest.NewRecorder()
+		h.UpdateVisibility(rec, mux.SetURLVars(httptest.NewRequest(http.MethodPatch, "/products/"+created.Data.ID+"/visibility", bytes.NewBufferString(body)), vars))
+		return rec.Code
+	}
+	if code := setVisibility(`{"status":"scheduled","publish_at":"2000-01-01T00:00:00Z"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a schedule in the past got %d", code)
+	}
+	if code := setVisibility(`{"status":"hidden"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown status got %d", code)
+	}
+	if code := setVisibility(`{"status":"published"}`); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	if get("") != http.StatusOK || !listed("") {
+		t.Fatal("expected published product to be visible")
+	}
+}
+
+func TestProductAttributes_CreateAndFilter(t *testing.T) {
+	h := setupProductHandler()
+	create := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.CreateProduct(rec, httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(body)))
+		return rec
+	}
+	if rec := create(`{"name":"Red Laptop","category":"Electronics","price":900,"stock":1,"attributes":{" Color ":"Red","screen_size":"16in","weight":" "}}`); rec.Code != http.StatusCreated ||
+		!bytes.Contains(rec.Body.Bytes(), []byte(`"attributes":{"color":"Red","screen_size":"16in"}`)) {
+		t.Fatalf("expected normalized attributes, got %d %s", rec.Code, rec.Body.String())
+	}
+	create(`{"name":"Blue Laptop","category":"Electronics","price":800,"stock":1,"attributes":{"color":"blue","screen_size":"16in"}}`)
+	if rec := create(`{"name":"Bad Laptop","category":"Electronics","price":800,"stock":1,"attributes":{"screen size":"14in"}}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid attribute name got %d", rec.Code)
+	}
+
+	list := func(query string) []models.Product {
+		rec := httptest.NewRecorder()
+		h.ListProducts(rec, httptest.NewRequest(http.MethodGet, "/products?"+query, nil))
+		var listed struct {
+			Data []models.Product `json:"data"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &listed)
+		return listed.Data
+	}
+	if products := list("attr.color=red"); len(products) != 1 || products[0].Name != "Red Laptop" {
+		t.Fatalf("expected only the red laptop, got %d products", len(products))
+	}
+	if products := list("attr.screen_size=16in&attr.color=BLUE"); len(products) != 1 || products[0].Name != "Blue Laptop" {
+		t.Fatalf("expected only the blue laptop, got %d products", len(products))
+	}
+	if products := list("attr.screen_size=16in"); len(products) != 2 {
+		t.Fatalf("expected both laptops, got %d products", len(products))
+	}
+}
+
+func TestOrderLimits_CreateAndUpdate(t *testing.T) {
+	h := setupProductHandler()
+	rec := httptest.NewRecorder()
+	h.CreateProduct(rec, httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(`{"name":"Bulk Paper","category":"Electronics","price":5,"stock":100,"min_order_qty":10,"max_order_qty":5}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a minimum above the maximum got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.CreateProduct(rec, httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(`{"name":"Bulk Paper","category":"Electronics","price":5,"stock":100,"min_order_qty":10,"max_order_qty":50}`)))
+	var created struct {
+		Data models.Product `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	if rec.Code != http.StatusCreated || created.Data.MinOrderQty != 10 || created.Data.MaxOrderQty != 50 {
+		t.Fatalf("expected limits 10-50, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	update := func(body string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/products/"+created.Data.ID, bytes.NewBufferString(body)), map[string]string{"id": created.Data.ID})
+		rec := httptest.NewRecorder()
+		h.UpdateProduct(rec, req)
+		return rec
+	}
+	if rec := update(`{"max_order_qty":8}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 when the maximum drops below the minimum got %d", rec.Code)
+	}
+	if rec := update(`{"min_order_qty":0,"max_order_qty":8}`); rec.Code != http.StatusOK || bytes.Contains(rec.Body.Bytes(), []byte(`"min_order_qty"`)) {
+		t.Fatalf("expected minimum removed and maximum lowered, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestProductKindAndWeight_CreateAndUpdate(t *testing.T) {
+	h := setupProductHandler()
+	rec := httptest.NewRecorder()
+	h.CreateProduct(rec, httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(`{"name":"Field Guide","category":"Electronics","price":12,"stock":0,"kind":"ebook"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown kind got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.CreateProduct(rec, httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(`{"name":"Field Guide","category":"Electronics","price":12,"stock":0,"kind":"digital"}`)))
+	var created struct {
+		Data models.Product `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	if rec.Code != http.StatusCreated || created.Data.Kind != models.ProductKindDigital || !created.Data.IsInStock() {
+		t.Fatalf("expected an in-stock digital product, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/products/"+created.Data.ID, bytes.NewBufferString(`{"kind":"physical","weight_kg":-1}`)), map[string]string{"id": created.Data.ID})
+	rec = httptest.NewRecorder()
+	h.UpdateProduct(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a negative weight got %d", rec.Code)
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/products/"+created.Data.ID, bytes.NewBufferString(`{"kind":"physical","weight_kg":0.4}`)), map[string]string{"id": created.Data.ID})
+	rec = httptest.NewRecorder()
+	h.UpdateProduct(rec, req)
+	if rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte(`"kind":"physical"`)) || !bytes.Contains(rec.Body.Bytes(), []byte(`"weight_kg":0.4`)) {
+		t.Fatalf("expected the product to become physical and weigh 0.4kg, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAllowBackorder_CreateAndUpdate(t *testing.T) {
+	h := setupProductHandler()
+	rec := httptest.NewRecorder()
+	h.CreateProduct(rec, httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(`{"name":"Next Gen Console","category":"Electronics","price":499,"stock":0,"allow_backorder":true}`)))
+	var created struct {
+		Data models.Product `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	if rec.Code != http.StatusCreated || !created.Data.AllowBackorder {
+		t.Fatalf("expected a product taking backorders, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	update := func(body string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/products/"+created.Data.ID, bytes.NewBufferString(body)), map[string]string{"id": created.Data.ID})
+		rec := httptest.NewRecorder()
+		h.UpdateProduct(rec, req)
+		return rec
+	}
+	if rec := update(`{"price":479}`); rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte(`"allow_backorder":true`)) {
+		t.Fatalf("expected backorders to stay on, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := update(`{"allow_backorder":false}`); rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte(`"allow_backorder":false`)) {
+		t.Fatalf("expected backorders turned off, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCreateProduct_DuplicateDetection(t *testing.T) {
+	h := setupProductHandler()
+	create := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.CreateProduct(rec, httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(body)))
+		return rec
+	}
+
+	if rec := create(`{"name":"Desk Lamp","sku":"lamp-001","category":"Electronics","price":30,"stock":1}`); rec.Code != http.StatusCreated || !bytes.Contains(rec.Body.Bytes(), []byte(`"sku":"LAMP-001"`)) {
+		t.Fatalf("expected product created with normalized SKU, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec := create(`{"name":"Desk-Lamps","category":"Electronics","price":30,"stock":1}`)
+	var conflict struct {
+		Data duplicate.Match `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &conflict)
+	if rec.Code != http.StatusConflict || conflict.Data.Reason != duplicate.ReasonSimilarName || conflict.Data.Product.Name != "Desk Lamp" {
+		t.Fatalf("expected 409 naming the similar product, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := create(`{"name":"Desk-Lamps","category":"Electronics","price":30,"stock":1,"allow_duplicate":true}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected override to allow the similar product, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := create(`{"name":"Floor Lamp","sku":"LAMP-001","category":"Electronics","price":30,"stock":1,"allow_duplicate":true}`); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a SKU in use even with the override, got %d", rec.Code)
+	}
+	if rec := create(`{"name":"Floor Lamp","sku":"lamp 002","category":"Electronics","price":30,"stock":1}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid SKU, got %d", rec.Code)
+	}
+}
+
+func TestSearchProducts(t *testing.T) {
+	h := setupProductHandler()
+	rec := httptest.NewRecorder()
+	h.SearchProducts(rec, httptest.NewRequest(http.MethodGet, "/products/search?q=electronics+headphones&currency=EUR", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+	var resp models.Response
+	var products []models.Product
+	resp.Data = &products
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if len(products) != 1 || products[0].Name != "Wireless Headphones" || products[0].Currency != "EUR" {
+		t.Fatalf("expected the headphones priced in EUR, got %+v", products)
+	}
+
+	for _, query := range []string{"", "q=+-+", "q=phone&limit=0", "q=" + strings.Repeat("a", maxSearchQueryLength+1)} {
+		rec = httptest.NewRecorder()
+		h.SearchProducts(rec, httptest.NewRequest(http.MethodGet, "/products/search?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%.20s: expected 400 got %d", query, rec.Code)
+		}
+	}
+}
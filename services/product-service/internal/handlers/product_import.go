@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"ecommerce/pkg/api"
+	"product-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// MaxImportSize caps the size of an uploaded CSV file
+const MaxImportSize = 10 << 20
+
+// importColumns are the recognised CSV headers; name, price and one of category/category_id are required
+var importColumns = []string{"name", "description", "category", "category_id", "price", "stock", "image_url", "tags"}
+
+// tagSeparator splits the tags column in CSV imports and exports
+const tagSeparator = "|"
+
+// ImportProducts handles POST /products/import - creates products from a CSV upload, sent either as
+// a multipart "file" field or as a text/csv body, and reports the outcome of every row.
+// Rows naming a product that already exists are skipped; invalid rows are reported and do not stop the import.
+func (h *ProductHandler) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxImportSize)
+
+	var source io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			api.WriteError(w, http.StatusBadRequest, "CSV file is required in the \"file\" field")
+			return
+		}
+		defer file.Close()
+		source = file
+	}
+
+	reader := csv.NewReader(source)
+	reader.FieldsPerRecord = -1 // row length is checked per row so one bad line doesn't abort the import
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "CSV must start with a header row")
+		return
+	}
+	columns, err := parseImportHeader(header)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Stock from the file is attributed to this import in the stock history
+	stockSource := models.StockSource{Actor: stockActor(r), Reason: models.StockReasonImport, Reference: "import:" + uuid.New().String()}
+
+	report := &models.ImportReport{Rows: []models.ImportRowResult{}}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		var parseErr *csv.ParseError
+		var maxBytes *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytes):
+			api.WriteError(w, http.StatusRequestEntityTooLarge, "CSV file is too large")
+			return
+		case errors.As(err, &parseErr):
+			report.Add(models.ImportRowResult{Row: parseErr.StartLine, Status: models.ImportError, Reason: "Malformed CSV row"})
+			continue
+		case err != nil:
+			api.WriteError(w, http.StatusBadRequest, "Failed to read CSV upload")
+			return
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) != len(header) {
+			report.Add(models.ImportRowResult{Row: line, Status: models.ImportError, Reason: "Wrong number of columns"})
+			continue
+		}
+
+		report.Add(h.importRow(r.Context(), line, columns, record, stockSource))
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: fmt.Sprintf("Imported %d products (%d skipped, %d errors)", report.Created, report.Skipped, report.Errors),
+		Data:    report,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// importRow validates a single CSV record and creates the product it describes
+func (h *ProductHandler) importRow(ctx context.Context, line int, columns map[string]int, record []string, stockSource models.StockSource) models.ImportRowResult {
+	field := func(name string) string {
+		if index, ok := columns[name]; ok {
+			return strings.TrimSpace(record[index])
+		}
+		return ""
+	}
+
+	result := models.ImportRowResult{Row: line, Name: field("name")}
+	fail := func(reason string) models.ImportRowResult {
+		result.Status = models.ImportError
+		result.Reason = reason
+		return result
+	}
+
+	if result.Name == "" {
+		return fail("Name is required")
+	}
+
+	price, err := strconv.ParseFloat(field("price"), 64)
+	if err != nil || price <= 0 {
+		return fail("Price must be a positive number")
+	}
+
+	stock := 0
+	if value := field("stock"); value != "" {
+		stock, err = strconv.Atoi(value)
+		if err != nil || stock < 0 {
+			return fail("Stock must be a non-negative integer")
+		}
+	}
+
+	categoryRef := field("category_id")
+	if categoryRef == "" {
+		categoryRef = field("category")
+	}
+	if categoryRef == "" {
+		return fail("Category is required")
+	}
+	category, err := h.resolveCategory(categoryRef)
+	if err != nil {
+		return fail("Category does not exist")
+	}
+
+	tags, err := models.NormalizeTags(strings.Split(field("tags"), tagSeparator))
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	product := models.NewProduct(result.Name, field("description"), category.Name, price, 0, field("image_url"))
+	product.CategoryID = category.ID
+	product.Tags = tags
+	if err := h.repo.Create(ctx, product); err != nil {
+		// The repository only rejects duplicate names, which are skipped rather than failed
+		result.Status = models.ImportSkipped
+		result.Reason = err.Error()
+		return result
+	}
+
+	if stock > 0 {
+		stockSource.Reference = fmt.Sprintf("%s:row-%d", stockSource.Reference, line)
+		if _, err := h.repo.AdjustStock(ctx, product.ID, stock, stockSource); err != nil {
+			return fail("Created without stock: " + err.Error())
+		}
+	}
+
+	result.Status = models.ImportCreated
+	result.ProductID = product.ID
+	return result
+}
+
+// parseImportHeader maps known column names to their index and checks required columns are present
+func parseImportHeader(header []string) (map[string]int, error) {
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !containsColumn(name) {
+			return nil, fmt.Errorf("Unknown column %q", name)
+		}
+		if _, duplicate := columns[name]; duplicate {
+			return nil, fmt.Errorf("Duplicate column %q", name)
+		}
+		columns[name] = i
+	}
+
+	_, hasCategory := columns["category"]
+	_, hasCategoryID := columns["category_id"]
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("CSV header must include a name column")
+	}
+	if _, ok := columns["price"]; !ok {
+		return nil, errors.New("CSV header must include a price column")
+	}
+	if !hasCategory && !hasCategoryID {
+		return nil, errors.New("CSV header must include a category or category_id column")
+	}
+	return columns, nil
+}
+
+// containsColumn reports whether name is a recognised import column
+func containsColumn(name string) bool {
+	for _, column := range importColumns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
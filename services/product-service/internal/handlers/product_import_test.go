@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"product-service/internal/models"
+)
+
+func TestImportProducts_ReportsEachRow(t *testing.T) {
+	h := setupProductHandler()
+	csvBody := "name,description,category,price,stock\n" +
+		"Desk Lamp,LED lamp,Electronics,24.50,10\n" +
+		"iPhone 15 Pro,duplicate,Electronics,999.99,1\n" +
+		"Broken,,Electronics,free,1\n" +
+		"Mystery,,Gadgets,5,1\n" +
+		"\"Running Socks\",\"Pack of 3\",footwear,9.99,\n"
+
+	req := httptest.NewRequest(http.MethodPost, "/products/import", bytes.NewBufferString(csvBody))
+	req.Header.Set("Content-Type", "text/csv")
+	rec := httptest.NewRecorder()
+	h.ImportProducts(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Data models.ImportReport `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	report := resp.Data
+	if report.Created != 2 || report.Skipped != 1 || report.Errors != 2 {
+		t.Fatalf("expected 2 created, 1 skipped, 2 errors, got %+v", report)
+	}
+
+	expected := []string{models.ImportCreated, models.ImportSkipped, models.ImportError, models.ImportError, models.ImportCreated}
+	for i, status := range expected {
+		if report.Rows[i].Status != status || report.Rows[i].Row != i+2 {
+			t.Fatalf("row %d: expected %s on line %d, got %+v", i, status, i+2, report.Rows[i])
+		}
+	}
+
+	created, err := h.repo.GetByID(context.Background(), report.Rows[4].ProductID)
+	if err != nil || created.CategoryID != "footwear" || created.Stock != 0 {
+		t.Fatalf("expected imported socks in footwear, got %+v (%v)", created, err)
+	}
+}
+
+func TestImportProducts_Multipart(t *testing.T) {
+	h := setupProductHandler()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", "products.csv")
+	part.Write([]byte("name,category_id,price\nKettle,appliances,35\n"))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/products/import", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	h.ImportProducts(rec, req)
+	if rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte(`"created":1`)) {
+		t.Fatalf("expected one product created, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestImportProducts_InvalidHeader(t *testing.T) {
+	h := setupProductHandler()
+	req := httptest.NewRequest(http.MethodPost, "/products/import", bytes.NewBufferString("title,price\nLamp,10\n"))
+	rec := httptest.NewRecorder()
+	h.ImportProducts(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 got %d", rec.Code)
+	}
+}
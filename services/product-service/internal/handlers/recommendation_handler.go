@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"ecommerce/pkg/api"
+	"product-service/internal/currency"
+	"product-service/internal/models"
+	"product-service/internal/recommend"
+	"product-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// Related product list sizes
+const (
+	DefaultRelatedLimit = 8
+	MaxRelatedLimit     = 50
+)
+
+// RecommendationHandler handles HTTP requests for related products
+type RecommendationHandler struct {
+	products    repository.ProductRepository
+	recommender recommend.Recommender
+	currencies  *currency.Converter
+}
+
+// NewRecommendationHandler creates a new recommendation handler
+func NewRecommendationHandler(products repository.ProductRepository, recommender recommend.Recommender, currencies *currency.Converter) *RecommendationHandler {
+	return &RecommendationHandler{
+		products:    products,
+		recommender: recommender,
+		currencies:  currencies,
+	}
+}
+
+// ListRelated handles GET /products/{id}/related - lists products related to a product (?limit=, ?currency=)
+func (h *RecommendationHandler) ListRelated(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	product, err := h.products.GetByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil || product.Status != models.ProductStatusPublished {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+
+	limit := DefaultRelatedLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > MaxRelatedLimit {
+			api.WriteError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = parsed
+	}
+
+	code, err := requestedCurrency(r, h.currencies)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	related, err := h.recommender.Related(r.Context(), product, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error finding related products", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve related products")
+		return
+	}
+	if err := convertPrices(h.currencies, code, related...); err != nil {
+		slog.ErrorContext(r.Context(), "Error converting prices", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to convert prices")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    related,
+	}
+
+	sendCacheable(w, r, response)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"context"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"product-service/internal/models"
+	"product-service/internal/recommend"
+	"product-service/internal/repository"
+)
+
+func TestListRelated(t *testing.T) {
+	repo := repository.NewInMemoryProductRepository()
+	h := NewRecommendationHandler(repo, recommend.NewCatalogRecommender(repo), testCurrencies())
+	lamp := models.NewProduct("Reading Lamp", "", "Lighting", 15, 2, "")
+	_ = repo.Create(context.Background(), lamp)
+	_ = repo.Create(context.Background(), models.NewProduct("Wall Lamp", "", "Lighting", 25, 1, ""))
+
+	rec := httptest.NewRecorder()
+	h.ListRelated(rec, imageRequest(http.MethodGet, "/products/"+lamp.ID+"/related", "", map[string]string{"id": lamp.ID}))
+	if rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte(`"name":"Wall Lamp"`)) {
+		t.Fatalf("expected related product, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ListRelated(rec, imageRequest(http.MethodGet, "/products/"+lamp.ID+"/related?limit=0", "", map[string]string{"id": lamp.ID}))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid limit got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ListRelated(rec, imageRequest(http.MethodGet, "/products/missing/related", "", map[string]string{"id": "missing"}))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 got %d", rec.Code)
+	}
+}
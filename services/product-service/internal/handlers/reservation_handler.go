@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+	"ecommerce/pkg/api"
+	"ecommerce/pkg/metrics"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// DefaultReservationTTL is how long reserved stock is held when neither the caller nor config says otherwise
+const DefaultReservationTTL = 15 * time.Minute
+
+// MaxReservationTTL caps the hold a caller may request with ttl_seconds
+const MaxReservationTTL = 24 * time.Hour
+
+// Stock reservation metrics, published at /metrics
+var (
+	stockReservations = metrics.NewCounterVec("stock_reservations_total",
+		"Stock reservation requests, by outcome: reserved, insufficient_stock, released, or committed", "outcome")
+	stockUnits = metrics.NewCounterVec("stock_reservation_units_total",
+		"Units of stock reserved, released, or committed", "outcome")
+)
+
+// ReservationHandler handles stock reservations made by order service during checkout
+type ReservationHandler struct {
+	repo repository.ProductRepository
+	ttl  time.Duration
+}
+
+// NewReservationHandler creates a new reservation handler holding stock for ttl by default
+func NewReservationHandler(repo repository.ProductRepository, ttl time.Duration) *ReservationHandler {
+	if ttl <= 0 {
+		ttl = DefaultReservationTTL
+	}
+	return &ReservationHandler{
+		repo: repo,
+		ttl:  ttl,
+	}
+}
+
+// ReserveStock handles POST /products/{id}/reserve - atomically sets stock aside for an order
+func (h *ReservationHandler) ReserveStock(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	productID := mux.Vars(r)["id"]
+
+	var req models.ReserveStockRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	ttl := h.ttl
+	if req.TTLSeconds != 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+		if ttl <= 0 || ttl > MaxReservationTTL {
+			api.WriteError(w, http.StatusBadRequest, "ttl_seconds must be between 1 and 86400")
+			return
+		}
+	}
+
+	reservation, err := h.repo.ReserveStock(r.Context(), productID, req.OrderID, req.Quantity, ttl, stockActor(r))
+	if errors.Is(err, models.ErrInsufficientStock) {
+		stockReservations.WithLabelValues("insufficient_stock").Inc()
+		api.WriteErrorCode(w, http.StatusConflict, CodeProductInsufficientStock, "Insufficient stock")
+		return
+	}
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+	stockReservations.WithLabelValues("reserved").Inc()
+	stockUnits.WithLabelValues("reserved").Add(float64(reservation.Quantity))
+
+	response := models.Response{
+		Success: true,
+		Message: "Stock reserved successfully",
+		Data:    reservation,
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ReleaseStock handles POST /products/{id}/release - returns a reservation's stock
+func (h *ReservationHandler) ReleaseStock(w http.ResponseWriter, r *http.Request) {
+	h.closeReservation(w, r, h.repo.ReleaseReservation, "Stock released successfully")
+}
+
+// CommitStock handles POST /products/{id}/commit - turns a held reservation into a sale
+func (h *ReservationHandler) CommitStock(w http.ResponseWriter, r *http.Request) {
+	h.closeReservation(w, r, h.repo.CommitReservation, "Stock committed successfully")
+}
+
+// closeReservation applies a release or commit to the reservation named in the request body
+func (h *ReservationHandler) closeReservation(w http.ResponseWriter, r *http.Request, apply func(ctx context.Context, productID, reservationID, actor string) (*models.StockReservation, error), message string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	productID := mux.Vars(r)["id"]
+
+	var req models.ReservationActionRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	reservation, err := apply(r.Context(), productID, req.ReservationID, stockActor(r))
+	switch {
+	case errors.Is(err, models.ErrReservationNotFound):
+		api.WriteErrorCode(w, http.StatusNotFound, CodeReservationNotFound, "Reservation not found")
+		return
+	case errors.Is(err, models.ErrReservationClosed):
+		api.WriteErrorCode(w, http.StatusConflict, CodeReservationInactive, "Reservation is no longer active")
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Error updating reservation", "reservation_id", req.ReservationID, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to update reservation")
+		return
+	}
+	stockReservations.WithLabelValues(string(reservation.Status)).Inc()
+	stockUnits.WithLabelValues(string(reservation.Status)).Add(float64(reservation.Quantity))
+
+	response := models.Response{
+		Success: true,
+		Message: message,
+		Data:    reservation,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+)
+
+func TestReservationHandler_ReserveAndRelease(t *testing.T) {
+	repo := repository.NewInMemoryProductRepository()
+	product := models.NewProduct("Console", "", "Electronics", 400, 2, "")
+	_ = repo.Create(context.Background(), product)
+	h := NewReservationHandler(repo, 0)
+	vars := map[string]string{"id": product.ID}
+	releasedBefore := stockUnits.WithLabelValues("released").Value()
+
+	rec := httptest.NewRecorder()
+	h.ReserveStock(rec, imageRequest(http.MethodPost, "/products/"+product.ID+"/reserve", `{"quantity":3}`, vars))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for more than available got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ReserveStock(rec, imageRequest(http.MethodPost, "/products/"+product.ID+"/reserve", `{"quantity":2,"order_id":"o1"}`, vars))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", rec.Code)
+	}
+	var resp struct {
+		Data models.StockReservation `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Data.ID == "" || resp.Data.Status != models.ReservationHeld {
+		t.Fatalf("expected held reservation, got %+v", resp.Data)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ReleaseStock(rec, imageRequest(http.MethodPost, "/products/"+product.ID+"/release", `{"reservation_id":"`+resp.Data.ID+`"}`, vars))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.CommitStock(rec, imageRequest(http.MethodPost, "/products/"+product.ID+"/commit", `{"reservation_id":"`+resp.Data.ID+`"}`, vars))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 committing a released reservation got %d", rec.Code)
+	}
+
+	stored, _ := repo.GetByID(context.Background(), product.ID)
+	if stored.Stock != 2 {
+		t.Errorf("expected stock back to 2, got %d", stored.Stock)
+	}
+	if released := stockUnits.WithLabelValues("released").Value() - releasedBefore; released != 2 {
+		t.Errorf("expected 2 released units counted, got %v", released)
+	}
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"ecommerce/pkg/api"
+	"product-service/internal/client"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// ReviewHandler handles HTTP requests for product reviews
+type ReviewHandler struct {
+	reviews         repository.ReviewRepository
+	products        repository.ProductRepository
+	purchases       client.PurchaseVerifier
+	requirePurchase atomic.Bool
+}
+
+// NewReviewHandler creates a new review handler. When requirePurchase is set, only users
+// the order service reports as having bought the product may review it; otherwise
+// reviews are accepted and flagged as verified when a purchase is found.
+func NewReviewHandler(reviews repository.ReviewRepository, products repository.ProductRepository, purchases client.PurchaseVerifier, requirePurchase bool) *ReviewHandler {
+	h := &ReviewHandler{
+		reviews:   reviews,
+		products:  products,
+		purchases: purchases,
+	}
+	h.requirePurchase.Store(requirePurchase)
+	return h
+}
+
+// SetRequirePurchase turns the purchase requirement for new reviews on or off
+func (h *ReviewHandler) SetRequirePurchase(required bool) {
+	h.requirePurchase.Store(required)
+}
+
+// CreateReview handles POST /products/{id}/reviews - adds a rating and review for a product
+func (h *ReviewHandler) CreateReview(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	productID := mux.Vars(r)["id"]
+	if _, err := h.products.GetByID(r.Context(), productID); err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+
+	var req models.CreateReviewRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	req.UserID = strings.TrimSpace(req.UserID)
+	if req.UserID == "" {
+		api.WriteError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
+	if req.Rating < models.MinRating || req.Rating > models.MaxRating {
+		api.WriteError(w, http.StatusBadRequest, "Rating must be between 1 and 5")
+		return
+	}
+
+	// Read the requirement once so a reload mid-request can't apply half of it
+	requirePurchase := h.requirePurchase.Load()
+	verified := false
+	if h.purchases != nil {
+		purchased, err := h.purchases.HasPurchased(r.Context(), req.UserID, productID)
+		switch {
+		case err != nil && requirePurchase:
+			slog.ErrorContext(r.Context(), "Error verifying purchase for review", "error", err)
+			api.WriteError(w, http.StatusServiceUnavailable, "Unable to verify purchase")
+			return
+		case err != nil:
+			// Purchase checks are best-effort when not required; keep the review unverified
+			slog.ErrorContext(r.Context(), "Error verifying purchase for review", "error", err)
+		default:
+			verified = purchased
+		}
+	}
+	if requirePurchase && !verified {
+		api.WriteErrorCode(w, http.StatusForbidden, CodeReviewPurchaseRequired, "Only customers who purchased this product can review it")
+		return
+	}
+
+	review := models.NewReview(productID, req.UserID, req.Rating, strings.TrimSpace(req.Title), strings.TrimSpace(req.Body))
+	review.VerifiedPurchase = verified
+
+	if err := h.reviews.Create(review); err != nil {
+		api.WriteErrorCode(w, http.StatusConflict, CodeReviewExists, "User has already reviewed this product")
+		return
+	}
+
+	h.refreshRating(r.Context(), productID)
+
+	w.WriteHeader(http.StatusCreated)
+	response := models.Response{
+		Success: true,
+		Message: "Review created successfully",
+		Data:    review,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListReviews handles GET /products/{id}/reviews - returns a page of reviews, newest first
+func (h *ReviewHandler) ListReviews(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	productID := mux.Vars(r)["id"]
+	if _, err := h.products.GetByID(r.Context(), productID); err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+
+	query := r.URL.Query()
+	page, limit := 1, models.DefaultPageLimit
+	if value := query.Get("page"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			api.WriteError(w, http.StatusBadRequest, "Invalid page")
+			return
+		}
+		page = parsed
+	}
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > models.MaxPageLimit {
+			api.WriteError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = parsed
+	}
+
+	reviews, pageInfo, err := h.reviews.ListByProduct(productID, page, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing reviews", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve reviews")
+		return
+	}
+
+	response := models.Response{
+		Success:    true,
+		Data:       reviews,
+		Pagination: pageInfo,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// refreshRating recomputes the product's average rating and review count
+func (h *ReviewHandler) refreshRating(ctx context.Context, productID string) {
+	average, count, err := h.reviews.Stats(productID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error computing review stats", "product_id", productID, "error", err)
+		return
+	}
+
+	// Round to two decimals for display
+	average = math.Round(average*100) / 100
+	if err := h.products.UpdateRating(ctx, productID, average, count); err != nil {
+		slog.ErrorContext(ctx, "Error updating rating", "product_id", productID, "error", err)
+	}
+}
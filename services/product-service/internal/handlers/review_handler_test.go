@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+)
+
+// stubPurchases reports purchases from a fixed set of user IDs
+type stubPurchases struct {
+	buyers map[string]bool
+	err    error
+}
+
+func (s *stubPurchases) HasPurchased(ctx context.Context, userID, productID string) (bool, error) {
+	return s.buyers[userID], s.err
+}
+
+func TestReviewHandler_CreateUpdatesRating(t *testing.T) {
+	products := repository.NewInMemoryProductRepository()
+	product := models.NewProduct("Camera", "", "Electronics", 300, 2, "")
+	_ = products.Create(context.Background(), product)
+	h := NewReviewHandler(repository.NewInMemoryReviewRepository(), products, &stubPurchases{buyers: map[string]bool{"buyer": true}}, false)
+	vars := map[string]string{"id": product.ID}
+	target := "/products/" + product.ID + "/reviews"
+
+	rec := httptest.NewRecorder()
+	h.CreateReview(rec, imageRequest(http.MethodPost, target, `{"user_id":"buyer","rating":5,"title":"Great"}`, vars))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", rec.Code)
+	}
+	var created struct {
+		Data models.Review `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	if !created.Data.VerifiedPurchase {
+		t.Error("expected buyer's review to be verified")
+	}
+
+	rec = httptest.NewRecorder()
+	h.CreateReview(rec, imageRequest(http.MethodPost, target, `{"user_id":"browser","rating":2}`, vars))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for unverified review got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.CreateReview(rec, imageRequest(http.MethodPost, target, `{"user_id":"buyer","rating":4}`, vars))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for second review got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.CreateReview(rec, imageRequest(http.MethodPost, target, `{"user_id":"other","rating":6}`, vars))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for out-of-range rating got %d", rec.Code)
+	}
+
+	stored, _ := products.GetByID(context.Background(), product.ID)
+	if stored.ReviewCount != 2 || stored.AverageRating != 3.5 {
+		t.Fatalf("expected 2 reviews averaging 3.5, got %d at %v", stored.ReviewCount, stored.AverageRating)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ListReviews(rec, imageRequest(http.MethodGet, target+"?limit=1", "", vars))
+	var listed struct {
+		Data       []models.Review  `json:"data"`
+		Pagination *models.PageInfo `json:"pagination"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &listed)
+	if len(listed.Data) != 1 || listed.Pagination == nil || listed.Pagination.Total != 2 || !listed.Pagination.HasMore {
+		t.Fatalf("expected first of two reviews, got %+v", listed)
+	}
+}
+
+func TestReviewHandler_RequirePurchase(t *testing.T) {
+	products := repository.NewInMemoryProductRepository()
+	product := models.NewProduct("Camera", "", "Electronics", 300, 2, "")
+	_ = products.Create(context.Background(), product)
+	purchases := &stubPurchases{buyers: map[string]bool{"buyer": true}}
+	h := NewReviewHandler(repository.NewInMemoryReviewRepository(), products, purchases, true)
+	vars := map[string]string{"id": product.ID}
+	target := "/products/" + product.ID + "/reviews"
+
+	rec := httptest.NewRecorder()
+	h.CreateReview(rec, imageRequest(http.MethodPost, target, `{"user_id":"browser","rating":1}`, vars))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for non-buyer got %d", rec.Code)
+	}
+
+	purchases.err = errors.New("order service down")
+	rec = httptest.NewRecorder()
+	h.CreateReview(rec, imageRequest(http.MethodPost, target, `{"user_id":"buyer","rating":5}`, vars))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 when purchase cannot be verified got %d", rec.Code)
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"ecommerce/pkg/api"
+	"product-service/internal/client"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// StockAlertHandler handles back-in-stock subscriptions and announces restocks to subscribers
+type StockAlertHandler struct {
+	subscriptions repository.StockSubscriptionRepository
+	products      repository.ProductRepository
+	publisher     client.BackInStockPublisher
+}
+
+// NewStockAlertHandler creates a new stock alert handler. Restock events go to publisher;
+// when it is nil they are only logged.
+func NewStockAlertHandler(subscriptions repository.StockSubscriptionRepository, products repository.ProductRepository, publisher client.BackInStockPublisher) *StockAlertHandler {
+	return &StockAlertHandler{
+		subscriptions: subscriptions,
+		products:      products,
+		publisher:     publisher,
+	}
+}
+
+// NotifyMe handles POST /products/{id}/notify-me - subscribes a user to be told when an
+// out-of-stock product is available again
+func (h *StockAlertHandler) NotifyMe(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	productID := mux.Vars(r)["id"]
+	product, err := h.products.GetByID(r.Context(), productID)
+	if err != nil || !product.IsPublished(time.Now()) {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+
+	var req models.NotifyMeRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	req.UserID = strings.TrimSpace(req.UserID)
+	if req.UserID == "" {
+		api.WriteError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
+	if product.IsInStock() {
+		api.WriteErrorCode(w, http.StatusConflict, CodeProductInStock, "Product is in stock")
+		return
+	}
+
+	subscription := models.NewStockSubscription(productID, req.UserID)
+	if err := h.subscriptions.Create(subscription); err != nil {
+		api.WriteErrorCode(w, http.StatusConflict, CodeStockAlertExists, "User is already subscribed to this product")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	response := models.Response{
+		Success: true,
+		Message: "You will be notified when this product is back in stock",
+		Data:    subscription,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// ProductRestocked sends a back-in-stock event for everyone subscribed to the product. It is
+// registered as the product repository's restock listener. Subscribers are notified once;
+// if the event can't be delivered they stay subscribed for the next restock.
+func (h *StockAlertHandler) ProductRestocked(product *models.Product) {
+	subscriptions, err := h.subscriptions.TakeByProduct(product.ID)
+	if err != nil {
+		slog.Error("Error loading stock subscriptions", "product_id", product.ID, "error", err)
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	event := models.NewBackInStockEvent(product, subscriptions)
+	if h.publisher == nil {
+		slog.Info("Product is back in stock", "product_id", product.ID, "subscriber_count", len(event.UserIDs))
+		return
+	}
+
+	if err := h.publisher.PublishBackInStock(event); err != nil {
+		slog.Error("Error publishing back-in-stock event", "product_id", product.ID, "error", err)
+		for _, subscription := range subscriptions {
+			h.subscriptions.Create(subscription)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+)
+
+// stubPublisher records published events on a channel
+type stubPublisher struct {
+	events chan *models.BackInStockEvent
+	err    error
+}
+
+func (s *stubPublisher) PublishBackInStock(event *models.BackInStockEvent) error {
+	s.events <- event
+	return s.err
+}
+
+func TestStockAlertHandler_NotifyMe(t *testing.T) {
+	products := repository.NewInMemoryProductRepository()
+	soldOut := models.NewProduct("Console", "", "Electronics", 499, 0, "")
+	inStock := models.NewProduct("Controller", "", "Electronics", 59, 3, "")
+	_ = products.Create(context.Background(), soldOut)
+	_ = products.Create(context.Background(), inStock)
+	h := NewStockAlertHandler(repository.NewInMemoryStockSubscriptionRepository(), products, nil)
+
+	cases := []struct {
+		name    string
+		product string
+		body    string
+		want    int
+	}{
+		{"subscribes", soldOut.ID, `{"user_id":"u1"}`, http.StatusCreated},
+		{"duplicate", soldOut.ID, `{"user_id":"u1"}`, http.StatusConflict},
+		{"missing user", soldOut.ID, `{}`, http.StatusBadRequest},
+		{"in stock", inStock.ID, `{"user_id":"u1"}`, http.StatusConflict},
+		{"unknown product", "missing", `{"user_id":"u1"}`, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		h.NotifyMe(rec, imageRequest(http.MethodPost, "/products/"+tc.product+"/notify-me", tc.body, map[string]string{"id": tc.product}))
+		if rec.Code != tc.want {
+			t.Errorf("%s: expected %d got %d", tc.name, tc.want, rec.Code)
+		}
+	}
+}
+
+func TestStockAlertHandler_PublishesOnRestock(t *testing.T) {
+	products := repository.NewInMemoryProductRepository()
+	product := models.NewProduct("Console", "", "Electronics", 499, 0, "")
+	_ = products.Create(context.Background(), product)
+	subscriptions := repository.NewInMemoryStockSubscriptionRepository()
+	_ = subscriptions.Create(models.NewStockSubscription(product.ID, "u1"))
+	_ = subscriptions.Create(models.NewStockSubscription(product.ID, "u2"))
+
+	publisher := &stubPublisher{events: make(chan *models.BackInStockEvent, 1), err: errors.New("webhook down")}
+	h := NewStockAlertHandler(subscriptions, products, publisher)
+	products.OnRestock(h.ProductRestocked)
+
+	restock := func() *models.BackInStockEvent {
+		t.Helper()
+		if _, err := products.AdjustStock(context.Background(), product.ID, 5, models.StockSource{}); err != nil {
+			t.Fatalf("AdjustStock failed: %v", err)
+		}
+		select {
+		case event := <-publisher.events:
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("expected a back-in-stock event")
+			return nil
+		}
+	}
+
+	// A failed delivery keeps everyone subscribed for the next restock
+	event := restock()
+	if event.Type != models.EventBackInStock || event.Stock != 5 || len(event.UserIDs) != 2 {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	// Give the listener a moment to restore the subscriptions after the failed delivery
+	deadline := time.Now().Add(time.Second)
+	for {
+		if remaining, _ := subscriptions.ListByProduct(product.ID); len(remaining) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected subscriptions to be kept after a failed delivery")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	_, _ = products.AdjustStock(context.Background(), product.ID, -5, models.StockSource{})
+	publisher.err = nil
+	if event := restock(); len(event.UserIDs) != 2 {
+		t.Fatalf("expected both subscribers in the event, got %v", event.UserIDs)
+	}
+
+	// Adding to stock that is already positive is not a restock
+	if _, err := products.AdjustStock(context.Background(), product.ID, 1, models.StockSource{}); err != nil {
+		t.Fatalf("AdjustStock failed: %v", err)
+	}
+	select {
+	case event := <-publisher.events:
+		t.Fatalf("expected no event when stock was already positive, got %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
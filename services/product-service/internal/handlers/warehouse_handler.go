@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"ecommerce/pkg/api"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// WarehouseHandler handles HTTP requests for warehouses and per-warehouse inventory
+type WarehouseHandler struct {
+	repo     repository.WarehouseRepository
+	products repository.ProductRepository
+}
+
+// NewWarehouseHandler creates a new warehouse handler
+func NewWarehouseHandler(repo repository.WarehouseRepository, products repository.ProductRepository) *WarehouseHandler {
+	return &WarehouseHandler{
+		repo:     repo,
+		products: products,
+	}
+}
+
+// inventoryResponse is a product's stock broken down by warehouse
+type inventoryResponse struct {
+	ProductID string                  `json:"product_id"`
+	Stock     int                     `json:"stock"`
+	Inventory []models.InventoryLevel `json:"inventory"`
+}
+
+// ListWarehouses handles GET /warehouses - lists all warehouses
+func (h *WarehouseHandler) ListWarehouses(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	warehouses, err := h.repo.List()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing warehouses", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve warehouses")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    warehouses,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateWarehouse handles POST /warehouses - creates a new warehouse
+func (h *WarehouseHandler) CreateWarehouse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.CreateWarehouseRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	warehouse := models.NewWarehouse(req.Name, req.Address)
+	if req.Slug != "" {
+		warehouse.ID = models.Slugify(req.Slug)
+	}
+	if warehouse.ID == "" {
+		api.WriteError(w, http.StatusBadRequest, "Name or slug must contain letters or digits")
+		return
+	}
+
+	if err := h.repo.Create(warehouse); err != nil {
+		slog.ErrorContext(r.Context(), "Error creating warehouse", "error", err)
+		api.WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Warehouse created successfully",
+		Data:    warehouse,
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetWarehouse handles GET /warehouses/{id} - retrieves a warehouse
+func (h *WarehouseHandler) GetWarehouse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	warehouse, err := h.repo.GetByID(mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeWarehouseNotFound, "Warehouse not found")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    warehouse,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetInventory handles GET /products/{id}/inventory - returns a product's stock per warehouse
+func (h *WarehouseHandler) GetInventory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	product, err := h.products.GetByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    inventoryOf(product),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateWarehouseStock handles PATCH /products/{id}/inventory/{warehouse_id} - sets ({"stock": 10})
+// or atomically adjusts ({"delta": -2}) the quantity held at one warehouse
+func (h *WarehouseHandler) UpdateWarehouseStock(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	productID, warehouseID := vars["id"], vars["warehouse_id"]
+
+	if _, err := h.repo.GetByID(warehouseID); err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeWarehouseNotFound, "Warehouse not found")
+		return
+	}
+
+	var req models.UpdateStockRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	if (req.Stock == nil) == (req.Delta == nil) {
+		api.WriteError(w, http.StatusBadRequest, "Provide either stock or delta")
+		return
+	}
+
+	if req.Delta != nil {
+		source := models.StockSource{Actor: stockActor(r), Reason: models.StockReasonManualAdjustment, Note: req.Note}
+		_, err := h.products.AdjustWarehouseStock(r.Context(), productID, warehouseID, *req.Delta, source)
+		if errors.Is(err, models.ErrInsufficientStock) {
+			api.WriteErrorCode(w, http.StatusConflict, CodeWarehouseInsufficientStock, "Adjustment would make warehouse stock negative")
+			return
+		}
+		if err != nil {
+			api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+			return
+		}
+	} else {
+		if *req.Stock < 0 {
+			api.WriteError(w, http.StatusBadRequest, "stock quantity cannot be negative")
+			return
+		}
+		source := models.StockSource{Actor: stockActor(r), Reason: models.StockReasonManualSet, Note: req.Note}
+		if err := h.products.SetWarehouseStock(r.Context(), productID, warehouseID, *req.Stock, source); err != nil {
+			api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+			return
+		}
+	}
+
+	h.respondWithInventory(w, r, productID, "Stock updated successfully")
+}
+
+// TransferStock handles POST /products/{id}/inventory/transfer - moves stock between warehouses
+func (h *WarehouseHandler) TransferStock(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	productID := mux.Vars(r)["id"]
+
+	var req models.TransferStockRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.FromWarehouseID == req.ToWarehouseID {
+		api.WriteError(w, http.StatusBadRequest, "Source and destination warehouses must differ")
+		return
+	}
+	for _, id := range []string{req.FromWarehouseID, req.ToWarehouseID} {
+		if _, err := h.repo.GetByID(id); err != nil {
+			api.WriteError(w, http.StatusNotFound, fmt.Sprintf("Warehouse %q not found", id))
+			return
+		}
+	}
+
+	source := models.StockSource{Actor: stockActor(r), Reason: models.StockReasonTransfer, Note: req.Note}
+	product, err := h.products.TransferStock(r.Context(), productID, req.FromWarehouseID, req.ToWarehouseID, req.Quantity, source)
+	if errors.Is(err, models.ErrInsufficientStock) {
+		api.WriteErrorCode(w, http.StatusConflict, CodeWarehouseInsufficientStock, "Not enough stock in the source warehouse")
+		return
+	}
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Stock transferred successfully",
+		Data:    inventoryOf(product),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// respondWithInventory writes the product's current inventory as a success response
+func (h *WarehouseHandler) respondWithInventory(w http.ResponseWriter, r *http.Request, productID, message string) {
+	product, err := h.products.GetByID(r.Context(), productID)
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: message,
+		Data:    inventoryOf(product),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// inventoryOf extracts the inventory view of a product
+func inventoryOf(product *models.Product) inventoryResponse {
+	return inventoryResponse{
+		ProductID: product.ID,
+		Stock:     product.Stock,
+		Inventory: product.Inventory,
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+)
+
+func TestWarehouseHandler_TransferAndAdjust(t *testing.T) {
+	products := repository.NewInMemoryProductRepository()
+	product := models.NewProduct("Fridge", "", "Appliances", 800, 5, "")
+	_ = products.Create(context.Background(), product)
+	h := NewWarehouseHandler(repository.NewInMemoryWarehouseRepository(), products)
+
+	rec := httptest.NewRecorder()
+	h.CreateWarehouse(rec, imageRequest(http.MethodPost, "/warehouses", `{"name":"Kisumu Depot","slug":"kisumu"}`, nil))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", rec.Code)
+	}
+
+	vars := map[string]string{"id": product.ID}
+	transfer := func(body string) int {
+		rec := httptest.NewRecorder()
+		h.TransferStock(rec, imageRequest(http.MethodPost, "/products/"+product.ID+"/inventory/transfer", body, vars))
+		return rec.Code
+	}
+	if code := transfer(`{"from_warehouse_id":"main","to_warehouse_id":"kisumu","quantity":3}`); code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", code)
+	}
+	if code := transfer(`{"from_warehouse_id":"main","to_warehouse_id":"kisumu","quantity":3}`); code != http.StatusConflict {
+		t.Fatalf("expected 409 for insufficient stock got %d", code)
+	}
+	if code := transfer(`{"from_warehouse_id":"main","to_warehouse_id":"nowhere","quantity":1}`); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown warehouse got %d", code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.UpdateWarehouseStock(rec, imageRequest(http.MethodPatch, "/products/"+product.ID+"/inventory/kisumu", `{"delta":2}`, map[string]string{"id": product.ID, "warehouse_id": "kisumu"}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+
+	stored, _ := products.GetByID(context.Background(), product.ID)
+	if stored.Stock != 7 || stored.WarehouseQuantity("kisumu") != 5 || stored.WarehouseQuantity(models.DefaultWarehouseID) != 2 {
+		t.Fatalf("unexpected inventory %+v (stock %d)", stored.Inventory, stored.Stock)
+	}
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// Attribute limits
+const (
+	MaxAttributesPerProduct = 50
+	MaxAttributeKeyLength   = 50
+	MaxAttributeValueLength = 200
+)
+
+// AttributeQueryPrefix marks list query parameters that filter on attributes, as in ?attr.color=red
+const AttributeQueryPrefix = "attr."
+
+// NormalizeAttributes lowercases and trims keys and trims values, dropping entries with a blank value.
+// Keys may contain only lowercase letters, digits, and underscores.
+func NormalizeAttributes(attributes map[string]string) (map[string]string, error) {
+	normalized := make(map[string]string, len(attributes))
+	for key, value := range attributes {
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !validAttributeKey(key) {
+			return nil, errors.New("attribute names must be 1-50 characters of letters, digits, or underscores")
+		}
+		if len(value) > MaxAttributeValueLength {
+			return nil, errors.New("attribute values must be at most 200 characters")
+		}
+		normalized[key] = value
+	}
+	if len(normalized) > MaxAttributesPerProduct {
+		return nil, errors.New("a product can have at most 50 attributes")
+	}
+	return normalized, nil
+}
+
+// validAttributeKey checks an already lowercased attribute name
+func validAttributeKey(key string) bool {
+	if key == "" || len(key) > MaxAttributeKeyLength {
+		return false
+	}
+	for _, c := range key {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// HasAttributes reports whether the product has every given attribute. Names must be
+// normalized; values are compared case-insensitively.
+func (p *Product) HasAttributes(attributes map[string]string) bool {
+	for key, value := range attributes {
+		if own, exists := p.Attributes[key]; !exists || !strings.EqualFold(own, value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Category represents a node in the product category hierarchy.
+// The ID is a URL-friendly slug derived from the name, e.g. "running-shoes".
+type Category struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	ParentID    string    `json:"parent_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CategoryNode is a category together with its subcategories, used for tree listings
+type CategoryNode struct {
+	Category
+	Children []*CategoryNode `json:"children,omitempty"`
+}
+
+// CreateCategoryRequest represents the request payload for creating a category
+type CreateCategoryRequest struct {
+	Name        string `json:"name" validate:"required,min=2"`
+	Slug        string `json:"slug,omitempty"`
+	Description string `json:"description,omitempty"`
+	ParentID    string `json:"parent_id,omitempty"`
+}
+
+// UpdateCategoryRequest represents the request payload for updating a category.
+// An empty parent_id moves the category to the top level.
+type UpdateCategoryRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	ParentID    *string `json:"parent_id,omitempty"`
+}
+
+// NewCategory creates a new category whose ID is the slug of its name
+func NewCategory(name, description, parentID string) *Category {
+	now := time.Now()
+	return &Category{
+		ID:          Slugify(name),
+		Name:        name,
+		Description: description,
+		ParentID:    parentID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// Slugify lowercases a name and joins its letters and digits with dashes
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
@@ -0,0 +1,38 @@
+package models
+
+// Import row outcomes
+const (
+	ImportCreated = "created"
+	ImportSkipped = "skipped"
+	ImportError   = "error"
+)
+
+// ImportRowResult reports what happened to a single CSV row during a bulk import
+type ImportRowResult struct {
+	Row       int    `json:"row"` // 1-based line number in the file, counting the header
+	Status    string `json:"status"`
+	Name      string `json:"name,omitempty"`
+	ProductID string `json:"product_id,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// ImportReport summarizes a bulk product import
+type ImportReport struct {
+	Created int               `json:"created"`
+	Skipped int               `json:"skipped"`
+	Errors  int               `json:"errors"`
+	Rows    []ImportRowResult `json:"rows"`
+}
+
+// Add records a row result and updates the totals
+func (r *ImportReport) Add(result ImportRowResult) {
+	switch result.Status {
+	case ImportCreated:
+		r.Created++
+	case ImportSkipped:
+		r.Skipped++
+	default:
+		r.Errors++
+	}
+	r.Rows = append(r.Rows, result)
+}
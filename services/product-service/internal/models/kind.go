@@ -0,0 +1,34 @@
+package models
+
+import "errors"
+
+// ProductKind says whether a product ships physically or is delivered as a download
+type ProductKind string
+
+// Product kinds. Digital products are not limited by stock; stock is only tracked for physical ones.
+const (
+	ProductKindPhysical ProductKind = "physical"
+	ProductKindDigital  ProductKind = "digital"
+)
+
+// ErrInvalidProductKind is returned for a kind other than physical or digital
+var ErrInvalidProductKind = errors.New("kind must be physical or digital")
+
+// SetKind changes the product's kind; an empty kind means physical
+func (p *Product) SetKind(kind ProductKind) error {
+	switch kind {
+	case "":
+		p.Kind = ProductKindPhysical
+	case ProductKindPhysical, ProductKindDigital:
+		p.Kind = kind
+	default:
+		return ErrInvalidProductKind
+	}
+	return nil
+}
+
+// IsDigital reports whether the product is delivered as a download. Products stored before
+// kinds existed have no kind and count as physical.
+func (p *Product) IsDigital() bool {
+	return p.Kind == ProductKindDigital
+}
@@ -0,0 +1,17 @@
+package models
+
+import "errors"
+
+// ErrInvalidOrderLimits is returned when a product's order quantity limits contradict each other
+var ErrInvalidOrderLimits = errors.New("order quantity limits must not be negative and the minimum must not exceed the maximum")
+
+// ValidateOrderLimits checks the product's minimum and maximum order quantities; zero means no limit
+func (p *Product) ValidateOrderLimits() error {
+	if p.MinOrderQty < 0 || p.MaxOrderQty < 0 {
+		return ErrInvalidOrderLimits
+	}
+	if p.MaxOrderQty > 0 && p.MinOrderQty > p.MaxOrderQty {
+		return ErrInvalidOrderLimits
+	}
+	return nil
+}
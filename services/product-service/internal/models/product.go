@@ -2,69 +2,305 @@ package models
 
 import (
 	"time"
+	"ecommerce/pkg/api"
 	"github.com/google/uuid"
 )
 
+// DefaultCurrency is the base currency product prices are stored in unless another is given
+const DefaultCurrency = "USD"
+
 // Product represents a product in the catalog
 type Product struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price"`
-	Category    string    `json:"category"`
-	Stock       int       `json:"stock"`
-	ImageURL    string    `json:"image_url,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID             string            `json:"id"`
+	Name           string            `json:"name"`
+	SKU            string            `json:"sku,omitempty"` // stock keeping unit, unique across the catalog when set
+	Kind           ProductKind       `json:"kind"`
+	Description    string            `json:"description"`
+	Price          float64           `json:"price"`
+	Currency       string            `json:"currency"` // ISO 4217 code that Price and SalePrice are in
+	SalePrice      *float64          `json:"sale_price,omitempty"`
+	SaleStart      *time.Time        `json:"sale_start,omitempty"` // sale applies from this time; nil means immediately
+	SaleEnd        *time.Time        `json:"sale_end,omitempty"`   // sale stops at this time; nil means until removed
+	EffectivePrice float64           `json:"effective_price"`      // price charged right now, computed when read
+	OnSale         bool              `json:"on_sale"`
+	CategoryID     string            `json:"category_id"`
+	Category       string            `json:"category"` // name of the category, kept for display and older clients
+	Status         ProductStatus     `json:"status"`
+	PublishAt      *time.Time        `json:"publish_at,omitempty"`    // when a scheduled product goes live
+	Stock          int               `json:"stock"`                   // total available across all warehouses
+	MinOrderQty    int               `json:"min_order_qty,omitempty"` // fewest units one order line may buy; 0 means no minimum
+	MaxOrderQty    int               `json:"max_order_qty,omitempty"` // most units one order line may buy; 0 means no maximum
+	WeightKg       float64           `json:"weight_kg,omitempty"`     // shipping weight of one unit
+	AllowBackorder bool              `json:"allow_backorder"`         // orders beyond Stock are accepted and filled when stock arrives
+	Inventory      []InventoryLevel  `json:"inventory"`               // per-warehouse breakdown of Stock
+	ImageURL       string            `json:"image_url,omitempty"`     // primary image, mirrors Images[0] for older clients
+	Images         []ProductImage    `json:"images"`
+	Tags           []string          `json:"tags"`
+	Attributes     map[string]string `json:"attributes"` // free-form specs such as "screen_size": "16in"
+	AverageRating  float64           `json:"average_rating"`
+	ReviewCount    int               `json:"review_count"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// ProductImage is one image in a product's ordered gallery; the first image is the primary one
+type ProductImage struct {
+	ID          string `json:"id"`
+	URL         string `json:"url"` // where the image is served from
+	AltText     string `json:"alt_text,omitempty"`
+	DownloadURL string `json:"download_url,omitempty"` // uploaded images only; serves the file as an attachment
+	FileName    string `json:"file_name,omitempty"`    // original name of an uploaded file
+	ContentType string `json:"content_type,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	StorageKey  string `json:"-"` // set for uploaded images, which this service stores and must clean up
+}
+
+// AddImageRequest represents the request payload for adding a product image.
+// Position is zero-based; omit it to append.
+type AddImageRequest struct {
+	URL      string `json:"url" validate:"required,url"`
+	AltText  string `json:"alt_text,omitempty"`
+	Position *int   `json:"position,omitempty"`
+}
+
+// ReorderImagesRequest lists every image ID of a product in the desired order
+type ReorderImagesRequest struct {
+	ImageIDs []string `json:"image_ids" validate:"required"`
 }
 
 // CreateProductRequest represents the request payload for creating a product
 type CreateProductRequest struct {
-	Name        string  `json:"name" validate:"required,min=2"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price" validate:"required,min=0"`
-	Category    string  `json:"category" validate:"required"`
-	Stock       int     `json:"stock" validate:"required,min=0"`
-	ImageURL    string  `json:"image_url,omitempty"`
+	Name        string            `json:"name" validate:"required,min=2"`
+	SKU         string            `json:"sku,omitempty"`
+	Kind        ProductKind       `json:"kind,omitempty"` // defaults to physical
+	Description string            `json:"description"`
+	Price       float64           `json:"price" validate:"required,gt=0"`
+	Currency    string            `json:"currency,omitempty"` // defaults to the base currency
+	CategoryID  string            `json:"category_id,omitempty"`
+	Category    string            `json:"category,omitempty"` // category name or slug, used when category_id is absent
+	Stock       int               `json:"stock" validate:"min=0"`
+	ImageURL    string            `json:"image_url,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	SalePrice   *float64          `json:"sale_price,omitempty"`
+	SaleStart   *time.Time        `json:"sale_start,omitempty"`
+	SaleEnd     *time.Time        `json:"sale_end,omitempty"`
+	Status      ProductStatus     `json:"status,omitempty"` // defaults to published
+	PublishAt   *time.Time        `json:"publish_at,omitempty"`
+	MinOrderQty int               `json:"min_order_qty,omitempty"`
+	MaxOrderQty int               `json:"max_order_qty,omitempty"`
+	WeightKg    float64           `json:"weight_kg,omitempty"`
+	// AllowBackorder accepts orders for more units than are in stock, including pre-orders before any arrive
+	AllowBackorder bool `json:"allow_backorder,omitempty"`
+	// AllowDuplicate lets an admin create a product whose name closely matches an existing one
+	AllowDuplicate bool `json:"allow_duplicate,omitempty"`
 }
 
 // UpdateProductRequest represents the request payload for updating a product
 type UpdateProductRequest struct {
-	Name        *string  `json:"name,omitempty"`
-	Description *string  `json:"description,omitempty"`
-	Price       *float64 `json:"price,omitempty"`
-	Category    *string  `json:"category,omitempty"`
-	Stock       *int     `json:"stock,omitempty"`
-	ImageURL    *string  `json:"image_url,omitempty"`
+	Name        *string            `json:"name,omitempty"`
+	SKU         *string            `json:"sku,omitempty"`
+	Kind        *ProductKind       `json:"kind,omitempty"`
+	Description *string            `json:"description,omitempty"`
+	Price       *float64           `json:"price,omitempty"`
+	Currency    *string            `json:"currency,omitempty"`
+	CategoryID  *string            `json:"category_id,omitempty"`
+	Category    *string            `json:"category,omitempty"`
+	Stock       *int               `json:"stock,omitempty"`
+	ImageURL    *string            `json:"image_url,omitempty"`
+	Tags        *[]string          `json:"tags,omitempty"`       // replaces all tags when present; send [] to clear
+	Attributes  *map[string]string `json:"attributes,omitempty"` // replaces all attributes when present; send {} to clear
+	// SalePrice replaces the whole sale, including its window, when present; send 0 to end the sale
+	SalePrice *float64   `json:"sale_price,omitempty"`
+	SaleStart *time.Time `json:"sale_start,omitempty"`
+	SaleEnd   *time.Time `json:"sale_end,omitempty"`
+	// Order quantity limits; send 0 to remove a limit
+	MinOrderQty *int     `json:"min_order_qty,omitempty"`
+	MaxOrderQty *int     `json:"max_order_qty,omitempty"`
+	WeightKg    *float64 `json:"weight_kg,omitempty"` // send 0 to clear
+	// AllowBackorder turns backorders on or off; orders already backordered are still filled
+	AllowBackorder *bool `json:"allow_backorder,omitempty"`
+}
+
+// UpdateStockRequest sets stock to an absolute value or adjusts it by a delta; exactly one must be given.
+// Prefer delta under concurrency, since it is applied atomically against the current level.
+type UpdateStockRequest struct {
+	Stock *int   `json:"stock,omitempty"`
+	Delta *int   `json:"delta,omitempty"`
+	Note  string `json:"note,omitempty"` // recorded in the stock history
 }
 
-// ProductFilter represents filtering options for product queries
+// ProductFilter represents filtering, sorting, and pagination options for product queries.
+// A zero Limit returns every match; Cursor takes precedence over Page when both are set.
 type ProductFilter struct {
-	Category  string  `json:"category,omitempty"`
-	MinPrice  float64 `json:"min_price,omitempty"`
-	MaxPrice  float64 `json:"max_price,omitempty"`
-	InStock   bool    `json:"in_stock,omitempty"`
+	Category    string            `json:"category,omitempty"`
+	CategoryIDs []string          `json:"category_ids,omitempty"` // takes precedence over Category
+	MinPrice    float64           `json:"min_price,omitempty"`
+	MaxPrice    float64           `json:"max_price,omitempty"`
+	InStock     bool              `json:"in_stock,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`       // products must carry every listed tag
+	Attributes  map[string]string `json:"attributes,omitempty"` // products must match every listed attribute
+	Sort        string            `json:"sort,omitempty"`
+	Order       string            `json:"order,omitempty"`
+	Page        int               `json:"page,omitempty"`
+	Limit       int               `json:"limit,omitempty"`
+	Cursor      string            `json:"cursor,omitempty"`
+	Status      ProductStatus     `json:"status,omitempty"` // matches the status as of now; empty matches every status
 }
 
 // NewProduct creates a new product with generated ID and timestamps
 func NewProduct(name, description, category string, price float64, stock int, imageURL string) *Product {
 	now := time.Now()
-	return &Product{
+	product := &Product{
 		ID:          uuid.New().String(),
 		Name:        name,
+		Kind:        ProductKindPhysical,
 		Description: description,
 		Price:       price,
+		Currency:    DefaultCurrency,
+		CategoryID:  Slugify(category),
 		Category:    category,
+		Status:      ProductStatusPublished,
 		Stock:       stock,
-		ImageURL:    imageURL,
+		Images:      []ProductImage{},
+		Tags:        []string{},
+		Attributes:  map[string]string{},
+		Inventory:   []InventoryLevel{},
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
+	product.SetPrimaryImageURL(imageURL)
+	product.SetWarehouseQuantity(DefaultWarehouseID, stock)
+	return product
+}
+
+// Clone returns a deep copy of the product
+func (p *Product) Clone() *Product {
+	clone := *p
+	clone.Images = append([]ProductImage{}, p.Images...)
+	clone.Tags = append([]string{}, p.Tags...)
+	clone.Attributes = make(map[string]string, len(p.Attributes))
+	for key, value := range p.Attributes {
+		clone.Attributes[key] = value
+	}
+	clone.Inventory = append([]InventoryLevel{}, p.Inventory...)
+	clone.SalePrice = copyFloat(p.SalePrice)
+	clone.SaleStart = copyTime(p.SaleStart)
+	clone.SaleEnd = copyTime(p.SaleEnd)
+	clone.PublishAt = copyTime(p.PublishAt)
+	return &clone
+}
+
+// HasTags reports whether the product carries every one of the given (normalized) tags
+func (p *Product) HasTags(tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, own := range p.Tags {
+			if own == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// SetPrimaryImageURL replaces the primary image's URL, adding an image if there is none.
+// An empty URL removes the primary image. This backs the legacy image_url field.
+func (p *Product) SetPrimaryImageURL(url string) {
+	switch {
+	case url == "" && len(p.Images) > 0:
+		p.Images = p.Images[1:]
+	case url == "":
+	case len(p.Images) > 0:
+		// The new URL replaces any uploaded file, so its upload details no longer apply
+		p.Images[0] = ProductImage{ID: p.Images[0].ID, URL: url, AltText: p.Images[0].AltText}
+	default:
+		p.Images = append(p.Images, ProductImage{ID: uuid.New().String(), URL: url})
+	}
+	p.syncImageURL()
+}
+
+// AddImage inserts an image at position (clamped to the gallery size) and returns it
+func (p *Product) AddImage(url, altText string, position int) ProductImage {
+	return p.InsertImage(ProductImage{ID: uuid.New().String(), URL: url, AltText: altText}, position)
+}
+
+// InsertImage inserts a fully described image at position (clamped to the gallery size) and returns it
+func (p *Product) InsertImage(image ProductImage, position int) ProductImage {
+	if position < 0 || position > len(p.Images) {
+		position = len(p.Images)
+	}
+	p.Images = append(p.Images, ProductImage{})
+	copy(p.Images[position+1:], p.Images[position:])
+	p.Images[position] = image
+	p.syncImageURL()
+	return image
+}
+
+// Image looks up an image by ID
+func (p *Product) Image(imageID string) (ProductImage, bool) {
+	for _, image := range p.Images {
+		if image.ID == imageID {
+			return image, true
+		}
+	}
+	return ProductImage{}, false
 }
 
-// IsInStock checks if the product has available stock
+// RemoveImage deletes an image by ID and reports whether it existed
+func (p *Product) RemoveImage(imageID string) bool {
+	for i, image := range p.Images {
+		if image.ID == imageID {
+			p.Images = append(p.Images[:i], p.Images[i+1:]...)
+			p.syncImageURL()
+			return true
+		}
+	}
+	return false
+}
+
+// ReorderImages puts the gallery in the given order; imageIDs must list every image exactly once
+func (p *Product) ReorderImages(imageIDs []string) bool {
+	if len(imageIDs) != len(p.Images) {
+		return false
+	}
+
+	byID := make(map[string]ProductImage, len(p.Images))
+	for _, image := range p.Images {
+		byID[image.ID] = image
+	}
+
+	reordered := make([]ProductImage, 0, len(imageIDs))
+	for _, id := range imageIDs {
+		image, ok := byID[id]
+		if !ok {
+			return false
+		}
+		reordered = append(reordered, image)
+		delete(byID, id)
+	}
+
+	p.Images = reordered
+	p.syncImageURL()
+	return true
+}
+
+// syncImageURL mirrors the primary image into ImageURL
+func (p *Product) syncImageURL() {
+	p.ImageURL = ""
+	if len(p.Images) > 0 {
+		p.ImageURL = p.Images[0].URL
+	}
+	p.UpdatedAt = time.Now()
+}
+
+// IsInStock checks if the product has available stock. Digital products never run out.
 func (p *Product) IsInStock() bool {
-	return p.Stock > 0
+	return p.IsDigital() || p.Stock > 0
 }
 
 // ReduceStock reduces the product stock by the specified quantity
@@ -78,9 +314,4 @@ func (p *Product) ReduceStock(quantity int) bool {
 }
 
 // Response represents a standard API response
-type Response struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
-}
+type Response = api.Response[PageInfo]
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ProductStatus controls whether a product is visible to shoppers
+type ProductStatus string
+
+// Product visibility states. Scheduled products become published once PublishAt passes.
+const (
+	ProductStatusDraft     ProductStatus = "draft"
+	ProductStatusPublished ProductStatus = "published"
+	ProductStatusScheduled ProductStatus = "scheduled"
+)
+
+// Visibility errors
+var (
+	ErrInvalidProductStatus = errors.New("status must be draft, published, or scheduled")
+	ErrInvalidPublishAt     = errors.New("scheduled products need a publish_at in the future")
+)
+
+// UpdateVisibilityRequest moves a product between draft, published, and scheduled
+type UpdateVisibilityRequest struct {
+	Status    ProductStatus `json:"status" validate:"required"`
+	PublishAt *time.Time    `json:"publish_at,omitempty"` // required when scheduling
+}
+
+// IsValidProductStatus checks if a status is one of the known visibility states
+func IsValidProductStatus(status ProductStatus) bool {
+	switch status {
+	case ProductStatusDraft, ProductStatusPublished, ProductStatusScheduled:
+		return true
+	}
+	return false
+}
+
+// SetVisibility changes the product's status. PublishAt is kept only for scheduled products.
+func (p *Product) SetVisibility(status ProductStatus, publishAt *time.Time, now time.Time) error {
+	if !IsValidProductStatus(status) {
+		return ErrInvalidProductStatus
+	}
+	if status == ProductStatusScheduled {
+		if publishAt == nil || !publishAt.After(now) {
+			return ErrInvalidPublishAt
+		}
+		p.PublishAt = copyTime(publishAt)
+	} else {
+		p.PublishAt = nil
+	}
+	p.Status = status
+	p.UpdatedAt = now
+	return nil
+}
+
+// StatusAt returns the product's status at the given time, treating a scheduled
+// product whose publish time has passed as published
+func (p *Product) StatusAt(at time.Time) ProductStatus {
+	if p.Status == ProductStatusScheduled && p.PublishAt != nil && !at.Before(*p.PublishAt) {
+		return ProductStatusPublished
+	}
+	return p.Status
+}
+
+// IsPublished reports whether shoppers can see the product at the given time
+func (p *Product) IsPublished(at time.Time) bool {
+	return p.StatusAt(at) == ProductStatusPublished
+}
+
+// ResolveStatus settles a scheduled product whose publish time has passed into the published state
+func (p *Product) ResolveStatus(at time.Time) {
+	if status := p.StatusAt(at); status != p.Status {
+		p.Status = status
+		p.PublishAt = nil
+	}
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReservationStatus is the lifecycle state of a stock reservation
+type ReservationStatus string
+
+const (
+	// ReservationHeld stock is set aside and returns to the product if the reservation expires
+	ReservationHeld ReservationStatus = "held"
+	// ReservationCommitted stock has been sold and no longer expires
+	ReservationCommitted ReservationStatus = "committed"
+	// ReservationReleased stock has been returned to the product
+	ReservationReleased ReservationStatus = "released"
+	// ReservationExpired stock was returned because the hold timed out
+	ReservationExpired ReservationStatus = "expired"
+)
+
+// Reservation errors
+var (
+	ErrInsufficientStock   = errors.New("insufficient stock")
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrReservationClosed   = errors.New("reservation is no longer active")
+)
+
+// StockReservation holds a quantity of a product for an order during checkout.
+// Reserved units are taken out of the product's stock straight away, so concurrent
+// checkouts cannot oversell.
+type StockReservation struct {
+	ID          string            `json:"id"`
+	ProductID   string            `json:"product_id"`
+	OrderID     string            `json:"order_id,omitempty"`
+	Quantity    int               `json:"quantity"`
+	Allocations []InventoryLevel  `json:"allocations"` // warehouses the reserved units were taken from
+	Status      ReservationStatus `json:"status"`
+	ExpiresAt   time.Time         `json:"expires_at"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// ReserveStockRequest represents the request payload for reserving stock
+type ReserveStockRequest struct {
+	Quantity   int    `json:"quantity" validate:"required,min=1"`
+	OrderID    string `json:"order_id,omitempty"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty"` // defaults to the service's reservation TTL
+}
+
+// ReservationActionRequest identifies a reservation to release or commit
+type ReservationActionRequest struct {
+	ReservationID string `json:"reservation_id" validate:"required"`
+}
+
+// NewStockReservation creates a held reservation expiring after ttl
+func NewStockReservation(productID, orderID string, quantity int, ttl time.Duration) *StockReservation {
+	now := time.Now()
+	return &StockReservation{
+		ID:        uuid.New().String(),
+		ProductID: productID,
+		OrderID:   orderID,
+		Quantity:  quantity,
+		Status:    ReservationHeld,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// IsExpired checks if a held reservation has passed its expiry time
+func (r *StockReservation) IsExpired(now time.Time) bool {
+	return r.Status == ReservationHeld && !now.Before(r.ExpiresAt)
+}
+
+// IsReturned checks if the reservation's units have gone back into stock
+func (r *StockReservation) IsReturned() bool {
+	return r.Status == ReservationReleased || r.Status == ReservationExpired
+}
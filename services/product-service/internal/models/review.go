@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Rating bounds for reviews
+const (
+	MinRating = 1
+	MaxRating = 5
+)
+
+// Review represents a customer's rating and review of a product
+type Review struct {
+	ID               string    `json:"id"`
+	ProductID        string    `json:"product_id"`
+	UserID           string    `json:"user_id"`
+	Rating           int       `json:"rating"`
+	Title            string    `json:"title,omitempty"`
+	Body             string    `json:"body,omitempty"`
+	VerifiedPurchase bool      `json:"verified_purchase"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// CreateReviewRequest represents the request payload for reviewing a product
+type CreateReviewRequest struct {
+	UserID string `json:"user_id" validate:"required"`
+	Rating int    `json:"rating" validate:"required,min=1,max=5"`
+	Title  string `json:"title,omitempty"`
+	Body   string `json:"body,omitempty"`
+}
+
+// NewReview creates a new review with generated ID and timestamp
+func NewReview(productID, userID string, rating int, title, body string) *Review {
+	return &Review{
+		ID:        uuid.New().String(),
+		ProductID: productID,
+		UserID:    userID,
+		Rating:    rating,
+		Title:     title,
+		Body:      body,
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Sale validation errors
+var (
+	ErrInvalidSalePrice  = errors.New("sale price must be positive and below the regular price")
+	ErrInvalidSaleWindow = errors.New("sale end must be after sale start")
+)
+
+// SetSale replaces the product's sale. A nil or zero salePrice removes the sale along with its window.
+func (p *Product) SetSale(salePrice *float64, start, end *time.Time) {
+	if salePrice == nil || *salePrice == 0 {
+		p.SalePrice, p.SaleStart, p.SaleEnd = nil, nil, nil
+		return
+	}
+	p.SalePrice = copyFloat(salePrice)
+	p.SaleStart = copyTime(start)
+	p.SaleEnd = copyTime(end)
+}
+
+// ValidateSale checks the sale against the regular price
+func (p *Product) ValidateSale() error {
+	if p.SalePrice == nil {
+		return nil
+	}
+	if *p.SalePrice <= 0 || *p.SalePrice >= p.Price {
+		return ErrInvalidSalePrice
+	}
+	if p.SaleStart != nil && p.SaleEnd != nil && !p.SaleEnd.After(*p.SaleStart) {
+		return ErrInvalidSaleWindow
+	}
+	return nil
+}
+
+// SaleActive reports whether the sale price applies at the given time
+func (p *Product) SaleActive(at time.Time) bool {
+	if p.SalePrice == nil {
+		return false
+	}
+	if p.SaleStart != nil && at.Before(*p.SaleStart) {
+		return false
+	}
+	if p.SaleEnd != nil && !at.Before(*p.SaleEnd) {
+		return false
+	}
+	return true
+}
+
+// PriceAt returns the price charged at the given time
+func (p *Product) PriceAt(at time.Time) float64 {
+	if p.SaleActive(at) {
+		return *p.SalePrice
+	}
+	return p.Price
+}
+
+// ApplyPricing fills in EffectivePrice and OnSale for the given time
+func (p *Product) ApplyPricing(at time.Time) {
+	p.OnSale = p.SaleActive(at)
+	p.EffectivePrice = p.PriceAt(at)
+}
+
+// copyFloat returns a copy of an optional float
+func copyFloat(value *float64) *float64 {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
+
+// copyTime returns a copy of an optional time
+func copyTime(value *time.Time) *time.Time {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
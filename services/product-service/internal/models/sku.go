@@ -0,0 +1,26 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// MaxSKULength is the longest stock keeping unit accepted
+const MaxSKULength = 64
+
+// ErrInvalidSKU is returned for SKUs with characters other than letters, digits, '-', '_' and '.'
+var ErrInvalidSKU = errors.New("sku must be at most 64 letters, digits, dashes, underscores, or dots")
+
+// NormalizeSKU trims and uppercases a SKU so the same code always compares equal. An empty SKU is allowed.
+func NormalizeSKU(sku string) (string, error) {
+	sku = strings.ToUpper(strings.TrimSpace(sku))
+	if len(sku) > MaxSKULength {
+		return "", ErrInvalidSKU
+	}
+	for _, c := range sku {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return "", ErrInvalidSKU
+		}
+	}
+	return sku, nil
+}
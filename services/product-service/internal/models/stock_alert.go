@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventBackInStock is the type of event sent when a product that sold out has stock again
+const EventBackInStock = "product.back_in_stock"
+
+// EventLowStockDigest is the type of event listing the products running low on stock
+const EventLowStockDigest = "product.low_stock_digest"
+
+// StockSubscription asks for a user to be told when an out-of-stock product is available again
+type StockSubscription struct {
+	ID        string    `json:"id"`
+	ProductID string    `json:"product_id"`
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NotifyMeRequest represents the request payload for subscribing to a back-in-stock alert
+type NotifyMeRequest struct {
+	UserID string `json:"user_id" validate:"required"`
+}
+
+// BackInStockEvent tells the notification layer which users to inform about a restocked product
+type BackInStockEvent struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	ProductID   string    `json:"product_id"`
+	ProductName string    `json:"product_name"`
+	Stock       int       `json:"stock"`
+	UserIDs     []string  `json:"user_ids"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+// NewStockSubscription creates a subscription with generated ID and timestamp
+func NewStockSubscription(productID, userID string) *StockSubscription {
+	return &StockSubscription{
+		ID:        uuid.New().String(),
+		ProductID: productID,
+		UserID:    userID,
+		CreatedAt: time.Now(),
+	}
+}
+
+// NewBackInStockEvent creates the event announcing a product's restock to its subscribers
+func NewBackInStockEvent(product *Product, subscriptions []*StockSubscription) *BackInStockEvent {
+	userIDs := make([]string, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		userIDs = append(userIDs, subscription.UserID)
+	}
+	return &BackInStockEvent{
+		ID:          uuid.New().String(),
+		Type:        EventBackInStock,
+		ProductID:   product.ID,
+		ProductName: product.Name,
+		Stock:       product.Stock,
+		UserIDs:     userIDs,
+		OccurredAt:  time.Now(),
+	}
+}
+
+// LowStockDigest tells the notification layer which products are running low, so staff can reorder
+type LowStockDigest struct {
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	Threshold  int               `json:"threshold"` // products with this many units or fewer are listed
+	Products   []LowStockProduct `json:"products"`
+	OccurredAt time.Time         `json:"occurred_at"`
+}
+
+// LowStockProduct is a product listed in a low-stock digest
+type LowStockProduct struct {
+	ProductID string `json:"product_id"`
+	Name      string `json:"name"`
+	SKU       string `json:"sku,omitempty"`
+	Stock     int    `json:"stock"`
+}
+
+// NewLowStockDigest creates the digest listing products, which are at or below threshold
+func NewLowStockDigest(threshold int, products []*Product) *LowStockDigest {
+	listed := make([]LowStockProduct, 0, len(products))
+	for _, product := range products {
+		listed = append(listed, LowStockProduct{ProductID: product.ID, Name: product.Name, SKU: product.SKU, Stock: product.Stock})
+	}
+	return &LowStockDigest{
+		ID:         uuid.New().String(),
+		Type:       EventLowStockDigest,
+		Threshold:  threshold,
+		Products:   listed,
+		OccurredAt: time.Now(),
+	}
+}
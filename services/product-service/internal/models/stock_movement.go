@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StockReason classifies why a product's stock changed
+type StockReason string
+
+const (
+	StockReasonInitial            StockReason = "initial"
+	StockReasonManualSet          StockReason = "manual_set"
+	StockReasonManualAdjustment   StockReason = "manual_adjustment"
+	StockReasonImport             StockReason = "import"
+	StockReasonTransfer           StockReason = "transfer"
+	StockReasonOrderReserved      StockReason = "order_reserved"
+	StockReasonOrderReleased      StockReason = "order_released"
+	StockReasonReservationExpired StockReason = "reservation_expired"
+)
+
+// SystemActor is recorded for stock changes made by the service itself (seeding, expiry sweeps)
+const SystemActor = "system"
+
+// StockSource describes who is changing stock and why
+type StockSource struct {
+	Actor     string
+	Reason    StockReason
+	Note      string
+	Reference string // related order, reservation, or import ID
+}
+
+// StockMovement is one recorded change to the quantity of a product at a warehouse
+type StockMovement struct {
+	ID          string      `json:"id"`
+	ProductID   string      `json:"product_id"`
+	WarehouseID string      `json:"warehouse_id"`
+	Delta       int         `json:"delta"`
+	StockAfter  int         `json:"stock_after"` // product total across warehouses after the change
+	Reason      StockReason `json:"reason"`
+	Actor       string      `json:"actor"`
+	Note        string      `json:"note,omitempty"`
+	Reference   string      `json:"reference,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// NewStockMovement records a change of delta units at a warehouse
+func NewStockMovement(productID, warehouseID string, delta, stockAfter int, source StockSource) *StockMovement {
+	actor := source.Actor
+	if actor == "" {
+		actor = SystemActor
+	}
+	return &StockMovement{
+		ID:          uuid.New().String(),
+		ProductID:   productID,
+		WarehouseID: warehouseID,
+		Delta:       delta,
+		StockAfter:  stockAfter,
+		Reason:      source.Reason,
+		Actor:       actor,
+		Note:        source.Note,
+		Reference:   source.Reference,
+		CreatedAt:   time.Now(),
+	}
+}
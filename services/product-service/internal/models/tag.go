@@ -0,0 +1,40 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// Tag limits
+const (
+	MaxTagsPerProduct = 20
+	MaxTagLength      = 50
+)
+
+// TagCount is a distinct tag and the number of products carrying it
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// NormalizeTags lowercases and trims tags, dropping blanks and duplicates while keeping the
+// original order. It fails if a tag is too long or there are too many tags.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > MaxTagLength {
+			return nil, errors.New("tags must be at most 50 characters")
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > MaxTagsPerProduct {
+		return nil, errors.New("a product can have at most 20 tags")
+	}
+	return normalized, nil
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// DefaultWarehouseID is the warehouse that receives stock when no location is given
+const DefaultWarehouseID = "main"
+
+// Warehouse is a location that holds inventory. The ID is a slug of the name, e.g. "nairobi-dc".
+type Warehouse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Address   string    `json:"address,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// InventoryLevel is the quantity of a product held at one warehouse
+type InventoryLevel struct {
+	WarehouseID string `json:"warehouse_id"`
+	Quantity    int    `json:"quantity"`
+}
+
+// CreateWarehouseRequest represents the request payload for creating a warehouse
+type CreateWarehouseRequest struct {
+	Name    string `json:"name" validate:"required,min=2"`
+	Slug    string `json:"slug,omitempty"`
+	Address string `json:"address,omitempty"`
+}
+
+// TransferStockRequest moves stock of a product between two warehouses
+type TransferStockRequest struct {
+	FromWarehouseID string `json:"from_warehouse_id" validate:"required"`
+	ToWarehouseID   string `json:"to_warehouse_id" validate:"required"`
+	Quantity        int    `json:"quantity" validate:"required,min=1"`
+	Note            string `json:"note,omitempty"`
+}
+
+// NewWarehouse creates a new warehouse whose ID is the slug of its name
+func NewWarehouse(name, address string) *Warehouse {
+	now := time.Now()
+	return &Warehouse{
+		ID:        Slugify(name),
+		Name:      name,
+		Address:   address,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// WarehouseQuantity returns how much of the product is held at a warehouse
+func (p *Product) WarehouseQuantity(warehouseID string) int {
+	for _, level := range p.Inventory {
+		if level.WarehouseID == warehouseID {
+			return level.Quantity
+		}
+	}
+	return 0
+}
+
+// SetWarehouseQuantity sets the quantity held at a warehouse and recomputes Stock as the total
+// across warehouses. Warehouses left with nothing are dropped from the inventory.
+func (p *Product) SetWarehouseQuantity(warehouseID string, quantity int) {
+	levels := make([]InventoryLevel, 0, len(p.Inventory)+1)
+	for _, level := range p.Inventory {
+		if level.WarehouseID != warehouseID {
+			levels = append(levels, level)
+		}
+	}
+	if quantity > 0 {
+		levels = append(levels, InventoryLevel{WarehouseID: warehouseID, Quantity: quantity})
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i].WarehouseID < levels[j].WarehouseID
+	})
+	p.Inventory = levels
+	p.syncStock()
+}
+
+// TakeStock removes quantity from the product's warehouses, drawing from the best-stocked
+// warehouse first, and returns where it was taken from. The caller must check Stock is sufficient.
+func (p *Product) TakeStock(quantity int) []InventoryLevel {
+	levels := append([]InventoryLevel{}, p.Inventory...)
+	sort.SliceStable(levels, func(i, j int) bool {
+		return levels[i].Quantity > levels[j].Quantity
+	})
+
+	var taken []InventoryLevel
+	for _, level := range levels {
+		if quantity == 0 {
+			break
+		}
+		take := level.Quantity
+		if take > quantity {
+			take = quantity
+		}
+		p.SetWarehouseQuantity(level.WarehouseID, level.Quantity-take)
+		taken = append(taken, InventoryLevel{WarehouseID: level.WarehouseID, Quantity: take})
+		quantity -= take
+	}
+	return taken
+}
+
+// ReturnStock puts previously taken stock back into the warehouses it came from
+func (p *Product) ReturnStock(levels []InventoryLevel) {
+	for _, level := range levels {
+		p.SetWarehouseQuantity(level.WarehouseID, p.WarehouseQuantity(level.WarehouseID)+level.Quantity)
+	}
+}
+
+// syncStock keeps Stock equal to the total held across all warehouses
+func (p *Product) syncStock() {
+	total := 0
+	for _, level := range p.Inventory {
+		total += level.Quantity
+	}
+	p.Stock = total
+}
@@ -0,0 +1,15 @@
+package models
+
+import "errors"
+
+// ErrInvalidWeight is returned for a negative shipping weight
+var ErrInvalidWeight = errors.New("weight_kg must not be negative")
+
+// SetWeight sets the shipping weight of one unit of the product; 0 means unknown
+func (p *Product) SetWeight(weightKg float64) error {
+	if weightKg < 0 {
+		return ErrInvalidWeight
+	}
+	p.WeightKg = weightKg
+	return nil
+}
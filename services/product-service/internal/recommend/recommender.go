@@ -0,0 +1,104 @@
+package recommend
+
+import (
+	"context"
+	"sort"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+)
+
+// Recommender suggests products related to a given product.
+// CatalogRecommender is the built-in heuristic; a client for a dedicated
+// recommendation service can replace it without touching the handlers.
+type Recommender interface {
+	Related(ctx context.Context, product *models.Product, limit int) ([]*models.Product, error)
+}
+
+// Scores used by the catalog heuristic: sharing a category outweighs a single shared tag
+const (
+	categoryScore  = 2
+	sharedTagScore = 1
+)
+
+// CatalogRecommender relates products by category and tags using the product catalog
+type CatalogRecommender struct {
+	products repository.ProductRepository
+}
+
+// NewCatalogRecommender creates a recommender backed by the product repository
+func NewCatalogRecommender(products repository.ProductRepository) *CatalogRecommender {
+	return &CatalogRecommender{
+		products: products,
+	}
+}
+
+// scoredProduct pairs a candidate with its relevance to the source product
+type scoredProduct struct {
+	product *models.Product
+	score   int
+}
+
+// Related returns up to limit products in the same category or sharing tags with product.
+// Results are ordered by relevance, then in-stock products first, then rating and name.
+func (c *CatalogRecommender) Related(ctx context.Context, product *models.Product, limit int) ([]*models.Product, error) {
+	candidates := []scoredProduct{}
+
+	// Only published products are recommended
+	filter := &models.ProductFilter{Limit: models.MaxPageLimit, Status: models.ProductStatusPublished}
+	for {
+		products, pageInfo, err := c.products.List(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, candidate := range products {
+			if candidate.ID == product.ID {
+				continue
+			}
+			if score := relevance(product, candidate); score > 0 {
+				candidates = append(candidates, scoredProduct{product: candidate, score: score})
+			}
+		}
+		if pageInfo == nil || !pageInfo.HasMore || pageInfo.NextCursor == "" {
+			break
+		}
+		filter.Cursor = pageInfo.NextCursor
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if inStockA, inStockB := a.product.Stock > 0, b.product.Stock > 0; inStockA != inStockB {
+			return inStockA
+		}
+		if a.product.AverageRating != b.product.AverageRating {
+			return a.product.AverageRating > b.product.AverageRating
+		}
+		return a.product.Name < b.product.Name
+	})
+
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	related := make([]*models.Product, 0, len(candidates))
+	for _, candidate := range candidates {
+		related = append(related, candidate.product)
+	}
+	return related, nil
+}
+
+// relevance scores how closely candidate relates to product; zero means unrelated
+func relevance(product, candidate *models.Product) int {
+	score := 0
+	if product.CategoryID != "" && candidate.CategoryID == product.CategoryID {
+		score += categoryScore
+	}
+	for _, tag := range candidate.Tags {
+		if product.HasTags([]string{tag}) {
+			score += sharedTagScore
+		}
+	}
+	return score
+}
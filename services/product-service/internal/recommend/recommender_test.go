@@ -0,0 +1,43 @@
+package recommend
+
+import (
+	"context"
+	"testing"
+	"product-service/internal/models"
+	"product-service/internal/repository"
+)
+
+func TestCatalogRecommender_Related(t *testing.T) {
+	repo := repository.NewInMemoryProductRepository()
+	create := func(name, category string, stock int, tags ...string) *models.Product {
+		product := models.NewProduct(name, "", category, 10, stock, "")
+		product.Tags = tags
+		_ = repo.Create(context.Background(), product)
+		return product
+	}
+
+	source := create("Desk Lamp", "Lighting", 5, "led", "office")
+	create("Floor Lamp", "Lighting", 0, "led")      // category + 1 tag, out of stock
+	create("Ceiling Lamp", "Lighting", 3, "led")    // category + 1 tag
+	create("Office Chair", "Furniture", 2, "office") // 1 tag
+	create("Pendant", "Lighting", 1)                 // category only
+	create("Blender", "Kitchen", 4, "appliance")     // unrelated
+
+	related, err := NewCatalogRecommender(repo).Related(context.Background(), source, 10)
+	if err != nil {
+		t.Fatalf("related failed: %v", err)
+	}
+	expected := []string{"Ceiling Lamp", "Floor Lamp", "Pendant", "Office Chair"}
+	if len(related) != len(expected) {
+		t.Fatalf("expected %d related products got %d", len(expected), len(related))
+	}
+	for i, name := range expected {
+		if related[i].Name != name {
+			t.Errorf("position %d: expected %s got %s", i, name, related[i].Name)
+		}
+	}
+
+	if limited, _ := NewCatalogRecommender(repo).Related(context.Background(), source, 2); len(limited) != 2 {
+		t.Errorf("expected limit to cap results, got %d", len(limited))
+	}
+}
@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"ecommerce/pkg/cache"
+	"product-service/internal/models"
+)
+
+// cacheTimeout bounds each cache call, so a slow Redis costs a read little more than a miss
+const cacheTimeout = 250 * time.Millisecond
+
+// Cache keys. Listings and tag counts embed the generation, which every write bumps, since a change
+// to one product can move it into or out of any of them.
+const (
+	productCacheKey      = "product:"
+	productGenerationKey = "products:generation"
+)
+
+// CachedProductRepository puts a cache in front of another product repository: reads of single
+// products, listings, and tag counts are answered from the cache when they can be and cached for
+// the TTL when they can't. Every write through it invalidates what it changed. Writes made behind
+// its back, and a read racing a write, can leave a stale entry until the TTL runs out, so listings
+// may also show a sale or a scheduled product up to a TTL late. Reservations and stock history
+// always go to the repository. When the cache fails, reads fall through to the repository.
+type CachedProductRepository struct {
+	store ProductRepository
+	cache *cache.Cache
+	ttl   time.Duration
+}
+
+// cachedProductPage is a cached listing
+type cachedProductPage struct {
+	Products []*esProductDocument `json:"products"`
+	Page     *models.PageInfo     `json:"page"`
+}
+
+// NewCachedProductRepository caches reads of store for ttl
+func NewCachedProductRepository(store ProductRepository, cache *cache.Cache, ttl time.Duration) *CachedProductRepository {
+	return &CachedProductRepository{store: store, cache: cache, ttl: ttl}
+}
+
+// GetByID retrieves a product by its ID, priced and published as of now. Cached products are
+// priced again on the way out, so sales start and end on time.
+func (r *CachedProductRepository) GetByID(ctx context.Context, id string) (*models.Product, error) {
+	// Documents keep the storage keys of uploaded images, which plain product JSON leaves out
+	document := &esProductDocument{Product: &models.Product{}}
+	if r.get(ctx, "product", productCacheKey+id, document) {
+		return present(document.product(), time.Now()), nil
+	}
+
+	product, err := r.store.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	r.set(ctx, productCacheKey+id, newProductDocument(product))
+	return product, nil
+}
+
+// List returns products matching the filter, answering repeated queries from the cache
+func (r *CachedProductRepository) List(ctx context.Context, filter *models.ProductFilter) ([]*models.Product, *models.PageInfo, error) {
+	query, err := json.Marshal(filter)
+	if err != nil {
+		return r.store.List(ctx, filter)
+	}
+	sum := sha256.Sum256(query)
+	key, ok := r.generationKey(ctx, "list:" + hex.EncodeToString(sum[:]))
+	if ok {
+		var page cachedProductPage
+		if r.get(ctx, "products", key, &page) {
+			products := make([]*models.Product, len(page.Products))
+			for i, document := range page.Products {
+				products[i] = document.product()
+			}
+			return products, page.Page, nil
+		}
+	}
+
+	products, info, err := r.store.List(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ok {
+		page := cachedProductPage{Products: make([]*esProductDocument, len(products)), Page: info}
+		for i, product := range products {
+			page.Products[i] = newProductDocument(product)
+		}
+		r.set(ctx, key, page)
+	}
+	return products, info, nil
+}
+
+// Search returns the products containing every word of query, answering repeated searches from the cache
+func (r *CachedProductRepository) Search(ctx context.Context, query string, filter *models.ProductFilter) ([]*models.Product, error) {
+	search, err := json.Marshal(struct {
+		Query  string                `json:"query"`
+		Filter *models.ProductFilter `json:"filter"`
+	}{query, filter})
+	if err != nil {
+		return r.store.Search(ctx, query, filter)
+	}
+	sum := sha256.Sum256(search)
+	key, ok := r.generationKey(ctx, "search:" + hex.EncodeToString(sum[:]))
+	if ok {
+		var page cachedProductPage
+		if r.get(ctx, "products", key, &page) {
+			products := make([]*models.Product, len(page.Products))
+			for i, document := range page.Products {
+				products[i] = document.product()
+			}
+			return products, nil
+		}
+	}
+
+	products, err := r.store.Search(ctx, query, filter)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		page := cachedProductPage{Products: make([]*esProductDocument, len(products))}
+		for i, product := range products {
+			page.Products[i] = newProductDocument(product)
+		}
+		r.set(ctx, key, page)
+	}
+	return products, nil
+}
+
+// GetByCategory retrieves all products in a specific category
+func (r *CachedProductRepository) GetByCategory(ctx context.Context, category string) ([]*models.Product, error) {
+	products, _, err := r.List(ctx, &models.ProductFilter{Category: category})
+	return products, err
+}
+
+// TagCounts returns every distinct tag with the number of products carrying it
+func (r *CachedProductRepository) TagCounts(ctx context.Context) ([]models.TagCount, error) {
+	key, ok := r.generationKey(ctx, "tags")
+	if ok {
+		var tags []models.TagCount
+		if r.get(ctx, "tags", key, &tags) {
+			return tags, nil
+		}
+	}
+
+	tags, err := r.store.TagCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		r.set(ctx, key, tags)
+	}
+	return tags, nil
+}
+
+// generationKey prefixes key with the current generation. It reports false when the generation
+// can't be read, in which case nothing should be cached.
+func (r *CachedProductRepository) generationKey(ctx context.Context, key string) (string, bool) {
+	ctx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+
+	generation, err := r.cache.Generation(ctx, productGenerationKey)
+	if err != nil {
+		slog.Warn("Error reading the product cache generation", "error", err)
+		return "", false
+	}
+	return "products:" + strconv.FormatInt(generation, 10) + ":" + key, true
+}
+
+// get reads a cached value, reporting whether there was one
+func (r *CachedProductRepository) get(ctx context.Context, keyspace, key string, out interface{}) bool {
+	ctx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+
+	err := r.cache.Get(ctx, keyspace, key, out)
+	if err != nil && !errors.Is(err, cache.ErrMiss) {
+		slog.Warn("Error reading the product cache", "key", key, "error", err)
+	}
+	return err == nil
+}
+
+// set caches a value for the TTL
+func (r *CachedProductRepository) set(ctx context.Context, key string, value interface{}) {
+	ctx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+
+	if err := r.cache.Set(ctx, key, value, r.ttl); err != nil {
+		slog.Warn("Error writing the product cache", "key", key, "error", err)
+	}
+}
+
+// invalidate drops the cached copies of the given products and every listing and tag count. It
+// goes ahead when ctx is cancelled, since the write it follows may have gone through anyway.
+func (r *CachedProductRepository) invalidate(ctx context.Context, ids ...string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cacheTimeout)
+	defer cancel()
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, productCacheKey+id)
+	}
+	if err := r.cache.Delete(ctx, keys...); err != nil {
+		slog.Warn("Error invalidating cached products", "product_ids", strings.Join(ids, ","), "error", err)
+	}
+	if err := r.cache.Bump(ctx, productGenerationKey); err != nil {
+		slog.Warn("Error invalidating cached product listings", "error", err)
+	}
+}
+
+// Create adds a new product
+func (r *CachedProductRepository) Create(ctx context.Context, product *models.Product) error {
+	if err := r.store.Create(ctx, product); err != nil {
+		return err
+	}
+	r.invalidate(ctx, product.ID)
+	return nil
+}
+
+// Update modifies an existing product
+func (r *CachedProductRepository) Update(ctx context.Context, product *models.Product) error {
+	// Invalidate even on failure, since the write may have gone through before it failed
+	defer r.invalidate(ctx, product.ID)
+	return r.store.Update(ctx, product)
+}
+
+// Delete removes a product
+func (r *CachedProductRepository) Delete(ctx context.Context, id string) error {
+	defer r.invalidate(ctx, id)
+	return r.store.Delete(ctx, id)
+}
+
+// UpdateStock sets the stock held at the default warehouse
+func (r *CachedProductRepository) UpdateStock(ctx context.Context, id string, quantity int, source models.StockSource) error {
+	defer r.invalidate(ctx, id)
+	return r.store.UpdateStock(ctx, id, quantity, source)
+}
+
+// AdjustStock adds delta to a product's stock and returns the new total
+func (r *CachedProductRepository) AdjustStock(ctx context.Context, id string, delta int, source models.StockSource) (int, error) {
+	defer r.invalidate(ctx, id)
+	return r.store.AdjustStock(ctx, id, delta, source)
+}
+
+// SetWarehouseStock sets the quantity of a product held at one warehouse
+func (r *CachedProductRepository) SetWarehouseStock(ctx context.Context, id, warehouseID string, quantity int, source models.StockSource) error {
+	defer r.invalidate(ctx, id)
+	return r.store.SetWarehouseStock(ctx, id, warehouseID, quantity, source)
+}
+
+// AdjustWarehouseStock adds delta to the quantity held at one warehouse and returns the product's new total
+func (r *CachedProductRepository) AdjustWarehouseStock(ctx context.Context, id, warehouseID string, delta int, source models.StockSource) (int, error) {
+	defer r.invalidate(ctx, id)
+	return r.store.AdjustWarehouseStock(ctx, id, warehouseID, delta, source)
+}
+
+// TransferStock moves quantity units of a product from one warehouse to another
+func (r *CachedProductRepository) TransferStock(ctx context.Context, id, fromWarehouseID, toWarehouseID string, quantity int, source models.StockSource) (*models.Product, error) {
+	defer r.invalidate(ctx, id)
+	return r.store.TransferStock(ctx, id, fromWarehouseID, toWarehouseID, quantity, source)
+}
+
+// StockHistory returns up to limit recorded stock changes for a product, newest first
+func (r *CachedProductRepository) StockHistory(ctx context.Context, productID string, limit int) ([]*models.StockMovement, error) {
+	return r.store.StockHistory(ctx, productID, limit)
+}
+
+// UpdateRating stores the review summary for a product
+func (r *CachedProductRepository) UpdateRating(ctx context.Context, id string, average float64, count int) error {
+	defer r.invalidate(ctx, id)
+	return r.store.UpdateRating(ctx, id, average, count)
+}
+
+// Count returns how many products are stored
+func (r *CachedProductRepository) Count(ctx context.Context) (int, error) {
+	return r.store.Count(ctx)
+}
+
+// ReserveStock takes quantity units out of a product's stock and records a held reservation for them
+func (r *CachedProductRepository) ReserveStock(ctx context.Context, productID, orderID string, quantity int, ttl time.Duration, actor string) (*models.StockReservation, error) {
+	defer r.invalidate(ctx, productID)
+	return r.store.ReserveStock(ctx, productID, orderID, quantity, ttl, actor)
+}
+
+// ReleaseReservation returns a reservation's units to stock
+func (r *CachedProductRepository) ReleaseReservation(ctx context.Context, productID, reservationID, actor string) (*models.StockReservation, error) {
+	defer r.invalidate(ctx, productID)
+	return r.store.ReleaseReservation(ctx, productID, reservationID, actor)
+}
+
+// CommitReservation marks held stock as sold so it no longer expires
+func (r *CachedProductRepository) CommitReservation(ctx context.Context, productID, reservationID, actor string) (*models.StockReservation, error) {
+	// A reservation that expired meanwhile is restocked instead of committed
+	defer r.invalidate(ctx, productID)
+	return r.store.CommitReservation(ctx, productID, reservationID, actor)
+}
+
+// ExpireReservations returns the stock of every held reservation that has expired by now. Which
+// products got stock back isn't reported, so their cached copies are left to run out; listings
+// are invalidated when anything expired.
+func (r *CachedProductRepository) ExpireReservations(ctx context.Context, now time.Time) (int, error) {
+	expired, err := r.store.ExpireReservations(ctx, now)
+	if expired > 0 {
+		r.invalidate(ctx)
+	}
+	return expired, err
+}
+
+// OnRestock registers the listener told when a product comes back into stock
+func (r *CachedProductRepository) OnRestock(listener RestockListener) {
+	r.store.OnRestock(listener)
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+	"ecommerce/pkg/cache"
+	"product-service/internal/models"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestCachedProductRepository caches an empty in-memory repository in a throwaway Redis,
+// returning the repository behind the cache too so tests can change it behind the cache's back
+func newTestCachedProductRepository(t *testing.T) (*CachedProductRepository, *InMemoryProductRepository, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	productCache, err := cache.New(context.Background(), "redis://"+server.Addr(), "product-service:")
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	t.Cleanup(func() { productCache.Close() })
+
+	store := &InMemoryProductRepository{
+		products:     make(map[string]*models.Product),
+		reservations: make(map[string]*models.StockReservation),
+		movements:    make(map[string][]*models.StockMovement),
+	}
+	return NewCachedProductRepository(store, productCache, time.Minute), store, server
+}
+
+func TestCachedProductRepository_GetByIDCachesAndInvalidates(t *testing.T) {
+	repo, store, _ := newTestCachedProductRepository(t)
+	product := models.NewProduct("Camera", "", "Electronics", 500, 4, "")
+	product.Images = []models.ProductImage{{ID: "img-1", URL: "/images/img-1", StorageKey: "products/img-1.jpg"}}
+	if err := repo.Create(context.Background(), product); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := repo.GetByID(context.Background(), product.ID); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+
+	// A change made behind the cache's back isn't seen until the entry is invalidated
+	store.UpdateStock(context.Background(), product.ID, 9, models.StockSource{})
+	cached, err := repo.GetByID(context.Background(), product.ID)
+	if err != nil || cached.Stock != 4 {
+		t.Fatalf("expected the cached stock of 4, got %+v, %v", cached, err)
+	}
+	if cached.Images[0].StorageKey != "products/img-1.jpg" {
+		t.Errorf("expected the image storage key to survive caching, got %q", cached.Images[0].StorageKey)
+	}
+
+	if _, err := repo.AdjustStock(context.Background(), product.ID, 1, models.StockSource{}); err != nil {
+		t.Fatalf("adjust: %v", err)
+	}
+	fresh, _ := repo.GetByID(context.Background(), product.ID)
+	if fresh.Stock != 10 {
+		t.Errorf("expected the adjusted stock of 10 after invalidation, got %d", fresh.Stock)
+	}
+
+	if _, err := repo.GetByID(context.Background(), "missing"); err == nil {
+		t.Error("expected a missing product to stay an error")
+	}
+}
+
+func TestCachedProductRepository_WritesInvalidateListings(t *testing.T) {
+	repo, store, _ := newTestCachedProductRepository(t)
+	laptop := models.NewProduct("Laptop", "", "Electronics", 1200, 5, "")
+	laptop.Tags = []string{"sale"}
+	repo.Create(context.Background(), laptop)
+
+	filter := &models.ProductFilter{Category: "Electronics"}
+	if products, _, err := repo.List(context.Background(), filter); err != nil || len(products) != 1 {
+		t.Fatalf("expected one product listed, got %v, %v", products, err)
+	}
+	if tags, err := repo.TagCounts(context.Background()); err != nil || len(tags) != 1 {
+		t.Fatalf("expected one tag, got %v, %v", tags, err)
+	}
+
+	store.Create(context.Background(), models.NewProduct("Phone", "", "Electronics", 800, 3, ""))
+	if products, _, _ := repo.List(context.Background(), filter); len(products) != 1 {
+		t.Errorf("expected the cached listing, got %d products", len(products))
+	}
+
+	tablet := models.NewProduct("Tablet", "", "Electronics", 300, 2, "")
+	tablet.Tags = []string{"new"}
+	repo.Create(context.Background(), tablet)
+	if products, _, _ := repo.List(context.Background(), filter); len(products) != 3 {
+		t.Errorf("expected every product listed after a write, got %d", len(products))
+	}
+	if tags, _ := repo.TagCounts(context.Background()); len(tags) != 2 {
+		t.Errorf("expected the new tag counted after a write, got %v", tags)
+	}
+}
+
+func TestCachedProductRepository_FallsThroughWhenCacheIsDown(t *testing.T) {
+	repo, _, server := newTestCachedProductRepository(t)
+	product := models.NewProduct("Kettle", "", "Appliances", 40, 2, "")
+	repo.Create(context.Background(), product)
+
+	server.Close()
+	if fetched, err := repo.GetByID(context.Background(), product.ID); err != nil || fetched.ID != product.ID {
+		t.Errorf("expected the product from the repository, got %+v, %v", fetched, err)
+	}
+	if products, _, err := repo.List(context.Background(), nil); err != nil || len(products) != 1 {
+		t.Errorf("expected the listing from the repository, got %v, %v", products, err)
+	}
+	if _, err := repo.AdjustStock(context.Background(), product.ID, -1, models.StockSource{}); err != nil {
+		t.Errorf("expected writes to succeed without the cache, got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+	"product-service/internal/models"
+)
+
+// CategoryRepository defines the interface for category data operations
+type CategoryRepository interface {
+	Create(category *models.Category) error
+	GetByID(id string) (*models.Category, error)
+	Update(category *models.Category) error
+	Delete(id string) error
+	List() ([]*models.Category, error)
+	Children(id string) ([]*models.Category, error)
+	Descendants(id string) ([]string, error)
+}
+
+// InMemoryCategoryRepository implements CategoryRepository using in-memory storage
+type InMemoryCategoryRepository struct {
+	categories map[string]*models.Category
+	mutex      sync.RWMutex
+}
+
+// NewInMemoryCategoryRepository creates a new in-memory category repository with the
+// categories used by the sample products
+func NewInMemoryCategoryRepository() *InMemoryCategoryRepository {
+	repo := &InMemoryCategoryRepository{
+		categories: make(map[string]*models.Category),
+	}
+
+	repo.seedData()
+	return repo
+}
+
+// seedData adds the sample categories
+func (r *InMemoryCategoryRepository) seedData() {
+	for _, name := range []string{"Electronics", "Footwear", "Appliances"} {
+		category := models.NewCategory(name, "", "")
+		r.categories[category.ID] = category
+	}
+}
+
+// Create adds a new category; the parent, if any, must exist
+func (r *InMemoryCategoryRepository) Create(category *models.Category) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if category.ID == "" {
+		return errors.New("category slug cannot be empty")
+	}
+	if _, exists := r.categories[category.ID]; exists {
+		return errors.New("category with this slug already exists")
+	}
+	if category.ParentID != "" {
+		if _, exists := r.categories[category.ParentID]; !exists {
+			return errors.New("parent category not found")
+		}
+	}
+
+	categoryCopy := *category
+	r.categories[category.ID] = &categoryCopy
+	return nil
+}
+
+// GetByID retrieves a category by its ID
+func (r *InMemoryCategoryRepository) GetByID(id string) (*models.Category, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	category, exists := r.categories[id]
+	if !exists {
+		return nil, errors.New("category not found")
+	}
+
+	categoryCopy := *category
+	return &categoryCopy, nil
+}
+
+// Update modifies an existing category, rejecting parents that would create a cycle
+func (r *InMemoryCategoryRepository) Update(category *models.Category) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.categories[category.ID]; !exists {
+		return errors.New("category not found")
+	}
+
+	// Walk up from the new parent; reaching the category itself means a cycle
+	for parentID := category.ParentID; parentID != ""; {
+		if parentID == category.ID {
+			return errors.New("category cannot be its own ancestor")
+		}
+		parent, exists := r.categories[parentID]
+		if !exists {
+			return errors.New("parent category not found")
+		}
+		parentID = parent.ParentID
+	}
+
+	category.UpdatedAt = time.Now()
+	categoryCopy := *category
+	r.categories[category.ID] = &categoryCopy
+	return nil
+}
+
+// Delete removes a category that has no subcategories
+func (r *InMemoryCategoryRepository) Delete(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.categories[id]; !exists {
+		return errors.New("category not found")
+	}
+	for _, category := range r.categories {
+		if category.ParentID == id {
+			return errors.New("category has subcategories")
+		}
+	}
+
+	delete(r.categories, id)
+	return nil
+}
+
+// List returns all categories sorted by name
+func (r *InMemoryCategoryRepository) List() ([]*models.Category, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	categories := make([]*models.Category, 0, len(r.categories))
+	for _, category := range r.categories {
+		categoryCopy := *category
+		categories = append(categories, &categoryCopy)
+	}
+
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Name < categories[j].Name
+	})
+	return categories, nil
+}
+
+// Children returns the direct subcategories of a category sorted by name
+func (r *InMemoryCategoryRepository) Children(id string) ([]*models.Category, error) {
+	categories, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+
+	children := make([]*models.Category, 0)
+	for _, category := range categories {
+		if category.ParentID == id {
+			children = append(children, category)
+		}
+	}
+	return children, nil
+}
+
+// Descendants returns the IDs of a category and every category beneath it
+func (r *InMemoryCategoryRepository) Descendants(id string) ([]string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if _, exists := r.categories[id]; !exists {
+		return nil, errors.New("category not found")
+	}
+
+	ids := []string{id}
+	for i := 0; i < len(ids); i++ {
+		for _, category := range r.categories {
+			if category.ParentID == ids[i] {
+				ids = append(ids, category.ID)
+			}
+		}
+	}
+	return ids, nil
+}
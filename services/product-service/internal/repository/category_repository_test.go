@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"product-service/internal/models"
+	"testing"
+)
+
+func TestInMemoryCategoryRepository_Hierarchy(t *testing.T) {
+	repo := NewInMemoryCategoryRepository()
+	phones := models.NewCategory("Phones", "", "electronics")
+	if err := repo.Create(phones); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if err := repo.Create(models.NewCategory("Smartphones", "", phones.ID)); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if err := repo.Create(models.NewCategory("Orphan", "", "missing")); err == nil {
+		t.Error("expected error for unknown parent")
+	}
+	if err := repo.Create(models.NewCategory("Phones", "", "")); err == nil {
+		t.Error("expected duplicate slug error")
+	}
+
+	ids, err := repo.Descendants("electronics")
+	if err != nil || len(ids) != 3 {
+		t.Fatalf("expected electronics with 2 descendants, got %v (%v)", ids, err)
+	}
+
+	// Moving a category under its own descendant would create a cycle
+	electronics, _ := repo.GetByID("electronics")
+	electronics.ParentID = "smartphones"
+	if err := repo.Update(electronics); err == nil {
+		t.Error("expected cycle to be rejected")
+	}
+
+	if err := repo.Delete(phones.ID); err == nil {
+		t.Error("expected delete of category with subcategories to fail")
+	}
+	if err := repo.Delete("smartphones"); err != nil {
+		t.Errorf("expected leaf delete to succeed: %v", err)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	cases := map[string]string{
+		"Electronics":       "electronics",
+		"  Running Shoes  ": "running-shoes",
+		"Home & Kitchen":    "home-kitchen",
+		"MacBook Pro 16\"":  "macbook-pro-16",
+	}
+	for in, want := range cases {
+		if got := models.Slugify(in); got != want {
+			t.Errorf("Slugify(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ElasticsearchConfig holds the connection settings for an Elasticsearch or OpenSearch cluster
+type ElasticsearchConfig struct {
+	URL         string // e.g. http://localhost:9200
+	Username    string // optional basic auth credentials
+	Password    string
+	IndexPrefix string // indices are named <prefix>-products, <prefix>-reservations, <prefix>-stock-movements
+	Timeout     time.Duration
+}
+
+// esObject is a JSON object in a request to the cluster
+type esObject map[string]interface{}
+
+// esError is an error response from the cluster
+type esError struct {
+	Status int
+	Type   string
+	Reason string
+}
+
+func (e *esError) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("elasticsearch: status %d", e.Status)
+	}
+	return fmt.Sprintf("elasticsearch: status %d: %s: %s", e.Status, e.Type, e.Reason)
+}
+
+// hasStatus reports whether err is a cluster response with the given HTTP status
+func hasStatus(err error, status int) bool {
+	var clusterErr *esError
+	return errors.As(err, &clusterErr) && clusterErr.Status == status
+}
+
+// esHit is a document returned by a get or search request
+type esHit struct {
+	ID          string            `json:"_id"`
+	Found       bool              `json:"found"`
+	SeqNo       int64             `json:"_seq_no"`
+	PrimaryTerm int64             `json:"_primary_term"`
+	Source      json.RawMessage   `json:"_source"`
+	Sort        []json.RawMessage `json:"sort"`
+}
+
+// version is the sequence number and primary term a write must match for optimistic concurrency control
+func (h *esHit) version() *esVersion {
+	return &esVersion{SeqNo: h.SeqNo, PrimaryTerm: h.PrimaryTerm}
+}
+
+// esVersion identifies the revision of a document that was read
+type esVersion struct {
+	SeqNo       int64
+	PrimaryTerm int64
+}
+
+// esSearchResponse is the part of a search response the repository reads
+type esSearchResponse struct {
+	Hits struct {
+		Total struct {
+			Value int `json:"value"`
+		} `json:"total"`
+		Hits []esHit `json:"hits"`
+	} `json:"hits"`
+	Aggregations json.RawMessage `json:"aggregations"`
+}
+
+// esClient talks to the cluster's REST API
+type esClient struct {
+	baseURL    string
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+// newESClient creates a client for the cluster in config
+func newESClient(config ElasticsearchConfig) *esClient {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &esClient{
+		baseURL:    strings.TrimRight(config.URL, "/"),
+		username:   config.Username,
+		password:   config.Password,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// do sends a request and decodes the JSON response into out when it is not nil, giving up once
+// ctx is done. A []byte body is sent as-is as newline-delimited JSON; anything else is encoded as
+// JSON. Responses outside the 2xx range are returned as *esError.
+func (c *esClient) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	contentType := "application/json"
+	switch payload := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(payload)
+		contentType = "application/x-ndjson"
+	default:
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if reader != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		clusterErr := &esError{Status: resp.StatusCode}
+		var failure struct {
+			Error struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		}
+		// HEAD responses and some 404s have no body, so a decode failure still leaves the status
+		if json.NewDecoder(resp.Body).Decode(&failure) == nil {
+			clusterErr.Type = failure.Error.Type
+			clusterErr.Reason = failure.Error.Reason
+		}
+		return clusterErr
+	}
+
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ensureIndex creates an index with the given mappings unless it already exists
+func (c *esClient) ensureIndex(ctx context.Context, index string, mappings esObject) error {
+	err := c.do(ctx, http.MethodHead, "/"+index, nil, nil)
+	if err == nil {
+		return nil
+	}
+	if !hasStatus(err, http.StatusNotFound) {
+		return err
+	}
+
+	err = c.do(ctx, http.MethodPut, "/"+index, esObject{"mappings": mappings}, nil)
+	// Another instance may have created it in the meantime
+	var clusterErr *esError
+	if errors.As(err, &clusterErr) && clusterErr.Type == "resource_already_exists_exception" {
+		return nil
+	}
+	return err
+}
+
+// get fetches a document by ID, returning nil when it does not exist
+func (c *esClient) get(ctx context.Context, index, id string) (*esHit, error) {
+	var hit esHit
+	err := c.do(ctx, http.MethodGet, "/"+index+"/_doc/"+id, nil, &hit)
+	if hasStatus(err, http.StatusNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !hit.Found {
+		return nil, nil
+	}
+	return &hit, nil
+}
+
+// put writes a document and waits for it to become searchable. A nil version creates the
+// document and fails with 409 if it exists; otherwise the write fails with 409 unless the
+// stored document is still at that version.
+func (c *esClient) put(ctx context.Context, index, id string, document interface{}, version *esVersion) error {
+	path := "/" + index + "/_create/" + id + "?refresh=wait_for"
+	if version != nil {
+		path = fmt.Sprintf("/%s/_doc/%s?refresh=wait_for&if_seq_no=%d&if_primary_term=%d", index, id, version.SeqNo, version.PrimaryTerm)
+	}
+	return c.do(ctx, http.MethodPut, path, document, nil)
+}
+
+// search runs a search request against an index
+func (c *esClient) search(ctx context.Context, index string, body esObject) (*esSearchResponse, error) {
+	var result esSearchResponse
+	if err := c.do(ctx, http.MethodPost, "/"+index+"/_search", body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// bulkIndex writes several documents to an index in one request, keyed by document ID
+func (c *esClient) bulkIndex(ctx context.Context, index string, documents map[string]interface{}) error {
+	var payload bytes.Buffer
+	encoder := json.NewEncoder(&payload)
+	for id, document := range documents {
+		if err := encoder.Encode(esObject{"index": esObject{"_index": index, "_id": id}}); err != nil {
+			return err
+		}
+		if err := encoder.Encode(document); err != nil {
+			return err
+		}
+	}
+
+	var result struct {
+		Errors bool `json:"errors"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/_bulk?refresh=wait_for", payload.Bytes(), &result); err != nil {
+		return err
+	}
+	if result.Errors {
+		return fmt.Errorf("elasticsearch: bulk write to %s partially failed", index)
+	}
+	return nil
+}
@@ -0,0 +1,851 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"product-service/internal/models"
+)
+
+// Tuning for the Elasticsearch-backed product repository
+const (
+	esWriteRetries = 5    // attempts at a read-modify-write before giving up on a busy document
+	esBatchSize    = 500  // documents fetched per request when a listing has no limit
+	esMaxTags      = 1000 // distinct tags returned by TagCounts
+	esMaxExpiries  = 1000 // reservations expired per sweep; the rest wait for the next one
+)
+
+// Elasticsearch repository errors
+var (
+	errProductNotFound  = errors.New("product not found")
+	errConcurrentUpdate = errors.New("product is being updated concurrently, please retry")
+)
+
+// effectivePriceScript works out the price charged at params.now, mirroring Product.PriceAt
+const effectivePriceScript = `double price = doc['price'].value;
+if (doc['sale_price'].size() > 0) {
+  long now = params.now;
+  boolean started = doc['sale_start'].size() == 0 || doc['sale_start'].value.toInstant().toEpochMilli() <= now;
+  boolean ended = doc['sale_end'].size() > 0 && doc['sale_end'].value.toInstant().toEpochMilli() <= now;
+  if (started && !ended) { price = doc['sale_price'].value; }
+}
+return price;`
+
+// Index mappings. Only fields that are searched, filtered, or sorted on are indexed; the rest of
+// each document is kept in _source. Attributes are indexed as "name=value" pairs so arbitrary
+// attribute names don't grow the mapping.
+var (
+	esProductMappings = esObject{
+		"dynamic": false,
+		"properties": esObject{
+			"id":              esObject{"type": "keyword"},
+			"name":            esObject{"type": "text"},
+			"name_key":        esObject{"type": "keyword"},
+			"sku":             esObject{"type": "keyword"},
+			"kind":            esObject{"type": "keyword"},
+			"description":     esObject{"type": "text"},
+			"price":           esObject{"type": "double"},
+			"currency":        esObject{"type": "keyword"},
+			"sale_price":      esObject{"type": "double"},
+			"sale_start":      esObject{"type": "date"},
+			"sale_end":        esObject{"type": "date"},
+			"category":        esObject{"type": "text"},
+			"category_id":     esObject{"type": "keyword"},
+			"category_key":    esObject{"type": "keyword"},
+			"status":          esObject{"type": "keyword"},
+			"publish_at":      esObject{"type": "date"},
+			"stock":           esObject{"type": "integer"},
+			"tags":            esObject{"type": "keyword"},
+			"attribute_pairs": esObject{"type": "keyword"},
+			"average_rating":  esObject{"type": "float"},
+			"created_at":      esObject{"type": "date"},
+			"updated_at":      esObject{"type": "date"},
+		},
+	}
+	esReservationMappings = esObject{
+		"dynamic": false,
+		"properties": esObject{
+			"id":         esObject{"type": "keyword"},
+			"product_id": esObject{"type": "keyword"},
+			"order_id":   esObject{"type": "keyword"},
+			"status":     esObject{"type": "keyword"},
+			"expires_at": esObject{"type": "date"},
+			"updated_at": esObject{"type": "date"},
+		},
+	}
+	esMovementMappings = esObject{
+		"dynamic": false,
+		"properties": esObject{
+			"product_id":   esObject{"type": "keyword"},
+			"warehouse_id": esObject{"type": "keyword"},
+			"reason":       esObject{"type": "keyword"},
+			"reference":    esObject{"type": "keyword"},
+			"created_at":   esObject{"type": "date_nanos"}, // history is ordered by this, so keep full precision
+		},
+	}
+)
+
+// ElasticsearchProductRepository implements ProductRepository on an Elasticsearch or OpenSearch
+// cluster so listings, filters, and tag facets are answered by the search engine rather than a scan.
+// Stock changes are read-modify-write cycles guarded by optimistic concurrency control, so
+// concurrent adjustments from several service instances cannot overwrite each other.
+type ElasticsearchProductRepository struct {
+	client           *esClient
+	productIndex     string
+	reservationIndex string
+	movementIndex    string
+	onRestock        RestockListener
+	mutex            sync.RWMutex // guards onRestock
+}
+
+// esProductDocument is how a product is stored. Next to the product it keeps lowercased copies
+// of the fields matched case-insensitively, and the storage keys of uploaded images, which the
+// product's JSON form leaves out. The PostgreSQL and MongoDB stores keep the same document.
+type esProductDocument struct {
+	*models.Product `bson:",inline"`
+	NameKey        string            `json:"name_key"`
+	CategoryKey    string            `json:"category_key"`
+	AttributePairs []string          `json:"attribute_pairs"`
+	ImageKeys      map[string]string `json:"image_keys,omitempty"` // image ID to storage key
+}
+
+// NewElasticsearchProductRepository connects to the cluster and creates the product,
+// reservation, and stock movement indices if they don't exist yet
+func NewElasticsearchProductRepository(ctx context.Context, config ElasticsearchConfig) (*ElasticsearchProductRepository, error) {
+	if config.URL == "" {
+		return nil, errors.New("elasticsearch URL is required")
+	}
+	prefix := config.IndexPrefix
+	if prefix == "" {
+		prefix = "products"
+	}
+
+	repo := &ElasticsearchProductRepository{
+		client:           newESClient(config),
+		productIndex:     prefix + "-products",
+		reservationIndex: prefix + "-reservations",
+		movementIndex:    prefix + "-stock-movements",
+	}
+
+	indices := map[string]esObject{
+		repo.productIndex:     esProductMappings,
+		repo.reservationIndex: esReservationMappings,
+		repo.movementIndex:    esMovementMappings,
+	}
+	for index, mappings := range indices {
+		if err := repo.client.ensureIndex(ctx, index, mappings); err != nil {
+			return nil, fmt.Errorf("creating index %s: %w", index, err)
+		}
+	}
+	return repo, nil
+}
+
+// newProductDocument prepares a product for indexing
+func newProductDocument(product *models.Product) *esProductDocument {
+	document := &esProductDocument{
+		Product:        product,
+		NameKey:        strings.ToLower(product.Name),
+		CategoryKey:    strings.ToLower(product.Category),
+		AttributePairs: make([]string, 0, len(product.Attributes)),
+	}
+	for key, value := range product.Attributes {
+		document.AttributePairs = append(document.AttributePairs, attributePair(key, value))
+	}
+	for _, image := range product.Images {
+		if image.StorageKey == "" {
+			continue
+		}
+		if document.ImageKeys == nil {
+			document.ImageKeys = make(map[string]string)
+		}
+		document.ImageKeys[image.ID] = image.StorageKey
+	}
+	return document
+}
+
+// decodeProduct rebuilds a product from its stored document
+func decodeProduct(source json.RawMessage) (*models.Product, error) {
+	document := esProductDocument{Product: &models.Product{}}
+	if err := json.Unmarshal(source, &document); err != nil {
+		return nil, err
+	}
+	return document.product(), nil
+}
+
+// product returns the stored product with the storage keys of its uploaded images put back
+func (d *esProductDocument) product() *models.Product {
+	for i := range d.Product.Images {
+		d.Product.Images[i].StorageKey = d.ImageKeys[d.Product.Images[i].ID]
+	}
+	return d.Product
+}
+
+// attributePair is the indexed form of one attribute, matched case-insensitively
+func attributePair(key, value string) string {
+	return strings.ToLower(key + "=" + value)
+}
+
+// Create adds a new product, rejecting names already in use regardless of case
+func (r *ElasticsearchProductRepository) Create(ctx context.Context, product *models.Product) error {
+	existing, err := r.client.search(ctx, r.productIndex, esObject{
+		"size":  0,
+		"query": esObject{"term": esObject{"name_key": strings.ToLower(product.Name)}},
+	})
+	if err != nil {
+		return err
+	}
+	if existing.Hits.Total.Value > 0 {
+		return errors.New("product with this name already exists")
+	}
+
+	stored := product.Clone()
+	if len(stored.Inventory) == 0 && stored.Stock > 0 {
+		stored.SetWarehouseQuantity(models.DefaultWarehouseID, stored.Stock)
+	}
+	if err := r.client.put(ctx, r.productIndex, stored.ID, newProductDocument(stored), nil); err != nil {
+		if hasStatus(err, http.StatusConflict) {
+			return errors.New("product already exists")
+		}
+		return err
+	}
+
+	movements := make([]*models.StockMovement, 0, len(stored.Inventory))
+	for _, level := range stored.Inventory {
+		if level.Quantity != 0 {
+			movements = append(movements, models.NewStockMovement(stored.ID, level.WarehouseID, level.Quantity, stored.Stock, models.StockSource{Reason: models.StockReasonInitial}))
+		}
+	}
+	r.recordMovements(ctx, movements)
+	return nil
+}
+
+// GetByID retrieves a product by its ID, priced and published as of now
+func (r *ElasticsearchProductRepository) GetByID(ctx context.Context, id string) (*models.Product, error) {
+	product, _, err := r.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return present(product, time.Now()), nil
+}
+
+// load reads a stored product along with the version needed to write it back
+func (r *ElasticsearchProductRepository) load(ctx context.Context, id string) (*models.Product, *esVersion, error) {
+	hit, err := r.client.get(ctx, r.productIndex, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if hit == nil {
+		return nil, nil, errProductNotFound
+	}
+	product, err := decodeProduct(hit.Source)
+	if err != nil {
+		return nil, nil, err
+	}
+	return product, hit.version(), nil
+}
+
+// Update modifies an existing product. Stock and inventory are left untouched.
+func (r *ElasticsearchProductRepository) Update(ctx context.Context, product *models.Product) error {
+	_, err := r.modify(ctx, product.ID, func(change *stockChange) error {
+		updated := product.Clone()
+		updated.Stock = change.product.Stock
+		updated.Inventory = change.product.Inventory
+		change.product = updated
+		return nil
+	})
+	return err
+}
+
+// Delete removes a product and its stock history
+func (r *ElasticsearchProductRepository) Delete(ctx context.Context, id string) error {
+	err := r.client.do(ctx, http.MethodDelete, "/"+r.productIndex+"/_doc/"+id+"?refresh=wait_for", nil, nil)
+	if hasStatus(err, http.StatusNotFound) {
+		return errProductNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return r.client.do(ctx, http.MethodPost, "/"+r.movementIndex+"/_delete_by_query?conflicts=proceed", esObject{
+		"query": esObject{"term": esObject{"product_id": id}},
+	}, nil)
+}
+
+// List returns products matching the filter, sorted and paginated as the filter requests.
+// Filtering, sorting, and counting all happen in the cluster; page numbers are limited by the
+// index's max_result_window, so deep listings should use cursors.
+func (r *ElasticsearchProductRepository) List(ctx context.Context, filter *models.ProductFilter) ([]*models.Product, *models.PageInfo, error) {
+	if filter == nil {
+		filter = &models.ProductFilter{}
+	}
+	sortField, order, err := sortOptions(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Sale prices and schedules are resolved against one instant for the whole query
+	now := time.Now()
+	body := esObject{
+		"query":            productQuery(filter, now),
+		"sort":             productSort(sortField, order, now),
+		"track_total_hits": true,
+	}
+
+	if filter.Limit <= 0 {
+		products, total, err := r.searchAll(ctx, body, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		return products, &models.PageInfo{Limit: total, Total: total}, nil
+	}
+
+	info := &models.PageInfo{Limit: filter.Limit}
+	// One extra hit tells whether there is another page
+	body["size"] = filter.Limit + 1
+	switch {
+	case filter.Cursor != "":
+		cursor, err := models.DecodeCursor(filter.Cursor)
+		if err != nil || cursor.Sort != sortField || cursor.Order != order {
+			return nil, nil, models.ErrInvalidCursor
+		}
+		if _, err := strconv.ParseFloat(cursor.Value, 64); err != nil {
+			return nil, nil, models.ErrInvalidCursor
+		}
+		body["search_after"] = []interface{}{json.RawMessage(cursor.Value), cursor.ID}
+	default:
+		page := filter.Page
+		if page < 1 {
+			page = 1
+		}
+		body["from"] = (page - 1) * filter.Limit
+		info.Page = page
+	}
+
+	result, err := r.client.search(ctx, r.productIndex, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	info.Total = result.Hits.Total.Value
+	if info.Page > 0 {
+		info.TotalPages = (info.Total + filter.Limit - 1) / filter.Limit
+	}
+
+	hits := result.Hits.Hits
+	if len(hits) > filter.Limit {
+		hits = hits[:filter.Limit]
+		info.HasMore = true
+	}
+	products, err := presentHits(hits, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.HasMore && len(hits) > 0 {
+		last := hits[len(hits)-1]
+		if len(last.Sort) > 0 {
+			info.NextCursor = models.Cursor{
+				Sort:  sortField,
+				Order: order,
+				Value: string(last.Sort[0]),
+				ID:    last.ID,
+			}.Encode()
+		}
+	}
+	return products, info, nil
+}
+
+// Search returns the products containing every word of query, ranked by the cluster with names
+// weighted above categories and categories above descriptions. Indices created before categories
+// were searchable match only names and descriptions until their products are written again.
+func (r *ElasticsearchProductRepository) Search(ctx context.Context, query string, filter *models.ProductFilter) ([]*models.Product, error) {
+	if filter == nil {
+		filter = &models.ProductFilter{}
+	}
+	terms := SearchTerms(query)
+	if len(terms) == 0 {
+		return []*models.Product{}, nil
+	}
+
+	now := time.Now()
+	body := esObject{
+		"query": esObject{"bool": esObject{
+			"must": esObject{"multi_match": esObject{
+				"query":    strings.Join(terms, " "),
+				"fields":   []string{fmt.Sprintf("name^%d", searchWeightName), fmt.Sprintf("category^%d", searchWeightCategory), "description"},
+				"type":     "cross_fields",
+				"operator": "and",
+			}},
+			"filter": productQuery(filter, now),
+		}},
+		"sort": []interface{}{"_score", esObject{"name_key": "asc"}},
+	}
+	if filter.Limit <= 0 {
+		products, _, err := r.searchAll(ctx, body, now)
+		return products, err
+	}
+
+	body["size"] = filter.Limit
+	result, err := r.client.search(ctx, r.productIndex, body)
+	if err != nil {
+		return nil, err
+	}
+	return presentHits(result.Hits.Hits, now)
+}
+
+// searchAll pages through every product matching a sorted search body
+func (r *ElasticsearchProductRepository) searchAll(ctx context.Context, body esObject, now time.Time) ([]*models.Product, int, error) {
+	body["size"] = esBatchSize
+	var products []*models.Product
+	total := 0
+	for {
+		result, err := r.client.search(ctx, r.productIndex, body)
+		if err != nil {
+			return nil, 0, err
+		}
+		total = result.Hits.Total.Value
+
+		batch, err := presentHits(result.Hits.Hits, now)
+		if err != nil {
+			return nil, 0, err
+		}
+		products = append(products, batch...)
+
+		hits := result.Hits.Hits
+		if len(hits) < esBatchSize {
+			break
+		}
+		body["search_after"] = hits[len(hits)-1].Sort
+	}
+	if products == nil {
+		products = []*models.Product{}
+	}
+	return products, total, nil
+}
+
+// presentHits decodes search hits into products priced and published as of now
+func presentHits(hits []esHit, now time.Time) ([]*models.Product, error) {
+	products := make([]*models.Product, 0, len(hits))
+	for _, hit := range hits {
+		product, err := decodeProduct(hit.Source)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, present(product, now))
+	}
+	return products, nil
+}
+
+// productQuery translates a filter into a bool query, evaluating sale prices and
+// scheduled publishing as of now
+func productQuery(filter *models.ProductFilter, now time.Time) esObject {
+	clauses := []interface{}{}
+
+	if len(filter.CategoryIDs) > 0 {
+		clauses = append(clauses, esObject{"terms": esObject{"category_id": filter.CategoryIDs}})
+	} else if filter.Category != "" {
+		clauses = append(clauses, esObject{"term": esObject{"category_key": strings.ToLower(filter.Category)}})
+	}
+	if filter.MinPrice > 0 || filter.MaxPrice > 0 {
+		clauses = append(clauses, effectivePriceQuery(filter.MinPrice, filter.MaxPrice, now))
+	}
+	if filter.InStock {
+		clauses = append(clauses, esObject{"bool": esObject{
+			"should": []interface{}{
+				esObject{"range": esObject{"stock": esObject{"gt": 0}}},
+				esObject{"term": esObject{"kind": models.ProductKindDigital}},
+			},
+			"minimum_should_match": 1,
+		}})
+	}
+	for _, tag := range filter.Tags {
+		clauses = append(clauses, esObject{"term": esObject{"tags": tag}})
+	}
+	for key, value := range filter.Attributes {
+		clauses = append(clauses, esObject{"term": esObject{"attribute_pairs": attributePair(key, value)}})
+	}
+	if filter.Status != "" {
+		clauses = append(clauses, statusQuery(filter.Status, now))
+	}
+
+	return esObject{"bool": esObject{"filter": clauses}}
+}
+
+// effectivePriceQuery matches products whose price at now falls within the bounds; a zero bound is open
+func effectivePriceQuery(min, max float64, now time.Time) esObject {
+	bounds := esObject{}
+	if min > 0 {
+		bounds["gte"] = min
+	}
+	if max > 0 {
+		bounds["lte"] = max
+	}
+
+	saleActive := saleActiveQuery(now)
+	return esObject{"bool": esObject{
+		"should": []interface{}{
+			esObject{"bool": esObject{"filter": []interface{}{
+				saleActive,
+				esObject{"range": esObject{"sale_price": bounds}},
+			}}},
+			esObject{"bool": esObject{
+				"must_not": []interface{}{saleActive},
+				"filter":   []interface{}{esObject{"range": esObject{"price": bounds}}},
+			}},
+		},
+		"minimum_should_match": 1,
+	}}
+}
+
+// saleActiveQuery matches products whose sale price applies at now, mirroring Product.SaleActive
+func saleActiveQuery(now time.Time) esObject {
+	at := esTime(now)
+	return esObject{"bool": esObject{"filter": []interface{}{
+		esObject{"exists": esObject{"field": "sale_price"}},
+		unsetOrRange("sale_start", esObject{"lte": at}),
+		unsetOrRange("sale_end", esObject{"gt": at}),
+	}}}
+}
+
+// statusQuery matches products with the given status at now, mirroring Product.StatusAt
+func statusQuery(status models.ProductStatus, now time.Time) esObject {
+	goneLive := esObject{"bool": esObject{"filter": []interface{}{
+		esObject{"term": esObject{"status": models.ProductStatusScheduled}},
+		esObject{"range": esObject{"publish_at": esObject{"lte": esTime(now)}}},
+	}}}
+
+	switch status {
+	case models.ProductStatusPublished:
+		return esObject{"bool": esObject{
+			"should": []interface{}{
+				esObject{"term": esObject{"status": models.ProductStatusPublished}},
+				goneLive,
+			},
+			"minimum_should_match": 1,
+		}}
+	case models.ProductStatusScheduled:
+		return esObject{"bool": esObject{
+			"filter":   []interface{}{esObject{"term": esObject{"status": models.ProductStatusScheduled}}},
+			"must_not": []interface{}{goneLive},
+		}}
+	default:
+		return esObject{"term": esObject{"status": status}}
+	}
+}
+
+// unsetOrRange matches documents where field is missing or within the range
+func unsetOrRange(field string, bounds esObject) esObject {
+	return esObject{"bool": esObject{
+		"should": []interface{}{
+			esObject{"bool": esObject{"must_not": []interface{}{esObject{"exists": esObject{"field": field}}}}},
+			esObject{"range": esObject{field: bounds}},
+		},
+		"minimum_should_match": 1,
+	}}
+}
+
+// productSort orders by the sort field, then by ID so results are deterministic
+func productSort(sortField, order string, now time.Time) []interface{} {
+	primary := esObject{"created_at": esObject{"order": order}}
+	if sortField == models.SortByPrice {
+		primary = esObject{"_script": esObject{
+			"type":  "number",
+			"order": order,
+			"script": esObject{
+				"lang":   "painless",
+				"source": effectivePriceScript,
+				"params": esObject{"now": now.UnixMilli()},
+			},
+		}}
+	}
+	return []interface{}{primary, esObject{"id": esObject{"order": order}}}
+}
+
+// esTime formats a time for a date range query
+func esTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// GetByCategory retrieves all products in a specific category
+func (r *ElasticsearchProductRepository) GetByCategory(ctx context.Context, category string) ([]*models.Product, error) {
+	products, _, err := r.List(ctx, &models.ProductFilter{Category: category})
+	return products, err
+}
+
+// stockChange is one attempt at modifying a stored product. Movements are collected as the
+// product is changed and only recorded once the write has gone through.
+type stockChange struct {
+	product   *models.Product
+	movements []*models.StockMovement
+}
+
+// setQuantity sets the quantity at a warehouse and notes the change
+func (c *stockChange) setQuantity(warehouseID string, quantity int, source models.StockSource) {
+	delta := quantity - c.product.WarehouseQuantity(warehouseID)
+	c.product.SetWarehouseQuantity(warehouseID, quantity)
+	c.note(warehouseID, delta, source)
+}
+
+// takeStock removes quantity across warehouses, best-stocked first, and notes each change
+func (c *stockChange) takeStock(quantity int, source models.StockSource) []models.InventoryLevel {
+	taken := c.product.TakeStock(quantity)
+	for _, level := range taken {
+		c.note(level.WarehouseID, -level.Quantity, source)
+	}
+	return taken
+}
+
+// returnStock puts stock back into the warehouses it was taken from and notes each change
+func (c *stockChange) returnStock(levels []models.InventoryLevel, source models.StockSource) {
+	for _, level := range levels {
+		c.setQuantity(level.WarehouseID, c.product.WarehouseQuantity(level.WarehouseID)+level.Quantity, source)
+	}
+}
+
+// note records a stock change of delta units at a warehouse
+func (c *stockChange) note(warehouseID string, delta int, source models.StockSource) {
+	if delta != 0 {
+		c.movements = append(c.movements, models.NewStockMovement(c.product.ID, warehouseID, delta, c.product.Stock, source))
+	}
+}
+
+// modify applies apply to the stored product and writes it back, retrying from a fresh read
+// when another writer got there first. It returns the product as written.
+func (r *ElasticsearchProductRepository) modify(ctx context.Context, id string, apply func(change *stockChange) error) (*models.Product, error) {
+	for attempt := 0; attempt < esWriteRetries; attempt++ {
+		product, version, err := r.load(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		before := product.Stock
+		change := &stockChange{product: product}
+		if err := apply(change); err != nil {
+			return change.product, err
+		}
+
+		err = r.client.put(ctx, r.productIndex, id, newProductDocument(change.product), version)
+		if hasStatus(err, http.StatusConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		r.recordMovements(ctx, change.movements)
+		if before <= 0 && change.product.Stock > 0 {
+			r.notifyRestock(change.product)
+		}
+		return change.product, nil
+	}
+	return nil, errConcurrentUpdate
+}
+
+// recordMovements adds stock changes to the history. The stock itself has already changed by
+// then, so a failure is logged rather than reported as a failed stock update.
+func (r *ElasticsearchProductRepository) recordMovements(ctx context.Context, movements []*models.StockMovement) {
+	if len(movements) == 0 {
+		return
+	}
+	documents := make(map[string]interface{}, len(movements))
+	for _, movement := range movements {
+		documents[movement.ID] = movement
+	}
+	if err := r.client.bulkIndex(ctx, r.movementIndex, documents); err != nil {
+		slog.Error("Error recording stock movements", "movements_count", len(movements), "error", err)
+	}
+}
+
+// OnRestock registers the listener told when a product comes back into stock
+func (r *ElasticsearchProductRepository) OnRestock(listener RestockListener) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.onRestock = listener
+}
+
+// notifyRestock hands a copy of a restocked product to the restock listener, if there is one
+func (r *ElasticsearchProductRepository) notifyRestock(product *models.Product) {
+	r.mutex.RLock()
+	listener := r.onRestock
+	r.mutex.RUnlock()
+
+	if listener != nil {
+		go listener(present(product, time.Now()))
+	}
+}
+
+// UpdateStock sets the stock held at the default warehouse
+func (r *ElasticsearchProductRepository) UpdateStock(ctx context.Context, id string, quantity int, source models.StockSource) error {
+	return r.SetWarehouseStock(ctx, id, models.DefaultWarehouseID, quantity, source)
+}
+
+// SetWarehouseStock sets the quantity of a product held at one warehouse
+func (r *ElasticsearchProductRepository) SetWarehouseStock(ctx context.Context, id, warehouseID string, quantity int, source models.StockSource) error {
+	if quantity < 0 {
+		if _, _, err := r.load(ctx, id); err != nil {
+			return err
+		}
+		return errors.New("stock quantity cannot be negative")
+	}
+
+	_, err := r.modify(ctx, id, func(change *stockChange) error {
+		change.setQuantity(warehouseID, quantity, source)
+		return nil
+	})
+	return err
+}
+
+// AdjustStock atomically adds delta (which may be negative) to a product's stock and returns
+// the new total. Additions go to the default warehouse; removals draw from the best-stocked
+// warehouses first. Adjustments that would take stock below zero fail with ErrInsufficientStock.
+func (r *ElasticsearchProductRepository) AdjustStock(ctx context.Context, id string, delta int, source models.StockSource) (int, error) {
+	product, err := r.modify(ctx, id, func(change *stockChange) error {
+		if change.product.Stock+delta < 0 {
+			return models.ErrInsufficientStock
+		}
+		if delta >= 0 {
+			change.setQuantity(models.DefaultWarehouseID, change.product.WarehouseQuantity(models.DefaultWarehouseID)+delta, source)
+		} else {
+			change.takeStock(-delta, source)
+		}
+		return nil
+	})
+	return stockOf(product), err
+}
+
+// AdjustWarehouseStock atomically adds delta to the quantity held at one warehouse and returns
+// the product's new total, failing with ErrInsufficientStock if that warehouse would go negative
+func (r *ElasticsearchProductRepository) AdjustWarehouseStock(ctx context.Context, id, warehouseID string, delta int, source models.StockSource) (int, error) {
+	product, err := r.modify(ctx, id, func(change *stockChange) error {
+		quantity := change.product.WarehouseQuantity(warehouseID)
+		if quantity+delta < 0 {
+			return models.ErrInsufficientStock
+		}
+		change.setQuantity(warehouseID, quantity+delta, source)
+		return nil
+	})
+	return stockOf(product), err
+}
+
+// stockOf returns a product's total stock, or zero when there is no product
+func stockOf(product *models.Product) int {
+	if product == nil {
+		return 0
+	}
+	return product.Stock
+}
+
+// TransferStock atomically moves quantity units of a product from one warehouse to another
+func (r *ElasticsearchProductRepository) TransferStock(ctx context.Context, id, fromWarehouseID, toWarehouseID string, quantity int, source models.StockSource) (*models.Product, error) {
+	product, err := r.modify(ctx, id, func(change *stockChange) error {
+		available := change.product.WarehouseQuantity(fromWarehouseID)
+		if available < quantity {
+			return models.ErrInsufficientStock
+		}
+		change.setQuantity(fromWarehouseID, available-quantity, source)
+		change.setQuantity(toWarehouseID, change.product.WarehouseQuantity(toWarehouseID)+quantity, source)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// StockHistory returns up to limit recorded stock changes for a product, newest first.
+// A limit of zero or less returns the most recent MaxStockHistory changes.
+func (r *ElasticsearchProductRepository) StockHistory(ctx context.Context, productID string, limit int) ([]*models.StockMovement, error) {
+	if _, _, err := r.load(ctx, productID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > MaxStockHistory {
+		limit = MaxStockHistory
+	}
+
+	result, err := r.client.search(ctx, r.movementIndex, esObject{
+		"size":  limit,
+		"query": esObject{"term": esObject{"product_id": productID}},
+		"sort":  []interface{}{esObject{"created_at": esObject{"order": "desc"}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]*models.StockMovement, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		var movement models.StockMovement
+		if err := json.Unmarshal(hit.Source, &movement); err != nil {
+			return nil, err
+		}
+		history = append(history, &movement)
+	}
+	return history, nil
+}
+
+// UpdateRating stores the review summary for a product
+func (r *ElasticsearchProductRepository) UpdateRating(ctx context.Context, id string, average float64, count int) error {
+	_, err := r.modify(ctx, id, func(change *stockChange) error {
+		change.product.AverageRating = average
+		change.product.ReviewCount = count
+		return nil
+	})
+	return err
+}
+
+// Ping checks that the cluster answers and the product index exists, for the readiness probe
+func (r *ElasticsearchProductRepository) Ping(ctx context.Context) error {
+	return r.client.do(ctx, http.MethodHead, "/"+r.productIndex, nil, nil)
+}
+
+// Count returns how many products are in the index
+func (r *ElasticsearchProductRepository) Count(ctx context.Context) (int, error) {
+	result, err := r.client.search(ctx, r.productIndex, esObject{"size": 0, "track_total_hits": true})
+	if err != nil {
+		return 0, err
+	}
+	return result.Hits.Total.Value, nil
+}
+
+// TagCounts returns every distinct tag with the number of products carrying it,
+// most used first and alphabetically within the same count
+func (r *ElasticsearchProductRepository) TagCounts(ctx context.Context) ([]models.TagCount, error) {
+	result, err := r.client.search(ctx, r.productIndex, esObject{
+		"size": 0,
+		"aggs": esObject{
+			"tags": esObject{"terms": esObject{
+				"field": "tags",
+				"size":  esMaxTags,
+				"order": []interface{}{esObject{"_count": "desc"}, esObject{"_key": "asc"}},
+			}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var aggregations struct {
+		Tags struct {
+			Buckets []struct {
+				Key      string `json:"key"`
+				DocCount int    `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"tags"`
+	}
+	if len(result.Aggregations) > 0 {
+		if err := json.Unmarshal(result.Aggregations, &aggregations); err != nil {
+			return nil, err
+		}
+	}
+
+	tags := make([]models.TagCount, 0, len(aggregations.Tags.Buckets))
+	for _, bucket := range aggregations.Tags.Buckets {
+		tags = append(tags, models.TagCount{Tag: bucket.Key, Count: bucket.DocCount})
+	}
+	return tags, nil
+}
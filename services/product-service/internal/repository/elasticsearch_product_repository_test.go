@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"product-service/internal/models"
+)
+
+// fakeDocument is a stored document in fakeCluster
+type fakeDocument struct {
+	source json.RawMessage
+	seqNo  int64
+}
+
+// fakeCluster implements just enough of the Elasticsearch REST API for the repository's
+// document reads and writes. Searches only understand a single term query.
+type fakeCluster struct {
+	mutex     sync.Mutex
+	indices   map[string]map[string]*fakeDocument
+	seqNo     int64
+	conflicts int // conditional writes to fail with 409 before accepting them
+}
+
+func newFakeCluster(t *testing.T) (*fakeCluster, *httptest.Server) {
+	cluster := &fakeCluster{indices: make(map[string]map[string]*fakeDocument)}
+	server := httptest.NewServer(cluster)
+	t.Cleanup(server.Close)
+	return cluster, server
+}
+
+func (c *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	index := parts[0]
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodHead:
+		if _, exists := c.indices[index]; !exists {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case len(parts) == 1 && r.Method == http.MethodPut:
+		c.indices[index] = make(map[string]*fakeDocument)
+		json.NewEncoder(w).Encode(map[string]bool{"acknowledged": true})
+	case index == "_bulk":
+		lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+		for i := 0; i+1 < len(lines); i += 2 {
+			var action struct {
+				Index struct {
+					Index string `json:"_index"`
+					ID    string `json:"_id"`
+				} `json:"index"`
+			}
+			json.Unmarshal([]byte(lines[i]), &action)
+			c.write(action.Index.Index, action.Index.ID, json.RawMessage(lines[i+1]))
+		}
+		json.NewEncoder(w).Encode(map[string]bool{"errors": false})
+	case len(parts) == 3 && parts[1] == "_doc" && r.Method == http.MethodGet:
+		document, exists := c.indices[index][parts[2]]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]bool{"found": false})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"_id": parts[2], "found": true, "_seq_no": document.seqNo, "_primary_term": 1, "_source": document.source,
+		})
+	case len(parts) == 3 && parts[1] == "_create":
+		if _, exists := c.indices[index][parts[2]]; exists {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		c.write(index, parts[2], body)
+	case len(parts) == 3 && parts[1] == "_doc" && r.Method == http.MethodPut:
+		document, exists := c.indices[index][parts[2]]
+		if ifSeqNo := r.URL.Query().Get("if_seq_no"); ifSeqNo != "" {
+			if c.conflicts > 0 || !exists || strconv.FormatInt(document.seqNo, 10) != ifSeqNo {
+				c.conflicts--
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"type": "version_conflict_engine_exception"}})
+				return
+			}
+		}
+		c.write(index, parts[2], body)
+	case len(parts) == 3 && parts[1] == "_doc" && r.Method == http.MethodDelete:
+		if _, exists := c.indices[index][parts[2]]; !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(c.indices[index], parts[2])
+	case len(parts) == 2 && parts[1] == "_search":
+		c.search(w, index, body)
+	default:
+		json.NewEncoder(w).Encode(map[string]interface{}{})
+	}
+}
+
+func (c *fakeCluster) write(index, id string, source json.RawMessage) {
+	c.seqNo++
+	c.indices[index][id] = &fakeDocument{source: append(json.RawMessage{}, source...), seqNo: c.seqNo}
+}
+
+func (c *fakeCluster) search(w http.ResponseWriter, index string, body []byte) {
+	var request struct {
+		Query struct {
+			Term map[string]string `json:"term"`
+		} `json:"query"`
+	}
+	json.Unmarshal(body, &request)
+
+	hits := []map[string]interface{}{}
+	for id, document := range c.indices[index] {
+		var fields map[string]interface{}
+		json.Unmarshal(document.source, &fields)
+		matches := true
+		for field, value := range request.Query.Term {
+			if fields[field] != value {
+				matches = false
+			}
+		}
+		if matches {
+			hits = append(hits, map[string]interface{}{"_id": id, "_source": document.source})
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"hits": map[string]interface{}{"total": map[string]int{"value": len(hits)}, "hits": hits},
+	})
+}
+
+func newTestElasticsearchRepository(t *testing.T) (*ElasticsearchProductRepository, *fakeCluster) {
+	cluster, server := newFakeCluster(t)
+	repo, err := NewElasticsearchProductRepository(context.Background(), ElasticsearchConfig{URL: server.URL, IndexPrefix: "test"})
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	return repo, cluster
+}
+
+func TestElasticsearchProductRepositoryCreatesIndices(t *testing.T) {
+	repo, cluster := newTestElasticsearchRepository(t)
+
+	for _, index := range []string{"test-products", "test-reservations", "test-stock-movements"} {
+		if _, exists := cluster.indices[index]; !exists {
+			t.Errorf("Expected index %s to be created", index)
+		}
+	}
+	if err := repo.Ping(context.Background()); err != nil {
+		t.Errorf("Expected the cluster to answer a ping, got %v", err)
+	}
+
+	cluster.mutex.Lock()
+	delete(cluster.indices, "test-products")
+	cluster.mutex.Unlock()
+	if err := repo.Ping(context.Background()); !hasStatus(err, http.StatusNotFound) {
+		t.Errorf("Expected a missing product index to fail the ping, got %v", err)
+	}
+}
+
+func TestElasticsearchProductRepositoryCreateAndAdjustStock(t *testing.T) {
+	repo, _ := newTestElasticsearchRepository(t)
+
+	product := models.NewProduct("Desk Lamp", "LED desk lamp", "Home", 39.99, 10, "")
+	if err := repo.Create(context.Background(), product); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := repo.Create(context.Background(), models.NewProduct("desk lamp", "", "Home", 10, 1, "")); err == nil {
+		t.Error("Expected a duplicate name in a different case to be rejected")
+	}
+	if count, err := repo.Count(context.Background()); err != nil || count != 1 {
+		t.Errorf("Expected 1 product stored, got %d (%v)", count, err)
+	}
+
+	stock, err := repo.AdjustStock(context.Background(), product.ID, -3, models.StockSource{Reason: models.StockReasonManualAdjustment})
+	if err != nil || stock != 7 {
+		t.Fatalf("Expected stock 7, got %d (%v)", stock, err)
+	}
+	if _, err := repo.AdjustStock(context.Background(), product.ID, -8, models.StockSource{}); !errors.Is(err, models.ErrInsufficientStock) {
+		t.Errorf("Expected ErrInsufficientStock, got %v", err)
+	}
+
+	stored, err := repo.GetByID(context.Background(), product.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if stored.Stock != 7 || stored.WarehouseQuantity(models.DefaultWarehouseID) != 7 {
+		t.Errorf("Expected 7 units at the default warehouse, got %+v", stored.Inventory)
+	}
+	if stored.EffectivePrice != 39.99 {
+		t.Errorf("Expected effective price to be worked out on read, got %v", stored.EffectivePrice)
+	}
+
+	if _, err := repo.GetByID(context.Background(), "missing"); err == nil || err.Error() != "product not found" {
+		t.Errorf("Expected product not found, got %v", err)
+	}
+}
+
+func TestElasticsearchProductRepositoryRetriesConflictingWrites(t *testing.T) {
+	repo, cluster := newTestElasticsearchRepository(t)
+
+	product := models.NewProduct("Desk Lamp", "LED desk lamp", "Home", 39.99, 10, "")
+	if err := repo.Create(context.Background(), product); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	cluster.conflicts = 2
+	stock, err := repo.AdjustStock(context.Background(), product.ID, 5, models.StockSource{})
+	if err != nil || stock != 15 {
+		t.Fatalf("Expected the adjustment to be applied once after retrying, got %d (%v)", stock, err)
+	}
+
+	cluster.conflicts = esWriteRetries
+	if _, err := repo.AdjustStock(context.Background(), product.ID, 1, models.StockSource{}); !errors.Is(err, errConcurrentUpdate) {
+		t.Errorf("Expected errConcurrentUpdate after running out of retries, got %v", err)
+	}
+}
+
+func TestElasticsearchProductRepositoryUpdateKeepsStock(t *testing.T) {
+	repo, _ := newTestElasticsearchRepository(t)
+
+	product := models.NewProduct("Desk Lamp", "LED desk lamp", "Home", 39.99, 10, "")
+	product.InsertImage(models.ProductImage{ID: "img-1", URL: "http://cdn/lamp.png", StorageKey: "products/lamp.png"}, 0)
+	if err := repo.Create(context.Background(), product); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	stale := product.Clone()
+	stale.Stock = 0
+	stale.Name = "Desk Lamp Pro"
+	if err := repo.Update(context.Background(), stale); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	stored, _ := repo.GetByID(context.Background(), product.ID)
+	if stored.Name != "Desk Lamp Pro" || stored.Stock != 10 {
+		t.Errorf("Expected renamed product with stock 10, got %q with %d", stored.Name, stored.Stock)
+	}
+	if image, ok := stored.Image("img-1"); !ok || image.StorageKey != "products/lamp.png" {
+		t.Errorf("Expected uploaded image storage key to be kept, got %+v", image)
+	}
+}
+
+func TestElasticsearchProductRepositoryReservations(t *testing.T) {
+	repo, _ := newTestElasticsearchRepository(t)
+
+	product := models.NewProduct("Desk Lamp", "LED desk lamp", "Home", 39.99, 10, "")
+	if err := repo.Create(context.Background(), product); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	reservation, err := repo.ReserveStock(context.Background(), product.ID, "order-1", 4, time.Minute, "order-service")
+	if err != nil {
+		t.Fatalf("ReserveStock failed: %v", err)
+	}
+	if stored, _ := repo.GetByID(context.Background(), product.ID); stored.Stock != 6 {
+		t.Errorf("Expected stock 6 while reserved, got %d", stored.Stock)
+	}
+
+	released, err := repo.ReleaseReservation(context.Background(), product.ID, reservation.ID, "order-service")
+	if err != nil || released.Status != models.ReservationReleased {
+		t.Fatalf("Expected reservation to be released, got %+v (%v)", released, err)
+	}
+	if stored, _ := repo.GetByID(context.Background(), product.ID); stored.Stock != 10 {
+		t.Errorf("Expected stock 10 after release, got %d", stored.Stock)
+	}
+
+	if _, err := repo.ReleaseReservation(context.Background(), product.ID, reservation.ID, "order-service"); !errors.Is(err, models.ErrReservationClosed) {
+		t.Errorf("Expected ErrReservationClosed releasing twice, got %v", err)
+	}
+	if _, err := repo.CommitReservation(context.Background(), "other-product", reservation.ID, "order-service"); !errors.Is(err, models.ErrReservationNotFound) {
+		t.Errorf("Expected ErrReservationNotFound for another product, got %v", err)
+	}
+}
+
+func TestProductQuery(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	filter := &models.ProductFilter{
+		Category:   "Electronics",
+		MinPrice:   10,
+		Tags:       []string{"sale"},
+		Attributes: map[string]string{"color": "Red"},
+		Status:     models.ProductStatusPublished,
+	}
+
+	data, err := json.Marshal(productQuery(filter, now))
+	if err != nil {
+		t.Fatalf("Failed to encode query: %v", err)
+	}
+	query := string(data)
+	for _, want := range []string{
+		`{"term":{"category_key":"electronics"}}`,
+		`{"term":{"tags":"sale"}}`,
+		`{"term":{"attribute_pairs":"color=red"}}`,
+		`{"range":{"sale_price":{"gte":10}}}`,
+		`{"range":{"publish_at":{"lte":"2024-05-01T12:00:00.000Z"}}}`,
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("Expected query to contain %s, got %s", want, query)
+		}
+	}
+}
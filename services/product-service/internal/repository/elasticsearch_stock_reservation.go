@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+	"product-service/internal/models"
+)
+
+// ReserveStock atomically takes quantity units out of a product's stock and records a held
+// reservation for them. It fails with ErrInsufficientStock rather than letting stock go negative.
+func (r *ElasticsearchProductRepository) ReserveStock(ctx context.Context, productID, orderID string, quantity int, ttl time.Duration, actor string) (*models.StockReservation, error) {
+	reservation := models.NewStockReservation(productID, orderID, quantity, ttl)
+	_, err := r.modify(ctx, productID, func(change *stockChange) error {
+		if change.product.Stock < quantity {
+			return models.ErrInsufficientStock
+		}
+		reservation.Allocations = change.takeStock(quantity, models.StockSource{
+			Actor:     actor,
+			Reason:    models.StockReasonOrderReserved,
+			Reference: reservationReference(reservation),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.client.put(ctx, r.reservationIndex, reservation.ID, reservation, nil); err != nil {
+		// Without a reservation record nothing would ever return the stock, so put it back now
+		if restockErr := r.returnReservation(ctx, reservation, models.ReservationReleased, models.SystemActor); restockErr != nil {
+			slog.Error("Error returning stock for unrecorded reservation", "reservation_id", reservation.ID, "error", restockErr)
+		}
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// ReleaseReservation returns a reservation's units to stock. Committed reservations can be
+// released too (e.g. when a confirmed order is cancelled).
+func (r *ElasticsearchProductRepository) ReleaseReservation(ctx context.Context, productID, reservationID, actor string) (*models.StockReservation, error) {
+	return r.closeReservation(ctx, productID, reservationID, models.ReservationReleased, actor)
+}
+
+// CommitReservation marks held stock as sold so it no longer expires
+func (r *ElasticsearchProductRepository) CommitReservation(ctx context.Context, productID, reservationID, actor string) (*models.StockReservation, error) {
+	return r.closeReservation(ctx, productID, reservationID, models.ReservationCommitted, actor)
+}
+
+// closeReservation moves a reservation to released or committed, restocking when released.
+// The reservation's status is switched first, guarded by its version, so two callers racing
+// to release the same reservation cannot both return its stock.
+func (r *ElasticsearchProductRepository) closeReservation(ctx context.Context, productID, reservationID string, status models.ReservationStatus, actor string) (*models.StockReservation, error) {
+	for attempt := 0; attempt < esWriteRetries; attempt++ {
+		hit, err := r.client.get(ctx, r.reservationIndex, reservationID)
+		if err != nil {
+			return nil, err
+		}
+		if hit == nil {
+			return nil, models.ErrReservationNotFound
+		}
+		var reservation models.StockReservation
+		if err := json.Unmarshal(hit.Source, &reservation); err != nil {
+			return nil, err
+		}
+		if reservation.ProductID != productID {
+			return nil, models.ErrReservationNotFound
+		}
+
+		now := time.Now()
+		restockActor := actor
+		switch {
+		// Expire lazily so a late commit can't sell stock that should already be back on the shelf
+		case reservation.IsExpired(now):
+			reservation.Status = models.ReservationExpired
+			restockActor = models.SystemActor
+		case status == models.ReservationCommitted && reservation.Status == models.ReservationHeld:
+			reservation.Status = models.ReservationCommitted
+		case status == models.ReservationReleased && (reservation.Status == models.ReservationHeld || reservation.Status == models.ReservationCommitted):
+			reservation.Status = models.ReservationReleased
+		default:
+			return nil, models.ErrReservationClosed
+		}
+		reservation.UpdatedAt = now
+
+		err = r.client.put(ctx, r.reservationIndex, reservation.ID, &reservation, hit.version())
+		if hasStatus(err, http.StatusConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if reservation.IsReturned() {
+			if err := r.returnReservation(ctx, &reservation, reservation.Status, restockActor); err != nil {
+				return nil, err
+			}
+		}
+		if reservation.Status != status {
+			return nil, models.ErrReservationClosed
+		}
+		return &reservation, nil
+	}
+	return nil, errConcurrentUpdate
+}
+
+// ExpireReservations returns the stock of every held reservation that has expired by now
+// and reports how many were expired. Returned reservations older than the retention period are dropped.
+func (r *ElasticsearchProductRepository) ExpireReservations(ctx context.Context, now time.Time) (int, error) {
+	result, err := r.client.search(ctx, r.reservationIndex, esObject{
+		"size":                esMaxExpiries,
+		"seq_no_primary_term": true,
+		"query": esObject{"bool": esObject{"filter": []interface{}{
+			esObject{"term": esObject{"status": models.ReservationHeld}},
+			esObject{"range": esObject{"expires_at": esObject{"lte": esTime(now)}}},
+		}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, hit := range result.Hits.Hits {
+		var reservation models.StockReservation
+		if err := json.Unmarshal(hit.Source, &reservation); err != nil {
+			return expired, err
+		}
+		if !reservation.IsExpired(now) {
+			continue
+		}
+		reservation.Status = models.ReservationExpired
+		reservation.UpdatedAt = now
+
+		// A conflict means the reservation was committed or released meanwhile, which settles it
+		err := r.client.put(ctx, r.reservationIndex, reservation.ID, &reservation, hit.version())
+		if hasStatus(err, http.StatusConflict) {
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		if err := r.returnReservation(ctx, &reservation, models.ReservationExpired, models.SystemActor); err != nil {
+			return expired, err
+		}
+		expired++
+	}
+
+	err = r.client.do(ctx, http.MethodPost, "/"+r.reservationIndex+"/_delete_by_query?conflicts=proceed", esObject{
+		"query": esObject{"bool": esObject{"filter": []interface{}{
+			esObject{"terms": esObject{"status": []models.ReservationStatus{models.ReservationReleased, models.ReservationExpired}}},
+			esObject{"range": esObject{"updated_at": esObject{"lt": esTime(now.Add(-reservationRetention))}}},
+		}}},
+	}, nil)
+	return expired, err
+}
+
+// returnReservation puts a reservation's units back into the warehouses they came from.
+// A product deleted since the reservation was made has nothing to return to.
+func (r *ElasticsearchProductRepository) returnReservation(ctx context.Context, reservation *models.StockReservation, status models.ReservationStatus, actor string) error {
+	reason := models.StockReasonOrderReleased
+	if status == models.ReservationExpired {
+		reason = models.StockReasonReservationExpired
+	}
+	_, err := r.modify(ctx, reservation.ProductID, func(change *stockChange) error {
+		change.returnStock(reservation.Allocations, models.StockSource{
+			Actor:     actor,
+			Reason:    reason,
+			Reference: reservationReference(reservation),
+		})
+		return nil
+	})
+	if errors.Is(err, errProductNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,530 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	"ecommerce/pkg/mongodb"
+	"product-service/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Unique indexes on products, named so a violation can be told apart from other failures
+const (
+	mongoProductsNameIndex = "products_name_key"
+	mongoProductsSKUIndex  = "products_sku_key"
+)
+
+var (
+	mongoProductIndexes = []mongo.IndexModel{
+		{Keys: bson.D{{Key: "name_key", Value: 1}}, Options: options.Index().SetName(mongoProductsNameIndex).SetUnique(true)},
+		// SKUs are optional, so only the ones given must be unique
+		{Keys: bson.D{{Key: "sku", Value: 1}}, Options: options.Index().SetName(mongoProductsSKUIndex).SetUnique(true).
+			SetPartialFilterExpression(bson.D{{Key: "sku", Value: bson.D{{Key: "$gt", Value: ""}}}})},
+		{Keys: bson.D{{Key: "category_id", Value: 1}}},
+		{Keys: bson.D{{Key: "category_key", Value: 1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}}},
+	}
+	mongoReservationIndexes = []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expires_at", Value: 1}}},
+	}
+	mongoMovementIndexes = []mongo.IndexModel{
+		{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "_id", Value: -1}}},
+	}
+)
+
+// MongoProductRepository implements ProductRepository on MongoDB collections. Every stock change
+// is a transaction, so adjustments from several service instances can't overwrite each other, and
+// the stock, its history, and any reservation are written together. Transactions need a replica set.
+type MongoProductRepository struct {
+	db           *mongo.Database
+	products     *mongo.Collection
+	reservations *mongo.Collection
+	movements    *mongo.Collection
+	onRestock    RestockListener
+	mutex        sync.RWMutex // guards onRestock
+}
+
+// mongoProductDocument is how a product is stored: the same document as the other stores, keyed by the product's ID
+type mongoProductDocument struct {
+	Key                string `bson:"_id"`
+	*esProductDocument `bson:",inline"`
+}
+
+// mongoReservationDocument is how a reservation is stored, keyed by its ID
+type mongoReservationDocument struct {
+	Key                      string `bson:"_id"`
+	*models.StockReservation `bson:",inline"`
+}
+
+// mongoMovementDocument is how a stock change is stored. Object IDs increase as movements are
+// recorded, so history is ordered by them.
+type mongoMovementDocument struct {
+	Key                   primitive.ObjectID `bson:"_id"`
+	*models.StockMovement `bson:",inline"`
+}
+
+// NewMongoProductRepository creates a repository on the database's products, stock_reservations,
+// and stock_movements collections, creating their indexes if they don't exist yet
+func NewMongoProductRepository(ctx context.Context, db *mongo.Database) (*MongoProductRepository, error) {
+	repo := &MongoProductRepository{
+		db:           db,
+		products:     db.Collection("products"),
+		reservations: db.Collection("stock_reservations"),
+		movements:    db.Collection("stock_movements"),
+	}
+
+	indexes := map[*mongo.Collection][]mongo.IndexModel{
+		repo.products:     mongoProductIndexes,
+		repo.reservations: mongoReservationIndexes,
+		repo.movements:    mongoMovementIndexes,
+	}
+	for collection, collectionIndexes := range indexes {
+		if err := mongodb.EnsureIndexes(ctx, collection, collectionIndexes); err != nil {
+			return nil, err
+		}
+	}
+	return repo, nil
+}
+
+// newMongoProductDocument prepares a product for storing
+func newMongoProductDocument(product *models.Product) *mongoProductDocument {
+	return &mongoProductDocument{Key: product.ID, esProductDocument: newProductDocument(product)}
+}
+
+// mongoProductError turns a unique index violation into the error the in-memory repository gives
+func mongoProductError(err error) error {
+	switch {
+	case mongodb.IsDuplicateKey(err, mongoProductsNameIndex):
+		return errors.New("product with this name already exists")
+	case mongodb.IsDuplicateKey(err, mongoProductsSKUIndex):
+		return errors.New("product with this SKU already exists")
+	case mongodb.IsDuplicateKey(err, "_id_"):
+		return errors.New("product already exists")
+	}
+	return err
+}
+
+// Create adds a new product, rejecting names already in use regardless of case
+func (r *MongoProductRepository) Create(ctx context.Context, product *models.Product) error {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	stored := product.Clone()
+	if len(stored.Inventory) == 0 && stored.Stock > 0 {
+		stored.SetWarehouseQuantity(models.DefaultWarehouseID, stored.Stock)
+	}
+	movements := make([]*models.StockMovement, 0, len(stored.Inventory))
+	for _, level := range stored.Inventory {
+		if level.Quantity != 0 {
+			movements = append(movements, models.NewStockMovement(stored.ID, level.WarehouseID, level.Quantity, stored.Stock, models.StockSource{Reason: models.StockReasonInitial}))
+		}
+	}
+
+	err := mongodb.InTransaction(ctx, r.db, func(ctx mongo.SessionContext) error {
+		if _, err := r.products.InsertOne(ctx, newMongoProductDocument(stored)); err != nil {
+			return err
+		}
+		return r.recordMovements(ctx, movements)
+	})
+	return mongoProductError(err)
+}
+
+// GetByID retrieves a product by its ID, priced and published as of now
+func (r *MongoProductRepository) GetByID(ctx context.Context, id string) (*models.Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	product, err := r.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return present(product, time.Now()), nil
+}
+
+// load reads a stored product
+func (r *MongoProductRepository) load(ctx context.Context, id string) (*models.Product, error) {
+	document := newMongoProductDocument(&models.Product{})
+	err := r.products.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return document.product(), nil
+}
+
+// Update modifies an existing product. Stock and inventory are left untouched.
+func (r *MongoProductRepository) Update(ctx context.Context, product *models.Product) error {
+	_, err := r.modify(ctx, product.ID, func(change *stockChange) error {
+		updated := product.Clone()
+		updated.Stock = change.product.Stock
+		updated.Inventory = change.product.Inventory
+		change.product = updated
+		return nil
+	})
+	return mongoProductError(err)
+}
+
+// Delete removes a product and its stock history
+func (r *MongoProductRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	return mongodb.InTransaction(ctx, r.db, func(ctx mongo.SessionContext) error {
+		result, err := r.products.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			return errProductNotFound
+		}
+		_, err = r.movements.DeleteMany(ctx, bson.D{{Key: "product_id", Value: id}})
+		return err
+	})
+}
+
+// List returns products matching the filter, sorted and paginated as the filter requests.
+// Categories are matched in the database; prices, schedules, tags, and attributes are checked
+// against each product as the in-memory store does, so both answer alike.
+func (r *MongoProductRepository) List(ctx context.Context, filter *models.ProductFilter) ([]*models.Product, *models.PageInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	query := bson.D{}
+	switch {
+	case filter != nil && len(filter.CategoryIDs) > 0:
+		query = bson.D{{Key: "category_id", Value: bson.D{{Key: "$in", Value: filter.CategoryIDs}}}}
+	case filter != nil && filter.Category != "":
+		query = bson.D{{Key: "category_key", Value: strings.ToLower(filter.Category)}}
+	}
+
+	cursor, err := r.products.Find(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	// Sale prices and schedules are resolved once per call so every product is seen at the same instant
+	now := time.Now()
+	products := make([]*models.Product, 0)
+	for cursor.Next(ctx) {
+		document := newMongoProductDocument(&models.Product{})
+		if err := cursor.Decode(document); err != nil {
+			return nil, nil, err
+		}
+		if product := document.product(); matchesFilter(product, filter, now) {
+			products = append(products, present(product, now))
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, nil, err
+	}
+	return paginateProducts(products, filter)
+}
+
+// Search returns the products containing every word of query. Products naming each word somewhere
+// are found in the database; they are ranked, and checked against the filter, as the in-memory
+// store does.
+func (r *MongoProductRepository) Search(ctx context.Context, query string, filter *models.ProductFilter) ([]*models.Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	now := time.Now()
+	search := newProductSearch(query, filter)
+	if len(search.terms) == 0 {
+		return []*models.Product{}, nil
+	}
+	// Terms are letters and digits only, so are safe in a pattern as they are
+	conditions := make(bson.A, len(search.terms))
+	for i, term := range search.terms {
+		pattern := primitive.Regex{Pattern: term, Options: "i"}
+		conditions[i] = bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "name", Value: pattern}},
+			bson.D{{Key: "category", Value: pattern}},
+			bson.D{{Key: "description", Value: pattern}},
+		}}}
+	}
+
+	cursor, err := r.products.Find(ctx, bson.D{{Key: "$and", Value: conditions}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		document := newMongoProductDocument(&models.Product{})
+		if err := cursor.Decode(document); err != nil {
+			return nil, err
+		}
+		if product := document.product(); matchesFilter(product, filter, now) {
+			search.consider(product)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	products := search.results()
+	for i, product := range products {
+		products[i] = present(product, now)
+	}
+	return products, nil
+}
+
+// GetByCategory retrieves all products in a specific category
+func (r *MongoProductRepository) GetByCategory(ctx context.Context, category string) ([]*models.Product, error) {
+	products, _, err := r.List(ctx, &models.ProductFilter{Category: category})
+	return products, err
+}
+
+// modify applies apply to the stored product and writes it back along with the stock movements
+// apply noted, in one transaction. It returns the product as written.
+func (r *MongoProductRepository) modify(ctx context.Context, id string, apply func(change *stockChange) error) (*models.Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	var change *stockChange
+	var before int
+	err := mongodb.InTransaction(ctx, r.db, func(ctx mongo.SessionContext) error {
+		var err error
+		change, before, err = r.modifyIn(ctx, id, apply)
+		return err
+	})
+	if err != nil {
+		if change != nil {
+			return change.product, err
+		}
+		return nil, err
+	}
+
+	r.checkRestock(change.product, before)
+	return change.product, nil
+}
+
+// modifyIn is modify within a transaction the caller runs. It returns the change, and the stock
+// the product had before it, even when apply fails.
+func (r *MongoProductRepository) modifyIn(ctx mongo.SessionContext, id string, apply func(change *stockChange) error) (*stockChange, int, error) {
+	product, err := r.load(ctx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	before := product.Stock
+	change := &stockChange{product: product}
+	if err := apply(change); err != nil {
+		return change, before, err
+	}
+
+	if _, err := r.products.ReplaceOne(ctx, bson.D{{Key: "_id", Value: id}}, newMongoProductDocument(change.product)); err != nil {
+		return change, before, err
+	}
+	return change, before, r.recordMovements(ctx, change.movements)
+}
+
+// recordMovements adds stock changes to the history, trimming each product's history to its
+// newest MaxStockHistory changes
+func (r *MongoProductRepository) recordMovements(ctx mongo.SessionContext, movements []*models.StockMovement) error {
+	if len(movements) == 0 {
+		return nil
+	}
+	documents := make([]interface{}, 0, len(movements))
+	for _, movement := range movements {
+		documents = append(documents, mongoMovementDocument{Key: primitive.NewObjectID(), StockMovement: movement})
+	}
+	if _, err := r.movements.InsertMany(ctx, documents); err != nil {
+		return err
+	}
+
+	productID := movements[0].ProductID
+	var oldest struct {
+		Key primitive.ObjectID `bson:"_id"`
+	}
+	err := r.movements.FindOne(ctx, bson.D{{Key: "product_id", Value: productID}},
+		options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}}).SetSkip(MaxStockHistory).SetProjection(bson.D{{Key: "_id", Value: 1}})).Decode(&oldest)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = r.movements.DeleteMany(ctx, bson.D{{Key: "product_id", Value: productID}, {Key: "_id", Value: bson.D{{Key: "$lte", Value: oldest.Key}}}})
+	return err
+}
+
+// OnRestock registers the listener told when a product comes back into stock
+func (r *MongoProductRepository) OnRestock(listener RestockListener) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.onRestock = listener
+}
+
+// checkRestock tells the restock listener if a product that had no stock before a change now has some
+func (r *MongoProductRepository) checkRestock(product *models.Product, before int) {
+	r.mutex.RLock()
+	listener := r.onRestock
+	r.mutex.RUnlock()
+
+	if before <= 0 && product.Stock > 0 && listener != nil {
+		go listener(present(product, time.Now()))
+	}
+}
+
+// UpdateStock sets the stock held at the default warehouse
+func (r *MongoProductRepository) UpdateStock(ctx context.Context, id string, quantity int, source models.StockSource) error {
+	return r.SetWarehouseStock(ctx, id, models.DefaultWarehouseID, quantity, source)
+}
+
+// SetWarehouseStock sets the quantity of a product held at one warehouse
+func (r *MongoProductRepository) SetWarehouseStock(ctx context.Context, id, warehouseID string, quantity int, source models.StockSource) error {
+	_, err := r.modify(ctx, id, func(change *stockChange) error {
+		if quantity < 0 {
+			return errors.New("stock quantity cannot be negative")
+		}
+		change.setQuantity(warehouseID, quantity, source)
+		return nil
+	})
+	return err
+}
+
+// AdjustStock atomically adds delta (which may be negative) to a product's stock and returns
+// the new total. Additions go to the default warehouse; removals draw from the best-stocked
+// warehouses first. Adjustments that would take stock below zero fail with ErrInsufficientStock.
+func (r *MongoProductRepository) AdjustStock(ctx context.Context, id string, delta int, source models.StockSource) (int, error) {
+	product, err := r.modify(ctx, id, func(change *stockChange) error {
+		if change.product.Stock+delta < 0 {
+			return models.ErrInsufficientStock
+		}
+		if delta >= 0 {
+			change.setQuantity(models.DefaultWarehouseID, change.product.WarehouseQuantity(models.DefaultWarehouseID)+delta, source)
+		} else {
+			change.takeStock(-delta, source)
+		}
+		return nil
+	})
+	return stockOf(product), err
+}
+
+// AdjustWarehouseStock atomically adds delta to the quantity held at one warehouse and returns
+// the product's new total, failing with ErrInsufficientStock if that warehouse would go negative
+func (r *MongoProductRepository) AdjustWarehouseStock(ctx context.Context, id, warehouseID string, delta int, source models.StockSource) (int, error) {
+	product, err := r.modify(ctx, id, func(change *stockChange) error {
+		quantity := change.product.WarehouseQuantity(warehouseID)
+		if quantity+delta < 0 {
+			return models.ErrInsufficientStock
+		}
+		change.setQuantity(warehouseID, quantity+delta, source)
+		return nil
+	})
+	return stockOf(product), err
+}
+
+// TransferStock atomically moves quantity units of a product from one warehouse to another
+func (r *MongoProductRepository) TransferStock(ctx context.Context, id, fromWarehouseID, toWarehouseID string, quantity int, source models.StockSource) (*models.Product, error) {
+	product, err := r.modify(ctx, id, func(change *stockChange) error {
+		available := change.product.WarehouseQuantity(fromWarehouseID)
+		if available < quantity {
+			return models.ErrInsufficientStock
+		}
+		change.setQuantity(fromWarehouseID, available-quantity, source)
+		change.setQuantity(toWarehouseID, change.product.WarehouseQuantity(toWarehouseID)+quantity, source)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// StockHistory returns up to limit recorded stock changes for a product, newest first.
+// A limit of zero or less returns the whole retained history.
+func (r *MongoProductRepository) StockHistory(ctx context.Context, productID string, limit int) ([]*models.StockMovement, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	if _, err := r.load(ctx, productID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > MaxStockHistory {
+		limit = MaxStockHistory
+	}
+
+	cursor, err := r.movements.Find(ctx, bson.D{{Key: "product_id", Value: productID}},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	history := make([]*models.StockMovement, 0)
+	for cursor.Next(ctx) {
+		document := mongoMovementDocument{StockMovement: &models.StockMovement{}}
+		if err := cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+		history = append(history, document.StockMovement)
+	}
+	return history, cursor.Err()
+}
+
+// UpdateRating stores the review summary for a product
+func (r *MongoProductRepository) UpdateRating(ctx context.Context, id string, average float64, count int) error {
+	_, err := r.modify(ctx, id, func(change *stockChange) error {
+		change.product.AverageRating = average
+		change.product.ReviewCount = count
+		return nil
+	})
+	return err
+}
+
+// Count returns how many products are stored
+func (r *MongoProductRepository) Count(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	count, err := r.products.CountDocuments(ctx, bson.D{})
+	return int(count), err
+}
+
+// TagCounts returns every distinct tag with the number of products carrying it,
+// most used first and alphabetically within the same count
+func (r *MongoProductRepository) TagCounts(ctx context.Context) ([]models.TagCount, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	cursor, err := r.products.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$tags"}, {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tags := make([]models.TagCount, 0)
+	for cursor.Next(ctx) {
+		var bucket struct {
+			Tag   string `bson:"_id"`
+			Count int    `bson:"count"`
+		}
+		if err := cursor.Decode(&bucket); err != nil {
+			return nil, err
+		}
+		tags = append(tags, models.TagCount{Tag: bucket.Tag, Count: bucket.Count})
+	}
+	return tags, cursor.Err()
+}
+
+// Ping checks the deployment can be reached, for the readiness probe
+func (r *MongoProductRepository) Ping(ctx context.Context) error {
+	return r.db.Client().Ping(ctx, nil)
+}
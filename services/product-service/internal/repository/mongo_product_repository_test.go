@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+	"ecommerce/pkg/mongodb"
+	"product-service/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// newTestMongoProductRepository connects to the replica set at TEST_MONGODB_URI, skipping the test
+// when it isn't set. The product collections are emptied first, so point it at a throwaway deployment.
+func newTestMongoProductRepository(t *testing.T) *MongoProductRepository {
+	t.Helper()
+	uri := os.Getenv("TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGODB_URI is not set")
+	}
+
+	ctx := context.Background()
+	db, err := mongodb.Connect(ctx, uri, "product_service_test")
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	t.Cleanup(func() { db.Client().Disconnect(context.Background()) })
+
+	repo, err := NewMongoProductRepository(ctx, db)
+	if err != nil {
+		t.Fatalf("creating indexes: %v", err)
+	}
+	for _, name := range []string{"products", "stock_reservations", "stock_movements"} {
+		if _, err := db.Collection(name).DeleteMany(ctx, bson.D{}); err != nil {
+			t.Fatalf("emptying %s: %v", name, err)
+		}
+	}
+	return repo
+}
+
+func TestMongoProductRepository_CreateListAndTags(t *testing.T) {
+	repo := newTestMongoProductRepository(t)
+	laptop := models.NewProduct("Laptop", "", "Electronics", 1200, 5, "")
+	laptop.Tags = []string{"computers", "sale"}
+	mug := models.NewProduct("Mug", "", "Kitchen", 10, 0, "")
+	mug.Tags = []string{"sale"}
+	for _, product := range []*models.Product{laptop, mug} {
+		if err := repo.Create(context.Background(), product); err != nil {
+			t.Fatalf("create %s: %v", product.Name, err)
+		}
+	}
+	if err := repo.Create(context.Background(), models.NewProduct("LAPTOP", "", "Electronics", 1, 1, "")); err == nil || err.Error() != "product with this name already exists" {
+		t.Errorf("expected the name to clash regardless of case, got %v", err)
+	}
+	// Products without a SKU don't clash with each other, but a given SKU is only used once
+	laptop.SKU = "LAP-1"
+	if err := repo.Update(context.Background(), laptop); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	tablet := models.NewProduct("Tablet", "", "Electronics", 300, 1, "")
+	tablet.SKU = "LAP-1"
+	if err := repo.Create(context.Background(), tablet); err == nil || err.Error() != "product with this SKU already exists" {
+		t.Errorf("expected the SKU to clash, got %v", err)
+	}
+
+	products, info, err := repo.List(context.Background(), &models.ProductFilter{Category: "electronics"})
+	if err != nil || len(products) != 1 || products[0].ID != laptop.ID || info.Total != 1 {
+		t.Errorf("expected only the laptop in electronics, got %v, %+v, %v", products, info, err)
+	}
+	products, _, err = repo.List(context.Background(), &models.ProductFilter{InStock: true})
+	if err != nil || len(products) != 1 || products[0].ID != laptop.ID {
+		t.Errorf("expected only the laptop in stock, got %v, %v", products, err)
+	}
+
+	tags, err := repo.TagCounts(context.Background())
+	if err != nil || len(tags) != 2 || tags[0] != (models.TagCount{Tag: "sale", Count: 2}) {
+		t.Errorf("expected sale to be the most used tag, got %v, %v", tags, err)
+	}
+}
+
+func TestMongoProductRepository_ReserveReleaseAndHistory(t *testing.T) {
+	repo := newTestMongoProductRepository(t)
+	product := models.NewProduct("Limited Edition", "", "Electronics", 50, 10, "")
+	if err := repo.Create(context.Background(), product); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var reservations []*models.StockReservation
+	for i := 0; i < 15; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if reservation, err := repo.ReserveStock(context.Background(), product.ID, "", 1, time.Minute, "test"); err == nil {
+				mu.Lock()
+				reservations = append(reservations, reservation)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	stored, _ := repo.GetByID(context.Background(), product.ID)
+	if len(reservations) != 10 || stored.Stock != 0 {
+		t.Fatalf("expected exactly 10 reservations and no stock left, got %d reserved and stock %d", len(reservations), stored.Stock)
+	}
+
+	if _, err := repo.ReleaseReservation(context.Background(), product.ID, reservations[0].ID, "test"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if _, err := repo.ReleaseReservation(context.Background(), product.ID, reservations[0].ID, "test"); err != models.ErrReservationClosed {
+		t.Errorf("expected a second release to find the reservation closed, got %v", err)
+	}
+	stored, _ = repo.GetByID(context.Background(), product.ID)
+	if stored.Stock != 1 {
+		t.Errorf("expected one unit back in stock, got %d", stored.Stock)
+	}
+
+	history, err := repo.StockHistory(context.Background(), product.ID, 0)
+	if err != nil || len(history) != 12 {
+		t.Fatalf("expected the initial stock, 10 reservations, and a release, got %d movements, %v", len(history), err)
+	}
+	if history[0].Reason != models.StockReasonOrderReleased {
+		t.Errorf("expected the release to be newest, got %s", history[0].Reason)
+	}
+}
+
+func TestMongoProductRepository_ExpireReservations(t *testing.T) {
+	repo := newTestMongoProductRepository(t)
+	product := models.NewProduct("Flash Sale", "", "Electronics", 50, 3, "")
+	if err := repo.Create(context.Background(), product); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	reservation, err := repo.ReserveStock(context.Background(), product.ID, "order-1", 2, time.Minute, "test")
+	if err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+
+	expired, err := repo.ExpireReservations(context.Background(), time.Now().Add(2 * time.Minute))
+	if err != nil || expired != 1 {
+		t.Fatalf("expected one reservation expired, got %d, %v", expired, err)
+	}
+	if _, err := repo.CommitReservation(context.Background(), product.ID, reservation.ID, "test"); err != models.ErrReservationClosed {
+		t.Errorf("expected an expired reservation not to commit, got %v", err)
+	}
+	stored, _ := repo.GetByID(context.Background(), product.ID)
+	if stored.Stock != 3 {
+		t.Errorf("expected all stock back, got %d", stored.Stock)
+	}
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+	"ecommerce/pkg/mongodb"
+	"product-service/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ReserveStock atomically takes quantity units out of a product's stock and records a held
+// reservation for them. It fails with ErrInsufficientStock rather than letting stock go negative.
+func (r *MongoProductRepository) ReserveStock(ctx context.Context, productID, orderID string, quantity int, ttl time.Duration, actor string) (*models.StockReservation, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	reservation := models.NewStockReservation(productID, orderID, quantity, ttl)
+	err := mongodb.InTransaction(ctx, r.db, func(ctx mongo.SessionContext) error {
+		_, _, err := r.modifyIn(ctx, productID, func(change *stockChange) error {
+			if change.product.Stock < quantity {
+				return models.ErrInsufficientStock
+			}
+			reservation.Allocations = change.takeStock(quantity, models.StockSource{
+				Actor:     actor,
+				Reason:    models.StockReasonOrderReserved,
+				Reference: reservationReference(reservation),
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		_, err = r.reservations.InsertOne(ctx, mongoReservationDocument{Key: reservation.ID, StockReservation: reservation})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// ReleaseReservation returns a reservation's units to stock. Committed reservations can be
+// released too (e.g. when a confirmed order is cancelled).
+func (r *MongoProductRepository) ReleaseReservation(ctx context.Context, productID, reservationID, actor string) (*models.StockReservation, error) {
+	return r.closeReservation(ctx, productID, reservationID, models.ReservationReleased, actor)
+}
+
+// CommitReservation marks held stock as sold so it no longer expires
+func (r *MongoProductRepository) CommitReservation(ctx context.Context, productID, reservationID, actor string) (*models.StockReservation, error) {
+	return r.closeReservation(ctx, productID, reservationID, models.ReservationCommitted, actor)
+}
+
+// closeReservation moves a reservation to released or committed, restocking when released. Two
+// callers racing to release the same reservation both write it, so one transaction conflicts and
+// is run again, finding the reservation already closed; its stock is only returned once.
+func (r *MongoProductRepository) closeReservation(ctx context.Context, productID, reservationID string, status models.ReservationStatus, actor string) (*models.StockReservation, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	var reservation *models.StockReservation
+	var restocked *stockChange
+	var before int
+	err := mongodb.InTransaction(ctx, r.db, func(ctx mongo.SessionContext) error {
+		var err error
+		restocked = nil
+		reservation, err = r.loadReservation(ctx, reservationID)
+		if err != nil {
+			return err
+		}
+		if reservation.ProductID != productID {
+			return models.ErrReservationNotFound
+		}
+
+		now := time.Now()
+		restockActor := actor
+		switch {
+		// Expire lazily so a late commit can't sell stock that should already be back on the shelf
+		case reservation.IsExpired(now):
+			reservation.Status = models.ReservationExpired
+			restockActor = models.SystemActor
+		case status == models.ReservationCommitted && reservation.Status == models.ReservationHeld:
+			reservation.Status = models.ReservationCommitted
+		case status == models.ReservationReleased && (reservation.Status == models.ReservationHeld || reservation.Status == models.ReservationCommitted):
+			reservation.Status = models.ReservationReleased
+		default:
+			return models.ErrReservationClosed
+		}
+		reservation.UpdatedAt = now
+
+		if reservation.IsReturned() {
+			restocked, before, err = r.returnReservationIn(ctx, reservation, restockActor)
+			if err != nil {
+				return err
+			}
+		}
+		return r.saveReservation(ctx, reservation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if restocked != nil {
+		r.checkRestock(restocked.product, before)
+	}
+	if reservation.Status != status {
+		return nil, models.ErrReservationClosed
+	}
+	return reservation, nil
+}
+
+// ExpireReservations returns the stock of every held reservation that has expired by now
+// and reports how many were expired. Returned reservations older than the retention period are dropped.
+func (r *MongoProductRepository) ExpireReservations(ctx context.Context, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	cursor, err := r.reservations.Find(ctx,
+		bson.D{{Key: "status", Value: models.ReservationHeld}, {Key: "expires_at", Value: bson.D{{Key: "$lte", Value: now}}}},
+		options.Find().SetLimit(dbMaxExpiries).SetProjection(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return 0, err
+	}
+	var keys []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, key := range keys {
+		var restocked *stockChange
+		var before int
+		var counted bool
+		err := mongodb.InTransaction(ctx, r.db, func(ctx mongo.SessionContext) error {
+			restocked, counted = nil, false
+			reservation, err := r.loadReservation(ctx, key.ID)
+			// The reservation was committed or released meanwhile, which settles it
+			if errors.Is(err, models.ErrReservationNotFound) || (err == nil && !reservation.IsExpired(now)) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			reservation.Status = models.ReservationExpired
+			reservation.UpdatedAt = now
+
+			restocked, before, err = r.returnReservationIn(ctx, reservation, models.SystemActor)
+			if err != nil {
+				return err
+			}
+			if err := r.saveReservation(ctx, reservation); err != nil {
+				return err
+			}
+			counted = true
+			return nil
+		})
+		if err != nil {
+			return expired, err
+		}
+		if counted {
+			expired++
+		}
+		if restocked != nil {
+			r.checkRestock(restocked.product, before)
+		}
+	}
+
+	_, err = r.reservations.DeleteMany(ctx, bson.D{
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{models.ReservationReleased, models.ReservationExpired}}}},
+		{Key: "updated_at", Value: bson.D{{Key: "$lt", Value: now.Add(-reservationRetention)}}},
+	})
+	return expired, err
+}
+
+// loadReservation reads a stored reservation
+func (r *MongoProductRepository) loadReservation(ctx context.Context, id string) (*models.StockReservation, error) {
+	document := mongoReservationDocument{StockReservation: &models.StockReservation{}}
+	err := r.reservations.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, models.ErrReservationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return document.StockReservation, nil
+}
+
+// saveReservation writes back a changed reservation
+func (r *MongoProductRepository) saveReservation(ctx context.Context, reservation *models.StockReservation) error {
+	_, err := r.reservations.ReplaceOne(ctx, bson.D{{Key: "_id", Value: reservation.ID}}, mongoReservationDocument{Key: reservation.ID, StockReservation: reservation})
+	return err
+}
+
+// returnReservationIn puts a reservation's units back into the warehouses they came from and
+// returns the change with the stock the product had before it. A product deleted since the
+// reservation was made has nothing to return to, so the change is nil.
+func (r *MongoProductRepository) returnReservationIn(ctx mongo.SessionContext, reservation *models.StockReservation, actor string) (*stockChange, int, error) {
+	reason := models.StockReasonOrderReleased
+	if reservation.Status == models.ReservationExpired {
+		reason = models.StockReasonReservationExpired
+	}
+	change, before, err := r.modifyIn(ctx, reservation.ProductID, func(change *stockChange) error {
+		change.returnStock(reservation.Allocations, models.StockSource{
+			Actor:     actor,
+			Reason:    reason,
+			Reference: reservationReference(reservation),
+		})
+		return nil
+	})
+	if errors.Is(err, errProductNotFound) {
+		return nil, 0, nil
+	}
+	return change, before, err
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"product-service/internal/models"
+)
+
+// paginateProducts sorts products according to the filter and cuts out the requested page.
+// Results are always ordered deterministically, with the product ID as a tiebreaker.
+func paginateProducts(products []*models.Product, filter *models.ProductFilter) ([]*models.Product, *models.PageInfo, error) {
+	if filter == nil {
+		filter = &models.ProductFilter{}
+	}
+
+	sortField, order, err := sortOptions(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	desc := order == models.SortDesc
+
+	sort.Slice(products, func(i, j int) bool {
+		cmp := compareProducts(products[i], products[j], sortField)
+		if desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+
+	total := len(products)
+	info := &models.PageInfo{Limit: filter.Limit, Total: total}
+
+	// No limit means the whole (sorted) result set
+	if filter.Limit <= 0 {
+		info.Limit = total
+		return products, info, nil
+	}
+
+	start := 0
+	switch {
+	case filter.Cursor != "":
+		cursor, err := models.DecodeCursor(filter.Cursor)
+		if err != nil || cursor.Sort != sortField || cursor.Order != order {
+			return nil, nil, models.ErrInvalidCursor
+		}
+		start = sort.Search(total, func(i int) bool {
+			cmp, err := compareToCursor(products[i], cursor, sortField)
+			if err != nil {
+				return false
+			}
+			if desc {
+				return cmp < 0
+			}
+			return cmp > 0
+		})
+	default:
+		page := filter.Page
+		if page < 1 {
+			page = 1
+		}
+		start = (page - 1) * filter.Limit
+		info.Page = page
+		info.TotalPages = (total + filter.Limit - 1) / filter.Limit
+	}
+
+	if start > total {
+		start = total
+	}
+	end := start + filter.Limit
+	if end > total {
+		end = total
+	}
+
+	page := products[start:end]
+	info.HasMore = end < total
+	if info.HasMore && len(page) > 0 {
+		last := page[len(page)-1]
+		info.NextCursor = models.Cursor{
+			Sort:  sortField,
+			Order: order,
+			Value: sortValue(last, sortField),
+			ID:    last.ID,
+		}.Encode()
+	}
+
+	return page, info, nil
+}
+
+// sortOptions returns the filter's sort field and direction, applying the defaults
+func sortOptions(filter *models.ProductFilter) (string, string, error) {
+	sortField := strings.ToLower(filter.Sort)
+	if sortField == "" {
+		sortField = models.SortByCreatedAt
+	}
+	if sortField != models.SortByCreatedAt && sortField != models.SortByPrice {
+		return "", "", errors.New("invalid sort field")
+	}
+
+	order := strings.ToLower(filter.Order)
+	if order == "" {
+		order = models.SortAsc
+	}
+	if order != models.SortAsc && order != models.SortDesc {
+		return "", "", errors.New("invalid sort order")
+	}
+	return sortField, order, nil
+}
+
+// compareProducts orders two products by the sort field, then by ID
+func compareProducts(a, b *models.Product, sortField string) int {
+	var cmp int
+	switch sortField {
+	case models.SortByPrice:
+		cmp = compareFloat(a.EffectivePrice, b.EffectivePrice)
+	default:
+		cmp = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if cmp != 0 {
+		return cmp
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// compareToCursor orders a product relative to the position a cursor marks
+func compareToCursor(p *models.Product, cursor *models.Cursor, sortField string) (int, error) {
+	var cmp int
+	switch sortField {
+	case models.SortByPrice:
+		value, err := strconv.ParseFloat(cursor.Value, 64)
+		if err != nil {
+			return 0, err
+		}
+		cmp = compareFloat(p.EffectivePrice, value)
+	default:
+		value, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return 0, err
+		}
+		cmp = p.CreatedAt.Compare(value)
+	}
+	if cmp != 0 {
+		return cmp, nil
+	}
+	return strings.Compare(p.ID, cursor.ID), nil
+}
+
+// sortValue returns the string form of a product's sort key for embedding in a cursor
+func sortValue(p *models.Product, sortField string) string {
+	if sortField == models.SortByPrice {
+		return strconv.FormatFloat(p.EffectivePrice, 'f', -1, 64)
+	}
+	return p.CreatedAt.Format(time.RFC3339Nano)
+}
+
+// compareFloat returns -1, 0, or 1 comparing two floats
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,510 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"ecommerce/pkg/postgres"
+	"product-service/internal/models"
+)
+
+// productsNameKey is the unique constraint that keeps product names distinct regardless of case
+const productsNameKey = "products_name_key"
+
+// Products are stored as the same documents the Elasticsearch store indexes, next to the columns
+// they are looked up by. Reservations and stock movements keep their JSON form the same way.
+var postgresProductSchema = []string{
+	`CREATE TABLE IF NOT EXISTS products (
+		id           TEXT PRIMARY KEY,
+		name_key     TEXT NOT NULL CONSTRAINT products_name_key UNIQUE,
+		category_id  TEXT NOT NULL DEFAULT '',
+		category_key TEXT NOT NULL DEFAULT '',
+		document     JSONB NOT NULL,
+		created_at   TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS products_category_id_idx ON products (category_id)`,
+	`CREATE INDEX IF NOT EXISTS products_category_key_idx ON products (category_key)`,
+	`CREATE TABLE IF NOT EXISTS stock_reservations (
+		id         TEXT PRIMARY KEY,
+		product_id TEXT NOT NULL,
+		status     TEXT NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL,
+		document   JSONB NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS stock_reservations_status_idx ON stock_reservations (status, expires_at)`,
+	`CREATE TABLE IF NOT EXISTS stock_movements (
+		seq        BIGSERIAL PRIMARY KEY,
+		product_id TEXT NOT NULL,
+		document   JSONB NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS stock_movements_product_idx ON stock_movements (product_id, seq)`,
+}
+
+// PostgresProductRepository implements ProductRepository on a PostgreSQL database. Every stock
+// change locks the product's row, so adjustments from several service instances are applied one
+// after another, and the stock, its history, and any reservation are written in one transaction.
+type PostgresProductRepository struct {
+	db        *sql.DB
+	onRestock RestockListener
+	mutex     sync.RWMutex // guards onRestock
+}
+
+// NewPostgresProductRepository creates a repository on db, creating its tables if they don't exist yet
+func NewPostgresProductRepository(ctx context.Context, db *sql.DB) (*PostgresProductRepository, error) {
+	if err := postgres.Migrate(ctx, db, "products", postgresProductSchema); err != nil {
+		return nil, err
+	}
+	return &PostgresProductRepository{db: db}, nil
+}
+
+// Create adds a new product, rejecting names already in use regardless of case
+func (r *PostgresProductRepository) Create(ctx context.Context, product *models.Product) error {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	stored := product.Clone()
+	if len(stored.Inventory) == 0 && stored.Stock > 0 {
+		stored.SetWarehouseQuantity(models.DefaultWarehouseID, stored.Stock)
+	}
+	document, err := json.Marshal(newProductDocument(stored))
+	if err != nil {
+		return err
+	}
+
+	movements := make([]*models.StockMovement, 0, len(stored.Inventory))
+	for _, level := range stored.Inventory {
+		if level.Quantity != 0 {
+			movements = append(movements, models.NewStockMovement(stored.ID, level.WarehouseID, level.Quantity, stored.Stock, models.StockSource{Reason: models.StockReasonInitial}))
+		}
+	}
+
+	// Documents go in as strings: lib/pq sends a []byte as bytea, which a JSONB column won't accept
+	err = postgres.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO products (id, name_key, category_id, category_key, document, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+			stored.ID, strings.ToLower(stored.Name), stored.CategoryID, strings.ToLower(stored.Category), string(document), stored.CreatedAt)
+		if err != nil {
+			return err
+		}
+		return recordPostgresMovements(ctx, tx, movements)
+	})
+	if postgres.IsUniqueViolation(err, productsNameKey) {
+		return errors.New("product with this name already exists")
+	}
+	if postgres.IsUniqueViolation(err, "products_pkey") {
+		return errors.New("product already exists")
+	}
+	return err
+}
+
+// GetByID retrieves a product by its ID, priced and published as of now
+func (r *PostgresProductRepository) GetByID(ctx context.Context, id string) (*models.Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	product, err := loadProduct(r.db.QueryRowContext(ctx, `SELECT document FROM products WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return present(product, time.Now()), nil
+}
+
+// loadProduct decodes the product document a query selected
+func loadProduct(row *sql.Row) (*models.Product, error) {
+	var document []byte
+	err := row.Scan(&document)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeProduct(document)
+}
+
+// Update modifies an existing product. Stock and inventory are left untouched.
+func (r *PostgresProductRepository) Update(ctx context.Context, product *models.Product) error {
+	_, err := r.modify(ctx, product.ID, func(change *stockChange) error {
+		updated := product.Clone()
+		updated.Stock = change.product.Stock
+		updated.Inventory = change.product.Inventory
+		change.product = updated
+		return nil
+	})
+	if postgres.IsUniqueViolation(err, productsNameKey) {
+		return errors.New("product with this name already exists")
+	}
+	return err
+}
+
+// Delete removes a product and its stock history
+func (r *PostgresProductRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	return postgres.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM products WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+		if deleted, err := result.RowsAffected(); err != nil || deleted == 0 {
+			if err != nil {
+				return err
+			}
+			return errProductNotFound
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM stock_movements WHERE product_id = $1`, id)
+		return err
+	})
+}
+
+// List returns products matching the filter, sorted and paginated as the filter requests.
+// Categories are matched in the database; prices, schedules, tags, and attributes are checked
+// against each product as the in-memory store does, so both answer alike.
+func (r *PostgresProductRepository) List(ctx context.Context, filter *models.ProductFilter) ([]*models.Product, *models.PageInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	query := `SELECT document FROM products`
+	var args []interface{}
+	switch {
+	case filter != nil && len(filter.CategoryIDs) > 0:
+		query += ` WHERE category_id = ANY($1)`
+		args = append(args, postgres.Array(filter.CategoryIDs))
+	case filter != nil && filter.Category != "":
+		query += ` WHERE category_key = $1`
+		args = append(args, strings.ToLower(filter.Category))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	// Sale prices and schedules are resolved once per call so every product is seen at the same instant
+	now := time.Now()
+	products := make([]*models.Product, 0)
+	for rows.Next() {
+		var document []byte
+		if err := rows.Scan(&document); err != nil {
+			return nil, nil, err
+		}
+		product, err := decodeProduct(document)
+		if err != nil {
+			return nil, nil, err
+		}
+		if matchesFilter(product, filter, now) {
+			products = append(products, present(product, now))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return paginateProducts(products, filter)
+}
+
+// Search returns the products containing every word of query. Products naming each word somewhere
+// are found in the database; they are ranked, and checked against the filter, as the in-memory
+// store does.
+func (r *PostgresProductRepository) Search(ctx context.Context, query string, filter *models.ProductFilter) ([]*models.Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	now := time.Now()
+	search := newProductSearch(query, filter)
+	if len(search.terms) == 0 {
+		return []*models.Product{}, nil
+	}
+	conditions := make([]string, len(search.terms))
+	args := make([]interface{}, len(search.terms))
+	for i, term := range search.terms {
+		conditions[i] = fmt.Sprintf(`(document->>'name' ILIKE $%[1]d OR document->>'category' ILIKE $%[1]d OR document->>'description' ILIKE $%[1]d)`, i+1)
+		args[i] = "%" + term + "%" // terms are letters and digits only, so need no escaping
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT document FROM products WHERE `+strings.Join(conditions, " AND "), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var document []byte
+		if err := rows.Scan(&document); err != nil {
+			return nil, err
+		}
+		product, err := decodeProduct(document)
+		if err != nil {
+			return nil, err
+		}
+		if matchesFilter(product, filter, now) {
+			search.consider(product)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	products := search.results()
+	for i, product := range products {
+		products[i] = present(product, now)
+	}
+	return products, nil
+}
+
+// GetByCategory retrieves all products in a specific category
+func (r *PostgresProductRepository) GetByCategory(ctx context.Context, category string) ([]*models.Product, error) {
+	products, _, err := r.List(ctx, &models.ProductFilter{Category: category})
+	return products, err
+}
+
+// modify locks the stored product, applies apply to it, and writes it back along with the stock
+// movements apply noted, all in one transaction. It returns the product as written.
+func (r *PostgresProductRepository) modify(ctx context.Context, id string, apply func(change *stockChange) error) (*models.Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	var change *stockChange
+	var before int
+	err := postgres.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		var err error
+		change, before, err = modifyInTx(ctx, tx, id, apply)
+		return err
+	})
+	if err != nil {
+		if change != nil {
+			return change.product, err
+		}
+		return nil, err
+	}
+
+	r.checkRestock(change.product, before)
+	return change.product, nil
+}
+
+// modifyInTx is modify within a transaction the caller owns. It returns the change, and the stock
+// the product had before it, even when apply fails.
+func modifyInTx(ctx context.Context, tx *sql.Tx, id string, apply func(change *stockChange) error) (*stockChange, int, error) {
+	product, err := loadProduct(tx.QueryRowContext(ctx, `SELECT document FROM products WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	before := product.Stock
+	change := &stockChange{product: product}
+	if err := apply(change); err != nil {
+		return change, before, err
+	}
+
+	document, err := json.Marshal(newProductDocument(change.product))
+	if err != nil {
+		return change, before, err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE products SET name_key = $2, category_id = $3, category_key = $4, document = $5 WHERE id = $1`,
+		id, strings.ToLower(change.product.Name), change.product.CategoryID, strings.ToLower(change.product.Category), string(document))
+	if err != nil {
+		return change, before, err
+	}
+	return change, before, recordPostgresMovements(ctx, tx, change.movements)
+}
+
+// recordPostgresMovements adds stock changes to the history, trimming each product's history to
+// its newest MaxStockHistory changes
+func recordPostgresMovements(ctx context.Context, tx *sql.Tx, movements []*models.StockMovement) error {
+	if len(movements) == 0 {
+		return nil
+	}
+	for _, movement := range movements {
+		document, err := json.Marshal(movement)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO stock_movements (product_id, document) VALUES ($1, $2)`, movement.ProductID, string(document)); err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM stock_movements WHERE product_id = $1 AND seq <= (
+		SELECT seq FROM stock_movements WHERE product_id = $1 ORDER BY seq DESC OFFSET $2 LIMIT 1)`,
+		movements[0].ProductID, MaxStockHistory)
+	return err
+}
+
+// OnRestock registers the listener told when a product comes back into stock
+func (r *PostgresProductRepository) OnRestock(listener RestockListener) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.onRestock = listener
+}
+
+// checkRestock tells the restock listener if a product that had no stock before a change now has some
+func (r *PostgresProductRepository) checkRestock(product *models.Product, before int) {
+	r.mutex.RLock()
+	listener := r.onRestock
+	r.mutex.RUnlock()
+
+	if before <= 0 && product.Stock > 0 && listener != nil {
+		go listener(present(product, time.Now()))
+	}
+}
+
+// UpdateStock sets the stock held at the default warehouse
+func (r *PostgresProductRepository) UpdateStock(ctx context.Context, id string, quantity int, source models.StockSource) error {
+	return r.SetWarehouseStock(ctx, id, models.DefaultWarehouseID, quantity, source)
+}
+
+// SetWarehouseStock sets the quantity of a product held at one warehouse
+func (r *PostgresProductRepository) SetWarehouseStock(ctx context.Context, id, warehouseID string, quantity int, source models.StockSource) error {
+	_, err := r.modify(ctx, id, func(change *stockChange) error {
+		if quantity < 0 {
+			return errors.New("stock quantity cannot be negative")
+		}
+		change.setQuantity(warehouseID, quantity, source)
+		return nil
+	})
+	return err
+}
+
+// AdjustStock atomically adds delta (which may be negative) to a product's stock and returns
+// the new total. Additions go to the default warehouse; removals draw from the best-stocked
+// warehouses first. Adjustments that would take stock below zero fail with ErrInsufficientStock.
+func (r *PostgresProductRepository) AdjustStock(ctx context.Context, id string, delta int, source models.StockSource) (int, error) {
+	product, err := r.modify(ctx, id, func(change *stockChange) error {
+		if change.product.Stock+delta < 0 {
+			return models.ErrInsufficientStock
+		}
+		if delta >= 0 {
+			change.setQuantity(models.DefaultWarehouseID, change.product.WarehouseQuantity(models.DefaultWarehouseID)+delta, source)
+		} else {
+			change.takeStock(-delta, source)
+		}
+		return nil
+	})
+	return stockOf(product), err
+}
+
+// AdjustWarehouseStock atomically adds delta to the quantity held at one warehouse and returns
+// the product's new total, failing with ErrInsufficientStock if that warehouse would go negative
+func (r *PostgresProductRepository) AdjustWarehouseStock(ctx context.Context, id, warehouseID string, delta int, source models.StockSource) (int, error) {
+	product, err := r.modify(ctx, id, func(change *stockChange) error {
+		quantity := change.product.WarehouseQuantity(warehouseID)
+		if quantity+delta < 0 {
+			return models.ErrInsufficientStock
+		}
+		change.setQuantity(warehouseID, quantity+delta, source)
+		return nil
+	})
+	return stockOf(product), err
+}
+
+// TransferStock atomically moves quantity units of a product from one warehouse to another
+func (r *PostgresProductRepository) TransferStock(ctx context.Context, id, fromWarehouseID, toWarehouseID string, quantity int, source models.StockSource) (*models.Product, error) {
+	product, err := r.modify(ctx, id, func(change *stockChange) error {
+		available := change.product.WarehouseQuantity(fromWarehouseID)
+		if available < quantity {
+			return models.ErrInsufficientStock
+		}
+		change.setQuantity(fromWarehouseID, available-quantity, source)
+		change.setQuantity(toWarehouseID, change.product.WarehouseQuantity(toWarehouseID)+quantity, source)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// StockHistory returns up to limit recorded stock changes for a product, newest first.
+// A limit of zero or less returns the whole retained history.
+func (r *PostgresProductRepository) StockHistory(ctx context.Context, productID string, limit int) ([]*models.StockMovement, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)`, productID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errProductNotFound
+	}
+	if limit <= 0 || limit > MaxStockHistory {
+		limit = MaxStockHistory
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT document FROM stock_movements WHERE product_id = $1 ORDER BY seq DESC LIMIT $2`, productID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]*models.StockMovement, 0)
+	for rows.Next() {
+		var document []byte
+		if err := rows.Scan(&document); err != nil {
+			return nil, err
+		}
+		var movement models.StockMovement
+		if err := json.Unmarshal(document, &movement); err != nil {
+			return nil, err
+		}
+		history = append(history, &movement)
+	}
+	return history, rows.Err()
+}
+
+// UpdateRating stores the review summary for a product
+func (r *PostgresProductRepository) UpdateRating(ctx context.Context, id string, average float64, count int) error {
+	_, err := r.modify(ctx, id, func(change *stockChange) error {
+		change.product.AverageRating = average
+		change.product.ReviewCount = count
+		return nil
+	})
+	return err
+}
+
+// Count returns how many products are stored
+func (r *PostgresProductRepository) Count(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM products`).Scan(&count)
+	return count, err
+}
+
+// TagCounts returns every distinct tag with the number of products carrying it,
+// most used first and alphabetically within the same count
+func (r *PostgresProductRepository) TagCounts(ctx context.Context) ([]models.TagCount, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT tag, COUNT(*) FROM products,
+		jsonb_array_elements_text(CASE jsonb_typeof(document->'tags') WHEN 'array' THEN document->'tags' ELSE '[]' END) AS tag
+		GROUP BY tag ORDER BY COUNT(*) DESC, tag`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]models.TagCount, 0)
+	for rows.Next() {
+		var tag models.TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// Ping checks the database can be reached, for the readiness probe
+func (r *PostgresProductRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+	"ecommerce/pkg/postgres"
+	"product-service/internal/models"
+)
+
+// ReserveStock atomically takes quantity units out of a product's stock and records a held
+// reservation for them. It fails with ErrInsufficientStock rather than letting stock go negative.
+func (r *PostgresProductRepository) ReserveStock(ctx context.Context, productID, orderID string, quantity int, ttl time.Duration, actor string) (*models.StockReservation, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	reservation := models.NewStockReservation(productID, orderID, quantity, ttl)
+	err := postgres.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		_, _, err := modifyInTx(ctx, tx, productID, func(change *stockChange) error {
+			if change.product.Stock < quantity {
+				return models.ErrInsufficientStock
+			}
+			reservation.Allocations = change.takeStock(quantity, models.StockSource{
+				Actor:     actor,
+				Reason:    models.StockReasonOrderReserved,
+				Reference: reservationReference(reservation),
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return saveReservation(ctx, tx, reservation, true)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// ReleaseReservation returns a reservation's units to stock. Committed reservations can be
+// released too (e.g. when a confirmed order is cancelled).
+func (r *PostgresProductRepository) ReleaseReservation(ctx context.Context, productID, reservationID, actor string) (*models.StockReservation, error) {
+	return r.closeReservation(ctx, productID, reservationID, models.ReservationReleased, actor)
+}
+
+// CommitReservation marks held stock as sold so it no longer expires
+func (r *PostgresProductRepository) CommitReservation(ctx context.Context, productID, reservationID, actor string) (*models.StockReservation, error) {
+	return r.closeReservation(ctx, productID, reservationID, models.ReservationCommitted, actor)
+}
+
+// closeReservation moves a reservation to released or committed, restocking when released. The
+// reservation's row is locked while it is settled, so two callers racing to release the same
+// reservation cannot both return its stock.
+func (r *PostgresProductRepository) closeReservation(ctx context.Context, productID, reservationID string, status models.ReservationStatus, actor string) (*models.StockReservation, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	var reservation *models.StockReservation
+	var restocked *stockChange
+	var before int
+	err := postgres.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		var err error
+		reservation, err = lockReservation(ctx, tx, reservationID)
+		if err != nil {
+			return err
+		}
+		if reservation.ProductID != productID {
+			return models.ErrReservationNotFound
+		}
+
+		now := time.Now()
+		restockActor := actor
+		switch {
+		// Expire lazily so a late commit can't sell stock that should already be back on the shelf
+		case reservation.IsExpired(now):
+			reservation.Status = models.ReservationExpired
+			restockActor = models.SystemActor
+		case status == models.ReservationCommitted && reservation.Status == models.ReservationHeld:
+			reservation.Status = models.ReservationCommitted
+		case status == models.ReservationReleased && (reservation.Status == models.ReservationHeld || reservation.Status == models.ReservationCommitted):
+			reservation.Status = models.ReservationReleased
+		default:
+			return models.ErrReservationClosed
+		}
+		reservation.UpdatedAt = now
+
+		if reservation.IsReturned() {
+			restocked, before, err = returnReservationInTx(ctx, tx, reservation, restockActor)
+			if err != nil {
+				return err
+			}
+		}
+		return saveReservation(ctx, tx, reservation, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if restocked != nil {
+		r.checkRestock(restocked.product, before)
+	}
+	if reservation.Status != status {
+		return nil, models.ErrReservationClosed
+	}
+	return reservation, nil
+}
+
+// ExpireReservations returns the stock of every held reservation that has expired by now
+// and reports how many were expired. Returned reservations older than the retention period are dropped.
+func (r *PostgresProductRepository) ExpireReservations(ctx context.Context, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT id FROM stock_reservations WHERE status = $1 AND expires_at <= $2 LIMIT $3`,
+		string(models.ReservationHeld), now, dbMaxExpiries)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		var restocked *stockChange
+		var before int
+		err := postgres.InTx(ctx, r.db, func(tx *sql.Tx) error {
+			reservation, err := lockReservation(ctx, tx, id)
+			// The reservation was committed or released meanwhile, which settles it
+			if errors.Is(err, models.ErrReservationNotFound) || (err == nil && !reservation.IsExpired(now)) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			reservation.Status = models.ReservationExpired
+			reservation.UpdatedAt = now
+
+			restocked, before, err = returnReservationInTx(ctx, tx, reservation, models.SystemActor)
+			if err != nil {
+				return err
+			}
+			if err := saveReservation(ctx, tx, reservation, false); err != nil {
+				return err
+			}
+			expired++
+			return nil
+		})
+		if err != nil {
+			return expired, err
+		}
+		if restocked != nil {
+			r.checkRestock(restocked.product, before)
+		}
+	}
+
+	_, err = r.db.ExecContext(ctx, `DELETE FROM stock_reservations WHERE status IN ($1, $2) AND updated_at < $3`,
+		string(models.ReservationReleased), string(models.ReservationExpired), now.Add(-reservationRetention))
+	return expired, err
+}
+
+// lockReservation reads a reservation and locks it until the transaction ends
+func lockReservation(ctx context.Context, tx *sql.Tx, id string) (*models.StockReservation, error) {
+	var document []byte
+	err := tx.QueryRowContext(ctx, `SELECT document FROM stock_reservations WHERE id = $1 FOR UPDATE`, id).Scan(&document)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrReservationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var reservation models.StockReservation
+	if err := json.Unmarshal(document, &reservation); err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// saveReservation inserts a new reservation or writes back a changed one
+func saveReservation(ctx context.Context, tx *sql.Tx, reservation *models.StockReservation, create bool) error {
+	document, err := json.Marshal(reservation)
+	if err != nil {
+		return err
+	}
+	if create {
+		_, err = tx.ExecContext(ctx, `INSERT INTO stock_reservations (id, product_id, status, expires_at, updated_at, document) VALUES ($1, $2, $3, $4, $5, $6)`,
+			reservation.ID, reservation.ProductID, string(reservation.Status), reservation.ExpiresAt, reservation.UpdatedAt, string(document))
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE stock_reservations SET status = $2, updated_at = $3, document = $4 WHERE id = $1`,
+		reservation.ID, string(reservation.Status), reservation.UpdatedAt, string(document))
+	return err
+}
+
+// returnReservationInTx puts a reservation's units back into the warehouses they came from and
+// returns the change with the stock the product had before it. A product deleted since the
+// reservation was made has nothing to return to, so the change is nil.
+func returnReservationInTx(ctx context.Context, tx *sql.Tx, reservation *models.StockReservation, actor string) (*stockChange, int, error) {
+	reason := models.StockReasonOrderReleased
+	if reservation.Status == models.ReservationExpired {
+		reason = models.StockReasonReservationExpired
+	}
+	change, before, err := modifyInTx(ctx, tx, reservation.ProductID, func(change *stockChange) error {
+		change.returnStock(reservation.Allocations, models.StockSource{
+			Actor:     actor,
+			Reason:    reason,
+			Reference: reservationReference(reservation),
+		})
+		return nil
+	})
+	if errors.Is(err, errProductNotFound) {
+		return nil, 0, nil
+	}
+	return change, before, err
+}
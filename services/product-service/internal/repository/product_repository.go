@@ -1,57 +1,86 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"sort"
 	"strings"
 	"sync"
+	"time"
+	"ecommerce/pkg/snapshot"
 	"product-service/internal/models"
 )
 
-// ProductRepository defines the interface for product data operations
+// ProductRepository defines the interface for product data operations. Implementations backed by a
+// database should abandon a query once ctx is done.
 type ProductRepository interface {
-	Create(product *models.Product) error
-	GetByID(id string) (*models.Product, error)
-	Update(product *models.Product) error
-	Delete(id string) error
-	List(filter *models.ProductFilter) ([]*models.Product, error)
-	GetByCategory(category string) ([]*models.Product, error)
-	UpdateStock(id string, quantity int) error
+	Create(ctx context.Context, product *models.Product) error
+	GetByID(ctx context.Context, id string) (*models.Product, error)
+	Update(ctx context.Context, product *models.Product) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context, filter *models.ProductFilter) ([]*models.Product, *models.PageInfo, error)
+	// Search returns the products whose name, category, or description contain every word of query
+	// and that pass the filter, most relevant first, at most filter.Limit of them (all when 0).
+	// Pagination and sorting in the filter are ignored.
+	Search(ctx context.Context, query string, filter *models.ProductFilter) ([]*models.Product, error)
+	GetByCategory(ctx context.Context, category string) ([]*models.Product, error)
+	UpdateStock(ctx context.Context, id string, quantity int, source models.StockSource) error
+	AdjustStock(ctx context.Context, id string, delta int, source models.StockSource) (int, error)
+	SetWarehouseStock(ctx context.Context, id, warehouseID string, quantity int, source models.StockSource) error
+	AdjustWarehouseStock(ctx context.Context, id, warehouseID string, delta int, source models.StockSource) (int, error)
+	TransferStock(ctx context.Context, id, fromWarehouseID, toWarehouseID string, quantity int, source models.StockSource) (*models.Product, error)
+	StockHistory(ctx context.Context, productID string, limit int) ([]*models.StockMovement, error)
+	UpdateRating(ctx context.Context, id string, average float64, count int) error
+	TagCounts(ctx context.Context) ([]models.TagCount, error)
+	// Count returns how many products are stored, unpublished ones included
+	Count(ctx context.Context) (int, error)
+	ReserveStock(ctx context.Context, productID, orderID string, quantity int, ttl time.Duration, actor string) (*models.StockReservation, error)
+	ReleaseReservation(ctx context.Context, productID, reservationID, actor string) (*models.StockReservation, error)
+	CommitReservation(ctx context.Context, productID, reservationID, actor string) (*models.StockReservation, error)
+	ExpireReservations(ctx context.Context, now time.Time) (int, error)
+	OnRestock(listener RestockListener)
 }
 
+// RestockListener is told about a product whose stock has gone from zero back to positive.
+// It is called on its own goroutine with a copy of the product.
+type RestockListener func(product *models.Product)
+
 // InMemoryProductRepository implements ProductRepository using in-memory storage
 type InMemoryProductRepository struct {
-	products map[string]*models.Product
-	mutex    sync.RWMutex
+	products     map[string]*models.Product
+	reservations map[string]*models.StockReservation
+	movements    map[string][]*models.StockMovement // stock history per product, oldest first
+	onRestock    RestockListener
+	mutex        sync.RWMutex
+	// journal logs every change once PersistTo is called; nil otherwise
+	journal *snapshot.Store[productSnapshot, productChange]
+	// unlogged holds the stock movements recorded since the last logged change
+	unlogged []*models.StockMovement
+	// index finds products by the words in their name, category, and description
+	index searchIndex
 }
 
-// NewInMemoryProductRepository creates a new in-memory product repository with sample data
-func NewInMemoryProductRepository() *InMemoryProductRepository {
-	repo := &InMemoryProductRepository{
-		products: make(map[string]*models.Product),
-	}
-
-	// Add sample products
-	repo.seedData()
-	return repo
-}
+// MaxStockHistory is how many stock changes are kept per product
+const MaxStockHistory = 1000
 
-// seedData adds sample products to the repository
-func (r *InMemoryProductRepository) seedData() {
-	sampleProducts := []*models.Product{
-		models.NewProduct("MacBook Pro 16\"", "Apple MacBook Pro with M3 chip", "Electronics", 2499.99, 10, "https://example.com/macbook.jpg"),
-		models.NewProduct("iPhone 15 Pro", "Latest iPhone with titanium design", "Electronics", 999.99, 25, "https://example.com/iphone.jpg"),
-		models.NewProduct("Nike Air Max", "Comfortable running shoes", "Footwear", 129.99, 50, "https://example.com/nike.jpg"),
-		models.NewProduct("Coffee Maker", "Automatic drip coffee maker", "Appliances", 89.99, 15, "https://example.com/coffee.jpg"),
-		models.NewProduct("Wireless Headphones", "Noise-cancelling Bluetooth headphones", "Electronics", 199.99, 30, "https://example.com/headphones.jpg"),
-	}
+// Tuning for the PostgreSQL- and MongoDB-backed product repositories
+const (
+	dbQueryTimeout = 10 * time.Second // bounds each repository call within the caller's context
+	dbMaxExpiries  = 1000             // reservations expired per sweep; the rest wait for the next one
+)
 
-	for _, product := range sampleProducts {
-		r.products[product.ID] = product
+// NewInMemoryProductRepository creates an empty in-memory product repository; SeedProducts fills
+// it with sample products
+func NewInMemoryProductRepository() *InMemoryProductRepository {
+	return &InMemoryProductRepository{
+		products:     make(map[string]*models.Product),
+		reservations: make(map[string]*models.StockReservation),
+		movements:    make(map[string][]*models.StockMovement),
 	}
 }
 
 // Create adds a new product to the repository
-func (r *InMemoryProductRepository) Create(product *models.Product) error {
+func (r *InMemoryProductRepository) Create(ctx context.Context, product *models.Product) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
@@ -62,12 +91,26 @@ func (r *InMemoryProductRepository) Create(product *models.Product) error {
 		}
 	}
 
-	r.products[product.ID] = product
+	stored := product.Clone()
+	if len(stored.Inventory) == 0 && stored.Stock > 0 {
+		stored.SetWarehouseQuantity(models.DefaultWarehouseID, stored.Stock)
+	}
+	r.products[product.ID] = stored
+	r.index.add(stored)
+	r.recordInitialStock(stored)
+	r.logProduct(stored, nil)
 	return nil
 }
 
+// recordInitialStock records the stock a product was created with; the caller must hold the write lock
+func (r *InMemoryProductRepository) recordInitialStock(product *models.Product) {
+	for _, level := range product.Inventory {
+		r.recordMovement(product, level.WarehouseID, level.Quantity, models.StockSource{Reason: models.StockReasonInitial})
+	}
+}
+
 // GetByID retrieves a product by its ID
-func (r *InMemoryProductRepository) GetByID(id string) (*models.Product, error) {
+func (r *InMemoryProductRepository) GetByID(ctx context.Context, id string) (*models.Product, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
@@ -76,26 +119,32 @@ func (r *InMemoryProductRepository) GetByID(id string) (*models.Product, error)
 		return nil, errors.New("product not found")
 	}
 
-	// Return a copy to prevent external modification
-	productCopy := *product
-	return &productCopy, nil
+	// Return a copy to prevent external modification, priced and published as of now
+	return present(product, time.Now()), nil
 }
 
-// Update modifies an existing product
-func (r *InMemoryProductRepository) Update(product *models.Product) error {
+// Update modifies an existing product. Stock and inventory are left untouched.
+func (r *InMemoryProductRepository) Update(ctx context.Context, product *models.Product) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if _, exists := r.products[product.ID]; !exists {
+	existing, exists := r.products[product.ID]
+	if !exists {
 		return errors.New("product not found")
 	}
 
-	r.products[product.ID] = product
+	// Stock is only changed through the stock methods so a stale copy can't undo concurrent adjustments
+	updated := product.Clone()
+	updated.Stock = existing.Stock
+	updated.Inventory = append([]models.InventoryLevel{}, existing.Inventory...)
+	r.products[product.ID] = updated
+	r.index.add(updated)
+	r.logProduct(updated, nil)
 	return nil
 }
 
 // Delete removes a product from the repository
-func (r *InMemoryProductRepository) Delete(id string) error {
+func (r *InMemoryProductRepository) Delete(ctx context.Context, id string) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
@@ -104,48 +153,110 @@ func (r *InMemoryProductRepository) Delete(id string) error {
 	}
 
 	delete(r.products, id)
+	r.index.remove(id)
+	delete(r.movements, id)
+	r.log(productChange{DeletedProductID: id})
 	return nil
 }
 
-// List returns all products, optionally filtered
-func (r *InMemoryProductRepository) List(filter *models.ProductFilter) ([]*models.Product, error) {
+// List returns products matching the filter, sorted and paginated as the filter requests
+func (r *InMemoryProductRepository) List(ctx context.Context, filter *models.ProductFilter) ([]*models.Product, *models.PageInfo, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	var products []*models.Product
+	// Sale prices and schedules are resolved once per call so every product is seen at the same instant
+	now := time.Now()
+	products := make([]*models.Product, 0, len(r.products))
 	for _, product := range r.products {
-		// Apply filters if provided
-		if filter != nil {
-			if filter.Category != "" && !strings.EqualFold(product.Category, filter.Category) {
-				continue
-			}
-			if filter.MinPrice > 0 && product.Price < filter.MinPrice {
-				continue
-			}
-			if filter.MaxPrice > 0 && product.Price > filter.MaxPrice {
-				continue
-			}
-			if filter.InStock && product.Stock <= 0 {
-				continue
-			}
+		if !matchesFilter(product, filter, now) {
+			continue
 		}
-
 		// Create a copy to prevent external modification
-		productCopy := *product
-		products = append(products, &productCopy)
+		products = append(products, present(product, now))
 	}
 
+	return paginateProducts(products, filter)
+}
+
+// Search returns the products containing every word of query, found with the repository's
+// inverted index and ranked by where the words appear
+func (r *InMemoryProductRepository) Search(ctx context.Context, query string, filter *models.ProductFilter) ([]*models.Product, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	now := time.Now()
+	search := newProductSearch(query, filter)
+	for id, score := range r.index.match(search.terms) {
+		if product := r.products[id]; matchesFilter(product, filter, now) {
+			search.add(product, score)
+		}
+	}
+
+	found := search.results()
+	products := make([]*models.Product, 0, len(found))
+	for _, product := range found {
+		// Create a copy to prevent external modification
+		products = append(products, present(product, now))
+	}
 	return products, nil
 }
 
+// matchesFilter reports whether a stored product passes the filter, with prices and status as of now
+func matchesFilter(product *models.Product, filter *models.ProductFilter, now time.Time) bool {
+	if filter == nil {
+		return true
+	}
+	if len(filter.CategoryIDs) > 0 {
+		if !containsString(filter.CategoryIDs, product.CategoryID) {
+			return false
+		}
+	} else if filter.Category != "" && !strings.EqualFold(product.Category, filter.Category) {
+		return false
+	}
+	price := product.PriceAt(now)
+	if filter.MinPrice > 0 && price < filter.MinPrice {
+		return false
+	}
+	if filter.MaxPrice > 0 && price > filter.MaxPrice {
+		return false
+	}
+	if filter.InStock && !product.IsInStock() {
+		return false
+	}
+	if len(filter.Tags) > 0 && !product.HasTags(filter.Tags) {
+		return false
+	}
+	if len(filter.Attributes) > 0 && !product.HasAttributes(filter.Attributes) {
+		return false
+	}
+	if filter.Status != "" && product.StatusAt(now) != filter.Status {
+		return false
+	}
+	return true
+}
+
+// present returns a copy of a stored product with its price and status worked out for the given time
+func present(product *models.Product, now time.Time) *models.Product {
+	productCopy := product.Clone()
+	productCopy.ApplyPricing(now)
+	productCopy.ResolveStatus(now)
+	return productCopy
+}
+
 // GetByCategory retrieves all products in a specific category
-func (r *InMemoryProductRepository) GetByCategory(category string) ([]*models.Product, error) {
+func (r *InMemoryProductRepository) GetByCategory(ctx context.Context, category string) ([]*models.Product, error) {
 	filter := &models.ProductFilter{Category: category}
-	return r.List(filter)
+	products, _, err := r.List(ctx, filter)
+	return products, err
 }
 
-// UpdateStock updates the stock quantity for a product
-func (r *InMemoryProductRepository) UpdateStock(id string, quantity int) error {
+// UpdateStock sets the stock held at the default warehouse
+func (r *InMemoryProductRepository) UpdateStock(ctx context.Context, id string, quantity int, source models.StockSource) error {
+	return r.SetWarehouseStock(ctx, id, models.DefaultWarehouseID, quantity, source)
+}
+
+// SetWarehouseStock sets the quantity of a product held at one warehouse
+func (r *InMemoryProductRepository) SetWarehouseStock(ctx context.Context, id, warehouseID string, quantity int, source models.StockSource) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
@@ -158,6 +269,225 @@ func (r *InMemoryProductRepository) UpdateStock(id string, quantity int) error {
 		return errors.New("stock quantity cannot be negative")
 	}
 
-	product.Stock = quantity
+	defer r.checkRestock(product, product.Stock)
+	r.setQuantity(product, warehouseID, quantity, source)
+	r.logProduct(product, nil)
+	return nil
+}
+
+// AdjustStock atomically adds delta (which may be negative) to a product's stock and returns
+// the new total. Additions go to the default warehouse; removals draw from the best-stocked
+// warehouses first. Adjustments that would take stock below zero fail with ErrInsufficientStock.
+func (r *InMemoryProductRepository) AdjustStock(ctx context.Context, id string, delta int, source models.StockSource) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	product, exists := r.products[id]
+	if !exists {
+		return 0, errors.New("product not found")
+	}
+
+	if product.Stock+delta < 0 {
+		return product.Stock, models.ErrInsufficientStock
+	}
+
+	defer r.checkRestock(product, product.Stock)
+	if delta >= 0 {
+		r.setQuantity(product, models.DefaultWarehouseID, product.WarehouseQuantity(models.DefaultWarehouseID)+delta, source)
+	} else {
+		r.takeStock(product, -delta, source)
+	}
+	r.logProduct(product, nil)
+	return product.Stock, nil
+}
+
+// AdjustWarehouseStock atomically adds delta to the quantity held at one warehouse and returns
+// the product's new total, failing with ErrInsufficientStock if that warehouse would go negative
+func (r *InMemoryProductRepository) AdjustWarehouseStock(ctx context.Context, id, warehouseID string, delta int, source models.StockSource) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	product, exists := r.products[id]
+	if !exists {
+		return 0, errors.New("product not found")
+	}
+
+	quantity := product.WarehouseQuantity(warehouseID)
+	if quantity+delta < 0 {
+		return product.Stock, models.ErrInsufficientStock
+	}
+
+	defer r.checkRestock(product, product.Stock)
+	r.setQuantity(product, warehouseID, quantity+delta, source)
+	r.logProduct(product, nil)
+	return product.Stock, nil
+}
+
+// TransferStock atomically moves quantity units of a product from one warehouse to another
+func (r *InMemoryProductRepository) TransferStock(ctx context.Context, id, fromWarehouseID, toWarehouseID string, quantity int, source models.StockSource) (*models.Product, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	product, exists := r.products[id]
+	if !exists {
+		return nil, errors.New("product not found")
+	}
+
+	available := product.WarehouseQuantity(fromWarehouseID)
+	if available < quantity {
+		return nil, models.ErrInsufficientStock
+	}
+
+	r.setQuantity(product, fromWarehouseID, available-quantity, source)
+	r.setQuantity(product, toWarehouseID, product.WarehouseQuantity(toWarehouseID)+quantity, source)
+	r.logProduct(product, nil)
+	return product.Clone(), nil
+}
+
+// StockHistory returns up to limit recorded stock changes for a product, newest first.
+// A limit of zero or less returns the whole retained history.
+func (r *InMemoryProductRepository) StockHistory(ctx context.Context, productID string, limit int) ([]*models.StockMovement, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if _, exists := r.products[productID]; !exists {
+		return nil, errors.New("product not found")
+	}
+
+	movements := r.movements[productID]
+	if limit <= 0 || limit > len(movements) {
+		limit = len(movements)
+	}
+
+	history := make([]*models.StockMovement, 0, limit)
+	for i := len(movements) - 1; i >= 0 && len(history) < limit; i-- {
+		movementCopy := *movements[i]
+		history = append(history, &movementCopy)
+	}
+	return history, nil
+}
+
+// setQuantity sets the quantity at a warehouse and records the change; the caller must hold the write lock
+func (r *InMemoryProductRepository) setQuantity(product *models.Product, warehouseID string, quantity int, source models.StockSource) {
+	delta := quantity - product.WarehouseQuantity(warehouseID)
+	product.SetWarehouseQuantity(warehouseID, quantity)
+	r.recordMovement(product, warehouseID, delta, source)
+}
+
+// takeStock removes quantity across warehouses and records a change for each one drawn from;
+// the caller must hold the write lock and have checked there is enough stock
+func (r *InMemoryProductRepository) takeStock(product *models.Product, quantity int, source models.StockSource) []models.InventoryLevel {
+	taken := product.TakeStock(quantity)
+	for _, level := range taken {
+		r.recordMovement(product, level.WarehouseID, -level.Quantity, source)
+	}
+	return taken
+}
+
+// returnStock puts stock back into the warehouses it was taken from and records each change;
+// the caller must hold the write lock
+func (r *InMemoryProductRepository) returnStock(product *models.Product, levels []models.InventoryLevel, source models.StockSource) {
+	for _, level := range levels {
+		r.setQuantity(product, level.WarehouseID, product.WarehouseQuantity(level.WarehouseID)+level.Quantity, source)
+	}
+}
+
+// recordMovement appends a stock change to the product's history, trimming the oldest entries
+// beyond MaxStockHistory; the caller must hold the write lock
+func (r *InMemoryProductRepository) recordMovement(product *models.Product, warehouseID string, delta int, source models.StockSource) {
+	if delta == 0 {
+		return
+	}
+
+	movement := models.NewStockMovement(product.ID, warehouseID, delta, product.Stock, source)
+	r.appendMovement(movement)
+	if r.journal != nil {
+		r.unlogged = append(r.unlogged, movement)
+	}
+}
+
+// appendMovement adds a movement to its product's history, trimming the oldest entries beyond
+// MaxStockHistory; the caller must hold the write lock
+func (r *InMemoryProductRepository) appendMovement(movement *models.StockMovement) {
+	movements := append(r.movements[movement.ProductID], movement)
+	if len(movements) > MaxStockHistory {
+		movements = movements[len(movements)-MaxStockHistory:]
+	}
+	r.movements[movement.ProductID] = movements
+}
+
+// OnRestock registers the listener told when a product comes back into stock
+func (r *InMemoryProductRepository) OnRestock(listener RestockListener) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.onRestock = listener
+}
+
+// checkRestock tells the restock listener if a product that had no stock before a change now has some;
+// the caller must hold the write lock
+func (r *InMemoryProductRepository) checkRestock(product *models.Product, before int) {
+	if before <= 0 && product.Stock > 0 && r.onRestock != nil {
+		go r.onRestock(present(product, time.Now()))
+	}
+}
+
+// UpdateRating stores the review summary for a product
+func (r *InMemoryProductRepository) UpdateRating(ctx context.Context, id string, average float64, count int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	product, exists := r.products[id]
+	if !exists {
+		return errors.New("product not found")
+	}
+
+	product.AverageRating = average
+	product.ReviewCount = count
+	r.logProduct(product, nil)
 	return nil
 }
+
+// Count returns how many products are stored
+func (r *InMemoryProductRepository) Count(ctx context.Context) (int, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return len(r.products), nil
+}
+
+// TagCounts returns every distinct tag with the number of products carrying it,
+// most used first and alphabetically within the same count
+func (r *InMemoryProductRepository) TagCounts(ctx context.Context) ([]models.TagCount, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	counts := make(map[string]int)
+	for _, product := range r.products {
+		for _, tag := range product.Tags {
+			counts[tag]++
+		}
+	}
+
+	tags := make([]models.TagCount, 0, len(counts))
+	for tag, count := range counts {
+		tags = append(tags, models.TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags, nil
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
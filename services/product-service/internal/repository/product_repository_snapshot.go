@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"log/slog"
+	"ecommerce/pkg/snapshot"
+	"product-service/internal/models"
+)
+
+// productSnapshot is the state of an InMemoryProductRepository as saved on disk
+type productSnapshot struct {
+	Products     map[string]*models.Product
+	Reservations map[string]*models.StockReservation
+	Movements    map[string][]*models.StockMovement
+}
+
+// productChange is one logged change: a product or reservation as it now stands, the stock
+// movements recorded with it, or the ID of a deleted product or dropped reservation
+type productChange struct {
+	Product              *models.Product
+	Reservation          *models.StockReservation
+	Movements            []*models.StockMovement
+	DeletedProductID     string
+	DeletedReservationID string
+}
+
+// PersistTo keeps the repository's products, reservations, and stock history in dir, so they
+// survive a restart. What was saved there before replaces the products in memory; a fresh
+// directory starts from them. Call it before the repository is used, and before seeding it.
+func (r *InMemoryProductRepository) PersistTo(dir string) error {
+	journal, err := snapshot.Open[productSnapshot, productChange](dir, "products")
+	if err != nil {
+		return err
+	}
+	saved, changes, err := journal.Load()
+	if err != nil {
+		journal.Close()
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if saved != nil {
+		r.products = make(map[string]*models.Product)
+		r.reservations = make(map[string]*models.StockReservation)
+		r.movements = make(map[string][]*models.StockMovement)
+		for id, product := range saved.Products {
+			r.products[id] = product
+		}
+		for id, reservation := range saved.Reservations {
+			r.reservations[id] = reservation
+		}
+		for id, movements := range saved.Movements {
+			r.movements[id] = movements
+		}
+	}
+	for _, change := range changes {
+		r.replay(change)
+	}
+	r.index = searchIndex{}
+	for _, product := range r.products {
+		r.index.add(product)
+	}
+
+	r.journal = journal
+	return r.journal.Save(r.snapshotState())
+}
+
+// replay applies a logged change; the caller must hold the write lock
+func (r *InMemoryProductRepository) replay(change productChange) {
+	if change.Product != nil {
+		r.products[change.Product.ID] = change.Product
+	}
+	if change.Reservation != nil {
+		r.reservations[change.Reservation.ID] = change.Reservation
+	}
+	for _, movement := range change.Movements {
+		r.appendMovement(movement)
+	}
+	if change.DeletedProductID != "" {
+		delete(r.products, change.DeletedProductID)
+		delete(r.movements, change.DeletedProductID)
+	}
+	if change.DeletedReservationID != "" {
+		delete(r.reservations, change.DeletedReservationID)
+	}
+}
+
+// snapshotState is the repository's state to save; the caller must hold the lock
+func (r *InMemoryProductRepository) snapshotState() *productSnapshot {
+	return &productSnapshot{Products: r.products, Reservations: r.reservations, Movements: r.movements}
+}
+
+// Snapshot saves every product to disk, emptying the change log. It does nothing unless PersistTo was called.
+func (r *InMemoryProductRepository) Snapshot() error {
+	if r.journal == nil {
+		return nil
+	}
+	// Changes are logged under the write lock, so none slips in while the snapshot is saved
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.journal.Save(r.snapshotState())
+}
+
+// Close takes a last snapshot and closes the files PersistTo opened
+func (r *InMemoryProductRepository) Close() error {
+	if r.journal == nil {
+		return nil
+	}
+	err := r.Snapshot()
+	if closeErr := r.journal.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// logProduct logs a product's and a reservation's current state, either of which may be nil,
+// with the stock movements recorded since the last logged change. Callers hold the write lock.
+func (r *InMemoryProductRepository) logProduct(product *models.Product, reservation *models.StockReservation) {
+	r.log(productChange{Product: product, Reservation: reservation})
+}
+
+// log appends a change to the journal, with the movements not logged yet. A failed write is
+// logged rather than failing the change, which is already made in memory; the next snapshot
+// still saves it.
+func (r *InMemoryProductRepository) log(change productChange) {
+	if r.journal == nil {
+		return
+	}
+	change.Movements = r.unlogged
+	r.unlogged = nil
+	if err := r.journal.Append(change); err != nil {
+		slog.Error("Error logging a product change; it is kept until the next snapshot", "error", err)
+	}
+}
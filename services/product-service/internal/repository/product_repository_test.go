@@ -1,20 +1,27 @@
 package repository
 
 import (
+	"context"
+	"sync"
 	"testing"
+	"time"
 	"product-service/internal/models"
 )
 
 func TestInMemoryProductRepository_CreateAndGet(t *testing.T) {
 	repo := NewInMemoryProductRepository()
+	seeded, _ := repo.Count(context.Background())
 	p := models.NewProduct("Test Product", "Desc", "Category", 10.0, 5, "img")
-	if err := repo.Create(p); err != nil {
+	if err := repo.Create(context.Background(), p); err != nil {
 		t.Fatalf("create failed: %v", err)
 	}
-	if err := repo.Create(models.NewProduct("Test Product", "Desc2", "Category", 11.0, 2, "img2")); err == nil {
+	if err := repo.Create(context.Background(), models.NewProduct("Test Product", "Desc2", "Category", 11.0, 2, "img2")); err == nil {
 		t.Error("expected duplicate name error")
 	}
-	got, err := repo.GetByID(p.ID)
+	if count, _ := repo.Count(context.Background()); count != seeded+1 {
+		t.Errorf("expected %d products got %d", seeded+1, count)
+	}
+	got, err := repo.GetByID(context.Background(), p.ID)
 	if err != nil {
 		t.Fatalf("get failed: %v", err)
 	}
@@ -25,12 +32,12 @@ func TestInMemoryProductRepository_CreateAndGet(t *testing.T) {
 
 func TestInMemoryProductRepository_Filtering(t *testing.T) {
 	repo := NewInMemoryProductRepository()
-	_ = repo.Create(models.NewProduct("Cheap", "", "Electronics", 5, 1, ""))
-	_ = repo.Create(models.NewProduct("Mid", "", "Electronics", 50, 0, ""))
-	_ = repo.Create(models.NewProduct("Expensive", "", "Electronics", 500, 3, ""))
+	_ = repo.Create(context.Background(), models.NewProduct("Cheap", "", "Electronics", 5, 1, ""))
+	_ = repo.Create(context.Background(), models.NewProduct("Mid", "", "Electronics", 50, 0, ""))
+	_ = repo.Create(context.Background(), models.NewProduct("Expensive", "", "Electronics", 500, 3, ""))
 
 	filter := &models.ProductFilter{MinPrice: 10, MaxPrice: 400, InStock: true, Category: "Electronics"}
-	list, err := repo.List(filter)
+	list, _, err := repo.List(context.Background(), filter)
 	if err != nil {
 		t.Fatalf("list failed: %v", err)
 	}
@@ -44,15 +51,320 @@ func TestInMemoryProductRepository_Filtering(t *testing.T) {
 func TestInMemoryProductRepository_UpdateStock(t *testing.T) {
 	repo := NewInMemoryProductRepository()
 	p := models.NewProduct("Stock Item", "", "Cat", 9.9, 10, "")
-	_ = repo.Create(p)
-	if err := repo.UpdateStock(p.ID, 25); err != nil {
+	_ = repo.Create(context.Background(), p)
+	if err := repo.UpdateStock(context.Background(), p.ID, 25, models.StockSource{}); err != nil {
 		t.Fatalf("update stock failed: %v", err)
 	}
-	got, _ := repo.GetByID(p.ID)
+	got, _ := repo.GetByID(context.Background(), p.ID)
 	if got.Stock != 25 {
 		t.Errorf("expected stock 25 got %d", got.Stock)
 	}
-	if err := repo.UpdateStock(p.ID, -5); err == nil {
+	if err := repo.UpdateStock(context.Background(), p.ID, -5, models.StockSource{}); err == nil {
 		t.Error("expected negative stock error")
 	}
 }
+
+func TestInMemoryProductRepository_AdjustStockConcurrent(t *testing.T) {
+	repo := NewInMemoryProductRepository()
+	p := models.NewProduct("Adjust Item", "", "Cat", 9.9, 10, "")
+	_ = repo.Create(context.Background(), p)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = repo.AdjustStock(context.Background(), p.ID, -1, models.StockSource{})
+		}()
+	}
+	wg.Wait()
+
+	got, _ := repo.GetByID(context.Background(), p.ID)
+	if got.Stock != 0 {
+		t.Fatalf("expected stock to stop at 0, got %d", got.Stock)
+	}
+	if _, err := repo.AdjustStock(context.Background(), p.ID, -1, models.StockSource{}); err != models.ErrInsufficientStock {
+		t.Errorf("expected insufficient stock error, got %v", err)
+	}
+	if stock, err := repo.AdjustStock(context.Background(), p.ID, 4, models.StockSource{}); err != nil || stock != 4 {
+		t.Errorf("expected restock to 4, got %d (%v)", stock, err)
+	}
+}
+
+func TestInMemoryProductRepository_SortAndPage(t *testing.T) {
+	repo := newSeededProductRepository(t)
+
+	list, info, err := repo.List(context.Background(), &models.ProductFilter{Sort: models.SortByPrice, Order: models.SortDesc, Page: 2, Limit: 2})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if info.Total != 5 || info.TotalPages != 3 || !info.HasMore || len(list) != 2 {
+		t.Fatalf("unexpected page info %+v with %d items", info, len(list))
+	}
+	if list[0].Price < list[1].Price {
+		t.Error("expected descending price order")
+	}
+	if list[0].Price != 199.99 {
+		t.Errorf("expected third most expensive product first on page 2, got %v", list[0].Price)
+	}
+}
+
+func TestInMemoryProductRepository_CursorPagination(t *testing.T) {
+	repo := newSeededProductRepository(t)
+	filter := &models.ProductFilter{Sort: models.SortByPrice, Limit: 2}
+
+	var prices []float64
+	for {
+		list, info, err := repo.List(context.Background(), filter)
+		if err != nil {
+			t.Fatalf("list failed: %v", err)
+		}
+		for _, p := range list {
+			prices = append(prices, p.Price)
+		}
+		if !info.HasMore {
+			break
+		}
+		filter.Cursor = info.NextCursor
+	}
+
+	if len(prices) != 5 {
+		t.Fatalf("expected to walk all 5 products, got %d", len(prices))
+	}
+	for i := 1; i < len(prices); i++ {
+		if prices[i] < prices[i-1] {
+			t.Fatalf("expected ascending prices across pages, got %v", prices)
+		}
+	}
+
+	// Cursors are bound to the sort they were issued for
+	filter.Sort = models.SortByCreatedAt
+	if _, _, err := repo.List(context.Background(), filter); err != models.ErrInvalidCursor {
+		t.Errorf("expected invalid cursor error, got %v", err)
+	}
+}
+
+func TestInMemoryProductRepository_TagFilterAndCounts(t *testing.T) {
+	repo := NewInMemoryProductRepository()
+	for name, tags := range map[string][]string{
+		"Tagged Lamp":   {"sale", "new"},
+		"Tagged Kettle": {"sale"},
+		"Tagged Mug":    {"new"},
+	} {
+		product := models.NewProduct(name, "", "Appliances", 10, 1, "")
+		product.Tags = tags
+		_ = repo.Create(context.Background(), product)
+	}
+
+	list, _, _ := repo.List(context.Background(), &models.ProductFilter{Tags: []string{"sale", "new"}})
+	if len(list) != 1 || list[0].Name != "Tagged Lamp" {
+		t.Fatalf("expected only the product with both tags, got %d products", len(list))
+	}
+
+	counts, err := repo.TagCounts(context.Background())
+	if err != nil {
+		t.Fatalf("tag counts failed: %v", err)
+	}
+	expected := []models.TagCount{{Tag: "new", Count: 2}, {Tag: "sale", Count: 2}}
+	if len(counts) != len(expected) || counts[0] != expected[0] || counts[1] != expected[1] {
+		t.Fatalf("expected %v got %v", expected, counts)
+	}
+}
+
+func TestInMemoryProductRepository_StockHistory(t *testing.T) {
+	repo := NewInMemoryProductRepository()
+	p := models.NewProduct("History Item", "", "Cat", 5, 10, "")
+	_ = repo.Create(context.Background(), p)
+
+	_ = repo.UpdateStock(context.Background(), p.ID, 4, models.StockSource{Actor: "admin", Reason: models.StockReasonManualSet})
+	_, _ = repo.AdjustStock(context.Background(), p.ID, 0, models.StockSource{Reason: models.StockReasonManualAdjustment})
+	reservation, _ := repo.ReserveStock(context.Background(), p.ID, "o1", 3, time.Minute, "order-service")
+
+	history, err := repo.StockHistory(context.Background(), p.ID, 0)
+	if err != nil {
+		t.Fatalf("stock history failed: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("expected 3 movements (zero deltas are not recorded), got %d", len(history))
+	}
+	latest := history[0]
+	if latest.Reason != models.StockReasonOrderReserved || latest.Delta != -3 || latest.StockAfter != 1 ||
+		latest.Actor != "order-service" || latest.Reference != "order:o1" {
+		t.Errorf("unexpected reservation movement %+v", latest)
+	}
+	if history[1].Delta != -6 || history[1].Actor != "admin" {
+		t.Errorf("unexpected manual movement %+v", history[1])
+	}
+	if history[2].Reason != models.StockReasonInitial || history[2].Delta != 10 || history[2].Actor != models.SystemActor {
+		t.Errorf("unexpected initial movement %+v", history[2])
+	}
+
+	if limited, _ := repo.StockHistory(context.Background(), p.ID, 1); len(limited) != 1 || limited[0].ID != latest.ID {
+		t.Errorf("expected limit to return the newest movement")
+	}
+	_, _ = repo.ReleaseReservation(context.Background(), p.ID, reservation.ID, "order-service")
+	if history, _ = repo.StockHistory(context.Background(), p.ID, 1); history[0].Reason != models.StockReasonOrderReleased || history[0].Delta != 3 {
+		t.Errorf("expected release to be recorded, got %+v", history[0])
+	}
+	if _, err := repo.StockHistory(context.Background(), "missing", 10); err == nil {
+		t.Error("expected error for unknown product")
+	}
+}
+
+func TestInMemoryProductRepository_SalePricing(t *testing.T) {
+	repo := NewInMemoryProductRepository()
+	salePrice := 30.0
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	active := models.NewProduct("Sale Kettle", "", "Sale Test", 50, 1, "")
+	active.SetSale(&salePrice, &past, &future)
+	scheduled := models.NewProduct("Scheduled Kettle", "", "Sale Test", 40, 1, "")
+	scheduled.SetSale(&salePrice, &future, nil)
+	_ = repo.Create(context.Background(), active)
+	_ = repo.Create(context.Background(), scheduled)
+
+	got, _ := repo.GetByID(context.Background(), active.ID)
+	if !got.OnSale || got.EffectivePrice != 30 || got.Price != 50 {
+		t.Errorf("expected active sale price 30, got on_sale=%v effective=%v", got.OnSale, got.EffectivePrice)
+	}
+	got, _ = repo.GetByID(context.Background(), scheduled.ID)
+	if got.OnSale || got.EffectivePrice != 40 {
+		t.Errorf("expected scheduled sale not yet applied, got effective=%v", got.EffectivePrice)
+	}
+
+	list, _, _ := repo.List(context.Background(), &models.ProductFilter{Category: "Sale Test", MaxPrice: 35, Sort: models.SortByPrice})
+	if len(list) != 1 || list[0].ID != active.ID {
+		t.Fatalf("expected price filter to use the effective price, got %d products", len(list))
+	}
+	list, _, _ = repo.List(context.Background(), &models.ProductFilter{Category: "Sale Test", Sort: models.SortByPrice})
+	if len(list) != 2 || list[0].ID != active.ID {
+		t.Errorf("expected price sort to use the effective price")
+	}
+}
+
+func TestInMemoryProductRepository_ScheduledPublishing(t *testing.T) {
+	repo := NewInMemoryProductRepository()
+	p := models.NewProduct("Launch Item", "", "Cat", 5, 1, "")
+	publishAt := time.Now().Add(time.Hour)
+	if err := p.SetVisibility(models.ProductStatusScheduled, &publishAt, time.Now()); err != nil {
+		t.Fatalf("schedule failed: %v", err)
+	}
+	_ = repo.Create(context.Background(), p)
+
+	if list, _, _ := repo.List(context.Background(), &models.ProductFilter{Status: models.ProductStatusPublished, Category: "Cat"}); len(list) != 0 {
+		t.Fatalf("expected scheduled product to stay unpublished before its publish time")
+	}
+
+	// Move the schedule into the past as if the publish time had arrived
+	repo.products[p.ID].PublishAt = &time.Time{}
+	list, _, _ := repo.List(context.Background(), &models.ProductFilter{Status: models.ProductStatusPublished, Category: "Cat"})
+	if len(list) != 1 || list[0].Status != models.ProductStatusPublished || list[0].PublishAt != nil {
+		t.Fatalf("expected product to be published once its publish time passed")
+	}
+}
+
+func TestInMemoryProductRepository_AttributeFilter(t *testing.T) {
+	repo := NewInMemoryProductRepository()
+	tv := models.NewProduct("Attribute TV", "", "Displays", 300, 1, "")
+	tv.Attributes = map[string]string{"screen_size": "55in", "panel": "OLED"}
+	_ = repo.Create(context.Background(), tv)
+	_ = repo.Create(context.Background(), models.NewProduct("Plain TV", "", "Displays", 200, 1, ""))
+
+	list, _, _ := repo.List(context.Background(), &models.ProductFilter{Attributes: map[string]string{"panel": "oled"}})
+	if len(list) != 1 || list[0].ID != tv.ID {
+		t.Fatalf("expected only the OLED TV, got %d products", len(list))
+	}
+
+	list[0].Attributes["panel"] = "LCD"
+	if got, _ := repo.GetByID(context.Background(), tv.ID); got.Attributes["panel"] != "OLED" {
+		t.Error("expected returned attributes to be a copy")
+	}
+}
+
+func TestInMemoryProductRepository_PersistsAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	repo := NewInMemoryProductRepository()
+	if err := repo.PersistTo(dir); err != nil {
+		t.Fatalf("persist: %v", err)
+	}
+	camera := models.NewProduct("Camera", "", "Electronics", 500, 4, "")
+	repo.Create(context.Background(), camera)
+	repo.Snapshot()
+	// Changes after the snapshot come back from the log
+	reservation, err := repo.ReserveStock(context.Background(), camera.ID, "order-1", 3, time.Hour, "")
+	if err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	repo.CommitReservation(context.Background(), camera.ID, reservation.ID, "")
+	seeded, _ := repo.Count(context.Background())
+
+	// A restart starts empty again, and loads the saved products
+	restarted := NewInMemoryProductRepository()
+	if err := restarted.PersistTo(dir); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	defer restarted.Close()
+	if count, _ := restarted.Count(context.Background()); count != seeded {
+		t.Errorf("expected the %d saved products, got %d", seeded, count)
+	}
+	stored, err := restarted.GetByID(context.Background(), camera.ID)
+	if err != nil || stored.Stock != 1 {
+		t.Fatalf("expected the camera with 1 left in stock, got %+v, %v", stored, err)
+	}
+	if found, _ := restarted.Search(context.Background(), "camera", nil); len(found) != 1 {
+		t.Errorf("expected the loaded camera to be searchable, got %d products", len(found))
+	}
+	if history, _ := restarted.StockHistory(context.Background(), camera.ID, 0); len(history) != 2 {
+		t.Errorf("expected the initial stock and the reservation in history, got %d movements", len(history))
+	}
+	if _, err := restarted.ReleaseReservation(context.Background(), camera.ID, reservation.ID, ""); err != nil {
+		t.Errorf("expected the committed reservation to survive the restart, got %v", err)
+	}
+}
+
+func TestInMemoryProductRepository_Search(t *testing.T) {
+	repo := NewInMemoryProductRepository()
+	kettle := models.NewProduct("Steel Kettle", "Boils water fast", "Kitchen", 30, 1, "")
+	_ = repo.Create(context.Background(), kettle)
+	_ = repo.Create(context.Background(), models.NewProduct("Teapot", "Pairs with a steel kettle", "Kitchen", 20, 1, ""))
+	_ = repo.Create(context.Background(), models.NewProduct("Steel Pan", "Nonstick", "Cookware", 40, 1, ""))
+	_ = repo.Create(context.Background(), models.NewProduct("Kitchen Scale", "Weighs in grams", "Tools", 15, 1, ""))
+
+	names := func(products []*models.Product) []string {
+		names := make([]string, len(products))
+		for i, product := range products {
+			names[i] = product.Name
+		}
+		return names
+	}
+
+	// A word in the name outranks the same word in the description
+	found, err := repo.Search(context.Background(), "STEEL kettle!", nil)
+	if err != nil || len(found) != 2 || found[0].Name != "Steel Kettle" || found[1].Name != "Teapot" {
+		t.Fatalf("expected the kettle before the teapot, got %v %v", names(found), err)
+	}
+	// A name beats a category, and every word must match
+	if found, _ := repo.Search(context.Background(), "kitchen", nil); len(found) != 3 || found[0].Name != "Kitchen Scale" {
+		t.Fatalf("expected the scale first of three kitchen matches, got %v", names(found))
+	}
+	if found, _ := repo.Search(context.Background(), "steel grams", nil); len(found) != 0 {
+		t.Fatalf("expected no product with both words, got %v", names(found))
+	}
+	if found, _ := repo.Search(context.Background(), "steel", &models.ProductFilter{MaxPrice: 35, Limit: 1}); len(found) != 1 || found[0].Name != "Steel Kettle" {
+		t.Fatalf("expected the filter and limit applied, got %v", names(found))
+	}
+
+	// The index follows updates and deletes
+	kettle.Name = "Copper Kettle"
+	_ = repo.Update(context.Background(), kettle)
+	if found, _ := repo.Search(context.Background(), "copper", nil); len(found) != 1 {
+		t.Fatalf("expected the renamed kettle found, got %v", names(found))
+	}
+	if found, _ := repo.Search(context.Background(), "steel kettle", nil); len(found) != 1 || found[0].Name != "Teapot" {
+		t.Fatalf("expected the old name forgotten, got %v", names(found))
+	}
+	_ = repo.Delete(context.Background(), kettle.ID)
+	if found, _ := repo.Search(context.Background(), "copper", nil); len(found) != 0 {
+		t.Fatalf("expected the deleted kettle gone, got %v", names(found))
+	}
+}
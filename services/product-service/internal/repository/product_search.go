@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"product-service/internal/models"
+)
+
+// Search weights: a word found in a product's name counts more than one in its category, which
+// counts more than one in its description
+const (
+	searchWeightName        = 3
+	searchWeightCategory    = 2
+	searchWeightDescription = 1
+)
+
+// SearchTerms splits text into the distinct lowercased words product search matches on
+func SearchTerms(text string) []string {
+	words := searchWords(text)
+	terms := make([]string, 0, len(words))
+	seen := make(map[string]bool, len(words))
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// searchWords splits text into lowercased words at anything that isn't a letter or digit
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// productTermWeights returns each word in a product's name, category, and description with its
+// weight, counting a word once for every time it appears
+func productTermWeights(product *models.Product) map[string]float64 {
+	weights := make(map[string]float64)
+	for _, field := range []struct {
+		text   string
+		weight float64
+	}{
+		{product.Name, searchWeightName},
+		{product.Category, searchWeightCategory},
+		{product.Description, searchWeightDescription},
+	} {
+		for _, word := range searchWords(field.text) {
+			weights[word] += field.weight
+		}
+	}
+	return weights
+}
+
+// searchIndex is an inverted index from each word to the products containing it, with the word's
+// weight in each. The zero value is an empty index. It is not safe for concurrent use; the
+// repository's lock guards it.
+type searchIndex struct {
+	postings map[string]map[string]float64
+	terms    map[string][]string // the words indexed for each product, to remove them again
+}
+
+// add indexes a product's words, replacing whatever was indexed for it before
+func (i *searchIndex) add(product *models.Product) {
+	if i.postings == nil {
+		i.postings = make(map[string]map[string]float64)
+		i.terms = make(map[string][]string)
+	}
+	i.remove(product.ID)
+	weights := productTermWeights(product)
+	terms := make([]string, 0, len(weights))
+	for term, weight := range weights {
+		if i.postings[term] == nil {
+			i.postings[term] = make(map[string]float64)
+		}
+		i.postings[term][product.ID] = weight
+		terms = append(terms, term)
+	}
+	i.terms[product.ID] = terms
+}
+
+// remove drops a product from the index
+func (i *searchIndex) remove(id string) {
+	for _, term := range i.terms[id] {
+		delete(i.postings[term], id)
+		if len(i.postings[term]) == 0 {
+			delete(i.postings, term)
+		}
+	}
+	delete(i.terms, id)
+}
+
+// match returns the IDs of the products containing every term, with the sum of the terms' weights
+func (i *searchIndex) match(terms []string) map[string]float64 {
+	if len(terms) == 0 {
+		return nil
+	}
+	// Walk the rarest term's products and look the others up
+	rarest := terms[0]
+	for _, term := range terms[1:] {
+		if len(i.postings[term]) < len(i.postings[rarest]) {
+			rarest = term
+		}
+	}
+
+	scores := make(map[string]float64)
+	for id := range i.postings[rarest] {
+		score := 0.0
+		for _, term := range terms {
+			weight, found := i.postings[term][id]
+			if !found {
+				score = 0
+				break
+			}
+			score += weight
+		}
+		if score > 0 {
+			scores[id] = score
+		}
+	}
+	return scores
+}
+
+// searchHit is a product found by a search with its relevance
+type searchHit struct {
+	product *models.Product
+	score   float64
+}
+
+// productSearch collects the products matching a search and ranks them. Stores check their
+// filter before handing it products, and copy the results before returning them; stores that
+// can't score products themselves pass it candidates to check with consider.
+type productSearch struct {
+	terms []string
+	limit int
+	hits  []searchHit
+}
+
+// newProductSearch starts a search for query's words, keeping at most filter.Limit products (all
+// when the filter is nil or has no limit)
+func newProductSearch(query string, filter *models.ProductFilter) *productSearch {
+	search := &productSearch{terms: SearchTerms(query)}
+	if filter != nil {
+		search.limit = filter.Limit
+	}
+	return search
+}
+
+// consider scores a stored product against the search's words, keeping it if it has every one
+// of them
+func (s *productSearch) consider(product *models.Product) {
+	weights := productTermWeights(product)
+	score := 0.0
+	for _, term := range s.terms {
+		if weights[term] == 0 {
+			return
+		}
+		score += weights[term]
+	}
+	s.add(product, score)
+}
+
+// add keeps a stored product matched with score
+func (s *productSearch) add(product *models.Product, score float64) {
+	if score > 0 {
+		s.hits = append(s.hits, searchHit{product: product, score: score})
+	}
+}
+
+// results returns the products found, most relevant first and by name among equals, at most
+// the search's limit of them
+func (s *productSearch) results() []*models.Product {
+	sort.Slice(s.hits, func(i, j int) bool {
+		if s.hits[i].score != s.hits[j].score {
+			return s.hits[i].score > s.hits[j].score
+		}
+		return strings.ToLower(s.hits[i].product.Name) < strings.ToLower(s.hits[j].product.Name)
+	})
+	hits := s.hits
+	if s.limit > 0 && len(hits) > s.limit {
+		hits = hits[:s.limit]
+	}
+	products := make([]*models.Product, len(hits))
+	for i, hit := range hits {
+		products[i] = hit.product
+	}
+	return products
+}
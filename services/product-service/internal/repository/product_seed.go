@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"ecommerce/pkg/seed"
+	"product-service/internal/models"
+)
+
+// ProductFixture is a product as written in the "products" section of a seed file
+type ProductFixture struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	SKU         string   `json:"sku"`
+	Description string   `json:"description"`
+	Category    string   `json:"category"`
+	Price       float64  `json:"price"`
+	Stock       int      `json:"stock"`
+	ImageURL    string   `json:"image_url"`
+	Tags        []string `json:"tags"`
+}
+
+// SeedProducts creates the products in the seed file at path that the repository doesn't have
+// yet, and returns how many it created. Fixtures need an ID, so seeding a store twice creates
+// nothing the second time.
+func SeedProducts(ctx context.Context, repo ProductRepository, path string) (int, error) {
+	var fixtures []ProductFixture
+	if err := seed.Load(path, "products", &fixtures); err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for i, fixture := range fixtures {
+		if fixture.ID == "" {
+			return created, fmt.Errorf("product fixture %d has no id", i+1)
+		}
+		if _, err := repo.GetByID(ctx, fixture.ID); err == nil {
+			continue
+		}
+
+		product := models.NewProduct(fixture.Name, fixture.Description, fixture.Category, fixture.Price, fixture.Stock, fixture.ImageURL)
+		product.ID = fixture.ID
+		product.SKU = fixture.SKU
+		if len(fixture.Tags) > 0 {
+			tags, err := models.NormalizeTags(fixture.Tags)
+			if err != nil {
+				return created, fmt.Errorf("product fixture %s: %w", fixture.ID, err)
+			}
+			product.Tags = tags
+		}
+		if product.Name == "" || product.Price < 0 || product.Stock < 0 {
+			return created, fmt.Errorf("product fixture %s needs a name, and a price and stock of at least 0", fixture.ID)
+		}
+		if err := repo.Create(ctx, product); err != nil {
+			return created, fmt.Errorf("product fixture %s: %w", fixture.ID, err)
+		}
+		created++
+	}
+	return created, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// demoFixtures is the sample data shared by the services, which seeded tests run against
+const demoFixtures = "../../../../fixtures/demo.yaml"
+
+// newSeededProductRepository returns an in-memory repository holding the demo products
+func newSeededProductRepository(t *testing.T) *InMemoryProductRepository {
+	t.Helper()
+	repo := NewInMemoryProductRepository()
+	if _, err := SeedProducts(context.Background(), repo, demoFixtures); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	return repo
+}
+
+func TestSeedProducts_CreatesMissingProductsOnce(t *testing.T) {
+	repo := NewInMemoryProductRepository()
+
+	created, err := SeedProducts(context.Background(), repo, demoFixtures)
+	if err != nil || created != 5 {
+		t.Fatalf("expected the 5 demo products created, got %d, %v", created, err)
+	}
+	product, err := repo.GetByID(context.Background(), "prod-coffee-maker")
+	if err != nil || product.Stock != 15 || product.Category != "Appliances" {
+		t.Fatalf("expected the coffee maker with 15 in stock, got %+v, %v", product, err)
+	}
+	if history, _ := repo.StockHistory(context.Background(), product.ID, 0); len(history) != 1 {
+		t.Errorf("expected the initial stock in history, got %d movements", len(history))
+	}
+
+	// Seeding again, as a restart against a persistent store does, creates nothing
+	if created, err := SeedProducts(context.Background(), repo, demoFixtures); err != nil || created != 0 {
+		t.Errorf("expected nothing created the second time, got %d, %v", created, err)
+	}
+	if count, _ := repo.Count(context.Background()); count != 5 {
+		t.Errorf("expected 5 products, got %d", count)
+	}
+}
+
+func TestSeedProducts_RejectsFixturesWithoutID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	os.WriteFile(path, []byte(`{"products": [{"name": "Kettle", "price": 30, "stock": 2}]}`), 0o644)
+
+	if _, err := SeedProducts(context.Background(), NewInMemoryProductRepository(), path); err == nil {
+		t.Error("expected a fixture without an id to be rejected")
+	}
+}
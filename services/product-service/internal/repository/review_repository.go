@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"product-service/internal/models"
+)
+
+// ReviewRepository defines the interface for product review data operations
+type ReviewRepository interface {
+	Create(review *models.Review) error
+	ListByProduct(productID string, page, limit int) ([]*models.Review, *models.PageInfo, error)
+	Stats(productID string) (average float64, count int, err error)
+}
+
+// InMemoryReviewRepository implements ReviewRepository using in-memory storage
+type InMemoryReviewRepository struct {
+	reviews map[string][]*models.Review // keyed by product ID, oldest first
+	mutex   sync.RWMutex
+}
+
+// NewInMemoryReviewRepository creates a new in-memory review repository
+func NewInMemoryReviewRepository() *InMemoryReviewRepository {
+	return &InMemoryReviewRepository{
+		reviews: make(map[string][]*models.Review),
+	}
+}
+
+// Create adds a review; each user may review a product once
+func (r *InMemoryReviewRepository) Create(review *models.Review) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.reviews[review.ProductID] {
+		if existing.UserID == review.UserID {
+			return errors.New("user has already reviewed this product")
+		}
+	}
+
+	reviewCopy := *review
+	r.reviews[review.ProductID] = append(r.reviews[review.ProductID], &reviewCopy)
+	return nil
+}
+
+// ListByProduct returns a page of a product's reviews, newest first
+func (r *InMemoryReviewRepository) ListByProduct(productID string, page, limit int) ([]*models.Review, *models.PageInfo, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	reviews := make([]*models.Review, 0, len(r.reviews[productID]))
+	for _, review := range r.reviews[productID] {
+		reviewCopy := *review
+		reviews = append(reviews, &reviewCopy)
+	}
+	sort.SliceStable(reviews, func(i, j int) bool {
+		return reviews[i].CreatedAt.After(reviews[j].CreatedAt)
+	})
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = models.DefaultPageLimit
+	}
+
+	total := len(reviews)
+	start := (page - 1) * limit
+	if start > total {
+		start = total
+	}
+	end := start + limit
+	if end > total {
+		end = total
+	}
+
+	info := &models.PageInfo{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: (total + limit - 1) / limit,
+		HasMore:    end < total,
+	}
+	return reviews[start:end], info, nil
+}
+
+// Stats returns the average rating and number of reviews for a product
+func (r *InMemoryReviewRepository) Stats(productID string) (float64, int, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	reviews := r.reviews[productID]
+	if len(reviews) == 0 {
+		return 0, 0, nil
+	}
+
+	sum := 0
+	for _, review := range reviews {
+		sum += review.Rating
+	}
+	return float64(sum) / float64(len(reviews)), len(reviews), nil
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"testing"
+	"time"
+	"product-service/internal/models"
+)
+
+func TestReviewRepository_ListNewestFirstAndStats(t *testing.T) {
+	repo := NewInMemoryReviewRepository()
+	older := models.NewReview("p1", "u1", 4, "", "")
+	older.CreatedAt = time.Now().Add(-time.Hour)
+	newer := models.NewReview("p1", "u2", 1, "", "")
+	_ = repo.Create(older)
+	_ = repo.Create(newer)
+
+	if err := repo.Create(models.NewReview("p1", "u1", 5, "", "")); err == nil {
+		t.Fatal("expected duplicate review to be rejected")
+	}
+
+	reviews, info, _ := repo.ListByProduct("p1", 1, 10)
+	if len(reviews) != 2 || reviews[0].ID != newer.ID || info.Total != 2 || info.HasMore {
+		t.Fatalf("expected newest review first, got %+v", reviews)
+	}
+
+	average, count, _ := repo.Stats("p1")
+	if count != 2 || average != 2.5 {
+		t.Fatalf("expected 2 reviews averaging 2.5, got %d at %v", count, average)
+	}
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+	"product-service/internal/models"
+)
+
+// reservationRetention is how long released or expired reservations are kept so late calls get a clear
+// answer. Committed reservations are kept so a cancelled order can still return its stock.
+const reservationRetention = 24 * time.Hour
+
+// ReserveStock atomically takes quantity units out of a product's stock and records a held
+// reservation for them. It fails with ErrInsufficientStock rather than letting stock go negative.
+func (r *InMemoryProductRepository) ReserveStock(ctx context.Context, productID, orderID string, quantity int, ttl time.Duration, actor string) (*models.StockReservation, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	product, exists := r.products[productID]
+	if !exists {
+		return nil, errors.New("product not found")
+	}
+	if product.Stock < quantity {
+		return nil, models.ErrInsufficientStock
+	}
+
+	reservation := models.NewStockReservation(productID, orderID, quantity, ttl)
+	reservation.Allocations = r.takeStock(product, quantity, models.StockSource{
+		Actor:     actor,
+		Reason:    models.StockReasonOrderReserved,
+		Reference: reservationReference(reservation),
+	})
+	r.reservations[reservation.ID] = reservation
+	r.logProduct(product, reservation)
+
+	reservationCopy := *reservation
+	return &reservationCopy, nil
+}
+
+// ReleaseReservation returns a reservation's units to stock. Committed reservations can be
+// released too (e.g. when a confirmed order is cancelled).
+func (r *InMemoryProductRepository) ReleaseReservation(ctx context.Context, productID, reservationID, actor string) (*models.StockReservation, error) {
+	return r.closeReservation(productID, reservationID, models.ReservationReleased, actor)
+}
+
+// CommitReservation marks held stock as sold so it no longer expires
+func (r *InMemoryProductRepository) CommitReservation(ctx context.Context, productID, reservationID, actor string) (*models.StockReservation, error) {
+	return r.closeReservation(productID, reservationID, models.ReservationCommitted, actor)
+}
+
+// ExpireReservations returns the stock of every held reservation that has expired by now
+// and reports how many were expired. Returned reservations older than the retention period are dropped.
+func (r *InMemoryProductRepository) ExpireReservations(ctx context.Context, now time.Time) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	expired := 0
+	for id, reservation := range r.reservations {
+		switch {
+		case reservation.IsExpired(now):
+			r.restock(reservation, models.ReservationExpired, now, models.SystemActor)
+			expired++
+		case reservation.IsReturned() && now.Sub(reservation.UpdatedAt) > reservationRetention:
+			delete(r.reservations, id)
+			r.log(productChange{DeletedReservationID: id})
+		}
+	}
+	return expired, nil
+}
+
+// closeReservation moves a reservation to released or committed, restocking when released
+func (r *InMemoryProductRepository) closeReservation(productID, reservationID string, status models.ReservationStatus, actor string) (*models.StockReservation, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	reservation, exists := r.reservations[reservationID]
+	if !exists || reservation.ProductID != productID {
+		return nil, models.ErrReservationNotFound
+	}
+
+	now := time.Now()
+	// Expire lazily so a late commit can't sell stock that should already be back on the shelf
+	if reservation.IsExpired(now) {
+		r.restock(reservation, models.ReservationExpired, now, models.SystemActor)
+	}
+
+	switch {
+	case status == models.ReservationCommitted && reservation.Status == models.ReservationHeld:
+		reservation.Status = models.ReservationCommitted
+		reservation.UpdatedAt = now
+		r.logProduct(nil, reservation)
+	case status == models.ReservationReleased && (reservation.Status == models.ReservationHeld || reservation.Status == models.ReservationCommitted):
+		r.restock(reservation, models.ReservationReleased, now, actor)
+	default:
+		return nil, models.ErrReservationClosed
+	}
+
+	reservationCopy := *reservation
+	return &reservationCopy, nil
+}
+
+// restock returns a reservation's units to the warehouses they came from and logs the change;
+// the caller must hold the write lock
+func (r *InMemoryProductRepository) restock(reservation *models.StockReservation, status models.ReservationStatus, now time.Time, actor string) {
+	reason := models.StockReasonOrderReleased
+	if status == models.ReservationExpired {
+		reason = models.StockReasonReservationExpired
+	}
+	if product, exists := r.products[reservation.ProductID]; exists {
+		defer r.checkRestock(product, product.Stock)
+		r.returnStock(product, reservation.Allocations, models.StockSource{
+			Actor:     actor,
+			Reason:    reason,
+			Reference: reservationReference(reservation),
+		})
+	}
+	reservation.Status = status
+	reservation.UpdatedAt = now
+	r.logProduct(r.products[reservation.ProductID], reservation)
+}
+
+// reservationReference identifies a reservation in stock history, preferring its order
+func reservationReference(reservation *models.StockReservation) string {
+	if reservation.OrderID != "" {
+		return "order:" + reservation.OrderID
+	}
+	return "reservation:" + reservation.ID
+}
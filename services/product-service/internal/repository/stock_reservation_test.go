@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"product-service/internal/models"
+)
+
+func newReservationTestRepo(stock int) (*InMemoryProductRepository, string) {
+	repo := NewInMemoryProductRepository()
+	product := models.NewProduct("Limited Edition", "", "Electronics", 50, stock, "")
+	_ = repo.Create(context.Background(), product)
+	return repo, product.ID
+}
+
+func TestReserveStock_NoOversellUnderConcurrency(t *testing.T) {
+	repo, productID := newReservationTestRepo(10)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for i := 0; i < 25; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.ReserveStock(context.Background(), productID, "", 1, time.Minute, "test"); err == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	product, _ := repo.GetByID(context.Background(), productID)
+	if reserved != 10 || product.Stock != 0 {
+		t.Fatalf("expected exactly 10 reservations and no stock left, got %d reserved and stock %d", reserved, product.Stock)
+	}
+	if _, err := repo.ReserveStock(context.Background(), productID, "", 1, time.Minute, "test"); err != models.ErrInsufficientStock {
+		t.Errorf("expected insufficient stock, got %v", err)
+	}
+}
+
+func TestReservation_ReleaseCommitAndExpire(t *testing.T) {
+	repo, productID := newReservationTestRepo(5)
+
+	released, _ := repo.ReserveStock(context.Background(), productID, "o1", 2, time.Minute, "test")
+	if _, err := repo.ReleaseReservation(context.Background(), productID, released.ID, "test"); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if _, err := repo.ReleaseReservation(context.Background(), productID, released.ID, "test"); err != models.ErrReservationClosed {
+		t.Errorf("expected double release to fail, got %v", err)
+	}
+
+	committed, _ := repo.ReserveStock(context.Background(), productID, "o2", 3, time.Minute, "test")
+	if _, err := repo.CommitReservation(context.Background(), productID, committed.ID, "test"); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+
+	expiring, _ := repo.ReserveStock(context.Background(), productID, "o3", 2, time.Minute, "test")
+	expired, _ := repo.ExpireReservations(context.Background(), time.Now().Add(2 * time.Minute))
+	if expired != 1 {
+		t.Fatalf("expected 1 expired reservation, got %d", expired)
+	}
+	if _, err := repo.CommitReservation(context.Background(), productID, expiring.ID, "test"); err != models.ErrReservationClosed {
+		t.Errorf("expected commit of expired reservation to fail, got %v", err)
+	}
+
+	product, _ := repo.GetByID(context.Background(), productID)
+	if product.Stock != 2 {
+		t.Fatalf("expected only committed units to leave stock, got %d", product.Stock)
+	}
+
+	// Cancelling after commit returns the stock
+	if _, err := repo.ReleaseReservation(context.Background(), productID, committed.ID, "test"); err != nil {
+		t.Fatalf("release of committed reservation failed: %v", err)
+	}
+	product, _ = repo.GetByID(context.Background(), productID)
+	if product.Stock != 5 {
+		t.Fatalf("expected stock restored to 5, got %d", product.Stock)
+	}
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+	"product-service/internal/models"
+)
+
+// StockSubscriptionRepository defines the interface for back-in-stock subscription data operations
+type StockSubscriptionRepository interface {
+	Create(subscription *models.StockSubscription) error
+	ListByProduct(productID string) ([]*models.StockSubscription, error)
+	TakeByProduct(productID string) ([]*models.StockSubscription, error)
+}
+
+// InMemoryStockSubscriptionRepository implements StockSubscriptionRepository using in-memory storage
+type InMemoryStockSubscriptionRepository struct {
+	subscriptions map[string][]*models.StockSubscription // keyed by product ID, oldest first
+	mutex         sync.RWMutex
+}
+
+// NewInMemoryStockSubscriptionRepository creates a new in-memory stock subscription repository
+func NewInMemoryStockSubscriptionRepository() *InMemoryStockSubscriptionRepository {
+	return &InMemoryStockSubscriptionRepository{
+		subscriptions: make(map[string][]*models.StockSubscription),
+	}
+}
+
+// Create adds a subscription; each user may subscribe to a product once
+func (r *InMemoryStockSubscriptionRepository) Create(subscription *models.StockSubscription) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.subscriptions[subscription.ProductID] {
+		if existing.UserID == subscription.UserID {
+			return errors.New("user is already subscribed to this product")
+		}
+	}
+
+	subscriptionCopy := *subscription
+	r.subscriptions[subscription.ProductID] = append(r.subscriptions[subscription.ProductID], &subscriptionCopy)
+	return nil
+}
+
+// ListByProduct returns a product's subscriptions, oldest first
+func (r *InMemoryStockSubscriptionRepository) ListByProduct(productID string) ([]*models.StockSubscription, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return copySubscriptions(r.subscriptions[productID]), nil
+}
+
+// TakeByProduct removes and returns all of a product's subscriptions, so each subscriber
+// is told about a restock once
+func (r *InMemoryStockSubscriptionRepository) TakeByProduct(productID string) ([]*models.StockSubscription, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	subscriptions := copySubscriptions(r.subscriptions[productID])
+	delete(r.subscriptions, productID)
+	return subscriptions, nil
+}
+
+// copySubscriptions returns copies of subscriptions to prevent external modification
+func copySubscriptions(subscriptions []*models.StockSubscription) []*models.StockSubscription {
+	copies := make([]*models.StockSubscription, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		subscriptionCopy := *subscription
+		copies = append(copies, &subscriptionCopy)
+	}
+	return copies
+}
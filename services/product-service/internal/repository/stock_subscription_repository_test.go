@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"testing"
+	"product-service/internal/models"
+)
+
+func TestStockSubscriptionRepository_TakeByProduct(t *testing.T) {
+	repo := NewInMemoryStockSubscriptionRepository()
+	_ = repo.Create(models.NewStockSubscription("p1", "u1"))
+	_ = repo.Create(models.NewStockSubscription("p1", "u2"))
+	_ = repo.Create(models.NewStockSubscription("p2", "u1"))
+
+	if err := repo.Create(models.NewStockSubscription("p1", "u1")); err == nil {
+		t.Fatal("expected duplicate subscription to be rejected")
+	}
+
+	taken, _ := repo.TakeByProduct("p1")
+	if len(taken) != 2 || taken[0].UserID != "u1" || taken[1].UserID != "u2" {
+		t.Fatalf("expected both p1 subscribers oldest first, got %+v", taken)
+	}
+	if remaining, _ := repo.ListByProduct("p1"); len(remaining) != 0 {
+		t.Fatalf("expected p1 subscriptions to be cleared, got %d", len(remaining))
+	}
+	if others, _ := repo.ListByProduct("p2"); len(others) != 1 {
+		t.Fatalf("expected p2 subscription to be kept, got %d", len(others))
+	}
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"product-service/internal/models"
+)
+
+// WarehouseRepository defines the interface for warehouse data operations
+type WarehouseRepository interface {
+	Create(warehouse *models.Warehouse) error
+	GetByID(id string) (*models.Warehouse, error)
+	List() ([]*models.Warehouse, error)
+}
+
+// InMemoryWarehouseRepository implements WarehouseRepository using in-memory storage
+type InMemoryWarehouseRepository struct {
+	warehouses map[string]*models.Warehouse
+	mutex      sync.RWMutex
+}
+
+// NewInMemoryWarehouseRepository creates a new in-memory warehouse repository holding the default warehouse
+func NewInMemoryWarehouseRepository() *InMemoryWarehouseRepository {
+	repo := &InMemoryWarehouseRepository{
+		warehouses: make(map[string]*models.Warehouse),
+	}
+
+	main := models.NewWarehouse("Main Warehouse", "")
+	main.ID = models.DefaultWarehouseID
+	repo.warehouses[main.ID] = main
+	return repo
+}
+
+// Create adds a new warehouse
+func (r *InMemoryWarehouseRepository) Create(warehouse *models.Warehouse) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if warehouse.ID == "" {
+		return errors.New("warehouse slug cannot be empty")
+	}
+	if _, exists := r.warehouses[warehouse.ID]; exists {
+		return errors.New("warehouse with this slug already exists")
+	}
+
+	warehouseCopy := *warehouse
+	r.warehouses[warehouse.ID] = &warehouseCopy
+	return nil
+}
+
+// GetByID retrieves a warehouse by its ID
+func (r *InMemoryWarehouseRepository) GetByID(id string) (*models.Warehouse, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	warehouse, exists := r.warehouses[id]
+	if !exists {
+		return nil, errors.New("warehouse not found")
+	}
+
+	warehouseCopy := *warehouse
+	return &warehouseCopy, nil
+}
+
+// List returns all warehouses ordered by ID
+func (r *InMemoryWarehouseRepository) List() ([]*models.Warehouse, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	warehouses := make([]*models.Warehouse, 0, len(r.warehouses))
+	for _, warehouse := range r.warehouses {
+		warehouseCopy := *warehouse
+		warehouses = append(warehouses, &warehouseCopy)
+	}
+	sort.Slice(warehouses, func(i, j int) bool {
+		return warehouses[i].ID < warehouses[j].ID
+	})
+	return warehouses, nil
+}
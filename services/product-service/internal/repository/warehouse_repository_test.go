@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+	"product-service/internal/models"
+)
+
+func TestInMemoryWarehouseRepository_SeedsDefault(t *testing.T) {
+	repo := NewInMemoryWarehouseRepository()
+	if _, err := repo.GetByID(models.DefaultWarehouseID); err != nil {
+		t.Fatalf("expected default warehouse: %v", err)
+	}
+	if err := repo.Create(models.NewWarehouse("Mombasa", "")); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if err := repo.Create(models.NewWarehouse("mombasa", "")); err == nil {
+		t.Error("expected duplicate slug to be rejected")
+	}
+}
+
+func TestInMemoryProductRepository_WarehouseStock(t *testing.T) {
+	repo := NewInMemoryProductRepository()
+	p := models.NewProduct("Warehouse Item", "", "Cat", 9.9, 10, "")
+	_ = repo.Create(context.Background(), p)
+
+	if _, err := repo.TransferStock(context.Background(), p.ID, models.DefaultWarehouseID, "mombasa", 4, models.StockSource{}); err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+	if _, err := repo.TransferStock(context.Background(), p.ID, "mombasa", models.DefaultWarehouseID, 5, models.StockSource{}); err != models.ErrInsufficientStock {
+		t.Errorf("expected insufficient stock, got %v", err)
+	}
+
+	got, _ := repo.GetByID(context.Background(), p.ID)
+	if got.Stock != 10 || got.WarehouseQuantity(models.DefaultWarehouseID) != 6 || got.WarehouseQuantity("mombasa") != 4 {
+		t.Fatalf("unexpected inventory %+v (stock %d)", got.Inventory, got.Stock)
+	}
+
+	// A reservation larger than any one warehouse draws from both and returns to both
+	reservation, err := repo.ReserveStock(context.Background(), p.ID, "", 8, time.Minute, "test")
+	if err != nil {
+		t.Fatalf("reserve failed: %v", err)
+	}
+	if len(reservation.Allocations) != 2 {
+		t.Fatalf("expected allocations from two warehouses, got %+v", reservation.Allocations)
+	}
+	_, _ = repo.ReleaseReservation(context.Background(), p.ID, reservation.ID, "test")
+	got, _ = repo.GetByID(context.Background(), p.ID)
+	if got.WarehouseQuantity(models.DefaultWarehouseID) != 6 || got.WarehouseQuantity("mombasa") != 4 {
+		t.Fatalf("expected stock returned to original warehouses, got %+v", got.Inventory)
+	}
+
+	// Full updates never overwrite stock
+	got.Stock = 999
+	_ = repo.Update(context.Background(), got)
+	got, _ = repo.GetByID(context.Background(), p.ID)
+	if got.Stock != 10 {
+		t.Errorf("expected Update to leave stock alone, got %d", got.Stock)
+	}
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// Storage errors
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Object is a stored file being read back
+type Object struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+}
+
+// Storage keeps uploaded files such as product images.
+// Implemented by LocalStorage and S3Storage; enables mocking in tests.
+type Storage interface {
+	// Put stores size bytes from body under key, replacing any existing object
+	Put(key, contentType string, body io.Reader, size int64) error
+	// Get opens a stored object; the caller must close its Body
+	Get(key string) (*Object, error)
+	// Delete removes an object
+	Delete(key string) error
+	// URL returns the public address the object is served from
+	URL(key string) string
+}
+
+// validKey rejects empty keys and keys that could escape the storage root
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
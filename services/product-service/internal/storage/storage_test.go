@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalStorage_PutGetDelete(t *testing.T) {
+	store, err := NewLocalStorage(t.TempDir(), "http://cdn.test/uploads/")
+	if err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	if err := store.Put("products/p1/a.png", "image/png", strings.NewReader("png-bytes"), 9); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	object, err := store.Get("products/p1/a.png")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	body, _ := io.ReadAll(object.Body)
+	object.Body.Close()
+	if string(body) != "png-bytes" || object.ContentType != "image/png" || object.Size != 9 {
+		t.Errorf("unexpected object %q %s %d", body, object.ContentType, object.Size)
+	}
+	if url := store.URL("products/p1/a.png"); url != "http://cdn.test/uploads/products/p1/a.png" {
+		t.Errorf("unexpected url %s", url)
+	}
+
+	for _, key := range []string{"", "../escape.png", "/abs.png", "a//b.png", "products/../../x"} {
+		if err := store.Put(key, "image/png", strings.NewReader("x"), 1); err != ErrInvalidKey {
+			t.Errorf("%q: expected invalid key error, got %v", key, err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	store.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products/p1", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected directories not to be served, got %d", rec.Code)
+	}
+
+	if err := store.Delete("products/p1/a.png"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := store.Get("products/p1/a.png"); err != ErrNotFound {
+		t.Errorf("expected not found after delete, got %v", err)
+	}
+}
+
+func TestS3Storage_SignsRequests(t *testing.T) {
+	var method, path, auth, amzDate, payload, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.EscapedPath()
+		auth, amzDate, payload = r.Header.Get("Authorization"), r.Header.Get("X-Amz-Date"), r.Header.Get("X-Amz-Content-Sha256")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	store := NewS3Storage(S3Config{
+		Bucket:          "catalog",
+		Region:          "eu-west-1",
+		Endpoint:        server.URL,
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+		PublicURL:       "https://cdn.test",
+	})
+	store.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+	if err := store.Put("products/p1/a b.png", "image/png", strings.NewReader("img"), 3); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	if method != http.MethodPut || path != "/catalog/products/p1/a%20b.png" || body != "img" {
+		t.Errorf("unexpected request %s %s %q", method, path, body)
+	}
+	if amzDate != "20240501T120000Z" || payload != unsignedPayload ||
+		!strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240501/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") {
+		t.Errorf("unexpected signature headers %q %q %q", auth, amzDate, payload)
+	}
+
+	if _, err := store.Get("products/p1/missing.png"); err != ErrNotFound {
+		t.Errorf("expected not found, got %v", err)
+	}
+	if url := store.URL("products/p1/a b.png"); url != "https://cdn.test/products/p1/a%20b.png" {
+		t.Errorf("unexpected public url %s", url)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"ecommerce/pkg/auth"
+	"ecommerce/pkg/config"
+	"ecommerce/pkg/diagnostics"
+	"ecommerce/pkg/health"
+	"ecommerce/pkg/jobs"
+	"ecommerce/pkg/logging"
+	"ecommerce/pkg/metrics"
+	"ecommerce/pkg/middleware"
+	"shipping-service/internal/carrier"
+	"shipping-service/internal/client"
+	"shipping-service/internal/handlers"
+	"shipping-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// DefaultTrackingSyncInterval is how often parcels are checked with their carriers unless
+// TRACKING_SYNC_INTERVAL says otherwise
+const DefaultTrackingSyncInterval = time.Minute
+
+func main() {
+	// Settings come from flags, the environment, and the YAML file named by -config or CONFIG_FILE
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		logging.Fatal("Failed to load configuration", "error", err)
+	}
+	logging.Setup(cfg.String("SERVICE_NAME", "shipping-service"))
+	serverConfig := cfg.Server(8086)
+
+	// The log level, body size limit, rate limits, and user service URL are read again on SIGHUP
+	reloader := config.NewReloader(cfg)
+	reloader.Register(config.TuneLogLevel)
+	reloader.Register(config.TuneMaxBodyBytes)
+
+	// With TLS_CERT_FILE, the API is served over mutual TLS, and calls to other services present the
+	// same certificate; the files are read again on SIGHUP, so rotated ones take effect
+	certs := reloader.TLS()
+
+	// Shipments are kept in memory until a database is plugged in
+	shipmentRepo := repository.NewInMemoryShipmentRepository()
+	metrics.NewGaugeFunc("shipments_stored", "Shipments in the repository", func() float64 {
+		return float64(shipmentRepo.Count())
+	})
+
+	// Labels are made with mock carriers until real carriers' APIs are plugged in
+	carriers := carrier.NewRegistry(carrier.MockCarriers()...)
+	slog.Warn("Labels are made by mock carriers; their tracking numbers are not real", "carriers", carriers.Names())
+
+	// Shipments are recorded on their orders, and tracking reported, through the order service's REST
+	// API, called with SERVICE_KEY, which user service must list in SERVICE_KEYS
+	// In production, this URL would come from service discovery
+	orders := client.NewOrderServiceClient(serviceURL(cfg, "ORDER_SERVICE_URL", "http://localhost:8083"), cfg.String("SERVICE_KEY", ""))
+	orders.UseTLS(certs.ClientConfig())
+
+	// Parcels are priced as domestic when they stay within SHIPPING_ORIGIN_COUNTRY
+	shipmentHandler := handlers.NewShipmentHandler(shipmentRepo, carriers, orders, cfg.String("SHIPPING_ORIGIN_COUNTRY", "US"))
+
+	// Parcels still on their way are checked with their carrier every TRACKING_SYNC_INTERVAL, and
+	// order service is told of each change, or told again if it missed one
+	syncInterval := cfg.Duration("TRACKING_SYNC_INTERVAL", DefaultTrackingSyncInterval, config.Positive)
+	scheduler := jobs.NewScheduler(jobs.Solo{})
+	scheduler.Add(jobs.Job{Name: "sync-tracking", Schedule: jobs.Every(syncInterval), Run: syncTracking(shipmentHandler), EveryInstance: true})
+	scheduler.Start()
+
+	// Service keys presented by other services are verified with the user service
+	serviceKeys := auth.NewServiceKeyVerifier("http://localhost:8081", time.Minute)
+	if certs != nil {
+		serviceKeys.UseTLS(certs.ClientConfig())
+	}
+	reloader.Register(func(cfg *config.Config) func() {
+		userServiceURL := serviceURL(cfg, "USER_SERVICE_URL", "http://localhost:8081")
+		return func() { serviceKeys.SetUserServiceURL(userServiceURL) }
+	})
+
+	// Shipments can't be recorded on their orders without order service, so readiness checks it
+	probes := health.NewChecker("shipping-service", cfg.Duration("READINESS_TIMEOUT", health.DefaultTimeout, config.NonNegative))
+	probes.Register("order_service", orders.Ping)
+
+	// Setup routes
+	router := setupRoutes(serverConfig.CORSOrigins, reloader, serviceKeys, probes, shipmentHandler)
+
+	// Stop before serving if any setting was invalid, listing every problem at once
+	if err := cfg.Err(); err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+	for _, key := range cfg.Unused() {
+		slog.Warn("Setting is not used", "key", key)
+	}
+	go reloader.Watch(context.Background())
+
+	// CPU and heap profiles and runtime variables are served on DEBUG_ADDR, an internal port apart
+	// from the API, when it is set
+	debugServer, err := diagnostics.Serve(serverConfig.DebugAddr)
+	if err != nil {
+		logging.Fatal("Diagnostics port failed to start", "error", err)
+	}
+
+	// Configure server; requests through the proxies in TRUSTED_PROXIES, such as the gateway, are
+	// attributed to the client they came from
+	server := &http.Server{
+		Addr:         serverConfig.Addr(),
+		Handler:      middleware.TrustProxies(serverConfig.TrustedProxies)(middleware.Versioned(router, "v1", "v1")),
+		ReadTimeout:  serverConfig.ReadTimeout,
+		WriteTimeout: serverConfig.WriteTimeout,
+		IdleTimeout:  serverConfig.IdleTimeout,
+	}
+
+	// Start server in a goroutine
+	go func() {
+		slog.Info("🚀 Shipping Service starting", "port", serverConfig.Port)
+		slog.Info("📚 API Documentation:")
+		slog.Info("  POST /rates                   - Quote every carrier's rates for a parcel")
+		slog.Info("  POST /shipments               - Make a label and ship an order's items (internal)")
+		slog.Info("  GET  /shipments?order_id=     - List an order's shipments (internal)")
+		slog.Info("  GET  /shipments/{id}          - Get a shipment with its tracking events (internal)")
+		slog.Info("  GET  /shipments/{id}/label    - Get a shipment's printable label (internal)")
+		slog.Info("  POST /shipments/{id}/tracking - Record a tracking event by hand (internal)")
+		slog.Info("  GET  /healthz                 - Liveness probe")
+		slog.Info("  GET  /readyz                  - Readiness probe, checking order service")
+		slog.Info("  GET  /metrics                 - Prometheus metrics")
+		slog.Info("---")
+
+		if err := certs.ListenAndServe(server); err != nil && err != http.ErrServerClosed {
+			logging.Fatal("Server failed to start", "error", err)
+		}
+	}()
+
+	// Wait for interrupt signal to gracefully shutdown the server
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	slog.Info("🛑 Shutting down Shipping Service...")
+
+	// Graceful shutdown with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), serverConfig.ShutdownTimeout)
+	defer cancel()
+
+	debugServer.Close()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Server forced to shutdown", "error", err)
+	} else {
+		slog.Info("✅ Shipping Service shutdown complete")
+	}
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), serverConfig.DrainTimeout)
+	defer cancelDrain()
+	if err := scheduler.Shutdown(drainCtx); err != nil {
+		slog.Error("Job runs cut short at shutdown", "error", err)
+	}
+}
+
+// setupRoutes configures all the HTTP routes
+func setupRoutes(corsOrigins []string, reloader *config.Reloader, serviceKeys *auth.ServiceKeyVerifier, probes *health.Checker, shipmentHandler *handlers.ShipmentHandler) *mux.Router {
+	router := mux.NewRouter()
+
+	// Add CORS middleware
+	router.Use(middleware.CORS(corsOrigins))
+
+	// Tag each request with an ID for the logs and calls to other services
+	router.Use(middleware.RequestID)
+
+	// Name the running build on every response
+	router.Use(middleware.ServiceVersion)
+
+	// Send errors as problem details to clients that ask for application/problem+json
+	router.Use(middleware.ProblemDetails)
+
+	// Add logging middleware
+	router.Use(middleware.Logging)
+
+	// Count and time requests by route for /metrics
+	router.Use(middleware.Metrics)
+
+	// Record which service is calling when a service key is presented
+	router.Use(serviceKeys.Authenticate)
+
+	// Limit how fast each client may call: per calling service, else per IP
+	router.Use(middleware.RateLimit("global", middleware.RateLimiter(reloader, "RATE_LIMIT", 100, 600), middleware.RateLimiter(reloader, "RATE_LIMIT_SERVICE", 1000, 6000), auth.ServiceFromContext))
+
+	// API routes live under a version prefix; operational endpoints stay at the root
+	v1 := router.PathPrefix("/v1").Subrouter()
+
+	// Turn API requests away with 503 once too many are in flight, rather than letting a spike pile up
+	v1.Use(middleware.LoadShedder(reloader).Middleware)
+
+	// Rates are public, for showing shoppers what shipping costs
+	v1.HandleFunc("/rates", shipmentHandler.QuoteRates).Methods("POST")
+
+	// Shipments carry customers' addresses, so they are for other services and need a service key
+	v1.Handle("/shipments", serviceKeys.RequireService(http.HandlerFunc(shipmentHandler.CreateShipment))).Methods("POST")
+	v1.Handle("/shipments", serviceKeys.RequireService(http.HandlerFunc(shipmentHandler.ListShipments))).Methods("GET")
+	v1.Handle("/shipments/{id}", serviceKeys.RequireService(http.HandlerFunc(shipmentHandler.GetShipment))).Methods("GET")
+	v1.Handle("/shipments/{id}/label", serviceKeys.RequireService(http.HandlerFunc(shipmentHandler.GetLabel))).Methods("GET")
+	v1.Handle("/shipments/{id}/tracking", serviceKeys.RequireService(http.HandlerFunc(shipmentHandler.AddTracking))).Methods("POST")
+
+	// Service metrics; the expvar counters are on the diagnostics port
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// Liveness and readiness probes
+	router.HandleFunc("/healthz", probes.Liveness).Methods("GET")
+	router.HandleFunc("/readyz", probes.Readiness).Methods("GET")
+
+	return router
+}
+
+// Tracking sync metrics, published at /debug/vars
+var (
+	trackingShipmentsUpdated = expvar.NewInt("tracking_shipments_updated_total")
+	trackingFailures         = expvar.NewInt("tracking_failures_total")
+)
+
+// syncTracking checks parcels still on their way with their carriers and reports them to order service
+func syncTracking(shipmentHandler *handlers.ShipmentHandler) func(ctx context.Context, now time.Time) error {
+	return func(ctx context.Context, now time.Time) error {
+		updated, failed, err := shipmentHandler.SyncTracking(ctx, now)
+		if err != nil {
+			trackingFailures.Add(1)
+			return err
+		}
+		trackingShipmentsUpdated.Add(int64(updated))
+		trackingFailures.Add(int64(failed))
+		return nil
+	}
+}
+
+// serviceURL reads the base URL of a service's REST API from the setting key names
+func serviceURL(cfg *config.Config, key, defaultURL string) string {
+	raw := cfg.String(key, defaultURL)
+	upstream, err := url.Parse(raw)
+	if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+		logging.Fatal("Invalid service URL", "key", key, "url", raw)
+	}
+	return raw
+}
@@ -0,0 +1,113 @@
+// Package carrier makes labels, prices parcels, and tracks them with the carriers parcels are sent with
+package carrier
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+	"shipping-service/internal/models"
+)
+
+// Carrier errors
+var (
+	ErrUnknownCarrier  = errors.New("no such carrier")
+	ErrUnknownService  = errors.New("carrier does not offer the service")
+	ErrUnknownShipment = errors.New("carrier does not know the tracking number")
+)
+
+// Rate prices a parcel as a base charge plus a charge per kilogram, and says how many days it takes
+type Rate struct {
+	Base        float64
+	PerKg       float64
+	TransitDays int
+}
+
+// ServiceRates holds a service's rates for parcels within the origin country and abroad
+type ServiceRates struct {
+	Domestic      Rate
+	International Rate
+}
+
+// Rates are a carrier's rates by service
+type Rates map[string]ServiceRates
+
+// Price returns what sending a parcel of weightKg with service costs, and how many days it takes
+func (r Rates) Price(service string, weightKg float64, domestic bool) (float64, int, error) {
+	rates, exists := r[service]
+	if !exists {
+		return 0, 0, ErrUnknownService
+	}
+	rate := rates.International
+	if domestic {
+		rate = rates.Domestic
+	}
+	return models.RoundCents(rate.Base + rate.PerKg*weightKg), rate.TransitDays, nil
+}
+
+// LabelRequest is what a carrier needs to make a label
+type LabelRequest struct {
+	ShipmentID  string
+	Service     string
+	WeightKg    float64
+	Destination models.Address
+	Domestic    bool
+}
+
+// Label is a carrier's label for a parcel
+type Label struct {
+	TrackingNumber    string
+	Cost              float64
+	EstimatedDelivery time.Time
+	Data              []byte // the printable label, as plain text
+}
+
+// TrackingInfo is what a carrier reports about a parcel: its latest event, and its estimated
+// delivery when the carrier gives one
+type TrackingInfo struct {
+	Event             models.TrackingEvent
+	EstimatedDelivery *time.Time
+}
+
+// Carrier is a carrier's API for labels, rates, and tracking
+type Carrier interface {
+	Name() string
+	Rates() Rates
+	CreateLabel(ctx context.Context, request LabelRequest) (*Label, error)
+	// VoidLabel cancels a label that won't be used, such as one made for an order that refused it
+	VoidLabel(ctx context.Context, trackingNumber string) error
+	Track(ctx context.Context, trackingNumber string) (*TrackingInfo, error)
+}
+
+// Registry holds the carriers parcels can be sent with, by name
+type Registry struct {
+	carriers map[string]Carrier
+}
+
+// NewRegistry creates a registry of carriers
+func NewRegistry(carriers ...Carrier) *Registry {
+	registry := &Registry{carriers: make(map[string]Carrier, len(carriers))}
+	for _, carrier := range carriers {
+		registry.carriers[carrier.Name()] = carrier
+	}
+	return registry
+}
+
+// Get returns the carrier called name
+func (r *Registry) Get(name string) (Carrier, error) {
+	carrier, exists := r.carriers[name]
+	if !exists {
+		return nil, ErrUnknownCarrier
+	}
+	return carrier, nil
+}
+
+// Names lists the carriers in alphabetical order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.carriers))
+	for name := range r.carriers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,127 @@
+package carrier
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+	"shipping-service/internal/models"
+)
+
+// MockPickupDelay is how long after its label is made a mock carrier reports a parcel picked up
+const MockPickupDelay = time.Minute
+
+// MockCarrier makes labels and tracks parcels without a carrier's API, for development and tests.
+// It reports a parcel in transit from MockPickupDelay after its label is made, and delivered once
+// its estimated delivery has passed. It only knows the labels it made since the service started.
+type MockCarrier struct {
+	name   string
+	prefix string
+	rates  Rates
+	now    func() time.Time
+	labels map[string]mockLabel
+	mutex  sync.Mutex
+}
+
+// mockLabel is what a mock carrier remembers about a label it made
+type mockLabel struct {
+	createdAt         time.Time
+	estimatedDelivery time.Time
+	destination       string
+}
+
+// NewMockCarrier creates a mock carrier called name, whose tracking numbers start with prefix
+func NewMockCarrier(name, prefix string, rates Rates) *MockCarrier {
+	return &MockCarrier{
+		name:   name,
+		prefix: prefix,
+		rates:  rates,
+		now:    time.Now,
+		labels: make(map[string]mockLabel),
+	}
+}
+
+// MockCarriers are the carriers used unless others are configured: mock UPS, FedEx, and DHL, each
+// with standard and express services
+func MockCarriers() []Carrier {
+	return []Carrier{
+		NewMockCarrier("ups", "1Z", Rates{
+			models.ServiceStandard: {Domestic: Rate{Base: 6, PerKg: 1.1, TransitDays: 5}, International: Rate{Base: 18, PerKg: 4.5, TransitDays: 10}},
+			models.ServiceExpress:  {Domestic: Rate{Base: 16, PerKg: 2.2, TransitDays: 2}, International: Rate{Base: 38, PerKg: 8.5, TransitDays: 4}},
+		}),
+		NewMockCarrier("fedex", "FX", Rates{
+			models.ServiceStandard: {Domestic: Rate{Base: 5.5, PerKg: 1.2, TransitDays: 5}, International: Rate{Base: 17, PerKg: 4.8, TransitDays: 9}},
+			models.ServiceExpress:  {Domestic: Rate{Base: 15, PerKg: 2.5, TransitDays: 1}, International: Rate{Base: 40, PerKg: 8, TransitDays: 3}},
+		}),
+		NewMockCarrier("dhl", "JD", Rates{
+			models.ServiceStandard: {Domestic: Rate{Base: 7, PerKg: 1, TransitDays: 6}, International: Rate{Base: 14, PerKg: 4, TransitDays: 8}},
+			models.ServiceExpress:  {Domestic: Rate{Base: 18, PerKg: 2, TransitDays: 2}, International: Rate{Base: 32, PerKg: 7.5, TransitDays: 3}},
+		}),
+	}
+}
+
+// Name is the carrier's name
+func (c *MockCarrier) Name() string {
+	return c.name
+}
+
+// Rates are the carrier's rates
+func (c *MockCarrier) Rates() Rates {
+	return c.rates
+}
+
+// CreateLabel makes a plain-text label with a new tracking number
+func (c *MockCarrier) CreateLabel(ctx context.Context, request LabelRequest) (*Label, error) {
+	cost, transitDays, err := c.rates.Price(request.Service, request.WeightKg, request.Domestic)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	trackingNumber := fmt.Sprintf("%s%012d", c.prefix, rand.Int63n(1e12))
+	estimatedDelivery := now.AddDate(0, 0, transitDays)
+	c.labels[trackingNumber] = mockLabel{createdAt: now, estimatedDelivery: estimatedDelivery, destination: request.Destination.City}
+
+	to := request.Destination
+	data := fmt.Sprintf("%s %s\nTRACKING %s\nSHIPMENT %s\nWEIGHT %.2f KG\n\nSHIP TO\n%s\n%s %s\n%s %s\n",
+		c.name, request.Service, trackingNumber, request.ShipmentID, request.WeightKg,
+		to.Name, to.Line1, to.Line2, to.PostalCode+" "+to.City, to.Country)
+	return &Label{TrackingNumber: trackingNumber, Cost: cost, EstimatedDelivery: estimatedDelivery, Data: []byte(data)}, nil
+}
+
+// VoidLabel forgets the label
+func (c *MockCarrier) VoidLabel(ctx context.Context, trackingNumber string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, exists := c.labels[trackingNumber]; !exists {
+		return ErrUnknownShipment
+	}
+	delete(c.labels, trackingNumber)
+	return nil
+}
+
+// Track reports where the parcel is by how long ago its label was made
+func (c *MockCarrier) Track(ctx context.Context, trackingNumber string) (*TrackingInfo, error) {
+	c.mutex.Lock()
+	label, exists := c.labels[trackingNumber]
+	c.mutex.Unlock()
+	if !exists {
+		return nil, ErrUnknownShipment
+	}
+
+	info := &TrackingInfo{EstimatedDelivery: &label.estimatedDelivery}
+	switch now := c.now(); {
+	case !now.Before(label.estimatedDelivery):
+		info.Event = models.TrackingEvent{Status: models.TrackingDelivered, Description: "Delivered", Location: label.destination, OccurredAt: label.estimatedDelivery}
+	case !now.Before(label.createdAt.Add(MockPickupDelay)):
+		info.Event = models.TrackingEvent{Status: models.TrackingInTransit, Description: "Picked up", OccurredAt: label.createdAt.Add(MockPickupDelay)}
+	default:
+		info.Event = models.TrackingEvent{Status: models.TrackingPending, Description: "Label created", OccurredAt: label.createdAt}
+	}
+	return info, nil
+}
@@ -0,0 +1,46 @@
+package carrier
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"shipping-service/internal/models"
+)
+
+func TestMockCarrier_TracksParcelsByLabelAge(t *testing.T) {
+	now := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	c := NewMockCarrier("ups", "1Z", Rates{models.ServiceStandard: {Domestic: Rate{Base: 5, PerKg: 1, TransitDays: 3}}})
+	c.now = func() time.Time { return now }
+
+	label, err := c.CreateLabel(context.Background(), LabelRequest{ShipmentID: "shp_1", Service: models.ServiceStandard, WeightKg: 2, Domestic: true, Destination: models.Address{City: "Austin"}})
+	if err != nil || label.Cost != 7 || !strings.HasPrefix(label.TrackingNumber, "1Z") || !label.EstimatedDelivery.Equal(now.AddDate(0, 0, 3)) {
+		t.Fatalf("expected a 7.00 label arriving in 3 days, got %v %+v", err, label)
+	}
+
+	for _, step := range []struct {
+		after time.Duration
+		want  models.TrackingStatus
+	}{
+		{0, models.TrackingPending},
+		{MockPickupDelay, models.TrackingInTransit},
+		{3 * 24 * time.Hour, models.TrackingDelivered},
+	} {
+		c.now = func() time.Time { return now.Add(step.after) }
+		info, err := c.Track(context.Background(), label.TrackingNumber)
+		if err != nil || info.Event.Status != step.want {
+			t.Fatalf("after %s: expected %s, got %v %+v", step.after, step.want, err, info)
+		}
+	}
+
+	if _, err := c.CreateLabel(context.Background(), LabelRequest{Service: models.ServiceExpress, WeightKg: 1}); !errors.Is(err, ErrUnknownService) {
+		t.Fatalf("expected ErrUnknownService, got %v", err)
+	}
+	if err := c.VoidLabel(context.Background(), label.TrackingNumber); err != nil {
+		t.Fatalf("void failed: %v", err)
+	}
+	if _, err := c.Track(context.Background(), label.TrackingNumber); !errors.Is(err, ErrUnknownShipment) {
+		t.Errorf("expected a voided label forgotten, got %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+	"ecommerce/pkg/requestid"
+)
+
+// Orders records shipments on their orders. Implemented by OrderServiceClient; enables mocking in tests.
+type Orders interface {
+	// RecordShipment adds a shipment to the order and returns order service's ID for it. A refusal,
+	// such as for an order that isn't confirmed, is returned as a *RefusedError.
+	RecordShipment(ctx context.Context, orderID string, shipment ShipmentRecord) (string, error)
+	// ReportTracking tells order service what the carrier reports about one of the order's shipments
+	ReportTracking(ctx context.Context, orderID, orderShipmentID string, report TrackingReport) error
+	Ping(ctx context.Context) error
+}
+
+// ShipmentRecord is a shipment as order service records it
+type ShipmentRecord struct {
+	ProductIDs        []string   `json:"product_ids,omitempty"`
+	Carrier           string     `json:"carrier"`
+	TrackingNumber    string     `json:"tracking_number"`
+	EstimatedDelivery *time.Time `json:"estimated_delivery,omitempty"`
+}
+
+// TrackingReport is a carrier's report on a shipment, as order service takes it
+type TrackingReport struct {
+	Status            string     `json:"status"`
+	EstimatedDelivery *time.Time `json:"estimated_delivery,omitempty"`
+	DeliveredAt       *time.Time `json:"delivered_at,omitempty"`
+}
+
+// RefusedError is order service turning down a request, with the status and message it answered
+type RefusedError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *RefusedError) Error() string {
+	return fmt.Sprintf("order service refused with status %d: %s", e.StatusCode, e.Message)
+}
+
+// serviceKeyHeader carries this service's API key on internal calls
+const serviceKeyHeader = "X-Service-Key"
+
+// maxResponseBytes bounds how much of an order service response is read
+const maxResponseBytes = 1 << 20
+
+// OrderServiceClient calls the order service's REST API
+type OrderServiceClient struct {
+	httpClient *http.Client
+	baseURL    string
+	serviceKey string
+}
+
+// NewOrderServiceClient creates a client for the order service at baseURL. serviceKey is sent as
+// X-Service-Key to authenticate this service, which tracking reports need.
+func NewOrderServiceClient(baseURL, serviceKey string) *OrderServiceClient {
+	return &OrderServiceClient{
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+		baseURL:    baseURL,
+		serviceKey: serviceKey,
+	}
+}
+
+// UseTLS makes the client call order service over TLS with config, presenting this service's
+// certificate; order service's URL must then be https. Call it before the client is used.
+func (c *OrderServiceClient) UseTLS(config *tls.Config) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	c.httpClient.Transport = transport
+}
+
+// RecordShipment posts the shipment to POST /v1/orders/{id}/shipments; the order answered lists the
+// new shipment last
+func (c *OrderServiceClient) RecordShipment(ctx context.Context, orderID string, shipment ShipmentRecord) (string, error) {
+	var order struct {
+		Shipments []struct {
+			ID string `json:"id"`
+		} `json:"shipments"`
+	}
+	if err := c.post(ctx, "/v1/orders/"+url.PathEscape(orderID)+"/shipments", shipment, &order); err != nil {
+		return "", err
+	}
+	if len(order.Shipments) == 0 {
+		return "", errors.New("order service answered without the shipment")
+	}
+	return order.Shipments[len(order.Shipments)-1].ID, nil
+}
+
+// ReportTracking posts the report to POST /v1/internal/orders/{id}/shipments/{shipment_id}/tracking
+func (c *OrderServiceClient) ReportTracking(ctx context.Context, orderID, orderShipmentID string, report TrackingReport) error {
+	return c.post(ctx, "/v1/internal/orders/"+url.PathEscape(orderID)+"/shipments/"+url.PathEscape(orderShipmentID)+"/tracking", report, nil)
+}
+
+// Ping checks the order service is serving, for the readiness probe
+func (c *OrderServiceClient) Ping(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodGet, "/healthz", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("order service returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// post sends body as JSON and decodes the data of a successful answer into out, which may be nil.
+// An answer below 500 that isn't a success is returned as a *RefusedError.
+func (c *OrderServiceClient) post(ctx context.Context, path string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, http.MethodPost, path, payload)
+	if err != nil {
+		return fmt.Errorf("failed to call order service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Error string          `json:"error"`
+		Data  json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&envelope); err != nil && resp.StatusCode < 300 {
+		return fmt.Errorf("failed to decode order service response: %w", err)
+	}
+	switch {
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("order service returned status %d", resp.StatusCode)
+	case resp.StatusCode >= 300:
+		return &RefusedError{StatusCode: resp.StatusCode, Message: envelope.Error}
+	case out != nil:
+		if err := json.Unmarshal(envelope.Data, out); err != nil {
+			return fmt.Errorf("failed to decode order service response: %w", err)
+		}
+	}
+	return nil
+}
+
+// do sends a request authenticated with the service key, tagged with ctx's request ID
+func (c *OrderServiceClient) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	requestid.Propagate(req)
+	if c.serviceKey != "" {
+		req.Header.Set(serviceKeyHeader, c.serviceKey)
+	}
+	return c.httpClient.Do(req)
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOrderServiceClient_RecordShipment(t *testing.T) {
+	var gotKey string
+	var got ShipmentRecord
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.Header.Get(serviceKeyHeader)
+		switch r.URL.Path {
+		case "/v1/orders/o1/shipments":
+			json.NewDecoder(r.Body).Decode(&got)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"success":true,"data":{"id":"o1","shipments":[{"id":"s0"},{"id":"s1"}]}}`))
+		case "/v1/orders/o2/shipments":
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"success":false,"error":"Only confirmed orders can ship; order is pending"}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	orders := NewOrderServiceClient(server.URL, "shipping-key")
+
+	id, err := orders.RecordShipment(context.Background(), "o1", ShipmentRecord{Carrier: "ups", TrackingNumber: "1Z1"})
+	if err != nil || id != "s1" {
+		t.Fatalf("expected the new shipment s1, got %q %v", id, err)
+	}
+	if gotKey != "shipping-key" || got.Carrier != "ups" || got.TrackingNumber != "1Z1" {
+		t.Errorf("expected the shipment sent with the service key, got %q %+v", gotKey, got)
+	}
+
+	_, err = orders.RecordShipment(context.Background(), "o2", ShipmentRecord{Carrier: "ups"})
+	var refused *RefusedError
+	if !errors.As(err, &refused) || refused.StatusCode != http.StatusConflict || refused.Message == "" {
+		t.Fatalf("expected the refusal passed back, got %v", err)
+	}
+
+	err = orders.ReportTracking(context.Background(), "o3", "s1", TrackingReport{Status: "in_transit"})
+	if err == nil || errors.As(err, &refused) {
+		t.Errorf("expected a server error not taken for a refusal, got %v", err)
+	}
+}
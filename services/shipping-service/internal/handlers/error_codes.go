@@ -0,0 +1,15 @@
+package handlers
+
+// Error codes the shipping service sends in the code of an error response, so clients can tell
+// failures apart without matching messages. Errors without one of these get a code named after
+// their status, such as NOT_FOUND.
+const (
+	CodeShipmentNotFound   = "SHIPMENT_NOT_FOUND"
+	CodeUnknownCarrier     = "UNKNOWN_CARRIER"
+	CodeUnknownService     = "UNKNOWN_SERVICE" // the carrier doesn't offer the shipping service
+	CodeOrderNotFound      = "ORDER_NOT_FOUND"
+	CodeOrderNotShippable  = "ORDER_NOT_SHIPPABLE" // order service refused the shipment; the error says why
+	CodeCarrierUnavailable = "CARRIER_UNAVAILABLE"
+	CodeShipmentDelivered  = "SHIPMENT_DELIVERED"
+	CodeOrderServiceDown   = "ORDER_SERVICE_UNAVAILABLE"
+)
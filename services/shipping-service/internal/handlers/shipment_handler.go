@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"ecommerce/pkg/api"
+	"shipping-service/internal/carrier"
+	"shipping-service/internal/client"
+	"shipping-service/internal/models"
+	"shipping-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// ShipmentHandler serves shipments: quoting carriers' rates, making labels, following parcels with
+// their carriers, and telling order service where they are
+type ShipmentHandler struct {
+	repo     repository.ShipmentRepository
+	carriers *carrier.Registry
+	orders   client.Orders
+	origin   string
+}
+
+// NewShipmentHandler creates a new shipment handler for parcels sent from the origin country (an
+// ISO 3166 code); parcels to anywhere else pay international rates
+func NewShipmentHandler(repo repository.ShipmentRepository, carriers *carrier.Registry, orders client.Orders, origin string) *ShipmentHandler {
+	return &ShipmentHandler{repo: repo, carriers: carriers, orders: orders, origin: strings.ToUpper(origin)}
+}
+
+// QuoteRates handles POST /rates - what sending a parcel costs with each carrier and service,
+// cheapest first
+func (h *ShipmentHandler) QuoteRates(w http.ResponseWriter, r *http.Request) {
+	var req models.QuoteRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	names := h.carriers.Names()
+	if req.Carrier != "" {
+		names = []string{strings.ToLower(strings.TrimSpace(req.Carrier))}
+	}
+	quotes := []models.Quote{}
+	for _, name := range names {
+		c, err := h.carriers.Get(name)
+		if err != nil {
+			api.WriteErrorCode(w, http.StatusBadRequest, CodeUnknownCarrier, "Unknown carrier "+name)
+			return
+		}
+		for service := range c.Rates() {
+			amount, transitDays, _ := c.Rates().Price(service, req.WeightKg, h.domestic(req.Country))
+			quotes = append(quotes, models.Quote{Carrier: name, Service: service, Amount: amount, Currency: models.DefaultCurrency, TransitDays: transitDays})
+		}
+	}
+	sort.Slice(quotes, func(i, j int) bool {
+		if quotes[i].Amount != quotes[j].Amount {
+			return quotes[i].Amount < quotes[j].Amount
+		}
+		return quotes[i].Carrier+quotes[i].Service < quotes[j].Carrier+quotes[j].Service
+	})
+	api.WriteJSON(w, http.StatusOK, models.Response{Success: true, Data: quotes})
+}
+
+// CreateShipment handles POST /shipments - makes a label with the carrier and records the shipment on
+// its order with order service. If order service refuses the shipment, such as for an order that
+// isn't confirmed, the label is voided.
+func (h *ShipmentHandler) CreateShipment(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateShipmentRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+	req.Carrier = strings.ToLower(strings.TrimSpace(req.Carrier))
+	if req.Service == "" {
+		req.Service = models.ServiceStandard
+	}
+	req.Destination.Country = strings.ToUpper(req.Destination.Country)
+
+	c, err := h.carriers.Get(req.Carrier)
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusBadRequest, CodeUnknownCarrier, "Unknown carrier "+req.Carrier)
+		return
+	}
+
+	// Once the carrier is asked, the label is recorded or voided even if the caller has gone
+	ctx := context.WithoutCancel(r.Context())
+	shipment := models.NewShipment(req)
+	label, err := c.CreateLabel(ctx, carrier.LabelRequest{
+		ShipmentID:  shipment.ID,
+		Service:     req.Service,
+		WeightKg:    req.WeightKg,
+		Destination: req.Destination,
+		Domestic:    h.domestic(req.Destination.Country),
+	})
+	if err != nil {
+		if errors.Is(err, carrier.ErrUnknownService) {
+			api.WriteErrorCode(w, http.StatusBadRequest, CodeUnknownService, req.Carrier+" does not offer "+req.Service+" shipping")
+			return
+		}
+		slog.ErrorContext(r.Context(), "Creating label failed", "carrier", req.Carrier, "order_id", req.OrderID, "error", err)
+		api.WriteErrorCode(w, http.StatusServiceUnavailable, CodeCarrierUnavailable, "Unable to create label")
+		return
+	}
+	shipment.Labelled(label.TrackingNumber, label.Cost, label.EstimatedDelivery, label.Data)
+
+	orderShipmentID, err := h.orders.RecordShipment(ctx, req.OrderID, client.ShipmentRecord{
+		ProductIDs:        req.ProductIDs,
+		Carrier:           req.Carrier,
+		TrackingNumber:    label.TrackingNumber,
+		EstimatedDelivery: shipment.EstimatedDelivery,
+	})
+	if err != nil {
+		if voidErr := c.VoidLabel(ctx, label.TrackingNumber); voidErr != nil {
+			slog.ErrorContext(r.Context(), "Voiding unused label failed", "carrier", req.Carrier, "tracking_number", label.TrackingNumber, "error", voidErr)
+		}
+		h.writeOrderError(w, r, err)
+		return
+	}
+	shipment.OrderShipmentID = orderShipmentID
+	shipment.ReportedStatus = shipment.Status
+
+	if err := h.repo.Create(shipment); err != nil {
+		slog.ErrorContext(r.Context(), "Error creating shipment", "order_id", shipment.OrderID, "tracking_number", shipment.TrackingNumber, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to create shipment")
+		return
+	}
+
+	api.WriteJSON(w, http.StatusCreated, models.Response{
+		Success: true,
+		Message: "Shipment created successfully",
+		Data:    shipment,
+	})
+}
+
+// GetShipment handles GET /shipments/{id}
+func (h *ShipmentHandler) GetShipment(w http.ResponseWriter, r *http.Request) {
+	shipment, err := h.repo.Get(mux.Vars(r)["id"])
+	if err != nil {
+		h.writeRepoError(w, r, err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, models.Response{Success: true, Data: shipment})
+}
+
+// ListShipments handles GET /shipments?order_id= - an order's shipments, oldest first
+func (h *ShipmentHandler) ListShipments(w http.ResponseWriter, r *http.Request) {
+	orderID := r.URL.Query().Get("order_id")
+	if orderID == "" {
+		api.WriteError(w, http.StatusBadRequest, "order_id is required")
+		return
+	}
+	shipments, err := h.repo.ListByOrder(orderID)
+	if err != nil {
+		h.writeRepoError(w, r, err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, models.Response{Success: true, Data: shipments})
+}
+
+// GetLabel handles GET /shipments/{id}/label - the carrier's printable label
+func (h *ShipmentHandler) GetLabel(w http.ResponseWriter, r *http.Request) {
+	shipment, err := h.repo.Get(mux.Vars(r)["id"])
+	if err != nil {
+		h.writeRepoError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="`+shipment.TrackingNumber+`.txt"`)
+	w.Write(shipment.Label)
+}
+
+// AddTracking handles POST /shipments/{id}/tracking - records a tracking event by hand, such as one a
+// carrier without a tracking API sent, and tells order service at once
+func (h *ShipmentHandler) AddTracking(w http.ResponseWriter, r *http.Request) {
+	var req models.AddTrackingRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+	event := models.TrackingEvent{
+		Status:      req.Status,
+		Description: strings.TrimSpace(req.Description),
+		Location:    strings.TrimSpace(req.Location),
+		OccurredAt:  time.Now(),
+	}
+	if req.OccurredAt != nil {
+		event.OccurredAt = *req.OccurredAt
+	}
+
+	shipment, err := h.repo.Update(mux.Vars(r)["id"], func(shipment *models.Shipment) error {
+		if shipment.Status == models.TrackingDelivered {
+			return errDelivered
+		}
+		shipment.Track(event, req.EstimatedDelivery)
+		return nil
+	})
+	if errors.Is(err, errDelivered) {
+		api.WriteErrorCode(w, http.StatusConflict, CodeShipmentDelivered, "Shipment was already delivered")
+		return
+	}
+	if err != nil {
+		h.writeRepoError(w, r, err)
+		return
+	}
+
+	// A report order service misses now is sent again by the next tracking sync
+	if shipment.NeedsReport() {
+		if reported, err := h.report(context.WithoutCancel(r.Context()), shipment); err != nil {
+			slog.ErrorContext(r.Context(), "Reporting tracking to order service failed", "shipment_id", shipment.ID, "order_id", shipment.OrderID, "error", err)
+		} else {
+			shipment = reported
+		}
+	}
+	api.WriteJSON(w, http.StatusOK, models.Response{Success: true, Message: "Tracking recorded", Data: shipment})
+}
+
+// errDelivered is returned from an update when the shipment was delivered already
+var errDelivered = errors.New("shipment was already delivered")
+
+// SyncTracking asks the carriers about every parcel still on its way, records what they report, and
+// tells order service of each status it hasn't heard yet. It reports how many shipments changed or
+// were reported, and how many could not be looked up or reported.
+func (h *ShipmentHandler) SyncTracking(ctx context.Context, now time.Time) (int, int, error) {
+	shipments, err := h.repo.ListOpen()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	updated, failed := 0, 0
+	for _, shipment := range shipments {
+		changed := false
+		if shipment.Status != models.TrackingDelivered {
+			tracked, err := h.track(ctx, shipment)
+			if err != nil {
+				slog.ErrorContext(ctx, "Tracking shipment failed", "shipment_id", shipment.ID, "carrier", shipment.Carrier, "error", err)
+				failed++
+			} else if tracked != nil {
+				shipment, changed = tracked, true
+			}
+		}
+		if shipment.NeedsReport() {
+			if _, err := h.report(ctx, shipment); err != nil {
+				slog.ErrorContext(ctx, "Reporting tracking to order service failed", "shipment_id", shipment.ID, "order_id", shipment.OrderID, "error", err)
+				failed++
+			} else {
+				changed = true
+			}
+		}
+		if changed {
+			updated++
+		}
+	}
+	return updated, failed, nil
+}
+
+// track asks the shipment's carrier where the parcel is and records it, returning the shipment as
+// saved, or nil when nothing new was reported
+func (h *ShipmentHandler) track(ctx context.Context, shipment *models.Shipment) (*models.Shipment, error) {
+	c, err := h.carriers.Get(shipment.Carrier)
+	if err != nil {
+		return nil, err
+	}
+	info, err := c.Track(ctx, shipment.TrackingNumber)
+	if err != nil {
+		return nil, err
+	}
+	// Until the carrier has the parcel there is nothing to add to the label being made
+	if info.Event.Status == models.TrackingPending {
+		return nil, nil
+	}
+
+	var changed bool
+	tracked, err := h.repo.Update(shipment.ID, func(shipment *models.Shipment) error {
+		changed = shipment.Track(info.Event, info.EstimatedDelivery)
+		return nil
+	})
+	if err != nil || !changed {
+		return nil, err
+	}
+	return tracked, nil
+}
+
+// report tells order service the shipment's status and returns the shipment as saved. Order service
+// refusing the report, such as for an order since purged, is logged and not retried.
+func (h *ShipmentHandler) report(ctx context.Context, shipment *models.Shipment) (*models.Shipment, error) {
+	err := h.orders.ReportTracking(ctx, shipment.OrderID, shipment.OrderShipmentID, client.TrackingReport{
+		Status:            string(shipment.Status),
+		EstimatedDelivery: shipment.EstimatedDelivery,
+		DeliveredAt:       shipment.DeliveredAt,
+	})
+	var refused *client.RefusedError
+	if errors.As(err, &refused) {
+		slog.WarnContext(ctx, "Order service refused tracking report", "shipment_id", shipment.ID, "order_id", shipment.OrderID, "status", refused.StatusCode, "error", refused.Message)
+	} else if err != nil {
+		return nil, err
+	}
+
+	status := shipment.Status
+	return h.repo.Update(shipment.ID, func(shipment *models.Shipment) error {
+		shipment.ReportedStatus = status
+		return nil
+	})
+}
+
+// domestic reports whether a parcel to country stays within the origin country
+func (h *ShipmentHandler) domestic(country string) bool {
+	return strings.EqualFold(country, h.origin)
+}
+
+// writeOrderError sends the response for order service failing to record a shipment
+func (h *ShipmentHandler) writeOrderError(w http.ResponseWriter, r *http.Request, err error) {
+	var refused *client.RefusedError
+	switch {
+	case errors.As(err, &refused) && refused.StatusCode == http.StatusNotFound:
+		api.WriteErrorCode(w, http.StatusNotFound, CodeOrderNotFound, "Order not found")
+	case errors.As(err, &refused):
+		api.WriteErrorCode(w, http.StatusConflict, CodeOrderNotShippable, refused.Message)
+	default:
+		slog.ErrorContext(r.Context(), "Recording shipment with order service failed", "error", err)
+		api.WriteErrorCode(w, http.StatusServiceUnavailable, CodeOrderServiceDown, "Unable to record shipment on the order")
+	}
+}
+
+// writeRepoError sends the response for an error from the repository
+func (h *ShipmentHandler) writeRepoError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, repository.ErrShipmentNotFound) {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeShipmentNotFound, "Shipment not found")
+		return
+	}
+	slog.ErrorContext(r.Context(), "Error accessing shipments", "error", err)
+	api.WriteError(w, http.StatusInternalServerError, "Failed to access shipments")
+}
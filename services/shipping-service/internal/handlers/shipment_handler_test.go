@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"shipping-service/internal/carrier"
+	"shipping-service/internal/client"
+	"shipping-service/internal/models"
+	"shipping-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeOrders records shipments on fake orders and keeps the tracking reported to it
+type fakeOrders struct {
+	refuse  error // returned for every shipment when set
+	down    bool  // tracking reports fail while set
+	reports []client.TrackingReport
+}
+
+func (f *fakeOrders) RecordShipment(ctx context.Context, orderID string, shipment client.ShipmentRecord) (string, error) {
+	if f.refuse != nil {
+		return "", f.refuse
+	}
+	return "s-" + shipment.TrackingNumber, nil
+}
+
+func (f *fakeOrders) ReportTracking(ctx context.Context, orderID, orderShipmentID string, report client.TrackingReport) error {
+	if f.down {
+		return errors.New("order service unavailable")
+	}
+	f.reports = append(f.reports, report)
+	return nil
+}
+
+func (f *fakeOrders) Ping(ctx context.Context) error {
+	return nil
+}
+
+// fakeCarrier makes labels and reports whatever it is told to
+type fakeCarrier struct {
+	*carrier.MockCarrier
+	info   *carrier.TrackingInfo
+	voided []string
+}
+
+func (c *fakeCarrier) VoidLabel(ctx context.Context, trackingNumber string) error {
+	c.voided = append(c.voided, trackingNumber)
+	return nil
+}
+
+func (c *fakeCarrier) Track(ctx context.Context, trackingNumber string) (*carrier.TrackingInfo, error) {
+	return c.info, nil
+}
+
+func newTestHandler() (*ShipmentHandler, *fakeCarrier, *fakeOrders) {
+	ups := &fakeCarrier{MockCarrier: carrier.MockCarriers()[0].(*carrier.MockCarrier)}
+	orders := &fakeOrders{}
+	return NewShipmentHandler(repository.NewInMemoryShipmentRepository(), carrier.NewRegistry(ups), orders, "US"), ups, orders
+}
+
+// call runs handler with body and the route's vars, decoding the data it answers with into out
+func call(t *testing.T, handler http.HandlerFunc, body string, vars map[string]string, out interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/shipments", bytes.NewBufferString(body)), vars))
+	if out != nil {
+		json.Unmarshal(rec.Body.Bytes(), &models.Response{Data: out})
+	}
+	return rec
+}
+
+const shipmentBody = `{"order_id":"o1","carrier":"ups","weight_kg":2,"destination":{"line1":"1 Main St","city":"Austin","postal_code":"78701","country":"us"}}`
+
+func TestShipmentHandler_QuoteRates(t *testing.T) {
+	h, _, _ := newTestHandler()
+
+	var quotes []models.Quote
+	rec := call(t, h.QuoteRates, `{"weight_kg":2,"country":"DE"}`, nil, &quotes)
+	if rec.Code != http.StatusOK || len(quotes) != 2 || quotes[0].Service != models.ServiceStandard || quotes[0].Amount != 27 {
+		t.Fatalf("expected international standard then express, cheapest first, got %d %+v", rec.Code, quotes)
+	}
+	if rec := call(t, h.QuoteRates, `{"weight_kg":2,"country":"US","carrier":"pigeon"}`, nil, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown carrier, got %d", rec.Code)
+	}
+}
+
+func TestShipmentHandler_CreateShipmentRecordsItOnTheOrder(t *testing.T) {
+	h, ups, orders := newTestHandler()
+
+	var shipment models.Shipment
+	rec := call(t, h.CreateShipment, shipmentBody, nil, &shipment)
+	if rec.Code != http.StatusCreated || shipment.OrderShipmentID != "s-"+shipment.TrackingNumber || shipment.Cost != 8.2 || shipment.Service != models.ServiceStandard {
+		t.Fatalf("expected a domestic standard shipment recorded on the order, got %d %s", rec.Code, rec.Body.String())
+	}
+	rec = call(t, h.GetLabel, "", map[string]string{"id": shipment.ID}, nil)
+	if rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte(shipment.TrackingNumber)) {
+		t.Fatalf("expected the label naming the tracking number, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	orders.refuse = &client.RefusedError{StatusCode: http.StatusConflict, Message: "Only confirmed orders can ship; order is pending"}
+	if rec := call(t, h.CreateShipment, shipmentBody, nil, nil); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 when the order refuses the shipment, got %d", rec.Code)
+	}
+	if len(ups.voided) != 1 {
+		t.Errorf("expected the unused label voided, got %v", ups.voided)
+	}
+	if shipments, _ := h.repo.ListByOrder("o1"); len(shipments) != 1 {
+		t.Errorf("expected only the recorded shipment kept, got %d", len(shipments))
+	}
+}
+
+func TestShipmentHandler_SyncTrackingReportsToOrderService(t *testing.T) {
+	h, ups, orders := newTestHandler()
+	var shipment models.Shipment
+	call(t, h.CreateShipment, shipmentBody, nil, &shipment)
+
+	ups.info = &carrier.TrackingInfo{Event: models.TrackingEvent{Status: models.TrackingPending, OccurredAt: time.Now()}}
+	if updated, failed, err := h.SyncTracking(context.Background(), time.Now()); updated != 0 || failed != 0 || err != nil || len(orders.reports) != 0 {
+		t.Fatalf("expected nothing new before pickup, got %d %d %v %v", updated, failed, err, orders.reports)
+	}
+
+	// A report order service misses is sent again by the next sync
+	pickedUp := models.TrackingEvent{Status: models.TrackingInTransit, Description: "Picked up", OccurredAt: time.Now()}
+	ups.info = &carrier.TrackingInfo{Event: pickedUp}
+	orders.down = true
+	if updated, failed, _ := h.SyncTracking(context.Background(), time.Now()); updated != 1 || failed != 1 {
+		t.Fatalf("expected the shipment updated and its report failed, got %d %d", updated, failed)
+	}
+	orders.down = false
+	if updated, failed, _ := h.SyncTracking(context.Background(), time.Now()); updated != 1 || failed != 0 {
+		t.Fatalf("expected the missed report sent, got %d %d", updated, failed)
+	}
+	if len(orders.reports) != 1 || orders.reports[0].Status != string(models.TrackingInTransit) {
+		t.Fatalf("expected in_transit reported once, got %+v", orders.reports)
+	}
+
+	rec := call(t, h.AddTracking, `{"status":"delivered","location":"Austin"}`, map[string]string{"id": shipment.ID}, &shipment)
+	if rec.Code != http.StatusOK || shipment.Status != models.TrackingDelivered || shipment.DeliveredAt == nil || len(shipment.Events) != 3 {
+		t.Fatalf("expected the shipment delivered, got %d %s", rec.Code, rec.Body.String())
+	}
+	if len(orders.reports) != 2 || orders.reports[1].Status != string(models.TrackingDelivered) || orders.reports[1].DeliveredAt == nil {
+		t.Fatalf("expected the delivery reported at once, got %+v", orders.reports)
+	}
+	if rec := call(t, h.AddTracking, `{"status":"in_transit"}`, map[string]string{"id": shipment.ID}, nil); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 tracking a delivered shipment, got %d", rec.Code)
+	}
+	if open, _ := h.repo.ListOpen(); len(open) != 0 {
+		t.Errorf("expected nothing left to follow, got %d", len(open))
+	}
+}
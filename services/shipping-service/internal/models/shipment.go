@@ -0,0 +1,208 @@
+package models
+
+import (
+	"math"
+	"time"
+	"ecommerce/pkg/api"
+
+	"github.com/google/uuid"
+)
+
+// Response is the response envelope; shipment lists aren't paginated
+type Response = api.Response[api.Unpaged]
+
+// DefaultCurrency is what shipping is quoted and charged in
+const DefaultCurrency = "USD"
+
+// Shipping services a carrier offers
+const (
+	ServiceStandard = "standard"
+	ServiceExpress  = "express"
+)
+
+// TrackingStatus is where the carrier reports a parcel to be. The values are the ones order service
+// records on its shipments.
+type TrackingStatus string
+
+// Tracking states
+const (
+	TrackingPending   TrackingStatus = "pending" // the label is made but the carrier doesn't have the parcel yet
+	TrackingInTransit TrackingStatus = "in_transit"
+	TrackingDelivered TrackingStatus = "delivered"
+	TrackingException TrackingStatus = "exception" // delayed, failed delivery attempt, or returned
+)
+
+// Address is where a parcel is going
+type Address struct {
+	Name       string `json:"name,omitempty" validate:"max=200"`
+	Line1      string `json:"line1" validate:"required,max=200"`
+	Line2      string `json:"line2,omitempty" validate:"max=200"`
+	City       string `json:"city" validate:"required,max=100"`
+	PostalCode string `json:"postal_code" validate:"required,max=20"`
+	Country    string `json:"country" validate:"required,len=2"` // ISO 3166 code
+}
+
+// Shipment is a parcel sent for an order with a carrier's label. It is recorded on the order as one
+// of its shipments, under OrderShipmentID.
+type Shipment struct {
+	ID                string          `json:"id"`
+	OrderID           string          `json:"order_id"`
+	OrderShipmentID   string          `json:"order_shipment_id"`
+	ProductIDs        []string        `json:"product_ids,omitempty"` // every item waiting to ship when empty
+	Carrier           string          `json:"carrier"`
+	Service           string          `json:"service"`
+	TrackingNumber    string          `json:"tracking_number"`
+	WeightKg          float64         `json:"weight_kg"`
+	Destination       Address         `json:"destination"`
+	Cost              float64         `json:"cost"`
+	Currency          string          `json:"currency"`
+	Status            TrackingStatus  `json:"status"`
+	EstimatedDelivery *time.Time      `json:"estimated_delivery,omitempty"`
+	DeliveredAt       *time.Time      `json:"delivered_at,omitempty"`
+	Events            []TrackingEvent `json:"events"`
+	// ReportedStatus is the status order service was last told of; the shipment is reported again
+	// while it differs from Status
+	ReportedStatus TrackingStatus `json:"-"`
+	Label          []byte         `json:"-"` // the carrier's label, served at /shipments/{id}/label
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// TrackingEvent is one scan or report in a parcel's journey
+type TrackingEvent struct {
+	Status      TrackingStatus `json:"status"`
+	Description string         `json:"description,omitempty"`
+	Location    string         `json:"location,omitempty"`
+	OccurredAt  time.Time      `json:"occurred_at"`
+}
+
+// CreateShipmentRequest represents the request payload for making a label and shipping some of an
+// order's items
+type CreateShipmentRequest struct {
+	OrderID string `json:"order_id" validate:"required"`
+	// ProductIDs lists the items in the parcel; every item still waiting to ship is included when empty
+	ProductIDs  []string `json:"product_ids,omitempty"`
+	Carrier     string   `json:"carrier" validate:"required"`
+	Service     string   `json:"service" validate:"omitempty,oneof=standard express"` // standard when empty
+	WeightKg    float64  `json:"weight_kg" validate:"gt=0"`
+	Destination Address  `json:"destination"`
+}
+
+// QuoteRequest represents the request payload for pricing a parcel with every carrier
+type QuoteRequest struct {
+	WeightKg float64 `json:"weight_kg" validate:"gt=0"`
+	// Country is where the parcel is going (an ISO 3166 code)
+	Country string `json:"country" validate:"required,len=2"`
+	Carrier string `json:"carrier,omitempty"` // only this carrier's rates when set
+}
+
+// Quote is what a carrier charges to send a parcel with one of its services
+type Quote struct {
+	Carrier     string  `json:"carrier"`
+	Service     string  `json:"service"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	TransitDays int     `json:"transit_days"`
+}
+
+// AddTrackingRequest represents the request payload for recording a tracking event by hand, such as
+// one a carrier without a tracking API reported
+type AddTrackingRequest struct {
+	Status            TrackingStatus `json:"status" validate:"required,oneof=pending in_transit delivered exception"`
+	Description       string         `json:"description,omitempty" validate:"max=500"`
+	Location          string         `json:"location,omitempty" validate:"max=200"`
+	EstimatedDelivery *time.Time     `json:"estimated_delivery,omitempty"`
+	OccurredAt        *time.Time     `json:"occurred_at,omitempty"` // now when left out
+}
+
+// NewShipment creates a pending shipment for req, still to be labelled
+func NewShipment(req CreateShipmentRequest) *Shipment {
+	now := time.Now()
+	return &Shipment{
+		ID:          "shp_" + uuid.New().String(),
+		OrderID:     req.OrderID,
+		ProductIDs:  req.ProductIDs,
+		Carrier:     req.Carrier,
+		Service:     req.Service,
+		WeightKg:    req.WeightKg,
+		Destination: req.Destination,
+		Currency:    DefaultCurrency,
+		Status:      TrackingPending,
+		Events:      []TrackingEvent{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// Labelled records the carrier's label for the shipment
+func (s *Shipment) Labelled(trackingNumber string, cost float64, estimatedDelivery time.Time, label []byte) {
+	s.TrackingNumber = trackingNumber
+	s.Cost = RoundCents(cost)
+	s.EstimatedDelivery = &estimatedDelivery
+	s.Label = label
+	s.Events = append(s.Events, TrackingEvent{Status: TrackingPending, Description: "Label created", OccurredAt: time.Now()})
+	s.UpdatedAt = time.Now()
+}
+
+// Track records a tracking event and moves the shipment to its status. Events can arrive out of
+// order, so one that would take the shipment back a step is kept in the history without changing the
+// status, an event already recorded is skipped, and nothing changes a delivered shipment. It reports
+// whether the shipment changed.
+func (s *Shipment) Track(event TrackingEvent, estimatedDelivery *time.Time) bool {
+	if s.Status == TrackingDelivered {
+		return false
+	}
+	for _, seen := range s.Events {
+		if seen.Status == event.Status && seen.Description == event.Description && seen.Location == event.Location && seen.OccurredAt.Equal(event.OccurredAt) {
+			return false
+		}
+	}
+
+	s.Events = append(append(make([]TrackingEvent, 0, len(s.Events)+1), s.Events...), event)
+	if advances(s.Status, event.Status) {
+		s.Status = event.Status
+	}
+	if estimatedDelivery != nil {
+		s.EstimatedDelivery = estimatedDelivery
+	}
+	if s.Status == TrackingDelivered {
+		deliveredAt := event.OccurredAt
+		s.DeliveredAt = &deliveredAt
+	}
+	s.UpdatedAt = time.Now()
+	return true
+}
+
+// NeedsReport reports whether order service hasn't been told of the shipment's status yet
+func (s *Shipment) NeedsReport() bool {
+	return s.ReportedStatus != s.Status
+}
+
+// Copy returns a copy of the shipment that shares nothing with it
+func (s *Shipment) Copy() *Shipment {
+	shipment := *s
+	shipment.ProductIDs = append([]string(nil), s.ProductIDs...)
+	shipment.Events = append([]TrackingEvent{}, s.Events...)
+	shipment.Label = append([]byte(nil), s.Label...)
+	return &shipment
+}
+
+// advances reports whether moving a parcel from status from to status is a step forward. A parcel
+// in trouble can get back on its way, and one not yet picked up can run into trouble.
+func advances(from, status TrackingStatus) bool {
+	switch from {
+	case TrackingPending:
+		return status != TrackingPending
+	case TrackingInTransit:
+		return status == TrackingDelivered || status == TrackingException
+	case TrackingException:
+		return status == TrackingInTransit || status == TrackingDelivered
+	default:
+		return false
+	}
+}
+
+// RoundCents rounds an amount to whole cents
+func RoundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
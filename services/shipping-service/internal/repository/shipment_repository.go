@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"shipping-service/internal/models"
+)
+
+var ErrShipmentNotFound = errors.New("shipment not found")
+
+// ShipmentRepository defines the interface for shipment data operations
+type ShipmentRepository interface {
+	Create(shipment *models.Shipment) error
+	Get(id string) (*models.Shipment, error)
+	// ListByOrder returns the shipments sent for an order, oldest first
+	ListByOrder(orderID string) ([]*models.Shipment, error)
+	// ListOpen returns the shipments still to be followed: those not yet delivered, and those whose
+	// status order service hasn't been told of, oldest first
+	ListOpen() ([]*models.Shipment, error)
+	// Update changes a shipment with change, saving it only if change returns nil, and returns the
+	// shipment as saved. No other change to the shipment is made meanwhile.
+	Update(id string, change func(shipment *models.Shipment) error) (*models.Shipment, error)
+	Delete(id string) error
+}
+
+// InMemoryShipmentRepository implements ShipmentRepository using in-memory storage
+type InMemoryShipmentRepository struct {
+	shipments map[string]*models.Shipment
+	mutex     sync.RWMutex
+}
+
+// NewInMemoryShipmentRepository creates a new in-memory shipment repository
+func NewInMemoryShipmentRepository() *InMemoryShipmentRepository {
+	return &InMemoryShipmentRepository{shipments: make(map[string]*models.Shipment)}
+}
+
+// Create adds a shipment
+func (r *InMemoryShipmentRepository) Create(shipment *models.Shipment) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.shipments[shipment.ID] = shipment.Copy()
+	return nil
+}
+
+// Get retrieves a shipment by its ID
+func (r *InMemoryShipmentRepository) Get(id string) (*models.Shipment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	shipment, exists := r.shipments[id]
+	if !exists {
+		return nil, ErrShipmentNotFound
+	}
+	return shipment.Copy(), nil
+}
+
+// ListByOrder returns an order's shipments
+func (r *InMemoryShipmentRepository) ListByOrder(orderID string) ([]*models.Shipment, error) {
+	return r.list(func(shipment *models.Shipment) bool {
+		return shipment.OrderID == orderID
+	}), nil
+}
+
+// ListOpen returns the shipments still to be followed
+func (r *InMemoryShipmentRepository) ListOpen() ([]*models.Shipment, error) {
+	return r.list(func(shipment *models.Shipment) bool {
+		return shipment.Status != models.TrackingDelivered || shipment.NeedsReport()
+	}), nil
+}
+
+// list returns copies of the shipments matching keep, oldest first
+func (r *InMemoryShipmentRepository) list(keep func(shipment *models.Shipment) bool) []*models.Shipment {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	shipments := []*models.Shipment{}
+	for _, shipment := range r.shipments {
+		if keep(shipment) {
+			shipments = append(shipments, shipment.Copy())
+		}
+	}
+	sort.Slice(shipments, func(i, j int) bool {
+		return shipments[i].CreatedAt.Before(shipments[j].CreatedAt)
+	})
+	return shipments
+}
+
+// Update changes a shipment under the repository's lock
+func (r *InMemoryShipmentRepository) Update(id string, change func(shipment *models.Shipment) error) (*models.Shipment, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, exists := r.shipments[id]
+	if !exists {
+		return nil, ErrShipmentNotFound
+	}
+	shipment := stored.Copy()
+	if err := change(shipment); err != nil {
+		return nil, err
+	}
+	r.shipments[id] = shipment
+	return shipment.Copy(), nil
+}
+
+// Delete removes a shipment
+func (r *InMemoryShipmentRepository) Delete(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.shipments[id]; !exists {
+		return ErrShipmentNotFound
+	}
+	delete(r.shipments, id)
+	return nil
+}
+
+// Count returns how many shipments are stored
+func (r *InMemoryShipmentRepository) Count() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return len(r.shipments)
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"testing"
+	"time"
+	"shipping-service/internal/models"
+)
+
+func TestInMemoryShipmentRepository_ListOpen(t *testing.T) {
+	repo := NewInMemoryShipmentRepository()
+	newShipment := func(status, reported models.TrackingStatus, age time.Duration) *models.Shipment {
+		shipment := models.NewShipment(models.CreateShipmentRequest{OrderID: "o1"})
+		shipment.Status, shipment.ReportedStatus = status, reported
+		shipment.CreatedAt = shipment.CreatedAt.Add(-age)
+		repo.Create(shipment)
+		return shipment
+	}
+	inTransit := newShipment(models.TrackingInTransit, models.TrackingInTransit, time.Hour)
+	unreported := newShipment(models.TrackingDelivered, models.TrackingInTransit, 2*time.Hour)
+	newShipment(models.TrackingDelivered, models.TrackingDelivered, 3*time.Hour)
+
+	open, err := repo.ListOpen()
+	if err != nil || len(open) != 2 || open[0].ID != unreported.ID || open[1].ID != inTransit.ID {
+		t.Fatalf("expected the unreported delivery then the parcel in transit, got %v %+v", err, open)
+	}
+	if all, _ := repo.ListByOrder("o1"); len(all) != 3 {
+		t.Errorf("expected all 3 of the order's shipments, got %d", len(all))
+	}
+}
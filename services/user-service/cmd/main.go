@@ -2,50 +2,215 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
+	"ecommerce/pkg/config"
+	"ecommerce/pkg/diagnostics"
+	"ecommerce/pkg/events"
+	"ecommerce/pkg/health"
+	"ecommerce/pkg/logging"
+	"ecommerce/pkg/metrics"
+	"ecommerce/pkg/middleware"
+	"ecommerce/pkg/mongodb"
+	"ecommerce/pkg/openapi"
+	"ecommerce/pkg/postgres"
+	"ecommerce/pkg/rpc"
+	"ecommerce/pkg/snapshot"
+	userv1 "ecommerce/pkg/proto/user/v1"
+	"user-service/internal/auth"
+	"user-service/internal/client"
+	"user-service/internal/consumer"
 	"user-service/internal/handlers"
+	"user-service/internal/models"
 	"user-service/internal/repository"
 
 	"github.com/gorilla/mux"
 )
 
 func main() {
+	// Settings come from flags, the environment, and the YAML file named by -config or CONFIG_FILE
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		logging.Fatal("Failed to load configuration", "error", err)
+	}
+	logging.Setup(cfg.String("SERVICE_NAME", "user-service"))
+	serverConfig := cfg.Server(8081)
+
+	// Settings shown as reloadable at /admin/config are read again on SIGHUP
+	reloader := config.NewReloader(cfg)
+	reloader.Register(config.TuneLogLevel)
+	reloader.Register(config.TuneMaxBodyBytes)
+
+	// With TLS_CERT_FILE, the REST and gRPC APIs are served over mutual TLS, and calls to other services
+	// present the same certificate; the files are read again on SIGHUP, so rotated ones take effect
+	certs := reloader.TLS()
+
 	// Initialize repository
-	userRepo := repository.NewInMemoryUserRepository()
+	userRepo := setupUserRepository(cfg)
+	stopSnapshots := setupSnapshots(cfg, userRepo)
+	metrics.NewGaugeFunc("users_stored", "Users in the repository, deactivated ones included", func() float64 {
+		count, err := userRepo.Count(context.Background())
+		if err != nil {
+			slog.Error("Error counting users", "error", err)
+		}
+		return float64(count)
+	})
+	addressRepo := repository.NewInMemoryAddressRepository()
+	sessionStore := repository.NewInMemorySessionStore()
+	serviceKeyRepo := repository.NewInMemoryServiceKeyRepository()
+	auditRepo := repository.NewInMemoryAuditRepository()
+	tokenRepo := repository.NewInMemoryVerificationTokenRepository()
+	activityRepo := repository.NewInMemoryActivityRepository()
+
+	// Bootstrap an admin account so admin-only endpoints are reachable, and SEED_FILE's sample users
+	seedAdmin(cfg, userRepo)
+	seedUsers(cfg, userRepo)
+
+	// Initialize authentication
+	sessionTTL := cfg.Duration("SESSION_TTL", auth.DefaultSessionTTL, config.Positive)
+	authenticator := auth.NewAuthenticator(userRepo, sessionStore, sessionTTL)
+	loginLimiter := auth.NewLoginLimiter(middleware.RateLimiter(reloader, "LOGIN_RATE_IP", 20, 10), middleware.RateLimiter(reloader, "LOGIN_RATE_EMAIL", 5, 2))
+	serviceKeys := auth.NewServiceKeys(serviceKeyRepo)
+	seedServiceKeys(cfg, serviceKeys)
+
+	// Initialize password policy
+	passwordPolicy := loadPasswordPolicy(cfg)
+
+	// Initialize client for the order service (used for GDPR exports)
+	orderClient := client.NewOrderServiceClient("http://localhost:8083", cfg.String("SERVICE_KEY", ""))
+	if certs != nil {
+		orderClient.UseTLS(certs.ClientConfig())
+	}
+	reloader.Register(func(cfg *config.Config) func() {
+		orderServiceURL := cfg.String("ORDER_SERVICE_URL", "http://localhost:8083")
+		return func() { orderClient.SetURL(orderServiceURL) }
+	})
+
+	// Outbound email and SMS are logged until real providers are configured
+	mailer := client.NewLogMailer()
+	smsSender := client.NewLogSMSSender()
 
 	// Initialize handlers
-	userHandler := handlers.NewUserHandler(userRepo)
+	userHandler := handlers.NewUserHandler(userRepo, authenticator, auditRepo, tokenRepo, passwordPolicy)
+	addressHandler := handlers.NewAddressHandler(addressRepo, userRepo)
+	privacyHandler := handlers.NewPrivacyHandler(userRepo, addressRepo, activityRepo, orderClient)
+	serviceKeyHandler := handlers.NewServiceKeyHandler(serviceKeys)
+	auditHandler := handlers.NewAuditHandler(auditRepo)
+	adminHandler := handlers.NewAdminHandler(userRepo, authenticator, tokenRepo, auditRepo)
+	emailHandler := handlers.NewEmailHandler(userRepo, tokenRepo, mailer, auditRepo)
+	otpHandler := handlers.NewOTPHandler(userRepo, authenticator, tokenRepo, smsSender, auditRepo)
+	activityHandler := handlers.NewActivityHandler(activityRepo)
+
+	// Users' activity feeds are built from the order events arriving through the broker named by BROKER
+	consumeCtx, stopConsuming := context.WithCancel(context.Background())
+	if subscriber := setupSubscriber(cfg); subscriber != nil {
+		topic := cfg.String("ORDER_EVENTS_TOPIC", events.DefaultOrderTopic)
+		go subscriber.Consume(consumeCtx, "user-service", topic, consumer.NewOrderEvents(activityRepo).Handle)
+	}
 
 	// Setup routes
-	router := setupRoutes(userHandler)
+	// With a database as the user store, readiness checks it is reachable
+	probes := health.NewChecker("user-service", health.DefaultTimeout)
+	switch database := userRepo.(type) {
+	case *repository.PostgresUserRepository:
+		probes.Register("postgres", database.Ping)
+	case *repository.MongoUserRepository:
+		probes.Register("mongodb", database.Ping)
+	}
+
+	router := setupRoutes(serverConfig.CORSOrigins, reloader, probes, authenticator, loginLimiter, serviceKeys, userHandler, addressHandler, privacyHandler, serviceKeyHandler, auditHandler, adminHandler, emailHandler, otpHandler, activityHandler)
+
+	// Other services look users up over gRPC, next to the REST API
+	grpcServer := rpc.NewServer(serviceKeys.AuthenticateCall, certs.ServerConfig())
+	userv1.RegisterUserServiceServer(grpcServer, handlers.NewUserServer(userRepo, authenticator))
+
+	// Stop before serving if any setting was invalid, listing every problem at once
+	if err := cfg.Err(); err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+	for _, key := range cfg.Unused() {
+		slog.Warn("Setting is not used", "key", key)
+	}
+	go reloader.Watch(context.Background())
 
-	// Configure server
+	// CPU and heap profiles and runtime variables are served on DEBUG_ADDR, an internal port apart
+	// from the API, when it is set
+	debugServer, err := diagnostics.Serve(serverConfig.DebugAddr)
+	if err != nil {
+		logging.Fatal("Diagnostics port failed to start", "error", err)
+	}
+
+	// Configure server; requests through the proxies in TRUSTED_PROXIES, such as the gateway, are
+	// attributed to the client they came from
 	server := &http.Server{
-		Addr:         ":8081",
-		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:         serverConfig.Addr(),
+		Handler:      middleware.TrustProxies(serverConfig.TrustedProxies)(middleware.Versioned(router, "v1", "v1")),
+		ReadTimeout:  serverConfig.ReadTimeout,
+		WriteTimeout: serverConfig.WriteTimeout,
+		IdleTimeout:  serverConfig.IdleTimeout,
 	}
 
 	// Start server in a goroutine
 	go func() {
-		log.Println("🚀 User Service starting on port 8081...")
-		log.Println("📚 API Documentation:")
-		log.Println("  POST /users           - Create user")
-		log.Println("  GET  /users/{id}      - Get user by ID")
-		log.Println("  GET  /users           - List all users")
-		log.Println("  POST /auth/login      - User login")
-		log.Println("  GET  /health          - Health check")
-		log.Println("---")
-
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed to start: %v", err)
+		slog.Info("🚀 User Service starting", "port", serverConfig.Port)
+		slog.Info("📚 API Documentation:")
+		slog.Info("  POST /users           - Create user")
+		slog.Info("  GET  /users/{id}      - Get user by ID")
+		slog.Info("  GET  /users           - List all users")
+		slog.Info("  DELETE /users/{id}    - Deactivate user (self or admin)")
+		slog.Info("  DELETE /users/{id}?purge=true - Erase personal data, including orders (self or admin)")
+		slog.Info("  GET  /users/{id}/export - Export user data (self or admin)")
+		slog.Info("  POST /users/{id}/addresses              - Add address (self or admin)")
+		slog.Info("  GET  /users/{id}/addresses              - List addresses (self or admin)")
+		slog.Info("  GET  /users/{id}/addresses/default      - Get default shipping/billing address (self or admin)")
+		slog.Info("  PUT  /users/{id}/addresses/{address_id} - Update address (self or admin)")
+		slog.Info("  DELETE /users/{id}/addresses/{address_id} - Delete address (self or admin)")
+		slog.Info("  GET  /users/{id}/activity - List recent orders placed and cancelled (self or admin)")
+		slog.Info("  POST /auth/login      - User login (rate limited per IP and email)")
+		slog.Info("  POST /auth/otp/request - Send a login code by SMS")
+		slog.Info("  POST /auth/otp/verify  - Log in with an SMS code")
+		slog.Info("  POST /auth/logout     - Revoke current session")
+		slog.Info("  POST /auth/refresh    - Exchange current session for a new token")
+		slog.Info("  POST /auth/password-reset - Complete a password reset with a reset token")
+		slog.Info("  POST /users/{id}/password - Change own password")
+		slog.Info("  POST /users/{id}/email    - Request email change (confirmation sent to new address)")
+		slog.Info("  POST /auth/email/confirm  - Confirm email change with token")
+		slog.Info("  GET  /users/{id}/sessions              - List sessions (self or admin)")
+		slog.Info("  DELETE /users/{id}/sessions/{session_id} - Revoke session (self or admin)")
+		slog.Info("  GET  /admin/users?role=...  - List users, optionally by role (admin)")
+		slog.Info("  POST /admin/users/{id}/disable - Disable user and revoke sessions (admin)")
+		slog.Info("  POST /admin/users/{id}/reactivate - Reactivate user (admin)")
+		slog.Info("  POST /admin/users/{id}/force-password-reset - Require password reset, returns reset token (admin)")
+		slog.Info("  PUT  /admin/users/{id}/role - Change user role (admin)")
+		slog.Info("  POST /admin/service-keys       - Issue service API key (admin)")
+		slog.Info("  GET  /admin/service-keys       - List service API keys (admin)")
+		slog.Info("  DELETE /admin/service-keys/{id} - Revoke service API key (admin)")
+		slog.Info("  POST /internal/service-keys/verify - Verify a service API key (internal)")
+		slog.Info("  GET  /internal/users/{id}/addresses/{address_id|default} - Look up an address (internal)")
+		slog.Info("  GET  /admin/audit?user_id=... - Query auth audit log (admin)")
+		slog.Info("  GET  /admin/config         - Settings in effect; reloadable ones are re-read on SIGHUP (admin)")
+		slog.Info("  GET  /healthz         - Liveness probe")
+		slog.Info("  GET  /readyz          - Readiness probe")
+		slog.Info("  GET  /metrics         - Prometheus metrics")
+		slog.Info("---")
+
+		if err := certs.ListenAndServe(server); err != nil && err != http.ErrServerClosed {
+			logging.Fatal("Server failed to start", "error", err)
+		}
+	}()
+
+	go func() {
+		slog.Info("🚀 User Service gRPC API starting", "port", serverConfig.GRPCPort)
+		slog.Info("  ecommerce.user.v1.UserService/GetUser       - Get user by ID")
+		slog.Info("  ecommerce.user.v1.UserService/BatchGetUsers - Get users by ID in one call")
+		slog.Info("  ecommerce.user.v1.UserService/VerifySession - Resolve a bearer token to its session and user")
+		if err := rpc.Serve(grpcServer, serverConfig.GRPCPort); err != nil {
+			logging.Fatal("gRPC server failed to start", "error", err)
 		}
 	}()
 
@@ -54,78 +219,291 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("🛑 Shutting down User Service...")
+	slog.Info("🛑 Shutting down User Service...")
 
 	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), serverConfig.ShutdownTimeout)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
+	stopConsuming()
+	rpc.Shutdown(ctx, grpcServer)
+	debugServer.Close()
+	err = server.Shutdown(ctx)
+	// Snapshot once requests have drained, so the last snapshot has their changes
+	stopSnapshots()
+	if err != nil {
+		slog.Error("Server forced to shutdown", "error", err)
 	} else {
-		log.Println("✅ User Service shutdown complete")
+		slog.Info("✅ User Service shutdown complete")
 	}
 }
 
 // setupRoutes configures all the HTTP routes
-func setupRoutes(userHandler *handlers.UserHandler) *mux.Router {
+func setupRoutes(
+	corsOrigins []string,
+	reloader *config.Reloader,
+	probes *health.Checker,
+	authenticator *auth.Authenticator,
+	loginLimiter *auth.LoginLimiter,
+	serviceKeys *auth.ServiceKeys,
+	userHandler *handlers.UserHandler,
+	addressHandler *handlers.AddressHandler,
+	privacyHandler *handlers.PrivacyHandler,
+	serviceKeyHandler *handlers.ServiceKeyHandler,
+	auditHandler *handlers.AuditHandler,
+	adminHandler *handlers.AdminHandler,
+	emailHandler *handlers.EmailHandler,
+	otpHandler *handlers.OTPHandler,
+	activityHandler *handlers.ActivityHandler,
+) *mux.Router {
 	router := mux.NewRouter()
 
 	// Add CORS middleware
-	router.Use(corsMiddleware)
+	router.Use(middleware.CORS(corsOrigins))
 	
+	// Tag each request with an ID for the logs and calls to other services
+	router.Use(middleware.RequestID)
+
+	// Name the running build on every response
+	router.Use(middleware.ServiceVersion)
+
+	// Send errors as problem details to clients that ask for application/problem+json
+	router.Use(middleware.ProblemDetails)
+
 	// Add logging middleware
-	router.Use(loggingMiddleware)
+	router.Use(middleware.Logging)
+
+	// Count and time requests by route for /metrics
+	router.Use(middleware.Metrics)
+
+	// Resolve bearer tokens into the request context
+	router.Use(authenticator.Authenticate)
+
+	// Resolve service API keys for internal calls
+	router.Use(serviceKeys.Authenticate)
 
-	// API routes
-	api := router.PathPrefix("/").Subrouter()
+	// Limit how fast each client may call: end users per IP, other services per service
+	router.Use(middleware.RateLimit("global", middleware.RateLimiter(reloader, "RATE_LIMIT", 100, 600), middleware.RateLimiter(reloader, "RATE_LIMIT_SERVICE", 1000, 6000), auth.ServiceFromContext))
+
+	// API routes live under a version prefix; operational endpoints stay at the root
+	v1 := router.PathPrefix("/v1").Subrouter()
+
+	// Turn API requests away with 503 once too many are in flight, rather than letting a spike pile up
+	v1.Use(middleware.LoadShedder(reloader).Middleware)
 
 	// User routes
-	api.HandleFunc("/users", userHandler.CreateUser).Methods("POST")
-	api.HandleFunc("/users/{id}", userHandler.GetUser).Methods("GET")
-	api.HandleFunc("/users", userHandler.ListUsers).Methods("GET")
+	v1.HandleFunc("/users", userHandler.CreateUser).Methods("POST")
+	v1.HandleFunc("/users/{id}", userHandler.GetUser).Methods("GET")
+	v1.HandleFunc("/users", userHandler.ListUsers).Methods("GET")
+	v1.Handle("/users/{id}", authenticator.RequireAuth(http.HandlerFunc(privacyHandler.PurgeUser))).Methods("DELETE").Queries("purge", "true")
+	v1.Handle("/users/{id}", authenticator.RequireAuth(http.HandlerFunc(userHandler.DeactivateUser))).Methods("DELETE")
+	v1.Handle("/users/{id}/export", authenticator.RequireAuth(http.HandlerFunc(privacyHandler.ExportUserData))).Methods("GET")
+
+	// Address routes
+	v1.Handle("/users/{id}/addresses", authenticator.RequireAuth(http.HandlerFunc(addressHandler.CreateAddress))).Methods("POST")
+	v1.Handle("/users/{id}/addresses", authenticator.RequireAuth(http.HandlerFunc(addressHandler.ListAddresses))).Methods("GET")
+	v1.Handle("/users/{id}/addresses/default", authenticator.RequireAuth(http.HandlerFunc(addressHandler.GetDefaultAddress))).Methods("GET")
+	v1.Handle("/users/{id}/addresses/{address_id}", authenticator.RequireAuth(http.HandlerFunc(addressHandler.GetAddress))).Methods("GET")
+	v1.Handle("/users/{id}/addresses/{address_id}", authenticator.RequireAuth(http.HandlerFunc(addressHandler.UpdateAddress))).Methods("PUT")
+	v1.Handle("/users/{id}/addresses/{address_id}", authenticator.RequireAuth(http.HandlerFunc(addressHandler.DeleteAddress))).Methods("DELETE")
 
 	// Auth routes
-	api.HandleFunc("/auth/login", userHandler.Login).Methods("POST")
+	v1.Handle("/auth/login", loginLimiter.Limit(http.HandlerFunc(userHandler.Login))).Methods("POST")
+	v1.Handle("/auth/otp/request", loginLimiter.Limit(http.HandlerFunc(otpHandler.RequestOTP))).Methods("POST")
+	v1.Handle("/auth/otp/verify", loginLimiter.Limit(http.HandlerFunc(otpHandler.VerifyOTP))).Methods("POST")
+	v1.Handle("/auth/logout", authenticator.RequireAuth(http.HandlerFunc(userHandler.Logout))).Methods("POST")
+	v1.Handle("/auth/refresh", authenticator.RequireAuth(http.HandlerFunc(userHandler.RefreshToken))).Methods("POST")
+	v1.HandleFunc("/auth/password-reset", userHandler.ResetPassword).Methods("POST")
+	v1.Handle("/users/{id}/email", authenticator.RequireAuth(http.HandlerFunc(emailHandler.RequestEmailChange))).Methods("POST")
+	v1.HandleFunc("/auth/email/confirm", emailHandler.ConfirmEmailChange).Methods("POST")
+	v1.Handle("/users/{id}/password", authenticator.RequireAuth(http.HandlerFunc(userHandler.ChangePassword))).Methods("POST")
+
+	// Session routes
+	v1.Handle("/users/{id}/sessions", authenticator.RequireAuth(http.HandlerFunc(userHandler.ListSessions))).Methods("GET")
+	v1.Handle("/users/{id}/sessions/{session_id}", authenticator.RequireAuth(http.HandlerFunc(userHandler.RevokeSession))).Methods("DELETE")
 
-	// Health check
-	api.HandleFunc("/health", userHandler.HealthCheck).Methods("GET")
+	// Activity routes
+	v1.Handle("/users/{id}/activity", authenticator.RequireAuth(http.HandlerFunc(activityHandler.ListActivity))).Methods("GET")
+
+	// Admin routes
+	admin := v1.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.RateLimit("admin", middleware.RateLimiter(reloader, "RATE_LIMIT_ADMIN", 30, 60), nil, auth.ServiceFromContext))
+	admin.Use(authenticator.RequireRole(models.RoleAdmin))
+	admin.HandleFunc("/users", adminHandler.ListUsers).Methods("GET")
+	admin.HandleFunc("/users/{id}/disable", adminHandler.DisableUser).Methods("POST")
+	admin.HandleFunc("/users/{id}/reactivate", userHandler.ReactivateUser).Methods("POST")
+	admin.HandleFunc("/users/{id}/force-password-reset", adminHandler.ForcePasswordReset).Methods("POST")
+	admin.HandleFunc("/users/{id}/role", adminHandler.ChangeRole).Methods("PUT")
+	admin.HandleFunc("/service-keys", serviceKeyHandler.IssueServiceKey).Methods("POST")
+	admin.HandleFunc("/service-keys", serviceKeyHandler.ListServiceKeys).Methods("GET")
+	admin.HandleFunc("/service-keys/{id}", serviceKeyHandler.RevokeServiceKey).Methods("DELETE")
+	admin.HandleFunc("/audit", auditHandler.ListAuditEvents).Methods("GET")
+	admin.HandleFunc("/config", reloader.ServeSettings).Methods("GET")
+
+	// Internal routes for other services
+	v1.HandleFunc("/internal/service-keys/verify", serviceKeyHandler.VerifyServiceKey).Methods("POST")
+	v1.Handle("/internal/users/{id}/addresses/default", serviceKeys.RequireService(http.HandlerFunc(addressHandler.LookupDefaultAddress))).Methods("GET")
+	v1.Handle("/internal/users/{id}/addresses/{address_id}", serviceKeys.RequireService(http.HandlerFunc(addressHandler.LookupAddress))).Methods("GET")
+
+	// The API's OpenAPI document, and Swagger UI to browse it
+	router.Handle("/openapi.json", handlers.OpenAPI().Handler(router)).Methods("GET")
+	router.PathPrefix("/docs").Handler(openapi.SwaggerUI("/docs/", "/openapi.json")).Methods("GET")
+
+	// Liveness and readiness probes
+	router.HandleFunc("/healthz", probes.Liveness).Methods("GET")
+	router.HandleFunc("/readyz", probes.Readiness).Methods("GET")
+
+	// Service metrics
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
 
 	return router
 }
 
-// corsMiddleware adds CORS headers to responses
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-		// Handle preflight requests
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+// setupUserRepository picks the user store from USER_STORE: "memory" (the default), "postgres",
+// which connects to DATABASE_URL and creates its table on first start, or "mongodb", which uses the
+// MONGODB_DATABASE database at MONGODB_URI and creates its indexes on first start
+func setupUserRepository(cfg *config.Config) repository.UserRepository {
+	switch store := cfg.String("USER_STORE", "memory"); store {
+	case "memory":
+		return repository.NewInMemoryUserRepository()
+	case "postgres":
+		databaseURL := cfg.String("DATABASE_URL", "")
+		if databaseURL == "" {
+			logging.Fatal("Invalid store configuration: DATABASE_URL is required")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		db, err := postgres.Open(ctx, databaseURL)
+		if err != nil {
+			logging.Fatal("Failed to connect to PostgreSQL", "error", err)
+		}
+		repo, err := repository.NewPostgresUserRepository(ctx, db)
+		if err != nil {
+			logging.Fatal("Failed to prepare the users table", "error", err)
+		}
+		return repo
+	case "mongodb":
+		mongoURI := cfg.String("MONGODB_URI", "")
+		if mongoURI == "" {
+			logging.Fatal("Invalid store configuration: MONGODB_URI is required")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		db, err := mongodb.Connect(ctx, mongoURI, cfg.String("MONGODB_DATABASE", "ecommerce"))
+		if err != nil {
+			logging.Fatal("Failed to connect to MongoDB", "error", err)
+		}
+		repo, err := repository.NewMongoUserRepository(ctx, db)
+		if err != nil {
+			logging.Fatal("Failed to prepare the users collection", "error", err)
 		}
+		return repo
+	default:
+		logging.Fatal("Invalid USER_STORE", "store", store)
+		return nil
+	}
+}
 
-		next.ServeHTTP(w, r)
-	})
+// setupSnapshots keeps in-memory users in SNAPSHOT_DIR, when it is set, so a dev or demo instance
+// keeps them across restarts. They are snapshotted every SNAPSHOT_INTERVAL, with each change logged
+// in between; the returned stop takes a last snapshot.
+func setupSnapshots(cfg *config.Config, userRepo repository.UserRepository) (stop func()) {
+	dir := cfg.String("SNAPSHOT_DIR", "")
+	memoryRepo, inMemory := userRepo.(*repository.InMemoryUserRepository)
+	if dir == "" || !inMemory {
+		return func() {}
+	}
+	if err := memoryRepo.PersistTo(dir); err != nil {
+		logging.Fatal("Failed to load the users snapshot", "dir", dir, "error", err)
+	}
+	slog.Info("Persisting users", "dir", dir)
+	interval := cfg.Duration("SNAPSHOT_INTERVAL", snapshot.DefaultInterval, config.Positive)
+	return snapshot.Schedule("users", memoryRepo, interval)
 }
 
-// loggingMiddleware logs HTTP requests
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-
-		// Call the next handler
-		next.ServeHTTP(w, r)
-
-		// Log the request
-		log.Printf(
-			"[%s] %s %s %v",
-			r.Method,
-			r.RequestURI,
-			r.RemoteAddr,
-			time.Since(start),
-		)
-	})
+// setupSubscriber configures consuming events from BROKER: "log" (the default) means order events only
+// go to order service's log, so there is nothing to consume, and "kafka-rest" consumes them through
+// the Kafka REST Proxy at KAFKA_REST_URL
+func setupSubscriber(cfg *config.Config) events.Subscriber {
+	switch broker := cfg.String("BROKER", "log"); broker {
+	case "log":
+		return nil
+	case "kafka-rest":
+		proxyURL := cfg.String("KAFKA_REST_URL", "")
+		if proxyURL == "" {
+			logging.Fatal("Invalid broker configuration: KAFKA_REST_URL is required")
+		}
+		return events.NewKafkaREST(proxyURL)
+	default:
+		logging.Fatal("Invalid BROKER", "broker", broker)
+		return nil
+	}
+}
+
+// seedAdmin creates an admin account from ADMIN_EMAIL/ADMIN_PASSWORD when both are set
+func seedAdmin(cfg *config.Config, userRepo repository.UserRepository) {
+	email := cfg.String("ADMIN_EMAIL", "")
+	password := cfg.String("ADMIN_PASSWORD", "")
+	if email == "" || password == "" {
+		return
+	}
+
+	admin := models.NewUser("Administrator", email, password)
+	admin.Role = models.RoleAdmin
+	if err := userRepo.Create(context.Background(), admin); err != nil {
+		slog.Error("Failed to seed admin user", "error", err)
+		return
+	}
+	slog.Info("👤 Admin user seeded", "email", email)
+}
+
+// seedUsers creates the users in the SEED_FILE fixtures, such as fixtures/demo.yaml, that the store
+// doesn't have yet. It runs after snapshots are loaded, so users kept from an earlier run aren't
+// created again.
+func seedUsers(cfg *config.Config, userRepo repository.UserRepository) {
+	path := cfg.String("SEED_FILE", "")
+	if path == "" {
+		return
+	}
+	created, err := repository.SeedUsers(context.Background(), userRepo, path)
+	if err != nil {
+		logging.Fatal("Failed to seed users", "file", path, "error", err)
+	}
+	slog.Info("🌱 Users seeded", "file", path, "created", created)
+}
+
+// seedServiceKeys registers pre-shared keys from SERVICE_KEYS ("service:key,service:key")
+// so services can authenticate to each other without a manual issuance step
+func seedServiceKeys(cfg *config.Config, serviceKeys *auth.ServiceKeys) {
+	for _, entry := range cfg.List("SERVICE_KEYS", nil) {
+		service, key, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found || service == "" || key == "" {
+			continue
+		}
+		if _, err := serviceKeys.Register(service, key); err != nil {
+			slog.Error("Failed to register service key", "service", service, "error", err)
+			continue
+		}
+		slog.Info("🔑 Service key registered", "service", service)
+	}
+}
+
+// loadPasswordPolicy builds the password policy from the PASSWORD_* settings,
+// falling back to auth.DefaultPasswordPolicy for anything unset
+func loadPasswordPolicy(cfg *config.Config) *auth.PasswordPolicy {
+	policy := auth.DefaultPasswordPolicy()
+	policy.MinLength = cfg.Int("PASSWORD_MIN_LENGTH", policy.MinLength, config.Positive)
+	policy.RequireUpper = cfg.Bool("PASSWORD_REQUIRE_UPPER", policy.RequireUpper)
+	policy.RequireLower = cfg.Bool("PASSWORD_REQUIRE_LOWER", policy.RequireLower)
+	policy.RequireDigit = cfg.Bool("PASSWORD_REQUIRE_DIGIT", policy.RequireDigit)
+	policy.RequireSymbol = cfg.Bool("PASSWORD_REQUIRE_SYMBOL", policy.RequireSymbol)
+
+	if path := cfg.String("PASSWORD_BANNED_FILE", ""); path != "" {
+		if err := policy.LoadBannedFile(path); err != nil {
+			logging.Fatal("Failed to load banned passwords", "error", err)
+		}
+		slog.Info("🔒 Banned password list loaded", "path", path)
+	}
+
+	return policy
 }
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+	"ecommerce/pkg/api"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+)
+
+// DefaultSessionTTL is how long a login session stays valid when no TTL is configured
+const DefaultSessionTTL = 24 * time.Hour
+
+var (
+	errInvalidToken    = errors.New("Invalid or expired token")
+	errInactiveAccount = errors.New("Account is deactivated")
+)
+
+type contextKey string
+
+const (
+	userContextKey    contextKey = "auth.user"
+	sessionContextKey contextKey = "auth.session"
+)
+
+// Authenticator issues session tokens, resolves bearer tokens to users and enforces access rules
+type Authenticator struct {
+	repo     repository.UserRepository
+	sessions repository.SessionStore
+	ttl      time.Duration
+}
+
+// NewAuthenticator creates a new authenticator backed by the user repository and session store
+func NewAuthenticator(repo repository.UserRepository, sessions repository.SessionStore, ttl time.Duration) *Authenticator {
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	return &Authenticator{
+		repo:     repo,
+		sessions: sessions,
+		ttl:      ttl,
+	}
+}
+
+// StartSession creates a new session for the user and returns it; the bearer token is session.Token
+func (a *Authenticator) StartSession(user *models.User, r *http.Request) (*models.Session, error) {
+	token, err := GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := models.NewSession(user.ID, token, r.UserAgent(), r.RemoteAddr, a.ttl)
+	if err := a.sessions.Create(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Sessions returns the underlying session store
+func (a *Authenticator) Sessions() repository.SessionStore {
+	return a.sessions
+}
+
+// Authenticate resolves the bearer token (if any) and stores the caller in the request context.
+// Requests without a token pass through unauthenticated; invalid tokens are rejected.
+func (a *Authenticator) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, session, err := a.resolve(r.Context(), header)
+		if err != nil {
+			api.WriteError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		ctx := WithSession(WithUser(r.Context(), user), session)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireAuth rejects requests that were not authenticated by Authenticate
+func (a *Authenticator) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if UserFromContext(r.Context()) == nil {
+			api.WriteError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireRole rejects requests whose authenticated user does not have the given role
+func (a *Authenticator) RequireRole(role models.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return a.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if UserFromContext(r.Context()).Role != role {
+				api.WriteError(w, http.StatusForbidden, "Insufficient permissions")
+				return
+			}
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// resolve maps an Authorization header to a valid session and its active user
+func (a *Authenticator) resolve(ctx context.Context, header string) (*models.User, *models.Session, error) {
+	token := strings.TrimPrefix(header, "Bearer ")
+	if token == header {
+		return nil, nil, errInvalidToken
+	}
+	return a.Verify(ctx, token)
+}
+
+// Verify maps a bearer token to a valid session and its active user, marking the session as seen. It
+// fails when the token is invalid or expired or the account is deactivated, with a message fit for the
+// caller.
+func (a *Authenticator) Verify(ctx context.Context, token string) (*models.User, *models.Session, error) {
+	if token == "" {
+		return nil, nil, errInvalidToken
+	}
+
+	session, err := a.sessions.GetByToken(token)
+	if err != nil || !session.IsValid() {
+		return nil, nil, errInvalidToken
+	}
+
+	user, err := a.repo.GetByID(ctx, session.UserID)
+	if err != nil {
+		return nil, nil, errInvalidToken
+	}
+	if !user.Active {
+		return nil, nil, errInactiveAccount
+	}
+
+	a.sessions.Touch(session.ID)
+	return user, session, nil
+}
+
+// WithUser returns a copy of ctx carrying the authenticated user
+func WithUser(ctx context.Context, user *models.User) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+// WithSession returns a copy of ctx carrying the current session
+func WithSession(ctx context.Context, session *models.Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey, session)
+}
+
+// SessionFromContext returns the current session, or nil for anonymous requests
+func SessionFromContext(ctx context.Context) *models.Session {
+	session, _ := ctx.Value(sessionContextKey).(*models.Session)
+	return session
+}
+
+// UserFromContext returns the authenticated user, or nil for anonymous requests
+func UserFromContext(ctx context.Context) *models.User {
+	user, _ := ctx.Value(userContextKey).(*models.User)
+	return user
+}
+
+// CanAccessUser checks whether the caller may act on the given user's data
+func CanAccessUser(ctx context.Context, userID string) bool {
+	caller := UserFromContext(ctx)
+	return caller != nil && (caller.ID == userID || caller.IsAdmin())
+}
+
+// GenerateToken generates a random opaque secret suitable for bearer and verification tokens
+func GenerateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// GenerateCode generates a random numeric code of the given length, for codes users type in
+func GenerateCode(digits int) (string, error) {
+	code := make([]byte, digits)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		code[i] = byte('0' + n.Int64())
+	}
+	return string(code), nil
+}
+
+// HashToken returns the hex-encoded SHA-256 of a secret, for storing secrets at rest
+func HashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
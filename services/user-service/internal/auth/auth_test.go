@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+)
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func startSession(t *testing.T, a *Authenticator, user *models.User) *models.Session {
+	t.Helper()
+	session, err := a.StartSession(user, httptest.NewRequest(http.MethodPost, "/auth/login", nil))
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	return session
+}
+
+func TestRequireRole(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository()
+	customer := models.NewUser("Customer", "c@example.com", "p")
+	admin := models.NewUser("Admin", "a@example.com", "p")
+	admin.Role = models.RoleAdmin
+	_ = repo.Create(context.Background(), customer)
+	_ = repo.Create(context.Background(), admin)
+
+	a := NewAuthenticator(repo, repository.NewInMemorySessionStore(), 0)
+	handler := a.Authenticate(a.RequireRole(models.RoleAdmin)(okHandler()))
+	customerSession := startSession(t, a, customer)
+	adminSession := startSession(t, a, admin)
+
+	cases := []struct {
+		name   string
+		token  string
+		expect int
+	}{
+		{"anonymous", "", http.StatusUnauthorized},
+		{"invalid token", "Bearer nope", http.StatusUnauthorized},
+		{"customer", "Bearer " + customerSession.Token, http.StatusForbidden},
+		{"admin", "Bearer " + adminSession.Token, http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", tc.token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.expect {
+			t.Errorf("%s: expected %d got %d", tc.name, tc.expect, rec.Code)
+		}
+	}
+}
+
+func TestAuthenticate_RejectsDeactivatedUser(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository()
+	user := models.NewUser("User", "u@example.com", "p")
+	_ = repo.Create(context.Background(), user)
+	a := NewAuthenticator(repo, repository.NewInMemorySessionStore(), 0)
+	session := startSession(t, a, user)
+	_ = repo.SetActive(context.Background(), user.ID, false)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+session.Token)
+	rec := httptest.NewRecorder()
+	a.Authenticate(okHandler()).ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 got %d", rec.Code)
+	}
+}
+
+func TestAuthenticate_RejectsRevokedAndExpiredSessions(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository()
+	user := models.NewUser("User", "u@example.com", "p")
+	_ = repo.Create(context.Background(), user)
+	sessions := repository.NewInMemorySessionStore()
+	a := NewAuthenticator(repo, sessions, 0)
+
+	revoked := startSession(t, a, user)
+	_ = sessions.Revoke(revoked.ID)
+
+	expired := models.NewSession(user.ID, "expired-token", "", "", -time.Minute)
+	_ = sessions.Create(expired)
+
+	for name, token := range map[string]string{"revoked": revoked.Token, "expired": expired.Token} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		a.Authenticate(okHandler()).ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401 got %d", name, rec.Code)
+		}
+	}
+}
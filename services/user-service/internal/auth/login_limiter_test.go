@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"ecommerce/pkg/ratelimit"
+)
+
+func loginRequest(remoteAddr, email string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewBufferString(`{"email":"`+email+`","password":"p"}`))
+	req.RemoteAddr = remoteAddr
+	return req
+}
+
+func TestLoginLimiter_PerEmail(t *testing.T) {
+	var seenBody string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		seenBody = string(body)
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := NewLoginLimiter(nil, ratelimit.NewTokenBucket(1, 1)).Limit(next)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, loginRequest("10.0.0.1:1234", "a@example.com"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected first attempt to pass, got %d", rec.Code)
+	}
+	if seenBody == "" {
+		t.Error("expected request body to be preserved for the handler")
+	}
+
+	// A different IP does not bypass the per-email limit, and email matching is case-insensitive
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, loginRequest("10.0.0.2:1234", "A@Example.com"))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, loginRequest("10.0.0.1:1234", "b@example.com"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected other email to pass, got %d", rec.Code)
+	}
+}
+
+func TestLoginLimiter_PerIP(t *testing.T) {
+	handler := NewLoginLimiter(ratelimit.NewTokenBucket(1, 1), nil).Limit(okHandler())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, loginRequest("10.0.0.1:1234", "a@example.com"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected first attempt to pass, got %d", rec.Code)
+	}
+
+	// Same host on a different source port shares the bucket
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, loginRequest("10.0.0.1:5678", "b@example.com"))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 got %d", rec.Code)
+	}
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"user-service/internal/models"
+)
+
+// PasswordPolicy validates new passwords on signup, reset, and change
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	banned        map[string]struct{}
+}
+
+// DefaultPasswordPolicy returns the policy used when nothing is configured
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength:    8,
+		RequireUpper: true,
+		RequireLower: true,
+		RequireDigit: true,
+	}
+}
+
+// SetBanned replaces the banned password list; comparison is case-insensitive
+func (p *PasswordPolicy) SetBanned(passwords []string) {
+	p.banned = make(map[string]struct{}, len(passwords))
+	for _, password := range passwords {
+		p.banned[strings.ToLower(password)] = struct{}{}
+	}
+}
+
+// LoadBannedFile reads a banned password list with one entry per line.
+// Blank lines and lines starting with # are ignored.
+func (p *PasswordPolicy) LoadBannedFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open banned password list: %w", err)
+	}
+	defer file.Close()
+
+	var passwords []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords = append(passwords, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read banned password list: %w", err)
+	}
+
+	p.SetBanned(passwords)
+	return nil
+}
+
+// Validate returns every rule the password violates, or nil when it is acceptable
+func (p *PasswordPolicy) Validate(password string) []models.PolicyViolation {
+	var violations []models.PolicyViolation
+
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, models.PolicyViolation{
+			Code:    models.ViolationTooShort,
+			Message: fmt.Sprintf("Password must be at least %d characters", p.MinLength),
+		})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireUpper && !hasUpper {
+		violations = append(violations, models.PolicyViolation{Code: models.ViolationMissingUpper, Message: "Password must contain an uppercase letter"})
+	}
+	if p.RequireLower && !hasLower {
+		violations = append(violations, models.PolicyViolation{Code: models.ViolationMissingLower, Message: "Password must contain a lowercase letter"})
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, models.PolicyViolation{Code: models.ViolationMissingDigit, Message: "Password must contain a digit"})
+	}
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, models.PolicyViolation{Code: models.ViolationMissingSymbol, Message: "Password must contain a symbol"})
+	}
+
+	if _, banned := p.banned[strings.ToLower(password)]; banned {
+		violations = append(violations, models.PolicyViolation{Code: models.ViolationBanned, Message: "Password is too common"})
+	}
+
+	return violations
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"user-service/internal/models"
+)
+
+func violationCodes(violations []models.PolicyViolation) map[string]bool {
+	codes := make(map[string]bool)
+	for _, v := range violations {
+		codes[v.Code] = true
+	}
+	return codes
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+	policy.RequireSymbol = true
+
+	if violations := policy.Validate("Str0ng!Pass"); len(violations) != 0 {
+		t.Fatalf("expected strong password to pass, got %+v", violations)
+	}
+
+	codes := violationCodes(policy.Validate("abc"))
+	for _, code := range []string{models.ViolationTooShort, models.ViolationMissingUpper, models.ViolationMissingDigit, models.ViolationMissingSymbol} {
+		if !codes[code] {
+			t.Errorf("expected violation %s", code)
+		}
+	}
+	if codes[models.ViolationMissingLower] {
+		t.Error("did not expect missing lowercase violation")
+	}
+}
+
+func TestPasswordPolicy_LoadBannedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banned.txt")
+	if err := os.WriteFile(path, []byte("# common passwords\n\nPassword123\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := DefaultPasswordPolicy()
+	if err := policy.LoadBannedFile(path); err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if !violationCodes(policy.Validate("PASSWORD123"))[models.ViolationBanned] {
+		t.Error("expected banned password to be rejected case-insensitively")
+	}
+	if policy.LoadBannedFile(filepath.Join(t.TempDir(), "missing.txt")) == nil {
+		t.Error("expected error for missing file")
+	}
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"user-service/internal/repository"
+)
+
+func TestServiceKeys_RequireService(t *testing.T) {
+	keys := NewServiceKeys(repository.NewInMemoryServiceKeyRepository())
+	if _, err := keys.Register("order-service", "sk_test_order"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	var caller string
+	handler := keys.Authenticate(keys.RequireService(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller = ServiceFromContext(r.Context())
+	})))
+
+	cases := []struct {
+		name   string
+		key    string
+		expect int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong", "sk_wrong", http.StatusUnauthorized},
+		{"valid", "sk_test_order", http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/internal", nil)
+		if tc.key != "" {
+			req.Header.Set(ServiceKeyHeader, tc.key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.expect {
+			t.Errorf("%s: expected %d got %d", tc.name, tc.expect, rec.Code)
+		}
+	}
+	if caller != "order-service" {
+		t.Errorf("expected caller order-service, got %q", caller)
+	}
+}
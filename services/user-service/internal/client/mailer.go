@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Mailer abstracts outbound email delivery.
+// Implemented by LogMailer; swap in an SMTP or provider-backed sender in production.
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// LogMailer writes emails to the service log instead of delivering them (development only)
+type LogMailer struct{}
+
+// NewLogMailer creates a mailer that logs outgoing messages
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+// Send logs the message
+func (m *LogMailer) Send(ctx context.Context, to, subject, body string) error {
+	slog.InfoContext(ctx, "📧 Email", "to", to, "subject", subject, "body", body)
+	return nil
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"ecommerce/pkg/requestid"
+)
+
+// OrderClient abstracts the order-service operations needed by user service.
+// Implemented by OrderServiceClient; enables mocking in tests.
+type OrderClient interface {
+	GetUserOrders(ctx context.Context, userID string) (json.RawMessage, error)
+	AnonymizeUserOrders(ctx context.Context, userID string) error
+}
+
+// serviceKeyHeader carries this service's API key on internal calls
+const serviceKeyHeader = "X-Service-Key"
+
+// OrderServiceClient handles communication with the order service
+type OrderServiceClient struct {
+	httpClient      *http.Client
+	orderServiceURL string
+	serviceKey      string
+	mutex           sync.RWMutex // guards orderServiceURL
+}
+
+// NewOrderServiceClient creates a new client for the order service.
+// serviceKey is sent as X-Service-Key to authenticate this service.
+func NewOrderServiceClient(orderServiceURL, serviceKey string) *OrderServiceClient {
+	return &OrderServiceClient{
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		orderServiceURL: orderServiceURL,
+		serviceKey:      serviceKey,
+	}
+}
+
+// UseTLS makes the client call order service over TLS with config, presenting this service's
+// certificate; order service's URL must then be https. Call it before the client is used.
+func (c *OrderServiceClient) UseTLS(config *tls.Config) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	c.httpClient.Transport = transport
+}
+
+// SetURL points the client at an order service that has moved
+func (c *OrderServiceClient) SetURL(orderServiceURL string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.orderServiceURL = orderServiceURL
+}
+
+// baseURL returns the order service's current URL
+func (c *OrderServiceClient) baseURL() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.orderServiceURL
+}
+
+// serviceResponse represents the standard response envelope returned by the other services
+type serviceResponse struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error"`
+}
+
+// GetUserOrders retrieves all orders of a user as raw JSON, so the user service
+// does not need to mirror the order model
+func (c *OrderServiceClient) GetUserOrders(ctx context.Context, userID string) (json.RawMessage, error) {
+	url := fmt.Sprintf("%s/v1/orders/user/%s", c.baseURL(), userID)
+	resp, err := c.do(ctx, http.MethodGet, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call order service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("order service returned status %d", resp.StatusCode)
+	}
+
+	var envelope serviceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode order service response: %w", err)
+	}
+	if !envelope.Success {
+		return nil, fmt.Errorf("order service error: %s", envelope.Error)
+	}
+	if len(envelope.Data) == 0 || string(envelope.Data) == "null" {
+		return json.RawMessage("[]"), nil
+	}
+	return envelope.Data, nil
+}
+
+// AnonymizeUserOrders asks the order service to strip personal data from a user's historical orders
+func (c *OrderServiceClient) AnonymizeUserOrders(ctx context.Context, userID string) error {
+	url := fmt.Sprintf("%s/v1/orders/user/%s/anonymize", c.baseURL(), userID)
+	resp, err := c.do(ctx, http.MethodPost, url)
+	if err != nil {
+		return fmt.Errorf("failed to call order service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("order service returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// do sends a bodiless request authenticated with the service key, tagged with ctx's request ID
+func (c *OrderServiceClient) do(ctx context.Context, method, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	requestid.Propagate(req)
+	if c.serviceKey != "" {
+		req.Header.Set(serviceKeyHeader, c.serviceKey)
+	}
+	return c.httpClient.Do(req)
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SMSSender abstracts outbound SMS delivery.
+// Implemented by LogSMSSender; swap in a provider-backed sender (e.g. Twilio) in production.
+type SMSSender interface {
+	Send(ctx context.Context, to, message string) error
+}
+
+// LogSMSSender writes text messages to the service log instead of delivering them (development only)
+type LogSMSSender struct{}
+
+// NewLogSMSSender creates an SMS sender that logs outgoing messages
+func NewLogSMSSender() *LogSMSSender {
+	return &LogSMSSender{}
+}
+
+// Send logs the message
+func (s *LogSMSSender) Send(ctx context.Context, to, message string) error {
+	slog.InfoContext(ctx, "📱 SMS", "to", to, "message", message)
+	return nil
+}
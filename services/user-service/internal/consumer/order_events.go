@@ -0,0 +1,55 @@
+// Package consumer handles the events other services publish that feed users' data
+package consumer
+
+import (
+	"context"
+	"log/slog"
+	"ecommerce/pkg/events"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+)
+
+// OrderEvents adds users' orders to their activity feeds: an entry when an order is placed and
+// another when it is cancelled
+type OrderEvents struct {
+	activities repository.ActivityRepository
+}
+
+// NewOrderEvents creates a handler recording activity in activities
+func NewOrderEvents(activities repository.ActivityRepository) *OrderEvents {
+	return &OrderEvents{activities: activities}
+}
+
+// Handle processes one order event. An activity takes its event's ID, so an event delivered again
+// isn't recorded twice.
+func (c *OrderEvents) Handle(ctx context.Context, msg events.Message) error {
+	event, err := events.DecodeOrderEvent(msg)
+	if err != nil {
+		// Retrying can't fix a malformed event
+		slog.ErrorContext(ctx, "Skipping undecodable order event", "offset", msg.Offset, "error", err)
+		return nil
+	}
+
+	var activityType models.ActivityType
+	switch event.Type {
+	case events.OrderCreated:
+		activityType = models.ActivityOrderPlaced
+	case events.OrderCancelled:
+		activityType = models.ActivityOrderCancelled
+	default:
+		return nil
+	}
+	// Guest orders belong to no account
+	if event.Data.Order.UserID == "" {
+		return nil
+	}
+
+	return c.activities.Add(&models.Activity{
+		ID:         event.ID,
+		UserID:     event.Data.Order.UserID,
+		Type:       activityType,
+		OrderID:    event.OrderID,
+		Total:      event.Data.Order.Total,
+		OccurredAt: event.OccurredAt,
+	})
+}
@@ -0,0 +1,50 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+	"ecommerce/pkg/events"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+)
+
+func orderMessage(t *testing.T, event events.OrderEvent) events.Message {
+	value, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events.Message{Topic: events.DefaultOrderTopic, Key: event.OrderID, Value: value}
+}
+
+func TestOrderEvents_BuildsActivityFeed(t *testing.T) {
+	activities := repository.NewInMemoryActivityRepository()
+	consumer := NewOrderEvents(activities)
+	placedAt := time.Now().Add(-time.Hour)
+	order := events.Order{ID: "o1", UserID: "u1", Total: 42}
+
+	messages := []events.Message{
+		orderMessage(t, events.OrderEvent{ID: "e1", Type: events.OrderCreated, OrderID: "o1", Data: events.OrderEventData{Order: order}, OccurredAt: placedAt}),
+		orderMessage(t, events.OrderEvent{ID: "e2", Type: events.OrderStatusChanged, OrderID: "o1", Data: events.OrderEventData{Order: order}, OccurredAt: placedAt.Add(time.Minute)}),
+		orderMessage(t, events.OrderEvent{ID: "e3", Type: events.OrderCancelled, OrderID: "o1", Data: events.OrderEventData{Order: order}, OccurredAt: placedAt.Add(time.Minute)}),
+		// Delivered again after a restart
+		orderMessage(t, events.OrderEvent{ID: "e1", Type: events.OrderCreated, OrderID: "o1", Data: events.OrderEventData{Order: order}, OccurredAt: placedAt}),
+		// A guest order belongs to no account
+		orderMessage(t, events.OrderEvent{ID: "e4", Type: events.OrderCreated, OrderID: "o2", Data: events.OrderEventData{Order: events.Order{ID: "o2"}}}),
+		{Value: json.RawMessage(`"not an event"`)},
+	}
+	for _, msg := range messages {
+		if err := consumer.Handle(context.Background(), msg); err != nil {
+			t.Fatalf("handle failed: %v", err)
+		}
+	}
+
+	feed, _ := activities.ListByUser("u1", 0)
+	if len(feed) != 2 || feed[0].Type != models.ActivityOrderCancelled || feed[1].Type != models.ActivityOrderPlaced {
+		t.Fatalf("expected the cancellation then the order, got %+v", feed)
+	}
+	if feed[1].OrderID != "o1" || feed[1].Total != 42 || !feed[1].OccurredAt.Equal(placedAt) {
+		t.Errorf("unexpected activity %+v", feed[1])
+	}
+}
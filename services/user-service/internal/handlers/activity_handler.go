@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"ecommerce/pkg/api"
+	"user-service/internal/auth"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// ActivityHandler serves users' activity feeds
+type ActivityHandler struct {
+	repo repository.ActivityRepository
+}
+
+// NewActivityHandler creates a new activity handler
+func NewActivityHandler(repo repository.ActivityRepository) *ActivityHandler {
+	return &ActivityHandler{
+		repo: repo,
+	}
+}
+
+// ListActivity handles GET /users/{id}/activity - returns a user's recent activity, newest first
+// (self or admin). Supports ?limit=, 50 by default.
+func (h *ActivityHandler) ListActivity(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := mux.Vars(r)["id"]
+	if !auth.CanAccessUser(r.Context(), userID) {
+		api.WriteError(w, http.StatusForbidden, "Not allowed to view this user's activity")
+		return
+	}
+
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	activities, err := h.repo.ListByUser(userID, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing activity", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve activity")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    activities,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"user-service/internal/auth"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+func TestListActivity_SelfOnlyNewestFirst(t *testing.T) {
+	repo := repository.NewInMemoryActivityRepository()
+	user := models.NewUser("Test", "t@example.com", "secret")
+	other := models.NewUser("Other", "o@example.com", "secret")
+	now := time.Now()
+	_ = repo.Add(&models.Activity{ID: "e1", UserID: user.ID, Type: models.ActivityOrderPlaced, OrderID: "o1", OccurredAt: now.Add(-time.Hour)})
+	_ = repo.Add(&models.Activity{ID: "e2", UserID: user.ID, Type: models.ActivityOrderPlaced, OrderID: "o2", OccurredAt: now})
+	h := NewActivityHandler(repo)
+
+	list := func(caller *models.User, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users/"+user.ID+"/activity"+query, nil)
+		req = mux.SetURLVars(req.WithContext(auth.WithUser(req.Context(), caller)), map[string]string{"id": user.ID})
+		rec := httptest.NewRecorder()
+		h.ListActivity(rec, req)
+		return rec
+	}
+
+	if rec := list(other, ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for another user got %d", rec.Code)
+	}
+
+	rec := list(user, "?limit=1")
+	var response struct {
+		Data []models.Activity `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d %v", rec.Code, err)
+	}
+	if len(response.Data) != 1 || response.Data[0].OrderID != "o2" {
+		t.Errorf("expected only the newest activity, got %+v", response.Data)
+	}
+}
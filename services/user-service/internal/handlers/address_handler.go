@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"ecommerce/pkg/api"
+	"user-service/internal/auth"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// AddressHandler handles HTTP requests for the /users/{id}/addresses sub-resource. Only the user
+// themselves or an admin may use those routes; other services look addresses up through the
+// /internal routes with a service key instead.
+type AddressHandler struct {
+	repo     repository.AddressRepository
+	userRepo repository.UserRepository
+}
+
+// NewAddressHandler creates a new address handler
+func NewAddressHandler(repo repository.AddressRepository, userRepo repository.UserRepository) *AddressHandler {
+	return &AddressHandler{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+// CreateAddress handles POST /users/{id}/addresses - adds an address to a user
+func (h *AddressHandler) CreateAddress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := mux.Vars(r)["id"]
+	if !h.canAccess(w, r, userID) || !h.userExists(w, r, userID) {
+		return
+	}
+
+	var req models.CreateAddressRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	address := models.NewAddress(userID, req)
+	if err := h.repo.Create(address); err != nil {
+		slog.ErrorContext(r.Context(), "Error creating address", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to create address")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Address created successfully",
+		Data:    address,
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListAddresses handles GET /users/{id}/addresses - lists a user's addresses
+func (h *AddressHandler) ListAddresses(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := mux.Vars(r)["id"]
+	if !h.canAccess(w, r, userID) || !h.userExists(w, r, userID) {
+		return
+	}
+
+	addresses, err := h.repo.ListByUser(userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing addresses", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve addresses")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    addresses,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetAddress handles GET /users/{id}/addresses/{address_id} - retrieves a single address
+func (h *AddressHandler) GetAddress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !h.canAccess(w, r, mux.Vars(r)["id"]) {
+		return
+	}
+	h.LookupAddress(w, r)
+}
+
+// LookupAddress handles GET /internal/users/{id}/addresses/{address_id} - retrieves a single address
+// for another service, such as the order service checking where an order ships
+func (h *AddressHandler) LookupAddress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	address, err := h.repo.GetByID(vars["id"], vars["address_id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeAddressNotFound, "Address not found")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    address,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetDefaultAddress handles GET /users/{id}/addresses/default?type=shipping|billing
+// - retrieves the user's default address for the given slot (shipping if omitted)
+func (h *AddressHandler) GetDefaultAddress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !h.canAccess(w, r, mux.Vars(r)["id"]) {
+		return
+	}
+	h.LookupDefaultAddress(w, r)
+}
+
+// LookupDefaultAddress handles GET /internal/users/{id}/addresses/default?type=shipping|billing
+// - retrieves a user's default address for another service
+func (h *AddressHandler) LookupDefaultAddress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := mux.Vars(r)["id"]
+
+	addressType := models.AddressType(r.URL.Query().Get("type"))
+	if addressType == "" {
+		addressType = models.AddressTypeShipping
+	}
+	if !models.IsValidAddressType(addressType) {
+		api.WriteError(w, http.StatusBadRequest, "Address type must be shipping or billing")
+		return
+	}
+
+	address, err := h.repo.GetDefault(userID, addressType)
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeAddressNotFound, "Default address not found")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    address,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateAddress handles PUT /users/{id}/addresses/{address_id} - updates an address
+func (h *AddressHandler) UpdateAddress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	if !h.canAccess(w, r, vars["id"]) {
+		return
+	}
+	address, err := h.repo.GetByID(vars["id"], vars["address_id"])
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeAddressNotFound, "Address not found")
+		return
+	}
+
+	var req models.UpdateAddressRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	// Update fields if provided
+	if req.Label != nil {
+		address.Label = *req.Label
+	}
+	if req.RecipientName != nil {
+		address.RecipientName = *req.RecipientName
+	}
+	if req.Line1 != nil {
+		address.Line1 = *req.Line1
+	}
+	if req.Line2 != nil {
+		address.Line2 = *req.Line2
+	}
+	if req.City != nil {
+		address.City = *req.City
+	}
+	if req.State != nil {
+		address.State = *req.State
+	}
+	if req.PostalCode != nil {
+		address.PostalCode = *req.PostalCode
+	}
+	if req.Country != nil {
+		address.Country = *req.Country
+	}
+	if req.Phone != nil {
+		address.Phone = *req.Phone
+	}
+	if req.IsDefaultShipping != nil {
+		address.IsDefaultShipping = *req.IsDefaultShipping
+	}
+	if req.IsDefaultBilling != nil {
+		address.IsDefaultBilling = *req.IsDefaultBilling
+	}
+
+	if err := h.repo.Update(address); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating address", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to update address")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Address updated successfully",
+		Data:    address,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteAddress handles DELETE /users/{id}/addresses/{address_id} - removes an address
+func (h *AddressHandler) DeleteAddress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	if !h.canAccess(w, r, vars["id"]) {
+		return
+	}
+	if err := h.repo.Delete(vars["id"], vars["address_id"]); err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeAddressNotFound, "Address not found")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Address deleted successfully",
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// canAccess writes a 403 response and returns false unless the caller is the user or an admin
+func (h *AddressHandler) canAccess(w http.ResponseWriter, r *http.Request, userID string) bool {
+	if !auth.CanAccessUser(r.Context(), userID) {
+		api.WriteError(w, http.StatusForbidden, "Not allowed to access this user's addresses")
+		return false
+	}
+	return true
+}
+
+// userExists writes a 404 response and returns false when the user does not exist
+func (h *AddressHandler) userExists(w http.ResponseWriter, r *http.Request, userID string) bool {
+	if _, err := h.userRepo.GetByID(r.Context(), userID); err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return false
+	}
+	return true
+}
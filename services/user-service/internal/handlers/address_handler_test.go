@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"user-service/internal/auth"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+func setupAddressHandler(t *testing.T) (*AddressHandler, *models.User) {
+	t.Helper()
+	userRepo := repository.NewInMemoryUserRepository()
+	user := models.NewUser("Test", "t@example.com", "secret")
+	if err := userRepo.Create(context.Background(), user); err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	return NewAddressHandler(repository.NewInMemoryAddressRepository(), userRepo), user
+}
+
+// addressRequest returns a request made by caller (anonymous when nil) with the user ID route variable set
+func addressRequest(method, target, body string, userID string, caller *models.User) *http.Request {
+	req := mux.SetURLVars(httptest.NewRequest(method, target, bytes.NewBufferString(body)), map[string]string{"id": userID})
+	if caller != nil {
+		req = req.WithContext(auth.WithUser(req.Context(), caller))
+	}
+	return req
+}
+
+func TestCreateAddress_SetsDefaultShipping(t *testing.T) {
+	h, user := setupAddressHandler(t)
+	body := `{"recipient_name":"Test","line1":"1 Main St","city":"Nairobi","postal_code":"00100","country":"KE"}`
+	rec := httptest.NewRecorder()
+
+	h.CreateAddress(rec, addressRequest(http.MethodPost, "/users/"+user.ID+"/addresses", body, user.ID, user))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", rec.Code)
+	}
+
+	req := addressRequest(http.MethodGet, "/users/"+user.ID+"/addresses/default?type=shipping", "", user.ID, user)
+	rec = httptest.NewRecorder()
+	h.GetDefaultAddress(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+	var resp struct {
+		Data models.Address `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	if !resp.Data.IsDefaultShipping || resp.Data.Line1 != "1 Main St" {
+		t.Errorf("unexpected default address: %+v", resp.Data)
+	}
+}
+
+func TestCreateAddress_UnknownUser(t *testing.T) {
+	h, _ := setupAddressHandler(t)
+	admin := models.NewUser("Admin", "a@example.com", "secret")
+	admin.Role = models.RoleAdmin
+	body := `{"recipient_name":"Test","line1":"1 Main St","city":"Nairobi","postal_code":"00100","country":"KE"}`
+	rec := httptest.NewRecorder()
+
+	h.CreateAddress(rec, addressRequest(http.MethodPost, "/users/missing/addresses", body, "missing", admin))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 got %d", rec.Code)
+	}
+}
+
+func TestGetDefaultAddress_InvalidType(t *testing.T) {
+	h, user := setupAddressHandler(t)
+	rec := httptest.NewRecorder()
+
+	h.GetDefaultAddress(rec, addressRequest(http.MethodGet, "/users/"+user.ID+"/addresses/default?type=gift", "", user.ID, user))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 got %d", rec.Code)
+	}
+}
+
+func TestAddresses_OnlyTheUserOrAnAdmin(t *testing.T) {
+	h, user := setupAddressHandler(t)
+	address := models.NewAddress(user.ID, models.CreateAddressRequest{RecipientName: "Test", Line1: "1 Main St", City: "Nairobi", PostalCode: "00100", Country: "KE"})
+	if err := h.repo.Create(address); err != nil {
+		t.Fatalf("seed address: %v", err)
+	}
+	other := models.NewUser("Other", "o@example.com", "secret")
+	withAddress := func(req *http.Request) *http.Request {
+		return mux.SetURLVars(req, map[string]string{"id": user.ID, "address_id": address.ID})
+	}
+
+	for name, call := range map[string]func(caller *models.User) int{
+		"create": func(caller *models.User) int {
+			rec := httptest.NewRecorder()
+			h.CreateAddress(rec, addressRequest(http.MethodPost, "/users/"+user.ID+"/addresses", `{}`, user.ID, caller))
+			return rec.Code
+		},
+		"list": func(caller *models.User) int {
+			rec := httptest.NewRecorder()
+			h.ListAddresses(rec, addressRequest(http.MethodGet, "/users/"+user.ID+"/addresses", "", user.ID, caller))
+			return rec.Code
+		},
+		"default": func(caller *models.User) int {
+			rec := httptest.NewRecorder()
+			h.GetDefaultAddress(rec, addressRequest(http.MethodGet, "/users/"+user.ID+"/addresses/default", "", user.ID, caller))
+			return rec.Code
+		},
+		"get": func(caller *models.User) int {
+			rec := httptest.NewRecorder()
+			h.GetAddress(rec, withAddress(addressRequest(http.MethodGet, "/users/"+user.ID+"/addresses/"+address.ID, "", user.ID, caller)))
+			return rec.Code
+		},
+		"update": func(caller *models.User) int {
+			rec := httptest.NewRecorder()
+			h.UpdateAddress(rec, withAddress(addressRequest(http.MethodPut, "/users/"+user.ID+"/addresses/"+address.ID, `{"label":"Home"}`, user.ID, caller)))
+			return rec.Code
+		},
+		"delete": func(caller *models.User) int {
+			rec := httptest.NewRecorder()
+			h.DeleteAddress(rec, withAddress(addressRequest(http.MethodDelete, "/users/"+user.ID+"/addresses/"+address.ID, "", user.ID, caller)))
+			return rec.Code
+		},
+	} {
+		if code := call(nil); code != http.StatusForbidden {
+			t.Errorf("%s anonymously: expected 403 got %d", name, code)
+		}
+		if code := call(other); code != http.StatusForbidden {
+			t.Errorf("%s as another user: expected 403 got %d", name, code)
+		}
+	}
+
+	// Other services look addresses up with a service key, through routes with no user check
+	rec := httptest.NewRecorder()
+	h.LookupAddress(rec, withAddress(addressRequest(http.MethodGet, "/internal/users/"+user.ID+"/addresses/"+address.ID, "", user.ID, nil)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 from the internal lookup got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.LookupDefaultAddress(rec, addressRequest(http.MethodGet, "/internal/users/"+user.ID+"/addresses/default", "", user.ID, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 from the internal default lookup got %d", rec.Code)
+	}
+}
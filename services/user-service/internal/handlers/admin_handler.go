@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+	"ecommerce/pkg/api"
+	"user-service/internal/auth"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// PasswordResetTTL is how long an admin-issued password reset token stays valid
+const PasswordResetTTL = 24 * time.Hour
+
+// AdminHandler handles admin-only user management requests
+type AdminHandler struct {
+	repo   repository.UserRepository
+	auth   *auth.Authenticator
+	tokens repository.VerificationTokenRepository
+	audit  repository.AuditRepository
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(repo repository.UserRepository, authenticator *auth.Authenticator, tokens repository.VerificationTokenRepository, audit repository.AuditRepository) *AdminHandler {
+	return &AdminHandler{
+		repo:   repo,
+		auth:   authenticator,
+		tokens: tokens,
+		audit:  audit,
+	}
+}
+
+// ListUsers handles GET /admin/users - lists users, optionally filtered by ?role=
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		users []*models.User
+		err   error
+	)
+	if role := models.Role(r.URL.Query().Get("role")); role != "" {
+		if !models.IsValidRole(role) {
+			api.WriteErrorCode(w, http.StatusBadRequest, CodeInvalidRole, "Invalid role")
+			return
+		}
+		users, err = h.repo.ListByRole(r.Context(), role)
+	} else {
+		users, err = h.repo.List(r.Context())
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing users", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve users")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Users retrieved successfully",
+		Data:    users,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// DisableUser handles POST /admin/users/{id}/disable - disables an account and signs it out everywhere
+func (h *AdminHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := mux.Vars(r)["id"]
+	if actor := auth.UserFromContext(r.Context()); actor != nil && actor.ID == userID {
+		api.WriteError(w, http.StatusBadRequest, "Admins cannot disable their own account")
+		return
+	}
+
+	if err := h.repo.SetActive(r.Context(), userID, false); err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+
+	if err := h.auth.Sessions().RevokeAllForUser(userID); err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking sessions for disabled user", "user_id", userID, "error", err)
+	}
+
+	user, err := h.repo.GetByID(r.Context(), userID)
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+	recordAudit(h.audit, r, models.AuditUserDisabled, user.ID, user.Email, "")
+
+	response := models.Response{
+		Success: true,
+		Message: "User disabled successfully",
+		Data:    user,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// ForcePasswordReset handles POST /admin/users/{id}/force-password-reset - blocks login until the
+// user sets a new password with the returned one-time token
+func (h *AdminHandler) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := mux.Vars(r)["id"]
+	user, err := h.repo.GetByID(r.Context(), userID)
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+
+	secret, err := auth.GenerateToken()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating reset token", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to issue reset token")
+		return
+	}
+
+	// Only the latest reset token stays valid
+	if err := h.tokens.InvalidateForUser(models.TokenPurposePasswordReset, userID); err != nil {
+		slog.ErrorContext(r.Context(), "Error invalidating reset tokens", "user_id", userID, "error", err)
+	}
+
+	token := models.NewVerificationToken(models.TokenPurposePasswordReset, userID, auth.HashToken(secret), "", PasswordResetTTL)
+	if err := h.tokens.Create(token); err != nil {
+		slog.ErrorContext(r.Context(), "Error storing reset token", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to issue reset token")
+		return
+	}
+
+	if err := h.repo.SetPasswordResetRequired(r.Context(), userID, true); err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+
+	if err := h.auth.Sessions().RevokeAllForUser(userID); err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking sessions", "user_id", userID, "error", err)
+	}
+
+	recordAudit(h.audit, r, models.AuditResetForced, user.ID, user.Email, "")
+
+	response := models.Response{
+		Success: true,
+		Message: "Password reset required",
+		Data: models.PasswordResetResponse{
+			UserID:     userID,
+			ResetToken: secret,
+			ExpiresAt:  token.ExpiresAt,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// ChangeRole handles PUT /admin/users/{id}/role - changes a user's role
+func (h *AdminHandler) ChangeRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := mux.Vars(r)["id"]
+
+	var req models.ChangeRoleRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	if !models.IsValidRole(req.Role) {
+		api.WriteErrorCode(w, http.StatusBadRequest, CodeInvalidRole, "Invalid role")
+		return
+	}
+
+	// Prevent admins from locking themselves out of the admin API
+	if actor := auth.UserFromContext(r.Context()); actor != nil && actor.ID == userID {
+		api.WriteError(w, http.StatusBadRequest, "Admins cannot change their own role")
+		return
+	}
+
+	if err := h.repo.SetRole(r.Context(), userID, req.Role); err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+
+	user, err := h.repo.GetByID(r.Context(), userID)
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+	recordAudit(h.audit, r, models.AuditRoleChanged, user.ID, user.Email, string(req.Role))
+
+	response := models.Response{
+		Success: true,
+		Message: "Role updated successfully",
+		Data:    user,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
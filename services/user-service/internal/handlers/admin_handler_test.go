@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"user-service/internal/auth"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+func setupAdminHandler() (*AdminHandler, *UserHandler, *models.User) {
+	repo := repository.NewInMemoryUserRepository()
+	authenticator := auth.NewAuthenticator(repo, repository.NewInMemorySessionStore(), 0)
+	audit := repository.NewInMemoryAuditRepository()
+	tokens := repository.NewInMemoryVerificationTokenRepository()
+
+	admin := models.NewUser("Admin", "admin@example.com", "secret")
+	admin.Role = models.RoleAdmin
+	_ = repo.Create(context.Background(), admin)
+
+	return NewAdminHandler(repo, authenticator, tokens, audit), NewUserHandler(repo, authenticator, audit, tokens, &auth.PasswordPolicy{}), admin
+}
+
+func adminRequest(method, target string, body *bytes.Buffer, admin *models.User, vars map[string]string) *http.Request {
+	if body == nil {
+		body = &bytes.Buffer{}
+	}
+	req := httptest.NewRequest(method, target, body)
+	req = req.WithContext(auth.WithUser(req.Context(), admin))
+	return mux.SetURLVars(req, vars)
+}
+
+func TestAdminListUsers_FiltersByRole(t *testing.T) {
+	h, _, admin := setupAdminHandler()
+	_ = h.repo.Create(context.Background(), models.NewUser("Customer", "c@example.com", "secret"))
+
+	rec := httptest.NewRecorder()
+	h.ListUsers(rec, adminRequest(http.MethodGet, "/admin/users?role=admin", nil, admin, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+	var resp struct {
+		Data []models.User `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	if len(resp.Data) != 1 || resp.Data[0].ID != admin.ID {
+		t.Fatalf("expected only the admin, got %+v", resp.Data)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ListUsers(rec, adminRequest(http.MethodGet, "/admin/users?role=owner", nil, admin, nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown role got %d", rec.Code)
+	}
+}
+
+func TestAdminDisableUser_RevokesSessions(t *testing.T) {
+	h, userHandler, admin := setupAdminHandler()
+	user := models.NewUser("Test", "t@example.com", "secret")
+	_ = h.repo.Create(context.Background(), user)
+	login := loginAs(t, userHandler, "t@example.com", "secret")
+
+	rec := httptest.NewRecorder()
+	h.DisableUser(rec, adminRequest(http.MethodPost, "/admin/users/"+user.ID+"/disable", nil, admin, map[string]string{"id": user.ID}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+	if session, err := h.auth.Sessions().GetByToken(login.Token); err == nil && session.IsValid() {
+		t.Error("expected sessions to be revoked")
+	}
+
+	rec = httptest.NewRecorder()
+	h.DisableUser(rec, adminRequest(http.MethodPost, "/admin/users/"+admin.ID+"/disable", nil, admin, map[string]string{"id": admin.ID}))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 disabling self got %d", rec.Code)
+	}
+}
+
+func TestAdminForcePasswordReset_BlocksLoginUntilReset(t *testing.T) {
+	h, userHandler, admin := setupAdminHandler()
+	user := models.NewUser("Test", "t@example.com", "secret")
+	_ = h.repo.Create(context.Background(), user)
+
+	rec := httptest.NewRecorder()
+	h.ForcePasswordReset(rec, adminRequest(http.MethodPost, "/admin/users/"+user.ID+"/force-password-reset", nil, admin, map[string]string{"id": user.ID}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+	var resp struct {
+		Data models.PasswordResetResponse `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Data.ResetToken == "" {
+		t.Fatal("expected a reset token")
+	}
+
+	rec = httptest.NewRecorder()
+	userHandler.Login(rec, httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewBufferString(`{"email":"t@example.com","password":"secret"}`)))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected login to be blocked, got %d", rec.Code)
+	}
+
+	body := `{"token":"` + resp.Data.ResetToken + `","new_password":"newsecret"}`
+	rec = httptest.NewRecorder()
+	userHandler.ResetPassword(rec, httptest.NewRequest(http.MethodPost, "/auth/password-reset", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 on reset got %d", rec.Code)
+	}
+	loginAs(t, userHandler, "t@example.com", "newsecret")
+
+	// Tokens are single-use
+	rec = httptest.NewRecorder()
+	userHandler.ResetPassword(rec, httptest.NewRequest(http.MethodPost, "/auth/password-reset", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 reusing token got %d", rec.Code)
+	}
+}
+
+func TestAdminChangeRole(t *testing.T) {
+	h, _, admin := setupAdminHandler()
+	user := models.NewUser("Test", "t@example.com", "secret")
+	_ = h.repo.Create(context.Background(), user)
+
+	rec := httptest.NewRecorder()
+	h.ChangeRole(rec, adminRequest(http.MethodPut, "/admin/users/"+user.ID+"/role", bytes.NewBufferString(`{"role":"admin"}`), admin, map[string]string{"id": user.ID}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+	if updated, _ := h.repo.GetByID(context.Background(), user.ID); updated.Role != models.RoleAdmin {
+		t.Errorf("expected role admin got %s", updated.Role)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ChangeRole(rec, adminRequest(http.MethodPut, "/admin/users/"+user.ID+"/role", bytes.NewBufferString(`{"role":"owner"}`), admin, map[string]string{"id": user.ID}))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown role got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ChangeRole(rec, adminRequest(http.MethodPut, "/admin/users/"+admin.ID+"/role", bytes.NewBufferString(`{"role":"customer"}`), admin, map[string]string{"id": admin.ID}))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 changing own role got %d", rec.Code)
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+	"ecommerce/pkg/api"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+)
+
+// AuditHandler handles queries against the authentication audit log
+type AuditHandler struct {
+	repo repository.AuditRepository
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(repo repository.AuditRepository) *AuditHandler {
+	return &AuditHandler{
+		repo: repo,
+	}
+}
+
+// ListAuditEvents handles GET /admin/audit - queries the audit log (admin only).
+// Supports ?user_id=, ?type=, ?since= (RFC 3339) and ?limit=.
+func (h *AuditHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	filter := &models.AuditFilter{
+		UserID: query.Get("user_id"),
+		Type:   models.AuditEventType(query.Get("type")),
+		Limit:  100,
+	}
+
+	if sinceStr := query.Get("since"); sinceStr != "" {
+		since, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			api.WriteError(w, http.StatusBadRequest, "since must be an RFC 3339 timestamp")
+			return
+		}
+		filter.Since = since
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
+			filter.Limit = limit
+		}
+	}
+
+	events, err := h.repo.Query(filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying audit log", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve audit events")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    events,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"ecommerce/pkg/api"
+	"user-service/internal/auth"
+	"user-service/internal/client"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// EmailChangeTTL is how long an email change confirmation token stays valid
+const EmailChangeTTL = 24 * time.Hour
+
+// EmailHandler handles the two-step email change flow
+type EmailHandler struct {
+	repo   repository.UserRepository
+	tokens repository.VerificationTokenRepository
+	mailer client.Mailer
+	audit  repository.AuditRepository
+}
+
+// NewEmailHandler creates a new email handler
+func NewEmailHandler(repo repository.UserRepository, tokens repository.VerificationTokenRepository, mailer client.Mailer, audit repository.AuditRepository) *EmailHandler {
+	return &EmailHandler{
+		repo:   repo,
+		tokens: tokens,
+		mailer: mailer,
+		audit:  audit,
+	}
+}
+
+// RequestEmailChange handles POST /users/{id}/email - sends a confirmation token to the new address.
+// The current email stays active until the change is confirmed.
+func (h *EmailHandler) RequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := mux.Vars(r)["id"]
+	caller := auth.UserFromContext(r.Context())
+	if caller == nil || caller.ID != userID {
+		api.WriteError(w, http.StatusForbidden, "Users can only change their own email")
+		return
+	}
+
+	var req models.ChangeEmailRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	newEmail := strings.TrimSpace(req.NewEmail)
+	if newEmail == "" || req.Password == "" {
+		api.WriteError(w, http.StatusBadRequest, "New email and password are required")
+		return
+	}
+
+	// Re-read the user with credentials to check the password
+	user, err := h.repo.GetByEmail(r.Context(), caller.Email)
+	if err != nil || user.Password != req.Password {
+		api.WriteErrorCode(w, http.StatusUnauthorized, CodePasswordIncorrect, "Password is incorrect")
+		return
+	}
+
+	if newEmail == user.Email {
+		api.WriteError(w, http.StatusBadRequest, "New email must differ from the current email")
+		return
+	}
+	if _, err := h.repo.GetByEmail(r.Context(), newEmail); err == nil {
+		api.WriteErrorCode(w, http.StatusConflict, CodeEmailTaken, "Email is already in use")
+		return
+	}
+
+	secret, err := auth.GenerateToken()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating email change token", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to start email change")
+		return
+	}
+
+	// Only the latest pending change can be confirmed
+	if err := h.tokens.InvalidateForUser(models.TokenPurposeEmailChange, userID); err != nil {
+		slog.ErrorContext(r.Context(), "Error invalidating email change tokens", "user_id", userID, "error", err)
+	}
+
+	token := models.NewVerificationToken(models.TokenPurposeEmailChange, userID, auth.HashToken(secret), newEmail, EmailChangeTTL)
+	if err := h.tokens.Create(token); err != nil {
+		slog.ErrorContext(r.Context(), "Error storing email change token", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to start email change")
+		return
+	}
+
+	body := fmt.Sprintf("Confirm your new email address with this token: %s\nIt expires at %s.", secret, token.ExpiresAt.Format(time.RFC1123))
+	if err := h.mailer.Send(r.Context(), newEmail, "Confirm your new email address", body); err != nil {
+		slog.ErrorContext(r.Context(), "Error sending email change confirmation", "error", err)
+		api.WriteError(w, http.StatusBadGateway, "Failed to send confirmation email")
+		return
+	}
+
+	recordAudit(h.audit, r, models.AuditEmailChangeRequested, user.ID, user.Email, newEmail)
+
+	response := models.Response{
+		Success: true,
+		Message: "Confirmation sent to the new email address",
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ConfirmEmailChange handles POST /auth/email/confirm - applies a pending email change
+func (h *EmailHandler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.ConfirmEmailRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	token, err := h.tokens.Consume(models.TokenPurposeEmailChange, auth.HashToken(req.Token))
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusBadRequest, CodeConfirmationTokenInvalid, "Invalid or expired confirmation token")
+		return
+	}
+
+	user, err := h.repo.GetByID(r.Context(), token.UserID)
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+
+	// The address may have been claimed by another account since the request
+	if err := h.repo.UpdateEmail(r.Context(), user.ID, token.Payload); err != nil {
+		api.WriteErrorCode(w, http.StatusConflict, CodeEmailTaken, "Email is already in use")
+		return
+	}
+	user.Email = token.Payload
+	recordAudit(h.audit, r, models.AuditEmailChanged, user.ID, user.Email, "")
+
+	response := models.Response{
+		Success: true,
+		Message: "Email updated successfully",
+		Data:    user,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
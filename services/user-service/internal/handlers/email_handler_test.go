@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"user-service/internal/auth"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// captureMailer records the last email sent
+type captureMailer struct {
+	to   string
+	body string
+}
+
+func (m *captureMailer) Send(ctx context.Context, to, subject, body string) error {
+	m.to = to
+	m.body = body
+	return nil
+}
+
+// token extracts the confirmation token from the captured email body
+func (m *captureMailer) token() string {
+	_, rest, _ := strings.Cut(m.body, "token: ")
+	token, _, _ := strings.Cut(rest, "\n")
+	return token
+}
+
+func requestEmailChange(h *EmailHandler, user *models.User, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/users/"+user.ID+"/email", bytes.NewBufferString(body))
+	req = mux.SetURLVars(req.WithContext(auth.WithUser(req.Context(), user)), map[string]string{"id": user.ID})
+	rec := httptest.NewRecorder()
+	h.RequestEmailChange(rec, req)
+	return rec
+}
+
+func TestEmailChange_RequiresConfirmation(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository()
+	mailer := &captureMailer{}
+	h := NewEmailHandler(repo, repository.NewInMemoryVerificationTokenRepository(), mailer, repository.NewInMemoryAuditRepository())
+	user := models.NewUser("Test", "old@example.com", "secret")
+	_ = repo.Create(context.Background(), user)
+
+	rec := requestEmailChange(h, user, `{"new_email":"new@example.com","password":"secret"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 got %d", rec.Code)
+	}
+	if mailer.to != "new@example.com" || mailer.token() == "" {
+		t.Fatalf("expected token to be mailed to the new address, got %q", mailer.to)
+	}
+	if _, err := repo.GetByEmail(context.Background(), "old@example.com"); err != nil {
+		t.Error("expected old email to stay active until confirmation")
+	}
+
+	confirm := `{"token":"` + mailer.token() + `"}`
+	rec = httptest.NewRecorder()
+	h.ConfirmEmailChange(rec, httptest.NewRequest(http.MethodPost, "/auth/email/confirm", bytes.NewBufferString(confirm)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+	if updated, _ := repo.GetByID(context.Background(), user.ID); updated.Email != "new@example.com" {
+		t.Errorf("expected email to be updated, got %s", updated.Email)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ConfirmEmailChange(rec, httptest.NewRequest(http.MethodPost, "/auth/email/confirm", bytes.NewBufferString(confirm)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected token reuse to fail, got %d", rec.Code)
+	}
+}
+
+func TestEmailChange_Rejections(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository()
+	h := NewEmailHandler(repo, repository.NewInMemoryVerificationTokenRepository(), &captureMailer{}, repository.NewInMemoryAuditRepository())
+	user := models.NewUser("Test", "old@example.com", "secret")
+	_ = repo.Create(context.Background(), user)
+	_ = repo.Create(context.Background(), models.NewUser("Other", "taken@example.com", "secret"))
+
+	cases := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"wrong password", `{"new_email":"new@example.com","password":"nope"}`, http.StatusUnauthorized},
+		{"email taken", `{"new_email":"taken@example.com","password":"secret"}`, http.StatusConflict},
+		{"same email", `{"new_email":"old@example.com","password":"secret"}`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		if rec := requestEmailChange(h, user, tc.body); rec.Code != tc.code {
+			t.Errorf("%s: expected %d got %d", tc.name, tc.code, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package handlers
+
+// Error codes the user service sends in the code of an error response, so clients can tell
+// failures apart without matching messages. Errors without one of these get a code named after
+// their status, such as NOT_FOUND.
+const (
+	CodeUserNotFound             = "USER_NOT_FOUND"
+	CodeInvalidCredentials       = "INVALID_CREDENTIALS"
+	CodeAccountDeactivated       = "ACCOUNT_DEACTIVATED"
+	CodePasswordResetRequired    = "PASSWORD_RESET_REQUIRED"
+	CodePasswordIncorrect        = "PASSWORD_INCORRECT"
+	CodePasswordPolicy           = "PASSWORD_POLICY" // details lists the rules the password broke
+	CodeLoginCodeInvalid         = "LOGIN_CODE_INVALID"
+	CodeResetTokenInvalid        = "RESET_TOKEN_INVALID"
+	CodeConfirmationTokenInvalid = "CONFIRMATION_TOKEN_INVALID"
+	CodeEmailTaken               = "EMAIL_TAKEN"
+	CodeInvalidRole              = "INVALID_ROLE"
+	CodeAddressNotFound          = "ADDRESS_NOT_FOUND"
+	CodeSessionNotFound          = "SESSION_NOT_FOUND"
+	CodeServiceKeyNotFound       = "SERVICE_KEY_NOT_FOUND"
+)
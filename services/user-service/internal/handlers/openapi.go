@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"ecommerce/pkg/openapi"
+	"user-service/internal/models"
+)
+
+// OpenAPI describes the user service's routes for its OpenAPI document
+func OpenAPI() *openapi.Spec {
+	spec := openapi.New("User Service", "1.0.0", "Manages accounts, addresses, sessions, and the service keys other services call with.")
+	session := []string{openapi.Session}
+	service := []string{openapi.ServiceKey}
+
+	// Users
+	spec.Describe("POST", "/v1/users", openapi.Route{Summary: "Create a user", Body: models.CreateUserRequest{}, Response: models.User{}, Status: http.StatusCreated})
+	spec.Describe("GET", "/v1/users", openapi.Route{Summary: "List users", Response: []models.User{}})
+	spec.Describe("GET", "/v1/users/{id}", openapi.Route{Summary: "Get a user", Response: models.User{}})
+	spec.Describe("DELETE", "/v1/users/{id}", openapi.Route{Summary: "Deactivate an account, or with purge=true erase its personal data", Query: map[string]string{"purge": "true to erase the user's personal data and anonymize their orders"}, Auth: session})
+	spec.Describe("GET", "/v1/users/{id}/export", openapi.Route{Summary: "Download all data held about a user", Response: models.UserDataExport{}, Produces: "application/json", Auth: session})
+	spec.Describe("POST", "/v1/users/{id}/email", openapi.Route{Summary: "Send a confirmation token to a new email address", Body: models.ChangeEmailRequest{}, Auth: session})
+	spec.Describe("POST", "/v1/users/{id}/password", openapi.Route{Summary: "Change your own password, signing out other sessions", Body: models.ChangePasswordRequest{}, Auth: session})
+	spec.Describe("GET", "/v1/users/{id}/activity", openapi.Route{Summary: "List a user's recent activity, newest first", Response: []models.Activity{}, Query: map[string]string{"limit": "how many activities to return, 50 by default"}, Auth: session})
+	spec.Describe("GET", "/v1/users/{id}/sessions", openapi.Route{Summary: "List a user's sessions", Response: []models.Session{}, Auth: session})
+	spec.Describe("DELETE", "/v1/users/{id}/sessions/{session_id}", openapi.Route{Summary: "Revoke a session", Auth: session})
+
+	// Addresses
+	spec.Describe("POST", "/v1/users/{id}/addresses", openapi.Route{Summary: "Add an address; a user's first becomes their default", Body: models.CreateAddressRequest{}, Response: models.Address{}, Status: http.StatusCreated, Auth: session})
+	spec.Describe("GET", "/v1/users/{id}/addresses", openapi.Route{Summary: "List a user's addresses", Response: []models.Address{}, Auth: session})
+	spec.Describe("GET", "/v1/users/{id}/addresses/default", openapi.Route{Summary: "Get a user's default address", Response: models.Address{}, Query: map[string]string{"type": "shipping (default) or billing"}, Auth: session})
+	spec.Describe("GET", "/v1/users/{id}/addresses/{address_id}", openapi.Route{Summary: "Get an address", Response: models.Address{}, Auth: session})
+	spec.Describe("PUT", "/v1/users/{id}/addresses/{address_id}", openapi.Route{Summary: "Update an address or its default flags", Body: models.UpdateAddressRequest{}, Response: models.Address{}, Auth: session})
+	spec.Describe("DELETE", "/v1/users/{id}/addresses/{address_id}", openapi.Route{Summary: "Remove an address", Auth: session})
+
+	// Authentication
+	spec.Describe("POST", "/v1/auth/login", openapi.Route{Summary: "Log in with an email and password", Body: models.LoginRequest{}, Response: models.LoginResponse{}})
+	spec.Describe("POST", "/v1/auth/otp/request", openapi.Route{Summary: "Text a login code to a registered phone", Body: models.OTPRequest{}})
+	spec.Describe("POST", "/v1/auth/otp/verify", openapi.Route{Summary: "Log in with a phone and the code texted to it", Body: models.OTPVerifyRequest{}, Response: models.LoginResponse{}})
+	spec.Describe("POST", "/v1/auth/logout", openapi.Route{Summary: "Revoke the session the request is made with", Auth: session})
+	spec.Describe("POST", "/v1/auth/refresh", openapi.Route{Summary: "Exchange the session for a new one", Response: models.LoginResponse{}, Auth: session})
+	spec.Describe("POST", "/v1/auth/password-reset", openapi.Route{Summary: "Set a new password with a one-time reset token", Body: models.ResetPasswordRequest{}})
+	spec.Describe("POST", "/v1/auth/email/confirm", openapi.Route{Summary: "Confirm a pending email change", Body: models.ConfirmEmailRequest{}, Response: models.User{}})
+
+	// Administration
+	spec.Describe("GET", "/v1/admin/users", openapi.Route{Summary: "List users", Response: []models.User{}, Query: map[string]string{"role": "only users with this role"}, Auth: session})
+	spec.Describe("POST", "/v1/admin/users/{id}/disable", openapi.Route{Summary: "Disable an account and sign it out everywhere", Response: models.User{}, Auth: session})
+	spec.Describe("POST", "/v1/admin/users/{id}/reactivate", openapi.Route{Summary: "Restore a deactivated account", Response: models.User{}, Auth: session})
+	spec.Describe("POST", "/v1/admin/users/{id}/force-password-reset", openapi.Route{Summary: "Block login until the user resets their password", Response: models.PasswordResetResponse{}, Auth: session})
+	spec.Describe("PUT", "/v1/admin/users/{id}/role", openapi.Route{Summary: "Change a user's role", Body: models.ChangeRoleRequest{}, Response: models.User{}, Auth: session})
+	spec.Describe("POST", "/v1/admin/service-keys", openapi.Route{Summary: "Issue a key for a service; the key is only returned here", Body: models.CreateServiceKeyRequest{}, Response: models.ServiceKeyResponse{}, Status: http.StatusCreated, Auth: session})
+	spec.Describe("GET", "/v1/admin/service-keys", openapi.Route{Summary: "List issued service keys", Response: []models.ServiceKey{}, Auth: session})
+	spec.Describe("DELETE", "/v1/admin/service-keys/{id}", openapi.Route{Summary: "Revoke a service key", Auth: session})
+	spec.Describe("GET", "/v1/admin/audit", openapi.Route{Summary: "Query the authentication audit log", Response: []models.AuditEvent{}, Query: map[string]string{
+		"user_id": "only this user's events",
+		"type":    "only events of this type",
+		"since":   "only events at or after this RFC 3339 time",
+		"limit":   "how many events to return; 100 by default",
+	}, Auth: session})
+	spec.Describe("GET", "/v1/admin/config", openapi.Route{Summary: "Show the settings in effect", Auth: session})
+	spec.Describe("POST", "/v1/internal/service-keys/verify", openapi.Route{Summary: "Look up the service a key was issued to", Body: models.VerifyServiceKeyRequest{}, Response: map[string]string{}})
+	spec.Describe("GET", "/v1/internal/users/{id}/addresses/default", openapi.Route{Summary: "Look up a user's default address", Response: models.Address{}, Query: map[string]string{"type": "shipping (default) or billing"}, Auth: service})
+	spec.Describe("GET", "/v1/internal/users/{id}/addresses/{address_id}", openapi.Route{Summary: "Look up one of a user's addresses", Response: models.Address{}, Auth: service})
+
+	// Operations
+	spec.Describe("GET", "/openapi.json", openapi.Route{Summary: "This document"})
+	spec.Describe("GET", "/healthz", openapi.Route{Summary: "Report whether the service is running"})
+	spec.Describe("GET", "/readyz", openapi.Route{Summary: "Report whether the service can take traffic"})
+	spec.Describe("GET", "/metrics", openapi.Route{Summary: "Prometheus metrics", Produces: "text/plain"})
+	return spec
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+	"ecommerce/pkg/api"
+	"user-service/internal/auth"
+	"user-service/internal/client"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+)
+
+const (
+	// OTPTTL is how long an SMS login code stays valid
+	OTPTTL = 5 * time.Minute
+	// otpDigits is the length of SMS login codes
+	otpDigits = 6
+)
+
+// OTPHandler handles passwordless login with one-time codes sent by SMS
+type OTPHandler struct {
+	repo   repository.UserRepository
+	auth   *auth.Authenticator
+	tokens repository.VerificationTokenRepository
+	sms    client.SMSSender
+	audit  repository.AuditRepository
+}
+
+// NewOTPHandler creates a new OTP handler
+func NewOTPHandler(repo repository.UserRepository, authenticator *auth.Authenticator, tokens repository.VerificationTokenRepository, sms client.SMSSender, audit repository.AuditRepository) *OTPHandler {
+	return &OTPHandler{
+		repo:   repo,
+		auth:   authenticator,
+		tokens: tokens,
+		sms:    sms,
+		audit:  audit,
+	}
+}
+
+// RequestOTP handles POST /auth/otp/request - texts a login code to the phone on file.
+// The response is the same whether or not the phone is registered.
+func (h *OTPHandler) RequestOTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.OTPRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	phone, ok := models.NormalizePhone(req.Phone)
+	if !ok {
+		api.WriteError(w, http.StatusBadRequest, "Phone must be in international format, e.g. +254712345678")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "If the phone number is registered, a login code has been sent",
+	}
+
+	user, err := h.repo.GetByPhone(r.Context(), phone)
+	if err != nil || !user.Active {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	code, err := auth.GenerateCode(otpDigits)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating OTP", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to send login code")
+		return
+	}
+
+	// Only the latest code can be used
+	if err := h.tokens.InvalidateForUser(models.TokenPurposeOTPLogin, user.ID); err != nil {
+		slog.ErrorContext(r.Context(), "Error invalidating OTPs", "user_id", user.ID, "error", err)
+	}
+
+	token := models.NewVerificationToken(models.TokenPurposeOTPLogin, user.ID, otpHash(phone, code), "", OTPTTL)
+	if err := h.tokens.Create(token); err != nil {
+		slog.ErrorContext(r.Context(), "Error storing OTP", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to send login code")
+		return
+	}
+
+	if err := h.sms.Send(r.Context(), phone, "Your login code is "+code+". It expires in 5 minutes."); err != nil {
+		slog.ErrorContext(r.Context(), "Error sending OTP SMS", "error", err)
+		api.WriteError(w, http.StatusBadGateway, "Failed to send login code")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// VerifyOTP handles POST /auth/otp/verify - completes login with an SMS code
+func (h *OTPHandler) VerifyOTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.OTPVerifyRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	phone, ok := models.NormalizePhone(req.Phone)
+	if !ok || req.Code == "" {
+		api.WriteError(w, http.StatusBadRequest, "Phone and code are required")
+		return
+	}
+
+	token, err := h.tokens.Consume(models.TokenPurposeOTPLogin, otpHash(phone, req.Code))
+	if err != nil {
+		recordAudit(h.audit, r, models.AuditLoginFailure, "", "", "invalid otp for "+phone)
+		api.WriteErrorCode(w, http.StatusUnauthorized, CodeLoginCodeInvalid, "Invalid or expired code")
+		return
+	}
+
+	user, err := h.repo.GetByID(r.Context(), token.UserID)
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusUnauthorized, CodeLoginCodeInvalid, "Invalid or expired code")
+		return
+	}
+
+	if !user.Active {
+		recordAudit(h.audit, r, models.AuditLoginFailure, user.ID, user.Email, "account deactivated")
+		api.WriteErrorCode(w, http.StatusForbidden, CodeAccountDeactivated, "Account is deactivated")
+		return
+	}
+
+	if user.PasswordResetRequired {
+		recordAudit(h.audit, r, models.AuditLoginFailure, user.ID, user.Email, "password reset required")
+		api.WriteErrorCode(w, http.StatusForbidden, CodePasswordResetRequired, "Password reset required")
+		return
+	}
+
+	session, err := h.auth.StartSession(user, r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting session", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to start session")
+		return
+	}
+
+	recordAudit(h.audit, r, models.AuditLoginSuccess, user.ID, user.Email, "otp")
+
+	response := models.Response{
+		Success: true,
+		Message: "Login successful",
+		Data:    newLoginResponse(user, session),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// otpHash binds a code to the phone it was sent to, so equal codes for different users never collide
+func otpHash(phone, code string) string {
+	return auth.HashToken(phone + ":" + code)
+}
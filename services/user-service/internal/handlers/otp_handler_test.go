@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"user-service/internal/auth"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+)
+
+// captureSMS records the last text message sent
+type captureSMS struct {
+	to      string
+	message string
+}
+
+func (s *captureSMS) Send(ctx context.Context, to, message string) error {
+	s.to = to
+	s.message = message
+	return nil
+}
+
+func setupOTPHandler() (*OTPHandler, *captureSMS, *models.User) {
+	repo := repository.NewInMemoryUserRepository()
+	sms := &captureSMS{}
+	h := NewOTPHandler(repo, auth.NewAuthenticator(repo, repository.NewInMemorySessionStore(), 0), repository.NewInMemoryVerificationTokenRepository(), sms, repository.NewInMemoryAuditRepository())
+
+	user := models.NewUser("Test", "t@example.com", "secret")
+	user.Phone = "+254712345678"
+	_ = repo.Create(context.Background(), user)
+	return h, sms, user
+}
+
+func TestOTP_LoginWithCode(t *testing.T) {
+	h, sms, _ := setupOTPHandler()
+
+	rec := httptest.NewRecorder()
+	h.RequestOTP(rec, httptest.NewRequest(http.MethodPost, "/auth/otp/request", bytes.NewBufferString(`{"phone":"+254 712-345-678"}`)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 got %d", rec.Code)
+	}
+	if sms.to != "+254712345678" {
+		t.Fatalf("expected SMS to normalized phone, got %q", sms.to)
+	}
+	code := strings.Fields(strings.TrimPrefix(sms.message, "Your login code is "))[0]
+	code = strings.TrimSuffix(code, ".")
+
+	rec = httptest.NewRecorder()
+	h.VerifyOTP(rec, httptest.NewRequest(http.MethodPost, "/auth/otp/verify", bytes.NewBufferString(`{"phone":"+254712345678","code":"000000x"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a code that isn't 6 characters got %d", rec.Code)
+	}
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	rec = httptest.NewRecorder()
+	h.VerifyOTP(rec, httptest.NewRequest(http.MethodPost, "/auth/otp/verify", bytes.NewBufferString(`{"phone":"+254712345678","code":"`+wrong+`"}`)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for wrong code got %d", rec.Code)
+	}
+
+	body := `{"phone":"+254712345678","code":"` + code + `"}`
+	rec = httptest.NewRecorder()
+	h.VerifyOTP(rec, httptest.NewRequest(http.MethodPost, "/auth/otp/verify", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.VerifyOTP(rec, httptest.NewRequest(http.MethodPost, "/auth/otp/verify", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected code reuse to fail, got %d", rec.Code)
+	}
+}
+
+func TestOTP_UnknownPhoneDoesNotLeak(t *testing.T) {
+	h, sms, _ := setupOTPHandler()
+
+	rec := httptest.NewRecorder()
+	h.RequestOTP(rec, httptest.NewRequest(http.MethodPost, "/auth/otp/request", bytes.NewBufferString(`{"phone":"+15550001111"}`)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 got %d", rec.Code)
+	}
+	if sms.to != "" {
+		t.Error("expected no SMS for an unregistered phone")
+	}
+
+	rec = httptest.NewRecorder()
+	h.RequestOTP(rec, httptest.NewRequest(http.MethodPost, "/auth/otp/request", bytes.NewBufferString(`{"phone":"not-a-phone"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid phone got %d", rec.Code)
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+	"ecommerce/pkg/api"
+	"user-service/internal/auth"
+	"user-service/internal/client"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// PrivacyHandler handles GDPR data export and erasure requests
+type PrivacyHandler struct {
+	userRepo    repository.UserRepository
+	addressRepo repository.AddressRepository
+	activities  repository.ActivityRepository
+	orders      client.OrderClient
+}
+
+// NewPrivacyHandler creates a new privacy handler
+func NewPrivacyHandler(userRepo repository.UserRepository, addressRepo repository.AddressRepository, activities repository.ActivityRepository, orders client.OrderClient) *PrivacyHandler {
+	return &PrivacyHandler{
+		userRepo:    userRepo,
+		addressRepo: addressRepo,
+		activities:  activities,
+		orders:      orders,
+	}
+}
+
+// ExportUserData handles GET /users/{id}/export - returns all data held about a user
+// as a downloadable JSON archive. Only the user themselves or an admin may export.
+func (h *PrivacyHandler) ExportUserData(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := mux.Vars(r)["id"]
+	if !auth.CanAccessUser(r.Context(), userID) {
+		api.WriteError(w, http.StatusForbidden, "Not allowed to export this user's data")
+		return
+	}
+
+	user, err := h.userRepo.GetByID(r.Context(), userID)
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+
+	addresses, err := h.addressRepo.ListByUser(userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing addresses for export", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve addresses")
+		return
+	}
+
+	// An export must be complete, so a failing order service fails the whole request
+	orders, err := h.orders.GetUserOrders(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching orders for export", "error", err)
+		api.WriteError(w, http.StatusBadGateway, "Failed to retrieve orders from order service")
+		return
+	}
+
+	export := models.UserDataExport{
+		ExportedAt: time.Now().UTC(),
+		User:       *user,
+		Addresses:  addresses,
+		Orders:     orders,
+	}
+
+	filename := fmt.Sprintf("user-%s-export-%s.json", userID, export.ExportedAt.Format("20060102T150405Z"))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(export)
+}
+
+// PurgeUser handles DELETE /users/{id}?purge=true - erases a user's personal data.
+// The profile is anonymized locally, then order service is asked to anonymize the
+// user's historical orders. If that call fails, the local change is compensated by
+// restoring the previous record so the two services never disagree.
+func (h *PrivacyHandler) PurgeUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := mux.Vars(r)["id"]
+	if !auth.CanAccessUser(r.Context(), userID) {
+		api.WriteError(w, http.StatusForbidden, "Not allowed to purge this user")
+		return
+	}
+
+	// Step 1: anonymize the profile, keeping a snapshot for compensation
+	snapshot, err := h.userRepo.Anonymize(r.Context(), userID)
+	if err != nil {
+		api.WriteError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	// Step 2: anonymize historical orders downstream
+	if err := h.orders.AnonymizeUserOrders(r.Context(), userID); err != nil {
+		slog.ErrorContext(r.Context(), "Purge of user failed at order service, compensating", "user_id", userID, "error", err)
+		if restoreErr := h.userRepo.Restore(r.Context(), snapshot); restoreErr != nil {
+			slog.ErrorContext(r.Context(), "CRITICAL: failed to restore user after aborted purge", "user_id", userID, "error", restoreErr)
+		}
+		api.WriteError(w, http.StatusBadGateway, "Failed to anonymize orders; user data was not purged")
+		return
+	}
+
+	// Step 3: local-only cleanup that cannot fail downstream
+	addresses, _ := h.addressRepo.ListByUser(userID)
+	for _, address := range addresses {
+		if err := h.addressRepo.Delete(userID, address.ID); err != nil {
+			slog.ErrorContext(r.Context(), "Error deleting address during purge", "address_id", address.ID, "error", err)
+		}
+	}
+	if err := h.activities.DeleteByUser(userID); err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting activity during purge", "user_id", userID, "error", err)
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "User data purged successfully",
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"user-service/internal/auth"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+type mockOrderClient struct {
+	orders       json.RawMessage
+	err          error
+	anonymizeErr error
+	anonymized   []string
+}
+
+func (m *mockOrderClient) GetUserOrders(ctx context.Context, userID string) (json.RawMessage, error) {
+	return m.orders, m.err
+}
+
+func (m *mockOrderClient) AnonymizeUserOrders(ctx context.Context, userID string) error {
+	if m.anonymizeErr != nil {
+		return m.anonymizeErr
+	}
+	m.anonymized = append(m.anonymized, userID)
+	return nil
+}
+
+func newExportRequest(userID string, caller *models.User) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/users/"+userID+"/export", nil)
+	if caller != nil {
+		req = req.WithContext(auth.WithUser(req.Context(), caller))
+	}
+	return mux.SetURLVars(req, map[string]string{"id": userID})
+}
+
+func TestExportUserData_Success(t *testing.T) {
+	userRepo := repository.NewInMemoryUserRepository()
+	user := models.NewUser("Test", "t@example.com", "secret")
+	_ = userRepo.Create(context.Background(), user)
+	orders := &mockOrderClient{orders: json.RawMessage(`[{"id":"o1"}]`)}
+	h := NewPrivacyHandler(userRepo, repository.NewInMemoryAddressRepository(), repository.NewInMemoryActivityRepository(), orders)
+
+	rec := httptest.NewRecorder()
+	h.ExportUserData(rec, newExportRequest(user.ID, user))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Disposition"), "attachment;") {
+		t.Error("expected attachment content disposition")
+	}
+
+	var export models.UserDataExport
+	if err := json.Unmarshal(rec.Body.Bytes(), &export); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if export.User.ID != user.ID || export.User.Password != "" {
+		t.Error("expected exported profile without password")
+	}
+	var exported []map[string]string
+	if err := json.Unmarshal(export.Orders, &exported); err != nil || len(exported) != 1 || exported[0]["id"] != "o1" {
+		t.Errorf("unexpected orders payload: %s", export.Orders)
+	}
+}
+
+func TestExportUserData_Forbidden(t *testing.T) {
+	userRepo := repository.NewInMemoryUserRepository()
+	user := models.NewUser("Test", "t@example.com", "secret")
+	other := models.NewUser("Other", "o@example.com", "secret")
+	_ = userRepo.Create(context.Background(), user)
+	_ = userRepo.Create(context.Background(), other)
+	h := NewPrivacyHandler(userRepo, repository.NewInMemoryAddressRepository(), repository.NewInMemoryActivityRepository(), &mockOrderClient{})
+
+	rec := httptest.NewRecorder()
+	h.ExportUserData(rec, newExportRequest(user.ID, other))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 got %d", rec.Code)
+	}
+}
+
+func TestExportUserData_OrderServiceDown(t *testing.T) {
+	userRepo := repository.NewInMemoryUserRepository()
+	admin := models.NewUser("Admin", "a@example.com", "secret")
+	admin.Role = models.RoleAdmin
+	user := models.NewUser("Test", "t@example.com", "secret")
+	_ = userRepo.Create(context.Background(), admin)
+	_ = userRepo.Create(context.Background(), user)
+	h := NewPrivacyHandler(userRepo, repository.NewInMemoryAddressRepository(), repository.NewInMemoryActivityRepository(), &mockOrderClient{err: errors.New("down")})
+
+	rec := httptest.NewRecorder()
+	h.ExportUserData(rec, newExportRequest(user.ID, admin))
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502 got %d", rec.Code)
+	}
+}
+
+func newPurgeRequest(userID string, caller *models.User) *http.Request {
+	req := httptest.NewRequest(http.MethodDelete, "/users/"+userID+"?purge=true", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), caller))
+	return mux.SetURLVars(req, map[string]string{"id": userID})
+}
+
+func TestPurgeUser_AnonymizesUserAndOrders(t *testing.T) {
+	userRepo := repository.NewInMemoryUserRepository()
+	addressRepo := repository.NewInMemoryAddressRepository()
+	user := models.NewUser("Test", "t@example.com", "secret")
+	_ = userRepo.Create(context.Background(), user)
+	_ = addressRepo.Create(models.NewAddress(user.ID, models.CreateAddressRequest{RecipientName: "Test", Line1: "1 Main St"}))
+	activityRepo := repository.NewInMemoryActivityRepository()
+	_ = activityRepo.Add(&models.Activity{ID: "e1", UserID: user.ID, Type: models.ActivityOrderPlaced, OrderID: "o1"})
+	orders := &mockOrderClient{}
+	h := NewPrivacyHandler(userRepo, addressRepo, activityRepo, orders)
+
+	rec := httptest.NewRecorder()
+	h.PurgeUser(rec, newPurgeRequest(user.ID, user))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+	if len(orders.anonymized) != 1 || orders.anonymized[0] != user.ID {
+		t.Error("expected order service to be asked to anonymize orders")
+	}
+	purged, _ := userRepo.GetByID(context.Background(), user.ID)
+	if purged.Email == "t@example.com" || purged.Active || purged.AnonymizedAt == nil {
+		t.Errorf("expected anonymized inactive user, got %+v", purged)
+	}
+	if remaining, _ := addressRepo.ListByUser(user.ID); len(remaining) != 0 {
+		t.Errorf("expected addresses to be removed, got %d", len(remaining))
+	}
+	if remaining, _ := activityRepo.ListByUser(user.ID, 0); len(remaining) != 0 {
+		t.Errorf("expected activity to be removed, got %d", len(remaining))
+	}
+}
+
+func TestPurgeUser_CompensatesOnOrderServiceFailure(t *testing.T) {
+	userRepo := repository.NewInMemoryUserRepository()
+	user := models.NewUser("Test", "t@example.com", "secret")
+	_ = userRepo.Create(context.Background(), user)
+	h := NewPrivacyHandler(userRepo, repository.NewInMemoryAddressRepository(), repository.NewInMemoryActivityRepository(), &mockOrderClient{anonymizeErr: errors.New("down")})
+
+	rec := httptest.NewRecorder()
+	h.PurgeUser(rec, newPurgeRequest(user.ID, user))
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502 got %d", rec.Code)
+	}
+	restored, err := userRepo.GetByEmail(context.Background(), "t@example.com")
+	if err != nil {
+		t.Fatalf("expected original user to be restored: %v", err)
+	}
+	if !restored.Active || restored.Password != "secret" {
+		t.Error("expected restored user to be active with original credentials")
+	}
+}
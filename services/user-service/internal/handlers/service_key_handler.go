@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"ecommerce/pkg/api"
+	"user-service/internal/auth"
+	"user-service/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+// ServiceKeyHandler handles issuance and verification of service-to-service API keys
+type ServiceKeyHandler struct {
+	keys *auth.ServiceKeys
+}
+
+// NewServiceKeyHandler creates a new service key handler
+func NewServiceKeyHandler(keys *auth.ServiceKeys) *ServiceKeyHandler {
+	return &ServiceKeyHandler{
+		keys: keys,
+	}
+}
+
+// IssueServiceKey handles POST /admin/service-keys - issues a new key for a service (admin only)
+func (h *ServiceKeyHandler) IssueServiceKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.CreateServiceKeyRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	plaintext, key, err := h.keys.Issue(req.Service)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error issuing service key", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to issue service key")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Service key issued; store it now, it will not be shown again",
+		Data: models.ServiceKeyResponse{
+			ServiceKey: *key,
+			Key:        plaintext,
+		},
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListServiceKeys handles GET /admin/service-keys - lists issued keys without their secrets (admin only)
+func (h *ServiceKeyHandler) ListServiceKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	keys, err := h.keys.Repository().List()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing service keys", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve service keys")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    keys,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// RevokeServiceKey handles DELETE /admin/service-keys/{id} - revokes a key (admin only)
+func (h *ServiceKeyHandler) RevokeServiceKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := h.keys.Repository().Revoke(mux.Vars(r)["id"]); err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeServiceKeyNotFound, "Service key not found")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Service key revoked successfully",
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// VerifyServiceKey handles POST /internal/service-keys/verify - lets other services
+// validate a key they received and learn which service presented it
+func (h *ServiceKeyHandler) VerifyServiceKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.VerifyServiceKeyRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	key, err := h.keys.Verify(req.Key)
+	if err != nil {
+		api.WriteError(w, http.StatusUnauthorized, "Invalid service key")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data: map[string]string{
+			"service": key.Service,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
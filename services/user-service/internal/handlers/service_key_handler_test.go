@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"user-service/internal/auth"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServiceKeyLifecycle(t *testing.T) {
+	h := NewServiceKeyHandler(auth.NewServiceKeys(repository.NewInMemoryServiceKeyRepository()))
+
+	rec := httptest.NewRecorder()
+	h.IssueServiceKey(rec, httptest.NewRequest(http.MethodPost, "/admin/service-keys", bytes.NewBufferString(`{"service":"order-service"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", rec.Code)
+	}
+	var issued struct {
+		Data models.ServiceKeyResponse `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &issued)
+	if issued.Data.Key == "" || issued.Data.Service != "order-service" {
+		t.Fatalf("unexpected issuance payload: %+v", issued.Data)
+	}
+
+	verify := func() int {
+		body := bytes.NewBufferString(`{"key":"` + issued.Data.Key + `"}`)
+		rec := httptest.NewRecorder()
+		h.VerifyServiceKey(rec, httptest.NewRequest(http.MethodPost, "/internal/service-keys/verify", body))
+		return rec.Code
+	}
+	if code := verify(); code != http.StatusOK {
+		t.Fatalf("expected issued key to verify, got %d", code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ListServiceKeys(rec, httptest.NewRequest(http.MethodGet, "/admin/service-keys", nil))
+	if bytes.Contains(rec.Body.Bytes(), []byte(issued.Data.Key)) {
+		t.Error("listing must not expose key secrets")
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/admin/service-keys/"+issued.Data.ID, nil), map[string]string{"id": issued.Data.ID})
+	rec = httptest.NewRecorder()
+	h.RevokeServiceKey(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 on revoke got %d", rec.Code)
+	}
+	if code := verify(); code != http.StatusUnauthorized {
+		t.Fatalf("expected revoked key to be rejected, got %d", code)
+	}
+}
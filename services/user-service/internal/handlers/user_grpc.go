@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	userv1 "ecommerce/pkg/proto/user/v1"
+	"user-service/internal/auth"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// UserServer serves the UserService gRPC API other services look accounts up with
+type UserServer struct {
+	userv1.UnimplementedUserServiceServer
+	repo repository.UserRepository
+	auth *auth.Authenticator
+}
+
+// NewUserServer creates a new UserService server
+func NewUserServer(repo repository.UserRepository, authenticator *auth.Authenticator) *UserServer {
+	return &UserServer{repo: repo, auth: authenticator}
+}
+
+// GetUser returns a user, the gRPC counterpart of GET /users/{id}
+func (s *UserServer) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.User, error) {
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "User ID is required")
+	}
+
+	user, err := s.repo.GetByID(ctx, req.Id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting user", "error", err)
+		return nil, status.Error(codes.NotFound, "User not found")
+	}
+	return userMessage(user), nil
+}
+
+// BatchGetUsers returns the users with the given IDs, so callers resolving many users make one call
+func (s *UserServer) BatchGetUsers(ctx context.Context, req *userv1.BatchGetUsersRequest) (*userv1.BatchGetUsersResponse, error) {
+	resp := &userv1.BatchGetUsersResponse{}
+	for _, id := range req.Ids {
+		user, err := s.repo.GetByID(ctx, id)
+		if err != nil {
+			continue
+		}
+		resp.Users = append(resp.Users, userMessage(user))
+	}
+	return resp, nil
+}
+
+// VerifySession resolves a bearer token to its session and user, so the gateway can authenticate
+// clients once for every service
+func (s *UserServer) VerifySession(ctx context.Context, req *userv1.VerifySessionRequest) (*userv1.Session, error) {
+	user, session, err := s.auth.Verify(ctx, req.Token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return &userv1.Session{
+		Id:        session.ID,
+		User:      userMessage(user),
+		ExpiresAt: timestamppb.New(session.ExpiresAt),
+	}, nil
+}
+
+// userMessage converts a user to its protobuf message, leaving the password out
+func userMessage(user *models.User) *userv1.User {
+	return &userv1.User{
+		Id:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Phone:     user.Phone,
+		Role:      string(user.Role),
+		Active:    user.Active,
+		CreatedAt: timestamppb.New(user.CreatedAt),
+		UpdatedAt: timestamppb.New(user.UpdatedAt),
+	}
+}
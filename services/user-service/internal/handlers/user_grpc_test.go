@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	userv1 "ecommerce/pkg/proto/user/v1"
+	"user-service/internal/auth"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUserServer_GetUser(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository()
+	user := models.NewUser("Test", "t@example.com", "secret")
+	if err := repo.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	server := NewUserServer(repo, auth.NewAuthenticator(repo, repository.NewInMemorySessionStore(), 0))
+
+	got, err := server.GetUser(context.Background(), &userv1.GetUserRequest{Id: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != user.ID || got.Email != "t@example.com" || !got.Active || got.CreatedAt.AsTime().IsZero() {
+		t.Fatalf("expected the stored user, got %+v", got)
+	}
+
+	if _, err := server.GetUser(context.Background(), &userv1.GetUserRequest{Id: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for an unknown user, got %v", err)
+	}
+	if _, err := server.GetUser(context.Background(), &userv1.GetUserRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument without an ID, got %v", err)
+	}
+}
+
+func TestUserServer_BatchGetUsers(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository()
+	ada := models.NewUser("Ada", "ada@example.com", "secret")
+	bob := models.NewUser("Bob", "bob@example.com", "secret")
+	for _, u := range []*models.User{ada, bob} {
+		if err := repo.Create(context.Background(), u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := NewUserServer(repo, auth.NewAuthenticator(repo, repository.NewInMemorySessionStore(), 0))
+
+	resp, err := server.BatchGetUsers(context.Background(), &userv1.BatchGetUsersRequest{Ids: []string{bob.ID, "missing", ada.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Users) != 2 || resp.Users[0].Name != "Bob" || resp.Users[1].Name != "Ada" {
+		t.Fatalf("expected both users without the missing one, got %+v", resp.Users)
+	}
+}
+
+func TestUserServer_VerifySession(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository()
+	user := models.NewUser("Test", "t@example.com", "secret")
+	if err := repo.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	authenticator := auth.NewAuthenticator(repo, repository.NewInMemorySessionStore(), 0)
+	session, err := authenticator.StartSession(user, httptest.NewRequest("POST", "/v1/auth/login", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewUserServer(repo, authenticator)
+
+	got, err := server.VerifySession(context.Background(), &userv1.VerifySessionRequest{Token: session.Token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != session.ID || got.User.GetId() != user.ID || got.ExpiresAt.AsTime().IsZero() {
+		t.Fatalf("expected the session and its user, got %+v", got)
+	}
+
+	if _, err := server.VerifySession(context.Background(), &userv1.VerifySessionRequest{Token: "bogus"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for an unknown token, got %v", err)
+	}
+
+	user.Active = false
+	if err := repo.Update(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.VerifySession(context.Background(), &userv1.VerifySessionRequest{Token: session.Token}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for a deactivated account, got %v", err)
+	}
+}
@@ -2,23 +2,33 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	"ecommerce/pkg/api"
+	"user-service/internal/auth"
 	"user-service/internal/models"
 	"user-service/internal/repository"
 
 	"github.com/gorilla/mux"
 )
 
-// UserHandler handles HTTP requests related to users
+// UserHandler handles HTTP requests related to users and their sessions
 type UserHandler struct {
-	repo repository.UserRepository
+	repo   repository.UserRepository
+	auth   *auth.Authenticator
+	audit  repository.AuditRepository
+	tokens repository.VerificationTokenRepository
+	policy *auth.PasswordPolicy
 }
 
 // NewUserHandler creates a new user handler
-func NewUserHandler(repo repository.UserRepository) *UserHandler {
+func NewUserHandler(repo repository.UserRepository, authenticator *auth.Authenticator, audit repository.AuditRepository, tokens repository.VerificationTokenRepository, policy *auth.PasswordPolicy) *UserHandler {
 	return &UserHandler{
-		repo: repo,
+		repo:   repo,
+		auth:   authenticator,
+		audit:  audit,
+		tokens: tokens,
+		policy: policy,
 	}
 }
 
@@ -27,22 +37,28 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var req models.CreateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid JSON payload")
+	if !api.DecodeJSON(w, r, &req) {
 		return
 	}
 
-	// Basic validation
-	if req.Name == "" || req.Email == "" || req.Password == "" {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Name, email, and password are required")
+	if violations := h.policy.Validate(req.Password); len(violations) > 0 {
+		h.sendPolicyViolations(w, violations)
 		return
 	}
 
 	// Create user
 	user := models.NewUser(req.Name, req.Email, req.Password)
-	if err := h.repo.Create(user); err != nil {
-		log.Printf("Error creating user: %v", err)
-		h.sendErrorResponse(w, http.StatusConflict, err.Error())
+	if req.Phone != "" {
+		phone, ok := models.NormalizePhone(req.Phone)
+		if !ok {
+			api.WriteError(w, http.StatusBadRequest, "Phone must be in international format, e.g. +254712345678")
+			return
+		}
+		user.Phone = phone
+	}
+	if err := h.repo.Create(r.Context(), user); err != nil {
+		slog.ErrorContext(r.Context(), "Error creating user", "error", err)
+		api.WriteError(w, http.StatusConflict, err.Error())
 		return
 	}
 
@@ -67,14 +83,17 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	userID := vars["id"]
 
 	if userID == "" {
-		h.sendErrorResponse(w, http.StatusBadRequest, "User ID is required")
+		api.WriteError(w, http.StatusBadRequest, "User ID is required")
 		return
 	}
 
-	user, err := h.repo.GetByID(userID)
+	user, err := h.repo.GetByID(r.Context(), userID)
 	if err != nil {
-		log.Printf("Error getting user: %v", err)
-		h.sendErrorResponse(w, http.StatusNotFound, "User not found")
+		slog.ErrorContext(r.Context(), "Error getting user", "error", err)
+		api.WriteErrorCode(w, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+	if api.NotModified(w, r, user.UpdatedAt) {
 		return
 	}
 
@@ -91,43 +110,269 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var req models.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid JSON payload")
-		return
-	}
-
-	// Basic validation
-	if req.Email == "" || req.Password == "" {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Email and password are required")
+	if !api.DecodeJSON(w, r, &req) {
 		return
 	}
 
 	// Get user by email
-	user, err := h.repo.GetByEmail(req.Email)
+	user, err := h.repo.GetByEmail(r.Context(), req.Email)
 	if err != nil {
-		log.Printf("Login attempt for non-existent user: %s", req.Email)
-		h.sendErrorResponse(w, http.StatusUnauthorized, "Invalid credentials")
+		slog.InfoContext(r.Context(), "Login attempt for non-existent user", "email", req.Email)
+		h.recordAudit(r, models.AuditLoginFailure, "", req.Email, "unknown email")
+		api.WriteErrorCode(w, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid credentials")
 		return
 	}
 
 	// Simple password check (in production, use proper password hashing)
 	if user.Password != req.Password {
-		log.Printf("Invalid password for user: %s", req.Email)
-		h.sendErrorResponse(w, http.StatusUnauthorized, "Invalid credentials")
+		slog.WarnContext(r.Context(), "Invalid password for user", "email", req.Email)
+		h.recordAudit(r, models.AuditLoginFailure, user.ID, user.Email, "invalid password")
+		api.WriteErrorCode(w, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid credentials")
 		return
 	}
 
-	// Create login response (in production, generate JWT token)
-	loginResp := models.LoginResponse{
-		User:  *user,
-		Token: "mock-jwt-token-" + user.ID, // Mock token for demonstration
+	// Deactivated accounts cannot log in
+	if !user.Active {
+		slog.InfoContext(r.Context(), "Login attempt for deactivated user", "email", req.Email)
+		h.recordAudit(r, models.AuditLoginFailure, user.ID, user.Email, "account deactivated")
+		api.WriteErrorCode(w, http.StatusForbidden, CodeAccountDeactivated, "Account is deactivated")
+		return
+	}
+
+	// An admin-forced reset must be completed before logging in again
+	if user.PasswordResetRequired {
+		h.recordAudit(r, models.AuditLoginFailure, user.ID, user.Email, "password reset required")
+		api.WriteErrorCode(w, http.StatusForbidden, CodePasswordResetRequired, "Password reset required")
+		return
 	}
-	loginResp.User.Password = "" // Don't return password
+
+	// Start a new session; its opaque token is the bearer credential
+	session, err := h.auth.StartSession(user, r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting session", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to start session")
+		return
+	}
+
+	h.recordAudit(r, models.AuditLoginSuccess, user.ID, user.Email, "")
 
 	response := models.Response{
 		Success: true,
 		Message: "Login successful",
-		Data:    loginResp,
+		Data:    newLoginResponse(user, session),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// RefreshToken handles POST /auth/refresh - exchanges the current session for a new one.
+// The old token is revoked so a leaked token cannot outlive the refresh.
+func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := auth.UserFromContext(r.Context())
+	current := auth.SessionFromContext(r.Context())
+	if user == nil || current == nil {
+		api.WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	session, err := h.auth.StartSession(user, r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting session", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to refresh token")
+		return
+	}
+	if err := h.auth.Sessions().Revoke(current.ID); err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking refreshed session", "session_id", current.ID, "error", err)
+	}
+
+	h.recordAudit(r, models.AuditTokenRefreshed, user.ID, user.Email, "")
+
+	response := models.Response{
+		Success: true,
+		Message: "Token refreshed successfully",
+		Data:    newLoginResponse(user, session),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// ChangePassword handles POST /users/{id}/password - changes the caller's own password.
+// All other sessions of the user are revoked.
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := mux.Vars(r)["id"]
+	caller := auth.UserFromContext(r.Context())
+	if caller == nil || caller.ID != userID {
+		api.WriteError(w, http.StatusForbidden, "Users can only change their own password")
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	if violations := h.policy.Validate(req.NewPassword); len(violations) > 0 {
+		h.sendPolicyViolations(w, violations)
+		return
+	}
+
+	// Re-read the user with credentials to check the current password
+	user, err := h.repo.GetByEmail(r.Context(), caller.Email)
+	if err != nil || user.Password != req.CurrentPassword {
+		api.WriteErrorCode(w, http.StatusUnauthorized, CodePasswordIncorrect, "Current password is incorrect")
+		return
+	}
+
+	if err := h.repo.UpdatePassword(r.Context(), userID, req.NewPassword); err != nil {
+		slog.ErrorContext(r.Context(), "Error changing password", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	h.revokeOtherSessions(userID, auth.SessionFromContext(r.Context()))
+	h.recordAudit(r, models.AuditPasswordChanged, user.ID, user.Email, "")
+
+	response := models.Response{
+		Success: true,
+		Message: "Password changed successfully",
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// Logout handles POST /auth/logout - revokes the session used to make the request
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	session := auth.SessionFromContext(r.Context())
+	if session == nil {
+		api.WriteError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	if err := h.auth.Sessions().Revoke(session.ID); err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking session", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		h.recordAudit(r, models.AuditLogout, user.ID, user.Email, "")
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Logged out successfully",
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListSessions handles GET /users/{id}/sessions - lists a user's unexpired sessions (self or admin)
+func (h *UserHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := mux.Vars(r)["id"]
+	if !auth.CanAccessUser(r.Context(), userID) {
+		api.WriteError(w, http.StatusForbidden, "Not allowed to view these sessions")
+		return
+	}
+
+	sessions, err := h.auth.Sessions().ListByUser(userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing sessions", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve sessions")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Data:    sessions,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// RevokeSession handles DELETE /users/{id}/sessions/{session_id} - revokes one session (self or admin)
+func (h *UserHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	userID := vars["id"]
+	if !auth.CanAccessUser(r.Context(), userID) {
+		api.WriteError(w, http.StatusForbidden, "Not allowed to revoke this session")
+		return
+	}
+
+	// Make sure the session belongs to the user in the path
+	sessions, err := h.auth.Sessions().ListByUser(userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing sessions", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+	owned := false
+	for _, session := range sessions {
+		if session.ID == vars["session_id"] {
+			owned = true
+			break
+		}
+	}
+	if !owned {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeSessionNotFound, "Session not found")
+		return
+	}
+
+	if err := h.auth.Sessions().Revoke(vars["session_id"]); err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeSessionNotFound, "Session not found")
+		return
+	}
+
+	response := models.Response{
+		Success: true,
+		Message: "Session revoked successfully",
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// ResetPassword handles POST /auth/password-reset - completes a password reset with a one-time token
+func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.ResetPasswordRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	// Check the policy before consuming the token so the user can retry
+	if violations := h.policy.Validate(req.NewPassword); len(violations) > 0 {
+		h.sendPolicyViolations(w, violations)
+		return
+	}
+
+	token, err := h.tokens.Consume(models.TokenPurposePasswordReset, auth.HashToken(req.Token))
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusBadRequest, CodeResetTokenInvalid, "Invalid or expired reset token")
+		return
+	}
+
+	if err := h.repo.UpdatePassword(r.Context(), token.UserID, req.NewPassword); err != nil {
+		slog.ErrorContext(r.Context(), "Error resetting password", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	h.revokeOtherSessions(token.UserID, nil)
+	h.recordAudit(r, models.AuditPasswordReset, token.UserID, "", "")
+
+	response := models.Response{
+		Success: true,
+		Message: "Password reset successfully",
 	}
 
 	json.NewEncoder(w).Encode(response)
@@ -137,10 +382,10 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	users, err := h.repo.List()
+	users, err := h.repo.List(r.Context())
 	if err != nil {
-		log.Printf("Error listing users: %v", err)
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve users")
+		slog.ErrorContext(r.Context(), "Error listing users", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve users")
 		return
 	}
 
@@ -152,30 +397,114 @@ func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// HealthCheck handles GET /health - returns service health status
-func (h *UserHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
+// DeactivateUser handles DELETE /users/{id} - deactivates a user account instead of removing it.
+// Only the user themselves or an admin may deactivate an account.
+func (h *UserHandler) DeactivateUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	userID := mux.Vars(r)["id"]
+	if !auth.CanAccessUser(r.Context(), userID) {
+		api.WriteError(w, http.StatusForbidden, "Not allowed to deactivate this user")
+		return
+	}
+
+	if err := h.repo.SetActive(r.Context(), userID, false); err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+
+	// Sign the user out everywhere
+	if err := h.auth.Sessions().RevokeAllForUser(userID); err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking sessions for deactivated user", "user_id", userID, "error", err)
+	}
+
 	response := models.Response{
 		Success: true,
-		Message: "User service is healthy",
-		Data: map[string]string{
-			"service": "user-service",
-			"status":  "UP",
-		},
+		Message: "User deactivated successfully",
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
-// sendErrorResponse sends a standardized error response
-func (h *UserHandler) sendErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-	w.WriteHeader(statusCode)
+// ReactivateUser handles POST /admin/users/{id}/reactivate - restores a deactivated account (admin only)
+func (h *UserHandler) ReactivateUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := mux.Vars(r)["id"]
+	if err := h.repo.SetActive(r.Context(), userID, true); err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+
+	user, err := h.repo.GetByID(r.Context(), userID)
+	if err != nil {
+		api.WriteErrorCode(w, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
 
 	response := models.Response{
-		Success: false,
-		Error:   message,
+		Success: true,
+		Message: "User reactivated successfully",
+		Data:    user,
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
+
+// newLoginResponse builds the token response for a freshly started session
+func newLoginResponse(user *models.User, session *models.Session) models.LoginResponse {
+	loginResp := models.LoginResponse{
+		User:      *user,
+		Token:     session.Token,
+		SessionID: session.ID,
+		ExpiresAt: session.ExpiresAt,
+	}
+	loginResp.User.Password = "" // Don't return password
+	return loginResp
+}
+
+// revokeOtherSessions signs a user out everywhere except the given session
+func (h *UserHandler) revokeOtherSessions(userID string, keep *models.Session) {
+	sessions, err := h.auth.Sessions().ListByUser(userID)
+	if err != nil {
+		slog.Error("Error listing sessions", "user_id", userID, "error", err)
+		return
+	}
+	for _, session := range sessions {
+		if keep != nil && session.ID == keep.ID {
+			continue
+		}
+		if err := h.auth.Sessions().Revoke(session.ID); err != nil {
+			slog.Error("Error revoking session", "session_id", session.ID, "error", err)
+		}
+	}
+}
+
+// recordAudit appends an event to the audit log
+func (h *UserHandler) recordAudit(r *http.Request, eventType models.AuditEventType, userID, email, reason string) {
+	recordAudit(h.audit, r, eventType, userID, email, reason)
+}
+
+// recordAudit appends an event to the audit log; failures are logged but never block the request
+func recordAudit(audit repository.AuditRepository, r *http.Request, eventType models.AuditEventType, userID, email, reason string) {
+	event := models.NewAuditEvent(eventType, userID, email)
+	event.IPAddress = r.RemoteAddr
+	event.UserAgent = r.UserAgent()
+	event.Reason = reason
+	if actor := auth.UserFromContext(r.Context()); actor != nil {
+		event.ActorID = actor.ID
+	}
+
+	if err := audit.Append(event); err != nil {
+		slog.ErrorContext(r.Context(), "Error recording audit event", "event_type", eventType, "error", err)
+	}
+}
+
+// sendPolicyViolations sends a 400 listing every password policy rule that failed
+func (h *UserHandler) sendPolicyViolations(w http.ResponseWriter, violations []models.PolicyViolation) {
+	api.WriteErrorResponse(w, http.StatusBadRequest, api.Response[api.Unpaged]{
+		Code:    CodePasswordPolicy,
+		Error:   "Password does not meet policy requirements",
+		Details: violations,
+	})
+}
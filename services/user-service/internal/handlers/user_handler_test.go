@@ -1,17 +1,25 @@
 package handlers
 
 import (
+	"context"
 	"bytes"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"user-service/internal/auth"
 	"user-service/internal/models"
 	"user-service/internal/repository"
+
+	"github.com/gorilla/mux"
 )
 
 func setupUserHandler() *UserHandler {
-	return NewUserHandler(repository.NewInMemoryUserRepository())
+	return newUserHandler(repository.NewInMemoryUserRepository())
+}
+
+func newUserHandler(repo repository.UserRepository) *UserHandler {
+	return NewUserHandler(repo, auth.NewAuthenticator(repo, repository.NewInMemorySessionStore(), 0), repository.NewInMemoryAuditRepository(), repository.NewInMemoryVerificationTokenRepository(), &auth.PasswordPolicy{})
 }
 
 func TestCreateUser_Success(t *testing.T) {
@@ -44,6 +52,31 @@ func TestCreateUser_ValidationError(t *testing.T) {
 	}
 }
 
+func TestGetUser_ConditionalGet(t *testing.T) {
+	h := setupUserHandler()
+	user := models.NewUser("Test", "t@example.com", "p")
+	_ = h.repo.Create(context.Background(), user)
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/users/"+user.ID, nil), map[string]string{"id": user.ID})
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		h.GetUser(rec, req)
+		return rec
+	}
+
+	etag := get("").Header().Get("ETag")
+	if rec := get(etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("expected a bodyless 304 for an unchanged user, got %d", rec.Code)
+	}
+	_ = h.repo.SetActive(context.Background(), user.ID, false)
+	if rec := get(etag); rec.Code != http.StatusOK {
+		t.Errorf("expected the deactivated user sent, got %d", rec.Code)
+	}
+}
+
 func TestLogin_InvalidCredentials(t *testing.T) {
 	h := setupUserHandler()
 	// create a user
@@ -61,3 +94,210 @@ func TestLogin_InvalidCredentials(t *testing.T) {
 		t.Fatalf("expected 401 got %d", lres.Code)
 	}
 }
+
+func TestDeactivateUser_BlocksLogin(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository()
+	h := newUserHandler(repo)
+	user := models.NewUser("Test", "t@example.com", "secret")
+	_ = repo.Create(context.Background(), user)
+
+	req := httptest.NewRequest(http.MethodDelete, "/users/"+user.ID, nil)
+	req = mux.SetURLVars(req.WithContext(auth.WithUser(req.Context(), user)), map[string]string{"id": user.ID})
+	rec := httptest.NewRecorder()
+	h.DeactivateUser(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+
+	loginBody := bytes.NewBufferString(`{"email":"t@example.com","password":"secret"}`)
+	lres := httptest.NewRecorder()
+	h.Login(lres, httptest.NewRequest(http.MethodPost, "/auth/login", loginBody))
+	if lres.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 got %d", lres.Code)
+	}
+
+	rreq := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/admin/users/"+user.ID+"/reactivate", nil), map[string]string{"id": user.ID})
+	rrec := httptest.NewRecorder()
+	h.ReactivateUser(rrec, rreq)
+	if rrec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rrec.Code)
+	}
+	fetched, _ := repo.GetByID(context.Background(), user.ID)
+	if !fetched.Active {
+		t.Error("expected user to be active after reactivation")
+	}
+}
+
+func TestDeactivateUser_OtherUserForbidden(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository()
+	h := newUserHandler(repo)
+	target := models.NewUser("Target", "target@example.com", "secret")
+	caller := models.NewUser("Caller", "caller@example.com", "secret")
+	_ = repo.Create(context.Background(), target)
+	_ = repo.Create(context.Background(), caller)
+
+	req := httptest.NewRequest(http.MethodDelete, "/users/"+target.ID, nil)
+	req = mux.SetURLVars(req.WithContext(auth.WithUser(req.Context(), caller)), map[string]string{"id": target.ID})
+	rec := httptest.NewRecorder()
+	h.DeactivateUser(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 got %d", rec.Code)
+	}
+}
+
+func loginAs(t *testing.T, h *UserHandler, email, password string) models.LoginResponse {
+	t.Helper()
+	body := bytes.NewBufferString(`{"email":"` + email + `","password":"` + password + `"}`)
+	rec := httptest.NewRecorder()
+	h.Login(rec, httptest.NewRequest(http.MethodPost, "/auth/login", body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login failed with %d", rec.Code)
+	}
+	var resp struct {
+		Data models.LoginResponse `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	return resp.Data
+}
+
+func TestLogout_RevokesSessionToken(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository()
+	h := newUserHandler(repo)
+	_ = repo.Create(context.Background(), models.NewUser("Test", "t@example.com", "secret"))
+	login := loginAs(t, h, "t@example.com", "secret")
+
+	router := mux.NewRouter()
+	router.Use(h.auth.Authenticate)
+	router.Handle("/auth/logout", h.auth.RequireAuth(http.HandlerFunc(h.Logout))).Methods("POST")
+	router.Handle("/users/{id}/sessions", h.auth.RequireAuth(http.HandlerFunc(h.ListSessions))).Methods("GET")
+
+	req := httptest.NewRequest(http.MethodGet, "/users/"+login.User.ID+"/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+login.Token)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 listing sessions got %d", rec.Code)
+	}
+	if bytes.Contains(rec.Body.Bytes(), []byte(login.Token)) {
+		t.Error("session listing must not expose tokens")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+login.Token)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 on logout got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users/"+login.User.ID+"/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+login.Token)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected revoked token to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestAudit_RecordsLoginsAndPasswordChange(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository()
+	h := newUserHandler(repo)
+	_ = repo.Create(context.Background(), models.NewUser("Test", "t@example.com", "secret"))
+
+	// failed then successful login
+	bad := httptest.NewRecorder()
+	h.Login(bad, httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewBufferString(`{"email":"t@example.com","password":"nope"}`)))
+	login := loginAs(t, h, "t@example.com", "secret")
+
+	router := mux.NewRouter()
+	router.Use(h.auth.Authenticate)
+	router.Handle("/users/{id}/password", h.auth.RequireAuth(http.HandlerFunc(h.ChangePassword))).Methods("POST")
+	req := httptest.NewRequest(http.MethodPost, "/users/"+login.User.ID+"/password", bytes.NewBufferString(`{"current_password":"secret","new_password":"newsecret"}`))
+	req.Header.Set("Authorization", "Bearer "+login.Token)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 changing password got %d", rec.Code)
+	}
+	loginAs(t, h, "t@example.com", "newsecret")
+
+	events, _ := h.audit.Query(&models.AuditFilter{UserID: login.User.ID})
+	var types []models.AuditEventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	expected := []models.AuditEventType{models.AuditLoginSuccess, models.AuditPasswordChanged, models.AuditLoginSuccess, models.AuditLoginFailure}
+	if len(types) != len(expected) {
+		t.Fatalf("expected events %v got %v", expected, types)
+	}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Fatalf("expected events %v got %v", expected, types)
+		}
+	}
+}
+
+func TestRefreshToken_RotatesSession(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository()
+	h := newUserHandler(repo)
+	_ = repo.Create(context.Background(), models.NewUser("Test", "t@example.com", "secret"))
+	login := loginAs(t, h, "t@example.com", "secret")
+
+	router := mux.NewRouter()
+	router.Use(h.auth.Authenticate)
+	router.Handle("/auth/refresh", h.auth.RequireAuth(http.HandlerFunc(h.RefreshToken))).Methods("POST")
+
+	refresh := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/auth/refresh", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := refresh(login.Token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rec.Code)
+	}
+	if rec := refresh(login.Token); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected old token to be revoked, got %d", rec.Code)
+	}
+	events, _ := h.audit.Query(&models.AuditFilter{Type: models.AuditTokenRefreshed})
+	if len(events) != 1 {
+		t.Errorf("expected 1 token.refreshed event got %d", len(events))
+	}
+}
+
+func TestPasswordPolicy_AppliedOnSignupAndChange(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository()
+	h := newUserHandler(repo)
+	h.policy = auth.DefaultPasswordPolicy()
+
+	rec := httptest.NewRecorder()
+	h.CreateUser(rec, httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(`{"name":"Test","email":"t@example.com","password":"weak"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for weak password got %d", rec.Code)
+	}
+	var resp struct {
+		Details []models.PolicyViolation `json:"details"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	if len(resp.Details) == 0 {
+		t.Fatal("expected structured policy violations")
+	}
+
+	rec = httptest.NewRecorder()
+	h.CreateUser(rec, httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(`{"name":"Test","email":"t@example.com","password":"Str0ngPass"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", rec.Code)
+	}
+	login := loginAs(t, h, "t@example.com", "Str0ngPass")
+
+	req := httptest.NewRequest(http.MethodPost, "/users/"+login.User.ID+"/password", bytes.NewBufferString(`{"current_password":"Str0ngPass","new_password":"short"}`))
+	req = mux.SetURLVars(req.WithContext(auth.WithUser(req.Context(), &login.User)), map[string]string{"id": login.User.ID})
+	rec = httptest.NewRecorder()
+	h.ChangePassword(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for weak new password got %d", rec.Code)
+	}
+}
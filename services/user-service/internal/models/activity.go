@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// ActivityType identifies what a user did
+type ActivityType string
+
+const (
+	ActivityOrderPlaced    ActivityType = "order.placed"
+	ActivityOrderCancelled ActivityType = "order.cancelled"
+)
+
+// Activity is an entry in a user's activity feed, built from the events other services publish
+type Activity struct {
+	ID         string       `json:"id"` // the ID of the event it was built from
+	UserID     string       `json:"user_id"`
+	Type       ActivityType `json:"type"`
+	OrderID    string       `json:"order_id,omitempty"`
+	Total      float64      `json:"total,omitempty"`
+	OccurredAt time.Time    `json:"occurred_at"`
+}
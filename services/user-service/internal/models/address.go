@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AddressType identifies which default slot an address is used for
+type AddressType string
+
+const (
+	AddressTypeShipping AddressType = "shipping"
+	AddressTypeBilling  AddressType = "billing"
+)
+
+// Address represents a postal address belonging to a user
+type Address struct {
+	ID                string    `json:"id"`
+	UserID            string    `json:"user_id"`
+	Label             string    `json:"label,omitempty"`
+	RecipientName     string    `json:"recipient_name"`
+	Line1             string    `json:"line1"`
+	Line2             string    `json:"line2,omitempty"`
+	City              string    `json:"city"`
+	State             string    `json:"state,omitempty"`
+	PostalCode        string    `json:"postal_code"`
+	Country           string    `json:"country"`
+	Phone             string    `json:"phone,omitempty"`
+	IsDefaultShipping bool      `json:"is_default_shipping"`
+	IsDefaultBilling  bool      `json:"is_default_billing"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// CreateAddressRequest represents the request payload for adding an address
+type CreateAddressRequest struct {
+	Label             string `json:"label,omitempty"`
+	RecipientName     string `json:"recipient_name" validate:"required"`
+	Line1             string `json:"line1" validate:"required"`
+	Line2             string `json:"line2,omitempty"`
+	City              string `json:"city" validate:"required"`
+	State             string `json:"state,omitempty"`
+	PostalCode        string `json:"postal_code" validate:"required"`
+	Country           string `json:"country" validate:"required,len=2"`
+	Phone             string `json:"phone,omitempty"`
+	IsDefaultShipping bool   `json:"is_default_shipping"`
+	IsDefaultBilling  bool   `json:"is_default_billing"`
+}
+
+// UpdateAddressRequest represents the request payload for updating an address
+type UpdateAddressRequest struct {
+	Label             *string `json:"label,omitempty"`
+	RecipientName     *string `json:"recipient_name,omitempty"`
+	Line1             *string `json:"line1,omitempty"`
+	Line2             *string `json:"line2,omitempty"`
+	City              *string `json:"city,omitempty"`
+	State             *string `json:"state,omitempty"`
+	PostalCode        *string `json:"postal_code,omitempty"`
+	Country           *string `json:"country,omitempty"`
+	Phone             *string `json:"phone,omitempty"`
+	IsDefaultShipping *bool   `json:"is_default_shipping,omitempty"`
+	IsDefaultBilling  *bool   `json:"is_default_billing,omitempty"`
+}
+
+// NewAddress creates a new address for a user from a create request
+func NewAddress(userID string, req CreateAddressRequest) *Address {
+	now := time.Now()
+	return &Address{
+		ID:                uuid.New().String(),
+		UserID:            userID,
+		Label:             req.Label,
+		RecipientName:     req.RecipientName,
+		Line1:             req.Line1,
+		Line2:             req.Line2,
+		City:              req.City,
+		State:             req.State,
+		PostalCode:        req.PostalCode,
+		Country:           req.Country,
+		Phone:             req.Phone,
+		IsDefaultShipping: req.IsDefaultShipping,
+		IsDefaultBilling:  req.IsDefaultBilling,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+}
+
+// IsValidAddressType checks whether the given type is a known default slot
+func IsValidAddressType(t AddressType) bool {
+	return t == AddressTypeShipping || t == AddressTypeBilling
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEventType identifies the kind of security-relevant action that was recorded
+type AuditEventType string
+
+const (
+	AuditLoginSuccess         AuditEventType = "login.success"
+	AuditLoginFailure         AuditEventType = "login.failure"
+	AuditLogout               AuditEventType = "logout"
+	AuditPasswordChanged      AuditEventType = "password.changed"
+	AuditTokenRefreshed       AuditEventType = "token.refreshed"
+	AuditPasswordReset        AuditEventType = "password.reset"
+	AuditEmailChangeRequested AuditEventType = "email.change_requested"
+	AuditEmailChanged         AuditEventType = "email.changed"
+	AuditResetForced          AuditEventType = "password.reset_forced"
+	AuditRoleChanged          AuditEventType = "role.changed"
+	AuditUserDisabled         AuditEventType = "user.disabled"
+)
+
+// AuditEvent represents a single entry in the append-only authentication audit log
+type AuditEvent struct {
+	ID        string         `json:"id"`
+	Type      AuditEventType `json:"type"`
+	UserID    string         `json:"user_id,omitempty"`
+	Email     string         `json:"email,omitempty"`
+	ActorID   string         `json:"actor_id,omitempty"`
+	IPAddress string         `json:"ip_address,omitempty"`
+	UserAgent string         `json:"user_agent,omitempty"`
+	Reason    string         `json:"reason,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// AuditFilter represents filtering options for audit log queries
+type AuditFilter struct {
+	UserID string         `json:"user_id,omitempty"`
+	Type   AuditEventType `json:"type,omitempty"`
+	Since  time.Time      `json:"since,omitempty"`
+	Limit  int            `json:"limit,omitempty"`
+}
+
+// ChangePasswordRequest represents the request payload for changing a password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+// NewAuditEvent creates a new audit event with generated ID and timestamp
+func NewAuditEvent(eventType AuditEventType, userID, email string) *AuditEvent {
+	return &AuditEvent{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		UserID:    userID,
+		Email:     email,
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+// PolicyViolation describes a single password policy rule a password failed
+type PolicyViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Password policy violation codes
+const (
+	ViolationTooShort      = "too_short"
+	ViolationMissingUpper  = "missing_uppercase"
+	ViolationMissingLower  = "missing_lowercase"
+	ViolationMissingDigit  = "missing_digit"
+	ViolationMissingSymbol = "missing_symbol"
+	ViolationBanned        = "banned"
+)
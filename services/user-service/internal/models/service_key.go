@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ServiceKey represents an API key used by another microservice to call this one.
+// Only a hash of the key is stored; the plaintext is returned once at issuance.
+type ServiceKey struct {
+	ID         string     `json:"id"`
+	Service    string     `json:"service"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateServiceKeyRequest represents the request payload for issuing a service key
+type CreateServiceKeyRequest struct {
+	Service string `json:"service" validate:"required"`
+}
+
+// ServiceKeyResponse is returned when a key is issued and is the only time the plaintext is exposed
+type ServiceKeyResponse struct {
+	ServiceKey
+	Key string `json:"key"`
+}
+
+// VerifyServiceKeyRequest represents the payload other services send to validate a key
+type VerifyServiceKeyRequest struct {
+	Key string `json:"key" validate:"required"`
+}
+
+// NewServiceKey creates a new service key record
+func NewServiceKey(service, prefix, keyHash string) *ServiceKey {
+	return &ServiceKey{
+		ID:        uuid.New().String(),
+		Service:   service,
+		Prefix:    prefix,
+		KeyHash:   keyHash,
+		CreatedAt: time.Now(),
+	}
+}
+
+// IsActive checks if the key has not been revoked
+func (k *ServiceKey) IsActive() bool {
+	return k.RevokedAt == nil
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session represents an authenticated login session. The bearer token is never
+// serialized; clients identify sessions by ID when listing or revoking them.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Token      string     `json:"-"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	IPAddress  string     `json:"ip_address,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// NewSession creates a new session for a user that expires after ttl
+func NewSession(userID, token, userAgent, ipAddress string, ttl time.Duration) *Session {
+	now := time.Now()
+	return &Session{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		Token:      token,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+}
+
+// IsValid checks if the session is neither revoked nor expired
+func (s *Session) IsValid() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
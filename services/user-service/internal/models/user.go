@@ -1,25 +1,43 @@
 package models
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
+	"ecommerce/pkg/api"
 	"github.com/google/uuid"
 )
 
+// Role represents the authorization role of a user
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	RoleAdmin    Role = "admin"
+)
+
 // User represents a user in the system
 type User struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  string    `json:"password,omitempty"` // omitempty prevents password from being returned in JSON
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                    string     `json:"id"`
+	Name                  string     `json:"name"`
+	Email                 string     `json:"email"`
+	Phone                 string     `json:"phone,omitempty"` // E.164, optional; enables SMS OTP login
+	Password              string     `json:"password,omitempty"` // omitempty prevents password from being returned in JSON
+	Role                  Role       `json:"role"`
+	Active                bool       `json:"active"`
+	PasswordResetRequired bool       `json:"password_reset_required,omitempty"` // blocks login until the password is reset
+	DeactivatedAt         *time.Time `json:"deactivated_at,omitempty"`
+	AnonymizedAt          *time.Time `json:"anonymized_at,omitempty"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
 }
 
 // CreateUserRequest represents the request payload for creating a user
 type CreateUserRequest struct {
 	Name     string `json:"name" validate:"required,min=2"`
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Password string `json:"password" validate:"required"`
+	Phone    string `json:"phone,omitempty" validate:"omitempty,phone"`
 }
 
 // LoginRequest represents the request payload for user login
@@ -28,10 +46,53 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// ChangeRoleRequest represents the request payload for changing a user's role
+type ChangeRoleRequest struct {
+	Role Role `json:"role" validate:"required"`
+}
+
+// ResetPasswordRequest represents the request payload for completing a password reset
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+// ChangeEmailRequest represents the request payload for starting an email change
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// ConfirmEmailRequest represents the request payload for confirming an email change
+type ConfirmEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// PasswordResetResponse is returned to admins when they force a reset. There is no
+// mail delivery yet, so the token is handed to the admin to pass on out-of-band.
+type PasswordResetResponse struct {
+	UserID     string    `json:"user_id"`
+	ResetToken string    `json:"reset_token"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// OTPRequest represents the request payload for sending a login code by SMS
+type OTPRequest struct {
+	Phone string `json:"phone" validate:"required,phone"`
+}
+
+// OTPVerifyRequest represents the request payload for logging in with an SMS code
+type OTPVerifyRequest struct {
+	Phone string `json:"phone" validate:"required,phone"`
+	Code  string `json:"code" validate:"required,len=6"`
+}
+
 // LoginResponse represents the response for successful login
 type LoginResponse struct {
-	User  User   `json:"user"`
-	Token string `json:"token"`
+	User      User      `json:"user"`
+	Token     string    `json:"token"`
+	SessionID string    `json:"session_id"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 // NewUser creates a new user with generated ID and timestamps
@@ -42,15 +103,67 @@ func NewUser(name, email, password string) *User {
 		Name:      name,
 		Email:     email,
 		Password:  password, // In production, this should be hashed
+		Role:      RoleCustomer,
+		Active:    true,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
 }
 
+// Anonymize replaces all personal data on the user and deactivates the account
+func (u *User) Anonymize() {
+	now := time.Now()
+	u.Name = "Deleted User"
+	u.Email = "deleted-" + u.ID + "@anonymized.invalid"
+	u.Password = ""
+	u.Active = false
+	u.DeactivatedAt = &now
+	u.AnonymizedAt = &now
+	u.UpdatedAt = now
+}
+
+// NormalizePhone strips common formatting from a phone number and checks it is in
+// E.164 form (a leading + followed by 8 to 15 digits)
+func NormalizePhone(phone string) (string, bool) {
+	var b strings.Builder
+	for _, c := range strings.TrimSpace(phone) {
+		switch {
+		case c == ' ' || c == '-' || c == '(' || c == ')' || c == '.':
+			continue
+		case c == '+' && b.Len() == 0:
+			b.WriteRune(c)
+		case c >= '0' && c <= '9':
+			b.WriteRune(c)
+		default:
+			return "", false
+		}
+	}
+
+	normalized := b.String()
+	digits := len(strings.TrimPrefix(normalized, "+"))
+	if !strings.HasPrefix(normalized, "+") || digits < 8 || digits > 15 {
+		return "", false
+	}
+	return normalized, true
+}
+
+// IsValidRole checks whether the given role is known
+func IsValidRole(role Role) bool {
+	return role == RoleCustomer || role == RoleAdmin
+}
+
+// IsAdmin checks if the user has the admin role
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
 // Response represents a standard API response
-type Response struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+type Response = api.Response[api.Unpaged]
+
+// UserDataExport represents the archive returned by the GDPR data export endpoint
+type UserDataExport struct {
+	ExportedAt time.Time       `json:"exported_at"`
+	User       User            `json:"user"`
+	Addresses  []*Address      `json:"addresses"`
+	Orders     json.RawMessage `json:"orders"`
 }
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TokenPurpose identifies what a one-time verification token may be used for
+type TokenPurpose string
+
+const (
+	TokenPurposePasswordReset TokenPurpose = "password_reset"
+	TokenPurposeEmailChange   TokenPurpose = "email_change"
+	TokenPurposeOTPLogin      TokenPurpose = "otp_login"
+)
+
+// VerificationToken represents a single-use secret sent out-of-band to a user.
+// Only a hash of the secret is stored; Payload carries purpose-specific data.
+type VerificationToken struct {
+	ID        string       `json:"id"`
+	Purpose   TokenPurpose `json:"purpose"`
+	UserID    string       `json:"user_id"`
+	TokenHash string       `json:"-"`
+	Payload   string       `json:"-"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    *time.Time   `json:"used_at,omitempty"`
+}
+
+// NewVerificationToken creates a new token record that expires after ttl
+func NewVerificationToken(purpose TokenPurpose, userID, tokenHash, payload string, ttl time.Duration) *VerificationToken {
+	now := time.Now()
+	return &VerificationToken{
+		ID:        uuid.New().String(),
+		Purpose:   purpose,
+		UserID:    userID,
+		TokenHash: tokenHash,
+		Payload:   payload,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
+
+// IsUsable checks if the token has neither been used nor expired
+func (t *VerificationToken) IsUsable() bool {
+	return t.UsedAt == nil && time.Now().Before(t.ExpiresAt)
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+	"user-service/internal/models"
+)
+
+// ActivityRepository stores users' activity feeds
+type ActivityRepository interface {
+	// Add records an activity. Adding one already recorded, as when its event is delivered again,
+	// changes nothing.
+	Add(activity *models.Activity) error
+	// ListByUser returns a user's most recent activities, newest first; limit 0 returns all of them
+	ListByUser(userID string, limit int) ([]*models.Activity, error)
+	DeleteByUser(userID string) error
+}
+
+// InMemoryActivityRepository implements ActivityRepository using in-memory storage
+type InMemoryActivityRepository struct {
+	activities map[string][]*models.Activity // by user ID
+	seen       map[string]bool               // IDs of the activities recorded
+	mutex      sync.RWMutex
+}
+
+// NewInMemoryActivityRepository creates a new in-memory activity repository
+func NewInMemoryActivityRepository() *InMemoryActivityRepository {
+	return &InMemoryActivityRepository{
+		activities: make(map[string][]*models.Activity),
+		seen:       make(map[string]bool),
+	}
+}
+
+// Add records an activity unless it was recorded already
+func (r *InMemoryActivityRepository) Add(activity *models.Activity) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.seen[activity.ID] {
+		return nil
+	}
+	r.seen[activity.ID] = true
+	activityCopy := *activity
+	r.activities[activity.UserID] = append(r.activities[activity.UserID], &activityCopy)
+	return nil
+}
+
+// ListByUser returns a user's most recent activities, newest first
+func (r *InMemoryActivityRepository) ListByUser(userID string, limit int) ([]*models.Activity, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	activities := make([]*models.Activity, 0, len(r.activities[userID]))
+	for _, activity := range r.activities[userID] {
+		activityCopy := *activity
+		activities = append(activities, &activityCopy)
+	}
+	// Events from different partitions can arrive out of order, so order by when they happened
+	sort.SliceStable(activities, func(i, j int) bool {
+		return activities[i].OccurredAt.After(activities[j].OccurredAt)
+	})
+	if limit > 0 && len(activities) > limit {
+		activities = activities[:limit]
+	}
+	return activities, nil
+}
+
+// DeleteByUser removes a user's activity feed
+func (r *InMemoryActivityRepository) DeleteByUser(userID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.activities, userID)
+	return nil
+}
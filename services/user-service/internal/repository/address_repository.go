@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+	"user-service/internal/models"
+)
+
+// AddressRepository defines the interface for user address operations
+type AddressRepository interface {
+	Create(address *models.Address) error
+	GetByID(userID, id string) (*models.Address, error)
+	ListByUser(userID string) ([]*models.Address, error)
+	GetDefault(userID string, addressType models.AddressType) (*models.Address, error)
+	Update(address *models.Address) error
+	Delete(userID, id string) error
+}
+
+// InMemoryAddressRepository implements AddressRepository using in-memory storage
+type InMemoryAddressRepository struct {
+	addresses map[string]*models.Address
+	mutex     sync.RWMutex
+}
+
+// NewInMemoryAddressRepository creates a new in-memory address repository
+func NewInMemoryAddressRepository() *InMemoryAddressRepository {
+	return &InMemoryAddressRepository{
+		addresses: make(map[string]*models.Address),
+	}
+}
+
+// Create adds a new address, taking over the default flags from the user's other addresses
+func (r *InMemoryAddressRepository) Create(address *models.Address) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// The first address a user adds becomes the default for both slots
+	if !r.hasAddressesLocked(address.UserID) {
+		address.IsDefaultShipping = true
+		address.IsDefaultBilling = true
+	}
+
+	r.clearDefaultsLocked(address)
+	r.addresses[address.ID] = address
+	return nil
+}
+
+// GetByID retrieves a single address owned by the given user
+func (r *InMemoryAddressRepository) GetByID(userID, id string) (*models.Address, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	address, exists := r.addresses[id]
+	if !exists || address.UserID != userID {
+		return nil, errors.New("address not found")
+	}
+
+	addressCopy := *address
+	return &addressCopy, nil
+}
+
+// ListByUser returns all addresses of a user, oldest first
+func (r *InMemoryAddressRepository) ListByUser(userID string) ([]*models.Address, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	addresses := make([]*models.Address, 0)
+	for _, address := range r.addresses {
+		if address.UserID == userID {
+			addressCopy := *address
+			addresses = append(addresses, &addressCopy)
+		}
+	}
+
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i].CreatedAt.Before(addresses[j].CreatedAt)
+	})
+	return addresses, nil
+}
+
+// GetDefault returns the user's default shipping or billing address
+func (r *InMemoryAddressRepository) GetDefault(userID string, addressType models.AddressType) (*models.Address, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, address := range r.addresses {
+		if address.UserID != userID {
+			continue
+		}
+		if (addressType == models.AddressTypeShipping && address.IsDefaultShipping) ||
+			(addressType == models.AddressTypeBilling && address.IsDefaultBilling) {
+			addressCopy := *address
+			return &addressCopy, nil
+		}
+	}
+
+	return nil, errors.New("default address not found")
+}
+
+// Update modifies an existing address
+func (r *InMemoryAddressRepository) Update(address *models.Address) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, exists := r.addresses[address.ID]
+	if !exists || existing.UserID != address.UserID {
+		return errors.New("address not found")
+	}
+
+	address.UpdatedAt = time.Now()
+	r.clearDefaultsLocked(address)
+	r.addresses[address.ID] = address
+	return nil
+}
+
+// Delete removes an address; if it held a default flag, the oldest remaining address inherits it
+func (r *InMemoryAddressRepository) Delete(userID, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	address, exists := r.addresses[id]
+	if !exists || address.UserID != userID {
+		return errors.New("address not found")
+	}
+
+	delete(r.addresses, id)
+
+	if address.IsDefaultShipping || address.IsDefaultBilling {
+		if successor := r.oldestLocked(userID); successor != nil {
+			successor.IsDefaultShipping = successor.IsDefaultShipping || address.IsDefaultShipping
+			successor.IsDefaultBilling = successor.IsDefaultBilling || address.IsDefaultBilling
+		}
+	}
+	return nil
+}
+
+// hasAddressesLocked reports whether the user already has any address. Caller must hold the lock.
+func (r *InMemoryAddressRepository) hasAddressesLocked(userID string) bool {
+	for _, address := range r.addresses {
+		if address.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// clearDefaultsLocked removes default flags from the user's other addresses
+// when the given address claims them. Caller must hold the lock.
+func (r *InMemoryAddressRepository) clearDefaultsLocked(address *models.Address) {
+	for _, other := range r.addresses {
+		if other.UserID != address.UserID || other.ID == address.ID {
+			continue
+		}
+		if address.IsDefaultShipping {
+			other.IsDefaultShipping = false
+		}
+		if address.IsDefaultBilling {
+			other.IsDefaultBilling = false
+		}
+	}
+}
+
+// oldestLocked returns the user's oldest address. Caller must hold the lock.
+func (r *InMemoryAddressRepository) oldestLocked(userID string) *models.Address {
+	var oldest *models.Address
+	for _, address := range r.addresses {
+		if address.UserID != userID {
+			continue
+		}
+		if oldest == nil || address.CreatedAt.Before(oldest.CreatedAt) {
+			oldest = address
+		}
+	}
+	return oldest
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"testing"
+	"user-service/internal/models"
+)
+
+func newTestAddress(userID, line1 string) *models.Address {
+	return models.NewAddress(userID, models.CreateAddressRequest{
+		RecipientName: "Alice",
+		Line1:         line1,
+		City:          "Nairobi",
+		PostalCode:    "00100",
+		Country:       "KE",
+	})
+}
+
+func TestInMemoryAddressRepository_FirstAddressBecomesDefault(t *testing.T) {
+	repo := NewInMemoryAddressRepository()
+	first := newTestAddress("u1", "1 First St")
+	_ = repo.Create(first)
+
+	got, err := repo.GetDefault("u1", models.AddressTypeShipping)
+	if err != nil {
+		t.Fatalf("expected default shipping address, got error: %v", err)
+	}
+	if got.ID != first.ID {
+		t.Errorf("expected first address to be default")
+	}
+	if _, err := repo.GetDefault("u1", models.AddressTypeBilling); err != nil {
+		t.Errorf("expected default billing address, got error: %v", err)
+	}
+}
+
+func TestInMemoryAddressRepository_DefaultMovesOnCreateAndDelete(t *testing.T) {
+	repo := NewInMemoryAddressRepository()
+	first := newTestAddress("u1", "1 First St")
+	_ = repo.Create(first)
+
+	second := newTestAddress("u1", "2 Second St")
+	second.IsDefaultShipping = true
+	_ = repo.Create(second)
+
+	got, _ := repo.GetDefault("u1", models.AddressTypeShipping)
+	if got.ID != second.ID {
+		t.Errorf("expected second address to take over default shipping")
+	}
+	got, _ = repo.GetDefault("u1", models.AddressTypeBilling)
+	if got.ID != first.ID {
+		t.Errorf("expected first address to keep default billing")
+	}
+
+	if err := repo.Delete("u1", second.ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	got, _ = repo.GetDefault("u1", models.AddressTypeShipping)
+	if got == nil || got.ID != first.ID {
+		t.Errorf("expected remaining address to inherit default shipping")
+	}
+}
+
+func TestInMemoryAddressRepository_OwnershipIsEnforced(t *testing.T) {
+	repo := NewInMemoryAddressRepository()
+	a := newTestAddress("u1", "1 First St")
+	_ = repo.Create(a)
+
+	if _, err := repo.GetByID("u2", a.ID); err == nil {
+		t.Error("expected not found when reading another user's address")
+	}
+	if err := repo.Delete("u2", a.ID); err == nil {
+		t.Error("expected not found when deleting another user's address")
+	}
+	list, _ := repo.ListByUser("u2")
+	if len(list) != 0 {
+		t.Errorf("expected no addresses for u2, got %d", len(list))
+	}
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"sync"
+	"user-service/internal/models"
+)
+
+// AuditRepository defines the interface for the append-only audit log.
+// There are deliberately no update or delete operations.
+type AuditRepository interface {
+	Append(event *models.AuditEvent) error
+	Query(filter *models.AuditFilter) ([]*models.AuditEvent, error)
+}
+
+// InMemoryAuditRepository implements AuditRepository using an in-memory slice
+type InMemoryAuditRepository struct {
+	events []*models.AuditEvent
+	mutex  sync.RWMutex
+}
+
+// NewInMemoryAuditRepository creates a new in-memory audit repository
+func NewInMemoryAuditRepository() *InMemoryAuditRepository {
+	return &InMemoryAuditRepository{
+		events: make([]*models.AuditEvent, 0),
+	}
+}
+
+// Append records an event at the end of the log
+func (r *InMemoryAuditRepository) Append(event *models.AuditEvent) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	eventCopy := *event
+	r.events = append(r.events, &eventCopy)
+	return nil
+}
+
+// Query returns matching events, newest first
+func (r *InMemoryAuditRepository) Query(filter *models.AuditFilter) ([]*models.AuditEvent, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	events := make([]*models.AuditEvent, 0)
+	for i := len(r.events) - 1; i >= 0; i-- {
+		event := r.events[i]
+
+		// Apply filters if provided
+		if filter != nil {
+			if filter.UserID != "" && event.UserID != filter.UserID {
+				continue
+			}
+			if filter.Type != "" && event.Type != filter.Type {
+				continue
+			}
+			if !filter.Since.IsZero() && event.CreatedAt.Before(filter.Since) {
+				continue
+			}
+		}
+
+		eventCopy := *event
+		events = append(events, &eventCopy)
+
+		if filter != nil && filter.Limit > 0 && len(events) >= filter.Limit {
+			break
+		}
+	}
+
+	return events, nil
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"testing"
+	"user-service/internal/models"
+)
+
+func TestInMemoryAuditRepository_QueryFilters(t *testing.T) {
+	repo := NewInMemoryAuditRepository()
+	_ = repo.Append(models.NewAuditEvent(models.AuditLoginFailure, "u1", "a@example.com"))
+	_ = repo.Append(models.NewAuditEvent(models.AuditLoginSuccess, "u1", "a@example.com"))
+	_ = repo.Append(models.NewAuditEvent(models.AuditLoginSuccess, "u2", "b@example.com"))
+
+	events, err := repo.Query(&models.AuditFilter{UserID: "u1"})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != models.AuditLoginSuccess {
+		t.Errorf("expected 2 events for u1 newest first, got %d", len(events))
+	}
+
+	events, _ = repo.Query(&models.AuditFilter{Type: models.AuditLoginSuccess, Limit: 1})
+	if len(events) != 1 || events[0].UserID != "u2" {
+		t.Errorf("expected newest login.success only, got %+v", events)
+	}
+}
@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+	"ecommerce/pkg/mongodb"
+	"user-service/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Unique indexes on users, named so a violation can be told apart from other failures
+const (
+	mongoUsersEmailIndex = "users_email_key"
+	mongoUsersPhoneIndex = "users_phone_key"
+)
+
+var mongoUserIndexes = []mongo.IndexModel{
+	{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetName(mongoUsersEmailIndex).SetUnique(true)},
+	// Phone numbers are optional, so only the ones given must be unique
+	{Keys: bson.D{{Key: "phone", Value: 1}}, Options: options.Index().SetName(mongoUsersPhoneIndex).SetUnique(true).
+		SetPartialFilterExpression(bson.D{{Key: "phone", Value: bson.D{{Key: "$gt", Value: ""}}}})},
+	{Keys: bson.D{{Key: "role", Value: 1}}},
+}
+
+// MongoUserRepository implements UserRepository on a MongoDB collection, so users are shared by
+// every instance of the service and survive restarts
+type MongoUserRepository struct {
+	users *mongo.Collection
+}
+
+// mongoUserDocument is how a user is stored: the user keyed by their ID
+type mongoUserDocument struct {
+	Key          string `bson:"_id"`
+	*models.User `bson:",inline"`
+}
+
+// NewMongoUserRepository creates a repository on the database's users collection, creating its
+// indexes if they don't exist yet
+func NewMongoUserRepository(ctx context.Context, db *mongo.Database) (*MongoUserRepository, error) {
+	users := db.Collection("users")
+	if err := mongodb.EnsureIndexes(ctx, users, mongoUserIndexes); err != nil {
+		return nil, err
+	}
+	return &MongoUserRepository{users: users}, nil
+}
+
+// mongoDuplicateError turns a unique index violation into the error the in-memory repository gives
+func mongoDuplicateError(err error) error {
+	switch {
+	case mongodb.IsDuplicateKey(err, mongoUsersEmailIndex):
+		return errors.New("user with this email already exists")
+	case mongodb.IsDuplicateKey(err, mongoUsersPhoneIndex):
+		return errors.New("user with this phone already exists")
+	}
+	return err
+}
+
+// Create adds a new user
+func (r *MongoUserRepository) Create(ctx context.Context, user *models.User) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	_, err := r.users.InsertOne(ctx, mongoUserDocument{Key: user.ID, User: user})
+	return mongoDuplicateError(err)
+}
+
+// GetByID retrieves a user by their ID, without their password
+func (r *MongoUserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
+	user, err := r.findUser(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return nil, err
+	}
+	user.Password = ""
+	return user, nil
+}
+
+// GetByEmail retrieves a user by their email address, with their password for authentication
+func (r *MongoUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.findUser(ctx, bson.D{{Key: "email", Value: email}})
+}
+
+// GetByPhone retrieves a user by their phone number, without their password
+func (r *MongoUserRepository) GetByPhone(ctx context.Context, phone string) (*models.User, error) {
+	if phone == "" {
+		return nil, errors.New("user not found")
+	}
+	user, err := r.findUser(ctx, bson.D{{Key: "phone", Value: phone}})
+	if err != nil {
+		return nil, err
+	}
+	user.Password = ""
+	return user, nil
+}
+
+// findUser reads the one user matching filter
+func (r *MongoUserRepository) findUser(ctx context.Context, filter bson.D) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	document := mongoUserDocument{User: &models.User{}}
+	err := r.users.FindOne(ctx, filter).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errors.New("user not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return document.User, nil
+}
+
+// findUsers reads every user matching filter, oldest first, without their passwords
+func (r *MongoUserRepository) findUsers(ctx context.Context, filter bson.D) ([]*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	cursor, err := r.users.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := make([]*models.User, 0)
+	for cursor.Next(ctx) {
+		document := mongoUserDocument{User: &models.User{}}
+		if err := cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+		document.User.Password = ""
+		users = append(users, document.User)
+	}
+	return users, cursor.Err()
+}
+
+// Update replaces an existing user. Users read by ID carry no password, so an empty password
+// leaves the stored one in place.
+func (r *MongoUserRepository) Update(ctx context.Context, user *models.User) error {
+	fields := bson.D{
+		{Key: "name", Value: user.Name},
+		{Key: "email", Value: user.Email},
+		{Key: "phone", Value: user.Phone},
+		{Key: "role", Value: user.Role},
+		{Key: "active", Value: user.Active},
+		{Key: "password_reset_required", Value: user.PasswordResetRequired},
+		{Key: "deactivated_at", Value: user.DeactivatedAt},
+		{Key: "anonymized_at", Value: user.AnonymizedAt},
+		{Key: "updated_at", Value: user.UpdatedAt},
+	}
+	if user.Password != "" {
+		fields = append(fields, bson.E{Key: "password", Value: user.Password})
+	}
+	return r.update(ctx, user.ID, fields)
+}
+
+// Delete removes a user
+func (r *MongoUserRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.users.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+// List returns all users, without their passwords
+func (r *MongoUserRepository) List(ctx context.Context) ([]*models.User, error) {
+	return r.findUsers(ctx, bson.D{})
+}
+
+// Count returns how many users are stored
+func (r *MongoUserRepository) Count(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	count, err := r.users.CountDocuments(ctx, bson.D{})
+	return int(count), err
+}
+
+// SetActive deactivates or reactivates a user account without removing it
+func (r *MongoUserRepository) SetActive(ctx context.Context, id string, active bool) error {
+	now := time.Now()
+	var deactivatedAt *time.Time
+	if !active {
+		deactivatedAt = &now
+	}
+	return r.update(ctx, id, bson.D{{Key: "active", Value: active}, {Key: "deactivated_at", Value: deactivatedAt}, {Key: "updated_at", Value: now}})
+}
+
+// UpdatePassword replaces a user's password
+func (r *MongoUserRepository) UpdatePassword(ctx context.Context, id, password string) error {
+	return r.update(ctx, id, bson.D{{Key: "password", Value: password}, {Key: "password_reset_required", Value: false}, {Key: "updated_at", Value: time.Now()}})
+}
+
+// UpdateEmail replaces a user's email, failing if another user already has it
+func (r *MongoUserRepository) UpdateEmail(ctx context.Context, id, email string) error {
+	return r.update(ctx, id, bson.D{{Key: "email", Value: email}, {Key: "updated_at", Value: time.Now()}})
+}
+
+// SetPasswordResetRequired flags or unflags a user as needing a password reset before login
+func (r *MongoUserRepository) SetPasswordResetRequired(ctx context.Context, id string, required bool) error {
+	return r.update(ctx, id, bson.D{{Key: "password_reset_required", Value: required}, {Key: "updated_at", Value: time.Now()}})
+}
+
+// SetRole changes a user's role
+func (r *MongoUserRepository) SetRole(ctx context.Context, id string, role models.Role) error {
+	return r.update(ctx, id, bson.D{{Key: "role", Value: role}, {Key: "updated_at", Value: time.Now()}})
+}
+
+// ListByRole returns all users with the given role, without their passwords
+func (r *MongoUserRepository) ListByRole(ctx context.Context, role models.Role) ([]*models.User, error) {
+	return r.findUsers(ctx, bson.D{{Key: "role", Value: role}})
+}
+
+// Anonymize scrubs a user's personal data and returns a full snapshot of the previous record
+// (including password) so the change can be compensated. The rewrite only goes through while the
+// user is still not anonymized, so two purges can't both take a snapshot.
+func (r *MongoUserRepository) Anonymize(ctx context.Context, id string) (*models.User, error) {
+	user, err := r.findUser(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return nil, err
+	}
+	if user.AnonymizedAt != nil {
+		return nil, errors.New("user already anonymized")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	previous := *user
+	user.Anonymize()
+	result, err := r.users.ReplaceOne(ctx, bson.D{{Key: "_id", Value: id}, {Key: "anonymized_at", Value: nil}}, mongoUserDocument{Key: id, User: user})
+	if err != nil {
+		return nil, mongoDuplicateError(err)
+	}
+	if result.MatchedCount == 0 {
+		return nil, errors.New("user already anonymized")
+	}
+	return &previous, nil
+}
+
+// Restore replaces a user record with a previously taken snapshot
+func (r *MongoUserRepository) Restore(ctx context.Context, user *models.User) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.users.ReplaceOne(ctx, bson.D{{Key: "_id", Value: user.ID}}, mongoUserDocument{Key: user.ID, User: user})
+	if err != nil {
+		return mongoDuplicateError(err)
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+// Ping checks the deployment can be reached, for the readiness probe
+func (r *MongoUserRepository) Ping(ctx context.Context) error {
+	return r.users.Database().Client().Ping(ctx, nil)
+}
+
+// update sets fields on one user, failing with "user not found" if there is no such user
+func (r *MongoUserRepository) update(ctx context.Context, id string, fields bson.D) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.users.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, bson.D{{Key: "$set", Value: fields}})
+	if err != nil {
+		return mongoDuplicateError(err)
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"testing"
+	"ecommerce/pkg/mongodb"
+	"user-service/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// newTestMongoUserRepository connects to the replica set at TEST_MONGODB_URI, skipping the test
+// when it isn't set. The users collection is emptied first, so point it at a throwaway deployment.
+func newTestMongoUserRepository(t *testing.T) *MongoUserRepository {
+	t.Helper()
+	uri := os.Getenv("TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGODB_URI is not set")
+	}
+
+	ctx := context.Background()
+	db, err := mongodb.Connect(ctx, uri, "user_service_test")
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	t.Cleanup(func() { db.Client().Disconnect(context.Background()) })
+
+	repo, err := NewMongoUserRepository(ctx, db)
+	if err != nil {
+		t.Fatalf("creating indexes: %v", err)
+	}
+	for _, name := range []string{"users"} {
+		if _, err := db.Collection(name).DeleteMany(ctx, bson.D{}); err != nil {
+			t.Fatalf("emptying %s: %v", name, err)
+		}
+	}
+	return repo
+}
+
+func TestMongoUserRepository_CreateAndGet(t *testing.T) {
+	repo := newTestMongoUserRepository(t)
+	user := models.NewUser("Alice", "alice@example.com", "password123")
+	user.Phone = "+254700000001"
+
+	if err := repo.Create(context.Background(), user); err != nil {
+		t.Fatalf("expected create success, got error: %v", err)
+	}
+	if err := repo.Create(context.Background(), models.NewUser("Alice2", "alice@example.com", "pass")); err == nil || err.Error() != "user with this email already exists" {
+		t.Errorf("expected duplicate email error, got %v", err)
+	}
+	duplicatePhone := models.NewUser("Alice3", "alice3@example.com", "pass")
+	duplicatePhone.Phone = user.Phone
+	if err := repo.Create(context.Background(), duplicatePhone); err == nil || err.Error() != "user with this phone already exists" {
+		t.Errorf("expected duplicate phone error, got %v", err)
+	}
+	// Users without a phone number don't clash with each other
+	if err := repo.Create(context.Background(), models.NewUser("Bob", "bob@example.com", "pass")); err != nil {
+		t.Errorf("expected a second user without a phone to be created, got %v", err)
+	}
+
+	fetched, err := repo.GetByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("expected fetch success, got error: %v", err)
+	}
+	if fetched.Email != user.Email || fetched.Password != "" {
+		t.Errorf("expected %s without a password, got %+v", user.Email, fetched)
+	}
+	withPassword, err := repo.GetByEmail(context.Background(), user.Email)
+	if err != nil || withPassword.Password != "password123" {
+		t.Errorf("expected GetByEmail to return the password, got %+v, %v", withPassword, err)
+	}
+	if _, err := repo.GetByID(context.Background(), "missing"); err == nil || err.Error() != "user not found" {
+		t.Errorf("expected user not found, got %v", err)
+	}
+}
+
+func TestMongoUserRepository_UpdateKeepsPassword(t *testing.T) {
+	repo := newTestMongoUserRepository(t)
+	user := models.NewUser("Carol", "carol@example.com", "secret")
+	if err := repo.Create(context.Background(), user); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	fetched, _ := repo.GetByID(context.Background(), user.ID)
+	fetched.Name = "Carol Updated"
+	if err := repo.Update(context.Background(), fetched); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	stored, _ := repo.GetByEmail(context.Background(), user.Email)
+	if stored.Name != "Carol Updated" || stored.Password != "secret" {
+		t.Errorf("expected the new name and the old password, got %+v", stored)
+	}
+}
+
+func TestMongoUserRepository_AnonymizeAndRestore(t *testing.T) {
+	repo := newTestMongoUserRepository(t)
+	user := models.NewUser("Dave", "dave@example.com", "secret")
+	if err := repo.Create(context.Background(), user); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	snapshot, err := repo.Anonymize(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("anonymize: %v", err)
+	}
+	if snapshot.Email != "dave@example.com" || snapshot.Password != "secret" {
+		t.Errorf("expected a full snapshot, got %+v", snapshot)
+	}
+	if _, err := repo.Anonymize(context.Background(), user.ID); err == nil {
+		t.Error("expected anonymizing twice to fail")
+	}
+	if _, err := repo.GetByEmail(context.Background(), "dave@example.com"); err == nil {
+		t.Error("expected the email to be scrubbed")
+	}
+
+	if err := repo.Restore(context.Background(), snapshot); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	restored, err := repo.GetByEmail(context.Background(), "dave@example.com")
+	if err != nil || restored.AnonymizedAt != nil {
+		t.Errorf("expected the user restored, got %+v, %v", restored, err)
+	}
+}
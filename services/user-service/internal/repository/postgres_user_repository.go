@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+	"ecommerce/pkg/postgres"
+	"user-service/internal/models"
+)
+
+// Unique constraints on users, named so a violation can be told apart from other failures
+const (
+	usersEmailKey = "users_email_key"
+	usersPhoneKey = "users_phone_key"
+)
+
+var postgresUserSchema = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		id                      TEXT PRIMARY KEY,
+		name                    TEXT NOT NULL,
+		email                   TEXT NOT NULL CONSTRAINT users_email_key UNIQUE,
+		phone                   TEXT NOT NULL DEFAULT '',
+		password                TEXT NOT NULL DEFAULT '',
+		role                    TEXT NOT NULL,
+		active                  BOOLEAN NOT NULL,
+		password_reset_required BOOLEAN NOT NULL DEFAULT FALSE,
+		deactivated_at          TIMESTAMPTZ,
+		anonymized_at           TIMESTAMPTZ,
+		created_at              TIMESTAMPTZ NOT NULL,
+		updated_at              TIMESTAMPTZ NOT NULL
+	)`,
+	// Phone numbers are optional, so only the ones given must be unique
+	`CREATE UNIQUE INDEX IF NOT EXISTS users_phone_key ON users (phone) WHERE phone <> ''`,
+	`CREATE INDEX IF NOT EXISTS users_role_idx ON users (role)`,
+}
+
+// userColumns are the columns a user is read from, in the order scanUser expects
+const userColumns = `id, name, email, phone, password, role, active, password_reset_required, deactivated_at, anonymized_at, created_at, updated_at`
+
+// PostgresUserRepository implements UserRepository on a PostgreSQL database, so users are shared
+// by every instance of the service and survive restarts
+type PostgresUserRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresUserRepository creates a repository on db, creating its table if it doesn't exist yet
+func NewPostgresUserRepository(ctx context.Context, db *sql.DB) (*PostgresUserRepository, error) {
+	if err := postgres.Migrate(ctx, db, "users", postgresUserSchema); err != nil {
+		return nil, err
+	}
+	return &PostgresUserRepository{db: db}, nil
+}
+
+// rowScanner is a *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser reads a user selected with userColumns
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	var role string
+	var deactivatedAt, anonymizedAt sql.NullTime
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Phone, &user.Password, &role, &user.Active,
+		&user.PasswordResetRequired, &deactivatedAt, &anonymizedAt, &user.CreatedAt, &user.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("user not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	user.Role = models.Role(role)
+	if deactivatedAt.Valid {
+		user.DeactivatedAt = &deactivatedAt.Time
+	}
+	if anonymizedAt.Valid {
+		user.AnonymizedAt = &anonymizedAt.Time
+	}
+	return &user, nil
+}
+
+// duplicateError turns a unique constraint violation into the error the in-memory repository gives
+func duplicateError(err error) error {
+	switch {
+	case postgres.IsUniqueViolation(err, usersEmailKey):
+		return errors.New("user with this email already exists")
+	case postgres.IsUniqueViolation(err, usersPhoneKey):
+		return errors.New("user with this phone already exists")
+	}
+	return err
+}
+
+// Create adds a new user
+func (r *PostgresUserRepository) Create(ctx context.Context, user *models.User) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO users (`+userColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		user.ID, user.Name, user.Email, user.Phone, user.Password, string(user.Role), user.Active,
+		user.PasswordResetRequired, user.DeactivatedAt, user.AnonymizedAt, user.CreatedAt, user.UpdatedAt)
+	return duplicateError(err)
+}
+
+// GetByID retrieves a user by their ID, without their password
+func (r *PostgresUserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
+	user, err := r.queryUser(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = ""
+	return user, nil
+}
+
+// GetByEmail retrieves a user by their email address, with their password for authentication
+func (r *PostgresUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.queryUser(ctx, `SELECT `+userColumns+` FROM users WHERE email = $1`, email)
+}
+
+// GetByPhone retrieves a user by their phone number, without their password
+func (r *PostgresUserRepository) GetByPhone(ctx context.Context, phone string) (*models.User, error) {
+	if phone == "" {
+		return nil, errors.New("user not found")
+	}
+	user, err := r.queryUser(ctx, `SELECT `+userColumns+` FROM users WHERE phone = $1`, phone)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = ""
+	return user, nil
+}
+
+// queryUser reads the one user query selects
+func (r *PostgresUserRepository) queryUser(ctx context.Context, query string, args ...interface{}) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	return scanUser(r.db.QueryRowContext(ctx, query, args...))
+}
+
+// queryUsers reads every user query selects, without their passwords
+func (r *PostgresUserRepository) queryUsers(ctx context.Context, query string, args ...interface{}) ([]*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*models.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		user.Password = ""
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// Update replaces an existing user. Users read by ID carry no password, so an empty password
+// leaves the stored one in place.
+func (r *PostgresUserRepository) Update(ctx context.Context, user *models.User) error {
+	return r.exec(ctx, `UPDATE users SET name = $2, email = $3, phone = $4, password = COALESCE(NULLIF($5, ''), password),
+		role = $6, active = $7, password_reset_required = $8, deactivated_at = $9, anonymized_at = $10, updated_at = $11
+		WHERE id = $1`,
+		user.ID, user.Name, user.Email, user.Phone, user.Password, string(user.Role), user.Active,
+		user.PasswordResetRequired, user.DeactivatedAt, user.AnonymizedAt, user.UpdatedAt)
+}
+
+// Delete removes a user
+func (r *PostgresUserRepository) Delete(ctx context.Context, id string) error {
+	return r.exec(ctx, `DELETE FROM users WHERE id = $1`, id)
+}
+
+// List returns all users, without their passwords
+func (r *PostgresUserRepository) List(ctx context.Context) ([]*models.User, error) {
+	return r.queryUsers(ctx, `SELECT ` + userColumns + ` FROM users ORDER BY created_at, id`)
+}
+
+// Count returns how many users are stored
+func (r *PostgresUserRepository) Count(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&count)
+	return count, err
+}
+
+// SetActive deactivates or reactivates a user account without removing it
+func (r *PostgresUserRepository) SetActive(ctx context.Context, id string, active bool) error {
+	now := time.Now()
+	var deactivatedAt *time.Time
+	if !active {
+		deactivatedAt = &now
+	}
+	return r.exec(ctx, `UPDATE users SET active = $2, deactivated_at = $3, updated_at = $4 WHERE id = $1`, id, active, deactivatedAt, now)
+}
+
+// UpdatePassword replaces a user's password
+func (r *PostgresUserRepository) UpdatePassword(ctx context.Context, id, password string) error {
+	return r.exec(ctx, `UPDATE users SET password = $2, password_reset_required = FALSE, updated_at = $3 WHERE id = $1`, id, password, time.Now())
+}
+
+// UpdateEmail replaces a user's email, failing if another user already has it
+func (r *PostgresUserRepository) UpdateEmail(ctx context.Context, id, email string) error {
+	return r.exec(ctx, `UPDATE users SET email = $2, updated_at = $3 WHERE id = $1`, id, email, time.Now())
+}
+
+// SetPasswordResetRequired flags or unflags a user as needing a password reset before login
+func (r *PostgresUserRepository) SetPasswordResetRequired(ctx context.Context, id string, required bool) error {
+	return r.exec(ctx, `UPDATE users SET password_reset_required = $2, updated_at = $3 WHERE id = $1`, id, required, time.Now())
+}
+
+// SetRole changes a user's role
+func (r *PostgresUserRepository) SetRole(ctx context.Context, id string, role models.Role) error {
+	return r.exec(ctx, `UPDATE users SET role = $2, updated_at = $3 WHERE id = $1`, id, string(role), time.Now())
+}
+
+// ListByRole returns all users with the given role, without their passwords
+func (r *PostgresUserRepository) ListByRole(ctx context.Context, role models.Role) ([]*models.User, error) {
+	return r.queryUsers(ctx, `SELECT `+userColumns+` FROM users WHERE role = $1 ORDER BY created_at, id`, string(role))
+}
+
+// Anonymize scrubs a user's personal data and returns a full snapshot of the previous record
+// (including password) so the change can be compensated. The user is locked while it is read and
+// rewritten, so two purges can't both take a snapshot.
+func (r *PostgresUserRepository) Anonymize(ctx context.Context, id string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	var snapshot *models.User
+	err := postgres.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		user, err := scanUser(tx.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1 FOR UPDATE`, id))
+		if err != nil {
+			return err
+		}
+		if user.AnonymizedAt != nil {
+			return errors.New("user already anonymized")
+		}
+
+		previous := *user
+		user.Anonymize()
+		_, err = tx.ExecContext(ctx, `UPDATE users SET name = $2, email = $3, password = $4, active = $5, deactivated_at = $6,
+			anonymized_at = $7, updated_at = $8 WHERE id = $1`,
+			user.ID, user.Name, user.Email, user.Password, user.Active, user.DeactivatedAt, user.AnonymizedAt, user.UpdatedAt)
+		snapshot = &previous
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Restore replaces a user record with a previously taken snapshot
+func (r *PostgresUserRepository) Restore(ctx context.Context, user *models.User) error {
+	return r.exec(ctx, `UPDATE users SET name = $2, email = $3, phone = $4, password = $5, role = $6, active = $7,
+		password_reset_required = $8, deactivated_at = $9, anonymized_at = $10, updated_at = $11 WHERE id = $1`,
+		user.ID, user.Name, user.Email, user.Phone, user.Password, string(user.Role), user.Active,
+		user.PasswordResetRequired, user.DeactivatedAt, user.AnonymizedAt, user.UpdatedAt)
+}
+
+// Ping checks the database can be reached, for the readiness probe
+func (r *PostgresUserRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// exec runs a statement that changes one user, failing with "user not found" if it changed none
+func (r *PostgresUserRepository) exec(ctx context.Context, query string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return duplicateError(err)
+	}
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if changed == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+	"user-service/internal/models"
+)
+
+// ServiceKeyRepository defines the interface for service API key storage
+type ServiceKeyRepository interface {
+	Create(key *models.ServiceKey) error
+	GetByHash(keyHash string) (*models.ServiceKey, error)
+	List() ([]*models.ServiceKey, error)
+	Revoke(id string) error
+	MarkUsed(id string) error
+}
+
+// InMemoryServiceKeyRepository implements ServiceKeyRepository using in-memory storage
+type InMemoryServiceKeyRepository struct {
+	keys  map[string]*models.ServiceKey
+	mutex sync.RWMutex
+}
+
+// NewInMemoryServiceKeyRepository creates a new in-memory service key repository
+func NewInMemoryServiceKeyRepository() *InMemoryServiceKeyRepository {
+	return &InMemoryServiceKeyRepository{
+		keys: make(map[string]*models.ServiceKey),
+	}
+}
+
+// Create stores a new service key
+func (r *InMemoryServiceKeyRepository) Create(key *models.ServiceKey) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.keys {
+		if existing.KeyHash == key.KeyHash {
+			return errors.New("service key already exists")
+		}
+	}
+
+	r.keys[key.ID] = key
+	return nil
+}
+
+// GetByHash retrieves a key by the hash of its plaintext
+func (r *InMemoryServiceKeyRepository) GetByHash(keyHash string) (*models.ServiceKey, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, key := range r.keys {
+		if key.KeyHash == keyHash {
+			keyCopy := *key
+			return &keyCopy, nil
+		}
+	}
+
+	return nil, errors.New("service key not found")
+}
+
+// List returns all service keys, newest first
+func (r *InMemoryServiceKeyRepository) List() ([]*models.ServiceKey, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	keys := make([]*models.ServiceKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		keyCopy := *key
+		keys = append(keys, &keyCopy)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// Revoke invalidates a service key
+func (r *InMemoryServiceKeyRepository) Revoke(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key, exists := r.keys[id]
+	if !exists {
+		return errors.New("service key not found")
+	}
+
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+	}
+	return nil
+}
+
+// MarkUsed records the last time a key was presented
+func (r *InMemoryServiceKeyRepository) MarkUsed(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key, exists := r.keys[id]
+	if !exists {
+		return errors.New("service key not found")
+	}
+
+	now := time.Now()
+	key.LastUsedAt = &now
+	return nil
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+	"user-service/internal/models"
+)
+
+// SessionStore defines the interface for login session storage.
+// Sessions carry their own expiry so a key-value store with TTLs (e.g. Redis)
+// can implement it without a background sweeper.
+type SessionStore interface {
+	Create(session *models.Session) error
+	GetByToken(token string) (*models.Session, error)
+	ListByUser(userID string) ([]*models.Session, error)
+	Touch(id string) error
+	Revoke(id string) error
+	RevokeAllForUser(userID string) error
+}
+
+// InMemorySessionStore implements SessionStore using in-memory storage
+type InMemorySessionStore struct {
+	sessions map[string]*models.Session // keyed by session ID
+	byToken  map[string]string          // token -> session ID
+	mutex    sync.RWMutex
+}
+
+// NewInMemorySessionStore creates a new in-memory session store
+func NewInMemorySessionStore() *InMemorySessionStore {
+	return &InMemorySessionStore{
+		sessions: make(map[string]*models.Session),
+		byToken:  make(map[string]string),
+	}
+}
+
+// Create stores a new session
+func (s *InMemorySessionStore) Create(session *models.Session) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.byToken[session.Token]; exists {
+		return errors.New("session token already exists")
+	}
+
+	s.sessions[session.ID] = session
+	s.byToken[session.Token] = session.ID
+	return nil
+}
+
+// GetByToken retrieves the session for a bearer token
+func (s *InMemorySessionStore) GetByToken(token string) (*models.Session, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	id, exists := s.byToken[token]
+	if !exists {
+		return nil, errors.New("session not found")
+	}
+
+	sessionCopy := *s.sessions[id]
+	return &sessionCopy, nil
+}
+
+// ListByUser returns the user's sessions that have not expired, newest first
+func (s *InMemorySessionStore) ListByUser(userID string) ([]*models.Session, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now := time.Now()
+	sessions := make([]*models.Session, 0)
+	for _, session := range s.sessions {
+		if session.UserID == userID && now.Before(session.ExpiresAt) {
+			sessionCopy := *session
+			sessions = append(sessions, &sessionCopy)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+// Touch records activity on a session
+func (s *InMemorySessionStore) Touch(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return errors.New("session not found")
+	}
+
+	session.LastSeenAt = time.Now()
+	return nil
+}
+
+// Revoke invalidates a single session
+func (s *InMemorySessionStore) Revoke(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return errors.New("session not found")
+	}
+
+	if session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+	}
+	return nil
+}
+
+// RevokeAllForUser invalidates every session of a user
+func (s *InMemorySessionStore) RevokeAllForUser(userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+	}
+	return nil
+}
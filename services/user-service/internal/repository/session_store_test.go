@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"testing"
+	"time"
+	"user-service/internal/models"
+)
+
+func TestInMemorySessionStore_CreateAndRevoke(t *testing.T) {
+	store := NewInMemorySessionStore()
+	s1 := models.NewSession("u1", "token-1", "", "", time.Hour)
+	s2 := models.NewSession("u1", "token-2", "", "", time.Hour)
+	_ = store.Create(s1)
+	_ = store.Create(s2)
+
+	if err := store.Create(models.NewSession("u2", "token-1", "", "", time.Hour)); err == nil {
+		t.Error("expected duplicate token error")
+	}
+
+	got, err := store.GetByToken("token-1")
+	if err != nil || got.ID != s1.ID || !got.IsValid() {
+		t.Fatalf("expected valid session for token-1, got %v (%v)", got, err)
+	}
+
+	_ = store.Revoke(s1.ID)
+	got, _ = store.GetByToken("token-1")
+	if got.IsValid() {
+		t.Error("expected revoked session to be invalid")
+	}
+
+	_ = store.RevokeAllForUser("u1")
+	got, _ = store.GetByToken("token-2")
+	if got.IsValid() {
+		t.Error("expected all user sessions to be revoked")
+	}
+}
+
+func TestInMemorySessionStore_ListByUserSkipsExpired(t *testing.T) {
+	store := NewInMemorySessionStore()
+	_ = store.Create(models.NewSession("u1", "live", "", "", time.Hour))
+	_ = store.Create(models.NewSession("u1", "expired", "", "", -time.Minute))
+	_ = store.Create(models.NewSession("u2", "other", "", "", time.Hour))
+
+	sessions, err := store.ListByUser("u1")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Token != "live" {
+		t.Errorf("expected only the live session, got %d", len(sessions))
+	}
+}
@@ -1,26 +1,46 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"sync"
+	"time"
+	"ecommerce/pkg/snapshot"
 	"user-service/internal/models"
 )
 
-// UserRepository defines the interface for user data operations
+// UserRepository defines the interface for user data operations. Implementations backed by a
+// database should abandon a query once ctx is done.
 type UserRepository interface {
-	Create(user *models.User) error
-	GetByID(id string) (*models.User, error)
-	GetByEmail(email string) (*models.User, error)
-	Update(user *models.User) error
-	Delete(id string) error
-	List() ([]*models.User, error)
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id string) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByPhone(ctx context.Context, phone string) (*models.User, error)
+	Update(ctx context.Context, user *models.User) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context) ([]*models.User, error)
+	// Count returns how many users are stored, deactivated ones included
+	Count(ctx context.Context) (int, error)
+	SetActive(ctx context.Context, id string, active bool) error
+	UpdatePassword(ctx context.Context, id, password string) error
+	UpdateEmail(ctx context.Context, id, email string) error
+	SetPasswordResetRequired(ctx context.Context, id string, required bool) error
+	SetRole(ctx context.Context, id string, role models.Role) error
+	ListByRole(ctx context.Context, role models.Role) ([]*models.User, error)
+	Anonymize(ctx context.Context, id string) (*models.User, error)
+	Restore(ctx context.Context, user *models.User) error
 }
 
+// queryTimeout bounds each call to a database-backed repository within the caller's context
+const queryTimeout = 5 * time.Second
+
 // InMemoryUserRepository implements UserRepository using in-memory storage
 // In production, this would be replaced with a database implementation
 type InMemoryUserRepository struct {
 	users map[string]*models.User
 	mutex sync.RWMutex
+	// journal logs every change once PersistTo is called; nil otherwise
+	journal *snapshot.Store[userSnapshot, userChange]
 }
 
 // NewInMemoryUserRepository creates a new in-memory user repository
@@ -31,7 +51,7 @@ func NewInMemoryUserRepository() *InMemoryUserRepository {
 }
 
 // Create adds a new user to the repository
-func (r *InMemoryUserRepository) Create(user *models.User) error {
+func (r *InMemoryUserRepository) Create(ctx context.Context, user *models.User) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
@@ -40,14 +60,20 @@ func (r *InMemoryUserRepository) Create(user *models.User) error {
 		if existingUser.Email == user.Email {
 			return errors.New("user with this email already exists")
 		}
+		if user.Phone != "" && existingUser.Phone == user.Phone {
+			return errors.New("user with this phone already exists")
+		}
 	}
 
-	r.users[user.ID] = user
+	// Store a copy so callers can't mutate the stored record (e.g. by clearing the password)
+	userCopy := *user
+	r.users[user.ID] = &userCopy
+	r.logPut(&userCopy)
 	return nil
 }
 
 // GetByID retrieves a user by their ID
-func (r *InMemoryUserRepository) GetByID(id string) (*models.User, error) {
+func (r *InMemoryUserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
@@ -63,7 +89,7 @@ func (r *InMemoryUserRepository) GetByID(id string) (*models.User, error) {
 }
 
 // GetByEmail retrieves a user by their email address
-func (r *InMemoryUserRepository) GetByEmail(email string) (*models.User, error) {
+func (r *InMemoryUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
@@ -76,8 +102,28 @@ func (r *InMemoryUserRepository) GetByEmail(email string) (*models.User, error)
 	return nil, errors.New("user not found")
 }
 
+// GetByPhone retrieves a user by their phone number
+func (r *InMemoryUserRepository) GetByPhone(ctx context.Context, phone string) (*models.User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if phone == "" {
+		return nil, errors.New("user not found")
+	}
+
+	for _, user := range r.users {
+		if user.Phone == phone {
+			userCopy := *user
+			userCopy.Password = "" // Don't return password
+			return &userCopy, nil
+		}
+	}
+
+	return nil, errors.New("user not found")
+}
+
 // Update modifies an existing user
-func (r *InMemoryUserRepository) Update(user *models.User) error {
+func (r *InMemoryUserRepository) Update(ctx context.Context, user *models.User) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
@@ -86,11 +132,12 @@ func (r *InMemoryUserRepository) Update(user *models.User) error {
 	}
 
 	r.users[user.ID] = user
+	r.logPut(user)
 	return nil
 }
 
 // Delete removes a user from the repository
-func (r *InMemoryUserRepository) Delete(id string) error {
+func (r *InMemoryUserRepository) Delete(ctx context.Context, id string) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
@@ -99,11 +146,12 @@ func (r *InMemoryUserRepository) Delete(id string) error {
 	}
 
 	delete(r.users, id)
+	r.logDelete(id)
 	return nil
 }
 
 // List returns all users (without passwords)
-func (r *InMemoryUserRepository) List() ([]*models.User, error) {
+func (r *InMemoryUserRepository) List(ctx context.Context) ([]*models.User, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
@@ -116,3 +164,156 @@ func (r *InMemoryUserRepository) List() ([]*models.User, error) {
 
 	return users, nil
 }
+
+// Count returns how many users are stored
+func (r *InMemoryUserRepository) Count(ctx context.Context) (int, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return len(r.users), nil
+}
+
+// SetActive deactivates or reactivates a user account without removing it
+func (r *InMemoryUserRepository) SetActive(ctx context.Context, id string, active bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return errors.New("user not found")
+	}
+
+	now := time.Now()
+	user.Active = active
+	user.UpdatedAt = now
+	if active {
+		user.DeactivatedAt = nil
+	} else {
+		user.DeactivatedAt = &now
+	}
+	r.logPut(user)
+	return nil
+}
+
+// UpdatePassword replaces a user's password
+func (r *InMemoryUserRepository) UpdatePassword(ctx context.Context, id, password string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return errors.New("user not found")
+	}
+
+	user.Password = password // In production, this should be hashed
+	user.PasswordResetRequired = false
+	user.UpdatedAt = time.Now()
+	r.logPut(user)
+	return nil
+}
+
+// UpdateEmail replaces a user's email, failing if another user already has it
+func (r *InMemoryUserRepository) UpdateEmail(ctx context.Context, id, email string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return errors.New("user not found")
+	}
+
+	for _, existingUser := range r.users {
+		if existingUser.ID != id && existingUser.Email == email {
+			return errors.New("user with this email already exists")
+		}
+	}
+
+	user.Email = email
+	user.UpdatedAt = time.Now()
+	r.logPut(user)
+	return nil
+}
+
+// SetPasswordResetRequired flags or unflags a user as needing a password reset before login
+func (r *InMemoryUserRepository) SetPasswordResetRequired(ctx context.Context, id string, required bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return errors.New("user not found")
+	}
+
+	user.PasswordResetRequired = required
+	user.UpdatedAt = time.Now()
+	r.logPut(user)
+	return nil
+}
+
+// SetRole changes a user's role
+func (r *InMemoryUserRepository) SetRole(ctx context.Context, id string, role models.Role) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return errors.New("user not found")
+	}
+
+	user.Role = role
+	user.UpdatedAt = time.Now()
+	r.logPut(user)
+	return nil
+}
+
+// ListByRole returns all users with the given role (without passwords)
+func (r *InMemoryUserRepository) ListByRole(ctx context.Context, role models.Role) ([]*models.User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	users := make([]*models.User, 0)
+	for _, user := range r.users {
+		if user.Role == role {
+			userCopy := *user
+			userCopy.Password = "" // Don't return passwords
+			users = append(users, &userCopy)
+		}
+	}
+
+	return users, nil
+}
+
+// Anonymize scrubs a user's personal data in place and returns a full snapshot
+// of the previous record (including password) so the change can be compensated
+func (r *InMemoryUserRepository) Anonymize(ctx context.Context, id string) (*models.User, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return nil, errors.New("user not found")
+	}
+	if user.AnonymizedAt != nil {
+		return nil, errors.New("user already anonymized")
+	}
+
+	snapshot := *user
+	user.Anonymize()
+	r.logPut(user)
+	return &snapshot, nil
+}
+
+// Restore replaces a user record with a previously taken snapshot
+func (r *InMemoryUserRepository) Restore(ctx context.Context, user *models.User) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.users[user.ID]; !exists {
+		return errors.New("user not found")
+	}
+
+	snapshot := *user
+	r.users[user.ID] = &snapshot
+	r.logPut(&snapshot)
+	return nil
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"log/slog"
+	"ecommerce/pkg/snapshot"
+	"user-service/internal/models"
+)
+
+// userSnapshot is the state of an InMemoryUserRepository as saved on disk
+type userSnapshot struct {
+	Users map[string]*models.User
+}
+
+// userChange is one logged change: a user as it now stands, or the ID of a deleted one
+type userChange struct {
+	User      *models.User
+	DeletedID string
+}
+
+// PersistTo keeps the repository's users in dir, so they survive a restart. Users saved there
+// before replace the ones in memory; a fresh directory starts from the current users.
+// Call it before the repository is used.
+func (r *InMemoryUserRepository) PersistTo(dir string) error {
+	journal, err := snapshot.Open[userSnapshot, userChange](dir, "users")
+	if err != nil {
+		return err
+	}
+	saved, changes, err := journal.Load()
+	if err != nil {
+		journal.Close()
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if saved != nil {
+		r.users = saved.Users
+		if r.users == nil {
+			r.users = make(map[string]*models.User)
+		}
+	}
+	for _, change := range changes {
+		if change.User != nil {
+			r.users[change.User.ID] = change.User
+		} else {
+			delete(r.users, change.DeletedID)
+		}
+	}
+
+	r.journal = journal
+	return r.journal.Save(&userSnapshot{Users: r.users})
+}
+
+// Snapshot saves every user to disk, emptying the change log. It does nothing unless PersistTo was called.
+func (r *InMemoryUserRepository) Snapshot() error {
+	if r.journal == nil {
+		return nil
+	}
+	// Changes are logged under the write lock, so none slips in while the snapshot is saved
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.journal.Save(&userSnapshot{Users: r.users})
+}
+
+// Close takes a last snapshot and closes the files PersistTo opened
+func (r *InMemoryUserRepository) Close() error {
+	if r.journal == nil {
+		return nil
+	}
+	err := r.Snapshot()
+	if closeErr := r.journal.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// logPut logs a user's current state. Callers hold the write lock.
+func (r *InMemoryUserRepository) logPut(user *models.User) {
+	r.log(userChange{User: user})
+}
+
+// logDelete logs a user's removal. Callers hold the write lock.
+func (r *InMemoryUserRepository) logDelete(id string) {
+	r.log(userChange{DeletedID: id})
+}
+
+// log appends a change to the journal. A failed write is logged rather than failing the change,
+// which is already made in memory; the next snapshot still saves it.
+func (r *InMemoryUserRepository) log(change userChange) {
+	if r.journal == nil {
+		return
+	}
+	if err := r.journal.Append(change); err != nil {
+		slog.Error("Error logging a user change; it is kept until the next snapshot", "error", err)
+	}
+}
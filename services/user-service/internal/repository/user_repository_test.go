@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"testing"
 	"user-service/internal/models"
 )
@@ -9,17 +10,17 @@ func TestInMemoryUserRepository_CreateAndGet(t *testing.T) {
 	repo := NewInMemoryUserRepository()
 	user := models.NewUser("Alice", "alice@example.com", "password123")
 
-	if err := repo.Create(user); err != nil {
+	if err := repo.Create(context.Background(), user); err != nil {
 		t.Errorf("expected create success, got error: %v", err)
 	}
 
 	// Duplicate email
 	dup := models.NewUser("Alice2", "alice@example.com", "pass")
-	if err := repo.Create(dup); err == nil {
+	if err := repo.Create(context.Background(), dup); err == nil {
 		t.Error("expected duplicate email error, got nil")
 	}
 
-	fetched, err := repo.GetByID(user.ID)
+	fetched, err := repo.GetByID(context.Background(), user.ID)
 	if err != nil {
 		t.Fatalf("expected fetch success, got error: %v", err)
 	}
@@ -34,41 +35,171 @@ func TestInMemoryUserRepository_CreateAndGet(t *testing.T) {
 func TestInMemoryUserRepository_UpdateAndDelete(t *testing.T) {
 	repo := NewInMemoryUserRepository()
 	user := models.NewUser("Bob", "bob@example.com", "password")
-	_ = repo.Create(user)
+	_ = repo.Create(context.Background(), user)
 
 	user.Name = "Bob Updated"
-	if err := repo.Update(user); err != nil {
+	if err := repo.Update(context.Background(), user); err != nil {
 		t.Fatalf("update failed: %v", err)
 	}
 
-	fetched, _ := repo.GetByID(user.ID)
+	fetched, _ := repo.GetByID(context.Background(), user.ID)
 	if fetched.Name != "Bob Updated" {
 		t.Errorf("expected updated name, got %s", fetched.Name)
 	}
 
-	if err := repo.Delete(user.ID); err != nil {
+	if err := repo.Delete(context.Background(), user.ID); err != nil {
 		t.Fatalf("delete failed: %v", err)
 	}
-	if _, err := repo.GetByID(user.ID); err == nil {
+	if _, err := repo.GetByID(context.Background(), user.ID); err == nil {
 		t.Error("expected error fetching deleted user")
 	}
 }
 
 func TestInMemoryUserRepository_List(t *testing.T) {
 	repo := NewInMemoryUserRepository()
-	_ = repo.Create(models.NewUser("A", "a@example.com", "p"))
-	_ = repo.Create(models.NewUser("B", "b@example.com", "p"))
+	_ = repo.Create(context.Background(), models.NewUser("A", "a@example.com", "p"))
+	_ = repo.Create(context.Background(), models.NewUser("B", "b@example.com", "p"))
 
-	users, err := repo.List()
+	users, err := repo.List(context.Background())
 	if err != nil {
 		t.Fatalf("list failed: %v", err)
 	}
 	if len(users) != 2 {
 		t.Errorf("expected 2 users, got %d", len(users))
 	}
+	if count, _ := repo.Count(context.Background()); count != 2 {
+		t.Errorf("expected a count of 2, got %d", count)
+	}
 	for _, u := range users {
 		if u.Password != "" {
 			t.Error("expected stripped password in list")
 		}
 	}
 }
+
+func TestInMemoryUserRepository_SetActive(t *testing.T) {
+	repo := NewInMemoryUserRepository()
+	user := models.NewUser("Carol", "carol@example.com", "password")
+	_ = repo.Create(context.Background(), user)
+
+	if err := repo.SetActive(context.Background(), user.ID, false); err != nil {
+		t.Fatalf("deactivate failed: %v", err)
+	}
+	fetched, err := repo.GetByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("expected deactivated user to still exist: %v", err)
+	}
+	if fetched.Active || fetched.DeactivatedAt == nil {
+		t.Error("expected user to be inactive with deactivation timestamp")
+	}
+
+	_ = repo.SetActive(context.Background(), user.ID, true)
+	fetched, _ = repo.GetByID(context.Background(), user.ID)
+	if !fetched.Active || fetched.DeactivatedAt != nil {
+		t.Error("expected user to be reactivated")
+	}
+
+	if err := repo.SetActive(context.Background(), "missing", false); err == nil {
+		t.Error("expected error for unknown user")
+	}
+}
+
+func TestInMemoryUserRepository_AnonymizeAndRestore(t *testing.T) {
+	repo := NewInMemoryUserRepository()
+	user := models.NewUser("Dave", "dave@example.com", "password")
+	_ = repo.Create(context.Background(), user)
+
+	snapshot, err := repo.Anonymize(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("anonymize failed: %v", err)
+	}
+	if snapshot.Email != "dave@example.com" || snapshot.Password != "password" {
+		t.Error("expected snapshot to hold the original record")
+	}
+	if _, err := repo.GetByEmail(context.Background(), "dave@example.com"); err == nil {
+		t.Error("expected original email to be gone after anonymization")
+	}
+	if _, err := repo.Anonymize(context.Background(), user.ID); err == nil {
+		t.Error("expected error anonymizing twice")
+	}
+
+	if err := repo.Restore(context.Background(), snapshot); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	restored, err := repo.GetByEmail(context.Background(), "dave@example.com")
+	if err != nil || restored.Password != "password" {
+		t.Error("expected original record to be restored")
+	}
+}
+
+func TestInMemoryUserRepository_SetRoleAndListByRole(t *testing.T) {
+	repo := NewInMemoryUserRepository()
+	user := models.NewUser("Erin", "erin@example.com", "password")
+	_ = repo.Create(context.Background(), user)
+	_ = repo.Create(context.Background(), models.NewUser("Frank", "frank@example.com", "password"))
+
+	if err := repo.SetRole(context.Background(), user.ID, models.RoleAdmin); err != nil {
+		t.Fatalf("set role failed: %v", err)
+	}
+	admins, _ := repo.ListByRole(context.Background(), models.RoleAdmin)
+	if len(admins) != 1 || admins[0].ID != user.ID || admins[0].Password != "" {
+		t.Fatalf("expected one admin without password, got %+v", admins)
+	}
+	customers, _ := repo.ListByRole(context.Background(), models.RoleCustomer)
+	if len(customers) != 1 {
+		t.Errorf("expected one customer got %d", len(customers))
+	}
+
+	if err := repo.SetRole(context.Background(), "missing", models.RoleAdmin); err == nil {
+		t.Error("expected error for unknown user")
+	}
+}
+
+func TestInMemoryUserRepository_UpdateEmail(t *testing.T) {
+	repo := NewInMemoryUserRepository()
+	user := models.NewUser("Gina", "gina@example.com", "password")
+	_ = repo.Create(context.Background(), user)
+	_ = repo.Create(context.Background(), models.NewUser("Hank", "hank@example.com", "password"))
+
+	if err := repo.UpdateEmail(context.Background(), user.ID, "hank@example.com"); err == nil {
+		t.Error("expected error taking another user's email")
+	}
+	if err := repo.UpdateEmail(context.Background(), user.ID, "gina2@example.com"); err != nil {
+		t.Fatalf("update email failed: %v", err)
+	}
+	if _, err := repo.GetByEmail(context.Background(), "gina2@example.com"); err != nil {
+		t.Error("expected user to be found by new email")
+	}
+}
+
+func TestInMemoryUserRepository_PersistsAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	repo := NewInMemoryUserRepository()
+	if err := repo.PersistTo(dir); err != nil {
+		t.Fatalf("persist: %v", err)
+	}
+	alice := models.NewUser("Alice", "alice@example.com", "hashed")
+	bob := models.NewUser("Bob", "bob@example.com", "hashed")
+	repo.Create(context.Background(), alice)
+	repo.Create(context.Background(), bob)
+	repo.Snapshot()
+	// Changes after the snapshot come back from the log
+	repo.SetRole(context.Background(), alice.ID, models.RoleAdmin)
+	repo.Delete(context.Background(), bob.ID)
+
+	restarted := NewInMemoryUserRepository()
+	if err := restarted.PersistTo(dir); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	defer restarted.Close()
+	stored, err := restarted.GetByEmail(context.Background(), "alice@example.com")
+	if err != nil {
+		t.Fatalf("expected alice to survive the restart, got %v", err)
+	}
+	if stored.Role != models.RoleAdmin || stored.Password != "hashed" {
+		t.Errorf("expected the logged role change and the password kept, got %+v", stored)
+	}
+	if _, err := restarted.GetByID(context.Background(), bob.ID); err == nil {
+		t.Error("expected the logged delete to be replayed")
+	}
+}
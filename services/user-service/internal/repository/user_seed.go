@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"ecommerce/pkg/seed"
+	"user-service/internal/models"
+)
+
+// UserFixture is a user as written in the "users" section of a seed file
+type UserFixture struct {
+	ID       string      `json:"id"`
+	Name     string      `json:"name"`
+	Email    string      `json:"email"`
+	Phone    string      `json:"phone"`
+	Password string      `json:"password"`
+	Role     models.Role `json:"role"` // customer when empty
+}
+
+// SeedUsers creates the users in the seed file at path that the repository doesn't have yet, by
+// ID or email, and returns how many it created. Fixtures need an ID, so seeding a store twice
+// creates nothing the second time.
+func SeedUsers(ctx context.Context, repo UserRepository, path string) (int, error) {
+	var fixtures []UserFixture
+	if err := seed.Load(path, "users", &fixtures); err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for i, fixture := range fixtures {
+		if fixture.ID == "" {
+			return created, fmt.Errorf("user fixture %d has no id", i+1)
+		}
+		if _, err := repo.GetByID(ctx, fixture.ID); err == nil {
+			continue
+		}
+		if _, err := repo.GetByEmail(ctx, fixture.Email); err == nil {
+			continue
+		}
+
+		user := models.NewUser(fixture.Name, fixture.Email, fixture.Password)
+		user.ID = fixture.ID
+		user.Phone = fixture.Phone
+		if fixture.Role != "" {
+			user.Role = fixture.Role
+		}
+		if user.Email == "" || user.Password == "" || !models.IsValidRole(user.Role) {
+			return created, fmt.Errorf("user fixture %s needs an email, a password, and a known role", fixture.ID)
+		}
+		if err := repo.Create(ctx, user); err != nil {
+			return created, fmt.Errorf("user fixture %s: %w", fixture.ID, err)
+		}
+		created++
+	}
+	return created, nil
+}
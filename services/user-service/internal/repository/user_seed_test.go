@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"user-service/internal/models"
+)
+
+// demoFixtures is the sample data shared by the services
+const demoFixtures = "../../../../fixtures/demo.yaml"
+
+func TestSeedUsers_CreatesMissingUsersOnce(t *testing.T) {
+	repo := NewInMemoryUserRepository()
+
+	created, err := SeedUsers(context.Background(), repo, demoFixtures)
+	if err != nil || created != 1 {
+		t.Fatalf("expected the demo customer created, got %d, %v", created, err)
+	}
+	user, err := repo.GetByEmail(context.Background(), "customer@example.com")
+	if err != nil || user.ID != "user-demo-customer" || user.Role != models.RoleCustomer {
+		t.Fatalf("expected the demo customer, got %+v, %v", user, err)
+	}
+
+	if created, err := SeedUsers(context.Background(), repo, demoFixtures); err != nil || created != 0 {
+		t.Errorf("expected nothing created the second time, got %d, %v", created, err)
+	}
+}
+
+func TestSeedUsers_RejectsUnknownRoles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.yaml")
+	os.WriteFile(path, []byte("users:\n  - id: u1\n    email: root@example.com\n    password: secret\n    role: superuser\n"), 0o644)
+
+	if _, err := SeedUsers(context.Background(), NewInMemoryUserRepository(), path); err == nil {
+		t.Error("expected an unknown role to be rejected")
+	}
+}
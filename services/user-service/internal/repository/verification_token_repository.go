@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+	"time"
+	"user-service/internal/models"
+)
+
+// VerificationTokenRepository defines the interface for single-use verification tokens
+type VerificationTokenRepository interface {
+	Create(token *models.VerificationToken) error
+	Consume(purpose models.TokenPurpose, tokenHash string) (*models.VerificationToken, error)
+	InvalidateForUser(purpose models.TokenPurpose, userID string) error
+}
+
+// InMemoryVerificationTokenRepository implements VerificationTokenRepository using in-memory storage
+type InMemoryVerificationTokenRepository struct {
+	tokens map[string]*models.VerificationToken // keyed by token hash
+	mutex  sync.Mutex
+}
+
+// NewInMemoryVerificationTokenRepository creates a new in-memory verification token repository
+func NewInMemoryVerificationTokenRepository() *InMemoryVerificationTokenRepository {
+	return &InMemoryVerificationTokenRepository{
+		tokens: make(map[string]*models.VerificationToken),
+	}
+}
+
+// Create stores a new token
+func (r *InMemoryVerificationTokenRepository) Create(token *models.VerificationToken) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.tokens[token.TokenHash]; exists {
+		return errors.New("token already exists")
+	}
+
+	r.tokens[token.TokenHash] = token
+	return nil
+}
+
+// Consume atomically marks a usable token as used and returns it
+func (r *InMemoryVerificationTokenRepository) Consume(purpose models.TokenPurpose, tokenHash string) (*models.VerificationToken, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token, exists := r.tokens[tokenHash]
+	if !exists || token.Purpose != purpose || !token.IsUsable() {
+		return nil, errors.New("invalid or expired token")
+	}
+
+	now := time.Now()
+	token.UsedAt = &now
+
+	tokenCopy := *token
+	return &tokenCopy, nil
+}
+
+// InvalidateForUser marks all outstanding tokens of a purpose for a user as used
+func (r *InMemoryVerificationTokenRepository) InvalidateForUser(purpose models.TokenPurpose, userID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.Purpose == purpose && token.UsedAt == nil {
+			token.UsedAt = &now
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"testing"
+	"time"
+	"user-service/internal/models"
+)
+
+func TestInMemoryVerificationTokenRepository_ConsumeOnce(t *testing.T) {
+	repo := NewInMemoryVerificationTokenRepository()
+	token := models.NewVerificationToken(models.TokenPurposePasswordReset, "user-1", "hash", "", time.Hour)
+	if err := repo.Create(token); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	consumed, err := repo.Consume(models.TokenPurposePasswordReset, "hash")
+	if err != nil || consumed.UserID != "user-1" {
+		t.Fatalf("expected token to be consumed, got %v", err)
+	}
+	if _, err := repo.Consume(models.TokenPurposePasswordReset, "hash"); err == nil {
+		t.Error("expected second consume to fail")
+	}
+}
+
+func TestInMemoryVerificationTokenRepository_RejectsExpiredAndInvalidated(t *testing.T) {
+	repo := NewInMemoryVerificationTokenRepository()
+	_ = repo.Create(models.NewVerificationToken(models.TokenPurposePasswordReset, "user-1", "expired", "", -time.Minute))
+	_ = repo.Create(models.NewVerificationToken(models.TokenPurposePasswordReset, "user-1", "old", "", time.Hour))
+
+	if _, err := repo.Consume(models.TokenPurposePasswordReset, "expired"); err == nil {
+		t.Error("expected expired token to be rejected")
+	}
+
+	_ = repo.InvalidateForUser(models.TokenPurposePasswordReset, "user-1")
+	if _, err := repo.Consume(models.TokenPurposePasswordReset, "old"); err == nil {
+		t.Error("expected invalidated token to be rejected")
+	}
+}